@@ -0,0 +1,1137 @@
+//go:build cgo
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+
+	"github.com/neo-2022/openclaw-memory/agent-service/internal/breaker"
+	"github.com/neo-2022/openclaw-memory/agent-service/internal/clarify"
+	"github.com/neo-2022/openclaw-memory/agent-service/internal/config"
+	"github.com/neo-2022/openclaw-memory/agent-service/internal/db"
+	"github.com/neo-2022/openclaw-memory/agent-service/internal/llm"
+	"github.com/neo-2022/openclaw-memory/agent-service/internal/maintenance"
+	"github.com/neo-2022/openclaw-memory/agent-service/internal/models"
+	"github.com/neo-2022/openclaw-memory/agent-service/internal/progress"
+	"github.com/neo-2022/openclaw-memory/agent-service/internal/rag"
+	"github.com/neo-2022/openclaw-memory/agent-service/internal/recipe"
+	"github.com/neo-2022/openclaw-memory/agent-service/internal/retry"
+	"github.com/neo-2022/openclaw-memory/agent-service/internal/taskreport"
+	"github.com/neo-2022/openclaw-memory/agent-service/internal/tools/toolstest"
+	"github.com/neo-2022/openclaw-memory/agent-service/internal/watchdog"
+)
+
+// setupChat — окружение chatHandler без сети: SQLite во временном каталоге,
+// тестовый tools-service, агент admin с провайдером MockProvider по сценарию.
+func setupChat(t *testing.T, script ...llm.MockResponse) (*llm.MockProvider, *toolstest.Server) {
+	t.Helper()
+	tools := toolstest.NewServer()
+	t.Cleanup(tools.Close)
+
+	prevCfg, prevDB := config.Current(), db.DB
+	t.Cleanup(func() { config.Set(prevCfg); db.DB = prevDB })
+	cfg := config.Defaults()
+	cfg.ToolsServiceURL = tools.URL
+	cfg.BrowserServiceURL = tools.URL
+	cfg.MemoryServiceURL = tools.URL
+	cfg.LearningsEnabled = false
+	cfg.TitleEnabled = false
+	cfg.BackupEnabled = false
+	cfg.ArtifactsDir = t.TempDir()
+	config.Set(cfg)
+
+	gdb, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "agent.db")), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("открытие SQLite: %v", err)
+	}
+	if err := db.Migrate(gdb); err != nil {
+		t.Fatalf("миграция: %v", err)
+	}
+	db.DB = gdb
+	agent := models.Agent{Name: "admin", Prompt: "Ты тестовый агент", Provider: "mock", LLMModel: "mock-model", SupportsTools: true, ToolsEnabled: true}
+	if err := gdb.Create(&agent).Error; err != nil {
+		t.Fatalf("агент: %v", err)
+	}
+
+	providerBreakers = breaker.NewSet(cfg.ProviderBreakerFailures, cfg.ProviderBreakerReset)
+
+	provider := llm.NewMockProvider("mock", script...)
+	llm.GlobalRegistry.Register(provider)
+	return provider, tools
+}
+
+// postChat — запрос к chatHandler и разобранный ответ.
+func postChat(t *testing.T, body ChatRequest) ChatResponse {
+	t.Helper()
+	data, _ := json.Marshal(body)
+	r := httptest.NewRequest(http.MethodPost, "/chat", bytes.NewReader(data))
+	r.Header.Set("X-Request-ID", "test-"+strings.ReplaceAll(t.Name(), "/", "-"))
+	w := httptest.NewRecorder()
+	chatHandler(w, r)
+	var resp ChatResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("ответ %d: %s", w.Code, w.Body.String())
+	}
+	return resp
+}
+
+// TestChatToolLoop — вызов инструмента в каждом из четырёх форматов проходит
+// полный цикл: разбор, вызов tools-service, результат в контексте модели, ответ.
+func TestChatToolLoop(t *testing.T) {
+	args := map[string]interface{}{"path": "/srv/readme.txt"}
+	formats := map[string]llm.MockResponse{
+		"structured": llm.MockToolCall("read", args),
+		"json":       llm.MockJSONToolCall("read", args),
+		"xml":        llm.MockXMLToolCall("read", args),
+		"inline":     llm.MockInlineToolCall("read", args),
+	}
+	for name, call := range formats {
+		t.Run(name, func(t *testing.T) {
+			provider, tools := setupChat(t, call, llm.MockText("В файле написано hello"))
+			tools.Handle("/read", toolstest.Reply(map[string]interface{}{"content": "hello"}))
+
+			resp := postChat(t, ChatRequest{Agent: "admin", Messages: []llm.Message{{Role: "user", Content: "Прочитай /srv/readme.txt"}}, NoCache: true})
+			if resp.Error != "" || resp.Response != "В файле написано hello" {
+				t.Fatalf("ответ: %+v", resp)
+			}
+			if calls := tools.Calls(); len(calls) == 0 || calls[len(calls)-1].Path != "/read" || calls[len(calls)-1].Args["path"] != "/srv/readme.txt" {
+				t.Errorf("вызовы tools-service: %+v", calls)
+			}
+			if resp.Status != taskreport.StatusCompleted || len(resp.ActionsTaken) != 1 || resp.ActionsTaken[0].Tool != "read" {
+				t.Errorf("итог: %s %+v", resp.Status, resp.ActionsTaken)
+			}
+			reqs := provider.Requests()
+			if len(reqs) != 2 || provider.Remaining() != 0 {
+				t.Fatalf("вызовов модели %d, осталось шагов %d", len(reqs), provider.Remaining())
+			}
+			last := reqs[1].Messages[len(reqs[1].Messages)-1]
+			if last.Role != "tool" || !strings.Contains(last.Content, "hello") {
+				t.Errorf("результат инструмента в контексте модели: %+v", last)
+			}
+		})
+	}
+}
+
+// TestChatToolError — ошибка tools-service попадает модели и в итог partial.
+func TestChatToolError(t *testing.T) {
+	_, tools := setupChat(t, llm.MockToolCall("read", map[string]interface{}{"path": "/nope"}), llm.MockText("Файл не найден"))
+	tools.Handle("/read", func(map[string]interface{}) (int, interface{}) {
+		return http.StatusNotFound, map[string]interface{}{"code": "NOT_FOUND", "message": "файл не найден"}
+	})
+	resp := postChat(t, ChatRequest{Agent: "admin", Messages: []llm.Message{{Role: "user", Content: "Прочитай /nope"}}, NoCache: true})
+	if resp.Status != taskreport.StatusPartial || len(resp.ActionsTaken) != 1 || resp.ActionsTaken[0].Error != "файл не найден" {
+		t.Errorf("итог: %s %+v", resp.Status, resp.ActionsTaken)
+	}
+	// Ошибка инструмента учитывается в ранжировании моделей роли (list_models_for_role)
+	var saved models.Message
+	db.DB.First(&saved, resp.MessageID)
+	if saved.ToolCalls != 1 || saved.ToolErrors != 1 || saved.LatencyMs <= 0 {
+		t.Errorf("замеры ответа: вызовов %d, ошибок %d, %d мс", saved.ToolCalls, saved.ToolErrors, saved.LatencyMs)
+	}
+}
+
+// TestChatMultipleTextToolCalls — все вызовы из текстового ответа выполняются
+// по порядку, результат каждого — отдельное сообщение перед следующим раундом.
+func TestChatMultipleTextToolCalls(t *testing.T) {
+	content := llm.MockXMLToolCall("read", map[string]interface{}{"path": "/a"}).Content + "\n" +
+		llm.MockXMLToolCall("read", map[string]interface{}{"path": "/b"}).Content
+	provider, tools := setupChat(t, llm.MockText(content), llm.MockText("Прочитал оба файла"))
+	tools.Handle("/read", func(args map[string]interface{}) (int, interface{}) {
+		return http.StatusOK, map[string]interface{}{"content": "файл " + args["path"].(string)}
+	})
+
+	resp := postChat(t, ChatRequest{Agent: "admin", Messages: []llm.Message{{Role: "user", Content: "Прочитай /a и /b"}}, NoCache: true})
+	if resp.Response != "Прочитал оба файла" || len(resp.ActionsTaken) != 2 {
+		t.Fatalf("ответ: %+v", resp)
+	}
+	var calls []toolstest.Call
+	for _, c := range tools.Calls() {
+		if c.Path == "/read" {
+			calls = append(calls, c)
+		}
+	}
+	if len(calls) != 2 || calls[0].Args["path"] != "/a" || calls[1].Args["path"] != "/b" {
+		t.Errorf("вызовы tools-service: %+v", calls)
+	}
+	reqs := provider.Requests()
+	if len(reqs) != 2 {
+		t.Fatalf("вызовов модели %d", len(reqs))
+	}
+	msgs := reqs[1].Messages
+	results := msgs[len(msgs)-2:]
+	if msgs[len(msgs)-3].Role != "assistant" ||
+		results[0].ToolCallID != "xml-0" || !strings.Contains(results[0].Content, "файл /a") ||
+		results[1].ToolCallID != "xml-1" || !strings.Contains(results[1].Content, "файл /b") {
+		t.Errorf("результаты в контексте модели: %+v", msgs[len(msgs)-3:])
+	}
+}
+
+// TestChatToolArgumentValidation — вызов без обязательного аргумента не доходит
+// до tools-service, модель получает ошибки схемы и подсказку и исправляет вызов.
+func TestChatToolArgumentValidation(t *testing.T) {
+	provider, tools := setupChat(t,
+		llm.MockToolCall("read", map[string]interface{}{"file": "/a"}),
+		llm.MockToolCall("read", map[string]interface{}{"path": "/a"}),
+		llm.MockText("Прочитал"))
+	tools.Handle("/read", toolstest.Reply(map[string]interface{}{"content": "hello"}))
+
+	resp := postChat(t, ChatRequest{Agent: "admin", Messages: []llm.Message{{Role: "user", Content: "Прочитай /a"}}, NoCache: true})
+	if resp.Response != "Прочитал" || len(resp.ActionsTaken) != 2 || resp.ActionsTaken[0].Status != taskreport.ActionError {
+		t.Fatalf("ответ: %+v", resp)
+	}
+	var reads int
+	for _, c := range tools.Calls() {
+		if c.Path == "/read" {
+			reads++
+		}
+	}
+	if reads != 1 {
+		t.Errorf("вызовов /read: %d, ожидался один корректный", reads)
+	}
+	msgs := provider.Requests()[1].Messages
+	if res := msgs[len(msgs)-2]; res.Role != "tool" || !strings.Contains(res.Content, "validation_errors") || !strings.Contains(res.Content, "path") {
+		t.Errorf("ошибка схемы в контексте модели: %s", res.Content)
+	}
+	if note := msgs[len(msgs)-1]; note.Role != "system" || !strings.Contains(note.Content, "попытка 1 из 2") || !strings.Contains(note.Content, `"required":["path"]`) {
+		t.Errorf("подсказка для исправления: %+v", note)
+	}
+}
+
+// TestChatReasoning — размышления отделяются от ответа и возвращаются только
+// по include_reasoning.
+func TestChatReasoning(t *testing.T) {
+	setupChat(t, llm.MockText("<think>Пользователь здоровается</think>Привет!"), llm.MockText("<reasoning>снова</reasoning>Привет ещё раз!"))
+	msgs := []llm.Message{{Role: "user", Content: "Привет"}}
+	resp := postChat(t, ChatRequest{Agent: "admin", Messages: msgs, NoCache: true, IncludeReasoning: true})
+	if resp.Response != "Привет!" || resp.Reasoning != "Пользователь здоровается" {
+		t.Errorf("с размышлениями: %q / %q", resp.Response, resp.Reasoning)
+	}
+	resp = postChat(t, ChatRequest{Agent: "admin", Messages: msgs, NoCache: true})
+	if resp.Response != "Привет ещё раз!" || resp.Reasoning != "" {
+		t.Errorf("без размышлений: %q / %q", resp.Response, resp.Reasoning)
+	}
+}
+
+// TestChatProviderBreaker — после серии ошибок провайдер отключается, и
+// запросы сразу уходят резервной модели, не дожидаясь ошибок и повторов.
+func TestChatProviderBreaker(t *testing.T) {
+	fallback, _ := setupChat(t, llm.MockText("Ответ резервной модели"))
+	cfg := *config.Current()
+	cfg.LLMRetryMaxAttempts = 1
+	cfg.ProviderBreakerFailures = 2
+	cfg.ProviderFallbackProvider, cfg.ProviderFallbackModel = "mock", "mock-fallback"
+	config.Set(&cfg)
+	down := llm.MockResponse{Err: &retry.HTTPError{Provider: "Mock", Status: 503, Message: "недоступен"}}
+	dead := llm.NewMockProvider("mockdown", down, down, down)
+	llm.GlobalRegistry.Register(dead)
+	if err := db.DB.Model(&models.Agent{}).Where("name = ?", "admin").Update("provider", "mockdown").Error; err != nil {
+		t.Fatal(err)
+	}
+
+	msgs := []llm.Message{{Role: "user", Content: "Привет"}}
+	for i := 0; i < 2; i++ {
+		if resp := postChat(t, ChatRequest{Agent: "admin", Messages: msgs, NoCache: true}); resp.Error == "" {
+			t.Fatalf("запрос %d: ожидалась ошибка провайдера, ответ %q", i+1, resp.Response)
+		}
+	}
+	resp := postChat(t, ChatRequest{Agent: "admin", Messages: msgs, NoCache: true})
+	if resp.Response != "Ответ резервной модели" {
+		t.Errorf("ответ %q, ошибка %q", resp.Response, resp.Error)
+	}
+	if dead.Remaining() != 1 || len(fallback.Requests()) != 1 || fallback.Requests()[0].Model != "mock-fallback" {
+		t.Errorf("отключённый провайдер вызван %d раз, резервный: %d", 3-dead.Remaining(), len(fallback.Requests()))
+	}
+}
+
+// TestChatWorkspaceEnv — переменные пространства доходят до execute, модель
+// видит только имена, а значение секрета вырезано из вывода команды.
+func TestChatWorkspaceEnv(t *testing.T) {
+	provider, tools := setupChat(t, llm.MockToolCall("execute", map[string]interface{}{"command": "echo $GITHUB_TOKEN"}), llm.MockText("Готово"))
+	tools.Handle("/execute", toolstest.Reply(map[string]interface{}{"stdout": "ghp_test_secret\n", "returncode": 0}))
+	ws := models.Workspace{Name: "app", Path: t.TempDir()}
+	if err := db.DB.Create(&ws).Error; err != nil {
+		t.Fatal(err)
+	}
+	db.DB.Create(&models.EnvVar{WorkspaceID: &ws.ID, Key: "GITHUB_TOKEN", Value: "ghp_test_secret", Secret: true})
+
+	resp := postChat(t, ChatRequest{Agent: "admin", WorkspaceID: &ws.ID, Messages: []llm.Message{{Role: "user", Content: "Проверь токен"}}, NoCache: true})
+	if resp.Error != "" {
+		t.Fatalf("ответ: %+v", resp)
+	}
+	calls := tools.Calls()
+	if len(calls) == 0 {
+		t.Fatal("execute не вызван")
+	}
+	if env, _ := calls[len(calls)-1].Args["env"].(map[string]interface{}); env["GITHUB_TOKEN"] != "ghp_test_secret" {
+		t.Errorf("env в запросе execute: %v", calls[len(calls)-1].Args)
+	}
+	reqs := provider.Requests()
+	if len(reqs) != 2 {
+		t.Fatalf("вызовов модели %d", len(reqs))
+	}
+	for _, m := range reqs[1].Messages {
+		if strings.Contains(m.Content, "ghp_test_secret") {
+			t.Errorf("значение секрета попало в контекст модели (%s): %s", m.Role, m.Content)
+		}
+	}
+	if system := reqs[0].Messages[0].Content; !strings.Contains(system, "$GITHUB_TOKEN (секрет)") || !strings.Contains(system, ws.Path) {
+		t.Errorf("системный промпт без пространства и переменных: %s", system)
+	}
+}
+
+// TestChatWorkspaceEditRollback — edit_file работает в рабочем пространстве
+// запроса, копия хранит абсолютный путь и пространство, откат вне запроса
+// /chat выполняется в том же пространстве.
+func TestChatWorkspaceEditRollback(t *testing.T) {
+	_, tools := setupChat(t, llm.MockToolCall("edit_file", map[string]interface{}{"file_path": "src/a.txt", "old_text": "old", "new_text": "new"}), llm.MockText("Готово"))
+	cfg := config.Current()
+	cfg.BackupEnabled = true
+	config.Set(cfg)
+	tools.Handle("/read", toolstest.Reply(map[string]interface{}{"content": "old text"}))
+	tools.Handle("/write", toolstest.Reply(map[string]interface{}{"status": "ok"}))
+	ws := models.Workspace{Name: "app", Path: t.TempDir()}
+	if err := db.DB.Create(&ws).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if resp := postChat(t, ChatRequest{Agent: "admin", WorkspaceID: &ws.ID, Messages: []llm.Message{{Role: "user", Content: "Замени old"}}, NoCache: true}); resp.Error != "" {
+		t.Fatalf("ответ: %+v", resp)
+	}
+	for _, c := range tools.Calls() {
+		if (c.Path == "/read" || c.Path == "/write") && c.Workspace != ws.Path {
+			t.Errorf("%s без X-Workspace-Root: %q", c.Path, c.Workspace)
+		}
+	}
+	var backups []models.FileBackup
+	db.DB.Find(&backups)
+	want := filepath.Join(ws.Path, "src/a.txt")
+	if len(backups) != 1 || backups[0].Path != want || backups[0].Workspace != ws.Path {
+		t.Fatalf("копии: %+v", backups)
+	}
+
+	results := restoreAll(context.Background(), backups)
+	if len(results) != 1 || results[0].Status != "restored" {
+		t.Fatalf("откат: %+v", results)
+	}
+	calls := tools.Calls()
+	last := calls[len(calls)-1]
+	if last.Path != "/write" || last.Args["path"] != want || last.Workspace != ws.Path || last.Args["content"] != "old text" {
+		t.Errorf("запись при откате: %+v", last)
+	}
+}
+
+// TestChatWriteUnreadable — файл, который не удалось прочитать (не 404),
+// не считается новым: копия не создаётся, результат сообщает, что откатить
+// нельзя, а откат по старой копии не перезаписывает файл вслепую.
+func TestChatWriteUnreadable(t *testing.T) {
+	provider, tools := setupChat(t, llm.MockToolCall("write", map[string]interface{}{"path": "/srv/app.conf", "content": "new"}), llm.MockText("Готово"))
+	cfg := config.Current()
+	cfg.BackupEnabled = true
+	config.Set(cfg)
+	tools.Handle("/read", func(map[string]interface{}) (int, interface{}) {
+		return http.StatusInternalServerError, map[string]interface{}{"code": "INTERNAL_ERROR", "message": "permission denied"}
+	})
+	tools.Handle("/write", toolstest.Reply(map[string]interface{}{"status": "ok"}))
+
+	resp := postChat(t, ChatRequest{Agent: "admin", Messages: []llm.Message{{Role: "user", Content: "Запиши конфиг"}}, NoCache: true})
+	if resp.Error != "" {
+		t.Fatalf("ответ: %+v", resp)
+	}
+	var n int64
+	db.DB.Model(&models.FileBackup{}).Count(&n)
+	if n != 0 {
+		t.Errorf("создано копий: %d", n)
+	}
+	if reqs := provider.Requests(); len(reqs) != 2 || !strings.Contains(reqs[1].Messages[len(reqs[1].Messages)-1].Content, "rollback_unavailable") {
+		t.Errorf("результат инструмента без пометки об откате: %+v", reqs)
+	}
+
+	b := models.FileBackup{AgentName: "admin", Tool: "write", Path: "/srv/app.conf", Existed: true, Content: "old"}
+	db.DB.Create(&b)
+	writes := 0
+	for _, c := range tools.Calls() {
+		if c.Path == "/write" {
+			writes++
+		}
+	}
+	if results := restoreAll(context.Background(), []models.FileBackup{b}); len(results) != 1 || results[0].Status != "error" {
+		t.Fatalf("откат: %+v", results)
+	}
+	for _, c := range tools.Calls() {
+		if c.Path == "/write" {
+			writes--
+		}
+	}
+	if writes != 0 {
+		t.Error("откат перезаписал файл, текущее состояние которого не прочитано")
+	}
+}
+
+// TestChatAskUser — ask_user останавливает цикл и возвращает вопрос; ответ
+// пользователя приходит модели результатом вызова, цикл продолжается.
+func TestChatAskUser(t *testing.T) {
+	provider, tools := setupChat(t,
+		llm.MockToolCall("ask_user", map[string]interface{}{"question": "Какую ветку удалить?", "options": []interface{}{"feature/a", "feature/b"}}),
+		llm.MockText("Удалил feature/a"))
+	t.Cleanup(func() { clarifications = clarify.NewStore() })
+
+	resp := postChat(t, ChatRequest{Agent: "admin", Messages: []llm.Message{{Role: "user", Content: "Удали старую ветку"}}, NoCache: true})
+	if resp.Status != taskreport.StatusNeedsInput || resp.Clarification == nil || resp.Clarification.Question != "Какую ветку удалить?" {
+		t.Fatalf("ожидался уточняющий вопрос: %+v", resp)
+	}
+	if !strings.Contains(resp.Response, "feature/b") {
+		t.Errorf("варианты ответа не в тексте: %q", resp.Response)
+	}
+	for _, c := range tools.Calls() {
+		if strings.Contains(c.Path, "ask_user") {
+			t.Errorf("ask_user не должен уходить в tools-service: %+v", c)
+		}
+	}
+
+	resp = postChat(t, ChatRequest{Agent: "admin", ClarificationID: resp.Clarification.ID, Messages: []llm.Message{{Role: "user", Content: "feature/a"}}, NoCache: true})
+	if resp.Response != "Удалил feature/a" || resp.Status != taskreport.StatusCompleted {
+		t.Fatalf("продолжение: %+v", resp)
+	}
+	reqs := provider.Requests()
+	if len(reqs) != 2 {
+		t.Fatalf("вызовов модели %d", len(reqs))
+	}
+	msgs := reqs[1].Messages
+	if last := msgs[len(msgs)-1]; last.Role != "tool" || last.ToolCallID != "call_ask_user" || !strings.Contains(last.Content, "feature/a") {
+		t.Errorf("ответ пользователя не передан результатом ask_user: %+v", last)
+	}
+	if msgs[1].Content != "Удали старую ветку" {
+		t.Errorf("контекст до вопроса потерян: %+v", msgs)
+	}
+
+	data, _ := json.Marshal(ChatRequest{Agent: "admin", ClarificationID: "stale", Messages: []llm.Message{{Role: "user", Content: "да"}}})
+	w := httptest.NewRecorder()
+	chatHandler(w, httptest.NewRequest(http.MethodPost, "/chat", bytes.NewReader(data)))
+	if w.Code != http.StatusConflict {
+		t.Errorf("устаревший вопрос: %d %s", w.Code, w.Body.String())
+	}
+}
+
+// TestChatStreamProgress — с Accept: text/event-stream /chat отдаёт события
+// progress по ходу цикла инструментов и шагов скила, затем result.
+func TestChatStreamProgress(t *testing.T) {
+	setupChat(t, llm.MockToolCall("run_commands", map[string]interface{}{"commands": []interface{}{"echo a", "echo b"}}), llm.MockText("Готово"))
+
+	data, _ := json.Marshal(ChatRequest{Agent: "admin", Messages: []llm.Message{{Role: "user", Content: "Выполни команды"}}, NoCache: true})
+	r := httptest.NewRequest(http.MethodPost, "/chat", bytes.NewReader(data))
+	r.Header.Set("Accept", "text/event-stream")
+	w := httptest.NewRecorder()
+	chatHandler(w, r)
+
+	if ct := w.Header().Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("Content-Type = %q", ct)
+	}
+	var steps []progress.Event
+	var result ChatResponse
+	for _, block := range strings.Split(w.Body.String(), "\n\n") {
+		event, payload, _ := strings.Cut(block, "\ndata: ")
+		switch event {
+		case "event: progress":
+			var e progress.Event
+			if err := json.Unmarshal([]byte(payload), &e); err != nil {
+				t.Fatalf("progress: %v: %s", err, payload)
+			}
+			if e.Steps > 0 {
+				steps = append(steps, e)
+			}
+		case "event: result":
+			if err := json.Unmarshal([]byte(payload), &result); err != nil {
+				t.Fatalf("result: %v: %s", err, payload)
+			}
+		}
+	}
+	if len(steps) != 2 || steps[1].Tool != "run_commands" || steps[1].Step != 2 || steps[1].Percent != 50 || steps[1].Round != 1 {
+		t.Errorf("шаги скила: %+v", steps)
+	}
+	if result.Response != "Готово" {
+		t.Errorf("итоговый ответ: %+v\n%s", result, w.Body.String())
+	}
+}
+
+// TestChatRecipe — рецепт из YAML выдаётся модели инструментом, шаги идут
+// в tools-service; разрушительный шаг без подтверждения не выполняется.
+func TestChatRecipe(t *testing.T) {
+	provider, tools := setupChat(t, llm.MockToolCall("tidy_project", map[string]interface{}{"path": "/srv/app"}), llm.MockText("Готово"))
+	tools.Handle("/execute", toolstest.Reply(map[string]interface{}{"stdout": "ok", "returncode": 0}))
+	r, err := recipe.Parse([]byte(`
+name: tidy_project
+description: Навести порядок в проекте
+parameters:
+  - name: path
+    required: true
+steps:
+  - name: fmt
+    tool: execute
+    args: {command: 'cd {{shq .path}} && gofmt -w .'}
+  - name: cleanup
+    tool: delete
+    args: {path: '{{.path}}/tmp'}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	recipes.Replace([]*recipe.Recipe{r}, nil)
+	t.Cleanup(func() { recipes.Replace(nil, nil) })
+
+	resp := postChat(t, ChatRequest{Agent: "admin", Messages: []llm.Message{{Role: "user", Content: "Наведи порядок"}}, NoCache: true})
+	if resp.Response != "Готово" {
+		t.Fatalf("ответ: %+v", resp)
+	}
+	offered := false
+	for _, tool := range provider.Requests()[0].Tools {
+		offered = offered || tool.Function.Name == "tidy_project"
+	}
+	if !offered {
+		t.Error("рецепт не выдан модели инструментом")
+	}
+	var executed, deleted bool
+	for _, c := range tools.Calls() {
+		executed = executed || (c.Path == "/execute" && c.Args["command"] == "cd '/srv/app' && gofmt -w .")
+		deleted = deleted || c.Path == "/delete"
+	}
+	if !executed || deleted {
+		t.Errorf("вызовы tools-service: %+v", tools.Calls())
+	}
+	msgs := provider.Requests()[1].Messages
+	if last := msgs[len(msgs)-1]; !strings.Contains(last.Content, "разрушительный шаг") {
+		t.Errorf("результат рецепта: %s", last.Content)
+	}
+}
+
+// TestCompoundSkills — импорт составного скила создаёт версии, откат
+// переключает активную, переключатель агента убирает скил из инструментов.
+func TestCompoundSkills(t *testing.T) {
+	setupChat(t)
+	t.Cleanup(func() { recipes.Replace(nil, nil) })
+	const spec = "name: lint_project\ndescription: Проверить проект\nsteps:\n  - tool: execute\n    args: {command: 'make lint'}\n"
+	call := func(method, target, body string) *httptest.ResponseRecorder {
+		t.Helper()
+		w := httptest.NewRecorder()
+		compoundSkillsHandler(w, httptest.NewRequest(method, target, strings.NewReader(body)))
+		return w
+	}
+
+	if w := call(http.MethodPost, "/skills/compound", spec); w.Code != http.StatusCreated {
+		t.Fatalf("импорт: %d %s", w.Code, w.Body.String())
+	}
+	if w := call(http.MethodPost, "/skills/compound", spec); w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"unchanged"`) {
+		t.Errorf("повторный импорт того же текста: %d %s", w.Code, w.Body.String())
+	}
+	remote := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		io.WriteString(w, strings.Replace(spec, "make lint", "make vet", 1))
+	}))
+	defer remote.Close()
+	if w := call(http.MethodPost, "/skills/compound?url="+url.QueryEscape(remote.URL), ""); w.Code != http.StatusCreated || !strings.Contains(w.Body.String(), `"version":2`) {
+		t.Fatalf("импорт по URL: %d %s", w.Code, w.Body.String())
+	}
+	if r, ok := recipes.Get("lint_project"); !ok || r.Revision != 2 || r.Steps[0].Args["command"] != "make vet" {
+		t.Fatalf("активна версия 2: %+v", r)
+	}
+
+	for name, tc := range map[string]struct {
+		body string
+		code int
+	}{
+		"неизвестный ключ":       {spec + "retry: 3\n", http.StatusBadRequest},
+		"неизвестный инструмент": {strings.Replace(spec, "execute", "format_disk", 1), http.StatusBadRequest},
+		"имя встроенного":        {strings.Replace(spec, "lint_project", "read", 1), http.StatusConflict},
+	} {
+		if w := call(http.MethodPost, "/skills/compound", tc.body); w.Code != tc.code {
+			t.Errorf("%s: %d %s", name, w.Code, w.Body.String())
+		}
+	}
+
+	if w := call(http.MethodPost, "/skills/compound/lint_project/activate", `{"version": 1}`); w.Code != http.StatusOK {
+		t.Fatalf("откат: %d %s", w.Code, w.Body.String())
+	}
+	if r, _ := recipes.Get("lint_project"); r.Revision != 1 {
+		t.Errorf("после отката активна версия %d", r.Revision)
+	}
+
+	if w := call(http.MethodPut, "/skills/compound/lint_project/agents", `{"agent": "admin", "enabled": false}`); w.Code != http.StatusOK {
+		t.Fatalf("отключение для агента: %d %s", w.Code, w.Body.String())
+	}
+	if tools := recipeTools("admin"); len(tools) != 0 {
+		t.Errorf("отключённый скил выдан агенту: %+v", tools)
+	}
+	if w := call(http.MethodPut, "/skills/compound/lint_project/agents", `{"agent": "admin", "enabled": null}`); w.Code != http.StatusOK || len(recipeTools("admin")) != 1 {
+		t.Errorf("сброс переключателя: %d %s", w.Code, w.Body.String())
+	}
+
+	w := call(http.MethodGet, "/skills/compound/lint_project", "")
+	var view struct {
+		Revision int                    `json:"revision"`
+		Spec     string                 `json:"spec"`
+		Versions []models.CompoundSkill `json:"versions"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &view); err != nil || view.Revision != 1 || view.Spec != spec || len(view.Versions) != 2 {
+		t.Errorf("карточка скила: %d %s", w.Code, w.Body.String())
+	}
+
+	if w := call(http.MethodDelete, "/skills/compound/lint_project", ""); w.Code != http.StatusOK {
+		t.Fatalf("удаление: %d %s", w.Code, w.Body.String())
+	}
+	if w := call(http.MethodGet, "/skills/compound/lint_project", ""); w.Code != http.StatusNotFound {
+		t.Errorf("после удаления: %d", w.Code)
+	}
+}
+
+// TestChatPersona — образ агента задаёт промпт, температуру и инструменты
+// ответа, переключается без перезапуска и запоминается в сохранённом ответе.
+func TestChatPersona(t *testing.T) {
+	provider, _ := setupChat(t, llm.MockText("Замечаний нет"), llm.MockText("Готово"))
+	call := func(method, target, body string) *httptest.ResponseRecorder {
+		t.Helper()
+		w := httptest.NewRecorder()
+		agentResourceHandler(w, httptest.NewRequest(method, target, strings.NewReader(body)))
+		return w
+	}
+
+	if w := call(http.MethodPost, "/agents/admin/personas", `{"name": "reviewer", "prompt": "Ты строгий ревьюер", "temperature": 0.2, "tool_policy": "allow", "tools": ["read", " read"]}`); w.Code != http.StatusCreated {
+		t.Fatalf("сохранение образа: %d %s", w.Code, w.Body.String())
+	}
+	if w := call(http.MethodPost, "/agents/admin/personas", `{"name": "broken", "tool_policy": "allow"}`); w.Code != http.StatusBadRequest {
+		t.Errorf("allow без списка инструментов: %d %s", w.Code, w.Body.String())
+	}
+	if w := call(http.MethodPut, "/agents/admin/persona", `{"name": "ghost"}`); w.Code != http.StatusNotFound {
+		t.Errorf("переключение на несуществующий образ: %d", w.Code)
+	}
+	if w := call(http.MethodPut, "/agents/admin/persona", `{"name": "reviewer"}`); w.Code != http.StatusOK {
+		t.Fatalf("переключение: %d %s", w.Code, w.Body.String())
+	}
+
+	resp := postChat(t, ChatRequest{Agent: "admin", Messages: []llm.Message{{Role: "user", Content: "Проверь код"}}, NoCache: true})
+	if resp.Persona != "reviewer" || resp.Response != "Замечаний нет" {
+		t.Fatalf("ответ образом: %+v", resp)
+	}
+	req := provider.Requests()[0]
+	if !strings.HasPrefix(req.Messages[0].Content, "Ты строгий ревьюер") {
+		t.Errorf("системный промпт образа: %q", truncate(req.Messages[0].Content, 60))
+	}
+	if req.Temperature == nil || *req.Temperature != 0.2 {
+		t.Errorf("температура образа: %v", req.Temperature)
+	}
+	if len(req.Tools) != 1 || req.Tools[0].Function.Name != "read" {
+		t.Errorf("политика allow: %d инструментов", len(req.Tools))
+	}
+	var saved models.Message
+	db.DB.First(&saved, resp.MessageID)
+	if saved.Persona != "reviewer" {
+		t.Errorf("образ сохранённого ответа: %q", saved.Persona)
+	}
+
+	if w := call(http.MethodPut, "/agents/admin/persona", `{"name": ""}`); w.Code != http.StatusOK {
+		t.Fatalf("сброс образа: %d %s", w.Code, w.Body.String())
+	}
+	resp = postChat(t, ChatRequest{Agent: "admin", Messages: []llm.Message{{Role: "user", Content: "Привет"}}, NoCache: true})
+	req = provider.Requests()[1]
+	if resp.Persona != "" || !strings.HasPrefix(req.Messages[0].Content, "Ты тестовый агент") || req.Temperature != nil || len(req.Tools) < 2 {
+		t.Errorf("без образа действуют настройки агента: %+v", resp)
+	}
+
+	if w := call(http.MethodDelete, "/agents/admin/personas/reviewer", ""); w.Code != http.StatusOK {
+		t.Fatalf("удаление: %d %s", w.Code, w.Body.String())
+	}
+	if w := call(http.MethodGet, "/agents/admin/personas/reviewer", ""); w.Code != http.StatusNotFound {
+		t.Errorf("после удаления: %d", w.Code)
+	}
+}
+
+// TestWatchdogRestart — упавший сервис перезапускается командой через
+// execute tools-service, инцидент попадает в системный лог.
+func TestWatchdogRestart(t *testing.T) {
+	_, tools := setupChat(t)
+	tools.Handle("/execute", toolstest.Reply(map[string]interface{}{"stdout": "", "returncode": 0}))
+	cfg := *config.Current()
+	cfg.WatchdogFailures = 1
+	config.Set(&cfg)
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer down.Close()
+
+	prev := watchedServices
+	t.Cleanup(func() { watchedServices = prev })
+	initWatchdog()
+	watchedServices = []watchdog.Service{{Name: "memory-service", HealthURL: down.URL + "/health", Restart: watchdog.RestartSystemd, Unit: "agent-memory"}}
+
+	w := httptest.NewRecorder()
+	watchdogHandler(w, httptest.NewRequest(http.MethodPost, "/watchdog/check", nil))
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"action":"restarted"`) {
+		t.Fatalf("проверка: %d %s", w.Code, w.Body.String())
+	}
+	restarted := false
+	for _, c := range tools.Calls() {
+		restarted = restarted || (c.Path == "/execute" && c.Args["command"] == "systemctl restart agent-memory")
+	}
+	if !restarted {
+		t.Errorf("вызовы tools-service: %+v", tools.Calls())
+	}
+	var entry models.SystemLog
+	if err := db.DB.Where("service = ?", "memory-service").First(&entry).Error; err != nil || !strings.Contains(entry.Message, "перезапущен") {
+		t.Errorf("системный лог: %+v, %v", entry, err)
+	}
+}
+
+func TestMaintenanceRun(t *testing.T) {
+	_, tools := setupChat(t)
+	tools.Handle("/ttl/expired", toolstest.Reply(map[string]interface{}{"deleted_count": 4, "status": "ok"}))
+	tools.Handle("/reindex", toolstest.Reply(map[string]interface{}{"reindexed_count": 0, "status": "ok"}))
+	var hook map[string]interface{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&hook)
+	}))
+	defer srv.Close()
+	cfg := *config.Current()
+	cfg.MaintenanceWebhookURL = srv.URL
+	config.Set(&cfg)
+
+	w := httptest.NewRecorder()
+	maintenanceHandler(w, httptest.NewRequest(http.MethodPost, "/maintenance/run", nil))
+	var rep maintenance.Report
+	if err := json.Unmarshal(w.Body.Bytes(), &rep); w.Code != http.StatusOK || err != nil {
+		t.Fatalf("запуск: %d %s", w.Code, w.Body.String())
+	}
+	if len(rep.Tasks) != 4 || rep.Tasks[1].Status != maintenance.StatusOK || !strings.Contains(rep.Tasks[1].Summary, "удалено устаревших документов: 4") {
+		t.Errorf("задачи: %+v", rep.Tasks)
+	}
+	if !rep.Notified || !strings.Contains(fmt.Sprint(hook["text"]), "Уплотнение индекса RAG") {
+		t.Errorf("уведомление: %v, %v", rep.NotifyError, hook)
+	}
+	var art models.Artifact
+	if err := db.DB.First(&art, rep.ArtifactID).Error; err != nil || art.Tool != "maintenance" || !strings.HasPrefix(art.Name, "maintenance-") {
+		t.Errorf("артефакт: %+v, %v", art, err)
+	}
+
+	w = httptest.NewRecorder()
+	maintenanceHandler(w, httptest.NewRequest(http.MethodGet, "/maintenance", nil))
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"next_run"`) || !strings.Contains(w.Body.String(), `"artifact_id"`) {
+		t.Errorf("состояние: %d %s", w.Code, w.Body.String())
+	}
+}
+
+func TestSecurityAudit(t *testing.T) {
+	_, tools := setupChat(t)
+	finding := func(check, sev, title string) map[string]interface{} {
+		return map[string]interface{}{"check": check, "severity": sev, "title": title}
+	}
+	tools.Handle("/security/ports", toolstest.Reply(map[string]interface{}{"findings": []interface{}{
+		finding("ports", "info", "Порт 22 доступен снаружи"),
+		finding("ports", "high", "Порт 6379 открыт на всех интерфейсах: Redis"),
+	}}))
+	tools.Handle("/security/sudo", toolstest.Reply(map[string]interface{}{"findings": []interface{}{}}))
+	tools.Handle("/security/ssh", toolstest.Reply(map[string]interface{}{"findings": []interface{}{
+		finding("ssh", "critical", "SSH разрешает вход с пустым паролем"),
+		finding("ssh", "medium", "SSH принимает пароли"),
+	}}))
+	tools.Handle("/security/writable", toolstest.Reply(map[string]interface{}{"findings": []interface{}{}}))
+	// /security/updates не настроен — 404, аудит продолжается
+
+	res := handleSecurityAudit(context.Background(), map[string]interface{}{})
+	findings, _ := res["findings"].([]map[string]interface{})
+	var order []string
+	for _, f := range findings {
+		order = append(order, f["severity"].(string))
+	}
+	if strings.Join(order, ",") != "critical,high,medium,info" || findings[0]["priority"] != 1 {
+		t.Errorf("порядок замечаний: %v", order)
+	}
+	checks := res["checks"].(map[string]interface{})
+	if checks["updates"].(map[string]interface{})["status"] != "error" || checks["ssh"].(map[string]interface{})["status"] != "ok" {
+		t.Errorf("проверки: %+v", checks)
+	}
+	if !strings.Contains(res["message"].(string), "критичных 1, высоких 1") {
+		t.Errorf("сводка: %v", res["message"])
+	}
+
+	if res := handleSecurityAudit(context.Background(), map[string]interface{}{"checks": []interface{}{"rootkits"}}); res["error"] == nil {
+		t.Error("неизвестная проверка")
+	}
+}
+
+// TestStackBackup — копия базы и загрузок восстанавливается поверх
+// изменений; без confirm=yes восстановление не выполняется, перед ним
+// сохраняется текущее состояние.
+func TestStackBackup(t *testing.T) {
+	setupChat(t)
+	cfg := *config.Current()
+	cfg.StackBackupDir = t.TempDir()
+	cfg.UploadsDir = t.TempDir()
+	config.Set(&cfg)
+	avatar := filepath.Join(cfg.UploadsDir, "avatars", "admin.png")
+	os.MkdirAll(filepath.Dir(avatar), 0755)
+	os.WriteFile(avatar, []byte("png"), 0644)
+
+	w := httptest.NewRecorder()
+	adminBackupHandler(w, httptest.NewRequest(http.MethodPost, "/admin/backup", strings.NewReader(`{"components":["db","uploads"]}`)))
+	var backup stackBackupResult
+	if err := json.Unmarshal(w.Body.Bytes(), &backup); w.Code != http.StatusOK || err != nil || backup.Manifest.Files["uploads"] != 1 {
+		t.Fatalf("копия: %d %s", w.Code, w.Body.String())
+	}
+
+	db.DB.Model(&models.Agent{}).Where("name = ?", "admin").Update("prompt", "испорчено")
+	os.Remove(avatar)
+	restore := func(query string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		adminRestoreHandler(w, httptest.NewRequest(http.MethodPost, "/admin/restore?components=db,uploads&name="+backup.Name+query, nil))
+		return w
+	}
+	if w := restore(""); w.Code != http.StatusBadRequest {
+		t.Errorf("без подтверждения: %d %s", w.Code, w.Body.String())
+	}
+	if w := restore("&dry_run=1"); w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"dry_run":true`) {
+		t.Errorf("проверка: %d %s", w.Code, w.Body.String())
+	}
+	if w := restore("&confirm=yes"); w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"pre_restore_backup":"stack-`) {
+		t.Fatalf("восстановление: %d %s", w.Code, w.Body.String())
+	}
+	var agent models.Agent
+	db.DB.Where("name = ?", "admin").First(&agent)
+	if data, err := os.ReadFile(avatar); agent.Prompt != "Ты тестовый агент" || err != nil || string(data) != "png" {
+		t.Errorf("после восстановления: промпт %q, аватар %q %v", agent.Prompt, data, err)
+	}
+
+	w = httptest.NewRecorder()
+	adminBackupHandler(w, httptest.NewRequest(http.MethodGet, "/admin/backup", nil))
+	if w.Code != http.StatusOK || strings.Count(w.Body.String(), `"name":"stack-`) != 2 {
+		t.Errorf("список: %d %s", w.Code, w.Body.String())
+	}
+	w = httptest.NewRecorder()
+	adminBackupHandler(w, httptest.NewRequest(http.MethodGet, "/admin/backup/"+backup.Name, nil))
+	if w.Code != http.StatusOK || w.Body.Len() != int(backup.Size) {
+		t.Errorf("скачивание: %d, %d байт", w.Code, w.Body.Len())
+	}
+}
+
+// TestStackRestoreSlowUpload — архив в теле восстановления загружается дольше
+// ReadTimeout сервера: обработчик снимает дедлайны соединения.
+func TestStackRestoreSlowUpload(t *testing.T) {
+	setupChat(t)
+	cfg := *config.Current()
+	cfg.StackBackupDir = t.TempDir()
+	cfg.UploadsDir = t.TempDir()
+	config.Set(&cfg)
+	w := httptest.NewRecorder()
+	adminBackupHandler(w, httptest.NewRequest(http.MethodPost, "/admin/backup", strings.NewReader(`{"components":["db"]}`)))
+	var backup stackBackupResult
+	if err := json.Unmarshal(w.Body.Bytes(), &backup); w.Code != http.StatusOK || err != nil {
+		t.Fatalf("копия: %d %s", w.Code, w.Body.String())
+	}
+	archive, err := os.ReadFile(filepath.Join(cfg.StackBackupDir, backup.Name))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(adminRestoreHandler))
+	srv.Config.ReadTimeout = 100 * time.Millisecond
+	srv.Config.WriteTimeout = 100 * time.Millisecond
+	srv.Start()
+	defer srv.Close()
+
+	pr, pw := io.Pipe()
+	go func() {
+		step := len(archive)/5 + 1
+		for i := 0; i < len(archive); i += step {
+			time.Sleep(60 * time.Millisecond)
+			pw.Write(archive[i:min(i+step, len(archive))])
+		}
+		pw.Close()
+	}()
+	resp, err := http.Post(srv.URL+"/admin/restore?dry_run=1", "application/gzip", pr)
+	if err != nil {
+		t.Fatalf("загрузка прервана дедлайном: %v", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK || !strings.Contains(string(body), `"dry_run":true`) {
+		t.Errorf("проверка архива: %d %s", resp.StatusCode, body)
+	}
+}
+
+// TestRagDeleteBulk — удаление по префиксу источника с проверкой (dry run)
+// и полная очистка только с действующим одноразовым токеном.
+func TestRagDeleteBulk(t *testing.T) {
+	setupChat(t)
+	for _, doc := range []models.RagDocument{
+		{Title: "a.md", Source: "folder:/docs"},
+		{Title: "b.md", Source: "folder:/docs/sub"},
+		{Title: "c.md", Source: "folder:/docs_old"},
+		{Title: "d.md", Source: "upload"},
+	} {
+		db.DB.Create(&doc)
+	}
+	del := func(query string) string {
+		w := httptest.NewRecorder()
+		ragDeleteHandler(w, httptest.NewRequest(http.MethodDelete, "/rag/delete?"+query, nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s: %d %s", query, w.Code, w.Body.String())
+		}
+		return w.Body.String()
+	}
+	if body := del("source_prefix=folder:/docs/&dry_run=1"); !strings.Contains(body, `"deleted":1`) {
+		t.Errorf("проверка: %s", body)
+	}
+	if body := del("source_prefix=folder:/docs_"); !strings.Contains(body, `"deleted":1`) {
+		t.Errorf("_ не экранирован: %s", body)
+	}
+	if body := del("source_prefix=folder:/docs"); !strings.Contains(body, `"deleted":2`) {
+		t.Errorf("по префиксу: %s", body)
+	}
+
+	purge := func(body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		ragPurgeHandler(w, httptest.NewRequest(http.MethodPost, "/rag/purge", strings.NewReader(body)))
+		return w
+	}
+	var issued struct {
+		ConfirmToken string `json:"confirm_token"`
+		Documents    int    `json:"documents"`
+	}
+	if w := purge(""); json.Unmarshal(w.Body.Bytes(), &issued) != nil || issued.ConfirmToken == "" || issued.Documents != 1 {
+		t.Fatalf("токен: %d %s", w.Code, w.Body.String())
+	}
+	if w := purge(`{"confirm_token":"wrong"}`); w.Code != http.StatusBadRequest {
+		t.Errorf("чужой токен: %d", w.Code)
+	}
+	if w := purge(`{"confirm_token":"` + issued.ConfirmToken + `"}`); w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"deleted":1`) {
+		t.Errorf("очистка: %d %s", w.Code, w.Body.String())
+	}
+	if w := purge(`{"confirm_token":"` + issued.ConfirmToken + `"}`); w.Code != http.StatusBadRequest {
+		t.Errorf("повторный токен: %d", w.Code)
+	}
+}
+
+// TestRagVersions — повторная загрузка того же документа сохраняет прежнее
+// содержимое версией, без изменений — новой версии не создаёт; diff по
+// умолчанию — между предыдущей и текущей.
+func TestRagVersions(t *testing.T) {
+	setupChat(t)
+	add := func(content string) map[string]interface{} {
+		w := httptest.NewRecorder()
+		ragAddHandler(w, httptest.NewRequest(http.MethodPost, "/rag/add", strings.NewReader(`{"title":"guide.md","source":"folder:/docs","content":"`+content+`"}`)))
+		var out map[string]interface{}
+		if err := json.Unmarshal(w.Body.Bytes(), &out); w.Code != http.StatusOK || err != nil {
+			t.Fatalf("добавление: %d %s", w.Code, w.Body.String())
+		}
+		return out
+	}
+	first := add(`шаг 1\nшаг 2`)
+	if out := add(`шаг 1\nшаг 2`); out["unchanged"] != true || out["version"] != 1.0 {
+		t.Errorf("без изменений: %v", out)
+	}
+	if out := add(`шаг 1\nшаг 2 исправлен\nшаг 3`); out["version"] != 2.0 || out["db_id"] != first["db_id"] {
+		t.Errorf("новая версия: %v", out)
+	}
+	var count int64
+	db.DB.Model(&models.RagDocument{}).Count(&count)
+	if count != 1 {
+		t.Errorf("строк документа: %d", count)
+	}
+
+	get := func(url string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		ragVersionsHandler(w, httptest.NewRequest(http.MethodGet, url, nil))
+		return w
+	}
+	if w := get("/rag/versions?title=guide.md"); w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"current_version":2`) || strings.Count(w.Body.String(), `"version":`) != 2 {
+		t.Errorf("список: %d %s", w.Code, w.Body.String())
+	}
+	if w := get("/rag/versions?title=guide.md&version=1"); !strings.Contains(w.Body.String(), `"content":"шаг 1\nшаг 2"`) {
+		t.Errorf("версия 1: %s", w.Body.String())
+	}
+	w := get("/rag/versions/diff?title=guide.md&source=folder:/docs")
+	var d struct {
+		From, To, Added, Removed int
+		Diff                     string
+	}
+	if json.Unmarshal(w.Body.Bytes(), &d) != nil || d.From != 1 || d.To != 2 || d.Added != 2 || d.Removed != 1 || !strings.Contains(d.Diff, "+шаг 3") {
+		t.Errorf("diff: %d %s", w.Code, w.Body.String())
+	}
+	if w := get("/rag/versions/diff?title=guide.md&from=7"); w.Code != http.StatusNotFound {
+		t.Errorf("нет версии: %d", w.Code)
+	}
+}
+
+// TestRagAsk — вопрос уходит модели с пронумерованными фрагментами из
+// ChromaDB, ответ возвращается со ссылками; без фрагментов модель не вызывается.
+func TestRagAsk(t *testing.T) {
+	provider, _ := setupChat(t, llm.MockText("Запустите make install [2]."))
+	var found bool
+	chroma := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !found {
+			io.WriteString(w, `{"ids":[[]],"documents":[[]],"metadatas":[[]],"distances":[[]]}`)
+			return
+		}
+		io.WriteString(w, `{"ids":[["ragdoc-1","ragdoc-2"]],"documents":[["Требования: Go 1.24","Установка: make install"]],`+
+			`"metadatas":[[{"title":"req.md","db_id":1},{"title":"install.md","source":"folder:/docs","db_id":2,"version":3}]],"distances":[[0.2,0.3]]}`)
+	}))
+	defer chroma.Close()
+	prev := ragRetriever
+	t.Cleanup(func() { ragRetriever = prev })
+	ragRetriever = rag.NewDBRetriever(&rag.Config{ChromaURL: chroma.URL})
+
+	ask := func() ragAskResponse {
+		w := httptest.NewRecorder()
+		ragAskHandler(w, httptest.NewRequest(http.MethodPost, "/rag/ask", strings.NewReader(`{"question":"Как установить?"}`)))
+		var resp ragAskResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); w.Code != http.StatusOK || err != nil {
+			t.Fatalf("ответ %d: %s", w.Code, w.Body.String())
+		}
+		return resp
+	}
+	if resp := ask(); resp.Answered || resp.Answer != rag.NoAnswer || len(provider.Requests()) != 0 {
+		t.Errorf("без фрагментов: %+v", resp)
+	}
+
+	found = true
+	resp := ask()
+	if !resp.Answered || resp.Model != "mock-model" || len(resp.Citations) != 2 || resp.Citations[0].Cited || !resp.Citations[1].Cited || resp.Citations[1].Version != 3 {
+		t.Errorf("ответ: %+v", resp)
+	}
+	reqs := provider.Requests()
+	if len(reqs) != 1 || reqs[0].Messages[0].Content != rag.AskPrompt || !strings.Contains(reqs[0].Messages[1].Content, "[2] install.md (folder:/docs)\nУстановка: make install") {
+		t.Errorf("запрос к модели: %+v", reqs)
+	}
+}
+
+// TestRagSearchFilter — префикс источника раскрывается в источники документов
+// из БД и уходит в ChromaDB условием where; без подходящих документов
+// ChromaDB не вызывается.
+func TestRagSearchFilter(t *testing.T) {
+	setupChat(t)
+	for _, src := range []string{"folder:/docs/api", "folder:/docs/api/v2", "folder:/docs/guide"} {
+		db.DB.Create(&models.RagDocument{Title: "a.md", Source: src})
+	}
+	var bodies []string
+	chroma := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		bodies = append(bodies, string(data))
+		io.WriteString(w, `{"ids":[["ragdoc-1"]],"documents":[["Авторизация по токену"]],"metadatas":[[{"title":"a.md","source":"folder:/docs/api"}]],"distances":[[0.1]]}`)
+	}))
+	defer chroma.Close()
+	prev := ragRetriever
+	t.Cleanup(func() { ragRetriever = prev })
+	ragRetriever = rag.NewDBRetriever(&rag.Config{ChromaURL: chroma.URL})
+
+	search := func(query string) string {
+		w := httptest.NewRecorder()
+		ragSearchHandler(w, httptest.NewRequest(http.MethodGet, "/rag/search?q=token&"+query, nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s: %d %s", query, w.Code, w.Body.String())
+		}
+		return w.Body.String()
+	}
+	if body := search("source_prefix=folder:/docs/api&extensions=md,YAML"); !strings.Contains(body, "Авторизация по токену") {
+		t.Errorf("результат: %s", body)
+	}
+	if len(bodies) != 1 || !strings.Contains(bodies[0], `"where":{"$and":[{"source":{"$in":["folder:/docs/api","folder:/docs/api/v2"]}},{"ext":{"$in":[".md",".yaml"]}}]}`) {
+		t.Errorf("запрос к ChromaDB: %v", bodies)
+	}
+	if body := search("source_prefix=folder:/other"); strings.TrimSpace(body) != "[]" || len(bodies) != 1 {
+		t.Errorf("нет документов: %s, запросов %d", body, len(bodies))
+	}
+	w := httptest.NewRecorder()
+	ragSearchHandler(w, httptest.NewRequest(http.MethodGet, "/rag/search?q=token&since=вчера", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("некорректная дата: %d", w.Code)
+	}
+}
+
+// TestRagStats — токены, доля дублей и устаревшие документы считаются по
+// БД, источники сортируются по объёму; при ChromaDB добавляются коллекции
+// со сверкой коллекции по умолчанию.
+func TestRagStats(t *testing.T) {
+	setupChat(t)
+	docs := []models.RagDocument{
+		{Title: "a.md", Source: "folder:/docs", Content: strings.Repeat("x", 40)},
+		{Title: "b.md", Source: "folder:/docs", Content: strings.Repeat("x", 40)},
+		{Title: "c.md", Source: "upload", Content: "12345678"},
+	}
+	for i := range docs {
+		db.DB.Create(&docs[i])
+	}
+	db.DB.Model(&docs[2]).UpdateColumn("updated_at", time.Now().AddDate(0, 0, -40))
+
+	chroma := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/api/v2/collections":
+			io.WriteString(w, `[{"name":"rag_docs"},{"name":"notes"}]`)
+		case strings.HasSuffix(r.URL.Path, "/count"):
+			io.WriteString(w, "3")
+		default:
+			fmt.Fprintf(w, `{"ids":["ragdoc-%d","ragdoc-%d","ragdoc-99"],"metadatas":[{"db_id":%d},{"db_id":%d},{"db_id":99}]}`, docs[0].ID, docs[1].ID, docs[0].ID, docs[1].ID)
+		}
+	}))
+	defer chroma.Close()
+	prev := ragRetriever
+	t.Cleanup(func() { ragRetriever = prev })
+	ragRetriever = rag.NewDBRetriever(&rag.Config{ChromaURL: chroma.URL})
+
+	w := httptest.NewRecorder()
+	ragStatsHandler(w, httptest.NewRequest(http.MethodGet, "/rag/stats?stale_days=30", nil))
+	var stats struct {
+		Documents      int                  `json:"documents"`
+		TokensTotal    int                  `json:"tokens_total"`
+		DuplicateRatio float64              `json:"duplicate_ratio"`
+		StaleDocuments int                  `json:"stale_documents"`
+		Sources        []ragSourceStats     `json:"sources"`
+		Collections    []ragCollectionStats `json:"collections"`
+		Dimension      int                  `json:"embedding_dimension"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &stats); w.Code != http.StatusOK || err != nil {
+		t.Fatalf("ответ %d: %s", w.Code, w.Body.String())
+	}
+	if stats.Documents != 3 || stats.TokensTotal != 22 || stats.DuplicateRatio != 1.0/3 || stats.StaleDocuments != 1 || stats.Dimension == 0 {
+		t.Errorf("статистика: %+v", stats)
+	}
+	if len(stats.Sources) != 2 || stats.Sources[0].Source != "folder:/docs" || stats.Sources[0].Tokens != 20 || stats.Sources[1].Stale != 1 {
+		t.Errorf("источники: %+v", stats.Sources)
+	}
+	for _, c := range stats.Collections {
+		if c.Name == rag.Collection && (c.Chunks != 3 || c.Orphaned == nil || *c.Orphaned != 1 || *c.Missing != 1) {
+			t.Errorf("коллекция %s: %+v", c.Name, c)
+		}
+		if c.Name != rag.Collection && c.Orphaned != nil {
+			t.Errorf("сверка не той коллекции: %+v", c)
+		}
+	}
+
+	w = httptest.NewRecorder()
+	ragStatsHandler(w, httptest.NewRequest(http.MethodGet, "/rag/stats?stale_days=-1", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("stale_days=-1: %d", w.Code)
+	}
+}
+
+// TestSanitizeArgs — переменные окружения вызова (токен push) не попадают в
+// лог, длинные строки обрезаются.
+func TestSanitizeArgs(t *testing.T) {
+	safe := sanitizeArgs(map[string]interface{}{
+		"command": strings.Repeat("x", 300),
+		"env":     map[string]string{"GITFLOW_PUSH_TOKEN": "ghp_secret"},
+	})
+	if got := fmt.Sprint(safe); strings.Contains(got, "ghp_secret") || len(safe["command"].(string)) != 203 {
+		t.Errorf("секрет в логе: %s", got)
+	}
+}
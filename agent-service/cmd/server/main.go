@@ -9,8 +9,10 @@
 //
 // HTTP-эндпоинты:
 //   - /health            — проверка состояния сервиса
+//   - /ready             — готовность: БД, провайдеры, tools/memory-service (503, если нет)
 //   - /chat              — основной чат с агентами (POST)
 //   - /agents            — список агентов с их настройками (GET)
+//   - /agents/{name}/persona — образы агента и переключение между ними (GET, PUT)
 //   - /models            — список локальных моделей Ollama с поддержкой инструментов (GET)
 //   - /prompts           — список файлов промптов для агента (GET)
 //   - /prompts/load      — загрузка промпта из файла (POST)
@@ -21,7 +23,14 @@
 //   - /providers         — управление облачными LLM-провайдерами (GET/POST)
 //   - /cloud-models      — список моделей облачного провайдера (GET)
 //   - /workspaces        — управление рабочими пространствами (GET/POST/DELETE)
-//   - /uploads/          — раздача статических файлов (аватары и др.)
+//   - /workspace/{id}/   — индекс символов и карта репозитория пространства
+//   - /env-vars          — переменные окружения инструментов пространства или диалога
+//   - /skills/compound   — составные скилы из рецептов: импорт, версии, включение по агентам
+//   - /watchdog          — сторож сервисов: состояние, инциденты, перезапуск упавших
+//   - /maintenance       — ночное обслуживание и утренняя сводка
+//   - /admin/backup      — резервная копия стека: база, uploads, промпты, конфигурация
+//   - /admin/restore     — восстановление стека из копии
+//   - /uploads/          — раздача загруженных файлов из UPLOADS_PUBLIC_PATHS (аватары)
 //
 // Порт по умолчанию: 8083 (настраивается через AGENT_SERVICE_PORT).
 package main
@@ -29,34 +38,110 @@ package main
 import (
 	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
+	"mime"
 	"net/http"
+	"net/http/httptest"
+	"net/url"
 	"os"
 	"os/signal"
 	"path/filepath"
-	"regexp"
+	"slices"
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"sync/atomic"
 	"syscall"
 	"time"
+	"unicode/utf8"
 
+	"github.com/neo-2022/openclaw-memory/agent-service/internal/envvars"
+	"github.com/neo-2022/openclaw-memory/agent-service/internal/maintenance"
 	"github.com/neo-2022/openclaw-memory/agent-service/internal/metrics"
+	"github.com/neo-2022/openclaw-memory/agent-service/internal/persona"
+	"github.com/neo-2022/openclaw-memory/agent-service/internal/progress"
+	"github.com/neo-2022/openclaw-memory/agent-service/internal/promptpack"
+	"github.com/neo-2022/openclaw-memory/agent-service/internal/providerguide"
 	"github.com/neo-2022/openclaw-memory/agent-service/internal/rag"
+	"github.com/neo-2022/openclaw-memory/agent-service/internal/reasoning"
+	"github.com/neo-2022/openclaw-memory/agent-service/internal/recipe"
+	"github.com/neo-2022/openclaw-memory/agent-service/internal/stackbackup"
+	"github.com/neo-2022/openclaw-memory/agent-service/internal/watchdog"
 
+	"github.com/google/uuid"
 	"github.com/neo-2022/openclaw-memory/agent-service/internal/apierror"
+	"github.com/neo-2022/openclaw-memory/agent-service/internal/artifact"
+	"github.com/neo-2022/openclaw-memory/agent-service/internal/attachments"
+	"github.com/neo-2022/openclaw-memory/agent-service/internal/avatar"
+	"github.com/neo-2022/openclaw-memory/agent-service/internal/backup"
+	"github.com/neo-2022/openclaw-memory/agent-service/internal/batch"
+	"github.com/neo-2022/openclaw-memory/agent-service/internal/benchmark"
+	"github.com/neo-2022/openclaw-memory/agent-service/internal/breaker"
+	"github.com/neo-2022/openclaw-memory/agent-service/internal/browserrpc"
+	"github.com/neo-2022/openclaw-memory/agent-service/internal/budget"
+	"github.com/neo-2022/openclaw-memory/agent-service/internal/clarify"
+	"github.com/neo-2022/openclaw-memory/agent-service/internal/config"
+	"github.com/neo-2022/openclaw-memory/agent-service/internal/convtitle"
+	"github.com/neo-2022/openclaw-memory/agent-service/internal/correction"
 	"github.com/neo-2022/openclaw-memory/agent-service/internal/db"
+	"github.com/neo-2022/openclaw-memory/agent-service/internal/episodic"
+	"github.com/neo-2022/openclaw-memory/agent-service/internal/feedback"
+	"github.com/neo-2022/openclaw-memory/agent-service/internal/gitflow"
+	"github.com/neo-2022/openclaw-memory/agent-service/internal/guard"
 	"github.com/neo-2022/openclaw-memory/agent-service/internal/handlers"
+	"github.com/neo-2022/openclaw-memory/agent-service/internal/health"
+	"github.com/neo-2022/openclaw-memory/agent-service/internal/httpclient"
+	"github.com/neo-2022/openclaw-memory/agent-service/internal/i18n"
+	"github.com/neo-2022/openclaw-memory/agent-service/internal/infosource"
 	"github.com/neo-2022/openclaw-memory/agent-service/internal/intent"
+	"github.com/neo-2022/openclaw-memory/agent-service/internal/issues"
+	"github.com/neo-2022/openclaw-memory/agent-service/internal/jobqueue"
+	"github.com/neo-2022/openclaw-memory/agent-service/internal/kube"
+	"github.com/neo-2022/openclaw-memory/agent-service/internal/learnings"
+	"github.com/neo-2022/openclaw-memory/agent-service/internal/limiter"
 	"github.com/neo-2022/openclaw-memory/agent-service/internal/llm"
+	"github.com/neo-2022/openclaw-memory/agent-service/internal/logger"
+	"github.com/neo-2022/openclaw-memory/agent-service/internal/logstore"
+	"github.com/neo-2022/openclaw-memory/agent-service/internal/middleware"
+	"github.com/neo-2022/openclaw-memory/agent-service/internal/modelrank"
 	"github.com/neo-2022/openclaw-memory/agent-service/internal/models"
+	"github.com/neo-2022/openclaw-memory/agent-service/internal/modelwatch"
+	"github.com/neo-2022/openclaw-memory/agent-service/internal/replay"
+	"github.com/neo-2022/openclaw-memory/agent-service/internal/repomap"
 	"github.com/neo-2022/openclaw-memory/agent-service/internal/repository"
+	"github.com/neo-2022/openclaw-memory/agent-service/internal/respcache"
+	"github.com/neo-2022/openclaw-memory/agent-service/internal/retry"
+	"github.com/neo-2022/openclaw-memory/agent-service/internal/risk"
+	"github.com/neo-2022/openclaw-memory/agent-service/internal/routing"
 	"github.com/neo-2022/openclaw-memory/agent-service/internal/skills"
+	"github.com/neo-2022/openclaw-memory/agent-service/internal/slash"
+	"github.com/neo-2022/openclaw-memory/agent-service/internal/smarthome"
+	"github.com/neo-2022/openclaw-memory/agent-service/internal/speculative"
+	"github.com/neo-2022/openclaw-memory/agent-service/internal/speech"
+	"github.com/neo-2022/openclaw-memory/agent-service/internal/taskreport"
+	"github.com/neo-2022/openclaw-memory/agent-service/internal/textdiff"
+	"github.com/neo-2022/openclaw-memory/agent-service/internal/timeline"
+	"github.com/neo-2022/openclaw-memory/agent-service/internal/toolcall"
 	"github.com/neo-2022/openclaw-memory/agent-service/internal/tools"
+	"github.com/neo-2022/openclaw-memory/agent-service/internal/toolschema"
+	"github.com/neo-2022/openclaw-memory/agent-service/internal/toolspb"
+	"github.com/neo-2022/openclaw-memory/agent-service/internal/toolsrpc"
+	"github.com/neo-2022/openclaw-memory/agent-service/internal/tracing"
+	"github.com/neo-2022/openclaw-memory/agent-service/internal/uploads"
+	"github.com/neo-2022/openclaw-memory/agent-service/internal/vision"
+	"github.com/neo-2022/openclaw-memory/agent-service/internal/warmup"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
 )
 
 // ChatRequest — структура входящего запроса на /chat.
@@ -66,8 +151,28 @@ import (
 //   - Messages: массив сообщений (история диалога), включая роли user, assistant, system
 //   - Agent: имя агента (admin)
 type ChatRequest struct {
-	Messages []llm.Message `json:"messages"`
-	Agent    string        `json:"agent"`
+	Messages   []llm.Message `json:"messages"`
+	Agent      string        `json:"agent"`
+	ImageFiles []string      `json:"image_files,omitempty"` // Изображения из каталога загрузок — прикрепляются к последнему сообщению
+
+	Attachments []ChatAttachment `json:"attachments,omitempty"`  // Документы к последнему сообщению
+	SessionID   string           `json:"session_id,omitempty"`   // Сессия с ранее прикреплёнными документами
+	NoCache     bool             `json:"no_cache,omitempty"`     // Не брать ответ из кэша (RESPONSE_CACHE_MODE) и не сохранять в него
+	ChatID      string           `json:"chat_id,omitempty"`      // Диалог из /conversations: реплики сохраняются в нём
+	Record      bool             `json:"record,omitempty"`       // Записать запрос для воспроизведения (POST /chat/replay)
+	WorkspaceID *uint            `json:"workspace_id,omitempty"` // Текущее рабочее пространство; по умолчанию — диалога или агента
+
+	ClarificationID string `json:"clarification_id,omitempty"` // Ответ на уточняющий вопрос (clarification.id); без него ответом считается следующее сообщение
+
+	IncludeReasoning bool `json:"include_reasoning,omitempty"` // Вернуть размышления reasoning-модели в поле reasoning
+}
+
+// ChatAttachment — документ, прикреплённый к сообщению: содержимое в base64
+// или имя файла из каталога загрузок.
+type ChatAttachment struct {
+	Name string `json:"name"`
+	Data string `json:"data,omitempty"`
+	File string `json:"file,omitempty"`
 }
 
 // ChatResponse — структура ответа от /chat.
@@ -77,10 +182,37 @@ type ChatRequest struct {
 //   - Response: текст ответа от LLM (через выбранного провайдера)
 //   - Error: сообщение об ошибке (опционально, omitempty — не включается если пусто)
 //   - Sources: источники RAG (опционально, для отображения в UI)
+//   - MessageID: ID сохранённого ответа ассистента — для оценки через POST /feedback
+//   - Routing: какая модель ответила и почему (только в режиме маршрутизации)
 type ChatResponse struct {
-	Response string   `json:"response"`
-	Error    string   `json:"error,omitempty"`
-	Sources  []Source `json:"sources,omitempty"`
+	Response  string   `json:"response"`
+	Error     string   `json:"error,omitempty"`
+	Sources   []Source `json:"sources,omitempty"`
+	MessageID uint     `json:"message_id,omitempty"`
+	Command   string   `json:"command,omitempty"`    // Выполненная slash-команда (для /clear интерфейс очищает историю)
+	SessionID string   `json:"session_id,omitempty"` // Сессия прикреплённых документов — передать в следующих сообщениях
+
+	Routing *routing.Decision `json:"routing,omitempty"` // Выбор модели маршрутизатором (ROUTER_ENABLED)
+	Guard   []guard.Finding   `json:"guard,omitempty"`   // Находки защитного слоя (GUARD_INPUT_POLICY, GUARD_OUTPUT_POLICY)
+
+	Confirmation  []risk.Assessment `json:"confirmation,omitempty"`  // Разрушительные действия, ожидающие ответа «да» пользователя
+	Clarification *clarify.Question `json:"clarification,omitempty"` // Уточняющий вопрос агента (ask_user); ответ — следующим сообщением
+	Budget        string            `json:"budget,omitempty"`        // Бюджет исчерпан, ответила локальная модель (BUDGET_FALLBACK_MODEL)
+
+	Changes []models.FileChange `json:"changes,omitempty"` // Изменения файлов инструментами edit_file и write (unified diff)
+
+	// Структурированный итог для программных клиентов (см. taskreport)
+	Status       string                `json:"status,omitempty"`        // completed | partial | needs_confirmation | needs_input | failed
+	ActionsTaken []taskreport.Action   `json:"actions_taken,omitempty"` // Вызовы инструментов по порядку
+	Artifacts    []taskreport.Artifact `json:"artifacts,omitempty"`     // Созданные и изменённые файлы
+
+	RecordingID uint `json:"recording_id,omitempty"` // Запись запроса для POST /chat/replay (поле record или REPLAY_RECORD)
+
+	Reasoning string `json:"reasoning,omitempty"` // Размышления модели перед ответом (include_reasoning)
+
+	QuotaWarning string `json:"quota_warning,omitempty"` // Заканчивается лимит облачного провайдера (PROVIDER_QUOTA_WARN)
+
+	Persona string `json:"persona,omitempty"` // Образ агента, которым дан ответ (/agents/{name}/persona)
 }
 
 // Source представляет источник RAG для отображения в UI
@@ -114,21 +246,6 @@ type UpdatePromptRequest struct {
 	Prompt string `json:"prompt"`
 }
 
-// getEnv — вспомогательная функция для чтения переменных окружения.
-// Если переменная не задана или пуста — возвращает значение по умолчанию.
-//
-// Параметры:
-//   - key: имя переменной окружения
-//   - defaultValue: значение по умолчанию
-//
-// Возвращает: значение переменной окружения или defaultValue.
-func getEnv(key, defaultValue string) string {
-	if value := os.Getenv(key); value != "" {
-		return value
-	}
-	return defaultValue
-}
-
 var requestCounter uint64
 
 func generateRequestID() string {
@@ -158,45 +275,35 @@ func requestIDMiddleware(next http.HandlerFunc) http.HandlerFunc {
 var ragRetriever *rag.DBRetriever
 
 // initRAG — инициализация RAG-системы при старте.
-// Загружает конфигурацию из переменных окружения и создаёт экземпляр DBRetriever.
+// Берёт параметры из конфигурации сервиса и создаёт экземпляр DBRetriever.
+// Лимиты поиска (RAG_TOP_K и др.) обновляются на лету при перезагрузке конфигурации.
 func initRAG() {
-	chromaURL := getEnv("CHROMA_URL", "")
-	embModel := getEnv("EMBEDDING_MODEL", "nomic-embed-text")
-	topK := 5
-	if k := getEnv("RAG_TOP_K", ""); k != "" {
-		if parsed, err := strconv.Atoi(k); err == nil {
-			topK = parsed
-		}
-	}
-
-	maxChunkLen := rag.DefaultMaxChunkLen
-	if v := getEnv("RAG_MAX_CHUNK_LEN", ""); v != "" {
-		if parsed, err := strconv.Atoi(v); err == nil {
-			maxChunkLen = parsed
-		}
-	}
-
-	maxContextLen := rag.DefaultMaxContextLen
-	if v := getEnv("RAG_MAX_CONTEXT_LEN", ""); v != "" {
-		if parsed, err := strconv.Atoi(v); err == nil {
-			maxContextLen = parsed
-		}
-	}
+	cfg := config.Current()
+	chromaURL := cfg.ChromaURL
+	embModel := cfg.EmbeddingModel
+	topK := cfg.RAGTopK
 
-	config := &rag.Config{
+	ragConfig := &rag.Config{
 		ChromaURL:      chromaURL,
 		EmbeddingModel: embModel,
 		TopK:           topK,
-		MaxChunkLen:    maxChunkLen,
-		MaxContextLen:  maxContextLen,
-		DBHost:         getEnv("DB_HOST", "localhost"),
-		DBPort:         getEnv("DB_PORT", "5432"),
-		DBUser:         getEnv("DB_USER", "postgres"),
-		DBPassword:     getEnv("DB_PASSWORD", "postgres"),
-		DBName:         getEnv("DB_NAME", "agentcore"),
-	}
-
-	ragRetriever = rag.NewDBRetriever(config)
+		MaxChunkLen:    cfg.RAGMaxChunkLen,
+		MaxContextLen:  cfg.RAGMaxContextLen,
+		DBHost:         cfg.DBHost,
+		DBPort:         cfg.DBPort,
+		DBUser:         cfg.DBUser,
+		DBPassword:     cfg.DBPassword,
+		DBName:         cfg.DBName,
+	}
+	if cfg.DBDriver == config.DriverSQLite {
+		// Таблица rag_docs ведётся только в PostgreSQL; в режиме SQLite она не создаётся
+		ragConfig.DBHost = ""
+	}
+
+	ragRetriever = rag.NewDBRetriever(ragConfig)
+	config.OnReload(func(c *config.Config) {
+		ragRetriever.SetLimits(c.RAGTopK, c.RAGMaxChunkLen, c.RAGMaxContextLen)
+	})
 	slog.Info("RAG инициализирован", slog.String("chroma_url", chromaURL), slog.String("модель", embModel), slog.Int("topK", topK))
 
 	// Загружаем демо-документы в ChromA при первом запуске
@@ -215,8 +322,8 @@ func initRAG() {
 // Инструменты browser-service (порт 8084): browser_*, input_*, internet_search, crawler_*, check_url_access и т.д.
 // Возвращает (baseURL, path). Если инструмент не найден — возвращает tools-service с /toolName.
 func resolveToolRoute(toolName string) (string, string) {
-	toolsURL := getEnv("TOOLS_SERVICE_URL", "http://localhost:8082")
-	browserURL := getEnv("BROWSER_SERVICE_URL", "http://localhost:8084")
+	toolsURL := config.Current().ToolsServiceURL
+	browserURL := config.Current().BrowserServiceURL
 
 	// Маппинг имён инструментов → (сервис, эндпоинт)
 	browserRoutes := map[string]string{
@@ -248,6 +355,40 @@ func resolveToolRoute(toolName string) (string, string) {
 		return browserURL, path
 	}
 
+	// Инструменты tools-service, имя эндпоинта которых отличается от имени инструмента
+	toolsRoutes := map[string]string{
+		"run_code":    "/run-code",
+		"lint_code":   "/lint",
+		"format_code": "/format",
+		"mail_list":   "/mail/list",
+		"mail_read":   "/mail/read",
+		"mail_send":   "/mail/send",
+
+		"calendar_list":   "/calendar/list",
+		"calendar_create": "/calendar/create",
+		"calendar_update": "/calendar/update",
+
+		"prometheus_query": "/prometheus/query",
+		"disk_usage":       "/diskusage",
+
+		"net_ping":       "/net/ping",
+		"net_traceroute": "/net/traceroute",
+		"net_dns":        "/net/dns",
+		"net_public_ip":  "/net/publicip",
+		"net_interfaces": "/net/interfaces",
+
+		"security_ports":    "/security/ports",
+		"security_sudo":     "/security/sudo",
+		"security_ssh":      "/security/ssh",
+		"security_updates":  "/security/updates",
+		"security_writable": "/security/writable",
+
+		"workspace_init": "/workspace/init",
+	}
+	if path, ok := toolsRoutes[toolName]; ok {
+		return toolsURL, path
+	}
+
 	// Всё остальное — tools-service (execute, read, write, list, delete, sysinfo, sysload, cputemp и т.д.)
 	return toolsURL, "/" + toolName
 }
@@ -267,10 +408,13 @@ func sanitizeArgs(args map[string]interface{}) map[string]interface{} {
 	safe := make(map[string]interface{}, len(args))
 	for k, v := range args {
 		lower := strings.ToLower(k)
-		if strings.Contains(lower, "token") || strings.Contains(lower, "secret") || strings.Contains(lower, "password") || strings.Contains(lower, "key") {
+		if strings.Contains(lower, "token") || strings.Contains(lower, "secret") || strings.Contains(lower, "password") || strings.Contains(lower, "key") || lower == "env" {
 			safe[k] = "***"
-		} else if s, ok := v.(string); ok && len(s) > 200 {
-			safe[k] = s[:200] + "..."
+		} else if s, ok := v.(string); ok {
+			if len(s) > 200 {
+				s = s[:200] + "..."
+			}
+			safe[k] = s
 		} else {
 			safe[k] = v
 		}
@@ -279,70 +423,147 @@ func sanitizeArgs(args map[string]interface{}) map[string]interface{} {
 }
 
 func callTool(toolName string, args map[string]interface{}) (map[string]interface{}, error) {
+	return callToolCtx(context.Background(), toolName, args)
+}
+
+// callToolCtx — вызов инструмента tools-service/browser-service в рамках трассировки ctx.
+// Открывает клиентский спан tool.call и передаёт сервису заголовок traceparent.
+func callToolCtx(ctx context.Context, toolName string, args map[string]interface{}) (result map[string]interface{}, err error) {
 	callStart := time.Now()
 	baseURL, path := resolveToolRoute(toolName)
 	fullURL := baseURL + path
+	ctx, span := tracing.StartClient(ctx, "tool.call "+toolName,
+		attribute.String("tool.name", toolName),
+		attribute.String("url.full", fullURL),
+	)
+	defer func() {
+		if err != nil {
+			tracing.RecordError(span, err)
+		} else if msg, ok := result["error"].(string); ok {
+			span.SetStatus(codes.Error, msg)
+		}
+		span.End()
+	}()
 	slog.Info("[TOOL-CALL] начало",
 		slog.String("инструмент", toolName),
 		slog.String("url", fullURL),
 		slog.Any("параметры", sanitizeArgs(args)),
 	)
 
-	data, err := json.Marshal(args)
+	// Переменные окружения добавляются только в тело запроса — в лог выше они не попадают
+	env := envVarsFrom(ctx)
+	body := args
+	if vars := toolEnv(env, args); vars != nil && (toolName == "execute" || toolName == "run_code") && baseURL == config.Current().ToolsServiceURL {
+		body = make(map[string]interface{}, len(args)+1)
+		for k, v := range args {
+			body[k] = v
+		}
+		body["env"] = vars
+	}
+	data, err := json.Marshal(body)
 	if err != nil {
 		slog.Error("[TOOL-CALL] ошибка маршалинга", slog.String("инструмент", toolName), slog.String("ошибка", err.Error()), slog.Duration("длительность", time.Since(callStart)))
 		return nil, err
 	}
-	// Создаём HTTP клиент с заголовком авторизации для tools-service
-	client := &http.Client{}
-	req, err := http.NewRequest("POST", fullURL, bytes.NewReader(data))
+	// Клиент tools-service на общем пуле; время вызова ограничивает ctx
+	client := httpclient.New("tools", 0)
+	req, err := http.NewRequestWithContext(ctx, "POST", fullURL, bytes.NewReader(data))
 	if err != nil {
 		slog.Error("[TOOL-CALL] ошибка создания запроса", slog.String("инструмент", toolName), slog.String("ошибка", err.Error()), slog.Duration("длительность", time.Since(callStart)))
 		return nil, err
 	}
 	req.Header.Set("Content-Type", "application/json")
+	tracing.Inject(ctx, req.Header)
+	// Тот же X-Request-ID связывает записи системного лога от tools-service и browser-service
+	if cid, ok := ctx.Value(logger.CorrelationIDKey).(string); ok && cid != "" {
+		req.Header.Set("X-Request-ID", cid)
+	}
 	// Добавляем токен авторизации для tools-service
-	toolsToken := getEnv("TOOLS_SERVICE_TOKEN", "")
+	toolsToken := config.Current().ToolsServiceToken
 	if toolsToken != "" {
 		req.Header.Set("Authorization", "Bearer "+toolsToken)
 	}
-	resp, err := client.Do(req)
-	if err != nil {
-		slog.Error("[TOOL-CALL] ошибка HTTP", slog.String("инструмент", toolName), slog.String("ошибка", err.Error()), slog.Duration("длительность", time.Since(callStart)))
-		return nil, err
+	// Каталог текущего рабочего пространства: относительные пути и cwd команд
+	if root := workspaceFrom(ctx); root != "" && baseURL == config.Current().ToolsServiceURL {
+		req.Header.Set("X-Workspace-Root", root)
 	}
-	defer resp.Body.Close()
+	var (
+		statusCode int
+		respHeader = http.Header{}
+		bodyBytes  []byte
+		viaRPC     bool
+	)
+	if toolsRPC != nil && baseURL == config.Current().ToolsServiceURL {
+		// Команды и файловые инструменты — методы gRPC; результат приводится к
+		// статусу и телу HTTP-эндпоинта. Остальные инструменты — по HTTP ниже
+		statusCode, bodyBytes, viaRPC, err = callToolRPC(ctx, toolName, path, args, req.Header)
+		if err != nil {
+			slog.Error("[TOOL-CALL] ошибка gRPC", slog.String("инструмент", toolName), slog.String("ошибка", err.Error()), slog.Duration("длительность", time.Since(callStart)))
+			return nil, err
+		}
+	} else if browserRPC != nil && baseURL == config.Current().BrowserServiceURL {
+		statusCode, bodyBytes, err = browserRPC.Invoke(ctx, path, data, req.Header)
+		if err != nil {
+			slog.Error("[TOOL-CALL] ошибка gRPC", slog.String("инструмент", toolName), slog.String("ошибка", err.Error()), slog.Duration("длительность", time.Since(callStart)))
+			return nil, err
+		}
+		viaRPC = true
+	}
+	if !viaRPC {
+		resp, err := client.Do(req)
+		if err != nil {
+			slog.Error("[TOOL-CALL] ошибка HTTP", slog.String("инструмент", toolName), slog.String("ошибка", err.Error()), slog.Duration("длительность", time.Since(callStart)))
+			return nil, err
+		}
+		defer resp.Body.Close()
 
-	bodyBytes, err := io.ReadAll(resp.Body)
-	if err != nil {
-		slog.Error("[TOOL-CALL] ошибка чтения", slog.String("инструмент", toolName), slog.String("ошибка", err.Error()), slog.Duration("длительность", time.Since(callStart)))
-		return nil, fmt.Errorf("ошибка чтения ответа: %v", err)
+		bodyBytes, err = io.ReadAll(resp.Body)
+		if err != nil {
+			slog.Error("[TOOL-CALL] ошибка чтения", slog.String("инструмент", toolName), slog.String("ошибка", err.Error()), slog.Duration("длительность", time.Since(callStart)))
+			return nil, fmt.Errorf("ошибка чтения ответа: %v", err)
+		}
+		statusCode, respHeader = resp.StatusCode, resp.Header
 	}
 
+	// Значения секретов не должны попасть в ответ модели
+	bodyBytes = []byte(toolRedactor(env, args).Redact(string(bodyBytes)))
+
 	duration := time.Since(callStart)
-	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+	if statusCode < 200 || statusCode >= 300 {
+		span.SetAttributes(attribute.Int("http.response.status_code", statusCode))
 		slog.Warn("[TOOL-CALL] HTTP ошибка",
 			slog.String("инструмент", toolName),
-			slog.Int("статус", resp.StatusCode),
+			slog.Int("статус", statusCode),
 			slog.Duration("длительность", duration),
 			slog.String("outcome", "error"),
 		)
-		return map[string]interface{}{
-			"error":       fmt.Sprintf("HTTP %d от %s", resp.StatusCode, fullURL),
-			"status_code": resp.StatusCode,
-			"body":        string(bodyBytes),
-		}, nil
+		// Ошибку сервиса приводим к единому формату apierror, чтобы модель
+		// видела код, текст и подсказку, а не сырое тело ответа.
+		apiErr := apierror.Parse(statusCode, respHeader, bodyBytes)
+		result = map[string]interface{}{
+			"error":       apiErr.Message,
+			"code":        apiErr.Code,
+			"retryable":   apiErr.Retryable,
+			"status_code": statusCode,
+			"source":      fullURL,
+		}
+		if apiErr.Hint != "" {
+			result["hint"] = apiErr.Hint
+		}
+		if apiErr.RequestID != "" {
+			result["request_id"] = apiErr.RequestID
+		}
+		return result, nil
 	}
 
 	slog.Info("[TOOL-CALL] завершён",
 		slog.String("инструмент", toolName),
-		slog.Int("статус", resp.StatusCode),
+		slog.Int("статус", statusCode),
 		slog.Duration("длительность", duration),
 		slog.Int("байт_ответа", len(bodyBytes)),
 		slog.String("outcome", "success"),
 	)
 
-	var result map[string]interface{}
 	if err := json.Unmarshal(bodyBytes, &result); err == nil {
 		return result, nil
 	}
@@ -360,6 +581,122 @@ func callTool(toolName string, args map[string]interface{}) (map[string]interfac
 	return map[string]interface{}{"result": string(bodyBytes)}, nil
 }
 
+// toolsRPC — gRPC-клиент tools-service (TOOLS_GRPC_ADDR); nil — вызовы по HTTP.
+var toolsRPC *toolsrpc.Client
+
+// initToolsRPC — подключение к внутреннему gRPC API tools-service, если задан TOOLS_GRPC_ADDR.
+func initToolsRPC() {
+	addr := config.Current().ToolsGRPCAddr
+	if addr == "" {
+		return
+	}
+	client, err := toolsrpc.New(addr)
+	if err != nil {
+		slog.Warn("gRPC tools-service недоступен, инструменты вызываются по HTTP", slog.String("адрес", addr), slog.String("ошибка", err.Error()))
+		return
+	}
+	toolsRPC = client
+	slog.Info("Инструменты tools-service вызываются по gRPC", slog.String("адрес", addr))
+}
+
+// browserRPC — gRPC-клиент browser-service (BROWSER_GRPC_ADDR); nil — вызовы по HTTP.
+var browserRPC *browserrpc.Client
+
+// initBrowserRPC — подключение к внутреннему gRPC API browser-service, если задан BROWSER_GRPC_ADDR.
+func initBrowserRPC() {
+	addr := config.Current().BrowserGRPCAddr
+	if addr == "" {
+		return
+	}
+	client, err := browserrpc.New(addr)
+	if err != nil {
+		slog.Warn("gRPC browser-service недоступен, инструменты браузера вызываются по HTTP", slog.String("адрес", addr), slog.String("ошибка", err.Error()))
+		return
+	}
+	browserRPC = client
+	slog.Info("Инструменты browser-service вызываются по gRPC", slog.String("адрес", addr))
+}
+
+// toolEnv — окружение команды: переменные запроса /chat (пространство и
+// диалог) и собственные args["env"] вызова, например учётные данные git push;
+// собственные перекрывают одноимённые. nil — переменных нет.
+func toolEnv(env *envvars.Set, args map[string]interface{}) map[string]string {
+	own, _ := args["env"].(map[string]string)
+	if env == nil {
+		return own
+	}
+	if len(own) == 0 {
+		return env.Env
+	}
+	out := make(map[string]string, len(env.Env)+len(own))
+	for k, v := range env.Env {
+		out[k] = v
+	}
+	for k, v := range own {
+		out[k] = v
+	}
+	return out
+}
+
+// toolRedactor — секреты, вырезаемые из ответа инструмента: переменные
+// запроса и токен push gitflow из собственных переменных вызова (args["env"]).
+func toolRedactor(env *envvars.Set, args map[string]interface{}) *envvars.Set {
+	own, _ := args["env"].(map[string]string)
+	return env.WithSecret(own[gitflow.PushTokenEnv])
+}
+
+// callToolRPC — вызов инструмента tools-service по gRPC. ok=false — у
+// эндпоинта path нет метода gRPC, инструмент вызывается по HTTP. Ответ —
+// статус и JSON-тело одноимённого HTTP-эндпоинта. Вывод команды /execute
+// приходит по мере выполнения и попадает в хронологию запроса.
+func callToolRPC(ctx context.Context, toolName, path string, args map[string]interface{}, header http.Header) (status int, body []byte, ok bool, err error) {
+	filePath, _ := args["path"].(string)
+	var resp interface{}
+	switch path {
+	case "/execute":
+		command, _ := args["command"].(string)
+		tl := timelineFrom(ctx)
+		env := envVarsFrom(ctx)
+		redactor := toolRedactor(env, args)
+		var res *toolspb.ExecuteResult
+		res, err = toolsRPC.Execute(ctx, command, toolEnv(env, args), header, func(stream string, chunk []byte) {
+			tl.Add(timeline.Event{Type: timeline.EventToolOutput, Tool: toolName, Target: stream, Detail: truncate(redactor.Redact(string(chunk)), 500)})
+		})
+		if err == nil {
+			out := map[string]interface{}{"stdout": res.GetStdout(), "stderr": res.GetStderr(), "returncode": res.GetReturnCode()}
+			if res.GetError() != "" {
+				out["error"] = res.GetError()
+			}
+			resp = out
+		}
+	case "/read":
+		var content string
+		content, err = toolsRPC.ReadFile(ctx, filePath, header)
+		resp = map[string]string{"content": content}
+	case "/write":
+		content, _ := args["content"].(string)
+		err = toolsRPC.WriteFile(ctx, filePath, content, header)
+		resp = map[string]string{"status": "ok"}
+	case "/list":
+		var files []string
+		files, err = toolsRPC.ListDir(ctx, filePath, header)
+		resp = map[string][]string{"files": files}
+	case "/delete":
+		err = toolsRPC.DeleteFile(ctx, filePath, header)
+		resp = map[string]string{"status": "ok"}
+	default:
+		return 0, nil, false, nil
+	}
+	if err != nil {
+		if status, body, ok := toolsrpc.HTTPError(err); ok {
+			return status, body, true, nil
+		}
+		return 0, nil, true, err
+	}
+	body, err = json.Marshal(resp)
+	return http.StatusOK, body, true, err
+}
+
 // chatHandler— основной обработчик чат-запросов (POST /chat).
 // Это главная точка взаимодействия пользователя с AI-агентами.
 //
@@ -380,45 +717,274 @@ func callTool(toolName string, args map[string]interface{}) (map[string]interfac
 //  7. Сохранение сообщений в PostgreSQL (пользовательское + ответ агента)
 //  8. Возврат ответа клиенту в формате ChatResponse
 //
-// chatWithRetry — обёртка над provider.Chat с повторными попытками при транзиентных ошибках (503, 504).
-// Бесплатные модели на Routeway/OpenRouter часто возвращают временные ошибки.
-// Делаем до 3 попыток с паузой 3 секунды между ними.
-func chatWithRetry(provider llm.ChatProvider, req *llm.ChatRequest) (*llm.ChatResponse, error) {
-	const maxRetries = 3
-	var lastErr error
-	for attempt := 0; attempt < maxRetries; attempt++ {
+// chatWithRetry — обёртка над provider.Chat с повторными попытками при временных ошибках
+// (429, 502-504): бесплатные модели на Routeway/OpenRouter часто их возвращают.
+// Число попыток, паузы с jitter и бюджет повторов — LLM_RETRY_*, см. пакет retry.
+// Каждая попытка — отдельный спан llm.chat с провайдером и моделью.
+func chatWithRetry(ctx context.Context, provider llm.ChatProvider, req *llm.ChatRequest) (*llm.ChatResponse, error) {
+	if rs := replayFrom(ctx); rs != nil && rs.Player.MockLLM {
+		// Воспроизведение: ответ модели из записи, провайдер не вызывается
+		resp, err := rs.Player.NextLLM()
+		call := timeline.Event{Type: timeline.EventLLMCall, Provider: "replay", Model: req.Model, Status: "ok", Detail: "ответ из записи"}
+		if err != nil {
+			call.Status, call.Detail = "error", err.Error()
+		}
+		timelineFrom(ctx).Add(call)
+		if err == nil && req.OnDelta != nil && resp.Content != "" {
+			req.OnDelta(resp.Content)
+		}
+		return resp, err
+	}
+	if provider.Name() == "ollama" {
+		// Время удержания модели в памяти зависит от частоты её использования
+		req.KeepAlive = modelWarmer.KeepAlive(req.Model)
+		modelWarmer.Touch(req.Model)
+	}
+	cacheOpt := responseCacheOptions()
+	if cacheOpt.Mode != respcache.ModeOff {
+		if respcache.Bypassed(ctx) {
+			metrics.RecordResponseCache(respcache.ResultBypass)
+		} else {
+			cached, result := responseCache.Get(req, cacheOpt)
+			metrics.RecordResponseCache(result)
+			if cached != nil {
+				timelineFrom(ctx).Add(timeline.Event{Type: timeline.EventCacheHit, Provider: provider.Name(), Model: req.Model, Detail: result})
+				slog.Info("Ответ взят из кэша", slog.String("модель", req.Model), slog.String("результат", result))
+				if req.OnDelta != nil && cached.Content != "" {
+					req.OnDelta(cached.Content)
+				}
+				recorderFrom(ctx).RecordLLM(cached)
+				return cached, nil
+			}
+		}
+	}
+	cfg := config.Current()
+	policy := retry.Policy{MaxAttempts: cfg.LLMRetryMaxAttempts, BaseDelay: cfg.LLMRetryBaseDelay, MaxDelay: cfg.LLMRetryMaxDelay}
+	llmRetryBudget.SetLimit(cfg.LLMRetryBudget)
+	tl := timelineFrom(ctx)
+	// Отключённый предохранителем провайдер не вызывается: ошибка сразу, без ожидания повторов
+	providerBreakers.Configure(cfg.ProviderBreakerFailures, cfg.ProviderBreakerReset)
+	cb := providerBreakers.Get(provider.Name())
+	if err := cb.Allow(); err != nil {
+		metrics.RecordLLMCircuit(provider.Name(), int(breaker.StateOpen), true)
+		tl.Add(timeline.Event{Type: timeline.EventLLMCall, Provider: provider.Name(), Model: req.Model, Status: "error", Detail: err.Error()})
+		return nil, err
+	}
+	for attempt := 0; ; attempt++ {
+		callStart := time.Now()
+		_, span := tracing.StartClient(ctx, "llm.chat",
+			attribute.String("llm.provider", provider.Name()),
+			attribute.String("llm.model", req.Model),
+			attribute.Int("llm.attempt", attempt+1),
+			attribute.Int("llm.messages", len(req.Messages)),
+			attribute.Int("llm.tools", len(req.Tools)),
+		)
 		resp, err := provider.Chat(req)
+		cb.Record(err)
+		metrics.RecordLLMCircuit(provider.Name(), int(cb.State()), false)
+		call := timeline.Event{Type: timeline.EventLLMCall, Provider: provider.Name(), Model: req.Model, Attempt: attempt + 1, DurationMs: time.Since(callStart).Milliseconds(), Status: "ok"}
+		if err != nil {
+			call.Status, call.Detail = "error", truncate(err.Error(), 300)
+		} else if len(resp.ToolCalls) > 0 {
+			call.Detail = fmt.Sprintf("вызовов инструментов: %d", len(resp.ToolCalls))
+		}
+		tl.Add(call)
 		if err == nil {
+			span.SetAttributes(attribute.Int("llm.tool_calls", len(resp.ToolCalls)))
+			span.End()
+			recordUsage(ctx, provider.Name(), req, resp)
+			recorderFrom(ctx).RecordLLM(resp)
+			if !respcache.Bypassed(ctx) {
+				responseCache.Put(req, resp, cacheOpt)
+			}
 			return resp, nil
 		}
-		lastErr = err
-		errStr := err.Error()
-		if strings.Contains(errStr, "429") {
-			delay := time.Duration(3*(attempt+1)) * time.Second
-			slog.Warn("Rate limit 429", slog.Int("попытка", attempt+1), slog.Int("макс", maxRetries), slog.String("ошибка", err.Error()), slog.Duration("задержка", delay))
-			time.Sleep(delay)
-			continue
+		tracing.RecordError(span, err)
+		span.End()
+		reason, retryAfter, ok := retry.Classify(err)
+		if !ok || attempt+1 >= policy.MaxAttempts || cb.State() == breaker.StateOpen {
+			return nil, err
 		}
-		if strings.Contains(errStr, "503") || strings.Contains(errStr, "504") || strings.Contains(errStr, "502") {
-			delay := 3 * time.Second
-			slog.Warn("Транзиентная ошибка LLM", slog.Int("попытка", attempt+1), slog.Int("макс", maxRetries), slog.String("ошибка", err.Error()), slog.Duration("задержка", delay))
-			time.Sleep(delay)
-			continue
+		delay, ok := policy.Delay(attempt+1, retryAfter)
+		if !ok {
+			slog.Warn("Провайдер просит повторить позже предела паузы, без повтора", slog.String("провайдер", provider.Name()), slog.Duration("retry_after", retryAfter))
+			return nil, err
 		}
-		return nil, err
+		if !llmRetryBudget.Allow(provider.Name()) {
+			metrics.RecordLLMRetryBudgetExhausted(provider.Name())
+			slog.Warn("Бюджет повторов провайдера исчерпан", slog.String("провайдер", provider.Name()), slog.Int("лимит_в_минуту", cfg.LLMRetryBudget))
+			return nil, err
+		}
+		metrics.RecordLLMRetry(provider.Name(), reason, llmRetryBudget.Remaining(provider.Name()))
+		slog.Warn("Временная ошибка LLM, повтор", slog.String("причина", reason), slog.Int("попытка", attempt+1), slog.Int("макс", policy.MaxAttempts), slog.String("ошибка", err.Error()), slog.Duration("задержка", delay))
+		tl.Add(timeline.Event{Type: timeline.EventLLMRetry, Provider: provider.Name(), Model: req.Model, Attempt: attempt + 1, DurationMs: delay.Milliseconds(), Detail: reason})
+		if retry.Sleep(ctx, delay) != nil {
+			return nil, err
+		}
+	}
+}
+
+// llmRetryBudget — повторы к каждому провайдеру за минуту (LLM_RETRY_BUDGET).
+var llmRetryBudget = retry.NewBudget(0, time.Minute)
+
+// providerBreakers — предохранители LLM-провайдеров (PROVIDER_BREAKER_*).
+var providerBreakers = breaker.NewSet(0, time.Minute)
+
+// responseCache — ответы LLM на повторяющиеся запросы (RESPONSE_CACHE_MODE).
+var responseCache = respcache.New()
+
+// responseCacheOptions — параметры кэша ответов из текущей конфигурации.
+func responseCacheOptions() respcache.Options {
+	cfg := config.Current()
+	return respcache.Options{
+		Mode:       cfg.ResponseCacheMode,
+		TTL:        cfg.ResponseCacheTTL,
+		MaxEntries: cfg.ResponseCacheMaxEntries,
+		Similarity: cfg.ResponseCacheSimilarity,
+	}
+}
+
+// recordUsage — сохраняет расход токенов ответа для бюджетов и GET /usage.
+// Облачные провайдеры не сообщают токены через ChatResponse — число
+// оценивается по длине текста (Estimated).
+func recordUsage(ctx context.Context, providerName string, req *llm.ChatRequest, resp *llm.ChatResponse) {
+	if db.DB == nil {
+		return
+	}
+	subject := budget.SubjectFrom(ctx)
+	rec := models.UsageRecord{
+		AgentName: subject.Agent,
+		KeyID:     subject.KeyID,
+		Provider:  providerName,
+		ModelName: req.Model,
+	}
+	if resp.Stats != nil && resp.Stats.PromptTokens+resp.Stats.CompletionTokens > 0 {
+		rec.PromptTokens, rec.CompletionTokens = resp.Stats.PromptTokens, resp.Stats.CompletionTokens
+	} else {
+		rec.Estimated = true
+		for _, m := range req.Messages {
+			rec.PromptTokens += learnings.EstimateTokens(m.Content)
+		}
+		rec.CompletionTokens = learnings.EstimateTokens(resp.Content)
+		for _, tc := range resp.ToolCalls {
+			rec.CompletionTokens += learnings.EstimateTokens(tc.Function.Name) + learnings.EstimateTokens(string(tc.Function.Arguments))
+		}
+	}
+	rec.Cost = budgetPrices().Cost(req.Model, rec.PromptTokens, rec.CompletionTokens)
+	metrics.RecordLLMToken(providerName, req.Model, "prompt", rec.PromptTokens)
+	metrics.RecordLLMToken(providerName, req.Model, "completion", rec.CompletionTokens)
+	if err := budget.Record(db.DB, &rec); err != nil {
+		slog.Warn("Не удалось записать расход токенов", slog.String("модель", req.Model), slog.String("ошибка", err.Error()))
+	}
+}
+
+// budgetPrices — цены моделей из BUDGET_PRICES; при ошибке формата стоимость не считается.
+func budgetPrices() budget.Prices {
+	prices, err := budget.ParsePrices(config.Current().BudgetPrices)
+	if err != nil {
+		slog.Warn("Некорректный BUDGET_PRICES", slog.String("ошибка", err.Error()))
+	}
+	return prices
+}
+
+// requestKeyID — отпечаток API-ключа клиента: Bearer-токен, переданный шлюзом, или X-API-Key.
+func requestKeyID(r *http.Request) string {
+	token := strings.TrimSpace(r.Header.Get("X-API-Key"))
+	if auth := r.Header.Get("Authorization"); token == "" && strings.HasPrefix(auth, "Bearer ") {
+		token = strings.TrimSpace(strings.TrimPrefix(auth, "Bearer "))
+	}
+	return budget.KeyID(token)
+}
+
+// routeChat — выбор модели под запрос; nil, если маршрутизация выключена.
+// Если быстрая модель недоступна (провайдер не настроен), остаётся основная.
+func routeChat(req *ChatRequest, lastMsg, providerName, modelName string) *routing.Decision {
+	cfg := config.Current()
+	if !cfg.RouterEnabled || cfg.RouterFastModel == "" {
+		return nil
+	}
+	router := &routing.Router{
+		Fast:      routing.Target{Provider: cfg.RouterFastProvider, Model: cfg.RouterFastModel},
+		Threshold: cfg.RouterThreshold,
+	}
+	fast, err := llm.GlobalRegistry.Get(cfg.RouterFastProvider)
+	if err == nil && cfg.RouterClassifierModel != "" {
+		router.Classifier, router.ClassifierModel = fast, cfg.RouterClassifierModel
+	}
+	d := router.Route(routing.Input{
+		Message:        lastMsg,
+		Turns:          len(req.Messages),
+		HasImages:      llm.HasImages(req.Messages),
+		HasAttachments: len(req.Attachments) > 0 || req.SessionID != "",
+	}, routing.Target{Provider: providerName, Model: modelName})
+	if d.Tier == routing.TierFast && err != nil {
+		d.Tier, d.Provider, d.Model = routing.TierStrong, providerName, modelName
+		d.Reasons = append(d.Reasons, "быстрая модель недоступна: "+err.Error())
+	}
+	return &d
+}
+
+// modelSupportsTools — поддержка инструментов моделью, выбранной маршрутизатором:
+// для Ollama — по кэшу проверки, облачные модели вызывают инструменты.
+func modelSupportsTools(providerName, modelName string) bool {
+	if providerName != "ollama" {
+		return true
+	}
+	ok, err := repository.GetModelToolSupport(modelName)
+	return err == nil && ok
+}
+
+// routerStatsHandler — GET /router/stats: сколько ответов дала быстрая и
+// основная модель (по сохранённым сообщениям), ?agent= — только один агент.
+func routerStatsHandler(w http.ResponseWriter, r *http.Request) {
+	cid := r.Header.Get("X-Request-ID")
+	if r.Method != http.MethodGet {
+		apierror.MethodNotAllowed(w, cid)
+		return
+	}
+	type row struct {
+		Tier     string `json:"tier"`
+		Provider string `json:"provider"`
+		Model    string `json:"model"`
+		Count    int64  `json:"count"`
+	}
+	q := db.DB.Model(&models.Message{}).
+		Select("messages.route_tier AS tier, messages.provider AS provider, messages.llm_model AS model, COUNT(*) AS count").
+		Where("messages.role = ? AND messages.route_tier <> ''", "assistant").
+		Group("messages.route_tier, messages.provider, messages.llm_model").
+		Order("count DESC")
+	if agent := r.URL.Query().Get("agent"); agent != "" {
+		q = q.Joins("JOIN agents ON agents.id = messages.agent_id").Where("agents.name = ?", agent)
+	}
+	rows := []row{}
+	if err := q.Scan(&rows).Error; err != nil {
+		apierror.InternalError(w, cid, "Ошибка чтения статистики маршрутизации", err.Error())
+		return
 	}
-	return nil, lastErr
+	cfg := config.Current()
+	w.Header().Set("Content-Type", "application/json")
+	writeJSON(w, map[string]interface{}{
+		"enabled":    cfg.RouterEnabled,
+		"fast_model": cfg.RouterFastProvider + "/" + cfg.RouterFastModel,
+		"threshold":  cfg.RouterThreshold,
+		"models":     rows,
+	})
 }
 
 func chatHandler(w http.ResponseWriter, r *http.Request) {
 	startTime := time.Now()
 	statusCode := 200
 	cid := r.Header.Get("X-Request-ID")
+	ctx := logger.WithCorrelationID(r.Context(), cid)
 
 	if r.Method != http.MethodPost {
 		apierror.MethodNotAllowed(w, cid)
 		return
 	}
+	if strings.Contains(r.Header.Get("Accept"), "text/event-stream") && progress.From(r.Context()) == nil {
+		chatStreamHandler(w, r)
+		return
+	}
 
 	var req ChatRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -429,17 +995,106 @@ func chatHandler(w http.ResponseWriter, r *http.Request) {
 		apierror.BadRequest(w, cid, "Пустой список messages", "Передайте хотя бы одно сообщение")
 		return
 	}
+	if req.NoCache || strings.Contains(r.Header.Get("Cache-Control"), "no-cache") {
+		ctx = respcache.WithBypass(ctx)
+	}
+	// Запись для воспроизведения (POST /chat/replay); при воспроизведении не ведётся
+	rs := replayFrom(ctx)
+	var recorder *replay.Recorder
+	var rawRequest []byte
+	if rs != nil {
+		ctx = respcache.WithBypass(ctx)
+	} else if req.Record || config.Current().ReplayRecord {
+		recorder = &replay.Recorder{}
+		ctx = context.WithValue(ctx, recorderKey{}, recorder)
+		rawRequest, _ = json.Marshal(req)
+	}
+	// Хронология запроса для GET /chat/{request_id}/events; итог — как поле status ответа
+	outcome := taskreport.StatusFailed
+	if cid != "" {
+		tl := chatTimelines.Start(cid, req.Agent)
+		ctx = context.WithValue(ctx, timelineKey{}, tl)
+		defer func() { tl.Finish(outcome, "") }()
+	}
+	var conv *models.Chat
+	if req.ChatID != "" {
+		conv = &models.Chat{}
+		if err := db.DB.Where("id = ?", req.ChatID).First(conv).Error; err != nil {
+			apierror.NotFound(w, cid, "Диалог не найден")
+			return
+		}
+		ctx = context.WithValue(ctx, conversationKey{}, conv.ID)
+	}
+
+	if err := prepareChatImages(&req); err != nil {
+		apierror.BadRequest(w, cid, "Некорректное изображение: "+err.Error(), "Передайте PNG/JPEG/GIF/WebP в base64 или имя загруженного файла")
+		return
+	}
 
 	lastMsg := req.Messages[len(req.Messages)-1].Content
-	intentType, params := intent.DetectIntent(lastMsg)
-	if intentType != intent.IntentNone {
-		resp, err := handlers.HandleIntent(intentType, params)
+
+	// Slash-команды (/model, /provider, /clear, /tools, /workspace) выполняются без вызова LLM
+	if cmd, ok := slash.Parse(lastMsg); ok {
+		resp, err := runSlashCommand(req.Agent, cmd)
 		if err != nil {
-			slog.Error("Ошибка intent handler", slog.String("ошибка", err.Error()), slog.String("request_id", cid))
+			apierror.BadRequest(w, cid, err.Error(), slash.Help())
+			return
+		}
+		slog.Info("Slash-команда выполнена", slog.String("агент", req.Agent), slog.String("команда", cmd.Name), slog.String("request_id", cid))
+		outcome = taskreport.StatusCompleted
+		writeJSON(w, ChatResponse{Response: resp, Command: cmd.Name, Status: outcome})
+		return
+	}
+
+	// Ответ «да» или «нет» на запрос подтверждения разрушительного действия —
+	// только в том же диалоге (или сессии), где он задан
+	convID := ""
+	if conv != nil {
+		convID = conv.ID
+	}
+	approvalScope := risk.Scope(req.Agent, convID, req.SessionID)
+	ctx = context.WithValue(ctx, approvalScopeKey{}, approvalScope)
+	if len(riskApprovals.Pending(approvalScope)) > 0 {
+		if yes, ok := risk.Answer(lastMsg); ok && yes {
+			n := riskApprovals.Approve(approvalScope)
+			slog.Info("Пользователь подтвердил действия", slog.String("агент", req.Agent), slog.Int("количество", n), slog.String("request_id", cid))
+		} else if ok {
+			n := riskApprovals.Reject(approvalScope)
+			slog.Info("Пользователь отменил действия", slog.String("агент", req.Agent), slog.Int("количество", n), slog.String("request_id", cid))
+		}
+	}
+
+	// Ответ на уточняющий вопрос агента (ask_user): цикл инструментов продолжится с места остановки
+	clarifyKey := clarify.Key(req.Agent, "")
+	if conv != nil {
+		clarifyKey = clarify.Key(req.Agent, conv.ID)
+	}
+	var resumed *clarify.Pending
+	if rs == nil && regenerationFrom(r.Context()) == nil {
+		var ok bool
+		resumed, ok = clarifications.Take(clarifyKey, req.ClarificationID)
+		if !ok && req.ClarificationID != "" {
+			apierror.Write(w, http.StatusConflict, apierror.Response{
+				Code:      "CLARIFICATION_EXPIRED",
+				Message:   "Уточняющий вопрос не найден или ответ на него уже получен",
+				Hint:      "Отправьте сообщение без clarification_id — агент начнёт задачу заново",
+				RequestID: cid,
+			})
+			return
+		}
+	}
+
+	intentType := intent.IntentNone
+	if detected, params, ok := intentRegistry.Detect(lastMsg, intentEnabledFor(req.Agent)); ok && resumed == nil {
+		intentType = detected.Name
+		resp, err := detected.Handle(params)
+		if err != nil {
+			slog.Error("Ошибка intent handler", slog.String("интент", detected.Name), slog.String("ошибка", err.Error()), slog.String("request_id", cid))
 			apierror.InternalError(w, cid, "Ошибка обработки намерения", "Попробуйте переформулировать запрос")
 			return
 		}
-		writeJSON(w, ChatResponse{Response: resp})
+		outcome = taskreport.StatusCompleted
+		writeJSON(w, ChatResponse{Response: resp, Status: outcome})
 		return
 	}
 
@@ -449,23 +1104,145 @@ func chatHandler(w http.ResponseWriter, r *http.Request) {
 		apierror.NotFound(w, cid, "Агент не найден")
 		return
 	}
+	// Файловые инструменты и /execute работают относительно текущего рабочего пространства
+	ws, err := currentWorkspace(req.WorkspaceID, conv, agent)
+	if err != nil {
+		apierror.NotFound(w, cid, "Рабочее пространство не найдено")
+		return
+	}
+	if ws != nil {
+		ctx = context.WithValue(ctx, workspaceKey{}, ws.Path)
+	}
+	// Переменные окружения пространства и диалога — в execute и run_code, без значений в промпте
+	env := loadEnvVars(ws, conv)
+	if env != nil {
+		ctx = context.WithValue(ctx, envVarsKey{}, env)
+	}
 
 	providerName := agent.Provider
 	if providerName == "" {
 		providerName = "ollama"
 	}
+	// Образ агента (/agents/{name}/persona): промпт, температура и политика инструментов
+	activePersona := personaStore().Active(agent)
+
+	// === Маршрутизация: простые запросы — быстрой модели (ROUTER_ENABLED) ===
+	modelName := agent.LLMModel
+	supportsTools := agent.SupportsTools
+	regen := regenerationFrom(r.Context())
+	var route *routing.Decision
+	if regen != nil && regen.Model != "" {
+		// Повторная генерация другой моделью — маршрутизатор не участвует
+		if regen.Provider != "" {
+			providerName = regen.Provider
+		}
+		modelName = regen.Model
+		supportsTools = modelSupportsTools(providerName, modelName)
+		timelineFrom(ctx).Add(timeline.Event{Type: timeline.EventProviderSwitch, Provider: providerName, Model: modelName, Detail: "повторная генерация"})
+	} else if rs != nil {
+		// Воспроизведение — записанная или заданная модель, маршрутизатор не участвует
+		providerName, modelName = rs.Provider, rs.Model
+		supportsTools = modelSupportsTools(providerName, modelName)
+		timelineFrom(ctx).Add(timeline.Event{Type: timeline.EventProviderSwitch, Provider: providerName, Model: modelName, Detail: "воспроизведение записи"})
+	} else {
+		route = routeChat(&req, lastMsg, providerName, modelName)
+	}
+	if route != nil && route.Tier == routing.TierFast {
+		providerName, modelName = route.Provider, route.Model
+		supportsTools = modelSupportsTools(providerName, modelName)
+	}
+	if route != nil {
+		timelineFrom(ctx).Add(timeline.Event{Type: timeline.EventRouting, Provider: providerName, Model: modelName, Detail: route.Tier})
+		metrics.RecordRouterDecision(req.Agent, route.Tier, route.Classifier)
+		slog.Info("Модель выбрана маршрутизатором",
+			slog.String("агент", req.Agent),
+			slog.String("уровень", route.Tier),
+			slog.String("модель", providerName+"/"+modelName),
+			slog.Float64("сложность", route.Score),
+			slog.String("request_id", cid))
+	}
+
+	// === Предохранитель: отключённый после серии ошибок провайдер заменяется резервной моделью ===
+	if cfg := config.Current(); providerBreakers.Open(providerName) && cfg.ProviderFallbackModel != "" && cfg.ProviderFallbackProvider != providerName {
+		slog.Warn("Провайдер отключён предохранителем, ответ резервной моделью",
+			slog.String("провайдер", providerName),
+			slog.String("модель", cfg.ProviderFallbackProvider+"/"+cfg.ProviderFallbackModel),
+			slog.String("request_id", cid))
+		metrics.RecordLLMCircuit(providerName, int(breaker.StateOpen), true)
+		providerName, modelName = cfg.ProviderFallbackProvider, cfg.ProviderFallbackModel
+		timelineFrom(ctx).Add(timeline.Event{Type: timeline.EventProviderSwitch, Provider: providerName, Model: modelName, Detail: "провайдер отключён предохранителем"})
+		supportsTools = modelSupportsTools(providerName, modelName)
+	}
+
+	// === Бюджеты: исчерпанный лимит агента или ключа закрывает облачные модели ===
+	keyID := requestKeyID(r)
+	ctx = budget.WithSubject(ctx, req.Agent, keyID)
+	budgetNotice := ""
+	if budget.IsCloud(providerName) && db.DB != nil {
+		exceeded, err := budget.Exceeded(db.DB, req.Agent, keyID, time.Now())
+		if err != nil {
+			slog.Warn("Не удалось проверить бюджет", slog.String("агент", req.Agent), slog.String("ошибка", err.Error()), slog.String("request_id", cid))
+		}
+		if len(exceeded) > 0 {
+			cfg := config.Current()
+			msg := budget.Message(exceeded)
+			if budget.Fallback(exceeded) && cfg.BudgetFallbackModel != "" {
+				slog.Info("Бюджет исчерпан, ответ локальной моделью",
+					slog.String("агент", req.Agent),
+					slog.String("модель", cfg.BudgetFallbackProvider+"/"+cfg.BudgetFallbackModel),
+					slog.String("причина", msg),
+					slog.String("request_id", cid))
+				for _, b := range exceeded {
+					metrics.RecordBudgetExceeded(b.Scope, "fallback")
+				}
+				providerName, modelName = cfg.BudgetFallbackProvider, cfg.BudgetFallbackModel
+				timelineFrom(ctx).Add(timeline.Event{Type: timeline.EventProviderSwitch, Provider: providerName, Model: modelName, Detail: "бюджет исчерпан: " + msg})
+				budgetNotice = msg + "; ответ подготовлен локальной моделью " + modelName
+				supportsTools = modelSupportsTools(providerName, modelName)
+			} else {
+				slog.Warn("Бюджет исчерпан, запрос отклонён", slog.String("агент", req.Agent), slog.String("причина", msg), slog.String("request_id", cid))
+				for _, b := range exceeded {
+					metrics.RecordBudgetExceeded(b.Scope, "refused")
+				}
+				apierror.Write(w, http.StatusTooManyRequests, apierror.Response{
+					Code:      "BUDGET_EXCEEDED",
+					Message:   "Бюджет на облачные модели исчерпан: " + msg,
+					Hint:      "Переключите агента на локальную модель, увеличьте лимит в /usage/budgets или задайте BUDGET_FALLBACK_MODEL",
+					RequestID: cid,
+				})
+				return
+			}
+		}
+	}
 
 	provider, err := llm.GlobalRegistry.Get(providerName)
 	if err != nil {
 		slog.Error("Провайдер не найден", slog.String("провайдер", providerName), slog.String("ошибка", err.Error()), slog.String("request_id", cid))
 		WriteSystemLog("error", "agent-service", fmt.Sprintf("Провайдер %s не найден", providerName), err.Error())
 		apierror.InternalError(w, cid, "Провайдер не настроен", "Проверьте конфигурацию провайдера")
-		metrics.RecordChatError(req.Agent, providerName, agent.LLMModel, "provider_not_found")
+		metrics.RecordChatError(req.Agent, providerName, modelName, "provider_not_found")
+		return
+	}
+
+	// Лимит одновременных запросов к провайдеру (PROVIDER_MAX_CONCURRENT): место
+	// держится до конца цикла инструментов, чтобы параллельные чаты не перегружали Ollama
+	releaseProvider, err := providerLimiters.Acquire(r.Context(), providerName)
+	if err != nil {
+		if errors.Is(err, limiter.ErrSaturated) {
+			slog.Warn("Лимит одновременных запросов к провайдеру исчерпан", slog.String("провайдер", providerName), slog.String("request_id", cid))
+			metrics.RecordConcurrencyRejected(providerName)
+			middleware.WriteSaturated(w, cid)
+		}
 		return
 	}
+	defer releaseProvider()
 
 	// Записываем метрику чат-запроса
-	metrics.RecordChatRequest(req.Agent, providerName, agent.LLMModel)
+	metrics.RecordChatRequest(req.Agent, providerName, modelName)
+
+	// Внешний текст (RAG, результаты инструментов) проверяется на prompt-injection, ответ — на секреты
+	chatGuard := currentGuard()
+	var guardFindings []guard.Finding
 
 	// === RAG: поиск релевантных документов ===
 	// Выполняем семантический поиск по базе знаний перед запросом к LLM
@@ -485,7 +1262,9 @@ func chatHandler(w http.ResponseWriter, r *http.Request) {
 			metrics.RecordRAGSearch("success", len(results), ragDuration)
 			ragContext = "\n\n=== База знаний (RAG) ===\n"
 			for i, r := range results {
-				ragContext += fmt.Sprintf("[%d] %s: %s\n", i+1, r.Doc.Title, truncate(r.Doc.Content, 150))
+				verdict := chatGuard.CheckInput(guard.SourceRAG, r.Doc.Title, r.Doc.Content)
+				guardFindings = append(guardFindings, verdict.Findings...)
+				ragContext += fmt.Sprintf("[%d] %s: %s\n", i+1, r.Doc.Title, verdict.Apply(truncate(r.Doc.Content, 150)))
 				ragSources = append(ragSources, Source{
 					Title:   r.Doc.Title,
 					Content: truncate(r.Doc.Content, 100),
@@ -503,18 +1282,30 @@ func chatHandler(w http.ResponseWriter, r *http.Request) {
 	// Перед каждым запросом к LLM ищем в базе знаний модели
 	// релевантные факты и добавляем их в системный промпт.
 	systemPrompt := agent.Prompt
-
-	// Learnings ВКЛЮЧЕНЫ - получаем накопленные знания модели из memory-service
-	learnings := fetchModelLearnings(agent.LLMModel, lastMsg)
-	if len(learnings) > 0 {
-		learningContext := "\n\n=== Накопленные знания модели ===\n"
-		for i, l := range learnings {
-			learningContext += "- " + l + "\n"
-			_ = i
+	if activePersona != nil && activePersona.Prompt != "" {
+		systemPrompt = activePersona.Prompt
+	}
+
+	// Знания подставляются, только если включены глобально (LEARNINGS_ENABLED) и для агента;
+	// отбор по порогу, категориям и бюджету токенов не даёт им раздувать контекст
+	learningsOn := learningsEnabledFor(agent)
+	if learningsOn {
+		modelLearnings := fetchModelLearnings(agent.LLMModel, lastMsg)
+		if len(modelLearnings) > 0 {
+			learningContext := "\n\n=== Накопленные знания модели ===\n"
+			for _, l := range modelLearnings {
+				learningContext += "- " + l + "\n"
+			}
+			learningContext += "=== Используй эти знания для более точных ответов ===\n"
+			systemPrompt += learningContext
+			slog.Info("Знания добавлены в контекст", slog.Int("количество", len(modelLearnings)), slog.String("модель", agent.LLMModel))
 		}
-		learningContext += "=== Используй эти знания для более точных ответов ===\n"
-		systemPrompt += learningContext
-		slog.Info("Знания добавлены в контекст", slog.Int("количество", len(learnings)), slog.String("модель", agent.LLMModel))
+	}
+
+	// === Эпизодическая память: похожие задачи из прошлых разговоров ===
+	episodicOn := config.Current().EpisodicEnabled
+	if episodicOn {
+		systemPrompt += episodic.FormatRecall(fetchEpisodes(req.Agent, lastMsg), time.Now())
 	}
 
 	// === Skill Engine: получение релевантных навыков (Eternal RAG: раздел 5.3) ===
@@ -526,6 +1317,25 @@ func chatHandler(w http.ResponseWriter, r *http.Request) {
 		systemPrompt += skillContext
 	}
 
+	// === Карта репозитория: агент, привязанный к рабочему пространству, видит структуру проекта ===
+	if ws != nil {
+		systemPrompt += fmt.Sprintf("\n\nТекущее рабочее пространство: %s (%s). Относительные пути в файловых инструментах и команды execute — от этого каталога; выходить за его пределы нельзя.\n", ws.Name, ws.Path)
+	}
+	if env != nil {
+		systemPrompt += fmt.Sprintf("\nПеременные окружения для execute и run_code: %s. Обращайся к ним по имени ($NAME, os.environ); значения секретов не выводи и не запрашивай.\n", env.Describe())
+	}
+	systemPrompt += workspaceRepoMap(agent)
+	systemPrompt += agentTaskPrompt(agent.Name)
+
+	// === Прикреплённые документы: целиком (небольшие) или фрагменты из индекса сессии ===
+	attachContext, attachSources, sessionID, err := buildAttachmentContext(&req, lastMsg)
+	if err != nil {
+		apierror.BadRequest(w, cid, "Не удалось прочитать вложение: "+err.Error(), "Поддерживаются текстовые файлы, PDF и DOCX")
+		return
+	}
+	systemPrompt += attachContext
+	ragSources = append(ragSources, attachSources...)
+
 	// Добавляем RAG контекст к системному промпту
 	if ragContext != "" {
 		systemPrompt += ragContext
@@ -534,190 +1344,363 @@ func chatHandler(w http.ResponseWriter, r *http.Request) {
 	messages := make([]llm.Message, 0, len(req.Messages)+1)
 	messages = append(messages, llm.Message{Role: "system", Content: systemPrompt})
 	messages = append(messages, req.Messages...)
+	if resumed != nil {
+		// Продолжение после уточняющего вопроса: контекст на момент вопроса и ответ пользователя
+		messages = resumed.Resume(systemPrompt, lastMsg)
+		slog.Info("Получен ответ на уточняющий вопрос", slog.String("агент", req.Agent), slog.String("вопрос", resumed.Question.ID), slog.String("request_id", cid))
+	}
+
+	// Текстовая модель не видит изображений — заменяем их распознанным текстом и описанием
+	if llm.HasImages(messages) && !llm.SupportsVision(providerName, modelName) {
+		slog.Info("Модель не поддерживает изображения, используется описание", slog.String("модель", modelName), slog.String("request_id", cid))
+		messages = vision.ReplaceImages(messages, imageDescriber().Text)
+	}
 
+	// Модель Ollama без tool calling получает инструменты через ограниченную
+	// генерацию: ответ по JSON Schema вызова вместо разбора текста
+	constrainTools := !supportsTools && providerName == "ollama" && config.Current().OllamaConstrainedTools
+	if constrainTools {
+		supportsTools = true
+	}
 	// LM Studio имеет маленький контекст (4096 токенов) - отключаем инструменты
-	supportsTools := agent.SupportsTools && providerName != "lmstudio"
+	supportsTools = supportsTools && agent.ToolsEnabled && providerName != "lmstudio"
+	supportsTools = supportsTools && (activePersona == nil || activePersona.ToolPolicy != persona.ToolsNone)
 
-	// Стриминг отключаем когда есть инструменты — Ollama не поддерживает tool calling в режиме stream
-	useStream := providerName == "ollama" && !supportsTools
+	// Стриминг работает и с инструментами: провайдер собирает вызовы из
+	// фрагментов стрима (ограниченная генерация Ollama стрим отключает сама)
+	useStream := llm.SupportsStreaming(providerName)
 	chatReq := &llm.ChatRequest{
-		Model:    agent.LLMModel,
-		Messages: messages,
-		Stream:   useStream,
+		Model:          modelName,
+		Messages:       messages,
+		Stream:         useStream,
+		ConstrainTools: constrainTools && supportsTools,
+	}
+	if activePersona != nil {
+		chatReq.Temperature = activePersona.Temperature
+	}
+	if regen != nil && regen.Temperature != nil {
+		chatReq.Temperature = regen.Temperature
+	}
+	if rs != nil && !rs.Player.MockLLM {
+		// Воспроизведение с живой моделью — без случайности, насколько позволяет провайдер
+		zero := 0.0
+		chatReq.Temperature = &zero
 	}
 
 	if supportsTools {
-		chatReq.Tools = tools.GetToolsForAgent(req.Agent, agent.LLMModel)
+		chatReq.Tools = tools.GetToolsForAgent(req.Agent, modelName)
+		if smartHomeHub().Configured() {
+			chatReq.Tools = append(chatReq.Tools, tools.GetSmartHomeTools()...)
+		}
+		if kubeClient != nil {
+			chatReq.Tools = append(chatReq.Tools, tools.GetKubeTools()...)
+		}
+		if config.Current().ToolResultMaxChars > 0 {
+			chatReq.Tools = append(chatReq.Tools, tools.GetArtifactTools()...)
+		}
+		if config.Current().AskUserEnabled {
+			chatReq.Tools = append(chatReq.Tools, tools.GetClarifyTools()...)
+		}
+		chatReq.Tools = append(chatReq.Tools, recipeTools(req.Agent)...)
+		if activePersona != nil {
+			allowed := chatReq.Tools[:0]
+			for _, t := range chatReq.Tools {
+				if persona.AllowsTool(activePersona, t.Function.Name) {
+					allowed = append(allowed, t)
+				}
+			}
+			chatReq.Tools = allowed
+			chatReq.ConstrainTools = chatReq.ConstrainTools && len(allowed) > 0
+		}
 		toolNames := make([]string, len(chatReq.Tools))
 		for i, t := range chatReq.Tools {
 			toolNames[i] = t.Function.Name
 		}
-		slog.Info("Инструменты назначены агенту", slog.String("агент", req.Agent), slog.String("модель", agent.LLMModel), slog.Int("количество", len(chatReq.Tools)))
+		slog.Info("Инструменты назначены агенту", slog.String("агент", req.Agent), slog.String("модель", modelName), slog.Int("количество", len(chatReq.Tools)))
+	}
+
+	// Аргументы вызовов проверяются по схемам выданных модели инструментов
+	if len(chatReq.Tools) > 0 {
+		schemas := make(map[string]*toolschema.Schema, len(chatReq.Tools))
+		for _, t := range chatReq.Tools {
+			schemas[t.Function.Name] = toolschema.Parse(t.Function.Parameters)
+		}
+		ctx = context.WithValue(ctx, toolSchemasKey{}, schemas)
 	}
 
-	chatResp, err := chatWithRetry(provider, chatReq)
+	// Изменения файлов инструментами (diff) собираются за весь цикл и сохраняются с ответом
+	changes := &fileChanges{}
+	ctx = context.WithValue(ctx, fileChangesKey{}, changes)
+	report := &taskreport.Report{}
+	ctx = context.WithValue(ctx, taskReportKey{}, report)
+
+	chatResp, err := chatWithRetry(ctx, provider, chatReq)
 	if err != nil {
 		slog.Error("[LLM-ERROR] ошибка провайдера",
 			slog.String("тип", "llm"),
 			slog.String("провайдер", providerName),
-			slog.String("модель", agent.LLMModel),
+			slog.String("модель", modelName),
 			slog.String("ошибка", err.Error()),
 			slog.String("request_id", cid),
 		)
-		WriteSystemLog("error", "agent-service", fmt.Sprintf("[LLM] Ошибка (%s/%s): %s", providerName, agent.LLMModel, llm.TranslateLLMError(err.Error())), err.Error())
-		writeJSON(w, ChatResponse{Error: llm.TranslateLLMError(err.Error())})
+		WriteSystemLog("error", "agent-service", fmt.Sprintf("[LLM] Ошибка (%s/%s): %s", providerName, modelName, llm.TranslateLLMError(err.Error())), err.Error())
+		writeJSON(w, ChatResponse{Error: i18n.Translate(i18n.FromContext(r.Context()), llm.TranslateLLMError(err.Error())), Status: taskreport.StatusFailed})
 		return
 	}
 
 	// === Цикл выполнения инструментов (tool call loop) ===
 	// Модели могут вызывать инструменты последовательно: например write→read, или sysinfo→execute.
-	// Цикл обрабатывает до 5 раундов tool calls (structured JSON, inline JSON, XML-формат).
+	// Цикл обрабатывает до 5 раундов tool calls (structured, а также JSON, XML и inline в тексте ответа).
 	// После каждого вызова результат добавляется в контекст и отправляется повторный запрос к LLM.
 	// Цикл завершается когда LLM возвращает обычный текст без tool calls.
-	var toolCallCount int
+	var toolCallCount, toolErrors int
 	var usedTools []string
+	var riskPending []risk.Assessment
 	const maxToolRounds = 5
-	for round := 0; round < maxToolRounds; round++ {
+	// Вызовы посреди текста принимаются только для инструментов, выданных модели
+	var textToolCalls toolcall.Parser
+	if len(chatReq.Tools) > 0 {
+		offered := make(map[string]bool, len(chatReq.Tools))
+		for _, t := range chatReq.Tools {
+			offered[t.Function.Name] = true
+		}
+		textToolCalls.Known = func(name string) bool { return offered[name] }
+	}
+	// Ошибка инструмента — системная заметка со схемой и просьбой исправить
+	// аргументы; каждая заметка добавляет раунд сверх maxToolRounds
+	corrections := correction.NewTracker(config.Current().ToolRetryMax)
+	var correctionNotes []llm.Message
+	observeTool := func(toolName string, result map[string]interface{}) {
+		status, errText := taskreport.ActionStatus(result)
+		switch status {
+		case taskreport.ActionOK:
+			if corrections.Succeeded(toolName) {
+				metrics.RecordToolCorrection(modelName, correction.OutcomeCorrected)
+			}
+		case taskreport.ActionError:
+			toolErrors++
+			attempt, ok := corrections.Failed(toolName)
+			if !ok {
+				return
+			}
+			var params interface{}
+			for _, t := range chatReq.Tools {
+				if t.Function.Name == toolName {
+					params = t.Function.Parameters
+				}
+			}
+			correctionNotes = append(correctionNotes, llm.Message{Role: "system", Content: correction.Note(toolName, errText, params, attempt, corrections.Max())})
+			timelineFrom(ctx).Add(timeline.Event{Type: timeline.EventLLMRetry, Provider: providerName, Model: modelName, Tool: toolName, Attempt: attempt, Detail: "исправление вызова после ошибки"})
+		}
+	}
+	defer func() {
+		for range corrections.Unresolved() {
+			metrics.RecordToolCorrection(modelName, correction.OutcomeFailed)
+		}
+	}()
+	// Уточняющий вопрос (ask_user) не уходит в tools-service: после раунда цикл
+	// останавливается, контекст сохраняется до ответа пользователя. Вызовы после
+	// вопроса в том же раунде пропускаются — они могут зависеть от ответа.
+	var asked *clarify.Question
+	var askedCallID string
+	askUser := func(name, callID string, args map[string]interface{}) (content string, handled bool) {
+		if asked != nil {
+			b, _ := json.Marshal(map[string]string{"skipped": "не выполнено: ожидается ответ пользователя на уточняющий вопрос"})
+			return string(b), true
+		}
+		if name != clarify.ToolName {
+			return "", false
+		}
+		q, err := clarify.Parse(args)
+		if err != nil {
+			b, _ := json.Marshal(map[string]string{"error": err.Error()})
+			return string(b), true
+		}
+		asked, askedCallID = &q, callID
+		return "", true
+	}
+	pauseForAnswer := func() *llm.ChatResponse {
+		clarifications.Ask(clarifyKey, clarify.Pending{Question: *asked, CallID: askedCallID, Messages: append([]llm.Message(nil), messages...)}, config.Current().AskUserTTL)
+		correctionNotes = nil
+		timelineFrom(ctx).Add(timeline.Event{Type: timeline.EventToolCall, Tool: clarify.ToolName, Status: taskreport.StatusNeedsInput, Detail: truncate(asked.Question, 200)})
+		slog.Info("Агент задал уточняющий вопрос", slog.String("агент", req.Agent), slog.String("вопрос", asked.ID), slog.String("request_id", cid))
+		return &llm.ChatResponse{Content: asked.Text()}
+	}
+	// Каждый раунд — спан tool.round; в нём вызовы инструментов и повторный запрос к LLM
+	var roundSpan trace.Span
+	defer func() {
+		if roundSpan != nil {
+			roundSpan.End()
+		}
+	}()
+	for round := 0; round < maxToolRounds+corrections.Used(); round++ {
+		if roundSpan != nil {
+			roundSpan.End()
+		}
+		var roundCtx context.Context
+		roundCtx, roundSpan = tracing.Start(ctx, "tool.round", attribute.Int("tool.round", round))
 		slog.Info("Ответ провайдера", slog.String("провайдер", providerName), slog.Int("раунд", round), slog.Int("символов", len(chatResp.Content)), slog.Int("инструментов", len(chatResp.ToolCalls)))
 
 		// --- Вариант 1: Структурированные tool calls (стандартный OpenAI/OpenRouter формат) ---
 		if len(chatResp.ToolCalls) > 0 {
+			progress.Round(roundCtx, round, fmt.Sprintf("вызовов инструментов: %d", len(chatResp.ToolCalls)))
 			messages = append(messages, llm.Message{Role: "assistant", Content: chatResp.Content, ToolCalls: chatResp.ToolCalls})
 			for _, tc := range chatResp.ToolCalls {
 				slog.Info("Tool call", slog.String("имя", tc.Function.Name))
-				args := parseToolArguments(tc.Function.Arguments)
-				result := dispatchTool(req.Agent, tc.Function.Name, args, req.Messages)
+				args := toolcall.Arguments(tc.Function.Arguments)
+				if content, handled := askUser(tc.Function.Name, tc.ID, args); handled {
+					if content != "" {
+						messages = append(messages, llm.Message{Role: "tool", Content: content, ToolCallID: tc.ID})
+					}
+					continue
+				}
+				progress.Tool(roundCtx, tc.Function.Name)
+				result := dispatchChecked(roundCtx, req.Agent, tc.Function.Name, args, req.Messages, &riskPending)
 				slog.Info("Инструмент выполнен", slog.String("имя", tc.Function.Name))
 				resultBytes, _ := json.Marshal(result)
-				messages = append(messages, llm.Message{Role: "tool", Content: string(resultBytes), ToolCallID: tc.ID})
+				messages = append(messages, llm.Message{Role: "tool", Content: guardToolResult(chatGuard, tc.Function.Name, resultBytes, &guardFindings), ToolCallID: tc.ID})
 				toolCallCount++
 				usedTools = append(usedTools, tc.Function.Name)
+				observeTool(tc.Function.Name, result)
+			}
+			if asked != nil {
+				chatResp = pauseForAnswer()
+				break
 			}
+			messages, correctionNotes = append(messages, correctionNotes...), nil
 			chatReq.Messages = messages
-			chatResp, err = chatWithRetry(provider, chatReq)
+			progress.Note(roundCtx, "модель обрабатывает результаты инструментов")
+			chatResp, err = chatWithRetry(roundCtx, provider, chatReq)
 			if err != nil {
 				slog.Error("[LLM-ERROR] ошибка после tool-call", slog.String("тип", "llm"), slog.Int("раунд", round), slog.String("ошибка", err.Error()), slog.String("request_id", cid))
-				writeJSON(w, ChatResponse{Error: llm.TranslateLLMError(err.Error())})
+				writeJSON(w, ChatResponse{Error: i18n.Translate(i18n.FromContext(r.Context()), llm.TranslateLLMError(err.Error())), Status: taskreport.StatusFailed})
 				return
 			}
 			continue
 		}
 
-		// --- Очистка thinking-теговreasoning-моделей перед парсингом tool calls ---
-		// Модели типа ministral-3-14b-reasoning оборачивают размышления в [THINK]...[/THINK],
-		// что может помешать распознаванию JSON/XML/inline tool calls в тексте ответа.
-		contentForParsing := stripThinkingTags(chatResp.Content)
-
-		// --- Вариант 2: JSON tool call в тексте ответа (некоторые модели возвращают JSON вместо structured) ---
-		// Поддерживаем оба формата: {"name":"x","arguments":{...}} и {"name":"x","parameters":{...}}
-		var jsonToolCall struct {
-			Name       string                 `json:"name"`
-			Arguments  map[string]interface{} `json:"arguments"`
-			Parameters map[string]interface{} `json:"parameters"`
-		}
-		if err := json.Unmarshal([]byte(strings.TrimSpace(contentForParsing)), &jsonToolCall); err == nil && jsonToolCall.Name != "" {
-			toolArgs := jsonToolCall.Arguments
-			if len(toolArgs) == 0 {
-				toolArgs = jsonToolCall.Parameters
-			}
-			slog.Info("JSON tool call", slog.Int("раунд", round), slog.String("имя", jsonToolCall.Name))
+		// --- Вариант 2: tool calls в тексте ответа (JSON, XML, inline) ---
+		// Модели без структурированных tool_calls пишут вызов текстом; toolcall
+		// разбирает все форматы, в том числе несколько вызовов и вызовы посреди текста.
+		if calls := textToolCalls.Parse(chatResp.Content); len(calls) > 0 {
+			progress.Round(roundCtx, round, fmt.Sprintf("вызовов инструментов: %d", len(calls)))
 			messages = append(messages, llm.Message{Role: "assistant", Content: chatResp.Content})
-			result := dispatchTool(req.Agent, jsonToolCall.Name, toolArgs, req.Messages)
-			slog.Info("JSON инструмент выполнен", slog.String("имя", jsonToolCall.Name))
-			resultBytes, _ := json.Marshal(result)
-			messages = append(messages, llm.Message{Role: "tool", Content: string(resultBytes), ToolCallID: "json-0"})
-			toolCallCount++
-			usedTools = append(usedTools, jsonToolCall.Name)
-			chatReq.Messages = messages
-			chatResp, err = chatWithRetry(provider, chatReq)
-			if err != nil {
-				slog.Error("Ошибка LLM", slog.Int("раунд", round), slog.String("ошибка", err.Error()))
-				writeJSON(w, ChatResponse{Error: llm.TranslateLLMError(err.Error())})
-				return
+			for i, call := range calls {
+				slog.Info("Tool call в тексте", slog.Int("раунд", round), slog.String("формат", call.Format), slog.String("имя", call.Name))
+				callID := fmt.Sprintf("%s-%d", call.Format, i)
+				if content, handled := askUser(call.Name, callID, call.Args); handled {
+					if content != "" {
+						messages = append(messages, llm.Message{Role: "tool", Content: content, ToolCallID: callID})
+					}
+					continue
+				}
+				progress.Tool(roundCtx, call.Name)
+				result := dispatchChecked(roundCtx, req.Agent, call.Name, call.Args, req.Messages, &riskPending)
+				slog.Info("Инструмент выполнен", slog.String("имя", call.Name))
+				resultBytes, _ := json.Marshal(result)
+				messages = append(messages, llm.Message{Role: "tool", Content: guardToolResult(chatGuard, call.Name, resultBytes, &guardFindings), ToolCallID: callID})
+				toolCallCount++
+				usedTools = append(usedTools, call.Name)
+				observeTool(call.Name, result)
 			}
-			continue
-		}
-
-		// --- Вариант 3: XML tool call(nemotron и подобные модели) ---
-		// Формат: <tool_call><function=имя><parameter=ключ>значение</parameter></function></tool_call>
-		if xmlName, xmlArgs, ok := parseXMLToolCall(contentForParsing); ok {
-			slog.Info("XML tool call", slog.Int("раунд", round), slog.String("имя", xmlName))
-			messages = append(messages, llm.Message{Role: "assistant", Content: chatResp.Content})
-			result := dispatchTool(req.Agent, xmlName, xmlArgs, req.Messages)
-			slog.Info("XML инструмент выполнен", slog.String("имя", xmlName))
-			resultBytes, _ := json.Marshal(result)
-			messages = append(messages, llm.Message{Role: "tool", Content: string(resultBytes), ToolCallID: "xml-0"})
-			toolCallCount++
-			usedTools = append(usedTools, xmlName)
+			if asked != nil {
+				chatResp = pauseForAnswer()
+				break
+			}
+			messages, correctionNotes = append(messages, correctionNotes...), nil
 			chatReq.Messages = messages
-			chatResp, err = chatWithRetry(provider, chatReq)
+			progress.Note(roundCtx, "модель обрабатывает результаты инструментов")
+			chatResp, err = chatWithRetry(roundCtx, provider, chatReq)
 			if err != nil {
 				slog.Error("Ошибка LLM", slog.Int("раунд", round), slog.String("ошибка", err.Error()))
-				writeJSON(w, ChatResponse{Error: llm.TranslateLLMError(err.Error())})
+				writeJSON(w, ChatResponse{Error: i18n.Translate(i18n.FromContext(r.Context()), llm.TranslateLLMError(err.Error())), Status: taskreport.StatusFailed})
 				return
 			}
 			continue
 		}
 
-		// --- Вариант 4: Inline tool call формат "toolname{json}" (devstral и подобные модели) ---
-		// Некоторые модели возвращают tool call как текст: execute{"command":"ls"} вместо structured формата.
-		// Парсим имя функции и JSON-аргументы из текста ответа.
-		inlineRe := regexp.MustCompile(`^(\w+)(\{.+\})$`)
-		trimmedContent := strings.TrimSpace(contentForParsing)
-		if matches := inlineRe.FindStringSubmatch(trimmedContent); len(matches) == 3 {
-			inlineName := matches[1]
-			var inlineArgs map[string]interface{}
-			if json.Unmarshal([]byte(matches[2]), &inlineArgs) == nil {
-				slog.Info("Inline tool call", slog.Int("раунд", round), slog.String("имя", inlineName))
-				messages = append(messages, llm.Message{Role: "assistant", Content: chatResp.Content})
-				result := dispatchTool(req.Agent, inlineName, inlineArgs, req.Messages)
-				slog.Info("Inline инструмент выполнен", slog.String("имя", inlineName))
-				resultBytes, _ := json.Marshal(result)
-				messages = append(messages, llm.Message{Role: "tool", Content: string(resultBytes), ToolCallID: "inline-0"})
-				toolCallCount++
-				usedTools = append(usedTools, inlineName)
-				chatReq.Messages = messages
-				chatResp, err = chatWithRetry(provider, chatReq)
-				if err != nil {
-					slog.Error("Ошибка LLM", slog.Int("раунд", round), slog.String("ошибка", err.Error()))
-					writeJSON(w, ChatResponse{Error: llm.TranslateLLMError(err.Error())})
-					return
-				}
-				continue
-			}
-		}
-
 		// --- Нет tool calls— это финальный текстовый ответ ---
 		break
 	}
 
-	// Очищаем финальный ответ от thinking-тегов reasoning-моделей перед отправкой пользователю
-	finalContent := stripThinkingTags(chatResp.Content)
+	// Размышления reasoning-моделей отделяются от ответа; пользователю — только
+	// по запросу (include_reasoning), для сворачиваемого блока в интерфейсе
+	finalContent, thoughts := splitReasoning(chatResp)
 	if strings.TrimSpace(finalContent) == "" && supportsTools {
-		slog.Warn("LLM вернул пустой ответ с tools — повтор без tools", slog.String("агент", req.Agent), slog.String("модель", agent.LLMModel))
+		slog.Warn("LLM вернул пустой ответ с tools — повтор без tools", slog.String("агент", req.Agent), slog.String("модель", modelName))
+		timelineFrom(ctx).Add(timeline.Event{Type: timeline.EventLLMRetry, Provider: providerName, Model: modelName, Detail: "пустой ответ — повтор без инструментов"})
 		chatReq.Tools = nil
 		chatReq.Messages = messages
-		chatReq.Stream = providerName == "ollama"
-		chatResp, err = chatWithRetry(provider, chatReq)
+		chatResp, err = chatWithRetry(ctx, provider, chatReq)
 		if err == nil {
-			finalContent = stripThinkingTags(chatResp.Content)
+			finalContent, thoughts = splitReasoning(chatResp)
+		}
+	}
+	if len(riskPending) > 0 {
+		riskApprovals.Request(approvalScope, riskPending)
+		finalContent = strings.TrimSpace(finalContent + risk.Prompt(riskPending))
+		if approvalScope == "" {
+			finalContent += "\n\nПодтвердить действия можно только в диалоге: передайте chat_id или session_id в следующем запросе и повторите задачу."
 		}
 	}
 	if strings.TrimSpace(finalContent) == "" {
-		slog.Warn("LLM вернул пустой ответ", slog.String("агент", req.Agent), slog.String("модель", agent.LLMModel))
-		writeJSON(w, ChatResponse{Error: "Модель вернула пустой ответ. Возможно, исчерпан лимит запросов или модель недоступна. Попробуйте другую модель."})
+		slog.Warn("LLM вернул пустой ответ", slog.String("агент", req.Agent), slog.String("модель", modelName))
+		writeJSON(w, ChatResponse{Error: i18n.Translate(i18n.FromContext(r.Context()), "Модель вернула пустой ответ. Возможно, исчерпан лимит запросов или модель недоступна. Попробуйте другую модель."), Status: taskreport.StatusFailed})
 		return
 	}
+	finalContent, outputFindings := chatGuard.CheckOutput(finalContent)
+	guardFindings = append(guardFindings, outputFindings...)
+	if req.IncludeReasoning && thoughts != "" {
+		thoughts, _ = chatGuard.CheckOutput(thoughts)
+	} else {
+		thoughts = ""
+	}
+	recordGuardFindings(guardFindings, req.Agent, cid)
 	lastUserMsg := req.Messages[len(req.Messages)-1]
-	saveChatMessages(req.Agent, lastUserMsg, finalContent)
-	go extractAndStoreLearnings(agent.LLMModel, req.Agent, lastUserMsg.Content, finalContent)
-	WriteSystemLog("info", "agent-service", fmt.Sprintf("Чат: агент=%s, модель=%s/%s", req.Agent, providerName, agent.LLMModel), fmt.Sprintf("Вопрос: %s", truncate(lastUserMsg.Content, 200)))
+	var chatID *string
+	if conv != nil {
+		chatID = &conv.ID
+	}
+	var messageID uint
+	switch {
+	case rs != nil:
+		// Воспроизведение не сохраняет реплики
+	case regen != nil:
+		messageID = saveAlternative(regen.Of, finalContent, modelName, providerName, personaName(activePersona), route)
+	default:
+		messageID = saveChatMessages(req.Agent, chatID, lastUserMsg, finalContent, modelName, providerName, personaName(activePersona), route)
+	}
+	if messageID != 0 {
+		// Фактические задержка и успешность инструментов — для ранжирования моделей роли (modelrank)
+		db.DB.Model(&models.Message{}).Where("id = ?", messageID).Updates(map[string]interface{}{
+			"latency_ms": max(time.Since(startTime).Milliseconds(), 1), "tool_calls": toolCallCount, "tool_errors": toolErrors,
+		})
+	}
+	fileChangeList := changes.save(messageID)
+	if cid != "" && messageID != 0 && db.DB != nil {
+		db.DB.Model(&models.FileBackup{}).Where("request_id = ?", cid).Update("message_id", messageID)
+	}
+	if conv != nil && regen == nil {
+		db.DB.Model(conv).Update("agent_name", req.Agent)
+		if conv.Title == "" && conv.Name == "" && config.Current().TitleEnabled {
+			go titleConversation(budget.WithSubject(context.Background(), req.Agent, keyID), conv.ID, agent, lastUserMsg.Content, finalContent)
+		}
+	}
+	if learningsOn && regen == nil && rs == nil && asked == nil {
+		runInBackground(jobLearningExtract, learningJob{Model: agent.LLMModel, Agent: req.Agent, User: lastUserMsg.Content, Assistant: finalContent}, func() {
+			extractAndStoreLearnings(agent.LLMModel, req.Agent, lastUserMsg.Content, finalContent)
+		})
+	}
+	if episodicOn && regen == nil && rs == nil && asked == nil {
+		go storeEpisode(req.Agent, agent.LLMModel, episodic.Summarize(lastUserMsg.Content, finalContent, usedTools), messageID)
+	}
+	WriteSystemLog("info", "agent-service", fmt.Sprintf("Чат: агент=%s, модель=%s/%s", req.Agent, providerName, modelName), fmt.Sprintf("Вопрос: %s", truncate(lastUserMsg.Content, 200)))
 
 	durationMs := float64(time.Since(startTime).Milliseconds())
 	scenarioName := "chat/" + req.Agent
 	metrics.GetScenarioCollector().Record(scenarioName, durationMs, toolCallCount, true, "")
 
-	if autoSkillPipeline != nil && len(usedTools) > 0 {
+	if autoSkillPipeline != nil && len(usedTools) > 0 && rs == nil {
 		detectedIntent := lastMsg
 		if intentType != intent.IntentNone {
 			detectedIntent = string(intentType)
@@ -729,3455 +1712,10669 @@ func chatHandler(w http.ResponseWriter, r *http.Request) {
 	defer func() {
 		metrics.RecordHTTPRequest(r.Method, "/chat", statusCode, time.Since(startTime))
 	}()
-	writeJSON(w, ChatResponse{Response: finalContent, Sources: ragSources})
+	for _, c := range fileChangeList {
+		report.AddArtifacts(taskreport.Artifact{Name: filepath.Base(c.Path), Path: c.Path})
+	}
+	outcome = report.Status()
+	if asked != nil {
+		outcome = taskreport.StatusNeedsInput
+	}
+	var recordingID uint
+	if recorder != nil {
+		recordingID = saveRecording(cid, req.Agent, providerName, modelName, rawRequest, recorder.Tape(), finalContent)
+	}
+	var quotaNotice string
+	if p, err := llm.GlobalRegistry.Get(providerName); err == nil {
+		qcfg := config.Current()
+		quotaNotice = i18n.Translate(i18n.FromContext(r.Context()), quotaWarning(providerQuotas.cached(p, qcfg.ProviderQuotaTTL), qcfg.ProviderQuotaWarn))
+	}
+	writeJSON(w, ChatResponse{
+		Response: finalContent, Sources: ragSources, MessageID: messageID, SessionID: sessionID,
+		Routing: route, Guard: guardFindings, Confirmation: riskPending, Clarification: asked, Budget: budgetNotice, Changes: fileChangeList,
+		Status: outcome, ActionsTaken: report.Actions(), Artifacts: report.Artifacts(), RecordingID: recordingID,
+		Reasoning: thoughts, QuotaWarning: quotaNotice, Persona: personaName(activePersona),
+	})
 }
 
-// dispatchTool — единый диспетчер выполнения инструментов.
-// Централизует логику маршрутизации tool calls для всех форматов (structured, JSON, XML).
-// Обрабатывает специальные инструменты (configure_agent, get_agent_info и др.)
-// и делегирует остальные в tools-service через callTool().
-//
-// Параметры:
-//   - agentName: имя текущего агента (для проверки прав доступа)
-//   - toolName: имя вызываемого инструмента
-//   - args: аргументы инструмента
-//   - history: история сообщений (для делегирования задач другим агентам)
-func dispatchTool(agentName, toolName string, args map[string]interface{}, history []llm.Message) map[string]interface{} {
-	dispatchStart := time.Now()
-	slog.Info("[DISPATCH] начало",
-		slog.String("агент", agentName),
-		slog.String("инструмент", toolName),
-		slog.Any("параметры", sanitizeArgs(args)),
-	)
-	var result map[string]interface{}
-	defer func() {
-		outcome := "success"
-		if _, hasErr := result["error"]; hasErr {
-			outcome = "error"
+// chatStreamPing — период комментария-пинга в потоковом /chat.
+const chatStreamPing = 15 * time.Second
+
+// chatStreamHandler — POST /chat с Accept: text/event-stream: тот же конвейер,
+// но ответ потоком SSE. Пока идёт цикл инструментов, приходят события
+// progress (раунд, инструмент, шаг и процент плана составного скила, см.
+// пакет progress); в конце — result (ChatResponse) или error (тело ошибки API).
+// Между событиями — комментарий-пинг, чтобы прокси не закрыл соединение.
+func chatStreamHandler(w http.ResponseWriter, r *http.Request) {
+	rc := http.NewResponseController(w)
+	rc.SetWriteDeadline(time.Time{})
+
+	var mu sync.Mutex
+	send := func(event string, data []byte) {
+		mu.Lock()
+		defer mu.Unlock()
+		if event == "" {
+			fmt.Fprint(w, ": ping\n\n")
+		} else {
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, data)
 		}
-		slog.Info("[DISPATCH] завершён",
-			slog.String("агент", agentName),
-			slog.String("инструмент", toolName),
-			slog.Duration("длительность", time.Since(dispatchStart)),
-			slog.String("outcome", outcome),
-		)
+		rc.Flush()
+	}
+	sub := r.Clone(progress.With(r.Context(), func(e progress.Event) {
+		data, _ := json.Marshal(e)
+		send("progress", data)
+	}))
+	rec := httptest.NewRecorder()
+	for k, v := range w.Header() {
+		rec.Header()[k] = v
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	rc.Flush()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		chatHandler(rec, sub)
 	}()
-	switch toolName {
-	case "configure_agent":
-		result = handleConfigureAgent(args)
-		return result
-	case "get_agent_info":
-		result = handleGetAgentInfo(args)
-		return result
-	case "list_models_for_role":
-		result = handleListModelsForRole(args)
-		return result
-	case "view_logs":
-		result = handleViewLogs(args)
-		return result
-	case "debug_code":
-		filePath, _ := args["file_path"].(string)
-		cmdArgs, _ := args["args"].(string)
-		cmd := filePath
-		if cmdArgs != "" {
-			cmd = filePath + " " + cmdArgs
-		}
-		var callErr error
-		result, callErr = callTool("execute", map[string]interface{}{"command": cmd})
-		if callErr != nil {
-			result = map[string]interface{}{"error": callErr.Error()}
-		}
-		return result
-	case "edit_file":
-		filePath, _ := args["file_path"].(string)
-		oldText, _ := args["old_text"].(string)
-		newText, _ := args["new_text"].(string)
-		readResult, readErr := callTool("read", map[string]interface{}{"path": filePath})
-		if readErr != nil {
-			result = map[string]interface{}{"error": readErr.Error()}
-			return result
-		}
-		content, _ := readResult["content"].(string)
-		if !strings.Contains(content, oldText) {
-			result = map[string]interface{}{"error": "old_text не найден в файле"}
-			return result
-		}
-		newContent := strings.Replace(content, oldText, newText, 1)
-		result, readErr = callTool("write", map[string]interface{}{"path": filePath, "content": newContent})
-		if readErr != nil {
-			result = map[string]interface{}{"error": readErr.Error()}
+	ticker := time.NewTicker(chatStreamPing)
+	defer ticker.Stop()
+	for waiting := true; waiting; {
+		select {
+		case <-done:
+			waiting = false
+		case <-ticker.C:
+			send("", nil)
 		}
-		return result
+	}
+	event := "result"
+	if rec.Code != http.StatusOK {
+		event = "error"
+	}
+	send(event, bytes.TrimSpace(rec.Body.Bytes()))
+}
 
-	// ============================================================================
-	// Универсальные LEGO-блоки (compound skills)
-	// Каждый скил выполняет цепочку базовых инструментов за один вызов.
-	// Умная модель (7B+) предпочтёт базовые инструменты.
-	// Слабая модель (3B) вызовет один составной скил и получит готовый результат.
-	// ============================================================================
+// splitReasoning — ответ модели без размышлений и сами размышления: поле
+// провайдера (Ollama thinking) и блоки в тексте ответа.
+func splitReasoning(resp *llm.ChatResponse) (answer, thoughts string) {
+	answer, thoughts = reasoning.Split(resp.Content)
+	if resp.Reasoning != "" {
+		thoughts = strings.TrimSpace(resp.Reasoning + "\n\n" + thoughts)
+	}
+	return answer, thoughts
+}
 
-	// БЛОК 1: Системные
-	case "full_system_report":
-		result = handleFullSystemReport()
-		return result
-	case "check_stack":
-		result = handleCheckStack(args)
-		return result
-	case "diagnose_service":
-		result = handleDiagnoseService(args)
-		return result
+// currentGuard — защитный слой с политиками из текущей конфигурации.
+func currentGuard() guard.Guard {
+	cfg := config.Current()
+	return guard.Guard{Input: guard.Policy(cfg.GuardInputPolicy), Output: guard.Policy(cfg.GuardOutputPolicy)}
+}
 
-	case "web_research":
-		result = handleWebResearch(args)
-		return result
-	case "check_resources_batch":
-		result = handleCheckResourcesBatch(args)
-		return result
+// guardToolResult — результат инструмента для контекста модели после
+// проверки на prompt-injection. Веб-инструменты отмечаются отдельным источником.
+// Слишком длинный результат сокращается, см. compactToolResult.
+func guardToolResult(g guard.Guard, toolName string, result []byte, findings *[]guard.Finding) string {
+	source := guard.SourceTool
+	if toolName == "web_research" || toolName == "internet_search" || strings.HasPrefix(toolName, "browser_") {
+		source = guard.SourceWeb
+	}
+	verdict := g.CheckInput(source, toolName, string(result))
+	*findings = append(*findings, verdict.Findings...)
+	return compactToolResult(toolName, verdict.Apply(string(result)))
+}
 
-	case "generate_report":
-		result = handleGenerateReport(args)
-		return result
-	case "create_script":
-		result = handleCreateScript(args)
-		return result
+// toolArtifacts — полные тексты усечённых результатов инструментов (get_artifact).
+var toolArtifacts = artifact.NewStore(200, time.Hour)
 
-	case "run_commands":
-		result = handleRunCommands(args)
-		return result
-	case "setup_cron_job":
-		result = handleSetupCronJob(args)
-		return result
-	case "setup_git_automation":
-		result = handleSetupGitAutomation(args)
-		return result
-	case "project_init":
-		result = handleProjectInit(args)
-		return result
+// compactToolResult — результат длиннее TOOL_RESULT_MAX_CHARS заменяется
+// началом и концом; полный текст сохраняется артефактом, модель может
+// дочитать его инструментом get_artifact.
+func compactToolResult(toolName, text string) string {
+	if toolName == "get_artifact" {
+		return text
+	}
+	preview, cut := artifact.Truncate(text, config.Current().ToolResultMaxChars)
+	if !cut {
+		return text
+	}
+	id := toolArtifacts.Put(toolName, text)
+	metrics.RecordToolResultTruncated(toolName)
+	slog.Info("Результат инструмента сокращён",
+		slog.String("инструмент", toolName),
+		slog.Int("символов", utf8.RuneCountInString(text)),
+		slog.String("артефакт", id))
+	out, _ := json.Marshal(map[string]interface{}{
+		"truncated":   true,
+		"artifact_id": id,
+		"total_chars": utf8.RuneCountInString(text),
+		"preview":     preview,
+		"note":        "Результат сокращён: показаны начало и конец. Нужные подробности прочитай инструментом get_artifact (id, offset/length или query).",
+	})
+	return string(out)
+}
 
-	case "install_packages":
-		result = handleInstallPackages(args)
-		return result
+// handleGetArtifact — инструмент get_artifact: фрагмент полного результата
+// по смещению или строки, содержащие query.
+func handleGetArtifact(args map[string]interface{}) map[string]interface{} {
+	id, _ := args["id"].(string)
+	a, ok := toolArtifacts.Get(id)
+	if !ok {
+		return map[string]interface{}{"error": "Артефакт " + id + " не найден или устарел — повтори исходный вызов инструмента"}
+	}
+	limit := config.Current().ToolResultMaxChars
+	if query, _ := args["query"].(string); query != "" {
+		lines, found := artifact.Grep(a.Content, query, limit)
+		return map[string]interface{}{"id": a.ID, "tool": a.Tool, "query": query, "matches": found, "content": lines}
+	}
+	offset, _ := args["offset"].(float64)
+	length, _ := args["length"].(float64)
+	if length <= 0 || (limit > 0 && int(length) > limit) {
+		length = float64(limit)
+	}
+	part, rest := artifact.Slice(a.Content, int(offset), int(length))
+	return map[string]interface{}{"id": a.ID, "tool": a.Tool, "offset": int(offset), "content": part, "remaining_chars": rest}
+}
 
-	default:
-		var callErr error
-		result, callErr = callTool(toolName, args)
-		if callErr != nil {
-			slog.Error("[TOOL-CALL] ошибка вызова инструмента", slog.String("инструмент", toolName), slog.String("ошибка", callErr.Error()))
-			result = map[string]interface{}{"error": callErr.Error()}
-		}
-		return result
+// recordGuardFindings — метрики и журнал находок защитного слоя.
+func recordGuardFindings(findings []guard.Finding, agentName, cid string) {
+	for _, f := range findings {
+		metrics.RecordGuardFinding(f.Source, f.Kind, f.Action)
+		slog.Warn("Защитный слой: подозрительный фрагмент",
+			slog.String("агент", agentName),
+			slog.String("источник", f.Source),
+			slog.String("имя", f.Name),
+			slog.String("правило", f.Rule),
+			slog.String("действие", f.Action),
+			slog.String("фрагмент", f.Excerpt),
+			slog.String("request_id", cid))
 	}
 }
 
-// parseToolArguments — универсальный парсер аргументов tool call.
-// Ollama возвращает arguments как JSON-объект, OpenRouter/OpenAI — как JSON-строку.
-// Некоторые модели могут вернуть число, строку или невалидный JSON.
-// Функция пытается распарсить в map[string]interface{}, а при неудаче
-// оборачивает значение в map с ключом "value".
-func parseToolArguments(raw json.RawMessage) map[string]interface{} {
-	if len(raw) == 0 {
-		return map[string]interface{}{}
-	}
-	// Попытка 1: стандартный JSON-объект {"key": "value"}
-	var args map[string]interface{}
-	if err := json.Unmarshal(raw, &args); err == nil {
-		return args
-	}
-	// Попытка 2: JSON-строка (OpenAI формат) — "{\"key\": \"value\"}"
-	var jsonStr string
-	if err := json.Unmarshal(raw, &jsonStr); err == nil {
-		if err2 := json.Unmarshal([]byte(jsonStr), &args); err2 == nil {
-			return args
-		}
-		return map[string]interface{}{"value": jsonStr}
-	}
-	// Попытка 3: любое другое значение (число, массив и т.д.)
-	var anyVal interface{}
-	if err := json.Unmarshal(raw, &anyVal); err == nil {
-		return map[string]interface{}{"value": anyVal}
-	}
-	slog.Warn("Не удалось распарсить аргументы tool call")
-	return map[string]interface{}{}
-}
-
-// parseXMLToolCall — парсит XML-формат tool calls от моделей типа nemotron.
-// Формат: <tool_call><function=имя><parameter=ключ>значение</parameter>...</function></tool_call>
-// Возвращает имя функции, аргументы и флаг успешного парсинга.
-func parseXMLToolCall(content string) (string, map[string]interface{}, bool) {
-	content = strings.TrimSpace(content)
-	if !strings.Contains(content, "<tool_call>") {
-		return "", nil, false
-	}
-
-	// Формат 1 (nemotron): <tool_call><function=имя><parameter=ключ>значение</parameter></function></tool_call>
-	fnRe := regexp.MustCompile(`<function=([^>]+)>`)
-	fnMatch := fnRe.FindStringSubmatch(content)
-	if len(fnMatch) >= 2 {
-		funcName := strings.TrimSpace(fnMatch[1])
-		paramRe := regexp.MustCompile(`<parameter=([^>]+)>\s*([\s\S]*?)\s*</parameter>`)
-		paramMatches := paramRe.FindAllStringSubmatch(content, -1)
-		args := make(map[string]interface{})
-		for _, m := range paramMatches {
-			if len(m) >= 3 {
-				key := strings.TrimSpace(m[1])
-				val := strings.TrimSpace(m[2])
-				args[key] = val
-			}
+// dispatchChecked — вызов инструмента после оценки риска. Разрушительный
+// вызов выполняется только с согласия пользователя или при RISK_AUTO_APPROVE;
+// иначе модель получает отказ, а вызов добавляется в pending — к ответу
+// будет приложен запрос подтверждения.
+func dispatchChecked(ctx context.Context, agentName, toolName string, args map[string]interface{}, history []llm.Message, pending *[]risk.Assessment) (result map[string]interface{}) {
+	report, _ := ctx.Value(taskReportKey{}).(*taskreport.Report)
+	if rec := recorderFrom(ctx); rec != nil {
+		defer func() { rec.RecordTool(toolName, args, result) }()
+	}
+	if violations := toolSchemaFrom(ctx, toolName).Check(args); len(violations) > 0 {
+		// Модель получает список ошибок и исправляет вызов сама, инструмент не вызывается
+		list := make([]string, len(violations))
+		for i, v := range violations {
+			list[i] = v.String()
+		}
+		slog.Warn("Аргументы не соответствуют схеме инструмента", slog.String("агент", agentName), slog.String("инструмент", toolName), slog.String("ошибки", strings.Join(list, "; ")))
+		result = map[string]interface{}{
+			"error":             "Некорректные аргументы " + toolName + ": " + strings.Join(list, "; "),
+			"validation_errors": violations,
+			"message":           "Инструмент не вызван. Исправь аргументы по описанию параметров и повтори вызов.",
+		}
+		timelineFrom(ctx).Add(timeline.Event{Type: timeline.EventToolCall, Tool: toolName, Status: taskreport.ActionError, Detail: strings.Join(list, "; ")})
+		if report != nil {
+			report.Record(toolName, "", result, 0)
 		}
-		return funcName, args, true
+		return result
 	}
-
-	// Формат 2 (glm и др.): <tool_call>имя_функции</tool_call> или <tool_call>имя_функции{"key":"val"}</tool_call>
-	simpleRe := regexp.MustCompile(`<tool_call>\s*(\w+)\s*((?:\{[\s\S]*?\})?)\s*</tool_call>`)
-	simpleMatch := simpleRe.FindStringSubmatch(content)
-	if len(simpleMatch) >= 2 {
-		funcName := strings.TrimSpace(simpleMatch[1])
-		args := make(map[string]interface{})
-		if len(simpleMatch) >= 3 && strings.TrimSpace(simpleMatch[2]) != "" {
-			json.Unmarshal([]byte(strings.TrimSpace(simpleMatch[2])), &args)
+	assessment := riskEngine.Classify(toolName, args)
+	if rs := replayFrom(ctx); rs != nil && rs.MockTools {
+		// Воспроизведение: инструмент не вызывается, результат из записи
+		recorded, ok := rs.Player.Tool(toolName, args)
+		if !ok {
+			recorded = map[string]interface{}{"error": "Воспроизведение: вызова " + toolName + " нет в записи"}
+		}
+		status, errText := taskreport.ActionStatus(recorded)
+		timelineFrom(ctx).Add(timeline.Event{Type: timeline.EventToolCall, Tool: toolName, Target: assessment.Target, Status: status, Detail: strings.TrimSpace("из записи " + errText)})
+		if report != nil {
+			report.Record(toolName, assessment.Target, recorded, 0)
+		}
+		return recorded
+	}
+	decision := "executed"
+	if assessment.Level == risk.LevelDestructive {
+		switch {
+		case config.Current().RiskAutoApprove:
+			decision = "auto_approved"
+		case riskApprovals.Consume(approvalScopeFrom(ctx), assessment):
+			decision = "approved"
+		default:
+			decision = "confirmation_required"
+		}
+		slog.Warn("Разрушительное действие",
+			slog.String("агент", agentName),
+			slog.String("инструмент", toolName),
+			slog.String("цель", truncate(assessment.Target, 200)),
+			slog.String("причина", assessment.Reason),
+			slog.String("решение", decision))
+	}
+	metrics.RecordToolRisk(string(assessment.Level), decision)
+	if decision == "confirmation_required" {
+		*pending = append(*pending, assessment)
+		result = map[string]interface{}{
+			"error":                 "Действие не выполнено: нужно подтверждение пользователя (" + assessment.Reason + ")",
+			"confirmation_required": true,
+			"risk":                  assessment.Level,
+			"message":               "Не повторяй вызов. Кратко объясни, что собираешься сделать и зачем; запрос подтверждения будет добавлен к ответу автоматически.",
+		}
+		if report != nil {
+			report.Record(toolName, assessment.Target, result, 0)
+		}
+		timelineFrom(ctx).Add(timeline.Event{Type: timeline.EventToolCall, Tool: toolName, Target: assessment.Target, Status: taskreport.ActionConfirmationRequired, Detail: assessment.Reason})
+		return result
+	}
+	started := time.Now()
+	result = dispatchTool(ctx, agentName, toolName, args, history)
+	files := saveToolArtifacts(ctx, agentName, toolName, args, result)
+	if len(files) > 0 {
+		result["files"] = files
+	}
+	elapsed := time.Since(started)
+	status, errText := taskreport.ActionStatus(result)
+	timelineFrom(ctx).Add(timeline.Event{Type: timeline.EventToolCall, Tool: toolName, Target: assessment.Target, Status: status, DurationMs: elapsed.Milliseconds(), Detail: errText})
+	if report != nil {
+		report.Record(toolName, assessment.Target, result, elapsed)
+		for _, f := range files {
+			report.AddArtifacts(taskreport.Artifact{ID: f.ID, Name: f.Name, URL: f.URL})
 		}
-		return funcName, args, true
 	}
-
-	return "", nil, false
+	return result
 }
 
-// stripThinkingTags — удаляет блоки размышлений reasoning-моделей из текста ответа.
-// Поддерживает форматы: [THINK]...[/THINK] (Ministral), <think>...</think> (DeepSeek-R1, QwQ).
-// Reasoning-модели (ministral-3-14b-reasoning, deepseek-r1, qwq-32b и др.) оборачивают
-// свой внутренний процесс размышления в специальные теги перед финальным ответом.
-// Эти теги нужно удалить, чтобы:
-//  1. Не показывать пользователю внутренние размышления модели
-//  2. Не ломать парсинг tool calls (JSON/XML/inline), если модель думает перед вызовом
-//
-// Возвращает очищенный текст без thinking-блоков.
-func stripThinkingTags(content string) string {
-	thinkRe := regexp.MustCompile(`(?s)\[THINK\].*?\[/THINK\]`)
-	content = thinkRe.ReplaceAllString(content, "")
-	xmlThinkRe := regexp.MustCompile(`(?s)<think>.*?</think>`)
-	content = xmlThinkRe.ReplaceAllString(content, "")
-	return strings.TrimSpace(content)
+type toolSchemasKey struct{}
+
+// toolSchemaFrom — схема параметров инструмента, выданного модели в текущем
+// запросе; nil — инструмент не выдан или схемы нет (аргументы не проверяются).
+func toolSchemaFrom(ctx context.Context, name string) *toolschema.Schema {
+	schemas, _ := ctx.Value(toolSchemasKey{}).(map[string]*toolschema.Schema)
+	return schemas[name]
 }
 
-// handleConfigureAgent — обработчик инструмента configure_agent.
-// Позволяет настраивать агента Admin: менять модель, провайдера, промпт.
-//
-// Параметры (из args):
-//   - agent_name (обязательный): имя агента для настройки
-//   - model (опциональный): новая модель
-//   - provider (опциональный): новый провайдер
-//   - prompt (опциональный): новый системный промпт
-func handleConfigureAgent(args map[string]interface{}) map[string]interface{} {
-	agentName, ok := args["agent_name"].(string)
-	if !ok || agentName == "" {
-		return map[string]interface{}{"error": "agent_name обязателен"}
-	}
+// taskReportKey — ключ контекста со сводкой действий запроса /chat (taskreport.Report).
+type taskReportKey struct{}
 
-	var agent models.Agent
-	if err := db.DB.Where("name = ?", agentName).First(&agent).Error; err != nil {
-		return map[string]interface{}{"error": "Агент не найден: " + agentName}
-	}
+// chatTimelines — хронологии последних запросов /chat (GET /chat/{request_id}/events).
+var chatTimelines = timeline.NewStore(1000, 24*time.Hour)
 
-	changes := []string{}
+type timelineKey struct{}
 
-	if model, ok := args["model"].(string); ok && model != "" {
-		agent.LLMModel = model
-		changes = append(changes, "модель: "+model)
+// timelineFrom — хронология текущего запроса; nil — запрос без хронологии
+// (запись в nil ничего не делает).
+func timelineFrom(ctx context.Context) *timeline.Timeline {
+	tl, _ := ctx.Value(timelineKey{}).(*timeline.Timeline)
+	return tl
+}
+
+// chatEventsHandler — GET /chat/{request_id}/events — хронология запроса
+// /chat: выбор модели, вызовы LLM и повторы, смена провайдера, вызовы
+// инструментов с длительностью. request_id — заголовок X-Request-ID ответа.
+func chatEventsHandler(w http.ResponseWriter, r *http.Request) {
+	cid := r.Header.Get("X-Request-ID")
+	requestID, action, _ := strings.Cut(strings.Trim(strings.TrimPrefix(r.URL.Path, "/chat/"), "/"), "/")
+	if requestID == "" || action != "events" {
+		apierror.NotFound(w, cid, "Ресурс не найден")
+		return
 	}
-	if provider, ok := args["provider"].(string); ok && provider != "" {
-		agent.Provider = provider
-		changes = append(changes, "провайдер: "+provider)
+	if r.Method != http.MethodGet {
+		apierror.MethodNotAllowed(w, cid)
+		return
 	}
-	if prompt, ok := args["prompt"].(string); ok && prompt != "" {
-		agent.Prompt = prompt
-		changes = append(changes, "промпт обновлён")
+	rec, ok := chatTimelines.Get(requestID)
+	if !ok {
+		apierror.NotFound(w, cid, "Хронология запроса не найдена или устарела")
+		return
 	}
+	writeJSON(w, rec)
+}
 
-	if len(changes) == 0 {
-		return map[string]interface{}{"error": "Не указаны параметры для изменения (model, provider, prompt)"}
-	}
+type recorderKey struct{}
 
-	if err := db.DB.Save(&agent).Error; err != nil {
-		return map[string]interface{}{"error": "Ошибка сохранения: " + err.Error()}
-	}
+// recorderFrom — запись текущего запроса; nil — запрос не записывается.
+func recorderFrom(ctx context.Context) *replay.Recorder {
+	rec, _ := ctx.Value(recorderKey{}).(*replay.Recorder)
+	return rec
+}
 
-	return map[string]interface{}{
-		"status":  "ok",
-		"agent":   agentName,
-		"changes": changes,
-		"message": "Агент " + agentName + " успешно настроен",
-	}
+// replaySession — параметры воспроизведения записи (POST /chat/replay).
+type replaySession struct {
+	Player    *replay.Player
+	MockTools bool   // Инструменты отвечают записанными результатами
+	Provider  string // Модель воспроизведения: записанная или заданная в запросе
+	Model     string
 }
 
-// handleGetAgentInfo — обработчик инструмента get_agent_info.
-// Возвращает полную информацию об агенте: модель, провайдер, промпт, поддержка инструментов.
-func handleGetAgentInfo(args map[string]interface{}) map[string]interface{} {
-	agentName, ok := args["agent_name"].(string)
-	if !ok || agentName == "" {
-		return map[string]interface{}{"error": "agent_name обязателен"}
-	}
+type replayKey struct{}
 
-	var agent models.Agent
-	if err := db.DB.Where("name = ?", agentName).First(&agent).Error; err != nil {
-		return map[string]interface{}{"error": "Агент не найден: " + agentName}
+// replayFrom — воспроизведение из контекста запроса (nil — обычный запрос).
+func replayFrom(ctx context.Context) *replaySession {
+	rs, _ := ctx.Value(replayKey{}).(*replaySession)
+	return rs
+}
+
+// saveRecording — сохраняет запись запроса /chat; 0 — не сохранена.
+func saveRecording(requestID, agentName, providerName, modelName string, request []byte, tape replay.Tape, response string) uint {
+	if db.DB == nil {
+		return 0
 	}
+	data, err := json.Marshal(tape)
+	if err != nil {
+		return 0
+	}
+	rec := models.ChatRecording{
+		RequestID: requestID,
+		AgentName: agentName,
+		Provider:  providerName,
+		Model:     modelName,
+		Request:   string(request),
+		Tape:      string(data),
+		Response:  response,
+		LLMCalls:  len(tape.Exchanges),
+		ToolCalls: len(tape.Tools),
+	}
+	if err := db.DB.Create(&rec).Error; err != nil {
+		slog.Error("Не удалось сохранить запись запроса", slog.String("ошибка", err.Error()), slog.String("request_id", requestID))
+		return 0
+	}
+	return rec.ID
+}
 
-	return map[string]interface{}{
-		"name":           agent.Name,
-		"model":          agent.LLMModel,
-		"provider":       agent.Provider,
-		"supports_tools": agent.SupportsTools,
-		"prompt":         agent.Prompt,
-		"prompt_file":    agent.CurrentPromptFile,
-		"avatar":         agent.Avatar,
+// chatRecordingsHandler — GET /chat/recordings?agent=...&limit=50 — записи
+// запросов /chat, новые первыми (без тела запроса и записи).
+func chatRecordingsHandler(w http.ResponseWriter, r *http.Request) {
+	cid := r.Header.Get("X-Request-ID")
+	if r.Method != http.MethodGet {
+		apierror.MethodNotAllowed(w, cid)
+		return
 	}
-}
-
-// handleListModelsForRole — обработчик инструмента list_models_for_role.
-// Возвращает список доступных моделей с рекомендациями для указанной роли.
-// Показывает какие модели подходят (suitable=true) и какие нет, с пояснениями.
-func handleListModelsForRole(args map[string]interface{}) map[string]interface{} {
-	role, ok := args["role"].(string)
-	if !ok || role == "" {
-		return map[string]interface{}{"error": "role обязателен (admin)"}
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	if limit <= 0 || limit > 500 {
+		limit = 50
+	}
+	query := db.DB.Order("id DESC").Limit(limit)
+	if agent := r.URL.Query().Get("agent"); agent != "" {
+		query = query.Where("agent_name = ?", agent)
+	}
+	var list []models.ChatRecording
+	if err := query.Find(&list).Error; err != nil {
+		apierror.InternalError(w, cid, "Ошибка чтения записей", "")
+		return
 	}
+	writeJSON(w, list)
+}
 
-	ollamaModels, err := repository.GetOllamaModels()
+// chatRecordingHandler — одна запись:
+//
+//	GET    /chat/recordings/{id} — запись с телом запроса, ответами модели и результатами инструментов
+//	DELETE /chat/recordings/{id} — удалить запись
+func chatRecordingHandler(w http.ResponseWriter, r *http.Request) {
+	cid := r.Header.Get("X-Request-ID")
+	id, err := strconv.ParseUint(strings.Trim(strings.TrimPrefix(r.URL.Path, "/chat/recordings/"), "/"), 10, 64)
 	if err != nil {
-		return map[string]interface{}{"error": "Не удалось получить список моделей: " + err.Error()}
+		apierror.NotFound(w, cid, "Запись не найдена")
+		return
 	}
-
-	type modelRec struct {
-		Name     string `json:"name"`
-		Suitable bool   `json:"suitable"`
-		Note     string `json:"note"`
-		Family   string `json:"family"`
-		Size     string `json:"size"`
+	var rec models.ChatRecording
+	if err := db.DB.First(&rec, id).Error; err != nil {
+		apierror.NotFound(w, cid, "Запись не найдена")
+		return
 	}
-	result := make([]modelRec, 0, len(ollamaModels))
-
-	for _, m := range ollamaModels {
-		fullInfo, infoErr := repository.GetModelFullInfo(m)
-		if infoErr != nil {
-			result = append(result, modelRec{Name: m, Note: "Ошибка получения информации"})
-			continue
-		}
-		var roles []string
-		var notes map[string]string
-		json.Unmarshal([]byte(fullInfo.SuitableRoles), &roles)
-		json.Unmarshal([]byte(fullInfo.RoleNotes), &notes)
-
-		suitable := false
-		for _, r := range roles {
-			if r == role {
-				suitable = true
-				break
-			}
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, struct {
+			models.ChatRecording
+			Request json.RawMessage `json:"request"`
+			Tape    json.RawMessage `json:"tape"`
+		}{rec, json.RawMessage(rec.Request), json.RawMessage(rec.Tape)})
+	case http.MethodDelete:
+		if err := db.DB.Delete(&rec).Error; err != nil {
+			apierror.InternalError(w, cid, "Не удалось удалить запись", "")
+			return
 		}
-		result = append(result, modelRec{
-			Name:     m,
-			Suitable: suitable,
-			Note:     notes[role],
-			Family:   fullInfo.Family,
-			Size:     fullInfo.ParameterSize,
-		})
+		writeJSON(w, map[string]interface{}{"status": "deleted", "id": rec.ID})
+	default:
+		apierror.MethodNotAllowed(w, cid)
 	}
+}
 
-	return map[string]interface{}{
-		"role":   role,
-		"models": result,
-	}
+// ReplayRequest — запрос POST /chat/replay.
+type ReplayRequest struct {
+	RecordingID uint   `json:"recording_id"`
+	Provider    string `json:"provider,omitempty"`   // Провайдер другой модели (пусто — записанный)
+	Model       string `json:"model,omitempty"`      // Другая модель (пусто — записанная)
+	MockTools   *bool  `json:"mock_tools,omitempty"` // Результаты инструментов из записи (по умолчанию true)
+	MockLLM     bool   `json:"mock_llm,omitempty"`   // Ответы модели из записи: проверка разбора ответов без провайдера
 }
 
-// healthHandler — проверка состояния сервиса (GET /health).
-// Возвращает JSON {"status":"ok","service":"agent-service"} для мониторинга.
-func healthHandler(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	w.Write([]byte(`{"status":"ok","service":"agent-service"}`))
+// ReplayResponse — результат воспроизведения и сравнение с записанным ответом.
+type ReplayResponse struct {
+	ChatResponse
+	RecordingID uint         `json:"recording_id"`
+	Provider    string       `json:"provider"`
+	Model       string       `json:"model"`
+	MockTools   bool         `json:"mock_tools"`
+	MockLLM     bool         `json:"mock_llm"`
+	Original    string       `json:"original"`       // Записанный ответ
+	Identical   bool         `json:"identical"`      // Ответ совпал с записанным
+	Diff        string       `json:"diff,omitempty"` // Unified diff записанного и нового ответа
+	Replay      replay.Stats `json:"replay"`
 }
 
-// agentsHandler — получение списка всех агентов с их настройками (GET /agents).
-// Возвращает JSON-массив с информацией о каждом агенте:
-// имя, текущая модель, провайдер, поддержка инструментов, аватар, промпт.
-// Используется фронтендом для отображения карточек агентов в панели моделей.
-func agentsHandler(w http.ResponseWriter, r *http.Request) {
+// chatReplayHandler — POST /chat/replay — повторяет записанный запрос /chat:
+// инструменты отвечают записанными результатами (mock_tools, по умолчанию),
+// модель — записанная, другая (provider, model) или тоже из записи (mock_llm).
+// Реплики не сохраняются; при mock_tools=false инструменты выполняются по-настоящему.
+func chatReplayHandler(w http.ResponseWriter, r *http.Request) {
 	cid := r.Header.Get("X-Request-ID")
-	if r.Method != http.MethodGet {
+	if r.Method != http.MethodPost {
 		apierror.MethodNotAllowed(w, cid)
 		return
 	}
-	var agents []models.Agent
-	if err := db.DB.Find(&agents).Error; err != nil {
-		apierror.InternalError(w, cid, err.Error(), "")
+	var req ReplayRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.RecordingID == 0 {
+		apierror.BadRequest(w, cid, "Не указан recording_id", "Передайте ID записи из поля recording_id ответа /chat")
 		return
 	}
-	var result []map[string]interface{}
-	for _, a := range agents {
-		result = append(result, map[string]interface{}{
-			"name":          a.Name,
-			"model":         a.LLMModel,
-			"provider":      a.Provider,
-			"supportsTools": a.SupportsTools,
-			"avatar":        a.Avatar,
-			"prompt_file":   a.CurrentPromptFile,
-			"prompt":        a.Prompt,
-		})
+	if req.Provider != "" && req.Model == "" {
+		apierror.BadRequest(w, cid, "Вместе с provider укажите model", "")
+		return
 	}
-	w.Header().Set("Content-Type", "application/json")
-	writeJSON(w, result)
-}
-
-// modelsHandler — получение списка локальных моделей Ollama (GET /models).
-// Запрашивает список установленных моделей у Ollama, синхронизирует с БД
-// и возвращает полную информацию о каждой модели: поддержка инструментов,
-// семейство, размер, специализация на коде, подходящие роли и пояснения.
-//
-// Возвращает JSON-массив объектов ModelInfo для отображения в UI.
-// Вся информация определяется автоматически — никаких жёстких привязок.
-func modelsHandler(w http.ResponseWriter, r *http.Request) {
-	cid := r.Header.Get("X-Request-ID")
-	if r.Method != http.MethodGet {
-		apierror.MethodNotAllowed(w, cid)
+	if req.MockLLM && req.Model != "" {
+		apierror.BadRequest(w, cid, "mock_llm несовместим с model", "Ответы модели берутся из записи — другая модель не вызывается")
 		return
 	}
-
-	ollamaModels, err := repository.GetOllamaModels()
-	if err != nil {
-		apierror.InternalError(w, cid, "Не удалось получить модели", err.Error())
+	var recording models.ChatRecording
+	if err := db.DB.First(&recording, req.RecordingID).Error; err != nil {
+		apierror.NotFound(w, cid, "Запись не найдена")
 		return
 	}
-
-	if err := repository.SyncModels(ollamaModels); err != nil {
-		apierror.InternalError(w, cid, "Не удалось синхронизировать модели", err.Error())
+	var tape replay.Tape
+	var chatReq ChatRequest
+	if json.Unmarshal([]byte(recording.Tape), &tape) != nil || json.Unmarshal([]byte(recording.Request), &chatReq) != nil {
+		apierror.InternalError(w, cid, "Запись повреждена", "")
 		return
 	}
+	// Воспроизведение не пишет в диалог и не создаёт новую запись
+	chatReq.ChatID, chatReq.Record, chatReq.NoCache = "", false, true
 
-	type ModelInfo struct {
-		Name          string            `json:"name"`
-		SupportsTools bool              `json:"supportsTools"`
-		Family        string            `json:"family"`
-		ParameterSize string            `json:"parameterSize"`
-		IsCodeModel   bool              `json:"isCodeModel"`
-		SuitableRoles []string          `json:"suitableRoles"`
-		RoleNotes     map[string]string `json:"roleNotes"`
+	rs := &replaySession{
+		Player:    replay.NewPlayer(tape, req.MockLLM),
+		MockTools: req.MockTools == nil || *req.MockTools,
+		Provider:  recording.Provider,
+		Model:     recording.Model,
 	}
-	result := make([]ModelInfo, 0, len(ollamaModels))
-
-	for _, m := range ollamaModels {
-		fullInfo, err := repository.GetModelFullInfo(m)
-		if err != nil {
-			slog.Error("Ошибка получения информации о модели", slog.String("модель", m), slog.String("ошибка", err.Error()))
-			result = append(result, ModelInfo{Name: m})
-			continue
+	if req.Model != "" {
+		rs.Model = req.Model
+		if req.Provider != "" {
+			rs.Provider = req.Provider
 		}
+	}
+	body, _ := json.Marshal(chatReq)
+	ctx := context.WithValue(r.Context(), replayKey{}, rs)
+	sub, err := http.NewRequestWithContext(ctx, http.MethodPost, "/chat", bytes.NewReader(body))
+	if err != nil {
+		apierror.InternalError(w, cid, "Не удалось воспроизвести запрос", "")
+		return
+	}
+	sub.Header = r.Header.Clone()
+	sub.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	chatHandler(rec, sub)
 
-		var roles []string
-		var notes map[string]string
-		json.Unmarshal([]byte(fullInfo.SuitableRoles), &roles)
-		json.Unmarshal([]byte(fullInfo.RoleNotes), &notes)
-
-		result = append(result, ModelInfo{
-			Name:          m,
-			SupportsTools: fullInfo.SupportsTools,
-			Family:        fullInfo.Family,
-			ParameterSize: fullInfo.ParameterSize,
-			IsCodeModel:   fullInfo.IsCodeModel,
-			SuitableRoles: roles,
-			RoleNotes:     notes,
-		})
+	var resp ChatResponse
+	if rec.Code != http.StatusOK || json.Unmarshal(rec.Body.Bytes(), &resp) != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(rec.Code)
+		w.Write(rec.Body.Bytes())
+		return
 	}
+	out := ReplayResponse{
+		ChatResponse: resp,
+		RecordingID:  recording.ID,
+		Provider:     rs.Provider,
+		Model:        rs.Model,
+		MockTools:    rs.MockTools,
+		MockLLM:      req.MockLLM,
+		Original:     recording.Response,
+		Identical:    resp.Response == recording.Response,
+		Replay:       rs.Player.Stats(),
+	}
+	if !out.Identical {
+		out.Diff = textdiff.Unified("response", recording.Response, resp.Response).Text
+	}
+	slog.Info("Запрос воспроизведён",
+		slog.Uint64("запись", uint64(recording.ID)),
+		slog.String("модель", rs.Provider+"/"+rs.Model),
+		slog.Bool("инструменты_из_записи", rs.MockTools),
+		slog.Bool("модель_из_записи", req.MockLLM),
+		slog.Bool("совпадает", out.Identical),
+		slog.String("request_id", cid))
+	writeJSON(w, out)
+}
 
-	w.Header().Set("Content-Type", "application/json")
-	writeJSON(w, result)
+// fileChanges — изменения файлов за один запрос /chat; сохраняются вместе с ответом.
+type fileChanges struct {
+	mu   sync.Mutex
+	list []models.FileChange
 }
 
-// promptsHandler — получение списка файлов промптов для агента (GET /prompts?agent=...).
-// Ищет файлы .txt, .prompt, .md в директории prompts/{agent}.
-// Используется для отображения модального окна выбора промпта в UI.
-func promptsHandler(w http.ResponseWriter, r *http.Request) {
-	cid := r.Header.Get("X-Request-ID")
-	if r.Method != http.MethodGet {
-		apierror.MethodNotAllowed(w, cid)
+type fileChangesKey struct{}
+
+// attachDiff — добавляет к результату инструмента unified diff изменения
+// файла и запоминает изменение для ответа /chat. Двоичные файлы
+// и записи без изменений пропускаются.
+func attachDiff(ctx context.Context, result map[string]interface{}, agentName, toolName, path, before, after string, created bool) {
+	if result == nil || strings.ContainsRune(before, 0) || strings.ContainsRune(after, 0) {
 		return
 	}
-	agentName := r.URL.Query().Get("agent")
-	if agentName == "" {
-		apierror.BadRequest(w, cid, "Не указан параметр agent", "")
+	d := textdiff.Unified(path, before, after)
+	if d.Text == "" {
+		result["diff"] = "файл не изменился"
 		return
 	}
-	promptsDir := filepath.Join(".", "prompts", agentName)
-	if _, err := os.Stat(promptsDir); os.IsNotExist(err) {
-		writeJSON(w, []string{})
-		return
+	result["diff"] = d.Text
+	result["added"], result["removed"] = d.Added, d.Removed
+	if changes, ok := ctx.Value(fileChangesKey{}).(*fileChanges); ok {
+		changes.mu.Lock()
+		changes.list = append(changes.list, models.FileChange{
+			ChatID: conversationFrom(ctx), AgentName: agentName, Tool: toolName,
+			Path: workspacePath(ctx, path), Workspace: workspaceFrom(ctx),
+			Diff: d.Text, Added: d.Added, Removed: d.Removed, Created: created,
+		})
+		changes.mu.Unlock()
 	}
-	files, err := os.ReadDir(promptsDir)
+}
+
+// save — сохраняет изменения с привязкой к ответу ассистента messageID.
+func (c *fileChanges) save(messageID uint) []models.FileChange {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.list) == 0 {
+		return nil
+	}
+	for i := range c.list {
+		c.list[i].MessageID = messageID
+	}
+	if db.DB != nil {
+		if err := db.DB.Create(&c.list).Error; err != nil {
+			slog.Error("Не удалось сохранить изменения файлов", slog.String("ошибка", err.Error()))
+		}
+	}
+	return c.list
+}
+
+// backupFile — сохраняет содержимое файла перед изменением инструментом
+// (BACKUP_ENABLED). existed=false — файла не было, откат его удалит.
+// Возвращает ID копии; 0 — копия не создана (выключено, двоичный или
+// слишком большой файл).
+func backupFile(ctx context.Context, agentName, toolName, path, content string, existed bool) uint {
+	cfg := config.Current()
+	if !cfg.BackupEnabled || db.DB == nil || path == "" {
+		return 0
+	}
+	if !backup.Restorable(content) || int64(len(content)) > cfg.MaxUploadBytes {
+		slog.Warn("Копия файла не создана: двоичный или слишком большой файл", slog.String("инструмент", toolName), slog.String("путь", path))
+		return 0
+	}
+	requestID, _ := ctx.Value(logger.CorrelationIDKey).(string)
+	b := models.FileBackup{
+		RequestID: requestID,
+		ChatID:    conversationFrom(ctx),
+		AgentName: agentName,
+		Tool:      toolName,
+		Path:      workspacePath(ctx, path),
+		Workspace: workspaceFrom(ctx),
+		Existed:   existed,
+		Content:   content,
+		Size:      len(content),
+	}
+	if err := db.DB.Create(&b).Error; err != nil {
+		slog.Error("Не удалось сохранить копию файла", slog.String("путь", path), slog.String("ошибка", err.Error()))
+		return 0
+	}
+	return b.ID
+}
+
+// readCurrent — содержимое файла перед изменением. existed=false — файла нет
+// (tools-service ответил 404). Ошибка — файл не удалось прочитать по другой
+// причине (права, размер, недоступность сервиса): его состояние неизвестно,
+// и копию для отката сделать нельзя.
+func readCurrent(ctx context.Context, path string) (content string, existed bool, err error) {
+	res, err := callToolCtx(ctx, "read", map[string]interface{}{"path": path})
 	if err != nil {
-		apierror.InternalError(w, cid, err.Error(), "")
-		return
+		return "", false, err
 	}
-	result := []string{}
-	for _, f := range files {
-		if !f.IsDir() && (strings.HasSuffix(f.Name(), ".txt") || strings.HasSuffix(f.Name(), ".prompt") || strings.HasSuffix(f.Name(), ".md")) {
-			result = append(result, f.Name())
+	if msg, failed := res["error"]; failed {
+		if code, _ := res["status_code"].(int); code == http.StatusNotFound {
+			return "", false, nil
 		}
+		return "", false, fmt.Errorf("%v", msg)
 	}
-	w.Header().Set("Content-Type", "application/json")
-	writeJSON(w, result)
+	content, ok := res["content"].(string)
+	if !ok {
+		return "", false, errors.New("в ответе read нет content")
+	}
+	return content, true, nil
 }
 
-// loadPromptHandler — загрузка промпта из файла (POST /prompts/load).
-// Читает содержимое файла prompts/{agent}/{filename}, обновляет промпт агента в БД.
-// Устанавливает CurrentPromptFile для отображения текущего выбранного файла.
-func loadPromptHandler(w http.ResponseWriter, r *http.Request) {
-	cid := r.Header.Get("X-Request-ID")
-	if r.Method != http.MethodPost {
-		apierror.MethodNotAllowed(w, cid)
-		return
+// noteNoRollback — помечает в результате инструмента, что изменение нельзя
+// откатить: файл не удалось прочитать до него.
+func noteNoRollback(result map[string]interface{}, readErr error) {
+	result["rollback_unavailable"] = "файл не прочитан до изменения, копии нет: " + readErr.Error()
+	slog.Warn("Копия файла не создана: файл не прочитан", slog.String("ошибка", readErr.Error()))
+}
+
+// restoreBackup — возвращает файл в состояние копии: записывает прежнее
+// содержимое или удаляет файл, которого не было. Текущее состояние файла
+// перед откатом тоже сохраняется (undo — ID этой копии), и откат можно
+// отменить; если копию создать не удалось (BACKUP_ENABLED=false, двоичный
+// или слишком большой файл), undo = 0 и отменить откат нельзя. Если текущее
+// состояние не прочитано, откат не выполняется. Откат выполняется в рабочем
+// пространстве, где файл был изменён: tools-service проверяет, что путь не
+// выходит за его пределы.
+func restoreBackup(ctx context.Context, b models.FileBackup) (status string, undo uint, err error) {
+	if b.Workspace != "" {
+		ctx = context.WithValue(ctx, workspaceKey{}, b.Workspace)
+	}
+	current, existed, err := readCurrent(ctx, b.Path)
+	if err != nil {
+		return "", 0, fmt.Errorf("текущее состояние файла не прочитано, откат не выполнен: %w", err)
 	}
-	var req struct {
-		Agent    string `json:"agent"`
-		Filename string `json:"filename"`
+	if !existed && !b.Existed {
+		return "absent", 0, nil
 	}
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		apierror.BadRequest(w, cid, "Невалидный JSON", "")
-		return
+	undo = backupFile(ctx, b.AgentName, "rollback", b.Path, current, existed)
+	if undo == 0 {
+		slog.Warn("Откат без копии текущей версии: отменить его будет нельзя", slog.String("путь", b.Path))
 	}
-	if req.Agent == "" || req.Filename == "" {
-		apierror.BadRequest(w, cid, "Требуются agent и filename", "")
-		return
+	var res map[string]interface{}
+	action := "restored"
+	if b.Existed {
+		res, err = callToolCtx(ctx, "write", map[string]interface{}{"path": b.Path, "content": b.Content})
+	} else {
+		action = "deleted"
+		res, err = callToolCtx(ctx, "delete", map[string]interface{}{"path": b.Path})
 	}
-	agent, err := repository.GetAgentByName(req.Agent)
-	if err != nil {
-		apierror.NotFound(w, cid, "Агент не найден")
-		return
+	if err == nil {
+		if msg, failed := res["error"]; failed {
+			err = fmt.Errorf("%v", msg)
+		}
 	}
-	promptPath := filepath.Join(".", "prompts", req.Agent, req.Filename)
-	content, err := os.ReadFile(promptPath)
 	if err != nil {
-		apierror.InternalError(w, cid, "Не удалось прочитать файл промпта", "")
-		return
+		return "", undo, err
 	}
-	agent.Prompt = string(content)
-	agent.CurrentPromptFile = req.Filename
-	if err := db.DB.Save(agent).Error; err != nil {
-		apierror.InternalError(w, cid, "Не удалось обновить агента", "")
-		return
+	now := time.Now()
+	db.DB.Model(&models.FileBackup{}).Where("id = ?", b.ID).Update("restored_at", now)
+	return action, undo, nil
+}
+
+// conversationKey — ключ контекста с ID диалога /conversations текущего запроса.
+type conversationKey struct{}
+
+// conversationFrom — ID диалога из контекста (nil — запрос вне диалога).
+func conversationFrom(ctx context.Context) *string {
+	id, _ := ctx.Value(conversationKey{}).(string)
+	if id == "" {
+		return nil
 	}
-	w.Header().Set("Content-Type", "application/json")
-	writeJSON(w, map[string]string{"status": "ok"})
+	return &id
 }
 
-// updatePromptHandler — обновление промпта вручную (POST /agent/prompt).
-// Устанавливает новый системный промпт, введённый пользователем через UI.
-// Сбрасывает CurrentPromptFile, так как промпт больше не привязан к файлу.
-func updatePromptHandler(w http.ResponseWriter, r *http.Request) {
-	cid := r.Header.Get("X-Request-ID")
-	if r.Method != http.MethodPost {
-		apierror.MethodNotAllowed(w, cid)
-		return
+// workspaceKey — ключ контекста с каталогом текущего рабочего пространства.
+type workspaceKey struct{}
+
+// workspaceFrom — каталог рабочего пространства из контекста ("" — не задано).
+func workspaceFrom(ctx context.Context) string {
+	path, _ := ctx.Value(workspaceKey{}).(string)
+	return path
+}
+
+// workspacePath — path, разрешённый от рабочего пространства из контекста, как
+// его разрешает tools-service: относительный путь становится абсолютным,
+// абсолютные пути и ~ не меняются.
+func workspacePath(ctx context.Context, path string) string {
+	ws := workspaceFrom(ctx)
+	if ws == "" || path == "" || filepath.IsAbs(path) || strings.HasPrefix(path, "~") {
+		return path
 	}
-	var req UpdatePromptRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		apierror.BadRequest(w, cid, "Невалидный JSON", "")
-		return
+	return filepath.Join(ws, path)
+}
+
+// currentWorkspace — рабочее пространство запроса /chat: workspace_id из
+// запроса, иначе пространство диалога, иначе агента (/workspace). Ошибка —
+// только если пространство из запроса не найдено; nil — пространства нет.
+func currentWorkspace(requested *uint, conv *models.Chat, agent *models.Agent) (*models.Workspace, error) {
+	id := requested
+	if id == nil && conv != nil {
+		id = conv.WorkspaceID
 	}
-	if req.Agent == "" {
-		apierror.BadRequest(w, cid, "Требуется agent", "")
-		return
+	if id == nil {
+		id = agent.WorkspaceID
 	}
-	agent, err := repository.GetAgentByName(req.Agent)
-	if err != nil {
-		apierror.NotFound(w, cid, "Агент не найден")
-		return
+	if id == nil {
+		return nil, nil
 	}
-	agent.Prompt = req.Prompt
-	agent.CurrentPromptFile = ""
-	if err := db.DB.Save(agent).Error; err != nil {
-		apierror.InternalError(w, cid, "Не удалось обновить агента", "")
-		return
-	}
-	w.Header().Set("Content-Type", "application/json")
-	writeJSON(w, map[string]string{"status": "ok"})
-}
-
-// updateAgentModelHandler — смена модели и/или провайдера агента (POST /update-model).
-// Позволяет переключить агента на другую модель (локальную или облачную)
-// и при необходимости изменить провайдера.
-// Например: {"agent":"admin", "model":"gpt-4o", "provider":"openai"}
-func updateAgentModelHandler(w http.ResponseWriter, r *http.Request) {
-	cid := r.Header.Get("X-Request-ID")
-	if r.Method != http.MethodPost {
-		apierror.MethodNotAllowed(w, cid)
-		return
-	}
-	var req UpdateModelRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		apierror.BadRequest(w, cid, "Невалидный JSON", "")
-		return
-	}
-	if req.Agent == "" || req.Model == "" {
-		apierror.BadRequest(w, cid, "Требуются agent и model", "")
-		return
-	}
-
-	var agent models.Agent
-	if err := db.DB.Where("name = ?", req.Agent).First(&agent).Error; err != nil {
-		apierror.NotFound(w, cid, "Агент не найден")
-		return
-	}
-
-	agent.LLMModel = req.Model
-	if req.Provider != "" {
-		agent.Provider = req.Provider
+	var ws models.Workspace
+	if err := db.DB.First(&ws, *id).Error; err != nil {
+		if requested != nil {
+			return nil, err
+		}
+		slog.Warn("Рабочее пространство не найдено", slog.Uint64("workspace_id", uint64(*id)), slog.String("агент", agent.Name))
+		return nil, nil
 	}
-	if err := db.DB.Save(&agent).Error; err != nil {
-		apierror.InternalError(w, cid, "Не удалось обновить агента", "")
-		return
+	if ws.Path == "" {
+		return nil, nil
 	}
-
-	w.Header().Set("Content-Type", "application/json")
-	writeJSON(w, map[string]string{"status": "ok"})
+	return &ws, nil
 }
 
-// avatarUploadHandler — загрузка аватара агента (POST /avatar?agent=...).
-// Принимает multipart/form-data с файлом изображения (до 10 МБ).
-// Сохраняет файл в uploads/avatars/{agent}_{filename} и обновляет поле Avatar в БД.
-// Файлы раздаются через /uploads/avatars/ как статика.
-func avatarUploadHandler(w http.ResponseWriter, r *http.Request) {
-	cid := r.Header.Get("X-Request-ID")
-	if r.Method != http.MethodPost {
-		apierror.MethodNotAllowed(w, cid)
-		return
-	}
-
-	agentName := r.URL.Query().Get("agent")
-	if agentName == "" {
-		apierror.BadRequest(w, cid, "Требуется параметр agent", "")
-		return
-	}
-
-	err := r.ParseMultipartForm(10 << 20)
-	if err != nil {
-		apierror.BadRequest(w, cid, "Не удалось разобрать multipart form", "")
-		return
-	}
-	file, handler, err := r.FormFile("file")
-	if err != nil {
-		apierror.BadRequest(w, cid, "Файл не предоставлен", "")
-		return
-	}
-	defer file.Close()
-
-	uploadDir := filepath.Join("uploads", "avatars")
-	if err := os.MkdirAll(uploadDir, 0755); err != nil {
-		apierror.InternalError(w, cid, "Не удалось создать директорию", "")
-		return
-	}
-
-	filename := agentName + "_" + handler.Filename
-	dst := filepath.Join(uploadDir, filename)
+// envVarsKey — ключ контекста с переменными окружения инструментов запроса.
+type envVarsKey struct{}
 
-	out, err := os.Create(dst)
-	if err != nil {
-		apierror.InternalError(w, cid, "Не удалось сохранить файл", "")
-		return
-	}
-	defer out.Close()
-	if _, err := io.Copy(out, file); err != nil {
-		apierror.InternalError(w, cid, "Не удалось скопировать файл", "")
-		return
-	}
-	slog.Info("Аватар сохранён", slog.String("путь", dst))
+// envVarsFrom — переменные окружения из контекста (nil — не заданы).
+func envVarsFrom(ctx context.Context) *envvars.Set {
+	env, _ := ctx.Value(envVarsKey{}).(*envvars.Set)
+	return env
+}
 
-	agent, err := repository.GetAgentByName(agentName)
-	if err != nil {
-		apierror.NotFound(w, cid, "Агент не найден")
-		return
+// loadEnvVars — переменные пространства ws и диалога conv; переменные
+// диалога перекрывают одноимённые переменные пространства.
+func loadEnvVars(ws *models.Workspace, conv *models.Chat) *envvars.Set {
+	var wsVars, chatVars []models.EnvVar
+	if ws != nil {
+		db.DB.Where("workspace_id = ?", ws.ID).Find(&wsVars)
 	}
-	agent.Avatar = filename
-	if err := db.DB.Save(agent).Error; err != nil {
-		apierror.InternalError(w, cid, "Не удалось обновить аватар", "")
-		return
+	if conv != nil {
+		db.DB.Where("chat_id = ?", conv.ID).Find(&chatVars)
 	}
-
-	w.Header().Set("Content-Type", "application/json")
-	writeJSON(w, map[string]string{"status": "ok"})
+	return envvars.Build(wsVars, chatVars)
 }
 
-// avatarGetHandler — получение информации об аватаре агента (GET /avatar-info?agent=...).
-// Возвращает JSON с именем файла аватара или 404, если аватар не загружен.
-func avatarGetHandler(w http.ResponseWriter, r *http.Request) {
-	cid := r.Header.Get("X-Request-ID")
-	agentName := r.URL.Query().Get("agent")
-	if agentName == "" {
-		apierror.BadRequest(w, cid, "Требуется параметр agent", "")
-		return
-	}
-	agent, err := repository.GetAgentByName(agentName)
-	if err != nil {
-		apierror.NotFound(w, cid, "Агент не найден")
-		return
-	}
-	if agent.Avatar == "" {
-		w.WriteHeader(http.StatusNotFound)
-		return
-	}
-	w.Header().Set("Content-Type", "application/json")
-	writeJSON(w, map[string]string{"avatar": agent.Avatar})
+// artifactFiles — хранилище файлов, созданных инструментами (ARTIFACTS_DIR).
+func artifactFiles() *artifact.Files {
+	cfg := config.Current()
+	return &artifact.Files{Dir: cfg.ArtifactsDir, MaxBytes: cfg.MaxUploadBytes}
 }
 
-// rootHandler — обработчик корневого пути (GET /).
-// Возвращает список агентов с их настройками в формате JSON.
-// Используется для проверки работоспособности сервиса и отладки.
-// Обрабатывает только точный путь "/" — для остальных возвращает 404.
-func rootHandler(w http.ResponseWriter, r *http.Request) {
-	cid := r.Header.Get("X-Request-ID")
-	if r.Method != http.MethodGet {
-		apierror.MethodNotAllowed(w, cid)
-		return
-	}
-	if r.URL.Path != "/" {
-		apierror.NotFound(w, cid, "Маршрут не найден")
-		return
-	}
-	var agents []models.Agent
-	if err := db.DB.Find(&agents).Error; err != nil {
-		apierror.InternalError(w, cid, err.Error(), "")
-		return
-	}
-	var result []map[string]interface{}
-	for _, a := range agents {
-		result = append(result, map[string]interface{}{
-			"name":          a.Name,
-			"model":         a.LLMModel,
-			"provider":      a.Provider,
-			"supportsTools": a.SupportsTools,
-			"avatar":        a.Avatar,
-			"prompt_file":   a.CurrentPromptFile,
-			"prompt":        a.Prompt,
-		})
+// artifactSigner — подпись ссылок на артефакты (nil — ARTIFACT_URL_SECRET не задан).
+func artifactSigner() *artifact.Signer {
+	secret := config.Current().ArtifactURLSecret
+	if secret == "" {
+		return nil
 	}
-	w.Header().Set("Content-Type", "application/json")
-	writeJSON(w, result)
+	return &artifact.Signer{Secret: []byte(secret)}
 }
 
-// writeJSON — вспомогательная функция для JSON-ответов.
-// Кодирует значение v в JSON и записывает в ResponseWriter.
-// При ошибке кодирования логирует ошибку (не паникует).
-// Заголовок Content-Type должен быть установлен вызывающей функцией.
-func truncate(s string, maxLen int) string {
-	if len(s) <= maxLen {
-		return s
+// artifactDownloadURL — ссылка на скачивание артефакта; при заданном ключе
+// подписи — подписанная на ARTIFACT_URL_TTL.
+func artifactDownloadURL(id uint) string {
+	if s := artifactSigner(); s != nil {
+		u, _ := s.URL(id, config.Current().ArtifactURLTTL, time.Now())
+		return u
 	}
-	return s[:maxLen] + "..."
+	return fmt.Sprintf("/artifacts/%d/download", id)
 }
 
-func writeJSON(w http.ResponseWriter, v interface{}) {
-	if err := json.NewEncoder(w).Encode(v); err != nil {
-		slog.Error("Ошибка JSON кодирования", slog.String("ошибка", err.Error()))
-	}
+// ArtifactLink — созданный файл в результате инструмента: модель может
+// сослаться на него, интерфейс — показать.
+type ArtifactLink struct {
+	ID   uint   `json:"id"`
+	Name string `json:"name"`
+	URL  string `json:"url"`
 }
 
-// saveChatMessages — сохранение пары сообщений (пользователь + агент) в PostgreSQL.
-// Используется после каждого успешного ответа от LLM для персистентной истории чатов.
-//
-// Порядок действий:
-//  1. Поиск агента в БД по имени (для привязки сообщений к агенту через AgentID)
-//  2. Создание записи сообщения пользователя (role: user)
-//  3. Создание записи ответа ассистента (role: assistant)
-//
-// При ошибке — логирует предупреждение, но не прерывает работу.
-func saveChatMessages(agentName string, userMessage llm.Message, response string) {
-	var agent models.Agent
-	if err := db.DB.Where("name = ?", agentName).First(&agent).Error; err != nil {
-		slog.Error("Не удалось найти агента для сохранения чата", slog.String("ошибка", err.Error()))
-		return
+// saveToolArtifacts — копирует файлы, созданные инструментом, в хранилище
+// артефактов и регистрирует их: скриншоты и PDF браузера, файлы write,
+// артефакты run_code. Ошибка копирования не влияет на результат инструмента.
+func saveToolArtifacts(ctx context.Context, agentName, toolName string, args, result map[string]interface{}) []ArtifactLink {
+	if result == nil || db.DB == nil {
+		return nil
 	}
-
-	role := userMessage.Role
-	if role == "" {
-		role = "user"
+	if _, failed := result["error"]; failed {
+		return nil
 	}
-	dbMsg := models.Message{
-		Role:    role,
-		Content: userMessage.Content,
-		AgentID: agent.ID,
+	type source struct {
+		name string
+		open func() (io.ReadCloser, error)
 	}
-	if err := db.DB.Create(&dbMsg).Error; err != nil {
-		slog.Error("Не удалось сохранить сообщение пользователя", slog.String("ошибка", err.Error()))
+	var sources []source
+	switch toolName {
+	case "browser_screenshot", "browser_pdf":
+		path, _ := result["file_path"].(string)
+		if path == "" {
+			return nil
+		}
+		sources = append(sources, source{path, func() (io.ReadCloser, error) { return os.Open(path) }})
+	case "write":
+		path, _ := args["path"].(string)
+		content, ok := args["content"].(string)
+		if path == "" || !ok {
+			return nil
+		}
+		sources = append(sources, source{path, func() (io.ReadCloser, error) { return io.NopCloser(strings.NewReader(content)), nil }})
+	case "run_code":
+		runID, _ := result["run_id"].(string)
+		items, _ := result["artifacts"].([]interface{})
+		for _, it := range items {
+			m, _ := it.(map[string]interface{})
+			name, _ := m["name"].(string)
+			if runID == "" || name == "" {
+				continue
+			}
+			sources = append(sources, source{name, func() (io.ReadCloser, error) { return fetchRunArtifact(ctx, runID, name) }})
+		}
+	default:
+		return nil
 	}
 
-	assistantMsg := models.Message{
-		Role:    "assistant",
-		Content: response,
-		AgentID: agent.ID,
-	}
-	if err := db.DB.Create(&assistantMsg).Error; err != nil {
-		slog.Error("Не удалось сохранить сообщение ассистента", slog.String("ошибка", err.Error()))
+	files := artifactFiles()
+	var links []ArtifactLink
+	for _, src := range sources {
+		rc, err := src.open()
+		if err != nil {
+			slog.Warn("Файл инструмента недоступен", slog.String("инструмент", toolName), slog.String("файл", filepath.Base(src.name)), slog.String("ошибка", err.Error()))
+			continue
+		}
+		stored, err := files.Save(src.name, rc)
+		rc.Close()
+		if err != nil {
+			slog.Warn("Не удалось сохранить артефакт", slog.String("инструмент", toolName), slog.String("файл", filepath.Base(src.name)), slog.String("ошибка", err.Error()))
+			continue
+		}
+		mimeType := artifact.MimeType(src.name)
+		rec := models.Artifact{
+			ChatID:     conversationFrom(ctx),
+			AgentName:  agentName,
+			Tool:       toolName,
+			Name:       filepath.Base(src.name),
+			MimeType:   mimeType,
+			Kind:       artifact.Kind(mimeType),
+			Size:       stored.Size,
+			SHA256:     stored.SHA256,
+			StorageKey: stored.Key,
+		}
+		if err := db.DB.Create(&rec).Error; err != nil {
+			files.Remove(stored.Key)
+			slog.Error("Не удалось зарегистрировать артефакт", slog.String("ошибка", err.Error()))
+			continue
+		}
+		links = append(links, ArtifactLink{ID: rec.ID, Name: rec.Name, URL: artifactDownloadURL(rec.ID)})
 	}
+	return links
 }
 
-// fetchModelLearnings — получение релевантных знаний модели из memory-service.
-// Вызывается перед каждым запросом к LLM. Найденные знания добавляются
-// к системному промпту, обогащая контекст модели накопленными знаниями.
-//
-// Система обучения работает следующим образом:
-//  1. Перед отправкой запроса к LLM берётся последнее сообщение пользователя
-//  2. По нему выполняется семантический поиск в базе знаний модели (ChromaDB)
-//  3. Найденные релевантные знания добавляются к системному промпту
-//  4. Модель получает обогащённый контекст и может давать более точные ответы
-//
-// Параметры:
-//   - modelName: имя модели LLM (например, "llama3.1:8b")
-//   - query: текст запроса для семантического поиска (последнее сообщение пользователя)
-//
-// Возвращает:
-//   - []string: список релевантных знаний (может быть пустым)
-func fetchModelLearnings(modelName string, query string) []string {
-	memoryURL := getEnv("MEMORY_SERVICE_URL", "http://localhost:8001")
-
-	reqBody := map[string]interface{}{
-		"query":      query,
-		"model_name": modelName,
-		"top_k":      5,
-	}
-	data, err := json.Marshal(reqBody)
+// fetchRunArtifact — файл, созданный run_code, из tools-service.
+func fetchRunArtifact(ctx context.Context, runID, name string) (io.ReadCloser, error) {
+	u := config.Current().ToolsServiceURL + "/run-code/artifact?" + url.Values{"run_id": {runID}, "name": {name}}.Encode()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
 	if err != nil {
-		slog.Error("Ошибка сериализации запроса знаний", slog.String("ошибка", err.Error()))
-		return nil
+		return nil, err
 	}
-
-	resp, err := http.Post(memoryURL+"/learnings/search", "application/json", bytes.NewReader(data))
+	if token := config.Current().ToolsServiceToken; token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := httpclient.New("tools", 0).Do(req)
 	if err != nil {
-		slog.Error("Ошибка запроса знаний из memory-service", slog.String("ошибка", err.Error()))
-		return nil
+		return nil, err
 	}
-	defer resp.Body.Close()
-
 	if resp.StatusCode != http.StatusOK {
-		slog.Warn("memory-service вернул ошибку при поиске знаний", slog.Int("статус", resp.StatusCode))
-		return nil
-	}
-
-	var result struct {
-		Results []struct {
-			Text     string                 `json:"text"`
-			Score    float64                `json:"score"`
-			Source   string                 `json:"source"`
-			Metadata map[string]interface{} `json:"metadata"`
-		} `json:"results"`
-		Count     int    `json:"count"`
-		ModelName string `json:"model_name"`
-	}
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		slog.Error("Ошибка декодирования ответа знаний", slog.String("ошибка", err.Error()))
-		return nil
+		resp.Body.Close()
+		return nil, fmt.Errorf("tools-service HTTP %d", resp.StatusCode)
 	}
+	return resp.Body, nil
+}
 
-	if len(result.Results) > 0 {
-		slog.Info("Знания найдены", slog.Int("количество", len(result.Results)), slog.String("модель", modelName))
+// lintAfterEdit — замечания линтеров к только что изменённому файлу
+// (LINT_AFTER_EDIT). Ошибка проверки не отменяет правку, а попадает в результат.
+func lintAfterEdit(ctx context.Context, filePath string) interface{} {
+	report, err := callToolCtx(ctx, "lint_code", map[string]interface{}{"path": filePath})
+	if err != nil {
+		return map[string]interface{}{"error": err.Error()}
 	}
-	texts := make([]string, 0, len(result.Results))
-	for _, r := range result.Results {
-		texts = append(texts, r.Text)
+	if msg, ok := report["error"].(string); ok {
+		return map[string]interface{}{"error": msg}
 	}
-	return texts
+	return map[string]interface{}{"findings": report["findings"], "ran": report["ran"]}
 }
 
-// fetchRelevantSkills — получение релевантных навыков из Skill Engine.
-// Вызывается в chat pipeline перед запросом к LLM для обогащения контекста.
-// Навыки содержат цель, шаги, примеры и ограничения — структурированные знания,
-// которые помогают модели решать задачи точнее.
+// dispatchTool — единый диспетчер выполнения инструментов.
+// Централизует логику маршрутизации tool calls для всех форматов (structured, JSON, XML).
+// Обрабатывает специальные инструменты (configure_agent, get_agent_info и др.)
+// и делегирует остальные в tools-service через callTool().
+//
+// Параметры:
+//   - agentName: имя текущего агента (для проверки прав доступа)
+//   - toolName: имя вызываемого инструмента
+//   - args: аргументы инструмента
+//   - history: история сообщений (для делегирования задач другим агентам)
+func dispatchTool(ctx context.Context, agentName, toolName string, args map[string]interface{}, history []llm.Message) map[string]interface{} {
+	dispatchStart := time.Now()
+	slog.Info("[DISPATCH] начало",
+		slog.String("агент", agentName),
+		slog.String("инструмент", toolName),
+		slog.Any("параметры", sanitizeArgs(args)),
+	)
+	var result map[string]interface{}
+	defer func() {
+		outcome := "success"
+		if _, hasErr := result["error"]; hasErr {
+			outcome = "error"
+		}
+		slog.Info("[DISPATCH] завершён",
+			slog.String("агент", agentName),
+			slog.String("инструмент", toolName),
+			slog.Duration("длительность", time.Since(dispatchStart)),
+			slog.String("outcome", outcome),
+		)
+	}()
+	if r, ok := recipes.Get(toolName); ok && recipes.Enabled(toolName, agentName) {
+		result = runRecipe(ctx, agentName, r, args)
+		return result
+	}
+	switch toolName {
+	case "configure_agent":
+		result = handleConfigureAgent(args)
+		return result
+	case "get_agent_info":
+		result = handleGetAgentInfo(args)
+		return result
+	case "list_models_for_role":
+		result = handleListModelsForRole(args)
+		return result
+	case "view_logs":
+		result = handleViewLogs(args)
+		return result
+	case "search_knowledge":
+		result = handleSearchKnowledge(ctx, args)
+		return result
+	case "get_artifact":
+		result = handleGetArtifact(args)
+		return result
+	case "write":
+		// Прежнее содержимое читается до записи, чтобы показать diff
+		filePath, _ := args["path"].(string)
+		newContent, _ := args["content"].(string)
+		oldContent, existed, readErr := readCurrent(ctx, filePath)
+		var backupID uint
+		if readErr == nil {
+			backupID = backupFile(ctx, agentName, toolName, filePath, oldContent, existed)
+		}
+		var callErr error
+		result, callErr = callToolCtx(ctx, toolName, args)
+		if callErr != nil {
+			result = map[string]interface{}{"error": callErr.Error()}
+			return result
+		}
+		if _, failed := result["error"]; !failed {
+			if readErr != nil {
+				noteNoRollback(result, readErr)
+				return result
+			}
+			attachDiff(ctx, result, agentName, toolName, filePath, oldContent, newContent, !existed)
+			if backupID != 0 {
+				result["backup_id"] = backupID
+			}
+		}
+		return result
+	case "delete":
+		filePath, _ := args["path"].(string)
+		var backupID uint
+		var readErr error
+		if config.Current().BackupEnabled {
+			var content string
+			var existed bool
+			if content, existed, readErr = readCurrent(ctx, filePath); existed {
+				backupID = backupFile(ctx, agentName, toolName, filePath, content, true)
+			}
+		}
+		var callErr error
+		result, callErr = callToolCtx(ctx, toolName, args)
+		if callErr != nil {
+			result = map[string]interface{}{"error": callErr.Error()}
+			return result
+		}
+		if _, failed := result["error"]; !failed {
+			if readErr != nil {
+				noteNoRollback(result, readErr)
+			} else if backupID != 0 {
+				result["backup_id"] = backupID
+			}
+		}
+		return result
+	case "debug_code":
+		filePath, _ := args["file_path"].(string)
+		cmdArgs, _ := args["args"].(string)
+		cmd := filePath
+		if cmdArgs != "" {
+			cmd = filePath + " " + cmdArgs
+		}
+		var callErr error
+		result, callErr = callToolCtx(ctx, "execute", map[string]interface{}{"command": cmd})
+		if callErr != nil {
+			result = map[string]interface{}{"error": callErr.Error()}
+		}
+		return result
+	case "edit_file":
+		filePath, _ := args["file_path"].(string)
+		oldText, _ := args["old_text"].(string)
+		newText, _ := args["new_text"].(string)
+		readResult, readErr := callToolCtx(ctx, "read", map[string]interface{}{"path": filePath})
+		if readErr != nil {
+			result = map[string]interface{}{"error": readErr.Error()}
+			return result
+		}
+		content, _ := readResult["content"].(string)
+		if !strings.Contains(content, oldText) {
+			result = map[string]interface{}{"error": "old_text не найден в файле"}
+			return result
+		}
+		newContent := strings.Replace(content, oldText, newText, 1)
+		backupID := backupFile(ctx, agentName, toolName, filePath, content, true)
+		result, readErr = callToolCtx(ctx, "write", map[string]interface{}{"path": filePath, "content": newContent})
+		if readErr != nil {
+			result = map[string]interface{}{"error": readErr.Error()}
+			return result
+		}
+		attachDiff(ctx, result, agentName, toolName, filePath, content, newContent, false)
+		if backupID != 0 {
+			result["backup_id"] = backupID
+		}
+		if config.Current().LintAfterEdit {
+			result["lint"] = lintAfterEdit(ctx, filePath)
+		}
+		return result
+
+	// ============================================================================
+	// Универсальные LEGO-блоки (compound skills)
+	// Каждый скил выполняет цепочку базовых инструментов за один вызов.
+	// Умная модель (7B+) предпочтёт базовые инструменты.
+	// Слабая модель (3B) вызовет один составной скил и получит готовый результат.
+	// ============================================================================
+
+	// БЛОК 1: Системные
+	case "full_system_report":
+		result = handleFullSystemReport(ctx)
+		return result
+	case "check_stack":
+		result = handleCheckStack(ctx, args)
+		return result
+	case "diagnose_service":
+		result = handleDiagnoseService(ctx, args)
+		return result
+	case "security_audit":
+		result = handleSecurityAudit(ctx, args)
+		return result
+
+	case "web_research":
+		result = handleWebResearch(ctx, args)
+		return result
+	case "check_resources_batch":
+		result = handleCheckResourcesBatch(ctx, args)
+		return result
+
+	case "generate_report":
+		result = handleGenerateReport(ctx, args)
+		return result
+	case "import_issue":
+		issueURL, _ := args["url"].(string)
+		task, err := importIssue(ctx, issueURL, agentName)
+		if err != nil {
+			return map[string]interface{}{"error": err.Error()}
+		}
+		return map[string]interface{}{
+			"success": true,
+			"task_id": task.ID,
+			"issue":   task.IssueKey,
+			"title":   task.Title,
+			"summary": task.Summary,
+			"plan":    strings.Split(task.Plan, "\n"),
+			"message": "Задача добавлена в план; её описание будет в контексте следующих запросов",
+		}
+	case "git_pull_request":
+		result = handleGitPullRequest(ctx, agentName, args)
+		return result
+	case "home_sensor", "home_switch", "home_scene":
+		result = handleSmartHome(ctx, toolName, args)
+		return result
+	case "k8s_pods", "k8s_deployments", "k8s_events", "k8s_describe", "k8s_logs":
+		result = handleKube(ctx, toolName, args)
+		return result
+	case "ollama_pull", "ollama_delete", "ollama_ps":
+		result = handleOllamaTool(ctx, toolName, args)
+		return result
+	case "create_script":
+		result = handleCreateScript(ctx, args)
+		return result
+
+	case "run_commands":
+		result = handleRunCommands(ctx, args)
+		return result
+	case "setup_cron_job":
+		result = handleSetupCronJob(ctx, args)
+		return result
+	case "setup_git_automation":
+		result = handleSetupGitAutomation(ctx, args)
+		return result
+	case "project_init":
+		result = handleProjectInit(ctx, args)
+		return result
+
+	case "install_packages":
+		result = handleInstallPackages(ctx, args)
+		return result
+
+	default:
+		var callErr error
+		result, callErr = callToolCtx(ctx, toolName, args)
+		if callErr != nil {
+			slog.Error("[TOOL-CALL] ошибка вызова инструмента", slog.String("инструмент", toolName), slog.String("ошибка", callErr.Error()))
+			result = map[string]interface{}{"error": callErr.Error()}
+		}
+		return result
+	}
+}
+
+// handleConfigureAgent — обработчик инструмента configure_agent.
+// Позволяет настраивать агента Admin: менять модель, провайдера, промпт.
+//
+// Параметры (из args):
+//   - agent_name (обязательный): имя агента для настройки
+//   - model (опциональный): новая модель
+//   - provider (опциональный): новый провайдер
+//   - prompt (опциональный): новый системный промпт
+func handleConfigureAgent(args map[string]interface{}) map[string]interface{} {
+	agentName, ok := args["agent_name"].(string)
+	if !ok || agentName == "" {
+		return map[string]interface{}{"error": "agent_name обязателен"}
+	}
+
+	var agent models.Agent
+	if err := db.DB.Where("name = ?", agentName).First(&agent).Error; err != nil {
+		return map[string]interface{}{"error": "Агент не найден: " + agentName}
+	}
+
+	changes := []string{}
+
+	if model, ok := args["model"].(string); ok && model != "" {
+		agent.LLMModel = model
+		changes = append(changes, "модель: "+model)
+	}
+	if provider, ok := args["provider"].(string); ok && provider != "" {
+		agent.Provider = provider
+		changes = append(changes, "провайдер: "+provider)
+	}
+	if prompt, ok := args["prompt"].(string); ok && prompt != "" {
+		agent.Prompt = prompt
+		changes = append(changes, "промпт обновлён")
+	}
+	if enabled, ok := args["learnings_enabled"].(bool); ok {
+		agent.LearningsEnabled = enabled
+		changes = append(changes, fmt.Sprintf("накопленные знания: %v", enabled))
+	}
+
+	if len(changes) == 0 {
+		return map[string]interface{}{"error": "Не указаны параметры для изменения (model, provider, prompt, learnings_enabled)"}
+	}
+
+	if err := db.DB.Save(&agent).Error; err != nil {
+		return map[string]interface{}{"error": "Ошибка сохранения: " + err.Error()}
+	}
+
+	return map[string]interface{}{
+		"status":  "ok",
+		"agent":   agentName,
+		"changes": changes,
+		"message": "Агент " + agentName + " успешно настроен",
+	}
+}
+
+// handleGetAgentInfo — обработчик инструмента get_agent_info.
+// Возвращает полную информацию об агенте: модель, провайдер, промпт, поддержка инструментов.
+func handleGetAgentInfo(args map[string]interface{}) map[string]interface{} {
+	agentName, ok := args["agent_name"].(string)
+	if !ok || agentName == "" {
+		return map[string]interface{}{"error": "agent_name обязателен"}
+	}
+
+	var agent models.Agent
+	if err := db.DB.Where("name = ?", agentName).First(&agent).Error; err != nil {
+		return map[string]interface{}{"error": "Агент не найден: " + agentName}
+	}
+
+	return map[string]interface{}{
+		"name":           agent.Name,
+		"model":          agent.LLMModel,
+		"provider":       agent.Provider,
+		"supports_tools": agent.SupportsTools,
+		"learnings":      agent.LearningsEnabled,
+		"tools_enabled":  agent.ToolsEnabled,
+		"prompt":         agent.Prompt,
+		"prompt_file":    agent.CurrentPromptFile,
+		"avatar":         agent.Avatar,
+	}
+}
+
+// handleListModelsForRole — обработчик инструмента list_models_for_role.
+// Возвращает доступные модели с рекомендациями для указанной роли,
+// ранжированные по убыванию оценки (пакет modelrank): статическая пригодность
+// для роли (suitable, note) дополняется замером /models/benchmark, фактической
+// успешностью инструментов и задержкой ответов роли и оценками пользователей.
+// recommended — лучшая подходящая модель без пометки flagged.
+func handleListModelsForRole(args map[string]interface{}) map[string]interface{} {
+	role, ok := args["role"].(string)
+	if !ok || role == "" {
+		return map[string]interface{}{"error": "role обязателен (admin)"}
+	}
+
+	ollamaModels, err := repository.GetOllamaModels()
+	if err != nil {
+		return map[string]interface{}{"error": "Не удалось получить список моделей: " + err.Error()}
+	}
+
+	type modelRec struct {
+		modelrank.Ranked
+		Suitable bool   `json:"suitable"`
+		Note     string `json:"note"`
+		Family   string `json:"family"`
+		Size     string `json:"size"`
+		// Flagged — модель стабильно получает 👎 от пользователей в этой роли
+		Flagged bool `json:"flagged,omitempty"`
+	}
+	recs := make(map[string]*modelRec, len(ollamaModels))
+	candidates := make([]modelrank.Candidate, 0, len(ollamaModels))
+
+	// Оценки пользователей и фактическая работа моделей: роль совпадает с именем агента
+	ratings, err := feedback.ForRole(db.DB, role)
+	if err != nil {
+		slog.Warn("Не удалось получить оценки моделей", slog.String("роль", role), slog.String("ошибка", err.Error()))
+	}
+	usage, err := modelrank.UsageForRole(db.DB, role)
+	if err != nil {
+		slog.Warn("Не удалось получить статистику ответов моделей", slog.String("роль", role), slog.String("ошибка", err.Error()))
+	}
+	benchmarks := repository.LatestBenchmarks()
+
+	for _, m := range ollamaModels {
+		c := modelrank.Candidate{Name: m}
+		if b, ok := benchmarks[m]; ok {
+			c.Benchmark = &b
+		}
+		if u, ok := usage[m]; ok {
+			c.Usage = &u
+		}
+		if st, ok := ratings[m]; ok {
+			c.Feedback = &st
+		}
+		candidates = append(candidates, c)
+
+		fullInfo, infoErr := repository.GetModelFullInfo(m)
+		if infoErr != nil {
+			recs[m] = &modelRec{Note: "Ошибка получения информации"}
+			continue
+		}
+		var roles []string
+		var notes map[string]string
+		json.Unmarshal([]byte(fullInfo.SuitableRoles), &roles)
+		json.Unmarshal([]byte(fullInfo.RoleNotes), &notes)
+
+		suitable := false
+		for _, r := range roles {
+			if r == role {
+				suitable = true
+				break
+			}
+		}
+		candidates[len(candidates)-1].Suitable = suitable
+		rec := &modelRec{
+			Suitable: suitable,
+			Note:     notes[role],
+			Family:   fullInfo.Family,
+			Size:     fullInfo.ParameterSize,
+		}
+		if st, ok := ratings[m]; ok && st.Flagged {
+			rec.Flagged = true
+			warn := fmt.Sprintf("Пользователи недовольны ответами в этой роли: 👎 %d из %d", st.Down, st.Total)
+			if rec.Note != "" {
+				rec.Note += ". " + warn
+			} else {
+				rec.Note = warn
+			}
+		}
+		recs[m] = rec
+	}
+
+	result := make([]modelRec, 0, len(ollamaModels))
+	var recommended string
+	for _, ranked := range modelrank.Rank(candidates) {
+		rec := recs[ranked.Name]
+		rec.Ranked = ranked
+		if recommended == "" && rec.Suitable && !rec.Flagged {
+			recommended = ranked.Name
+		}
+		result = append(result, *rec)
+	}
+
+	return map[string]interface{}{
+		"role":        role,
+		"models":      result,
+		"recommended": recommended,
+	}
+}
+
+// healthHandler — проверка состояния сервиса (GET /health).
+// Возвращает JSON {"status":"ok","service":"agent-service"} для мониторинга.
+func healthHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if drainer.Draining() {
+		// Балансировщик должен перестать слать сюда трафик, пока дорабатывают активные чаты
+		w.WriteHeader(http.StatusServiceUnavailable)
+		fmt.Fprintf(w, `{"status":"draining","service":"agent-service","active_chats":%d}`, drainer.Active())
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(`{"status":"ok","service":"agent-service"}`))
+}
+
+// feedbackHandler — оценка ответа агента (POST /feedback).
+// Тело: {"message_id": 42, "rating": "up"|"down"|1|-1, "comment": "..."}.
+// Агент, модель и провайдер берутся из оцениваемого сообщения. Отрицательная
+// оценка с комментарием передаётся в систему обучения модели (категория feedback).
+func feedbackHandler(w http.ResponseWriter, r *http.Request) {
+	cid := r.Header.Get("X-Request-ID")
+	if r.Method != http.MethodPost {
+		apierror.MethodNotAllowed(w, cid)
+		return
+	}
+	var req struct {
+		MessageID uint        `json:"message_id"`
+		Rating    interface{} `json:"rating"`
+		Comment   string      `json:"comment"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		apierror.BadRequest(w, cid, "Некорректный JSON", "")
+		return
+	}
+	if req.MessageID == 0 {
+		apierror.BadRequest(w, cid, "message_id обязателен", "ID ответа возвращается в поле message_id ответа /chat")
+		return
+	}
+	rating, err := feedback.ParseRating(req.Rating)
+	if err != nil {
+		apierror.BadRequest(w, cid, err.Error(), "")
+		return
+	}
+
+	var msg models.Message
+	if err := db.DB.Preload("Agent").First(&msg, req.MessageID).Error; err != nil {
+		apierror.NotFound(w, cid, "Сообщение не найдено")
+		return
+	}
+	if msg.Role != "assistant" {
+		apierror.BadRequest(w, cid, "Оценивать можно только ответы агента", "")
+		return
+	}
+
+	fb := models.MessageFeedback{
+		MessageID: msg.ID,
+		AgentName: msg.Agent.Name,
+		ModelName: msg.LLMModel,
+		Provider:  msg.Provider,
+		Rating:    rating,
+		Comment:   strings.TrimSpace(req.Comment),
+	}
+	if err := db.DB.Create(&fb).Error; err != nil {
+		slog.Error("Не удалось сохранить оценку", slog.String("ошибка", err.Error()), slog.String("request_id", cid))
+		apierror.InternalError(w, cid, "Ошибка сохранения оценки", "")
+		return
+	}
+	slog.Info("Оценка ответа", slog.Uint64("сообщение", uint64(msg.ID)), slog.Int("оценка", rating), slog.String("агент", fb.AgentName), slog.String("модель", fb.ModelName), slog.String("request_id", cid))
+
+	if rating < 0 && fb.Comment != "" && fb.ModelName != "" {
+		go storeFeedbackLearning(fb, msg.Content)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	writeJSON(w, fb)
+}
+
+// feedbackStatsHandler — сводка оценок по агентам и моделям (GET /feedback/stats).
+// Фильтры: ?agent=coder&model=qwen2.5:7b. Поле flagged отмечает модели,
+// которые стабильно получают 👎 в роли агента.
+func feedbackStatsHandler(w http.ResponseWriter, r *http.Request) {
+	cid := r.Header.Get("X-Request-ID")
+	if r.Method != http.MethodGet {
+		apierror.MethodNotAllowed(w, cid)
+		return
+	}
+	stats, err := feedback.Stats(db.DB, feedback.Filter{
+		AgentName: r.URL.Query().Get("agent"),
+		ModelName: r.URL.Query().Get("model"),
+	})
+	if err != nil {
+		slog.Error("Ошибка статистики оценок", slog.String("ошибка", err.Error()), slog.String("request_id", cid))
+		apierror.InternalError(w, cid, "Ошибка чтения оценок", "")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	writeJSON(w, map[string]interface{}{
+		"stats": stats,
+		"flag_rule": map[string]interface{}{
+			"min_votes":  feedback.FlagMinVotes,
+			"down_ratio": feedback.FlagDownRatio,
+		},
+	})
+}
+
+// usageHandler — расход токенов и состояние бюджетов (GET /usage).
+// ?period=daily|monthly (по умолчанию monthly) — за какой период суммировать
+// расход по агентам и ключам. key_id — отпечаток ключа вызывающего клиента.
+func usageHandler(w http.ResponseWriter, r *http.Request) {
+	cid := r.Header.Get("X-Request-ID")
+	if r.Method != http.MethodGet {
+		apierror.MethodNotAllowed(w, cid)
+		return
+	}
+	period := r.URL.Query().Get("period")
+	if period == "" {
+		period = budget.PeriodMonthly
+	}
+	if period != budget.PeriodDaily && period != budget.PeriodMonthly {
+		apierror.BadRequest(w, cid, "Неизвестный период "+period, "Допустимо: daily, monthly")
+		return
+	}
+	now := time.Now()
+	since := budget.PeriodStart(period, now)
+	byAgent, byKey, err := budget.Summary(db.DB, since)
+	if err != nil {
+		slog.Error("Ошибка сводки расхода", slog.String("ошибка", err.Error()), slog.String("request_id", cid))
+		apierror.InternalError(w, cid, "Ошибка чтения расхода", "")
+		return
+	}
+	var budgets []models.Budget
+	if err := db.DB.Order("scope, subject, period").Find(&budgets).Error; err != nil {
+		apierror.InternalError(w, cid, "Ошибка чтения бюджетов", "")
+		return
+	}
+	statuses := make([]budget.Status, 0, len(budgets))
+	for _, b := range budgets {
+		st, err := budget.StatusOf(db.DB, b, now)
+		if err != nil {
+			apierror.InternalError(w, cid, "Ошибка расчёта бюджета", "")
+			return
+		}
+		statuses = append(statuses, st)
+	}
+	writeJSON(w, map[string]interface{}{
+		"period":   period,
+		"since":    since,
+		"key_id":   requestKeyID(r),
+		"agents":   byAgent,
+		"keys":     byKey,
+		"budgets":  statuses,
+		"prices":   budgetPrices(),
+		"fallback": config.Current().BudgetFallbackModel,
+	})
+}
+
+// usageBudgetsHandler — бюджеты облачных моделей (/usage/budgets).
+// GET — список, POST — создать или изменить бюджет (scope, subject, period),
+// DELETE ?scope=&subject=&period= — удалить. Для scope=key вместо отпечатка
+// можно передать сам API-ключ — сохранится только отпечаток.
+func usageBudgetsHandler(w http.ResponseWriter, r *http.Request) {
+	cid := r.Header.Get("X-Request-ID")
+	switch r.Method {
+	case http.MethodGet:
+		var budgets []models.Budget
+		if err := db.DB.Order("scope, subject, period").Find(&budgets).Error; err != nil {
+			apierror.InternalError(w, cid, "Ошибка чтения бюджетов", "")
+			return
+		}
+		writeJSON(w, map[string]interface{}{"budgets": budgets})
+	case http.MethodPost:
+		var b models.Budget
+		if err := json.NewDecoder(r.Body).Decode(&b); err != nil {
+			apierror.BadRequest(w, cid, "Невалидный JSON", "Ожидается {scope, subject, period, token_limit, cost_limit, action}")
+			return
+		}
+		if err := budget.Validate(&b); err != nil {
+			apierror.BadRequest(w, cid, "Некорректный бюджет: "+err.Error(), "scope: agent|key, period: daily|monthly, action: refuse|fallback")
+			return
+		}
+		var existing models.Budget
+		err := db.DB.Where("scope = ? AND subject = ? AND period = ?", b.Scope, b.Subject, b.Period).First(&existing).Error
+		if err == nil {
+			b.Model = existing.Model
+		}
+		if err := db.DB.Save(&b).Error; err != nil {
+			slog.Error("Ошибка сохранения бюджета", slog.String("ошибка", err.Error()), slog.String("request_id", cid))
+			apierror.InternalError(w, cid, "Ошибка сохранения бюджета", "")
+			return
+		}
+		slog.Info("Бюджет сохранён",
+			slog.String("область", b.Scope),
+			slog.String("субъект", b.Subject),
+			slog.String("период", b.Period),
+			slog.Int64("токены", b.TokenLimit),
+			slog.Float64("стоимость", b.CostLimit),
+			slog.String("request_id", cid))
+		writeJSON(w, b)
+	case http.MethodDelete:
+		q := r.URL.Query()
+		b := models.Budget{Scope: q.Get("scope"), Subject: q.Get("subject"), Period: q.Get("period"), TokenLimit: 1}
+		if err := budget.Validate(&b); err != nil {
+			apierror.BadRequest(w, cid, "Некорректный бюджет: "+err.Error(), "Передайте scope, subject и period")
+			return
+		}
+		res := db.DB.Unscoped().Where("scope = ? AND subject = ? AND period = ?", b.Scope, b.Subject, b.Period).Delete(&models.Budget{})
+		if res.Error != nil {
+			apierror.InternalError(w, cid, "Ошибка удаления бюджета", "")
+			return
+		}
+		if res.RowsAffected == 0 {
+			apierror.NotFound(w, cid, "Бюджет не найден")
+			return
+		}
+		writeJSON(w, map[string]interface{}{"deleted": b.Scope + "/" + b.Subject + "/" + b.Period})
+	default:
+		apierror.MethodNotAllowed(w, cid)
+	}
+}
+
+// responseCacheHandler — кэш ответов LLM (/response-cache): GET — режим и число
+// записей, DELETE — очистить (например, после смены промпта агента).
+func responseCacheHandler(w http.ResponseWriter, r *http.Request) {
+	cid := r.Header.Get("X-Request-ID")
+	opt := responseCacheOptions()
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, map[string]interface{}{
+			"mode":        opt.Mode,
+			"ttl":         opt.TTL.String(),
+			"max_entries": opt.MaxEntries,
+			"similarity":  opt.Similarity,
+			"entries":     responseCache.Len(),
+		})
+	case http.MethodDelete:
+		n := responseCache.Clear()
+		slog.Info("Кэш ответов очищен", slog.Int("записей", n), slog.String("request_id", cid))
+		writeJSON(w, map[string]interface{}{"cleared": n})
+	default:
+		apierror.MethodNotAllowed(w, cid)
+	}
+}
+
+// storeFeedbackLearning — сохраняет комментарий к 👎 как знание модели,
+// чтобы в следующих ответах модель учитывала, чем был недоволен пользователь.
+func storeFeedbackLearning(fb models.MessageFeedback, answer string) {
+	reqBody := map[string]interface{}{
+		"text":       fmt.Sprintf("Пользователь оценил ответ негативно: %s. Ответ: %s", fb.Comment, truncate(answer, 300)),
+		"model_name": fb.ModelName,
+		"agent_name": fb.AgentName,
+		"category":   "feedback",
+		"metadata": map[string]interface{}{
+			"source":     "user_feedback",
+			"message_id": fb.MessageID,
+			"rating":     fb.Rating,
+		},
+	}
+	data, err := json.Marshal(reqBody)
+	if err != nil {
+		return
+	}
+	resp, err := memoryClient.Post(config.Current().MemoryServiceURL+"/learnings", "application/json", bytes.NewReader(data))
+	if err != nil {
+		slog.Error("Ошибка передачи оценки в систему обучения", slog.String("ошибка", err.Error()))
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		slog.Warn("memory-service вернул ошибку при сохранении оценки", slog.Int("статус", resp.StatusCode))
+	}
+}
+
+// configHandler — текущая конфигурация сервиса без секретов (GET /config).
+// Поле reloadable перечисляет параметры, применяемые без перезапуска.
+func configHandler(w http.ResponseWriter, r *http.Request) {
+	cid := r.Header.Get("X-Request-ID")
+	if r.Method != http.MethodGet {
+		apierror.MethodNotAllowed(w, cid)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"config":     config.Current().Sanitized(),
+		"reloadable": []string{"rag_top_k", "rag_max_chunk_len", "rag_max_context_len", "learnings_enabled", "learnings_top_k", "learnings_min_score", "learnings_max_per_category", "learnings_token_budget", "episodic_enabled", "episodic_top_k", "episodic_min_score", "repo_map_budget", "lint_after_edit"},
+	})
+}
+
+// configReloadHandler — перечитывает конфигурацию (POST /config/reload), как и SIGHUP.
+// Некорректная конфигурация отклоняется с 400, действующая остаётся без изменений.
+func configReloadHandler(w http.ResponseWriter, r *http.Request) {
+	cid := r.Header.Get("X-Request-ID")
+	if r.Method != http.MethodPost {
+		apierror.MethodNotAllowed(w, cid)
+		return
+	}
+	res, err := reloadConfig()
+	if err != nil {
+		apierror.BadRequest(w, cid, "Конфигурация не применена: "+err.Error(), "Исправьте файл конфигурации или переменные окружения")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(res)
+}
+
+// reloadConfig — перезагрузка конфигурации с записью результата в лог.
+func reloadConfig() (config.ReloadResult, error) {
+	res, err := config.Reload()
+	if err != nil {
+		slog.Error("Перезагрузка конфигурации отклонена", slog.String("ошибка", err.Error()))
+		return res, err
+	}
+	slog.Info("Конфигурация перезагружена", slog.Any("применено", res.Applied), slog.Any("требует_перезапуска", res.RestartRequired))
+	return res, nil
+}
+
+// readinessChecks — проверки /ready. Обязательны: БД, хотя бы один LLM-провайдер,
+// tools-service и memory-service — без них чат не работает. Ollama и browser-service
+// отражаются в ответе, но не снимают сервис с балансировки: агенты могут
+// работать на облачных провайдерах и без браузера.
+func readinessChecks() []health.Check {
+	cfg := config.Current()
+	return []health.Check{
+		{Name: "draining", Required: true, Run: func(ctx context.Context) error {
+			if drainer.Draining() {
+				return fmt.Errorf("сервис останавливается, активных чатов: %d", drainer.Active())
+			}
+			return nil
+		}},
+		{Name: "database", Required: true, Run: db.Ping},
+		{Name: "database_pool", Run: db.PoolCheck},
+		{Name: "providers", Required: true, Run: func(ctx context.Context) error {
+			if len(llm.GlobalRegistry.List()) == 0 {
+				return fmt.Errorf("не зарегистрировано ни одного LLM-провайдера")
+			}
+			return nil
+		}},
+		health.HTTPCheck("tools-service", cfg.ToolsServiceURL+"/health", true),
+		health.HTTPCheck("memory-service", cfg.MemoryServiceURL+"/health", true),
+		health.HTTPCheck("browser-service", cfg.BrowserServiceURL+"/health", false),
+		health.HTTPCheck("ollama", cfg.OllamaURL+"/api/version", false),
+	}
+}
+
+// agentsHandler — получение списка всех агентов с их настройками (GET /agents).
+// Возвращает JSON-массив с информацией о каждом агенте:
+// имя, текущая модель, провайдер, поддержка инструментов, аватар, промпт.
+// Используется фронтендом для отображения карточек агентов в панели моделей.
+func agentsHandler(w http.ResponseWriter, r *http.Request) {
+	cid := r.Header.Get("X-Request-ID")
+	if r.Method != http.MethodGet {
+		apierror.MethodNotAllowed(w, cid)
+		return
+	}
+	var agents []models.Agent
+	if err := db.DB.Find(&agents).Error; err != nil {
+		apierror.InternalError(w, cid, err.Error(), "")
+		return
+	}
+	active := personaStore().ActiveByAgent(agents)
+	var result []map[string]interface{}
+	for _, a := range agents {
+		p := active[a.Name]
+		result = append(result, map[string]interface{}{
+			"name":          a.Name,
+			"model":         a.LLMModel,
+			"provider":      a.Provider,
+			"supportsTools": a.SupportsTools,
+			"learnings":     a.LearningsEnabled,
+			"toolsEnabled":  a.ToolsEnabled,
+			"avatar":        agentAvatar(&a, p),
+			"prompt_file":   a.CurrentPromptFile,
+			"prompt":        a.Prompt,
+			"persona":       personaName(p),
+		})
+	}
+	w.Header().Set("Content-Type", "application/json")
+	writeJSON(w, result)
+}
+
+// personaStore — образы агентов в текущей БД; промпты из файлов — в prompts/.
+func personaStore() *persona.Store {
+	return persona.NewStore(db.DB, filepath.Join(".", "prompts"))
+}
+
+// personaName — имя образа для ответа и истории (пусто — без образа).
+func personaName(p *models.Persona) string {
+	if p == nil {
+		return ""
+	}
+	return p.Name
+}
+
+// agentAvatar — аватар, который показывается агенту: аватар активного образа
+// p, если он задан, иначе собственный.
+func agentAvatar(agent *models.Agent, p *models.Persona) string {
+	if p != nil && p.Avatar != "" {
+		return p.Avatar
+	}
+	return agent.Avatar
+}
+
+// PersonaView — образ агента в ответах API: инструменты политики списком.
+type PersonaView struct {
+	models.Persona
+	Tools []string `json:"tools,omitempty"`
+}
+
+func personaView(p models.Persona) PersonaView {
+	return PersonaView{Persona: p, Tools: persona.SplitTools(p.Tools)}
+}
+
+// PersonaRequest — тело POST /agents/{name}/personas.
+type PersonaRequest struct {
+	Name        string   `json:"name"`
+	Prompt      string   `json:"prompt"`      // Текст промпта; пусто — промпт агента
+	PromptFile  string   `json:"prompt_file"` // Или файл prompts/{agent}/
+	FromAgent   bool     `json:"from_agent"`  // Взять текущий промпт агента (его файл или текст)
+	Temperature *float64 `json:"temperature"`
+	ToolPolicy  string   `json:"tool_policy"` // all (по умолчанию), none, allow, deny
+	Tools       []string `json:"tools"`       // Инструменты для allow и deny
+}
+
+// agentResourceHandler — образы агента (persona packs, пакет persona):
+//
+//	GET    /agents/{name}/persona              — активный образ и все сохранённые
+//	PUT    /agents/{name}/persona              — переключить {"name": "reviewer"} (или POST); пустое имя — без образа
+//	GET    /agents/{name}/personas             — сохранённые образы
+//	POST   /agents/{name}/personas             — сохранить образ (PersonaRequest), тот же name заменяет
+//	GET    /agents/{name}/personas/{persona}   — образ
+//	DELETE /agents/{name}/personas/{persona}   — удалить образ и его аватар
+//
+// Образ задаёт промпт, температуру и политику инструментов ответов агента;
+// аватар образа загружается через POST /avatar?agent={name}&persona={persona}.
+// Переключение действует со следующего сообщения, каждый ответ хранит имя
+// образа (поле persona в /chat и истории диалога).
+func agentResourceHandler(w http.ResponseWriter, r *http.Request) {
+	cid := r.Header.Get("X-Request-ID")
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/agents/"), "/"), "/")
+	if len(parts) < 2 || (parts[1] != "persona" && parts[1] != "personas") || (parts[1] == "persona" && len(parts) > 2) || len(parts) > 3 {
+		apierror.NotFound(w, cid, "Маршрут не найден")
+		return
+	}
+	agent, err := repository.GetAgentByName(parts[0])
+	if err != nil {
+		apierror.NotFound(w, cid, "Агент не найден")
+		return
+	}
+	store := personaStore()
+
+	switch {
+	case parts[1] == "persona" && r.Method == http.MethodGet:
+		list, err := store.List(agent.Name)
+		if err != nil {
+			apierror.InternalError(w, cid, "Ошибка чтения образов", "")
+			return
+		}
+		views := make([]PersonaView, len(list))
+		for i, p := range list {
+			views[i] = personaView(p)
+		}
+		var active *PersonaView
+		if p := store.Active(agent); p != nil {
+			v := personaView(*p)
+			active = &v
+		}
+		writeJSON(w, map[string]interface{}{"agent": agent.Name, "active": agent.Persona, "persona": active, "personas": views})
+
+	case parts[1] == "persona" && (r.Method == http.MethodPut || r.Method == http.MethodPost):
+		var req struct {
+			Name string `json:"name"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			apierror.BadRequest(w, cid, "Некорректный JSON", `Ожидается {"name": "имя образа"}`)
+			return
+		}
+		prev := agent.Persona
+		p, err := store.Switch(agent, strings.TrimSpace(req.Name))
+		if errors.Is(err, persona.ErrNotFound) {
+			apierror.NotFound(w, cid, "Образ не найден: "+req.Name)
+			return
+		}
+		if err != nil {
+			slog.Error("Ошибка переключения образа", slog.String("агент", agent.Name), slog.String("ошибка", err.Error()), slog.String("request_id", cid))
+			apierror.InternalError(w, cid, "Ошибка сохранения", "")
+			return
+		}
+		slog.Info("Образ агента переключён", slog.String("агент", agent.Name), slog.String("был", prev), slog.String("стал", agent.Persona))
+		var view *PersonaView
+		if p != nil {
+			v := personaView(*p)
+			view = &v
+		}
+		writeJSON(w, map[string]interface{}{"status": "ok", "agent": agent.Name, "active": agent.Persona, "previous": prev, "persona": view, "avatar": agentAvatar(agent, p)})
+
+	case len(parts) == 2 && r.Method == http.MethodGet:
+		list, err := store.List(agent.Name)
+		if err != nil {
+			apierror.InternalError(w, cid, "Ошибка чтения образов", "")
+			return
+		}
+		views := make([]PersonaView, len(list))
+		for i, p := range list {
+			views[i] = personaView(p)
+		}
+		writeJSON(w, map[string]interface{}{"agent": agent.Name, "active": agent.Persona, "personas": views})
+
+	case len(parts) == 2 && r.Method == http.MethodPost:
+		var req PersonaRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			apierror.BadRequest(w, cid, "Некорректный JSON", "")
+			return
+		}
+		p := models.Persona{
+			Agent:       agent.Name,
+			Name:        strings.TrimSpace(req.Name),
+			Prompt:      req.Prompt,
+			PromptFile:  req.PromptFile,
+			Temperature: req.Temperature,
+			ToolPolicy:  req.ToolPolicy,
+			Tools:       strings.Join(req.Tools, ","),
+		}
+		if req.FromAgent {
+			p.Prompt, p.PromptFile = agent.Prompt, agent.CurrentPromptFile
+		}
+		created, err := store.Save(&p)
+		if errors.Is(err, promptpack.ErrNotFound) {
+			apierror.NotFound(w, cid, "Файл промпта не найден: "+p.PromptFile)
+			return
+		}
+		if err != nil {
+			apierror.BadRequest(w, cid, err.Error(), "")
+			return
+		}
+		slog.Info("Образ агента сохранён", slog.String("агент", agent.Name), slog.String("образ", p.Name), slog.Bool("создан", created))
+		if created {
+			w.WriteHeader(http.StatusCreated)
+		}
+		writeJSON(w, personaView(p))
+
+	case len(parts) == 3 && (r.Method == http.MethodGet || r.Method == http.MethodDelete):
+		var p *models.Persona
+		if r.Method == http.MethodGet {
+			p, err = store.Get(agent.Name, parts[2])
+		} else {
+			p, err = store.Delete(agent.Name, parts[2])
+		}
+		if errors.Is(err, persona.ErrNotFound) {
+			apierror.NotFound(w, cid, "Образ не найден: "+parts[2])
+			return
+		}
+		if err != nil {
+			apierror.InternalError(w, cid, "Ошибка чтения образов", "")
+			return
+		}
+		if r.Method == http.MethodGet {
+			writeJSON(w, personaView(*p))
+			return
+		}
+		if err := avatar.Remove(filepath.Join("uploads", "avatars"), p.Avatar); err != nil {
+			slog.Warn("Не удалось удалить аватар образа", slog.String("файл", p.Avatar), slog.String("ошибка", err.Error()))
+		}
+		slog.Info("Образ агента удалён", slog.String("агент", agent.Name), slog.String("образ", p.Name))
+		writeJSON(w, map[string]interface{}{"status": "deleted", "agent": agent.Name, "name": p.Name})
+
+	default:
+		apierror.MethodNotAllowed(w, cid)
+	}
+}
+
+// modelsHandler — получение списка локальных моделей Ollama (GET /models).
+// Запрашивает список установленных моделей у Ollama, синхронизирует с БД
+// и возвращает полную информацию о каждой модели: поддержка инструментов,
+// семейство, размер, специализация на коде, подходящие роли и пояснения.
+//
+// Возвращает JSON-массив объектов ModelInfo для отображения в UI.
+// Вся информация определяется автоматически — никаких жёстких привязок.
+func modelsHandler(w http.ResponseWriter, r *http.Request) {
+	cid := r.Header.Get("X-Request-ID")
+	if r.Method != http.MethodGet {
+		apierror.MethodNotAllowed(w, cid)
+		return
+	}
+
+	ollamaModels, err := repository.GetOllamaModels()
+	if err != nil {
+		apierror.InternalError(w, cid, "Не удалось получить модели", err.Error())
+		return
+	}
+
+	if err := repository.SyncModels(ollamaModels); err != nil {
+		apierror.InternalError(w, cid, "Не удалось синхронизировать модели", err.Error())
+		return
+	}
+
+	type ModelInfo struct {
+		Name          string            `json:"name"`
+		SupportsTools bool              `json:"supportsTools"`
+		Family        string            `json:"family"`
+		ParameterSize string            `json:"parameterSize"`
+		IsCodeModel   bool              `json:"isCodeModel"`
+		SuitableRoles []string          `json:"suitableRoles"`
+		RoleNotes     map[string]string `json:"roleNotes"`
+		// Результаты эмпирической проверки (нет probedAt — проверка ещё идёт или отключена)
+		ToolCallFormat string     `json:"toolCallFormat,omitempty"`
+		SupportsJSON   bool       `json:"supportsJSON"`
+		ContextLength  int        `json:"contextLength,omitempty"`
+		UsableContext  int        `json:"usableContext,omitempty"`
+		ProbedAt       *time.Time `json:"probedAt,omitempty"`
+		// Последний замер POST /models/benchmark (нет — модель не замерялась)
+		Benchmark *models.ModelBenchmark `json:"benchmark,omitempty"`
+	}
+	result := make([]ModelInfo, 0, len(ollamaModels))
+	benchmarks := repository.LatestBenchmarks()
+
+	for _, m := range ollamaModels {
+		fullInfo, err := repository.GetModelFullInfo(m)
+		if err != nil {
+			slog.Error("Ошибка получения информации о модели", slog.String("модель", m), slog.String("ошибка", err.Error()))
+			result = append(result, ModelInfo{Name: m})
+			continue
+		}
+
+		var roles []string
+		var notes map[string]string
+		json.Unmarshal([]byte(fullInfo.SuitableRoles), &roles)
+		json.Unmarshal([]byte(fullInfo.RoleNotes), &notes)
+
+		result = append(result, ModelInfo{
+			Name:          m,
+			SupportsTools: fullInfo.SupportsTools,
+			Family:        fullInfo.Family,
+			ParameterSize: fullInfo.ParameterSize,
+			IsCodeModel:   fullInfo.IsCodeModel,
+			SuitableRoles: roles,
+			RoleNotes:     notes,
+
+			ToolCallFormat: fullInfo.ToolCallFormat,
+			SupportsJSON:   fullInfo.SupportsJSON,
+			ContextLength:  fullInfo.ContextLength,
+			UsableContext:  fullInfo.UsableContext,
+			ProbedAt:       fullInfo.ProbedAt,
+		})
+		if b, ok := benchmarks[m]; ok {
+			b.Details = "" // Подробности — в GET /models/benchmark
+			result[len(result)-1].Benchmark = &b
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	writeJSON(w, result)
+}
+
+// ollamaPulls — фоновые загрузки моделей Ollama; после успешной загрузки
+// модель сразу классифицируется (поддержка инструментов, роли).
+var ollamaPulls = &llm.PullTracker{OnDone: func(model string, err error) {
+	if err != nil {
+		slog.Error("Ошибка загрузки модели Ollama", slog.String("модель", model), slog.String("ошибка", err.Error()))
+		return
+	}
+	slog.Info("Модель Ollama загружена", slog.String("модель", model))
+	if names, err := repository.GetOllamaModels(); err == nil {
+		repository.SyncModels(names)
+	}
+}}
+
+// ollamaProvider — зарегистрированный провайдер Ollama.
+func ollamaProvider() (*llm.OllamaProvider, error) {
+	p, err := llm.GlobalRegistry.Get("ollama")
+	if err != nil {
+		return nil, err
+	}
+	op, ok := p.(*llm.OllamaProvider)
+	if !ok {
+		return nil, errors.New("провайдер ollama не поддерживает управление моделями")
+	}
+	return op, nil
+}
+
+// ollamaModelRequest — тело POST /ollama/pull и /ollama/delete.
+type ollamaModelRequest struct {
+	Name string `json:"name"`
+}
+
+// decodeOllamaModel — имя модели из тела запроса; при ошибке ответ уже записан.
+func decodeOllamaModel(w http.ResponseWriter, r *http.Request, cid string) (string, bool) {
+	var req ollamaModelRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		apierror.BadRequest(w, cid, "Невалидный JSON", "Ожидается {\"name\": \"llama3.1:8b\"}")
+		return "", false
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		apierror.BadRequest(w, cid, "name обязателен", "Укажите имя модели, например qwen2.5:7b")
+		return "", false
+	}
+	return req.Name, true
+}
+
+// ollamaPullHandler — POST /ollama/pull: загрузка модели из реестра Ollama.
+// По умолчанию отвечает потоком SSE (event: progress, затем done или error);
+// с ?stream=false сразу возвращает 202 и состояние загрузки. Загрузка идёт
+// в фоне и не прерывается при отключении клиента.
+func ollamaPullHandler(w http.ResponseWriter, r *http.Request) {
+	cid := r.Header.Get("X-Request-ID")
+	if r.Method != http.MethodPost {
+		apierror.MethodNotAllowed(w, cid)
+		return
+	}
+	name, ok := decodeOllamaModel(w, r, cid)
+	if !ok {
+		return
+	}
+	op, err := ollamaProvider()
+	if err != nil {
+		apierror.ServiceUnavailable(w, cid, "Ollama недоступна", err.Error())
+		return
+	}
+	st, started := ollamaPulls.Start(op, name)
+	if started {
+		slog.Info("Запущена загрузка модели Ollama", slog.String("модель", name), slog.String("request_id", cid))
+	}
+	if r.URL.Query().Get("stream") == "false" {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusAccepted)
+		writeJSON(w, st)
+		return
+	}
+
+	rc := http.NewResponseController(w)
+	rc.SetWriteDeadline(time.Time{})
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	var last llm.PullProgress
+	ticker := time.NewTicker(500 * time.Millisecond)
+	defer ticker.Stop()
+	for first := true; ; first = false {
+		st, _ = ollamaPulls.Status(name)
+		if st.Done {
+			event := "done"
+			if st.Error != "" {
+				event = "error"
+			}
+			data, _ := json.Marshal(st)
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, data)
+			rc.Flush()
+			return
+		}
+		if first || st.Progress != last {
+			last = st.Progress
+			data, _ := json.Marshal(st)
+			if _, err := fmt.Fprintf(w, "event: progress\ndata: %s\n\n", data); err != nil || rc.Flush() != nil {
+				return
+			}
+		}
+		select {
+		case <-r.Context().Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// ollamaDeleteHandler — POST/DELETE /ollama/delete: удаление локальной модели.
+// Модель, назначенную агенту, удалить нельзя (409) — сначала смените модель.
+func ollamaDeleteHandler(w http.ResponseWriter, r *http.Request) {
+	cid := r.Header.Get("X-Request-ID")
+	if r.Method != http.MethodPost && r.Method != http.MethodDelete {
+		apierror.MethodNotAllowed(w, cid)
+		return
+	}
+	name, ok := decodeOllamaModel(w, r, cid)
+	if !ok {
+		return
+	}
+	res, status := deleteOllamaModel(r.Context(), name)
+	if status != http.StatusOK {
+		msg, _ := res["error"].(string)
+		hint, _ := res["hint"].(string)
+		apierror.Write(w, status, apierror.Response{Code: apierror.CodeForStatus(status), Message: msg, Hint: hint, RequestID: cid})
+		return
+	}
+	slog.Info("Модель Ollama удалена", slog.String("модель", name), slog.String("request_id", cid))
+	w.Header().Set("Content-Type", "application/json")
+	writeJSON(w, res)
+}
+
+// deleteOllamaModel — удаление модели с проверкой, что она не назначена агенту.
+// Общая логика для /ollama/delete и инструмента ollama_delete.
+func deleteOllamaModel(ctx context.Context, name string) (map[string]interface{}, int) {
+	var agents []models.Agent
+	db.DB.Where("llm_model = ? AND provider = ?", name, "ollama").Find(&agents)
+	if len(agents) > 0 {
+		names := make([]string, len(agents))
+		for i, a := range agents {
+			names[i] = a.Name
+		}
+		return map[string]interface{}{
+			"error": "Модель используется агентами: " + strings.Join(names, ", "),
+			"hint":  "Назначьте агентам другую модель (POST /update-model) и повторите удаление",
+		}, http.StatusConflict
+	}
+	op, err := ollamaProvider()
+	if err != nil {
+		return map[string]interface{}{"error": "Ollama недоступна: " + err.Error()}, http.StatusServiceUnavailable
+	}
+	if err := op.Delete(ctx, name); err != nil {
+		if errors.Is(err, llm.ErrModelNotFound) {
+			return map[string]interface{}{"error": err.Error(), "hint": "Список моделей: GET /models"}, http.StatusNotFound
+		}
+		return map[string]interface{}{"error": err.Error()}, http.StatusBadGateway
+	}
+	db.DB.Where("model_name = ?", name).Delete(&models.ModelToolSupport{})
+	return map[string]interface{}{"deleted": name}, http.StatusOK
+}
+
+// ollamaPsHandler — GET /ollama/ps: модели в памяти Ollama и идущие загрузки.
+func ollamaPsHandler(w http.ResponseWriter, r *http.Request) {
+	cid := r.Header.Get("X-Request-ID")
+	if r.Method != http.MethodGet {
+		apierror.MethodNotAllowed(w, cid)
+		return
+	}
+	res, err := ollamaStatus(r.Context())
+	if err != nil {
+		apierror.ServiceUnavailable(w, cid, "Ollama недоступна", err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	writeJSON(w, res)
+}
+
+// ollamaStatus — загруженные в память модели и активные загрузки.
+func ollamaStatus(ctx context.Context) (map[string]interface{}, error) {
+	op, err := ollamaProvider()
+	if err != nil {
+		return nil, err
+	}
+	running, err := op.Running(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if running == nil {
+		running = []llm.RunningModel{}
+	}
+	pulls := ollamaPulls.Active()
+	if pulls == nil {
+		pulls = []llm.PullStatus{}
+	}
+	return map[string]interface{}{"running": running, "pulls": pulls}, nil
+}
+
+// handleOllamaTool — инструменты ollama_pull, ollama_delete, ollama_ps.
+// ollama_pull ждёт завершения до 30 секунд, дальше загрузка идёт в фоне;
+// повторный вызов с тем же именем возвращает прогресс.
+func handleOllamaTool(ctx context.Context, toolName string, args map[string]interface{}) map[string]interface{} {
+	name, _ := args["name"].(string)
+	name = strings.TrimSpace(name)
+	if toolName != "ollama_ps" && name == "" {
+		return map[string]interface{}{"error": "name обязателен"}
+	}
+	switch toolName {
+	case "ollama_pull":
+		op, err := ollamaProvider()
+		if err != nil {
+			return map[string]interface{}{"error": "Ollama недоступна: " + err.Error()}
+		}
+		if _, started := ollamaPulls.Start(op, name); started {
+			slog.Info("Запущена загрузка модели Ollama", slog.String("модель", name), slog.String("источник", "инструмент"))
+		}
+		st := ollamaPulls.Wait(ctx, name, 30*time.Second)
+		switch {
+		case st.Error != "":
+			return map[string]interface{}{"error": st.Error}
+		case st.Done:
+			return map[string]interface{}{"model": name, "status": "загружена"}
+		}
+		return map[string]interface{}{
+			"model":    name,
+			"status":   "загружается в фоне",
+			"progress": st.Progress,
+			"message":  "Вызовите ollama_pull с тем же именем позже, чтобы узнать прогресс",
+		}
+	case "ollama_delete":
+		res, _ := deleteOllamaModel(ctx, name)
+		return res
+	default:
+		res, err := ollamaStatus(ctx)
+		if err != nil {
+			return map[string]interface{}{"error": err.Error()}
+		}
+		return res
+	}
+}
+
+// promptsHandler — получение списка файлов промптов для агента (GET /prompts?agent=...).
+// Ищет файлы .txt, .prompt, .md в директории prompts/{agent}.
+// Используется для отображения модального окна выбора промпта в UI.
+func promptsHandler(w http.ResponseWriter, r *http.Request) {
+	cid := r.Header.Get("X-Request-ID")
+	if r.Method != http.MethodGet {
+		apierror.MethodNotAllowed(w, cid)
+		return
+	}
+	agentName := r.URL.Query().Get("agent")
+	if agentName == "" {
+		apierror.BadRequest(w, cid, "Не указан параметр agent", "")
+		return
+	}
+	promptsDir := filepath.Join(".", "prompts", agentName)
+	if _, err := os.Stat(promptsDir); os.IsNotExist(err) {
+		writeJSON(w, []string{})
+		return
+	}
+	files, err := os.ReadDir(promptsDir)
+	if err != nil {
+		apierror.InternalError(w, cid, err.Error(), "")
+		return
+	}
+	result := []string{}
+	for _, f := range files {
+		if !f.IsDir() && promptpack.ValidFilename(f.Name()) {
+			result = append(result, f.Name())
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	writeJSON(w, result)
+}
+
+// loadPromptHandler — загрузка промпта из файла (POST /prompts/load).
+// Читает содержимое файла prompts/{agent}/{filename}, обновляет промпт агента в БД.
+// Устанавливает CurrentPromptFile для отображения текущего выбранного файла.
+func loadPromptHandler(w http.ResponseWriter, r *http.Request) {
+	cid := r.Header.Get("X-Request-ID")
+	if r.Method != http.MethodPost {
+		apierror.MethodNotAllowed(w, cid)
+		return
+	}
+	var req struct {
+		Agent    string `json:"agent"`
+		Filename string `json:"filename"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		apierror.BadRequest(w, cid, "Невалидный JSON", "")
+		return
+	}
+	if req.Agent == "" || req.Filename == "" {
+		apierror.BadRequest(w, cid, "Требуются agent и filename", "")
+		return
+	}
+	agent, err := repository.GetAgentByName(req.Agent)
+	if err != nil {
+		apierror.NotFound(w, cid, "Агент не найден")
+		return
+	}
+	if !promptpack.ValidFilename(req.Filename) {
+		apierror.BadRequest(w, cid, "Некорректное имя файла промпта", "Ожидается имя файла .txt, .prompt или .md без пути")
+		return
+	}
+	promptPath := filepath.Join(".", "prompts", req.Agent, req.Filename)
+	content, err := os.ReadFile(promptPath)
+	if err != nil {
+		apierror.InternalError(w, cid, "Не удалось прочитать файл промпта", "")
+		return
+	}
+	agent.Prompt = string(content)
+	agent.CurrentPromptFile = req.Filename
+	if err := db.DB.Save(agent).Error; err != nil {
+		apierror.InternalError(w, cid, "Не удалось обновить агента", "")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	writeJSON(w, map[string]string{"status": "ok"})
+}
+
+// updatePromptHandler — обновление промпта вручную (POST /agent/prompt).
+// Устанавливает новый системный промпт, введённый пользователем через UI.
+// Сбрасывает CurrentPromptFile, так как промпт больше не привязан к файлу.
+func updatePromptHandler(w http.ResponseWriter, r *http.Request) {
+	cid := r.Header.Get("X-Request-ID")
+	if r.Method != http.MethodPost {
+		apierror.MethodNotAllowed(w, cid)
+		return
+	}
+	var req UpdatePromptRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		apierror.BadRequest(w, cid, "Невалидный JSON", "")
+		return
+	}
+	if req.Agent == "" {
+		apierror.BadRequest(w, cid, "Требуется agent", "")
+		return
+	}
+	agent, err := repository.GetAgentByName(req.Agent)
+	if err != nil {
+		apierror.NotFound(w, cid, "Агент не найден")
+		return
+	}
+	agent.Prompt = req.Prompt
+	agent.CurrentPromptFile = ""
+	if err := db.DB.Save(agent).Error; err != nil {
+		apierror.InternalError(w, cid, "Не удалось обновить агента", "")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	writeJSON(w, map[string]string{"status": "ok"})
+}
+
+// promptExportHandler — набор промптов агента для обмена (GET /prompts/export).
+//
+//	?agent=admin                — текущий промпт агента с его моделью и провайдером
+//	?agent=admin&filename=x.md  — файл prompts/{agent}/x.md
+//	?agent=admin&all=1          — все файлы prompts/{agent}/
+//
+// Ответ — YAML-файл набора (см. пакет promptpack) для скачивания; его
+// принимает POST /prompts/import в другой установке.
+func promptExportHandler(w http.ResponseWriter, r *http.Request) {
+	cid := r.Header.Get("X-Request-ID")
+	if r.Method != http.MethodGet {
+		apierror.MethodNotAllowed(w, cid)
+		return
+	}
+	q := r.URL.Query()
+	agentName := q.Get("agent")
+	if !promptpack.ValidAgent(agentName) {
+		apierror.BadRequest(w, cid, "Не указан или некорректен параметр agent", "")
+		return
+	}
+	agent, err := repository.GetAgentByName(agentName)
+	if err != nil {
+		apierror.NotFound(w, cid, "Агент не найден")
+		return
+	}
+	dir := filepath.Join(".", "prompts")
+	var prompts []promptpack.Prompt
+	switch filename := q.Get("filename"); {
+	case q.Get("all") == "1":
+		if prompts, err = promptpack.ReadDir(dir, agentName); err != nil {
+			apierror.InternalError(w, cid, "Не удалось прочитать промпты агента", "")
+			return
+		}
+	case filename != "":
+		if !promptpack.ValidFilename(filename) {
+			apierror.BadRequest(w, cid, "Некорректное имя файла промпта", "Ожидается имя файла .txt, .prompt или .md без пути")
+			return
+		}
+		content, err := os.ReadFile(filepath.Join(dir, agentName, filename))
+		if err != nil {
+			apierror.NotFound(w, cid, "Файл промпта не найден")
+			return
+		}
+		prompts = []promptpack.Prompt{{Agent: agentName, Filename: filename, Content: string(content)}}
+	default:
+		filename := agent.CurrentPromptFile
+		if !promptpack.ValidFilename(filename) {
+			filename = agentName + ".md"
+		}
+		prompts = []promptpack.Prompt{{Agent: agentName, Filename: filename, Content: agent.Prompt}}
+	}
+	if len(prompts) == 0 || strings.TrimSpace(prompts[0].Content) == "" {
+		apierror.NotFound(w, cid, "У агента нет промптов для экспорта")
+		return
+	}
+	for i := range prompts {
+		prompts[i].Provider = agent.Provider
+		prompts[i].Model = agent.LLMModel
+	}
+	pack := promptpack.New(agentName, prompts, time.Now())
+	pack.Description = q.Get("description")
+	pack.Author = q.Get("author")
+	data, err := promptpack.Encode(pack)
+	if err != nil {
+		apierror.InternalError(w, cid, "Не удалось сформировать набор промптов", "")
+		return
+	}
+	w.Header().Set("Content-Type", "application/yaml; charset=utf-8")
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": agentName + ".promptpack.yaml"}))
+	w.Write(data)
+}
+
+// promptImportHandler — импорт набора промптов в prompts/{agent}/ (POST /prompts/import).
+// Тело — набор в YAML или JSON (см. пакет promptpack).
+//
+//	?on_conflict=skip|overwrite|rename — файл с тем же именем и другим содержимым
+//	?agent=coder                       — положить все промпты набора этому агенту
+//	?dry_run=1                         — только показать, что будет сделано
+//
+// Промпты для агентов, которых нет в этой установке, не импортируются.
+// Промпт агента не меняется: выбрать импортированный файл — POST /prompts/load.
+func promptImportHandler(w http.ResponseWriter, r *http.Request) {
+	cid := r.Header.Get("X-Request-ID")
+	if r.Method != http.MethodPost {
+		apierror.MethodNotAllowed(w, cid)
+		return
+	}
+	q := r.URL.Query()
+	conflict, err := promptpack.ParseConflict(q.Get("on_conflict"))
+	if err != nil {
+		apierror.BadRequest(w, cid, err.Error(), "")
+		return
+	}
+	data, err := io.ReadAll(r.Body)
+	if err != nil {
+		apierror.BadRequest(w, cid, "Не удалось прочитать тело запроса", "")
+		return
+	}
+	pack, err := promptpack.Parse(data)
+	if err == nil && q.Get("agent") != "" {
+		for i := range pack.Prompts {
+			pack.Prompts[i].Agent = q.Get("agent")
+		}
+		err = pack.Validate()
+	}
+	if err != nil {
+		apierror.BadRequest(w, cid, "Некорректный набор промптов: "+err.Error(), "Ожидается файл из GET /prompts/export (format: "+promptpack.Format+")")
+		return
+	}
+
+	// Промпты неизвестных агентов отсекаются до записи на диск
+	var results []promptpack.Result
+	known := make(map[string]bool)
+	importable := *pack
+	importable.Prompts = nil
+	for _, pr := range pack.Prompts {
+		ok, checked := known[pr.Agent]
+		if !checked {
+			_, err := repository.GetAgentByName(pr.Agent)
+			ok = err == nil
+			known[pr.Agent] = ok
+		}
+		if !ok {
+			results = append(results, promptpack.Result{Agent: pr.Agent, Filename: pr.Filename, Status: promptpack.StatusError, Error: "агент не найден"})
+			continue
+		}
+		importable.Prompts = append(importable.Prompts, pr)
+	}
+	dryRun := q.Get("dry_run") == "1"
+	results = append(results, promptpack.Import(filepath.Join(".", "prompts"), &importable, conflict, dryRun)...)
+
+	summary := make(map[string]int)
+	for _, res := range results {
+		summary[res.Status]++
+	}
+	if !dryRun {
+		slog.Info("Импортирован набор промптов",
+			slog.String("набор", pack.Name),
+			slog.Int("промптов", len(pack.Prompts)),
+			slog.Int("создано", summary[promptpack.StatusCreated]+summary[promptpack.StatusRenamed]),
+			slog.Int("конфликтов", summary[promptpack.StatusConflict]),
+			slog.String("request_id", cid))
+	}
+	writeJSON(w, map[string]interface{}{
+		"pack":        pack.Name,
+		"dry_run":     dryRun,
+		"on_conflict": conflict,
+		"results":     results,
+		"summary":     summary,
+	})
+}
+
+// PromptFileRequest — тело POST и PUT /prompts/files.
+type PromptFileRequest struct {
+	Agent       string  `json:"agent"`                  // POST: агент
+	Filename    string  `json:"filename"`               // POST: имя файла; PUT: новое имя (переименование)
+	Content     *string `json:"content,omitempty"`      // Содержимое (PUT без content — только переименование)
+	BaseSHA256  string  `json:"base_sha256,omitempty"`  // PUT: sha256 правленой версии — защита от перезаписи чужих правок
+	MakeCurrent bool    `json:"make_current,omitempty"` // Сразу назначить файл промптом агента
+}
+
+// promptFilesHandler — управление файлами prompts/{agent}/ из интерфейса:
+//
+//	GET    /prompts/files?agent=admin       — файлы агента (имя, размер, sha256)
+//	GET    /prompts/files/{agent}/{file}    — файл с содержимым
+//	POST   /prompts/files                   — создать {agent, filename, content}
+//	PUT    /prompts/files/{agent}/{file}    — изменить {content, base_sha256} и/или переименовать {filename}
+//	DELETE /prompts/files/{agent}/{file}    — удалить
+//
+// Имена агентов и файлов проверяются (promptpack.ValidFilename), выйти за
+// пределы prompts/ нельзя. Если файл — текущий промпт агента
+// (CurrentPromptFile), правка обновляет промпт, переименование — ссылку на
+// файл, удаление отвязывает файл (сам промпт остаётся).
+func promptFilesHandler(w http.ResponseWriter, r *http.Request) {
+	cid := r.Header.Get("X-Request-ID")
+	dir := filepath.Join(".", "prompts")
+	rest := strings.Trim(strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, "/prompts/files"), "/"), "/")
+	agentName, filename, _ := strings.Cut(rest, "/")
+	if rest == "" {
+		agentName = r.URL.Query().Get("agent")
+	}
+
+	var req PromptFileRequest
+	if r.Method == http.MethodPost || r.Method == http.MethodPut {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			apierror.BadRequest(w, cid, "Невалидный JSON", "Ожидается {\"agent\", \"filename\", \"content\"}")
+			return
+		}
+		if r.Method == http.MethodPost {
+			agentName = req.Agent
+		}
+	}
+	if !promptpack.ValidAgent(agentName) {
+		apierror.BadRequest(w, cid, "Не указан или некорректен агент", "")
+		return
+	}
+	agent, err := repository.GetAgentByName(agentName)
+	if err != nil {
+		apierror.NotFound(w, cid, "Агент не найден")
+		return
+	}
+
+	var file promptpack.File
+	switch {
+	case r.Method == http.MethodGet && rest == "":
+		files, err := promptpack.List(dir, agentName)
+		if err != nil {
+			apierror.InternalError(w, cid, "Не удалось прочитать промпты агента", "")
+			return
+		}
+		writeJSON(w, map[string]interface{}{"agent": agentName, "current": agent.CurrentPromptFile, "files": files})
+		return
+	case r.Method == http.MethodGet:
+		file, err = promptpack.Read(dir, agentName, filename)
+	case r.Method == http.MethodPost && rest == "":
+		if req.Content == nil {
+			apierror.BadRequest(w, cid, "Требуется content", "")
+			return
+		}
+		file, err = promptpack.Create(dir, agentName, req.Filename, *req.Content)
+	case r.Method == http.MethodPut && rest != "":
+		// Сначала все проверки, потом запись: при конфликте файл не меняется
+		file, err = promptpack.Read(dir, agentName, filename)
+		if err == nil && req.Content != nil && req.BaseSHA256 != "" && !strings.EqualFold(req.BaseSHA256, file.SHA256) {
+			err = promptpack.ErrChanged
+		}
+		if err == nil && req.Filename != "" && req.Filename != filename {
+			file, err = promptpack.Rename(dir, agentName, filename, req.Filename)
+		}
+		if err == nil && req.Content != nil {
+			file, err = promptpack.Update(dir, agentName, file.Filename, *req.Content, "")
+		}
+	case r.Method == http.MethodDelete && rest != "":
+		if err = promptpack.Delete(dir, agentName, filename); err == nil {
+			file = promptpack.File{Agent: agentName, Filename: filename}
+		}
+	default:
+		apierror.MethodNotAllowed(w, cid)
+		return
+	}
+	switch {
+	case errors.Is(err, promptpack.ErrNotFound):
+		apierror.NotFound(w, cid, "Файл промпта не найден")
+		return
+	case errors.Is(err, promptpack.ErrExists), errors.Is(err, promptpack.ErrChanged):
+		apierror.Write(w, http.StatusConflict, apierror.Response{
+			Code:      apierror.CodeForStatus(http.StatusConflict),
+			Message:   err.Error(),
+			Hint:      "Обновите список файлов и повторите",
+			RequestID: cid,
+		})
+		return
+	case err != nil && (r.Method == http.MethodGet || r.Method == http.MethodDelete):
+		apierror.InternalError(w, cid, "Ошибка работы с файлом промпта", "")
+		return
+	case err != nil:
+		apierror.BadRequest(w, cid, err.Error(), "")
+		return
+	}
+
+	// Текущий промпт агента следует за своим файлом
+	save := true
+	switch {
+	case r.Method == http.MethodDelete && agent.CurrentPromptFile == filename:
+		agent.CurrentPromptFile = ""
+	case (r.Method == http.MethodPost || r.Method == http.MethodPut) && (req.MakeCurrent || (filename != "" && agent.CurrentPromptFile == filename)):
+		agent.Prompt = file.Content
+		agent.CurrentPromptFile = file.Filename
+	default:
+		save = false
+	}
+	if save {
+		if err := db.DB.Save(agent).Error; err != nil {
+			apierror.InternalError(w, cid, "Файл сохранён, но не удалось обновить агента", "")
+			return
+		}
+	}
+	if r.Method != http.MethodGet {
+		slog.Info("Файл промпта изменён",
+			slog.String("агент", agentName),
+			slog.String("файл", file.Filename),
+			slog.String("метод", r.Method),
+			slog.String("request_id", cid))
+	}
+	if r.Method == http.MethodPost {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+	}
+	writeJSON(w, file)
+}
+
+// updateAgentModelHandler — смена модели и/или провайдера агента (POST /update-model).
+// Позволяет переключить агента на другую модель (локальную или облачную)
+// и при необходимости изменить провайдера.
+// Например: {"agent":"admin", "model":"gpt-4o", "provider":"openai"}
+func updateAgentModelHandler(w http.ResponseWriter, r *http.Request) {
+	cid := r.Header.Get("X-Request-ID")
+	if r.Method != http.MethodPost {
+		apierror.MethodNotAllowed(w, cid)
+		return
+	}
+	var req UpdateModelRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		apierror.BadRequest(w, cid, "Невалидный JSON", "")
+		return
+	}
+	if req.Agent == "" || req.Model == "" {
+		apierror.BadRequest(w, cid, "Требуются agent и model", "")
+		return
+	}
+
+	var agent models.Agent
+	if err := db.DB.Where("name = ?", req.Agent).First(&agent).Error; err != nil {
+		apierror.NotFound(w, cid, "Агент не найден")
+		return
+	}
+
+	agent.LLMModel = req.Model
+	if req.Provider != "" {
+		agent.Provider = req.Provider
+	}
+	if err := db.DB.Save(&agent).Error; err != nil {
+		apierror.InternalError(w, cid, "Не удалось обновить агента", "")
+		return
+	}
+	if agent.Provider == "ollama" {
+		go warmAgentModels([]string{agent.LLMModel})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	writeJSON(w, map[string]string{"status": "ok"})
+}
+
+// modelWarmer — прогрев моделей Ollama и выбор keep_alive по частоте использования.
+var modelWarmer = warmup.New(warmup.LoadConfig())
+
+// agentOllamaModels — локальные модели, назначенные агентам.
+func agentOllamaModels() []string {
+	var names []string
+	db.DB.Model(&models.Agent{}).Where("provider = ? AND llm_model <> ''", "ollama").Distinct().Pluck("llm_model", &names)
+	return names
+}
+
+// warmAgentModels — обновляет набор моделей агентов и загружает models
+// в память Ollama. Вызывается при старте и при смене модели агента.
+func warmAgentModels(names []string) {
+	modelWarmer.SetPinned(agentOllamaModels())
+	op, err := ollamaProvider()
+	if err != nil || len(names) == 0 {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancel()
+	modelWarmer.Warm(ctx, op, names)
+}
+
+// modelsLoadedHandler — GET /models/loaded: модели в памяти Ollama, занятая
+// VRAM, частота использования и текущее время удержания (keep_alive).
+func modelsLoadedHandler(w http.ResponseWriter, r *http.Request) {
+	cid := r.Header.Get("X-Request-ID")
+	if r.Method != http.MethodGet {
+		apierror.MethodNotAllowed(w, cid)
+		return
+	}
+	op, err := ollamaProvider()
+	if err != nil {
+		apierror.ServiceUnavailable(w, cid, "Ollama недоступна", err.Error())
+		return
+	}
+	rep, err := modelWarmer.Report(r.Context(), op)
+	if err != nil {
+		apierror.ServiceUnavailable(w, cid, "Ollama недоступна", err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	writeJSON(w, rep)
+}
+
+// benchmarkRequest — тело POST /models/benchmark.
+type benchmarkRequest struct {
+	Model        string `json:"model"`
+	Provider     string `json:"provider"`      // По умолчанию ollama
+	SpeedRuns    int    `json:"speed_runs"`    // Число замеров скорости (1–10, по умолчанию 3)
+	ContextSizes []int  `json:"context_sizes"` // Размеры контекста для проверки, ≈ токенов
+}
+
+// benchmarksRunning — модели, для которых сейчас идёт замер.
+var benchmarksRunning sync.Map
+
+// modelsBenchmarkHandler — /models/benchmark.
+//   - POST: прогоняет стандартный набор замеров (скорость, задержка до первого
+//     токена, вызов инструментов, размер контекста), сохраняет результат и
+//     возвращает его. Замер занимает от десятков секунд до нескольких минут.
+//   - GET ?model=&limit=: история замеров для сравнения моделей.
+func modelsBenchmarkHandler(w http.ResponseWriter, r *http.Request) {
+	cid := r.Header.Get("X-Request-ID")
+	switch r.Method {
+	case http.MethodGet:
+		limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+		if limit <= 0 || limit > 200 {
+			limit = 50
+		}
+		list, err := repository.ListBenchmarks(r.URL.Query().Get("model"), limit)
+		if err != nil {
+			apierror.InternalError(w, cid, "Не удалось получить историю замеров", err.Error())
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		writeJSON(w, list)
+	case http.MethodPost:
+		runBenchmark(w, r, cid)
+	default:
+		apierror.MethodNotAllowed(w, cid)
+	}
+}
+
+// runBenchmark — POST /models/benchmark.
+func runBenchmark(w http.ResponseWriter, r *http.Request, cid string) {
+	var req benchmarkRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		apierror.BadRequest(w, cid, "Невалидный JSON", "Ожидается {\"model\": \"qwen2.5:7b\"}")
+		return
+	}
+	req.Model = strings.TrimSpace(req.Model)
+	if req.Model == "" {
+		apierror.BadRequest(w, cid, "model обязателен", "Укажите имя модели, например qwen2.5:7b")
+		return
+	}
+	if req.Provider == "" {
+		req.Provider = "ollama"
+	}
+	if req.SpeedRuns > 10 {
+		req.SpeedRuns = 10
+	}
+	for _, n := range req.ContextSizes {
+		if n < 256 || n > 131072 {
+			apierror.BadRequest(w, cid, "Недопустимый размер контекста", "context_sizes: от 256 до 131072 токенов")
+			return
+		}
+	}
+	provider, err := llm.GlobalRegistry.Get(req.Provider)
+	if err != nil {
+		apierror.BadRequest(w, cid, "Неизвестный провайдер", err.Error())
+		return
+	}
+	key := req.Provider + "/" + req.Model
+	if _, busy := benchmarksRunning.LoadOrStore(key, true); busy {
+		apierror.Write(w, http.StatusConflict, apierror.Response{
+			Code:      apierror.CodeForStatus(http.StatusConflict),
+			Message:   "Замер этой модели уже идёт",
+			RequestID: cid,
+		})
+		return
+	}
+	defer benchmarksRunning.Delete(key)
+
+	opt := benchmark.Options{SpeedRuns: req.SpeedRuns, ContextSizes: req.ContextSizes}
+	if req.Provider == "ollama" {
+		// Модели без tool calling не гоняем по тестам инструментов — результат заранее известен
+		if info, err := repository.GetModelFullInfo(req.Model); err == nil && !info.SupportsTools {
+			opt.SkipTools = true
+		}
+	}
+	slog.Info("Замер модели запущен", slog.String("модель", req.Model), slog.String("провайдер", req.Provider), slog.String("request_id", cid))
+	runner := &benchmark.Runner{Provider: provider, Model: req.Model}
+	rep, err := runner.Run(r.Context(), opt)
+	if err != nil {
+		apierror.ServiceUnavailable(w, cid, "Замер не выполнен", err.Error())
+		return
+	}
+
+	details, _ := json.Marshal(rep)
+	record := models.ModelBenchmark{
+		Provider:        rep.Provider,
+		ModelName:       rep.Model,
+		TokensPerSec:    rep.TokensPerSec,
+		FirstTokenMs:    rep.FirstTokenMs,
+		ToolCalls:       len(rep.Tools),
+		ToolSuccessRate: rep.ToolSuccessRate,
+		ContextTokens:   rep.ContextTokens,
+		DurationMs:      rep.DurationMs,
+		Details:         string(details),
+	}
+	for _, t := range rep.Tools {
+		if t.OK {
+			record.ToolCallsOK++
+		}
+	}
+	if err := repository.SaveBenchmark(&record); err != nil {
+		slog.Error("Не удалось сохранить замер модели", slog.String("модель", req.Model), slog.String("ошибка", err.Error()))
+	}
+	slog.Info("Замер модели завершён",
+		slog.String("модель", req.Model),
+		slog.Float64("токенов_в_сек", rep.TokensPerSec),
+		slog.Float64("инструменты", rep.ToolSuccessRate),
+		slog.Int("контекст", rep.ContextTokens))
+
+	w.Header().Set("Content-Type", "application/json")
+	writeJSON(w, struct {
+		ID uint `json:"id"`
+		*benchmark.Report
+	}{record.ID, rep})
+}
+
+// avatarUploadHandler — загрузка аватара агента (POST /avatar?agent=...).
+// Принимает multipart/form-data с изображением PNG, JPEG, GIF или WebP (до 10 МБ;
+// тип определяется по содержимому). Сохраняет квадратные WebP размеров
+// avatar.Sizes без EXIF в uploads/avatars/, основной записывает в поле Avatar,
+// файлы прежнего аватара удаляет. Файлы раздаются через /uploads/avatars/ как статика.
+// С параметром persona аватар загружается образу агента (/agents/{name}/persona),
+// аватар самого агента не меняется.
+func avatarUploadHandler(w http.ResponseWriter, r *http.Request) {
+	cid := r.Header.Get("X-Request-ID")
+	if r.Method != http.MethodPost {
+		apierror.MethodNotAllowed(w, cid)
+		return
+	}
+
+	agentName := r.URL.Query().Get("agent")
+	if agentName == "" {
+		apierror.BadRequest(w, cid, "Требуется параметр agent", "")
+		return
+	}
+
+	err := r.ParseMultipartForm(10 << 20)
+	if err != nil {
+		apierror.BadRequest(w, cid, "Не удалось разобрать multipart form", "")
+		return
+	}
+	file, _, err := r.FormFile("file")
+	if err != nil {
+		apierror.BadRequest(w, cid, "Файл не предоставлен", "")
+		return
+	}
+	defer file.Close()
+	data, err := io.ReadAll(file)
+	if err != nil {
+		apierror.BadRequest(w, cid, "Не удалось прочитать файл", "")
+		return
+	}
+
+	agent, err := repository.GetAgentByName(agentName)
+	if err != nil {
+		apierror.NotFound(w, cid, "Агент не найден")
+		return
+	}
+	// Исходный файл не сохраняется: только квадратные WebP без метаданных
+	variants, err := avatar.Process(data)
+	if err != nil {
+		apierror.BadRequest(w, cid, err.Error(), "Загрузите изображение PNG, JPEG, GIF или WebP")
+		return
+	}
+	var p *models.Persona
+	prefix := agentName
+	if name := r.URL.Query().Get("persona"); name != "" {
+		if p, err = personaStore().Get(agent.Name, name); err != nil {
+			apierror.NotFound(w, cid, "Образ не найден: "+name)
+			return
+		}
+		prefix = agentName + "_" + name
+	}
+	uploadDir := filepath.Join("uploads", "avatars")
+	names, err := avatar.Save(uploadDir, prefix, variants)
+	if err != nil {
+		apierror.InternalError(w, cid, "Не удалось сохранить файл", "")
+		return
+	}
+	slog.Info("Аватар сохранён", slog.String("агент", agentName), slog.String("образ", personaName(p)), slog.String("файл", names[0]))
+
+	var old string
+	if p != nil {
+		old = p.Avatar
+		err = db.DB.Model(p).Update("avatar", names[0]).Error
+	} else {
+		old = agent.Avatar
+		agent.Avatar = names[0]
+		err = db.DB.Save(agent).Error
+	}
+	if err != nil {
+		apierror.InternalError(w, cid, "Не удалось обновить аватар", "")
+		return
+	}
+	if err := avatar.Remove(uploadDir, old, names...); err != nil {
+		slog.Warn("Не удалось удалить старый аватар", slog.String("файл", old), slog.String("ошибка", err.Error()))
+	}
+
+	sizes := make(map[string]string, len(names))
+	for k, v := range variants {
+		sizes[strconv.Itoa(v.Size)] = names[k]
+	}
+	w.Header().Set("Content-Type", "application/json")
+	writeJSON(w, map[string]interface{}{"status": "ok", "avatar": names[0], "sizes": sizes})
+}
+
+// avatarGetHandler — получение информации об аватаре агента (GET /avatar-info?agent=...).
+// Возвращает JSON с именем файла аватара (аватар активного образа, если он
+// задан) или 404, если аватар не загружен.
+func avatarGetHandler(w http.ResponseWriter, r *http.Request) {
+	cid := r.Header.Get("X-Request-ID")
+	agentName := r.URL.Query().Get("agent")
+	if agentName == "" {
+		apierror.BadRequest(w, cid, "Требуется параметр agent", "")
+		return
+	}
+	agent, err := repository.GetAgentByName(agentName)
+	if err != nil {
+		apierror.NotFound(w, cid, "Агент не найден")
+		return
+	}
+	file := agentAvatar(agent, personaStore().Active(agent))
+	if file == "" {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	writeJSON(w, map[string]string{"avatar": file})
+}
+
+// rootHandler — обработчик корневого пути (GET /).
+// Возвращает список агентов с их настройками в формате JSON.
+// Используется для проверки работоспособности сервиса и отладки.
+// Обрабатывает только точный путь "/" — для остальных возвращает 404.
+func rootHandler(w http.ResponseWriter, r *http.Request) {
+	cid := r.Header.Get("X-Request-ID")
+	if r.Method != http.MethodGet {
+		apierror.MethodNotAllowed(w, cid)
+		return
+	}
+	if r.URL.Path != "/" {
+		apierror.NotFound(w, cid, "Маршрут не найден")
+		return
+	}
+	var agents []models.Agent
+	if err := db.DB.Find(&agents).Error; err != nil {
+		apierror.InternalError(w, cid, err.Error(), "")
+		return
+	}
+	active := personaStore().ActiveByAgent(agents)
+	var result []map[string]interface{}
+	for _, a := range agents {
+		p := active[a.Name]
+		result = append(result, map[string]interface{}{
+			"name":          a.Name,
+			"model":         a.LLMModel,
+			"provider":      a.Provider,
+			"supportsTools": a.SupportsTools,
+			"learnings":     a.LearningsEnabled,
+			"toolsEnabled":  a.ToolsEnabled,
+			"avatar":        agentAvatar(&a, p),
+			"prompt_file":   a.CurrentPromptFile,
+			"prompt":        a.Prompt,
+			"persona":       personaName(p),
+		})
+	}
+	w.Header().Set("Content-Type", "application/json")
+	writeJSON(w, result)
+}
+
+// writeJSON — вспомогательная функция для JSON-ответов.
+// Кодирует значение v в JSON и записывает в ResponseWriter.
+// При ошибке кодирования логирует ошибку (не паникует).
+// Заголовок Content-Type должен быть установлен вызывающей функцией.
+func truncate(s string, maxLen int) string {
+	if len(s) <= maxLen {
+		return s
+	}
+	return s[:maxLen] + "..."
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		slog.Error("Ошибка JSON кодирования", slog.String("ошибка", err.Error()))
+	}
+}
+
+// saveChatMessages — сохранение пары сообщений (пользователь + агент) в PostgreSQL.
+// Используется после каждого успешного ответа от LLM для персистентной истории чатов.
+//
+// Порядок действий:
+//  1. Поиск агента в БД по имени (для привязки сообщений к агенту через AgentID)
+//  2. Создание записи сообщения пользователя (role: user) — в диалоге chatID, если он задан
+//  3. Создание записи ответа ассистента (role: assistant) с моделью, провайдером,
+//     образом агента (personaName, пусто — без образа) и уровнем модели,
+//     выбранным маршрутизатором (route, nil — маршрутизация выключена)
+//
+// Возвращает ID сообщения ассистента (0, если сохранить не удалось) — по нему
+// пользователь оценивает ответ через POST /feedback.
+// При ошибке — логирует предупреждение, но не прерывает работу.
+func saveChatMessages(agentName string, chatID *string, userMessage llm.Message, response, modelName, providerName, personaName string, route *routing.Decision) uint {
+	var agent models.Agent
+	if err := db.DB.Where("name = ?", agentName).First(&agent).Error; err != nil {
+		slog.Error("Не удалось найти агента для сохранения чата", slog.String("ошибка", err.Error()))
+		return 0
+	}
+
+	role := userMessage.Role
+	if role == "" {
+		role = "user"
+	}
+	dbMsg := models.Message{
+		Role:    role,
+		Content: userMessage.Content,
+		AgentID: agent.ID,
+		ChatID:  chatID,
+	}
+	if err := db.DB.Create(&dbMsg).Error; err != nil {
+		slog.Error("Не удалось сохранить сообщение пользователя", slog.String("ошибка", err.Error()))
+	}
+
+	assistantMsg := models.Message{
+		Role:     "assistant",
+		Content:  response,
+		AgentID:  agent.ID,
+		ChatID:   chatID,
+		LLMModel: modelName,
+		Provider: providerName,
+		Persona:  personaName,
+	}
+	if route != nil {
+		assistantMsg.RouteTier = route.Tier
+	}
+	if err := db.DB.Create(&assistantMsg).Error; err != nil {
+		slog.Error("Не удалось сохранить сообщение ассистента", slog.String("ошибка", err.Error()))
+		return 0
+	}
+	return assistantMsg.ID
+}
+
+// saveAlternative — сохраняет новый вариант ответа of (без повторного
+// сообщения пользователя). Возвращает ID сохранённого ответа.
+func saveAlternative(of uint, response, modelName, providerName, personaName string, route *routing.Decision) uint {
+	var orig models.Message
+	if err := db.DB.First(&orig, of).Error; err != nil {
+		slog.Error("Не удалось найти исходный ответ", slog.Uint64("сообщение", uint64(of)), slog.String("ошибка", err.Error()))
+		return 0
+	}
+	alt := models.Message{
+		Role:          "assistant",
+		Content:       response,
+		AgentID:       orig.AgentID,
+		ChatID:        orig.ChatID,
+		LLMModel:      modelName,
+		Provider:      providerName,
+		Persona:       personaName,
+		AlternativeOf: &of,
+	}
+	if route != nil {
+		alt.RouteTier = route.Tier
+	}
+	if err := db.DB.Create(&alt).Error; err != nil {
+		slog.Error("Не удалось сохранить вариант ответа", slog.String("ошибка", err.Error()))
+		return 0
+	}
+	return alt.ID
+}
+
+// regeneration — параметры повторной генерации ответа, передаются в chatHandler через контекст.
+type regeneration struct {
+	Of          uint // Первый ответ, новый вариант сохраняется рядом с ним
+	Provider    string
+	Model       string
+	Temperature *float64
+}
+
+type regenerationKey struct{}
+
+// regenerationFrom — параметры повторной генерации из контекста запроса (nil — обычный запрос).
+func regenerationFrom(ctx context.Context) *regeneration {
+	g, _ := ctx.Value(regenerationKey{}).(*regeneration)
+	return g
+}
+
+// RegenerateRequest — запрос POST /chat/regenerate.
+type RegenerateRequest struct {
+	MessageID   uint          `json:"message_id"`            // Ответ ассистента, который нужно сгенерировать заново
+	Messages    []llm.Message `json:"messages,omitempty"`    // История до вопроса включительно; пусто — из сохранённого диалога
+	Temperature *float64      `json:"temperature,omitempty"` // Температура нового варианта
+	Provider    string        `json:"provider,omitempty"`    // Другой провайдер (вместе с model)
+	Model       string        `json:"model,omitempty"`       // Другая модель; пусто — модель агента
+}
+
+// Alternative — вариант ответа на один и тот же вопрос.
+type Alternative struct {
+	MessageID uint      `json:"message_id"`
+	Content   string    `json:"content"`
+	Model     string    `json:"model,omitempty"`
+	Provider  string    `json:"provider,omitempty"`
+	Persona   string    `json:"persona,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// RegenerateResponse — новый вариант ответа и все варианты для сравнения.
+type RegenerateResponse struct {
+	ChatResponse
+	Alternatives []Alternative `json:"alternatives"`
+}
+
+// alternatives — первый ответ root и все его варианты в порядке создания.
+func alternatives(root uint) []Alternative {
+	var msgs []models.Message
+	db.DB.Where("id = ? OR alternative_of = ?", root, root).Order("id").Find(&msgs)
+	out := make([]Alternative, len(msgs))
+	for i, m := range msgs {
+		out[i] = Alternative{MessageID: m.ID, Content: m.Content, Model: m.LLMModel, Provider: m.Provider, Persona: m.Persona, CreatedAt: m.CreatedAt}
+	}
+	return out
+}
+
+// regenerateHistory — сохранённый контекст ответа: вопрос пользователя перед
+// ним и, если ответ принадлежит диалогу, предыдущие реплики без вариантов.
+func regenerateHistory(answer models.Message) ([]llm.Message, error) {
+	chatCond, chatArgs := "chat_id IS NULL", []interface{}{}
+	if answer.ChatID != nil {
+		chatCond, chatArgs = "chat_id = ?", []interface{}{*answer.ChatID}
+	}
+	var question models.Message
+	if err := db.DB.Where("agent_id = ? AND id < ? AND role = ?", answer.AgentID, answer.ID, "user").
+		Where(chatCond, chatArgs...).Order("id DESC").First(&question).Error; err != nil {
+		return nil, errors.New("не найден вопрос, на который дан ответ")
+	}
+	if answer.ChatID == nil {
+		return []llm.Message{{Role: "user", Content: question.Content}}, nil
+	}
+	var prev []models.Message
+	db.DB.Where("agent_id = ? AND id <= ? AND role IN ? AND alternative_of IS NULL", answer.AgentID, question.ID, []string{"user", "assistant"}).
+		Where(chatCond, chatArgs...).Order("id").Find(&prev)
+	history := make([]llm.Message, len(prev))
+	for i, m := range prev {
+		history[i] = llm.Message{Role: m.Role, Content: m.Content}
+	}
+	return history, nil
+}
+
+// chatRegenerateHandler — ещё один вариант ответа на тот же вопрос.
+//
+//	POST /chat/regenerate {"message_id": 42, "temperature": 0.9, "model": "..."} — вопрос
+//	     повторяется с тем же контекстом; новый ответ сохраняется вариантом исходного,
+//	     в ответе — все варианты для сравнения
+//	GET  /chat/regenerate?message_id=42 — все варианты ответа
+func chatRegenerateHandler(w http.ResponseWriter, r *http.Request) {
+	cid := r.Header.Get("X-Request-ID")
+	if r.Method == http.MethodGet {
+		id, _ := strconv.ParseUint(r.URL.Query().Get("message_id"), 10, 64)
+		var answer models.Message
+		if id == 0 || db.DB.Where("id = ? AND role = ?", id, "assistant").First(&answer).Error != nil {
+			apierror.NotFound(w, cid, "Ответ не найден")
+			return
+		}
+		root := answer.ID
+		if answer.AlternativeOf != nil {
+			root = *answer.AlternativeOf
+		}
+		writeJSON(w, map[string]interface{}{"message_id": root, "alternatives": alternatives(root)})
+		return
+	}
+	if r.Method != http.MethodPost {
+		apierror.MethodNotAllowed(w, cid)
+		return
+	}
+	var req RegenerateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.MessageID == 0 {
+		apierror.BadRequest(w, cid, "Не указан message_id", "Передайте ID ответа из поля message_id ответа /chat")
+		return
+	}
+	if req.Temperature != nil && (*req.Temperature < 0 || *req.Temperature > 2) {
+		apierror.BadRequest(w, cid, "temperature должна быть от 0 до 2", "")
+		return
+	}
+	if req.Provider != "" && req.Model == "" {
+		apierror.BadRequest(w, cid, "Вместе с provider укажите model", "")
+		return
+	}
+	var answer models.Message
+	if err := db.DB.Preload("Agent").Where("id = ? AND role = ?", req.MessageID, "assistant").First(&answer).Error; err != nil {
+		apierror.NotFound(w, cid, "Ответ не найден")
+		return
+	}
+	root := answer.ID
+	if answer.AlternativeOf != nil {
+		root = *answer.AlternativeOf
+		db.DB.First(&answer, root)
+	}
+	messages := req.Messages
+	if len(messages) == 0 {
+		history, err := regenerateHistory(answer)
+		if err != nil {
+			apierror.BadRequest(w, cid, err.Error(), "Передайте историю в поле messages")
+			return
+		}
+		messages = history
+	}
+	if messages[len(messages)-1].Role != "user" {
+		apierror.BadRequest(w, cid, "История должна заканчиваться вопросом пользователя", "")
+		return
+	}
+
+	chatReq := ChatRequest{Agent: answer.Agent.Name, Messages: messages, NoCache: true}
+	if answer.ChatID != nil {
+		chatReq.ChatID = *answer.ChatID
+	}
+	body, _ := json.Marshal(chatReq)
+	ctx := context.WithValue(r.Context(), regenerationKey{}, &regeneration{Of: root, Provider: req.Provider, Model: req.Model, Temperature: req.Temperature})
+	sub, err := http.NewRequestWithContext(ctx, http.MethodPost, "/chat", bytes.NewReader(body))
+	if err != nil {
+		apierror.InternalError(w, cid, "Не удалось повторить запрос", "")
+		return
+	}
+	sub.Header = r.Header.Clone()
+	sub.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	chatHandler(rec, sub)
+
+	var resp ChatResponse
+	if rec.Code != http.StatusOK || json.Unmarshal(rec.Body.Bytes(), &resp) != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(rec.Code)
+		w.Write(rec.Body.Bytes())
+		return
+	}
+	slog.Info("Ответ сгенерирован заново",
+		slog.String("агент", answer.Agent.Name),
+		slog.Uint64("исходный", uint64(root)),
+		slog.Uint64("вариант", uint64(resp.MessageID)),
+		slog.String("request_id", cid))
+	writeJSON(w, RegenerateResponse{ChatResponse: resp, Alternatives: alternatives(root)})
+}
+
+// artifactsHandler — GET /artifacts?chat_id=...&agent=...&limit=50 — файлы,
+// созданные агентами, новые первыми.
+func artifactsHandler(w http.ResponseWriter, r *http.Request) {
+	cid := r.Header.Get("X-Request-ID")
+	if r.Method != http.MethodGet {
+		apierror.MethodNotAllowed(w, cid)
+		return
+	}
+	q := r.URL.Query()
+	limit, _ := strconv.Atoi(q.Get("limit"))
+	if limit <= 0 || limit > 500 {
+		limit = 50
+	}
+	query := db.DB.Order("id DESC").Limit(limit)
+	if chatID := q.Get("chat_id"); chatID != "" {
+		query = query.Where("chat_id = ?", chatID)
+	}
+	if agent := q.Get("agent"); agent != "" {
+		query = query.Where("agent_name = ?", agent)
+	}
+	if kind := q.Get("kind"); kind != "" {
+		query = query.Where("kind = ?", kind)
+	}
+	var list []models.Artifact
+	if err := query.Find(&list).Error; err != nil {
+		apierror.InternalError(w, cid, "Ошибка чтения артефактов", "")
+		return
+	}
+	writeJSON(w, list)
+}
+
+// artifactHandler — один артефакт:
+//
+//	GET    /artifacts/{id}          — описание файла
+//	GET    /artifacts/{id}/download — содержимое (?inline=1 — для просмотра в браузере;
+//	                                  ?expires=...&sig=... — подписанная ссылка)
+//	POST   /artifacts/{id}/url      — подписанная ссылка на скачивание (?ttl=1h)
+//	DELETE /artifacts/{id}          — удалить запись и файл
+//
+// Показ в браузере (inline) разрешён только для растровых изображений и PDF;
+// остальное отдаётся как вложение, с nosniff и CSP sandbox.
+func artifactHandler(w http.ResponseWriter, r *http.Request) {
+	cid := r.Header.Get("X-Request-ID")
+	rest := strings.Trim(strings.TrimPrefix(r.URL.Path, "/artifacts/"), "/")
+	idPart, action, _ := strings.Cut(rest, "/")
+	id, err := strconv.ParseUint(idPart, 10, 64)
+	if err != nil || (action != "" && action != "download" && action != "url") {
+		apierror.NotFound(w, cid, "Артефакт не найден")
+		return
+	}
+	var a models.Artifact
+	if err := db.DB.First(&a, id).Error; err != nil {
+		apierror.NotFound(w, cid, "Артефакт не найден")
+		return
+	}
+	files := artifactFiles()
+	switch {
+	case r.Method == http.MethodGet && action == "download":
+		if !checkArtifactSignature(w, r, a.ID) {
+			return
+		}
+		path, err := files.Path(a.StorageKey)
+		if err != nil {
+			apierror.NotFound(w, cid, "Файл артефакта не найден")
+			return
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			apierror.NotFound(w, cid, "Файл артефакта не найден")
+			return
+		}
+		defer f.Close()
+		uploads.SetHeaders(w, a.Name, a.MimeType, r.URL.Query().Get("inline") == "1")
+		http.ServeContent(w, r, "", a.CreatedAt, f)
+	case r.Method == http.MethodPost && action == "url":
+		signer := artifactSigner()
+		if signer == nil {
+			apierror.BadRequest(w, cid, "Подписанные ссылки не настроены", "Задайте ARTIFACT_URL_SECRET")
+			return
+		}
+		ttl := config.Current().ArtifactURLTTL
+		if v := r.URL.Query().Get("ttl"); v != "" {
+			d, err := time.ParseDuration(v)
+			if err != nil || d <= 0 || d > maxArtifactURLTTL {
+				apierror.BadRequest(w, cid, "Некорректный срок действия ссылки", "Длительность от 1s до 168h, например ttl=1h")
+				return
+			}
+			ttl = d
+		}
+		u, expires := signer.URL(a.ID, ttl, time.Now())
+		writeJSON(w, map[string]interface{}{"url": u, "expires_at": expires})
+	case r.Method == http.MethodGet && action == "":
+		writeJSON(w, a)
+	case r.Method == http.MethodDelete && action == "":
+		if err := db.DB.Delete(&a).Error; err != nil {
+			apierror.InternalError(w, cid, "Не удалось удалить артефакт", "")
+			return
+		}
+		if err := files.Remove(a.StorageKey); err != nil {
+			slog.Warn("Не удалось удалить файл артефакта", slog.Uint64("артефакт", uint64(a.ID)), slog.String("ошибка", err.Error()))
+		}
+		writeJSON(w, map[string]interface{}{"status": "deleted", "id": a.ID})
+	default:
+		apierror.MethodNotAllowed(w, cid)
+	}
+}
+
+// maxArtifactURLTTL — наибольший срок действия подписанной ссылки (?ttl=).
+const maxArtifactURLTTL = 7 * 24 * time.Hour
+
+// checkArtifactSignature — проверяет подпись ссылки на скачивание артефакта
+// id, если она передана или обязательна (ARTIFACT_URL_REQUIRE_SIGNATURE).
+// При ошибке отвечает 403 и возвращает false.
+func checkArtifactSignature(w http.ResponseWriter, r *http.Request, id uint) bool {
+	q := r.URL.Query()
+	signed := q.Has("sig") || q.Has("expires")
+	if !signed && !config.Current().ArtifactURLRequireSig {
+		return true
+	}
+	err := artifact.ErrBadSignature
+	if s := artifactSigner(); s != nil {
+		err = s.Verify(id, q, time.Now())
+	}
+	if err == nil {
+		return true
+	}
+	apierror.Write(w, http.StatusForbidden, apierror.Response{
+		Code:      "FORBIDDEN",
+		Message:   err.Error(),
+		Hint:      "Запросите новую ссылку: POST /artifacts/{id}/url",
+		RequestID: r.Header.Get("X-Request-ID"),
+	})
+	return false
+}
+
+// RollbackRequest — тело POST /rollback: что откатить. Достаточно одного поля.
+type RollbackRequest struct {
+	MessageID uint   `json:"message_id,omitempty"` // Все изменения файлов в ответе ассистента
+	RequestID string `json:"request_id,omitempty"` // Все изменения за запрос /chat (X-Request-ID)
+	BackupIDs []uint `json:"backup_ids,omitempty"` // Выбранные копии
+}
+
+// RollbackResult — итог восстановления одного файла.
+type RollbackResult struct {
+	BackupID     uint   `json:"backup_id"`
+	Path         string `json:"path"`
+	Status       string `json:"status"`                   // restored | deleted | absent | error
+	UndoBackupID uint   `json:"undo_backup_id,omitempty"` // Копия версии до отката; нет — откат не отменить
+	Error        string `json:"error,omitempty"`
+}
+
+// restoreAll — откатывает копии по плану backup.Plan; ошибка одного файла
+// не прерывает остальные.
+func restoreAll(ctx context.Context, backups []models.FileBackup) []RollbackResult {
+	plan := backup.Plan(backups)
+	results := make([]RollbackResult, 0, len(plan))
+	for _, b := range plan {
+		res := RollbackResult{BackupID: b.ID, Path: b.Path}
+		status, undo, err := restoreBackup(ctx, b)
+		res.UndoBackupID = undo
+		if err != nil {
+			res.Status, res.Error = "error", err.Error()
+			slog.Warn("Откат файла не выполнен", slog.String("путь", b.Path), slog.String("ошибка", err.Error()))
+		} else {
+			res.Status = status
+			slog.Info("Файл откачен", slog.String("путь", b.Path), slog.String("действие", status))
+		}
+		results = append(results, res)
+	}
+	return results
+}
+
+// rollbackHandler — копии файлов, изменённых агентами:
+//
+//	GET  /rollback?chat_id=...&agent=...&message_id=...&limit=50 — список копий, новые первыми
+//	POST /rollback {message_id | request_id | backup_ids}      — откат группы изменений
+func rollbackHandler(w http.ResponseWriter, r *http.Request) {
+	cid := r.Header.Get("X-Request-ID")
+	switch r.Method {
+	case http.MethodGet:
+		q := r.URL.Query()
+		limit, _ := strconv.Atoi(q.Get("limit"))
+		if limit <= 0 || limit > 500 {
+			limit = 50
+		}
+		query := db.DB.Order("id DESC").Limit(limit)
+		if chatID := q.Get("chat_id"); chatID != "" {
+			query = query.Where("chat_id = ?", chatID)
+		}
+		if agent := q.Get("agent"); agent != "" {
+			query = query.Where("agent_name = ?", agent)
+		}
+		if messageID := q.Get("message_id"); messageID != "" {
+			query = query.Where("message_id = ?", messageID)
+		}
+		var list []models.FileBackup
+		if err := query.Find(&list).Error; err != nil {
+			apierror.InternalError(w, cid, "Ошибка чтения копий файлов", "")
+			return
+		}
+		writeJSON(w, list)
+	case http.MethodPost:
+		var req RollbackRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			apierror.BadRequest(w, cid, "Некорректный JSON", "")
+			return
+		}
+		query := db.DB.Order("id ASC")
+		switch {
+		case req.MessageID != 0:
+			query = query.Where("message_id = ?", req.MessageID)
+		case req.RequestID != "":
+			query = query.Where("request_id = ?", req.RequestID)
+		case len(req.BackupIDs) > 0:
+			query = query.Where("id IN ?", req.BackupIDs)
+		default:
+			apierror.BadRequest(w, cid, "Укажите message_id, request_id или backup_ids", "")
+			return
+		}
+		var backups []models.FileBackup
+		if err := query.Find(&backups).Error; err != nil {
+			apierror.InternalError(w, cid, "Ошибка чтения копий файлов", "")
+			return
+		}
+		if len(backups) == 0 {
+			apierror.NotFound(w, cid, "Копии файлов не найдены")
+			return
+		}
+		writeJSON(w, map[string]interface{}{"results": restoreAll(r.Context(), backups)})
+	default:
+		apierror.MethodNotAllowed(w, cid)
+	}
+}
+
+// rollbackItemHandler — одна копия:
+//
+//	GET  /rollback/{id} — описание и сохранённое содержимое
+//	POST /rollback/{id} — вернуть файл в состояние копии
+func rollbackItemHandler(w http.ResponseWriter, r *http.Request) {
+	cid := r.Header.Get("X-Request-ID")
+	id, err := strconv.ParseUint(strings.Trim(strings.TrimPrefix(r.URL.Path, "/rollback/"), "/"), 10, 64)
+	if err != nil {
+		apierror.NotFound(w, cid, "Копия файла не найдена")
+		return
+	}
+	var b models.FileBackup
+	if err := db.DB.First(&b, id).Error; err != nil {
+		apierror.NotFound(w, cid, "Копия файла не найдена")
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, struct {
+			models.FileBackup
+			Content string `json:"content"`
+		}{b, b.Content})
+	case http.MethodPost:
+		status, undo, err := restoreBackup(r.Context(), b)
+		if err != nil {
+			apierror.InternalError(w, cid, "Не удалось откатить файл", err.Error())
+			return
+		}
+		writeJSON(w, RollbackResult{BackupID: b.ID, Path: b.Path, Status: status, UndoBackupID: undo})
+	default:
+		apierror.MethodNotAllowed(w, cid)
+	}
+}
+
+// ConversationView — диалог в ответах /conversations. Title — имя, заданное
+// пользователем, или название, придуманное моделью.
+type ConversationView struct {
+	ID          string    `json:"id"`
+	Title       string    `json:"title"`
+	Name        string    `json:"name,omitempty"`
+	AutoTitle   string    `json:"auto_title,omitempty"`
+	Agent       string    `json:"agent,omitempty"`
+	WorkspaceID *uint     `json:"workspace_id,omitempty"`
+	Messages    int64     `json:"messages"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// ConversationMessage — реплика диалога в GET /conversations/{id}.
+type ConversationMessage struct {
+	ID        uint      `json:"id"`
+	Role      string    `json:"role"`
+	Content   string    `json:"content"`
+	Model     string    `json:"model,omitempty"`
+	Persona   string    `json:"persona,omitempty"` // Образ агента, которым дан ответ
+	CreatedAt time.Time `json:"created_at"`
+	// AlternativeOf — реплика является вариантом этого ответа (POST /chat/regenerate)
+	AlternativeOf *uint `json:"alternative_of,omitempty"`
+}
+
+// conversationViews — диалоги с числом реплик.
+func conversationViews(chats []models.Chat) []ConversationView {
+	ids := make([]string, len(chats))
+	for i, c := range chats {
+		ids[i] = c.ID
+	}
+	var counts []struct {
+		ChatID string
+		N      int64
+	}
+	if len(ids) > 0 {
+		db.DB.Model(&models.Message{}).Select("chat_id, COUNT(*) AS n").Where("chat_id IN ?", ids).Group("chat_id").Scan(&counts)
+	}
+	byChat := map[string]int64{}
+	for _, c := range counts {
+		byChat[c.ChatID] = c.N
+	}
+	out := make([]ConversationView, len(chats))
+	for i, c := range chats {
+		title := c.Name
+		if title == "" {
+			title = c.Title
+		}
+		out[i] = ConversationView{
+			ID: c.ID, Title: title, Name: c.Name, AutoTitle: c.Title, Agent: c.AgentName,
+			WorkspaceID: c.WorkspaceID, Messages: byChat[c.ID], CreatedAt: c.CreatedAt, UpdatedAt: c.UpdatedAt,
+		}
+	}
+	return out
+}
+
+// conversationsHandler — диалоги.
+//
+//	GET  /conversations?agent=admin — список, недавние первыми
+//	POST /conversations {"agent": "admin", "name": "", "workspace_id": 1} — новый диалог;
+//	     его id передаётся в /chat как chat_id, название появится после первого ответа
+func conversationsHandler(w http.ResponseWriter, r *http.Request) {
+	cid := r.Header.Get("X-Request-ID")
+	switch r.Method {
+	case http.MethodGet:
+		q := db.DB.Order("updated_at DESC")
+		if agent := r.URL.Query().Get("agent"); agent != "" {
+			q = q.Where("agent_name = ?", agent)
+		}
+		var chats []models.Chat
+		if err := q.Limit(200).Find(&chats).Error; err != nil {
+			apierror.InternalError(w, cid, "Ошибка чтения диалогов", "")
+			return
+		}
+		writeJSON(w, conversationViews(chats))
+	case http.MethodPost:
+		var req struct {
+			Agent       string `json:"agent"`
+			Name        string `json:"name"`
+			WorkspaceID *uint  `json:"workspace_id"`
+		}
+		if r.ContentLength != 0 {
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				apierror.BadRequest(w, cid, "Невалидный JSON", "Ожидается {\"agent\": \"admin\"}")
+				return
+			}
+		}
+		chat := models.Chat{AgentName: req.Agent, Name: strings.TrimSpace(req.Name), WorkspaceID: req.WorkspaceID}
+		if err := db.DB.Create(&chat).Error; err != nil {
+			slog.Error("Не удалось создать диалог", slog.String("ошибка", err.Error()), slog.String("request_id", cid))
+			apierror.InternalError(w, cid, "Не удалось создать диалог", "")
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		writeJSON(w, conversationViews([]models.Chat{chat})[0])
+	default:
+		apierror.MethodNotAllowed(w, cid)
+	}
+}
+
+// conversationHandler — один диалог: GET /conversations/{id} — с репликами,
+// PATCH /conversations/{id} {"name": "..."} — переименовать, DELETE — удалить.
+func conversationHandler(w http.ResponseWriter, r *http.Request) {
+	cid := r.Header.Get("X-Request-ID")
+	id := strings.Trim(strings.TrimPrefix(r.URL.Path, "/conversations/"), "/")
+	var chat models.Chat
+	if err := db.DB.Where("id = ?", id).First(&chat).Error; err != nil {
+		apierror.NotFound(w, cid, "Диалог не найден")
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		var msgs []models.Message
+		db.DB.Where("chat_id = ?", chat.ID).Order("id").Find(&msgs)
+		out := make([]ConversationMessage, len(msgs))
+		for i, m := range msgs {
+			out[i] = ConversationMessage{ID: m.ID, Role: m.Role, Content: m.Content, Model: m.LLMModel, Persona: m.Persona, CreatedAt: m.CreatedAt, AlternativeOf: m.AlternativeOf}
+		}
+		var files []models.Artifact
+		db.DB.Where("chat_id = ?", chat.ID).Order("id").Find(&files)
+		var changes []models.FileChange
+		db.DB.Where("chat_id = ?", chat.ID).Order("id").Find(&changes)
+		writeJSON(w, map[string]interface{}{"conversation": conversationViews([]models.Chat{chat})[0], "messages": out, "artifacts": files, "changes": changes})
+	case http.MethodPatch:
+		var req struct {
+			Name string `json:"name"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			apierror.BadRequest(w, cid, "Невалидный JSON", "Ожидается {\"name\": \"...\"}")
+			return
+		}
+		db.DB.Model(&chat).Update("name", strings.TrimSpace(req.Name))
+		writeJSON(w, conversationViews([]models.Chat{chat})[0])
+	case http.MethodDelete:
+		db.DB.Delete(&chat)
+		db.DB.Where("chat_id = ?", chat.ID).Delete(&models.EnvVar{})
+		writeJSON(w, map[string]interface{}{"status": "deleted", "id": chat.ID})
+	default:
+		apierror.MethodNotAllowed(w, cid)
+	}
+}
+
+// titleConversation — после первого обмена репликами дешёвая модель
+// (TITLE_MODEL, иначе ROUTER_FAST_MODEL, иначе модель агента) придумывает
+// название диалога. Если модель не ответила, название берётся из вопроса.
+// Вызывается в фоне; существующее название не перезаписывается.
+func titleConversation(ctx context.Context, chatID string, agent *models.Agent, question, answer string) {
+	var answers int64
+	db.DB.Model(&models.Message{}).Where("chat_id = ? AND role = ?", chatID, "assistant").Count(&answers)
+	if answers != 1 {
+		return
+	}
+	cfg := config.Current()
+	providerName, modelName := agent.Provider, agent.LLMModel
+	switch {
+	case cfg.TitleModel != "":
+		providerName, modelName = cfg.TitleProvider, cfg.TitleModel
+	case cfg.RouterFastModel != "":
+		providerName, modelName = cfg.RouterFastProvider, cfg.RouterFastModel
+	}
+	if providerName == "" {
+		providerName = "ollama"
+	}
+
+	title := ""
+	ctx, cancel := context.WithTimeout(ctx, time.Minute)
+	defer cancel()
+	provider, err := llm.GlobalRegistry.Get(providerName)
+	if err == nil {
+		var resp *llm.ChatResponse
+		if resp, err = chatWithRetry(ctx, provider, &llm.ChatRequest{Model: modelName, Messages: convtitle.Messages(question, answer)}); err == nil {
+			title = convtitle.Clean(reasoning.Strip(resp.Content))
+		}
+	}
+	if err != nil {
+		slog.Warn("Название диалога не сгенерировано", slog.String("диалог", chatID), slog.String("модель", providerName+"/"+modelName), slog.String("ошибка", err.Error()))
+	}
+	if title == "" {
+		title = convtitle.Fallback(question)
+	}
+	if title == "" {
+		return
+	}
+	res := db.DB.Model(&models.Chat{}).Where("id = ? AND (title = '' OR title IS NULL)", chatID).Update("title", title)
+	if res.Error != nil {
+		slog.Error("Не удалось сохранить название диалога", slog.String("диалог", chatID), slog.String("ошибка", res.Error.Error()))
+		return
+	}
+	slog.Info("Название диалога сгенерировано", slog.String("диалог", chatID), slog.String("название", title), slog.String("модель", modelName))
+}
+
+// memoryClient — запросы к memory-service без своего таймаута (сохранение и
+// поиск опыта, эпизодов, RAG) на общем пуле соединений.
+var memoryClient = httpclient.New("memory", 30*time.Second)
+
+// fetchModelLearnings — получение релевантных знаний модели из memory-service.
+// Вызывается перед каждым запросом к LLM. Найденные знания добавляются
+// к системному промпту, обогащая контекст модели накопленными знаниями.
+//
+// Система обучения работает следующим образом:
+//  1. Перед отправкой запроса к LLM берётся последнее сообщение пользователя
+//  2. По нему выполняется семантический поиск в базе знаний модели (ChromaDB)
+//  3. Кандидаты отбираются пакетом learnings: порог релевантности, лимит на категорию,
+//     бюджет токенов и отсев перефразированных дубликатов (LEARNINGS_* в конфигурации)
+//  4. Отобранные знания добавляются к системному промпту
+//
+// Параметры:
+//   - modelName: имя модели LLM (например, "llama3.1:8b")
+//   - query: текст запроса для семантического поиска (последнее сообщение пользователя)
+//
+// Возвращает:
+//   - []string: список отобранных знаний (может быть пустым)
+func fetchModelLearnings(modelName string, query string) []string {
+	cfg := config.Current()
+	memoryURL := cfg.MemoryServiceURL
+
+	reqBody := map[string]interface{}{
+		"query":      query,
+		"model_name": modelName,
+		"top_k":      cfg.LearningsTopK,
+		// Закреплённые пользователем знания подставляются независимо от релевантности
+		"include_pinned": true,
+	}
+	data, err := json.Marshal(reqBody)
+	if err != nil {
+		slog.Error("Ошибка сериализации запроса знаний", slog.String("ошибка", err.Error()))
+		return nil
+	}
+
+	resp, err := memoryClient.Post(memoryURL+"/learnings/search", "application/json", bytes.NewReader(data))
+	if err != nil {
+		slog.Error("Ошибка запроса знаний из memory-service", slog.String("ошибка", err.Error()))
+		return nil
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		slog.Warn("memory-service вернул ошибку при поиске знаний", slog.Int("статус", resp.StatusCode))
+		return nil
+	}
+
+	var result struct {
+		Results []struct {
+			Text     string                 `json:"text"`
+			Score    float64                `json:"score"`
+			Source   string                 `json:"source"`
+			Metadata map[string]interface{} `json:"metadata"`
+		} `json:"results"`
+		Count     int    `json:"count"`
+		ModelName string `json:"model_name"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		slog.Error("Ошибка декодирования ответа знаний", slog.String("ошибка", err.Error()))
+		return nil
+	}
+
+	cands := make([]learnings.Candidate, 0, len(result.Results))
+	for _, r := range result.Results {
+		category, _ := r.Metadata["category"].(string)
+		pinned := r.Source == "pinned" || r.Metadata["pinned"] == "true"
+		cands = append(cands, learnings.Candidate{Text: r.Text, Score: r.Score, Category: category, Pinned: pinned})
+	}
+	sel := learnings.Select(cands, learnings.Limits{
+		MinScore:       cfg.LearningsMinScore,
+		MaxPerCategory: cfg.LearningsMaxPerCategory,
+		TokenBudget:    cfg.LearningsTokenBudget,
+	})
+	if len(result.Results) > 0 {
+		slog.Info("Знания найдены",
+			slog.Int("кандидатов", len(result.Results)),
+			slog.Int("отобрано", len(sel.Selected)),
+			slog.Int("токенов", sel.Tokens),
+			slog.Any("отсеяно", sel.Dropped),
+			slog.String("модель", modelName))
+	}
+	texts := make([]string, 0, len(sel.Selected))
+	for _, c := range sel.Selected {
+		texts = append(texts, c.Text)
+	}
+	return texts
+}
+
+// fetchEpisodes — поиск прошлых эпизодов агента, похожих на текущий запрос
+// (POST /episodes/search memory-service). Ошибки не прерывают чат.
+func fetchEpisodes(agentName, query string) []episodic.Episode {
+	cfg := config.Current()
+	data, err := json.Marshal(map[string]interface{}{
+		"query":      query,
+		"agent_name": agentName,
+		"top_k":      cfg.EpisodicTopK,
+		"min_score":  cfg.EpisodicMinScore,
+	})
+	if err != nil {
+		return nil
+	}
+	resp, err := memoryClient.Post(cfg.MemoryServiceURL+"/episodes/search", "application/json", bytes.NewReader(data))
+	if err != nil {
+		slog.Error("Ошибка поиска эпизодов в memory-service", slog.String("ошибка", err.Error()))
+		return nil
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		slog.Warn("memory-service вернул ошибку при поиске эпизодов", slog.Int("статус", resp.StatusCode))
+		return nil
+	}
+	var result struct {
+		Results []struct {
+			Text     string                 `json:"text"`
+			Score    float64                `json:"score"`
+			Metadata map[string]interface{} `json:"metadata"`
+		} `json:"results"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		slog.Error("Ошибка декодирования эпизодов", slog.String("ошибка", err.Error()))
+		return nil
+	}
+	episodes := make([]episodic.Episode, 0, len(result.Results))
+	for _, r := range result.Results {
+		ep := episodic.Episode{Text: r.Text, Score: r.Score}
+		if ts, ok := r.Metadata["created_at"].(string); ok {
+			ep.CreatedAt, _ = time.Parse(time.RFC3339Nano, ts)
+		}
+		episodes = append(episodes, ep)
+	}
+	if len(episodes) > 0 {
+		slog.Info("Найдены похожие прошлые эпизоды", slog.Int("количество", len(episodes)), slog.String("агент", agentName))
+	}
+	return episodes
+}
+
+// storeEpisode — сохранение резюме завершённого диалога как эпизода (POST /episodes).
+func storeEpisode(agentName, modelName, summary string, messageID uint) {
+	if summary == "" {
+		return
+	}
+	data, err := json.Marshal(map[string]interface{}{
+		"summary":    summary,
+		"agent_name": agentName,
+		"model_name": modelName,
+		"metadata":   map[string]interface{}{"message_id": messageID},
+	})
+	if err != nil {
+		return
+	}
+	resp, err := memoryClient.Post(config.Current().MemoryServiceURL+"/episodes", "application/json", bytes.NewReader(data))
+	if err != nil {
+		slog.Error("Ошибка сохранения эпизода в memory-service", slog.String("ошибка", err.Error()))
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		slog.Warn("memory-service вернул ошибку при сохранении эпизода", slog.Int("статус", resp.StatusCode))
+	}
+}
+
+// learningsEnabledFor — подставлять и извлекать знания для агента:
+// должны быть включены и глобально (LEARNINGS_ENABLED), и у самого агента.
+func learningsEnabledFor(agent *models.Agent) bool {
+	return config.Current().LearningsEnabled && agent.LearningsEnabled
+}
+
+// fetchRelevantSkills — получение релевантных навыков из Skill Engine.
+// Вызывается в chat pipeline перед запросом к LLM для обогащения контекста.
+// Навыки содержат цель, шаги, примеры и ограничения — структурированные знания,
+// которые помогают модели решать задачи точнее.
 //
 // Параметры:
 //   - query: текст запроса пользователя для семантического поиска
 //   - topK: максимум навыков для возврата
 //
-// Возвращает: отформатированную строку с навыками для вставки в системный промпт
-func fetchRelevantSkills(query string, topK int) string {
-	memoryURL := getEnv("MEMORY_SERVICE_URL", "http://localhost:8001")
+// Возвращает: отформатированную строку с навыками для вставки в системный промпт
+func fetchRelevantSkills(query string, topK int) string {
+	memoryURL := config.Current().MemoryServiceURL
+
+	reqBody := map[string]interface{}{
+		"query": query,
+		"top_k": topK,
+	}
+	data, err := json.Marshal(reqBody)
+	if err != nil {
+		slog.Error("[SKILL-FETCH] ошибка сериализации", slog.String("ошибка", err.Error()))
+		return ""
+	}
+
+	client := httpclient.New("memory", 10*time.Second)
+	resp, err := client.Post(memoryURL+"/skills/search", "application/json", bytes.NewReader(data))
+	if err != nil {
+		slog.Warn("[SKILL-FETCH] memory-service недоступен", slog.String("ошибка", err.Error()))
+		return ""
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		slog.Warn("[SKILL-FETCH] ошибка ответа", slog.Int("статус", resp.StatusCode))
+		return ""
+	}
+
+	var result struct {
+		Results []struct {
+			Goal        string   `json:"goal"`
+			Steps       []string `json:"steps"`
+			Examples    []string `json:"examples"`
+			Constraints []string `json:"constraints"`
+			Confidence  float64  `json:"confidence"`
+			Relevance   float64  `json:"relevance"`
+			ID          string   `json:"id"`
+		} `json:"results"`
+		Count int `json:"count"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		slog.Error("[SKILL-FETCH] ошибка декодирования", slog.String("ошибка", err.Error()))
+		return ""
+	}
+
+	if len(result.Results) == 0 {
+		return ""
+	}
+
+	// Форматируем навыки для добавления в системный промпт
+	skillContext := "\n\n=== Навыки агента (Skill Engine) ===\n"
+	for i, s := range result.Results {
+		skillContext += fmt.Sprintf("[Навык %d] %s (confidence: %.0f%%)\n", i+1, s.Goal, s.Confidence*100)
+		if len(s.Steps) > 0 {
+			skillContext += "  Шаги: "
+			for j, step := range s.Steps {
+				if j > 0 {
+					skillContext += " → "
+				}
+				skillContext += step
+			}
+			skillContext += "\n"
+		}
+		if len(s.Constraints) > 0 {
+			skillContext += "  Ограничения: " + strings.Join(s.Constraints, "; ") + "\n"
+		}
+		// Фиксируем использование навыка асинхронно
+		go recordSkillUsage(memoryURL, s.ID)
+	}
+	skillContext += "Применяй эти навыки при решении задач пользователя.\n"
+
+	slog.Info("[SKILL-FETCH] навыки найдены", slog.Int("количество", len(result.Results)))
+	return skillContext
+}
+
+// recordSkillUsage — асинхронная фиксация использования навыка.
+// Увеличивает usage_count и confidence навыка в memory-service.
+func recordSkillUsage(memoryURL, skillID string) {
+	client := httpclient.New("memory", 5*time.Second)
+	req, err := http.NewRequest("POST", memoryURL+"/skills/"+skillID+"/usage", nil)
+	if err != nil {
+		return
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		slog.Warn("[SKILL-USAGE] ошибка записи", slog.String("skill_id", skillID), slog.String("ошибка", err.Error()))
+		return
+	}
+	defer resp.Body.Close()
+}
+
+// extractAndStoreLearnings — извлечение и сохранение знаний из диалога.
+// Вызывается асинхронно после каждого успешного ответа от LLM: в горутине
+// или заданием очереди learning_extract (JOBS_QUEUE), которое повторяется,
+// если memory-service недоступен.
+//
+// Алгоритм извлечения знаний:
+//  1. Анализ последнего сообщения пользователя и ответа агента
+//  2. Определение категории знания:
+//     - preference: предпочтения пользователя (язык, стиль, формат)
+//     - fact: факты о системе, окружении, проекте
+//     - correction: исправления и уточнения от пользователя
+//     - skill: успешные подходы к решению задач
+//     - general: прочие полезные знания
+//  3. Формирование текста знания и отправка в memory-service
+//  4. Для категории "skill" — дополнительно создаёт навык через Skill Engine
+//  5. Создание связей в Graph Engine между новым и существующими знаниями
+//
+// Знания привязываются к конкретной модели (modelName), а не к агенту,
+// потому что при смене агента модель сохраняет свои знания.
+//
+// Параметры:
+//   - modelName: имя модели LLM
+//   - agentName: имя агента (admin)
+//   - userMsg: последнее сообщение пользователя
+//   - assistantResp: ответ агента
+func extractAndStoreLearnings(modelName, agentName, userMsg, assistantResp string) error {
+	memoryURL := config.Current().MemoryServiceURL
+
+	// Определяем категорию знания на основе содержания диалога
+	category := classifyLearningCategory(userMsg, assistantResp)
+
+	// Формируем текст знания — компактное резюме взаимодействия
+	learningText := formatLearningText(userMsg, assistantResp, category)
+	if learningText == "" {
+		return nil
+	}
+
+	reqBody := map[string]interface{}{
+		"text":       learningText,
+		"model_name": modelName,
+		"agent_name": agentName,
+		"category":   category,
+		"metadata": map[string]interface{}{
+			"source": "auto_extract",
+		},
+	}
+	data, err := json.Marshal(reqBody)
+	if err != nil {
+		slog.Error("Ошибка сериализации знания", slog.String("ошибка", err.Error()))
+		return err
+	}
+
+	resp, err := memoryClient.Post(memoryURL+"/learnings", "application/json", bytes.NewReader(data))
+	if err != nil {
+		slog.Error("Ошибка сохранения знания в memory-service", slog.String("ошибка", err.Error()))
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		slog.Warn("memory-service вернул ошибку при сохранении знания", slog.Int("статус", resp.StatusCode))
+		return fmt.Errorf("memory-service: статус %d", resp.StatusCode)
+	}
+
+	// Декодируем ответ для получения ID знания (нужен для связей в графе)
+	var learningResp struct {
+		ID string `json:"id"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&learningResp); err != nil {
+		slog.Warn("Ошибка декодирования ответа learning", slog.String("ошибка", err.Error()))
+	}
+
+	slog.Info("Знание сохранено", slog.String("модель", modelName), slog.String("категория", category), slog.String("id", learningResp.ID))
+
+	// === Автоматическое создание навыка из диалога (Eternal RAG: раздел 7) ===
+	// Если категория — "skill", создаём структурированный навык через Skill Engine.
+	// Навык содержит цель, шаги, примеры — извлечённые из текста диалога.
+	if category == "skill" {
+		go createSkillFromDialog(memoryURL, userMsg+"\n"+assistantResp, modelName)
+	}
+
+	// === Автоматическое создание связей в Graph Engine ===
+	// Если знание связано с противоречием, создаём связь "contradicts" в графе.
+	// Это позволяет обходить граф знаний и находить конфликтующие факты.
+	if learningResp.ID != "" {
+		go autoCreateGraphRelationships(memoryURL, learningResp.ID, learningText, modelName)
+	}
+	return nil
+}
+
+// createSkillFromDialog — создание навыка из текста диалога через Skill Engine.
+// Вызывается асинхронно когда extractAndStoreLearnings обнаруживает категорию "skill".
+func createSkillFromDialog(memoryURL, dialogText, modelName string) {
+	reqBody := map[string]interface{}{
+		"dialog_text": dialogText,
+		"model_name":  modelName,
+	}
+	data, err := json.Marshal(reqBody)
+	if err != nil {
+		slog.Error("[SKILL-CREATE] ошибка сериализации", slog.String("ошибка", err.Error()))
+		return
+	}
+
+	client := httpclient.New("memory", 15*time.Second)
+	resp, err := client.Post(memoryURL+"/skills/from-dialog", "application/json", bytes.NewReader(data))
+	if err != nil {
+		slog.Warn("[SKILL-CREATE] ошибка создания навыка", slog.String("ошибка", err.Error()))
+		return
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusOK {
+		slog.Info("[SKILL-CREATE] навык создан из диалога", slog.String("модель", modelName))
+	} else {
+		slog.Warn("[SKILL-CREATE] ошибка ответа", slog.Int("статус", resp.StatusCode))
+	}
+}
+
+// autoCreateGraphRelationships — автоматическое создание связей в графе знаний.
+// После сохранения нового знания ищем похожие существующие знания
+// и создаём связи "relates_to" между ними (Eternal RAG: раздел 5.4).
+func autoCreateGraphRelationships(memoryURL, learningID, learningText, modelName string) {
+	// Ищем похожие знания для создания связей
+	reqBody := map[string]interface{}{
+		"query":      learningText,
+		"model_name": modelName,
+		"top_k":      3,
+	}
+	data, err := json.Marshal(reqBody)
+	if err != nil {
+		return
+	}
+
+	client := httpclient.New("memory", 10*time.Second)
+	resp, err := client.Post(memoryURL+"/learnings/search", "application/json", bytes.NewReader(data))
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return
+	}
+
+	var searchResult struct {
+		Results []struct {
+			ID    string  `json:"id"`
+			Score float64 `json:"score"`
+		} `json:"results"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&searchResult); err != nil {
+		return
+	}
+
+	// Создаём связи "relates_to" к похожим знаниям (score > 0.7, исключая само знание)
+	minRelateScore := 0.7
+	for _, r := range searchResult.Results {
+		if r.ID == learningID || r.Score < minRelateScore {
+			continue
+		}
+		relBody := map[string]interface{}{
+			"source_id":         learningID,
+			"target_id":         r.ID,
+			"relationship_type": "relates_to",
+			"source_type":       "knowledge",
+			"target_type":       "knowledge",
+			"metadata": map[string]interface{}{
+				"auto_created": true,
+				"similarity":   r.Score,
+			},
+		}
+		relData, err := json.Marshal(relBody)
+		if err != nil {
+			continue
+		}
+		relResp, err := client.Post(memoryURL+"/graph/relationships", "application/json", bytes.NewReader(relData))
+		if err != nil {
+			slog.Warn("[GRAPH-AUTO] ошибка создания связи", slog.String("ошибка", err.Error()))
+			continue
+		}
+		relResp.Body.Close()
+		slog.Info("[GRAPH-AUTO] связь создана", slog.String("source", learningID), slog.String("target", r.ID))
+	}
+}
+
+// classifyLearningCategory — автоматическая классификация категории знания.
+// Анализирует текст сообщения пользователя и ответа агента,
+// определяя к какой категории относится извлечённое знание.
+//
+// Категории:
+//   - "preference": пользователь выражает предпочтение (язык, стиль, формат)
+//   - "correction": пользователь исправляет агента или указывает на ошибку
+//   - "fact": обмен фактической информацией о системе/окружении
+//   - "skill": описание решения задачи, алгоритма, подхода
+//   - "general": прочие знания, не попадающие в другие категории
+func classifyLearningCategory(userMsg, assistantResp string) string {
+	lowerUser := strings.ToLower(userMsg)
+
+	// Исправления и коррекции
+	correctionKeywords := []string{"неправильно", "не так", "ошибка", "исправь", "wrong", "fix",
+		"не верно", "неверно", "не правильно", "ты ошибся", "это не то"}
+	for _, kw := range correctionKeywords {
+		if strings.Contains(lowerUser, kw) {
+			return "correction"
+		}
+	}
+
+	// Предпочтения пользователя
+	preferenceKeywords := []string{"всегда", "по-русски", "на русском", "по русски",
+		"предпочитаю", "мне нравится", "хочу чтобы", "используй", "формат",
+		"отвечай", "пиши", "стиль"}
+	for _, kw := range preferenceKeywords {
+		if strings.Contains(lowerUser, kw) {
+			return "preference"
+		}
+	}
+
+	// Факты о системе
+	factKeywords := []string{"у меня", "мой компьютер", "моя система", "установлен",
+		"версия", "конфигурация", "характеристики", "параметры", "specs"}
+	for _, kw := range factKeywords {
+		if strings.Contains(lowerUser, kw) {
+			return "fact"
+		}
+	}
+
+	// Навыки и подходы
+	skillKeywords := []string{"как сделать", "решение", "алгоритм", "подход",
+		"метод", "способ", "инструкция", "tutorial", "how to"}
+	for _, kw := range skillKeywords {
+		if strings.Contains(lowerUser, kw) {
+			return "skill"
+		}
+	}
+
+	return "general"
+}
+
+// formatLearningText — формирование текста знания из диалога.
+// Создаёт компактное резюме взаимодействия, которое будет сохранено
+// в базе знаний модели и использовано в будущих диалогах.
+//
+// Для разных категорий формат различается:
+//   - correction: фиксируется что было неправильно и как исправлено
+//   - preference: фиксируется предпочтение пользователя
+//   - fact: фиксируется факт о системе/окружении
+//   - skill/general: краткое резюме взаимодействия
+//
+// Фильтрация: слишком короткие сообщения (< 10 символов) игнорируются,
+// так как из них невозможно извлечь полезное знание.
+func formatLearningText(userMsg, assistantResp, category string) string {
+	if len(userMsg) < 10 {
+		return ""
+	}
+
+	// Ограничиваем длину для компактности
+	maxUserLen := 200
+	maxRespLen := 300
+	if len(userMsg) > maxUserLen {
+		userMsg = userMsg[:maxUserLen] + "..."
+	}
+	if len(assistantResp) > maxRespLen {
+		assistantResp = assistantResp[:maxRespLen] + "..."
+	}
+
+	switch category {
+	case "correction":
+		return "Пользователь указал на ошибку: " + userMsg + " | Исправленный ответ: " + assistantResp
+	case "preference":
+		return "Предпочтение пользователя: " + userMsg
+	case "fact":
+		return "Факт о системе: " + userMsg + " | Контекст: " + assistantResp
+	case "skill":
+		return "Решение задачи: " + userMsg + " | Подход: " + assistantResp
+	default:
+		return "Контекст диалога: " + userMsg + " | Ответ: " + assistantResp
+	}
+}
+
+// learningStatsHandler — получение статистики обучения из memory-service (GET /learning-stats).
+// Проксирует запрос к memory-service /learnings/stats и возвращает результат.
+// Показывает общее количество знаний, разбивку по моделям и категориям.
+func learningStatsHandler(w http.ResponseWriter, r *http.Request) {
+	cid := r.Header.Get("X-Request-ID")
+	if r.Method != http.MethodGet {
+		apierror.MethodNotAllowed(w, cid)
+		return
+	}
+
+	memoryURL := config.Current().MemoryServiceURL
+	resp, err := memoryClient.Get(memoryURL + "/learnings/stats")
+	if err != nil {
+		apierror.InternalError(w, cid, "Ошибка подключения к memory-service", err.Error())
+		return
+	}
+	defer resp.Body.Close()
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(resp.StatusCode)
+	io.Copy(w, resp.Body)
+}
+
+// providersHandler — управление облачными LLM-провайдерами (GET/POST /providers).
+//
+// GET — возвращает список всех провайдеров с их статусом:
+//   - ollama (всегда включён, локальный)
+//   - openai, anthropic, yandexgpt, gigachat (облачные)
+//     Для каждого провайдера возвращается: имя, включён ли, есть ли API-ключ,
+//     список доступных моделей (если провайдер активен).
+//
+// POST — сохранение/обновление конфигурации провайдера:
+//
+//	Принимает JSON с полями: provider, api_key, base_url, folder_id, scope, enabled.
+//	Сохраняет конфигурацию в PostgreSQL и регистрирует провайдера в реестре.
+//	Поля обновляются выборочно — пустые значения не перезаписывают существующие.
+//
+// Используется UI для настройки облачных провайдеров в панели моделей.
+func providersHandler(w http.ResponseWriter, r *http.Request) {
+	cid := r.Header.Get("X-Request-ID")
+	switch r.Method {
+	case http.MethodGet:
+		var configs []models.ProviderConfig
+		db.DB.Find(&configs)
+
+		type ProviderResponse struct {
+			Name         string              `json:"name"`
+			Enabled      bool                `json:"enabled"`
+			Models       []string            `json:"models"`
+			ModelsDetail []llm.ModelDetail   `json:"models_detail"`
+			HasKey       bool                `json:"hasKey"`
+			Guide        providerguide.Guide `json:"guide"`
+		}
+
+		var result []ProviderResponse
+
+		// Ollama — может быть как локальным, так и удалённым (через API)
+		ollamaResp := ProviderResponse{
+			Name:    "ollama",
+			Enabled: true,
+			HasKey:  true,
+			Guide:   providerGuide(r, "ollama"),
+		}
+		if ollamaProvider, ollamaErr := llm.GlobalRegistry.Get("ollama"); ollamaErr == nil {
+			if ollamaModelList, listErr := ollamaProvider.ListModels(); listErr == nil {
+				ollamaResp.Models = ollamaModelList
+			}
+			if ollamaDetailed, detailErr := ollamaProvider.ListModelsDetailed(); detailErr == nil {
+				ollamaResp.ModelsDetail = ollamaDetailed
+			}
+		}
+		result = append(result, ollamaResp)
+
+		cloudProviders := []string{"yandexgpt", "gigachat"}
+		for _, name := range cloudProviders {
+			pr := ProviderResponse{Name: name, Guide: providerGuide(r, name)}
+			for _, cfg := range configs {
+				if cfg.ProviderName == name {
+					pr.Enabled = cfg.Enabled
+					pr.HasKey = cfg.APIKey != "" || cfg.ServiceAccountJSON != ""
+					break
+				}
+			}
+			p, err := llm.GlobalRegistry.Get(name)
+			if err == nil {
+				pr.HasKey = true
+				modelNames, _ := p.ListModels()
+				pr.Models = modelNames
+				detailed, _ := p.ListModelsDetailed()
+				pr.ModelsDetail = detailed
+			}
+			result = append(result, pr)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-cache, no-store, must-revalidate")
+		w.Header().Set("Pragma", "no-cache")
+		writeJSON(w, result)
+
+	case http.MethodPost:
+		var req struct {
+			Provider           string `json:"provider"`
+			APIKey             string `json:"api_key"`
+			BaseURL            string `json:"base_url"`
+			FolderID           string `json:"folder_id"`
+			Scope              string `json:"scope"`
+			ServiceAccountJSON string `json:"service_account_json"`
+			Enabled            bool   `json:"enabled"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			apierror.BadRequest(w, cid, "Невалидный JSON", "")
+			return
+		}
+		if req.Provider == "" {
+			apierror.BadRequest(w, cid, "Требуется provider", "")
+			return
+		}
+		// Токены GitHub/GitLab/Jira для git_pull_request и import_issue, а также
+		// умный дом (homeassistant, mqtt): не LLM, регистрация не нужна
+		if req.Provider == gitflow.ProviderGitHub || req.Provider == gitflow.ProviderGitLab || req.Provider == issues.TrackerJira ||
+			req.Provider == smarthome.ProviderHomeAssistant || req.Provider == smarthome.ProviderMQTT {
+			var cfg models.ProviderConfig
+			db.DB.Where("provider_name = ?", req.Provider).FirstOrCreate(&cfg, models.ProviderConfig{ProviderName: req.Provider})
+			if req.APIKey != "" {
+				cfg.APIKey = req.APIKey
+			}
+			cfg.BaseURL = req.BaseURL
+			cfg.Enabled = req.Enabled
+			switch {
+			case req.Provider == smarthome.ProviderMQTT:
+				if _, err := smarthome.ParseMQTT(cfg.BaseURL, cfg.APIKey); err != nil {
+					apierror.BadRequest(w, cid, err.Error(), "base_url — адрес брокера mqtt://host:1883 или mqtts://host:8883; api_key — user:password, если брокер требует вход")
+					return
+				}
+			case req.Provider == smarthome.ProviderHomeAssistant && (cfg.APIKey == "" || cfg.BaseURL == ""):
+				apierror.BadRequest(w, cid, "Требуются api_key и base_url", "Long-lived access token из профиля Home Assistant и адрес вида http://homeassistant.local:8123")
+				return
+			case cfg.APIKey == "":
+				apierror.BadRequest(w, cid, "Требуется api_key", "Personal access token с правами repo (GitHub) или api (GitLab); для Jira Cloud — email:api_token")
+				return
+			}
+			if err := db.DB.Save(&cfg).Error; err != nil {
+				apierror.InternalError(w, cid, "Не удалось сохранить конфигурацию", "")
+				return
+			}
+			writeJSON(w, map[string]interface{}{"status": "ok"})
+			return
+		}
+
+		// Шаг 1: Регистрируем провайдера временно для проверки ключа
+		extra := req.FolderID
+		if req.Scope != "" {
+			extra = req.Scope
+		}
+		apiKey := req.APIKey
+		saJSON := req.ServiceAccountJSON
+		if apiKey == "" || saJSON == "" {
+			var existingCfg models.ProviderConfig
+			db.DB.Where("provider_name = ?", req.Provider).First(&existingCfg)
+			if apiKey == "" {
+				apiKey = existingCfg.APIKey
+			}
+			if saJSON == "" {
+				saJSON = existingCfg.ServiceAccountJSON
+			}
+		}
+		if apiKey == "" && saJSON == "" && req.Provider != "lmstudio" && req.Provider != "ollama" {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			writeJSON(w, map[string]interface{}{
+				"status": "error",
+				"error":  "API-ключ или JSON сервисного аккаунта не указан",
+				"hint":   providerGuide(r, req.Provider).Hint,
+			})
+			return
+		}
+
+		if err := llm.RegisterProvider(req.Provider, apiKey, req.BaseURL, extra, saJSON); err != nil {
+			slog.Error("Ошибка регистрации провайдера", slog.String("провайдер", req.Provider), slog.String("ошибка", err.Error()))
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			writeJSON(w, map[string]interface{}{
+				"status": "error",
+				"error":  fmt.Sprintf("Не удалось зарегистрировать провайдер: %v", err),
+				"hint":   providerGuide(r, req.Provider).Hint,
+			})
+			return
+		}
+
+		// Шаг 2: Проверяем ключ — запрашиваем список моделей
+		p, err := llm.GlobalRegistry.Get(req.Provider)
+		if err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusInternalServerError)
+			writeJSON(w, map[string]interface{}{
+				"status": "error",
+				"error":  "Провайдер не найден после регистрации",
+				"hint":   providerGuide(r, req.Provider).Hint,
+			})
+			return
+		}
+
+		if req.Provider == "yandexgpt" {
+			if yp, ok := p.(*llm.YandexGPTProvider); ok {
+				if err := yp.Validate(); err != nil {
+					slog.Warn("Проверка провайдера не пройдена (горутина)", slog.String("провайдер", req.Provider), slog.String("ошибка", err.Error()))
+					w.Header().Set("Content-Type", "application/json")
+					w.WriteHeader(http.StatusBadRequest)
+					writeJSON(w, map[string]interface{}{
+						"status": "error",
+						"error":  fmt.Sprintf("Ключ/настройки не прошли проверку: %v", err),
+						"hint":   providerGuide(r, req.Provider).Hint,
+					})
+					return
+				}
+				if resolved := yp.GetResolvedFolderID(); resolved != "" && req.FolderID == "" {
+					req.FolderID = resolved
+				}
+			}
+		}
+
+		verifyModels, verifyErr := p.ListModels()
+		if verifyErr != nil {
+			slog.Warn("Проверка провайдера не пройдена", slog.String("провайдер", req.Provider), slog.String("ошибка", verifyErr.Error()))
+			WriteSystemLog("error", "agent-service", fmt.Sprintf("Провайдер %s: ключ не прошёл проверку", req.Provider), verifyErr.Error())
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusUnauthorized)
+			writeJSON(w, map[string]interface{}{
+				"status": "error",
+				"error":  fmt.Sprintf("Ключ не прошёл проверку: %v", verifyErr),
+				"hint":   providerGuide(r, req.Provider).Hint,
+			})
+			return
+		}
+		slog.Info("Провайдер проверен", slog.String("провайдер", req.Provider), slog.Int("моделей", len(verifyModels)))
+		WriteSystemLog("info", "agent-service", fmt.Sprintf("Провайдер %s подключён, моделей: %d", req.Provider, len(verifyModels)), "")
+
+		// Шаг 3: Ключ прошёл проверку — сохраняем в БД
+		var cfg models.ProviderConfig
+		db.DB.Where("provider_name = ?", req.Provider).FirstOrCreate(&cfg, models.ProviderConfig{ProviderName: req.Provider})
+		cfg.APIKey = apiKey
+		if req.BaseURL != "" {
+			cfg.BaseURL = req.BaseURL
+		}
+		if req.FolderID != "" {
+			cfg.FolderID = req.FolderID
+		}
+		if req.Scope != "" {
+			cfg.Scope = req.Scope
+		}
+		if saJSON != "" {
+			cfg.ServiceAccountJSON = saJSON
+		}
+		cfg.Enabled = req.Enabled
+
+		if err := db.DB.Save(&cfg).Error; err != nil {
+			apierror.InternalError(w, cid, "Не удалось сохранить конфигурацию", "")
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		writeJSON(w, map[string]interface{}{
+			"status": "ok",
+			"models": verifyModels,
+		})
+
+	default:
+		apierror.MethodNotAllowed(w, cid)
+	}
+}
+
+// providerGuide — руководство и подсказка провайдера на языке запроса
+// (встроенные тексты или PROVIDER_GUIDES_DIR, см. пакет providerguide).
+func providerGuide(r *http.Request, provider string) providerguide.Guide {
+	g, _ := providerguide.Load(config.Current().ProviderGuidesDir, provider, i18n.FromContext(r.Context()))
+	return g
+}
+
+// providerResourceHandler — ресурсы провайдера: GET /providers/{name}/guide
+// (руководство) и GET /providers/{name}/quota (остаток лимитов).
+func providerResourceHandler(w http.ResponseWriter, r *http.Request) {
+	cid := r.Header.Get("X-Request-ID")
+	name, resource, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/providers/"), "/")
+	if name == "" || (resource != "guide" && resource != "quota") {
+		apierror.NotFound(w, cid, "Маршрут не найден")
+		return
+	}
+	if r.Method != http.MethodGet {
+		apierror.MethodNotAllowed(w, cid)
+		return
+	}
+	if resource == "quota" {
+		providerQuotaHandler(w, r, name)
+		return
+	}
+	providerGuideHandler(w, r, name)
+}
+
+// providerGuideHandler — руководство по провайдеру. UI загружает его отдельно
+// от списка провайдеров; тексты правятся в PROVIDER_GUIDES_DIR без пересборки сервиса.
+func providerGuideHandler(w http.ResponseWriter, r *http.Request, name string) {
+	cid := r.Header.Get("X-Request-ID")
+	lang := i18n.FromContext(r.Context())
+	guide, found := providerguide.Load(config.Current().ProviderGuidesDir, name, lang)
+	if !found {
+		apierror.NotFound(w, cid, "Руководство по провайдеру не найдено")
+		return
+	}
+	writeJSON(w, struct {
+		Provider string `json:"provider"`
+		Language string `json:"language"`
+		providerguide.Guide
+	}{name, lang, guide})
+}
+
+// providerQuotaHandler — остаток лимитов провайдера из его биллингового API
+// (OpenRouter — кредиты, GigaChat — токены) или заголовков лимитов (Cerebras).
+// Интерфейс показывает его заранее, чтобы задача не оборвалась на 429.
+func providerQuotaHandler(w http.ResponseWriter, r *http.Request, name string) {
+	cid := r.Header.Get("X-Request-ID")
+	p, err := llm.GlobalRegistry.Get(name)
+	if err != nil {
+		apierror.NotFound(w, cid, "Провайдер не настроен")
+		return
+	}
+	q, err := providerQuotas.fetch(p)
+	if errors.Is(err, llm.ErrQuotaUnsupported) {
+		apierror.BadRequest(w, cid, "Провайдер не сообщает остаток лимитов", "Остаток доступен для openrouter, cerebras и gigachat")
+		return
+	}
+	if err != nil {
+		slog.Warn("Не удалось получить остаток лимитов", slog.String("провайдер", name), slog.String("ошибка", err.Error()))
+		apierror.LLMError(w, cid, "Не удалось получить остаток лимитов", llm.TranslateLLMError(err.Error()))
+		return
+	}
+	writeJSON(w, struct {
+		*llm.Quota
+		Warning string `json:"warning,omitempty"`
+	}{q, i18n.Translate(i18n.FromContext(r.Context()), quotaWarning(q, config.Current().ProviderQuotaWarn))})
+}
+
+// quotaCache — последний известный остаток лимитов провайдеров.
+type quotaCache struct {
+	mu         sync.Mutex
+	quotas     map[string]*llm.Quota
+	refreshing map[string]bool
+}
+
+var providerQuotas = &quotaCache{quotas: map[string]*llm.Quota{}, refreshing: map[string]bool{}}
+
+// fetch — свежий остаток лимитов из API провайдера; сохраняется в кэше и метриках.
+func (c *quotaCache) fetch(p llm.ChatProvider) (*llm.Quota, error) {
+	q, err := llm.GetQuota(p)
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	c.quotas[p.Name()] = q
+	c.mu.Unlock()
+	for _, it := range q.Items {
+		if it.Remaining != nil {
+			metrics.RecordLLMQuota(q.Provider, it.Name, *it.Remaining)
+		}
+	}
+	return q, nil
+}
+
+// cached — последний остаток без ожидания; если он старше ttl, обновляется
+// в фоне и пригодится следующему запросу. Ошибка обновления откладывает
+// следующую попытку тоже на ttl.
+func (c *quotaCache) cached(p llm.ChatProvider, ttl time.Duration) *llm.Quota {
+	if _, ok := p.(llm.QuotaProvider); !ok || ttl <= 0 {
+		return nil
+	}
+	name := p.Name()
+	c.mu.Lock()
+	q := c.quotas[name]
+	refresh := (q == nil || time.Since(q.CheckedAt) > ttl) && !c.refreshing[name]
+	if refresh {
+		c.refreshing[name] = true
+	}
+	c.mu.Unlock()
+	if refresh {
+		go func() {
+			if _, err := c.fetch(p); err != nil {
+				slog.Debug("Остаток лимитов провайдера не обновлён", slog.String("провайдер", name), slog.String("ошибка", err.Error()))
+				c.mu.Lock()
+				c.quotas[name] = &llm.Quota{Provider: name, CheckedAt: time.Now()}
+				c.mu.Unlock()
+			}
+			c.mu.Lock()
+			delete(c.refreshing, name)
+			c.mu.Unlock()
+		}()
+	}
+	return q
+}
+
+// quotaWarning — предупреждение, если остаток какого-либо лимита не больше доли frac.
+func quotaWarning(q *llm.Quota, frac float64) string {
+	if q == nil || frac <= 0 {
+		return ""
+	}
+	it, ok := q.Low(frac)
+	if !ok {
+		return ""
+	}
+	num := func(v float64) string { return strconv.FormatFloat(v, 'f', -1, 64) }
+	return fmt.Sprintf("У провайдера %s заканчивается лимит %s: осталось %s из %s", q.Provider, it.Name, num(*it.Remaining), num(*it.Limit))
+}
+
+// modelWatcher — кэш списков моделей облачных провайдеров с фоновым обновлением
+// (MODEL_REFRESH_INTERVAL) и обнаружением изменений каталога.
+var modelWatcher = newModelWatcher()
+
+func newModelWatcher() *modelwatch.Watcher {
+	w := modelwatch.New()
+	w.OnChange = onModelCatalogChange
+	return w
+}
+
+// watchedProviders — включённые облачные провайдеры: локальные Ollama и
+// LM Studio показывают установленные модели и в фоновом обновлении не нуждаются.
+func watchedProviders() []llm.ChatProvider {
+	var configs []models.ProviderConfig
+	db.DB.Where("enabled = ? AND provider_name NOT IN ?", true, []string{"ollama", "lmstudio"}).Find(&configs)
+	var out []llm.ChatProvider
+	for _, cfg := range configs {
+		if p, err := llm.GlobalRegistry.Get(cfg.ProviderName); err == nil {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+// onModelCatalogChange — запись об изменении каталога; агенты, чья модель
+// пропала из каталога, попадают в системный лог предупреждением.
+func onModelCatalogChange(c modelwatch.Change) {
+	slog.Info("Каталог моделей провайдера изменился", slog.String("провайдер", c.Provider), slog.Any("добавлены", c.Added), slog.Any("удалены", c.Removed))
+	metrics.RecordModelCatalogChange(c.Provider, "added", len(c.Added))
+	metrics.RecordModelCatalogChange(c.Provider, "removed", len(c.Removed))
+	if len(c.Removed) == 0 {
+		return
+	}
+	var agents []models.Agent
+	db.DB.Where("provider = ? AND llm_model IN ?", c.Provider, c.Removed).Find(&agents)
+	for _, a := range agents {
+		slog.Warn("Модель агента пропала из каталога провайдера", slog.String("агент", a.Name), slog.String("провайдер", c.Provider), slog.String("модель", a.LLMModel))
+		WriteSystemLog("warn", "agent-service", fmt.Sprintf("Модель %s агента %s больше не доступна у провайдера %s", a.LLMModel, a.Name, c.Provider), "Выберите для агента другую модель")
+	}
+}
+
+// cloudModelsHandler — получение списка моделей облачного провайдера (GET /cloud-models).
+// Если передан параметр ?provider=..., возвращает модели конкретного провайдера.
+// Если параметр не передан — возвращает список всех зарегистрированных провайдеров.
+//
+// Используется фронтендом для заполнения выпадающего списка облачных моделей.
+func cloudModelsHandler(w http.ResponseWriter, r *http.Request) {
+	cid := r.Header.Get("X-Request-ID")
+	if r.Method != http.MethodGet {
+		apierror.MethodNotAllowed(w, cid)
+		return
+	}
+	providerName := r.URL.Query().Get("provider")
+	slog.Info("Запрос облачных моделей", slog.String("провайдер", providerName))
+	if providerName == "" {
+		allProviders := llm.GlobalRegistry.ListAll()
+		w.Header().Set("Content-Type", "application/json")
+		writeJSON(w, allProviders)
+		return
+	}
+
+	p, err := llm.GlobalRegistry.Get(providerName)
+	if err != nil {
+		slog.Error("Облачный провайдер не найден", slog.String("ошибка", err.Error()))
+		apierror.NotFound(w, cid, "Провайдер не найден")
+		return
+	}
+	slog.Info("Облачный провайдер найден")
+	// Список из кэша фонового обновления, пока он не старше интервала
+	snap, ok := modelWatcher.Get(p.Name())
+	if interval := config.Current().ModelRefreshInterval; !ok || interval <= 0 || time.Since(snap.FetchedAt) > interval {
+		if _, err := modelWatcher.Refresh(p); err != nil {
+			slog.Error("Ошибка получения списка моделей", slog.String("ошибка", err.Error()))
+			apierror.InternalError(w, cid, "Не удалось получить модели", err.Error())
+			return
+		}
+		snap, _ = modelWatcher.Get(p.Name())
+	}
+	w.Header().Set("ETag", snap.ETag)
+	if r.Header.Get("If-None-Match") == snap.ETag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	slog.Info("Облачные модели получены", slog.Int("количество", len(snap.Models)))
+	w.Header().Set("Content-Type", "application/json")
+	writeJSON(w, snap.Models)
+}
+
+// workspacesHandler — управление рабочими пространствами (GET/POST/DELETE /workspaces).
+//
+// Рабочие пространства (Workspaces) — это изолированные проекты с:
+//   - Именем и путём к рабочей директории на ПК
+//   - Отдельной историей чатов
+//   - Отдельной конфигурацией агентов
+//
+// GET    — возвращает список всех пространств
+// POST   — создаёт новое пространство (JSON: {name, path})
+// DELETE — удаляет пространство по ID (?id=...)
+func workspacesHandler(w http.ResponseWriter, r *http.Request) {
+	cid := r.Header.Get("X-Request-ID")
+	switch r.Method {
+	case http.MethodGet:
+		var workspaces []models.Workspace
+		db.DB.Find(&workspaces)
+		w.Header().Set("Content-Type", "application/json")
+		writeJSON(w, workspaces)
+
+	case http.MethodPost:
+		var req struct {
+			Name      string `json:"name"`
+			Path      string `json:"path"`
+			CreateDir bool   `json:"create_dir"` // Создать каталог, если его нет
+			GitInit   bool   `json:"git_init"`   // git init в каталоге
+			Manifest  bool   `json:"manifest"`   // Записать .agent-workspace.json
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			apierror.BadRequest(w, cid, "Невалидный JSON", "")
+			return
+		}
+		if req.Name == "" {
+			apierror.BadRequest(w, cid, "Требуется name", "")
+			return
+		}
+		if req.Path == "" && (req.CreateDir || req.GitInit || req.Manifest) {
+			apierror.BadRequest(w, cid, "Для create_dir, git_init и manifest нужен path", "")
+			return
+		}
+		// Каталог проверяется и подготавливается до записи в БД: при ошибке
+		// пространство не создаётся, а UI получает код и подсказку tools-service
+		var bootstrap map[string]interface{}
+		if req.Path != "" {
+			ctx := logger.WithCorrelationID(r.Context(), cid)
+			res, err := callToolCtx(ctx, "workspace_init", map[string]interface{}{
+				"path": req.Path, "name": req.Name, "create": req.CreateDir, "git": req.GitInit, "manifest": req.Manifest,
+			})
+			if err != nil {
+				apierror.ServiceUnavailable(w, cid, "tools-service недоступен: не удалось проверить каталог пространства", "Запустите tools-service или создайте пространство без path")
+				return
+			}
+			if msg, failed := res["error"].(string); failed {
+				status, _ := res["status_code"].(int)
+				if status < 400 || status >= 500 {
+					status = http.StatusBadGateway
+				}
+				code, _ := res["code"].(string)
+				hint, _ := res["hint"].(string)
+				apierror.Write(w, status, apierror.Response{Code: code, Message: msg, Hint: hint, RequestID: cid})
+				return
+			}
+			bootstrap = res
+			if p, ok := res["path"].(string); ok && p != "" {
+				req.Path = p // Путь после раскрытия ~ и нормализации
+			}
+		}
+		ws := models.Workspace{Name: req.Name, Path: req.Path}
+		if err := db.DB.Create(&ws).Error; err != nil {
+			apierror.InternalError(w, cid, "Не удалось создать workspace", "")
+			return
+		}
+		if ws.Path != "" {
+			repoMaps.ReindexAsync(ws)
+		}
+		slog.Info("Рабочее пространство создано",
+			slog.String("пространство", ws.Name),
+			slog.String("путь", ws.Path),
+			slog.Bool("git_init", req.GitInit),
+			slog.String("request_id", cid))
+		w.Header().Set("Content-Type", "application/json")
+		writeJSON(w, struct {
+			models.Workspace
+			Bootstrap map[string]interface{} `json:"bootstrap,omitempty"`
+		}{ws, bootstrap})
+
+	case http.MethodDelete:
+		id := r.URL.Query().Get("id")
+		if id == "" {
+			apierror.BadRequest(w, cid, "Требуется id", "")
+			return
+		}
+		if err := db.DB.Delete(&models.Workspace{}, id).Error; err != nil {
+			apierror.InternalError(w, cid, "Не удалось удалить workspace", "")
+			return
+		}
+		if wsID, err := strconv.ParseUint(id, 10, 64); err == nil {
+			repoMaps.Drop(uint(wsID))
+			db.DB.Where("workspace_id = ?", wsID).Delete(&models.EnvVar{})
+		}
+		w.Header().Set("Content-Type", "application/json")
+		writeJSON(w, map[string]string{"status": "ok"})
+
+	default:
+		apierror.MethodNotAllowed(w, cid)
+	}
+}
+
+// EnvVarRequest — тело POST /env-vars: переменная пространства
+// (workspace_id) или диалога (chat_id).
+type EnvVarRequest struct {
+	WorkspaceID *uint   `json:"workspace_id,omitempty"`
+	ChatID      *string `json:"chat_id,omitempty"`
+	Key         string  `json:"key"`
+	Value       string  `json:"value"`
+	Secret      bool    `json:"secret"`
+}
+
+// envVarView — переменная в ответе API; значение секрета не отдаётся.
+func envVarView(v models.EnvVar) map[string]interface{} {
+	out := map[string]interface{}{
+		"id": v.ID, "key": v.Key, "secret": v.Secret, "created_at": v.CreatedAt, "updated_at": v.UpdatedAt,
+	}
+	if v.WorkspaceID != nil {
+		out["workspace_id"] = *v.WorkspaceID
+	}
+	if v.ChatID != nil {
+		out["chat_id"] = *v.ChatID
+	}
+	if v.Secret {
+		out["value"] = envvars.Mask
+	} else {
+		out["value"] = v.Value
+	}
+	return out
+}
+
+// envVarsHandler — переменные окружения инструментов execute и run_code.
+//
+//	GET    /env-vars?workspace_id= | ?chat_id= — переменные пространства или диалога
+//	POST   /env-vars                           — создать или заменить (по key)
+//	DELETE /env-vars/{id}                      — удалить
+//
+// Значения секретов (secret: true) в ответах заменены на ***.
+func envVarsHandler(w http.ResponseWriter, r *http.Request) {
+	cid := r.Header.Get("X-Request-ID")
+	switch r.Method {
+	case http.MethodGet:
+		q := db.DB.Order("key")
+		switch wsID, chatID := r.URL.Query().Get("workspace_id"), r.URL.Query().Get("chat_id"); {
+		case wsID != "" && chatID == "":
+			q = q.Where("workspace_id = ?", wsID)
+		case chatID != "" && wsID == "":
+			q = q.Where("chat_id = ?", chatID)
+		default:
+			apierror.BadRequest(w, cid, "Требуется workspace_id или chat_id", "Переменные принадлежат рабочему пространству или диалогу")
+			return
+		}
+		var vars []models.EnvVar
+		if err := q.Find(&vars).Error; err != nil {
+			apierror.InternalError(w, cid, "Не удалось получить переменные", "")
+			return
+		}
+		out := make([]map[string]interface{}, 0, len(vars))
+		for _, v := range vars {
+			out = append(out, envVarView(v))
+		}
+		writeJSON(w, out)
+
+	case http.MethodPost:
+		var req EnvVarRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			apierror.BadRequest(w, cid, "Невалидный JSON", "Проверьте формат тела запроса")
+			return
+		}
+		if (req.WorkspaceID == nil) == (req.ChatID == nil) {
+			apierror.BadRequest(w, cid, "Требуется ровно одно из workspace_id и chat_id", "")
+			return
+		}
+		if err := envvars.Validate(req.Key, req.Value, req.Secret); err != nil {
+			apierror.BadRequest(w, cid, err.Error(), "")
+			return
+		}
+		var (
+			column string
+			owner  interface{}
+		)
+		if req.WorkspaceID != nil {
+			if err := db.DB.First(&models.Workspace{}, *req.WorkspaceID).Error; err != nil {
+				apierror.NotFound(w, cid, "Рабочее пространство не найдено")
+				return
+			}
+			column, owner = "workspace_id", *req.WorkspaceID
+		} else {
+			if err := db.DB.Where("id = ?", *req.ChatID).First(&models.Chat{}).Error; err != nil {
+				apierror.NotFound(w, cid, "Диалог не найден")
+				return
+			}
+			column, owner = "chat_id", *req.ChatID
+		}
+		var v models.EnvVar
+		created := db.DB.Where(column+" = ? AND key = ?", owner, req.Key).First(&v).Error != nil
+		if created {
+			var count int64
+			db.DB.Model(&models.EnvVar{}).Where(column+" = ?", owner).Count(&count)
+			if count >= envvars.MaxVars {
+				apierror.BadRequest(w, cid, fmt.Sprintf("Не больше %d переменных", envvars.MaxVars), "Удалите ненужные переменные")
+				return
+			}
+			v = models.EnvVar{WorkspaceID: req.WorkspaceID, ChatID: req.ChatID, Key: req.Key}
+		}
+		v.Value, v.Secret = req.Value, req.Secret
+		if err := db.DB.Save(&v).Error; err != nil {
+			apierror.InternalError(w, cid, "Не удалось сохранить переменную", "")
+			return
+		}
+		slog.Info("Переменная окружения сохранена", slog.String("переменная", v.Key), slog.Bool("секрет", v.Secret), slog.String("request_id", cid))
+		if created {
+			w.WriteHeader(http.StatusCreated)
+		}
+		writeJSON(w, envVarView(v))
+
+	case http.MethodDelete:
+		id, err := strconv.ParseUint(strings.Trim(strings.TrimPrefix(r.URL.Path, "/env-vars"), "/"), 10, 64)
+		if err != nil {
+			apierror.BadRequest(w, cid, "Требуется id: DELETE /env-vars/{id}", "")
+			return
+		}
+		res := db.DB.Delete(&models.EnvVar{}, id)
+		if res.Error != nil {
+			apierror.InternalError(w, cid, "Не удалось удалить переменную", "")
+			return
+		}
+		if res.RowsAffected == 0 {
+			apierror.NotFound(w, cid, "Переменная не найдена")
+			return
+		}
+		writeJSON(w, map[string]string{"status": "ok"})
+
+	default:
+		apierror.MethodNotAllowed(w, cid)
+	}
+}
+
+// repoMaps — индекс символов рабочих пространств и кэш карт репозиториев.
+var repoMaps *repomap.Store
+
+// workspaceSymbolsHandler — индекс символов рабочего пространства.
+//
+//	GET  /workspace/{id}/symbols?file=&kind=&limit= — символы (по файлу и строке)
+//	POST /workspace/{id}/symbols                   — переиндексировать сейчас
+//	GET  /workspace/{id}/symbols/search?q=&limit=  — поиск по имени
+//	GET  /workspace/{id}/repomap?budget=           — компактная карта репозитория
+func workspaceSymbolsHandler(w http.ResponseWriter, r *http.Request) {
+	cid := r.Header.Get("X-Request-ID")
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/workspace/"), "/"), "/")
+	wsID, err := strconv.ParseUint(parts[0], 10, 64)
+	if err != nil || len(parts) < 2 {
+		apierror.BadRequest(w, cid, "Некорректный путь", "Используйте /workspace/{id}/symbols, /workspace/{id}/symbols/search или /workspace/{id}/repomap")
+		return
+	}
+	var ws models.Workspace
+	if err := db.DB.First(&ws, wsID).Error; err != nil {
+		apierror.NotFound(w, cid, "Рабочее пространство не найдено")
+		return
+	}
+	q := r.URL.Query()
+	limit, _ := strconv.Atoi(q.Get("limit"))
+	action := strings.Join(parts[1:], "/")
+
+	switch {
+	case action == "symbols" && r.Method == http.MethodPost:
+		start := time.Now()
+		idx, err := repoMaps.Reindex(&ws)
+		if err != nil {
+			slog.Error("Ошибка индексации пространства", slog.String("пространство", ws.Name), slog.String("ошибка", err.Error()), slog.String("request_id", cid))
+			apierror.BadRequest(w, cid, "Не удалось проиндексировать: "+err.Error(), "Проверьте путь рабочего пространства")
+			return
+		}
+		slog.Info("Пространство проиндексировано", slog.String("пространство", ws.Name), slog.Int("символов", len(idx.Symbols)), slog.String("request_id", cid))
+		writeJSON(w, map[string]interface{}{
+			"workspace_id": ws.ID,
+			"files":        len(idx.Files),
+			"symbols":      len(idx.Symbols),
+			"skipped":      idx.Skipped,
+			"indexed_at":   ws.IndexedAt,
+			"duration_ms":  time.Since(start).Milliseconds(),
+		})
+	case r.Method != http.MethodGet:
+		apierror.MethodNotAllowed(w, cid)
+	case action == "symbols":
+		syms, err := repoMaps.Symbols(ws.ID, q.Get("file"), q.Get("kind"), limit)
+		if err != nil {
+			apierror.InternalError(w, cid, "Ошибка чтения индекса", "")
+			return
+		}
+		writeJSON(w, map[string]interface{}{"symbols": syms, "total": len(syms), "indexed_at": ws.IndexedAt})
+	case action == "symbols/search":
+		if limit <= 0 {
+			limit = 50
+		}
+		syms, err := repoMaps.Search(ws.ID, q.Get("q"), limit)
+		if err != nil {
+			apierror.InternalError(w, cid, "Ошибка поиска по индексу", "")
+			return
+		}
+		writeJSON(w, map[string]interface{}{"symbols": syms, "total": len(syms)})
+	case action == "repomap":
+		budget, _ := strconv.Atoi(q.Get("budget"))
+		m, err := repoMaps.Map(ws.ID, budget)
+		if errors.Is(err, repomap.ErrEmpty) {
+			apierror.NotFound(w, cid, "Индекс пуст: выполните POST /workspace/"+parts[0]+"/symbols")
+			return
+		}
+		if err != nil {
+			apierror.InternalError(w, cid, "Ошибка построения карты", "")
+			return
+		}
+		writeJSON(w, map[string]interface{}{"repomap": m, "indexed_at": ws.IndexedAt})
+	default:
+		apierror.NotFound(w, cid, "Неизвестный ресурс: "+action)
+	}
+}
+
+// workspaceRepoMap — блок системного промпта с картой репозитория пространства,
+// к которому привязан агент. Отсутствующий или устаревший индекс перестраивается
+// в фоне; пока индекса нет, карта не подставляется.
+func workspaceRepoMap(agent *models.Agent) string {
+	budget := config.Current().RepoMapBudget
+	if agent.WorkspaceID == nil || budget <= 0 {
+		return ""
+	}
+	var ws models.Workspace
+	if err := db.DB.First(&ws, *agent.WorkspaceID).Error; err != nil || ws.Path == "" {
+		return ""
+	}
+	if repomap.Stale(ws) {
+		repoMaps.ReindexAsync(ws)
+	}
+	if ws.IndexedAt == nil {
+		return ""
+	}
+	m, err := repoMaps.Map(ws.ID, budget)
+	if err != nil {
+		return ""
+	}
+	return fmt.Sprintf("\n\n=== Карта репозитория %s (%s) ===\nФайлы проекта и ключевые символы; пути относительно корня. Перед правкой читай нужный файл целиком.\n%s\n=== Конец карты репозитория ===\n", ws.Name, ws.Path, m)
+}
+
+// trackerCredentials — доступ к трекеру задачи из настроек провайдеров:
+// github/gitlab — те же токены, что у git_pull_request, jira — провайдер jira.
+func trackerCredentials(ref issues.Ref) issues.Credentials {
+	if ref.Tracker == issues.TrackerJira {
+		var cfg models.ProviderConfig
+		if err := db.DB.Where("provider_name = ? AND enabled = ?", issues.TrackerJira, true).First(&cfg).Error; err != nil {
+			return issues.Credentials{}
+		}
+		return issues.Credentials{Token: cfg.APIKey, BaseURL: cfg.BaseURL}
+	}
+	acc, ok := gitHostingAccount(ref.Host)
+	if !ok || acc.Provider != ref.Tracker {
+		return issues.Credentials{}
+	}
+	return issues.Credentials{Token: acc.Token, BaseURL: acc.BaseURL}
+}
+
+// importIssue — загружает задачу трекера, получает от модели агента резюме и
+// план и создаёт AgentTask со статусом planned. Повторный импорт той же
+// ссылки для агента обновляет существующую задачу.
+func importIssue(ctx context.Context, rawURL, agentName string) (*models.AgentTask, error) {
+	ref, err := issues.ParseURL(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	agent, err := repository.GetAgentByName(agentName)
+	if err != nil {
+		return nil, fmt.Errorf("агент %s не найден", agentName)
+	}
+	issue, err := issues.Fetch(ctx, ref, trackerCredentials(ref))
+	if err != nil {
+		return nil, err
+	}
+	text := issue.Text(issues.MaxBody)
+
+	summary, steps := "", []string(nil)
+	providerName := agent.Provider
+	if providerName == "" {
+		providerName = "ollama"
+	}
+	if provider, perr := llm.GlobalRegistry.Get(providerName); perr == nil {
+		resp, cerr := chatWithRetry(ctx, provider, &llm.ChatRequest{
+			Model:    agent.LLMModel,
+			Messages: []llm.Message{{Role: "system", Content: issues.PlanPrompt}, {Role: "user", Content: text}},
+		})
+		if cerr == nil {
+			summary, steps = issues.ParsePlan(resp.Content)
+		} else {
+			slog.Warn("Не удалось составить план задачи", slog.String("задача", ref.Display()), slog.String("ошибка", cerr.Error()))
+		}
+	}
+	if summary == "" {
+		summary = issues.FallbackSummary(issue)
+	}
+
+	var task models.AgentTask
+	db.DB.Where("agent = ? AND issue_url = ?", agent.Name, ref.URL).FirstOrInit(&task)
+	task.Agent = agent.Name
+	task.WorkspaceID = agent.WorkspaceID
+	task.Title = issue.Title
+	task.Tracker = ref.Tracker
+	task.IssueKey = ref.Display()
+	task.IssueURL = ref.URL
+	task.Context = text
+	task.Summary = summary
+	task.Plan = strings.Join(steps, "\n")
+	if task.Status == "" || task.Status == models.TaskDone || task.Status == models.TaskCancelled {
+		task.Status = models.TaskPlanned
+	}
+	if err := db.DB.Save(&task).Error; err != nil {
+		return nil, err
+	}
+	slog.Info("Задача импортирована", slog.String("задача", task.IssueKey), slog.String("агент", task.Agent), slog.Int("шагов", len(steps)))
+	return &task, nil
+}
+
+// activeTask — последняя незавершённая задача агента (planned или in_progress).
+func activeTask(agentName string) *models.AgentTask {
+	var task models.AgentTask
+	err := db.DB.Where("agent = ? AND status IN ?", agentName, []string{models.TaskPlanned, models.TaskInProgress}).
+		Order("updated_at DESC").First(&task).Error
+	if err != nil {
+		return nil
+	}
+	return &task
+}
+
+// agentTaskPrompt — блок системного промпта с активной задачей агента;
+// первая подстановка переводит задачу в in_progress.
+func agentTaskPrompt(agentName string) string {
+	task := activeTask(agentName)
+	if task == nil {
+		return ""
+	}
+	if task.Status == models.TaskPlanned {
+		db.DB.Model(task).Update("status", models.TaskInProgress)
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "\n\n=== Текущая задача %s ===\n%s\n\nРезюме: %s\n", task.IssueKey, task.IssueURL, task.Summary)
+	if task.Plan != "" {
+		b.WriteString("\nПлан:\n")
+		for i, step := range strings.Split(task.Plan, "\n") {
+			fmt.Fprintf(&b, "%d. %s\n", i+1, step)
+		}
+	}
+	b.WriteString("\nИсходный текст задачи:\n" + task.Context + "\n")
+	b.WriteString("Изменения оформляй через git_pull_request — коммит и PR автоматически сошлются на задачу.\n=== Конец задачи ===\n")
+	return b.String()
+}
+
+// recordTaskCommit — отмечает коммит скила git_pull_request в активной задаче
+// и переводит её на ревью, если открыт PR.
+func recordTaskCommit(task *models.AgentTask, res gitflow.Result) {
+	line := strings.TrimSpace(res.Commit + " " + res.Branch + " " + res.PRURL)
+	commits := task.Commits
+	if commits != "" {
+		commits += "\n"
+	}
+	updates := map[string]interface{}{"commits": commits + line}
+	if res.PRURL != "" {
+		updates["status"] = models.TaskReview
+	}
+	db.DB.Model(task).Updates(updates)
+}
+
+// tasksHandler — задачи агентов из трекеров.
+//
+//	GET  /tasks?agent=admin&status=planned — список задач
+//	POST /tasks {"url": "https://github.com/o/r/issues/1", "agent": "admin"} — импорт задачи
+func tasksHandler(w http.ResponseWriter, r *http.Request) {
+	cid := r.Header.Get("X-Request-ID")
+	switch r.Method {
+	case http.MethodGet:
+		q := db.DB.Order("updated_at DESC")
+		if agent := r.URL.Query().Get("agent"); agent != "" {
+			q = q.Where("agent = ?", agent)
+		}
+		if status := r.URL.Query().Get("status"); status != "" {
+			q = q.Where("status = ?", status)
+		}
+		var tasks []models.AgentTask
+		q.Limit(200).Find(&tasks)
+		writeJSON(w, tasks)
+	case http.MethodPost:
+		var req struct {
+			URL   string `json:"url"`
+			Agent string `json:"agent"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.URL == "" {
+			apierror.BadRequest(w, cid, "Требуется url задачи", "Поддерживаются ссылки на issue GitHub/GitLab и тикеты Jira (/browse/KEY-1)")
+			return
+		}
+		if req.Agent == "" {
+			req.Agent = "admin"
+		}
+		task, err := importIssue(r.Context(), req.URL, req.Agent)
+		if err != nil {
+			slog.Warn("Ошибка импорта задачи", slog.String("url", req.URL), slog.String("ошибка", err.Error()), slog.String("request_id", cid))
+			if errors.Is(err, issues.ErrNotFound) {
+				apierror.NotFound(w, cid, err.Error())
+				return
+			}
+			apierror.BadRequest(w, cid, err.Error(), "Для приватных задач сохраните токен через POST /providers (github, gitlab или jira)")
+			return
+		}
+		writeJSON(w, task)
+	default:
+		apierror.MethodNotAllowed(w, cid)
+	}
+}
+
+// taskHandler — одна задача: GET /tasks/{id}, POST /tasks/{id} {"status": "done"}, DELETE /tasks/{id}.
+func taskHandler(w http.ResponseWriter, r *http.Request) {
+	cid := r.Header.Get("X-Request-ID")
+	id, err := strconv.ParseUint(strings.Trim(strings.TrimPrefix(r.URL.Path, "/tasks/"), "/"), 10, 64)
+	if err != nil {
+		apierror.BadRequest(w, cid, "Некорректный id задачи", "")
+		return
+	}
+	var task models.AgentTask
+	if err := db.DB.First(&task, id).Error; err != nil {
+		apierror.NotFound(w, cid, "Задача не найдена")
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, task)
+	case http.MethodPost:
+		var req struct {
+			Status string `json:"status"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		switch req.Status {
+		case models.TaskPlanned, models.TaskInProgress, models.TaskReview, models.TaskDone, models.TaskCancelled:
+		default:
+			apierror.BadRequest(w, cid, "Некорректный status", "Допустимо: planned, in_progress, review, done, cancelled")
+			return
+		}
+		db.DB.Model(&task).Update("status", req.Status)
+		writeJSON(w, task)
+	case http.MethodDelete:
+		db.DB.Delete(&task)
+		writeJSON(w, map[string]interface{}{"status": "deleted", "id": task.ID})
+	default:
+		apierror.MethodNotAllowed(w, cid)
+	}
+}
+
+// WriteSystemLog — записывает событие в централизованную систему логов.
+// Используется всеми компонентами для логирования ошибок и важных событий.
+// Параметры:
+//   - level: уровень лога (error, warn, info, debug)
+//   - service: имя микросервиса-источника
+//   - message: текст сообщения
+//   - details: дополнительные данные (стек, параметры запроса)
+func WriteSystemLog(level, service, message, details string) {
+	entry := models.SystemLog{
+		Level:   level,
+		Service: service,
+		Message: message,
+		Details: details,
+	}
+	if err := db.DB.Create(&entry).Error; err != nil {
+		slog.Error("Ошибка записи в системный лог", slog.String("ошибка", err.Error()))
+	}
+}
+
+// logsHandler — HTTP-обработчик для работы с системными логами.
+// GET: возвращает логи с фильтрацией по уровню (?level=error), сервису (?service=agent-service),
+//
+//	тексту (?q=ollama, полнотекстовый поиск по message и details), интервалу
+//	(?since=1h&until=2025-01-31) и лимиту (?limit=100). По умолчанию — последние 100 записей.
+//	?group_by=service|level — количество записей по группам с теми же фильтрами.
+//	?tail=true — поток новых записей в формате SSE.
+//
+// DELETE: удаляет (с архивированием) записи старше ?before=2025-01-31.
+// POST: принимает новый лог или массив логов от внешних сервисов (tools-service,
+// browser-service, memory-service, api-gateway); correlation_id связывает записи одного запроса.
+// PATCH: отмечает лог как исправленный (?id=123&resolved=true).
+func logsHandler(w http.ResponseWriter, r *http.Request) {
+	cid := r.Header.Get("X-Request-ID")
+	switch r.Method {
+	case http.MethodGet:
+		q, err := logstore.ParseQuery(r.URL.Query(), time.Now())
+		if err != nil {
+			apierror.BadRequest(w, cid, err.Error(), "Пример: ?q=ollama&level=error&since=1h")
+			return
+		}
+		if groupBy := r.URL.Query().Get("group_by"); groupBy != "" {
+			buckets, err := q.Aggregate(db.DB, groupBy)
+			if err != nil {
+				apierror.BadRequest(w, cid, err.Error(), "Используйте group_by=service или group_by=level")
+				return
+			}
+			writeJSON(w, map[string]interface{}{"group_by": groupBy, "buckets": buckets})
+			return
+		}
+		if r.URL.Query().Get("tail") == "true" {
+			tailLogs(w, r, q)
+			return
+		}
+
+		logs, err := q.Find(db.DB)
+		if err != nil {
+			slog.Error("Ошибка чтения системного лога", slog.String("ошибка", err.Error()), slog.String("request_id", cid))
+			apierror.InternalError(w, cid, "Ошибка чтения логов", "")
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		writeJSON(w, logs)
+
+	case http.MethodPost:
+		type logEntry struct {
+			Level         string `json:"level"`
+			Service       string `json:"service"`
+			Message       string `json:"message"`
+			Details       string `json:"details"`
+			CorrelationID string `json:"correlation_id"`
+		}
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			apierror.BadRequest(w, cid, "Не удалось прочитать тело запроса", "")
+			return
+		}
+		// Отправители логов (tools-service, browser-service) присылают записи пачками
+		var entries []logEntry
+		if trimmed := bytes.TrimSpace(body); len(trimmed) > 0 && trimmed[0] == '[' {
+			err = json.Unmarshal(trimmed, &entries)
+		} else {
+			var single logEntry
+			err = json.Unmarshal(body, &single)
+			entries = []logEntry{single}
+		}
+		if err != nil {
+			apierror.BadRequest(w, cid, "Невалидный JSON", "")
+			return
+		}
+		logs := make([]models.SystemLog, 0, len(entries))
+		for _, e := range entries {
+			if e.Level == "" || e.Service == "" || e.Message == "" {
+				apierror.BadRequest(w, cid, "level, service, message обязательны", "")
+				return
+			}
+			logs = append(logs, models.SystemLog{
+				Level:         e.Level,
+				Service:       e.Service,
+				Message:       e.Message,
+				Details:       e.Details,
+				CorrelationID: e.CorrelationID,
+			})
+		}
+		if len(logs) > 0 {
+			if err := db.DB.Create(&logs).Error; err != nil {
+				slog.Error("Ошибка записи в системный лог", slog.String("ошибка", err.Error()))
+				apierror.InternalError(w, cid, "Ошибка записи в системный лог", "")
+				return
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		writeJSON(w, map[string]interface{}{"status": "ok", "count": len(logs)})
+
+	case http.MethodPatch:
+		id := r.URL.Query().Get("id")
+		if id == "" {
+			apierror.BadRequest(w, cid, "id обязателен", "")
+			return
+		}
+		resolved := r.URL.Query().Get("resolved") == "true"
+		if err := db.DB.Model(&models.SystemLog{}).Where("id = ?", id).Update("resolved", resolved).Error; err != nil {
+			apierror.InternalError(w, cid, "Ошибка обновления", "")
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		writeJSON(w, map[string]string{"status": "ok"})
+
+	case http.MethodDelete:
+		raw := r.URL.Query().Get("before")
+		if raw == "" {
+			apierror.BadRequest(w, cid, "before обязателен", "Укажите дату: ?before=2025-01-31 или ?before=2025-01-31T00:00:00Z")
+			return
+		}
+		before, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			before, err = time.ParseInLocation("2006-01-02", raw, time.Local)
+		}
+		if err != nil {
+			apierror.BadRequest(w, cid, "Некорректная дата before", "Формат: 2025-01-31 или RFC3339")
+			return
+		}
+		res, err := logPruner.PruneBefore(r.Context(), before)
+		if err != nil {
+			slog.Error("Ошибка ручной очистки системного лога", slog.String("ошибка", err.Error()), slog.String("request_id", cid))
+			apierror.InternalError(w, cid, "Ошибка очистки логов", err.Error())
+			return
+		}
+		slog.Info("Системный лог очищен вручную", slog.Int64("удалено", res.Deleted), slog.String("до", before.Format(time.RFC3339)))
+		writeJSON(w, res)
+
+	default:
+		apierror.MethodNotAllowed(w, cid)
+	}
+}
+
+// tailInterval — период опроса БД в режиме GET /logs?tail=true.
+const tailInterval = 2 * time.Second
+
+// tailLogs — отдаёт новые записи системного лога потоком SSE (event: log),
+// пока клиент не отключится. Сначала отправляются последние записи по фильтру.
+func tailLogs(w http.ResponseWriter, r *http.Request, q logstore.Query) {
+	rc := http.NewResponseController(w)
+	// Поток живёт дольше WriteTimeout сервера
+	rc.SetWriteDeadline(time.Time{})
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	if q.Limit > 100 {
+		q.Limit = 100
+	}
+	send := func(logs []models.SystemLog) bool {
+		for _, l := range logs {
+			data, _ := json.Marshal(l)
+			if _, err := fmt.Fprintf(w, "id: %d\nevent: log\ndata: %s\n\n", l.ID, data); err != nil {
+				return false
+			}
+			if l.ID > q.AfterID {
+				q.AfterID = l.ID
+			}
+		}
+		return rc.Flush() == nil
+	}
+
+	initial, err := q.Find(db.DB)
+	if err != nil {
+		return
+	}
+	// Find возвращает новые первыми — клиенту отдаём в хронологическом порядке
+	for i, j := 0, len(initial)-1; i < j; i, j = i+1, j-1 {
+		initial[i], initial[j] = initial[j], initial[i]
+	}
+	if !send(initial) {
+		return
+	}
+
+	ticker := time.NewTicker(tailInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-ticker.C:
+			var fresh []models.SystemLog
+			if err := q.Apply(db.DB).Order("id").Limit(q.Limit).Find(&fresh).Error; err != nil {
+				return
+			}
+			if len(fresh) == 0 {
+				// Комментарий-пинг не даёт прокси закрыть простаивающее соединение
+				if _, err := fmt.Fprint(w, ": ping\n\n"); err != nil || rc.Flush() != nil {
+					return
+				}
+				continue
+			}
+			if !send(fresh) {
+				return
+			}
+		}
+	}
+}
+
+// logPruner — очистка системного лога по политике хранения (см. logstore.LoadRetentionConfig).
+var logPruner *logstore.Pruner
+
+// uploadLogArchive — выгружает архив логов на Яндекс.Диск через tools-service.
+func uploadLogArchive(path, content string) error {
+	result, err := callTool("ydisk/upload", map[string]interface{}{"path": path, "content": content, "overwrite": true})
+	if err != nil {
+		return err
+	}
+	if msg, ok := result["error"].(string); ok {
+		return fmt.Errorf("%s", msg)
+	}
+	return nil
+}
+
+// ragAddHandler — обработчик для добавления документа в RAG базу знаний
+func ragAddHandler(w http.ResponseWriter, r *http.Request) {
+	cid := r.Header.Get("X-Request-ID")
+	if r.Method != http.MethodPost {
+		apierror.MethodNotAllowed(w, cid)
+		return
+	}
+
+	var req struct {
+		Title       string `json:"title"`
+		Content     string `json:"content"`
+		Source      string `json:"source"`
+		WorkspaceID *uint  `json:"workspace_id"` // Для фильтра поиска workspace_id
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		apierror.BadRequest(w, cid, "Невалидный JSON", "")
+		return
+	}
+
+	if req.Title == "" || req.Content == "" {
+		apierror.BadRequest(w, cid, "Требуются title и content", "")
+		return
+	}
+
+	ragDoc, changed, err := saveRagDocument(r.Context(), req.Title, req.Content, req.Source, req.WorkspaceID)
+	if err != nil {
+		slog.Error("Ошибка сохранения RAG документа в БД", slog.String("ошибка", err.Error()))
+		apierror.InternalError(w, cid, "Не удалось сохранить документ", "")
+		return
+	}
+
+	// Запись ChromaDB привязана к строке БД, чтобы сверка /rag/admin/verify её находила
+	docID := rag.ChromaID(ragDoc.ID)
+	if changed {
+		indexRagDocument(ragDoc)
+	}
+
+	slog.Info("RAG документ добавлен", slog.String("заголовок", req.Title), slog.Uint64("id", uint64(ragDoc.ID)),
+		slog.Int("версия", ragDoc.Version), slog.Bool("изменён", changed))
+	writeJSON(w, map[string]interface{}{"status": "ok", "id": docID, "db_id": ragDoc.ID, "version": ragDoc.Version, "unchanged": !changed})
+}
+
+// saveRagDocument — сохраняет документ RAG. Если документ с тем же названием
+// и источником уже есть, его содержимое уходит в историю версий
+// (RagDocumentVersion), а строка получает новое содержимое и следующий номер;
+// changed == false — содержимое не изменилось, новая версия не создана.
+// workspaceID == nil — пространство документа не меняется.
+func saveRagDocument(ctx context.Context, title, content, source string, workspaceID *uint) (doc models.RagDocument, changed bool, err error) {
+	err = db.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Where("title = ? AND source = ?", title, source).Order("id DESC").First(&doc).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			doc = models.RagDocument{Title: title, Content: content, Source: source, TotalChunks: 1, Version: 1, WorkspaceID: workspaceID}
+			changed = true
+			return tx.Create(&doc).Error
+		}
+		if err != nil || doc.Content == content {
+			return err
+		}
+		prev := models.RagDocumentVersion{DocumentID: doc.ID, Version: max(doc.Version, 1), Content: doc.Content, CreatedAt: doc.UpdatedAt}
+		if err := tx.Create(&prev).Error; err != nil {
+			return err
+		}
+		doc.Content = content
+		doc.Version = prev.Version + 1
+		if workspaceID != nil {
+			doc.WorkspaceID = workspaceID
+		}
+		changed = true
+		return tx.Save(&doc).Error
+	})
+	return doc, changed, err
+}
+
+// indexRagDocument — добавляет или заменяет запись документа в ChromaDB;
+// ошибка только логируется (её покажет GET /rag/admin/verify).
+func indexRagDocument(doc models.RagDocument) {
+	if ragRetriever == nil || ragRetriever.Config().ChromaURL == "" {
+		return
+	}
+	chromaDoc := rag.RagDoc{
+		ID:        rag.ChromaID(doc.ID),
+		Title:     doc.Title,
+		Content:   doc.Content,
+		Source:    doc.Source,
+		DBID:      doc.ID,
+		Version:   doc.Version,
+		CreatedAt: doc.UpdatedAt,
+	}
+	if doc.WorkspaceID != nil {
+		chromaDoc.WorkspaceID = *doc.WorkspaceID
+	}
+	if err := ragRetriever.AddDocument(chromaDoc); err != nil {
+		slog.Error("Ошибка добавления в ChromA", slog.String("ошибка", err.Error()))
+	}
+}
+
+// ragFilterRequest — фильтры поиска по базе знаний: /rag/search, /rag/ask
+// и инструмент search_knowledge.
+type ragFilterRequest struct {
+	Collection   string   `json:"collection"`
+	Source       string   `json:"source"`        // Точный источник
+	SourcePrefix string   `json:"source_prefix"` // Префикс источника, например folder:/docs/api
+	Extensions   []string `json:"extensions"`    // Расширения: md, .go
+	Since        string   `json:"since"`         // RFC3339, 2006-01-02 или длительность назад (7d)
+	Until        string   `json:"until"`
+	WorkspaceID  uint     `json:"workspace_id"`
+}
+
+// ragFilterFromQuery — фильтры из параметров GET: extensions через запятую.
+func ragFilterFromQuery(q url.Values) ragFilterRequest {
+	f := ragFilterRequest{
+		Collection:   q.Get("collection"),
+		Source:       q.Get("source"),
+		SourcePrefix: q.Get("source_prefix"),
+		Since:        q.Get("since"),
+		Until:        q.Get("until"),
+	}
+	for _, e := range strings.Split(q.Get("extensions"), ",") {
+		if e = strings.TrimSpace(e); e != "" {
+			f.Extensions = append(f.Extensions, e)
+		}
+	}
+	if id, err := strconv.ParseUint(q.Get("workspace_id"), 10, 64); err == nil {
+		f.WorkspaceID = uint(id)
+	}
+	return f
+}
+
+// resolve — фильтр для ChromaDB. Префикс источника ChromaDB не умеет —
+// он раскрывается в список источников документов из БД; ok == false —
+// под префикс не подходит ни один документ, искать нечего.
+func (f ragFilterRequest) resolve(ctx context.Context) (filter rag.Filter, ok bool, err error) {
+	now := time.Now()
+	filter = rag.Filter{Collection: f.Collection, Extensions: f.Extensions, WorkspaceID: f.WorkspaceID}
+	if filter.Since, err = logstore.ParseTime(f.Since, now); err != nil {
+		return filter, false, err
+	}
+	if filter.Until, err = logstore.ParseTime(f.Until, now); err != nil {
+		return filter, false, err
+	}
+	if f.Source != "" {
+		filter.Sources = []string{f.Source}
+	}
+	if f.SourcePrefix != "" {
+		var sources []string
+		err := db.DB.WithContext(ctx).Model(&models.RagDocument{}).Where(`source LIKE ? ESCAPE '\'`, likePrefix(f.SourcePrefix)).Distinct().Pluck("source", &sources).Error
+		if err != nil {
+			return filter, false, err
+		}
+		if f.Source != "" {
+			// Оба условия: точный источник должен подходить под префикс
+			if !strings.HasPrefix(f.Source, f.SourcePrefix) {
+				sources = nil
+			} else {
+				sources = []string{f.Source}
+			}
+		}
+		if len(sources) == 0 {
+			return filter, false, nil
+		}
+		filter.Sources = sources
+	}
+	return filter, true, nil
+}
+
+// ragSearchHandler — обработчик для поиска по RAG базе знаний.
+// С фильтрами (source, source_prefix, extensions, since, until, collection,
+// workspace_id) поиск идёт только в ChromaDB, без демонстрационного режима.
+func ragSearchHandler(w http.ResponseWriter, r *http.Request) {
+	cid := r.Header.Get("X-Request-ID")
+	if r.Method != http.MethodPost && r.Method != http.MethodGet {
+		apierror.MethodNotAllowed(w, cid)
+		return
+	}
+
+	query := r.URL.Query().Get("q")
+	filterReq := ragFilterFromQuery(r.URL.Query())
+	topK, _ := strconv.Atoi(r.URL.Query().Get("top_k"))
+	if query == "" && r.Method == http.MethodPost {
+		var req struct {
+			Query string `json:"query"`
+			TopK  int    `json:"top_k"`
+			ragFilterRequest
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err == nil {
+			query, topK, filterReq = req.Query, req.TopK, req.ragFilterRequest
+		}
+	}
+
+	if query == "" {
+		apierror.BadRequest(w, cid, "Требуется query", "")
+		return
+	}
+
+	if ragRetriever == nil {
+		apierror.InternalError(w, cid, "RAG не инициализирован", "")
+		return
+	}
+	if topK <= 0 {
+		topK = 5
+	}
+
+	filter, ok, err := filterReq.resolve(r.Context())
+	if err != nil {
+		apierror.BadRequest(w, cid, err.Error(), "")
+		return
+	}
+	if ok && filter.Empty() && filter.Collection == "" {
+		results, err := ragRetriever.Search(query, topK)
+		if err != nil {
+			apierror.InternalError(w, cid, err.Error(), "")
+			return
+		}
+		writeJSON(w, results)
+		return
+	}
+	if ragRetriever.Config().ChromaURL == "" {
+		apierror.ServiceUnavailable(w, cid, rag.ErrChromaDisabled.Error(), "Фильтры поиска выполняются в ChromaDB — задайте CHROMA_URL")
+		return
+	}
+	results := []rag.SearchResult{}
+	if ok {
+		found, err := ragRetriever.Query(r.Context(), query, topK, filter)
+		if err != nil {
+			apierror.ServiceUnavailable(w, cid, err.Error(), "Проверьте доступность ChromaDB")
+			return
+		}
+		results = append(results, found...)
+	}
+	writeJSON(w, results)
+}
+
+// ragAskRequest — вопрос к базе знаний (/rag/ask).
+type ragAskRequest struct {
+	Question    string   `json:"question"`
+	TopK        int      `json:"top_k"`     // Фрагментов в контексте (по умолчанию RAG_TOP_K)
+	MinScore    float64  `json:"min_score"` // Фрагменты с меньшей оценкой отбрасываются
+	Agent       string   `json:"agent"`     // Чья модель отвечает, если model не задана (по умолчанию admin)
+	Provider    string   `json:"provider"`
+	Model       string   `json:"model"`
+	Temperature *float64 `json:"temperature"` // По умолчанию ragAskTemperature
+	ragFilterRequest
+}
+
+// ragAskResponse — ответ /rag/ask.
+type ragAskResponse struct {
+	Answer    string          `json:"answer"`
+	Answered  bool            `json:"answered"`  // false — в базе знаний нет ответа
+	Citations []rag.Citation  `json:"citations"` // Все переданные модели фрагменты; cited — на них ссылается ответ
+	Provider  string          `json:"provider,omitempty"`
+	Model     string          `json:"model,omitempty"`
+	Guard     []guard.Finding `json:"guard,omitempty"`
+	TookMs    int64           `json:"took_ms"`
+}
+
+// ragAskTemperature — температура ответа по фрагментам: низкая, чтобы
+// модель не фантазировала.
+const ragAskTemperature = 0.1
+
+// ragAskMaxTopK — предел top_k для одного вопроса.
+const ragAskMaxTopK = 20
+
+// ragAskHandler — ответ на вопрос по базе знаний без цикла агента: поиск в
+// ChromaDB, промпт только из найденных фрагментов, один запрос к модели и
+// ссылки на фрагменты. Инструменты, память и история диалога не используются.
+func ragAskHandler(w http.ResponseWriter, r *http.Request) {
+	cid := r.Header.Get("X-Request-ID")
+	if r.Method != http.MethodPost {
+		apierror.MethodNotAllowed(w, cid)
+		return
+	}
+	var req ragAskRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		apierror.BadRequest(w, cid, "Невалидный JSON", "")
+		return
+	}
+	req.Question = strings.TrimSpace(req.Question)
+	if req.Question == "" {
+		apierror.BadRequest(w, cid, "Требуется question", "")
+		return
+	}
+	if ragRetriever == nil || ragRetriever.Config().ChromaURL == "" {
+		apierror.ServiceUnavailable(w, cid, rag.ErrChromaDisabled.Error(), "Задайте CHROMA_URL: ответы строятся по найденным в ChromaDB фрагментам")
+		return
+	}
+
+	agentName := req.Agent
+	if agentName == "" {
+		agentName = "admin"
+	}
+	providerName, modelName := req.Provider, req.Model
+	if modelName == "" {
+		agent, err := repository.GetAgentByName(agentName)
+		if err != nil {
+			apierror.NotFound(w, cid, "Агент "+agentName+" не найден")
+			return
+		}
+		providerName, modelName = agent.Provider, agent.LLMModel
+	}
+	if providerName == "" {
+		providerName = "ollama"
+	}
+	provider, err := llm.GlobalRegistry.Get(providerName)
+	if err != nil {
+		apierror.BadRequest(w, cid, "Провайдер "+providerName+" не настроен", "Проверьте конфигурацию провайдера")
+		return
+	}
+
+	cfg := config.Current()
+	topK := req.TopK
+	if topK <= 0 {
+		topK = cfg.RAGTopK
+	}
+	topK = min(topK, ragAskMaxTopK)
+
+	filter, ok, err := req.ragFilterRequest.resolve(r.Context())
+	if err != nil {
+		apierror.BadRequest(w, cid, err.Error(), "")
+		return
+	}
+	start := time.Now()
+	ctx := budget.WithSubject(r.Context(), agentName, requestKeyID(r))
+	var results []rag.SearchResult
+	if ok {
+		results, err = ragRetriever.Query(ctx, req.Question, topK, filter)
+	}
+	if err != nil {
+		metrics.RecordRAGSearch("error", 0, time.Since(start))
+		apierror.ServiceUnavailable(w, cid, err.Error(), "Проверьте доступность ChromaDB")
+		return
+	}
+	metrics.RecordRAGSearch("success", len(results), time.Since(start))
+
+	// Фрагменты проверяются защитой так же, как RAG-контекст /chat
+	g := currentGuard()
+	resp := ragAskResponse{Answer: rag.NoAnswer, Citations: []rag.Citation{}}
+	kept := results[:0]
+	for _, res := range results {
+		if res.Score < req.MinScore {
+			continue
+		}
+		verdict := g.CheckInput(guard.SourceRAG, res.Doc.Title, res.Doc.Content)
+		resp.Guard = append(resp.Guard, verdict.Findings...)
+		res.Doc.Content = verdict.Apply(rag.TruncateChunk(res.Doc.Content, cfg.RAGMaxChunkLen))
+		kept = append(kept, res)
+	}
+	kept = rag.LimitContext(kept, cfg.RAGMaxContextLen)
+	if len(kept) == 0 {
+		resp.TookMs = time.Since(start).Milliseconds()
+		writeJSON(w, resp)
+		return
+	}
+
+	if budget.IsCloud(providerName) && db.DB != nil {
+		if exceeded, _ := budget.Exceeded(db.DB, agentName, requestKeyID(r), time.Now()); len(exceeded) > 0 {
+			for _, b := range exceeded {
+				metrics.RecordBudgetExceeded(b.Scope, "refused")
+			}
+			apierror.Write(w, http.StatusTooManyRequests, apierror.Response{
+				Code:      "BUDGET_EXCEEDED",
+				Message:   "Бюджет на облачные модели исчерпан: " + budget.Message(exceeded),
+				Hint:      "Укажите локальную модель (provider, model) или увеличьте лимит в /usage/budgets",
+				RequestID: cid,
+			})
+			return
+		}
+	}
+
+	temperature := ragAskTemperature
+	if req.Temperature != nil {
+		temperature = *req.Temperature
+	}
+	llmResp, err := chatWithRetry(ctx, provider, &llm.ChatRequest{
+		Model: modelName,
+		Messages: []llm.Message{
+			{Role: "system", Content: rag.AskPrompt},
+			{Role: "user", Content: rag.AskUserMessage(req.Question, kept)},
+		},
+		Temperature: &temperature,
+	})
+	if err != nil {
+		slog.Error("Ошибка ответа по базе знаний", slog.String("модель", providerName+"/"+modelName), slog.String("ошибка", err.Error()), slog.String("request_id", cid))
+		apierror.LLMError(w, cid, err.Error(), "Повторите запрос или укажите другую модель")
+		return
+	}
+	answer, findings := g.CheckOutput(strings.TrimSpace(reasoning.Strip(llmResp.Content)))
+	resp.Guard = append(resp.Guard, findings...)
+	resp.Answer = answer
+	resp.Answered = answer != "" && !strings.Contains(answer, rag.NoAnswer)
+	resp.Citations = rag.Citations(answer, kept, 300)
+	resp.Provider, resp.Model = providerName, modelName
+	resp.TookMs = time.Since(start).Milliseconds()
+	slog.Info("Ответ по базе знаний", slog.String("модель", providerName+"/"+modelName), slog.Int("фрагментов", len(kept)),
+		slog.Bool("ответ_найден", resp.Answered), slog.Int64("время_мс", resp.TookMs), slog.String("request_id", cid))
+	writeJSON(w, resp)
+}
+
+// ragFilesHandler — обработчик для получения списка файлов в RAG (сгруппировано по папкам)
+func ragFilesHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodGet {
+		var docs []models.RagDocument
+		db.DB.Find(&docs)
+
+		type fileInfo struct {
+			FileName    string `json:"file_name"`
+			ChunksCount int    `json:"chunks_count"`
+		}
+		type folderData struct {
+			Folder     string     `json:"folder"`
+			Files      []fileInfo `json:"files"`
+			TotalFiles int        `json:"total_files"`
+		}
+
+		// Группируем по папкам
+		folderMap := make(map[string]*folderData)
+		for _, doc := range docs {
+			// Извлекаем папку из имени файла
+			parts := strings.Split(doc.Title, "/")
+			var folder, fileName string
+			if len(parts) > 1 {
+				folder = parts[0]
+				fileName = strings.Join(parts[1:], "/")
+			} else {
+				folder = "(корневая папка)"
+				fileName = doc.Title
+			}
+
+			if _, ok := folderMap[folder]; !ok {
+				folderMap[folder] = &folderData{
+					Folder:     folder,
+					Files:      []fileInfo{},
+					TotalFiles: 0,
+				}
+			}
+
+			folderMap[folder].Files = append(folderMap[folder].Files, fileInfo{
+				FileName:    fileName,
+				ChunksCount: doc.TotalChunks,
+			})
+			folderMap[folder].TotalFiles++
+		}
+
+		// Сортируем папки
+		folders := make([]*folderData, 0, len(folderMap))
+		for _, v := range folderMap {
+			folders = append(folders, v)
+		}
+
+		// Сортируем: корневая в конце
+		sort.Slice(folders, func(i, j int) bool {
+			f1 := folders[i].Folder
+			f2 := folders[j].Folder
+			if f1 == "(корневая папка)" {
+				return false
+			}
+			if f2 == "(корневая папка)" {
+				return true
+			}
+			return f1 < f2
+		})
+
+		writeJSON(w, folders)
+		return
+	}
+	cid := r.Header.Get("X-Request-ID")
+	apierror.MethodNotAllowed(w, cid)
+}
+
+// ragStaleDays — через сколько дней без обновления документ считается
+// устаревшим в /rag/stats (переопределяется ?stale_days=).
+const ragStaleDays = 90
+
+// ragStatsMaxSources — сколько самых больших источников показывать в /rag/stats.
+const ragStatsMaxSources = 20
+
+// ragSourceStats — документы одного источника в /rag/stats.
+type ragSourceStats struct {
+	Source      string    `json:"source"`
+	Documents   int       `json:"documents"`
+	Tokens      int       `json:"tokens"`
+	Stale       int       `json:"stale"` // Документов старше stale_days
+	LastUpdated time.Time `json:"last_updated"`
+}
+
+// ragCollectionStats — коллекция ChromaDB в /rag/stats; сверка с БД — только
+// для коллекции по умолчанию.
+type ragCollectionStats struct {
+	Name       string `json:"name"`
+	Chunks     int    `json:"chunks"`
+	Orphaned   *int   `json:"orphaned,omitempty"`   // Записей без строки БД
+	Missing    *int   `json:"missing,omitempty"`    // Строк БД без записи
+	Duplicates *int   `json:"duplicates,omitempty"` // Лишних записей одной строки
+}
+
+// ragStatsHandler — статистика и отчёт о качестве базы знаний: объём в
+// токенах, доля дублей, устаревшие документы, разбивка по источникам и,
+// если настроен ChromaDB, число записей в коллекциях и расхождения с БД.
+//
+//	GET /rag/stats?stale_days=90
+func ragStatsHandler(w http.ResponseWriter, r *http.Request) {
+	cid := r.Header.Get("X-Request-ID")
+	if r.Method != http.MethodGet {
+		apierror.MethodNotAllowed(w, cid)
+		return
+	}
+	staleDays := ragStaleDays
+	if s := r.URL.Query().Get("stale_days"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n <= 0 {
+			apierror.BadRequest(w, cid, "Невалидный stale_days", "Число дней, например stale_days=30")
+			return
+		}
+		staleDays = n
+	}
+	ctx := r.Context()
+
+	var rows []struct {
+		Title     string
+		Source    string
+		Size      int
+		UpdatedAt time.Time
+	}
+	if err := db.DB.WithContext(ctx).Model(&models.RagDocument{}).Select("title, source, updated_at, LENGTH(content) AS size").Scan(&rows).Error; err != nil {
+		apierror.InternalError(w, cid, "Не удалось прочитать документы: "+err.Error(), "")
+		return
+	}
+	var distinctContent, versions int64
+	db.DB.WithContext(ctx).Model(&models.RagDocument{}).Select("COUNT(DISTINCT content)").Scan(&distinctContent)
+	db.DB.WithContext(ctx).Model(&models.RagDocumentVersion{}).Count(&versions)
+
+	staleBefore := time.Now().AddDate(0, 0, -staleDays)
+	titles := map[string]bool{}
+	bySource := map[string]*ragSourceStats{}
+	var tokens, stale int
+	var oldest, newest time.Time
+	for _, row := range rows {
+		titles[row.Title] = true
+		t := (row.Size + 3) / 4 // Оценка как learnings.EstimateTokens: ~4 символа на токен
+		tokens += t
+		s := bySource[row.Source]
+		if s == nil {
+			s = &ragSourceStats{Source: row.Source}
+			bySource[row.Source] = s
+		}
+		s.Documents++
+		s.Tokens += t
+		if row.UpdatedAt.After(s.LastUpdated) {
+			s.LastUpdated = row.UpdatedAt
+		}
+		if row.UpdatedAt.Before(staleBefore) {
+			stale++
+			s.Stale++
+		}
+		if oldest.IsZero() || row.UpdatedAt.Before(oldest) {
+			oldest = row.UpdatedAt
+		}
+		if row.UpdatedAt.After(newest) {
+			newest = row.UpdatedAt
+		}
+	}
+	sources := make([]ragSourceStats, 0, len(bySource))
+	for _, s := range bySource {
+		sources = append(sources, *s)
+	}
+	sort.Slice(sources, func(i, j int) bool {
+		if sources[i].Tokens != sources[j].Tokens {
+			return sources[i].Tokens > sources[j].Tokens
+		}
+		return sources[i].Source < sources[j].Source
+	})
+	totalSources := len(sources)
+	if len(sources) > ragStatsMaxSources {
+		sources = sources[:ragStatsMaxSources]
+	}
+	duplicateRatio := 0.0
+	if len(rows) > 0 {
+		duplicateRatio = float64(int64(len(rows))-distinctContent) / float64(len(rows))
+	}
+
+	out := map[string]interface{}{
+		"facts_count":     len(rows),
+		"files_count":     len(titles),
+		"documents":       len(rows),
+		"versions":        versions,
+		"tokens_total":    tokens,
+		"duplicate_ratio": duplicateRatio,
+		"stale_days":      staleDays,
+		"stale_documents": stale,
+		"sources_count":   totalSources,
+		"sources":         sources,
+		"embedding_model": config.Current().EmbeddingModel,
+	}
+	if !newest.IsZero() {
+		out["last_updated"] = newest
+		out["oldest_update"] = oldest
+	}
+	if ragRetriever == nil || ragRetriever.Config().ChromaURL == "" {
+		writeJSON(w, out)
+		return
+	}
+	out["embedding_dimension"] = ragRetriever.EmbeddingDimension()
+	list, err := ragRetriever.Collections(ctx)
+	if err != nil {
+		out["chroma_error"] = err.Error()
+		writeJSON(w, out)
+		return
+	}
+	collections := make([]ragCollectionStats, len(list))
+	for i, c := range list {
+		collections[i] = ragCollectionStats{Name: c.Name, Chunks: c.Count}
+		if c.Name != rag.Collection {
+			continue
+		}
+		docs, err := storedRagDocs(ctx, false)
+		if err != nil {
+			apierror.InternalError(w, cid, "Не удалось прочитать документы: "+err.Error(), "")
+			return
+		}
+		entries, err := ragRetriever.Entries(ctx, c.Name)
+		if err != nil {
+			out["chroma_error"] = err.Error()
+			continue
+		}
+		rep := rag.Verify(c.Name, docs, entries)
+		collections[i].Orphaned, collections[i].Missing, collections[i].Duplicates = &rep.Orphaned, &rep.Missing, &rep.Duplicates
+	}
+	out["collections"] = collections
+	writeJSON(w, out)
+}
+
+// ragVersionInfo — версия документа RAG в списке /rag/versions.
+type ragVersionInfo struct {
+	Version   int       `json:"version"`
+	CreatedAt time.Time `json:"created_at"`
+	Size      int       `json:"size"`
+	Current   bool      `json:"current,omitempty"`
+	Content   string    `json:"content,omitempty"` // Только при ?version=
+}
+
+// findRagDocument — документ по ?id= или ?title= (и ?source=, если
+// документов с таким названием несколько). Ответ об ошибке уже записан,
+// если ok == false.
+func findRagDocument(w http.ResponseWriter, r *http.Request) (doc models.RagDocument, ok bool) {
+	cid := r.Header.Get("X-Request-ID")
+	q := r.URL.Query()
+	query := db.DB.WithContext(r.Context())
+	switch {
+	case q.Get("id") != "":
+		id, err := strconv.ParseUint(q.Get("id"), 10, 64)
+		if err != nil {
+			apierror.BadRequest(w, cid, "Некорректный id", "")
+			return doc, false
+		}
+		query = query.Where("id = ?", id)
+	case q.Get("title") != "":
+		query = query.Where("title = ?", q.Get("title"))
+		if q.Has("source") {
+			query = query.Where("source = ?", q.Get("source"))
+		}
+	default:
+		apierror.BadRequest(w, cid, "Требуется id или title", "")
+		return doc, false
+	}
+	var rows []models.RagDocument
+	if err := query.Select("id", "title", "source", "version", "content", "updated_at").Order("id DESC").Find(&rows).Error; err != nil {
+		apierror.InternalError(w, cid, "Не удалось прочитать документ: "+err.Error(), "")
+		return doc, false
+	}
+	if len(rows) == 0 {
+		apierror.NotFound(w, cid, "Документ не найден")
+		return doc, false
+	}
+	sources := map[string]bool{}
+	for _, row := range rows {
+		sources[row.Source] = true
+	}
+	if len(sources) > 1 {
+		list := make([]string, 0, len(sources))
+		for src := range sources {
+			list = append(list, src)
+		}
+		sort.Strings(list)
+		apierror.BadRequest(w, cid, "Документов с таким названием несколько", "Укажите source: "+strings.Join(list, ", "))
+		return doc, false
+	}
+	return rows[0], true
+}
 
-	reqBody := map[string]interface{}{
-		"query": query,
-		"top_k": topK,
+// ragVersion — содержимое и время загрузки версии n документа doc.
+func ragVersion(ctx context.Context, doc models.RagDocument, n int) (ragVersionInfo, error) {
+	if n == doc.Version {
+		return ragVersionInfo{Version: n, CreatedAt: doc.UpdatedAt, Size: len(doc.Content), Current: true, Content: doc.Content}, nil
 	}
-	data, err := json.Marshal(reqBody)
-	if err != nil {
-		slog.Error("[SKILL-FETCH] ошибка сериализации", slog.String("ошибка", err.Error()))
-		return ""
+	var v models.RagDocumentVersion
+	if err := db.DB.WithContext(ctx).Where("document_id = ? AND version = ?", doc.ID, n).First(&v).Error; err != nil {
+		return ragVersionInfo{}, err
 	}
+	return ragVersionInfo{Version: n, CreatedAt: v.CreatedAt, Size: len(v.Content), Content: v.Content}, nil
+}
 
-	client := &http.Client{Timeout: 10 * time.Second}
-	resp, err := client.Post(memoryURL+"/skills/search", "application/json", bytes.NewReader(data))
-	if err != nil {
-		slog.Warn("[SKILL-FETCH] memory-service недоступен", slog.String("ошибка", err.Error()))
-		return ""
+// ragVersionsHandler — история версий документа RAG.
+//
+//	GET /rag/versions?title=&source=         — список версий (или ?id=)
+//	GET /rag/versions?title=&version=2       — содержимое версии
+//	GET /rag/versions/diff?title=&from=&to=  — unified diff между версиями
+//	                                           (по умолчанию предыдущая → текущая)
+func ragVersionsHandler(w http.ResponseWriter, r *http.Request) {
+	cid := r.Header.Get("X-Request-ID")
+	if r.Method != http.MethodGet {
+		apierror.MethodNotAllowed(w, cid)
+		return
+	}
+	diff := r.URL.Path == "/rag/versions/diff"
+	doc, ok := findRagDocument(w, r)
+	if !ok {
+		return
+	}
+	ctx := r.Context()
+	q := r.URL.Query()
+	version := func(key string, def int) (ragVersionInfo, bool) {
+		n := def
+		if s := q.Get(key); s != "" {
+			var err error
+			if n, err = strconv.Atoi(s); err != nil {
+				apierror.BadRequest(w, cid, "Некорректный "+key, "")
+				return ragVersionInfo{}, false
+			}
+		}
+		v, err := ragVersion(ctx, doc, n)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			apierror.NotFound(w, cid, fmt.Sprintf("Версия %d не найдена", n))
+			return v, false
+		}
+		if err != nil {
+			apierror.InternalError(w, cid, "Не удалось прочитать версию: "+err.Error(), "")
+			return v, false
+		}
+		return v, true
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		slog.Warn("[SKILL-FETCH] ошибка ответа", slog.Int("статус", resp.StatusCode))
-		return ""
+	if diff {
+		to, ok := version("to", doc.Version)
+		if !ok {
+			return
+		}
+		from, ok := version("from", to.Version-1)
+		if !ok {
+			return
+		}
+		d := textdiff.Unified(doc.Title, from.Content, to.Content)
+		writeJSON(w, map[string]interface{}{
+			"db_id": doc.ID, "title": doc.Title, "source": doc.Source,
+			"from": from.Version, "to": to.Version,
+			"diff": d.Text, "added": d.Added, "removed": d.Removed, "identical": d.Text == "",
+		})
+		return
+	}
+	if q.Get("version") != "" {
+		if v, ok := version("version", 0); ok {
+			writeJSON(w, v)
+		}
+		return
 	}
 
-	var result struct {
-		Results []struct {
-			Goal        string   `json:"goal"`
-			Steps       []string `json:"steps"`
-			Examples    []string `json:"examples"`
-			Constraints []string `json:"constraints"`
-			Confidence  float64  `json:"confidence"`
-			Relevance   float64  `json:"relevance"`
-			ID          string   `json:"id"`
-		} `json:"results"`
-		Count int `json:"count"`
+	var history []models.RagDocumentVersion
+	if err := db.DB.WithContext(ctx).Where("document_id = ?", doc.ID).Order("version DESC").Find(&history).Error; err != nil {
+		apierror.InternalError(w, cid, "Не удалось прочитать версии: "+err.Error(), "")
+		return
 	}
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		slog.Error("[SKILL-FETCH] ошибка декодирования", slog.String("ошибка", err.Error()))
-		return ""
+	cur, _ := ragVersion(ctx, doc, doc.Version)
+	cur.Content = ""
+	versions := []ragVersionInfo{cur}
+	for _, v := range history {
+		versions = append(versions, ragVersionInfo{Version: v.Version, CreatedAt: v.CreatedAt, Size: len(v.Content)})
 	}
+	writeJSON(w, map[string]interface{}{
+		"db_id": doc.ID, "title": doc.Title, "source": doc.Source,
+		"current_version": doc.Version, "versions": versions,
+	})
+}
 
-	if len(result.Results) == 0 {
-		return ""
+// ragAdminMu — одна пересборка коллекции ChromaDB за раз.
+var ragAdminMu sync.Mutex
+
+// storedRagDocs — документы таблицы RagDocument (без удалённых) для сверки
+// и пересборки ChromaDB; текст загружается только для пересборки.
+func storedRagDocs(ctx context.Context, withContent bool) ([]rag.StoredDoc, error) {
+	fields := []string{"id", "title", "source", "version", "updated_at", "workspace_id"}
+	if withContent {
+		fields = append(fields, "content")
+	}
+	var rows []models.RagDocument
+	if err := db.DB.WithContext(ctx).Select(fields).Order("id").Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	docs := make([]rag.StoredDoc, len(rows))
+	for i, row := range rows {
+		docs[i] = rag.StoredDoc{DBID: row.ID, Title: row.Title, Source: row.Source, Version: row.Version, Content: row.Content, UpdatedAt: row.UpdatedAt}
+		if row.WorkspaceID != nil {
+			docs[i].WorkspaceID = *row.WorkspaceID
+		}
 	}
+	return docs, nil
+}
 
-	// Форматируем навыки для добавления в системный промпт
-	skillContext := "\n\n=== Навыки агента (Skill Engine) ===\n"
-	for i, s := range result.Results {
-		skillContext += fmt.Sprintf("[Навык %d] %s (confidence: %.0f%%)\n", i+1, s.Goal, s.Confidence*100)
-		if len(s.Steps) > 0 {
-			skillContext += "  Шаги: "
-			for j, step := range s.Steps {
-				if j > 0 {
-					skillContext += " → "
-				}
-				skillContext += step
+// ragAdminHandler — администрирование коллекций ChromaDB.
+//
+//	GET  /rag/admin/collections — коллекции и число записей, документов в БД
+//	GET  /rag/admin/verify      — сверка RagDocument ↔ ChromaDB (?collection=, по умолчанию rag_docs)
+//	POST /rag/admin/rebuild     — пересобрать коллекцию из RagDocument {collection?}: rag_docs
+//	                              или коллекцию с метаданными owner=agent-service-rag
+func ragAdminHandler(w http.ResponseWriter, r *http.Request) {
+	cid := r.Header.Get("X-Request-ID")
+	action := strings.TrimPrefix(r.URL.Path, "/rag/admin/")
+	method := map[string]string{"collections": http.MethodGet, "verify": http.MethodGet, "rebuild": http.MethodPost}[action]
+	if method == "" {
+		apierror.NotFound(w, cid, "Неизвестный путь")
+		return
+	}
+	if r.Method != method {
+		apierror.MethodNotAllowed(w, cid)
+		return
+	}
+	if ragRetriever == nil || ragRetriever.Config().ChromaURL == "" {
+		apierror.ServiceUnavailable(w, cid, rag.ErrChromaDisabled.Error(), "Задайте CHROMA_URL: без него поиск идёт только по БД")
+		return
+	}
+	ctx := r.Context()
+	collection := r.URL.Query().Get("collection")
+
+	switch action {
+	case "collections":
+		list, err := ragRetriever.Collections(ctx)
+		if err != nil {
+			apierror.ServiceUnavailable(w, cid, err.Error(), "Проверьте CHROMA_URL и CHROMA_API_VERSION")
+			return
+		}
+		var dbCount int64
+		db.DB.Model(&models.RagDocument{}).Count(&dbCount)
+		writeJSON(w, map[string]interface{}{"collections": list, "default": rag.Collection, "db_documents": dbCount})
+
+	case "verify":
+		if collection == "" {
+			collection = rag.Collection
+		}
+		docs, err := storedRagDocs(ctx, false)
+		if err != nil {
+			apierror.InternalError(w, cid, "Не удалось прочитать документы: "+err.Error(), "")
+			return
+		}
+		entries, err := ragRetriever.Entries(ctx, collection)
+		if err != nil {
+			apierror.ServiceUnavailable(w, cid, err.Error(), "Проверьте, что коллекция существует: GET /rag/admin/collections")
+			return
+		}
+		rep := rag.Verify(collection, docs, entries)
+		if !rep.Consistent {
+			slog.Warn("БД и ChromaDB расходятся", slog.String("коллекция", collection), slog.Int("нет_в_chroma", rep.Missing),
+				slog.Int("лишних_в_chroma", rep.Orphaned), slog.Int("дублей", rep.Duplicates), slog.String("request_id", cid))
+		}
+		writeJSON(w, rep)
+
+	case "rebuild":
+		if r.ContentLength != 0 {
+			var req struct {
+				Collection string `json:"collection"`
+			}
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				apierror.BadRequest(w, cid, "Невалидный JSON", "Пример: {\"collection\": \"rag_docs\"}")
+				return
+			}
+			if req.Collection != "" {
+				collection = req.Collection
 			}
-			skillContext += "\n"
 		}
-		if len(s.Constraints) > 0 {
-			skillContext += "  Ограничения: " + strings.Join(s.Constraints, "; ") + "\n"
+		if collection == "" {
+			collection = rag.Collection
 		}
-		// Фиксируем использование навыка асинхронно
-		go recordSkillUsage(memoryURL, s.ID)
+		if !ragAdminMu.TryLock() {
+			apierror.Write(w, http.StatusConflict, apierror.Response{
+				Code:      apierror.CodeForStatus(http.StatusConflict),
+				Message:   "пересборка уже выполняется",
+				Hint:      "Дождитесь окончания и проверьте результат: GET /rag/admin/verify",
+				RequestID: cid,
+			})
+			return
+		}
+		defer ragAdminMu.Unlock()
+		docs, err := storedRagDocs(ctx, true)
+		if err != nil {
+			apierror.InternalError(w, cid, "Не удалось прочитать документы: "+err.Error(), "")
+			return
+		}
+		start := time.Now()
+		res, err := ragRetriever.Rebuild(ctx, collection, docs)
+		if errors.Is(err, rag.ErrForeignCollection) {
+			apierror.Write(w, http.StatusForbidden, apierror.Response{
+				Code:      apierror.CodeForStatus(http.StatusForbidden),
+				Message:   err.Error(),
+				Hint:      "Пересобирать можно " + rag.Collection + " и коллекции с метаданными " + rag.OwnerKey + "=" + rag.Owner,
+				RequestID: cid,
+			})
+			return
+		}
+		if err != nil {
+			slog.Error("Ошибка пересборки коллекции ChromaDB", slog.String("коллекция", collection), slog.Int("проиндексировано", res.Indexed), slog.String("ошибка", err.Error()), slog.String("request_id", cid))
+			apierror.ServiceUnavailable(w, cid, err.Error(), "Пересборка идемпотентна — повторите запрос")
+			return
+		}
+		slog.Info("Коллекция ChromaDB пересобрана", slog.String("коллекция", collection), slog.Int("проиндексировано", res.Indexed),
+			slog.Int("удалено", res.Removed), slog.Duration("время", time.Since(start)), slog.String("request_id", cid))
+		writeJSON(w, res)
 	}
-	skillContext += "Применяй эти навыки при решении задач пользователя.\n"
+}
 
-	slog.Info("[SKILL-FETCH] навыки найдены", slog.Int("количество", len(result.Results)))
-	return skillContext
+// ragDeleteRequest — условия удаления документов RAG; заданные условия
+// объединяются через И.
+type ragDeleteRequest struct {
+	Name         string   `json:"name"`          // Точное название (прежний параметр)
+	Names        []string `json:"names"`         // Несколько названий сразу
+	SourcePrefix string   `json:"source_prefix"` // Префикс источника, например folder:/docs
+	DryRun       bool     `json:"dry_run"`       // Только посчитать, ничего не удалять
 }
 
-// recordSkillUsage — асинхронная фиксация использования навыка.
-// Увеличивает usage_count и confidence навыка в memory-service.
-func recordSkillUsage(memoryURL, skillID string) {
-	client := &http.Client{Timeout: 5 * time.Second}
-	req, err := http.NewRequest("POST", memoryURL+"/skills/"+skillID+"/usage", nil)
+// likePrefix — шаблон LIKE «начинается с prefix» с экранированием % и _.
+func likePrefix(prefix string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(prefix) + "%"
+}
+
+// deleteChromaDocs — удаляет из ChromaDB записи удалённых строк. Ошибка не
+// отменяет удаление из БД: лишние записи покажет GET /rag/admin/verify.
+func deleteChromaDocs(ctx context.Context, cid string, docs []rag.StoredDoc, purge bool) (int, error) {
+	if ragRetriever == nil || ragRetriever.Config().ChromaURL == "" {
+		return 0, nil
+	}
+	var n int
+	var err error
+	if purge {
+		n, err = ragRetriever.Purge(ctx, rag.Collection)
+	} else {
+		n, err = ragRetriever.DeleteDocs(ctx, rag.Collection, docs)
+	}
 	if err != nil {
+		slog.Warn("Не удалось удалить записи из ChromaDB", slog.Int("удалено", n), slog.String("ошибка", err.Error()), slog.String("request_id", cid))
+	}
+	return n, err
+}
+
+// ragDeleteHandler — удаление документов RAG из БД и их записей из ChromaDB.
+//
+//	DELETE /rag/delete?name=a.md                  — документ по названию
+//	DELETE /rag/delete?source_prefix=folder:/docs — все документы папки
+//	POST   /rag/delete {names, source_prefix, dry_run}
+func ragDeleteHandler(w http.ResponseWriter, r *http.Request) {
+	cid := r.Header.Get("X-Request-ID")
+	if r.Method != http.MethodDelete && r.Method != http.MethodPost {
+		apierror.MethodNotAllowed(w, cid)
 		return
 	}
-	resp, err := client.Do(req)
+
+	q := r.URL.Query()
+	req := ragDeleteRequest{Name: q.Get("name"), SourcePrefix: q.Get("source_prefix"), DryRun: q.Get("dry_run") == "1" || q.Get("dry_run") == "true"}
+	if req.Name == "" && req.SourcePrefix == "" {
+		json.NewDecoder(r.Body).Decode(&req)
+	}
+	names := req.Names
+	if req.Name != "" {
+		names = append(names, req.Name)
+	}
+	if len(names) == 0 && req.SourcePrefix == "" {
+		apierror.BadRequest(w, cid, "Требуется name, names или source_prefix", "Удалить всю базу знаний — POST /rag/purge")
+		return
+	}
+
+	ctx := r.Context()
+	query := db.DB.WithContext(ctx).Model(&models.RagDocument{})
+	if len(names) > 0 {
+		query = query.Where("title IN ?", names)
+	}
+	if req.SourcePrefix != "" {
+		query = query.Where(`source LIKE ? ESCAPE '\'`, likePrefix(req.SourcePrefix))
+	}
+	var rows []models.RagDocument
+	if err := query.Select("id", "title", "source").Find(&rows).Error; err != nil {
+		slog.Error("Ошибка поиска RAG документов для удаления", slog.String("ошибка", err.Error()))
+		apierror.InternalError(w, cid, "Не удалось удалить", "")
+		return
+	}
+	resp := map[string]interface{}{"status": "ok", "deleted": len(rows)}
+	if req.DryRun || len(rows) == 0 {
+		resp["dry_run"] = req.DryRun
+		writeJSON(w, resp)
+		return
+	}
+
+	ids := make([]uint, len(rows))
+	docs := make([]rag.StoredDoc, len(rows))
+	for i, row := range rows {
+		ids[i] = row.ID
+		docs[i] = rag.StoredDoc{DBID: row.ID, Title: row.Title, Source: row.Source}
+	}
+	err := db.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("document_id IN ?", ids).Delete(&models.RagDocumentVersion{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&models.RagDocument{}, ids).Error
+	})
 	if err != nil {
-		slog.Warn("[SKILL-USAGE] ошибка записи", slog.String("skill_id", skillID), slog.String("ошибка", err.Error()))
+		slog.Error("Ошибка удаления RAG документа", slog.String("ошибка", err.Error()))
+		apierror.InternalError(w, cid, "Не удалось удалить", "")
 		return
 	}
-	defer resp.Body.Close()
+	removed, err := deleteChromaDocs(ctx, cid, docs, false)
+	resp["chroma_removed"] = removed
+	if err != nil {
+		resp["chroma_error"] = err.Error()
+	}
+
+	slog.Info("RAG документы удалены", slog.Any("названия", names), slog.String("префикс", req.SourcePrefix),
+		slog.Int("удалено", len(rows)), slog.Int("из ChromaDB", removed), slog.String("request_id", cid))
+	writeJSON(w, resp)
 }
 
-// extractAndStoreLearnings — извлечение и сохранение знаний из диалога.
-// Вызывается асинхронно (в горутине) после каждого успешного ответа от LLM.
-//
-// Алгоритм извлечения знаний:
-//  1. Анализ последнего сообщения пользователя и ответа агента
-//  2. Определение категории знания:
-//     - preference: предпочтения пользователя (язык, стиль, формат)
-//     - fact: факты о системе, окружении, проекте
-//     - correction: исправления и уточнения от пользователя
-//     - skill: успешные подходы к решению задач
-//     - general: прочие полезные знания
-//  3. Формирование текста знания и отправка в memory-service
-//  4. Для категории "skill" — дополнительно создаёт навык через Skill Engine
-//  5. Создание связей в Graph Engine между новым и существующими знаниями
-//
-// Знания привязываются к конкретной модели (modelName), а не к агенту,
-// потому что при смене агента модель сохраняет свои знания.
-//
-// Параметры:
-//   - modelName: имя модели LLM
-//   - agentName: имя агента (admin)
-//   - userMsg: последнее сообщение пользователя
-//   - assistantResp: ответ агента
-func extractAndStoreLearnings(modelName, agentName, userMsg, assistantResp string) {
-	memoryURL := getEnv("MEMORY_SERVICE_URL", "http://localhost:8001")
+// ragPurgeTTL — сколько действует токен подтверждения полной очистки.
+const ragPurgeTTL = 5 * time.Minute
 
-	// Определяем категорию знания на основе содержания диалога
-	category := classifyLearningCategory(userMsg, assistantResp)
+// ragPurge — выданный токен подтверждения очистки базы знаний (один за раз).
+var ragPurge struct {
+	sync.Mutex
+	token   string
+	expires time.Time
+}
 
-	// Формируем текст знания — компактное резюме взаимодействия
-	learningText := formatLearningText(userMsg, assistantResp, category)
-	if learningText == "" {
+// ragPurgeHandler — полная очистка базы знаний в два шага: POST без тела
+// выдаёт одноразовый токен, POST {confirm_token} в течение ragPurgeTTL
+// удаляет все документы из БД и все записи коллекции ChromaDB.
+func ragPurgeHandler(w http.ResponseWriter, r *http.Request) {
+	cid := r.Header.Get("X-Request-ID")
+	if r.Method != http.MethodPost {
+		apierror.MethodNotAllowed(w, cid)
+		return
+	}
+	var req struct {
+		ConfirmToken string `json:"confirm_token"`
+	}
+	json.NewDecoder(r.Body).Decode(&req)
+	ctx := r.Context()
+
+	if req.ConfirmToken == "" {
+		var count int64
+		if err := db.DB.WithContext(ctx).Model(&models.RagDocument{}).Count(&count).Error; err != nil {
+			apierror.InternalError(w, cid, "Не удалось посчитать документы: "+err.Error(), "")
+			return
+		}
+		ragPurge.Lock()
+		ragPurge.token = uuid.NewString()
+		ragPurge.expires = time.Now().Add(ragPurgeTTL)
+		token := ragPurge.token
+		ragPurge.Unlock()
+		writeJSON(w, map[string]interface{}{
+			"confirm_token": token,
+			"expires_in":    int(ragPurgeTTL.Seconds()),
+			"documents":     count,
+		})
+		return
+	}
+
+	ragPurge.Lock()
+	valid := ragPurge.token != "" && req.ConfirmToken == ragPurge.token && time.Now().Before(ragPurge.expires)
+	if valid {
+		ragPurge.token = ""
+	}
+	ragPurge.Unlock()
+	if !valid {
+		apierror.BadRequest(w, cid, "Неверный или просроченный confirm_token", "Получите новый токен: POST /rag/purge без тела")
+		return
+	}
+
+	res := db.DB.WithContext(ctx).Where("1 = 1").Delete(&models.RagDocumentVersion{})
+	if res.Error == nil {
+		res = db.DB.WithContext(ctx).Where("1 = 1").Delete(&models.RagDocument{})
+	}
+	if res.Error != nil {
+		slog.Error("Ошибка очистки базы знаний", slog.String("ошибка", res.Error.Error()), slog.String("request_id", cid))
+		apierror.InternalError(w, cid, "Не удалось очистить базу знаний", "")
 		return
 	}
+	resp := map[string]interface{}{"status": "ok", "deleted": res.RowsAffected}
+	removed, err := deleteChromaDocs(ctx, cid, nil, true)
+	resp["chroma_removed"] = removed
+	if err != nil {
+		resp["chroma_error"] = err.Error()
+	}
+	slog.Warn("База знаний очищена", slog.Int64("удалено", res.RowsAffected), slog.Int("из ChromaDB", removed), slog.String("request_id", cid))
+	WriteSystemLog("warn", "agent-service", "База знаний RAG очищена", fmt.Sprintf("документов: %d, записей ChromaDB: %d", res.RowsAffected, removed))
+	writeJSON(w, resp)
+}
+
+var supportedExtensions = map[string]bool{
+	".txt": true, ".md": true, ".markdown": true,
+	".json": true, ".jsonl": true,
+	".csv":  true,
+	".html": true, ".htm": true,
+	".xml":  true,
+	".yaml": true, ".yml": true,
+	".go": true, ".py": true, ".js": true, ".ts": true,
+	".java": true, ".c": true, ".cpp": true, ".h": true, ".hpp": true,
+	".rs": true, ".rb": true, ".php": true, ".swift": true, ".kt": true,
+	".sh": true, ".bash": true, ".zsh": true,
+	".sql": true, ".graphql": true, ".gql": true,
+	".dockerfile": true, ".toml": true, ".ini": true, ".conf": true,
+	".log": true,
+}
+
+// fetchRAGFromMemory — получение RAG-контекста из memory-service вместо ChromaDB.
+// Выполняет семантический поиск по базе знаний memory-service для обогащения
+// контекста модели при обработке чат-запроса.
+func fetchRAGFromMemory(query string, topK int) (string, []Source) {
+	memoryURL := config.Current().MemoryServiceURL
 
 	reqBody := map[string]interface{}{
-		"text":       learningText,
-		"model_name": modelName,
-		"agent_name": agentName,
-		"category":   category,
-		"metadata": map[string]interface{}{
-			"source": "auto_extract",
-		},
+		"query":         query,
+		"top_k":         topK,
+		"include_files": true,
 	}
 	data, err := json.Marshal(reqBody)
 	if err != nil {
-		slog.Error("Ошибка сериализации знания", slog.String("ошибка", err.Error()))
-		return
+		slog.Error("Ошибка сериализации RAG запроса к memory-service", slog.String("ошибка", err.Error()))
+		return "", nil
 	}
 
-	resp, err := http.Post(memoryURL+"/learnings", "application/json", bytes.NewReader(data))
-	if err != nil {
-		slog.Error("Ошибка сохранения знания в memory-service", slog.String("ошибка", err.Error()))
-		return
+	resp, err := memoryClient.Post(memoryURL+"/search", "application/json", bytes.NewReader(data))
+	if err != nil {
+		slog.Warn("memory-service недоступен для RAG поиска, fallback на ChromaDB", slog.String("ошибка", err.Error()))
+		return "", nil
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		slog.Warn("memory-service вернул ошибку при сохранении знания", slog.Int("статус", resp.StatusCode))
-		return
+		slog.Warn("memory-service вернул ошибку при RAG поиске", slog.Int("статус", resp.StatusCode))
+		return "", nil
 	}
 
-	// Декодируем ответ для получения ID знания (нужен для связей в графе)
-	var learningResp struct {
-		ID string `json:"id"`
+	var result struct {
+		Results []struct {
+			Text     string                 `json:"text"`
+			Score    float64                `json:"score"`
+			Source   string                 `json:"source"`
+			Metadata map[string]interface{} `json:"metadata"`
+		} `json:"results"`
+		Count int `json:"count"`
 	}
-	if err := json.NewDecoder(resp.Body).Decode(&learningResp); err != nil {
-		slog.Warn("Ошибка декодирования ответа learning", slog.String("ошибка", err.Error()))
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		slog.Error("Ошибка декодирования RAG ответа от memory-service", slog.String("ошибка", err.Error()))
+		return "", nil
 	}
 
-	slog.Info("Знание сохранено", slog.String("модель", modelName), slog.String("категория", category), slog.String("id", learningResp.ID))
-
-	// === Автоматическое создание навыка из диалога (Eternal RAG: раздел 7) ===
-	// Если категория — "skill", создаём структурированный навык через Skill Engine.
-	// Навык содержит цель, шаги, примеры — извлечённые из текста диалога.
-	if category == "skill" {
-		go createSkillFromDialog(memoryURL, userMsg+"\n"+assistantResp, modelName)
+	if len(result.Results) == 0 {
+		return "", nil
 	}
 
-	// === Автоматическое создание связей в Graph Engine ===
-	// Если знание связано с противоречием, создаём связь "contradicts" в графе.
-	// Это позволяет обходить граф знаний и находить конфликтующие факты.
-	if learningResp.ID != "" {
-		go autoCreateGraphRelationships(memoryURL, learningResp.ID, learningText, modelName)
+	var sources []Source
+	ragContext := "\n\n=== База знаний (RAG — memory-service) ===\n"
+	for i, r := range result.Results {
+		title := r.Source
+		if fn, ok := r.Metadata["file_name"]; ok {
+			title = fmt.Sprintf("%v", fn)
+		}
+		ragContext += fmt.Sprintf("[%d] %s: %s\n", i+1, title, truncate(r.Text, 150))
+		sources = append(sources, Source{
+			Title:   title,
+			Content: truncate(r.Text, 100),
+			Score:   i + 1,
+		})
 	}
+	ragContext += "Используй эту информацию из базы знаний для более точных ответов.\n"
+	return ragContext, sources
 }
 
-// createSkillFromDialog — создание навыка из текста диалога через Skill Engine.
-// Вызывается асинхронно когда extractAndStoreLearnings обнаруживает категорию "skill".
-func createSkillFromDialog(memoryURL, dialogText, modelName string) {
-	reqBody := map[string]interface{}{
-		"dialog_text": dialogText,
-		"model_name":  modelName,
+// proxyToMemoryService — вспомогательная функция для проксирования запросов к memory-service.
+// Используется новыми RAG-обработчиками (move, soft-delete, restore, pin, content-search, contradictions).
+func proxyToMemoryService(w http.ResponseWriter, method string, path string, body []byte) {
+	memoryURL := config.Current().MemoryServiceURL
+	url := memoryURL + path
+
+	var req *http.Request
+	var err error
+	if body != nil {
+		req, err = http.NewRequest(method, url, bytes.NewReader(body))
+	} else {
+		req, err = http.NewRequest(method, url, nil)
 	}
-	data, err := json.Marshal(reqBody)
 	if err != nil {
-		slog.Error("[SKILL-CREATE] ошибка сериализации", slog.String("ошибка", err.Error()))
+		slog.Error("Ошибка создания запроса к memory-service", slog.String("ошибка", err.Error()))
+		apierror.InternalError(w, "", "Не удалось сформировать запрос к memory-service", err.Error())
 		return
 	}
+	req.Header.Set("Content-Type", "application/json")
 
-	client := &http.Client{Timeout: 15 * time.Second}
-	resp, err := client.Post(memoryURL+"/skills/from-dialog", "application/json", bytes.NewReader(data))
+	client := httpclient.New("memory", 30*time.Second)
+	resp, err := client.Do(req)
 	if err != nil {
-		slog.Warn("[SKILL-CREATE] ошибка создания навыка", slog.String("ошибка", err.Error()))
+		slog.Error("Ошибка запроса к memory-service", slog.String("путь", path), slog.String("ошибка", err.Error()))
+		apierror.ServiceUnavailable(w, "", "memory-service недоступен", "Проверьте, что memory-service запущен и MEMORY_SERVICE_URL указан верно")
 		return
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode == http.StatusOK {
-		slog.Info("[SKILL-CREATE] навык создан из диалога", slog.String("модель", modelName))
-	} else {
-		slog.Warn("[SKILL-CREATE] ошибка ответа", slog.Int("статус", resp.StatusCode))
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(resp.StatusCode)
+	io.Copy(w, resp.Body)
+}
+
+// ragMoveHandler — перемещение файла между папками в RAG-базе
+func ragMoveHandler(w http.ResponseWriter, r *http.Request) {
+	cid := r.Header.Get("X-Request-ID")
+	if r.Method != http.MethodPost {
+		apierror.MethodNotAllowed(w, cid)
+		return
 	}
+	body, _ := io.ReadAll(r.Body)
+	proxyToMemoryService(w, "POST", "/files/move", body)
 }
 
-// autoCreateGraphRelationships — автоматическое создание связей в графе знаний.
-// После сохранения нового знания ищем похожие существующие знания
-// и создаём связи "relates_to" между ними (Eternal RAG: раздел 5.4).
-func autoCreateGraphRelationships(memoryURL, learningID, learningText, modelName string) {
-	// Ищем похожие знания для создания связей
-	reqBody := map[string]interface{}{
-		"query":      learningText,
-		"model_name": modelName,
-		"top_k":      3,
+// ragSoftDeleteHandler — мягкое удаление файла (пометка deleted_at)
+func ragSoftDeleteHandler(w http.ResponseWriter, r *http.Request) {
+	cid := r.Header.Get("X-Request-ID")
+	if r.Method != http.MethodPost && r.Method != http.MethodPatch {
+		apierror.MethodNotAllowed(w, cid)
+		return
 	}
-	data, err := json.Marshal(reqBody)
-	if err != nil {
+	body, _ := io.ReadAll(r.Body)
+	proxyToMemoryService(w, "PATCH", "/files/soft-delete", body)
+}
+
+// ragRestoreHandler — восстановление мягко удалённого файла
+func ragRestoreHandler(w http.ResponseWriter, r *http.Request) {
+	cid := r.Header.Get("X-Request-ID")
+	if r.Method != http.MethodPost && r.Method != http.MethodPatch {
+		apierror.MethodNotAllowed(w, cid)
 		return
 	}
+	body, _ := io.ReadAll(r.Body)
+	proxyToMemoryService(w, "PATCH", "/files/restore", body)
+}
 
-	client := &http.Client{Timeout: 10 * time.Second}
-	resp, err := client.Post(memoryURL+"/learnings/search", "application/json", bytes.NewReader(data))
-	if err != nil {
+// ragPinHandler — закрепление/открепление файла
+func ragPinHandler(w http.ResponseWriter, r *http.Request) {
+	cid := r.Header.Get("X-Request-ID")
+	if r.Method == http.MethodPost {
+		body, _ := io.ReadAll(r.Body)
+		proxyToMemoryService(w, "POST", "/files/pin", body)
 		return
 	}
-	defer resp.Body.Close()
+	if r.Method == http.MethodDelete {
+		name := r.URL.Query().Get("name")
+		proxyToMemoryService(w, "DELETE", "/files/pin?name="+name, nil)
+		return
+	}
+	apierror.MethodNotAllowed(w, cid)
+}
 
-	if resp.StatusCode != http.StatusOK {
+// ragRenameHandler — переименование файла в RAG-базе
+func ragRenameHandler(w http.ResponseWriter, r *http.Request) {
+	cid := r.Header.Get("X-Request-ID")
+	if r.Method != http.MethodPatch && r.Method != http.MethodPost {
+		apierror.MethodNotAllowed(w, cid)
 		return
 	}
+	body, _ := io.ReadAll(r.Body)
+	proxyToMemoryService(w, "PATCH", "/files/rename", body)
+}
 
-	var searchResult struct {
-		Results []struct {
-			ID    string  `json:"id"`
-			Score float64 `json:"score"`
-		} `json:"results"`
+// ragContentSearchHandler — семантический поиск по содержимому файлов
+func ragContentSearchHandler(w http.ResponseWriter, r *http.Request) {
+	cid := r.Header.Get("X-Request-ID")
+	if r.Method != http.MethodPost {
+		apierror.MethodNotAllowed(w, cid)
+		return
 	}
-	if err := json.NewDecoder(resp.Body).Decode(&searchResult); err != nil {
+	body, _ := io.ReadAll(r.Body)
+	proxyToMemoryService(w, "POST", "/files/content-search", body)
+}
+
+// ragContradictionsHandler — получение списка обнаруженных противоречий
+func ragContradictionsHandler(w http.ResponseWriter, r *http.Request) {
+	cid := r.Header.Get("X-Request-ID")
+	if r.Method != http.MethodGet {
+		apierror.MethodNotAllowed(w, cid)
 		return
 	}
+	topK := r.URL.Query().Get("top_k")
+	path := "/contradictions"
+	if topK != "" {
+		path += "?top_k=" + topK
+	}
+	proxyToMemoryService(w, "GET", path, nil)
+}
 
-	// Создаём связи "relates_to" к похожим знаниям (score > 0.7, исключая само знание)
-	minRelateScore := 0.7
-	for _, r := range searchResult.Results {
-		if r.ID == learningID || r.Score < minRelateScore {
-			continue
-		}
-		relBody := map[string]interface{}{
-			"source_id":         learningID,
-			"target_id":         r.ID,
-			"relationship_type": "relates_to",
-			"source_type":       "knowledge",
-			"target_type":       "knowledge",
-			"metadata": map[string]interface{}{
-				"auto_created": true,
-				"similarity":   r.Score,
-			},
-		}
-		relData, err := json.Marshal(relBody)
-		if err != nil {
-			continue
-		}
-		relResp, err := client.Post(memoryURL+"/graph/relationships", "application/json", bytes.NewReader(relData))
-		if err != nil {
-			slog.Warn("[GRAPH-AUTO] ошибка создания связи", slog.String("ошибка", err.Error()))
-			continue
-		}
-		relResp.Body.Close()
-		slog.Info("[GRAPH-AUTO] связь создана", slog.String("source", learningID), slog.String("target", r.ID))
+// ragDeletedFilesHandler — получение списка мягко удалённых файлов для корзины
+func ragDeletedFilesHandler(w http.ResponseWriter, r *http.Request) {
+	cid := r.Header.Get("X-Request-ID")
+	if r.Method != http.MethodGet {
+		apierror.MethodNotAllowed(w, cid)
+		return
 	}
+	proxyToMemoryService(w, "GET", "/files/deleted", nil)
 }
 
-// classifyLearningCategory — автоматическая классификация категории знания.
-// Анализирует текст сообщения пользователя и ответа агента,
-// определяя к какой категории относится извлечённое знание.
-//
-// Категории:
-//   - "preference": пользователь выражает предпочтение (язык, стиль, формат)
-//   - "correction": пользователь исправляет агента или указывает на ошибку
-//   - "fact": обмен фактической информацией о системе/окружении
-//   - "skill": описание решения задачи, алгоритма, подхода
-//   - "general": прочие знания, не попадающие в другие категории
-func classifyLearningCategory(userMsg, assistantResp string) string {
-	lowerUser := strings.ToLower(userMsg)
+// === Proxy-обработчики для Skill Engine (Eternal RAG: раздел 5.3) ===
+// Проксируют запросы от web-ui к memory-service для управления навыками.
 
-	// Исправления и коррекции
-	correctionKeywords := []string{"неправильно", "не так", "ошибка", "исправь", "wrong", "fix",
-		"не верно", "неверно", "не правильно", "ты ошибся", "это не то"}
-	for _, kw := range correctionKeywords {
-		if strings.Contains(lowerUser, kw) {
-			return "correction"
+// skillsListHandler — получение списка / создание навыков
+func skillsListHandler(w http.ResponseWriter, r *http.Request) {
+	cid := r.Header.Get("X-Request-ID")
+	switch r.Method {
+	case http.MethodGet:
+		// Передаём query-параметры (workspace_id, skill_status)
+		query := r.URL.RawQuery
+		path := "/skills"
+		if query != "" {
+			path += "?" + query
 		}
+		proxyToMemoryService(w, "GET", path, nil)
+	case http.MethodPost:
+		body, _ := io.ReadAll(r.Body)
+		proxyToMemoryService(w, "POST", "/skills", body)
+	default:
+		apierror.MethodNotAllowed(w, cid)
 	}
+}
 
-	// Предпочтения пользователя
-	preferenceKeywords := []string{"всегда", "по-русски", "на русском", "по русски",
-		"предпочитаю", "мне нравится", "хочу чтобы", "используй", "формат",
-		"отвечай", "пиши", "стиль"}
-	for _, kw := range preferenceKeywords {
-		if strings.Contains(lowerUser, kw) {
-			return "preference"
-		}
+// learningsHandler — управление накопленными знаниями моделей (прокси в memory-service):
+//   - GET /learnings/{model}?category=&pinned_only=true — список активных знаний модели
+//   - PATCH /learnings/item/{id} {"text", "category"} — исправить знание
+//   - DELETE /learnings/item/{id} — удалить знание
+//   - POST / DELETE /learnings/item/{id}/pin — закрепить / открепить (закреплённые
+//     подставляются в промпт всегда, независимо от релевантности)
+func learningsHandler(w http.ResponseWriter, r *http.Request) {
+	cid := r.Header.Get("X-Request-ID")
+	rest := strings.TrimPrefix(r.URL.EscapedPath(), "/learnings/")
+	if rest == "" || rest == "item/" {
+		apierror.BadRequest(w, cid, "Не указана модель или ID знания", "GET /learnings/{model} или /learnings/item/{id}")
+		return
 	}
 
-	// Факты о системе
-	factKeywords := []string{"у меня", "мой компьютер", "моя система", "установлен",
-		"версия", "конфигурация", "характеристики", "параметры", "specs"}
-	for _, kw := range factKeywords {
-		if strings.Contains(lowerUser, kw) {
-			return "fact"
+	if itemPath, ok := strings.CutPrefix(rest, "item/"); ok {
+		id, pin := strings.CutSuffix(itemPath, "/pin")
+		if id == "" || strings.Contains(id, "/") {
+			apierror.NotFound(w, cid, "Маршрут не найден")
+			return
+		}
+		switch {
+		case pin && (r.Method == http.MethodPost || r.Method == http.MethodDelete):
+			proxyToMemoryService(w, r.Method, "/learnings/item/"+id+"/pin", nil)
+		case !pin && r.Method == http.MethodPatch:
+			body, _ := io.ReadAll(r.Body)
+			proxyToMemoryService(w, http.MethodPatch, "/learnings/item/"+id, body)
+		case !pin && r.Method == http.MethodDelete:
+			proxyToMemoryService(w, http.MethodDelete, "/learnings/item/"+id, nil)
+		default:
+			apierror.MethodNotAllowed(w, cid)
 		}
+		return
 	}
 
-	// Навыки и подходы
-	skillKeywords := []string{"как сделать", "решение", "алгоритм", "подход",
-		"метод", "способ", "инструкция", "tutorial", "how to"}
-	for _, kw := range skillKeywords {
-		if strings.Contains(lowerUser, kw) {
-			return "skill"
-		}
+	if r.Method != http.MethodGet {
+		apierror.MethodNotAllowed(w, cid)
+		return
+	}
+	path := "/learnings/list/" + rest
+	if r.URL.RawQuery != "" {
+		path += "?" + r.URL.RawQuery
 	}
+	proxyToMemoryService(w, http.MethodGet, path, nil)
+}
 
-	return "general"
+// skillByIDHandler — CRUD операции над конкретным навыком (/skills/{id})
+func skillByIDHandler(w http.ResponseWriter, r *http.Request) {
+	cid := r.Header.Get("X-Request-ID")
+	// Извлекаем ID из пути: /skills/{id}
+	skillID := strings.TrimPrefix(r.URL.Path, "/skills/")
+	// Убираем суффикс /usage если есть (отдельный обработчик)
+	if strings.HasSuffix(skillID, "/usage") {
+		skillUsageHandler(w, r)
+		return
+	}
+	if skillID == "" {
+		apierror.BadRequest(w, r.Header.Get("X-Request-ID"), "Не указан skill_id", "Укажите идентификатор скилла в пути: /skills/{id}")
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		proxyToMemoryService(w, "GET", "/skills/"+skillID, nil)
+	case http.MethodPut:
+		body, _ := io.ReadAll(r.Body)
+		proxyToMemoryService(w, "PUT", "/skills/"+skillID, body)
+	case http.MethodDelete:
+		proxyToMemoryService(w, "DELETE", "/skills/"+skillID, nil)
+	default:
+		apierror.MethodNotAllowed(w, cid)
+	}
 }
 
-// formatLearningText — формирование текста знания из диалога.
-// Создаёт компактное резюме взаимодействия, которое будет сохранено
-// в базе знаний модели и использовано в будущих диалогах.
-//
-// Для разных категорий формат различается:
-//   - correction: фиксируется что было неправильно и как исправлено
-//   - preference: фиксируется предпочтение пользователя
-//   - fact: фиксируется факт о системе/окружении
-//   - skill/general: краткое резюме взаимодействия
-//
-// Фильтрация: слишком короткие сообщения (< 10 символов) игнорируются,
-// так как из них невозможно извлечь полезное знание.
-func formatLearningText(userMsg, assistantResp, category string) string {
-	if len(userMsg) < 10 {
-		return ""
+// skillSearchHandler — семантический поиск навыков
+func skillSearchHandler(w http.ResponseWriter, r *http.Request) {
+	cid := r.Header.Get("X-Request-ID")
+	if r.Method != http.MethodPost {
+		apierror.MethodNotAllowed(w, cid)
+		return
 	}
+	body, _ := io.ReadAll(r.Body)
+	proxyToMemoryService(w, "POST", "/skills/search", body)
+}
 
-	// Ограничиваем длину для компактности
-	maxUserLen := 200
-	maxRespLen := 300
-	if len(userMsg) > maxUserLen {
-		userMsg = userMsg[:maxUserLen] + "..."
+// skillFromDialogHandler — создание навыка из диалога
+func skillFromDialogHandler(w http.ResponseWriter, r *http.Request) {
+	cid := r.Header.Get("X-Request-ID")
+	if r.Method != http.MethodPost {
+		apierror.MethodNotAllowed(w, cid)
+		return
 	}
-	if len(assistantResp) > maxRespLen {
-		assistantResp = assistantResp[:maxRespLen] + "..."
+	body, _ := io.ReadAll(r.Body)
+	proxyToMemoryService(w, "POST", "/skills/from-dialog", body)
+}
+
+// skillUsageHandler — фиксация использования навыка
+func skillUsageHandler(w http.ResponseWriter, r *http.Request) {
+	cid := r.Header.Get("X-Request-ID")
+	if r.Method != http.MethodPost {
+		apierror.MethodNotAllowed(w, cid)
+		return
+	}
+	// Путь: /skills/{id}/usage → извлекаем ID
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/skills/"), "/")
+	if len(parts) < 2 || parts[0] == "" {
+		apierror.BadRequest(w, r.Header.Get("X-Request-ID"), "Не указан skill_id", "Укажите идентификатор скилла в пути: /skills/{id}")
+		return
 	}
+	proxyToMemoryService(w, "POST", "/skills/"+parts[0]+"/usage", nil)
+}
 
-	switch category {
-	case "correction":
-		return "Пользователь указал на ошибку: " + userMsg + " | Исправленный ответ: " + assistantResp
-	case "preference":
-		return "Предпочтение пользователя: " + userMsg
-	case "fact":
-		return "Факт о системе: " + userMsg + " | Контекст: " + assistantResp
-	case "skill":
-		return "Решение задачи: " + userMsg + " | Подход: " + assistantResp
+// === Proxy-обработчики для Graph Engine (Eternal RAG: раздел 5.4) ===
+// Проксируют запросы от web-ui к memory-service для управления графом знаний.
+
+// graphRelationshipsHandler — список / создание связей
+func graphRelationshipsHandler(w http.ResponseWriter, r *http.Request) {
+	cid := r.Header.Get("X-Request-ID")
+	switch r.Method {
+	case http.MethodGet:
+		query := r.URL.RawQuery
+		path := "/graph/relationships"
+		if query != "" {
+			path += "?" + query
+		}
+		proxyToMemoryService(w, "GET", path, nil)
+	case http.MethodPost:
+		body, _ := io.ReadAll(r.Body)
+		proxyToMemoryService(w, "POST", "/graph/relationships", body)
 	default:
-		return "Контекст диалога: " + userMsg + " | Ответ: " + assistantResp
+		apierror.MethodNotAllowed(w, cid)
 	}
 }
 
-// learningStatsHandler — получение статистики обучения из memory-service (GET /learning-stats).
-// Проксирует запрос к memory-service /learnings/stats и возвращает результат.
-// Показывает общее количество знаний, разбивку по моделям и категориям.
-func learningStatsHandler(w http.ResponseWriter, r *http.Request) {
+// graphRelationshipByIDHandler — удаление связи по ID
+func graphRelationshipByIDHandler(w http.ResponseWriter, r *http.Request) {
 	cid := r.Header.Get("X-Request-ID")
-	if r.Method != http.MethodGet {
+	relID := strings.TrimPrefix(r.URL.Path, "/graph/relationships/")
+	if relID == "" {
+		apierror.BadRequest(w, r.Header.Get("X-Request-ID"), "Не указан relationship_id", "Укажите идентификатор связи в пути запроса")
+		return
+	}
+	if r.Method != http.MethodDelete {
 		apierror.MethodNotAllowed(w, cid)
 		return
 	}
+	proxyToMemoryService(w, "DELETE", "/graph/relationships/"+relID, nil)
+}
 
-	memoryURL := getEnv("MEMORY_SERVICE_URL", "http://localhost:8001")
-	resp, err := http.Get(memoryURL + "/learnings/stats")
-	if err != nil {
-		apierror.InternalError(w, cid, "Ошибка подключения к memory-service", err.Error())
+// graphNeighborsHandler — получение соседних узлов
+func graphNeighborsHandler(w http.ResponseWriter, r *http.Request) {
+	cid := r.Header.Get("X-Request-ID")
+	if r.Method != http.MethodGet {
+		apierror.MethodNotAllowed(w, cid)
 		return
 	}
-	defer resp.Body.Close()
-
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(resp.StatusCode)
-	io.Copy(w, resp.Body)
+	nodeID := strings.TrimPrefix(r.URL.Path, "/graph/neighbors/")
+	if nodeID == "" {
+		apierror.BadRequest(w, r.Header.Get("X-Request-ID"), "Не указан node_id", "Укажите идентификатор узла в пути запроса")
+		return
+	}
+	query := r.URL.RawQuery
+	path := "/graph/neighbors/" + nodeID
+	if query != "" {
+		path += "?" + query
+	}
+	proxyToMemoryService(w, "GET", path, nil)
 }
 
-// providersHandler — управление облачными LLM-провайдерами (GET/POST /providers).
-//
-// GET — возвращает список всех провайдеров с их статусом:
-//   - ollama (всегда включён, локальный)
-//   - openai, anthropic, yandexgpt, gigachat (облачные)
-//     Для каждого провайдера возвращается: имя, включён ли, есть ли API-ключ,
-//     список доступных моделей (если провайдер активен).
-//
-// POST — сохранение/обновление конфигурации провайдера:
-//
-//	Принимает JSON с полями: provider, api_key, base_url, folder_id, scope, enabled.
-//	Сохраняет конфигурацию в PostgreSQL и регистрирует провайдера в реестре.
-//	Поля обновляются выборочно — пустые значения не перезаписывают существующие.
-//
-// Используется UI для настройки облачных провайдеров в панели моделей.
-func providersHandler(w http.ResponseWriter, r *http.Request) {
+// graphTraverseHandler — обход графа знаний (BFS)
+func graphTraverseHandler(w http.ResponseWriter, r *http.Request) {
 	cid := r.Header.Get("X-Request-ID")
-	switch r.Method {
-	case http.MethodGet:
-		var configs []models.ProviderConfig
-		db.DB.Find(&configs)
+	if r.Method != http.MethodPost {
+		apierror.MethodNotAllowed(w, cid)
+		return
+	}
+	body, _ := io.ReadAll(r.Body)
+	proxyToMemoryService(w, "POST", "/graph/traverse", body)
+}
 
-		type ProviderResponse struct {
-			Name         string            `json:"name"`
-			Enabled      bool              `json:"enabled"`
-			Models       []string          `json:"models"`
-			ModelsDetail []llm.ModelDetail `json:"models_detail"`
-			HasKey       bool              `json:"hasKey"`
-			Guide        ProviderGuide     `json:"guide"`
-		}
+// embeddingStatusHandler — статус модели эмбеддингов
+func embeddingStatusHandler(w http.ResponseWriter, r *http.Request) {
+	cid := r.Header.Get("X-Request-ID")
+	if r.Method != http.MethodGet {
+		apierror.MethodNotAllowed(w, cid)
+		return
+	}
+	proxyToMemoryService(w, "GET", "/embeddings/status", nil)
+}
 
-		var result []ProviderResponse
+// ragAddFolderHandler — обработчик для рекурсивной загрузки папки в RAG
+func ragAddFolderHandler(w http.ResponseWriter, r *http.Request) {
+	cid := r.Header.Get("X-Request-ID")
+	if r.Method != http.MethodPost {
+		apierror.MethodNotAllowed(w, cid)
+		return
+	}
 
-		// Ollama — может быть как локальным, так и удалённым (через API)
-		ollamaResp := ProviderResponse{
-			Name:    "ollama",
-			Enabled: true,
-			HasKey:  true,
-			Guide:   getProviderGuide("ollama"),
-		}
-		if ollamaProvider, ollamaErr := llm.GlobalRegistry.Get("ollama"); ollamaErr == nil {
-			if ollamaModelList, listErr := ollamaProvider.ListModels(); listErr == nil {
-				ollamaResp.Models = ollamaModelList
-			}
-			if ollamaDetailed, detailErr := ollamaProvider.ListModelsDetailed(); detailErr == nil {
-				ollamaResp.ModelsDetail = ollamaDetailed
-			}
-		}
-		result = append(result, ollamaResp)
+	var req struct {
+		FolderPath  string `json:"folder_path"`
+		Async       bool   `json:"async"` // Загрузить в фоне заданием очереди (JOBS_QUEUE)
+		WorkspaceID *uint  `json:"workspace_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		apierror.BadRequest(w, cid, "Невалидный JSON", "")
+		return
+	}
 
-		cloudProviders := []string{"yandexgpt", "gigachat"}
-		for _, name := range cloudProviders {
-			pr := ProviderResponse{Name: name, Guide: getProviderGuide(name)}
-			for _, cfg := range configs {
-				if cfg.ProviderName == name {
-					pr.Enabled = cfg.Enabled
-					pr.HasKey = cfg.APIKey != "" || cfg.ServiceAccountJSON != ""
-					break
-				}
-			}
-			p, err := llm.GlobalRegistry.Get(name)
-			if err == nil {
-				pr.HasKey = true
-				modelNames, _ := p.ListModels()
-				pr.Models = modelNames
-				detailed, _ := p.ListModelsDetailed()
-				pr.ModelsDetail = detailed
-			}
-			result = append(result, pr)
-		}
+	folderPath := req.FolderPath
+	if folderPath == "" {
+		apierror.BadRequest(w, cid, "Требуется folder_path", "")
+		return
+	}
 
-		w.Header().Set("Content-Type", "application/json")
-		w.Header().Set("Cache-Control", "no-cache, no-store, must-revalidate")
-		w.Header().Set("Pragma", "no-cache")
-		writeJSON(w, result)
+	info, err := os.Stat(folderPath)
+	if err != nil {
+		apierror.NotFound(w, cid, "Папка не найдена")
+		return
+	}
+	if !info.IsDir() {
+		apierror.BadRequest(w, cid, "Путь не является папкой", "")
+		return
+	}
 
-	case http.MethodPost:
-		var req struct {
-			Provider           string `json:"provider"`
-			APIKey             string `json:"api_key"`
-			BaseURL            string `json:"base_url"`
-			FolderID           string `json:"folder_id"`
-			Scope              string `json:"scope"`
-			ServiceAccountJSON string `json:"service_account_json"`
-			Enabled            bool   `json:"enabled"`
-		}
-		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-			apierror.BadRequest(w, cid, "Невалидный JSON", "")
+	if req.Async {
+		if jobQueue == nil {
+			apierror.ServiceUnavailable(w, cid, "Очередь заданий выключена", "Включите JOBS_QUEUE=true или загрузите папку синхронно")
 			return
 		}
-		if req.Provider == "" {
-			apierror.BadRequest(w, cid, "Требуется provider", "")
+		job, err := jobQueue.Enqueue(jobRAGIngestFolder, ragFolderJob{FolderPath: folderPath, WorkspaceID: req.WorkspaceID}, time.Time{})
+		if err != nil {
+			apierror.InternalError(w, cid, "Не удалось поставить задание в очередь", err.Error())
 			return
 		}
+		writeJobAccepted(w, job)
+		return
+	}
 
-		// Шаг 1: Регистрируем провайдера временно для проверки ключа
-		extra := req.FolderID
-		if req.Scope != "" {
-			extra = req.Scope
+	res := ingestFolder(folderPath, req.WorkspaceID)
+	res.Status = "ok"
+	writeJSON(w, res)
+}
+
+// ragFolderJob — параметры задания загрузки папки в RAG.
+type ragFolderJob struct {
+	FolderPath  string `json:"folder_path"`
+	WorkspaceID *uint  `json:"workspace_id,omitempty"`
+}
+
+// ragFolderResult — итог загрузки папки в RAG.
+type ragFolderResult struct {
+	Status     string   `json:"status,omitempty"`
+	FolderPath string   `json:"folder_path"`
+	Added      int      `json:"files_added"`     // Новых документов
+	Updated    int      `json:"files_updated"`   // Изменившихся: сохранена новая версия
+	Unchanged  int      `json:"files_unchanged"` // Уже загруженных без изменений
+	Skipped    int      `json:"files_skipped"`   // Неподдерживаемых расширений
+	Errors     []string `json:"errors"`
+}
+
+// ingestFolder — рекурсивная загрузка файлов папки в RAG (скрытые папки и
+// неподдерживаемые расширения пропускаются). Повторная загрузка той же папки
+// создаёт новые версии только изменившихся файлов.
+func ingestFolder(folderPath string, workspaceID *uint) ragFolderResult {
+	res := ragFolderResult{FolderPath: folderPath}
+	var walkFunc func(path string, info os.FileInfo, err error) error
+	walkFunc = func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return nil
 		}
-		apiKey := req.APIKey
-		saJSON := req.ServiceAccountJSON
-		if apiKey == "" || saJSON == "" {
-			var existingCfg models.ProviderConfig
-			db.DB.Where("provider_name = ?", req.Provider).First(&existingCfg)
-			if apiKey == "" {
-				apiKey = existingCfg.APIKey
-			}
-			if saJSON == "" {
-				saJSON = existingCfg.ServiceAccountJSON
+		if info.IsDir() {
+			// Пропускаем скрытые папки
+			if strings.HasPrefix(info.Name(), ".") {
+				return filepath.SkipDir
 			}
+			return nil
 		}
-		if apiKey == "" && saJSON == "" && req.Provider != "lmstudio" && req.Provider != "ollama" {
-			w.Header().Set("Content-Type", "application/json")
-			w.WriteHeader(http.StatusBadRequest)
-			writeJSON(w, map[string]interface{}{
-				"status": "error",
-				"error":  "API-ключ или JSON сервисного аккаунта не указан",
-				"hint":   getProviderHint(req.Provider),
-			})
-			return
+
+		ext := strings.ToLower(filepath.Ext(path))
+		if !supportedExtensions[ext] {
+			res.Skipped++
+			return nil
 		}
 
-		if err := llm.RegisterProvider(req.Provider, apiKey, req.BaseURL, extra, saJSON); err != nil {
-			slog.Error("Ошибка регистрации провайдера", slog.String("провайдер", req.Provider), slog.String("ошибка", err.Error()))
-			w.Header().Set("Content-Type", "application/json")
-			w.WriteHeader(http.StatusBadRequest)
-			writeJSON(w, map[string]interface{}{
-				"status": "error",
-				"error":  fmt.Sprintf("Не удалось зарегистрировать провайдер: %v", err),
-				"hint":   getProviderHint(req.Provider),
-			})
-			return
+		// Читаем содержимое файла
+		content, err := os.ReadFile(path)
+		if err != nil {
+			res.Errors = append(res.Errors, path+": "+err.Error())
+			return nil
 		}
 
-		// Шаг 2: Проверяем ключ — запрашиваем список моделей
-		p, err := llm.GlobalRegistry.Get(req.Provider)
+		// Относительный путь от папки
+		relPath, _ := filepath.Rel(folderPath, path)
+		title := relPath
+
+		ragDoc, changed, err := saveRagDocument(context.Background(), title, string(content), "folder:"+folderPath, workspaceID)
 		if err != nil {
-			w.Header().Set("Content-Type", "application/json")
-			w.WriteHeader(http.StatusInternalServerError)
-			writeJSON(w, map[string]interface{}{
-				"status": "error",
-				"error":  "Провайдер не найден после регистрации",
-				"hint":   getProviderHint(req.Provider),
-			})
-			return
+			slog.Error("Ошибка сохранения RAG документа в БД", slog.String("ошибка", err.Error()))
+			res.Errors = append(res.Errors, title+": "+err.Error())
+			return nil
+		}
+		switch {
+		case !changed:
+			res.Unchanged++
+			return nil
+		case ragDoc.Version > 1:
+			res.Updated++
+		default:
+			res.Added++
 		}
+		indexRagDocument(ragDoc)
+		slog.Info("RAG файл добавлен из папки", slog.String("заголовок", title), slog.Int("версия", ragDoc.Version))
+		return nil
+	}
 
-		if req.Provider == "yandexgpt" {
-			if yp, ok := p.(*llm.YandexGPTProvider); ok {
-				if err := yp.Validate(); err != nil {
-					slog.Warn("Проверка провайдера не пройдена (горутина)", slog.String("провайдер", req.Provider), slog.String("ошибка", err.Error()))
-					w.Header().Set("Content-Type", "application/json")
-					w.WriteHeader(http.StatusBadRequest)
-					writeJSON(w, map[string]interface{}{
-						"status": "error",
-						"error":  fmt.Sprintf("Ключ/настройки не прошли проверку: %v", err),
-						"hint":   getProviderHint(req.Provider),
-					})
-					return
-				}
-				if resolved := yp.GetResolvedFolderID(); resolved != "" && req.FolderID == "" {
-					req.FolderID = resolved
-				}
+	if err := filepath.Walk(folderPath, walkFunc); err != nil {
+		slog.Error("Ошибка сканирования папки RAG", slog.String("ошибка", err.Error()))
+	}
+
+	return res
+}
+
+// handleSearchKnowledge — обработчик инструмента search_knowledge: поиск по
+// базе знаний с фильтрами метаданных (как POST /rag/search).
+func handleSearchKnowledge(ctx context.Context, args map[string]interface{}) map[string]interface{} {
+	query, _ := args["query"].(string)
+	if strings.TrimSpace(query) == "" {
+		return map[string]interface{}{"error": "требуется query"}
+	}
+	if ragRetriever == nil || ragRetriever.Config().ChromaURL == "" {
+		return map[string]interface{}{"error": rag.ErrChromaDisabled.Error()}
+	}
+	var f ragFilterRequest
+	f.Source, _ = args["source"].(string)
+	f.SourcePrefix, _ = args["source_prefix"].(string)
+	f.Since, _ = args["since"].(string)
+	f.Until, _ = args["until"].(string)
+	if exts, ok := args["extensions"].([]interface{}); ok {
+		for _, e := range exts {
+			if s, ok := e.(string); ok {
+				f.Extensions = append(f.Extensions, s)
 			}
 		}
+	}
+	topK := 5
+	if n, ok := args["top_k"].(float64); ok && n > 0 {
+		topK = min(int(n), ragAskMaxTopK)
+	}
+	filter, ok, err := f.resolve(ctx)
+	if err != nil {
+		return map[string]interface{}{"error": err.Error()}
+	}
+	var results []rag.SearchResult
+	if ok {
+		if results, err = ragRetriever.Query(ctx, query, topK, filter); err != nil {
+			return map[string]interface{}{"error": err.Error()}
+		}
+	}
+	maxChunk := config.Current().RAGMaxChunkLen
+	found := make([]map[string]interface{}, 0, len(results))
+	for _, res := range results {
+		found = append(found, map[string]interface{}{
+			"title":   res.Doc.Title,
+			"source":  res.Doc.Source,
+			"score":   res.Score,
+			"content": rag.TruncateChunk(res.Doc.Content, maxChunk),
+		})
+	}
+	return map[string]interface{}{"results": found, "count": len(found)}
+}
 
-		verifyModels, verifyErr := p.ListModels()
-		if verifyErr != nil {
-			slog.Warn("Проверка провайдера не пройдена", slog.String("провайдер", req.Provider), slog.String("ошибка", verifyErr.Error()))
-			WriteSystemLog("error", "agent-service", fmt.Sprintf("Провайдер %s: ключ не прошёл проверку", req.Provider), verifyErr.Error())
-			w.Header().Set("Content-Type", "application/json")
-			w.WriteHeader(http.StatusUnauthorized)
-			writeJSON(w, map[string]interface{}{
-				"status": "error",
-				"error":  fmt.Sprintf("Ключ не прошёл проверку: %v", verifyErr),
-				"hint":   getProviderHint(req.Provider),
-			})
-			return
+// handleViewLogs — обработчик инструмента view_logs для Админа.
+// Позволяет агенту просматривать системные логи с фильтрацией по уровню и сервису.
+func handleViewLogs(args map[string]interface{}) map[string]interface{} {
+	values := url.Values{}
+	for _, key := range []string{"level", "service", "correlation_id", "query", "since", "until"} {
+		if v, ok := args[key].(string); ok && v != "" {
+			values.Set(key, v)
 		}
-		slog.Info("Провайдер проверен", slog.String("провайдер", req.Provider), slog.Int("моделей", len(verifyModels)))
-		WriteSystemLog("info", "agent-service", fmt.Sprintf("Провайдер %s подключён, моделей: %d", req.Provider, len(verifyModels)), "")
+	}
+	values.Set("q", values.Get("query"))
+	q, err := logstore.ParseQuery(values, time.Now())
+	if err != nil {
+		return map[string]interface{}{"error": err.Error()}
+	}
+	q.Limit = 20
+	if l, ok := args["limit"].(float64); ok && l > 0 {
+		q.Limit = int(min(l, logstore.MaxLimit))
+	}
 
-		// Шаг 3: Ключ прошёл проверку — сохраняем в БД
-		var cfg models.ProviderConfig
-		db.DB.Where("provider_name = ?", req.Provider).FirstOrCreate(&cfg, models.ProviderConfig{ProviderName: req.Provider})
-		cfg.APIKey = apiKey
-		if req.BaseURL != "" {
-			cfg.BaseURL = req.BaseURL
-		}
-		if req.FolderID != "" {
-			cfg.FolderID = req.FolderID
+	if groupBy, ok := args["group_by"].(string); ok && groupBy != "" {
+		buckets, err := q.Aggregate(db.DB, groupBy)
+		if err != nil {
+			return map[string]interface{}{"error": err.Error()}
 		}
-		if req.Scope != "" {
-			cfg.Scope = req.Scope
+		return map[string]interface{}{"group_by": groupBy, "buckets": buckets}
+	}
+
+	logs, err := q.Find(db.DB)
+	if err != nil {
+		return map[string]interface{}{"error": "Ошибка чтения логов: " + err.Error()}
+	}
+
+	var entries []map[string]interface{}
+	for _, l := range logs {
+		entry := map[string]interface{}{
+			"id":       l.ID,
+			"level":    l.Level,
+			"service":  l.Service,
+			"message":  l.Message,
+			"details":  l.Details,
+			"resolved": l.Resolved,
+			"time":     l.CreatedAt.Format("2006-01-02 15:04:05"),
 		}
-		if saJSON != "" {
-			cfg.ServiceAccountJSON = saJSON
+		if l.CorrelationID != "" {
+			entry["correlation_id"] = l.CorrelationID
 		}
-		cfg.Enabled = req.Enabled
+		entries = append(entries, entry)
+	}
 
-		if err := db.DB.Save(&cfg).Error; err != nil {
-			apierror.InternalError(w, cid, "Не удалось сохранить конфигурацию", "")
-			return
-		}
+	return map[string]interface{}{
+		"count": len(entries),
+		"logs":  entries,
+	}
+}
 
-		w.Header().Set("Content-Type", "application/json")
-		writeJSON(w, map[string]interface{}{
-			"status": "ok",
-			"models": verifyModels,
-		})
+// ============================================================================
+// Составные скилы-подстраховки (compound skill handlers)
+// Каждый обработчик выполняет цепочку базовых инструментов за один вызов.
+// Умная модель (7B+) предпочтёт базовые инструменты и сама построит цепочку.
+// Слабая модель (3B) вызовет один составной скил и получит готовый результат.
+// ============================================================================
 
-	default:
-		apierror.MethodNotAllowed(w, cid)
+// handleSetupGitAutomation — составной скил: полная git-автоматизация проекта.
+// Выполняет цепочку: mkdir → git init → создание autocommit.sh → создание backup.sh → добавление в crontab.
+// Все шаги выполняются последовательно через callToolCtx(ctx, "execute", ...).
+func handleSetupGitAutomation(ctx context.Context, args map[string]interface{}) map[string]interface{} {
+	projectPath, _ := args["project_path"].(string)
+	backupPath, _ := args["backup_path"].(string)
+	if projectPath == "" || backupPath == "" {
+		return map[string]interface{}{"error": "project_path и backup_path обязательны"}
 	}
-}
 
-// getProviderHint— возвращает подсказку для пользователя при ошибке подключения провайдера.
-// Каждый провайдер имеет свои особенности аутентификации и настройки.
-func getProviderHint(provider string) string {
-	switch provider {
-	case "gigachat":
-		return "GigaChat: убедитесь, что указан корректный API-ключ (Authorization Key) из личного кабинета Сбера. " +
-			"Также укажите scope: GIGACHAT_API_PERS (для физлиц) или GIGACHAT_API_B2B (для юрлиц). " +
-			"Получить ключ: https://developers.sber.ru/portal/products/gigachat"
-	case "yandexgpt":
-		return "YandexGPT: укажите API-ключ и Folder ID из Yandex Cloud. " +
-			"API-ключ создаётся в IAM → Сервисные аккаунты → Ключи API. " +
-			"Folder ID находится на главной странице каталога. " +
-			"Документация: https://cloud.yandex.ru/docs/yandexgpt/"
-	case "openrouter":
-		return "OpenRouter: укажите API-ключ с сайта https://openrouter.ai/keys. " +
-			"Убедитесь, что на балансе есть средства (Credits)."
-	case "openai":
-		return "OpenAI: укажите API-ключ с https://platform.openai.com/api-keys. " +
-			"Убедитесь, что ключ активен и на балансе есть средства."
-	case "anthropic":
-		return "Anthropic: укажите API-ключ с https://console.anthropic.com/settings/keys. " +
-			"Убедитесь, что ключ активен."
-	case "ollama":
-		return "Ollama: укажите URL сервера (по умолчанию http://localhost:11434). " +
-			"Убедитесь, что Ollama запущена: ollama serve. " +
-			"Для удалённого доступа задайте OLLAMA_HOST=0.0.0.0"
-	case "cerebras":
-		return "Cerebras: укажите API-ключ с https://cloud.cerebras.ai → API Keys. " +
-			"Free tier: 1M токенов/день, 30 RPM. Без привязки карты."
-	default:
-		return "Проверьте правильность API-ключа и параметров подключения."
-	}
-}
-
-// ProviderGuide — подробное руководство по провайдеру для отображения в UI.
-// Содержит пошаговые инструкции: подключение, выбор модели, оплата, проверка баланса.
-type ProviderGuide struct {
-	HowToConnect string `json:"how_to_connect"`
-	HowToChoose  string `json:"how_to_choose"`
-	HowToPay     string `json:"how_to_pay"`
-	HowToBalance string `json:"how_to_balance"`
-}
-
-// getProviderGuide — возвращает подробное руководство по провайдеру.
-// Инструкции включают: как подключить, как выбрать модель, где оплатить,
-// как проверить баланс средств/токенов или оставшуюся подписку.
-func getProviderGuide(provider string) ProviderGuide {
-	switch provider {
-	case "ollama":
-		return ProviderGuide{
-			HowToConnect: "1. Установите Ollama: curl -fsSL https://ollama.com/install.sh | sh\n" +
-				"2. Запустите сервер: ollama serve\n" +
-				"3. Скачайте модель: ollama pull llama3.1:8b\n" +
-				"4. URL по умолчанию: http://localhost:11434\n" +
-				"5. Для удалённого доступа: OLLAMA_HOST=0.0.0.0 ollama serve",
-			HowToChoose: "Сильные модели (7B+): llama3.1:8b (поддержка tool calling).\n" +
-				"Компактные модели (≤3B): получат составные навыки (LEGO-блоки).\n" +
-				"Универсальный выбор: llama3.1:8b.",
-			HowToPay: "Все модели Ollama бесплатны — работают локально на вашем ПК.\n" +
-				"Оплата не требуется. Единственный ресурс — вычислительная мощность вашего GPU/CPU.",
-			HowToBalance: "Ограничений по токенам нет. Проверка не требуется.\n" +
-				"Для мониторинга ресурсов используйте nvidia-smi (GPU) или htop (CPU).",
-		}
-	case "openrouter":
-		return ProviderGuide{
-			HowToConnect: "1. Зарегистрируйтесь на https://openrouter.ai\n" +
-				"2. Перейдите в https://openrouter.ai/keys\n" +
-				"3. Нажмите 'Create Key' и скопируйте ключ (начинается с sk-or-)\n" +
-				"4. Вставьте ключ в поле API Key выше",
-			HowToChoose: "OpenRouter — агрегатор 200+ моделей от разных провайдеров.\n" +
-				"Бесплатные модели отмечены ярким цветом (цена $0).\n" +
-				"Рекомендации: google/gemini-2.0-flash (бесплатная), meta-llama/llama-3.1-8b-instruct (бесплатная).",
-			HowToPay: "1. Перейдите на https://openrouter.ai/credits\n" +
-				"2. Нажмите 'Add Credits'\n" +
-				"3. Оплатите картой (Visa/Mastercard) от $5\n" +
-				"4. Бесплатные модели (цена $0) не требуют оплаты — работают сразу.",
-			HowToBalance: "Проверить баланс: https://openrouter.ai/credits\n" +
-				"История использования: https://openrouter.ai/activity\n" +
-				"Лимиты по ключу: https://openrouter.ai/keys → Edit Key → Credit Limit.",
-		}
-	case "gigachat":
-		return ProviderGuide{
-			HowToConnect: "1. Зарегистрируйтесь на https://developers.sber.ru\n" +
-				"2. Создайте проект в Личном кабинете\n" +
-				"3. Получите 'Authorization Key' (Client Credentials)\n" +
-				"4. Вставьте ключ в поле API Key\n" +
-				"5. Укажите Scope: GIGACHAT_API_PERS (для физлиц) или GIGACHAT_API_B2B (для юрлиц)",
-			HowToChoose: "GigaChat Lite — быстрая, для простых задач.\n" +
-				"GigaChat Pro — сбалансированная, для большинства задач.\n" +
-				"GigaChat Max — самая мощная, для сложных задач.\n" +
-				"Все модели поддерживают русский язык на высшем уровне.",
-			HowToPay: "Физлица (GIGACHAT_API_PERS):\n" +
-				"- Бесплатный тариф: 1 000 000 токенов GigaChat Lite в месяц.\n" +
-				"- Платный: от 500 руб/мес в личном кабинете.\n\n" +
-				"Юрлица (GIGACHAT_API_B2B):\n" +
-				"- Подписка через менеджера Сбера.\n" +
-				"- Оплата: https://developers.sber.ru/portal/products/gigachat → Тарифы.",
-			HowToBalance: "Проверить остаток токенов: Личный кабинет → https://developers.sber.ru → Мои проекты → Статистика.\n" +
-				"Подписка обновляется ежемесячно. Дата следующего обновления видна в разделе 'Подписка'.\n" +
-				"При исчерпании лимита — ответы с кодом 429 (Too Many Requests).",
-		}
-	case "yandexgpt":
-		return ProviderGuide{
-			HowToConnect: "1. Зарегистрируйтесь в Yandex Cloud: https://cloud.yandex.ru\n" +
-				"2. Создайте каталог (Folder) — запомните Folder ID\n" +
-				"3. Создайте сервисный аккаунт с ролью ai.languageModels.user\n" +
-				"4. Создайте API-ключ: IAM → Сервисные аккаунты → Создать ключ API\n" +
-				"5. Вставьте API Key и Folder ID в поля выше",
-			HowToChoose: "yandexgpt-lite — быстрая и дешёвая, для простых задач.\n" +
-				"yandexgpt — полная модель, для сложных задач.\n" +
-				"yandexgpt-32k — расширенный контекст 32K токенов, для больших документов.\n" +
-				"summarization — специализированная модель для суммаризации текстов.",
-			HowToPay: "При регистрации выдаётся грант на 4 000 руб. (действует 60 дней).\n" +
-				"После гранта: оплата по факту использования.\n" +
-				"Привязать карту: https://console.cloud.yandex.ru/billing → Способ оплаты.\n" +
-				"Цены: yandexgpt-lite — 0.20 руб/1K токенов, yandexgpt — 1.20 руб/1K токенов.",
-			HowToBalance: "Проверить баланс: https://console.cloud.yandex.ru/billing\n" +
-				"Остаток гранта: Billing → Гранты → Текущий грант.\n" +
-				"История расходов: Billing → Детализация → Фильтр по сервису 'YandexGPT'.\n" +
-				"Настроить оповещения: Billing → Бюджеты → Создать бюджет.",
-		}
-	case "openai":
-		return ProviderGuide{
-			HowToConnect: "1. Зарегистрируйтесь на https://platform.openai.com\n" +
-				"2. Перейдите в https://platform.openai.com/api-keys\n" +
-				"3. Нажмите 'Create new secret key' и скопируйте ключ (начинается с sk-)\n" +
-				"4. Вставьте ключ в поле API Key выше",
-			HowToChoose: "gpt-4o — лучшая модель (мультимодальная, быстрая).\n" +
-				"gpt-4o-mini — дешевле (хорошее соотношение цены/качества).\n" +
-				"gpt-3.5-turbo — самая дешёвая.\n" +
-				"o1 / o3 — модели с 'размышлением', для сложных логических задач.",
-			HowToPay: "1. Перейдите на https://platform.openai.com/settings/organization/billing\n" +
-				"2. Нажмите 'Add payment method' → привяжите карту (Visa/Mastercard)\n" +
-				"3. Нажмите 'Add to credit balance' → от $5\n" +
-				"4. Новым пользователям даётся $5 бесплатного кредита (действует 3 месяца).",
-			HowToBalance: "Проверить баланс: https://platform.openai.com/settings/organization/billing\n" +
-				"Текущее использование: https://platform.openai.com/usage\n" +
-				"Настроить лимиты: Settings → Limits → Monthly budget.\n" +
-				"При нулевом балансе — ответы с кодом 429 (Rate limit exceeded).",
-		}
-	case "anthropic":
-		return ProviderGuide{
-			HowToConnect: "1. Зарегистрируйтесь на https://console.anthropic.com\n" +
-				"2. Перейдите в https://console.anthropic.com/settings/keys\n" +
-				"3. Нажмите 'Create Key' и скопируйте ключ (начинается с sk-ant-)\n" +
-				"4. Вставьте ключ в поле API Key выше",
-			HowToChoose: "claude-sonnet-4 — новейшая модель, баланс цены/качества.\n" +
-				"claude-3.5-sonnet — отличная для кода.\n" +
-				"claude-3.5-haiku — быстрая и дешёвая.\n" +
-				"claude-3-opus — самая мощная, для самых сложных задач.",
-			HowToPay: "1. Перейдите на https://console.anthropic.com/settings/billing\n" +
-				"2. Нажмите 'Add payment method' → привяжите карту\n" +
-				"3. Пополните баланс (минимум $5)\n" +
-				"4. Новым пользователям даётся $5 бесплатного кредита.",
-			HowToBalance: "Проверить баланс: https://console.anthropic.com/settings/billing\n" +
-				"Текущее использование: https://console.anthropic.com/settings/usage\n" +
-				"Настроить лимиты: Settings → Plans → Spend limits.\n" +
-				"При нулевом балансе — ответы с кодом 400 (Insufficient credits).",
-		}
-	case "routeway":
-		return ProviderGuide{
-			HowToConnect: "1. Зарегистрируйтесь на https://routeway.ai\n" +
-				"2. Перейдите в Dashboard → API Keys\n" +
-				"3. Создайте ключ и скопируйте его\n" +
-				"4. Вставьте ключ в поле API Key",
-			HowToChoose: "Routeway — агрегатор 70+ моделей, 200 бесплатных запросов/день.\n" +
-				"Бесплатные модели: llama-3.3-70b-instruct:free, deepseek-r1:free, qwen2.5-72b:free.\n" +
-				"Рекомендации: llama-3.3-70b-instruct:free (tool calling), qwen2.5-72b:free (универсальная).",
-			HowToPay: "Бесплатные модели (с суффиксом :free) не требуют оплаты.\n" +
-				"Лимит: 200 запросов в день (в 4 раза больше OpenRouter).",
-			HowToBalance: "Лимит сбрасывается ежедневно.\n" +
-				"Проверьте остаток запросов в Dashboard на https://routeway.ai.",
-		}
-	case "cerebras":
-		return ProviderGuide{
-			HowToConnect: "1. Зарегистрируйтесь на https://cloud.cerebras.ai\n" +
-				"2. Перейдите в API Keys (левое меню)\n" +
-				"3. Нажмите 'Create API Key', дайте имя и скопируйте ключ (csk-...)\n" +
-				"4. Вставьте ключ в поле API Key выше",
-			HowToChoose: "Cerebras — сверхбыстрый инференс (до 2500 токенов/сек, 20x быстрее OpenAI).\n" +
-				"llama3.1-8b — быстрая и лёгкая.\n" +
-				"llama-3.3-70b — мощная.\n" +
-				"qwen-3-32b — сбалансированная (32B параметров).\n" +
-				"qwen-3-235b-a22b-instruct — самая мощная (MoE, 235B параметров).\n" +
-				"gpt-oss-120b — открытая GPT-модель (120B).\n" +
-				"zai-glm-4.7 — Preview модель.",
-			HowToPay: "Free tier (без карты):\n" +
-				"- 1 000 000 токенов в день\n" +
-				"- 30 запросов в минуту\n\n" +
-				"PayGo (с картой):\n" +
-				"- llama3.1-8b: $0.10/1M токенов\n" +
-				"- llama-3.3-70b: $0.60/1M токенов\n" +
-				"- qwen-3-32b: $0.30/1M токенов\n" +
-				"- qwen-3-235b: $0.90/1M токенов\n" +
-				"Оплата: https://cloud.cerebras.ai → Billing.",
-			HowToBalance: "Проверить использование: https://cloud.cerebras.ai → Usage.\n" +
-				"Free tier сбрасывается ежедневно.\n" +
-				"При превышении лимита — ответы с кодом 429 (Rate limit exceeded).",
-		}
-	case "lmstudio":
-		return ProviderGuide{
-			HowToConnect: "1. Скачайте LM Studio: https://lmstudio.ai\n" +
-				"2. Установите и запустите приложение\n" +
-				"3. Скачайте модель (My Models → View All → поиск → Download)\n" +
-				"4. Включите Developer mode: Settings (⚙) → Developer → ON\n" +
-				"5. Загрузите модель в память: выберите модель → Load Model\n" +
-				"6. Сервер запустится автоматически на http://localhost:1234/v1\n" +
-				"7. API Key не требуется (оставьте пустым)\n" +
-				"8. Нажмите кнопку ↻ (обновить) в панели провайдеров для загрузки списка моделей",
-			HowToChoose: "LM Studio — бесплатные локальные модели, без лимитов запросов.\n" +
-				"Рекомендации: ministral-3-14b-reasoning (14B, reasoning + tool calling),\n" +
-				"llama-3.1-8b-instruct (8B, универсальная).\n" +
-				"Требования: минимум 10GB RAM для 14B, 8GB для 8B моделей.",
-			HowToPay: "Полностью бесплатно! Модели работают локально на вашем ПК.\n" +
-				"Никаких лимитов, никаких подписок, данные не покидают компьютер.",
-			HowToBalance: "Ограничений нет. Проверьте ресурсы через nvidia-smi (GPU) или htop (CPU/RAM).\n" +
-				"Если модель медленная — попробуйте меньшую (8B вместо 14B).",
-		}
-	default:
-		return ProviderGuide{
-			HowToConnect: "Проверьте правильность API-ключа и параметров подключения.",
-			HowToChoose:  "Выберите модель, подходящую для вашей задачи.",
-			HowToPay:     "Уточните условия оплаты на сайте провайдера.",
-			HowToBalance: "Проверьте баланс в личном кабинете провайдера.",
-		}
+	autocommitMin := 30
+	if m, ok := args["autocommit_minutes"].(float64); ok && m > 0 {
+		autocommitMin = int(m)
+	}
+	backupSchedule := "0 0 * * *"
+	if s, ok := args["backup_schedule"].(string); ok && s != "" {
+		backupSchedule = s
+	}
+
+	var steps []map[string]interface{}
+	const gitSteps = 7
+
+	// Шаг 1: Создание директорий
+	progress.Step(ctx, 1, gitSteps, "создание каталогов")
+	r1, _ := callToolCtx(ctx, "execute", map[string]interface{}{"command": fmt.Sprintf("mkdir -p %s %s", projectPath, backupPath)})
+	steps = append(steps, map[string]interface{}{"step": "mkdir", "result": r1})
+
+	// Шаг 2: Инициализация git
+	progress.Step(ctx, 2, gitSteps, "git init")
+	r2, _ := callToolCtx(ctx, "execute", map[string]interface{}{"command": fmt.Sprintf("cd %s && git init && git config user.email 'admin@openclaw.local' && git config user.name 'OpenClaw Admin'", projectPath)})
+	steps = append(steps, map[string]interface{}{"step": "git_init", "result": r2})
+
+	// Шаг 3: Создание autocommit.sh
+	progress.Step(ctx, 3, gitSteps, "создание autocommit.sh")
+	autocommitScript := fmt.Sprintf("#!/bin/bash\n# Автоматический коммит всех изменений в проекте\n# Создан составным скилом setup_git_automation\ncd %s\ngit add -A\nDATETIME=$(date '+%%Y-%%m-%%d %%H:%%M:%%S')\ngit diff --cached --quiet || git commit -m \"auto-commit: $DATETIME\"\n", projectPath)
+	autocommitPath := projectPath + "/autocommit.sh"
+	r3, _ := callToolCtx(ctx, "write", map[string]interface{}{"path": autocommitPath, "content": autocommitScript})
+	steps = append(steps, map[string]interface{}{"step": "write_autocommit", "result": r3})
+
+	r3b, _ := callToolCtx(ctx, "execute", map[string]interface{}{"command": "chmod +x " + autocommitPath})
+	steps = append(steps, map[string]interface{}{"step": "chmod_autocommit", "result": r3b})
+
+	// Шаг 4: Создание backup.sh
+	progress.Step(ctx, 4, gitSteps, "создание backup.sh")
+	backupScript := fmt.Sprintf("#!/bin/bash\n# Резервное копирование проекта\n# Создан составным скилом setup_git_automation\nDATETIME=$(date '+%%Y%%m%%d_%%H%%M%%S')\nmkdir -p %s\ntar -czf %s/backup_${DATETIME}.tar.gz -C %s .\necho \"Бэкап создан: %s/backup_${DATETIME}.tar.gz\"\n", backupPath, backupPath, projectPath, backupPath)
+	backupScriptPath := projectPath + "/backup.sh"
+	r4, _ := callToolCtx(ctx, "write", map[string]interface{}{"path": backupScriptPath, "content": backupScript})
+	steps = append(steps, map[string]interface{}{"step": "write_backup", "result": r4})
+
+	r4b, _ := callToolCtx(ctx, "execute", map[string]interface{}{"command": "chmod +x " + backupScriptPath})
+	steps = append(steps, map[string]interface{}{"step": "chmod_backup", "result": r4b})
+
+	// Шаг 5: Добавление в crontab
+	progress.Step(ctx, 5, gitSteps, "добавление заданий в crontab")
+	cronCmd := fmt.Sprintf("(crontab -l 2>/dev/null; echo '*/%d * * * * %s'; echo '%s %s') | sort -u | crontab -", autocommitMin, autocommitPath, backupSchedule, backupScriptPath)
+	r5, _ := callToolCtx(ctx, "execute", map[string]interface{}{"command": cronCmd})
+	steps = append(steps, map[string]interface{}{"step": "crontab", "result": r5})
+
+	// Шаг 6: Первый коммит
+	progress.Step(ctx, 6, gitSteps, "первый коммит")
+	r6, _ := callToolCtx(ctx, "execute", map[string]interface{}{"command": fmt.Sprintf("cd %s && git add -A && git commit -m 'init: проект создан с автоматизацией'", projectPath)})
+	steps = append(steps, map[string]interface{}{"step": "initial_commit", "result": r6})
+
+	// Шаг 7: Проверка crontab
+	progress.Step(ctx, 7, gitSteps, "проверка crontab")
+	r7, _ := callToolCtx(ctx, "execute", map[string]interface{}{"command": "crontab -l"})
+	steps = append(steps, map[string]interface{}{"step": "verify_crontab", "result": r7})
+
+	return map[string]interface{}{
+		"success":     true,
+		"message":     fmt.Sprintf("Git-автоматизация настроена: проект %s, бэкапы в %s, автокоммит каждые %d мин, бэкап по расписанию %s", projectPath, backupPath, autocommitMin, backupSchedule),
+		"steps_count": len(steps),
+		"steps":       steps,
 	}
 }
 
-// cloudModelsHandler — получение списка моделей облачного провайдера (GET /cloud-models).
-// Если передан параметр ?provider=..., возвращает модели конкретного провайдера.
-// Если параметр не передан — возвращает список всех зарегистрированных провайдеров.
-//
-// Используется фронтендом для заполнения выпадающего списка облачных моделей.
-func cloudModelsHandler(w http.ResponseWriter, r *http.Request) {
-	cid := r.Header.Get("X-Request-ID")
-	if r.Method != http.MethodGet {
-		apierror.MethodNotAllowed(w, cid)
-		return
+// handleFullSystemReport — составной скил: полный отчёт о системе.
+// Собирает данные из sysinfo + sysload + cputemp + df + free + uname за один вызов.
+func handleFullSystemReport(ctx context.Context) map[string]interface{} {
+	report := make(map[string]interface{})
+
+	if r, err := callToolCtx(ctx, "sysinfo", map[string]interface{}{}); err == nil {
+		report["sysinfo"] = r
 	}
-	providerName := r.URL.Query().Get("provider")
-	slog.Info("Запрос облачных моделей", slog.String("провайдер", providerName))
-	if providerName == "" {
-		allProviders := llm.GlobalRegistry.ListAll()
-		w.Header().Set("Content-Type", "application/json")
-		writeJSON(w, allProviders)
-		return
+	if r, err := callToolCtx(ctx, "sysload", map[string]interface{}{}); err == nil {
+		report["sysload"] = r
 	}
-
-	p, err := llm.GlobalRegistry.Get(providerName)
-	if err != nil {
-		slog.Error("Облачный провайдер не найден", slog.String("ошибка", err.Error()))
-		apierror.NotFound(w, cid, "Провайдер не найден")
-		return
+	if r, err := callToolCtx(ctx, "cputemp", map[string]interface{}{}); err == nil {
+		report["cputemp"] = r
 	}
-	slog.Info("Облачный провайдер найден")
-	models, err := p.ListModels()
-	if err != nil {
-		slog.Error("Ошибка получения списка моделей", slog.String("ошибка", err.Error()))
-		apierror.InternalError(w, cid, "Не удалось получить модели", err.Error())
-		return
+	if r, err := callToolCtx(ctx, "execute", map[string]interface{}{"command": "df -h"}); err == nil {
+		report["disk"] = r
+	}
+	if r, err := callToolCtx(ctx, "execute", map[string]interface{}{"command": "free -m"}); err == nil {
+		report["memory"] = r
+	}
+	if r, err := callToolCtx(ctx, "execute", map[string]interface{}{"command": "uname -a"}); err == nil {
+		report["kernel"] = r
 	}
-	slog.Info("Облачные модели получены", slog.Int("количество", len(models)))
-	w.Header().Set("Content-Type", "application/json")
-	writeJSON(w, models)
-}
 
-// workspacesHandler — управление рабочими пространствами (GET/POST/DELETE /workspaces).
-//
-// Рабочие пространства (Workspaces) — это изолированные проекты с:
-//   - Именем и путём к рабочей директории на ПК
-//   - Отдельной историей чатов
-//   - Отдельной конфигурацией агентов
-//
-// GET    — возвращает список всех пространств
-// POST   — создаёт новое пространство (JSON: {name, path})
-// DELETE — удаляет пространство по ID (?id=...)
-func workspacesHandler(w http.ResponseWriter, r *http.Request) {
-	cid := r.Header.Get("X-Request-ID")
-	switch r.Method {
-	case http.MethodGet:
-		var workspaces []models.Workspace
-		db.DB.Find(&workspaces)
-		w.Header().Set("Content-Type", "application/json")
-		writeJSON(w, workspaces)
+	report["success"] = true
+	report["message"] = "Полный системный отчёт собран"
+	return report
+}
 
-	case http.MethodPost:
-		var req struct {
-			Name string `json:"name"`
-			Path string `json:"path"`
-		}
-		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-			apierror.BadRequest(w, cid, "Невалидный JSON", "")
-			return
-		}
-		if req.Name == "" {
-			apierror.BadRequest(w, cid, "Требуется name", "")
-			return
-		}
-		ws := models.Workspace{Name: req.Name, Path: req.Path}
-		if err := db.DB.Create(&ws).Error; err != nil {
-			apierror.InternalError(w, cid, "Не удалось создать workspace", "")
-			return
-		}
-		w.Header().Set("Content-Type", "application/json")
-		writeJSON(w, ws)
+// handleRunCommands — составной скил: последовательное выполнение нескольких bash-команд.
+// Принимает массив команд, выполняет каждую через callToolCtx(ctx, "execute") и собирает результаты.
+func handleRunCommands(ctx context.Context, args map[string]interface{}) map[string]interface{} {
+	commandsRaw, ok := args["commands"]
+	if !ok {
+		return map[string]interface{}{"error": "commands обязателен"}
+	}
 
-	case http.MethodDelete:
-		id := r.URL.Query().Get("id")
-		if id == "" {
-			apierror.BadRequest(w, cid, "Требуется id", "")
-			return
-		}
-		if err := db.DB.Delete(&models.Workspace{}, id).Error; err != nil {
-			apierror.InternalError(w, cid, "Не удалось удалить workspace", "")
-			return
+	var commands []string
+	switch v := commandsRaw.(type) {
+	case []interface{}:
+		for _, c := range v {
+			if s, ok := c.(string); ok {
+				commands = append(commands, s)
+			}
 		}
-		w.Header().Set("Content-Type", "application/json")
-		writeJSON(w, map[string]string{"status": "ok"})
-
+	case []string:
+		commands = v
 	default:
-		apierror.MethodNotAllowed(w, cid)
+		return map[string]interface{}{"error": "commands должен быть массивом строк"}
 	}
-}
 
-// WriteSystemLog — записывает событие в централизованную систему логов.
-// Используется всеми компонентами для логирования ошибок и важных событий.
-// Параметры:
-//   - level: уровень лога (error, warn, info, debug)
-//   - service: имя микросервиса-источника
-//   - message: текст сообщения
-//   - details: дополнительные данные (стек, параметры запроса)
-func WriteSystemLog(level, service, message, details string) {
-	entry := models.SystemLog{
-		Level:   level,
-		Service: service,
-		Message: message,
-		Details: details,
-	}
-	if err := db.DB.Create(&entry).Error; err != nil {
-		slog.Error("Ошибка записи в системный лог", slog.String("ошибка", err.Error()))
+	if len(commands) == 0 {
+		return map[string]interface{}{"error": "commands пуст"}
 	}
-}
-
-// logsHandler — HTTP-обработчик для работы с системными логами.
-// GET: возвращает логи с фильтрацией по уровню (?level=error), сервису (?service=agent-service),
-//
-//	и лимиту (?limit=100). По умолчанию возвращает последние 100 записей.
-//
-// POST: принимает новый лог от внешних сервисов (tools-service, memory-service, api-gateway).
-// PATCH: отмечает лог как исправленный (?id=123&resolved=true).
-func logsHandler(w http.ResponseWriter, r *http.Request) {
-	cid := r.Header.Get("X-Request-ID")
-	switch r.Method {
-	case http.MethodGet:
-		query := db.DB.Model(&models.SystemLog{}).Order("created_at DESC")
 
-		if level := r.URL.Query().Get("level"); level != "" {
-			query = query.Where("level = ?", level)
-		}
-		if service := r.URL.Query().Get("service"); service != "" {
-			query = query.Where("service = ?", service)
+	var results []map[string]interface{}
+	allOk := true
+	for i, cmd := range commands {
+		progress.Step(ctx, i+1, len(commands), truncate(cmd, 80))
+		r, err := callToolCtx(ctx, "execute", map[string]interface{}{"command": cmd})
+		entry := map[string]interface{}{
+			"index":   i,
+			"command": cmd,
 		}
-		if resolved := r.URL.Query().Get("resolved"); resolved != "" {
-			query = query.Where("resolved = ?", resolved == "true")
+		if err != nil {
+			entry["error"] = err.Error()
+			allOk = false
+		} else {
+			entry["result"] = r
 		}
+		results = append(results, entry)
+	}
 
-		limit := 100
-		if l := r.URL.Query().Get("limit"); l != "" {
-			if parsed, err := strconv.Atoi(l); err == nil && parsed > 0 {
-				limit = parsed
-			}
-		}
-		query = query.Limit(limit)
+	return map[string]interface{}{
+		"success":  allOk,
+		"message":  fmt.Sprintf("Выполнено %d команд", len(results)),
+		"commands": results,
+	}
+}
 
-		var logs []models.SystemLog
-		query.Find(&logs)
+// handleCreateScript — составной скил: создание исполняемого bash-скрипта.
+// Записывает содержимое в файл и делает chmod +x за один вызов.
+func handleCreateScript(ctx context.Context, args map[string]interface{}) map[string]interface{} {
+	path, _ := args["path"].(string)
+	content, _ := args["content"].(string)
+	if path == "" || content == "" {
+		return map[string]interface{}{"error": "path и content обязательны"}
+	}
 
-		w.Header().Set("Content-Type", "application/json")
-		writeJSON(w, logs)
+	// Создаём директорию если нужно
+	dir := path[:strings.LastIndex(path, "/")]
+	if dir != "" {
+		callToolCtx(ctx, "execute", map[string]interface{}{"command": "mkdir -p " + dir})
+	}
 
-	case http.MethodPost:
-		var req struct {
-			Level   string `json:"level"`
-			Service string `json:"service"`
-			Message string `json:"message"`
-			Details string `json:"details"`
-		}
-		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-			apierror.BadRequest(w, cid, "Невалидный JSON", "")
-			return
-		}
-		if req.Level == "" || req.Service == "" || req.Message == "" {
-			apierror.BadRequest(w, cid, "level, service, message обязательны", "")
-			return
-		}
-		WriteSystemLog(req.Level, req.Service, req.Message, req.Details)
-		w.Header().Set("Content-Type", "application/json")
-		writeJSON(w, map[string]string{"status": "ok"})
+	// Записываем файл
+	writeResult, err := callToolCtx(ctx, "write", map[string]interface{}{"path": path, "content": content})
+	if err != nil {
+		return map[string]interface{}{"error": "Ошибка записи: " + err.Error()}
+	}
 
-	case http.MethodPatch:
-		id := r.URL.Query().Get("id")
-		if id == "" {
-			apierror.BadRequest(w, cid, "id обязателен", "")
-			return
-		}
-		resolved := r.URL.Query().Get("resolved") == "true"
-		if err := db.DB.Model(&models.SystemLog{}).Where("id = ?", id).Update("resolved", resolved).Error; err != nil {
-			apierror.InternalError(w, cid, "Ошибка обновления", "")
-			return
-		}
-		w.Header().Set("Content-Type", "application/json")
-		writeJSON(w, map[string]string{"status": "ok"})
+	// Делаем исполняемым
+	chmodResult, err := callToolCtx(ctx, "execute", map[string]interface{}{"command": "chmod +x " + path})
+	if err != nil {
+		return map[string]interface{}{"error": "Ошибка chmod: " + err.Error()}
+	}
 
-	default:
-		apierror.MethodNotAllowed(w, cid)
+	return map[string]interface{}{
+		"success": true,
+		"message": fmt.Sprintf("Скрипт создан: %s (chmod +x)", path),
+		"write":   writeResult,
+		"chmod":   chmodResult,
 	}
 }
 
-// ragAddHandler — обработчик для добавления документа в RAG базу знаний
-func ragAddHandler(w http.ResponseWriter, r *http.Request) {
-	cid := r.Header.Get("X-Request-ID")
-	if r.Method != http.MethodPost {
-		apierror.MethodNotAllowed(w, cid)
-		return
+// handleGitPullRequest — составной скил: ветка, коммит с сообщением от LLM
+// (Conventional Commits), push и PR/MR через API GitHub/GitLab.
+// Возвращает ссылку на PR и выполненные шаги.
+func handleGitPullRequest(ctx context.Context, agentName string, args map[string]interface{}) map[string]interface{} {
+	req := gitflow.Request{}
+	req.RepoPath, _ = args["repo_path"].(string)
+	req.Branch, _ = args["branch"].(string)
+	req.Base, _ = args["base"].(string)
+	req.Message, _ = args["message"].(string)
+	req.Title, _ = args["title"].(string)
+	req.Body, _ = args["body"].(string)
+	req.Draft, _ = args["draft"].(bool)
+	if files, ok := args["files"].([]interface{}); ok {
+		for _, f := range files {
+			if s, ok := f.(string); ok && s != "" {
+				req.Files = append(req.Files, s)
+			}
+		}
+	}
+	if req.RepoPath == "" {
+		return map[string]interface{}{"error": "repo_path обязателен"}
 	}
 
-	var req struct {
-		Title   string `json:"title"`
-		Content string `json:"content"`
-		Source  string `json:"source"`
+	wf := gitflow.Workflow{
+		Exec: func(ctx context.Context, command string, env map[string]string) (string, error) {
+			args := map[string]interface{}{"command": command}
+			if env != nil {
+				args["env"] = env
+			}
+			res, err := callToolCtx(ctx, "execute", args)
+			if err != nil {
+				return "", err
+			}
+			stdout, _ := res["stdout"].(string)
+			stderr, _ := res["stderr"].(string)
+			if msg, _ := res["error"].(string); msg != "" {
+				return stdout, errors.New(msg)
+			}
+			if code, _ := res["returncode"].(float64); code != 0 {
+				return stdout + stderr, fmt.Errorf("код %d: %s", int(code), strings.TrimSpace(stderr))
+			}
+			return stdout, nil
+		},
+		Write: func(ctx context.Context, path, content string) error {
+			res, err := callToolCtx(ctx, "write", map[string]interface{}{"path": path, "content": content})
+			if err == nil {
+				if msg, ok := res["error"].(string); ok && msg != "" {
+					err = errors.New(msg)
+				}
+			}
+			return err
+		},
+		Message:  commitMessageGenerator(agentName),
+		Accounts: gitHostingAccount,
+	}
+	task := activeTask(agentName)
+	if task != nil {
+		req.Refs = task.IssueURL
+	}
+	result, err := wf.Run(ctx, req)
+	if task != nil && result.Commit != "" {
+		recordTaskCommit(task, result)
+	}
+	out := map[string]interface{}{
+		"branch":         result.Branch,
+		"base":           result.Base,
+		"commit":         result.Commit,
+		"commit_message": result.Message,
+		"files":          result.Files,
+		"pushed":         result.Pushed,
+		"steps":          result.Steps,
 	}
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		apierror.BadRequest(w, cid, "Невалидный JSON", "")
-		return
+	if err != nil {
+		slog.Warn("[GIT-PR] сценарий прерван", slog.String("репозиторий", req.RepoPath), slog.String("ошибка", err.Error()))
+		out["error"] = err.Error()
+		return out
+	}
+	slog.Info("[GIT-PR] запрос на слияние открыт", slog.String("репозиторий", req.RepoPath), slog.String("ветка", result.Branch), slog.String("url", result.PRURL))
+	out["success"] = true
+	out["pr_url"] = result.PRURL
+	out["message"] = fmt.Sprintf("Изменения закоммичены в %s (%s), PR: %s", result.Branch, result.Commit, result.PRURL)
+	return out
+}
+
+// commitMessageGenerator — сообщение коммита моделью агента по staged diff.
+func commitMessageGenerator(agentName string) gitflow.MessageFunc {
+	return func(ctx context.Context, diff string) (string, error) {
+		agent, err := repository.GetAgentByName(agentName)
+		if err != nil {
+			return "", err
+		}
+		providerName := agent.Provider
+		if providerName == "" {
+			providerName = "ollama"
+		}
+		provider, err := llm.GlobalRegistry.Get(providerName)
+		if err != nil {
+			return "", err
+		}
+		resp, err := chatWithRetry(ctx, provider, &llm.ChatRequest{
+			Model: agent.LLMModel,
+			Messages: []llm.Message{
+				{Role: "system", Content: "Напиши сообщение git-коммита в формате Conventional Commits для изменений ниже. " +
+					"Первая строка: type(scope): краткое описание на английском в повелительном наклонении, до 72 символов; " +
+					"type — feat, fix, docs, refactor, test, chore, perf, build или ci. " +
+					"Затем, если нужно, пустая строка и 1–3 строки пояснения. Ответь только текстом сообщения."},
+				{Role: "user", Content: diff},
+			},
+		})
+		if err != nil {
+			return "", err
+		}
+		return resp.Content, nil
 	}
+}
 
-	if req.Title == "" || req.Content == "" {
-		apierror.BadRequest(w, cid, "Требуются title и content", "")
-		return
+// gitHostingAccount — токен GitHub/GitLab из настроек провайдеров для хоста remote.
+func gitHostingAccount(host string) (gitflow.Account, bool) {
+	var configs []models.ProviderConfig
+	db.DB.Where("provider_name IN ? AND enabled = ?", []string{gitflow.ProviderGitHub, gitflow.ProviderGitLab}, true).Find(&configs)
+	var fallback *gitflow.Account
+	for _, cfg := range configs {
+		acc := gitflow.Account{Provider: cfg.ProviderName, Token: cfg.APIKey, BaseURL: cfg.BaseURL}
+		if acc.Token == "" {
+			continue
+		}
+		if acc.Host() == host {
+			return acc, true
+		}
+		// Self-hosted GitLab без base_url: узнаём по имени хоста
+		if acc.Provider == gitflow.ProviderGitLab && cfg.BaseURL == "" && strings.Contains(host, "gitlab") {
+			acc.BaseURL = "https://" + host
+			fallback = &acc
+		}
 	}
+	if fallback != nil {
+		return *fallback, true
+	}
+	return gitflow.Account{}, false
+}
 
-	docID := fmt.Sprintf("doc-%d", time.Now().UnixNano())
-
-	if ragRetriever != nil && ragRetriever.Config().ChromaURL != "" {
-		ragDoc := rag.RagDoc{
-			ID:      docID,
-			Title:   req.Title,
-			Content: req.Content,
-			Source:  req.Source,
-		}
-		if err := ragRetriever.AddDocument(ragDoc); err != nil {
-			slog.Error("Ошибка добавления в ChromA", slog.String("ошибка", err.Error()))
+// smartHomeHub — бэкенды умного дома из настроек провайдеров homeassistant и mqtt.
+func smartHomeHub() smarthome.Hub {
+	var configs []models.ProviderConfig
+	db.DB.Where("provider_name IN ? AND enabled = ?", []string{smarthome.ProviderHomeAssistant, smarthome.ProviderMQTT}, true).Find(&configs)
+	var hub smarthome.Hub
+	for _, cfg := range configs {
+		switch cfg.ProviderName {
+		case smarthome.ProviderHomeAssistant:
+			if cfg.BaseURL != "" && cfg.APIKey != "" {
+				hub.HA = smarthome.NewHomeAssistant(cfg.BaseURL, cfg.APIKey)
+			}
+		case smarthome.ProviderMQTT:
+			if m, err := smarthome.ParseMQTT(cfg.BaseURL, cfg.APIKey); err == nil {
+				hub.MQTT = m
+			}
 		}
 	}
+	return hub
+}
 
-	ragDoc := models.RagDocument{
-		Title:       req.Title,
-		Content:     req.Content,
-		Source:      req.Source,
-		ChunkIndex:  0,
-		TotalChunks: 1,
+// handleSmartHome — инструменты home_sensor, home_switch, home_scene.
+func handleSmartHome(ctx context.Context, toolName string, args map[string]interface{}) map[string]interface{} {
+	var req smarthome.Request
+	req.EntityID, _ = args["entity_id"].(string)
+	req.Query, _ = args["query"].(string)
+	req.Action, _ = args["action"].(string)
+	req.Topic, _ = args["topic"].(string)
+	req.Payload, _ = args["payload"].(string)
+
+	hub := smartHomeHub()
+	var res map[string]interface{}
+	var err error
+	switch toolName {
+	case "home_sensor":
+		res, err = hub.Sensor(ctx, req)
+	case "home_switch":
+		res, err = hub.Switch(ctx, req)
+	default:
+		res, err = hub.Scene(ctx, req)
 	}
-	if err := db.DB.Create(&ragDoc).Error; err != nil {
-		slog.Error("Ошибка сохранения RAG документа в БД", slog.String("ошибка", err.Error()))
-		apierror.InternalError(w, cid, "Не удалось сохранить документ", "")
-		return
+	if err != nil {
+		return map[string]interface{}{"error": err.Error()}
 	}
-
-	slog.Info("RAG документ добавлен", slog.String("заголовок", req.Title), slog.Uint64("id", uint64(ragDoc.ID)))
-	writeJSON(w, map[string]interface{}{"status": "ok", "id": docID, "db_id": ragDoc.ID})
+	if toolName != "home_sensor" {
+		slog.Info("Команда умному дому",
+			slog.String("инструмент", toolName),
+			slog.String("сущность", req.EntityID),
+			slog.String("топик", req.Topic),
+			slog.String("действие", req.Action),
+		)
+	}
+	return res
 }
 
-// ragSearchHandler — обработчик для поиска по RAG базе знаний
-func ragSearchHandler(w http.ResponseWriter, r *http.Request) {
-	cid := r.Header.Get("X-Request-ID")
-	if r.Method != http.MethodPost && r.Method != http.MethodGet {
-		apierror.MethodNotAllowed(w, cid)
-		return
+// kubeClient — доступ к кластеру Kubernetes; nil, если kubeconfig не найден.
+var kubeClient *kube.Client
+
+// handleKube — инструменты k8s_*: инспекция кластера только на чтение.
+func handleKube(ctx context.Context, toolName string, args map[string]interface{}) map[string]interface{} {
+	if kubeClient == nil {
+		return map[string]interface{}{"error": kube.ErrNoConfig.Error()}
 	}
+	ns, _ := args["namespace"].(string)
+	selector, _ := args["selector"].(string)
+	name, _ := args["name"].(string)
+	if ns == "" {
+		ns = kubeClient.Namespace()
+	}
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
 
-	query := r.URL.Query().Get("q")
-	if query == "" && r.Method == http.MethodPost {
-		var req struct {
-			Query string `json:"query"`
+	res := map[string]interface{}{"context": kubeClient.Context(), "namespace": ns}
+	var err error
+	switch toolName {
+	case "k8s_pods":
+		var pods []kube.Pod
+		if pods, err = kubeClient.ListPods(ctx, ns, selector); err == nil {
+			if len(pods) > 200 {
+				res["truncated"] = true
+				pods = pods[:200]
+			}
+			res["pods"], res["count"] = pods, len(pods)
+		}
+	case "k8s_deployments":
+		var deps []kube.Deployment
+		if deps, err = kubeClient.ListDeployments(ctx, ns, selector); err == nil {
+			res["deployments"], res["count"] = deps, len(deps)
+		}
+	case "k8s_events":
+		f := kube.EventFilter{Name: name}
+		f.Kind, _ = args["kind"].(string)
+		f.WarningsOnly, _ = args["warnings_only"].(bool)
+		if limit, ok := args["limit"].(float64); ok {
+			f.Limit = int(limit)
+		}
+		var events []kube.Event
+		if events, err = kubeClient.ListEvents(ctx, ns, f); err == nil {
+			res["events"], res["count"] = events, len(events)
+		}
+	case "k8s_describe":
+		kind, _ := args["kind"].(string)
+		res, err = kubeClient.Describe(ctx, kind, name, ns)
+	case "k8s_logs":
+		var o kube.LogOptions
+		o.Container, _ = args["container"].(string)
+		o.Previous, _ = args["previous"].(bool)
+		if n, ok := args["tail_lines"].(float64); ok {
+			o.TailLines = int(n)
+		}
+		if since, _ := args["since"].(string); since != "" {
+			if o.Since, err = time.ParseDuration(since); err != nil {
+				return map[string]interface{}{"error": "since: ожидается длительность вида 10m или 2h"}
+			}
 		}
-		if err := json.NewDecoder(r.Body).Decode(&req); err == nil {
-			query = req.Query
+		var logs string
+		if logs, err = kubeClient.Logs(ctx, name, ns, o); err == nil {
+			res["pod"], res["logs"] = name, logs
 		}
 	}
-
-	if query == "" {
-		apierror.BadRequest(w, cid, "Требуется query", "")
-		return
+	if err != nil {
+		return map[string]interface{}{"error": err.Error()}
 	}
+	return res
+}
 
-	if ragRetriever == nil {
-		apierror.InternalError(w, cid, "RAG не инициализирован", "")
-		return
+// handleSetupCronJob — составной скил: добавление задачи в crontab.
+// Безопасно добавляет запись, не затирая существующие.
+func handleSetupCronJob(ctx context.Context, args map[string]interface{}) map[string]interface{} {
+	schedule, _ := args["schedule"].(string)
+	command, _ := args["command"].(string)
+	if schedule == "" || command == "" {
+		return map[string]interface{}{"error": "schedule и command обязательны"}
 	}
 
-	results, err := ragRetriever.Search(query, 5)
+	cronEntry := schedule + " " + command
+	addCmd := fmt.Sprintf("(crontab -l 2>/dev/null; echo '%s') | sort -u | crontab -", cronEntry)
+
+	result, err := callToolCtx(ctx, "execute", map[string]interface{}{"command": addCmd})
 	if err != nil {
-		apierror.InternalError(w, cid, err.Error(), "")
-		return
+		return map[string]interface{}{"error": "Ошибка добавления в crontab: " + err.Error()}
 	}
 
-	writeJSON(w, results)
-}
+	// Проверяем что добавилось
+	verify, _ := callToolCtx(ctx, "execute", map[string]interface{}{"command": "crontab -l"})
 
-// ragFilesHandler — обработчик для получения списка файлов в RAG (сгруппировано по папкам)
-func ragFilesHandler(w http.ResponseWriter, r *http.Request) {
-	if r.Method == http.MethodGet {
-		var docs []models.RagDocument
-		db.DB.Find(&docs)
+	return map[string]interface{}{
+		"success": true,
+		"message": fmt.Sprintf("Задача добавлена в crontab: %s", cronEntry),
+		"result":  result,
+		"crontab": verify,
+	}
+}
 
-		type fileInfo struct {
-			FileName    string `json:"file_name"`
-			ChunksCount int    `json:"chunks_count"`
-		}
-		type folderData struct {
-			Folder     string     `json:"folder"`
-			Files      []fileInfo `json:"files"`
-			TotalFiles int        `json:"total_files"`
-		}
+// handleProjectInit — составной скил: инициализация нового проекта.
+// Создаёт директорию, README.md, .gitignore и инициализирует git.
+func handleProjectInit(ctx context.Context, args map[string]interface{}) map[string]interface{} {
+	path, _ := args["path"].(string)
+	name, _ := args["name"].(string)
+	if path == "" || name == "" {
+		return map[string]interface{}{"error": "path и name обязательны"}
+	}
+	desc, _ := args["description"].(string)
+	if desc == "" {
+		desc = "Проект " + name
+	}
 
-		// Группируем по папкам
-		folderMap := make(map[string]*folderData)
-		for _, doc := range docs {
-			// Извлекаем папку из имени файла
-			parts := strings.Split(doc.Title, "/")
-			var folder, fileName string
-			if len(parts) > 1 {
-				folder = parts[0]
-				fileName = strings.Join(parts[1:], "/")
-			} else {
-				folder = "(корневая папка)"
-				fileName = doc.Title
-			}
+	var steps []map[string]interface{}
 
-			if _, ok := folderMap[folder]; !ok {
-				folderMap[folder] = &folderData{
-					Folder:     folder,
-					Files:      []fileInfo{},
-					TotalFiles: 0,
-				}
-			}
+	// Создание директории
+	progress.Step(ctx, 1, 4, "создание каталога")
+	r1, _ := callToolCtx(ctx, "execute", map[string]interface{}{"command": "mkdir -p " + path})
+	steps = append(steps, map[string]interface{}{"step": "mkdir", "result": r1})
 
-			folderMap[folder].Files = append(folderMap[folder].Files, fileInfo{
-				FileName:    fileName,
-				ChunksCount: doc.TotalChunks,
-			})
-			folderMap[folder].TotalFiles++
-		}
+	// Создание README.md
+	progress.Step(ctx, 2, 4, "README.md")
+	readme := fmt.Sprintf("# %s\n\n%s\n\nСоздан: %s\n", name, desc, "$(date)")
+	r2, _ := callToolCtx(ctx, "write", map[string]interface{}{"path": path + "/README.md", "content": readme})
+	steps = append(steps, map[string]interface{}{"step": "readme", "result": r2})
 
-		// Сортируем папки
-		folders := make([]*folderData, 0, len(folderMap))
-		for _, v := range folderMap {
-			folders = append(folders, v)
-		}
+	// Создание .gitignore
+	progress.Step(ctx, 3, 4, ".gitignore")
+	gitignore := "*.log\n*.tmp\n*.swp\n.env\nnode_modules/\n__pycache__/\n.DS_Store\n"
+	r3, _ := callToolCtx(ctx, "write", map[string]interface{}{"path": path + "/.gitignore", "content": gitignore})
+	steps = append(steps, map[string]interface{}{"step": "gitignore", "result": r3})
 
-		// Сортируем: корневая в конце
-		sort.Slice(folders, func(i, j int) bool {
-			f1 := folders[i].Folder
-			f2 := folders[j].Folder
-			if f1 == "(корневая папка)" {
-				return false
-			}
-			if f2 == "(корневая папка)" {
-				return true
-			}
-			return f1 < f2
-		})
+	// Инициализация git
+	progress.Step(ctx, 4, 4, "git init и первый коммит")
+	r4, _ := callToolCtx(ctx, "execute", map[string]interface{}{"command": fmt.Sprintf("cd %s && git init && git config user.email 'admin@openclaw.local' && git config user.name 'OpenClaw Admin' && git add -A && git commit -m 'init: %s'", path, name)})
+	steps = append(steps, map[string]interface{}{"step": "git_init", "result": r4})
 
-		writeJSON(w, folders)
-		return
+	return map[string]interface{}{
+		"success": true,
+		"message": fmt.Sprintf("Проект '%s' инициализирован в %s (git, README, .gitignore)", name, path),
+		"steps":   steps,
 	}
-	cid := r.Header.Get("X-Request-ID")
-	apierror.MethodNotAllowed(w, cid)
 }
 
-// ragStatsHandler — обработчик для получения статистики RAG
-func ragStatsHandler(w http.ResponseWriter, r *http.Request) {
-	cid := r.Header.Get("X-Request-ID")
-	if r.Method != http.MethodGet {
-		apierror.MethodNotAllowed(w, cid)
-		return
-	}
-
-	var docCount int64
-	var uniqueFiles int64
-	db.DB.Model(&models.RagDocument{}).Count(&docCount)
-	db.DB.Model(&models.RagDocument{}).Distinct("title").Count(&uniqueFiles)
-
-	writeJSON(w, map[string]interface{}{
-		"facts_count": docCount,
-		"files_count": uniqueFiles,
-	})
-}
+// ============================================================================
+// НОВЫЕ УНИВЕРСАЛЬНЫЕ LEGO-БЛОКИ (обработчики)
+// Каждый обработчик выполняет цепочку базовых инструментов за один вызов.
+// Умная модель (7B+) предпочтёт базовые инструменты и сама построит цепочку.
+// Слабая модель (3B) вызовет один составной скил и получит готовый результат.
+// ============================================================================
 
-// ragDeleteHandler — обработчик для удаления документа из RAG
-func ragDeleteHandler(w http.ResponseWriter, r *http.Request) {
-	cid := r.Header.Get("X-Request-ID")
-	if r.Method != http.MethodDelete && r.Method != http.MethodPost {
-		apierror.MethodNotAllowed(w, cid)
-		return
+// handleCheckStack — LEGO-блок: проверка установленных версий программ.
+// Для каждой программы из списка выполняет команду определения версии
+// и собирает результаты в единый отчёт. Поддерживает: go, node, npm,
+// python3, psql, docker, git, nginx, redis-server, curl, wget и любые другие.
+func handleCheckStack(ctx context.Context, args map[string]interface{}) map[string]interface{} {
+	programsRaw, ok := args["programs"]
+	if !ok {
+		return map[string]interface{}{"error": "programs обязателен"}
 	}
 
-	fileName := r.URL.Query().Get("name")
-	if fileName == "" {
-		var req struct {
-			Name string `json:"name"`
-		}
-		if err := json.NewDecoder(r.Body).Decode(&req); err == nil {
-			fileName = req.Name
+	var programs []string
+	switch v := programsRaw.(type) {
+	case []interface{}:
+		for _, p := range v {
+			if s, ok := p.(string); ok {
+				programs = append(programs, s)
+			}
 		}
+	case []string:
+		programs = v
+	default:
+		return map[string]interface{}{"error": "programs должен быть массивом строк"}
 	}
 
-	if fileName == "" {
-		apierror.BadRequest(w, cid, "Требуется name", "")
-		return
-	}
-
-	if err := db.DB.Where("title = ?", fileName).Delete(&models.RagDocument{}).Error; err != nil {
-		slog.Error("Ошибка удаления RAG документа", slog.String("ошибка", err.Error()))
-		apierror.InternalError(w, cid, "Не удалось удалить", "")
-		return
+	if len(programs) == 0 {
+		return map[string]interface{}{"error": "programs пуст"}
 	}
 
-	slog.Info("RAG документ удалён", slog.String("файл", fileName))
-	writeJSON(w, map[string]string{"status": "ok"})
-}
-
-var supportedExtensions = map[string]bool{
-	".txt": true, ".md": true, ".markdown": true,
-	".json": true, ".jsonl": true,
-	".csv":  true,
-	".html": true, ".htm": true,
-	".xml":  true,
-	".yaml": true, ".yml": true,
-	".go": true, ".py": true, ".js": true, ".ts": true,
-	".java": true, ".c": true, ".cpp": true, ".h": true, ".hpp": true,
-	".rs": true, ".rb": true, ".php": true, ".swift": true, ".kt": true,
-	".sh": true, ".bash": true, ".zsh": true,
-	".sql": true, ".graphql": true, ".gql": true,
-	".dockerfile": true, ".toml": true, ".ini": true, ".conf": true,
-	".log": true,
-}
+	// Маппинг программа → команда для проверки версии.
+	// Для известных программ используем специфичную команду,
+	// для неизвестных — пробуем --version.
+	versionCommands := map[string]string{
+		"go":           "go version",
+		"node":         "node --version",
+		"npm":          "npm --version",
+		"python3":      "python3 --version",
+		"python":       "python3 --version",
+		"psql":         "psql --version",
+		"docker":       "docker --version",
+		"git":          "git --version",
+		"nginx":        "nginx -v 2>&1",
+		"redis-server": "redis-server --version",
+		"curl":         "curl --version | head -1",
+		"wget":         "wget --version | head -1",
+		"java":         "java -version 2>&1 | head -1",
+		"rustc":        "rustc --version",
+		"cargo":        "cargo --version",
+		"php":          "php --version | head -1",
+		"ruby":         "ruby --version",
+		"pip":          "pip3 --version",
+		"pip3":         "pip3 --version",
+		"gcc":          "gcc --version | head -1",
+		"make":         "make --version | head -1",
+		"systemctl":    "systemctl --version | head -1",
+	}
 
-// fetchRAGFromMemory — получение RAG-контекста из memory-service вместо ChromaDB.
-// Выполняет семантический поиск по базе знаний memory-service для обогащения
-// контекста модели при обработке чат-запроса.
-func fetchRAGFromMemory(query string, topK int) (string, []Source) {
-	memoryURL := getEnv("MEMORY_SERVICE_URL", "http://localhost:8001")
+	var results []map[string]interface{}
+	installed := 0
+	missing := 0
 
-	reqBody := map[string]interface{}{
-		"query":         query,
-		"top_k":         topK,
-		"include_files": true,
-	}
-	data, err := json.Marshal(reqBody)
-	if err != nil {
-		slog.Error("Ошибка сериализации RAG запроса к memory-service", slog.String("ошибка", err.Error()))
-		return "", nil
-	}
+	for _, prog := range programs {
+		cmd, known := versionCommands[prog]
+		if !known {
+			cmd = prog + " --version 2>&1 | head -1"
+		}
 
-	resp, err := http.Post(memoryURL+"/search", "application/json", bytes.NewReader(data))
-	if err != nil {
-		slog.Warn("memory-service недоступен для RAG поиска, fallback на ChromaDB", slog.String("ошибка", err.Error()))
-		return "", nil
-	}
-	defer resp.Body.Close()
+		// Проверяем наличие программы через which + версию
+		checkCmd := fmt.Sprintf("which %s >/dev/null 2>&1 && %s || echo 'НЕ УСТАНОВЛЕНО'", prog, cmd)
+		r, err := callToolCtx(ctx, "execute", map[string]interface{}{"command": checkCmd})
 
-	if resp.StatusCode != http.StatusOK {
-		slog.Warn("memory-service вернул ошибку при RAG поиске", slog.Int("статус", resp.StatusCode))
-		return "", nil
+		entry := map[string]interface{}{
+			"program": prog,
+		}
+		if err != nil {
+			entry["status"] = "ошибка"
+			entry["error"] = err.Error()
+			missing++
+		} else {
+			output, _ := r["output"].(string)
+			if strings.Contains(output, "НЕ УСТАНОВЛЕНО") {
+				entry["status"] = "не установлено"
+				missing++
+			} else {
+				entry["status"] = "установлено"
+				entry["version"] = strings.TrimSpace(output)
+				installed++
+			}
+		}
+		results = append(results, entry)
 	}
 
-	var result struct {
-		Results []struct {
-			Text     string                 `json:"text"`
-			Score    float64                `json:"score"`
-			Source   string                 `json:"source"`
-			Metadata map[string]interface{} `json:"metadata"`
-		} `json:"results"`
-		Count int `json:"count"`
-	}
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		slog.Error("Ошибка декодирования RAG ответа от memory-service", slog.String("ошибка", err.Error()))
-		return "", nil
+	return map[string]interface{}{
+		"success":   true,
+		"message":   fmt.Sprintf("Проверено %d программ: %d установлено, %d отсутствует", len(programs), installed, missing),
+		"installed": installed,
+		"missing":   missing,
+		"programs":  results,
 	}
+}
 
-	if len(result.Results) == 0 {
-		return "", nil
+// securityChecks — проверки security_audit по порядку (эндпоинты tools-service /security/*).
+var securityChecks = []string{"ports", "sudo", "ssh", "updates", "writable"}
+
+// securitySeverityRank — порядок уровней важности замечаний (меньше — срочнее).
+var securitySeverityRank = map[string]int{"critical": 0, "high": 1, "medium": 2, "low": 3, "info": 4}
+
+// handleSecurityAudit — LEGO-блок: аудит безопасности хоста.
+// Выполняет проверки tools-service (открытые порты, пользователи с sudo,
+// настройки SSH, обновления безопасности, файлы с записью для всех) и
+// сводит замечания в один список по убыванию важности: сначала critical,
+// затем high, medium, low, info. Недоступная проверка попадает в checks
+// с ошибкой и не прерывает аудит.
+func handleSecurityAudit(ctx context.Context, args map[string]interface{}) map[string]interface{} {
+	checks := securityChecks
+	if raw, ok := args["checks"].([]interface{}); ok && len(raw) > 0 {
+		checks = nil
+		for _, c := range raw {
+			name, _ := c.(string)
+			if !slices.Contains(securityChecks, name) {
+				return map[string]interface{}{"error": fmt.Sprintf("неизвестная проверка %q, доступны: %s", name, strings.Join(securityChecks, ", "))}
+			}
+			checks = append(checks, name)
+		}
 	}
 
-	var sources []Source
-	ragContext := "\n\n=== База знаний (RAG — memory-service) ===\n"
-	for i, r := range result.Results {
-		title := r.Source
-		if fn, ok := r.Metadata["file_name"]; ok {
-			title = fmt.Sprintf("%v", fn)
+	var findings []map[string]interface{}
+	status := map[string]interface{}{}
+	for _, check := range checks {
+		body := map[string]interface{}{}
+		if check == "writable" && args["paths"] != nil {
+			body["paths"] = args["paths"]
 		}
-		ragContext += fmt.Sprintf("[%d] %s: %s\n", i+1, title, truncate(r.Text, 150))
-		sources = append(sources, Source{
-			Title:   title,
-			Content: truncate(r.Text, 100),
-			Score:   i + 1,
-		})
+		r, err := callToolCtx(ctx, "security_"+check, body)
+		if err == nil {
+			if msg, ok := r["error"].(string); ok {
+				err = errors.New(msg)
+			}
+		}
+		if err != nil {
+			status[check] = map[string]interface{}{"status": "error", "error": err.Error()}
+			continue
+		}
+		list, _ := r["findings"].([]interface{})
+		for _, f := range list {
+			if m, ok := f.(map[string]interface{}); ok {
+				findings = append(findings, m)
+			}
+		}
+		status[check] = map[string]interface{}{"status": "ok", "findings": len(list)}
 	}
-	ragContext += "Используй эту информацию из базы знаний для более точных ответов.\n"
-	return ragContext, sources
-}
 
-// proxyToMemoryService — вспомогательная функция для проксирования запросов к memory-service.
-// Используется новыми RAG-обработчиками (move, soft-delete, restore, pin, content-search, contradictions).
-func proxyToMemoryService(w http.ResponseWriter, method string, path string, body []byte) {
-	memoryURL := getEnv("MEMORY_SERVICE_URL", "http://localhost:8001")
-	url := memoryURL + path
-
-	var req *http.Request
-	var err error
-	if body != nil {
-		req, err = http.NewRequest(method, url, bytes.NewReader(body))
-	} else {
-		req, err = http.NewRequest(method, url, nil)
+	rank := func(f map[string]interface{}) int {
+		sev, _ := f["severity"].(string)
+		if r, ok := securitySeverityRank[sev]; ok {
+			return r
+		}
+		return len(securitySeverityRank)
 	}
-	if err != nil {
-		slog.Error("Ошибка создания запроса к memory-service", slog.String("ошибка", err.Error()))
-		http.Error(w, `{"error":"internal error"}`, http.StatusInternalServerError)
-		return
+	sort.SliceStable(findings, func(i, j int) bool { return rank(findings[i]) < rank(findings[j]) })
+	counts := map[string]int{"critical": 0, "high": 0, "medium": 0, "low": 0, "info": 0}
+	for i, f := range findings {
+		f["priority"] = i + 1
+		if sev, ok := f["severity"].(string); ok {
+			counts[sev]++
+		}
 	}
-	req.Header.Set("Content-Type", "application/json")
 
-	client := &http.Client{Timeout: 30 * time.Second}
-	resp, err := client.Do(req)
-	if err != nil {
-		slog.Error("Ошибка запроса к memory-service", slog.String("путь", path), slog.String("ошибка", err.Error()))
-		http.Error(w, `{"error":"memory-service unavailable"}`, http.StatusBadGateway)
-		return
+	message := fmt.Sprintf("Аудит безопасности: критичных %d, высоких %d, средних %d, низких %d", counts["critical"], counts["high"], counts["medium"], counts["low"])
+	if counts["critical"]+counts["high"] > 0 {
+		message += ". Начните с пунктов priority 1–" + strconv.Itoa(counts["critical"]+counts["high"])
 	}
-	defer resp.Body.Close()
-
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(resp.StatusCode)
-	io.Copy(w, resp.Body)
-}
-
-// ragMoveHandler — перемещение файла между папками в RAG-базе
-func ragMoveHandler(w http.ResponseWriter, r *http.Request) {
-	cid := r.Header.Get("X-Request-ID")
-	if r.Method != http.MethodPost {
-		apierror.MethodNotAllowed(w, cid)
-		return
+	return map[string]interface{}{
+		"success":  true,
+		"message":  message,
+		"summary":  counts,
+		"checks":   status,
+		"findings": findings,
 	}
-	body, _ := io.ReadAll(r.Body)
-	proxyToMemoryService(w, "POST", "/files/move", body)
 }
 
-// ragSoftDeleteHandler — мягкое удаление файла (пометка deleted_at)
-func ragSoftDeleteHandler(w http.ResponseWriter, r *http.Request) {
-	cid := r.Header.Get("X-Request-ID")
-	if r.Method != http.MethodPost && r.Method != http.MethodPatch {
-		apierror.MethodNotAllowed(w, cid)
-		return
-	}
-	body, _ := io.ReadAll(r.Body)
-	proxyToMemoryService(w, "PATCH", "/files/soft-delete", body)
-}
+// handleDiagnoseService — LEGO-блок: диагностика сервиса.
+// Проверяет: 1) занят ли указанный порт, 2) работает ли процесс,
+// 3) HTTP-ответ health_url (если указан), 4) последние строки логов.
+// Возвращает структурированный отчёт о состоянии сервиса.
+func handleDiagnoseService(ctx context.Context, args map[string]interface{}) map[string]interface{} {
+	serviceName, _ := args["service_name"].(string)
+	port, _ := args["port"].(float64)
+	healthURL, _ := args["health_url"].(string)
 
-// ragRestoreHandler — восстановление мягко удалённого файла
-func ragRestoreHandler(w http.ResponseWriter, r *http.Request) {
-	cid := r.Header.Get("X-Request-ID")
-	if r.Method != http.MethodPost && r.Method != http.MethodPatch {
-		apierror.MethodNotAllowed(w, cid)
-		return
+	if serviceName == "" || port == 0 {
+		return map[string]interface{}{"error": "service_name и port обязательны"}
 	}
-	body, _ := io.ReadAll(r.Body)
-	proxyToMemoryService(w, "PATCH", "/files/restore", body)
-}
 
-// ragPinHandler — закрепление/открепление файла
-func ragPinHandler(w http.ResponseWriter, r *http.Request) {
-	cid := r.Header.Get("X-Request-ID")
-	if r.Method == http.MethodPost {
-		body, _ := io.ReadAll(r.Body)
-		proxyToMemoryService(w, "POST", "/files/pin", body)
-		return
-	}
-	if r.Method == http.MethodDelete {
-		name := r.URL.Query().Get("name")
-		proxyToMemoryService(w, "DELETE", "/files/pin?name="+name, nil)
-		return
+	report := map[string]interface{}{
+		"service": serviceName,
+		"port":    int(port),
 	}
-	apierror.MethodNotAllowed(w, cid)
-}
 
-// ragRenameHandler — переименование файла в RAG-базе
-func ragRenameHandler(w http.ResponseWriter, r *http.Request) {
-	cid := r.Header.Get("X-Request-ID")
-	if r.Method != http.MethodPatch && r.Method != http.MethodPost {
-		apierror.MethodNotAllowed(w, cid)
-		return
+	// Шаг 1: Проверяем, занят ли порт (кто слушает)
+	portCheck, err := callToolCtx(ctx, "execute", map[string]interface{}{
+		"command": fmt.Sprintf("ss -tlnp 2>/dev/null | grep ':%d ' || echo 'порт %d не занят'", int(port), int(port)),
+	})
+	if err == nil {
+		report["port_check"] = portCheck
 	}
-	body, _ := io.ReadAll(r.Body)
-	proxyToMemoryService(w, "PATCH", "/files/rename", body)
-}
 
-// ragContentSearchHandler — семантический поиск по содержимому файлов
-func ragContentSearchHandler(w http.ResponseWriter, r *http.Request) {
-	cid := r.Header.Get("X-Request-ID")
-	if r.Method != http.MethodPost {
-		apierror.MethodNotAllowed(w, cid)
-		return
+	// Шаг 2: Проверяем процесс по имени сервиса
+	procCheck, err := callToolCtx(ctx, "execute", map[string]interface{}{
+		"command": fmt.Sprintf("pgrep -fa '%s' 2>/dev/null || echo 'процесс %s не найден'", serviceName, serviceName),
+	})
+	if err == nil {
+		report["process_check"] = procCheck
 	}
-	body, _ := io.ReadAll(r.Body)
-	proxyToMemoryService(w, "POST", "/files/content-search", body)
-}
 
-// ragContradictionsHandler — получение списка обнаруженных противоречий
-func ragContradictionsHandler(w http.ResponseWriter, r *http.Request) {
-	cid := r.Header.Get("X-Request-ID")
-	if r.Method != http.MethodGet {
-		apierror.MethodNotAllowed(w, cid)
-		return
+	// Шаг 3: HTTP-проверка здоровья (если указан URL)
+	if healthURL != "" {
+		healthCheck, err := callToolCtx(ctx, "execute", map[string]interface{}{
+			"command": fmt.Sprintf("curl -s -o /dev/null -w '%%{http_code}' --connect-timeout 3 '%s' 2>/dev/null || echo 'недоступен'", healthURL),
+		})
+		if err == nil {
+			report["health_check"] = healthCheck
+		}
 	}
-	topK := r.URL.Query().Get("top_k")
-	path := "/contradictions"
-	if topK != "" {
-		path += "?top_k=" + topK
+
+	// Шаг 4: Проверяем журнал systemd (если сервис системный)
+	journalCheck, err := callToolCtx(ctx, "execute", map[string]interface{}{
+		"command": fmt.Sprintf("journalctl -u %s --no-pager -n 5 2>/dev/null || echo 'журнал systemd недоступен для %s'", serviceName, serviceName),
+	})
+	if err == nil {
+		report["journal"] = journalCheck
 	}
-	proxyToMemoryService(w, "GET", path, nil)
-}
 
-// ragDeletedFilesHandler — получение списка мягко удалённых файлов для корзины
-func ragDeletedFilesHandler(w http.ResponseWriter, r *http.Request) {
-	cid := r.Header.Get("X-Request-ID")
-	if r.Method != http.MethodGet {
-		apierror.MethodNotAllowed(w, cid)
-		return
+	// Шаг 5: Состояние и последние инциденты сторожа, если он следит за сервисом
+	for _, st := range serviceWatchdog.Statuses() {
+		if st.Name != serviceName && st.Unit != serviceName && st.Container != serviceName {
+			continue
+		}
+		var incidents []watchdog.Incident
+		for _, inc := range serviceWatchdog.Incidents() {
+			if inc.Service == st.Name && len(incidents) < 3 {
+				inc.Diagnosis = nil
+				incidents = append(incidents, inc)
+			}
+		}
+		report["watchdog"] = map[string]interface{}{"status": st, "incidents": incidents}
 	}
-	proxyToMemoryService(w, "GET", "/files/deleted", nil)
-}
 
-// === Proxy-обработчики для Skill Engine (Eternal RAG: раздел 5.3) ===
-// Проксируют запросы от web-ui к memory-service для управления навыками.
+	report["success"] = true
+	report["message"] = fmt.Sprintf("Диагностика сервиса '%s' (порт %d) завершена", serviceName, int(port))
+	return report
+}
 
-// skillsListHandler — получение списка / создание навыков
-func skillsListHandler(w http.ResponseWriter, r *http.Request) {
-	cid := r.Header.Get("X-Request-ID")
-	switch r.Method {
-	case http.MethodGet:
-		// Передаём query-параметры (workspace_id, skill_status)
-		query := r.URL.RawQuery
-		path := "/skills"
-		if query != "" {
-			path += "?" + query
+// serviceWatchdog — сторож сервисов (WATCHDOG_INTERVAL): проверка здоровья,
+// перезапуск упавших через tools-service и журнал инцидентов.
+var serviceWatchdog = watchdog.New(func() watchdog.Config {
+	cfg := config.Current()
+	return watchdog.Config{Failures: cfg.WatchdogFailures, Cooldown: cfg.WatchdogCooldown}
+})
+
+// watchedServices — сервисы сторожа: из WATCHDOG_FILE или по умолчанию.
+var watchedServices []watchdog.Service
+
+// initWatchdog — подключает перезапуск и диагностику к сторожу и загружает
+// сервисы. Без WATCHDOG_FILE (или при ошибке в нём) сторож следит за tools-,
+// memory- и browser-service и ничего не перезапускает.
+func initWatchdog() {
+	serviceWatchdog.Restart = restartWatchedService
+	serviceWatchdog.Diagnose = diagnoseWatchedService
+	serviceWatchdog.OnIncident = onServiceIncident
+	cfg := config.Current()
+	if cfg.WatchdogFile != "" {
+		list, err := watchdog.LoadServices(cfg.WatchdogFile)
+		if err == nil {
+			watchedServices = list
+			slog.Info("Сервисы сторожа загружены", slog.String("файл", cfg.WatchdogFile), slog.Int("количество", len(list)))
+			return
 		}
-		proxyToMemoryService(w, "GET", path, nil)
-	case http.MethodPost:
-		body, _ := io.ReadAll(r.Body)
-		proxyToMemoryService(w, "POST", "/skills", body)
-	default:
-		apierror.MethodNotAllowed(w, cid)
+		slog.Error("Не удалось загрузить сервисы сторожа", slog.String("файл", cfg.WatchdogFile), slog.String("ошибка", err.Error()))
+	}
+	watchedServices = []watchdog.Service{
+		{Name: "tools-service", HealthURL: cfg.ToolsServiceURL + "/health", Restart: watchdog.RestartNone},
+		{Name: "memory-service", HealthURL: cfg.MemoryServiceURL + "/health", Restart: watchdog.RestartNone},
+		{Name: "browser-service", HealthURL: cfg.BrowserServiceURL + "/health", Restart: watchdog.RestartNone},
 	}
 }
 
-// skillByIDHandler — CRUD операции над конкретным навыком (/skills/{id})
-func skillByIDHandler(w http.ResponseWriter, r *http.Request) {
-	cid := r.Header.Get("X-Request-ID")
-	// Извлекаем ID из пути: /skills/{id}
-	skillID := strings.TrimPrefix(r.URL.Path, "/skills/")
-	// Убираем суффикс /usage если есть (отдельный обработчик)
-	if strings.HasSuffix(skillID, "/usage") {
-		skillUsageHandler(w, r)
-		return
+// restartWatchedService — команда перезапуска через execute tools-service.
+// Упавший tools-service так не перезапустить: для него нужен systemd
+// Restart=always или restart-политика Docker.
+func restartWatchedService(ctx context.Context, s watchdog.Service, command string) error {
+	result, err := callToolCtx(ctx, "execute", map[string]interface{}{"command": command})
+	if err != nil {
+		return err
 	}
-	if skillID == "" {
-		http.Error(w, `{"error":"skill_id required"}`, http.StatusBadRequest)
-		return
+	if status, errText := taskreport.ActionStatus(result); status == taskreport.ActionError {
+		return errors.New(errText)
 	}
-	switch r.Method {
-	case http.MethodGet:
-		proxyToMemoryService(w, "GET", "/skills/"+skillID, nil)
-	case http.MethodPut:
-		body, _ := io.ReadAll(r.Body)
-		proxyToMemoryService(w, "PUT", "/skills/"+skillID, body)
-	case http.MethodDelete:
-		proxyToMemoryService(w, "DELETE", "/skills/"+skillID, nil)
-	default:
-		apierror.MethodNotAllowed(w, cid)
+	if code, _ := result["returncode"].(float64); code != 0 {
+		stderr, _ := result["stderr"].(string)
+		return fmt.Errorf("код %d: %s", int(code), strings.TrimSpace(stderr))
 	}
+	return nil
 }
 
-// skillSearchHandler — семантический поиск навыков
-func skillSearchHandler(w http.ResponseWriter, r *http.Request) {
-	cid := r.Header.Get("X-Request-ID")
-	if r.Method != http.MethodPost {
-		apierror.MethodNotAllowed(w, cid)
-		return
+// diagnoseWatchedService — отчёт diagnose_service об упавшем сервисе для инцидента.
+func diagnoseWatchedService(ctx context.Context, s watchdog.Service) map[string]interface{} {
+	u, err := url.Parse(s.HealthURL)
+	if err != nil {
+		return nil
 	}
-	body, _ := io.ReadAll(r.Body)
-	proxyToMemoryService(w, "POST", "/skills/search", body)
+	port, _ := strconv.Atoi(u.Port())
+	if port == 0 {
+		port = 80
+		if u.Scheme == "https" {
+			port = 443
+		}
+	}
+	name := s.Name
+	if s.Restart == watchdog.RestartSystemd {
+		name = s.Unit
+	}
+	report := handleDiagnoseService(ctx, map[string]interface{}{"service_name": name, "port": float64(port), "health_url": s.HealthURL})
+	delete(report, "watchdog")
+	return report
 }
 
-// skillFromDialogHandler — создание навыка из диалога
-func skillFromDialogHandler(w http.ResponseWriter, r *http.Request) {
-	cid := r.Header.Get("X-Request-ID")
-	if r.Method != http.MethodPost {
-		apierror.MethodNotAllowed(w, cid)
+// onServiceIncident — инцидент сторожа в системный лог.
+func onServiceIncident(inc watchdog.Incident) {
+	if inc.RecoveredAt != nil {
+		WriteSystemLog("info", inc.Service, fmt.Sprintf("Сервис %s снова отвечает (инцидент #%d)", inc.Service, inc.ID), "")
 		return
 	}
-	body, _ := io.ReadAll(r.Body)
-	proxyToMemoryService(w, "POST", "/skills/from-dialog", body)
+	details := fmt.Sprintf("Ошибка проверки: %s; неудачных проверок подряд: %d", inc.Error, inc.Failures)
+	switch inc.Action {
+	case watchdog.ActionRestarted:
+		WriteSystemLog("warn", inc.Service, fmt.Sprintf("Сервис %s не отвечал и перезапущен: %s", inc.Service, inc.Command), details)
+	case watchdog.ActionRestartFailed:
+		WriteSystemLog("error", inc.Service, fmt.Sprintf("Не удалось перезапустить сервис %s: %s", inc.Service, inc.RestartError), details)
+	default:
+		WriteSystemLog("error", inc.Service, fmt.Sprintf("Сервис %s не отвечает", inc.Service), details)
+	}
 }
 
-// skillUsageHandler — фиксация использования навыка
-func skillUsageHandler(w http.ResponseWriter, r *http.Request) {
+// watchdogHandler — сторож сервисов.
+//
+//	GET  /watchdog       — настройки, сервисы, их состояние и последние инциденты
+//	POST /watchdog/check — проверить сервисы сейчас (и при выключенном стороже)
+func watchdogHandler(w http.ResponseWriter, r *http.Request) {
 	cid := r.Header.Get("X-Request-ID")
-	if r.Method != http.MethodPost {
+	switch {
+	case r.URL.Path == "/watchdog" && r.Method == http.MethodGet:
+	case r.URL.Path == "/watchdog/check" && r.Method == http.MethodPost:
+		serviceWatchdog.Tick(r.Context(), watchedServices)
+	case r.URL.Path == "/watchdog" || r.URL.Path == "/watchdog/check":
 		apierror.MethodNotAllowed(w, cid)
 		return
-	}
-	// Путь: /skills/{id}/usage → извлекаем ID
-	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/skills/"), "/")
-	if len(parts) < 2 || parts[0] == "" {
-		http.Error(w, `{"error":"skill_id required"}`, http.StatusBadRequest)
+	default:
+		apierror.NotFound(w, cid, "Неизвестный путь")
 		return
 	}
-	proxyToMemoryService(w, "POST", "/skills/"+parts[0]+"/usage", nil)
+	cfg := config.Current()
+	writeJSON(w, map[string]interface{}{
+		"enabled":   cfg.WatchdogInterval > 0,
+		"interval":  cfg.WatchdogInterval.String(),
+		"failures":  cfg.WatchdogFailures,
+		"cooldown":  cfg.WatchdogCooldown.String(),
+		"services":  watchedServices,
+		"status":    serviceWatchdog.Statuses(),
+		"incidents": serviceWatchdog.Incidents(),
+	})
 }
 
-// === Proxy-обработчики для Graph Engine (Eternal RAG: раздел 5.4) ===
-// Проксируют запросы от web-ui к memory-service для управления графом знаний.
+// maintenanceState — последний отчёт ночного обслуживания и признак идущего прогона.
+var maintenanceState struct {
+	mu      sync.Mutex
+	running bool
+	last    *maintenance.Report
+}
 
-// graphRelationshipsHandler — список / создание связей
-func graphRelationshipsHandler(w http.ResponseWriter, r *http.Request) {
-	cid := r.Header.Get("X-Request-ID")
-	switch r.Method {
-	case http.MethodGet:
-		query := r.URL.RawQuery
-		path := "/graph/relationships"
-		if query != "" {
-			path += "?" + query
-		}
-		proxyToMemoryService(w, "GET", path, nil)
-	case http.MethodPost:
-		body, _ := io.ReadAll(r.Body)
-		proxyToMemoryService(w, "POST", "/graph/relationships", body)
-	default:
-		apierror.MethodNotAllowed(w, cid)
+// errMaintenanceRunning — обслуживание уже идёт (POST /maintenance/run во время прогона).
+var errMaintenanceRunning = errors.New("обслуживание уже выполняется")
+
+// maintenanceTasks — задачи ночного обслуживания по порядку.
+func maintenanceTasks() []maintenance.Task {
+	return []maintenance.Task{
+		{Name: "log_pruning", Title: "Очистка системного лога", Run: func(ctx context.Context) (maintenance.Outcome, error) {
+			if logPruner == nil {
+				return maintenance.Outcome{Summary: "очистка не настроена"}, nil
+			}
+			res, err := logPruner.ApplyPolicy(ctx)
+			return maintenance.Outcome{Summary: fmt.Sprintf("удалено записей: %d", res.Deleted), Details: res}, err
+		}},
+		{Name: "rag_compaction", Title: "Уплотнение индекса RAG", Run: compactRAG},
+		{Name: "model_refresh", Title: "Обновление списков моделей", Run: func(context.Context) (maintenance.Outcome, error) {
+			providers := watchedProviders()
+			var changes []modelwatch.Change
+			var failed []string
+			for _, p := range providers {
+				change, err := modelWatcher.Refresh(p)
+				if err != nil {
+					failed = append(failed, p.Name()+": "+err.Error())
+					continue
+				}
+				if change != nil {
+					onModelCatalogChange(*change)
+					changes = append(changes, *change)
+				}
+			}
+			out := maintenance.Outcome{
+				Summary: fmt.Sprintf("провайдеров: %d, изменилось каталогов: %d", len(providers), len(changes)),
+				Details: map[string]interface{}{"changes": changes, "errors": failed},
+				Warning: len(failed) > 0,
+			}
+			if len(failed) > 0 {
+				out.Summary += ", ошибок: " + strconv.Itoa(len(failed))
+			}
+			return out, nil
+		}},
+		{Name: "disk_usage", Title: "Место на диске", Run: func(context.Context) (maintenance.Outcome, error) {
+			cfg := config.Current()
+			paths := []string{cfg.UploadsDir, cfg.ArtifactsDir, cfg.SkillsDir, logstore.LoadRetentionConfig().ArchiveDir}
+			if cfg.DBDriver == config.DriverSQLite {
+				paths = append(paths, filepath.Dir(cfg.SQLitePath))
+			}
+			return maintenance.CheckDisks(paths, cfg.MaintenanceDiskWarn)
+		}},
 	}
 }
 
-// graphRelationshipByIDHandler — удаление связи по ID
-func graphRelationshipByIDHandler(w http.ResponseWriter, r *http.Request) {
-	cid := r.Header.Get("X-Request-ID")
-	relID := strings.TrimPrefix(r.URL.Path, "/graph/relationships/")
-	if relID == "" {
-		http.Error(w, `{"error":"relationship_id required"}`, http.StatusBadRequest)
-		return
+// compactRAG — удаление документов RAG с истёкшим сроком хранения и
+// переиндексация коллекций, которым она нужна (смена модели эмбеддингов).
+func compactRAG(ctx context.Context) (maintenance.Outcome, error) {
+	base := config.Current().MemoryServiceURL
+	client := httpclient.New("memory", 10*time.Minute)
+	call := func(method, path string) (map[string]interface{}, error) {
+		req, err := http.NewRequestWithContext(ctx, method, base+path, nil)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("memory-service %s: HTTP %d", path, resp.StatusCode)
+		}
+		var out map[string]interface{}
+		return out, json.NewDecoder(resp.Body).Decode(&out)
 	}
-	if r.Method != http.MethodDelete {
-		apierror.MethodNotAllowed(w, cid)
-		return
+	expired, err := call(http.MethodDelete, "/ttl/expired")
+	if err != nil {
+		return maintenance.Outcome{}, err
 	}
-	proxyToMemoryService(w, "DELETE", "/graph/relationships/"+relID, nil)
+	reindexed, err := call(http.MethodPost, "/reindex")
+	if err != nil {
+		return maintenance.Outcome{}, err
+	}
+	deleted, _ := expired["deleted_count"].(float64)
+	count, _ := reindexed["reindexed_count"].(float64)
+	return maintenance.Outcome{
+		Summary: fmt.Sprintf("удалено устаревших документов: %d, переиндексировано: %d", int(deleted), int(count)),
+		Details: map[string]interface{}{"ttl_deleted": int(deleted), "reindexed": int(count)},
+	}, nil
 }
 
-// graphNeighborsHandler — получение соседних узлов
-func graphNeighborsHandler(w http.ResponseWriter, r *http.Request) {
-	cid := r.Header.Get("X-Request-ID")
-	if r.Method != http.MethodGet {
-		apierror.MethodNotAllowed(w, cid)
-		return
+// runMaintenance — прогон ночного обслуживания: задачи, сводка в хранилище
+// артефактов, системный лог и webhook уведомлений (MAINTENANCE_WEBHOOK_URL).
+func runMaintenance(ctx context.Context) (maintenance.Report, error) {
+	maintenanceState.mu.Lock()
+	if maintenanceState.running {
+		maintenanceState.mu.Unlock()
+		return maintenance.Report{}, errMaintenanceRunning
 	}
-	nodeID := strings.TrimPrefix(r.URL.Path, "/graph/neighbors/")
-	if nodeID == "" {
-		http.Error(w, `{"error":"node_id required"}`, http.StatusBadRequest)
-		return
+	maintenanceState.running = true
+	maintenanceState.mu.Unlock()
+	defer func() {
+		maintenanceState.mu.Lock()
+		maintenanceState.running = false
+		maintenanceState.mu.Unlock()
+	}()
+
+	rep := maintenance.Run(ctx, maintenanceTasks())
+	summary := rep.Markdown()
+	name := "maintenance-" + rep.StartedAt.Format("2006-01-02") + ".md"
+	if stored, err := artifactFiles().Save(name, strings.NewReader(summary)); err != nil {
+		slog.Error("Не удалось сохранить сводку обслуживания", slog.String("ошибка", err.Error()))
+	} else {
+		mimeType := artifact.MimeType(name)
+		rec := models.Artifact{Tool: "maintenance", Name: name, MimeType: mimeType, Kind: artifact.Kind(mimeType), Size: stored.Size, SHA256: stored.SHA256, StorageKey: stored.Key}
+		if err := db.DB.Create(&rec).Error; err != nil {
+			artifactFiles().Remove(stored.Key)
+			slog.Error("Не удалось зарегистрировать сводку обслуживания", slog.String("ошибка", err.Error()))
+		} else {
+			rep.ArtifactID = rec.ID
+		}
 	}
-	query := r.URL.RawQuery
-	path := "/graph/neighbors/" + nodeID
-	if query != "" {
-		path += "?" + query
+	if hook := config.Current().MaintenanceWebhookURL; hook != "" {
+		if err := notifyMaintenance(ctx, hook, rep, summary); err != nil {
+			rep.NotifyError = err.Error()
+			slog.Warn("Сводка обслуживания не отправлена", slog.String("ошибка", err.Error()))
+		} else {
+			rep.Notified = true
+		}
 	}
-	proxyToMemoryService(w, "GET", path, nil)
+	level := map[string]string{maintenance.StatusOK: "info", maintenance.StatusWarning: "warn", maintenance.StatusError: "error"}[rep.Status]
+	WriteSystemLog(level, "agent-service", rep.Headline(), summary)
+
+	maintenanceState.mu.Lock()
+	maintenanceState.last = &rep
+	maintenanceState.mu.Unlock()
+	return rep, nil
 }
 
-// graphTraverseHandler — обход графа знаний (BFS)
-func graphTraverseHandler(w http.ResponseWriter, r *http.Request) {
-	cid := r.Header.Get("X-Request-ID")
-	if r.Method != http.MethodPost {
-		apierror.MethodNotAllowed(w, cid)
-		return
+// notifyMaintenance — POST сводки в webhook: text — сводка в Markdown
+// (Slack, Mattermost и Rocket.Chat показывают её как сообщение), report — отчёт целиком.
+func notifyMaintenance(ctx context.Context, hook string, rep maintenance.Report, summary string) error {
+	data, _ := json.Marshal(map[string]interface{}{"text": summary, "report": rep})
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, hook, bytes.NewReader(data))
+	if err != nil {
+		return err
 	}
-	body, _ := io.ReadAll(r.Body)
-	proxyToMemoryService(w, "POST", "/graph/traverse", body)
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := httpclient.New("notify", 15*time.Second).Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook ответил HTTP %d", resp.StatusCode)
+	}
+	return nil
 }
 
-// embeddingStatusHandler — статус модели эмбеддингов
-func embeddingStatusHandler(w http.ResponseWriter, r *http.Request) {
+// maintenanceHandler — ночное обслуживание.
+//
+//	GET  /maintenance     — расписание, следующий запуск и последний отчёт
+//	POST /maintenance/run — выполнить сейчас (409, если уже идёт)
+func maintenanceHandler(w http.ResponseWriter, r *http.Request) {
 	cid := r.Header.Get("X-Request-ID")
-	if r.Method != http.MethodGet {
+	switch {
+	case r.URL.Path == "/maintenance" && r.Method == http.MethodGet:
+		cfg := config.Current()
+		out := map[string]interface{}{"time": cfg.MaintenanceTime, "enabled": cfg.MaintenanceTime != "", "webhook": cfg.MaintenanceWebhookURL != ""}
+		if next, err := maintenance.NextRun(time.Now(), cfg.MaintenanceTime); err == nil {
+			out["next_run"] = next
+		}
+		maintenanceState.mu.Lock()
+		out["running"] = maintenanceState.running
+		if maintenanceState.last != nil {
+			out["last"] = maintenanceState.last
+		}
+		maintenanceState.mu.Unlock()
+		writeJSON(w, out)
+	case r.URL.Path == "/maintenance/run" && r.Method == http.MethodPost:
+		rep, err := runMaintenance(r.Context())
+		if errors.Is(err, errMaintenanceRunning) {
+			apierror.Write(w, http.StatusConflict, apierror.Response{
+				Code:      apierror.CodeForStatus(http.StatusConflict),
+				Message:   err.Error(),
+				Hint:      "Дождитесь окончания: GET /maintenance",
+				RequestID: cid,
+			})
+			return
+		}
+		slog.Info("Обслуживание выполнено по запросу", slog.String("статус", rep.Status), slog.String("request_id", cid))
+		writeJSON(w, rep)
+	case r.URL.Path == "/maintenance" || r.URL.Path == "/maintenance/run":
 		apierror.MethodNotAllowed(w, cid)
-		return
+	default:
+		apierror.NotFound(w, cid, "Неизвестный путь")
 	}
-	proxyToMemoryService(w, "GET", "/embeddings/status", nil)
 }
 
-// ragAddFolderHandler — обработчик для рекурсивной загрузки папки в RAG
-func ragAddFolderHandler(w http.ResponseWriter, r *http.Request) {
-	cid := r.Header.Get("X-Request-ID")
-	if r.Method != http.MethodPost {
-		apierror.MethodNotAllowed(w, cid)
-		return
-	}
+// stackBackupMu — одно резервное копирование или восстановление за раз.
+var stackBackupMu sync.Mutex
 
-	var req struct {
-		FolderPath string `json:"folder_path"`
+// stackBackupSource — что входит в копию стека: база, загруженные файлы,
+// промпты и файлы конфигурации (AGENT_CONFIG_FILE, интенты, правила риска, сторож).
+func stackBackupSource(cfg *config.Config) stackbackup.Source {
+	src := stackbackup.Source{Dirs: map[string]string{
+		stackbackup.ComponentUploads: cfg.UploadsDir,
+		stackbackup.ComponentPrompts: filepath.Join(".", "prompts"),
+	}}
+	if db.DB != nil {
+		switch db.DB.Dialector.Name() {
+		case config.DriverSQLite:
+			src.DB = stackbackup.SQLite{DB: db.DB}
+		case config.DriverPostgres:
+			dsn, env := db.PostgresToolConn(cfg)
+			src.DB = stackbackup.Postgres{DSN: dsn, Env: env}
+		}
 	}
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		apierror.BadRequest(w, cid, "Невалидный JSON", "")
-		return
+	for _, p := range []string{os.Getenv("AGENT_CONFIG_FILE"), cfg.IntentsFile, cfg.RiskRulesFile, cfg.WatchdogFile} {
+		if p != "" {
+			src.Config = append(src.Config, p)
+		}
 	}
+	return src
+}
 
-	folderPath := req.FolderPath
-	if folderPath == "" {
-		apierror.BadRequest(w, cid, "Требуется folder_path", "")
-		return
-	}
+// stackBackupRemote — внешнее хранилище из STACK_BACKUP_REMOTE (nil — не настроено).
+func stackBackupRemote(cfg *config.Config) (stackbackup.Remote, error) {
+	return stackbackup.ParseRemote(cfg.StackBackupRemote, stackbackup.Credentials{
+		YandexDiskToken: cfg.YandexDiskToken,
+		S3Endpoint:      cfg.StackBackupS3Endpoint,
+		S3Region:        cfg.StackBackupS3Region,
+		S3AccessKey:     cfg.StackBackupS3AccessKey,
+		S3SecretKey:     cfg.StackBackupS3SecretKey,
+	}, httpclient.New("backup", 0))
+}
 
-	info, err := os.Stat(folderPath)
+// stackBackupResult — ответ POST /admin/backup.
+type stackBackupResult struct {
+	stackbackup.Info
+	Remote      string   `json:"remote,omitempty"`       // Расположение копии во внешнем хранилище
+	RemoteError string   `json:"remote_error,omitempty"` // Почему не отправлена
+	Pruned      []string `json:"pruned,omitempty"`       // Удалённые старые копии
+}
+
+// createStackBackup — копия в STACK_BACKUP_DIR, отправка во внешнее хранилище
+// (upload) и удаление копий сверх STACK_BACKUP_KEEP. Ошибка отправки не
+// отменяет локальную копию и возвращается в RemoteError.
+func createStackBackup(ctx context.Context, opts stackbackup.Options, upload bool) (stackBackupResult, error) {
+	cfg := config.Current()
+	info, err := stackbackup.Create(ctx, cfg.StackBackupDir, stackBackupSource(cfg), opts)
 	if err != nil {
-		apierror.NotFound(w, cid, "Папка не найдена")
-		return
+		return stackBackupResult{}, err
+	}
+	res := stackBackupResult{Info: info}
+	if remote, err := stackBackupRemote(cfg); err != nil {
+		res.RemoteError = err.Error()
+	} else if remote != nil && upload {
+		if f, err := os.Open(filepath.Join(cfg.StackBackupDir, info.Name)); err != nil {
+			res.RemoteError = err.Error()
+		} else {
+			res.Remote, err = remote.Upload(ctx, info.Name, f, info.Size)
+			f.Close()
+			if err != nil {
+				res.RemoteError = err.Error()
+			}
+		}
 	}
-	if !info.IsDir() {
-		apierror.BadRequest(w, cid, "Путь не является папкой", "")
-		return
+	if res.Pruned, err = stackbackup.Prune(cfg.StackBackupDir, cfg.StackBackupKeep); err != nil {
+		slog.Warn("Не удалось удалить старые копии стека", slog.String("ошибка", err.Error()))
+	}
+	details := fmt.Sprintf("размер: %.1f МБ, компоненты: %s", float64(info.Size)/(1<<20), strings.Join(info.Manifest.Components, ", "))
+	if res.Remote != "" {
+		details += ", внешняя копия: " + res.Remote
+	}
+	if res.RemoteError != "" {
+		details += ", не отправлена: " + res.RemoteError
+		WriteSystemLog("warn", "agent-service", "Резервная копия стека "+info.Name+" не отправлена во внешнее хранилище", details)
+	} else {
+		WriteSystemLog("info", "agent-service", "Резервная копия стека "+info.Name, details)
+	}
+	return res, nil
+}
+
+// stackBackupError — ответ на ошибку копирования или восстановления.
+func stackBackupError(w http.ResponseWriter, cid string, err error) {
+	switch {
+	case errors.Is(err, stackbackup.ErrUnknownComponent), errors.Is(err, stackbackup.ErrNoSource), errors.Is(err, stackbackup.ErrBadArchive):
+		apierror.BadRequest(w, cid, err.Error(), "Компоненты: db, uploads, prompts, config")
+	case errors.Is(err, stackbackup.ErrNotFound):
+		apierror.NotFound(w, cid, err.Error())
+	default:
+		apierror.InternalError(w, cid, err.Error(), "Проверьте STACK_BACKUP_DIR и наличие pg_dump/psql для PostgreSQL")
 	}
+}
 
-	// Рекурсивно сканируем папку
-	var filesAdded int
-	var filesSkipped int
-	var errors []string
+// stackBackupBusy — занято другой операцией: 409.
+func stackBackupBusy(w http.ResponseWriter, cid string) {
+	apierror.Write(w, http.StatusConflict, apierror.Response{
+		Code:      apierror.CodeForStatus(http.StatusConflict),
+		Message:   "резервное копирование или восстановление уже выполняется",
+		Hint:      "Повторите запрос после окончания",
+		RequestID: cid,
+	})
+}
 
-	var walkFunc func(path string, info os.FileInfo, err error) error
-	walkFunc = func(path string, info os.FileInfo, err error) error {
+// noConnDeadlines — снимает ReadTimeout и WriteTimeout сервера для запроса:
+// архив стека загружается и скачивается дольше них, предел задаёт timeout
+// маршрута в шлюзе.
+func noConnDeadlines(w http.ResponseWriter) {
+	rc := http.NewResponseController(w)
+	rc.SetReadDeadline(time.Time{})
+	rc.SetWriteDeadline(time.Time{})
+}
+
+// adminBackupHandler — резервная копия всего стека.
+//
+//	POST /admin/backup        — снять копию {components?, upload?}
+//	GET  /admin/backup        — копии в STACK_BACKUP_DIR и внешнее хранилище
+//	GET  /admin/backup/{name} — скачать архив
+func adminBackupHandler(w http.ResponseWriter, r *http.Request) {
+	cid := r.Header.Get("X-Request-ID")
+	noConnDeadlines(w)
+	cfg := config.Current()
+	if name := strings.TrimPrefix(r.URL.Path, "/admin/backup/"); name != r.URL.Path && name != "" {
+		if r.Method != http.MethodGet {
+			apierror.MethodNotAllowed(w, cid)
+			return
+		}
+		path, err := stackbackup.Path(cfg.StackBackupDir, name)
 		if err != nil {
-			return nil
+			stackBackupError(w, cid, err)
+			return
 		}
-		if info.IsDir() {
-			// Пропускаем скрытые папки
-			if strings.HasPrefix(info.Name(), ".") {
-				return filepath.SkipDir
+		w.Header().Set("Content-Type", "application/gzip")
+		w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": name}))
+		http.ServeFile(w, r, path)
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		list, err := stackbackup.List(cfg.StackBackupDir)
+		if err != nil {
+			stackBackupError(w, cid, err)
+			return
+		}
+		writeJSON(w, map[string]interface{}{"backups": list, "dir": cfg.StackBackupDir, "keep": cfg.StackBackupKeep, "remote": cfg.StackBackupRemote})
+	case http.MethodPost:
+		var req struct {
+			Components []string `json:"components"`
+			Upload     *bool    `json:"upload"` // false — не отправлять во внешнее хранилище
+		}
+		if r.ContentLength != 0 {
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				apierror.BadRequest(w, cid, "Неверный JSON: "+err.Error(), "Пример: {\"components\": [\"db\", \"prompts\"]}")
+				return
 			}
-			return nil
 		}
-
-		ext := strings.ToLower(filepath.Ext(path))
-		if !supportedExtensions[ext] {
-			filesSkipped++
-			return nil
+		if !stackBackupMu.TryLock() {
+			stackBackupBusy(w, cid)
+			return
+		}
+		defer stackBackupMu.Unlock()
+		res, err := createStackBackup(r.Context(), stackbackup.Options{Components: req.Components}, req.Upload == nil || *req.Upload)
+		if err != nil {
+			slog.Error("Ошибка резервного копирования стека", slog.String("ошибка", err.Error()), slog.String("request_id", cid))
+			stackBackupError(w, cid, err)
+			return
 		}
+		slog.Info("Резервная копия стека создана", slog.String("имя", res.Name), slog.Int64("размер", res.Size), slog.String("request_id", cid))
+		writeJSON(w, res)
+	default:
+		apierror.MethodNotAllowed(w, cid)
+	}
+}
+
+// adminRestoreHandler — восстановление стека из архива.
+//
+//	POST /admin/restore?name=stack-….tar.gz — из копии в STACK_BACKUP_DIR
+//	POST /admin/restore                    — из архива в теле запроса
+//
+// Параметры: components=db,prompts — часть компонентов; dry_run=1 — только
+// проверить архив; без dry_run нужен confirm=yes. Перед восстановлением
+// снимается копия текущего состояния (…-pre-restore.tar.gz).
+func adminRestoreHandler(w http.ResponseWriter, r *http.Request) {
+	cid := r.Header.Get("X-Request-ID")
+	if r.Method != http.MethodPost {
+		apierror.MethodNotAllowed(w, cid)
+		return
+	}
+	noConnDeadlines(w)
+	q := r.URL.Query()
+	dryRun := q.Get("dry_run") == "1" || q.Get("dry_run") == "true"
+	if !dryRun && q.Get("confirm") != "yes" {
+		apierror.BadRequest(w, cid, "Восстановление перезапишет базу данных и файлы", "Проверьте архив с ?dry_run=1, затем повторите с ?confirm=yes")
+		return
+	}
+	var components []string
+	if c := q.Get("components"); c != "" {
+		components = strings.Split(c, ",")
+	}
+	if !stackBackupMu.TryLock() {
+		stackBackupBusy(w, cid)
+		return
+	}
+	defer stackBackupMu.Unlock()
 
-		// Читаем содержимое файла
-		content, err := os.ReadFile(path)
+	cfg := config.Current()
+	var archive io.Reader = r.Body
+	if name := q.Get("name"); name != "" {
+		path, err := stackbackup.Path(cfg.StackBackupDir, name)
 		if err != nil {
-			errors = append(errors, path+": "+err.Error())
-			return nil
+			stackBackupError(w, cid, err)
+			return
 		}
-
-		// Относительный путь от папки
-		relPath, _ := filepath.Rel(folderPath, path)
-		title := relPath
-
-		docID := fmt.Sprintf("doc-%d-%s", time.Now().UnixNano(), strings.ReplaceAll(relPath, "/", "-"))
-
-		if ragRetriever != nil && ragRetriever.Config().ChromaURL != "" {
-			ragDoc := rag.RagDoc{
-				ID:      docID,
-				Title:   title,
-				Content: string(content),
-				Source:  "folder:" + folderPath,
-			}
-			if err := ragRetriever.AddDocument(ragDoc); err != nil {
-				slog.Error("Ошибка добавления в ChromA", slog.String("ошибка", err.Error()))
-			}
+		f, err := os.Open(path)
+		if err != nil {
+			stackBackupError(w, cid, err)
+			return
 		}
+		defer f.Close()
+		archive = f
+	} else if r.ContentLength == 0 {
+		apierror.BadRequest(w, cid, "Нет архива", "Укажите ?name= копии из GET /admin/backup или передайте архив в теле запроса")
+		return
+	}
 
-		ragDoc := models.RagDocument{
-			Title:       title,
-			Content:     string(content),
-			Source:      "folder:" + folderPath,
-			ChunkIndex:  0,
-			TotalChunks: 1,
+	out := map[string]interface{}{}
+	if !dryRun {
+		// Без копии текущего состояния неудачное восстановление не откатить
+		pre, err := createStackBackup(r.Context(), stackbackup.Options{Components: components, Label: "pre-restore"}, false)
+		if err != nil {
+			slog.Error("Не удалось сохранить состояние перед восстановлением", slog.String("ошибка", err.Error()), slog.String("request_id", cid))
+			stackBackupError(w, cid, fmt.Errorf("копия перед восстановлением: %w", err))
+			return
 		}
-		if err := db.DB.Create(&ragDoc).Error; err != nil {
-			slog.Error("Ошибка сохранения RAG документа в БД", slog.String("ошибка", err.Error()))
-			errors = append(errors, title+": "+err.Error())
-			return nil
+		out["pre_restore_backup"] = pre.Name
+	}
+	res, err := stackbackup.Restore(r.Context(), archive, stackBackupSource(cfg), components, dryRun)
+	if err != nil {
+		slog.Error("Ошибка восстановления стека", slog.String("ошибка", err.Error()), slog.String("request_id", cid))
+		if !dryRun {
+			WriteSystemLog("error", "agent-service", "Восстановление стека не выполнено", err.Error())
 		}
-
-		filesAdded++
-		slog.Info("RAG файл добавлен из папки", slog.String("заголовок", title))
-		return nil
+		stackBackupError(w, cid, err)
+		return
 	}
-
-	if err := filepath.Walk(folderPath, walkFunc); err != nil {
-		slog.Error("Ошибка сканирования папки RAG", slog.String("ошибка", err.Error()))
+	out["result"] = res
+	if !dryRun {
+		// Кэши и фоновые задачи читают базу при запуске
+		out["restart_recommended"] = slices.Contains(res.Components, stackbackup.ComponentDB) || slices.Contains(res.Components, stackbackup.ComponentConfig)
+		WriteSystemLog("warn", "agent-service", "Стек восстановлен из резервной копии от "+res.Manifest.CreatedAt.Local().Format("2006-01-02 15:04"),
+			fmt.Sprintf("компоненты: %s, копия до восстановления: %v", strings.Join(res.Components, ", "), out["pre_restore_backup"]))
+		slog.Warn("Стек восстановлен из резервной копии", slog.String("компоненты", strings.Join(res.Components, ",")), slog.String("request_id", cid))
 	}
-
-	writeJSON(w, map[string]interface{}{
-		"status":        "ok",
-		"folder_path":   folderPath,
-		"files_added":   filesAdded,
-		"files_skipped": filesSkipped,
-		"errors":        errors,
-	})
+	writeJSON(w, out)
 }
 
-// handleViewLogs — обработчик инструмента view_logs для Админа.
-// Позволяет агенту просматривать системные логи с фильтрацией по уровню и сервису.
-func handleViewLogs(args map[string]interface{}) map[string]interface{} {
-	query := db.DB.Model(&models.SystemLog{}).Order("created_at DESC")
-
-	if level, ok := args["level"].(string); ok && level != "" {
-		query = query.Where("level = ?", level)
-	}
-	if service, ok := args["service"].(string); ok && service != "" {
-		query = query.Where("service = ?", service)
+// handleWebResearch — LEGO-блок: поиск информации в интернете.
+// Выполняет internet_search по указанной теме, затем загружает текст
+// лучших результатов через browser_get_text. Возвращает сводку.
+// Если browser-service недоступен, возвращает только результаты поиска.
+func handleWebResearch(ctx context.Context, args map[string]interface{}) map[string]interface{} {
+	topic, _ := args["topic"].(string)
+	if topic == "" {
+		return map[string]interface{}{"error": "topic обязателен"}
 	}
 
-	limit := 20
-	if l, ok := args["limit"].(float64); ok && l > 0 {
-		limit = int(l)
+	maxSources := 3
+	if m, ok := args["max_sources"].(float64); ok && m > 0 {
+		maxSources = int(m)
 	}
-	query = query.Limit(limit)
-
-	var logs []models.SystemLog
-	query.Find(&logs)
 
-	var entries []map[string]interface{}
-	for _, l := range logs {
-		entries = append(entries, map[string]interface{}{
-			"id":       l.ID,
-			"level":    l.Level,
-			"service":  l.Service,
-			"message":  l.Message,
-			"details":  l.Details,
-			"resolved": l.Resolved,
-			"time":     l.CreatedAt.Format("2006-01-02 15:04:05"),
-		})
+	report := map[string]interface{}{
+		"topic": topic,
 	}
 
-	return map[string]interface{}{
-		"count": len(entries),
-		"logs":  entries,
+	// Шаг 1: Поиск в интернете через browser-service
+	searchResult, err := callToolCtx(ctx, "internet_search", map[string]interface{}{"query": topic})
+	if err != nil {
+		// Если browser-service недоступен, пробуем через execute + curl
+		fallbackResult, fallbackErr := callToolCtx(ctx, "execute", map[string]interface{}{
+			"command": fmt.Sprintf("curl -s 'https://api.duckduckgo.com/?q=%s&format=json&no_html=1' 2>/dev/null | head -c 2000", topic),
+		})
+		if fallbackErr != nil {
+			return map[string]interface{}{"error": "Поиск недоступен: " + err.Error()}
+		}
+		report["search_fallback"] = fallbackResult
+		report["success"] = true
+		report["message"] = "Выполнен поиск через DuckDuckGo API (browser-service недоступен)"
+		return report
 	}
-}
 
-// ============================================================================
-// Составные скилы-подстраховки (compound skill handlers)
-// Каждый обработчик выполняет цепочку базовых инструментов за один вызов.
-// Умная модель (7B+) предпочтёт базовые инструменты и сама построит цепочку.
-// Слабая модель (3B) вызовет один составной скил и получит готовый результат.
-// ============================================================================
+	report["search_results"] = searchResult
 
-// handleSetupGitAutomation — составной скил: полная git-автоматизация проекта.
-// Выполняет цепочку: mkdir → git init → создание autocommit.sh → создание backup.sh → добавление в crontab.
-// Все шаги выполняются последовательно через callTool("execute", ...).
-func handleSetupGitAutomation(args map[string]interface{}) map[string]interface{} {
-	projectPath, _ := args["project_path"].(string)
-	backupPath, _ := args["backup_path"].(string)
-	if projectPath == "" || backupPath == "" {
-		return map[string]interface{}{"error": "project_path и backup_path обязательны"}
+	// Шаг 2: Загружаем текст с лучших результатов (если есть URL-ы)
+	var sources []map[string]interface{}
+	if results, ok := searchResult["results"].([]interface{}); ok {
+		limit := maxSources
+		if len(results) < limit {
+			limit = len(results)
+		}
+		for i := 0; i < limit; i++ {
+			if item, ok := results[i].(map[string]interface{}); ok {
+				if url, ok := item["url"].(string); ok && url != "" {
+					text, textErr := callToolCtx(ctx, "browser_get_text", map[string]interface{}{"url": url})
+					source := map[string]interface{}{
+						"url":   url,
+						"title": item["title"],
+					}
+					if textErr == nil {
+						source["content"] = text
+					} else {
+						source["error"] = textErr.Error()
+					}
+					sources = append(sources, source)
+				}
+			}
+		}
 	}
 
-	autocommitMin := 30
-	if m, ok := args["autocommit_minutes"].(float64); ok && m > 0 {
-		autocommitMin = int(m)
-	}
-	backupSchedule := "0 0 * * *"
-	if s, ok := args["backup_schedule"].(string); ok && s != "" {
-		backupSchedule = s
+	if len(sources) > 0 {
+		report["sources"] = sources
 	}
 
-	var steps []map[string]interface{}
-
-	// Шаг 1: Создание директорий
-	r1, _ := callTool("execute", map[string]interface{}{"command": fmt.Sprintf("mkdir -p %s %s", projectPath, backupPath)})
-	steps = append(steps, map[string]interface{}{"step": "mkdir", "result": r1})
-
-	// Шаг 2: Инициализация git
-	r2, _ := callTool("execute", map[string]interface{}{"command": fmt.Sprintf("cd %s && git init && git config user.email 'admin@openclaw.local' && git config user.name 'OpenClaw Admin'", projectPath)})
-	steps = append(steps, map[string]interface{}{"step": "git_init", "result": r2})
-
-	// Шаг 3: Создание autocommit.sh
-	autocommitScript := fmt.Sprintf("#!/bin/bash\n# Автоматический коммит всех изменений в проекте\n# Создан составным скилом setup_git_automation\ncd %s\ngit add -A\nDATETIME=$(date '+%%Y-%%m-%%d %%H:%%M:%%S')\ngit diff --cached --quiet || git commit -m \"auto-commit: $DATETIME\"\n", projectPath)
-	autocommitPath := projectPath + "/autocommit.sh"
-	r3, _ := callTool("write", map[string]interface{}{"path": autocommitPath, "content": autocommitScript})
-	steps = append(steps, map[string]interface{}{"step": "write_autocommit", "result": r3})
-
-	r3b, _ := callTool("execute", map[string]interface{}{"command": "chmod +x " + autocommitPath})
-	steps = append(steps, map[string]interface{}{"step": "chmod_autocommit", "result": r3b})
+	report["success"] = true
+	report["message"] = fmt.Sprintf("Исследование темы '%s': найдено результатов, загружено %d источников", topic, len(sources))
+	return report
+}
 
-	// Шаг 4: Создание backup.sh
-	backupScript := fmt.Sprintf("#!/bin/bash\n# Резервное копирование проекта\n# Создан составным скилом setup_git_automation\nDATETIME=$(date '+%%Y%%m%%d_%%H%%M%%S')\nmkdir -p %s\ntar -czf %s/backup_${DATETIME}.tar.gz -C %s .\necho \"Бэкап создан: %s/backup_${DATETIME}.tar.gz\"\n", backupPath, backupPath, projectPath, backupPath)
-	backupScriptPath := projectPath + "/backup.sh"
-	r4, _ := callTool("write", map[string]interface{}{"path": backupScriptPath, "content": backupScript})
-	steps = append(steps, map[string]interface{}{"step": "write_backup", "result": r4})
+// handleCheckResourcesBatch — LEGO-блок: проверка доступности нескольких URL.
+// Для каждого URL выполняет check_url_access через tools-service.
+// Возвращает сводную таблицу доступности всех ресурсов.
+func handleCheckResourcesBatch(ctx context.Context, args map[string]interface{}) map[string]interface{} {
+	urlsRaw, ok := args["urls"]
+	if !ok {
+		return map[string]interface{}{"error": "urls обязателен"}
+	}
 
-	r4b, _ := callTool("execute", map[string]interface{}{"command": "chmod +x " + backupScriptPath})
-	steps = append(steps, map[string]interface{}{"step": "chmod_backup", "result": r4b})
+	var urls []string
+	switch v := urlsRaw.(type) {
+	case []interface{}:
+		for _, u := range v {
+			if s, ok := u.(string); ok {
+				urls = append(urls, s)
+			}
+		}
+	case []string:
+		urls = v
+	default:
+		return map[string]interface{}{"error": "urls должен быть массивом строк"}
+	}
 
-	// Шаг 5: Добавление в crontab
-	cronCmd := fmt.Sprintf("(crontab -l 2>/dev/null; echo '*/%d * * * * %s'; echo '%s %s') | sort -u | crontab -", autocommitMin, autocommitPath, backupSchedule, backupScriptPath)
-	r5, _ := callTool("execute", map[string]interface{}{"command": cronCmd})
-	steps = append(steps, map[string]interface{}{"step": "crontab", "result": r5})
+	if len(urls) == 0 {
+		return map[string]interface{}{"error": "urls пуст"}
+	}
 
-	// Шаг 6: Первый коммит
-	r6, _ := callTool("execute", map[string]interface{}{"command": fmt.Sprintf("cd %s && git add -A && git commit -m 'init: проект создан с автоматизацией'", projectPath)})
-	steps = append(steps, map[string]interface{}{"step": "initial_commit", "result": r6})
+	var results []map[string]interface{}
+	accessible := 0
+	failed := 0
 
-	// Шаг 7: Проверка crontab
-	r7, _ := callTool("execute", map[string]interface{}{"command": "crontab -l"})
-	steps = append(steps, map[string]interface{}{"step": "verify_crontab", "result": r7})
+	for _, url := range urls {
+		r, err := callToolCtx(ctx, "check_url_access", map[string]interface{}{"url": url})
+		entry := map[string]interface{}{
+			"url": url,
+		}
+		if err != nil {
+			entry["status"] = "ошибка"
+			entry["error"] = err.Error()
+			failed++
+		} else {
+			entry["result"] = r
+			// Определяем доступность по результату
+			if errMsg, hasErr := r["error"]; hasErr && errMsg != nil {
+				entry["status"] = "недоступен"
+				failed++
+			} else {
+				entry["status"] = "доступен"
+				accessible++
+			}
+		}
+		results = append(results, entry)
+	}
 
 	return map[string]interface{}{
-		"success":     true,
-		"message":     fmt.Sprintf("Git-автоматизация настроена: проект %s, бэкапы в %s, автокоммит каждые %d мин, бэкап по расписанию %s", projectPath, backupPath, autocommitMin, backupSchedule),
-		"steps_count": len(steps),
-		"steps":       steps,
+		"success":    true,
+		"message":    fmt.Sprintf("Проверено %d URL: %d доступно, %d недоступно", len(urls), accessible, failed),
+		"accessible": accessible,
+		"failed":     failed,
+		"results":    results,
 	}
 }
 
-// handleFullSystemReport — составной скил: полный отчёт о системе.
-// Собирает данные из sysinfo + sysload + cputemp + df + free + uname за один вызов.
-func handleFullSystemReport() map[string]interface{} {
-	report := make(map[string]interface{})
+// handleGenerateReport — LEGO-блок: создание текстового отчёта с верификацией.
+// Выполняет: 1) mkdir -p для директории, 2) write содержимого в файл,
+// 3) read для проверки записи, 4) stat для проверки размера файла.
+// Гарантирует что файл создан и содержит данные.
+func handleGenerateReport(ctx context.Context, args map[string]interface{}) map[string]interface{} {
+	path, _ := args["path"].(string)
+	content, _ := args["content"].(string)
+	title, _ := args["title"].(string)
 
-	if r, err := callTool("sysinfo", map[string]interface{}{}); err == nil {
-		report["sysinfo"] = r
-	}
-	if r, err := callTool("sysload", map[string]interface{}{}); err == nil {
-		report["sysload"] = r
+	if path == "" || content == "" {
+		return map[string]interface{}{"error": "path и content обязательны"}
 	}
-	if r, err := callTool("cputemp", map[string]interface{}{}); err == nil {
-		report["cputemp"] = r
+
+	// Формируем полное содержимое отчёта с заголовком
+	fullContent := content
+	if title != "" {
+		fullContent = fmt.Sprintf("=== %s ===\nДата: $(date)\n\n%s", title, content)
 	}
-	if r, err := callTool("execute", map[string]interface{}{"command": "df -h"}); err == nil {
-		report["disk"] = r
+
+	// Шаг 1: Создаём директорию если нужно
+	dir := path[:strings.LastIndex(path, "/")]
+	if dir != "" {
+		callToolCtx(ctx, "execute", map[string]interface{}{"command": "mkdir -p " + dir})
 	}
-	if r, err := callTool("execute", map[string]interface{}{"command": "free -m"}); err == nil {
-		report["memory"] = r
+
+	// Шаг 2: Записываем файл
+	writeResult, err := callToolCtx(ctx, "write", map[string]interface{}{"path": path, "content": fullContent})
+	if err != nil {
+		return map[string]interface{}{"error": "Ошибка записи отчёта: " + err.Error()}
 	}
-	if r, err := callTool("execute", map[string]interface{}{"command": "uname -a"}); err == nil {
-		report["kernel"] = r
+
+	// Шаг 3: Читаем обратно для верификации
+	readResult, err := callToolCtx(ctx, "read", map[string]interface{}{"path": path})
+	if err != nil {
+		return map[string]interface{}{
+			"success": false,
+			"message": "Файл записан, но не удалось прочитать для проверки",
+			"write":   writeResult,
+			"error":   err.Error(),
+		}
 	}
 
-	report["success"] = true
-	report["message"] = "Полный системный отчёт собран"
-	return report
+	// Шаг 4: Проверяем размер файла
+	statResult, _ := callToolCtx(ctx, "execute", map[string]interface{}{
+		"command": fmt.Sprintf("stat -c '%%s байт' '%s' 2>/dev/null || wc -c < '%s'", path, path),
+	})
+
+	return map[string]interface{}{
+		"success":   true,
+		"message":   fmt.Sprintf("Отчёт записан в %s и проверен", path),
+		"path":      path,
+		"write":     writeResult,
+		"verified":  readResult,
+		"file_size": statResult,
+	}
 }
 
-// handleRunCommands — составной скил: последовательное выполнение нескольких bash-команд.
-// Принимает массив команд, выполняет каждую через callTool("execute") и собирает результаты.
-func handleRunCommands(args map[string]interface{}) map[string]interface{} {
-	commandsRaw, ok := args["commands"]
+// handleInstallPackages — LEGO-блок: установка пакетов через менеджер пакетов.
+// Поддерживает apt, npm, pip. Выполняет установку + проверку версий после.
+// Для apt автоматически добавляет sudo и -y флаг.
+func handleInstallPackages(ctx context.Context, args map[string]interface{}) map[string]interface{} {
+	packagesRaw, ok := args["packages"]
 	if !ok {
-		return map[string]interface{}{"error": "commands обязателен"}
+		return map[string]interface{}{"error": "packages обязателен"}
 	}
 
-	var commands []string
-	switch v := commandsRaw.(type) {
+	var packages []string
+	switch v := packagesRaw.(type) {
 	case []interface{}:
-		for _, c := range v {
-			if s, ok := c.(string); ok {
-				commands = append(commands, s)
+		for _, p := range v {
+			if s, ok := p.(string); ok {
+				packages = append(packages, s)
 			}
 		}
 	case []string:
-		commands = v
+		packages = v
 	default:
-		return map[string]interface{}{"error": "commands должен быть массивом строк"}
+		return map[string]interface{}{"error": "packages должен быть массивом строк"}
 	}
 
-	if len(commands) == 0 {
-		return map[string]interface{}{"error": "commands пуст"}
+	if len(packages) == 0 {
+		return map[string]interface{}{"error": "packages пуст"}
 	}
 
-	var results []map[string]interface{}
-	allOk := true
-	for i, cmd := range commands {
-		r, err := callTool("execute", map[string]interface{}{"command": cmd})
-		entry := map[string]interface{}{
-			"index":   i,
-			"command": cmd,
+	manager, _ := args["manager"].(string)
+	if manager == "" {
+		manager = "apt"
+	}
+
+	var installCmd string
+	switch manager {
+	case "apt":
+		installCmd = "sudo apt-get install -y " + strings.Join(packages, " ")
+	case "npm":
+		installCmd = "npm install -g " + strings.Join(packages, " ")
+	case "pip":
+		installCmd = "pip3 install " + strings.Join(packages, " ")
+	default:
+		return map[string]interface{}{"error": fmt.Sprintf("Неизвестный менеджер пакетов: %s (поддерживаются: apt, npm, pip)", manager)}
+	}
+
+	// Шаг 1: Обновляем индекс (только для apt)
+	var steps []map[string]interface{}
+	if manager == "apt" {
+		updateResult, _ := callToolCtx(ctx, "execute", map[string]interface{}{"command": "sudo apt-get update -qq"})
+		steps = append(steps, map[string]interface{}{"step": "update_index", "result": updateResult})
+	}
+
+	// Шаг 2: Устанавливаем пакеты
+	installResult, err := callToolCtx(ctx, "execute", map[string]interface{}{"command": installCmd})
+	if err != nil {
+		return map[string]interface{}{
+			"error":   "Ошибка установки: " + err.Error(),
+			"command": installCmd,
+			"steps":   steps,
 		}
-		if err != nil {
-			entry["error"] = err.Error()
-			allOk = false
-		} else {
-			entry["result"] = r
+	}
+	steps = append(steps, map[string]interface{}{"step": "install", "command": installCmd, "result": installResult})
+
+	// Шаг 3: Проверяем версии установленных пакетов
+	for _, pkg := range packages {
+		var verifyCmd string
+		switch manager {
+		case "apt":
+			verifyCmd = fmt.Sprintf("dpkg -l %s 2>/dev/null | tail -1 || echo 'не найден'", pkg)
+		case "npm":
+			verifyCmd = fmt.Sprintf("npm list -g %s 2>/dev/null | tail -1 || echo 'не найден'", pkg)
+		case "pip":
+			verifyCmd = fmt.Sprintf("pip3 show %s 2>/dev/null | grep Version || echo 'не найден'", pkg)
 		}
-		results = append(results, entry)
+		verifyResult, _ := callToolCtx(ctx, "execute", map[string]interface{}{"command": verifyCmd})
+		steps = append(steps, map[string]interface{}{"step": "verify_" + pkg, "result": verifyResult})
 	}
 
 	return map[string]interface{}{
-		"success":  allOk,
-		"message":  fmt.Sprintf("Выполнено %d команд", len(results)),
-		"commands": results,
+		"success":  true,
+		"message":  fmt.Sprintf("Установлено %d пакетов через %s", len(packages), manager),
+		"manager":  manager,
+		"packages": packages,
+		"steps":    steps,
 	}
 }
 
-// handleCreateScript — составной скил: создание исполняемого bash-скрипта.
-// Записывает содержимое в файл и делает chmod +x за один вызов.
-func handleCreateScript(args map[string]interface{}) map[string]interface{} {
-	path, _ := args["path"].(string)
-	content, _ := args["content"].(string)
-	if path == "" || content == "" {
-		return map[string]interface{}{"error": "path и content обязательны"}
+// initProvidersFromDB — загрузка конфигурации облачных провайдеров из PostgreSQL.
+// Вызывается при старте сервиса после инициализации БД.
+// Для каждого включённого провайдера регистрирует его в глобальном реестре
+// с API-ключом, базовым URL и дополнительными параметрами (folder_id/scope).
+// Это позволяет сохранять настройки провайдеров между перезапусками сервиса.
+func autoskillPatternsHandler(w http.ResponseWriter, r *http.Request) {
+	if autoSkillPipeline == nil {
+		apierror.ServiceUnavailable(w, r.Header.Get("X-Request-ID"), "Конвейер автоскиллов не инициализирован", "Дождитесь завершения запуска сервиса")
+		return
 	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(autoSkillPipeline.ListPatterns())
+}
 
-	// Создаём директорию если нужно
-	dir := path[:strings.LastIndex(path, "/")]
-	if dir != "" {
-		callTool("execute", map[string]interface{}{"command": "mkdir -p " + dir})
+func autoskillCandidatesHandler(w http.ResponseWriter, r *http.Request) {
+	if autoSkillPipeline == nil {
+		apierror.ServiceUnavailable(w, r.Header.Get("X-Request-ID"), "Конвейер автоскиллов не инициализирован", "Дождитесь завершения запуска сервиса")
+		return
 	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(autoSkillPipeline.ListCandidates())
+}
 
-	// Записываем файл
-	writeResult, err := callTool("write", map[string]interface{}{"path": path, "content": content})
-	if err != nil {
-		return map[string]interface{}{"error": "Ошибка записи: " + err.Error()}
+func autoskillPromoteHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		apierror.MethodNotAllowed(w, r.Header.Get("X-Request-ID"))
+		return
+	}
+	if autoSkillPipeline == nil {
+		apierror.ServiceUnavailable(w, r.Header.Get("X-Request-ID"), "Конвейер автоскиллов не инициализирован", "Дождитесь завершения запуска сервиса")
+		return
 	}
+	promoted := autoSkillPipeline.PromoteCandidates()
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"promoted": promoted, "count": len(promoted)})
+}
 
-	// Делаем исполняемым
-	chmodResult, err := callTool("execute", map[string]interface{}{"command": "chmod +x " + path})
-	if err != nil {
-		return map[string]interface{}{"error": "Ошибка chmod: " + err.Error()}
+func autoskillRollbackHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		apierror.MethodNotAllowed(w, r.Header.Get("X-Request-ID"))
+		return
+	}
+	intentName := r.URL.Query().Get("intent")
+	if intentName == "" {
+		apierror.BadRequest(w, r.Header.Get("X-Request-ID"), "Параметр intent обязателен", "Передайте ?intent=<имя интента>")
+		return
+	}
+	if autoSkillPipeline == nil {
+		apierror.ServiceUnavailable(w, r.Header.Get("X-Request-ID"), "Конвейер автоскиллов не инициализирован", "Дождитесь завершения запуска сервиса")
+		return
+	}
+	if err := autoSkillPipeline.Rollback(intentName); err != nil {
+		apierror.NotFound(w, r.Header.Get("X-Request-ID"), err.Error())
+		return
 	}
+	w.Header().Set("Content-Type", "application/json")
+	fmt.Fprintf(w, `{"status":"rolled_back","intent":%q}`, intentName)
+}
 
-	return map[string]interface{}{
-		"success": true,
-		"message": fmt.Sprintf("Скрипт создан: %s (chmod +x)", path),
-		"write":   writeResult,
-		"chmod":   chmodResult,
+func initProvidersFromDB() {
+	var configs []models.ProviderConfig
+	db.DB.Where("enabled = ?", true).Find(&configs)
+	for _, cfg := range configs {
+		extra := cfg.FolderID
+		if cfg.Scope != "" {
+			extra = cfg.Scope
+		}
+		if err := llm.RegisterProvider(cfg.ProviderName, cfg.APIKey, cfg.BaseURL, extra, cfg.ServiceAccountJSON); err != nil {
+			slog.Error("Не удалось зарегистрировать провайдер из БД", slog.String("провайдер", cfg.ProviderName), slog.String("ошибка", err.Error()))
+		}
 	}
 }
 
-// handleSetupCronJob — составной скил: добавление задачи в crontab.
-// Безопасно добавляет запись, не затирая существующие.
-func handleSetupCronJob(args map[string]interface{}) map[string]interface{} {
-	schedule, _ := args["schedule"].(string)
-	command, _ := args["command"].(string)
-	if schedule == "" || command == "" {
-		return map[string]interface{}{"error": "schedule и command обязательны"}
+// main — точка входа agent-service.
+//
+// Порядок инициализации:
+//  0. Загрузка и проверка конфигурации (validateEnv, config.Load)
+//  1. Подключение к PostgreSQL и миграции (db.InitDB)
+//  2. Инициализация локального провайдера Ollama (llm.InitProviders)
+//  3. Загрузка конфигурации облачных провайдеров из БД (initProvidersFromDB)
+//  4. Создание агента Admin по умолчанию, если его нет
+//  5. Инициализация метрик OpenTelemetry
+//  6. Регистрация HTTP-обработчиков для всех эндпоинтов
+//  7. Настройка раздачи статических файлов из uploads/
+//  8. Запуск HTTP-сервера на порту AGENT_SERVICE_PORT (по умолчанию 8083)
+func validateEnv() {
+	slog.Info("Проверка конфигурации")
+
+	cfg, err := config.Load()
+	if err == nil {
+		err = cfg.Validate()
+	}
+	if err != nil {
+		slog.Error("Некорректная конфигурация", slog.String("ошибка", err.Error()))
+		os.Exit(1)
+	}
+	config.Set(cfg)
+	i18n.SetDefault(cfg.DefaultLanguage)
+	timeouts, _ := httpclient.ParseTimeouts(cfg.HTTPClientTimeouts)
+	httpclient.Configure(cfg.HTTPMaxIdleConnsPerHost, cfg.HTTPIdleConnTimeout, timeouts)
+	// Клиент создан до Configure — пересоздаём, чтобы учесть HTTP_CLIENT_TIMEOUTS
+	memoryClient = httpclient.New("memory", 30*time.Second)
+	config.OnReload(func(c *config.Config) { i18n.SetDefault(c.DefaultLanguage) })
+
+	if cfg.DBDriver == config.DriverSQLite {
+		slog.Info("Хранилище SQLite (однопользовательский режим)", slog.String("файл", cfg.SQLitePath))
+	} else if os.Getenv("DATABASE_URL") == "" && os.Getenv("DB_HOST") == "" {
+		slog.Info("DATABASE_URL и DB_HOST не заданы, используются значения по умолчанию")
+		slog.Info("Для настройки см. .env.example или документацию")
 	}
+	slog.Info("Порт agent-service", slog.String("порт", cfg.Port))
+	slog.Info("tools-service URL", slog.String("url", cfg.ToolsServiceURL))
+	slog.Info("memory-service URL", slog.String("url", cfg.MemoryServiceURL))
+	slog.Info("Ollama URL", slog.String("url", cfg.OllamaURL))
 
-	cronEntry := schedule + " " + command
-	addCmd := fmt.Sprintf("(crontab -l 2>/dev/null; echo '%s') | sort -u | crontab -", cronEntry)
+	slog.Info("Проверка конфигурации завершена")
+}
+
+var autoSkillPipeline *skills.AutoSkillPipeline
+
+// intentRegistry — интенты, обрабатываемые до вызова LLM (встроенные и из INTENTS_FILE).
+var intentRegistry = intent.NewRegistry()
 
-	result, err := callTool("execute", map[string]interface{}{"command": addCmd})
+// riskEngine — правила оценки риска вызовов инструментов (встроенные и из RISK_RULES_FILE).
+var riskEngine, _ = risk.NewEngine()
+
+// riskApprovals — запросы подтверждения разрушительных действий и ответы пользователя.
+var riskApprovals = risk.NewApprovals()
+
+// approvalScopeKey — ключ контекста с областью подтверждений запроса (risk.Scope).
+type approvalScopeKey struct{}
+
+// approvalScopeFrom — область подтверждений из контекста ("" — вне диалога и сессии).
+func approvalScopeFrom(ctx context.Context) string {
+	scope, _ := ctx.Value(approvalScopeKey{}).(string)
+	return scope
+}
+
+// clarifications — уточняющие вопросы агентов (ask_user), ожидающие ответа пользователя.
+var clarifications = clarify.NewStore()
+
+// initRisk — добавляет к встроенным правилам риска пользовательские из RISK_RULES_FILE.
+// Ошибка в файле не останавливает сервис: действуют встроенные правила.
+func initRisk() {
+	path := config.Current().RiskRulesFile
+	if path == "" {
+		return
+	}
+	rules, err := risk.LoadRules(path)
+	if err == nil {
+		var engine *risk.Engine
+		if engine, err = risk.NewEngine(rules...); err == nil {
+			riskEngine = engine
+		}
+	}
 	if err != nil {
-		return map[string]interface{}{"error": "Ошибка добавления в crontab: " + err.Error()}
+		slog.Error("Не удалось загрузить правила риска", slog.String("файл", path), slog.String("ошибка", err.Error()))
+		return
 	}
+	slog.Info("Пользовательские правила риска загружены", slog.String("файл", path), slog.Int("количество", len(rules)))
+}
 
-	// Проверяем что добавилось
-	verify, _ := callTool("execute", map[string]interface{}{"command": "crontab -l"})
+// recipes — установленные составные скилы: рецепты из RECIPES_DIR и активные
+// версии импортированных через /skills/compound.
+var recipes = recipe.NewRegistry()
 
-	return map[string]interface{}{
-		"success": true,
-		"message": fmt.Sprintf("Задача добавлена в crontab: %s", cronEntry),
-		"result":  result,
-		"crontab": verify,
+// builtinToolNames — встроенные инструменты: рецепт не может занять их имя,
+// а шаги импортированного рецепта вызывают только их.
+func builtinToolNames() map[string]bool {
+	builtin := map[string]bool{}
+	for _, group := range [][]llm.Tool{tools.GetAllTools(), tools.GetSmartHomeTools(), tools.GetKubeTools(), tools.GetArtifactTools(), tools.GetClarifyTools()} {
+		for _, t := range group {
+			builtin[t.Function.Name] = true
+		}
 	}
+	return builtin
 }
 
-// handleProjectInit — составной скил: инициализация нового проекта.
-// Создаёт директорию, README.md, .gitignore и инициализирует git.
-func handleProjectInit(args map[string]interface{}) map[string]interface{} {
-	path, _ := args["path"].(string)
-	name, _ := args["name"].(string)
-	if path == "" || name == "" {
-		return map[string]interface{}{"error": "path и name обязательны"}
+// initRecipes — загружает рецепты составных скилов при запуске.
+func initRecipes() {
+	if n := reloadRecipes(); n > 0 {
+		slog.Info("Рецепты составных скилов загружены", slog.String("каталог", config.Current().RecipesDir), slog.Int("количество", n))
 	}
-	desc, _ := args["description"].(string)
-	if desc == "" {
-		desc = "Проект " + name
+}
+
+// reloadRecipes — перечитывает рецепты RECIPES_DIR, активные версии
+// импортированных скилов и переключатели агентов. Рецепт с именем встроенного
+// инструмента пропускается, файловый рецепт важнее импортированного с тем же
+// именем; ошибка в одном рецепте не мешает остальным. Возвращает число рецептов.
+func reloadRecipes() int {
+	builtin := builtinToolNames()
+	taken := map[string]bool{}
+	var list []*recipe.Recipe
+	if dir := config.Current().RecipesDir; dir != "" {
+		files, err := recipe.Load(dir)
+		if err != nil {
+			slog.Error("Часть рецептов не загружена", slog.String("каталог", dir), slog.String("ошибка", err.Error()))
+		}
+		for _, r := range files {
+			if builtin[r.Name] {
+				slog.Warn("Рецепт совпадает по имени со встроенным инструментом и пропущен", slog.String("рецепт", r.Name), slog.String("файл", r.File))
+				continue
+			}
+			taken[r.Name] = true
+			list = append(list, r)
+		}
 	}
 
-	var steps []map[string]interface{}
+	var rows []models.CompoundSkill
+	if err := db.DB.Where("active = ?", true).Find(&rows).Error; err != nil {
+		slog.Error("Не удалось прочитать импортированные составные скилы", slog.String("ошибка", err.Error()))
+	}
+	for _, row := range rows {
+		r, err := recipe.Parse([]byte(row.Spec))
+		if err != nil {
+			slog.Error("Импортированный рецепт не загружен", slog.String("рецепт", row.Name), slog.Int("версия", row.Version), slog.String("ошибка", err.Error()))
+			continue
+		}
+		if builtin[r.Name] || taken[r.Name] {
+			slog.Warn("Импортированный рецепт перекрыт встроенным инструментом или файлом RECIPES_DIR", slog.String("рецепт", r.Name))
+			continue
+		}
+		r.Source, r.Revision = recipe.SourceImport, row.Version
+		taken[r.Name] = true
+		list = append(list, r)
+	}
 
-	// Создание директории
-	r1, _ := callTool("execute", map[string]interface{}{"command": "mkdir -p " + path})
-	steps = append(steps, map[string]interface{}{"step": "mkdir", "result": r1})
+	var switches []models.CompoundSkillAgent
+	db.DB.Find(&switches)
+	toggles := map[string]map[string]bool{}
+	for _, s := range switches {
+		if toggles[s.Name] == nil {
+			toggles[s.Name] = map[string]bool{}
+		}
+		toggles[s.Name][s.Agent] = s.Enabled
+	}
+	recipes.Replace(list, toggles)
+	return len(list)
+}
 
-	// Создание README.md
-	readme := fmt.Sprintf("# %s\n\n%s\n\nСоздан: %s\n", name, desc, "$(date)")
-	r2, _ := callTool("write", map[string]interface{}{"path": path + "/README.md", "content": readme})
-	steps = append(steps, map[string]interface{}{"step": "readme", "result": r2})
+// recipeTools — рецепты, доступные агенту, в виде инструментов модели.
+func recipeTools(agentName string) []llm.Tool {
+	list := recipes.ForAgent(agentName)
+	out := make([]llm.Tool, len(list))
+	for i, r := range list {
+		out[i] = r.Tool()
+	}
+	return out
+}
 
-	// Создание .gitignore
-	gitignore := "*.log\n*.tmp\n*.swp\n.env\nnode_modules/\n__pycache__/\n.DS_Store\n"
-	r3, _ := callTool("write", map[string]interface{}{"path": path + "/.gitignore", "content": gitignore})
-	steps = append(steps, map[string]interface{}{"step": "gitignore", "result": r3})
+// runRecipe — выполняет рецепт: шаги идут через dispatchTool, как вызовы
+// модели. Разрушительный шаг не выполняется без RISK_AUTO_APPROVE — такое
+// действие модель должна вызвать сама, чтобы пользователь его подтвердил.
+func runRecipe(ctx context.Context, agentName string, r *recipe.Recipe, args map[string]interface{}) map[string]interface{} {
+	return r.Run(ctx, args, func(ctx context.Context, tool string, stepArgs map[string]interface{}) map[string]interface{} {
+		if _, nested := recipes.Get(tool); nested {
+			return map[string]interface{}{"error": "рецепт не может вызывать другой рецепт (" + tool + ")"}
+		}
+		if a := riskEngine.Classify(tool, stepArgs); a.Level == risk.LevelDestructive && !config.Current().RiskAutoApprove {
+			return map[string]interface{}{"error": "разрушительный шаг не выполнен: " + a.Reason + ". Вызови " + tool + " напрямую, чтобы пользователь подтвердил действие"}
+		}
+		result := dispatchTool(ctx, agentName, tool, stepArgs, nil)
+		status, errText := taskreport.ActionStatus(result)
+		timelineFrom(ctx).Add(timeline.Event{Type: timeline.EventToolCall, Tool: tool, Status: status, Detail: strings.TrimSpace("шаг рецепта " + r.Name + " " + errText)})
+		return result
+	})
+}
 
-	// Инициализация git
-	r4, _ := callTool("execute", map[string]interface{}{"command": fmt.Sprintf("cd %s && git init && git config user.email 'admin@openclaw.local' && git config user.name 'OpenClaw Admin' && git add -A && git commit -m 'init: %s'", path, name)})
-	steps = append(steps, map[string]interface{}{"step": "git_init", "result": r4})
+// compoundSkillView — составной скил в ответах /skills/compound.
+type compoundSkillView struct {
+	*recipe.Recipe
+	Tools        []string               `json:"tools"`                   // Инструменты шагов по порядку
+	AgentToggles map[string]bool        `json:"agent_toggles,omitempty"` // Переключатели агентов поверх agents
+	Spec         string                 `json:"spec,omitempty"`          // Текст активной версии (импортированные)
+	Versions     []models.CompoundSkill `json:"versions,omitempty"`      // История версий (импортированные)
+}
 
-	return map[string]interface{}{
-		"success": true,
-		"message": fmt.Sprintf("Проект '%s' инициализирован в %s (git, README, .gitignore)", name, path),
-		"steps":   steps,
+func newCompoundSkillView(r *recipe.Recipe) compoundSkillView {
+	v := compoundSkillView{Recipe: r, AgentToggles: recipes.Toggles(r.Name)}
+	for _, s := range r.Steps {
+		v.Tools = append(v.Tools, s.Tool)
 	}
+	return v
 }
 
-// ============================================================================
-// НОВЫЕ УНИВЕРСАЛЬНЫЕ LEGO-БЛОКИ (обработчики)
-// Каждый обработчик выполняет цепочку базовых инструментов за один вызов.
-// Умная модель (7B+) предпочтёт базовые инструменты и сама построит цепочку.
-// Слабая модель (3B) вызовет один составной скил и получит готовый результат.
-// ============================================================================
+// CompoundSkillActivateRequest — тело POST /skills/compound/{name}/activate.
+type CompoundSkillActivateRequest struct {
+	Version int `json:"version"`
+}
 
-// handleCheckStack — LEGO-блок: проверка установленных версий программ.
-// Для каждой программы из списка выполняет команду определения версии
-// и собирает результаты в единый отчёт. Поддерживает: go, node, npm,
-// python3, psql, docker, git, nginx, redis-server, curl, wget и любые другие.
-func handleCheckStack(args map[string]interface{}) map[string]interface{} {
-	programsRaw, ok := args["programs"]
-	if !ok {
-		return map[string]interface{}{"error": "programs обязателен"}
-	}
+// CompoundSkillAgentRequest — тело PUT /skills/compound/{name}/agents:
+// enabled: null снимает переключатель, и снова действует список agents рецепта.
+type CompoundSkillAgentRequest struct {
+	Agent   string `json:"agent"`
+	Enabled *bool  `json:"enabled"`
+}
 
-	var programs []string
-	switch v := programsRaw.(type) {
-	case []interface{}:
-		for _, p := range v {
-			if s, ok := p.(string); ok {
-				programs = append(programs, s)
+// compoundSkillsHandler — установленные составные скилы (рецепты, пакет recipe).
+//
+//	GET    /skills/compound                 — рецепты RECIPES_DIR и импортированные
+//	POST   /skills/compound?url=&dry_run=1  — импорт рецепта из тела (YAML) или по URL
+//	GET    /skills/compound/{name}          — рецепт, текст и история версий
+//	DELETE /skills/compound/{name}          — удалить импортированный скил со всеми версиями
+//	POST   /skills/compound/{name}/activate — сделать активной версию {"version": N} (откат)
+//	PUT    /skills/compound/{name}/agents   — включить или отключить для агента {"agent", "enabled"}
+//
+// Импорт проверяет рецепт как при загрузке из RECIPES_DIR, а также неизвестные
+// ключи YAML и инструменты шагов. Имя встроенного инструмента или файлового
+// рецепта занято (409). Повторный импорт того же имени создаёт новую версию.
+func compoundSkillsHandler(w http.ResponseWriter, r *http.Request) {
+	cid := r.Header.Get("X-Request-ID")
+	rest := strings.Trim(strings.TrimPrefix(r.URL.Path, "/skills/compound"), "/")
+	if rest == "" {
+		switch r.Method {
+		case http.MethodGet:
+			list := recipes.All()
+			out := make([]compoundSkillView, len(list))
+			for i, rc := range list {
+				out[i] = newCompoundSkillView(rc)
 			}
+			writeJSON(w, out)
+		case http.MethodPost:
+			importCompoundSkill(w, r, cid)
+		default:
+			apierror.MethodNotAllowed(w, cid)
 		}
-	case []string:
-		programs = v
-	default:
-		return map[string]interface{}{"error": "programs должен быть массивом строк"}
+		return
 	}
 
-	if len(programs) == 0 {
-		return map[string]interface{}{"error": "programs пуст"}
+	name, action, _ := strings.Cut(rest, "/")
+	var versions []models.CompoundSkill
+	if err := db.DB.Where("name = ?", name).Order("version desc").Find(&versions).Error; err != nil {
+		apierror.InternalError(w, cid, "Не удалось получить версии скила", "")
+		return
 	}
-
-	// Маппинг программа → команда для проверки версии.
-	// Для известных программ используем специфичную команду,
-	// для неизвестных — пробуем --version.
-	versionCommands := map[string]string{
-		"go":           "go version",
-		"node":         "node --version",
-		"npm":          "npm --version",
-		"python3":      "python3 --version",
-		"python":       "python3 --version",
-		"psql":         "psql --version",
-		"docker":       "docker --version",
-		"git":          "git --version",
-		"nginx":        "nginx -v 2>&1",
-		"redis-server": "redis-server --version",
-		"curl":         "curl --version | head -1",
-		"wget":         "wget --version | head -1",
-		"java":         "java -version 2>&1 | head -1",
-		"rustc":        "rustc --version",
-		"cargo":        "cargo --version",
-		"php":          "php --version | head -1",
-		"ruby":         "ruby --version",
-		"pip":          "pip3 --version",
-		"pip3":         "pip3 --version",
-		"gcc":          "gcc --version | head -1",
-		"make":         "make --version | head -1",
-		"systemctl":    "systemctl --version | head -1",
+	current, installed := recipes.Get(name)
+	if !installed && len(versions) == 0 {
+		apierror.NotFound(w, cid, "Составной скил не найден")
+		return
 	}
 
-	var results []map[string]interface{}
-	installed := 0
-	missing := 0
+	switch {
+	case action == "" && r.Method == http.MethodGet:
+		var view compoundSkillView
+		if installed {
+			view = newCompoundSkillView(current)
+		} else {
+			// Импортированный скил перекрыт файлом или не разбирается — показываем историю
+			view = compoundSkillView{Recipe: &recipe.Recipe{Name: name, Source: recipe.SourceImport}}
+		}
+		for _, v := range versions {
+			if v.Active {
+				view.Spec = v.Spec
+			}
+		}
+		view.Versions = versions
+		writeJSON(w, view)
 
-	for _, prog := range programs {
-		cmd, known := versionCommands[prog]
-		if !known {
-			cmd = prog + " --version 2>&1 | head -1"
+	case action == "" && r.Method == http.MethodDelete:
+		if len(versions) == 0 {
+			writeCompoundSkillConflict(w, cid, "Скил загружен из RECIPES_DIR ("+current.File+")", "Удалите файл рецепта и перезапустите сервис")
+			return
+		}
+		err := db.DB.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Where("name = ?", name).Delete(&models.CompoundSkill{}).Error; err != nil {
+				return err
+			}
+			return tx.Where("name = ?", name).Delete(&models.CompoundSkillAgent{}).Error
+		})
+		if err != nil {
+			apierror.InternalError(w, cid, "Не удалось удалить скил", "")
+			return
 		}
+		reloadRecipes()
+		slog.Info("Составной скил удалён", slog.String("скил", name), slog.Int("версий", len(versions)), slog.String("request_id", cid))
+		writeJSON(w, map[string]string{"status": "ok"})
 
-		// Проверяем наличие программы через which + версию
-		checkCmd := fmt.Sprintf("which %s >/dev/null 2>&1 && %s || echo 'НЕ УСТАНОВЛЕНО'", prog, cmd)
-		r, err := callTool("execute", map[string]interface{}{"command": checkCmd})
+	case action == "activate" && r.Method == http.MethodPost:
+		var req CompoundSkillActivateRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			apierror.BadRequest(w, cid, "Невалидный JSON", "Ожидается {\"version\": N}")
+			return
+		}
+		found := false
+		for _, v := range versions {
+			found = found || v.Version == req.Version
+		}
+		if !found {
+			apierror.NotFound(w, cid, fmt.Sprintf("Версия %d скила %s не найдена", req.Version, name))
+			return
+		}
+		if err := activateCompoundSkill(name, req.Version); err != nil {
+			apierror.InternalError(w, cid, "Не удалось переключить версию", "")
+			return
+		}
+		reloadRecipes()
+		slog.Info("Версия составного скила переключена", slog.String("скил", name), slog.Int("версия", req.Version), slog.String("request_id", cid))
+		writeJSON(w, map[string]interface{}{"status": "ok", "name": name, "version": req.Version})
 
-		entry := map[string]interface{}{
-			"program": prog,
+	case action == "agents" && r.Method == http.MethodPut:
+		var req CompoundSkillAgentRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Agent == "" {
+			apierror.BadRequest(w, cid, "Требуется agent", "Ожидается {\"agent\": \"coder\", \"enabled\": false}")
+			return
 		}
-		if err != nil {
-			entry["status"] = "ошибка"
-			entry["error"] = err.Error()
-			missing++
+		if _, err := repository.GetAgentByName(req.Agent); err != nil {
+			apierror.NotFound(w, cid, "Агент не найден: "+req.Agent)
+			return
+		}
+		agent := strings.ToLower(req.Agent)
+		var err error
+		if req.Enabled == nil {
+			err = db.DB.Where("name = ? AND agent = ?", name, agent).Delete(&models.CompoundSkillAgent{}).Error
 		} else {
-			output, _ := r["output"].(string)
-			if strings.Contains(output, "НЕ УСТАНОВЛЕНО") {
-				entry["status"] = "не установлено"
-				missing++
-			} else {
-				entry["status"] = "установлено"
-				entry["version"] = strings.TrimSpace(output)
-				installed++
+			var s models.CompoundSkillAgent
+			if db.DB.Where("name = ? AND agent = ?", name, agent).First(&s).Error != nil {
+				s = models.CompoundSkillAgent{Name: name, Agent: agent}
 			}
+			s.Enabled = *req.Enabled
+			err = db.DB.Save(&s).Error
 		}
-		results = append(results, entry)
-	}
+		if err != nil {
+			apierror.InternalError(w, cid, "Не удалось сохранить переключатель агента", "")
+			return
+		}
+		reloadRecipes()
+		slog.Info("Составной скил переключён для агента", slog.String("скил", name), slog.String("агент", agent), slog.Bool("сброс", req.Enabled == nil), slog.String("request_id", cid))
+		writeJSON(w, map[string]interface{}{"status": "ok", "name": name, "agent": agent, "enabled": recipes.Enabled(name, agent)})
 
-	return map[string]interface{}{
-		"success":   true,
-		"message":   fmt.Sprintf("Проверено %d программ: %d установлено, %d отсутствует", len(programs), installed, missing),
-		"installed": installed,
-		"missing":   missing,
-		"programs":  results,
+	case action == "" || action == "activate" || action == "agents":
+		apierror.MethodNotAllowed(w, cid)
+	default:
+		apierror.NotFound(w, cid, "Неизвестное действие: "+action)
 	}
 }
 
-// handleDiagnoseService — LEGO-блок: диагностика сервиса.
-// Проверяет: 1) занят ли указанный порт, 2) работает ли процесс,
-// 3) HTTP-ответ health_url (если указан), 4) последние строки логов.
-// Возвращает структурированный отчёт о состоянии сервиса.
-func handleDiagnoseService(args map[string]interface{}) map[string]interface{} {
-	serviceName, _ := args["service_name"].(string)
-	port, _ := args["port"].(float64)
-	healthURL, _ := args["health_url"].(string)
-
-	if serviceName == "" || port == 0 {
-		return map[string]interface{}{"error": "service_name и port обязательны"}
-	}
-
-	report := map[string]interface{}{
-		"service": serviceName,
-		"port":    int(port),
+// importCompoundSkill — POST /skills/compound: рецепт из тела запроса или по
+// ?url=; ?dry_run=1 — только проверить.
+func importCompoundSkill(w http.ResponseWriter, r *http.Request, cid string) {
+	q := r.URL.Query()
+	source := "upload"
+	var (
+		data []byte
+		err  error
+	)
+	if u := q.Get("url"); u != "" {
+		source = u
+		data, err = fetchRecipe(r.Context(), u)
+		if err != nil {
+			apierror.BadRequest(w, cid, "Не удалось загрузить рецепт: "+err.Error(), "Нужен доступный http(s)-URL YAML-файла")
+			return
+		}
+	} else if data, err = io.ReadAll(io.LimitReader(r.Body, recipe.MaxBytes+1)); err != nil {
+		apierror.BadRequest(w, cid, "Не удалось прочитать тело запроса", "")
+		return
 	}
 
-	// Шаг 1: Проверяем, занят ли порт (кто слушает)
-	portCheck, err := callTool("execute", map[string]interface{}{
-		"command": fmt.Sprintf("ss -tlnp 2>/dev/null | grep ':%d ' || echo 'порт %d не занят'", int(port), int(port)),
-	})
+	builtin := builtinToolNames()
+	rc, err := recipe.Parse(data)
 	if err == nil {
-		report["port_check"] = portCheck
+		err = rc.CheckTools(func(tool string) bool { return builtin[tool] })
+	}
+	if err != nil {
+		apierror.BadRequest(w, cid, "Некорректный рецепт: "+err.Error(), "Формат — как у файлов RECIPES_DIR, пример в docs/recipes")
+		return
+	}
+	if builtin[rc.Name] {
+		writeCompoundSkillConflict(w, cid, "Имя "+rc.Name+" занято встроенным инструментом", "Переименуйте рецепт")
+		return
+	}
+	if cur, ok := recipes.Get(rc.Name); ok && cur.Source == recipe.SourceFile {
+		writeCompoundSkillConflict(w, cid, "Имя "+rc.Name+" занято рецептом RECIPES_DIR ("+cur.File+")", "Переименуйте рецепт или удалите файл")
+		return
 	}
 
-	// Шаг 2: Проверяем процесс по имени сервиса
-	procCheck, err := callTool("execute", map[string]interface{}{
-		"command": fmt.Sprintf("pgrep -fa '%s' 2>/dev/null || echo 'процесс %s не найден'", serviceName, serviceName),
-	})
-	if err == nil {
-		report["process_check"] = procCheck
+	sum := sha256.Sum256(data)
+	hash := hex.EncodeToString(sum[:])
+	var versions []models.CompoundSkill
+	if err := db.DB.Where("name = ?", rc.Name).Order("version desc").Find(&versions).Error; err != nil {
+		apierror.InternalError(w, cid, "Не удалось получить версии скила", "")
+		return
+	}
+	// Тот же текст уже импортирован — новая версия не нужна, она становится активной
+	status, version := "created", 1
+	if len(versions) > 0 {
+		status, version = "updated", versions[0].Version+1
+	}
+	for _, v := range versions {
+		if v.SHA256 == hash {
+			status, version = "unchanged", v.Version
+			break
+		}
+	}
+	rc.Source, rc.Revision = recipe.SourceImport, version
+	result := map[string]interface{}{"status": status, "version": version, "skill": newCompoundSkillView(rc)}
+	if q.Get("dry_run") == "1" {
+		result["dry_run"] = true
+		writeJSON(w, result)
+		return
 	}
 
-	// Шаг 3: HTTP-проверка здоровья (если указан URL)
-	if healthURL != "" {
-		healthCheck, err := callTool("execute", map[string]interface{}{
-			"command": fmt.Sprintf("curl -s -o /dev/null -w '%%{http_code}' --connect-timeout 3 '%s' 2>/dev/null || echo 'недоступен'", healthURL),
-		})
-		if err == nil {
-			report["health_check"] = healthCheck
+	if status != "unchanged" {
+		row := models.CompoundSkill{Name: rc.Name, Version: version, Source: source, SHA256: hash, Spec: string(data)}
+		if err := db.DB.Create(&row).Error; err != nil {
+			apierror.InternalError(w, cid, "Не удалось сохранить скил", "")
+			return
 		}
 	}
+	if err := activateCompoundSkill(rc.Name, version); err != nil {
+		apierror.InternalError(w, cid, "Не удалось сделать версию активной", "")
+		return
+	}
+	reloadRecipes()
+	slog.Info("Составной скил импортирован",
+		slog.String("скил", rc.Name),
+		slog.Int("версия", version),
+		slog.String("статус", status),
+		slog.String("источник", source),
+		slog.String("request_id", cid))
+	if status != "unchanged" {
+		w.WriteHeader(http.StatusCreated)
+	}
+	writeJSON(w, result)
+}
 
-	// Шаг 4: Проверяем журнал systemd (если сервис системный)
-	journalCheck, err := callTool("execute", map[string]interface{}{
-		"command": fmt.Sprintf("journalctl -u %s --no-pager -n 5 2>/dev/null || echo 'журнал systemd недоступен для %s'", serviceName, serviceName),
+// activateCompoundSkill — делает версию version скила name единственной активной.
+func activateCompoundSkill(name string, version int) error {
+	return db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.CompoundSkill{}).Where("name = ?", name).Update("active", false).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.CompoundSkill{}).Where("name = ? AND version = ?", name, version).Update("active", true).Error
 	})
-	if err == nil {
-		report["journal"] = journalCheck
+}
+
+// fetchRecipe — текст рецепта по http(s)-URL, не больше recipe.MaxBytes (+1
+// байт, чтобы recipe.Parse отклонил слишком большой файл).
+func fetchRecipe(ctx context.Context, rawURL string) ([]byte, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, errors.New("ожидается http(s)-URL")
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := httpclient.New("recipes", 15*time.Second).Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("HTTP %d", resp.StatusCode)
 	}
+	return io.ReadAll(io.LimitReader(resp.Body, recipe.MaxBytes+1))
+}
 
-	report["success"] = true
-	report["message"] = fmt.Sprintf("Диагностика сервиса '%s' (порт %d) завершена", serviceName, int(port))
-	return report
+func writeCompoundSkillConflict(w http.ResponseWriter, cid, message, hint string) {
+	apierror.Write(w, http.StatusConflict, apierror.Response{
+		Code:      apierror.CodeForStatus(http.StatusConflict),
+		Message:   message,
+		Hint:      hint,
+		RequestID: cid,
+	})
 }
 
-// handleWebResearch — LEGO-блок: поиск информации в интернете.
-// Выполняет internet_search по указанной теме, затем загружает текст
-// лучших результатов через browser_get_text. Возвращает сводку.
-// Если browser-service недоступен, возвращает только результаты поиска.
-func handleWebResearch(args map[string]interface{}) map[string]interface{} {
-	topic, _ := args["topic"].(string)
-	if topic == "" {
-		return map[string]interface{}{"error": "topic обязателен"}
+// initIntents — регистрирует встроенные интенты и пользовательские из INTENTS_FILE.
+// Ошибка в пользовательском файле не останавливает сервис: встроенные интенты работают.
+func initIntents() {
+	cfg := config.Current()
+	infoSettings := infosource.Settings{
+		WeatherBackend: cfg.WeatherBackend,
+		WeatherURL:     cfg.WeatherURL,
+		GeocodingURL:   cfg.WeatherGeocodingURL,
+		Language:       cfg.DefaultLanguage,
+		NewsBackend:    cfg.NewsBackend,
+		NewsFeeds:      cfg.NewsFeedList(),
+	}
+	weather, err := infosource.NewWeatherSource(infoSettings)
+	if err != nil && !errors.Is(err, infosource.ErrDisabled) {
+		slog.Warn("Интент погоды отключён", slog.String("ошибка", err.Error()))
+	}
+	news, err := infosource.NewNewsSource(infoSettings)
+	if err != nil && !errors.Is(err, infosource.ErrDisabled) {
+		slog.Warn("Интент новостей отключён", slog.String("ошибка", err.Error()))
+	}
+	handlers.ConfigureInfoSources(weather, cfg.WeatherPlace, news)
+	if err := handlers.RegisterBuiltins(intentRegistry); err != nil {
+		slog.Error("Не удалось зарегистрировать встроенные интенты", slog.String("ошибка", err.Error()))
+		os.Exit(1)
 	}
-
-	maxSources := 3
-	if m, ok := args["max_sources"].(float64); ok && m > 0 {
-		maxSources = int(m)
+	path := cfg.IntentsFile
+	if path == "" {
+		return
+	}
+	specs, err := intent.LoadSpecs(path)
+	if err != nil {
+		slog.Error("Не удалось прочитать файл интентов", slog.String("файл", path), slog.String("ошибка", err.Error()))
+		return
+	}
+	n, err := intent.RegisterSpecs(intentRegistry, specs)
+	if err != nil {
+		slog.Warn("Часть пользовательских интентов пропущена", slog.String("ошибка", err.Error()))
 	}
+	slog.Info("Пользовательские интенты загружены", slog.String("файл", path), slog.Int("количество", n))
+}
 
-	report := map[string]interface{}{
-		"topic": topic,
+// intentEnabledFor — фильтр интентов агента (отключённые хранятся в Agent.DisabledIntents).
+// Если агент не найден, разрешены все интенты.
+func intentEnabledFor(agentName string) func(string) bool {
+	var agent models.Agent
+	if agentName == "" || db.DB.Select("disabled_intents").Where("name = ?", agentName).First(&agent).Error != nil {
+		return nil
 	}
+	disabled := intent.ParseNames(agent.DisabledIntents)
+	if len(disabled) == 0 {
+		return nil
+	}
+	return func(name string) bool { return !disabled[name] }
+}
 
-	// Шаг 1: Поиск в интернете через browser-service
-	searchResult, err := callTool("internet_search", map[string]interface{}{"query": topic})
-	if err != nil {
-		// Если browser-service недоступен, пробуем через execute + curl
-		fallbackResult, fallbackErr := callTool("execute", map[string]interface{}{
-			"command": fmt.Sprintf("curl -s 'https://api.duckduckgo.com/?q=%s&format=json&no_html=1' 2>/dev/null | head -c 2000", topic),
-		})
-		if fallbackErr != nil {
-			return map[string]interface{}{"error": "Поиск недоступен: " + err.Error()}
-		}
-		report["search_fallback"] = fallbackResult
-		report["success"] = true
-		report["message"] = "Выполнен поиск через DuckDuckGo API (browser-service недоступен)"
-		return report
+// runSlashCommand — выполняет slash-команду чата для агента.
+// Возвращает текст ответа; ошибка — некорректная команда или аргумент.
+func runSlashCommand(agentName string, cmd slash.Command) (string, error) {
+	if cmd.Name == slash.CmdHelp {
+		return slash.Help(), nil
+	}
+	var agent models.Agent
+	if err := db.DB.Where("name = ?", agentName).First(&agent).Error; err != nil {
+		return "", fmt.Errorf("агент не найден: %s", agentName)
 	}
+	arg := cmd.Arg()
 
-	report["search_results"] = searchResult
-
-	// Шаг 2: Загружаем текст с лучших результатов (если есть URL-ы)
-	var sources []map[string]interface{}
-	if results, ok := searchResult["results"].([]interface{}); ok {
-		limit := maxSources
-		if len(results) < limit {
-			limit = len(results)
+	switch cmd.Name {
+	case slash.CmdModel:
+		if arg == "" {
+			return fmt.Sprintf("Текущая модель: %s (%s)", agent.LLMModel, agent.Provider), nil
 		}
-		for i := 0; i < limit; i++ {
-			if item, ok := results[i].(map[string]interface{}); ok {
-				if url, ok := item["url"].(string); ok && url != "" {
-					text, textErr := callTool("browser_get_text", map[string]interface{}{"url": url})
-					source := map[string]interface{}{
-						"url":   url,
-						"title": item["title"],
-					}
-					if textErr == nil {
-						source["content"] = text
-					} else {
-						source["error"] = textErr.Error()
-					}
-					sources = append(sources, source)
-				}
+		agent.LLMModel = arg
+	case slash.CmdProvider:
+		if arg == "" {
+			return fmt.Sprintf("Текущий провайдер: %s. Доступны: %s", agent.Provider, strings.Join(llm.GlobalRegistry.List(), ", ")), nil
+		}
+		if _, err := llm.GlobalRegistry.Get(arg); err != nil {
+			return "", fmt.Errorf("провайдер %s не настроен", arg)
+		}
+		agent.Provider = arg
+	case slash.CmdTools:
+		if arg == "" {
+			return fmt.Sprintf("Инструменты: %s", onOff(agent.ToolsEnabled)), nil
+		}
+		on, err := slash.ParseSwitch(arg)
+		if err != nil {
+			return "", err
+		}
+		agent.ToolsEnabled = on
+	case slash.CmdWorkspace:
+		var workspaces []models.Workspace
+		db.DB.Order("name").Find(&workspaces)
+		names := make([]string, 0, len(workspaces))
+		var found *models.Workspace
+		for i := range workspaces {
+			names = append(names, workspaces[i].Name)
+			if strings.EqualFold(workspaces[i].Name, arg) {
+				found = &workspaces[i]
 			}
 		}
+		if arg == "" {
+			return "Рабочие пространства: " + strings.Join(names, ", "), nil
+		}
+		if found == nil {
+			return "", fmt.Errorf("рабочее пространство %q не найдено. Доступны: %s", arg, strings.Join(names, ", "))
+		}
+		agent.WorkspaceID = &found.ID
+	case slash.CmdClear:
+		res := db.DB.Where("agent_id = ?", agent.ID).Delete(&models.Message{})
+		if res.Error != nil {
+			return "", fmt.Errorf("не удалось очистить историю: %w", res.Error)
+		}
+		return fmt.Sprintf("История чата очищена (%d сообщений)", res.RowsAffected), nil
 	}
 
-	if len(sources) > 0 {
-		report["sources"] = sources
+	if err := db.DB.Save(&agent).Error; err != nil {
+		return "", fmt.Errorf("ошибка сохранения агента: %w", err)
+	}
+	switch cmd.Name {
+	case slash.CmdModel:
+		return "Модель агента " + agentName + ": " + agent.LLMModel, nil
+	case slash.CmdProvider:
+		return "Провайдер агента " + agentName + ": " + agent.Provider, nil
+	case slash.CmdTools:
+		return "Инструменты агента " + agentName + ": " + onOff(agent.ToolsEnabled), nil
+	default:
+		return "Агент " + agentName + " привязан к рабочему пространству " + arg, nil
 	}
+}
 
-	report["success"] = true
-	report["message"] = fmt.Sprintf("Исследование темы '%s': найдено результатов, загружено %d источников", topic, len(sources))
-	return report
+func onOff(on bool) string {
+	if on {
+		return "включены"
+	}
+	return "отключены"
 }
 
-// handleCheckResourcesBatch — LEGO-блок: проверка доступности нескольких URL.
-// Для каждого URL выполняет check_url_access через tools-service.
-// Возвращает сводную таблицу доступности всех ресурсов.
-func handleCheckResourcesBatch(args map[string]interface{}) map[string]interface{} {
-	urlsRaw, ok := args["urls"]
-	if !ok {
-		return map[string]interface{}{"error": "urls обязателен"}
+// speechSettings — параметры STT/TTS из текущей конфигурации.
+func speechSettings() speech.Settings {
+	cfg := config.Current()
+	return speech.Settings{
+		STTBackend:  cfg.STTBackend,
+		STTURL:      cfg.STTURL,
+		STTModel:    cfg.STTModel,
+		STTLanguage: cfg.STTLanguage,
+		TTSBackend:  cfg.TTSBackend,
+		TTSURL:      cfg.TTSURL,
+		TTSModel:    cfg.TTSModel,
+		TTSVoice:    cfg.TTSVoice,
+		APIKey:      cfg.SpeechAPIKey,
 	}
+}
 
-	var urls []string
-	switch v := urlsRaw.(type) {
-	case []interface{}:
-		for _, u := range v {
-			if s, ok := u.(string); ok {
-				urls = append(urls, s)
-			}
+// ChatAudioResponse — ответ /chat/audio: распознанный текст, ответ агента и (по запросу) озвучка.
+type ChatAudioResponse struct {
+	ChatResponse
+	Transcript string `json:"transcript"`
+	Audio      string `json:"audio,omitempty"`      // Озвученный ответ в base64
+	AudioType  string `json:"audio_type,omitempty"` // MIME-тип озвучки (audio/mpeg, audio/wav)
+}
+
+// chatAudioHandler — голосовой чат: POST /chat/audio (multipart/form-data).
+//
+// Поля формы:
+//   - audio (обязательное): аудиозапись (wav, mp3, ogg, webm — что принимает бэкенд STT)
+//   - agent (обязательное): имя агента
+//   - history (опционально): JSON-массив предыдущих сообщений, как messages в /chat
+//   - speak (опционально): "true" — озвучить ответ бэкендом TTS_BACKEND
+//
+// Распознанный текст проходит обычный конвейер /chat (slash-команды, интенты, RAG, инструменты).
+func chatAudioHandler(w http.ResponseWriter, r *http.Request) {
+	cid := r.Header.Get("X-Request-ID")
+	if r.Method != http.MethodPost {
+		apierror.MethodNotAllowed(w, cid)
+		return
+	}
+	stt, err := speech.NewTranscriber(speechSettings())
+	if err != nil {
+		apierror.ServiceUnavailable(w, cid, "Распознавание речи не настроено: "+err.Error(), "Задайте STT_BACKEND (whisper_cpp или openai)")
+		return
+	}
+	if err := r.ParseMultipartForm(32 << 20); err != nil {
+		apierror.BadRequest(w, cid, "Ожидается multipart/form-data", "Передайте аудио в поле audio")
+		return
+	}
+	file, header, err := r.FormFile("audio")
+	if err != nil {
+		apierror.BadRequest(w, cid, "Не передано поле audio", "")
+		return
+	}
+	defer file.Close()
+	audio, err := io.ReadAll(file)
+	if err != nil || len(audio) == 0 {
+		apierror.BadRequest(w, cid, "Пустая аудиозапись", "")
+		return
+	}
+	agentName := r.FormValue("agent")
+	if agentName == "" {
+		apierror.BadRequest(w, cid, "Не указан agent", "")
+		return
+	}
+	var history []llm.Message
+	if raw := r.FormValue("history"); raw != "" {
+		if err := json.Unmarshal([]byte(raw), &history); err != nil {
+			apierror.BadRequest(w, cid, "Невалидный JSON в history", "")
+			return
 		}
-	case []string:
-		urls = v
-	default:
-		return map[string]interface{}{"error": "urls должен быть массивом строк"}
 	}
 
-	if len(urls) == 0 {
-		return map[string]interface{}{"error": "urls пуст"}
+	started := time.Now()
+	transcript, err := stt.Transcribe(r.Context(), audio, header.Filename)
+	if err != nil {
+		slog.Error("Ошибка распознавания речи", slog.String("ошибка", err.Error()), slog.String("request_id", cid))
+		apierror.ServiceUnavailable(w, cid, "Не удалось распознать речь", "Проверьте доступность сервиса STT")
+		return
 	}
+	if transcript == "" {
+		apierror.BadRequest(w, cid, "Речь не распознана", "Запишите сообщение ещё раз")
+		return
+	}
+	slog.Info("Речь распознана", slog.String("агент", agentName), slog.Int("символов", len([]rune(transcript))),
+		slog.Duration("время", time.Since(started)), slog.String("request_id", cid))
 
-	var results []map[string]interface{}
-	accessible := 0
-	failed := 0
+	// Обычный конвейер /chat — тот же обработчик, что и для текстовых сообщений
+	chatBody, _ := json.Marshal(ChatRequest{
+		Agent:    agentName,
+		Messages: append(history, llm.Message{Role: "user", Content: transcript}),
+	})
+	chatReq, err := http.NewRequestWithContext(r.Context(), http.MethodPost, "/chat", bytes.NewReader(chatBody))
+	if err != nil {
+		apierror.InternalError(w, cid, "Ошибка формирования запроса", "")
+		return
+	}
+	chatReq.Header.Set("Content-Type", "application/json")
+	chatReq.Header.Set("X-Request-ID", cid)
+	rec := httptest.NewRecorder()
+	chatHandler(rec, chatReq)
+	if rec.Code != http.StatusOK {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(rec.Code)
+		w.Write(rec.Body.Bytes())
+		return
+	}
+	var result ChatAudioResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &result.ChatResponse); err != nil {
+		apierror.InternalError(w, cid, "Ошибка разбора ответа агента", "")
+		return
+	}
+	result.Transcript = transcript
 
-	for _, url := range urls {
-		r, err := callTool("check_url_access", map[string]interface{}{"url": url})
-		entry := map[string]interface{}{
-			"url": url,
+	if r.FormValue("speak") == "true" && result.Response != "" {
+		tts, err := speech.NewSynthesizer(speechSettings())
+		if err == nil {
+			var data []byte
+			data, result.AudioType, err = tts.Synthesize(r.Context(), result.Response)
+			result.Audio = base64.StdEncoding.EncodeToString(data)
 		}
 		if err != nil {
-			entry["status"] = "ошибка"
-			entry["error"] = err.Error()
-			failed++
-		} else {
-			entry["result"] = r
-			// Определяем доступность по результату
-			if errMsg, hasErr := r["error"]; hasErr && errMsg != nil {
-				entry["status"] = "недоступен"
-				failed++
-			} else {
-				entry["status"] = "доступен"
-				accessible++
-			}
+			// Текстовый ответ всё равно возвращаем — озвучка необязательна
+			slog.Warn("Ответ не озвучен", slog.String("ошибка", err.Error()), slog.String("request_id", cid))
+			result.Audio, result.AudioType = "", ""
 		}
-		results = append(results, entry)
 	}
+	writeJSON(w, result)
+}
 
-	return map[string]interface{}{
-		"success":    true,
-		"message":    fmt.Sprintf("Проверено %d URL: %d доступно, %d недоступно", len(urls), accessible, failed),
-		"accessible": accessible,
-		"failed":     failed,
-		"results":    results,
-	}
+// ChatBatchRequest — тело POST /chat/batch: запросы задаются строками (prompts)
+// или элементами с меткой и историей (items).
+type ChatBatchRequest struct {
+	Agent       string          `json:"agent"`
+	Prompts     []string        `json:"prompts,omitempty"`
+	Items       []ChatBatchItem `json:"items,omitempty"`
+	Concurrency int             `json:"concurrency,omitempty"` // Не больше BATCH_CONCURRENCY; 0 — общий лимит
+	NoCache     bool            `json:"no_cache,omitempty"`
 }
 
-// handleGenerateReport — LEGO-блок: создание текстового отчёта с верификацией.
-// Выполняет: 1) mkdir -p для директории, 2) write содержимого в файл,
-// 3) read для проверки записи, 4) stat для проверки размера файла.
-// Гарантирует что файл создан и содержит данные.
-func handleGenerateReport(args map[string]interface{}) map[string]interface{} {
-	path, _ := args["path"].(string)
-	content, _ := args["content"].(string)
-	title, _ := args["title"].(string)
+// ChatBatchItem — запрос пакета: prompt или полная история messages.
+type ChatBatchItem struct {
+	ID       string        `json:"id,omitempty"`
+	Prompt   string        `json:"prompt,omitempty"`
+	Messages []llm.Message `json:"messages,omitempty"`
+}
 
-	if path == "" || content == "" {
-		return map[string]interface{}{"error": "path и content обязательны"}
-	}
+// batchJobs — пакетные задания /chat/batch.
+var batchJobs = batch.NewManager(1)
 
-	// Формируем полное содержимое отчёта с заголовком
-	fullContent := content
-	if title != "" {
-		fullContent = fmt.Sprintf("=== %s ===\nДата: $(date)\n\n%s", title, content)
-	}
+// Лимиты одновременных запросов к LLM: общий на /chat и производные маршруты,
+// по маршрутам (ROUTE_MAX_CONCURRENT) и по провайдерам (PROVIDER_MAX_CONCURRENT).
+var (
+	chatLimiter      *limiter.Limiter
+	routeLimiters    = &limiter.Group{}
+	providerLimiters = &limiter.Group{}
+)
 
-	// Шаг 1: Создаём директорию если нужно
-	dir := path[:strings.LastIndex(path, "/")]
-	if dir != "" {
-		callTool("execute", map[string]interface{}{"command": "mkdir -p " + dir})
-	}
+// initConcurrency — лимиты одновременных запросов из конфигурации (CHAT_MAX_*,
+// ROUTE_MAX_CONCURRENT, PROVIDER_MAX_CONCURRENT; строки проверены в config.Validate).
+func initConcurrency() {
+	cfg := config.Current()
+	chatLimiter = limiter.New(cfg.ChatMaxConcurrent, cfg.ChatMaxQueue, cfg.ChatQueueTimeout)
+	routes, _ := limiter.ParseLimits(cfg.RouteMaxConcurrent)
+	routeLimiters = &limiter.Group{Limits: routes, MaxQueue: cfg.ChatMaxQueue, Wait: cfg.ChatQueueTimeout}
+	providers, _ := limiter.ParseLimits(cfg.ProviderMaxConcurrent)
+	providerLimiters = &limiter.Group{Limits: providers, MaxQueue: cfg.ChatMaxQueue, Wait: cfg.ChatQueueTimeout}
+}
 
-	// Шаг 2: Записываем файл
-	writeResult, err := callTool("write", map[string]interface{}{"path": path, "content": fullContent})
-	if err != nil {
-		return map[string]interface{}{"error": "Ошибка записи отчёта: " + err.Error()}
+// limitConcurrency — лимит маршрута route и общий лимит чатов для обработчика.
+func limitConcurrency(route string, next http.HandlerFunc) http.HandlerFunc {
+	return middleware.Concurrency(next, func() {
+		slog.Warn("Лимит одновременных запросов исчерпан", slog.String("маршрут", route))
+		metrics.RecordConcurrencyRejected(route)
+	}, routeLimiters.Get(route), chatLimiter)
+}
+
+// initBatch — лимиты пакетной обработки из конфигурации (BATCH_*).
+func initBatch() {
+	cfg := config.Current()
+	batchJobs = batch.NewManager(cfg.BatchConcurrency)
+	batchJobs.MaxActive = cfg.BatchMaxJobs
+}
+
+// chatBatchHandler — пакетная обработка (/chat/batch).
+// POST — поставить задание в очередь, ответ 202 с идентификатором; каждый
+// запрос проходит обычный конвейер /chat агента. GET — список заданий.
+// Состояние задания и ответы — GET /chat/batch/{id}, отмена — DELETE /chat/batch/{id}.
+func chatBatchHandler(w http.ResponseWriter, r *http.Request) {
+	cid := r.Header.Get("X-Request-ID")
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, map[string]interface{}{"jobs": batchJobs.List(), "concurrency": batchJobs.Concurrency()})
+		return
+	case http.MethodPost:
+	default:
+		apierror.MethodNotAllowed(w, cid)
+		return
 	}
 
-	// Шаг 3: Читаем обратно для верификации
-	readResult, err := callTool("read", map[string]interface{}{"path": path})
-	if err != nil {
-		return map[string]interface{}{
-			"success": false,
-			"message": "Файл записан, но не удалось прочитать для проверки",
-			"write":   writeResult,
-			"error":   err.Error(),
+	var req ChatBatchRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		apierror.BadRequest(w, cid, "Невалидный JSON", "Ожидается {\"agent\": \"admin\", \"prompts\": [\"...\"]}")
+		return
+	}
+	items := req.Items
+	for _, p := range req.Prompts {
+		items = append(items, ChatBatchItem{Prompt: p})
+	}
+	if len(items) == 0 {
+		apierror.BadRequest(w, cid, "Пустой пакет", "Передайте prompts или items")
+		return
+	}
+	if limit := config.Current().BatchMaxItems; len(items) > limit {
+		apierror.BadRequest(w, cid, fmt.Sprintf("В пакете %d запросов, допустимо не больше %d", len(items), limit), "Разбейте пакет на части или увеличьте BATCH_MAX_ITEMS")
+		return
+	}
+	ids := make([]string, len(items))
+	for i, it := range items {
+		if strings.TrimSpace(it.Prompt) == "" && len(it.Messages) == 0 {
+			apierror.BadRequest(w, cid, fmt.Sprintf("Запрос %d пуст", i), "У каждого элемента нужен prompt или messages")
+			return
 		}
+		ids[i] = it.ID
+	}
+	if _, err := repository.GetAgentByName(req.Agent); err != nil {
+		apierror.NotFound(w, cid, "Агент не найден")
+		return
 	}
 
-	// Шаг 4: Проверяем размер файла
-	statResult, _ := callTool("execute", map[string]interface{}{
-		"command": fmt.Sprintf("stat -c '%%s байт' '%s' 2>/dev/null || wc -c < '%s'", path, path),
-	})
-
-	return map[string]interface{}{
-		"success":   true,
-		"message":   fmt.Sprintf("Отчёт записан в %s и проверен", path),
-		"path":      path,
-		"write":     writeResult,
-		"verified":  readResult,
-		"file_size": statResult,
+	// Заголовки ключа передаются в каждый запрос: расход засчитывается в бюджет клиента
+	auth, apiKey := r.Header.Get("Authorization"), r.Header.Get("X-API-Key")
+	run := func(ctx context.Context, i int) (string, error) {
+		messages := items[i].Messages
+		if items[i].Prompt != "" {
+			messages = append(append([]llm.Message(nil), messages...), llm.Message{Role: "user", Content: items[i].Prompt})
+		}
+		header := http.Header{}
+		header.Set("Authorization", auth)
+		header.Set("X-API-Key", apiKey)
+		resp, err := runChat(ctx, ChatRequest{Agent: req.Agent, Messages: messages, NoCache: req.NoCache}, fmt.Sprintf("%s-%d", cid, i), header)
+		status := batch.StatusDone
+		if ctx.Err() != nil {
+			status = batch.StatusCanceled
+		} else if err != nil {
+			status = batch.StatusFailed
+		}
+		metrics.RecordBatchItem(status)
+		return resp.Response, err
+	}
+	job, err := batchJobs.Start(req.Agent, ids, req.Concurrency, run)
+	if errors.Is(err, batch.ErrTooManyJobs) {
+		apierror.Write(w, http.StatusTooManyRequests, apierror.Response{
+			Code:      "TOO_MANY_JOBS",
+			Message:   err.Error(),
+			Hint:      "Дождитесь завершения или отмените задание (DELETE /chat/batch/{id})",
+			RequestID: cid,
+			Retryable: true,
+		})
+		return
 	}
+	slog.Info("Пакетное задание запущено",
+		slog.String("задание", job.ID),
+		slog.String("агент", req.Agent),
+		slog.Int("запросов", len(items)),
+		slog.Int("параллельно", job.Concurrency),
+		slog.String("request_id", cid))
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Location", "/chat/batch/"+job.ID)
+	w.WriteHeader(http.StatusAccepted)
+	job.Items = nil
+	writeJSON(w, job)
 }
 
-// handleInstallPackages — LEGO-блок: установка пакетов через менеджер пакетов.
-// Поддерживает apt, npm, pip. Выполняет установку + проверку версий после.
-// Для apt автоматически добавляет sudo и -y флаг.
-func handleInstallPackages(args map[string]interface{}) map[string]interface{} {
-	packagesRaw, ok := args["packages"]
-	if !ok {
-		return map[string]interface{}{"error": "packages обязателен"}
-	}
+// runChat — запрос req через обычный конвейер /chat внутри процесса (пакетная
+// обработка, запуск агента по расписанию). header — заголовки ключа клиента.
+func runChat(ctx context.Context, req ChatRequest, requestID string, header http.Header) (ChatResponse, error) {
+	var resp ChatResponse
+	body, _ := json.Marshal(req)
+	sub, err := http.NewRequestWithContext(ctx, http.MethodPost, "/chat", bytes.NewReader(body))
+	if err != nil {
+		return resp, err
+	}
+	for k, v := range header {
+		sub.Header[k] = v
+	}
+	sub.Header.Set("Content-Type", "application/json")
+	sub.Header.Set("X-Request-ID", requestID)
+	rec := httptest.NewRecorder()
+	chatHandler(rec, sub)
+	var apiErr apierror.Response
+	switch {
+	case rec.Code != http.StatusOK && json.Unmarshal(rec.Body.Bytes(), &apiErr) == nil && apiErr.Message != "":
+		err = errors.New(apiErr.Message)
+	case rec.Code != http.StatusOK:
+		err = fmt.Errorf("агент ответил статусом %d", rec.Code)
+	case json.Unmarshal(rec.Body.Bytes(), &resp) != nil:
+		err = errors.New("некорректный ответ агента")
+	case resp.Error != "":
+		err = errors.New(resp.Error)
+	}
+	return resp, err
+}
 
-	var packages []string
-	switch v := packagesRaw.(type) {
-	case []interface{}:
-		for _, p := range v {
-			if s, ok := p.(string); ok {
-				packages = append(packages, s)
-			}
+// chatBatchJobHandler — задание пакетной обработки: GET /chat/batch/{id} —
+// статус и ответы по запросам, DELETE — отменить.
+func chatBatchJobHandler(w http.ResponseWriter, r *http.Request) {
+	cid := r.Header.Get("X-Request-ID")
+	id := strings.Trim(strings.TrimPrefix(r.URL.Path, "/chat/batch/"), "/")
+	switch r.Method {
+	case http.MethodGet:
+		job, ok := batchJobs.Get(id)
+		if !ok {
+			apierror.NotFound(w, cid, "Задание не найдено")
+			return
 		}
-	case []string:
-		packages = v
+		writeJSON(w, job)
+	case http.MethodDelete:
+		if !batchJobs.Cancel(id) {
+			apierror.NotFound(w, cid, "Задание не найдено")
+			return
+		}
+		slog.Info("Пакетное задание отменено", slog.String("задание", id), slog.String("request_id", cid))
+		job, _ := batchJobs.Get(id)
+		job.Items = nil
+		writeJSON(w, job)
 	default:
-		return map[string]interface{}{"error": "packages должен быть массивом строк"}
+		apierror.MethodNotAllowed(w, cid)
 	}
+}
 
-	if len(packages) == 0 {
-		return map[string]interface{}{"error": "packages пуст"}
-	}
+// Типы заданий очереди (JOBS_QUEUE).
+const (
+	jobLearningExtract = "learning_extract"  // Извлечение знаний из диалога в memory-service
+	jobRAGIngestFolder = "rag_ingest_folder" // Загрузка папки в RAG (POST /rag/add-folder с async)
+	jobAgentRun        = "agent_run"         // Запуск агента с заданным сообщением, в том числе по расписанию
+)
 
-	manager, _ := args["manager"].(string)
-	if manager == "" {
-		manager = "apt"
-	}
+// jobQueue — очередь фоновых заданий; nil — JOBS_QUEUE выключен, задачи идут в горутинах.
+var jobQueue *jobqueue.Queue
 
-	var installCmd string
-	switch manager {
-	case "apt":
-		installCmd = "sudo apt-get install -y " + strings.Join(packages, " ")
-	case "npm":
-		installCmd = "npm install -g " + strings.Join(packages, " ")
-	case "pip":
-		installCmd = "pip3 install " + strings.Join(packages, " ")
-	default:
-		return map[string]interface{}{"error": fmt.Sprintf("Неизвестный менеджер пакетов: %s (поддерживаются: apt, npm, pip)", manager)}
+// jobsBroker — NATS-брокер очереди (JOBS_BROKER=nats); nil — только опрос БД.
+var jobsBroker *jobqueue.NATSBroker
+
+// learningJob — параметры задания извлечения знаний.
+type learningJob struct {
+	Model     string `json:"model"`
+	Agent     string `json:"agent"`
+	User      string `json:"user"`
+	Assistant string `json:"assistant"`
+}
+
+// agentRunJob — параметры запуска агента. Every — повторять с этим интервалом
+// ("24h"): следующий запуск ставится в очередь при начале текущего (один раз,
+// даже если текущий повторяется после ошибки).
+type agentRunJob struct {
+	Agent  string `json:"agent"`
+	Prompt string `json:"prompt"`
+	Every  string `json:"every,omitempty"`
+}
+
+// initJobs — очередь заданий из конфигурации (JOBS_*) с обработчиками всех
+// типов. Выполнение заданий запускает startJobs.
+func initJobs() {
+	cfg := config.Current()
+	if !cfg.JobsQueue {
+		return
+	}
+	host, _ := os.Hostname()
+	jobQueue = jobqueue.New(db.DB, fmt.Sprintf("%s-%d", host, os.Getpid()), cfg.JobsLease, cfg.JobsMaxAttempts)
+	if cfg.JobsBroker == "nats" {
+		b, err := jobqueue.NewNATSBroker(cfg.JobsBrokerURL, cfg.JobsBrokerSubject, jobQueue.Worker)
+		if err != nil {
+			slog.Warn("Брокер заданий недоступен, задания забираются опросом БД", slog.String("ошибка", err.Error()))
+		} else {
+			jobQueue.Broker = b
+			jobsBroker = b
+		}
 	}
+	jobQueue.Register(jobLearningExtract, func(_ context.Context, payload json.RawMessage) (interface{}, error) {
+		var p learningJob
+		if err := json.Unmarshal(payload, &p); err != nil {
+			return nil, err
+		}
+		return nil, extractAndStoreLearnings(p.Model, p.Agent, p.User, p.Assistant)
+	})
+	jobQueue.Register(jobRAGIngestFolder, func(_ context.Context, payload json.RawMessage) (interface{}, error) {
+		var p ragFolderJob
+		if err := json.Unmarshal(payload, &p); err != nil {
+			return nil, err
+		}
+		if info, err := os.Stat(p.FolderPath); err != nil || !info.IsDir() {
+			return nil, fmt.Errorf("папка %s не найдена", p.FolderPath)
+		}
+		return ingestFolder(p.FolderPath, p.WorkspaceID), nil
+	})
+	jobQueue.Register(jobAgentRun, runAgentJob)
+}
 
-	// Шаг 1: Обновляем индекс (только для apt)
-	var steps []map[string]interface{}
-	if manager == "apt" {
-		updateResult, _ := callTool("execute", map[string]interface{}{"command": "sudo apt-get update -qq"})
-		steps = append(steps, map[string]interface{}{"step": "update_index", "result": updateResult})
+// startJobs — выполнение заданий обработчиками этого процесса (JOBS_WORKERS)
+// до отмены ctx. Закрытие канала — все начатые задания завершены.
+func startJobs(ctx context.Context) <-chan struct{} {
+	done := make(chan struct{})
+	cfg := config.Current()
+	if jobQueue == nil || cfg.JobsWorkers == 0 {
+		close(done)
+		return done
 	}
+	slog.Info("Обработчики очереди заданий запущены", slog.Int("обработчиков", cfg.JobsWorkers), slog.String("имя", jobQueue.Worker))
+	go func() {
+		defer close(done)
+		jobQueue.Run(ctx, cfg.JobsWorkers, cfg.JobsPoll)
+	}()
+	return done
+}
 
-	// Шаг 2: Устанавливаем пакеты
-	installResult, err := callTool("execute", map[string]interface{}{"command": installCmd})
-	if err != nil {
-		return map[string]interface{}{
-			"error":   "Ошибка установки: " + err.Error(),
-			"command": installCmd,
-			"steps":   steps,
+// runInBackground — фоновая задача: заданием kind, если очередь включена,
+// иначе (или если поставить задание не удалось) — в горутине.
+func runInBackground(kind string, payload interface{}, fn func()) {
+	if jobQueue != nil {
+		_, err := jobQueue.Enqueue(kind, payload, time.Time{})
+		if err == nil {
+			return
 		}
+		slog.Warn("Не удалось поставить задание в очередь, выполняется в процессе", slog.String("тип", kind), slog.String("ошибка", err.Error()))
 	}
-	steps = append(steps, map[string]interface{}{"step": "install", "command": installCmd, "result": installResult})
+	go fn()
+}
 
-	// Шаг 3: Проверяем версии установленных пакетов
-	for _, pkg := range packages {
-		var verifyCmd string
-		switch manager {
-		case "apt":
-			verifyCmd = fmt.Sprintf("dpkg -l %s 2>/dev/null | tail -1 || echo 'не найден'", pkg)
-		case "npm":
-			verifyCmd = fmt.Sprintf("npm list -g %s 2>/dev/null | tail -1 || echo 'не найден'", pkg)
-		case "pip":
-			verifyCmd = fmt.Sprintf("pip3 show %s 2>/dev/null | grep Version || echo 'не найден'", pkg)
+// runAgentJob — обработчик agent_run: сообщение агенту через конвейер /chat.
+func runAgentJob(ctx context.Context, payload json.RawMessage) (interface{}, error) {
+	var p agentRunJob
+	if err := json.Unmarshal(payload, &p); err != nil {
+		return nil, err
+	}
+	if p.Every != "" {
+		every, err := time.ParseDuration(p.Every)
+		if err != nil || every <= 0 {
+			return nil, fmt.Errorf("every %q: нужна положительная длительность", p.Every)
+		}
+		if _, err := jobQueue.EnqueueOnce(jobAgentRun, p, time.Now().Add(every)); err != nil {
+			return nil, fmt.Errorf("не удалось запланировать следующий запуск: %w", err)
 		}
-		verifyResult, _ := callTool("execute", map[string]interface{}{"command": verifyCmd})
-		steps = append(steps, map[string]interface{}{"step": "verify_" + pkg, "result": verifyResult})
 	}
-
-	return map[string]interface{}{
-		"success":  true,
-		"message":  fmt.Sprintf("Установлено %d пакетов через %s", len(packages), manager),
-		"manager":  manager,
-		"packages": packages,
-		"steps":    steps,
+	requestID := fmt.Sprintf("job-%d", time.Now().UnixNano())
+	resp, err := runChat(ctx, ChatRequest{Agent: p.Agent, Messages: []llm.Message{{Role: "user", Content: p.Prompt}}}, requestID, nil)
+	if err != nil {
+		return nil, err
 	}
+	return map[string]interface{}{"request_id": requestID, "response": resp.Response}, nil
 }
 
-// initProvidersFromDB — загрузка конфигурации облачных провайдеров из PostgreSQL.
-// Вызывается при старте сервиса после инициализации БД.
-// Для каждого включённого провайдера регистрирует его в глобальном реестре
-// с API-ключом, базовым URL и дополнительными параметрами (folder_id/scope).
-// Это позволяет сохранять настройки провайдеров между перезапусками сервиса.
-func autoskillPatternsHandler(w http.ResponseWriter, r *http.Request) {
-	if autoSkillPipeline == nil {
-		http.Error(w, `{"error":"конвейер не инициализирован"}`, http.StatusServiceUnavailable)
-		return
+// validateJob — проверка параметров задания, поставленного через POST /jobs.
+func validateJob(kind string, payload json.RawMessage) error {
+	switch kind {
+	case jobAgentRun:
+		var p agentRunJob
+		if err := json.Unmarshal(payload, &p); err != nil || p.Agent == "" || strings.TrimSpace(p.Prompt) == "" {
+			return errors.New("agent_run: нужны agent и prompt")
+		}
+		if _, err := repository.GetAgentByName(p.Agent); err != nil {
+			return fmt.Errorf("агент %s не найден", p.Agent)
+		}
+		if p.Every != "" {
+			if d, err := time.ParseDuration(p.Every); err != nil || d < time.Minute {
+				return fmt.Errorf("every %q: нужна длительность не меньше 1m", p.Every)
+			}
+		}
+	case jobRAGIngestFolder:
+		var p ragFolderJob
+		if err := json.Unmarshal(payload, &p); err != nil || p.FolderPath == "" {
+			return errors.New("rag_ingest_folder: нужен folder_path")
+		}
+	case jobLearningExtract:
+		var p learningJob
+		if err := json.Unmarshal(payload, &p); err != nil || p.Model == "" {
+			return errors.New("learning_extract: нужны model, agent, user и assistant")
+		}
+	default:
+		return fmt.Errorf("неизвестный тип задания %q", kind)
 	}
+	return nil
+}
+
+// writeJobAccepted — ответ 202 с поставленным заданием.
+func writeJobAccepted(w http.ResponseWriter, job *models.Job) {
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(autoSkillPipeline.ListPatterns())
+	w.Header().Set("Location", fmt.Sprintf("/jobs/%d", job.ID))
+	w.WriteHeader(http.StatusAccepted)
+	writeJSON(w, job)
 }
 
-func autoskillCandidatesHandler(w http.ResponseWriter, r *http.Request) {
-	if autoSkillPipeline == nil {
-		http.Error(w, `{"error":"конвейер не инициализирован"}`, http.StatusServiceUnavailable)
+// jobsHandler — очередь фоновых заданий (JOBS_QUEUE):
+//
+//	GET  /jobs — задания (фильтры status, kind, limit), число по статусам и типы
+//	POST /jobs — поставить задание {kind, payload, run_at}; run_at — время запуска (RFC 3339)
+func jobsHandler(w http.ResponseWriter, r *http.Request) {
+	cid := r.Header.Get("X-Request-ID")
+	if jobQueue == nil {
+		apierror.ServiceUnavailable(w, cid, "Очередь заданий выключена", "Включите JOBS_QUEUE=true")
 		return
 	}
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(autoSkillPipeline.ListCandidates())
+	switch r.Method {
+	case http.MethodGet:
+		q := r.URL.Query()
+		limit, _ := strconv.Atoi(q.Get("limit"))
+		if limit <= 0 || limit > 500 {
+			limit = 50
+		}
+		jobs, err := jobqueue.List(db.DB, q.Get("status"), q.Get("kind"), limit)
+		if err != nil {
+			apierror.InternalError(w, cid, "Ошибка чтения заданий", "")
+			return
+		}
+		counts, err := jobqueue.Counts(db.DB)
+		if err != nil {
+			apierror.InternalError(w, cid, "Ошибка чтения заданий", "")
+			return
+		}
+		writeJSON(w, map[string]interface{}{"jobs": jobs, "counts": counts, "kinds": jobQueue.Kinds()})
+	case http.MethodPost:
+		var req struct {
+			Kind    string          `json:"kind"`
+			Payload json.RawMessage `json:"payload"`
+			RunAt   time.Time       `json:"run_at"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			apierror.BadRequest(w, cid, "Невалидный JSON", "")
+			return
+		}
+		if err := validateJob(req.Kind, req.Payload); err != nil {
+			apierror.BadRequest(w, cid, err.Error(), "Типы заданий: "+strings.Join(jobQueue.Kinds(), ", "))
+			return
+		}
+		job, err := jobQueue.Enqueue(req.Kind, req.Payload, req.RunAt)
+		if err != nil {
+			apierror.InternalError(w, cid, "Не удалось поставить задание в очередь", err.Error())
+			return
+		}
+		slog.Info("Задание поставлено в очередь", slog.Uint64("задание", uint64(job.ID)), slog.String("тип", job.Kind), slog.Time("запуск", job.RunAt), slog.String("request_id", cid))
+		writeJobAccepted(w, job)
+	default:
+		apierror.MethodNotAllowed(w, cid)
+	}
 }
 
-func autoskillPromoteHandler(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		http.Error(w, `{"error":"метод не поддерживается"}`, http.StatusMethodNotAllowed)
+// jobHandler — одно задание:
+//
+//	GET    /jobs/{id}       — задание с параметрами, итогом и ошибкой последней попытки
+//	POST   /jobs/{id}/retry — вернуть в очередь задание failed или canceled
+//	DELETE /jobs/{id}       — отменить ожидающее задание
+func jobHandler(w http.ResponseWriter, r *http.Request) {
+	cid := r.Header.Get("X-Request-ID")
+	if jobQueue == nil {
+		apierror.ServiceUnavailable(w, cid, "Очередь заданий выключена", "Включите JOBS_QUEUE=true")
 		return
 	}
-	if autoSkillPipeline == nil {
-		http.Error(w, `{"error":"конвейер не инициализирован"}`, http.StatusServiceUnavailable)
+	rest := strings.Trim(strings.TrimPrefix(r.URL.Path, "/jobs/"), "/")
+	idPart, action, _ := strings.Cut(rest, "/")
+	id, err := strconv.ParseUint(idPart, 10, 64)
+	if err != nil {
+		apierror.NotFound(w, cid, "Задание не найдено")
 		return
 	}
-	promoted := autoSkillPipeline.PromoteCandidates()
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]interface{}{"promoted": promoted, "count": len(promoted)})
+	var job *models.Job
+	switch {
+	case r.Method == http.MethodGet && action == "":
+		job, err = jobqueue.Get(db.DB, uint(id))
+	case r.Method == http.MethodPost && action == "retry":
+		job, err = jobQueue.Retry(uint(id))
+	case r.Method == http.MethodDelete && action == "":
+		job, err = jobQueue.Cancel(uint(id))
+	default:
+		apierror.MethodNotAllowed(w, cid)
+		return
+	}
+	switch {
+	case errors.Is(err, jobqueue.ErrNotFound):
+		apierror.NotFound(w, cid, "Задание не найдено")
+	case errors.Is(err, jobqueue.ErrWrongStatus):
+		apierror.Write(w, http.StatusConflict, apierror.Response{
+			Code:      "JOB_WRONG_STATUS",
+			Message:   fmt.Sprintf("Задание в статусе %s", job.Status),
+			Hint:      "Повторить можно задание failed или canceled, отменить — pending",
+			RequestID: cid,
+		})
+	case err != nil:
+		apierror.InternalError(w, cid, "Ошибка чтения задания", "")
+	default:
+		writeJSON(w, job)
+	}
 }
 
-func autoskillRollbackHandler(w http.ResponseWriter, r *http.Request) {
+// workerOnly — процесс-обработчик очереди (JOBS_WORKER_ONLY) отвечает только
+// на служебные маршруты и /jobs.
+func workerOnly(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch p := r.URL.Path; {
+		case p == "/health", p == "/ready", p == "/metrics", p == "/jobs", strings.HasPrefix(p, "/jobs/"):
+			next.ServeHTTP(w, r)
+		default:
+			apierror.ServiceUnavailable(w, r.Header.Get("X-Request-ID"), "Процесс выполняет только задания очереди", "Запросы принимает agent-service без JOBS_WORKER_ONLY")
+		}
+	})
+}
+
+// SpeculativeDraft — событие draft потока /chat/speculative.
+type SpeculativeDraft struct {
+	Response  string `json:"response"`
+	Provider  string `json:"provider"`
+	Model     string `json:"model"`
+	LatencyMs int64  `json:"latency_ms"`
+	Draft     bool   `json:"draft"`
+}
+
+// SpeculativeFinal — событие final: ответ основной модели и сравнение с черновиком.
+type SpeculativeFinal struct {
+	ChatResponse
+	Draft       bool             `json:"draft"`
+	LatencyMs   int64            `json:"latency_ms"`
+	Speculative *SpeculativeInfo `json:"speculative,omitempty"`
+}
+
+// SpeculativeInfo — итог гонки черновой и основной модели.
+type SpeculativeInfo struct {
+	Outcome    string  `json:"outcome"` // draft_first, primary_first, draft_failed
+	DraftModel string  `json:"draft_model"`
+	Agreement  float64 `json:"agreement,omitempty"` // Сходство черновика с ответом 0..1
+	LeadMs     int64   `json:"lead_ms,omitempty"`   // На сколько раньше показан черновик
+	DraftError string  `json:"draft_error,omitempty"`
+}
+
+// chatSpeculativeHandler — POST /chat/speculative: экспериментальная двойная
+// отправка (SPECULATIVE_ENABLED). Тело — как у /chat, ответ — поток SSE:
+//   - delta — фрагменты черновика по мере генерации;
+//   - draft — готовый черновик быстрой модели (SpeculativeDraft);
+//   - final — ответ основной модели через обычный конвейер /chat (SpeculativeFinal);
+//   - error — ошибка основного конвейера (тело ошибки API).
+//
+// Черновик отвечает без инструментов, RAG и памяти — только промпт агента и
+// история. Если режим выключен или запрос — slash-команда, вложение или
+// изображение, черновика нет и приходит только final.
+func chatSpeculativeHandler(w http.ResponseWriter, r *http.Request) {
+	cid := r.Header.Get("X-Request-ID")
 	if r.Method != http.MethodPost {
-		http.Error(w, `{"error":"метод не поддерживается"}`, http.StatusMethodNotAllowed)
+		apierror.MethodNotAllowed(w, cid)
 		return
 	}
-	intentName := r.URL.Query().Get("intent")
-	if intentName == "" {
-		http.Error(w, `{"error":"параметр intent обязателен"}`, http.StatusBadRequest)
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		apierror.BadRequest(w, cid, "Не удалось прочитать тело запроса", "")
 		return
 	}
-	if autoSkillPipeline == nil {
-		http.Error(w, `{"error":"конвейер не инициализирован"}`, http.StatusServiceUnavailable)
+	var req ChatRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		apierror.BadRequest(w, cid, "Невалидный JSON", "Проверьте формат тела запроса")
 		return
 	}
-	if err := autoSkillPipeline.Rollback(intentName); err != nil {
-		http.Error(w, fmt.Sprintf(`{"error":%q}`, err.Error()), http.StatusNotFound)
+	if len(req.Messages) == 0 {
+		apierror.BadRequest(w, cid, "Пустой список messages", "Передайте хотя бы одно сообщение")
 		return
 	}
-	w.Header().Set("Content-Type", "application/json")
-	fmt.Fprintf(w, `{"status":"rolled_back","intent":%q}`, intentName)
-}
+	lastMsg := req.Messages[len(req.Messages)-1].Content
+	_, isCommand := slash.Parse(lastMsg)
+	cfg := config.Current()
+	withDraft := cfg.SpeculativeEnabled && cfg.SpeculativeDraftModel != "" && !isCommand &&
+		len(req.Attachments) == 0 && len(req.ImageFiles) == 0 && !llm.HasImages(req.Messages)
+
+	rc := http.NewResponseController(w)
+	rc.SetWriteDeadline(time.Time{})
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
 
-func initProvidersFromDB() {
-	var configs []models.ProviderConfig
-	db.DB.Where("enabled = ?", true).Find(&configs)
-	for _, cfg := range configs {
-		extra := cfg.FolderID
-		if cfg.Scope != "" {
-			extra = cfg.Scope
+	// События пишутся из горутин черновика и основной модели; после final — ничего
+	var mu sync.Mutex
+	closed := false
+	send := func(event string, v interface{}, last bool) {
+		mu.Lock()
+		defer mu.Unlock()
+		if closed {
+			return
 		}
-		if err := llm.RegisterProvider(cfg.ProviderName, cfg.APIKey, cfg.BaseURL, extra, cfg.ServiceAccountJSON); err != nil {
-			slog.Error("Не удалось зарегистрировать провайдер из БД", slog.String("провайдер", cfg.ProviderName), slog.String("ошибка", err.Error()))
+		data, _ := json.Marshal(v)
+		fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, data)
+		rc.Flush()
+		closed = last
+	}
+
+	var primaryCode int
+	var primaryBody []byte
+	primary := func(ctx context.Context) speculative.Answer {
+		start := time.Now()
+		sub, _ := http.NewRequestWithContext(ctx, http.MethodPost, "/chat", bytes.NewReader(body))
+		sub.Header.Set("Content-Type", "application/json")
+		sub.Header.Set("X-Request-ID", cid)
+		rec := httptest.NewRecorder()
+		chatHandler(rec, sub)
+		primaryCode, primaryBody = rec.Code, rec.Body.Bytes()
+		a := speculative.Answer{Latency: time.Since(start)}
+		var resp ChatResponse
+		if primaryCode != http.StatusOK || json.Unmarshal(primaryBody, &resp) != nil || resp.Error != "" {
+			a.Err = errors.New("основная модель не ответила")
+		}
+		a.Content = resp.Response
+		return a
+	}
+	g := currentGuard()
+	draft := func(ctx context.Context) speculative.Answer {
+		start := time.Now()
+		a := speculative.Answer{Provider: cfg.SpeculativeDraftProvider, Model: cfg.SpeculativeDraftModel}
+		provider, err := llm.GlobalRegistry.Get(a.Provider)
+		if err != nil {
+			a.Err = err
+			return a
+		}
+		agent, err := repository.GetAgentByName(req.Agent)
+		if err != nil {
+			a.Err = err
+			return a
+		}
+		messages := append([]llm.Message{{Role: "system", Content: agent.Prompt}}, req.Messages...)
+		resp, err := chatWithRetry(ctx, provider, &llm.ChatRequest{
+			Model:    a.Model,
+			Messages: messages,
+			Stream:   llm.SupportsStreaming(provider.Name()),
+			OnDelta: func(text string) {
+				// При GUARD_OUTPUT_POLICY=block фрагменты не показываются до проверки всего черновика
+				if ctx.Err() == nil && g.Output != guard.PolicyBlock {
+					send("delta", map[string]string{"text": text}, false)
+				}
+			},
+		})
+		a.Latency = time.Since(start)
+		if err != nil {
+			a.Err = err
+			return a
 		}
+		a.Content, _ = g.CheckOutput(reasoning.Strip(resp.Content))
+		return a
+	}
+
+	var info *SpeculativeInfo
+	var took time.Duration
+	if withDraft {
+		res := speculative.Run(r.Context(), draft, primary, func(d speculative.Answer) {
+			send("draft", SpeculativeDraft{Response: d.Content, Provider: d.Provider, Model: d.Model, LatencyMs: d.Latency.Milliseconds(), Draft: true}, false)
+		})
+		took = res.Primary.Latency
+		info = &SpeculativeInfo{Outcome: res.Outcome, DraftModel: cfg.SpeculativeDraftProvider + "/" + cfg.SpeculativeDraftModel}
+		if res.Outcome == speculative.OutcomeDraftFirst && res.Primary.Err == nil {
+			info.Agreement, info.LeadMs = res.Agreement, res.Lead.Milliseconds()
+			metrics.RecordSpeculative(res.Outcome, res.Agreement, res.Lead)
+		} else if res.Primary.Err == nil {
+			metrics.RecordSpeculative(res.Outcome, 0, 0)
+		}
+		slog.Info("Двойная отправка завершена",
+			slog.String("агент", req.Agent),
+			slog.String("исход", res.Outcome),
+			slog.Float64("согласие", res.Agreement),
+			slog.Duration("выигрыш", res.Lead),
+			slog.String("request_id", cid))
+	} else {
+		took = primary(r.Context()).Latency
+	}
+
+	var final SpeculativeFinal
+	if primaryCode != http.StatusOK || json.Unmarshal(primaryBody, &final.ChatResponse) != nil {
+		send("error", json.RawMessage(primaryBody), true)
+		return
 	}
+	final.LatencyMs = took.Milliseconds()
+	final.Speculative = info
+	send("final", final, true)
 }
 
-// main — точка входа agent-service.
-//
-// Порядок инициализации:
-//  1. Подключение к PostgreSQL и миграции (db.InitDB)
-//  2. Инициализация локального провайдера Ollama (llm.InitProviders)
-//  3. Загрузка конфигурации облачных провайдеров из БД (initProvidersFromDB)
-//  4. Создание агента Admin по умолчанию, если его нет
-//  5. Инициализация метрик OpenTelemetry
-//  6. Регистрация HTTP-обработчиков для всех эндпоинтов
-//  7. Настройка раздачи статических файлов из uploads/
-//  8. Запуск HTTP-сервера на порту AGENT_SERVICE_PORT (по умолчанию 8083)
-func validateEnv() {
-	slog.Info("Проверка переменных окружения")
+// attachmentSessions — временные индексы документов, прикреплённых к чату.
+var attachmentSessions = attachments.NewSessions(attachments.SessionTTL)
 
-	dbURL := os.Getenv("DATABASE_URL")
-	dbHost := os.Getenv("DB_HOST")
-	if dbURL == "" && dbHost == "" {
-		slog.Info("DATABASE_URL и DB_HOST не заданы, используются значения по умолчанию")
-		slog.Info("Для настройки см. .env.example или документацию")
+// buildAttachmentContext — контекст из прикреплённых документов для системного промпта.
+// Все документы индексируются в сессии (session_id генерируется, если не передан),
+// чтобы следующие сообщения могли ссылаться на них. Небольшие документы
+// подставляются целиком, из больших — фрагменты, релевантные вопросу.
+func buildAttachmentContext(req *ChatRequest, query string) (string, []Source, string, error) {
+	sessionID := req.SessionID
+	if len(req.Attachments) == 0 && (sessionID == "" || !attachmentSessions.Has(sessionID)) {
+		return "", nil, "", nil
+	}
+	if sessionID == "" {
+		sessionID = uuid.NewString()
+	}
+
+	type doc struct{ name, text string }
+	var docs []doc
+	total := 0
+	for _, a := range req.Attachments {
+		data, err := attachmentData(a)
+		if err != nil {
+			return "", nil, "", err
+		}
+		text, err := attachments.Extract(a.Name, data)
+		if err != nil {
+			return "", nil, "", err
+		}
+		docs = append(docs, doc{a.Name, text})
+		total += len([]rune(text))
+		attachmentSessions.Add(sessionID, a.Name, attachments.Chunk(text, attachments.ChunkSize, attachments.ChunkOverlap))
+	}
+
+	var b strings.Builder
+	var sources []Source
+	if len(docs) > 0 && total <= attachments.InlineLimit {
+		b.WriteString("\n\n=== Прикреплённые файлы ===\n")
+		for i, d := range docs {
+			fmt.Fprintf(&b, "[%s]\n%s\n\n", d.name, d.text)
+			sources = append(sources, Source{Title: d.name, Content: truncate(d.text, 100), Score: i + 1})
+		}
+	} else {
+		fragments := attachmentSessions.Search(sessionID, query, attachments.TopK)
+		if len(fragments) == 0 {
+			return "", nil, sessionID, nil
+		}
+		b.WriteString("\n\n=== Фрагменты прикреплённых файлов ===\n")
+		for i, f := range fragments {
+			title := fmt.Sprintf("%s, фрагмент %d/%d", f.File, f.Index, f.Total)
+			fmt.Fprintf(&b, "[%s]\n%s\n\n", title, f.Text)
+			sources = append(sources, Source{Title: title, Content: truncate(f.Text, 100), Score: i + 1})
+		}
 	}
+	b.WriteString("Отвечая по содержимому файлов, указывай источник в квадратных скобках, например [имя файла].\n")
+	slog.Info("Вложения добавлены в контекст", slog.String("сессия", sessionID), slog.Int("файлов", len(docs)), slog.Int("источников", len(sources)))
+	return b.String(), sources, sessionID, nil
+}
 
-	port := getEnv("AGENT_SERVICE_PORT", "8083")
-	slog.Info("Порт agent-service", slog.String("порт", port))
+// attachmentData — содержимое вложения из base64 или каталога загрузок.
+func attachmentData(a ChatAttachment) ([]byte, error) {
+	if a.Name == "" {
+		a.Name = a.File
+	}
+	if a.Data != "" {
+		data, err := base64.StdEncoding.DecodeString(a.Data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: некорректный base64", a.Name)
+		}
+		return data, nil
+	}
+	if a.File == "" {
+		return nil, fmt.Errorf("%s: нет data или file", a.Name)
+	}
+	name := filepath.Base(filepath.Clean("/" + a.File))
+	return os.ReadFile(filepath.Join(config.Current().UploadsDir, name))
+}
 
-	toolsURL := getEnv("TOOLS_SERVICE_URL", "http://localhost:8082")
-	memoryURL := getEnv("MEMORY_SERVICE_URL", "http://localhost:8001")
-	slog.Info("tools-service URL", slog.String("url", toolsURL))
-	slog.Info("memory-service URL", slog.String("url", memoryURL))
+// prepareChatImages — приводит изображения сообщений к base64 и прикрепляет
+// файлы из каталога загрузок (image_files) к последнему сообщению.
+func prepareChatImages(req *ChatRequest) error {
+	for i := range req.Messages {
+		for j, img := range req.Messages[i].Images {
+			normalized, err := vision.Normalize(img)
+			if err != nil {
+				return err
+			}
+			req.Messages[i].Images[j] = normalized
+		}
+	}
+	last := &req.Messages[len(req.Messages)-1]
+	for _, name := range req.ImageFiles {
+		img, err := vision.LoadFile(config.Current().UploadsDir, name)
+		if err != nil {
+			return err
+		}
+		last.Images = append(last.Images, img)
+	}
+	return nil
+}
 
-	ollamaURL := getEnv("OLLAMA_URL", "")
-	if ollamaURL == "" {
-		ollamaURL = getEnv("OLLAMA_HOST", "http://localhost:11434")
+// imageDescriber — замена изображений текстом: OCR (tesseract) и описание
+// резервной мультимодальной моделью (VISION_FALLBACK_PROVIDER/MODEL).
+func imageDescriber() vision.Describer {
+	cfg := config.Current()
+	d := vision.Describer{OCR: vision.TesseractOCR}
+	if cfg.VisionFallbackModel == "" {
+		return d
+	}
+	provider, err := llm.GlobalRegistry.Get(cfg.VisionFallbackProvider)
+	if err != nil {
+		slog.Warn("Провайдер для описания изображений не найден", slog.String("провайдер", cfg.VisionFallbackProvider))
+		return d
 	}
-	slog.Info("Ollama URL", slog.String("url", ollamaURL))
+	d.Provider, d.Model = provider, cfg.VisionFallbackModel
+	return d
+}
 
-	slog.Info("Проверка окружения завершена")
+// intentsHandler — список интентов и включение/отключение для агента.
+//
+//	GET  /intents?agent=admin — интенты в порядке проверки; с agent — поле enabled
+//	POST /intents {"agent": "admin", "intent": "OPEN_APP", "enabled": false}
+func intentsHandler(w http.ResponseWriter, r *http.Request) {
+	cid := r.Header.Get("X-Request-ID")
+	switch r.Method {
+	case http.MethodGet:
+		enabled := intentEnabledFor(r.URL.Query().Get("agent"))
+		list := []map[string]interface{}{}
+		for _, in := range intentRegistry.List() {
+			list = append(list, map[string]interface{}{
+				"name":        in.Name,
+				"description": in.Description,
+				"source":      in.Source,
+				"priority":    in.Priority,
+				"enabled":     enabled == nil || enabled(in.Name),
+			})
+		}
+		writeJSON(w, map[string]interface{}{"intents": list})
+	case http.MethodPost:
+		var req struct {
+			Agent   string `json:"agent"`
+			Intent  string `json:"intent"`
+			Enabled bool   `json:"enabled"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Agent == "" || req.Intent == "" {
+			apierror.BadRequest(w, cid, "Укажите agent, intent и enabled", "")
+			return
+		}
+		if !intentRegistry.Has(req.Intent) {
+			apierror.NotFound(w, cid, "Интент не найден: "+req.Intent)
+			return
+		}
+		var agent models.Agent
+		if err := db.DB.Where("name = ?", req.Agent).First(&agent).Error; err != nil {
+			apierror.NotFound(w, cid, "Агент не найден")
+			return
+		}
+		disabled := intent.ParseNames(agent.DisabledIntents)
+		disabled[req.Intent] = !req.Enabled
+		if err := db.DB.Model(&agent).Update("disabled_intents", intent.JoinNames(disabled)).Error; err != nil {
+			slog.Error("Ошибка сохранения интентов агента", slog.String("агент", req.Agent), slog.String("ошибка", err.Error()), slog.String("request_id", cid))
+			apierror.InternalError(w, cid, "Ошибка сохранения", "")
+			return
+		}
+		slog.Info("Интент агента изменён", slog.String("агент", req.Agent), slog.String("интент", req.Intent), slog.Bool("включён", req.Enabled))
+		writeJSON(w, map[string]interface{}{"status": "ok", "agent": req.Agent, "intent": req.Intent, "enabled": req.Enabled})
+	default:
+		apierror.MethodNotAllowed(w, cid)
+	}
 }
 
-var autoSkillPipeline *skills.AutoSkillPipeline
+// drainer — режим плавной остановки: новые /chat отклоняются, активные дорабатывают.
+var drainer = middleware.NewDrainer()
 
 func main() {
 	validateEnv()
 
 	db.InitDB()
 
+	// Трассировка: W3C traceparent и экспорт спанов в OTLP (OTEL_EXPORTER_OTLP_ENDPOINT)
+	shutdownTracing, err := tracing.Init(context.Background(), "agent-service")
+	if err != nil {
+		slog.Error("Не удалось инициализировать трассировку", slog.String("ошибка", err.Error()))
+		os.Exit(1)
+	}
+
 	llm.InitProviders()
 	initProvidersFromDB()
 	initRAG()
@@ -4188,6 +12385,40 @@ func main() {
 	skillsDir := filepath.Join(".", "skills")
 	os.MkdirAll(skillsDir, 0755)
 	autoSkillPipeline = skills.NewAutoSkillPipeline(skillsDir, 3)
+	initIntents()
+	initRisk()
+	initRecipes()
+	initWatchdog()
+	initBatch()
+	initConcurrency()
+	initToolsRPC()
+	initBrowserRPC()
+	initJobs()
+	repoMaps = repomap.NewStore(db.DB)
+	if cfg, err := kube.LoadConfig(); err == nil {
+		kubeClient = kube.New(cfg)
+		slog.Info("Kubernetes подключён", slog.String("контекст", cfg.Context), slog.String("namespace", cfg.Namespace), slog.Bool("только_чтение", cfg.ReadOnly))
+	} else if !errors.Is(err, kube.ErrNoConfig) {
+		slog.Warn("Kubernetes: kubeconfig не загружен", slog.String("ошибка", err.Error()))
+	}
+
+	// Политика хранения системного лога: фоновая очистка с архивированием
+	logPruner = &logstore.Pruner{DB: db.DB, Cfg: logstore.LoadRetentionConfig(), Upload: uploadLogArchive}
+	pruneCtx, stopPruner := context.WithCancel(context.Background())
+	defer stopPruner()
+	go logPruner.Run(pruneCtx)
+	// Копии файлов до правок агентов хранятся BACKUP_RETENTION
+	go backup.RunPruner(pruneCtx, db.DB, func() time.Duration { return config.Current().BackupRetention })
+	go serviceWatchdog.Run(pruneCtx, func() time.Duration { return config.Current().WatchdogInterval }, func() []watchdog.Service { return watchedServices })
+	go maintenance.Schedule(pruneCtx, func() string { return config.Current().MaintenanceTime }, func(ctx context.Context) {
+		if _, err := runMaintenance(ctx); err != nil {
+			slog.Warn("Ночное обслуживание пропущено", slog.String("ошибка", err.Error()))
+		}
+	})
+	if jobQueue != nil {
+		go jobqueue.RunPruner(pruneCtx, db.DB, func() time.Duration { return config.Current().JobsRetention })
+	}
+	go modelWatcher.Run(pruneCtx, func() time.Duration { return config.Current().ModelRefreshInterval }, watchedProviders)
 	slog.Info("Конвейер auto-skill инициализирован", slog.String("директория", skillsDir))
 
 	if err := repository.CreateDefaultAgents(); err != nil {
@@ -4195,6 +12426,13 @@ func main() {
 		os.Exit(1)
 	}
 
+	// Прогрев моделей агентов: первый запрос в чат не ждёт холодной загрузки
+	if warmup.LoadConfig().Enabled {
+		go warmAgentModels(agentOllamaModels())
+	} else {
+		modelWarmer.SetPinned(agentOllamaModels())
+	}
+
 	// Регистрация метрик endpoint (должна быть перед catch-all роутером)
 	http.HandleFunc("/metrics", requestIDMiddleware(func(w http.ResponseWriter, r *http.Request) {
 		h := metrics.InitPrometheusHandler()
@@ -4202,20 +12440,71 @@ func main() {
 	}))
 
 	http.HandleFunc("/health", requestIDMiddleware(healthHandler))
-	http.HandleFunc("/chat", requestIDMiddleware(chatHandler))
+	http.HandleFunc("/ready", requestIDMiddleware(health.Handler("agent-service", readinessChecks)))
+	http.HandleFunc("/chat", requestIDMiddleware(drainer.Track(limitConcurrency("/chat", chatHandler))))
+	http.HandleFunc("/chat/audio", requestIDMiddleware(drainer.Track(limitConcurrency("/chat/audio", chatAudioHandler))))
+	http.HandleFunc("/chat/speculative", requestIDMiddleware(drainer.Track(limitConcurrency("/chat/speculative", chatSpeculativeHandler))))
+	http.HandleFunc("/chat/batch", requestIDMiddleware(chatBatchHandler))
+	http.HandleFunc("/chat/regenerate", requestIDMiddleware(drainer.Track(limitConcurrency("/chat/regenerate", chatRegenerateHandler))))
+	http.HandleFunc("/chat/batch/", requestIDMiddleware(chatBatchJobHandler))
+	http.HandleFunc("/jobs", requestIDMiddleware(jobsHandler))
+	http.HandleFunc("/jobs/", requestIDMiddleware(jobHandler))
+	http.HandleFunc("/chat/replay", requestIDMiddleware(drainer.Track(limitConcurrency("/chat/replay", chatReplayHandler))))
+	http.HandleFunc("/chat/recordings", requestIDMiddleware(chatRecordingsHandler))
+	http.HandleFunc("/chat/recordings/", requestIDMiddleware(chatRecordingHandler))
+	http.HandleFunc("/chat/", requestIDMiddleware(chatEventsHandler))
 	http.HandleFunc("/agents", requestIDMiddleware(agentsHandler))
+	http.HandleFunc("/agents/", requestIDMiddleware(agentResourceHandler))
 	http.HandleFunc("/models", requestIDMiddleware(modelsHandler))
+	http.HandleFunc("/models/loaded", requestIDMiddleware(modelsLoadedHandler))
+	http.HandleFunc("/models/benchmark", requestIDMiddleware(modelsBenchmarkHandler))
 	http.HandleFunc("/prompts", requestIDMiddleware(promptsHandler))
 	http.HandleFunc("/prompts/load", requestIDMiddleware(loadPromptHandler))
+	http.HandleFunc("/prompts/export", requestIDMiddleware(promptExportHandler))
+	http.HandleFunc("/prompts/import", requestIDMiddleware(promptImportHandler))
+	http.HandleFunc("/prompts/files", requestIDMiddleware(promptFilesHandler))
+	http.HandleFunc("/prompts/files/", requestIDMiddleware(promptFilesHandler))
 	http.HandleFunc("/agent/prompt", requestIDMiddleware(updatePromptHandler))
 	http.HandleFunc("/update-model", requestIDMiddleware(updateAgentModelHandler))
 	http.HandleFunc("/avatar", requestIDMiddleware(avatarUploadHandler))
 	http.HandleFunc("/avatar-info", requestIDMiddleware(avatarGetHandler))
 	http.HandleFunc("/providers", requestIDMiddleware(providersHandler))
+	http.HandleFunc("/providers/", requestIDMiddleware(providerResourceHandler))
 	http.HandleFunc("/cloud-models", requestIDMiddleware(cloudModelsHandler))
+	http.HandleFunc("/ollama/pull", requestIDMiddleware(ollamaPullHandler))
+	http.HandleFunc("/ollama/delete", requestIDMiddleware(ollamaDeleteHandler))
+	http.HandleFunc("/ollama/ps", requestIDMiddleware(ollamaPsHandler))
 	http.HandleFunc("/workspaces", requestIDMiddleware(workspacesHandler))
+	http.HandleFunc("/env-vars", requestIDMiddleware(envVarsHandler))
+	http.HandleFunc("/env-vars/", requestIDMiddleware(envVarsHandler))
+	http.HandleFunc("/watchdog", requestIDMiddleware(watchdogHandler))
+	http.HandleFunc("/watchdog/", requestIDMiddleware(watchdogHandler))
+	http.HandleFunc("/maintenance", requestIDMiddleware(maintenanceHandler))
+	http.HandleFunc("/maintenance/", requestIDMiddleware(maintenanceHandler))
+	http.HandleFunc("/admin/backup", requestIDMiddleware(adminBackupHandler))
+	http.HandleFunc("/admin/backup/", requestIDMiddleware(adminBackupHandler))
+	http.HandleFunc("/admin/restore", requestIDMiddleware(adminRestoreHandler))
+	http.HandleFunc("/workspace/", requestIDMiddleware(workspaceSymbolsHandler))
+	http.HandleFunc("/conversations", requestIDMiddleware(conversationsHandler))
+	http.HandleFunc("/artifacts", requestIDMiddleware(artifactsHandler))
+	http.HandleFunc("/artifacts/", requestIDMiddleware(artifactHandler))
+	http.HandleFunc("/rollback", requestIDMiddleware(rollbackHandler))
+	http.HandleFunc("/rollback/", requestIDMiddleware(rollbackItemHandler))
+	http.HandleFunc("/conversations/", requestIDMiddleware(conversationHandler))
+	http.HandleFunc("/tasks", requestIDMiddleware(tasksHandler))
+	http.HandleFunc("/tasks/", requestIDMiddleware(taskHandler))
 	http.HandleFunc("/learning-stats", requestIDMiddleware(learningStatsHandler))
+	http.HandleFunc("/learnings/", requestIDMiddleware(learningsHandler))
 	http.HandleFunc("/logs", requestIDMiddleware(logsHandler))
+	http.HandleFunc("/config", requestIDMiddleware(configHandler))
+	http.HandleFunc("/config/reload", requestIDMiddleware(configReloadHandler))
+	http.HandleFunc("/feedback", requestIDMiddleware(feedbackHandler))
+	http.HandleFunc("/feedback/stats", requestIDMiddleware(feedbackStatsHandler))
+	http.HandleFunc("/usage", requestIDMiddleware(usageHandler))
+	http.HandleFunc("/usage/budgets", requestIDMiddleware(usageBudgetsHandler))
+	http.HandleFunc("/response-cache", requestIDMiddleware(responseCacheHandler))
+	http.HandleFunc("/router/stats", requestIDMiddleware(routerStatsHandler))
+	http.HandleFunc("/intents", requestIDMiddleware(intentsHandler))
 
 	http.HandleFunc("/scenario-metrics", requestIDMiddleware(metrics.ScenarioMetricsHandler))
 	http.HandleFunc("/autoskill/patterns", requestIDMiddleware(autoskillPatternsHandler))
@@ -4229,7 +12518,12 @@ func main() {
 	http.HandleFunc("/rag/search", requestIDMiddleware(ragSearchHandler))
 	http.HandleFunc("/rag/files", requestIDMiddleware(ragFilesHandler))
 	http.HandleFunc("/rag/stats", requestIDMiddleware(ragStatsHandler))
+	http.HandleFunc("/rag/admin/", requestIDMiddleware(ragAdminHandler))
 	http.HandleFunc("/rag/delete", requestIDMiddleware(ragDeleteHandler))
+	http.HandleFunc("/rag/purge", requestIDMiddleware(ragPurgeHandler))
+	http.HandleFunc("/rag/ask", requestIDMiddleware(ragAskHandler))
+	http.HandleFunc("/rag/versions", requestIDMiddleware(ragVersionsHandler))
+	http.HandleFunc("/rag/versions/diff", requestIDMiddleware(ragVersionsHandler))
 
 	// RAG эндпоинты — расширенные операции (проксирование в memory-service)
 	http.HandleFunc("/rag/move", requestIDMiddleware(ragMoveHandler))
@@ -4246,6 +12540,9 @@ func main() {
 	http.HandleFunc("/skills/search", requestIDMiddleware(skillSearchHandler))
 	http.HandleFunc("/skills/from-dialog", requestIDMiddleware(skillFromDialogHandler))
 	http.HandleFunc("/skills/", requestIDMiddleware(skillByIDHandler))
+	// Составные скилы (рецепты): установка, версии и включение по агентам
+	http.HandleFunc("/skills/compound", requestIDMiddleware(compoundSkillsHandler))
+	http.HandleFunc("/skills/compound/", requestIDMiddleware(compoundSkillsHandler))
 
 	// Graph Engine эндпоинты — проксирование в memory-service (Eternal RAG: раздел 5.4)
 	http.HandleFunc("/graph/relationships", requestIDMiddleware(graphRelationshipsHandler))
@@ -4268,15 +12565,30 @@ func main() {
 	}
 
 	uploadDir := filepath.Join(".", "uploads")
-	http.Handle("/uploads/", requestIDHandler(http.StripPrefix("/uploads/", http.FileServer(http.Dir(uploadDir)))))
+	publicPaths, _ := uploads.ParsePaths(config.Current().UploadsPublicPaths)
+	http.Handle("/uploads/", requestIDHandler(http.StripPrefix("/uploads/", uploads.Handler(uploadDir, publicPaths))))
 
 	http.HandleFunc("/", requestIDMiddleware(rootHandler))
 
-	port := getEnv("AGENT_SERVICE_PORT", "8083")
+	cfg := config.Current()
+	port := cfg.Port
+
+	// Лимиты тела запроса: общий и отдельный для загрузки файлов (RAG, аватары)
+	var handler http.Handler = middleware.BodyLimit(cfg.MaxBodyBytes, cfg.MaxUploadBytes, []string{"/rag/", "/avatar", "/chat/audio"})(http.DefaultServeMux)
+	handler = middleware.Language(handler)
+	handler = tracing.Middleware(handler)
+	if cfg.GzipEnabled {
+		handler = middleware.Gzip(handler)
+	}
+	if cfg.JobsWorkerOnly {
+		handler = workerOnly(handler)
+	}
+	jobsCtx, stopJobs := context.WithCancel(context.Background())
+	jobsDone := startJobs(jobsCtx)
 
 	srv := &http.Server{
 		Addr:         ":" + port,
-		Handler:      nil,
+		Handler:      handler,
 		ReadTimeout:  15 * time.Second,
 		WriteTimeout: 300 * time.Second,
 		IdleTimeout:  60 * time.Second,
@@ -4290,15 +12602,57 @@ func main() {
 		}
 	}()
 
+	// SIGHUP — перезагрузка изменяемых на лету параметров (RAG_TOP_K и др.)
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for range hup {
+			reloadConfig()
+		}
+	}()
+
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	sig := <-quit
 	slog.Info("Получен сигнал завершения", slog.String("сигнал", sig.String()))
 
+	// 1. Режим «хромой утки»: /health отдаёт 503, новые /chat отклоняются,
+	//    активные чаты с циклами инструментов дорабатывают.
+	drainer.StartDraining()
+	slog.Info("Ожидание завершения активных чатов", slog.Int64("активных", drainer.Active()))
+	// Лимит ожидания должен покрывать самый долгий цикл инструментов
+	drainCtx, drainCancel := context.WithTimeout(context.Background(), config.Current().DrainTimeout)
+	if err := drainer.Wait(drainCtx); err != nil {
+		slog.Warn("Не все чаты завершились до истечения лимита", slog.Int64("активных", drainer.Active()))
+	}
+	drainCancel()
+
+	// 2. Закрытие слушателя и дожидание остальных запросов
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 	if err := srv.Shutdown(ctx); err != nil {
 		slog.Error("Ошибка при завершении сервера", slog.String("ошибка", err.Error()))
 	}
+
+	// 3. Фоновые задачи, трассировка и соединения с БД. Прерванные задания
+	//    очереди возвращаются в неё и будут выполнены после запуска.
+	stopJobs()
+	select {
+	case <-jobsDone:
+	case <-ctx.Done():
+		slog.Warn("Не все задания очереди завершились до остановки")
+	}
+	if jobsBroker != nil {
+		if err := jobsBroker.Close(); err != nil {
+			slog.Warn("Ошибка закрытия соединения с брокером заданий", slog.String("ошибка", err.Error()))
+		}
+	}
+	stopPruner()
+	if err := shutdownTracing(ctx); err != nil {
+		slog.Warn("Не удалось отправить оставшиеся спаны", slog.String("ошибка", err.Error()))
+	}
+	if err := db.Close(); err != nil {
+		slog.Warn("Ошибка закрытия соединений с БД", slog.String("ошибка", err.Error()))
+	}
 	slog.Info("Сервер корректно остановлен")
 }
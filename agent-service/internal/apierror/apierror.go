@@ -1,8 +1,18 @@
+// Пакет apierror — единый формат ошибок HTTP API для всех микросервисов.
+//
+// Каждая ошибка содержит машиночитаемый code, текст message, подсказку hint,
+// идентификатор корреляции request_id и признак retryable. Одинаковая копия
+// формата живёт в api-gateway, tools-service, browser-service и agent-service,
+// поэтому ответы любого сервиса разбираются одной функцией Parse.
 package apierror
 
 import (
 	"encoding/json"
 	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/neo-2022/openclaw-memory/agent-service/internal/i18n"
 )
 
 type Response struct {
@@ -13,7 +23,13 @@ type Response struct {
 	Retryable bool   `json:"retryable"`
 }
 
+// Write — отправляет ошибку. Message и Hint переводятся на язык запроса из
+// заголовка ответа Content-Language (его выставляет middleware.Language).
 func Write(w http.ResponseWriter, status int, resp Response) {
+	if lang := w.Header().Get("Content-Language"); lang != "" {
+		resp.Message = i18n.Translate(lang, resp.Message)
+		resp.Hint = i18n.Translate(lang, resp.Hint)
+	}
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(status)
 	json.NewEncoder(w).Encode(resp)
@@ -76,3 +92,93 @@ func ToolError(w http.ResponseWriter, requestID, message, hint string) {
 		Retryable: true,
 	})
 }
+
+func ServiceUnavailable(w http.ResponseWriter, requestID, message, hint string) {
+	Write(w, http.StatusServiceUnavailable, Response{
+		Code:      "SERVICE_UNAVAILABLE",
+		Message:   message,
+		Hint:      hint,
+		RequestID: requestID,
+		Retryable: true,
+	})
+}
+
+func PayloadTooLarge(w http.ResponseWriter, requestID, message, hint string) {
+	Write(w, http.StatusRequestEntityTooLarge, Response{
+		Code:      "PAYLOAD_TOO_LARGE",
+		Message:   message,
+		Hint:      hint,
+		RequestID: requestID,
+		Retryable: false,
+	})
+}
+
+// CodeForStatus — возвращает машиночитаемый код ошибки по HTTP-статусу.
+// Используется, когда удалённый сервис не прислал собственный code.
+func CodeForStatus(status int) string {
+	switch status {
+	case http.StatusBadRequest:
+		return "BAD_REQUEST"
+	case http.StatusUnauthorized:
+		return "UNAUTHORIZED"
+	case http.StatusForbidden:
+		return "FORBIDDEN"
+	case http.StatusNotFound:
+		return "NOT_FOUND"
+	case http.StatusMethodNotAllowed:
+		return "METHOD_NOT_ALLOWED"
+	case http.StatusRequestEntityTooLarge:
+		return "PAYLOAD_TOO_LARGE"
+	case http.StatusTooManyRequests:
+		return "RATE_LIMITED"
+	case http.StatusBadGateway:
+		return "BAD_GATEWAY"
+	case http.StatusServiceUnavailable:
+		return "SERVICE_UNAVAILABLE"
+	case http.StatusGatewayTimeout:
+		return "GATEWAY_TIMEOUT"
+	}
+	if status >= 500 {
+		return "INTERNAL_ERROR"
+	}
+	return "HTTP_" + strconv.Itoa(status)
+}
+
+// Parse — разбирает тело ответа с ошибкой от другого сервиса в единый формат.
+//
+// Понимает три варианта тела:
+//   - структурированный Response ({"code", "message", "hint", "request_id"});
+//   - устаревший формат {"error": "..."};
+//   - произвольный текст (обрезается до 500 символов).
+//
+// Если сервис не прислал code или request_id, они заполняются по статусу
+// и заголовку X-Request-ID соответственно.
+func Parse(status int, header http.Header, body []byte) Response {
+	var resp Response
+	if err := json.Unmarshal(body, &resp); err != nil || resp.Message == "" {
+		resp = Response{}
+		var legacy struct {
+			Error string `json:"error"`
+		}
+		if json.Unmarshal(body, &legacy) == nil && legacy.Error != "" {
+			resp.Message = legacy.Error
+		} else {
+			text := strings.TrimSpace(string(body))
+			if r := []rune(text); len(r) > 500 {
+				text = string(r[:500]) + "..."
+			}
+			resp.Message = text
+		}
+		resp.Retryable = status >= 500 || status == http.StatusTooManyRequests
+	}
+	if resp.Message == "" {
+		resp.Message = http.StatusText(status)
+	}
+	if resp.Code == "" {
+		resp.Code = CodeForStatus(status)
+	}
+	if resp.RequestID == "" && header != nil {
+		resp.RequestID = header.Get("X-Request-ID")
+	}
+	return resp
+}
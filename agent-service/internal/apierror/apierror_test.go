@@ -0,0 +1,56 @@
+package apierror
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestParse_Structured(t *testing.T) {
+	body := []byte(`{"code":"FORBIDDEN","message":"недостаточно прав","hint":"нужна роль admin","request_id":"tools-1","retryable":false}`)
+	resp := Parse(http.StatusForbidden, nil, body)
+	if resp.Code != "FORBIDDEN" || resp.Message != "недостаточно прав" {
+		t.Fatalf("неверный разбор: %+v", resp)
+	}
+	if resp.Hint != "нужна роль admin" || resp.RequestID != "tools-1" {
+		t.Fatalf("потеряны hint/request_id: %+v", resp)
+	}
+}
+
+func TestParse_LegacyError(t *testing.T) {
+	h := http.Header{}
+	h.Set("X-Request-ID", "req-42")
+	resp := Parse(http.StatusBadGateway, h, []byte(`{"error":"сервис недоступен"}`))
+	if resp.Message != "сервис недоступен" {
+		t.Fatalf("ожидалось сообщение из поля error, получено %q", resp.Message)
+	}
+	if resp.Code != "BAD_GATEWAY" {
+		t.Fatalf("ожидался код BAD_GATEWAY, получен %q", resp.Code)
+	}
+	if !resp.Retryable {
+		t.Fatal("ошибка 502 должна быть retryable")
+	}
+	if resp.RequestID != "req-42" {
+		t.Fatalf("ожидался request_id из заголовка, получен %q", resp.RequestID)
+	}
+}
+
+func TestParse_PlainText(t *testing.T) {
+	resp := Parse(http.StatusNotFound, nil, []byte("404 page not found\n"))
+	if resp.Message != "404 page not found" || resp.Code != "NOT_FOUND" || resp.Retryable {
+		t.Fatalf("неверный разбор текста: %+v", resp)
+	}
+	empty := Parse(http.StatusInternalServerError, nil, nil)
+	if empty.Message == "" || empty.Code != "INTERNAL_ERROR" {
+		t.Fatalf("пустое тело должно давать текст статуса: %+v", empty)
+	}
+}
+
+func TestWrite_RoundTrip(t *testing.T) {
+	rr := httptest.NewRecorder()
+	ToolError(rr, "req-1", "инструмент упал", "повторите позже")
+	resp := Parse(rr.Code, rr.Header(), rr.Body.Bytes())
+	if resp.Code != "TOOL_ERROR" || resp.RequestID != "req-1" || !resp.Retryable {
+		t.Fatalf("неверный круговой разбор: %+v", resp)
+	}
+}
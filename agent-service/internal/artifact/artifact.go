@@ -0,0 +1,156 @@
+// Package artifact — артефакты работы агента.
+//
+// Store — полные тексты больших результатов инструментов. Результат длиннее
+// лимита (содержимое файла, DOM страницы, вывод команды) не попадает
+// в контекст модели целиком: модель получает начало и конец, а полный текст
+// сохраняется как артефакт. Нужные подробности модель дочитывает
+// инструментом get_artifact — по смещению или по строкам, содержащим
+// заданный текст. Так раунды инструментов укладываются в контекст,
+// а доступ к деталям сохраняется.
+//
+// Files — файлы, созданные инструментами (скриншоты, PDF, отчёты, скрипты),
+// см. files.go; список и скачивание — /artifacts.
+package artifact
+
+import (
+	"container/list"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// Artifact — полный результат инструмента.
+type Artifact struct {
+	ID        string
+	Tool      string
+	Content   string
+	CreatedAt time.Time
+}
+
+// Store — артефакты в памяти: не больше MaxEntries, каждый живёт TTL.
+type Store struct {
+	mu      sync.Mutex
+	items   map[string]*list.Element
+	order   *list.List // Начало — новые
+	now     func() time.Time
+	ttl     time.Duration
+	maximum int
+}
+
+// NewStore — хранилище на maxEntries артефактов с временем жизни ttl.
+func NewStore(maxEntries int, ttl time.Duration) *Store {
+	if maxEntries < 1 {
+		maxEntries = 1
+	}
+	return &Store{items: map[string]*list.Element{}, order: list.New(), now: time.Now, ttl: ttl, maximum: maxEntries}
+}
+
+// Put — сохраняет результат инструмента, возвращает идентификатор артефакта.
+func (s *Store) Put(tool, content string) string {
+	a := &Artifact{ID: newID(), Tool: tool, Content: content, CreatedAt: s.now()}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.items[a.ID] = s.order.PushFront(a)
+	for s.order.Len() > s.maximum {
+		s.remove(s.order.Back())
+	}
+	return a.ID
+}
+
+// Get — артефакт по идентификатору; просроченный удаляется.
+func (s *Store) Get(id string) (Artifact, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	el, ok := s.items[id]
+	if !ok {
+		return Artifact{}, false
+	}
+	a := el.Value.(*Artifact)
+	if s.ttl > 0 && s.now().Sub(a.CreatedAt) > s.ttl {
+		s.remove(el)
+		return Artifact{}, false
+	}
+	return *a, true
+}
+
+// Len — число хранимых артефактов.
+func (s *Store) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.order.Len()
+}
+
+func (s *Store) remove(el *list.Element) {
+	delete(s.items, el.Value.(*Artifact).ID)
+	s.order.Remove(el)
+}
+
+// Truncate — начало и конец text общей длиной около limit символов
+// с отметкой о пропущенной середине. Граница сдвигается к переводу строки,
+// если он недалеко. false — текст короче лимита и возвращён как есть.
+func Truncate(text string, limit int) (string, bool) {
+	total := utf8.RuneCountInString(text)
+	if limit <= 0 || total <= limit {
+		return text, false
+	}
+	runes := []rune(text)
+	headLen := limit * 2 / 3
+	tailLen := limit - headLen
+	head := string(runes[:headLen])
+	tail := string(runes[total-tailLen:])
+	if i := strings.LastIndexByte(head, '\n'); i > len(head)*3/4 {
+		head = head[:i+1]
+	}
+	if i := strings.IndexByte(tail, '\n'); i >= 0 && i < len(tail)/4 {
+		tail = tail[i+1:]
+	}
+	skipped := total - utf8.RuneCountInString(head) - utf8.RuneCountInString(tail)
+	return fmt.Sprintf("%s\n… пропущено %d симв. …\n%s", head, skipped, tail), true
+}
+
+// Slice — фрагмент текста: length символов начиная с offset. Возвращает
+// фрагмент и число оставшихся после него символов.
+func Slice(text string, offset, length int) (string, int) {
+	runes := []rune(text)
+	if offset < 0 {
+		offset = 0
+	}
+	if offset > len(runes) {
+		offset = len(runes)
+	}
+	end := len(runes)
+	if length > 0 && offset+length < end {
+		end = offset + length
+	}
+	return string(runes[offset:end]), len(runes) - end
+}
+
+// Grep — строки, содержащие query (без учёта регистра), с номерами строк;
+// не больше limit символов. Второе значение — число найденных строк.
+func Grep(text, query string, limit int) (string, int) {
+	q := strings.ToLower(query)
+	var b strings.Builder
+	found := 0
+	for i, line := range strings.Split(text, "\n") {
+		if !strings.Contains(strings.ToLower(line), q) {
+			continue
+		}
+		found++
+		entry := fmt.Sprintf("%d: %s\n", i+1, line)
+		if limit > 0 && utf8.RuneCountInString(b.String())+utf8.RuneCountInString(entry) > limit {
+			continue
+		}
+		b.WriteString(entry)
+	}
+	return b.String(), found
+}
+
+func newID() string {
+	b := make([]byte, 6)
+	rand.Read(b)
+	return "art-" + hex.EncodeToString(b)
+}
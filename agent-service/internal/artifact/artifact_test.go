@@ -0,0 +1,129 @@
+package artifact
+
+import (
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestTruncate — короткий текст не меняется, длинный сокращается до начала и конца.
+func TestTruncate(t *testing.T) {
+	if out, cut := Truncate("короткий", 100); cut || out != "короткий" {
+		t.Errorf("короткий текст: %q %v", out, cut)
+	}
+	var lines []string
+	for i := 0; i < 200; i++ {
+		lines = append(lines, strings.Repeat("х", 20))
+	}
+	lines[0], lines[199] = "НАЧАЛО", "КОНЕЦ"
+	out, cut := Truncate(strings.Join(lines, "\n"), 300)
+	if !cut || !strings.HasPrefix(out, "НАЧАЛО") || !strings.HasSuffix(out, "КОНЕЦ") || !strings.Contains(out, "пропущено") {
+		t.Errorf("усечение: %q", out)
+	}
+	if n := len([]rune(out)); n > 340 {
+		t.Errorf("длина %d, лимит 300", n)
+	}
+}
+
+// TestSliceGrep — чтение фрагмента по смещению и поиск строк.
+func TestSliceGrep(t *testing.T) {
+	text := "первая\nвторая ERROR\nтретья\nчетвёртая error"
+	if part, rest := Slice(text, 7, 6); part != "вторая" || rest != len([]rune(text))-13 {
+		t.Errorf("Slice: %q %d", part, rest)
+	}
+	if part, rest := Slice(text, 1000, 10); part != "" || rest != 0 {
+		t.Errorf("смещение за концом: %q %d", part, rest)
+	}
+	if out, n := Grep(text, "error", 0); n != 2 || !strings.Contains(out, "2: вторая ERROR") || !strings.Contains(out, "4: четвёртая error") {
+		t.Errorf("Grep: %q %d", out, n)
+	}
+}
+
+// TestStore — вытеснение старых и истечение артефактов.
+func TestStore(t *testing.T) {
+	now := time.Now()
+	s := NewStore(2, time.Hour)
+	s.now = func() time.Time { return now }
+	a := s.Put("read", "a")
+	b := s.Put("read", "b")
+	s.Put("read", "c")
+	if _, ok := s.Get(a); ok || s.Len() != 2 {
+		t.Error("старейший артефакт должен быть вытеснен")
+	}
+	if got, ok := s.Get(b); !ok || got.Content != "b" || got.Tool != "read" {
+		t.Errorf("артефакт b: %+v", got)
+	}
+	now = now.Add(2 * time.Hour)
+	if _, ok := s.Get(b); ok {
+		t.Error("артефакт должен истечь")
+	}
+}
+
+// TestFiles — сохранение, лимит размера и защита ключа от выхода из каталога.
+func TestFiles(t *testing.T) {
+	f := &Files{Dir: t.TempDir(), MaxBytes: 10}
+	st, err := f.Save("/tmp/screenshot_1.PNG", strings.NewReader("png-data"))
+	if err != nil || st.Size != 8 || !strings.HasSuffix(st.Key, ".png") || len(st.SHA256) != 64 {
+		t.Fatalf("Save: %+v %v", st, err)
+	}
+	path, err := f.Path(st.Key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "png-data" {
+		t.Errorf("содержимое: %q", data)
+	}
+	if _, err := f.Save("big.txt", strings.NewReader(strings.Repeat("x", 11))); err != ErrTooLarge {
+		t.Errorf("ожидалась ErrTooLarge, получено %v", err)
+	}
+	if entries, _ := os.ReadDir(f.Dir); len(entries) != 1 {
+		t.Errorf("слишком большой файл не должен остаться: %d файлов", len(entries))
+	}
+	for _, key := range []string{"../etc/passwd", "", ".hidden"} {
+		if _, err := f.Path(key); err == nil {
+			t.Errorf("ключ %q должен быть отклонён", key)
+		}
+	}
+	if err := f.Remove(st.Key); err != nil || f.Remove(st.Key) != nil {
+		t.Errorf("Remove: %v", err)
+	}
+	if MimeType("report.pdf") != "application/pdf" || Kind(MimeType("a.png")) != "image" || Kind(MimeType("run.sh")) != "text" {
+		t.Error("MimeType/Kind")
+	}
+}
+
+// TestSigner — подписанная ссылка действует до expires и только для своего артефакта.
+func TestSigner(t *testing.T) {
+	s := &Signer{Secret: []byte("secret")}
+	now := time.Unix(1_700_000_000, 0)
+	link, expires := s.URL(42, time.Minute, now)
+	if !expires.Equal(now.Add(time.Minute)) || !strings.HasPrefix(link, "/artifacts/42/download?") {
+		t.Fatalf("URL: %s %v", link, expires)
+	}
+	u, err := url.Parse(link)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := u.Query()
+	if err := s.Verify(42, q, now); err != nil {
+		t.Errorf("действующая ссылка отклонена: %v", err)
+	}
+	if err := s.Verify(42, q, now.Add(2*time.Minute)); err != ErrExpired {
+		t.Errorf("ожидалась ErrExpired, получено %v", err)
+	}
+	if err := s.Verify(43, q, now); err != ErrBadSignature {
+		t.Errorf("подпись чужого артефакта: %v", err)
+	}
+	if err := (&Signer{Secret: []byte("other")}).Verify(42, q, now); err != ErrBadSignature {
+		t.Errorf("подпись другим ключом: %v", err)
+	}
+	q.Set("expires", "1800000000")
+	if err := s.Verify(42, q, now); err != ErrBadSignature {
+		t.Errorf("продлённый срок должен ломать подпись: %v", err)
+	}
+	if err := s.Verify(42, url.Values{}, now); err != ErrBadSignature {
+		t.Errorf("ссылка без подписи: %v", err)
+	}
+}
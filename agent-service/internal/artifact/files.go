@@ -0,0 +1,109 @@
+package artifact
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ErrTooLarge — файл больше лимита хранилища.
+var ErrTooLarge = errors.New("файл больше допустимого размера")
+
+// Files — копии файлов, созданных инструментами агента (скриншоты, PDF,
+// отчёты, скрипты). Файлы лежат в Dir под случайными именами: наружу
+// отдаются по идентификатору записи, а не по пути в файловой системе.
+type Files struct {
+	Dir      string
+	MaxBytes int64 // Не больше стольких байт на файл (0 — без лимита)
+}
+
+// Stored — сохранённая копия файла.
+type Stored struct {
+	Key    string // Имя файла в Dir
+	Size   int64
+	SHA256 string
+}
+
+// Save — копирует содержимое r в хранилище; name нужен только для расширения.
+func (f *Files) Save(name string, r io.Reader) (Stored, error) {
+	if err := os.MkdirAll(f.Dir, 0o755); err != nil {
+		return Stored{}, fmt.Errorf("каталог артефактов: %w", err)
+	}
+	key := strings.TrimPrefix(newID(), "art-") + strings.ToLower(filepath.Ext(name))
+	path := filepath.Join(f.Dir, key)
+	out, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return Stored{}, err
+	}
+	hash := sha256.New()
+	src := r
+	if f.MaxBytes > 0 {
+		src = io.LimitReader(r, f.MaxBytes+1)
+	}
+	n, err := io.Copy(io.MultiWriter(out, hash), src)
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil && f.MaxBytes > 0 && n > f.MaxBytes {
+		err = ErrTooLarge
+	}
+	if err != nil {
+		os.Remove(path)
+		return Stored{}, err
+	}
+	return Stored{Key: key, Size: n, SHA256: hex.EncodeToString(hash.Sum(nil))}, nil
+}
+
+// Path — путь к сохранённому файлу; key из Save, выход за пределы Dir запрещён.
+func (f *Files) Path(key string) (string, error) {
+	if key == "" || key != filepath.Base(key) || strings.HasPrefix(key, ".") {
+		return "", fmt.Errorf("некорректный ключ артефакта %q", key)
+	}
+	return filepath.Join(f.Dir, key), nil
+}
+
+// Remove — удаляет сохранённый файл; отсутствующий файл не ошибка.
+func (f *Files) Remove(key string) error {
+	path, err := f.Path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// MimeType — тип содержимого по расширению имени файла.
+func MimeType(name string) string {
+	ext := strings.ToLower(filepath.Ext(name))
+	switch ext {
+	case ".md":
+		return "text/markdown; charset=utf-8"
+	case ".go", ".py", ".sh", ".js", ".ts", ".rs", ".yaml", ".yml", ".toml", ".sql", ".log":
+		return "text/plain; charset=utf-8"
+	}
+	if t := mime.TypeByExtension(ext); t != "" {
+		return t
+	}
+	return "application/octet-stream"
+}
+
+// Kind — вид файла для интерфейса: image, pdf, text или file.
+func Kind(mimeType string) string {
+	switch {
+	case strings.HasPrefix(mimeType, "image/"):
+		return "image"
+	case strings.HasPrefix(mimeType, "application/pdf"):
+		return "pdf"
+	case strings.HasPrefix(mimeType, "text/"), strings.HasPrefix(mimeType, "application/json"):
+		return "text"
+	}
+	return "file"
+}
@@ -0,0 +1,57 @@
+package artifact
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+var (
+	// ErrBadSignature — подпись ссылки не совпадает или отсутствует.
+	ErrBadSignature = errors.New("неверная подпись ссылки")
+	// ErrExpired — срок действия подписанной ссылки истёк.
+	ErrExpired = errors.New("срок действия ссылки истёк")
+)
+
+// Signer — подписанные ссылки на скачивание артефактов с ограниченным сроком
+// действия: HMAC-SHA256 от идентификатора и времени истечения. Ссылку можно
+// передать туда, где нет заголовка авторизации (тег <img>, другой
+// пользователь), — она перестанет работать после expires.
+type Signer struct {
+	Secret []byte
+}
+
+// Sign — подпись ссылки на артефакт id, действующей до expires.
+func (s *Signer) Sign(id uint, expires time.Time) string {
+	mac := hmac.New(sha256.New, s.Secret)
+	fmt.Fprintf(mac, "%d:%d", id, expires.Unix())
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// URL — подписанный путь /artifacts/{id}/download, действующий ttl.
+func (s *Signer) URL(id uint, ttl time.Duration, now time.Time) (string, time.Time) {
+	expires := now.Add(ttl).Truncate(time.Second)
+	q := url.Values{"expires": {strconv.FormatInt(expires.Unix(), 10)}, "sig": {s.Sign(id, expires)}}
+	return fmt.Sprintf("/artifacts/%d/download?%s", id, q.Encode()), expires
+}
+
+// Verify — проверяет параметры expires и sig ссылки на артефакт id.
+func (s *Signer) Verify(id uint, q url.Values, now time.Time) error {
+	sec, err := strconv.ParseInt(q.Get("expires"), 10, 64)
+	if err != nil || q.Get("sig") == "" {
+		return ErrBadSignature
+	}
+	expires := time.Unix(sec, 0)
+	if !hmac.Equal([]byte(q.Get("sig")), []byte(s.Sign(id, expires))) {
+		return ErrBadSignature
+	}
+	if now.After(expires) {
+		return ErrExpired
+	}
+	return nil
+}
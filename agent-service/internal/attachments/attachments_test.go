@@ -0,0 +1,76 @@
+package attachments
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestExtract — текстовые форматы и DOCX; неизвестные форматы отклоняются.
+func TestExtract(t *testing.T) {
+	if text, err := Extract("notes.md", []byte("# Заметки")); err != nil || text != "# Заметки" {
+		t.Errorf("md: %q, %v", text, err)
+	}
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	f, _ := zw.Create("word/document.xml")
+	f.Write([]byte(`<w:document xmlns:w="w"><w:body><w:p><w:r><w:t>Первый абзац</w:t></w:r></w:p><w:p><w:r><w:t>Второй</w:t><w:tab/><w:t>абзац</w:t></w:r></w:p></w:body></w:document>`))
+	zw.Close()
+	text, err := Extract("report.docx", buf.Bytes())
+	if err != nil || text != "Первый абзац\nВторой\tабзац\n" {
+		t.Errorf("docx: %q, %v", text, err)
+	}
+
+	if _, err := Extract("photo.exe", []byte{0x4d, 0x5a}); !errors.Is(err, ErrUnsupported) {
+		t.Errorf("ожидался ErrUnsupported, получено %v", err)
+	}
+}
+
+// TestChunk — фрагменты не длиннее size, с перекрытием, без потери текста.
+func TestChunk(t *testing.T) {
+	text := strings.Repeat("строка журнала nginx\n", 50)
+	chunks := Chunk(text, 200, 40)
+	if len(chunks) < 5 {
+		t.Fatalf("ожидалось несколько фрагментов, получено %d", len(chunks))
+	}
+	for i, c := range chunks {
+		if len([]rune(c)) > 200 {
+			t.Errorf("фрагмент %d длиннее лимита: %d", i, len([]rune(c)))
+		}
+	}
+	if got := Chunk("короткий", 200, 40); len(got) != 1 || got[0] != "короткий" {
+		t.Errorf("короткий текст: %v", got)
+	}
+}
+
+// TestSessions — поиск по фрагментам сессии и истечение неактивной сессии.
+func TestSessions(t *testing.T) {
+	s := NewSessions(time.Hour)
+	now := time.Now()
+	s.now = func() time.Time { return now }
+	s.Add("s1", "config.yaml", []string{
+		"server: порт 8080, таймаут 30s",
+		"database: postgres на порту 5432, пул 25 соединений",
+		"logging: уровень info, ротация ежедневно",
+	})
+
+	res := s.Search("s1", "Сколько соединений у postgres?", 1)
+	if len(res) != 1 || res[0].Index != 2 || res[0].File != "config.yaml" {
+		t.Fatalf("ожидался фрагмент 2, получено %+v", res)
+	}
+	if res := s.Search("s1", "что там?", 2); len(res) != 2 || res[0].Index != 1 {
+		t.Errorf("без совпадений ожидалось начало документа, получено %+v", res)
+	}
+	if s.Search("other", "порт", 3) != nil {
+		t.Error("фрагменты другой сессии не должны находиться")
+	}
+
+	now = now.Add(2 * time.Hour)
+	if s.Has("s1") {
+		t.Error("неактивная сессия должна истекать")
+	}
+}
@@ -0,0 +1,166 @@
+// Package attachments — документы, прикреплённые к сообщению /chat.
+//
+// Текст извлекается из файла (текстовые форматы, PDF, DOCX). Небольшие
+// документы подставляются в контекст целиком; большие режутся на фрагменты
+// и временно индексируются в хранилище сессии (Sessions), откуда в каждый
+// запрос сессии подставляются только фрагменты, релевантные вопросу.
+package attachments
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Параметры подстановки документов в контекст.
+const (
+	InlineLimit  = 12000         // Документы суммарно не длиннее стольких рун подставляются целиком
+	ChunkSize    = 1500          // Длина фрагмента для индексации, рун
+	ChunkOverlap = 200           // Перекрытие соседних фрагментов
+	TopK         = 4             // Сколько фрагментов сессии подставлять в запрос
+	SessionTTL   = 2 * time.Hour // Время жизни неактивной сессии с документами
+)
+
+// ErrUnsupported — формат файла не поддерживается.
+var ErrUnsupported = errors.New("формат файла не поддерживается")
+
+// textExtensions — форматы, которые читаются как текст.
+var textExtensions = map[string]bool{
+	".txt": true, ".md": true, ".markdown": true, ".rst": true, ".log": true,
+	".json": true, ".jsonl": true, ".csv": true, ".tsv": true,
+	".html": true, ".htm": true, ".xml": true,
+	".yaml": true, ".yml": true, ".toml": true, ".ini": true, ".conf": true, ".env": true,
+	".go": true, ".py": true, ".js": true, ".ts": true, ".java": true, ".c": true,
+	".cpp": true, ".h": true, ".hpp": true, ".rs": true, ".rb": true, ".php": true,
+	".sh": true, ".bash": true, ".sql": true, ".dockerfile": true,
+}
+
+// Extract — текст документа по имени файла и содержимому.
+func Extract(name string, data []byte) (string, error) {
+	ext := strings.ToLower(filepath.Ext(name))
+	switch {
+	case ext == ".pdf":
+		return extractPDF(data)
+	case ext == ".docx":
+		return extractDOCX(data)
+	case textExtensions[ext] || (ext == "" && utf8.Valid(data)):
+		if !utf8.Valid(data) {
+			return "", fmt.Errorf("%s: файл не в кодировке UTF-8", name)
+		}
+		return string(data), nil
+	}
+	return "", fmt.Errorf("%s: %w", name, ErrUnsupported)
+}
+
+// extractPDF — текст PDF через pdftotext (poppler-utils).
+func extractPDF(data []byte) (string, error) {
+	bin, err := exec.LookPath("pdftotext")
+	if err != nil {
+		return "", fmt.Errorf("для PDF нужен pdftotext (poppler-utils): %w", ErrUnsupported)
+	}
+	cmd := exec.Command(bin, "-layout", "-enc", "UTF-8", "-", "-")
+	cmd.Stdin = bytes.NewReader(data)
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("pdftotext: %w", err)
+	}
+	return string(out), nil
+}
+
+// extractDOCX — текст word/document.xml: абзацы <w:p> разделяются переводом строки.
+func extractDOCX(data []byte) (string, error) {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return "", fmt.Errorf("DOCX: %w", err)
+	}
+	for _, f := range zr.File {
+		if f.Name != "word/document.xml" {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return "", err
+		}
+		defer rc.Close()
+		return docxText(rc)
+	}
+	return "", errors.New("DOCX: нет word/document.xml")
+}
+
+func docxText(r io.Reader) (string, error) {
+	var b strings.Builder
+	dec := xml.NewDecoder(r)
+	inText := false
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", fmt.Errorf("DOCX: %w", err)
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "t":
+				inText = true
+			case "tab":
+				b.WriteByte('\t')
+			case "br":
+				b.WriteByte('\n')
+			}
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "t":
+				inText = false
+			case "p":
+				b.WriteByte('\n')
+			}
+		case xml.CharData:
+			if inText {
+				b.Write(t)
+			}
+		}
+	}
+	return b.String(), nil
+}
+
+// Chunk — фрагменты по size рун с перекрытием overlap, по возможности по границе строки.
+func Chunk(text string, size, overlap int) []string {
+	runes := []rune(strings.TrimSpace(text))
+	if len(runes) == 0 {
+		return nil
+	}
+	if size <= 0 || len(runes) <= size {
+		return []string{string(runes)}
+	}
+	if overlap < 0 || overlap >= size {
+		overlap = 0
+	}
+	var chunks []string
+	for start := 0; start < len(runes); {
+		end := start + size
+		if end >= len(runes) {
+			chunks = append(chunks, string(runes[start:]))
+			break
+		}
+		// Ищем перевод строки во второй половине фрагмента
+		for i := end; i > start+size/2; i-- {
+			if runes[i-1] == '\n' {
+				end = i
+				break
+			}
+		}
+		chunks = append(chunks, strings.TrimSpace(string(runes[start:end])))
+		start = end - overlap
+	}
+	return chunks
+}
@@ -0,0 +1,165 @@
+package attachments
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+// Fragment — фрагмент прикреплённого документа.
+type Fragment struct {
+	File  string  `json:"file"`  // Имя файла
+	Index int     `json:"index"` // Номер фрагмента в файле (с 1)
+	Total int     `json:"total"` // Всего фрагментов в файле
+	Text  string  `json:"text"`
+	Score float64 `json:"score"` // Релевантность запросу (BM25)
+}
+
+type indexed struct {
+	Fragment
+	terms map[string]int
+	size  int
+}
+
+type session struct {
+	fragments []indexed
+	touched   time.Time
+}
+
+// Sessions — временные коллекции фрагментов документов по сессиям чата.
+// Сессия удаляется, если к ней не обращались дольше TTL.
+type Sessions struct {
+	mu   sync.Mutex
+	ttl  time.Duration
+	data map[string]*session
+	now  func() time.Time
+}
+
+// NewSessions — хранилище с временем жизни неактивной сессии ttl.
+func NewSessions(ttl time.Duration) *Sessions {
+	return &Sessions{ttl: ttl, data: map[string]*session{}, now: time.Now}
+}
+
+// Add — индексирует фрагменты файла в сессии; возвращает их число.
+func (s *Sessions) Add(sessionID, file string, chunks []string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.expireLocked()
+	sess := s.data[sessionID]
+	if sess == nil {
+		sess = &session{}
+		s.data[sessionID] = sess
+	}
+	for i, text := range chunks {
+		terms := map[string]int{}
+		words := tokenize(text)
+		for _, w := range words {
+			terms[w]++
+		}
+		sess.fragments = append(sess.fragments, indexed{
+			Fragment: Fragment{File: file, Index: i + 1, Total: len(chunks), Text: text},
+			terms:    terms,
+			size:     len(words),
+		})
+	}
+	sess.touched = s.now()
+	return len(chunks)
+}
+
+// Has — есть ли в сессии документы.
+func (s *Sessions) Has(sessionID string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.expireLocked()
+	sess := s.data[sessionID]
+	return sess != nil && len(sess.fragments) > 0
+}
+
+// Search — topK фрагментов сессии, наиболее релевантных запросу (BM25).
+// Если в запросе нет совпадающих слов, возвращаются первые фрагменты
+// документов — вопрос вроде «что в файле?» относится к началу.
+func (s *Sessions) Search(sessionID, query string, topK int) []Fragment {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.expireLocked()
+	sess := s.data[sessionID]
+	if sess == nil || len(sess.fragments) == 0 {
+		return nil
+	}
+	sess.touched = s.now()
+
+	const k1, b = 1.2, 0.75
+	n := float64(len(sess.fragments))
+	avg := 0.0
+	for _, f := range sess.fragments {
+		avg += float64(f.size)
+	}
+	avg = math.Max(avg/n, 1)
+	qterms := map[string]bool{}
+	for _, w := range tokenize(query) {
+		qterms[w] = true
+	}
+
+	results := make([]Fragment, 0, len(sess.fragments))
+	for _, f := range sess.fragments {
+		score := 0.0
+		for term := range qterms {
+			tf := float64(f.terms[term])
+			if tf == 0 {
+				continue
+			}
+			df := 0.0
+			for _, other := range sess.fragments {
+				if other.terms[term] > 0 {
+					df++
+				}
+			}
+			idf := math.Log(1 + (n-df+0.5)/(df+0.5))
+			score += idf * tf * (k1 + 1) / (tf + k1*(1-b+b*float64(f.size)/avg))
+		}
+		fr := f.Fragment
+		fr.Score = score
+		results = append(results, fr)
+	}
+	sort.SliceStable(results, func(i, j int) bool { return results[i].Score > results[j].Score })
+	if results[0].Score == 0 {
+		sort.SliceStable(results, func(i, j int) bool { return results[i].Index < results[j].Index })
+	}
+	if topK > 0 && len(results) > topK {
+		results = results[:topK]
+	}
+	return results
+}
+
+// Drop — удаляет сессию.
+func (s *Sessions) Drop(sessionID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.data, sessionID)
+}
+
+func (s *Sessions) expireLocked() {
+	now := s.now()
+	for id, sess := range s.data {
+		if now.Sub(sess.touched) > s.ttl {
+			delete(s.data, id)
+		}
+	}
+}
+
+// tokenize — слова в нижнем регистре длиной от 2 символов.
+func tokenize(s string) []string {
+	fields := strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	words := fields[:0]
+	for _, w := range fields {
+		if len([]rune(w)) >= 2 {
+			words = append(words, w)
+		}
+	}
+	return words
+}
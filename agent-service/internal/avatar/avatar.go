@@ -0,0 +1,270 @@
+// Package avatar — обработка загруженных аватаров агентов.
+//
+// Принимаются только изображения PNG, JPEG, GIF и WebP (тип определяется по
+// содержимому, а не по имени файла). Изображение декодируется, поворачивается
+// по EXIF-ориентации, обрезается до квадрата по центру и сохраняется в
+// стандартных размерах (Sizes) в WebP. Исходный файл не сохраняется, поэтому
+// метаданные (EXIF с координатами съёмки, модель камеры) в аватар не попадают.
+package avatar
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/HugoSmits86/nativewebp"
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+)
+
+// Sizes — стороны квадратных вариантов аватара в пикселях; первый — основной
+// (Agent.Avatar), остальные — миниатюры для списков.
+var Sizes = []int{256, 64}
+
+// MaxPixels — предел размера исходного изображения (защита от «бомб»
+// декомпрессии: маленький файл, огромное изображение в памяти).
+const MaxPixels = 40_000_000
+
+// ErrUnsupported — файл не является изображением поддерживаемого формата.
+var ErrUnsupported = errors.New("поддерживаются изображения PNG, JPEG, GIF и WebP")
+
+var allowedTypes = map[string]bool{
+	"image/png":  true,
+	"image/jpeg": true,
+	"image/gif":  true,
+	"image/webp": true,
+}
+
+// Variant — вариант аватара одного размера в WebP.
+type Variant struct {
+	Size int
+	Data []byte
+}
+
+// Process — проверяет и преобразует загруженный файл в варианты Sizes.
+func Process(data []byte) ([]Variant, error) {
+	if !allowedTypes[http.DetectContentType(data)] {
+		return nil, ErrUnsupported
+	}
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrUnsupported
+	}
+	if cfg.Width <= 0 || cfg.Height <= 0 || cfg.Width*cfg.Height > MaxPixels {
+		return nil, fmt.Errorf("изображение %dx%d: больше %d мегапикселей", cfg.Width, cfg.Height, MaxPixels/1_000_000)
+	}
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("не удалось декодировать изображение: %w", err)
+	}
+	src = orient(src, exifOrientation(data))
+	square := cropSquare(src)
+
+	out := make([]Variant, 0, len(Sizes))
+	for _, size := range Sizes {
+		dst := image.NewNRGBA(image.Rect(0, 0, size, size))
+		draw.CatmullRom.Scale(dst, dst.Bounds(), square, square.Bounds(), draw.Src, nil)
+		var buf bytes.Buffer
+		if err := nativewebp.Encode(&buf, dst, nil); err != nil {
+			return nil, fmt.Errorf("кодирование WebP: %w", err)
+		}
+		out = append(out, Variant{Size: size, Data: buf.Bytes()})
+	}
+	return out, nil
+}
+
+// cropSquare — центральный квадрат изображения.
+func cropSquare(img image.Image) image.Image {
+	b := img.Bounds()
+	side := min(b.Dx(), b.Dy())
+	x0 := b.Min.X + (b.Dx()-side)/2
+	y0 := b.Min.Y + (b.Dy()-side)/2
+	rect := image.Rect(x0, y0, x0+side, y0+side)
+	if sub, ok := img.(interface {
+		SubImage(image.Rectangle) image.Image
+	}); ok {
+		return sub.SubImage(rect)
+	}
+	dst := image.NewNRGBA(image.Rect(0, 0, side, side))
+	draw.Copy(dst, image.Point{}, img, rect, draw.Src, nil)
+	return dst
+}
+
+var unsafeName = regexp.MustCompile(`[^a-zA-Z0-9_-]+`)
+
+// FileName — имя файла варианта size: имя агента без небезопасных символов
+// и хэш содержимого (новый аватар — новый URL, кэш браузера не мешает).
+func FileName(agent string, data []byte, size int) string {
+	sum := sha256.Sum256(data)
+	name := strings.Trim(unsafeName.ReplaceAllString(agent, "_"), "_")
+	if name == "" {
+		name = "agent"
+	}
+	return fmt.Sprintf("%s_%s_%d.webp", name, hex.EncodeToString(sum[:6]), size)
+}
+
+// Save — записывает варианты в dir. Возвращает имена файлов по порядку Sizes;
+// первое — основной аватар.
+func Save(dir, agent string, variants []Variant) ([]string, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	// Имя по основному варианту: у всех размеров общий префикс
+	var main []byte
+	if len(variants) > 0 {
+		main = variants[0].Data
+	}
+	names := make([]string, 0, len(variants))
+	for _, v := range variants {
+		name := FileName(agent, main, v.Size)
+		if err := os.WriteFile(filepath.Join(dir, name), v.Data, 0644); err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+	}
+	return names, nil
+}
+
+// Variants — имена всех размеров аватара по имени основного файла
+// (для старых аватаров с исходным именем — только он сам).
+func Variants(filename string) []string {
+	main := fmt.Sprintf("_%d.webp", Sizes[0])
+	if !strings.HasSuffix(filename, main) {
+		return []string{filename}
+	}
+	base := strings.TrimSuffix(filename, main)
+	out := make([]string, 0, len(Sizes))
+	for _, size := range Sizes {
+		out = append(out, fmt.Sprintf("%s_%d.webp", base, size))
+	}
+	return out
+}
+
+// Remove — удаляет файлы аватара filename из dir, кроме keep. Имена
+// вне dir (с разделителями пути) игнорируются.
+func Remove(dir, filename string, keep ...string) error {
+	if filename == "" || filename != filepath.Base(filename) || filename == "." || filename == ".." {
+		return nil
+	}
+	kept := make(map[string]bool, len(keep))
+	for _, k := range keep {
+		kept[k] = true
+	}
+	var errs []error
+	for _, name := range Variants(filename) {
+		if kept[name] {
+			continue
+		}
+		if err := os.Remove(filepath.Join(dir, name)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// exifOrientation — значение тега Orientation (0x0112) из EXIF в JPEG;
+// 1 — без поворота или тега нет.
+func exifOrientation(data []byte) int {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return 1
+	}
+	for i := 2; i+4 <= len(data); {
+		if data[i] != 0xFF {
+			return 1
+		}
+		marker := data[i+1]
+		if marker == 0xDA || marker == 0xD9 { // Начало данных изображения — EXIF дальше не бывает
+			return 1
+		}
+		length := int(binary.BigEndian.Uint16(data[i+2:]))
+		if length < 2 || i+2+length > len(data) {
+			return 1
+		}
+		seg := data[i+4 : i+2+length]
+		if marker == 0xE1 && len(seg) > 14 && string(seg[:6]) == "Exif\x00\x00" {
+			return tiffOrientation(seg[6:])
+		}
+		i += 2 + length
+	}
+	return 1
+}
+
+// tiffOrientation — Orientation из первого IFD заголовка TIFF.
+func tiffOrientation(tiff []byte) int {
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 1
+	}
+	ifd := int(order.Uint32(tiff[4:]))
+	if ifd+2 > len(tiff) {
+		return 1
+	}
+	n := int(order.Uint16(tiff[ifd:]))
+	for k := 0; k < n; k++ {
+		e := ifd + 2 + k*12
+		if e+12 > len(tiff) {
+			return 1
+		}
+		if order.Uint16(tiff[e:]) == 0x0112 {
+			if v := int(order.Uint16(tiff[e+8:])); v >= 1 && v <= 8 {
+				return v
+			}
+			return 1
+		}
+	}
+	return 1
+}
+
+// orient — изображение в правильной ориентации по значению EXIF Orientation.
+func orient(img image.Image, orientation int) image.Image {
+	if orientation <= 1 || orientation > 8 {
+		return img
+	}
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	dw, dh := w, h
+	if orientation >= 5 {
+		dw, dh = h, w
+	}
+	dst := image.NewNRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			var dx, dy int
+			switch orientation {
+			case 2: // Отражение по горизонтали
+				dx, dy = w-1-x, y
+			case 3: // Поворот на 180°
+				dx, dy = w-1-x, h-1-y
+			case 4: // Отражение по вертикали
+				dx, dy = x, h-1-y
+			case 5: // Транспонирование
+				dx, dy = y, x
+			case 6: // Поворот на 90° по часовой
+				dx, dy = h-1-y, x
+			case 7: // Поперечное отражение
+				dx, dy = h-1-y, w-1-x
+			case 8: // Поворот на 90° против часовой
+				dx, dy = y, w-1-x
+			}
+			dst.Set(dx, dy, img.At(b.Min.X+x, b.Min.Y+y))
+		}
+	}
+	return dst
+}
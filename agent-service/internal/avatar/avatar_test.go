@@ -0,0 +1,144 @@
+package avatar
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/image/webp"
+)
+
+// testImage — w×h: левая половина красная, правая синяя.
+func testImage(w, h int) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			c := color.NRGBA{R: 255, A: 255}
+			if x >= w/2 {
+				c = color.NRGBA{B: 255, A: 255}
+			}
+			img.SetNRGBA(x, y, c)
+		}
+	}
+	return img
+}
+
+// TestProcess — квадратные WebP всех размеров; не изображения отклоняются.
+func TestProcess(t *testing.T) {
+	var buf bytes.Buffer
+	png.Encode(&buf, testImage(300, 200))
+	variants, err := Process(buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(variants) != len(Sizes) {
+		t.Fatalf("вариантов %d", len(variants))
+	}
+	for i, v := range variants {
+		img, err := webp.Decode(bytes.NewReader(v.Data))
+		if err != nil {
+			t.Fatalf("вариант %d: %v", v.Size, err)
+		}
+		if b := img.Bounds(); v.Size != Sizes[i] || b.Dx() != v.Size || b.Dy() != v.Size {
+			t.Errorf("вариант %d: %v", v.Size, img.Bounds())
+		}
+	}
+
+	for name, data := range map[string][]byte{
+		"текст":  []byte("<svg onload=alert(1)>"),
+		"битый":  append([]byte("\x89PNG\r\n\x1a\n"), make([]byte, 32)...),
+		"пустой": nil,
+	} {
+		if _, err := Process(data); err == nil {
+			t.Errorf("%s: файл принят", name)
+		}
+	}
+}
+
+// TestEXIFOrientation — JPEG с Orientation=6 поворачивается, EXIF не сохраняется.
+func TestEXIFOrientation(t *testing.T) {
+	var buf bytes.Buffer
+	jpeg.Encode(&buf, testImage(40, 20), &jpeg.Options{Quality: 95})
+	data := withOrientation(buf.Bytes(), 6)
+	if got := exifOrientation(data); got != 6 {
+		t.Fatalf("ориентация %d", got)
+	}
+	rotated := orient(testImage(40, 20), 6)
+	if b := rotated.Bounds(); b.Dx() != 20 || b.Dy() != 40 {
+		t.Fatalf("после поворота %v", b)
+	}
+	// Левая (красная) половина после поворота по часовой — сверху
+	if r, _, _, _ := rotated.At(10, 2).RGBA(); r < 0xf000 {
+		t.Error("поворот в неверную сторону")
+	}
+	variants, err := Process(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(variants[0].Data, []byte("Exif")) {
+		t.Error("EXIF попал в аватар")
+	}
+}
+
+// withOrientation — JPEG с сегментом APP1 (EXIF, little-endian) с тегом Orientation.
+func withOrientation(jpg []byte, orientation uint16) []byte {
+	tiff := []byte("II*\x00\x08\x00\x00\x00")
+	tiff = binary.LittleEndian.AppendUint16(tiff, 1)
+	tiff = binary.LittleEndian.AppendUint16(tiff, 0x0112)
+	tiff = binary.LittleEndian.AppendUint16(tiff, 3) // SHORT
+	tiff = binary.LittleEndian.AppendUint32(tiff, 1)
+	tiff = binary.LittleEndian.AppendUint16(tiff, orientation)
+	tiff = append(tiff, 0, 0, 0, 0, 0, 0)
+	seg := append([]byte("Exif\x00\x00"), tiff...)
+	app1 := []byte{0xFF, 0xE1}
+	app1 = binary.BigEndian.AppendUint16(app1, uint16(len(seg)+2))
+	app1 = append(app1, seg...)
+	return append(append([]byte{0xFF, 0xD8}, app1...), jpg[2:]...)
+}
+
+// TestSaveAndRemove — при замене удаляются все размеры старого аватара и
+// файл в старом формате; пути вне каталога не трогаются.
+func TestSaveAndRemove(t *testing.T) {
+	dir := t.TempDir()
+	var buf bytes.Buffer
+	png.Encode(&buf, testImage(64, 64))
+	variants, _ := Process(buf.Bytes())
+	names, err := Save(dir, "../admin", variants)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(names) != len(Sizes) || filepath.Base(names[0]) != names[0] || names[0][:6] != "admin_" {
+		t.Fatalf("имена %v", names)
+	}
+	legacy := filepath.Join(dir, "admin_photo.jpg")
+	os.WriteFile(legacy, []byte("x"), 0644)
+	outside := filepath.Join(filepath.Dir(dir), "keep.txt")
+	os.WriteFile(outside, []byte("x"), 0644)
+	defer os.Remove(outside)
+
+	if err := Remove(dir, "admin_photo.jpg"); err != nil {
+		t.Fatal(err)
+	}
+	Remove(dir, "../keep.txt")
+	if err := Remove(dir, names[0], names[0]); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(legacy); !os.IsNotExist(err) {
+		t.Error("старый аватар не удалён")
+	}
+	if _, err := os.Stat(outside); err != nil {
+		t.Error("удалён файл вне каталога аватаров")
+	}
+	if _, err := os.Stat(filepath.Join(dir, names[0])); err != nil {
+		t.Error("удалён сохраняемый вариант")
+	}
+	if _, err := os.Stat(filepath.Join(dir, names[1])); !os.IsNotExist(err) {
+		t.Error("миниатюра старого аватара не удалена")
+	}
+}
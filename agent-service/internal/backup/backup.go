@@ -0,0 +1,73 @@
+// Package backup — копии файлов перед изменением инструментами агента.
+//
+// Перед write, edit_file и delete agent-service читает файл через
+// tools-service и сохраняет содержимое в таблицу FileBackup. Откат
+// (POST /rollback) записывает сохранённое содержимое обратно, а файл,
+// которого до вызова не было, удаляет. Копии группируются по запросу
+// /chat и ответу ассистента: откат ответа возвращает все затронутые файлы
+// в состояние до первого вызова инструмента.
+package backup
+
+import (
+	"context"
+	"log/slog"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"gorm.io/gorm"
+
+	"github.com/neo-2022/openclaw-memory/agent-service/internal/models"
+)
+
+// Restorable — содержимое можно сохранить и восстановить: текст в UTF-8
+// без нулевых байт (инструменты read/write передают файлы строкой).
+func Restorable(content string) bool {
+	return utf8.ValidString(content) && !strings.ContainsRune(content, 0)
+}
+
+// Plan — копии для отката группы изменений: по каждому пути самая ранняя
+// (состояние до первого изменения в группе), ещё не восстановленная.
+// Порядок — от последнего изменения к первому.
+func Plan(backups []models.FileBackup) []models.FileBackup {
+	earliest := map[string]models.FileBackup{}
+	for _, b := range backups {
+		if b.RestoredAt != nil {
+			continue
+		}
+		if cur, ok := earliest[b.Path]; !ok || b.ID < cur.ID {
+			earliest[b.Path] = b
+		}
+	}
+	out := make([]models.FileBackup, 0, len(earliest))
+	for _, b := range earliest {
+		out = append(out, b)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID > out[j].ID })
+	return out
+}
+
+// Prune — удаляет копии старше before. Возвращает число удалённых.
+func Prune(db *gorm.DB, before time.Time) (int64, error) {
+	res := db.Where("created_at < ?", before).Delete(&models.FileBackup{})
+	return res.RowsAffected, res.Error
+}
+
+// RunPruner — раз в час удаляет копии старше retention() до отмены ctx.
+func RunPruner(ctx context.Context, db *gorm.DB, retention func() time.Duration) {
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+	for {
+		if n, err := Prune(db, time.Now().Add(-retention())); err != nil {
+			slog.Warn("Не удалось удалить старые копии файлов", slog.String("ошибка", err.Error()))
+		} else if n > 0 {
+			slog.Info("Старые копии файлов удалены", slog.Int64("количество", n))
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
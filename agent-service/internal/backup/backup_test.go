@@ -0,0 +1,29 @@
+package backup
+
+import (
+	"testing"
+	"time"
+
+	"github.com/neo-2022/openclaw-memory/agent-service/internal/models"
+)
+
+// TestPlan — по каждому файлу самая ранняя невосстановленная копия, новые первыми.
+func TestPlan(t *testing.T) {
+	now := time.Now()
+	plan := Plan([]models.FileBackup{
+		{ID: 1, Path: "/srv/a.go", Content: "v1", Existed: true},
+		{ID: 2, Path: "/srv/b.go"},
+		{ID: 3, Path: "/srv/a.go", Content: "v2", Existed: true},
+		{ID: 4, Path: "/srv/c.go", RestoredAt: &now},
+	})
+	if len(plan) != 2 || plan[0].ID != 2 || plan[1].ID != 1 || plan[1].Content != "v1" {
+		t.Errorf("план отката: %+v", plan)
+	}
+}
+
+// TestRestorable — двоичное содержимое не сохраняется.
+func TestRestorable(t *testing.T) {
+	if !Restorable("package main\n") || Restorable("PNG\x00\x01") || Restorable(string([]byte{0xff, 0xfe})) {
+		t.Error("Restorable")
+	}
+}
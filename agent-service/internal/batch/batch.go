@@ -0,0 +1,291 @@
+// Package batch — пакетная обработка запросов к агенту (POST /chat/batch).
+//
+// Задание — список запросов, которые выполняются в фоне. Клиент сразу
+// получает идентификатор задания и затем опрашивает состояние: у каждого
+// запроса свой статус, ответ или ошибка. Одновременно выполняется не больше
+// BATCH_CONCURRENCY запросов на все задания — пакет не вытесняет
+// интерактивный чат; задание можно ограничить ещё сильнее полем concurrency.
+// Задания хранятся в памяти и удаляются через Retention после завершения.
+package batch
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Статусы задания и запроса.
+const (
+	StatusQueued   = "queued"
+	StatusRunning  = "running"
+	StatusDone     = "done"
+	StatusFailed   = "failed"
+	StatusCanceled = "canceled"
+)
+
+// ErrTooManyJobs — активных заданий уже MaxActive.
+var ErrTooManyJobs = errors.New("слишком много активных пакетных заданий")
+
+// Item — запрос задания и его результат.
+type Item struct {
+	Index      int        `json:"index"`
+	ID         string     `json:"id,omitempty"` // Метка клиента (имя документа, номер примера)
+	Status     string     `json:"status"`
+	Response   string     `json:"response,omitempty"`
+	Error      string     `json:"error,omitempty"`
+	StartedAt  *time.Time `json:"started_at,omitempty"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+	DurationMs int64      `json:"duration_ms,omitempty"`
+}
+
+// Counts — число запросов по статусам.
+type Counts struct {
+	Total    int `json:"total"`
+	Queued   int `json:"queued"`
+	Running  int `json:"running"`
+	Done     int `json:"done"`
+	Failed   int `json:"failed"`
+	Canceled int `json:"canceled"`
+}
+
+// Job — пакетное задание.
+type Job struct {
+	ID          string     `json:"id"`
+	Agent       string     `json:"agent"`
+	Status      string     `json:"status"`
+	Concurrency int        `json:"concurrency"`
+	Counts      Counts     `json:"counts"`
+	CreatedAt   time.Time  `json:"created_at"`
+	FinishedAt  *time.Time `json:"finished_at,omitempty"`
+	Items       []Item     `json:"items,omitempty"`
+}
+
+// Runner — выполняет запрос с номером index; возвращает ответ агента.
+type Runner func(ctx context.Context, index int) (string, error)
+
+type job struct {
+	Job
+	cancel context.CancelFunc
+}
+
+// Manager — задания и общий лимит одновременных запросов.
+type Manager struct {
+	mu    sync.Mutex
+	jobs  map[string]*job
+	slots chan struct{}
+	now   func() time.Time
+
+	// MaxActive — сколько заданий может выполняться одновременно
+	MaxActive int
+	// Retention — сколько хранить завершённое задание
+	Retention time.Duration
+}
+
+// NewManager — менеджер с общим лимитом concurrency одновременных запросов.
+func NewManager(concurrency int) *Manager {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	return &Manager{
+		jobs:      map[string]*job{},
+		slots:     make(chan struct{}, concurrency),
+		now:       time.Now,
+		MaxActive: 4,
+		Retention: 24 * time.Hour,
+	}
+}
+
+// Concurrency — общий лимит одновременных запросов.
+func (m *Manager) Concurrency() int { return cap(m.slots) }
+
+// Start — ставит задание из ids (по одной метке на запрос) в очередь.
+// concurrency ограничивает число одновременных запросов задания (0 — общий лимит).
+func (m *Manager) Start(agent string, ids []string, concurrency int, run Runner) (Job, error) {
+	if concurrency <= 0 || concurrency > m.Concurrency() {
+		concurrency = m.Concurrency()
+	}
+	m.mu.Lock()
+	m.cleanup()
+	active := 0
+	for _, j := range m.jobs {
+		if j.FinishedAt == nil {
+			active++
+		}
+	}
+	if m.MaxActive > 0 && active >= m.MaxActive {
+		m.mu.Unlock()
+		return Job{}, ErrTooManyJobs
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	j := &job{Job: Job{
+		ID:          newID(),
+		Agent:       agent,
+		Status:      StatusQueued,
+		Concurrency: concurrency,
+		CreatedAt:   m.now(),
+		Items:       make([]Item, len(ids)),
+	}, cancel: cancel}
+	for i, id := range ids {
+		j.Items[i] = Item{Index: i, ID: id, Status: StatusQueued}
+	}
+	m.jobs[j.ID] = j
+	snapshot := j.snapshot(false)
+	m.mu.Unlock()
+
+	go m.run(ctx, j, run)
+	return snapshot, nil
+}
+
+// run — выполняет запросы задания не больше Concurrency одновременно.
+func (m *Manager) run(ctx context.Context, j *job, run Runner) {
+	own := make(chan struct{}, j.Concurrency)
+	var wg sync.WaitGroup
+	for i := range j.Items {
+		if !acquire(ctx, own) {
+			break
+		}
+		if !acquire(ctx, m.slots) {
+			<-own
+			break
+		}
+		m.update(j, i, func(it *Item) {
+			now := m.now()
+			it.Status, it.StartedAt = StatusRunning, &now
+			j.Status = StatusRunning
+		})
+		wg.Add(1)
+		go func(i int) {
+			defer func() { <-m.slots; <-own; wg.Done() }()
+			resp, err := run(ctx, i)
+			m.update(j, i, func(it *Item) {
+				now := m.now()
+				it.FinishedAt = &now
+				it.DurationMs = now.Sub(*it.StartedAt).Milliseconds()
+				switch {
+				case err != nil && ctx.Err() != nil:
+					it.Status, it.Error = StatusCanceled, "задание отменено"
+				case err != nil:
+					it.Status, it.Error = StatusFailed, err.Error()
+				default:
+					it.Status, it.Response = StatusDone, resp
+				}
+			})
+		}(i)
+	}
+	wg.Wait()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := m.now()
+	j.FinishedAt = &now
+	j.Status = StatusDone
+	for i := range j.Items {
+		if j.Items[i].Status == StatusQueued {
+			j.Items[i].Status = StatusCanceled
+		}
+	}
+	if ctx.Err() != nil {
+		j.Status = StatusCanceled
+	}
+	j.cancel()
+}
+
+// acquire — занимает место в slots; false — задание отменено, место не занято.
+func acquire(ctx context.Context, slots chan struct{}) bool {
+	select {
+	case slots <- struct{}{}:
+		if ctx.Err() != nil {
+			<-slots
+			return false
+		}
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+func (m *Manager) update(j *job, i int, f func(*Item)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	f(&j.Items[i])
+}
+
+// Get — состояние задания со всеми запросами.
+func (m *Manager) Get(id string) (Job, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	j, ok := m.jobs[id]
+	if !ok {
+		return Job{}, false
+	}
+	return j.snapshot(false), true
+}
+
+// List — задания без списка запросов, новые первыми.
+func (m *Manager) List() []Job {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.cleanup()
+	out := make([]Job, 0, len(m.jobs))
+	for _, j := range m.jobs {
+		out = append(out, j.snapshot(true))
+	}
+	sort.Slice(out, func(a, b int) bool { return out[a].CreatedAt.After(out[b].CreatedAt) })
+	return out
+}
+
+// Cancel — отменяет задание: новые запросы не запускаются, выполняемые прерываются.
+func (m *Manager) Cancel(id string) bool {
+	m.mu.Lock()
+	j, ok := m.jobs[id]
+	m.mu.Unlock()
+	if ok {
+		j.cancel()
+	}
+	return ok
+}
+
+// cleanup — удаляет задания, завершённые раньше Retention. Вызывается под mu.
+func (m *Manager) cleanup() {
+	for id, j := range m.jobs {
+		if j.FinishedAt != nil && m.now().Sub(*j.FinishedAt) > m.Retention {
+			delete(m.jobs, id)
+		}
+	}
+}
+
+// snapshot — копия задания для ответа; summary — без списка запросов. Вызывается под mu.
+func (j *job) snapshot(summary bool) Job {
+	out := j.Job
+	out.Counts = Counts{Total: len(j.Items)}
+	for _, it := range j.Items {
+		switch it.Status {
+		case StatusQueued:
+			out.Counts.Queued++
+		case StatusRunning:
+			out.Counts.Running++
+		case StatusDone:
+			out.Counts.Done++
+		case StatusFailed:
+			out.Counts.Failed++
+		case StatusCanceled:
+			out.Counts.Canceled++
+		}
+	}
+	if summary {
+		out.Items = nil
+	} else {
+		out.Items = append([]Item(nil), j.Items...)
+	}
+	return out
+}
+
+func newID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return "batch-" + hex.EncodeToString(b)
+}
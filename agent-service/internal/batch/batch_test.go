@@ -0,0 +1,104 @@
+package batch
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// wait — ждёт завершения задания.
+func wait(t *testing.T, m *Manager, id string) Job {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if j, _ := m.Get(id); j.FinishedAt != nil {
+			return j
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatal("задание не завершилось")
+	return Job{}
+}
+
+// TestRun — статусы запросов и соблюдение лимита одновременных запросов.
+func TestRun(t *testing.T) {
+	m := NewManager(2)
+	var running, peak int32
+	run := func(ctx context.Context, i int) (string, error) {
+		n := atomic.AddInt32(&running, 1)
+		for {
+			p := atomic.LoadInt32(&peak)
+			if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+		atomic.AddInt32(&running, -1)
+		if i == 3 {
+			return "", errors.New("модель недоступна")
+		}
+		return "ответ", nil
+	}
+	j, err := m.Start("admin", []string{"a", "b", "c", "d", "e", "f"}, 5, run)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if j.Concurrency != 2 {
+		t.Errorf("лимит задания не выше общего: %d", j.Concurrency)
+	}
+	j = wait(t, m, j.ID)
+	if j.Status != StatusDone || j.Counts.Done != 5 || j.Counts.Failed != 1 {
+		t.Errorf("итог: %+v", j.Counts)
+	}
+	if j.Items[3].Error != "модель недоступна" || j.Items[0].ID != "a" || j.Items[0].Response != "ответ" {
+		t.Errorf("запросы: %+v", j.Items)
+	}
+	if peak > 2 {
+		t.Errorf("одновременно выполнялось %d запросов", peak)
+	}
+}
+
+// TestCancel — отмена прерывает выполняемые запросы и снимает очередь.
+func TestCancel(t *testing.T) {
+	m := NewManager(1)
+	started := make(chan struct{}, 1)
+	run := func(ctx context.Context, i int) (string, error) {
+		started <- struct{}{}
+		<-ctx.Done()
+		return "", ctx.Err()
+	}
+	j, _ := m.Start("admin", []string{"", "", ""}, 0, run)
+	<-started
+	if !m.Cancel(j.ID) || m.Cancel("batch-unknown") {
+		t.Fatal("отмена")
+	}
+	j = wait(t, m, j.ID)
+	if j.Status != StatusCanceled || j.Counts.Canceled != 3 {
+		t.Errorf("после отмены: %s %+v", j.Status, j.Counts)
+	}
+	// Место в общем лимите освобождено
+	if _, err := m.Start("admin", []string{""}, 0, func(context.Context, int) (string, error) { return "ok", nil }); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestLimits — число активных заданий ограничено, завершённые удаляются через Retention.
+func TestLimits(t *testing.T) {
+	now := time.Now()
+	m := NewManager(1)
+	m.MaxActive = 1
+	m.now = func() time.Time { return now }
+	block := make(chan struct{})
+	first, _ := m.Start("admin", []string{""}, 0, func(context.Context, int) (string, error) { <-block; return "", nil })
+	if _, err := m.Start("admin", []string{""}, 0, nil); !errors.Is(err, ErrTooManyJobs) {
+		t.Errorf("ожидалась ErrTooManyJobs, получено %v", err)
+	}
+	close(block)
+	wait(t, m, first.ID)
+	now = now.Add(m.Retention + time.Minute)
+	if len(m.List()) != 0 {
+		t.Error("завершённое задание должно удалиться")
+	}
+}
@@ -0,0 +1,324 @@
+// Package benchmark — стандартный набор замеров модели: скорость генерации,
+// задержка до первого токена, точность вызова инструментов и проверка
+// эффективного размера контекста («иголка в стоге сена»).
+//
+// Замеры идут через обычный llm.ChatProvider, поэтому отражают модель в
+// реальной конфигурации сервиса (в том числе num_ctx у Ollama). Точные
+// токены и тайминги берутся из статистики провайдера (Ollama); для облачных
+// провайдеров токены оцениваются по длине ответа, а задержкой до первого
+// токена считается полное время ответа.
+package benchmark
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/neo-2022/openclaw-memory/agent-service/internal/llm"
+)
+
+// DefaultContextSizes — размеры контекста (≈ токенов) для проверки по умолчанию.
+var DefaultContextSizes = []int{2048, 4096, 8192, 16384}
+
+// Options — параметры прогона.
+type Options struct {
+	SpeedRuns    int   // Число замеров скорости (по умолчанию 3)
+	ContextSizes []int // Размеры контекста по возрастанию (по умолчанию DefaultContextSizes)
+	SkipTools    bool  // Не проверять вызов инструментов (модель их не поддерживает)
+}
+
+// SpeedRun — один замер скорости генерации.
+type SpeedRun struct {
+	Tokens       int     `json:"tokens"`
+	Estimated    bool    `json:"estimated,omitempty"` // Токены оценены по длине текста
+	TokensPerSec float64 `json:"tokens_per_sec"`
+	FirstTokenMs float64 `json:"first_token_ms"`
+	TotalMs      float64 `json:"total_ms"`
+	Error        string  `json:"error,omitempty"`
+}
+
+// ToolResult — итог одного теста вызова инструмента.
+type ToolResult struct {
+	Case   string `json:"case"`
+	OK     bool   `json:"ok"`
+	Called string `json:"called,omitempty"` // Какой инструмент вызвала модель
+	Reason string `json:"reason,omitempty"`
+}
+
+// ContextResult — итог проверки одного размера контекста.
+type ContextResult struct {
+	Tokens int     `json:"tokens"`
+	Found  bool    `json:"found"`
+	Ms     float64 `json:"ms"`
+	Error  string  `json:"error,omitempty"`
+}
+
+// Report — результат прогона.
+type Report struct {
+	Provider        string          `json:"provider"`
+	Model           string          `json:"model"`
+	TokensPerSec    float64         `json:"tokens_per_sec"`
+	FirstTokenMs    float64         `json:"first_token_ms"`
+	ColdStartMs     float64         `json:"cold_start_ms,omitempty"` // Загрузка модели при первом запросе
+	Speed           []SpeedRun      `json:"speed"`
+	Tools           []ToolResult    `json:"tools,omitempty"`
+	ToolSuccessRate float64         `json:"tool_success_rate"`
+	Context         []ContextResult `json:"context"`
+	ContextTokens   int             `json:"context_tokens"` // Наибольший пройденный размер
+	DurationMs      int64           `json:"duration_ms"`
+}
+
+// Runner — прогон набора замеров для одной модели.
+type Runner struct {
+	Provider llm.ChatProvider
+	Model    string
+	now      func() time.Time
+}
+
+// Run — прогоняет набор замеров. Ошибка возвращается, только если модель
+// не ответила ни на один запрос; частичные сбои попадают в отчёт.
+func (r *Runner) Run(ctx context.Context, opt Options) (*Report, error) {
+	if r.now == nil {
+		r.now = time.Now
+	}
+	if opt.SpeedRuns <= 0 {
+		opt.SpeedRuns = 3
+	}
+	if len(opt.ContextSizes) == 0 {
+		opt.ContextSizes = DefaultContextSizes
+	}
+	started := r.now()
+	rep := &Report{Provider: r.Provider.Name(), Model: r.Model}
+
+	// Прогревочный запрос: время загрузки модели не должно попасть в замеры скорости
+	warm, _, err := r.chat(&llm.ChatRequest{Model: r.Model, Messages: user("Ответь одним словом: готов?")})
+	if err != nil {
+		return nil, fmt.Errorf("модель не отвечает: %w", err)
+	}
+	if warm.Stats != nil {
+		rep.ColdStartMs = round(warm.Stats.LoadMs)
+	}
+
+	r.speed(ctx, rep, opt.SpeedRuns)
+	if !opt.SkipTools {
+		r.tools(ctx, rep)
+	}
+	r.context(ctx, rep, opt.ContextSizes)
+	rep.DurationMs = r.now().Sub(started).Milliseconds()
+	return rep, nil
+}
+
+// speedPrompts — запросы на генерацию текста средней длины.
+var speedPrompts = []string{
+	"Опиши в одном абзаце (около 120 слов), как работает кэш процессора.",
+	"Напиши короткий рассказ (около 120 слов) о маяке на северном острове.",
+	"Объясни в одном абзаце (около 120 слов), зачем нужны индексы в базе данных.",
+}
+
+func (r *Runner) speed(ctx context.Context, rep *Report, runs int) {
+	var tps, ftl []float64
+	for i := 0; i < runs && ctx.Err() == nil; i++ {
+		resp, took, err := r.chat(&llm.ChatRequest{Model: r.Model, Messages: user(speedPrompts[i%len(speedPrompts)])})
+		run := SpeedRun{TotalMs: round(ms(took))}
+		if err != nil {
+			run.Error = err.Error()
+			rep.Speed = append(rep.Speed, run)
+			continue
+		}
+		if st := resp.Stats; st != nil && st.CompletionTokens > 0 && st.EvalMs > 0 {
+			run.Tokens = st.CompletionTokens
+			run.TokensPerSec = round(float64(st.CompletionTokens) / (st.EvalMs / 1000))
+			run.FirstTokenMs = round(st.LoadMs + st.PromptEvalMs)
+		} else {
+			run.Tokens, run.Estimated = estimateTokens(resp.Content), true
+			if took > 0 {
+				run.TokensPerSec = round(float64(run.Tokens) / took.Seconds())
+			}
+			run.FirstTokenMs = run.TotalMs
+		}
+		tps = append(tps, run.TokensPerSec)
+		ftl = append(ftl, run.FirstTokenMs)
+		rep.Speed = append(rep.Speed, run)
+	}
+	rep.TokensPerSec = median(tps)
+	rep.FirstTokenMs = median(ftl)
+}
+
+// toolCase — тест вызова инструмента: ожидаемый инструмент (пусто — без
+// вызова) и подстрока, которая должна быть в значении аргумента.
+type toolCase struct {
+	name, prompt string
+	tool, arg    string
+	argContains  string
+}
+
+var benchTools = []llm.Tool{
+	tool("get_weather", "Текущая погода в городе", map[string]any{
+		"city": map[string]any{"type": "string", "description": "Город"},
+	}, "city"),
+	tool("calculator", "Вычислить арифметическое выражение", map[string]any{
+		"expression": map[string]any{"type": "string", "description": "Выражение, например 2+2"},
+	}, "expression"),
+	tool("create_file", "Создать файл с содержимым", map[string]any{
+		"path":    map[string]any{"type": "string", "description": "Путь к файлу"},
+		"content": map[string]any{"type": "string", "description": "Содержимое"},
+	}, "path", "content"),
+}
+
+var toolCases = []toolCase{
+	{name: "weather", prompt: "Какая сейчас погода в Казани?", tool: "get_weather", arg: "city", argContains: "казан"},
+	{name: "calculator", prompt: "Посчитай точно с помощью калькулятора: 1234 * 5678", tool: "calculator", arg: "expression", argContains: "1234"},
+	{name: "create_file", prompt: "Создай файл notes/todo.txt с текстом «купить хлеб»", tool: "create_file", arg: "path", argContains: "todo.txt"},
+	{name: "no_tool", prompt: "Как по-английски «спасибо»? Ответь одним словом."},
+}
+
+func (r *Runner) tools(ctx context.Context, rep *Report) {
+	ok := 0
+	for _, c := range toolCases {
+		if ctx.Err() != nil {
+			break
+		}
+		res := ToolResult{Case: c.name}
+		resp, _, err := r.chat(&llm.ChatRequest{Model: r.Model, Messages: user(c.prompt), Tools: benchTools})
+		switch {
+		case err != nil:
+			res.Reason = err.Error()
+		case c.tool == "":
+			if len(resp.ToolCalls) == 0 {
+				res.OK = true
+			} else {
+				res.Called = resp.ToolCalls[0].Function.Name
+				res.Reason = "лишний вызов инструмента"
+			}
+		case len(resp.ToolCalls) == 0:
+			res.Reason = "инструмент не вызван"
+		default:
+			call := resp.ToolCalls[0].Function
+			res.Called = call.Name
+			value, _ := argsOf(call.Arguments)[c.arg].(string)
+			switch {
+			case call.Name != c.tool:
+				res.Reason = "вызван другой инструмент"
+			case !strings.Contains(strings.ToLower(value), c.argContains):
+				res.Reason = fmt.Sprintf("аргумент %s = %q", c.arg, value)
+			default:
+				res.OK = true
+			}
+		}
+		if res.OK {
+			ok++
+		}
+		rep.Tools = append(rep.Tools, res)
+	}
+	if len(rep.Tools) > 0 {
+		rep.ToolSuccessRate = round(float64(ok) / float64(len(rep.Tools)))
+	}
+}
+
+func (r *Runner) context(ctx context.Context, rep *Report, sizes []int) {
+	sizes = append([]int(nil), sizes...)
+	sort.Ints(sizes)
+	for i, size := range sizes {
+		if ctx.Err() != nil {
+			break
+		}
+		code := fmt.Sprintf("%04d-%s", 1000+size%9000, []string{"ЛУНА", "СОСНА", "ВОЛНА", "ГРАНИТ", "КОМЕТА"}[i%5])
+		resp, took, err := r.chat(&llm.ChatRequest{Model: r.Model, Messages: user(Haystack(size, code))})
+		res := ContextResult{Tokens: size, Ms: round(ms(took))}
+		if err != nil {
+			res.Error = err.Error()
+		} else {
+			res.Found = strings.Contains(strings.ToUpper(resp.Content), code)
+		}
+		rep.Context = append(rep.Context, res)
+		if !res.Found {
+			// Большие размеры заведомо не пройдут — не тратим на них время
+			break
+		}
+		rep.ContextTokens = size
+	}
+}
+
+// Haystack — текст примерно из tokens токенов с меткой code посередине и
+// вопросом о ней в конце.
+func Haystack(tokens int, code string) string {
+	var b strings.Builder
+	target := tokens * 3 // ≈ 3 символа на токен
+	needle := "\nВАЖНО: секретный код доступа — " + code + ".\n"
+	placed := false
+	for i, n := 1, 0; n < target-600; i++ {
+		if !placed && n >= target/2 {
+			b.WriteString(needle)
+			placed = true
+		}
+		line := fmt.Sprintf("Запись %d журнала: склад номер %d принял партию товара, накладная оформлена без замечаний. ", i, i%17+1)
+		b.WriteString(line)
+		n += utf8.RuneCountInString(line)
+	}
+	if !placed {
+		b.WriteString(needle)
+	}
+	b.WriteString("\n\nВопрос: какой секретный код доступа указан в тексте выше? Ответь только кодом.")
+	return b.String()
+}
+
+// chat — запрос к модели с замером времени.
+func (r *Runner) chat(req *llm.ChatRequest) (*llm.ChatResponse, time.Duration, error) {
+	start := r.now()
+	resp, err := r.Provider.Chat(req)
+	return resp, r.now().Sub(start), err
+}
+
+func user(text string) []llm.Message {
+	return []llm.Message{{Role: "user", Content: text}}
+}
+
+func tool(name, description string, props map[string]any, required ...string) llm.Tool {
+	return llm.Tool{Type: "function", Function: llm.FunctionDefinition{
+		Name:        name,
+		Description: description,
+		Parameters:  map[string]any{"type": "object", "properties": props, "required": required},
+	}}
+}
+
+// argsOf — аргументы вызова: объект (Ollama) или JSON-строка (OpenAI).
+func argsOf(raw json.RawMessage) map[string]interface{} {
+	var args map[string]interface{}
+	if json.Unmarshal(raw, &args) == nil {
+		return args
+	}
+	var s string
+	if json.Unmarshal(raw, &s) == nil && json.Unmarshal([]byte(s), &args) == nil {
+		return args
+	}
+	return map[string]interface{}{}
+}
+
+// estimateTokens — оценка числа токенов по длине текста (≈ 3 символа на токен).
+func estimateTokens(s string) int {
+	n := utf8.RuneCountInString(s) / 3
+	if n == 0 && s != "" {
+		n = 1
+	}
+	return n
+}
+
+func median(v []float64) float64 {
+	if len(v) == 0 {
+		return 0
+	}
+	s := append([]float64(nil), v...)
+	sort.Float64s(s)
+	if len(s)%2 == 1 {
+		return s[len(s)/2]
+	}
+	return round((s[len(s)/2-1] + s[len(s)/2]) / 2)
+}
+
+func ms(d time.Duration) float64 { return float64(d) / float64(time.Millisecond) }
+
+func round(f float64) float64 { return float64(int64(f*100+0.5)) / 100 }
@@ -0,0 +1,80 @@
+package benchmark
+
+import (
+	"context"
+	"encoding/json"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/neo-2022/openclaw-memory/agent-service/internal/llm"
+)
+
+// fakeModel — модель с контекстом maxChars символов: видит метку, только
+// если промпт в него помещается; инструменты вызывает по ключевым словам.
+type fakeModel struct {
+	maxChars int
+	calls    int
+}
+
+func (f *fakeModel) Name() string                                   { return "ollama" }
+func (f *fakeModel) ListModels() ([]string, error)                  { return nil, nil }
+func (f *fakeModel) ListModelsDetailed() ([]llm.ModelDetail, error) { return nil, nil }
+
+var codeRe = regexp.MustCompile(`код доступа — (\S+)\.`)
+
+func (f *fakeModel) Chat(req *llm.ChatRequest) (*llm.ChatResponse, error) {
+	f.calls++
+	prompt := req.Messages[0].Content
+	stats := &llm.GenerationStats{CompletionTokens: 100, EvalMs: 2000, PromptEvalMs: 150, LoadMs: 50}
+	if f.calls == 1 {
+		stats.LoadMs = 3000
+	}
+	resp := &llm.ChatResponse{Content: "ответ", Stats: stats}
+	switch {
+	case strings.Contains(prompt, "Казани"):
+		resp.ToolCalls = []llm.ToolCall{{Function: llm.FunctionCall{Name: "get_weather", Arguments: json.RawMessage(`{"city":"Казань"}`)}}}
+	case strings.Contains(prompt, "калькулятора"):
+		// Аргументы строкой, как у OpenAI-совместимых API
+		resp.ToolCalls = []llm.ToolCall{{Function: llm.FunctionCall{Name: "calculator", Arguments: json.RawMessage(`"{\"expression\":\"1234*5678\"}"`)}}}
+	case strings.Contains(prompt, "todo.txt"):
+		resp.ToolCalls = []llm.ToolCall{{Function: llm.FunctionCall{Name: "get_weather", Arguments: json.RawMessage(`{}`)}}}
+	case strings.Contains(prompt, "секретный код"):
+		if m := codeRe.FindStringSubmatch(prompt); m != nil && len([]rune(prompt)) <= f.maxChars {
+			resp.Content = "Код: " + m[1]
+		}
+	}
+	return resp, nil
+}
+
+// TestRun — скорость по статистике провайдера, точность инструментов и предел контекста.
+func TestRun(t *testing.T) {
+	model := &fakeModel{maxChars: 8192 * 3}
+	r := &Runner{Provider: model, Model: "qwen2.5:7b"}
+	rep, err := r.Run(context.Background(), Options{SpeedRuns: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rep.TokensPerSec != 50 || rep.FirstTokenMs != 200 || rep.ColdStartMs != 3000 {
+		t.Errorf("скорость: %+v", rep)
+	}
+	if rep.ToolSuccessRate != 0.75 || rep.Tools[2].Reason != "вызван другой инструмент" {
+		t.Errorf("инструменты: %+v", rep.Tools)
+	}
+	if rep.ContextTokens != 8192 || len(rep.Context) != 4 || rep.Context[3].Found {
+		t.Errorf("контекст: %+v", rep.Context)
+	}
+}
+
+// TestHaystack — метка стоит в середине, размер близок к заданному.
+func TestHaystack(t *testing.T) {
+	text := Haystack(4096, "1234-ЛУНА")
+	n := len([]rune(text))
+	if n < 4096*3-1000 || n > 4096*3+500 {
+		t.Errorf("размер %d символов", n)
+	}
+	pos := len([]rune(text[:strings.Index(text, "1234-ЛУНА")]))
+	if pos < n/3 || pos > 2*n/3 {
+		t.Errorf("метка на позиции %d из %d", pos, n)
+	}
+}
@@ -0,0 +1,219 @@
+// Package breaker — предохранитель (circuit breaker) вокруг LLM-провайдеров.
+//
+// Повторяет предохранитель api-gateway: после maxFailures ошибок подряд
+// провайдер считается недоступным (Open), и запросы к нему сразу получают
+// ErrOpen вместо ожидания таймаутов и повторов — обработчик чата
+// переключается на резервную модель. Через resetTimeout предохранитель
+// пропускает пробные запросы (HalfOpen): halfOpenMax успехов подряд
+// возвращают его в Closed, первая ошибка — снова в Open.
+//
+// Ошибками провайдера считаются 5xx и сетевые сбои (Counts); ответы 4xx
+// (неверный ключ, лимит) говорят о живом провайдере и предохранитель не трогают.
+package breaker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/neo-2022/openclaw-memory/agent-service/internal/retry"
+)
+
+// State — состояние предохранителя.
+type State int
+
+const (
+	// StateClosed — замкнут (провайдер работает, запросы проходят).
+	StateClosed State = iota
+	// StateOpen — разомкнут (провайдер недоступен, запросы отклоняются).
+	StateOpen
+	// StateHalfOpen — полуоткрыт (пробные запросы проверяют восстановление).
+	StateHalfOpen
+)
+
+func (s State) String() string {
+	switch s {
+	case StateOpen:
+		return "open"
+	case StateHalfOpen:
+		return "half_open"
+	}
+	return "closed"
+}
+
+// ErrOpen — провайдер отключён предохранителем.
+var ErrOpen = errors.New("circuit breaker open")
+
+// OpenError — запрос не отправлен: предохранитель провайдера разомкнут.
+type OpenError struct {
+	Provider string
+	RetryIn  time.Duration // Через сколько будет пробный запрос
+}
+
+func (e *OpenError) Error() string {
+	return fmt.Sprintf("провайдер %s временно отключён после серии ошибок, проверка через %s", e.Provider, e.RetryIn.Round(time.Second))
+}
+
+// Is — errors.Is(err, ErrOpen).
+func (e *OpenError) Is(target error) bool { return target == ErrOpen }
+
+// Counts — ошибка говорит о недоступности провайдера: 5xx или сетевой сбой.
+// Отмена запроса клиентом не считается.
+func Counts(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) {
+		return false
+	}
+	var he *retry.HTTPError
+	if errors.As(err, &he) {
+		return he.Status >= 500
+	}
+	return true
+}
+
+// CircuitBreaker — предохранитель одного провайдера.
+type CircuitBreaker struct {
+	mu              sync.Mutex
+	name            string
+	state           State
+	failures        int           // Ошибок подряд
+	successes       int           // Успехов подряд в HalfOpen
+	maxFailures     int           // Порог ошибок для перехода в Open (0 — не размыкается)
+	halfOpenMax     int           // Успехов для возврата в Closed
+	resetTimeout    time.Duration // Пауза перед пробными запросами
+	lastFailureTime time.Time
+	now             func() time.Time
+}
+
+// NewCircuitBreaker — предохранитель провайдера name.
+func NewCircuitBreaker(name string, maxFailures int, resetTimeout time.Duration) *CircuitBreaker {
+	return &CircuitBreaker{
+		name:         name,
+		maxFailures:  maxFailures,
+		halfOpenMax:  2,
+		resetTimeout: resetTimeout,
+		now:          time.Now,
+	}
+}
+
+// State — текущее состояние; после resetTimeout Open переходит в HalfOpen.
+func (cb *CircuitBreaker) State() State {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	return cb.stateLocked()
+}
+
+func (cb *CircuitBreaker) stateLocked() State {
+	if cb.state == StateOpen && cb.now().Sub(cb.lastFailureTime) >= cb.resetTimeout {
+		cb.state, cb.successes = StateHalfOpen, 0
+		slog.Info("Предохранитель провайдера полуоткрыт, пробный запрос", slog.String("провайдер", cb.name))
+	}
+	return cb.state
+}
+
+// Allow — nil, если запрос можно отправить, иначе *OpenError.
+func (cb *CircuitBreaker) Allow() error {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	if cb.stateLocked() != StateOpen {
+		return nil
+	}
+	return &OpenError{Provider: cb.name, RetryIn: cb.resetTimeout - cb.now().Sub(cb.lastFailureTime)}
+}
+
+// Record — результат запроса: ошибки по Counts размыкают предохранитель,
+// остальные исходы считаются успехом.
+func (cb *CircuitBreaker) Record(err error) {
+	if Counts(err) {
+		cb.RecordFailure()
+	} else {
+		cb.RecordSuccess()
+	}
+}
+
+// RecordSuccess — провайдер ответил.
+func (cb *CircuitBreaker) RecordSuccess() {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	switch cb.stateLocked() {
+	case StateHalfOpen:
+		cb.successes++
+		if cb.successes >= cb.halfOpenMax {
+			cb.state, cb.failures, cb.successes = StateClosed, 0, 0
+			slog.Info("Предохранитель провайдера замкнут", slog.String("провайдер", cb.name))
+		}
+	case StateClosed:
+		cb.failures = 0
+	}
+}
+
+// RecordFailure — провайдер недоступен.
+func (cb *CircuitBreaker) RecordFailure() {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	cb.failures++
+	cb.lastFailureTime = cb.now()
+	switch cb.stateLocked() {
+	case StateClosed:
+		if cb.maxFailures > 0 && cb.failures >= cb.maxFailures {
+			cb.state = StateOpen
+			slog.Warn("Предохранитель провайдера разомкнут", slog.String("провайдер", cb.name), slog.Int("ошибок", cb.failures))
+		}
+	case StateHalfOpen:
+		cb.state = StateOpen
+		slog.Warn("Предохранитель провайдера разомкнут: ошибка пробного запроса", slog.String("провайдер", cb.name))
+	}
+}
+
+// Set — предохранители провайдеров по имени, создаются при первом обращении.
+type Set struct {
+	mu           sync.Mutex
+	breakers     map[string]*CircuitBreaker
+	maxFailures  int
+	resetTimeout time.Duration
+}
+
+// NewSet — предохранители с порогом maxFailures (0 — не размыкаются) и паузой resetTimeout.
+func NewSet(maxFailures int, resetTimeout time.Duration) *Set {
+	return &Set{breakers: map[string]*CircuitBreaker{}, maxFailures: maxFailures, resetTimeout: resetTimeout}
+}
+
+// Configure — новые порог и пауза для всех предохранителей (перезагрузка конфигурации).
+func (s *Set) Configure(maxFailures int, resetTimeout time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.maxFailures == maxFailures && s.resetTimeout == resetTimeout {
+		return
+	}
+	s.maxFailures, s.resetTimeout = maxFailures, resetTimeout
+	for _, cb := range s.breakers {
+		cb.mu.Lock()
+		cb.maxFailures, cb.resetTimeout = maxFailures, resetTimeout
+		if maxFailures <= 0 {
+			cb.state, cb.failures = StateClosed, 0
+		}
+		cb.mu.Unlock()
+	}
+}
+
+// Get — предохранитель провайдера.
+func (s *Set) Get(provider string) *CircuitBreaker {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	cb, ok := s.breakers[provider]
+	if !ok {
+		cb = NewCircuitBreaker(provider, s.maxFailures, s.resetTimeout)
+		s.breakers[provider] = cb
+	}
+	return cb
+}
+
+// Open — провайдер сейчас отключён (без создания предохранителя).
+func (s *Set) Open(provider string) bool {
+	s.mu.Lock()
+	cb, ok := s.breakers[provider]
+	s.mu.Unlock()
+	return ok && cb.State() == StateOpen
+}
@@ -0,0 +1,73 @@
+package breaker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/neo-2022/openclaw-memory/agent-service/internal/retry"
+)
+
+// TestCircuitBreaker — Closed → Open после серии ошибок → HalfOpen после
+// паузы → Closed после успешных пробных запросов.
+func TestCircuitBreaker(t *testing.T) {
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	cb := NewCircuitBreaker("openai", 3, 30*time.Second)
+	cb.now = func() time.Time { return now }
+	down := &retry.HTTPError{Provider: "OpenAI", Status: 503}
+
+	cb.Record(down)
+	cb.Record(down)
+	cb.Record(&retry.HTTPError{Status: 401}) // 4xx сбрасывает серию
+	cb.Record(down)
+	cb.Record(down)
+	if cb.State() != StateClosed {
+		t.Fatalf("две ошибки подряд — ещё замкнут, состояние %v", cb.State())
+	}
+	cb.Record(fmt.Errorf("dial tcp: connection refused"))
+	err := cb.Allow()
+	if cb.State() != StateOpen || !errors.Is(err, ErrOpen) {
+		t.Fatalf("после третьей ошибки: %v, %v", cb.State(), err)
+	}
+
+	now = now.Add(31 * time.Second)
+	if cb.Allow() != nil || cb.State() != StateHalfOpen {
+		t.Fatalf("после паузы — пробный запрос, состояние %v", cb.State())
+	}
+	cb.Record(down)
+	if cb.State() != StateOpen {
+		t.Fatalf("ошибка пробного запроса — снова разомкнут, состояние %v", cb.State())
+	}
+
+	now = now.Add(31 * time.Second)
+	cb.Record(nil)
+	cb.Record(nil)
+	if cb.State() != StateClosed {
+		t.Errorf("два успеха в полуоткрытом — замкнут, состояние %v", cb.State())
+	}
+}
+
+// TestCounts — отмена клиентом и ответы 4xx не считаются недоступностью.
+func TestCounts(t *testing.T) {
+	if Counts(context.Canceled) || Counts(&retry.HTTPError{Status: 429}) || Counts(nil) {
+		t.Error("не ошибки провайдера")
+	}
+	if !Counts(&retry.HTTPError{Status: 502}) || !Counts(errors.New("timeout")) {
+		t.Error("ошибки провайдера")
+	}
+}
+
+// TestSetConfigure — порог 0 отключает предохранители.
+func TestSetConfigure(t *testing.T) {
+	s := NewSet(1, time.Minute)
+	s.Get("openai").RecordFailure()
+	if !s.Open("openai") || s.Open("ollama") {
+		t.Fatal("разомкнут только openai")
+	}
+	s.Configure(0, time.Minute)
+	if s.Open("openai") {
+		t.Error("предохранители отключены")
+	}
+}
@@ -0,0 +1,135 @@
+// Внутренний API browser-service для agent-service.
+//
+// Работает параллельно с HTTP JSON API: внешние клиенты и api-gateway
+// по-прежнему ходят по HTTP, а agent-service вызывает инструменты браузера,
+// ввода и поиска по gRPC — одно долгоживущее HTTP/2-соединение вместо запроса
+// на каждый вызов. x-request-id и traceparent передаются как одноимённые
+// заголовки HTTP.
+//
+// Go-код: make proto (пакеты internal/browserpb в agent-service и browser-service).
+
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             v5.29.3
+// source: browser/v1/browser.proto
+
+package browserpb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	Browser_Invoke_FullMethodName = "/agentregart.browser.v1.Browser/Invoke"
+)
+
+// BrowserClient is the client API for Browser service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type BrowserClient interface {
+	// Invoke — вызов эндпоинта browser-service: тело и ответ — тот же JSON,
+	// что у HTTP API (POST path).
+	Invoke(ctx context.Context, in *InvokeRequest, opts ...grpc.CallOption) (*InvokeResponse, error)
+}
+
+type browserClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewBrowserClient(cc grpc.ClientConnInterface) BrowserClient {
+	return &browserClient{cc}
+}
+
+func (c *browserClient) Invoke(ctx context.Context, in *InvokeRequest, opts ...grpc.CallOption) (*InvokeResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(InvokeResponse)
+	err := c.cc.Invoke(ctx, Browser_Invoke_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// BrowserServer is the server API for Browser service.
+// All implementations must embed UnimplementedBrowserServer
+// for forward compatibility.
+type BrowserServer interface {
+	// Invoke — вызов эндпоинта browser-service: тело и ответ — тот же JSON,
+	// что у HTTP API (POST path).
+	Invoke(context.Context, *InvokeRequest) (*InvokeResponse, error)
+	mustEmbedUnimplementedBrowserServer()
+}
+
+// UnimplementedBrowserServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedBrowserServer struct{}
+
+func (UnimplementedBrowserServer) Invoke(context.Context, *InvokeRequest) (*InvokeResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Invoke not implemented")
+}
+func (UnimplementedBrowserServer) mustEmbedUnimplementedBrowserServer() {}
+func (UnimplementedBrowserServer) testEmbeddedByValue()                 {}
+
+// UnsafeBrowserServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to BrowserServer will
+// result in compilation errors.
+type UnsafeBrowserServer interface {
+	mustEmbedUnimplementedBrowserServer()
+}
+
+func RegisterBrowserServer(s grpc.ServiceRegistrar, srv BrowserServer) {
+	// If the following call pancis, it indicates UnimplementedBrowserServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&Browser_ServiceDesc, srv)
+}
+
+func _Browser_Invoke_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(InvokeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BrowserServer).Invoke(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Browser_Invoke_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BrowserServer).Invoke(ctx, req.(*InvokeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// Browser_ServiceDesc is the grpc.ServiceDesc for Browser service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Browser_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "agentregart.browser.v1.Browser",
+	HandlerType: (*BrowserServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Invoke",
+			Handler:    _Browser_Invoke_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "browser/v1/browser.proto",
+}
@@ -0,0 +1,61 @@
+// Package browserrpc — gRPC-клиент внутреннего API browser-service (контракт —
+// proto/browser/v1/browser.proto). Включается BROWSER_GRPC_ADDR; без него
+// инструменты браузера, ввода и поиска вызываются по HTTP.
+//
+// Invoke возвращает тот же статус и JSON-тело, что и HTTP-эндпоинт, поэтому
+// разбор ответа инструмента не зависит от транспорта.
+package browserrpc
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/neo-2022/openclaw-memory/agent-service/internal/browserpb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+)
+
+// forwardedHeaders — заголовки HTTP-запроса, передаваемые как метаданные gRPC.
+var forwardedHeaders = []string{"X-Request-ID", "Traceparent"}
+
+// Client — соединение с browser-service; безопасен для параллельных вызовов.
+type Client struct {
+	conn    *grpc.ClientConn
+	browser browserpb.BrowserClient
+}
+
+// New — клиент для addr (host:port). Соединение устанавливается при первом
+// вызове и восстанавливается автоматически.
+func New(addr string) (*Client, error) {
+	conn, err := grpc.NewClient(addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		return nil, err
+	}
+	return &Client{conn: conn, browser: browserpb.NewBrowserClient(conn)}, nil
+}
+
+// Close — закрывает соединение.
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
+
+// Invoke — POST path с телом body; возвращает HTTP-статус и тело ответа
+// эндпоинта. header — заголовки запроса (X-Request-ID, трассировка).
+func (c *Client) Invoke(ctx context.Context, path string, body []byte, header http.Header) (int, []byte, error) {
+	var kv []string
+	for _, name := range forwardedHeaders {
+		if v := header.Get(name); v != "" {
+			kv = append(kv, strings.ToLower(name), v)
+		}
+	}
+	if len(kv) > 0 {
+		ctx = metadata.AppendToOutgoingContext(ctx, kv...)
+	}
+	resp, err := c.browser.Invoke(ctx, &browserpb.InvokeRequest{Path: path, Body: body})
+	if err != nil {
+		return 0, nil, err
+	}
+	return int(resp.GetStatus()), resp.GetBody(), nil
+}
@@ -0,0 +1,59 @@
+package browserrpc
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"testing"
+
+	"github.com/neo-2022/openclaw-memory/agent-service/internal/browserpb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// fakeBrowser — browser-service, возвращающий путь, тело и X-Request-ID запроса.
+type fakeBrowser struct {
+	browserpb.UnimplementedBrowserServer
+}
+
+func (fakeBrowser) Invoke(ctx context.Context, req *browserpb.InvokeRequest) (*browserpb.InvokeResponse, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	cid := ""
+	if v := md.Get("x-request-id"); len(v) > 0 {
+		cid = v[0]
+	}
+	if req.Path == "/browser/missing" {
+		return &browserpb.InvokeResponse{Status: http.StatusNotFound, Body: []byte(`{"error":"нет"}`)}, nil
+	}
+	return &browserpb.InvokeResponse{Status: http.StatusOK, Body: append([]byte(req.Path+" "+cid+" "), req.Body...)}, nil
+}
+
+// TestInvoke — путь, тело и X-Request-ID доходят до сервера, статус ошибки
+// эндпоинта возвращается как есть.
+func TestInvoke(t *testing.T) {
+	lis := bufconn.Listen(1 << 20)
+	srv := grpc.NewServer()
+	browserpb.RegisterBrowserServer(srv, fakeBrowser{})
+	go srv.Serve(lis)
+	defer srv.Stop()
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := &Client{conn: conn, browser: browserpb.NewBrowserClient(conn)}
+	defer c.Close()
+
+	header := http.Header{}
+	header.Set("X-Request-ID", "req-1")
+	status, body, err := c.Invoke(context.Background(), "/search", []byte(`{"query":"go"}`), header)
+	if err != nil || status != http.StatusOK || string(body) != `/search req-1 {"query":"go"}` {
+		t.Fatalf("статус %d, тело %q, ошибка %v", status, body, err)
+	}
+	if status, _, err := c.Invoke(context.Background(), "/browser/missing", nil, http.Header{}); err != nil || status != http.StatusNotFound {
+		t.Errorf("ошибка эндпоинта: %d %v", status, err)
+	}
+}
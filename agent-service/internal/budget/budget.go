@@ -0,0 +1,337 @@
+// Package budget — учёт расхода токенов и лимиты на облачные модели.
+//
+// Каждый ответ LLM записывается в UsageRecord: агент, отпечаток API-ключа
+// клиента, модель, токены и стоимость по ценам BUDGET_PRICES. Бюджеты
+// (models.Budget) задают дневной или месячный лимит токенов и/или
+// стоимости для агента или ключа. Когда лимит исчерпан, облачные вызовы
+// отклоняются с понятным сообщением или переключаются на локальную модель.
+package budget
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/neo-2022/openclaw-memory/agent-service/internal/models"
+)
+
+// Области бюджета.
+const (
+	ScopeAgent = "agent"
+	ScopeKey   = "key"
+)
+
+// Периоды бюджета.
+const (
+	PeriodDaily   = "daily"
+	PeriodMonthly = "monthly"
+)
+
+// Действия при превышении.
+const (
+	ActionRefuse   = "refuse"
+	ActionFallback = "fallback"
+)
+
+// localProviders — провайдеры, запросы к которым ничего не стоят и не ограничиваются.
+var localProviders = map[string]bool{"ollama": true, "lmstudio": true}
+
+// IsCloud — провайдер облачный (расходует бюджет).
+func IsCloud(provider string) bool { return !localProviders[provider] }
+
+// KeyID — отпечаток API-ключа: сам ключ не хранится и не показывается.
+func KeyID(token string) string {
+	if token == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(token))
+	return "key-" + hex.EncodeToString(sum[:])[:12]
+}
+
+type subjectKey struct{}
+
+// Subject — кому засчитывается расход запроса.
+type Subject struct {
+	Agent string
+	KeyID string
+}
+
+// WithSubject — контекст запроса с агентом и отпечатком ключа для учёта расхода.
+func WithSubject(ctx context.Context, agent, keyID string) context.Context {
+	return context.WithValue(ctx, subjectKey{}, Subject{Agent: agent, KeyID: keyID})
+}
+
+// SubjectFrom — агент и ключ из контекста (пустые, если не заданы).
+func SubjectFrom(ctx context.Context) Subject {
+	s, _ := ctx.Value(subjectKey{}).(Subject)
+	return s
+}
+
+// Validate — проверяет и нормализует бюджет из запроса.
+func Validate(b *models.Budget) error {
+	b.Scope = strings.ToLower(strings.TrimSpace(b.Scope))
+	b.Period = strings.ToLower(strings.TrimSpace(b.Period))
+	b.Action = strings.ToLower(strings.TrimSpace(b.Action))
+	b.Subject = strings.TrimSpace(b.Subject)
+	if b.Scope != ScopeAgent && b.Scope != ScopeKey {
+		return fmt.Errorf("scope: %q, ожидается agent или key", b.Scope)
+	}
+	if b.Subject == "" {
+		return errors.New("subject: нужно имя агента или API-ключ")
+	}
+	// Вместо отпечатка можно передать сам ключ — хранится только отпечаток
+	if b.Scope == ScopeKey && !strings.HasPrefix(b.Subject, "key-") {
+		b.Subject = KeyID(b.Subject)
+	}
+	if b.Period == "" {
+		b.Period = PeriodMonthly
+	}
+	if b.Period != PeriodDaily && b.Period != PeriodMonthly {
+		return fmt.Errorf("period: %q, ожидается daily или monthly", b.Period)
+	}
+	if b.Action == "" {
+		b.Action = ActionRefuse
+	}
+	if b.Action != ActionRefuse && b.Action != ActionFallback {
+		return fmt.Errorf("action: %q, ожидается refuse или fallback", b.Action)
+	}
+	if b.TokenLimit < 0 || b.CostLimit < 0 || (b.TokenLimit == 0 && b.CostLimit == 0) {
+		return errors.New("нужен token_limit или cost_limit больше нуля")
+	}
+	return nil
+}
+
+// PeriodStart — начало текущего периода: полночь или первое число месяца.
+func PeriodStart(period string, now time.Time) time.Time {
+	y, m, d := now.Date()
+	if period == PeriodDaily {
+		return time.Date(y, m, d, 0, 0, 0, 0, now.Location())
+	}
+	return time.Date(y, m, 1, 0, 0, 0, 0, now.Location())
+}
+
+// periodEnd — когда бюджет обнулится.
+func periodEnd(period string, now time.Time) time.Time {
+	start := PeriodStart(period, now)
+	if period == PeriodDaily {
+		return start.AddDate(0, 0, 1)
+	}
+	return start.AddDate(0, 1, 0)
+}
+
+// Price — цена модели за миллион токенов.
+type Price struct {
+	Input  float64 `json:"input"`
+	Output float64 `json:"output"`
+}
+
+// Prices — цены моделей; ключ — имя модели или его начало.
+type Prices map[string]Price
+
+// ParsePrices — разбирает BUDGET_PRICES: «модель=вход/выход» через запятую,
+// цены за миллион токенов, например «gpt-4o=2.5/10,claude-3-5-sonnet=3/15».
+func ParsePrices(s string) (Prices, error) {
+	prices := Prices{}
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		model, rates, ok := strings.Cut(item, "=")
+		in, out, ok2 := strings.Cut(rates, "/")
+		if !ok || !ok2 || strings.TrimSpace(model) == "" {
+			return nil, fmt.Errorf("цена %q: ожидается модель=вход/выход", item)
+		}
+		pin, err1 := strconv.ParseFloat(strings.TrimSpace(in), 64)
+		pout, err2 := strconv.ParseFloat(strings.TrimSpace(out), 64)
+		if err1 != nil || err2 != nil || pin < 0 || pout < 0 {
+			return nil, fmt.Errorf("цена %q: некорректное число", item)
+		}
+		prices[strings.TrimSpace(model)] = Price{Input: pin, Output: pout}
+	}
+	return prices, nil
+}
+
+// Lookup — цена модели: точное имя, имя без префикса провайдера («openai/gpt-4o»)
+// или самое длинное совпадающее начало («gpt-4o» для «gpt-4o-2024-08-06»).
+func (p Prices) Lookup(model string) (Price, bool) {
+	if price, ok := p[model]; ok {
+		return price, true
+	}
+	if i := strings.LastIndex(model, "/"); i >= 0 {
+		if price, ok := p[model[i+1:]]; ok {
+			return price, true
+		}
+	}
+	best, found := "", false
+	for name := range p {
+		if strings.HasPrefix(model, name) && len(name) > len(best) {
+			best, found = name, true
+		}
+	}
+	return p[best], found
+}
+
+// Cost — стоимость запроса (0, если цена модели неизвестна).
+func (p Prices) Cost(model string, promptTokens, completionTokens int) float64 {
+	price, ok := p.Lookup(model)
+	if !ok {
+		return 0
+	}
+	return (float64(promptTokens)*price.Input + float64(completionTokens)*price.Output) / 1e6
+}
+
+// Record — сохраняет расход одного запроса.
+func Record(db *gorm.DB, rec *models.UsageRecord) error {
+	if rec.CreatedAt.IsZero() {
+		rec.CreatedAt = time.Now()
+	}
+	rec.Cloud = IsCloud(rec.Provider)
+	return db.Create(rec).Error
+}
+
+// Totals — суммарный расход.
+type Totals struct {
+	Requests         int64   `json:"requests"`
+	PromptTokens     int64   `json:"prompt_tokens"`
+	CompletionTokens int64   `json:"completion_tokens"`
+	Tokens           int64   `json:"tokens"`
+	Cost             float64 `json:"cost"`
+}
+
+// Spent — облачный расход агента или ключа с начала периода.
+func Spent(db *gorm.DB, scope, subject string, since time.Time) (Totals, error) {
+	column := "agent_name"
+	if scope == ScopeKey {
+		column = "key_id"
+	}
+	var t Totals
+	err := db.Model(&models.UsageRecord{}).
+		Select("COUNT(*) AS requests, COALESCE(SUM(prompt_tokens), 0) AS prompt_tokens, COALESCE(SUM(completion_tokens), 0) AS completion_tokens, COALESCE(SUM(cost), 0) AS cost").
+		Where(column+" = ? AND cloud = ? AND created_at >= ?", subject, true, since).
+		Scan(&t).Error
+	t.Tokens = t.PromptTokens + t.CompletionTokens
+	return t, err
+}
+
+// Status — состояние бюджета в текущем периоде.
+type Status struct {
+	models.Budget
+	Spent           Totals    `json:"spent"`
+	TokensRemaining *int64    `json:"tokens_remaining,omitempty"`
+	CostRemaining   *float64  `json:"cost_remaining,omitempty"`
+	Exceeded        bool      `json:"exceeded"`
+	ResetsAt        time.Time `json:"resets_at"`
+}
+
+// StatusOf — расход и остаток по бюджету.
+func StatusOf(db *gorm.DB, b models.Budget, now time.Time) (Status, error) {
+	spent, err := Spent(db, b.Scope, b.Subject, PeriodStart(b.Period, now))
+	if err != nil {
+		return Status{}, err
+	}
+	s := Status{Budget: b, Spent: spent, ResetsAt: periodEnd(b.Period, now)}
+	if b.TokenLimit > 0 {
+		left := max(b.TokenLimit-spent.Tokens, 0)
+		s.TokensRemaining = &left
+		s.Exceeded = spent.Tokens >= b.TokenLimit
+	}
+	if b.CostLimit > 0 {
+		left := max(b.CostLimit-spent.Cost, 0)
+		s.CostRemaining = &left
+		s.Exceeded = s.Exceeded || spent.Cost >= b.CostLimit
+	}
+	return s, nil
+}
+
+// Exceeded — исчерпанные бюджеты агента и ключа (keyID может быть пустым).
+func Exceeded(db *gorm.DB, agent, keyID string, now time.Time) ([]Status, error) {
+	var budgets []models.Budget
+	q := db.Where("scope = ? AND subject = ?", ScopeAgent, agent)
+	if keyID != "" {
+		q = q.Or("scope = ? AND subject = ?", ScopeKey, keyID)
+	}
+	if err := q.Find(&budgets).Error; err != nil {
+		return nil, err
+	}
+	var out []Status
+	for _, b := range budgets {
+		s, err := StatusOf(db, b, now)
+		if err != nil {
+			return nil, err
+		}
+		if s.Exceeded {
+			out = append(out, s)
+		}
+	}
+	return out, nil
+}
+
+// Fallback — при превышении нужен ответ локальной моделью, а не отказ:
+// так бывает, только если все исчерпанные бюджеты разрешают fallback.
+func Fallback(exceeded []Status) bool {
+	for _, s := range exceeded {
+		if s.Action != ActionFallback {
+			return false
+		}
+	}
+	return len(exceeded) > 0
+}
+
+// Message — объяснение для пользователя, почему облачная модель недоступна.
+func Message(exceeded []Status) string {
+	var parts []string
+	for _, s := range exceeded {
+		who := "агента " + s.Subject
+		if s.Scope == ScopeKey {
+			who = "API-ключа " + s.Subject
+		}
+		period := "месячный"
+		if s.Period == PeriodDaily {
+			period = "дневной"
+		}
+		limit := ""
+		switch {
+		case s.TokenLimit > 0 && s.Spent.Tokens >= s.TokenLimit:
+			limit = fmt.Sprintf("израсходовано %d из %d токенов", s.Spent.Tokens, s.TokenLimit)
+		default:
+			limit = fmt.Sprintf("израсходовано %.2f из %.2f", s.Spent.Cost, s.CostLimit)
+		}
+		parts = append(parts, fmt.Sprintf("%s бюджет %s исчерпан (%s, обновится %s)", period, who, limit, s.ResetsAt.Format("02.01.2006 15:04")))
+	}
+	return strings.Join(parts, "; ")
+}
+
+// Usage — расход одного агента или ключа.
+type Usage struct {
+	Subject string `json:"subject"`
+	Totals
+}
+
+// Summary — расход с момента since по агентам и по ключам (все провайдеры).
+func Summary(db *gorm.DB, since time.Time) (byAgent, byKey []Usage, err error) {
+	group := func(column string) ([]Usage, error) {
+		var out []Usage
+		err := db.Model(&models.UsageRecord{}).
+			Select(column+" AS subject, COUNT(*) AS requests, COALESCE(SUM(prompt_tokens), 0) AS prompt_tokens, COALESCE(SUM(completion_tokens), 0) AS completion_tokens, COALESCE(SUM(cost), 0) AS cost").
+			Where("created_at >= ? AND "+column+" <> ''", since).
+			Group(column).Order("cost DESC, subject").
+			Scan(&out).Error
+		for i := range out {
+			out[i].Tokens = out[i].PromptTokens + out[i].CompletionTokens
+		}
+		return out, err
+	}
+	if byAgent, err = group("agent_name"); err != nil {
+		return nil, nil, err
+	}
+	byKey, err = group("key_id")
+	return byAgent, byKey, err
+}
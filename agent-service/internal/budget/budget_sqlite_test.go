@@ -0,0 +1,64 @@
+//go:build cgo
+
+package budget
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+
+	"github.com/neo-2022/openclaw-memory/agent-service/internal/models"
+)
+
+// TestExceeded — учитываются только облачные вызовы текущего периода;
+// fallback выбирается, только если все исчерпанные бюджеты его разрешают.
+func TestExceeded(t *testing.T) {
+	gdb, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "budget.db")), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("открытие SQLite: %v", err)
+	}
+	if err := gdb.AutoMigrate(&models.UsageRecord{}, &models.Budget{}); err != nil {
+		t.Fatalf("миграция: %v", err)
+	}
+	now := time.Now()
+	key := KeyID("token")
+	add := func(provider string, tokens int, at time.Time) {
+		rec := models.UsageRecord{CreatedAt: at, AgentName: "coder", KeyID: key, Provider: provider, ModelName: "m", PromptTokens: tokens, Cost: float64(tokens) / 1000}
+		if err := Record(gdb, &rec); err != nil {
+			t.Fatal(err)
+		}
+	}
+	add("openai", 600, now)
+	add("ollama", 5000, now)                   // локальная модель не считается
+	add("openai", 5000, now.AddDate(0, -2, 0)) // прошлый период
+
+	gdb.Create(&models.Budget{Scope: ScopeAgent, Subject: "coder", Period: PeriodMonthly, TokenLimit: 1000, Action: ActionFallback})
+	gdb.Create(&models.Budget{Scope: ScopeKey, Subject: key, Period: PeriodDaily, CostLimit: 0.5, Action: ActionFallback})
+
+	ex, err := Exceeded(gdb, "coder", key, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ex) != 1 || ex[0].Scope != ScopeKey || !Fallback(ex) {
+		t.Fatalf("исчерпан только бюджет ключа: %+v", ex)
+	}
+	if ex, _ := Exceeded(gdb, "coder", "", now); len(ex) != 0 {
+		t.Errorf("без ключа бюджет агента не исчерпан: %+v", ex)
+	}
+
+	add("anthropic", 500, now)
+	gdb.Model(&models.Budget{}).Where("scope = ?", ScopeKey).Update("action", ActionRefuse)
+	ex, _ = Exceeded(gdb, "coder", key, now)
+	if len(ex) != 2 || Fallback(ex) || Message(ex) == "" {
+		t.Errorf("исчерпаны оба, отказ: %+v", ex)
+	}
+
+	byAgent, byKey, err := Summary(gdb, PeriodStart(PeriodMonthly, now))
+	if err != nil || len(byAgent) != 1 || len(byKey) != 1 || byAgent[0].Requests != 3 || byAgent[0].Tokens != 6100 {
+		t.Errorf("сводка: %+v %+v %v", byAgent, byKey, err)
+	}
+}
@@ -0,0 +1,71 @@
+package budget
+
+import (
+	"testing"
+	"time"
+
+	"github.com/neo-2022/openclaw-memory/agent-service/internal/models"
+)
+
+// TestPrices — разбор BUDGET_PRICES, поиск цены по имени модели и расчёт стоимости.
+func TestPrices(t *testing.T) {
+	p, err := ParsePrices("gpt-4o=2.5/10, gpt-4o-mini=0.15/0.6,claude-3-5-sonnet=3/15")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := map[string]float64{
+		"gpt-4o":                     2.5,
+		"openai/gpt-4o-mini":         0.15,
+		"gpt-4o-mini-2024-07-18":     0.15,
+		"claude-3-5-sonnet-20241022": 3,
+	}
+	for model, want := range cases {
+		if price, ok := p.Lookup(model); !ok || price.Input != want {
+			t.Errorf("%s: %+v ok=%v, ожидалось %v", model, price, ok, want)
+		}
+	}
+	if _, ok := p.Lookup("llama3:8b"); ok {
+		t.Error("цена неизвестной модели")
+	}
+	if c := p.Cost("gpt-4o", 1000, 500); c != 0.0075 {
+		t.Errorf("стоимость: %v", c)
+	}
+	for _, bad := range []string{"gpt-4o=2.5", "gpt-4o=a/b", "=1/2", "gpt-4o=-1/2"} {
+		if _, err := ParsePrices(bad); err == nil {
+			t.Errorf("%q: ожидалась ошибка", bad)
+		}
+	}
+}
+
+// TestValidate — нормализация бюджета и отпечаток ключа вместо самого ключа.
+func TestValidate(t *testing.T) {
+	b := models.Budget{Scope: "Key", Subject: "secret-token", TokenLimit: 1000}
+	if err := Validate(&b); err != nil {
+		t.Fatal(err)
+	}
+	if b.Subject != KeyID("secret-token") || b.Period != PeriodMonthly || b.Action != ActionRefuse {
+		t.Errorf("нормализация: %+v", b)
+	}
+	for _, bad := range []models.Budget{
+		{Scope: "team", Subject: "x", TokenLimit: 1},
+		{Scope: "agent", TokenLimit: 1},
+		{Scope: "agent", Subject: "admin"},
+		{Scope: "agent", Subject: "admin", TokenLimit: 1, Period: "weekly"},
+		{Scope: "agent", Subject: "admin", TokenLimit: 1, Action: "warn"},
+	} {
+		if err := Validate(&bad); err == nil {
+			t.Errorf("%+v: ожидалась ошибка", bad)
+		}
+	}
+}
+
+// TestPeriodStart — начало суток и месяца, момент обнуления.
+func TestPeriodStart(t *testing.T) {
+	now := time.Date(2026, 1, 31, 18, 30, 0, 0, time.UTC)
+	if got := PeriodStart(PeriodDaily, now); !got.Equal(time.Date(2026, 1, 31, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("сутки: %v", got)
+	}
+	if got := periodEnd(PeriodMonthly, now); !got.Equal(time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("месяц: %v", got)
+	}
+}
@@ -0,0 +1,88 @@
+package calc
+
+import (
+	"testing"
+	"time"
+)
+
+// TestEval — приоритет операций, скобки, функции и ошибки.
+func TestEval(t *testing.T) {
+	for expr, want := range map[string]string{
+		"2+2*2":            "6",
+		"(2+3)*4":          "20",
+		"2^3^2":            "512",
+		"-2^2":             "-4",
+		"0.1+0.2":          "0.3",
+		"1,5 × 4":          "6",
+		"10 % 3":           "1",
+		"sqrt(16)+abs(-1)": "5",
+		"1/3":              "0.3333333333",
+		"round(pi*100)":    "314",
+	} {
+		v, err := Eval(expr)
+		if err != nil || Format(v) != want {
+			t.Errorf("%s = %s (%v), ожидалось %s", expr, Format(v), err, want)
+		}
+	}
+	for _, expr := range []string{"1/0", "2(3)", "+7 (999) 123-45-67", "foo(2)", "(1+2", ""} {
+		if v, err := Eval(expr); err == nil {
+			t.Errorf("%q: ожидалась ошибка, получено %v", expr, v)
+		}
+	}
+}
+
+// TestConvert — пересчёт через базовую единицу, температура, разные величины.
+func TestConvert(t *testing.T) {
+	cases := []struct {
+		value    float64
+		from, to string
+		want     string
+	}{
+		{5, "км", "мили", "3.1068559612 миля"},
+		{100, "f", "c", "37.7777777778 °C"},
+		{0, "цельсия", "kelvin", "273.15 K"},
+		{2, "ГБ", "мегабайты", "2048 МБ"},
+		{90, "km/h", "м/с", "25 м/с"},
+	}
+	for _, c := range cases {
+		v, unit, err := Convert(c.value, c.from, c.to)
+		if got := Format(v) + " " + unit; err != nil || got != c.want {
+			t.Errorf("%v %s → %s: %s (%v), ожидалось %s", c.value, c.from, c.to, got, err, c.want)
+		}
+	}
+	if _, _, err := Convert(1, "кг", "м"); err == nil {
+		t.Error("разные величины должны давать ошибку")
+	}
+}
+
+// TestDates — разбор дат, сдвиг, разница в днях через переход на летнее время.
+func TestDates(t *testing.T) {
+	berlin, err := Location("europe/berlin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2026, time.October, 18, 14, 5, 0, 0, berlin)
+	d, err := ParseDate("08.03", now)
+	if err != nil || FormatDate(d) != "8 марта 2027, понедельник" {
+		t.Errorf("08.03: %s, %v", FormatDate(d), err)
+	}
+	if d, _ := ParseDate("нового  года", now); DaysBetween(now, d) != 75 {
+		t.Errorf("до нового года: %d", DaysBetween(now, d))
+	}
+	// 25 октября 2026 в Берлине переход на зимнее время: сутки длиннее
+	if d, _ := ParseDate("2026-11-01", now); DaysBetween(now, d) != 14 {
+		t.Errorf("через переход времени: %d", DaysBetween(now, d))
+	}
+	if res, clock, err := Shift(now, 90, "минут"); err != nil || !clock || FormatDateTime(res) != "18 октября 2026, воскресенье, 15:35" {
+		t.Errorf("через 90 минут: %s, %v", FormatDateTime(res), err)
+	}
+	if res, clock, _ := Shift(time.Date(2026, time.January, 31, 0, 0, 0, 0, time.UTC), 1, "месяц"); clock || FormatDate(res) != "3 марта 2026, вторник" {
+		t.Errorf("через месяц: %s", FormatDate(res))
+	}
+	if loc, err := Location("Токио"); err != nil || loc.String() != "Asia/Tokyo" {
+		t.Errorf("Токио: %v, %v", loc, err)
+	}
+	if _, err := Location("атлантида"); err == nil {
+		t.Error("неизвестный город должен давать ошибку")
+	}
+}
@@ -0,0 +1,170 @@
+package calc
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+	_ "time/tzdata" // Часовые пояса не зависят от tzdata образа
+	"unicode"
+)
+
+var monthsGen = [...]string{"января", "февраля", "марта", "апреля", "мая", "июня", "июля", "августа", "сентября", "октября", "ноября", "декабря"}
+
+var weekdays = [...]string{"воскресенье", "понедельник", "вторник", "среда", "четверг", "пятница", "суббота"}
+
+// cities — города и сокращения, которые пишут вместо имени часового пояса.
+var cities = map[string]string{
+	"москва": "Europe/Moscow", "москве": "Europe/Moscow", "мск": "Europe/Moscow", "msk": "Europe/Moscow", "moscow": "Europe/Moscow",
+	"санкт-петербург": "Europe/Moscow", "питер": "Europe/Moscow", "питере": "Europe/Moscow", "петербурге": "Europe/Moscow",
+	"калининград": "Europe/Kaliningrad", "калининграде": "Europe/Kaliningrad",
+	"самара": "Europe/Samara", "самаре": "Europe/Samara",
+	"екатеринбург": "Asia/Yekaterinburg", "екатеринбурге": "Asia/Yekaterinburg",
+	"омск": "Asia/Omsk", "омске": "Asia/Omsk",
+	"новосибирск": "Asia/Novosibirsk", "новосибирске": "Asia/Novosibirsk",
+	"красноярск": "Asia/Krasnoyarsk", "красноярске": "Asia/Krasnoyarsk",
+	"иркутск": "Asia/Irkutsk", "иркутске": "Asia/Irkutsk",
+	"якутск": "Asia/Yakutsk", "якутске": "Asia/Yakutsk",
+	"владивосток": "Asia/Vladivostok", "владивостоке": "Asia/Vladivostok",
+	"магадан": "Asia/Magadan", "магадане": "Asia/Magadan",
+	"камчатка": "Asia/Kamchatka", "камчатке": "Asia/Kamchatka",
+	"минск": "Europe/Minsk", "минске": "Europe/Minsk", "киев": "Europe/Kyiv", "киеве": "Europe/Kyiv",
+	"алматы": "Asia/Almaty", "ташкент": "Asia/Tashkent", "ташкенте": "Asia/Tashkent",
+	"тбилиси": "Asia/Tbilisi", "ереван": "Asia/Yerevan", "ереване": "Asia/Yerevan", "баку": "Asia/Baku",
+	"лондон": "Europe/London", "лондоне": "Europe/London", "london": "Europe/London",
+	"берлин": "Europe/Berlin", "берлине": "Europe/Berlin", "berlin": "Europe/Berlin",
+	"париж": "Europe/Paris", "париже": "Europe/Paris", "paris": "Europe/Paris",
+	"стамбул": "Europe/Istanbul", "стамбуле": "Europe/Istanbul", "istanbul": "Europe/Istanbul",
+	"дубай": "Asia/Dubai", "дубае": "Asia/Dubai", "dubai": "Asia/Dubai",
+	"дели": "Asia/Kolkata", "delhi": "Asia/Kolkata",
+	"пекин": "Asia/Shanghai", "пекине": "Asia/Shanghai", "beijing": "Asia/Shanghai",
+	"шанхай": "Asia/Shanghai", "шанхае": "Asia/Shanghai", "shanghai": "Asia/Shanghai",
+	"сингапур": "Asia/Singapore", "сингапуре": "Asia/Singapore", "singapore": "Asia/Singapore",
+	"токио": "Asia/Tokyo", "tokyo": "Asia/Tokyo", "сеул": "Asia/Seoul", "сеуле": "Asia/Seoul", "seoul": "Asia/Seoul",
+	"сидней": "Australia/Sydney", "сиднее": "Australia/Sydney", "sydney": "Australia/Sydney",
+	"нью-йорк": "America/New_York", "нью-йорке": "America/New_York", "new york": "America/New_York", "nyc": "America/New_York",
+	"чикаго": "America/Chicago", "chicago": "America/Chicago",
+	"лос-анджелес": "America/Los_Angeles", "лос-анджелесе": "America/Los_Angeles", "los angeles": "America/Los_Angeles",
+	"сан-франциско": "America/Los_Angeles", "san francisco": "America/Los_Angeles",
+	"utc": "UTC", "gmt": "UTC",
+}
+
+// Location — часовой пояс по городу из списка, сокращению или имени IANA
+// без учёта регистра («europe/berlin»).
+func Location(place string) (*time.Location, error) {
+	place = strings.ToLower(strings.TrimSpace(place))
+	if name, ok := cities[place]; ok {
+		return time.LoadLocation(name)
+	}
+	if !strings.Contains(place, "/") {
+		return nil, fmt.Errorf("неизвестный часовой пояс %q", place)
+	}
+	// Имена IANA: каждая часть с заглавной буквы — america/new_york → America/New_York
+	var sb strings.Builder
+	upper := true
+	for _, r := range place {
+		if upper {
+			r = unicode.ToUpper(r)
+		}
+		sb.WriteRune(r)
+		upper = r == '/' || r == '_' || r == '-'
+	}
+	loc, err := time.LoadLocation(sb.String())
+	if err != nil {
+		return nil, fmt.Errorf("неизвестный часовой пояс %q", place)
+	}
+	return loc, nil
+}
+
+// ParseDate — дата из 2026-03-08, 08.03.2026, 08.03 или «нового года»
+// (ближайшая такая дата не раньше now) в часовом поясе now.
+func ParseDate(s string, now time.Time) (time.Time, error) {
+	s = strings.ToLower(strings.Join(strings.Fields(s), " "))
+	switch s {
+	case "новый год", "нового года", "new year":
+		return time.Date(now.Year()+1, time.January, 1, 0, 0, 0, 0, now.Location()), nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02", s, now.Location()); err == nil {
+		return t, nil
+	}
+	if t, err := time.ParseInLocation("2.1.2006", s, now.Location()); err == nil {
+		return t, nil
+	}
+	t, err := time.ParseInLocation("2.1", s, now.Location())
+	if err != nil {
+		return time.Time{}, fmt.Errorf("не дата: %q", s)
+	}
+	t = time.Date(now.Year(), t.Month(), t.Day(), 0, 0, 0, 0, now.Location())
+	if t.Before(Day(now)) {
+		t = t.AddDate(1, 0, 0)
+	}
+	return t, nil
+}
+
+// Day — начало дня t.
+func Day(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
+
+// DaysBetween — число календарных дней от a до b (отрицательное, если b раньше).
+func DaysBetween(a, b time.Time) int {
+	// Через UTC, чтобы переход на летнее время не давал 23- или 25-часовых суток
+	da := time.Date(a.Year(), a.Month(), a.Day(), 0, 0, 0, 0, time.UTC)
+	db := time.Date(b.Year(), b.Month(), b.Day(), 0, 0, 0, 0, time.UTC)
+	return int(math.Round(db.Sub(da).Hours() / 24))
+}
+
+// shiftUnits — единицы сдвига даты по всем написаниям.
+var shiftUnits = map[string]string{}
+
+func init() {
+	for unit, forms := range map[string][]string{
+		"minute": {"минуту", "минуты", "минут", "мин", "minute", "minutes", "min"},
+		"hour":   {"час", "часа", "часов", "ч", "hour", "hours"},
+		"day":    {"день", "дня", "дней", "сутки", "суток", "day", "days"},
+		"week":   {"неделю", "недели", "недель", "week", "weeks"},
+		"month":  {"месяц", "месяца", "месяцев", "month", "months"},
+		"year":   {"год", "года", "лет", "year", "years"},
+	} {
+		for _, f := range forms {
+			shiftUnits[f] = unit
+		}
+	}
+}
+
+// IsShiftUnit — единица, на которую умеет сдвигать Shift.
+func IsShiftUnit(s string) bool {
+	_, ok := shiftUnits[strings.ToLower(s)]
+	return ok
+}
+
+// Shift — t, сдвинутое на n единиц (минуты, часы, дни, недели, месяцы,
+// годы). clock — сдвиг меньше суток, в ответе нужно время, а не только дата.
+func Shift(t time.Time, n int, unit string) (res time.Time, clock bool, err error) {
+	switch shiftUnits[strings.ToLower(unit)] {
+	case "minute":
+		return t.Add(time.Duration(n) * time.Minute), true, nil
+	case "hour":
+		return t.Add(time.Duration(n) * time.Hour), true, nil
+	case "day":
+		return t.AddDate(0, 0, n), false, nil
+	case "week":
+		return t.AddDate(0, 0, 7*n), false, nil
+	case "month":
+		return t.AddDate(0, n, 0), false, nil
+	case "year":
+		return t.AddDate(n, 0, 0), false, nil
+	}
+	return time.Time{}, false, fmt.Errorf("неизвестная единица времени %q", unit)
+}
+
+// FormatDate — дата по-русски: «8 марта 2026, воскресенье».
+func FormatDate(t time.Time) string {
+	return strconv.Itoa(t.Day()) + " " + monthsGen[t.Month()-1] + " " + strconv.Itoa(t.Year()) + ", " + weekdays[t.Weekday()]
+}
+
+// FormatDateTime — дата и время: «8 марта 2026, воскресенье, 14:05».
+func FormatDateTime(t time.Time) string {
+	return FormatDate(t) + ", " + t.Format("15:04")
+}
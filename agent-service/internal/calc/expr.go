@@ -0,0 +1,201 @@
+// Package calc — детерминированные вычисления для интентов, отвечающих без
+// вызова LLM: арифметические выражения, перевод единиц измерения, арифметика
+// дат и время в часовых поясах.
+package calc
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// ErrSyntax — строка не является выражением, которое умеет считать Eval.
+var ErrSyntax = errors.New("не арифметическое выражение")
+
+// functions — функции выражений: sqrt(16), round(2.5).
+var functions = map[string]func(float64) float64{
+	"sqrt": math.Sqrt, "abs": math.Abs, "round": math.Round, "floor": math.Floor, "ceil": math.Ceil,
+	"sin": math.Sin, "cos": math.Cos, "tan": math.Tan, "ln": math.Log, "log": math.Log10, "exp": math.Exp,
+}
+
+// constants — именованные константы выражений.
+var constants = map[string]float64{"pi": math.Pi, "пи": math.Pi, "e": math.E}
+
+// Eval — значение арифметического выражения: + - * / % ^, скобки, унарный
+// минус, функции (sqrt, abs, round, floor, ceil, sin, cos, tan, ln, log, exp)
+// и константы pi, e. Десятичный разделитель — точка или запятая, «×» и «÷»
+// равны * и /. Неявное умножение («2(3)») не поддерживается — такая строка
+// вероятнее телефон, чем выражение.
+func Eval(expr string) (float64, error) {
+	p := &parser{s: strings.NewReplacer("×", "*", "÷", "/", "−", "-", "**", "^", ",", ".").Replace(expr)}
+	v, err := p.sum()
+	if err != nil {
+		return 0, err
+	}
+	if p.skip(); p.pos < len(p.s) {
+		return 0, fmt.Errorf("%w: лишнее %q", ErrSyntax, p.s[p.pos:])
+	}
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return 0, errors.New("результат не определён")
+	}
+	return v, nil
+}
+
+// parser — рекурсивный спуск: sum → product → unary → power → atom.
+// Унарный минус слабее степени: -2^2 = -4.
+type parser struct {
+	s   string
+	pos int
+}
+
+func (p *parser) skip() {
+	for p.pos < len(p.s) && p.s[p.pos] == ' ' {
+		p.pos++
+	}
+}
+
+// peek — следующий значимый символ (0 в конце строки).
+func (p *parser) peek() byte {
+	p.skip()
+	if p.pos < len(p.s) {
+		return p.s[p.pos]
+	}
+	return 0
+}
+
+func (p *parser) sum() (float64, error) {
+	v, err := p.product()
+	for err == nil {
+		op := p.peek()
+		if op != '+' && op != '-' {
+			break
+		}
+		p.pos++
+		var r float64
+		if r, err = p.product(); op == '+' {
+			v += r
+		} else {
+			v -= r
+		}
+	}
+	return v, err
+}
+
+func (p *parser) product() (float64, error) {
+	v, err := p.unary()
+	for err == nil {
+		op := p.peek()
+		if op != '*' && op != '/' && op != '%' {
+			break
+		}
+		p.pos++
+		var r float64
+		if r, err = p.unary(); err != nil {
+			break
+		}
+		if op != '*' && r == 0 {
+			return 0, errors.New("деление на ноль")
+		}
+		switch op {
+		case '*':
+			v *= r
+		case '/':
+			v /= r
+		default:
+			v = math.Mod(v, r)
+		}
+	}
+	return v, err
+}
+
+// power — возведение в степень правоассоциативно: 2^3^2 = 2^9.
+func (p *parser) power() (float64, error) {
+	v, err := p.atom()
+	if err != nil || p.peek() != '^' {
+		return v, err
+	}
+	p.pos++
+	e, err := p.unary()
+	return math.Pow(v, e), err
+}
+
+func (p *parser) unary() (float64, error) {
+	switch p.peek() {
+	case '-':
+		p.pos++
+		v, err := p.unary()
+		return -v, err
+	case '+':
+		p.pos++
+		return p.unary()
+	}
+	return p.power()
+}
+
+func (p *parser) atom() (float64, error) {
+	c := p.peek()
+	switch {
+	case c == '(':
+		p.pos++
+		v, err := p.sum()
+		if err != nil {
+			return 0, err
+		}
+		if p.peek() != ')' {
+			return 0, fmt.Errorf("%w: нет закрывающей скобки", ErrSyntax)
+		}
+		p.pos++
+		return v, nil
+	case c >= '0' && c <= '9' || c == '.':
+		start := p.pos
+		for p.pos < len(p.s) && (p.s[p.pos] >= '0' && p.s[p.pos] <= '9' || p.s[p.pos] == '.') {
+			p.pos++
+		}
+		v, err := strconv.ParseFloat(p.s[start:p.pos], 64)
+		if err != nil {
+			return 0, fmt.Errorf("%w: число %q", ErrSyntax, p.s[start:p.pos])
+		}
+		return v, nil
+	}
+	start := p.pos
+	for p.pos < len(p.s) {
+		r := rune(p.s[p.pos])
+		if r < 0x80 && !unicode.IsLetter(r) {
+			break
+		}
+		p.pos++
+	}
+	name := strings.ToLower(p.s[start:p.pos])
+	if name == "" {
+		return 0, fmt.Errorf("%w: ожидалось число", ErrSyntax)
+	}
+	if v, ok := constants[name]; ok {
+		return v, nil
+	}
+	fn, ok := functions[name]
+	if !ok || p.peek() != '(' {
+		return 0, fmt.Errorf("%w: неизвестное имя %q", ErrSyntax, name)
+	}
+	v, err := p.atom()
+	if err != nil {
+		return 0, err
+	}
+	return fn(v), nil
+}
+
+// Format — число для ответа: без хвоста погрешности (0.1+0.2 → 0.3) и без
+// экспоненты для обычных величин.
+func Format(v float64) string {
+	if a := math.Abs(v); a != 0 && (a >= 1e15 || a < 1e-6) {
+		return strconv.FormatFloat(v, 'g', 10, 64)
+	}
+	s := strconv.FormatFloat(v, 'f', 10, 64)
+	s = strings.TrimRight(strings.TrimRight(s, "0"), ".")
+	if s == "-0" {
+		return "0"
+	}
+	return s
+}
@@ -0,0 +1,117 @@
+package calc
+
+import (
+	"fmt"
+	"strings"
+)
+
+// unit — единица измерения: величина и множитель к базовой единице величины
+// (метр, килограмм, литр, секунда, байт, м/с). Температура пересчитывается
+// по формулам, множитель у неё не используется.
+type unit struct {
+	kind   string
+	factor float64
+	name   string // Обозначение в ответе
+}
+
+// units — единицы по всем написаниям; КБ, МБ и т.д. — двоичные (1024).
+var units = map[string]unit{}
+
+func init() {
+	add := func(kind string, factor float64, name string, aliases ...string) {
+		for _, a := range append(aliases, name) {
+			units[strings.ToLower(a)] = unit{kind: kind, factor: factor, name: name}
+		}
+	}
+	add("length", 0.001, "мм", "mm", "миллиметр", "миллиметра", "миллиметров", "миллиметрах", "миллиметры")
+	add("length", 0.01, "см", "cm", "сантиметр", "сантиметра", "сантиметров", "сантиметрах", "сантиметры")
+	add("length", 1, "м", "m", "метр", "метра", "метров", "метрах", "метры", "meter", "meters")
+	add("length", 1000, "км", "km", "километр", "километра", "километров", "километрах", "километры")
+	add("length", 0.0254, "дюйм", "in", "inch", "inches", "дюйма", "дюймов", "дюймах", "дюймы")
+	add("length", 0.3048, "фут", "ft", "foot", "feet", "фута", "футов", "футах", "футы")
+	add("length", 0.9144, "ярд", "yd", "yard", "yards", "ярда", "ярдов", "ярдах", "ярды")
+	add("length", 1609.344, "миля", "mi", "mile", "miles", "мили", "миль", "милях", "милю")
+	add("length", 1852, "морская миля", "nmi", "морских миль", "морские мили")
+
+	add("mass", 1e-6, "мг", "mg", "миллиграмм", "миллиграммов")
+	add("mass", 0.001, "г", "g", "гр", "грамм", "грамма", "граммов", "граммах", "граммы")
+	add("mass", 1, "кг", "kg", "килограмм", "килограмма", "килограммов", "килограммах", "килограммы")
+	add("mass", 1000, "т", "t", "тонна", "тонны", "тонн", "тоннах", "тонну")
+	add("mass", 0.028349523125, "унция", "oz", "ounce", "ounces", "унции", "унций", "унциях")
+	add("mass", 0.45359237, "фунт", "lb", "lbs", "pound", "pounds", "фунта", "фунтов", "фунтах", "фунты")
+
+	add("volume", 0.001, "мл", "ml", "миллилитр", "миллилитров", "миллилитры")
+	add("volume", 1, "л", "l", "литр", "литра", "литров", "литрах", "литры", "liter", "liters")
+	add("volume", 3.785411784, "галлон", "gal", "gallon", "gallons", "галлона", "галлонов", "галлонах", "галлоны")
+
+	add("time", 0.001, "мс", "ms")
+	add("time", 1, "с", "s", "sec", "сек", "секунда", "секунды", "секунд", "секундах", "секунду")
+	add("time", 60, "мин", "min", "минута", "минуты", "минут", "минутах", "минуту")
+	add("time", 3600, "ч", "h", "час", "часа", "часов", "часах", "часы", "hour", "hours")
+	add("time", 86400, "сут", "d", "день", "дня", "дней", "днях", "дни", "сутки", "суток", "day", "days")
+	add("time", 604800, "нед", "неделя", "недели", "недель", "неделях", "неделю", "week", "weeks")
+
+	add("data", 1, "Б", "b", "байт", "байта", "байтов", "байты", "bytes")
+	add("data", 1<<10, "КБ", "kb", "kib", "килобайт", "килобайта", "килобайтов", "килобайты")
+	add("data", 1<<20, "МБ", "mb", "mib", "мегабайт", "мегабайта", "мегабайтов", "мегабайтах", "мегабайты")
+	add("data", 1<<30, "ГБ", "gb", "gib", "гигабайт", "гигабайта", "гигабайтов", "гигабайтах", "гигабайты")
+	add("data", 1<<40, "ТБ", "tb", "tib", "терабайт", "терабайта", "терабайтов", "терабайтах", "терабайты")
+
+	add("speed", 1, "м/с", "m/s")
+	add("speed", 1/3.6, "км/ч", "km/h", "kmh", "kph")
+	add("speed", 0.44704, "mph", "миль/ч")
+	add("speed", 0.514444, "уз", "kn", "узел", "узла", "узлов", "узлах", "knots")
+
+	add("temperature", 0, "°C", "c", "°c", "цельсий", "цельсия", "цельсию", "градусов цельсия", "по цельсию", "celsius")
+	add("temperature", 0, "°F", "f", "°f", "фаренгейт", "фаренгейта", "фаренгейту", "градусов фаренгейта", "по фаренгейту", "fahrenheit")
+	add("temperature", 0, "K", "k", "кельвин", "кельвина", "кельвинов", "кельвины", "kelvin")
+}
+
+// IsUnit — известна ли единица (для распознавания интента).
+func IsUnit(s string) bool {
+	_, ok := units[strings.ToLower(strings.TrimSpace(s))]
+	return ok
+}
+
+// Convert — перевод value из единицы from в единицу to одной величины.
+// Возвращает значение и обозначение целевой единицы.
+func Convert(value float64, from, to string) (float64, string, error) {
+	f, ok := units[strings.ToLower(strings.TrimSpace(from))]
+	if !ok {
+		return 0, "", fmt.Errorf("неизвестная единица %q", from)
+	}
+	t, ok := units[strings.ToLower(strings.TrimSpace(to))]
+	if !ok {
+		return 0, "", fmt.Errorf("неизвестная единица %q", to)
+	}
+	if f.kind != t.kind {
+		return 0, "", fmt.Errorf("нельзя перевести %s в %s", f.name, t.name)
+	}
+	if f.kind != "temperature" {
+		return value * f.factor / t.factor, t.name, nil
+	}
+	var celsius float64
+	switch f.name {
+	case "°F":
+		celsius = (value - 32) * 5 / 9
+	case "K":
+		celsius = value - 273.15
+	default:
+		celsius = value
+	}
+	switch t.name {
+	case "°F":
+		return celsius*9/5 + 32, t.name, nil
+	case "K":
+		return celsius + 273.15, t.name, nil
+	}
+	return celsius, t.name, nil
+}
+
+// UnitName — обозначение единицы для ответа («километров» → «км»).
+func UnitName(s string) string {
+	if u, ok := units[strings.ToLower(strings.TrimSpace(s))]; ok {
+		return u.name
+	}
+	return s
+}
@@ -0,0 +1,158 @@
+// Package clarify — уточняющие вопросы агента пользователю (инструмент ask_user).
+//
+// Когда модели не хватает параметров (какую ветку удалить, в какой каталог
+// писать), она вызывает ask_user вместо того, чтобы угадывать. Цикл
+// инструментов /chat останавливается: контекст модели сохраняется в Store, а
+// клиент получает вопрос в поле clarification ответа (status needs_input).
+// Следующее сообщение пользователя в том же диалоге считается ответом: оно
+// возвращается модели как результат вызова ask_user, и цикл продолжается с
+// места остановки.
+package clarify
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+
+	"github.com/neo-2022/openclaw-memory/agent-service/internal/llm"
+)
+
+// ToolName — имя инструмента уточняющего вопроса.
+const ToolName = "ask_user"
+
+// Лимиты вопроса: модель не должна превращать его в анкету.
+const (
+	MaxQuestionChars = 1000
+	MaxOptions       = 10
+	MaxOptionChars   = 200
+)
+
+// Question — вопрос пользователю (поле clarification ответа /chat).
+type Question struct {
+	ID       string   `json:"id"`
+	Question string   `json:"question"`
+	Options  []string `json:"options,omitempty"` // Варианты ответа, если модель их предложила
+	Reason   string   `json:"reason,omitempty"`  // Зачем агенту нужен ответ
+}
+
+// Parse — вопрос из аргументов вызова ask_user.
+func Parse(args map[string]interface{}) (Question, error) {
+	q := Question{ID: uuid.NewString()}
+	q.Question, _ = args["question"].(string)
+	q.Question = strings.TrimSpace(q.Question)
+	if q.Question == "" {
+		return Question{}, fmt.Errorf("question: пустой вопрос")
+	}
+	if utf8.RuneCountInString(q.Question) > MaxQuestionChars {
+		return Question{}, fmt.Errorf("question: длиннее %d символов", MaxQuestionChars)
+	}
+	q.Reason, _ = args["reason"].(string)
+	q.Reason = strings.TrimSpace(q.Reason)
+	if opts, ok := args["options"].([]interface{}); ok {
+		for _, o := range opts {
+			s, _ := o.(string)
+			if s = strings.TrimSpace(s); s == "" {
+				continue
+			}
+			if utf8.RuneCountInString(s) > MaxOptionChars {
+				return Question{}, fmt.Errorf("options: вариант длиннее %d символов", MaxOptionChars)
+			}
+			q.Options = append(q.Options, s)
+		}
+	}
+	if len(q.Options) > MaxOptions {
+		return Question{}, fmt.Errorf("options: больше %d вариантов", MaxOptions)
+	}
+	return q, nil
+}
+
+// Text — вопрос для текста ответа (клиенты, не знающие поле clarification).
+func (q Question) Text() string {
+	var b strings.Builder
+	b.WriteString(q.Question)
+	if q.Reason != "" {
+		fmt.Fprintf(&b, "\n\n_%s_", q.Reason)
+	}
+	if len(q.Options) > 0 {
+		b.WriteString("\n\nВарианты:\n")
+		for i, o := range q.Options {
+			fmt.Fprintf(&b, "%d. %s\n", i+1, o)
+		}
+	}
+	return strings.TrimSpace(b.String())
+}
+
+// Pending — остановленный цикл инструментов: контекст модели на момент
+// вопроса (с системным промптом первым сообщением) и ID вызова ask_user.
+type Pending struct {
+	Question Question
+	CallID   string
+	Messages []llm.Message
+}
+
+// Resume — контекст для продолжения: системный промпт заменяется текущим,
+// ответ пользователя добавляется результатом вызова ask_user.
+func (p *Pending) Resume(systemPrompt, answer string) []llm.Message {
+	out := make([]llm.Message, len(p.Messages), len(p.Messages)+1)
+	copy(out, p.Messages)
+	if len(out) > 0 && out[0].Role == "system" {
+		out[0].Content = systemPrompt
+	}
+	result, _ := json.Marshal(map[string]string{"answer": answer})
+	return append(out, llm.Message{Role: "tool", Content: string(result), ToolCallID: p.CallID})
+}
+
+// Store — вопросы, ожидающие ответа, по диалогам (см. Key). У диалога не
+// больше одного вопроса: новый заменяет прежний.
+type Store struct {
+	mu      sync.Mutex
+	pending map[string]storeEntry
+	now     func() time.Time
+}
+
+type storeEntry struct {
+	p     Pending
+	until time.Time
+}
+
+// NewStore — пустое хранилище.
+func NewStore() *Store {
+	return &Store{pending: map[string]storeEntry{}, now: time.Now}
+}
+
+// Key — ключ диалога: агент и ID диалога из /conversations (пустой — без диалога).
+func Key(agent, chatID string) string {
+	return agent + "\x00" + chatID
+}
+
+// Ask — запоминает вопрос на ttl.
+func (s *Store) Ask(key string, p Pending, ttl time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pending[key] = storeEntry{p: p, until: s.now().Add(ttl)}
+}
+
+// Take — забирает непросроченный вопрос диалога; id — ID вопроса ("" — любой).
+// Вопрос одноразовый: повторный Take его не вернёт.
+func (s *Store) Take(key, id string) (*Pending, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.pending[key]
+	if !ok {
+		return nil, false
+	}
+	if s.now().After(e.until) {
+		delete(s.pending, key)
+		return nil, false
+	}
+	if id != "" && e.p.Question.ID != id {
+		return nil, false
+	}
+	delete(s.pending, key)
+	return &e.p, true
+}
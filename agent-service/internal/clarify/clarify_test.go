@@ -0,0 +1,82 @@
+package clarify
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/neo-2022/openclaw-memory/agent-service/internal/llm"
+)
+
+func TestParse(t *testing.T) {
+	q, err := Parse(map[string]interface{}{
+		"question": " Какую ветку удалить? ",
+		"options":  []interface{}{"feature/a", "", "feature/b"},
+		"reason":   "веток несколько",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if q.ID == "" || q.Question != "Какую ветку удалить?" || len(q.Options) != 2 {
+		t.Errorf("вопрос = %+v", q)
+	}
+	if !strings.Contains(q.Text(), "2. feature/b") {
+		t.Errorf("Text = %q", q.Text())
+	}
+	for _, args := range []map[string]interface{}{
+		{},
+		{"question": "  "},
+		{"question": strings.Repeat("?", MaxQuestionChars+1)},
+	} {
+		if _, err := Parse(args); err == nil {
+			t.Errorf("%v: ожидалась ошибка", args)
+		}
+	}
+}
+
+func TestStore_TakeOnce(t *testing.T) {
+	s := NewStore()
+	key := Key("coder", "chat-1")
+	s.Ask(key, Pending{Question: Question{ID: "q1"}, CallID: "call-1"}, time.Minute)
+	if _, ok := s.Take(key, "other"); ok {
+		t.Error("чужой ID вопроса не должен подходить")
+	}
+	if _, ok := s.Take(Key("coder", "chat-2"), ""); ok {
+		t.Error("вопрос другого диалога")
+	}
+	p, ok := s.Take(key, "q1")
+	if !ok || p.CallID != "call-1" {
+		t.Fatalf("Take = %+v, %v", p, ok)
+	}
+	if _, ok := s.Take(key, ""); ok {
+		t.Error("вопрос одноразовый")
+	}
+}
+
+func TestStore_Expired(t *testing.T) {
+	s := NewStore()
+	now := time.Now()
+	s.now = func() time.Time { return now }
+	s.Ask("k", Pending{Question: Question{ID: "q"}}, time.Minute)
+	now = now.Add(2 * time.Minute)
+	if _, ok := s.Take("k", ""); ok {
+		t.Error("просроченный вопрос")
+	}
+}
+
+func TestResume(t *testing.T) {
+	p := &Pending{CallID: "call-7", Messages: []llm.Message{
+		{Role: "system", Content: "старый промпт"},
+		{Role: "user", Content: "удали ветку"},
+	}}
+	out := p.Resume("новый промпт", "feature/a")
+	if len(out) != 3 || out[0].Content != "новый промпт" {
+		t.Fatalf("контекст = %+v", out)
+	}
+	if last := out[2]; last.Role != "tool" || last.ToolCallID != "call-7" || last.Content != `{"answer":"feature/a"}` {
+		t.Errorf("ответ = %+v", last)
+	}
+	if p.Messages[0].Content != "старый промпт" {
+		t.Error("сохранённый контекст не должен меняться")
+	}
+}
@@ -1,51 +1,1030 @@
 // Package config — централизованная конфигурация agent-service.
 //
-// Все параметры загружаются из переменных окружения с указанными значениями по умолчанию.
-// Используется для единообразного доступа к настройкам из любого пакета сервиса.
+// Параметры собираются в три слоя: значения по умолчанию → YAML-файл
+// (AGENT_CONFIG_FILE, необязателен) → переменные окружения. Конфигурация
+// проверяется при старте (порты, URL, обязательные ключи); часть параметров
+// (Tunable) можно перечитать на лету через Reload без перезапуска сервиса.
 package config
 
-import "os"
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/neo-2022/openclaw-memory/agent-service/internal/httpclient"
+	"github.com/neo-2022/openclaw-memory/agent-service/internal/i18n"
+	"github.com/neo-2022/openclaw-memory/agent-service/internal/limiter"
+	"github.com/neo-2022/openclaw-memory/agent-service/internal/maintenance"
+	"github.com/neo-2022/openclaw-memory/agent-service/internal/uploads"
+	"gopkg.in/yaml.v3"
+)
 
 // Config — структура конфигурации agent-service.
-// Содержит все параметры подключения к БД, внешним сервисам и пути к ресурсам.
+// Содержит параметры подключения к БД, внешним сервисам, лимиты и настройки RAG.
 type Config struct {
-	Port              string // Порт HTTP-сервера агента (по умолчанию 8083)
-	DBHost            string // Хост PostgreSQL (по умолчанию localhost)
-	DBPort            string // Порт PostgreSQL (по умолчанию 5432)
-	DBUser            string // Пользователь PostgreSQL
-	DBPassword        string // Пароль PostgreSQL
-	DBName            string // Имя базы данных
-	MemoryServiceURL  string // URL сервиса памяти (RAG)
-	ToolsServiceURL   string // URL сервиса инструментов
-	BrowserServiceURL string // URL сервиса браузера
-	OllamaURL         string // URL Ollama API для LLM
-	UploadsDir        string // Директория для загруженных файлов
-	SkillsDir         string // Директория с пользовательскими скиллами
-}
-
-// Load — загружает конфигурацию из переменных окружения.
-// Если переменная не задана, используется значение по умолчанию.
-func Load() *Config {
+	Port string `yaml:"port" json:"port"` // Порт HTTP-сервера агента (по умолчанию 8083)
+
+	DBDriver    string `yaml:"db_driver" json:"db_driver"`                 // postgres (по умолчанию) или sqlite
+	SQLitePath  string `yaml:"db_sqlite_path" json:"db_sqlite_path"`       // Файл базы для DB_DRIVER=sqlite
+	DatabaseURL string `yaml:"database_url" json:"database_url,omitempty"` // DSN PostgreSQL; если задан, DB_* не используются
+	DBHost      string `yaml:"db_host" json:"db_host"`                     // Хост PostgreSQL (по умолчанию localhost)
+	DBPort      string `yaml:"db_port" json:"db_port"`                     // Порт PostgreSQL (по умолчанию 5432)
+	DBUser      string `yaml:"db_user" json:"db_user"`                     // Пользователь PostgreSQL
+	DBPassword  string `yaml:"db_password" json:"db_password"`             // Пароль PostgreSQL
+	DBName      string `yaml:"db_name" json:"db_name"`                     // Имя базы данных
+
+	DBMaxOpenConns    int           `yaml:"db_max_open_conns" json:"db_max_open_conns"`         // Максимум открытых соединений пула
+	DBMaxIdleConns    int           `yaml:"db_max_idle_conns" json:"db_max_idle_conns"`         // Максимум простаивающих соединений
+	DBConnMaxLifetime time.Duration `yaml:"db_conn_max_lifetime" json:"db_conn_max_lifetime"`   // Время жизни соединения
+	DBConnMaxIdleTime time.Duration `yaml:"db_conn_max_idle_time" json:"db_conn_max_idle_time"` // Простой, после которого соединение закрывается
+
+	MemoryServiceURL  string `yaml:"memory_service_url" json:"memory_service_url"`   // URL сервиса памяти (RAG)
+	ToolsServiceURL   string `yaml:"tools_service_url" json:"tools_service_url"`     // URL сервиса инструментов
+	ToolsServiceToken string `yaml:"tools_service_token" json:"tools_service_token"` // Токен для tools-service
+	ToolsGRPCAddr     string `yaml:"tools_grpc_addr" json:"tools_grpc_addr"`         // Адрес gRPC tools-service (host:port); пусто — вызовы по HTTP
+	BrowserServiceURL string `yaml:"browser_service_url" json:"browser_service_url"` // URL сервиса браузера
+	BrowserGRPCAddr   string `yaml:"browser_grpc_addr" json:"browser_grpc_addr"`     // Адрес gRPC browser-service (host:port); пусто — вызовы по HTTP
+	OllamaURL         string `yaml:"ollama_url" json:"ollama_url"`                   // URL Ollama API для LLM
+
+	UploadsDir    string `yaml:"uploads_dir" json:"uploads_dir"`         // Директория для загруженных файлов
+	ArtifactsDir  string `yaml:"artifacts_dir" json:"artifacts_dir"`     // Директория файлов, созданных агентами (/artifacts)
+	SkillsDir     string `yaml:"skills_dir" json:"skills_dir"`           // Директория с пользовательскими скиллами
+	RecipesDir    string `yaml:"recipes_dir" json:"recipes_dir"`         // YAML-рецепты составных скилов (см. пакет recipe)
+	IntentsFile   string `yaml:"intents_file" json:"intents_file"`       // YAML с пользовательскими интентами (пусто — только встроенные)
+	RiskRulesFile string `yaml:"risk_rules_file" json:"risk_rules_file"` // YAML с дополнительными правилами риска инструментов
+
+	ProviderGuidesDir string `yaml:"provider_guides_dir" json:"provider_guides_dir"` // Руководства провайдеров <язык>/<провайдер>.yaml поверх встроенных (пусто — только встроенные)
+
+	// Раздача файлов: /uploads/ и подписанные ссылки на артефакты, см. пакет uploads
+	UploadsPublicPaths    string        `yaml:"uploads_public_paths" json:"uploads_public_paths"`         // Подкаталоги uploads/, доступные через /uploads/ (через запятую)
+	ArtifactURLSecret     string        `yaml:"artifact_url_secret" json:"artifact_url_secret"`           // Ключ подписи ссылок на артефакты (пусто — без подписи)
+	ArtifactURLTTL        time.Duration `yaml:"artifact_url_ttl" json:"artifact_url_ttl"`                 // Срок действия подписанной ссылки по умолчанию
+	ArtifactURLRequireSig bool          `yaml:"artifact_url_require_sig" json:"artifact_url_require_sig"` // Скачивание артефактов только по подписанным ссылкам
+
+	MaxBodyBytes   int64         `yaml:"max_body_bytes" json:"max_body_bytes"`     // Лимит тела запроса (AGENT_MAX_BODY_BYTES)
+	MaxUploadBytes int64         `yaml:"max_upload_bytes" json:"max_upload_bytes"` // Лимит загрузки файлов (AGENT_MAX_UPLOAD_BYTES)
+	GzipEnabled    bool          `yaml:"gzip_enabled" json:"gzip_enabled"`         // Сжатие ответов (AGENT_GZIP_ENABLED)
+	DrainTimeout   time.Duration `yaml:"drain_timeout" json:"drain_timeout"`       // Ожидание активных чатов при остановке
+
+	BatchConcurrency int `yaml:"batch_concurrency" json:"batch_concurrency"` // Одновременных запросов /chat/batch на все задания
+	BatchMaxItems    int `yaml:"batch_max_items" json:"batch_max_items"`     // Запросов в одном задании
+	BatchMaxJobs     int `yaml:"batch_max_jobs" json:"batch_max_jobs"`       // Одновременно выполняемых заданий
+
+	// Очередь фоновых заданий в БД, см. пакет jobqueue: задания переживают
+	// перезапуск и могут выполняться отдельными процессами-обработчиками
+	JobsQueue       bool          `yaml:"jobs_queue" json:"jobs_queue"`               // Тяжёлые фоновые задачи через очередь (false — горутины процесса)
+	JobsWorkers     int           `yaml:"jobs_workers" json:"jobs_workers"`           // Заданий, выполняемых процессом одновременно (0 — только постановка в очередь)
+	JobsWorkerOnly  bool          `yaml:"jobs_worker_only" json:"jobs_worker_only"`   // Процесс-обработчик: только задания, /health, /ready, /metrics и /jobs
+	JobsMaxAttempts int           `yaml:"jobs_max_attempts" json:"jobs_max_attempts"` // Попыток до статуса failed
+	JobsLease       time.Duration `yaml:"jobs_lease" json:"jobs_lease"`               // Аренда задания: после истечения его забирает другой обработчик
+	JobsPoll        time.Duration `yaml:"jobs_poll" json:"jobs_poll"`                 // Как часто обработчик проверяет очередь
+	JobsRetention   time.Duration `yaml:"jobs_retention" json:"jobs_retention"`       // Сколько хранить завершённые задания
+
+	// Шина сообщений о новых заданиях между процессами (см. jobqueue.Broker);
+	// без неё обработчики других процессов узнают о заданиях опросом БД
+	JobsBroker        string `yaml:"jobs_broker" json:"jobs_broker"`                 // nats (пусто — без шины)
+	JobsBrokerURL     string `yaml:"jobs_broker_url" json:"jobs_broker_url"`         // Адрес сервера шины: nats://localhost:4222
+	JobsBrokerSubject string `yaml:"jobs_broker_subject" json:"jobs_broker_subject"` // Префикс тем: <префикс>.<тип задания>
+
+	// Одновременные запросы к LLM, см. пакет limiter: сверх лимита запрос ждёт
+	// в очереди, при переполнении или истечении ожидания — 429 с Retry-After
+	ChatMaxConcurrent     int           `yaml:"chat_max_concurrent" json:"chat_max_concurrent"`         // Одновременных запросов /chat и производных на весь сервис (0 — без ограничения)
+	ChatMaxQueue          int           `yaml:"chat_max_queue" json:"chat_max_queue"`                   // Запросов, ожидающих места в каждом лимите
+	ChatQueueTimeout      time.Duration `yaml:"chat_queue_timeout" json:"chat_queue_timeout"`           // Сколько запрос ждёт места в очереди
+	RouteMaxConcurrent    string        `yaml:"route_max_concurrent" json:"route_max_concurrent"`       // Лимиты маршрутов: "/chat/speculative=2,/chat/audio=2"
+	ProviderMaxConcurrent string        `yaml:"provider_max_concurrent" json:"provider_max_concurrent"` // Лимиты провайдеров: "ollama=2,openrouter=8" (прочие без ограничения)
+
+	// Пул соединений исходящих запросов к сервисам и провайдерам, см. пакет httpclient
+	HTTPMaxIdleConnsPerHost int           `yaml:"http_max_idle_conns_per_host" json:"http_max_idle_conns_per_host"` // Простаивающих keep-alive соединений на хост
+	HTTPIdleConnTimeout     time.Duration `yaml:"http_idle_conn_timeout" json:"http_idle_conn_timeout"`             // Через сколько простаивающее соединение закрывается
+	HTTPClientTimeouts      string        `yaml:"http_client_timeouts" json:"http_client_timeouts"`                 // Таймауты назначений: "memory=10s,ollama=10m" (прочие — по умолчанию в коде)
+
+	ChromaURL      string `yaml:"chroma_url" json:"chroma_url"`           // URL ChromaDB (пусто — поиск по PostgreSQL)
+	EmbeddingModel string `yaml:"embedding_model" json:"embedding_model"` // Модель эмбеддингов RAG
+
+	VisionFallbackProvider string `yaml:"vision_fallback_provider" json:"vision_fallback_provider"` // Провайдер модели, описывающей изображения для текстовых моделей
+	VisionFallbackModel    string `yaml:"vision_fallback_model" json:"vision_fallback_model"`       // Мультимодальная модель для описания (пусто — только OCR)
+
+	STTBackend   string `yaml:"stt_backend" json:"stt_backend"`       // Распознавание речи: whisper_cpp, openai (пусто — /chat/audio отключён)
+	STTURL       string `yaml:"stt_url" json:"stt_url"`               // Адрес сервера whisper.cpp или базовый URL облачного API
+	STTModel     string `yaml:"stt_model" json:"stt_model"`           // Модель облачного распознавания (whisper-1)
+	STTLanguage  string `yaml:"stt_language" json:"stt_language"`     // Язык речи (ru)
+	TTSBackend   string `yaml:"tts_backend" json:"tts_backend"`       // Синтез речи: openai, http (пусто — без озвучивания)
+	TTSURL       string `yaml:"tts_url" json:"tts_url"`               // URL сервера синтеза или базовый URL облачного API
+	TTSModel     string `yaml:"tts_model" json:"tts_model"`           // Модель облачного синтеза (tts-1)
+	TTSVoice     string `yaml:"tts_voice" json:"tts_voice"`           // Голос
+	SpeechAPIKey string `yaml:"speech_api_key" json:"speech_api_key"` // Ключ облачного API речи (SPEECH_API_KEY, по умолчанию OPENAI_API_KEY)
+
+	// Интенты погоды и новостей без вызова LLM, см. пакет infosource
+	WeatherBackend      string `yaml:"weather_backend" json:"weather_backend"`             // open_meteo (пусто — интент WEATHER выключен)
+	WeatherURL          string `yaml:"weather_url" json:"weather_url"`                     // Базовый URL API прогноза (пусто — api.open-meteo.com)
+	WeatherGeocodingURL string `yaml:"weather_geocoding_url" json:"weather_geocoding_url"` // Базовый URL поиска мест (пусто — geocoding-api.open-meteo.com)
+	WeatherPlace        string `yaml:"weather_place" json:"weather_place"`                 // Город для «какая погода?» без названия места
+	NewsBackend         string `yaml:"news_backend" json:"news_backend"`                   // rss (пусто — интент NEWS выключен)
+	NewsFeeds           string `yaml:"news_feeds" json:"news_feeds"`                       // URL RSS- и Atom-лент через запятую
+
+	Tunable `yaml:",inline"`
+}
+
+// Tunable — параметры, которые применяются на лету при Reload.
+type Tunable struct {
+	RAGTopK          int `yaml:"rag_top_k" json:"rag_top_k"`                     // Сколько документов RAG подставлять в контекст
+	RAGMaxChunkLen   int `yaml:"rag_max_chunk_len" json:"rag_max_chunk_len"`     // Максимальная длина одного фрагмента
+	RAGMaxContextLen int `yaml:"rag_max_context_len" json:"rag_max_context_len"` // Максимальная суммарная длина контекста RAG
+
+	// Накопленные знания модели (learnings), см. пакет learnings
+	LearningsEnabled        bool    `yaml:"learnings_enabled" json:"learnings_enabled"`                   // Глобальный выключатель подстановки и извлечения знаний
+	LearningsTopK           int     `yaml:"learnings_top_k" json:"learnings_top_k"`                       // Сколько кандидатов запрашивать у memory-service
+	LearningsMinScore       float64 `yaml:"learnings_min_score" json:"learnings_min_score"`               // Порог релевантности кандидата
+	LearningsMaxPerCategory int     `yaml:"learnings_max_per_category" json:"learnings_max_per_category"` // Не больше стольких знаний одной категории
+	LearningsTokenBudget    int     `yaml:"learnings_token_budget" json:"learnings_token_budget"`         // Бюджет токенов на все знания в промпте
+
+	// Эпизодическая память (резюме прошлых диалогов), см. пакет episodic
+	EpisodicEnabled  bool    `yaml:"episodic_enabled" json:"episodic_enabled"`     // Сохранять эпизоды и вспоминать похожие
+	EpisodicTopK     int     `yaml:"episodic_top_k" json:"episodic_top_k"`         // Сколько прошлых эпизодов подставлять
+	EpisodicMinScore float64 `yaml:"episodic_min_score" json:"episodic_min_score"` // Минимальная близость эпизода к запросу
+
+	// Карта репозитория рабочего пространства в промпте агента, см. пакет repomap
+	RepoMapBudget int `yaml:"repo_map_budget" json:"repo_map_budget"` // Бюджет карты в символах (0 — не подставлять)
+
+	// Проверка линтерами после edit_file (POST /lint в tools-service)
+	LintAfterEdit bool `yaml:"lint_after_edit" json:"lint_after_edit"` // Прикладывать замечания линтеров к результату правки
+
+	// Маршрутизация запросов между быстрой и основной моделью, см. пакет routing
+	RouterEnabled         bool    `yaml:"router_enabled" json:"router_enabled"`                   // Выбирать модель под каждый запрос
+	RouterFastProvider    string  `yaml:"router_fast_provider" json:"router_fast_provider"`       // Провайдер быстрой модели
+	RouterFastModel       string  `yaml:"router_fast_model" json:"router_fast_model"`             // Быстрая модель для простых запросов
+	RouterClassifierModel string  `yaml:"router_classifier_model" json:"router_classifier_model"` // Модель-классификатор (пусто — эвристика)
+	RouterThreshold       float64 `yaml:"router_threshold" json:"router_threshold"`               // Сложность 0..1, начиная с которой нужна основная модель
+
+	// Двойная отправка /chat/speculative (эксперимент), см. пакет speculative
+	SpeculativeEnabled       bool   `yaml:"speculative_enabled" json:"speculative_enabled"`               // Показывать черновик быстрой модели до основного ответа
+	SpeculativeDraftProvider string `yaml:"speculative_draft_provider" json:"speculative_draft_provider"` // Провайдер черновой модели
+	SpeculativeDraftModel    string `yaml:"speculative_draft_model" json:"speculative_draft_model"`       // Черновая модель
+
+	// Защита от prompt-injection и утечек в ответе, см. пакет guard
+	GuardInputPolicy  string `yaml:"guard_input_policy" json:"guard_input_policy"`   // RAG, инструменты, веб: off, flag, block
+	GuardOutputPolicy string `yaml:"guard_output_policy" json:"guard_output_policy"` // Секреты и ПДн в ответе: off, flag, block
+
+	// Оценка риска вызовов инструментов, см. пакет risk
+	RiskAutoApprove bool `yaml:"risk_auto_approve" json:"risk_auto_approve"` // Выполнять разрушительные действия без подтверждения
+
+	// Бюджеты облачных моделей, см. пакет budget
+	BudgetPrices           string `yaml:"budget_prices" json:"budget_prices"`                       // Цены за 1M токенов: «модель=вход/выход» через запятую
+	BudgetFallbackProvider string `yaml:"budget_fallback_provider" json:"budget_fallback_provider"` // Локальный провайдер при превышении бюджета
+	BudgetFallbackModel    string `yaml:"budget_fallback_model" json:"budget_fallback_model"`       // Локальная модель (пусто — отказ вместо переключения)
+
+	// Кэш ответов LLM, см. пакет respcache
+	ResponseCacheMode       string        `yaml:"response_cache_mode" json:"response_cache_mode"`               // off, exact, semantic
+	ResponseCacheTTL        time.Duration `yaml:"response_cache_ttl" json:"response_cache_ttl"`                 // Время жизни ответа в кэше
+	ResponseCacheMaxEntries int           `yaml:"response_cache_max_entries" json:"response_cache_max_entries"` // Не больше стольких ответов в памяти
+	ResponseCacheSimilarity float64       `yaml:"response_cache_similarity" json:"response_cache_similarity"`   // Порог близости вопросов 0..1 (semantic)
+
+	// Названия диалогов /conversations, см. пакет convtitle
+	TitleEnabled  bool   `yaml:"title_enabled" json:"title_enabled"`   // Придумывать название после первого обмена репликами
+	TitleProvider string `yaml:"title_provider" json:"title_provider"` // Провайдер модели названий
+	TitleModel    string `yaml:"title_model" json:"title_model"`       // Дешёвая модель (пусто — ROUTER_FAST_MODEL или модель агента)
+
+	// Усечение больших результатов инструментов, см. пакет artifact
+	ToolResultMaxChars int `yaml:"tool_result_max_chars" json:"tool_result_max_chars"` // Длиннее — в контекст начало и конец, полный текст через get_artifact (0 — без усечения)
+
+	// Копии файлов перед изменением инструментами, см. пакет backup
+	BackupEnabled   bool          `yaml:"backup_enabled" json:"backup_enabled"`     // Сохранять файл перед write, edit_file и delete
+	BackupRetention time.Duration `yaml:"backup_retention" json:"backup_retention"` // Сколько хранить копии
+
+	// Запись запросов /chat для воспроизведения (POST /chat/replay), см. пакет replay
+	ReplayRecord bool `yaml:"replay_record" json:"replay_record"` // Записывать все запросы (иначе — только с полем record)
+
+	// Повтор вызова инструмента с подсказкой после ошибки, см. пакет correction
+	ToolRetryMax int `yaml:"tool_retry_max" json:"tool_retry_max"` // Подсказок на инструмент за запрос (0 — без подсказок)
+
+	// Уточняющие вопросы модели пользователю (инструмент ask_user), см. пакет clarify
+	AskUserEnabled bool          `yaml:"ask_user_enabled" json:"ask_user_enabled"` // Выдавать модели инструмент ask_user
+	AskUserTTL     time.Duration `yaml:"ask_user_ttl" json:"ask_user_ttl"`         // Сколько ждать ответа на вопрос; потом цикл не продолжается
+
+	// Повтор запросов к LLM при 429 и 502-504, см. пакет retry
+	LLMRetryMaxAttempts int           `yaml:"llm_retry_max_attempts" json:"llm_retry_max_attempts"` // Всего попыток, включая первую
+	LLMRetryBaseDelay   time.Duration `yaml:"llm_retry_base_delay" json:"llm_retry_base_delay"`     // Пауза после первой неудачи, далее удваивается (с jitter)
+	LLMRetryMaxDelay    time.Duration `yaml:"llm_retry_max_delay" json:"llm_retry_max_delay"`       // Предел паузы; Retry-After длиннее — без повтора
+	LLMRetryBudget      int           `yaml:"llm_retry_budget" json:"llm_retry_budget"`             // Повторов к одному провайдеру в минуту (0 — без ограничения)
+
+	// Предохранитель провайдеров LLM, см. пакет breaker
+	ProviderBreakerFailures  int           `yaml:"provider_breaker_failures" json:"provider_breaker_failures"`   // Ошибок подряд до отключения провайдера (0 — не отключать)
+	ProviderBreakerReset     time.Duration `yaml:"provider_breaker_reset" json:"provider_breaker_reset"`         // Через сколько пробный запрос к отключённому провайдеру
+	ProviderFallbackProvider string        `yaml:"provider_fallback_provider" json:"provider_fallback_provider"` // Провайдер вместо отключённого
+	ProviderFallbackModel    string        `yaml:"provider_fallback_model" json:"provider_fallback_model"`       // Модель вместо отключённого провайдера (пусто — ошибка без переключения)
+
+	// Остаток лимитов облачных провайдеров (GET /providers/{name}/quota)
+	ProviderQuotaTTL  time.Duration `yaml:"provider_quota_ttl" json:"provider_quota_ttl"`   // Как часто чат обновляет остаток в фоне (0 — не проверять)
+	ProviderQuotaWarn float64       `yaml:"provider_quota_warn" json:"provider_quota_warn"` // Доля остатка, ниже которой ответ чата предупреждает (0 — без предупреждений)
+
+	// Фоновое обновление списков моделей облачных провайдеров, см. пакет modelwatch
+	ModelRefreshInterval time.Duration `yaml:"model_refresh_interval" json:"model_refresh_interval"` // Как часто перечитывать каталоги моделей (0 — не обновлять)
+
+	// Сторож сервисов: проверка здоровья и перезапуск упавших, см. пакет watchdog
+	WatchdogInterval time.Duration `yaml:"watchdog_interval" json:"watchdog_interval"` // Как часто проверять сервисы (0 — сторож выключен)
+	WatchdogFile     string        `yaml:"watchdog_file" json:"watchdog_file"`         // YAML с сервисами и действиями перезапуска (пусто — tools-, memory- и browser-service без перезапуска)
+	WatchdogFailures int           `yaml:"watchdog_failures" json:"watchdog_failures"` // Неудачных проверок подряд до перезапуска
+	WatchdogCooldown time.Duration `yaml:"watchdog_cooldown" json:"watchdog_cooldown"` // Пауза между перезапусками одного сервиса
+
+	// Ночное обслуживание и утренняя сводка, см. пакет maintenance
+	MaintenanceTime       string  `yaml:"maintenance_time" json:"maintenance_time"`               // Время запуска ЧЧ:ММ по часам сервера (пусто — не запускать)
+	MaintenanceDiskWarn   float64 `yaml:"maintenance_disk_warn" json:"maintenance_disk_warn"`     // Доля заполнения диска, с которой сводка предупреждает
+	MaintenanceWebhookURL string  `yaml:"maintenance_webhook_url" json:"maintenance_webhook_url"` // Куда отправлять сводку (POST JSON; пусто — только артефакт)
+
+	// Резервная копия всего стека (/admin/backup, /admin/restore), см. пакет stackbackup
+	StackBackupDir         string `yaml:"stack_backup_dir" json:"stack_backup_dir"`                     // Каталог архивов
+	StackBackupKeep        int    `yaml:"stack_backup_keep" json:"stack_backup_keep"`                   // Сколько последних архивов хранить локально (0 — все)
+	StackBackupRemote      string `yaml:"stack_backup_remote" json:"stack_backup_remote"`               // ydisk:/папка или s3://бакет/префикс (пусто — только локально)
+	YandexDiskToken        string `yaml:"yandex_disk_token" json:"yandex_disk_token"`                   // OAuth-токен Яндекс.Диска для ydisk:
+	StackBackupS3Endpoint  string `yaml:"stack_backup_s3_endpoint" json:"stack_backup_s3_endpoint"`     // Адрес S3-совместимого хранилища
+	StackBackupS3Region    string `yaml:"stack_backup_s3_region" json:"stack_backup_s3_region"`         // Регион для подписи запросов
+	StackBackupS3AccessKey string `yaml:"stack_backup_s3_access_key" json:"stack_backup_s3_access_key"` // Идентификатор ключа доступа
+	StackBackupS3SecretKey string `yaml:"stack_backup_s3_secret_key" json:"stack_backup_s3_secret_key"` // Секретный ключ
+
+	// Язык ответов API без выбора в запросе (?lang=, X-Language, Accept-Language), см. пакет i18n
+	DefaultLanguage string `yaml:"default_language" json:"default_language"` // ru или en
+
+	// Инструменты для моделей Ollama без tool calling через ограниченную генерацию (format = JSON Schema)
+	OllamaConstrainedTools bool `yaml:"ollama_constrained_tools" json:"ollama_constrained_tools"` // Выдавать инструменты моделям без поддержки tools
+}
+
+// Драйверы базы данных (DB_DRIVER).
+const (
+	DriverPostgres = "postgres"
+	DriverSQLite   = "sqlite"
+)
+
+// Defaults — конфигурация по умолчанию (локальная установка).
+func Defaults() *Config {
 	return &Config{
-		Port:              getEnv("AGENT_PORT", "8083"),
-		DBHost:            getEnv("DB_HOST", "localhost"),
-		DBPort:            getEnv("DB_PORT", "5432"),
-		DBUser:            getEnv("DB_USER", "agentcore"),
-		DBPassword:        getEnv("DB_PASSWORD", "agentcore"),
-		DBName:            getEnv("DB_NAME", "agentcore"),
-		MemoryServiceURL:  getEnv("MEMORY_SERVICE_URL", "http://localhost:8001"),
-		ToolsServiceURL:   getEnv("TOOLS_SERVICE_URL", "http://localhost:8082"),
-		BrowserServiceURL: getEnv("BROWSER_SERVICE_URL", "http://localhost:8084"),
-		OllamaURL:         getEnv("OLLAMA_URL", "http://127.0.0.1:11434"),
-		UploadsDir:        getEnv("UPLOADS_DIR", "./uploads"),
-		SkillsDir:         getEnv("SKILLS_DIR", "./skills"),
-	}
-}
-
-// getEnv — возвращает значение переменной окружения или fallback, если не задана.
-func getEnv(key, fallback string) string {
-	if v := os.Getenv(key); v != "" {
-		return v
-	}
-	return fallback
+		Port:                   "8083",
+		DBDriver:               DriverPostgres,
+		SQLitePath:             "./data/agent.db",
+		DBHost:                 "localhost",
+		DBPort:                 "5432",
+		DBUser:                 "agent_user",
+		DBPassword:             "agent_password",
+		DBName:                 "agent_db",
+		DBMaxOpenConns:         25,
+		DBMaxIdleConns:         10,
+		DBConnMaxLifetime:      30 * time.Minute,
+		DBConnMaxIdleTime:      5 * time.Minute,
+		MemoryServiceURL:       "http://localhost:8001",
+		ToolsServiceURL:        "http://localhost:8082",
+		BrowserServiceURL:      "http://localhost:8084",
+		OllamaURL:              "http://localhost:11434",
+		UploadsDir:             "./uploads",
+		ArtifactsDir:           "./artifacts",
+		SkillsDir:              "./skills",
+		RecipesDir:             "./recipes",
+		MaxBodyBytes:           10 << 20,
+		MaxUploadBytes:         100 << 20,
+		GzipEnabled:            true,
+		DrainTimeout:           5 * time.Minute,
+		BatchConcurrency:       2,
+		BatchMaxItems:          500,
+		BatchMaxJobs:           4,
+		ChatMaxConcurrent:      8,
+		ChatMaxQueue:           16,
+		ChatQueueTimeout:       30 * time.Second,
+		RouteMaxConcurrent:     "/chat/speculative=2",
+		ProviderMaxConcurrent:  "ollama=2",
+		EmbeddingModel:         "nomic-embed-text",
+		VisionFallbackProvider: "ollama",
+		VisionFallbackModel:    "llava:7b",
+		STTBackend:             "whisper_cpp",
+		STTLanguage:            "ru",
+
+		HTTPMaxIdleConnsPerHost: 32,
+		HTTPIdleConnTimeout:     90 * time.Second,
+
+		JobsWorkers:     2,
+		JobsMaxAttempts: 5,
+		JobsLease:       5 * time.Minute,
+		JobsPoll:        5 * time.Second,
+		JobsRetention:   7 * 24 * time.Hour,
+
+		JobsBrokerURL:     "nats://localhost:4222",
+		JobsBrokerSubject: "agent.jobs",
+
+		UploadsPublicPaths: "avatars/",
+		ArtifactURLTTL:     15 * time.Minute,
+
+		Tunable: Tunable{
+			RAGTopK:          5,
+			RAGMaxChunkLen:   2000,
+			RAGMaxContextLen: 8000,
+
+			LearningsEnabled:        true,
+			LearningsTopK:           10,
+			LearningsMinScore:       0.35,
+			LearningsMaxPerCategory: 2,
+			LearningsTokenBudget:    400,
+
+			EpisodicEnabled:  false,
+			EpisodicTopK:     2,
+			EpisodicMinScore: 0.6,
+
+			RepoMapBudget: 6000,
+
+			LintAfterEdit: false,
+
+			RouterEnabled:      false,
+			RouterFastProvider: "ollama",
+			RouterThreshold:    0.4,
+
+			SpeculativeEnabled:       false,
+			SpeculativeDraftProvider: "ollama",
+
+			GuardInputPolicy:  "flag",
+			GuardOutputPolicy: "flag",
+
+			BudgetFallbackProvider: "ollama",
+
+			ResponseCacheMode:       "off",
+			ResponseCacheTTL:        10 * time.Minute,
+			ResponseCacheMaxEntries: 500,
+			ResponseCacheSimilarity: 0.92,
+
+			TitleEnabled:  true,
+			TitleProvider: "ollama",
+
+			ToolResultMaxChars: 12000,
+
+			BackupEnabled:   true,
+			BackupRetention: 7 * 24 * time.Hour,
+
+			ToolRetryMax: 2,
+
+			AskUserEnabled: true,
+			AskUserTTL:     30 * time.Minute,
+
+			LLMRetryMaxAttempts: 3,
+			LLMRetryBaseDelay:   2 * time.Second,
+			LLMRetryMaxDelay:    30 * time.Second,
+			LLMRetryBudget:      30,
+
+			ProviderBreakerFailures:  5,
+			ProviderBreakerReset:     30 * time.Second,
+			ProviderFallbackProvider: "ollama",
+			ProviderQuotaTTL:         5 * time.Minute,
+			ProviderQuotaWarn:        0.1,
+			ModelRefreshInterval:     6 * time.Hour,
+
+			WatchdogFailures: 3,
+			WatchdogCooldown: 10 * time.Minute,
+
+			MaintenanceTime:     "03:00",
+			MaintenanceDiskWarn: 0.9,
+
+			StackBackupDir:        "./backups",
+			StackBackupKeep:       7,
+			StackBackupS3Endpoint: "https://storage.yandexcloud.net",
+			StackBackupS3Region:   "ru-central1",
+
+			DefaultLanguage: "ru",
+		},
+	}
+}
+
+// Load — загружает конфигурацию: значения по умолчанию, затем YAML-файл
+// из AGENT_CONFIG_FILE (если задан), затем переменные окружения.
+// Возвращает ошибку, если файл не читается или значения некорректны.
+func Load() (*Config, error) {
+	c := Defaults()
+	if path := os.Getenv("AGENT_CONFIG_FILE"); path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("чтение файла конфигурации: %w", err)
+		}
+		if err := yaml.Unmarshal(data, c); err != nil {
+			return nil, fmt.Errorf("разбор файла конфигурации %s: %w", path, err)
+		}
+	}
+	var errs []error
+	envString(&c.Port, "AGENT_SERVICE_PORT", "AGENT_PORT")
+	envString(&c.DBDriver, "DB_DRIVER")
+	envString(&c.SQLitePath, "DB_SQLITE_PATH")
+	envString(&c.DatabaseURL, "DATABASE_URL")
+	envString(&c.DBHost, "DB_HOST")
+	envString(&c.DBPort, "DB_PORT")
+	envString(&c.DBUser, "DB_USER")
+	envString(&c.DBPassword, "DB_PASSWORD")
+	envString(&c.DBName, "DB_NAME")
+	envString(&c.MemoryServiceURL, "MEMORY_SERVICE_URL")
+	envString(&c.ToolsServiceURL, "TOOLS_SERVICE_URL")
+	envString(&c.ToolsServiceToken, "TOOLS_SERVICE_TOKEN")
+	envString(&c.ToolsGRPCAddr, "TOOLS_GRPC_ADDR")
+	envString(&c.BrowserServiceURL, "BROWSER_SERVICE_URL")
+	envString(&c.BrowserGRPCAddr, "BROWSER_GRPC_ADDR")
+	envString(&c.JobsBroker, "JOBS_BROKER")
+	envString(&c.JobsBrokerURL, "JOBS_BROKER_URL")
+	envString(&c.JobsBrokerSubject, "JOBS_BROKER_SUBJECT")
+	envString(&c.OllamaURL, "OLLAMA_URL", "OLLAMA_HOST")
+	envString(&c.UploadsDir, "UPLOADS_DIR")
+	envString(&c.ArtifactsDir, "ARTIFACTS_DIR")
+	envString(&c.SkillsDir, "SKILLS_DIR")
+	envString(&c.RecipesDir, "RECIPES_DIR")
+	envString(&c.IntentsFile, "INTENTS_FILE")
+	envString(&c.RiskRulesFile, "RISK_RULES_FILE")
+	envString(&c.WatchdogFile, "WATCHDOG_FILE")
+	envString(&c.MaintenanceTime, "MAINTENANCE_TIME")
+	envString(&c.MaintenanceWebhookURL, "MAINTENANCE_WEBHOOK_URL")
+	envString(&c.StackBackupDir, "STACK_BACKUP_DIR")
+	envString(&c.StackBackupRemote, "STACK_BACKUP_REMOTE")
+	envString(&c.YandexDiskToken, "YANDEX_DISK_TOKEN")
+	envString(&c.StackBackupS3Endpoint, "STACK_BACKUP_S3_ENDPOINT")
+	envString(&c.StackBackupS3Region, "STACK_BACKUP_S3_REGION")
+	envString(&c.StackBackupS3AccessKey, "STACK_BACKUP_S3_ACCESS_KEY")
+	envString(&c.StackBackupS3SecretKey, "STACK_BACKUP_S3_SECRET_KEY")
+	envString(&c.ProviderGuidesDir, "PROVIDER_GUIDES_DIR")
+	envString(&c.UploadsPublicPaths, "UPLOADS_PUBLIC_PATHS")
+	envString(&c.ArtifactURLSecret, "ARTIFACT_URL_SECRET")
+	envString(&c.RouteMaxConcurrent, "ROUTE_MAX_CONCURRENT")
+	envString(&c.ProviderMaxConcurrent, "PROVIDER_MAX_CONCURRENT")
+	envString(&c.HTTPClientTimeouts, "HTTP_CLIENT_TIMEOUTS")
+	envString(&c.ChromaURL, "CHROMA_URL")
+	envString(&c.EmbeddingModel, "EMBEDDING_MODEL")
+	envString(&c.VisionFallbackProvider, "VISION_FALLBACK_PROVIDER")
+	envString(&c.VisionFallbackModel, "VISION_FALLBACK_MODEL")
+	envString(&c.STTBackend, "STT_BACKEND")
+	envString(&c.STTURL, "STT_URL")
+	envString(&c.STTModel, "STT_MODEL")
+	envString(&c.STTLanguage, "STT_LANGUAGE")
+	envString(&c.TTSBackend, "TTS_BACKEND")
+	envString(&c.TTSURL, "TTS_URL")
+	envString(&c.TTSModel, "TTS_MODEL")
+	envString(&c.TTSVoice, "TTS_VOICE")
+	envString(&c.SpeechAPIKey, "SPEECH_API_KEY", "OPENAI_API_KEY")
+	envString(&c.WeatherBackend, "WEATHER_BACKEND")
+	envString(&c.WeatherURL, "WEATHER_URL")
+	envString(&c.WeatherGeocodingURL, "WEATHER_GEOCODING_URL")
+	envString(&c.WeatherPlace, "WEATHER_PLACE")
+	envString(&c.NewsBackend, "NEWS_BACKEND")
+	envString(&c.NewsFeeds, "NEWS_FEEDS")
+	envString(&c.RouterFastProvider, "ROUTER_FAST_PROVIDER")
+	envString(&c.RouterFastModel, "ROUTER_FAST_MODEL")
+	envString(&c.RouterClassifierModel, "ROUTER_CLASSIFIER_MODEL")
+	envString(&c.SpeculativeDraftProvider, "SPECULATIVE_DRAFT_PROVIDER")
+	envString(&c.SpeculativeDraftModel, "SPECULATIVE_DRAFT_MODEL")
+	envString(&c.GuardInputPolicy, "GUARD_INPUT_POLICY")
+	envString(&c.GuardOutputPolicy, "GUARD_OUTPUT_POLICY")
+	envString(&c.BudgetPrices, "BUDGET_PRICES")
+	envString(&c.BudgetFallbackProvider, "BUDGET_FALLBACK_PROVIDER")
+	envString(&c.BudgetFallbackModel, "BUDGET_FALLBACK_MODEL")
+	envString(&c.ProviderFallbackProvider, "PROVIDER_FALLBACK_PROVIDER")
+	envString(&c.ProviderFallbackModel, "PROVIDER_FALLBACK_MODEL")
+	envString(&c.DefaultLanguage, "DEFAULT_LANGUAGE")
+	envString(&c.ResponseCacheMode, "RESPONSE_CACHE_MODE")
+	envString(&c.TitleProvider, "TITLE_PROVIDER")
+	envString(&c.TitleModel, "TITLE_MODEL")
+	// OLLAMA_HOST в формате самой Ollama задаётся без схемы (127.0.0.1:11434)
+	if c.OllamaURL != "" && !strings.Contains(c.OllamaURL, "://") {
+		c.OllamaURL = "http://" + c.OllamaURL
+	}
+	errs = append(errs,
+		envInt(&c.DBMaxOpenConns, "DB_MAX_OPEN_CONNS"),
+		envInt(&c.DBMaxIdleConns, "DB_MAX_IDLE_CONNS"),
+		envDuration(&c.DBConnMaxLifetime, "DB_CONN_MAX_LIFETIME"),
+		envDuration(&c.DBConnMaxIdleTime, "DB_CONN_MAX_IDLE_TIME"),
+		envInt64(&c.MaxBodyBytes, "AGENT_MAX_BODY_BYTES"),
+		envInt64(&c.MaxUploadBytes, "AGENT_MAX_UPLOAD_BYTES"),
+		envBool(&c.GzipEnabled, "AGENT_GZIP_ENABLED"),
+		envDuration(&c.DrainTimeout, "AGENT_DRAIN_TIMEOUT"),
+		envInt(&c.BatchConcurrency, "BATCH_CONCURRENCY"),
+		envInt(&c.BatchMaxItems, "BATCH_MAX_ITEMS"),
+		envInt(&c.BatchMaxJobs, "BATCH_MAX_JOBS"),
+		envBool(&c.JobsQueue, "JOBS_QUEUE"),
+		envInt(&c.JobsWorkers, "JOBS_WORKERS"),
+		envBool(&c.JobsWorkerOnly, "JOBS_WORKER_ONLY"),
+		envInt(&c.JobsMaxAttempts, "JOBS_MAX_ATTEMPTS"),
+		envDuration(&c.JobsLease, "JOBS_LEASE"),
+		envDuration(&c.JobsPoll, "JOBS_POLL"),
+		envDuration(&c.JobsRetention, "JOBS_RETENTION"),
+		envDuration(&c.ArtifactURLTTL, "ARTIFACT_URL_TTL"),
+		envBool(&c.ArtifactURLRequireSig, "ARTIFACT_URL_REQUIRE_SIGNATURE"),
+		envInt(&c.ChatMaxConcurrent, "CHAT_MAX_CONCURRENT"),
+		envInt(&c.ChatMaxQueue, "CHAT_MAX_QUEUE"),
+		envDuration(&c.ChatQueueTimeout, "CHAT_QUEUE_TIMEOUT"),
+		envInt(&c.HTTPMaxIdleConnsPerHost, "HTTP_MAX_IDLE_CONNS_PER_HOST"),
+		envDuration(&c.HTTPIdleConnTimeout, "HTTP_IDLE_CONN_TIMEOUT"),
+		envInt(&c.RAGTopK, "RAG_TOP_K"),
+		envInt(&c.RAGMaxChunkLen, "RAG_MAX_CHUNK_LEN"),
+		envInt(&c.RAGMaxContextLen, "RAG_MAX_CONTEXT_LEN"),
+		envBool(&c.LearningsEnabled, "LEARNINGS_ENABLED"),
+		envInt(&c.LearningsTopK, "LEARNINGS_TOP_K"),
+		envFloat(&c.LearningsMinScore, "LEARNINGS_MIN_SCORE"),
+		envInt(&c.LearningsMaxPerCategory, "LEARNINGS_MAX_PER_CATEGORY"),
+		envInt(&c.LearningsTokenBudget, "LEARNINGS_TOKEN_BUDGET"),
+		envBool(&c.EpisodicEnabled, "EPISODIC_MEMORY_ENABLED"),
+		envInt(&c.EpisodicTopK, "EPISODIC_TOP_K"),
+		envFloat(&c.EpisodicMinScore, "EPISODIC_MIN_SCORE"),
+		envInt(&c.RepoMapBudget, "REPO_MAP_BUDGET"),
+		envBool(&c.LintAfterEdit, "LINT_AFTER_EDIT"),
+		envBool(&c.RouterEnabled, "ROUTER_ENABLED"),
+		envFloat(&c.RouterThreshold, "ROUTER_THRESHOLD"),
+		envBool(&c.SpeculativeEnabled, "SPECULATIVE_ENABLED"),
+		envBool(&c.RiskAutoApprove, "RISK_AUTO_APPROVE"),
+		envDuration(&c.ResponseCacheTTL, "RESPONSE_CACHE_TTL"),
+		envInt(&c.ResponseCacheMaxEntries, "RESPONSE_CACHE_MAX_ENTRIES"),
+		envFloat(&c.ResponseCacheSimilarity, "RESPONSE_CACHE_SIMILARITY"),
+		envBool(&c.TitleEnabled, "TITLE_ENABLED"),
+		envInt(&c.ToolResultMaxChars, "TOOL_RESULT_MAX_CHARS"),
+		envBool(&c.BackupEnabled, "BACKUP_ENABLED"),
+		envDuration(&c.BackupRetention, "BACKUP_RETENTION"),
+		envBool(&c.ReplayRecord, "REPLAY_RECORD"),
+		envInt(&c.ToolRetryMax, "TOOL_RETRY_MAX"),
+		envBool(&c.AskUserEnabled, "ASK_USER_ENABLED"),
+		envDuration(&c.AskUserTTL, "ASK_USER_TTL"),
+		envInt(&c.LLMRetryMaxAttempts, "LLM_RETRY_MAX_ATTEMPTS"),
+		envDuration(&c.LLMRetryBaseDelay, "LLM_RETRY_BASE_DELAY"),
+		envDuration(&c.LLMRetryMaxDelay, "LLM_RETRY_MAX_DELAY"),
+		envInt(&c.LLMRetryBudget, "LLM_RETRY_BUDGET"),
+		envInt(&c.ProviderBreakerFailures, "PROVIDER_BREAKER_FAILURES"),
+		envDuration(&c.ProviderBreakerReset, "PROVIDER_BREAKER_RESET"),
+		envDuration(&c.ProviderQuotaTTL, "PROVIDER_QUOTA_TTL"),
+		envFloat(&c.ProviderQuotaWarn, "PROVIDER_QUOTA_WARN"),
+		envDuration(&c.ModelRefreshInterval, "MODEL_REFRESH_INTERVAL"),
+		envDuration(&c.WatchdogInterval, "WATCHDOG_INTERVAL"),
+		envInt(&c.WatchdogFailures, "WATCHDOG_FAILURES"),
+		envDuration(&c.WatchdogCooldown, "WATCHDOG_COOLDOWN"),
+		envFloat(&c.MaintenanceDiskWarn, "MAINTENANCE_DISK_WARN"),
+		envInt(&c.StackBackupKeep, "STACK_BACKUP_KEEP"),
+		envBool(&c.OllamaConstrainedTools, "OLLAMA_CONSTRAINED_TOOLS"),
+	)
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Validate — проверяет конфигурацию: порт, URL внешних сервисов,
+// положительные лимиты и параметры RAG. Возвращает все найденные ошибки сразу.
+func (c *Config) Validate() error {
+	var errs []error
+	if p, err := strconv.Atoi(c.Port); err != nil || p < 1 || p > 65535 {
+		errs = append(errs, fmt.Errorf("port: некорректный порт %q", c.Port))
+	}
+	switch c.DBDriver {
+	case DriverPostgres:
+		if c.DatabaseURL == "" && (c.DBHost == "" || c.DBName == "") {
+			errs = append(errs, errors.New("db: нужен DATABASE_URL или DB_HOST и DB_NAME"))
+		}
+	case DriverSQLite:
+		if c.SQLitePath == "" {
+			errs = append(errs, errors.New("db_sqlite_path: не задан файл базы SQLite"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("db_driver: %q, ожидается postgres или sqlite", c.DBDriver))
+	}
+	urls := []struct{ name, value string }{
+		{"memory_service_url", c.MemoryServiceURL},
+		{"tools_service_url", c.ToolsServiceURL},
+		{"browser_service_url", c.BrowserServiceURL},
+		{"ollama_url", c.OllamaURL},
+	}
+	if c.ChromaURL != "" {
+		urls = append(urls, struct{ name, value string }{"chroma_url", c.ChromaURL})
+	}
+	if c.STTURL != "" {
+		urls = append(urls, struct{ name, value string }{"stt_url", c.STTURL})
+	}
+	if c.TTSURL != "" {
+		urls = append(urls, struct{ name, value string }{"tts_url", c.TTSURL})
+	}
+	if c.MaintenanceWebhookURL != "" {
+		urls = append(urls, struct{ name, value string }{"maintenance_webhook_url", c.MaintenanceWebhookURL})
+	}
+	if c.WeatherURL != "" {
+		urls = append(urls, struct{ name, value string }{"weather_url", c.WeatherURL})
+	}
+	if c.WeatherGeocodingURL != "" {
+		urls = append(urls, struct{ name, value string }{"weather_geocoding_url", c.WeatherGeocodingURL})
+	}
+	for _, feed := range c.NewsFeedList() {
+		urls = append(urls, struct{ name, value string }{"news_feeds", feed})
+	}
+	for _, u := range urls {
+		if err := validateURL(u.value); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", u.name, err))
+		}
+	}
+	switch c.STTBackend {
+	case "", "whisper_cpp", "openai":
+	default:
+		errs = append(errs, fmt.Errorf("stt_backend: %q, ожидается whisper_cpp или openai", c.STTBackend))
+	}
+	switch c.WeatherBackend {
+	case "", "open_meteo":
+	default:
+		errs = append(errs, fmt.Errorf("weather_backend: %q, ожидается open_meteo", c.WeatherBackend))
+	}
+	switch c.NewsBackend {
+	case "":
+	case "rss":
+		if len(c.NewsFeedList()) == 0 {
+			errs = append(errs, errors.New("news_backend=rss: нужен хотя бы один URL в news_feeds"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("news_backend: %q, ожидается rss", c.NewsBackend))
+	}
+	if !i18n.Supported(c.DefaultLanguage) {
+		errs = append(errs, fmt.Errorf("default_language: %q, ожидается ru или en", c.DefaultLanguage))
+	}
+	switch c.TTSBackend {
+	case "", "openai", "http":
+	default:
+		errs = append(errs, fmt.Errorf("tts_backend: %q, ожидается openai или http", c.TTSBackend))
+	}
+	if c.DBMaxOpenConns < 1 || c.DBMaxIdleConns < 0 || c.DBMaxIdleConns > c.DBMaxOpenConns {
+		errs = append(errs, fmt.Errorf("db_max_open_conns (%d) должен быть не меньше 1 и не меньше db_max_idle_conns (%d)", c.DBMaxOpenConns, c.DBMaxIdleConns))
+	}
+	if c.MaxBodyBytes <= 0 || c.MaxUploadBytes <= 0 {
+		errs = append(errs, errors.New("max_body_bytes и max_upload_bytes должны быть больше нуля"))
+	}
+	if c.DrainTimeout < 0 {
+		errs = append(errs, errors.New("drain_timeout не может быть отрицательным"))
+	}
+	if c.BatchConcurrency < 1 || c.BatchMaxItems < 1 || c.BatchMaxJobs < 1 {
+		errs = append(errs, errors.New("batch_concurrency, batch_max_items и batch_max_jobs должны быть больше нуля"))
+	}
+	if c.JobsWorkers < 0 || c.JobsMaxAttempts < 1 {
+		errs = append(errs, errors.New("jobs_workers не может быть отрицательным, jobs_max_attempts — не меньше 1"))
+	}
+	if c.JobsLease < 3*time.Second || c.JobsPoll <= 0 || c.JobsRetention <= 0 {
+		errs = append(errs, errors.New("jobs_lease — не меньше 3s, jobs_poll и jobs_retention — положительные длительности"))
+	}
+	if c.JobsWorkerOnly && (!c.JobsQueue || c.JobsWorkers == 0) {
+		errs = append(errs, errors.New("jobs_worker_only: нужны jobs_queue=true и jobs_workers больше нуля"))
+	}
+	switch c.JobsBroker {
+	case "":
+	case "nats":
+		if u, err := url.Parse(c.JobsBrokerURL); err != nil || u.Host == "" {
+			errs = append(errs, fmt.Errorf("jobs_broker_url: ожидается nats://хост:порт, получено %q", c.JobsBrokerURL))
+		}
+		if c.JobsBrokerSubject == "" || strings.ContainsAny(c.JobsBrokerSubject, " *>") {
+			errs = append(errs, fmt.Errorf("jobs_broker_subject: некорректный префикс темы %q", c.JobsBrokerSubject))
+		}
+		if !c.JobsQueue {
+			errs = append(errs, errors.New("jobs_broker: нужна jobs_queue=true"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("jobs_broker: %q, ожидается nats или пусто", c.JobsBroker))
+	}
+	if _, err := uploads.ParsePaths(c.UploadsPublicPaths); err != nil {
+		errs = append(errs, fmt.Errorf("uploads_public_paths: %w", err))
+	}
+	if c.ArtifactURLTTL <= 0 {
+		errs = append(errs, errors.New("artifact_url_ttl должен быть больше нуля"))
+	}
+	if c.ArtifactURLRequireSig && c.ArtifactURLSecret == "" {
+		errs = append(errs, errors.New("artifact_url_require_sig: нужен artifact_url_secret"))
+	}
+	if c.ChatMaxConcurrent < 0 || c.ChatMaxQueue < 0 || c.ChatQueueTimeout < 0 {
+		errs = append(errs, errors.New("chat_max_concurrent, chat_max_queue и chat_queue_timeout не могут быть отрицательными"))
+	}
+	if _, err := limiter.ParseLimits(c.RouteMaxConcurrent); err != nil {
+		errs = append(errs, fmt.Errorf("route_max_concurrent: %w", err))
+	}
+	if _, err := limiter.ParseLimits(c.ProviderMaxConcurrent); err != nil {
+		errs = append(errs, fmt.Errorf("provider_max_concurrent: %w", err))
+	}
+	if c.HTTPMaxIdleConnsPerHost < 1 || c.HTTPIdleConnTimeout <= 0 {
+		errs = append(errs, errors.New("http_max_idle_conns_per_host и http_idle_conn_timeout должны быть больше нуля"))
+	}
+	if _, err := httpclient.ParseTimeouts(c.HTTPClientTimeouts); err != nil {
+		errs = append(errs, fmt.Errorf("http_client_timeouts: %w", err))
+	}
+	if c.RAGTopK < 1 || c.RAGTopK > 100 {
+		errs = append(errs, fmt.Errorf("rag_top_k: %d вне диапазона 1..100", c.RAGTopK))
+	}
+	if c.RAGMaxChunkLen <= 0 || c.RAGMaxContextLen <= 0 {
+		errs = append(errs, errors.New("rag_max_chunk_len и rag_max_context_len должны быть больше нуля"))
+	}
+	if c.LearningsTopK < 1 || c.LearningsTopK > 20 {
+		errs = append(errs, fmt.Errorf("learnings_top_k: %d вне диапазона 1..20", c.LearningsTopK))
+	}
+	if c.LearningsMinScore < 0 || c.LearningsMinScore > 1 {
+		errs = append(errs, fmt.Errorf("learnings_min_score: %v вне диапазона 0..1", c.LearningsMinScore))
+	}
+	if c.LearningsMaxPerCategory < 0 || c.LearningsTokenBudget < 0 {
+		errs = append(errs, errors.New("learnings_max_per_category и learnings_token_budget не могут быть отрицательными"))
+	}
+	if c.EpisodicTopK < 1 || c.EpisodicTopK > 10 {
+		errs = append(errs, fmt.Errorf("episodic_top_k: %d вне диапазона 1..10", c.EpisodicTopK))
+	}
+	if c.EpisodicMinScore < 0 || c.EpisodicMinScore > 1 {
+		errs = append(errs, fmt.Errorf("episodic_min_score: %v вне диапазона 0..1", c.EpisodicMinScore))
+	}
+	if c.RepoMapBudget < 0 || c.RepoMapBudget > 50000 {
+		errs = append(errs, fmt.Errorf("repo_map_budget: %d вне диапазона 0..50000", c.RepoMapBudget))
+	}
+	if c.RouterThreshold < 0 || c.RouterThreshold > 1 {
+		errs = append(errs, fmt.Errorf("router_threshold: %v вне диапазона 0..1", c.RouterThreshold))
+	}
+	if c.RouterEnabled && c.RouterFastModel == "" {
+		errs = append(errs, errors.New("router_enabled: не задана быстрая модель (ROUTER_FAST_MODEL)"))
+	}
+	if c.SpeculativeEnabled && c.SpeculativeDraftModel == "" {
+		errs = append(errs, errors.New("speculative_enabled: не задана черновая модель (SPECULATIVE_DRAFT_MODEL)"))
+	}
+	for _, p := range []struct{ name, value string }{
+		{"guard_input_policy", c.GuardInputPolicy},
+		{"guard_output_policy", c.GuardOutputPolicy},
+	} {
+		switch p.value {
+		case "off", "flag", "block":
+		default:
+			errs = append(errs, fmt.Errorf("%s: %q, ожидается off, flag или block", p.name, p.value))
+		}
+	}
+	switch c.ResponseCacheMode {
+	case "off", "exact", "semantic":
+	default:
+		errs = append(errs, fmt.Errorf("response_cache_mode: %q, ожидается off, exact или semantic", c.ResponseCacheMode))
+	}
+	if c.ResponseCacheMode != "off" && (c.ResponseCacheTTL <= 0 || c.ResponseCacheMaxEntries <= 0) {
+		errs = append(errs, errors.New("response_cache_ttl: нужны положительные RESPONSE_CACHE_TTL и RESPONSE_CACHE_MAX_ENTRIES"))
+	}
+	if c.ResponseCacheSimilarity <= 0 || c.ResponseCacheSimilarity > 1 {
+		errs = append(errs, fmt.Errorf("response_cache_similarity: %v вне диапазона (0..1]", c.ResponseCacheSimilarity))
+	}
+	if c.ToolResultMaxChars != 0 && c.ToolResultMaxChars < 1000 {
+		errs = append(errs, fmt.Errorf("tool_result_max_chars: %d, нужно 0 (без усечения) или не меньше 1000", c.ToolResultMaxChars))
+	}
+	if c.BackupRetention <= 0 {
+		errs = append(errs, fmt.Errorf("backup_retention: %v, нужна положительная длительность", c.BackupRetention))
+	}
+	if c.ToolRetryMax < 0 {
+		errs = append(errs, fmt.Errorf("tool_retry_max: %d, нужно 0 (без подсказок) или больше", c.ToolRetryMax))
+	}
+	if c.AskUserTTL <= 0 {
+		errs = append(errs, fmt.Errorf("ask_user_ttl: %v, нужна положительная длительность", c.AskUserTTL))
+	}
+	if c.LLMRetryMaxAttempts < 1 {
+		errs = append(errs, fmt.Errorf("llm_retry_max_attempts: %d, нужна хотя бы 1 попытка", c.LLMRetryMaxAttempts))
+	}
+	if c.LLMRetryBaseDelay <= 0 || c.LLMRetryMaxDelay < c.LLMRetryBaseDelay {
+		errs = append(errs, fmt.Errorf("llm_retry_base_delay %v, llm_retry_max_delay %v: нужна положительная пауза не больше предела", c.LLMRetryBaseDelay, c.LLMRetryMaxDelay))
+	}
+	if c.ProviderQuotaTTL < 0 || c.ProviderQuotaWarn < 0 || c.ProviderQuotaWarn > 1 {
+		errs = append(errs, fmt.Errorf("provider_quota_ttl %v, provider_quota_warn %v: нужна неотрицательная пауза и доля 0..1", c.ProviderQuotaTTL, c.ProviderQuotaWarn))
+	}
+	if c.ModelRefreshInterval < 0 {
+		errs = append(errs, fmt.Errorf("model_refresh_interval %v: нужна неотрицательная пауза", c.ModelRefreshInterval))
+	}
+	if c.WatchdogInterval < 0 || c.WatchdogFailures < 1 || c.WatchdogCooldown < 0 {
+		errs = append(errs, fmt.Errorf("watchdog_interval %v, watchdog_failures %d, watchdog_cooldown %v: нужны неотрицательные паузы и хотя бы 1 проверка", c.WatchdogInterval, c.WatchdogFailures, c.WatchdogCooldown))
+	}
+	if c.MaintenanceTime != "" {
+		if _, _, err := maintenance.ParseTime(c.MaintenanceTime); err != nil {
+			errs = append(errs, fmt.Errorf("maintenance_time: %w", err))
+		}
+	}
+	if c.MaintenanceDiskWarn <= 0 || c.MaintenanceDiskWarn > 1 {
+		errs = append(errs, fmt.Errorf("maintenance_disk_warn: %v вне диапазона (0..1]", c.MaintenanceDiskWarn))
+	}
+	if c.StackBackupDir == "" || c.StackBackupKeep < 0 {
+		errs = append(errs, fmt.Errorf("stack_backup_dir %q, stack_backup_keep %d: нужен каталог и неотрицательное число копий", c.StackBackupDir, c.StackBackupKeep))
+	}
+	if c.StackBackupRemote != "" && !strings.HasPrefix(c.StackBackupRemote, "ydisk:") && !strings.HasPrefix(c.StackBackupRemote, "s3://") {
+		errs = append(errs, fmt.Errorf("stack_backup_remote %q: ожидается ydisk:/папка или s3://бакет/префикс", c.StackBackupRemote))
+	}
+	if c.ProviderBreakerFailures < 0 || c.ProviderBreakerReset <= 0 {
+		errs = append(errs, fmt.Errorf("provider_breaker_failures %d, provider_breaker_reset %v: нужен порог 0 (без отключения) или больше и положительная пауза", c.ProviderBreakerFailures, c.ProviderBreakerReset))
+	}
+	if c.LLMRetryBudget < 0 {
+		errs = append(errs, fmt.Errorf("llm_retry_budget: %d, нужно 0 (без ограничения) или больше", c.LLMRetryBudget))
+	}
+	return errors.Join(errs...)
+}
+
+// NewsFeedList — URL лент новостей из NewsFeeds без пустых элементов.
+func (c *Config) NewsFeedList() []string {
+	var feeds []string
+	for _, f := range strings.Split(c.NewsFeeds, ",") {
+		if f = strings.TrimSpace(f); f != "" {
+			feeds = append(feeds, f)
+		}
+	}
+	return feeds
+}
+
+// validateURL — URL должен быть абсолютным http(s)-адресом.
+func validateURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return err
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("ожидается http(s)://хост, получено %q", raw)
+	}
+	return nil
+}
+
+// secretMask — замена секретов в Sanitized.
+const secretMask = "***"
+
+// Sanitized — копия конфигурации без секретов, пригодная для GET /config.
+func (c *Config) Sanitized() Config {
+	s := *c
+	if s.DBPassword != "" {
+		s.DBPassword = secretMask
+	}
+	if s.ToolsServiceToken != "" {
+		s.ToolsServiceToken = secretMask
+	}
+	if s.SpeechAPIKey != "" {
+		s.SpeechAPIKey = secretMask
+	}
+	if s.ArtifactURLSecret != "" {
+		s.ArtifactURLSecret = secretMask
+	}
+	if s.YandexDiskToken != "" {
+		s.YandexDiskToken = secretMask
+	}
+	if s.StackBackupS3SecretKey != "" {
+		s.StackBackupS3SecretKey = secretMask
+	}
+	if u, err := url.Parse(s.JobsBrokerURL); err == nil && u.User != nil {
+		s.JobsBrokerURL = u.Redacted()
+	}
+	if s.DatabaseURL != "" {
+		if u, err := url.Parse(s.DatabaseURL); err == nil && u.User != nil {
+			s.DatabaseURL = u.Redacted()
+		} else {
+			s.DatabaseURL = secretMask
+		}
+	}
+	return s
+}
+
+var (
+	current  atomic.Pointer[Config]
+	reloadMu sync.Mutex
+	hooksMu  sync.Mutex
+	hooks    []func(*Config)
+)
+
+// Current — действующая конфигурация. До Set возвращает значения по умолчанию.
+func Current() *Config {
+	if c := current.Load(); c != nil {
+		return c
+	}
+	return Defaults()
+}
+
+// Set — делает c действующей конфигурацией (вызывается при старте).
+func Set(c *Config) {
+	current.Store(c)
+}
+
+// OnReload — регистрирует обработчик, вызываемый после применения Reload
+// (например, для передачи новых параметров RAG в ретривер).
+func OnReload(fn func(*Config)) {
+	hooksMu.Lock()
+	hooks = append(hooks, fn)
+	hooksMu.Unlock()
+}
+
+// ReloadResult — итог перезагрузки конфигурации.
+type ReloadResult struct {
+	Applied         []string `json:"applied"`          // Изменённые параметры, применённые на лету
+	RestartRequired []string `json:"restart_required"` // Изменённые параметры, требующие перезапуска
+}
+
+// Reload — перечитывает файл и окружение и применяет изменения Tunable.
+// Остальные изменения не применяются и перечисляются в RestartRequired.
+// При ошибке загрузки или проверки действующая конфигурация не меняется.
+func Reload() (ReloadResult, error) {
+	reloadMu.Lock()
+	defer reloadMu.Unlock()
+	res := ReloadResult{Applied: []string{}, RestartRequired: []string{}}
+	fresh, err := Load()
+	if err != nil {
+		return res, err
+	}
+	if err := fresh.Validate(); err != nil {
+		return res, err
+	}
+	old := Current()
+	next := *old
+	next.Tunable = fresh.Tunable
+	res.Applied = diff(old.Tunable, fresh.Tunable)
+
+	oldRest, freshRest := *old, *fresh
+	oldRest.Tunable, freshRest.Tunable = Tunable{}, Tunable{}
+	res.RestartRequired = diff(oldRest, freshRest)
+
+	current.Store(&next)
+	if len(res.Applied) > 0 {
+		hooksMu.Lock()
+		fns := append([]func(*Config){}, hooks...)
+		hooksMu.Unlock()
+		for _, fn := range fns {
+			fn(&next)
+		}
+	}
+	return res, nil
+}
+
+// diff — имена (yaml-теги) полей, значения которых различаются в a и b.
+func diff(a, b interface{}) []string {
+	va, vb := reflect.ValueOf(a), reflect.ValueOf(b)
+	t := va.Type()
+	changed := []string{}
+	for i := 0; i < t.NumField(); i++ {
+		if t.Field(i).Anonymous {
+			continue
+		}
+		if !reflect.DeepEqual(va.Field(i).Interface(), vb.Field(i).Interface()) {
+			name, _, _ := strings.Cut(t.Field(i).Tag.Get("yaml"), ",")
+			changed = append(changed, name)
+		}
+	}
+	return changed
+}
+
+// envString — записывает в dst первое непустое значение из переменных keys.
+func envString(dst *string, keys ...string) {
+	for _, k := range keys {
+		if v := os.Getenv(k); v != "" {
+			*dst = v
+			return
+		}
+	}
+}
+
+func envInt(dst *int, key string) error {
+	v := os.Getenv(key)
+	if v == "" {
+		return nil
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(v))
+	if err != nil {
+		return fmt.Errorf("%s: ожидается целое число, получено %q", key, v)
+	}
+	*dst = n
+	return nil
+}
+
+func envInt64(dst *int64, key string) error {
+	v := os.Getenv(key)
+	if v == "" {
+		return nil
+	}
+	n, err := strconv.ParseInt(strings.TrimSpace(v), 10, 64)
+	if err != nil {
+		return fmt.Errorf("%s: ожидается целое число, получено %q", key, v)
+	}
+	*dst = n
+	return nil
+}
+
+func envBool(dst *bool, key string) error {
+	v := os.Getenv(key)
+	if v == "" {
+		return nil
+	}
+	b, err := strconv.ParseBool(strings.TrimSpace(v))
+	if err != nil {
+		return fmt.Errorf("%s: ожидается true или false, получено %q", key, v)
+	}
+	*dst = b
+	return nil
+}
+
+func envFloat(dst *float64, key string) error {
+	v := os.Getenv(key)
+	if v == "" {
+		return nil
+	}
+	f, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+	if err != nil {
+		return fmt.Errorf("%s: ожидается число, получено %q", key, v)
+	}
+	*dst = f
+	return nil
+}
+
+func envDuration(dst *time.Duration, key string) error {
+	v := os.Getenv(key)
+	if v == "" {
+		return nil
+	}
+	d, err := time.ParseDuration(strings.TrimSpace(v))
+	if err != nil {
+		return fmt.Errorf("%s: ожидается длительность (30s, 5m), получено %q", key, v)
+	}
+	*dst = d
+	return nil
 }
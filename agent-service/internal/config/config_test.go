@@ -0,0 +1,140 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestLoadLayers — переменные окружения перекрывают YAML-файл, файл — значения по умолчанию.
+func TestLoadLayers(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "agent.yaml")
+	yml := "port: \"9000\"\nrag_top_k: 7\ndrain_timeout: 2m\nmemory_service_url: http://memory:8001\n"
+	if err := os.WriteFile(path, []byte(yml), 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("AGENT_CONFIG_FILE", path)
+	t.Setenv("RAG_TOP_K", "9")
+	t.Setenv("OLLAMA_HOST", "127.0.0.1:11434")
+
+	c, err := Load()
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if c.Port != "9000" || c.MemoryServiceURL != "http://memory:8001" || c.DrainTimeout != 2*time.Minute {
+		t.Errorf("значения из файла не применены: %+v", c)
+	}
+	if c.RAGTopK != 9 {
+		t.Errorf("RAG_TOP_K из окружения должен перекрыть файл, получено %d", c.RAGTopK)
+	}
+	if c.OllamaURL != "http://127.0.0.1:11434" {
+		t.Errorf("OLLAMA_HOST без схемы должен дополняться http://, получено %q", c.OllamaURL)
+	}
+	if c.ToolsServiceURL != Defaults().ToolsServiceURL {
+		t.Errorf("незаданный параметр должен остаться по умолчанию, получено %q", c.ToolsServiceURL)
+	}
+}
+
+// TestLoadInvalidNumber — нечисловое значение числовой переменной — ошибка загрузки.
+func TestLoadInvalidNumber(t *testing.T) {
+	t.Setenv("RAG_TOP_K", "много")
+	if _, err := Load(); err == nil || !strings.Contains(err.Error(), "RAG_TOP_K") {
+		t.Fatalf("ожидалась ошибка с именем переменной, получено %v", err)
+	}
+}
+
+// TestValidate — проверка порта, URL и параметров RAG; все ошибки возвращаются сразу.
+func TestValidate(t *testing.T) {
+	if err := Defaults().Validate(); err != nil {
+		t.Fatalf("конфигурация по умолчанию должна быть корректной: %v", err)
+	}
+	c := Defaults()
+	c.Port = "70000"
+	c.ToolsServiceURL = "localhost:8082"
+	c.RAGTopK = 0
+	c.DBDriver = "mysql"
+	c.LearningsMinScore = 1.5
+	c.STTBackend = "vosk"
+	c.RouterEnabled = true
+	c.GuardOutputPolicy = "deny"
+	c.ResponseCacheMode = "lru"
+	c.ToolResultMaxChars = 50
+	c.NewsBackend = "rss"
+	c.JobsBroker = "rabbitmq"
+	err := c.Validate()
+	if err == nil {
+		t.Fatal("ожидалась ошибка проверки")
+	}
+	for _, want := range []string{"port", "tools_service_url", "rag_top_k", "db_driver", "learnings_min_score", "stt_backend", "router_enabled", "guard_output_policy", "response_cache_mode", "tool_result_max_chars", "news_feeds", "jobs_broker"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("в ошибке нет %q: %v", want, err)
+		}
+	}
+}
+
+// TestSanitized — секреты скрываются, исходная конфигурация не меняется.
+func TestSanitized(t *testing.T) {
+	c := Defaults()
+	c.ToolsServiceToken = "secret-token"
+	c.SpeechAPIKey = "sk-speech"
+	c.StackBackupS3SecretKey = "s3-secret"
+	c.DatabaseURL = "postgres://user:pass@db:5432/agent"
+	s := c.Sanitized()
+	if s.DBPassword != secretMask || s.ToolsServiceToken != secretMask || s.SpeechAPIKey != secretMask || s.StackBackupS3SecretKey != secretMask {
+		t.Errorf("пароль и токены должны быть скрыты: %+v", s)
+	}
+	if strings.Contains(s.DatabaseURL, "pass") {
+		t.Errorf("пароль в DATABASE_URL не скрыт: %s", s.DatabaseURL)
+	}
+	if c.ToolsServiceToken != "secret-token" {
+		t.Error("Sanitized не должен менять исходную конфигурацию")
+	}
+}
+
+// TestReload — на лету применяются только Tunable, остальное требует перезапуска.
+func TestReload(t *testing.T) {
+	Set(Defaults())
+	defer current.Store(nil)
+	var hookTopK int
+	OnReload(func(c *Config) { hookTopK = c.RAGTopK })
+	defer func() { hooks = nil }()
+
+	t.Setenv("RAG_TOP_K", "12")
+	t.Setenv("AGENT_SERVICE_PORT", "9999")
+	res, err := Reload()
+	if err != nil {
+		t.Fatalf("Reload: %v", err)
+	}
+	if Current().RAGTopK != 12 || hookTopK != 12 {
+		t.Errorf("rag_top_k не применён: текущий %d, обработчик %d", Current().RAGTopK, hookTopK)
+	}
+	if Current().Port != "8083" {
+		t.Errorf("порт не должен меняться без перезапуска, получено %s", Current().Port)
+	}
+	if len(res.Applied) != 1 || res.Applied[0] != "rag_top_k" {
+		t.Errorf("applied: %v", res.Applied)
+	}
+	if len(res.RestartRequired) != 1 || res.RestartRequired[0] != "port" {
+		t.Errorf("restart_required: %v", res.RestartRequired)
+	}
+
+	t.Setenv("LEARNINGS_ENABLED", "false")
+	t.Setenv("LEARNINGS_MIN_SCORE", "0.5")
+	res, err = Reload()
+	if err != nil {
+		t.Fatalf("Reload: %v", err)
+	}
+	if Current().LearningsEnabled || Current().LearningsMinScore != 0.5 || len(res.RestartRequired) != 1 {
+		t.Errorf("параметры знаний должны применяться на лету: %+v, %+v", Current().Tunable, res)
+	}
+
+	t.Setenv("RAG_TOP_K", "0")
+	if _, err := Reload(); err == nil {
+		t.Fatal("некорректная конфигурация должна отклоняться")
+	}
+	if Current().RAGTopK != 12 {
+		t.Errorf("после отклонённой перезагрузки конфигурация изменилась: %d", Current().RAGTopK)
+	}
+}
@@ -0,0 +1,83 @@
+// Package convtitle — короткие названия диалогов для списка /conversations.
+//
+// После первого обмена репликами дешёвая модель получает вопрос и ответ и
+// предлагает название до пяти слов. Clean приводит ответ модели к виду
+// заголовка; если модель недоступна, название строится из вопроса (Fallback).
+package convtitle
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/neo-2022/openclaw-memory/agent-service/internal/llm"
+)
+
+// MaxWords — слов в названии.
+const MaxWords = 5
+
+// maxRunes — предел длины названия в символах.
+const maxRunes = 80
+
+// Prompt — системный промпт модели, придумывающей название.
+const Prompt = "Придумай короткое название для диалога по первому вопросу пользователя и ответу ассистента. " +
+	"Не больше пяти слов, на языке вопроса, без кавычек, точки в конце и пояснений. Ответь только названием."
+
+// Messages — запрос к модели: вопрос и начало ответа.
+func Messages(question, answer string) []llm.Message {
+	return []llm.Message{
+		{Role: "system", Content: Prompt},
+		{Role: "user", Content: "Вопрос: " + cut(question, 1000) + "\n\nОтвет: " + cut(answer, 1000)},
+	}
+}
+
+// Clean — название из ответа модели: первая непустая строка без префикса
+// «Название:», кавычек и завершающих знаков, не больше MaxWords слов.
+// Пустая строка — модель не дала пригодного названия.
+func Clean(raw string) string {
+	line := ""
+	for _, l := range strings.Split(raw, "\n") {
+		if l = strings.TrimSpace(l); l != "" {
+			line = l
+			break
+		}
+	}
+	const quotes = " \t\"'«»“”„`*#"
+	line = strings.Trim(line, quotes)
+	for _, prefix := range []string{"название:", "заголовок:", "title:"} {
+		if strings.HasPrefix(strings.ToLower(line), prefix) {
+			line = line[len(prefix):]
+		}
+	}
+	line = strings.Trim(line, quotes)
+	line = strings.TrimRightFunc(line, func(r rune) bool { return unicode.IsPunct(r) && r != ')' })
+	return title(strings.Fields(line))
+}
+
+// Fallback — название из первых слов вопроса, если модель не ответила.
+func Fallback(question string) string {
+	words := strings.FieldsFunc(question, func(r rune) bool {
+		return unicode.IsSpace(r) || (unicode.IsPunct(r) && r != '-' && r != '.' && r != '_')
+	})
+	return title(words)
+}
+
+// title — не больше MaxWords слов и maxRunes символов, первая буква заглавная.
+func title(words []string) string {
+	if len(words) > MaxWords {
+		words = words[:MaxWords]
+	}
+	t := cut(strings.Join(words, " "), maxRunes)
+	if t == "" {
+		return ""
+	}
+	r, size := utf8.DecodeRuneInString(t)
+	return string(unicode.ToUpper(r)) + t[size:]
+}
+
+func cut(s string, n int) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	return strings.TrimSpace(string([]rune(s)[:n]))
+}
@@ -0,0 +1,30 @@
+package convtitle
+
+import "testing"
+
+// TestClean — ответ модели приводится к названию до пяти слов.
+func TestClean(t *testing.T) {
+	cases := map[string]string{
+		"Настройка nginx на Ubuntu":                                 "Настройка nginx на Ubuntu",
+		"«Перезапуск службы Docker».":                               "Перезапуск службы Docker",
+		"Название: резервное копирование базы\nПояснение: …":        "Резервное копирование базы",
+		"\n\n  \"Очень длинное название диалога про всё на свете\"": "Очень длинное название диалога про",
+		"**Title: Go generics overview**":                           "Go generics overview",
+		"   ":                                                       "",
+	}
+	for raw, want := range cases {
+		if got := Clean(raw); got != want {
+			t.Errorf("%q: %q, ожидалось %q", raw, got, want)
+		}
+	}
+}
+
+// TestFallback — название из начала вопроса.
+func TestFallback(t *testing.T) {
+	if got := Fallback("как посмотреть логи nginx за вчера, если journald выключен?"); got != "Как посмотреть логи nginx за" {
+		t.Errorf("получено %q", got)
+	}
+	if got := Fallback("?!"); got != "" {
+		t.Errorf("получено %q", got)
+	}
+}
@@ -0,0 +1,92 @@
+// Package correction — повтор вызова инструмента с подсказкой после ошибки.
+//
+// Когда инструмент возвращает ошибку, модель получает системную заметку с
+// текстом ошибки и схемой параметров и просьбой повторить вызов с
+// исправленными аргументами. Число таких подсказок на инструмент в одном
+// запросе ограничено. Tracker считает, удалось ли модели исправить вызов:
+// следующий успешный вызов того же инструмента — исправление, ошибка,
+// оставшаяся к концу запроса, — неудача.
+package correction
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+)
+
+// Исход исправления (метка метрики).
+const (
+	OutcomeCorrected = "corrected"
+	OutcomeFailed    = "failed"
+)
+
+// Tracker — подсказки и их исходы в одном запросе. Не безопасен для
+// параллельного использования: инструменты одного запроса вызываются по очереди.
+type Tracker struct {
+	max      int
+	attempts map[string]int
+	pending  map[string]bool
+	used     int
+}
+
+// NewTracker — не больше max подсказок на инструмент; 0 — без подсказок.
+func NewTracker(max int) *Tracker {
+	return &Tracker{max: max, attempts: map[string]int{}, pending: map[string]bool{}}
+}
+
+// Failed — вызов tool завершился ошибкой. ok — нужна подсказка, attempt —
+// её номер; false — лимит исчерпан или подсказки отключены.
+func (t *Tracker) Failed(tool string) (attempt int, ok bool) {
+	if t.max <= 0 {
+		return 0, false
+	}
+	t.pending[tool] = true
+	if t.attempts[tool] >= t.max {
+		return t.attempts[tool], false
+	}
+	t.attempts[tool]++
+	t.used++
+	return t.attempts[tool], true
+}
+
+// Succeeded — вызов tool выполнен; true — он исправил предыдущую ошибку.
+func (t *Tracker) Succeeded(tool string) bool {
+	if !t.pending[tool] {
+		return false
+	}
+	delete(t.pending, tool)
+	return true
+}
+
+// Used — сколько подсказок выдано за запрос.
+func (t *Tracker) Used() int {
+	return t.used
+}
+
+// Max — лимит подсказок на инструмент.
+func (t *Tracker) Max() int {
+	return t.max
+}
+
+// Unresolved — инструменты, ошибку которых модель так и не исправила.
+func (t *Tracker) Unresolved() []string {
+	out := make([]string, 0, len(t.pending))
+	for tool := range t.pending {
+		out = append(out, tool)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// Note — системная заметка для модели: ошибка, схема параметров и просьба
+// повторить вызов.
+func Note(tool, errText string, params interface{}, attempt, max int) string {
+	note := fmt.Sprintf("Вызов инструмента %s завершился ошибкой: %s\n", tool, errText)
+	if params != nil {
+		if schema, err := json.Marshal(params); err == nil {
+			note += "Параметры инструмента (JSON Schema): " + string(schema) + "\n"
+		}
+	}
+	return note + fmt.Sprintf("Исправь аргументы и повтори вызов (попытка %d из %d). "+
+		"Если ошибка не связана с аргументами (нет файла, сервис недоступен), не повторяй вызов, а объясни пользователю, что не получилось.", attempt, max)
+}
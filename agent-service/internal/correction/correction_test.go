@@ -0,0 +1,41 @@
+package correction
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestTracker — лимит подсказок на инструмент и исходы исправлений.
+func TestTracker(t *testing.T) {
+	tr := NewTracker(2)
+	if n, ok := tr.Failed("read"); !ok || n != 1 {
+		t.Fatalf("первая ошибка: %d %v", n, ok)
+	}
+	if n, ok := tr.Failed("read"); !ok || n != 2 {
+		t.Fatalf("вторая ошибка: %d %v", n, ok)
+	}
+	if _, ok := tr.Failed("read"); ok {
+		t.Error("лимит подсказок превышен")
+	}
+	if !tr.Succeeded("read") || tr.Succeeded("read") {
+		t.Error("успех после ошибки — одно исправление")
+	}
+	tr.Failed("execute")
+	if tr.Used() != 3 || !reflect.DeepEqual(tr.Unresolved(), []string{"execute"}) {
+		t.Errorf("подсказок %d, неисправлено %v", tr.Used(), tr.Unresolved())
+	}
+	if _, ok := NewTracker(0).Failed("read"); ok {
+		t.Error("подсказки отключены")
+	}
+}
+
+// TestNote — заметка содержит ошибку, схему и номер попытки.
+func TestNote(t *testing.T) {
+	note := Note("read", "path: обязательное поле отсутствует", map[string]any{"required": []string{"path"}}, 1, 2)
+	for _, want := range []string{"read", "обязательное поле", `{"required":["path"]}`, "попытка 1 из 2"} {
+		if !strings.Contains(note, want) {
+			t.Errorf("нет %q в заметке:\n%s", want, note)
+		}
+	}
+}
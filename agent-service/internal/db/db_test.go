@@ -0,0 +1,33 @@
+package db
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/neo-2022/openclaw-memory/agent-service/internal/config"
+)
+
+// TestPostgresToolConn — пароль уходит в PGPASSWORD и не остаётся в строке
+// подключения, которая передаётся pg_dump и psql аргументом.
+func TestPostgresToolConn(t *testing.T) {
+	cases := []struct {
+		cfg      config.Config
+		dsn, env string
+	}{
+		{config.Config{DatabaseURL: "postgres://agent:s3cr%40t@db:5432/agents?sslmode=disable"}, "postgres://agent@db:5432/agents?sslmode=disable", "PGPASSWORD=s3cr@t"},
+		{config.Config{DatabaseURL: "postgresql://db/agents?user=agent&password=s3cret"}, "postgresql://db/agents?user=agent", "PGPASSWORD=s3cret"},
+		{config.Config{DatabaseURL: `host=db user=agent password='s3 cr\'et' dbname=agents`}, "host=db user=agent dbname=agents", "PGPASSWORD=s3 cr'et"},
+		{config.Config{DatabaseURL: "host=db user=agent password=s3cret dbname=agents"}, "host=db user=agent dbname=agents", "PGPASSWORD=s3cret"},
+		{config.Config{DatabaseURL: "postgres://agent@db/agents"}, "postgres://agent@db/agents", ""},
+		{config.Config{DBHost: "db", DBPort: "5432", DBUser: "agent", DBPassword: "s3cret", DBName: "agents"}, "host=db port=5432 user=agent dbname=agents sslmode=disable", "PGPASSWORD=s3cret"},
+	}
+	for _, c := range cases {
+		dsn, env := PostgresToolConn(&c.cfg)
+		if dsn != c.dsn || strings.Contains(dsn, "s3cr") || strings.Contains(dsn, "password") {
+			t.Errorf("%q: строка подключения %q, ожидалась %q", c.cfg.DatabaseURL, dsn, c.dsn)
+		}
+		if c.env != "" && (len(env) != 1 || env[0] != c.env) {
+			t.Errorf("%q: окружение %v, ожидалось %s", c.cfg.DatabaseURL, env, c.env)
+		}
+	}
+}
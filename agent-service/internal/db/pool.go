@@ -0,0 +1,105 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/neo-2022/openclaw-memory/agent-service/internal/config"
+	"github.com/neo-2022/openclaw-memory/agent-service/internal/metrics"
+)
+
+// configurePool — применяет размеры пула соединений из конфигурации
+// и подключает экспорт его состояния в /metrics.
+func configurePool(db *gorm.DB, cfg *config.Config) error {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	sqlDB.SetMaxOpenConns(cfg.DBMaxOpenConns)
+	sqlDB.SetMaxIdleConns(cfg.DBMaxIdleConns)
+	sqlDB.SetConnMaxLifetime(cfg.DBConnMaxLifetime)
+	sqlDB.SetConnMaxIdleTime(cfg.DBConnMaxIdleTime)
+	metrics.RegisterDBStats(sqlDB)
+	return nil
+}
+
+// poolSaturated — пул исчерпан, если все соединения заняты и есть ожидающие запросы.
+func poolSaturated(s sql.DBStats) error {
+	if s.MaxOpenConnections > 0 && s.InUse >= s.MaxOpenConnections && s.WaitCount > 0 {
+		return fmt.Errorf("пул соединений исчерпан: занято %d из %d, ожиданий %d (всего %s)",
+			s.InUse, s.MaxOpenConnections, s.WaitCount, s.WaitDuration.Round(time.Millisecond))
+	}
+	return nil
+}
+
+// queryStartKey — ключ времени начала запроса в gorm.Statement.
+const queryStartKey = "metrics:start"
+
+// registerQueryMetrics — подключает к GORM колбэки, измеряющие длительность
+// и ошибки запросов (agent_service_db_query_*{operation, table}).
+// «Запись не найдена» ошибкой не считается.
+func registerQueryMetrics(db *gorm.DB) error {
+	before := func(tx *gorm.DB) {
+		tx.InstanceSet(queryStartKey, time.Now())
+	}
+	after := func(operation string) func(*gorm.DB) {
+		return func(tx *gorm.DB) {
+			v, ok := tx.InstanceGet(queryStartKey)
+			if !ok {
+				return
+			}
+			start, _ := v.(time.Time)
+			table := tx.Statement.Table
+			if table == "" {
+				table = "raw"
+			}
+			failed := tx.Error != nil && !errors.Is(tx.Error, gorm.ErrRecordNotFound)
+			metrics.RecordDBQuery(operation, table, time.Since(start), failed)
+		}
+	}
+
+	cb := db.Callback()
+	var errs []error
+	errs = append(errs,
+		cb.Create().Before("gorm:create").Register("metrics:before_create", before),
+		cb.Create().After("gorm:create").Register("metrics:after_create", after("create")),
+		cb.Query().Before("gorm:query").Register("metrics:before_query", before),
+		cb.Query().After("gorm:query").Register("metrics:after_query", after("query")),
+		cb.Update().Before("gorm:update").Register("metrics:before_update", before),
+		cb.Update().After("gorm:update").Register("metrics:after_update", after("update")),
+		cb.Delete().Before("gorm:delete").Register("metrics:before_delete", before),
+		cb.Delete().After("gorm:delete").Register("metrics:after_delete", after("delete")),
+		cb.Row().Before("gorm:row").Register("metrics:before_row", before),
+		cb.Row().After("gorm:row").Register("metrics:after_row", after("row")),
+		cb.Raw().Before("gorm:raw").Register("metrics:before_raw", before),
+		cb.Raw().After("gorm:raw").Register("metrics:after_raw", after("raw")),
+	)
+	return errors.Join(errs...)
+}
+
+// hotIndexes — составные индексы для частых выборок:
+// история чата агента, фильтрация системного лога, поиск документов RAG по названию.
+var hotIndexes = []string{
+	"CREATE INDEX IF NOT EXISTS idx_messages_agent_created ON messages (agent_id, created_at)",
+	"CREATE INDEX IF NOT EXISTS idx_system_logs_level_created ON system_logs (level, created_at)",
+	"CREATE INDEX IF NOT EXISTS idx_rag_documents_title ON rag_documents (title)",
+}
+
+// ensureIndexes — создаёт индексы горячих запросов (идемпотентно).
+func ensureIndexes(db *gorm.DB) error {
+	var failed []string
+	for _, stmt := range hotIndexes {
+		if err := db.Exec(stmt).Error; err != nil {
+			failed = append(failed, err.Error())
+		}
+	}
+	if len(failed) > 0 {
+		return errors.New(strings.Join(failed, "; "))
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+package db
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+
+	"github.com/neo-2022/openclaw-memory/agent-service/internal/models"
+)
+
+// TestPoolSaturated — пул считается исчерпанным, только если все соединения заняты и есть ожидания.
+func TestPoolSaturated(t *testing.T) {
+	if err := poolSaturated(sql.DBStats{MaxOpenConnections: 5, InUse: 3, WaitCount: 10}); err != nil {
+		t.Errorf("есть свободные соединения, ошибки быть не должно: %v", err)
+	}
+	if err := poolSaturated(sql.DBStats{MaxOpenConnections: 5, InUse: 5}); err != nil {
+		t.Errorf("без ожиданий пул не исчерпан: %v", err)
+	}
+	if err := poolSaturated(sql.DBStats{MaxOpenConnections: 5, InUse: 5, WaitCount: 1}); err == nil {
+		t.Error("ожидалась ошибка исчерпания пула")
+	}
+	if err := poolSaturated(sql.DBStats{InUse: 100, WaitCount: 1}); err != nil {
+		t.Errorf("без лимита пул не может быть исчерпан: %v", err)
+	}
+}
+
+// TestQueryMetrics — колбэки GORM записывают длительность запроса с операцией и таблицей.
+func TestQueryMetrics(t *testing.T) {
+	gdb, err := gorm.Open(postgres.New(postgres.Config{DSN: "host=localhost"}), &gorm.Config{
+		DryRun:               true,
+		DisableAutomaticPing: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := registerQueryMetrics(gdb); err != nil {
+		t.Fatalf("регистрация колбэков: %v", err)
+	}
+	var logs []models.SystemLog
+	gdb.Where("level = ?", "error").Find(&logs)
+
+	families, err := prometheus.DefaultGatherer.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range families {
+		if f.GetName() != "agent_service_db_query_duration_seconds" {
+			continue
+		}
+		for _, m := range f.GetMetric() {
+			labels := map[string]string{}
+			for _, l := range m.GetLabel() {
+				labels[l.GetName()] = l.GetValue()
+			}
+			if labels["operation"] == "query" && labels["table"] == "system_logs" && m.GetHistogram().GetSampleCount() > 0 {
+				return
+			}
+		}
+	}
+	t.Error("не найдено наблюдение query/system_logs в agent_service_db_query_duration_seconds")
+}
@@ -0,0 +1,69 @@
+//go:build cgo
+
+package db
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+
+	"github.com/neo-2022/openclaw-memory/agent-service/internal/logstore"
+	"github.com/neo-2022/openclaw-memory/agent-service/internal/models"
+)
+
+// TestSQLiteMigrateAndCRUD — в режиме SQLite проходят все миграции и основные операции:
+// агенты, чаты (UUID без gen_random_uuid), сообщения, провайдеры, документы RAG и поиск по логам.
+func TestSQLiteMigrateAndCRUD(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "agent.db")
+	gdb, err := gorm.Open(sqlite.Open(SQLiteDSN(path)), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("открытие SQLite: %v", err)
+	}
+	if err := Migrate(gdb); err != nil {
+		t.Fatalf("миграции SQLite: %v", err)
+	}
+	// Повторный запуск миграций не должен падать
+	if err := Migrate(gdb); err != nil {
+		t.Fatalf("повторные миграции SQLite: %v", err)
+	}
+
+	agent := models.Agent{Name: "admin", LLMModel: "qwen2.5:7b"}
+	if err := gdb.Create(&agent).Error; err != nil {
+		t.Fatalf("создание агента: %v", err)
+	}
+	chat := models.Chat{Name: "тест"}
+	if err := gdb.Create(&chat).Error; err != nil {
+		t.Fatalf("создание чата: %v", err)
+	}
+	if len(chat.ID) != 36 {
+		t.Errorf("ожидался UUID чата, получено %q", chat.ID)
+	}
+	msg := models.Message{Role: "user", Content: "привет", AgentID: agent.ID, ChatID: &chat.ID}
+	if err := gdb.Create(&msg).Error; err != nil {
+		t.Fatalf("создание сообщения: %v", err)
+	}
+	if err := gdb.Create(&models.ProviderConfig{ProviderName: "gigachat", APIKey: "k"}).Error; err != nil {
+		t.Fatalf("создание провайдера: %v", err)
+	}
+	if err := gdb.Create(&models.RagDocument{Title: "Руководство", Content: "текст"}).Error; err != nil {
+		t.Fatalf("создание документа RAG: %v", err)
+	}
+
+	var loaded models.Agent
+	if err := gdb.Preload("Messages").Where("name = ?", "admin").First(&loaded).Error; err != nil {
+		t.Fatalf("чтение агента: %v", err)
+	}
+	if len(loaded.Messages) != 1 || loaded.Provider != "ollama" {
+		t.Errorf("неожиданный агент: сообщений %d, провайдер %q", len(loaded.Messages), loaded.Provider)
+	}
+
+	gdb.Create(&models.SystemLog{Level: "error", Service: "agent-service", Message: "Ollama недоступна"})
+	logs, err := logstore.Query{Text: "ollama", Since: time.Now().Add(-time.Hour), Limit: 10}.Find(gdb)
+	if err != nil || len(logs) != 1 {
+		t.Errorf("поиск по логам в SQLite: %d записей, ошибка %v", len(logs), err)
+	}
+}
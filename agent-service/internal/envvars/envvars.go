@@ -0,0 +1,197 @@
+// Package envvars — переменные окружения инструментов для рабочего
+// пространства или диалога.
+//
+// Переменные (например, GITHUB_TOKEN) хранятся в таблице env_vars и
+// передаются tools-service в теле запросов execute и run_code — модель видит
+// только их имена. Значения секретных переменных не отдаются через API, не
+// пишутся в логи и вырезаются из ответа инструмента (и из потокового вывода
+// команды) до того, как он попадёт в промпт. Переменные диалога перекрывают
+// одноимённые переменные пространства.
+package envvars
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/neo-2022/openclaw-memory/agent-service/internal/models"
+)
+
+// Лимиты: tools-service принимает не больше 64 переменных на команду.
+const (
+	MaxVars        = 64
+	MaxValueBytes  = 16 << 10
+	MinSecretBytes = 4 // Более короткое значение нельзя надёжно вырезать из вывода
+)
+
+// Mask — замена секретного значения в выводе и в ответах API.
+const Mask = "***"
+
+var keyRe = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]{0,127}$`)
+
+// Имена переменных проверяются по белому списку (тому же, что в tools-service):
+// список опасных имён никогда не полон (GIT_CONFIG_*, GIT_ASKPASS, PAGER,
+// NODE_OPTIONS, PYTHONSTARTUP, PERL5OPT...), а секретам и настройкам
+// пользователя хватает нескольких шаблонов имён.
+
+// allowed — разрешённые имена, не подходящие под шаблоны.
+var allowed = map[string]bool{
+	"GIT_AUTHOR_NAME": true, "GIT_AUTHOR_EMAIL": true, "GIT_COMMITTER_NAME": true, "GIT_COMMITTER_EMAIL": true,
+	"AWS_REGION": true, "AWS_DEFAULT_REGION": true, "DATABASE_URL": true, "CI": true, "TZ": true, "NO_COLOR": true,
+}
+
+// allowedSuffixes — окончания имён секретов и учётных данных (GITHUB_TOKEN,
+// OPENAI_API_KEY, AWS_ACCESS_KEY_ID); имя может совпадать с окончанием без «_».
+var allowedSuffixes = []string{"_TOKEN", "_KEY", "_KEY_ID", "_SECRET", "_PASSWORD", "_USER", "_USERNAME"}
+
+// AllowedPrefix — префикс произвольных переменных пользователя (APP_REGION).
+const AllowedPrefix = "APP_"
+
+// deniedPrefixes — пространства имён загрузчика, shell и git: в них имя не
+// разрешается даже по окончанию.
+var deniedPrefixes = []string{"LD_", "DYLD_", "BASH_", "GIT_"}
+
+// Allowed — можно ли задать переменную с таким именем.
+func Allowed(name string) bool {
+	name = strings.ToUpper(name)
+	if allowed[name] {
+		return true
+	}
+	for _, p := range deniedPrefixes {
+		if strings.HasPrefix(name, p) {
+			return false
+		}
+	}
+	if strings.HasPrefix(name, AllowedPrefix) && len(name) > len(AllowedPrefix) {
+		return true
+	}
+	for _, s := range allowedSuffixes {
+		if strings.HasSuffix(name, s) || name == s[1:] {
+			return true
+		}
+	}
+	return false
+}
+
+// Validate — имя, размер и (для секрета) минимальная длина значения.
+func Validate(key, value string, secret bool) error {
+	switch {
+	case !keyRe.MatchString(key):
+		return fmt.Errorf("key: %q, ожидается имя вида GITHUB_TOKEN (латиница, цифры, _)", key)
+	case !Allowed(key):
+		return fmt.Errorf("key: переменную %s задавать нельзя: разрешены *_TOKEN, *_KEY, *_SECRET, *_PASSWORD, *_USER и %s*", key, AllowedPrefix)
+	case len(value) > MaxValueBytes:
+		return fmt.Errorf("value: больше %d КБ", MaxValueBytes>>10)
+	case strings.ContainsRune(value, 0):
+		return fmt.Errorf("value: нулевой байт")
+	case secret && len(value) < MinSecretBytes:
+		return fmt.Errorf("value: секрет короче %d символов", MinSecretBytes)
+	}
+	return nil
+}
+
+// Set — переменные одного запроса /chat.
+type Set struct {
+	Env     map[string]string
+	secrets []string // Значения секретов, длинные — первыми
+	names   []string
+	secret  map[string]bool
+}
+
+// Build — набор из переменных пространства и диалога; переменные позже в
+// списке перекрывают одноимённые (сначала пространство, затем диалог).
+// nil — переменных нет.
+func Build(vars ...[]models.EnvVar) *Set {
+	s := &Set{Env: map[string]string{}, secret: map[string]bool{}}
+	for _, list := range vars {
+		for _, v := range list {
+			if !Allowed(v.Key) {
+				// Сохранена до белого списка: tools-service отклонил бы всю команду
+				continue
+			}
+			s.Env[v.Key] = v.Value
+			s.secret[v.Key] = v.Secret
+		}
+	}
+	if len(s.Env) == 0 {
+		return nil
+	}
+	for k, v := range s.Env {
+		s.names = append(s.names, k)
+		if s.secret[k] && len(v) >= MinSecretBytes {
+			s.secrets = append(s.secrets, v)
+		}
+	}
+	sort.Strings(s.names)
+	sort.Slice(s.secrets, func(i, j int) bool { return len(s.secrets[i]) > len(s.secrets[j]) })
+	return s
+}
+
+// Describe — строка для системного промпта: имена переменных без значений.
+func (s *Set) Describe() string {
+	if s == nil {
+		return ""
+	}
+	parts := make([]string, 0, len(s.names))
+	for _, k := range s.names {
+		if s.secret[k] {
+			parts = append(parts, "$"+k+" (секрет)")
+		} else {
+			parts = append(parts, "$"+k)
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// WithSecret — копия набора, которая дополнительно вырезает value (секрет,
+// переданный инструменту не переменной диалога, например токен push
+// gitflow). Значение короче MinSecretBytes игнорируется (возвращается s);
+// для nil — набор только из value.
+func (s *Set) WithSecret(value string) *Set {
+	if len(value) < MinSecretBytes {
+		return s
+	}
+	out := &Set{}
+	if s != nil {
+		*out = *s
+	}
+	out.secrets = append([]string{value}, out.secrets...)
+	sort.SliceStable(out.secrets, func(i, j int) bool { return len(out.secrets[i]) > len(out.secrets[j]) })
+	return out
+}
+
+// Redact — text с вырезанными значениями секретов, в том числе в
+// JSON-экранированном виде (тело ответа инструмента).
+func (s *Set) Redact(text string) string {
+	if s == nil {
+		return text
+	}
+	for _, v := range s.secrets {
+		for _, form := range jsonForms(v) {
+			text = strings.ReplaceAll(text, form, Mask)
+		}
+	}
+	return text
+}
+
+// jsonForms — значение как есть и в виде строки JSON (с экранированием
+// <, >, & и без него), без кавычек.
+func jsonForms(v string) []string {
+	forms := []string{v}
+	for _, html := range []bool{true, false} {
+		var buf bytes.Buffer
+		enc := json.NewEncoder(&buf)
+		enc.SetEscapeHTML(html)
+		if enc.Encode(v) != nil {
+			continue
+		}
+		q := strings.TrimSuffix(buf.String(), "\n")
+		if esc := q[1 : len(q)-1]; esc != v {
+			forms = append(forms, esc)
+		}
+	}
+	return forms
+}
@@ -0,0 +1,97 @@
+package envvars
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/neo-2022/openclaw-memory/agent-service/internal/models"
+)
+
+func TestValidate(t *testing.T) {
+	for _, key := range []string{"GITHUB_TOKEN", "OPENAI_API_KEY", "AWS_ACCESS_KEY_ID", "APP_REGION", "GIT_AUTHOR_NAME"} {
+		if err := Validate(key, "ghp_123456", true); err != nil {
+			t.Errorf("%s: корректная переменная отклонена: %v", key, err)
+		}
+	}
+	for _, tc := range []struct {
+		key, value string
+		secret     bool
+	}{
+		{"1TOKEN", "x", false},
+		{"MY-TOKEN", "x", false},
+		{"PATH", "/tmp", false},
+		{"ld_preload", "x.so", false},
+		{"GIT_CONFIG_COUNT", "1", false},
+		{"GIT_ASKPASS", "/tmp/x", false},
+		{"NODE_OPTIONS", "--require /tmp/x.js", false},
+		{"PYTHONSTARTUP", "/tmp/x.py", false},
+		{"PAGER", "sh", false},
+		{"REGION", "eu", false},
+		{"TOKEN", "abc", true},
+		{"TOKEN", "a\x00b", false},
+		{"TOKEN", strings.Repeat("x", MaxValueBytes+1), false},
+	} {
+		if err := Validate(tc.key, tc.value, tc.secret); err == nil {
+			t.Errorf("%s=%q: ожидалась ошибка", tc.key, tc.value)
+		}
+	}
+}
+
+func TestBuild_ChatOverridesWorkspace(t *testing.T) {
+	ws := []models.EnvVar{{Key: "APP_REGION", Value: "eu"}, {Key: "TOKEN", Value: "workspace-token", Secret: true}}
+	chat := []models.EnvVar{{Key: "TOKEN", Value: "chat-token-1", Secret: true}}
+	s := Build(ws, chat)
+	if s.Env["TOKEN"] != "chat-token-1" || s.Env["APP_REGION"] != "eu" {
+		t.Fatalf("env = %v", s.Env)
+	}
+	if got := s.Describe(); got != "$APP_REGION, $TOKEN (секрет)" {
+		t.Errorf("Describe = %q", got)
+	}
+	if Build(nil, nil) != nil {
+		t.Error("без переменных — nil")
+	}
+}
+
+func TestRedact(t *testing.T) {
+	s := Build([]models.EnvVar{
+		{Key: "TOKEN", Value: "ghp_secret", Secret: true},
+		{Key: "APP_QUOTED", Value: `pa"ss<word>`, Secret: true},
+		{Key: "APP_REGION", Value: "eu-west"},
+	})
+	body, _ := json.Marshal(map[string]string{"stdout": `token=ghp_secret region=eu-west pw=pa"ss<word>`})
+	out := s.Redact(string(body))
+	if strings.Contains(out, "ghp_secret") || strings.Contains(out, "ss\\u003cword") {
+		t.Errorf("секрет не вырезан: %s", out)
+	}
+	if out := s.Redact(`pw=pa\"ss<word>`); out != "pw="+Mask {
+		t.Errorf("секрет в JSON без экранирования HTML не вырезан: %s", out)
+	}
+	if !strings.Contains(out, "region=eu-west") {
+		t.Errorf("обычная переменная не должна вырезаться: %s", out)
+	}
+	var nilSet *Set
+	if nilSet.Redact("text") != "text" {
+		t.Error("nil-набор не меняет текст")
+	}
+}
+
+// TestWithSecret — дополнительный секрет вырезается и без переменных диалога,
+// исходный набор не меняется.
+func TestWithSecret(t *testing.T) {
+	var none *Set
+	if got := none.WithSecret("ghp_push").Redact(`{"stderr":"ghp_push"}`); strings.Contains(got, "ghp_push") {
+		t.Errorf("токен в ответе: %s", got)
+	}
+	if none.WithSecret("ab") != nil {
+		t.Error("короткое значение должно игнорироваться")
+	}
+	s := Build([]models.EnvVar{{Key: "API_TOKEN", Value: "tok_1234", Secret: true}})
+	both := s.WithSecret("ghp_push")
+	if got := both.Redact("tok_1234 ghp_push"); got != Mask+" "+Mask {
+		t.Errorf("Redact = %q", got)
+	}
+	if got := s.Redact("ghp_push"); got != "ghp_push" {
+		t.Errorf("исходный набор изменён: %q", got)
+	}
+}
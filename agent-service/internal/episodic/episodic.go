@@ -0,0 +1,102 @@
+// Package episodic — эпизодическая память агента: краткие резюме прошлых диалогов.
+//
+// После ответа agent-service сохраняет в memory-service эпизод «задача → результат»,
+// а перед новым ответом ищет похожие прошлые эпизоды и добавляет в системный
+// промпт заметку «ранее ты уже делал похожее». В отличие от learnings (факты
+// о пользователе и окружении), эпизоды дают непрерывность между сессиями.
+package episodic
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Лимиты длины частей резюме эпизода.
+const (
+	maxTaskLen   = 200
+	maxResultLen = 300
+)
+
+// Episode — найденный прошлый эпизод.
+type Episode struct {
+	Text      string    // Резюме «задача → результат»
+	Score     float64   // Близость к текущему запросу (0..1)
+	CreatedAt time.Time // Когда состоялся диалог (нулевое — неизвестно)
+}
+
+// Summarize — резюме диалога без дополнительного вызова LLM: запрос пользователя,
+// начало ответа (до первого абзаца) и использованные инструменты.
+// Пустая строка — диалог не стоит запоминать (пустой запрос или ответ).
+func Summarize(userMsg, answer string, tools []string) string {
+	task := clip(oneLine(userMsg), maxTaskLen)
+	result := strings.TrimSpace(answer)
+	if i := strings.Index(result, "\n\n"); i > 0 {
+		result = result[:i]
+	}
+	result = clip(oneLine(result), maxResultLen)
+	if task == "" || result == "" {
+		return ""
+	}
+	summary := "Задача: " + task + "\nРезультат: " + result
+	if len(tools) > 0 {
+		summary += "\nИнструменты: " + strings.Join(unique(tools), ", ")
+	}
+	return summary
+}
+
+// FormatRecall — блок для системного промпта с прошлыми эпизодами.
+func FormatRecall(episodes []Episode, now time.Time) string {
+	if len(episodes) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteString("\n\n=== Похожие задачи из прошлых разговоров ===\n")
+	for _, e := range episodes {
+		when := "ранее"
+		if !e.CreatedAt.IsZero() {
+			when = ago(now.Sub(e.CreatedAt))
+		}
+		fmt.Fprintf(&b, "- %s ты уже делал похожее:\n  %s\n", when, strings.ReplaceAll(e.Text, "\n", "\n  "))
+	}
+	b.WriteString("=== Учитывай прошлый опыт, но проверяй, что он применим сейчас ===\n")
+	return b.String()
+}
+
+// ago — человекочитаемая давность эпизода.
+func ago(d time.Duration) string {
+	switch {
+	case d < time.Hour:
+		return "Недавно"
+	case d < 24*time.Hour:
+		return "Сегодня"
+	case d < 48*time.Hour:
+		return "Вчера"
+	default:
+		return fmt.Sprintf("%d дн. назад", int(d.Hours()/24))
+	}
+}
+
+func oneLine(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+func clip(s string, n int) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	return string([]rune(s)[:n]) + "…"
+}
+
+func unique(items []string) []string {
+	seen := make(map[string]bool, len(items))
+	out := make([]string, 0, len(items))
+	for _, it := range items {
+		if !seen[it] {
+			seen[it] = true
+			out = append(out, it)
+		}
+	}
+	return out
+}
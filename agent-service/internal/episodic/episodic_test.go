@@ -0,0 +1,41 @@
+package episodic
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestSummarize — резюме содержит задачу, первый абзац ответа и инструменты без повторов.
+func TestSummarize(t *testing.T) {
+	got := Summarize("  Настрой\nnginx  как прокси ", "Добавил proxy_pass в конфиг.\n\nПодробности: ...", []string{"execute", "write", "execute"})
+	want := "Задача: Настрой nginx как прокси\nРезультат: Добавил proxy_pass в конфиг.\nИнструменты: execute, write"
+	if got != want {
+		t.Errorf("ожидалось:\n%s\nполучено:\n%s", want, got)
+	}
+	if Summarize("вопрос", "   ", nil) != "" {
+		t.Error("диалог без ответа не должен сохраняться")
+	}
+	long := Summarize(strings.Repeat("я", 500), "ok", nil)
+	if !strings.Contains(long, "…") || len([]rune(long)) > maxTaskLen+50 {
+		t.Errorf("длинная задача должна обрезаться: %d символов", len([]rune(long)))
+	}
+}
+
+// TestFormatRecall — давность эпизода и отступы многострочного резюме.
+func TestFormatRecall(t *testing.T) {
+	now := time.Date(2025, 3, 10, 12, 0, 0, 0, time.UTC)
+	out := FormatRecall([]Episode{
+		{Text: "Задача: a\nРезультат: b", CreatedAt: now.Add(-30 * time.Hour)},
+		{Text: "Задача: c\nРезультат: d", CreatedAt: now.Add(-5 * 24 * time.Hour)},
+		{Text: "Задача: e\nРезультат: f"},
+	}, now)
+	for _, want := range []string{"Вчера ты уже делал похожее", "5 дн. назад", "ранее ты", "\n  Результат: b"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("нет %q в:\n%s", want, out)
+		}
+	}
+	if FormatRecall(nil, now) != "" {
+		t.Error("без эпизодов блок не добавляется")
+	}
+}
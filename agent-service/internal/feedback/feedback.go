@@ -0,0 +1,119 @@
+// Package feedback — оценки ответов агентов пользователями и агрегированная статистика.
+//
+// Оценка (👍 = +1, 👎 = -1) привязывается к сообщению ассистента и хранит
+// агента, модель и провайдера, сгенерировавших ответ. По статистике модели,
+// которые стабильно получают отрицательные оценки в роли агента, помечаются
+// в list_models_for_role, а комментарии к 👎 передаются в систему обучения.
+package feedback
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/neo-2022/openclaw-memory/agent-service/internal/models"
+	"gorm.io/gorm"
+)
+
+// Правила пометки модели как неподходящей для роли.
+const (
+	// FlagMinVotes — минимальное число оценок, чтобы делать выводы.
+	FlagMinVotes = 5
+	// FlagDownRatio — доля 👎, начиная с которой модель помечается.
+	FlagDownRatio = 0.6
+)
+
+// ErrInvalidRating — оценка не распознана.
+var ErrInvalidRating = errors.New("rating должен быть 1, -1, \"up\" или \"down\"")
+
+// ParseRating — приводит оценку из запроса к +1/-1.
+// Принимаются числа 1/-1 и строки up/down (как у кнопок 👍/👎 в интерфейсе).
+func ParseRating(v interface{}) (int, error) {
+	switch r := v.(type) {
+	case float64:
+		if r == 1 || r == -1 {
+			return int(r), nil
+		}
+	case string:
+		switch strings.ToLower(strings.TrimSpace(r)) {
+		case "up", "1", "+1":
+			return 1, nil
+		case "down", "-1":
+			return -1, nil
+		}
+	}
+	return 0, ErrInvalidRating
+}
+
+// Stat — сводка оценок по паре агент + модель.
+type Stat struct {
+	AgentName string  `json:"agent"`
+	ModelName string  `json:"model"`
+	Provider  string  `json:"provider"`
+	Up        int64   `json:"up"`
+	Down      int64   `json:"down"`
+	Total     int64   `json:"total"`
+	Score     float64 `json:"score"`   // (up - down) / total, от -1 до 1
+	Flagged   bool    `json:"flagged"` // Модель стабильно получает 👎 в этой роли
+}
+
+// DownRatio — доля отрицательных оценок.
+func (s Stat) DownRatio() float64 {
+	if s.Total == 0 {
+		return 0
+	}
+	return float64(s.Down) / float64(s.Total)
+}
+
+// ShouldFlag — достаточно оценок и доля 👎 не ниже FlagDownRatio.
+func ShouldFlag(up, down int64) bool {
+	total := up + down
+	return total >= FlagMinVotes && float64(down)/float64(total) >= FlagDownRatio
+}
+
+// Filter — ограничения выборки статистики; пустые поля не фильтруют.
+type Filter struct {
+	AgentName string
+	ModelName string
+}
+
+// Stats — агрегирует оценки по агенту и модели.
+func Stats(db *gorm.DB, f Filter) ([]Stat, error) {
+	q := db.Model(&models.MessageFeedback{}).
+		Select("agent_name, model_name, MAX(provider) AS provider, " +
+			"SUM(CASE WHEN rating > 0 THEN 1 ELSE 0 END) AS up, " +
+			"SUM(CASE WHEN rating < 0 THEN 1 ELSE 0 END) AS down, " +
+			"COUNT(*) AS total").
+		Group("agent_name, model_name").
+		Order("agent_name, model_name")
+	if f.AgentName != "" {
+		q = q.Where("agent_name = ?", f.AgentName)
+	}
+	if f.ModelName != "" {
+		q = q.Where("model_name = ?", f.ModelName)
+	}
+	var stats []Stat
+	if err := q.Scan(&stats).Error; err != nil {
+		return nil, err
+	}
+	for i := range stats {
+		s := &stats[i]
+		if s.Total > 0 {
+			s.Score = float64(s.Up-s.Down) / float64(s.Total)
+		}
+		s.Flagged = ShouldFlag(s.Up, s.Down)
+	}
+	return stats, nil
+}
+
+// ForRole — статистика моделей в роли агента, ключ — имя модели.
+func ForRole(db *gorm.DB, agentName string) (map[string]Stat, error) {
+	stats, err := Stats(db, Filter{AgentName: agentName})
+	if err != nil {
+		return nil, err
+	}
+	byModel := make(map[string]Stat, len(stats))
+	for _, s := range stats {
+		byModel[s.ModelName] = s
+	}
+	return byModel, nil
+}
@@ -0,0 +1,38 @@
+package feedback
+
+import "testing"
+
+// TestParseRating — числа ±1 и строки up/down принимаются, остальное — ошибка.
+func TestParseRating(t *testing.T) {
+	cases := map[interface{}]int{float64(1): 1, float64(-1): -1, "up": 1, "DOWN": -1, "-1": -1}
+	for in, want := range cases {
+		got, err := ParseRating(in)
+		if err != nil || got != want {
+			t.Errorf("%v: ожидалось %d, получено %d (%v)", in, want, got, err)
+		}
+	}
+	for _, in := range []interface{}{float64(0), float64(5), "meh", nil, true} {
+		if _, err := ParseRating(in); err == nil {
+			t.Errorf("%v: ожидалась ошибка", in)
+		}
+	}
+}
+
+// TestShouldFlag — модель помечается только при достаточном числе оценок и высокой доле 👎.
+func TestShouldFlag(t *testing.T) {
+	cases := []struct {
+		up, down int64
+		want     bool
+	}{
+		{0, 4, false}, // мало оценок
+		{2, 3, true},  // 60% 👎
+		{3, 2, false}, // 40% 👎
+		{10, 20, true},
+		{0, 0, false},
+	}
+	for _, c := range cases {
+		if got := ShouldFlag(c.up, c.down); got != c.want {
+			t.Errorf("up=%d down=%d: ожидалось %v", c.up, c.down, c.want)
+		}
+	}
+}
@@ -0,0 +1,63 @@
+//go:build cgo
+
+package feedback
+
+import (
+	"path/filepath"
+	"testing"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+
+	"github.com/neo-2022/openclaw-memory/agent-service/internal/models"
+)
+
+// TestStats — агрегация по агенту и модели, оценка и пометка неподходящих моделей.
+func TestStats(t *testing.T) {
+	gdb, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "fb.db")), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("открытие SQLite: %v", err)
+	}
+	if err := gdb.AutoMigrate(&models.MessageFeedback{}); err != nil {
+		t.Fatalf("миграция: %v", err)
+	}
+	add := func(agent, model string, rating, n int) {
+		for i := 0; i < n; i++ {
+			fb := models.MessageFeedback{MessageID: 1, AgentName: agent, ModelName: model, Provider: "ollama", Rating: rating}
+			if err := gdb.Create(&fb).Error; err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	add("coder", "qwen2.5:7b", -1, 4)
+	add("coder", "qwen2.5:7b", 1, 1)
+	add("coder", "llama3:8b", 1, 3)
+	add("admin", "qwen2.5:7b", 1, 2)
+
+	byModel, err := ForRole(gdb, "coder")
+	if err != nil {
+		t.Fatalf("ForRole: %v", err)
+	}
+	if len(byModel) != 2 {
+		t.Fatalf("ожидалось 2 модели у coder, получено %d", len(byModel))
+	}
+	q := byModel["qwen2.5:7b"]
+	if q.Up != 1 || q.Down != 4 || q.Total != 5 || !q.Flagged {
+		t.Errorf("qwen2.5:7b в роли coder: %+v", q)
+	}
+	if q.Score != -0.6 {
+		t.Errorf("ожидалась оценка -0.6, получено %v", q.Score)
+	}
+	if byModel["llama3:8b"].Flagged {
+		t.Error("модель с положительными оценками не должна помечаться")
+	}
+
+	all, err := Stats(gdb, Filter{ModelName: "qwen2.5:7b"})
+	if err != nil {
+		t.Fatalf("Stats: %v", err)
+	}
+	if len(all) != 2 || all[0].AgentName != "admin" || all[0].Flagged {
+		t.Errorf("фильтр по модели: %+v", all)
+	}
+}
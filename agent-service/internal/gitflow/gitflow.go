@@ -0,0 +1,236 @@
+// Package gitflow — составной git-сценарий агента: ветка, коммит с
+// сообщением в формате Conventional Commits, push и pull/merge request
+// через API GitHub или GitLab.
+//
+// Команды git выполняются через Exec (в agent-service — инструмент execute
+// tools-service на ПК пользователя), поэтому пакет не зависит от того, где
+// лежит репозиторий. Токены хостингов хранятся в настройках провайдеров
+// (ProviderConfig с именем github/gitlab).
+package gitflow
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+)
+
+// Exec — выполнение shell-команды с дополнительными переменными окружения env
+// (nil — без них); ошибка — при ненулевом коде возврата.
+type Exec func(ctx context.Context, command string, env map[string]string) (string, error)
+
+// WriteFile — запись файла (сообщение коммита передаётся через файл, чтобы
+// многострочный текст и кавычки не попадали в командную строку).
+type WriteFile func(ctx context.Context, path, content string) error
+
+// MessageFunc — генерация сообщения коммита по staged diff (обычно — LLM).
+type MessageFunc func(ctx context.Context, diff string) (string, error)
+
+// Workflow — зависимости сценария.
+type Workflow struct {
+	Exec     Exec
+	Write    WriteFile
+	Message  MessageFunc                       // nil — FallbackMessage
+	Accounts func(host string) (Account, bool) // Токен хостинга для remote
+}
+
+// Request — параметры сценария.
+type Request struct {
+	RepoPath string   // Путь к репозиторию
+	Branch   string   // Новая ветка (пусто — из заголовка коммита или текущая feature-ветка)
+	Base     string   // Целевая ветка PR (пусто — ветка по умолчанию origin)
+	Files    []string // Файлы для коммита (пусто — все изменения)
+	Message  string   // Сообщение коммита (пусто — MessageFunc)
+	Title    string   // Заголовок PR (пусто — заголовок коммита)
+	Body     string   // Описание PR (пусто — тело коммита и список файлов)
+	Draft    bool     // Черновик PR
+	NoPR     bool     // Только ветка, коммит и push
+	Refs     string   // Ссылка на задачу трекера: строка «Refs:» в сообщении коммита и описании PR
+}
+
+// Step — выполненный шаг (для ответа агенту).
+type Step struct {
+	Name   string `json:"step"`
+	Output string `json:"output,omitempty"`
+}
+
+// Result — итог сценария.
+type Result struct {
+	Branch  string   `json:"branch"`
+	Base    string   `json:"base"`
+	Commit  string   `json:"commit"`
+	Message string   `json:"message"`
+	Files   []string `json:"files"`
+	Pushed  bool     `json:"pushed"`
+	PRURL   string   `json:"pr_url,omitempty"`
+	Steps   []Step   `json:"steps"`
+}
+
+// ErrNoChanges — в рабочем дереве нечего коммитить.
+var ErrNoChanges = errors.New("нет изменений для коммита")
+
+// MaxDiff — сколько символов staged diff передаётся генератору сообщения.
+const MaxDiff = 12000
+
+// msgFile — файл сообщения коммита внутри .git (перезаписывается при каждом
+// запуске, как COMMIT_EDITMSG).
+const msgFile = ".git/AGENT_COMMIT_MSG"
+
+// Run — ветка → add → commit → push → PR. При ошибке возвращает частичный
+// Result с выполненными шагами.
+func (w Workflow) Run(ctx context.Context, req Request) (Result, error) {
+	if strings.TrimSpace(req.RepoPath) == "" {
+		return Result{}, errors.New("не указан путь к репозиторию")
+	}
+	res := Result{}
+	gitEnv := func(step, args string, env map[string]string) (string, error) {
+		out, err := w.Exec(ctx, "git -C "+ShellQuote(req.RepoPath)+" "+args, env)
+		out = strings.TrimSpace(out)
+		if step != "" {
+			res.Steps = append(res.Steps, Step{Name: step, Output: out})
+		}
+		if err != nil {
+			return out, fmt.Errorf("%s: %w", step, err)
+		}
+		return out, nil
+	}
+	git := func(step, args string) (string, error) { return gitEnv(step, args, nil) }
+
+	status, err := git("status", "status --porcelain")
+	if err != nil {
+		return res, err
+	}
+	if status == "" {
+		return res, ErrNoChanges
+	}
+	current, err := git("", "rev-parse --abbrev-ref HEAD")
+	if err != nil || current == "HEAD" {
+		return res, errors.New("HEAD отсоединён — переключитесь на ветку")
+	}
+	res.Base = req.Base
+	if res.Base == "" {
+		res.Base = current
+		if def, err := git("", "symbolic-ref --short refs/remotes/origin/HEAD"); err == nil && def != "" {
+			res.Base = strings.TrimPrefix(def, "origin/")
+		}
+	}
+
+	// Staging до генерации сообщения: модели нужно именно то, что войдёт в коммит
+	if len(req.Files) > 0 {
+		quoted := make([]string, len(req.Files))
+		for i, f := range req.Files {
+			quoted[i] = ShellQuote(f)
+		}
+		_, err = git("add", "add -- "+strings.Join(quoted, " "))
+	} else {
+		_, err = git("add", "add -A")
+	}
+	if err != nil {
+		return res, err
+	}
+	names, err := git("", "diff --cached --name-only")
+	if err != nil {
+		return res, err
+	}
+	if names == "" {
+		return res, ErrNoChanges
+	}
+	res.Files = strings.Split(names, "\n")
+
+	res.Message = NormalizeMessage(req.Message)
+	if res.Message == "" && w.Message != nil {
+		diff, _ := git("", "diff --cached --stat")
+		patch, _ := git("", "diff --cached")
+		diff += "\n\n" + patch
+		if r := []rune(diff); len(r) > MaxDiff {
+			diff = string(r[:MaxDiff]) + "\n... (diff обрезан)"
+		}
+		if msg, err := w.Message(ctx, diff); err == nil {
+			res.Message = NormalizeMessage(msg)
+		} else {
+			res.Steps = append(res.Steps, Step{Name: "message", Output: "генерация не удалась: " + err.Error()})
+		}
+	}
+	if res.Message == "" {
+		res.Message = FallbackMessage(res.Files)
+	}
+	if req.Refs != "" && !strings.Contains(res.Message, req.Refs) {
+		res.Message += "\n\nRefs: " + req.Refs
+	}
+
+	res.Branch = req.Branch
+	if res.Branch == "" {
+		if current != res.Base {
+			res.Branch = current // Уже на feature-ветке — продолжаем её
+		} else {
+			res.Branch = BranchName(Subject(res.Message))
+		}
+	}
+	if res.Branch == res.Base && !req.NoPR {
+		return res, fmt.Errorf("ветка %s совпадает с целевой — укажите branch", res.Branch)
+	}
+	if res.Branch != current {
+		if _, err := git("checkout", "checkout -B "+ShellQuote(res.Branch)); err != nil {
+			return res, err
+		}
+	}
+
+	msgPath := path.Join(req.RepoPath, msgFile)
+	if err := w.Write(ctx, msgPath, res.Message+"\n"); err != nil {
+		return res, fmt.Errorf("commit: запись сообщения: %w", err)
+	}
+	if _, err := git("commit", "commit -F "+ShellQuote(msgPath)); err != nil {
+		return res, err
+	}
+	res.Commit, _ = git("", "rev-parse --short HEAD")
+
+	remoteURL, err := git("", "remote get-url origin")
+	if err != nil {
+		return res, errors.New("у репозитория нет remote origin")
+	}
+	remote, err := ParseRemote(remoteURL)
+	if err != nil {
+		return res, err
+	}
+	acc, hasAcc := Account{}, false
+	if w.Accounts != nil {
+		acc, hasAcc = w.Accounts(remote.Host)
+	}
+
+	// Сначала обычный push (ssh-ключ или credential helper); токен — запасной путь для https
+	pushArgs := "push -u origin " + ShellQuote(res.Branch)
+	if _, err := git("push", pushArgs); err != nil {
+		if !hasAcc || !remote.HTTPS {
+			return res, err
+		}
+		if _, err := gitEnv("push", credentialHelper+" "+pushArgs, acc.PushEnv()); err != nil {
+			return res, err
+		}
+	}
+	res.Pushed = true
+	if req.NoPR {
+		return res, nil
+	}
+	if !hasAcc {
+		return res, fmt.Errorf("%w: %s — сохраните токен в настройках провайдера github/gitlab", ErrNoAccount, remote.Host)
+	}
+
+	pr := PullRequest{Remote: remote, Head: res.Branch, Base: res.Base, Title: req.Title, Body: req.Body, Draft: req.Draft}
+	if pr.Title == "" {
+		pr.Title = Subject(res.Message)
+	}
+	if pr.Body == "" {
+		pr.Body = Body(res.Message)
+		if pr.Body != "" {
+			pr.Body += "\n\n"
+		}
+		pr.Body += "Файлы:\n- " + strings.Join(res.Files, "\n- ")
+	}
+	res.PRURL, err = OpenPullRequest(ctx, acc, pr)
+	if err != nil {
+		return res, err
+	}
+	res.Steps = append(res.Steps, Step{Name: "pull_request", Output: res.PRURL})
+	return res, nil
+}
@@ -0,0 +1,210 @@
+package gitflow
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/neo-2022/openclaw-memory/agent-service/internal/httpclient"
+)
+
+// Хостинги репозиториев (имя провайдера в ProviderConfig).
+const (
+	ProviderGitHub = "github"
+	ProviderGitLab = "gitlab"
+)
+
+// Account — доступ к хостингу: токен и адрес API из настроек провайдера.
+type Account struct {
+	Provider string // github, gitlab
+	Token    string // Personal access token
+	BaseURL  string // GitHub Enterprise: https://host/api/v3; GitLab: https://host (пусто — публичный сервис)
+}
+
+// APIBase — корень REST API хостинга.
+func (a Account) APIBase() string {
+	base := strings.TrimRight(a.BaseURL, "/")
+	switch a.Provider {
+	case ProviderGitLab:
+		if base == "" {
+			base = "https://gitlab.com"
+		}
+		if !strings.HasSuffix(base, "/api/v4") {
+			base += "/api/v4"
+		}
+		return base
+	default:
+		if base == "" {
+			return "https://api.github.com"
+		}
+		return base
+	}
+}
+
+// Host — хост, к которому относится учётная запись (для выбора по remote).
+func (a Account) Host() string {
+	if a.BaseURL == "" {
+		if a.Provider == ProviderGitLab {
+			return "gitlab.com"
+		}
+		return "github.com"
+	}
+	u, err := url.Parse(a.BaseURL)
+	if err != nil {
+		return ""
+	}
+	return u.Hostname()
+}
+
+// Переменные окружения с учётными данными git push по https. Токен
+// передаётся только через окружение команды: в командной строке (её видно в
+// ps и логах вызовов) — лишь credentialHelper, читающий эти переменные.
+const (
+	PushUserEnv  = "GITFLOW_PUSH_USER"
+	PushTokenEnv = "GITFLOW_PUSH_TOKEN"
+)
+
+// credentialHelper — git push -c ... с помощником учётных данных из окружения;
+// пустой credential.helper отключает помощники пользователя, чтобы токен не
+// сохранился в их хранилище.
+const credentialHelper = `-c credential.helper= -c credential.helper='!f() { test "$1" = get && printf "username=%s\npassword=%s\n" "$` + PushUserEnv + `" "$` + PushTokenEnv + `"; }; f'`
+
+// PushEnv — окружение git push по https с токеном (см. credentialHelper).
+func (a Account) PushEnv() map[string]string {
+	user := "x-access-token"
+	if a.Provider == ProviderGitLab {
+		user = "oauth2"
+	}
+	return map[string]string{PushUserEnv: user, PushTokenEnv: a.Token}
+}
+
+// PullRequest — параметры pull/merge request.
+type PullRequest struct {
+	Remote Remote
+	Head   string // Ветка с изменениями
+	Base   string // Целевая ветка
+	Title  string
+	Body   string
+	Draft  bool
+}
+
+// ErrNoAccount — для хоста remote не настроен токен.
+var ErrNoAccount = errors.New("не настроен токен хостинга")
+
+var httpClient = httpclient.New("git_hosting", 30*time.Second)
+
+// OpenPullRequest — создаёт PR (GitHub) или MR (GitLab) и возвращает его URL.
+// Если запрос для ветки уже открыт, возвращается URL существующего.
+func OpenPullRequest(ctx context.Context, acc Account, pr PullRequest) (string, error) {
+	if acc.Token == "" {
+		return "", fmt.Errorf("%w: %s", ErrNoAccount, pr.Remote.Host)
+	}
+	if acc.Provider == ProviderGitLab {
+		return openGitLabMR(ctx, acc, pr)
+	}
+	return openGitHubPR(ctx, acc, pr)
+}
+
+func openGitHubPR(ctx context.Context, acc Account, pr PullRequest) (string, error) {
+	endpoint := acc.APIBase() + "/repos/" + pr.Remote.Path + "/pulls"
+	var created struct {
+		HTMLURL string `json:"html_url"`
+	}
+	status, err := doJSON(ctx, acc, http.MethodPost, endpoint, map[string]interface{}{
+		"title": pr.Title, "head": pr.Head, "base": pr.Base, "body": pr.Body, "draft": pr.Draft,
+	}, &created)
+	if status == http.StatusUnprocessableEntity {
+		// «A pull request already exists» — ищем открытый PR этой ветки
+		var existing []struct {
+			HTMLURL string `json:"html_url"`
+		}
+		q := url.Values{"head": {pr.Remote.Owner() + ":" + pr.Head}, "state": {"open"}}
+		if _, lerr := doJSON(ctx, acc, http.MethodGet, endpoint+"?"+q.Encode(), nil, &existing); lerr == nil && len(existing) > 0 {
+			return existing[0].HTMLURL, nil
+		}
+	}
+	if err != nil {
+		return "", fmt.Errorf("GitHub: %w", err)
+	}
+	return created.HTMLURL, nil
+}
+
+func openGitLabMR(ctx context.Context, acc Account, pr PullRequest) (string, error) {
+	endpoint := acc.APIBase() + "/projects/" + url.PathEscape(pr.Remote.Path) + "/merge_requests"
+	title := pr.Title
+	if pr.Draft {
+		title = "Draft: " + title
+	}
+	var created struct {
+		WebURL string `json:"web_url"`
+	}
+	status, err := doJSON(ctx, acc, http.MethodPost, endpoint, map[string]interface{}{
+		"title": title, "source_branch": pr.Head, "target_branch": pr.Base, "description": pr.Body,
+	}, &created)
+	if status == http.StatusConflict {
+		var existing []struct {
+			WebURL string `json:"web_url"`
+		}
+		q := url.Values{"source_branch": {pr.Head}, "state": {"opened"}}
+		if _, lerr := doJSON(ctx, acc, http.MethodGet, endpoint+"?"+q.Encode(), nil, &existing); lerr == nil && len(existing) > 0 {
+			return existing[0].WebURL, nil
+		}
+	}
+	if err != nil {
+		return "", fmt.Errorf("GitLab: %w", err)
+	}
+	return created.WebURL, nil
+}
+
+// doJSON — запрос к API хостинга; возвращает HTTP-статус и ошибку для кодов вне 2xx.
+func doJSON(ctx context.Context, acc Account, method, endpoint string, body, out interface{}) (int, error) {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return 0, err
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, reader)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if acc.Provider == ProviderGitLab {
+		req.Header.Set("PRIVATE-TOKEN", acc.Token)
+	} else {
+		req.Header.Set("Authorization", "Bearer "+acc.Token)
+		req.Header.Set("Accept", "application/vnd.github+json")
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		var apiErr struct {
+			Message interface{} `json:"message"`
+		}
+		json.Unmarshal(data, &apiErr)
+		msg := fmt.Sprint(apiErr.Message)
+		if apiErr.Message == nil {
+			msg = strings.TrimSpace(string(data))
+		}
+		return resp.StatusCode, fmt.Errorf("HTTP %d: %s", resp.StatusCode, msg)
+	}
+	if out != nil {
+		if err := json.Unmarshal(data, out); err != nil {
+			return resp.StatusCode, fmt.Errorf("разбор ответа: %w", err)
+		}
+	}
+	return resp.StatusCode, nil
+}
@@ -0,0 +1,111 @@
+package gitflow
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// conventionalTypes — типы Conventional Commits.
+var conventionalTypes = map[string]bool{
+	"feat": true, "fix": true, "docs": true, "style": true, "refactor": true, "perf": true,
+	"test": true, "build": true, "ci": true, "chore": true, "revert": true,
+}
+
+var conventionalRe = regexp.MustCompile(`^([a-z]+)(\([^)]*\))?(!)?: \S`)
+
+// maxSubject — предел длины заголовка коммита.
+const maxSubject = 72
+
+// NormalizeMessage — приводит ответ модели к сообщению Conventional Commits:
+// снимает обрамление ```, пустые строки в начале и кавычки; заголовок без
+// допустимого типа получает префикс «chore: » и обрезается до 72 символов.
+func NormalizeMessage(raw string) string {
+	raw = strings.TrimSpace(raw)
+	raw = strings.TrimPrefix(raw, "```text")
+	raw = strings.TrimPrefix(raw, "```")
+	raw = strings.TrimSuffix(raw, "```")
+	lines := strings.Split(strings.TrimSpace(raw), "\n")
+	subject := strings.Trim(strings.TrimSpace(lines[0]), "\"'`")
+	if subject == "" {
+		return ""
+	}
+	if m := conventionalRe.FindStringSubmatch(subject); m == nil || !conventionalTypes[m[1]] {
+		subject = "chore: " + lowerFirst(subject)
+	}
+	subject = strings.TrimSuffix(subject, ".")
+	if r := []rune(subject); len(r) > maxSubject {
+		subject = strings.TrimSpace(string(r[:maxSubject]))
+	}
+	body := strings.TrimSpace(strings.Join(lines[1:], "\n"))
+	if body == "" {
+		return subject
+	}
+	return subject + "\n\n" + body
+}
+
+// Subject — первая строка сообщения коммита.
+func Subject(msg string) string {
+	s, _, _ := strings.Cut(msg, "\n")
+	return s
+}
+
+// Body — сообщение коммита без заголовка.
+func Body(msg string) string {
+	_, b, _ := strings.Cut(msg, "\n")
+	return strings.TrimSpace(b)
+}
+
+// FallbackMessage — сообщение без модели: по списку изменённых файлов.
+func FallbackMessage(files []string) string {
+	switch len(files) {
+	case 0:
+		return "chore: update files"
+	case 1:
+		return "chore: update " + files[0]
+	default:
+		return fmt.Sprintf("chore: update %d files", len(files))
+	}
+}
+
+// BranchName — имя ветки из заголовка коммита: «feat(api): Add X» → feat/add-x.
+func BranchName(subject string) string {
+	prefix := "chore"
+	if m := conventionalRe.FindStringSubmatch(subject); m != nil && conventionalTypes[m[1]] {
+		prefix = m[1]
+		subject = subject[strings.Index(subject, ": ")+2:]
+	}
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(subject) {
+		if r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)) {
+			b.WriteRune(r)
+			dash = false
+		} else if !dash && b.Len() > 0 {
+			b.WriteByte('-')
+			dash = true
+		}
+		if b.Len() >= 40 {
+			break
+		}
+	}
+	slug := strings.Trim(b.String(), "-")
+	if slug == "" {
+		slug = "update"
+	}
+	return prefix + "/" + slug
+}
+
+func lowerFirst(s string) string {
+	r := []rune(s)
+	if len(r) > 1 && unicode.IsUpper(r[0]) && !unicode.IsUpper(r[1]) {
+		r[0] = unicode.ToLower(r[0])
+	}
+	return string(r)
+}
+
+// ShellQuote — аргумент в одинарных кавычках для bash.
+func ShellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
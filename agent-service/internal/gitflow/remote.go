@@ -0,0 +1,58 @@
+package gitflow
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// Remote — репозиторий на хостинге, разобранный из URL remote origin.
+type Remote struct {
+	Host  string // github.com, gitlab.example.com
+	Path  string // owner/repo (для GitLab — с подгруппами: group/sub/repo)
+	HTTPS bool   // remote по https (push с токеном возможен); false — ssh
+}
+
+// Owner — владелец репозитория (для GitLab — пространство имён).
+func (r Remote) Owner() string {
+	if i := strings.LastIndexByte(r.Path, '/'); i >= 0 {
+		return r.Path[:i]
+	}
+	return ""
+}
+
+// Name — имя репозитория.
+func (r Remote) Name() string {
+	return r.Path[strings.LastIndexByte(r.Path, '/')+1:]
+}
+
+// ParseRemote — разбор URL remote: https://host/owner/repo(.git),
+// ssh://git@host[:port]/owner/repo.git и scp-форма git@host:owner/repo.git.
+func ParseRemote(raw string) (Remote, error) {
+	raw = strings.TrimSpace(raw)
+	var r Remote
+	switch {
+	case strings.Contains(raw, "://"):
+		u, err := url.Parse(raw)
+		if err != nil {
+			return Remote{}, fmt.Errorf("некорректный URL remote: %w", err)
+		}
+		r.Host = u.Hostname()
+		r.Path = u.Path
+		r.HTTPS = u.Scheme == "https" || u.Scheme == "http"
+	case strings.Contains(raw, ":"):
+		// git@github.com:owner/repo.git
+		hostPart, path, _ := strings.Cut(raw, ":")
+		if i := strings.IndexByte(hostPart, '@'); i >= 0 {
+			hostPart = hostPart[i+1:]
+		}
+		r.Host, r.Path = hostPart, path
+	default:
+		return Remote{}, fmt.Errorf("неизвестный формат remote: %s", raw)
+	}
+	r.Path = strings.TrimSuffix(strings.Trim(r.Path, "/"), ".git")
+	if r.Host == "" || !strings.Contains(r.Path, "/") {
+		return Remote{}, fmt.Errorf("в remote нет владельца и имени репозитория: %s", raw)
+	}
+	return r, nil
+}
@@ -0,0 +1,168 @@
+package guard
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// rule — шаблон находки.
+type rule struct {
+	name string
+	kind string
+	re   *regexp.Regexp
+	// group — номер группы со значением (0 — всё совпадение); маскируется только она
+	group int
+	// valid — дополнительная проверка значения (контрольная сумма, заглушки)
+	valid func(string) bool
+}
+
+// injectionRules — признаки попытки переопределить инструкции модели.
+var injectionRules = []rule{
+	{name: "ignore_instructions", kind: KindInjection, re: regexp.MustCompile(`(?i)\b(ignore|disregard|forget|override)\s+(all\s+|any\s+)?(of\s+)?(the\s+|your\s+)?(previous|prior|above|earlier|preceding|system)\s+(instructions?|prompts?|rules|directions|messages)`)},
+	{name: "ignore_instructions", kind: KindInjection, re: regexp.MustCompile(`(?i)(игнорируй|проигнорируй|забудь|отмени|не обращай внимания на)\s+(все\s+)?(предыдущие|прошлые|прежние|вышеуказанные|системные|данные ранее)\s+(инструкции|указания|правила|сообщения|промпты?)`)},
+	{name: "role_override", kind: KindInjection, re: regexp.MustCompile(`(?i)\byou\s+are\s+now\s+(in\s+)?(developer\s+mode|dan\b|jailbroken|unrestricted|an?\s+unfiltered)|\bdo\s+anything\s+now\b|(ты\s+теперь|отныне\s+ты)\s+(работаешь\s+)?(без\s+ограничений|в\s+режиме\s+разработчика)`)},
+	{name: "new_instructions", kind: KindInjection, re: regexp.MustCompile(`(?i)(^|\n)\s*(new|updated|real|actual)\s+(system\s+)?instructions?\s*:|(^|\n)\s*(новые|настоящие|обновлённые)\s+(системные\s+)?инструкции\s*:`)},
+	{name: "prompt_leak", kind: KindInjection, re: regexp.MustCompile(`(?i)\b(reveal|print|repeat|output|show)\s+(me\s+)?(your|the)\s+(system\s+prompt|hidden\s+instructions|initial\s+instructions)|(покажи|выведи|раскрой|повтори)\s+(свой\s+|свои\s+)?(системный\s+промпт|скрытые\s+инструкции|исходные\s+инструкции)`)},
+	{name: "exfiltration", kind: KindInjection, re: regexp.MustCompile(`(?i)\b(send|upload|post|forward|exfiltrate)\b.{0,40}\b(password|credentials|api[\s_-]?keys?|tokens?|\.env|ssh\s+keys?|secrets?)\b.{0,20}\bto\b|(отправь|перешли|загрузи)\S*\s.{0,40}(пароль|пароли|токен|ключи?\s+api|\.env|ssh-ключ|секрет)`)},
+	{name: "chat_template", kind: KindInjection, re: regexp.MustCompile(`<\|im_start\|>|<\|im_end\|>|<\|system\|>|<\|start_header_id\|>|\[/?INST\]|<<SYS>>`)},
+	{name: "html_comment", kind: KindHidden, re: regexp.MustCompile(`(?is)<!--.{0,500}?(ignore|instruction|assistant|you\s+must|system\s+prompt|ai\s+model|инструкц|ассистент|игнорируй|ты\s+должен)\S*.{0,500}?-->`)},
+	{name: "hidden_element", kind: KindHidden, re: regexp.MustCompile(`(?is)<[a-z][a-z0-9]*[^>]*\bstyle\s*=\s*["'][^"']*(display\s*:\s*none|visibility\s*:\s*hidden|font-size\s*:\s*0(px)?\s*[;"']|opacity\s*:\s*0\s*[;"'])[^>]*>\s*[^<\s][^<]{20,}`)},
+	{name: "invisible_text", kind: KindHidden, re: regexp.MustCompile(`[\x{E0000}-\x{E007F}]{4,}|[\x{200B}-\x{200D}\x{2060}\x{FEFF}]{8,}`)},
+}
+
+// ScanInjection — находки prompt-injection во внешнем тексте (по одной на правило).
+func ScanInjection(text string) []Finding {
+	var found []Finding
+	seen := map[string]bool{}
+	for _, r := range injectionRules {
+		if seen[r.name] {
+			continue
+		}
+		loc := r.re.FindStringIndex(text)
+		if loc == nil {
+			continue
+		}
+		seen[r.name] = true
+		found = append(found, Finding{Kind: r.kind, Rule: r.name, Excerpt: excerpt(text[loc[0]:loc[1]])})
+	}
+	return found
+}
+
+// excerpt — совпадение для отчёта: пробелы схлопнуты, не длиннее 80 символов.
+func excerpt(s string) string {
+	s = strings.Join(strings.Fields(s), " ")
+	if utf8.RuneCountInString(s) > 80 {
+		s = string([]rune(s)[:80]) + "…"
+	}
+	return s
+}
+
+// sensitiveRules — секреты и персональные данные в ответе модели.
+var sensitiveRules = []rule{
+	{name: "private_key", kind: KindSecret, re: regexp.MustCompile(`-----BEGIN [A-Z ]*PRIVATE KEY-----[\s\S]*?-----END [A-Z ]*PRIVATE KEY-----`)},
+	{name: "aws_access_key", kind: KindSecret, re: regexp.MustCompile(`\b(AKIA|ASIA)[0-9A-Z]{16}\b`)},
+	{name: "github_token", kind: KindSecret, re: regexp.MustCompile(`\b(gh[pousr]_[A-Za-z0-9]{36}|github_pat_[A-Za-z0-9_]{60,})\b`)},
+	{name: "api_key", kind: KindSecret, re: regexp.MustCompile(`\bsk-(proj-|or-v1-|ant-)?[A-Za-z0-9_-]{20,}`)},
+	{name: "api_key", kind: KindSecret, re: regexp.MustCompile(`\bAIza[0-9A-Za-z_-]{35}\b`)},
+	{name: "slack_token", kind: KindSecret, re: regexp.MustCompile(`\bxox[abprs]-[A-Za-z0-9-]{10,}`)},
+	{name: "jwt", kind: KindSecret, re: regexp.MustCompile(`\beyJ[A-Za-z0-9_-]{10,}\.eyJ[A-Za-z0-9_-]{10,}\.[A-Za-z0-9_-]{10,}`)},
+	{name: "url_password", kind: KindSecret, re: regexp.MustCompile(`\b[a-z][a-z0-9+.-]*://[^\s:/@]+:([^\s@/]{3,})@`), group: 1, valid: notPlaceholder},
+	{name: "password", kind: KindSecret, re: regexp.MustCompile(`(?i)(?:^|[^\p{L}\d_])(?:password|passwd|pwd|пароль|secret|token|api[_-]?key|access[_-]?key)["']?\s*[:=]\s*["']?([^\s"',;]{6,})`), group: 1, valid: notPlaceholder},
+	{name: "card_number", kind: KindPII, re: regexp.MustCompile(`\b\d{4}[ -]?\d{4}[ -]?\d{4}[ -]?\d{4}(?:[ -]?\d{3})?\b`), valid: luhn},
+	{name: "snils", kind: KindPII, re: regexp.MustCompile(`\b\d{3}-\d{3}-\d{3}[ -]\d{2}\b`)},
+	{name: "phone", kind: KindPII, re: regexp.MustCompile(`(?:\+7|\b8)[\s(-]*\d{3}[\s)-]*\d{3}[\s-]*\d{2}[\s-]*\d{2}\b`)},
+	{name: "email", kind: KindPII, re: regexp.MustCompile(`\b[A-Za-z0-9._%+-]+@[A-Za-z0-9-]+(\.[A-Za-z0-9-]+)*\.[A-Za-z]{2,}\b`), valid: notExampleEmail},
+}
+
+// match — найденное значение и его границы в тексте (байты).
+type match struct {
+	rule, kind string
+	start, end int
+}
+
+// scanSensitive — секреты и персональные данные; пересекающиеся совпадения
+// не дублируются (первым считается более специфичное правило).
+func scanSensitive(text string) []match {
+	var out []match
+	taken := func(s, e int) bool {
+		for _, m := range out {
+			if s < m.end && e > m.start {
+				return true
+			}
+		}
+		return false
+	}
+	for _, r := range sensitiveRules {
+		for _, loc := range r.re.FindAllStringSubmatchIndex(text, -1) {
+			s, e := loc[2*r.group], loc[2*r.group+1]
+			if s < 0 || taken(s, e) {
+				continue
+			}
+			if r.valid != nil && !r.valid(text[s:e]) {
+				continue
+			}
+			out = append(out, match{rule: r.name, kind: r.kind, start: s, end: e})
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].start < out[j].start })
+	return out
+}
+
+// redact — заменяет совпадения на [скрыто: правило].
+func redact(text string, matches []match) string {
+	var b strings.Builder
+	prev := 0
+	for _, m := range matches {
+		b.WriteString(text[prev:m.start])
+		b.WriteString("[скрыто: " + m.rule + "]")
+		prev = m.end
+	}
+	b.WriteString(text[prev:])
+	return b.String()
+}
+
+// notPlaceholder — значение похоже на настоящий секрет, а не на заглушку из примера.
+func notPlaceholder(v string) bool {
+	l := strings.ToLower(v)
+	for _, p := range []string{"<", "${", "{{", "your", "xxx", "***", "...", "example", "changeme", "password", "secret", "пароль", "ваш"} {
+		if strings.Contains(l, p) {
+			return false
+		}
+	}
+	return true
+}
+
+// notExampleEmail — адреса из документации и служебные адреса git не считаются персональными.
+func notExampleEmail(v string) bool {
+	l := strings.ToLower(v)
+	domain := l[strings.LastIndex(l, "@")+1:]
+	switch {
+	case domain == "example.com", domain == "example.org", strings.HasSuffix(domain, ".example"),
+		strings.HasSuffix(domain, "users.noreply.github.com"), strings.HasPrefix(l, "git@"), strings.HasPrefix(l, "noreply@"):
+		return false
+	}
+	return true
+}
+
+// luhn — контрольная сумма номера карты.
+func luhn(v string) bool {
+	sum, n := 0, 0
+	for i := len(v) - 1; i >= 0; i-- {
+		c := v[i]
+		if c < '0' || c > '9' {
+			continue
+		}
+		d := int(c - '0')
+		if n%2 == 1 {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		n++
+	}
+	return n >= 13 && sum%10 == 0
+}
@@ -0,0 +1,107 @@
+package handlers
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/neo-2022/openclaw-memory/agent-service/internal/calc"
+	"github.com/neo-2022/openclaw-memory/agent-service/internal/intent"
+)
+
+// handleCalculate считает арифметическое выражение
+func handleCalculate(params intent.Params) (string, error) {
+	v, err := calc.Eval(params["expr"])
+	if err != nil {
+		return "", err
+	}
+	text := params["text"]
+	if text == "" {
+		text = params["expr"]
+	}
+	return fmt.Sprintf("%s = %s", strings.TrimSpace(text), calc.Format(v)), nil
+}
+
+// handleConvertUnits переводит величину в другую единицу
+func handleConvertUnits(params intent.Params) (string, error) {
+	value, err := strconv.ParseFloat(strings.Replace(params["value"], ",", ".", 1), 64)
+	if err != nil {
+		return "", fmt.Errorf("невалидное число %q", params["value"])
+	}
+	res, unit, err := calc.Convert(value, params["from"], params["to"])
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s %s = %s %s", calc.Format(value), calc.UnitName(params["from"]), calc.Format(res), unit), nil
+}
+
+// handleDateTime отвечает на вопрос о дате или времени (операции intent.DateOp*)
+func handleDateTime(params intent.Params) (string, error) {
+	t := time.Now()
+	date := func(key string) (time.Time, error) {
+		if params[key] == "" {
+			return calc.Day(t), nil
+		}
+		return calc.ParseDate(params[key], t)
+	}
+	switch params["op"] {
+	case intent.DateOpTimeIn:
+		loc, err := calc.Location(params["place"])
+		if err != nil {
+			return "", err
+		}
+		local := t.In(loc)
+		return fmt.Sprintf("Сейчас в %s: %s (%s, UTC%s)", loc, local.Format("15:04"), calc.FormatDate(local), local.Format("-07:00")), nil
+
+	case intent.DateOpWeekday:
+		d, err := date("date")
+		if err != nil {
+			return "", err
+		}
+		return calc.FormatDate(d), nil
+
+	case intent.DateOpDays:
+		from, err := date("date")
+		if err != nil {
+			return "", err
+		}
+		to, err := date("date2")
+		if err != nil {
+			return "", err
+		}
+		// «Дней прошло с 01.09»: дата без года — прошедшая, а не ближайшая будущая
+		if params["date2"] == "" && strings.Count(params["date"], ".") == 1 && from.After(to) {
+			from = from.AddDate(-1, 0, 0)
+		}
+		days := calc.DaysBetween(from, to)
+		switch {
+		case params["date"] == "":
+			return fmt.Sprintf("До %s — %d дн.", calc.FormatDate(to), days), nil
+		case params["date2"] == "":
+			return fmt.Sprintf("С %s прошло %d дн.", calc.FormatDate(from), days), nil
+		}
+		return fmt.Sprintf("Между %s и %s — %d дн.", calc.FormatDate(from), calc.FormatDate(to), days), nil
+
+	case intent.DateOpShift:
+		n, err := strconv.Atoi(params["n"])
+		if err != nil {
+			return "", fmt.Errorf("невалидное число %q", params["n"])
+		}
+		from := t
+		if params["date"] != "" {
+			if from, err = date("date"); err != nil {
+				return "", err
+			}
+		}
+		res, clock, err := calc.Shift(from, n, params["unit"])
+		if err != nil {
+			return "", err
+		}
+		if clock {
+			return calc.FormatDateTime(res), nil
+		}
+		return calc.FormatDate(res), nil
+	}
+	return "", fmt.Errorf("неизвестная операция %q", params["op"])
+}
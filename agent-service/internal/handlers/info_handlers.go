@@ -0,0 +1,75 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/neo-2022/openclaw-memory/agent-service/internal/infosource"
+	"github.com/neo-2022/openclaw-memory/agent-service/internal/intent"
+)
+
+// newsLimit — сколько новостей показывать в ответе.
+const newsLimit = 5
+
+// infoTimeout — ожидание ответа внешнего API.
+const infoTimeout = 15 * time.Second
+
+var (
+	weatherSource infosource.WeatherSource
+	weatherPlace  string
+	newsSource    infosource.NewsSource
+)
+
+// ConfigureInfoSources задаёт источники интентов WEATHER и NEWS; вызывается
+// до RegisterBuiltins. Интент с nil-источником не регистрируется.
+func ConfigureInfoSources(weather infosource.WeatherSource, defaultPlace string, news infosource.NewsSource) {
+	weatherSource, weatherPlace, newsSource = weather, defaultPlace, news
+}
+
+// infoHandlers — обработчики интентов внешних данных; nil — источник не настроен.
+func infoHandlers() map[string]intent.Handler {
+	handlers := map[string]intent.Handler{intent.IntentWeather: nil, intent.IntentNews: nil}
+	if weatherSource != nil {
+		handlers[intent.IntentWeather] = handleWeather
+	}
+	if newsSource != nil {
+		handlers[intent.IntentNews] = handleNews
+	}
+	return handlers
+}
+
+// handleWeather отвечает погодой в месте (или в WEATHER_PLACE)
+func handleWeather(params intent.Params) (string, error) {
+	place := strings.TrimSpace(params["place"])
+	if place == "" {
+		place = weatherPlace
+	}
+	if place == "" {
+		return "Уточните город, например: «погода в Москве».", nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), infoTimeout)
+	defer cancel()
+	w, err := weatherSource.Weather(ctx, place)
+	if errors.Is(err, infosource.ErrNotFound) {
+		return fmt.Sprintf("Не нашёл место «%s». Уточните название города.", place), nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return infosource.FormatWeather(w, time.Now()), nil
+}
+
+// handleNews отвечает свежими новостями из лент
+func handleNews(params intent.Params) (string, error) {
+	query := strings.TrimSpace(params["query"])
+	ctx, cancel := context.WithTimeout(context.Background(), infoTimeout)
+	defer cancel()
+	items, err := newsSource.News(ctx, query, newsLimit)
+	if err != nil {
+		return "", err
+	}
+	return infosource.FormatNews(query, items, time.Local), nil
+}
@@ -8,10 +8,15 @@ import (
 	"os"
 	"os/exec"
 	"strings"
+	"time"
 
+	"github.com/neo-2022/openclaw-memory/agent-service/internal/httpclient"
 	"github.com/neo-2022/openclaw-memory/agent-service/internal/intent"
 )
 
+// gatewayClient — запросы интентов к api-gateway на общем пуле соединений.
+var gatewayClient = httpclient.New("gateway", 30*time.Second)
+
 func getToolsBaseURL() string {
 	if url := os.Getenv("GATEWAY_URL"); url != "" {
 		return url
@@ -21,22 +26,51 @@ func getToolsBaseURL() string {
 
 // HandleIntent вызывает соответствующий обработчик для данного интента
 func HandleIntent(intentType string, params intent.Params) (string, error) {
-	switch intentType {
-	case intent.IntentRememberFact:
-		return handleRememberFact(params)
-	case intent.IntentAddSynonym:
-		return handleAddSynonym(params)
-	case intent.IntentAddToAutostart:
-		return handleAddToAutostart(params)
-	case intent.IntentOpenApp:
-		return handleOpenApp(params)
-	case intent.IntentOpenFolder:
-		return handleOpenFolder(params)
-	case intent.IntentHardwareInfo:
-		return handleHardwareInfo()
-	default:
-		return "", fmt.Errorf("unknown intent: %s", intentType)
+	if handler, ok := builtinHandlers[intentType]; ok {
+		return handler(params)
+	}
+	return "", fmt.Errorf("unknown intent: %s", intentType)
+}
+
+// builtinHandlers — обработчики встроенных интентов (распознаватели — intent.Builtins).
+var builtinHandlers = map[string]intent.Handler{
+	intent.IntentRememberFact:   handleRememberFact,
+	intent.IntentAddSynonym:     handleAddSynonym,
+	intent.IntentAddToAutostart: handleAddToAutostart,
+	intent.IntentOpenApp:        handleOpenApp,
+	intent.IntentOpenFolder:     handleOpenFolder,
+	intent.IntentHardwareInfo:   func(intent.Params) (string, error) { return handleHardwareInfo() },
+	intent.IntentCalculate:      handleCalculate,
+	intent.IntentConvertUnits:   handleConvertUnits,
+	intent.IntentDateTime:       handleDateTime,
+}
+
+// RegisterBuiltins регистрирует встроенные интенты в реестре, сохраняя порядок их проверки.
+// Интенты внешних данных без настроенного источника (см. ConfigureInfoSources) пропускаются.
+func RegisterBuiltins(r *intent.Registry) error {
+	info := infoHandlers()
+	for i, b := range intent.Builtins() {
+		handler, ok := builtinHandlers[b.Name]
+		if !ok {
+			if handler, ok = info[b.Name]; ok && handler == nil {
+				continue
+			}
+		}
+		if !ok {
+			return fmt.Errorf("нет обработчика встроенного интента %s", b.Name)
+		}
+		if err := r.Register(intent.Intent{
+			Name:        b.Name,
+			Description: b.Description,
+			Source:      intent.SourceBuiltin,
+			Priority:    intent.BuiltinPriority + i,
+			Match:       b.Match,
+			Handle:      handler,
+		}); err != nil {
+			return err
+		}
 	}
+	return nil
 }
 
 // handleRememberFact отправляет факт в memory-service
@@ -54,7 +88,7 @@ func handleRememberFact(params intent.Params) (string, error) {
 		},
 	}
 	data, _ := json.Marshal(payload)
-	resp, err := http.Post(url, "application/json", bytes.NewReader(data))
+	resp, err := gatewayClient.Post(url, "application/json", bytes.NewReader(data))
 	if err != nil {
 		return "", fmt.Errorf("failed to call memory-service: %w", err)
 	}
@@ -86,7 +120,7 @@ func handleAddSynonym(params intent.Params) (string, error) {
 		},
 	}
 	data, _ := json.Marshal(payload)
-	resp, err := http.Post(url, "application/json", bytes.NewReader(data))
+	resp, err := gatewayClient.Post(url, "application/json", bytes.NewReader(data))
 	if err != nil {
 		return "", fmt.Errorf("failed to save synonym: %w", err)
 	}
@@ -111,7 +145,7 @@ func handleAddToAutostart(params intent.Params) (string, error) {
 	findURL := getToolsBaseURL() + "/tools/findapp"
 	findPayload := map[string]string{"name": app}
 	data, _ := json.Marshal(findPayload)
-	resp, err := http.Post(findURL, "application/json", bytes.NewReader(data))
+	resp, err := gatewayClient.Post(findURL, "application/json", bytes.NewReader(data))
 	if err != nil {
 		return "", fmt.Errorf("failed to call tools-service: %w", err)
 	}
@@ -170,7 +204,7 @@ func handleOpenApp(params intent.Params) (string, error) {
 	findURL := getToolsBaseURL() + "/tools/findapp"
 	findPayload := map[string]string{"name": app}
 	data, _ := json.Marshal(findPayload)
-	resp, err := http.Post(findURL, "application/json", bytes.NewReader(data))
+	resp, err := gatewayClient.Post(findURL, "application/json", bytes.NewReader(data))
 	if err != nil {
 		return "", fmt.Errorf("failed to call tools-service: %w", err)
 	}
@@ -195,7 +229,7 @@ func handleOpenApp(params intent.Params) (string, error) {
 		launchURL := getToolsBaseURL() + "/tools/launchapp"
 		launchPayload := map[string]string{"desktop_file": appInfo["desktop_path"].(string)}
 		launchData, _ := json.Marshal(launchPayload)
-		launchResp, err := http.Post(launchURL, "application/json", bytes.NewReader(launchData))
+		launchResp, err := gatewayClient.Post(launchURL, "application/json", bytes.NewReader(launchData))
 		if err != nil {
 			return "", fmt.Errorf("failed to launch: %w", err)
 		}
@@ -238,7 +272,7 @@ func handleOpenFolder(params intent.Params) (string, error) {
 	url := getToolsBaseURL() + "/tools/execute"
 	payload := map[string]string{"command": "xdg-open " + path}
 	data, _ := json.Marshal(payload)
-	resp, err := http.Post(url, "application/json", bytes.NewReader(data))
+	resp, err := gatewayClient.Post(url, "application/json", bytes.NewReader(data))
 	if err != nil {
 		return "", fmt.Errorf("failed to call tools-service: %w", err)
 	}
@@ -254,7 +288,7 @@ func handleOpenFolder(params intent.Params) (string, error) {
 // handleHardwareInfo получает информацию о железе
 func handleHardwareInfo() (string, error) {
 	url := getToolsBaseURL() + "/tools/sysinfo"
-	resp, err := http.Get(url)
+	resp, err := gatewayClient.Get(url)
 	if err != nil {
 		return "", fmt.Errorf("failed to call tools-service: %w", err)
 	}
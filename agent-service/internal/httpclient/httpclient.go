@@ -0,0 +1,112 @@
+// Package httpclient — HTTP-клиенты для запросов к другим сервисам
+// (memory-service, tools-service, Ollama) и облачным провайдерам LLM.
+//
+// Все клиенты работают через общий пул соединений с keep-alive: у
+// http.DefaultTransport всего 2 простаивающих соединения на хост, и при
+// параллельных чатах соединения с memory-service и Ollama постоянно
+// закрываются и открываются заново. Клиент помечен назначением (memory,
+// tools, ollama, openrouter...): по нему задаётся таймаут (HTTP_CLIENT_TIMEOUTS)
+// и собираются метрики — время ответа и доля переиспользованных соединений.
+package httpclient
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptrace"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/neo-2022/openclaw-memory/agent-service/internal/metrics"
+)
+
+var (
+	mu       sync.RWMutex
+	timeouts = map[string]time.Duration{}
+	pool     = newTransport(32, 90*time.Second)
+)
+
+func newTransport(maxIdlePerHost int, idleTimeout time.Duration) *http.Transport {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.MaxIdleConns = 0 // без общего предела, ограничение — на хост
+	t.MaxIdleConnsPerHost = maxIdlePerHost
+	t.IdleConnTimeout = idleTimeout
+	return t
+}
+
+// Configure — параметры пула и таймауты назначений (HTTP_MAX_IDLE_CONNS_PER_HOST,
+// HTTP_IDLE_CONN_TIMEOUT, HTTP_CLIENT_TIMEOUTS). Вызывается при старте до
+// первых запросов; клиенты, созданные раньше, сохраняют свой таймаут.
+func Configure(maxIdlePerHost int, idleTimeout time.Duration, destTimeouts map[string]time.Duration) {
+	mu.Lock()
+	defer mu.Unlock()
+	pool.MaxIdleConnsPerHost = maxIdlePerHost
+	pool.IdleConnTimeout = idleTimeout
+	timeouts = destTimeouts
+}
+
+// Transport — общий пул соединений; Clone() — основа для транспорта со своими
+// настройками TLS (см. NewWithTransport).
+func Transport() *http.Transport {
+	return pool
+}
+
+// New — клиент назначения destination на общем пуле. timeout — таймаут по
+// умолчанию (0 — без таймаута, для потоковых ответов; время ограничивает ctx).
+func New(destination string, timeout time.Duration) *http.Client {
+	return NewWithTransport(destination, pool, timeout)
+}
+
+// NewWithTransport — клиент назначения на своём транспорте (например, с
+// собственным TLS) с теми же метриками и таймаутами.
+func NewWithTransport(destination string, rt http.RoundTripper, timeout time.Duration) *http.Client {
+	mu.RLock()
+	if t, ok := timeouts[destination]; ok {
+		timeout = t
+	}
+	mu.RUnlock()
+	return &http.Client{Transport: &instrumented{destination: destination, next: rt}, Timeout: timeout}
+}
+
+// instrumented — метрики запросов назначения: время до заголовков ответа и
+// новое или переиспользованное соединение.
+type instrumented struct {
+	destination string
+	next        http.RoundTripper
+}
+
+func (t *instrumented) RoundTrip(r *http.Request) (*http.Response, error) {
+	trace := &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			metrics.RecordHTTPClientConn(t.destination, info.Reused)
+		},
+	}
+	start := time.Now()
+	resp, err := t.next.RoundTrip(r.WithContext(httptrace.WithClientTrace(r.Context(), trace)))
+	status := "error"
+	if err == nil {
+		status = fmt.Sprintf("%dxx", resp.StatusCode/100)
+	}
+	metrics.RecordHTTPClientRequest(t.destination, status, time.Since(start))
+	return resp, err
+}
+
+// ParseTimeouts — таймауты вида "memory=10s,ollama=10m" (назначение=длительность
+// через запятую, 0 — без таймаута).
+func ParseTimeouts(s string) (map[string]time.Duration, error) {
+	out := make(map[string]time.Duration)
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		dest, val, ok := strings.Cut(part, "=")
+		d, err := time.ParseDuration(strings.TrimSpace(val))
+		dest = strings.TrimSpace(dest)
+		if !ok || dest == "" || err != nil || d < 0 {
+			return nil, fmt.Errorf("%q: ожидается назначение=длительность (10s, 5m)", part)
+		}
+		out[dest] = d
+	}
+	return out, nil
+}
@@ -0,0 +1,59 @@
+package httpclient
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestNewTimeouts — таймаут назначения из HTTP_CLIENT_TIMEOUTS важнее заданного в коде.
+func TestNewTimeouts(t *testing.T) {
+	timeouts, err := ParseTimeouts(" memory=10s, ollama=0 ,")
+	if err != nil || timeouts["memory"] != 10*time.Second || len(timeouts) != 2 {
+		t.Fatalf("%v %v", timeouts, err)
+	}
+	if _, err := ParseTimeouts("memory=10"); err == nil {
+		t.Error("длительность без единиц — ошибка")
+	}
+	Configure(16, time.Minute, timeouts)
+	defer Configure(32, 90*time.Second, nil)
+
+	if c := New("memory", 30*time.Second); c.Timeout != 10*time.Second {
+		t.Errorf("memory: %v", c.Timeout)
+	}
+	if c := New("ollama", 5*time.Minute); c.Timeout != 0 {
+		t.Errorf("ollama: %v", c.Timeout)
+	}
+	if c := New("tools", time.Second); c.Timeout != time.Second || Transport().MaxIdleConnsPerHost != 16 {
+		t.Errorf("tools: %v", c.Timeout)
+	}
+}
+
+// TestKeepAlive — последовательные запросы идут через одно соединение пула.
+func TestKeepAlive(t *testing.T) {
+	var mu sync.Mutex
+	conns := map[string]bool{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		conns[r.RemoteAddr] = true
+		mu.Unlock()
+		w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+
+	c := New("test", 5*time.Second)
+	for i := 0; i < 3; i++ {
+		resp, err := c.Get(srv.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+	}
+	if len(conns) != 1 {
+		t.Errorf("соединений: %d, ожидалось одно", len(conns))
+	}
+}
@@ -0,0 +1,233 @@
+package i18n
+
+// en — английский каталог: русский текст → перевод.
+var en = map[string]string{
+	// Общие ошибки API
+	"Метод не поддерживается":                                       "Method not allowed",
+	"Невалидный JSON":                                               "Invalid JSON",
+	"Некорректный JSON":                                             "Invalid JSON",
+	"Невалидный JSON в history":                                     "Invalid JSON in history",
+	"Проверьте формат тела запроса":                                 "Check the request body format",
+	"Не удалось прочитать тело запроса":                             "Failed to read the request body",
+	"Ошибка формирования запроса":                                   "Failed to build the request",
+	"Ресурс не найден":                                              "Resource not found",
+	"Маршрут не найден":                                             "Route not found",
+	"Неизвестный ресурс: %s":                                        "Unknown resource: %s",
+	"Некорректный путь":                                             "Invalid path",
+	"Ошибка обновления":                                             "Update failed",
+	"Ошибка сохранения":                                             "Save failed",
+	"Не удалось удалить":                                            "Delete failed",
+	"Сервис перезапускается и не принимает новые запросы":           "The service is restarting and does not accept new requests",
+	"Повторите запрос через несколько секунд":                       "Retry the request in a few seconds",
+	"Слишком много одновременных запросов":                          "Too many concurrent requests",
+	"тело запроса %d байт превышает лимит %d байт":                  "request body of %d bytes exceeds the %d byte limit",
+	"Уменьшите размер загружаемого файла или разбейте его на части": "Reduce the upload size or split the file into parts",
+	"Исправьте файл конфигурации или переменные окружения":          "Fix the configuration file or environment variables",
+	"Конфигурация не применена: %s":                                 "Configuration not applied: %s",
+	"Не удалось сохранить конфигурацию":                             "Failed to save the configuration",
+
+	// Обязательные параметры
+	"Требуется agent":                                                     "agent is required",
+	"Требуется api_key":                                                   "api_key is required",
+	"Требуется folder_path":                                               "folder_path is required",
+	"Требуется id":                                                        "id is required",
+	"Требуется name":                                                      "name is required",
+	"Требуется provider":                                                  "provider is required",
+	"Требуется query":                                                     "query is required",
+	"Требуется url задачи":                                                "Task url is required",
+	"Требуется параметр agent":                                            "The agent parameter is required",
+	"Требуются agent и filename":                                          "agent and filename are required",
+	"Требуются agent и model":                                             "agent and model are required",
+	"Требуются api_key и base_url":                                        "api_key and base_url are required",
+	"Требуются title и content":                                           "title and content are required",
+	"Не указан agent":                                                     "agent is not specified",
+	"Не указан message_id":                                                "message_id is not specified",
+	"Не указан recording_id":                                              "recording_id is not specified",
+	"Не указан параметр agent":                                            "The agent parameter is not specified",
+	"Не указана модель или ID знания":                                     "Model or learning ID is not specified",
+	"before обязателен":                                                   "before is required",
+	"id обязателен":                                                       "id is required",
+	"message_id обязателен":                                               "message_id is required",
+	"model обязателен":                                                    "model is required",
+	"name обязателен":                                                     "name is required",
+	"level, service, message обязательны":                                 "level, service and message are required",
+	"Укажите agent, intent и enabled":                                     "Specify agent, intent and enabled",
+	"Укажите message_id, request_id или backup_ids":                       "Specify message_id, request_id or backup_ids",
+	"Укажите имя модели, например qwen2.5:7b":                             "Specify a model name, for example qwen2.5:7b",
+	"Вместе с provider укажите model":                                     "Specify model together with provider",
+	"Ожидается multipart/form-data":                                       "multipart/form-data expected",
+	"Ожидается {\"agent\": \"admin\", \"prompts\": [\"...\"]}":            "Expected {\"agent\": \"admin\", \"prompts\": [\"...\"]}",
+	"Ожидается {\"agent\": \"admin\"}":                                    "Expected {\"agent\": \"admin\"}",
+	"Ожидается {\"model\": \"qwen2.5:7b\"}":                               "Expected {\"model\": \"qwen2.5:7b\"}",
+	"Ожидается {\"name\": \"...\"}":                                       "Expected {\"name\": \"...\"}",
+	"Ожидается {\"name\": \"llama3.1:8b\"}":                               "Expected {\"name\": \"llama3.1:8b\"}",
+	"Ожидается {scope, subject, period, token_limit, cost_limit, action}": "Expected {scope, subject, period, token_limit, cost_limit, action}",
+
+	// Агенты, модели и провайдеры
+	"Агент не найден":                                                                                  "Agent not found",
+	"Не удалось обновить агента":                                                                       "Failed to update the agent",
+	"Не удалось обновить аватар":                                                                       "Failed to update the avatar",
+	"Не удалось прочитать файл промпта":                                                                "Failed to read the prompt file",
+	"Провайдер не найден":                                                                              "Provider not found",
+	"Провайдер не настроен":                                                                            "Provider is not configured",
+	"Руководство по провайдеру не найдено":                                                             "Provider guide not found",
+	"Неизвестный провайдер":                                                                            "Unknown provider",
+	"Проверьте конфигурацию провайдера":                                                                "Check the provider configuration",
+	"Не удалось получить модели":                                                                       "Failed to fetch models",
+	"Не удалось синхронизировать модели":                                                               "Failed to synchronize models",
+	"Ollama недоступна":                                                                                "Ollama is unavailable",
+	"Недопустимый размер контекста":                                                                    "Invalid context size",
+	"context_sizes: от 256 до 131072 токенов":                                                          "context_sizes: from 256 to 131072 tokens",
+	"temperature должна быть от 0 до 2":                                                                "temperature must be between 0 and 2",
+	"Замер не выполнен":                                                                                "Benchmark failed",
+	"Замер этой модели уже идёт":                                                                       "This model is already being benchmarked",
+	"Не удалось получить историю замеров":                                                              "Failed to fetch benchmark history",
+	"Personal access token с правами repo (GitHub) или api (GitLab); для Jira Cloud — email:api_token": "Personal access token with repo (GitHub) or api (GitLab) scope; for Jira Cloud use email:api_token",
+	"Long-lived access token из профиля Home Assistant и адрес вида http://homeassistant.local:8123":   "Long-lived access token from the Home Assistant profile and an address like http://homeassistant.local:8123",
+	"base_url — адрес брокера mqtt://host:1883 или mqtts://host:8883; api_key — user:password, если брокер требует вход": "base_url is the broker address mqtt://host:1883 or mqtts://host:8883; api_key is user:password if the broker requires login",
+
+	// Чат, диалоги и ответы
+	"Пустой список messages":                                "The messages list is empty",
+	"Передайте хотя бы одно сообщение":                      "Pass at least one message",
+	"Передайте историю в поле messages":                     "Pass the history in the messages field",
+	"История должна заканчиваться вопросом пользователя":    "The history must end with a user question",
+	"Диалог не найден":                                      "Conversation not found",
+	"Не удалось создать диалог":                             "Failed to create the conversation",
+	"Ошибка чтения диалогов":                                "Failed to read conversations",
+	"Сообщение не найдено":                                  "Message not found",
+	"Ответ не найден":                                       "Response not found",
+	"Оценивать можно только ответы агента":                  "Only agent responses can be rated",
+	"Ошибка сохранения оценки":                              "Failed to save the rating",
+	"Ошибка чтения оценок":                                  "Failed to read ratings",
+	"ID ответа возвращается в поле message_id ответа /chat": "The response ID is returned in the message_id field of the /chat response",
+	"Передайте ID ответа из поля message_id ответа /chat":   "Pass the response ID from the message_id field of the /chat response",
+	"Передайте ID записи из поля recording_id ответа /chat": "Pass the recording ID from the recording_id field of the /chat response",
+	"Хронология запроса не найдена или устарела":            "Request timeline not found or expired",
+	"Ошибка разбора ответа агента":                          "Failed to parse the agent response",
+	"Попробуйте переформулировать запрос":                   "Try rephrasing the request",
+	"Модель вернула пустой ответ. Возможно, исчерпан лимит запросов или модель недоступна. Попробуйте другую модель.": "The model returned an empty response. The request limit may be exhausted or the model is unavailable. Try another model.",
+	"Интент не найден: %s": "Intent not found: %s",
+	"провайдер %s временно отключён после серии ошибок, проверка через %s": "provider %s is temporarily disabled after a series of errors, next check in %s",
+	"Ошибка обработки намерения":                                           "Failed to process the intent",
+
+	// Запись и воспроизведение, пакеты
+	"Запись не найдена":               "Recording not found",
+	"Запись повреждена":               "Recording is corrupted",
+	"Ошибка чтения записей":           "Failed to read recordings",
+	"Не удалось удалить запись":       "Failed to delete the recording",
+	"Не удалось воспроизвести запрос": "Failed to replay the request",
+	"Не удалось повторить запрос":     "Failed to repeat the request",
+	"mock_llm несовместим с model":    "mock_llm cannot be combined with model",
+	"Ответы модели берутся из записи — другая модель не вызывается": "Model responses are taken from the recording; no other model is called",
+	"Пустой пакет":                "The batch is empty",
+	"Передайте prompts или items": "Pass prompts or items",
+	"В пакете %d запросов, допустимо не больше %d":          "The batch has %d requests, at most %d are allowed",
+	"Разбейте пакет на части или увеличьте BATCH_MAX_ITEMS": "Split the batch or increase BATCH_MAX_ITEMS",
+	"Запрос %d пуст": "Request %d is empty",
+	"У каждого элемента нужен prompt или messages":                        "Each item needs prompt or messages",
+	"Задание не найдено":                                                  "Job not found",
+	"Дождитесь завершения или отмените задание (DELETE /chat/batch/{id})": "Wait for completion or cancel the job (DELETE /chat/batch/{id})",
+
+	// Вложения, изображения и речь
+	"Не удалось прочитать вложение: %s":                               "Failed to read the attachment: %s",
+	"Поддерживаются текстовые файлы, PDF и DOCX":                      "Text files, PDF and DOCX are supported",
+	"Некорректное изображение: %s":                                    "Invalid image: %s",
+	"Передайте PNG/JPEG/GIF/WebP в base64 или имя загруженного файла": "Pass PNG/JPEG/GIF/WebP as base64 or the name of an uploaded file",
+	"Не удалось разобрать multipart form":                             "Failed to parse the multipart form",
+	"Файл не предоставлен":                                            "No file provided",
+	"Не передано поле audio":                                          "The audio field is missing",
+	"Передайте аудио в поле audio":                                    "Pass the audio in the audio field",
+	"Пустая аудиозапись":                                              "The audio recording is empty",
+	"Запишите сообщение ещё раз":                                      "Record the message again",
+	"Речь не распознана":                                              "No speech recognized",
+	"Не удалось распознать речь":                                      "Speech recognition failed",
+	"Проверьте доступность сервиса STT":                               "Check that the STT service is available",
+	"Распознавание речи не настроено: %s":                             "Speech recognition is not configured: %s",
+	"Задайте STT_BACKEND (whisper_cpp или openai)":                    "Set STT_BACKEND (whisper_cpp or openai)",
+
+	// Рабочие пространства, файлы и артефакты
+	"Рабочее пространство не найдено":                                                                 "Workspace not found",
+	"Не удалось создать workspace":                                                                    "Failed to create the workspace",
+	"Не удалось удалить workspace":                                                                    "Failed to delete the workspace",
+	"Проверьте путь рабочего пространства":                                                            "Check the workspace path",
+	"Папка не найдена":                                                                                "Folder not found",
+	"Путь не является папкой":                                                                         "The path is not a folder",
+	"Не удалось создать директорию":                                                                   "Failed to create the directory",
+	"Не удалось сохранить файл":                                                                       "Failed to save the file",
+	"Не удалось скопировать файл":                                                                     "Failed to copy the file",
+	"Не удалось откатить файл":                                                                        "Failed to restore the file",
+	"Копия файла не найдена":                                                                          "File backup not found",
+	"Копии файлов не найдены":                                                                         "File backups not found",
+	"Ошибка чтения копий файлов":                                                                      "Failed to read file backups",
+	"Артефакт не найден":                                                                              "Artifact not found",
+	"Файл артефакта не найден":                                                                        "Artifact file not found",
+	"Не удалось удалить артефакт":                                                                     "Failed to delete the artifact",
+	"Ошибка чтения артефактов":                                                                        "Failed to read artifacts",
+	"Индекс пуст: выполните POST /workspace/%s":                                                       "The index is empty: run POST /workspace/%s",
+	"Не удалось проиндексировать: %s":                                                                 "Indexing failed: %s",
+	"Ошибка чтения индекса":                                                                           "Failed to read the index",
+	"Ошибка поиска по индексу":                                                                        "Index search failed",
+	"Ошибка построения карты":                                                                         "Failed to build the repository map",
+	"Используйте /workspace/{id}/symbols, /workspace/{id}/symbols/search или /workspace/{id}/repomap": "Use /workspace/{id}/symbols, /workspace/{id}/symbols/search or /workspace/{id}/repomap",
+
+	// Знания, RAG и документы
+	"RAG не инициализирован":                          "RAG is not initialized",
+	"Ошибка подключения к memory-service":             "Failed to connect to memory-service",
+	"Не удалось сохранить документ":                   "Failed to save the document",
+	"GET /learnings/{model} или /learnings/item/{id}": "GET /learnings/{model} or /learnings/item/{id}",
+
+	// Задачи и трекеры
+	"Задача не найдена":                                                                   "Task not found",
+	"Некорректный id задачи":                                                              "Invalid task id",
+	"Некорректный status":                                                                 "Invalid status",
+	"Допустимо: planned, in_progress, review, done, cancelled":                            "Allowed: planned, in_progress, review, done, cancelled",
+	"Поддерживаются ссылки на issue GitHub/GitLab и тикеты Jira (/browse/KEY-1)":          "Links to GitHub/GitLab issues and Jira tickets (/browse/KEY-1) are supported",
+	"Для приватных задач сохраните токен через POST /providers (github, gitlab или jira)": "For private tasks save a token via POST /providers (github, gitlab or jira)",
+
+	// Бюджеты и расход
+	"Бюджет не найден":                                                 "Budget not found",
+	"Некорректный бюджет: %s":                                          "Invalid budget: %s",
+	"Неизвестный период %s":                                            "Unknown period %s",
+	"Допустимо: daily, monthly":                                        "Allowed: daily, monthly",
+	"Передайте scope, subject и period":                                "Pass scope, subject and period",
+	"scope: agent|key, period: daily|monthly, action: refuse|fallback": "scope: agent|key, period: daily|monthly, action: refuse|fallback",
+	"Ошибка сохранения бюджета":                                        "Failed to save the budget",
+	"Ошибка удаления бюджета":                                          "Failed to delete the budget",
+	"Ошибка чтения бюджетов":                                           "Failed to read budgets",
+	"Ошибка расчёта бюджета":                                           "Failed to calculate the budget",
+	"Ошибка чтения расхода":                                            "Failed to read usage",
+	"Бюджет на облачные модели исчерпан: %s":                           "The cloud model budget is exhausted: %s",
+	"Переключите агента на локальную модель, увеличьте лимит в /usage/budgets или задайте BUDGET_FALLBACK_MODEL": "Switch the agent to a local model, raise the limit in /usage/budgets or set BUDGET_FALLBACK_MODEL",
+	"Ошибка чтения статистики маршрутизации":                                                                     "Failed to read routing statistics",
+
+	// Логи
+	"Ошибка записи в системный лог":                                     "Failed to write to the system log",
+	"Ошибка чтения логов":                                               "Failed to read logs",
+	"Ошибка очистки логов":                                              "Failed to clear logs",
+	"Некорректная дата before":                                          "Invalid before date",
+	"Укажите дату: ?before=2025-01-31 или ?before=2025-01-31T00:00:00Z": "Specify a date: ?before=2025-01-31 or ?before=2025-01-31T00:00:00Z",
+	"Формат: 2025-01-31 или RFC3339":                                    "Format: 2025-01-31 or RFC3339",
+	"Пример: ?q=ollama&level=error&since=1h":                            "Example: ?q=ollama&level=error&since=1h",
+	"Используйте group_by=service или group_by=level":                   "Use group_by=service or group_by=level",
+}
+
+// enFragments — предложения, заменяемые внутри текста: подсказки к ошибкам
+// провайдеров, после которых идёт исходный текст ошибки.
+var enFragments = map[string]string{
+	"Превышен лимит запросов. Подождите и попробуйте снова.":                                                               "Request limit exceeded. Wait and try again.",
+	"Превышен лимит запросов (rate limit). Подождите и попробуйте снова.":                                                  "Request limit exceeded (rate limit). Wait and try again.",
+	"Недостаточно средств на балансе провайдера.":                                                                          "Insufficient provider balance.",
+	"Недостаточно средств на балансе. Пополните баланс или используйте бесплатную модель.":                                 "Insufficient balance. Top up the balance or use a free model.",
+	"Неверный API-ключ. Проверьте настройки провайдера.":                                                                   "Invalid API key. Check the provider settings.",
+	"Таймаут запроса к провайдеру. Модель не ответила вовремя. Попробуйте более лёгкую модель.":                            "The provider request timed out. The model did not respond in time. Try a lighter model.",
+	"Не удалось подключиться к провайдеру. Проверьте, что сервис запущен.":                                                 "Failed to connect to the provider. Check that the service is running.",
+	"Не удалось найти сервер провайдера. Проверьте URL в настройках.":                                                      "Provider server not found. Check the URL in the settings.",
+	"Ошибка YandexGPT: Folder ID не соответствует папке сервисного аккаунта. Проверьте Folder ID в настройках провайдера.": "YandexGPT error: the Folder ID does not match the service account folder. Check the Folder ID in the provider settings.",
+	"Некорректный запрос (400). Проверьте параметры провайдера.":                                                           "Bad request (400). Check the provider parameters.",
+	"Доступ запрещён. Проверьте разрешения API-ключа.":                                                                     "Access denied. Check the API key permissions.",
+	"Внутренняя ошибка сервера провайдера. Попробуйте позже.":                                                              "Provider internal server error. Try again later.",
+	"Сервер провайдера временно недоступен. Попробуйте позже.":                                                             "The provider server is temporarily unavailable. Try again later.",
+	"Таймаут сервера провайдера. Попробуйте более лёгкую модель.":                                                          "The provider server timed out. Try a lighter model.",
+	"Сервис провайдера временно недоступен. Попробуйте позже.":                                                             "The provider service is temporarily unavailable. Try again later.",
+	"Доступ заблокирован (Cloudflare/WAF). Возможно, ваш IP заблокирован провайдером. Попробуйте VPN или другой IP.":       "Access blocked (Cloudflare/WAF). Your IP may be blocked by the provider. Try a VPN or another IP.",
+}
@@ -0,0 +1,221 @@
+// Package i18n — язык сообщений API для пользователя.
+//
+// Исходный язык сообщений — русский: тексты ошибок, подсказок и руководств
+// пишутся в коде по-русски, а каталог другого языка (en) сопоставляет
+// русскому тексту перевод. Так каталог ru не нужен — он совпадает с кодом,
+// а сообщение без перевода остаётся русским, а не пропадает.
+//
+// Язык запроса выбирает Negotiate: параметр ?lang=, затем заголовок
+// X-Language (настройка пользователя в интерфейсе), затем Accept-Language,
+// иначе язык по умолчанию (DEFAULT_LANGUAGE). Выбранный язык сохраняется в
+// контексте запроса и в заголовке ответа Content-Language — по нему
+// apierror переводит ошибки, не меняя сигнатуры обработчиков.
+//
+// Записи каталога бывают трёх видов:
+//   - точный текст: "Агент не найден";
+//   - шаблон с %s и %d: "Некорректный бюджет: %s" — для сообщений, собранных
+//     fmt.Sprintf или сложением строк; подставленные значения сохраняются;
+//   - фрагмент (fragments): предложение, которое заменяется в любом месте
+//     текста, — подсказки провайдеров внутри текста ошибки.
+package i18n
+
+import (
+	"context"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Поддерживаемые языки.
+const (
+	Russian = "ru"
+	English = "en"
+)
+
+var (
+	mu          sync.RWMutex
+	defaultLang = Russian
+)
+
+// SetDefault — язык для запросов без явного выбора; неподдерживаемый игнорируется.
+func SetDefault(lang string) {
+	if lang = normalize(lang); lang != "" {
+		mu.Lock()
+		defaultLang = lang
+		mu.Unlock()
+	}
+}
+
+// Default — язык по умолчанию.
+func Default() string {
+	mu.RLock()
+	defer mu.RUnlock()
+	return defaultLang
+}
+
+// Supported — язык поддерживается (ru, en; регион игнорируется: en-US → en).
+func Supported(lang string) bool {
+	return normalize(lang) != ""
+}
+
+// normalize — код поддерживаемого языка или "".
+func normalize(lang string) string {
+	lang = strings.ToLower(strings.TrimSpace(lang))
+	if i := strings.IndexAny(lang, "-_"); i >= 0 {
+		lang = lang[:i]
+	}
+	switch lang {
+	case Russian, English:
+		return lang
+	}
+	return ""
+}
+
+// Negotiate — язык ответа на запрос r.
+func Negotiate(r *http.Request) string {
+	if lang := normalize(r.URL.Query().Get("lang")); lang != "" {
+		return lang
+	}
+	if lang := normalize(r.Header.Get("X-Language")); lang != "" {
+		return lang
+	}
+	if lang := fromAcceptLanguage(r.Header.Get("Accept-Language")); lang != "" {
+		return lang
+	}
+	return Default()
+}
+
+// fromAcceptLanguage — поддерживаемый язык с наибольшим весом q.
+func fromAcceptLanguage(header string) string {
+	best, bestQ := "", 0.0
+	for _, part := range strings.Split(header, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if f, err := strconv.ParseFloat(v, 64); err == nil {
+				q = f
+			}
+		}
+		if lang := normalize(tag); lang != "" && q > bestQ {
+			best, bestQ = lang, q
+		}
+	}
+	return best
+}
+
+type langKey struct{}
+
+// WithLanguage — контекст с языком запроса.
+func WithLanguage(ctx context.Context, lang string) context.Context {
+	return context.WithValue(ctx, langKey{}, lang)
+}
+
+// FromContext — язык запроса; без него — язык по умолчанию.
+func FromContext(ctx context.Context) string {
+	if lang, ok := ctx.Value(langKey{}).(string); ok && lang != "" {
+		return lang
+	}
+	return Default()
+}
+
+// catalog — переводы одного языка.
+type catalog struct {
+	exact     map[string]string
+	patterns  []pattern
+	fragments []string // Пары «русский, перевод», длинные первыми
+}
+
+type pattern struct {
+	re    *regexp.Regexp
+	verbs []string // %s или %d в порядке появления
+	out   []string // Перевод, разрезанный по %s и %d
+}
+
+var catalogs = map[string]*catalog{
+	English: newCatalog(en, enFragments),
+}
+
+var verbRe = regexp.MustCompile(`%[sd]`)
+
+// newCatalog — записи с %s и %d становятся шаблонами, остальные — точными.
+func newCatalog(messages, fragments map[string]string) *catalog {
+	c := &catalog{exact: map[string]string{}}
+	keys := make([]string, 0, len(messages))
+	for k := range messages {
+		keys = append(keys, k)
+	}
+	// Длинные шаблоны первыми: «Ошибка сохранения бюджета: %s» раньше «Ошибка сохранения: %s»
+	sort.Strings(keys)
+	sort.SliceStable(keys, func(i, j int) bool { return len(keys[i]) > len(keys[j]) })
+	for _, k := range keys {
+		if !verbRe.MatchString(k) {
+			c.exact[k] = messages[k]
+			continue
+		}
+		p := pattern{verbs: verbRe.FindAllString(k, -1), out: verbRe.Split(messages[k], -1)}
+		parts := verbRe.Split(k, -1)
+		var expr strings.Builder
+		expr.WriteString(`(?s)^`)
+		for i, part := range parts {
+			expr.WriteString(regexp.QuoteMeta(part))
+			if i < len(p.verbs) {
+				if p.verbs[i] == "%d" {
+					expr.WriteString(`(-?\d+)`)
+				} else {
+					expr.WriteString(`(.*?)`)
+				}
+			}
+		}
+		expr.WriteString(`$`)
+		p.re = regexp.MustCompile(expr.String())
+		c.patterns = append(c.patterns, p)
+	}
+	frags := make([]string, 0, len(fragments))
+	for k := range fragments {
+		frags = append(frags, k)
+	}
+	sort.Strings(frags)
+	sort.SliceStable(frags, func(i, j int) bool { return len(frags[i]) > len(frags[j]) })
+	for _, k := range frags {
+		c.fragments = append(c.fragments, k, fragments[k])
+	}
+	return c
+}
+
+// Translate — text на языке lang. Русский и тексты без перевода
+// возвращаются как есть.
+func Translate(lang, text string) string {
+	c := catalogs[normalize(lang)]
+	if c == nil || text == "" {
+		return text
+	}
+	if out, ok := c.exact[text]; ok {
+		return out
+	}
+	for _, p := range c.patterns {
+		m := p.re.FindStringSubmatch(text)
+		if m == nil {
+			continue
+		}
+		// Значения подставляются по порядку; текст на месте %s тоже переводится
+		var out strings.Builder
+		for i, part := range p.out {
+			out.WriteString(part)
+			if i+1 < len(p.out) && i+1 < len(m) {
+				v := m[i+1]
+				if p.verbs[i] == "%s" {
+					v = Translate(lang, v)
+				}
+				out.WriteString(v)
+			}
+		}
+		return out.String()
+	}
+	for i := 0; i < len(c.fragments); i += 2 {
+		text = strings.ReplaceAll(text, c.fragments[i], c.fragments[i+1])
+	}
+	return text
+}
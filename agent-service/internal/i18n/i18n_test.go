@@ -0,0 +1,58 @@
+package i18n
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+)
+
+// TestNegotiate — ?lang= важнее X-Language, тот важнее Accept-Language.
+func TestNegotiate(t *testing.T) {
+	cases := []struct {
+		url, xLang, accept, want string
+	}{
+		{"/chat", "", "", Russian},
+		{"/chat", "", "de-DE, en-US;q=0.8, ru;q=0.5", English},
+		{"/chat", "", "en;q=0.3, ru-RU", Russian},
+		{"/chat", "en", "ru", English},
+		{"/chat?lang=ru", "en", "en", Russian},
+		{"/chat?lang=fr", "", "en-GB", English},
+	}
+	for _, c := range cases {
+		r := httptest.NewRequest("GET", c.url, nil)
+		if c.xLang != "" {
+			r.Header.Set("X-Language", c.xLang)
+		}
+		if c.accept != "" {
+			r.Header.Set("Accept-Language", c.accept)
+		}
+		if got := Negotiate(r); got != c.want {
+			t.Errorf("%s X-Language=%q Accept-Language=%q: %s, ожидался %s", c.url, c.xLang, c.accept, got, c.want)
+		}
+	}
+
+	SetDefault("en")
+	defer SetDefault(Russian)
+	if Negotiate(httptest.NewRequest("GET", "/chat", nil)) != English || FromContext(context.Background()) != English {
+		t.Error("без выбора — язык по умолчанию")
+	}
+}
+
+// TestTranslate — точный текст, шаблон со значениями, фрагмент внутри текста.
+func TestTranslate(t *testing.T) {
+	cases := map[string]string{
+		"Агент не найден": "Agent not found",
+		"В пакете 120 запросов, допустимо не больше 100":                             "The batch has 120 requests, at most 100 are allowed",
+		"Некорректный бюджет: Агент не найден":                                       "Invalid budget: Agent not found",
+		"Неверный API-ключ. Проверьте настройки провайдера. (HTTP 401: invalid key)": "Invalid API key. Check the provider settings. (HTTP 401: invalid key)",
+		"Текст без перевода":                                                         "Текст без перевода",
+	}
+	for in, want := range cases {
+		if got := Translate(English, in); got != want {
+			t.Errorf("%q: %q, ожидалось %q", in, got, want)
+		}
+		if got := Translate(Russian, in); got != in {
+			t.Errorf("русский не переводится: %q", got)
+		}
+	}
+}
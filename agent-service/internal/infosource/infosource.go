@@ -0,0 +1,149 @@
+// Package infosource — внешние источники данных для интентов погоды и
+// новостей: ответ собирается из открытого API и форматируется локально,
+// без вызова LLM.
+//
+// Источники подключаются по бэкенду (WEATHER_BACKEND, NEWS_BACKEND): пустой
+// бэкенд — интент в этой установке выключен. Новые источники добавляются
+// реализацией WeatherSource или NewsSource и веткой в NewWeatherSource или
+// NewNewsSource.
+package infosource
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/neo-2022/openclaw-memory/agent-service/internal/httpclient"
+)
+
+// Бэкенды источников.
+const (
+	BackendOpenMeteo = "open_meteo" // Погода: Open-Meteo (без ключа API)
+	BackendRSS       = "rss"        // Новости: RSS- и Atom-ленты из NEWS_FEEDS
+)
+
+// ErrDisabled — бэкенд не настроен.
+var ErrDisabled = errors.New("источник не настроен")
+
+// ErrNotFound — место не найдено.
+var ErrNotFound = errors.New("место не найдено")
+
+// Settings — параметры источников (см. WEATHER_* и NEWS_* в config).
+type Settings struct {
+	WeatherBackend string
+	WeatherURL     string // Базовый URL API прогноза
+	GeocodingURL   string // Базовый URL API поиска мест
+	WeatherPlace   string // Место по умолчанию для «какая погода?» без города
+	Language       string // Язык названий мест (ru)
+	NewsBackend    string
+	NewsFeeds      []string // URL лент
+}
+
+// WeatherSource — текущая погода и прогноз по названию места.
+type WeatherSource interface {
+	Weather(ctx context.Context, place string) (*Weather, error)
+}
+
+// NewsSource — свежие новости из лент; пустой запрос — все новости.
+type NewsSource interface {
+	News(ctx context.Context, query string, limit int) ([]NewsItem, error)
+}
+
+// Weather — погода в месте.
+type Weather struct {
+	Place       string
+	Country     string
+	Temperature float64 // °C
+	FeelsLike   float64 // °C
+	Humidity    int     // %
+	WindSpeed   float64 // м/с
+	Code        int     // Код погоды WMO
+	Days        []DayForecast
+}
+
+// DayForecast — прогноз на день.
+type DayForecast struct {
+	Date          time.Time
+	Min, Max      float64 // °C
+	Precipitation float64 // мм
+	Code          int
+}
+
+// NewsItem — новость из ленты.
+type NewsItem struct {
+	Title     string
+	Link      string
+	Feed      string // Название ленты
+	Published time.Time
+}
+
+var httpClient = httpclient.New("infosource", 15*time.Second)
+
+// NewWeatherSource — источник погоды по WEATHER_BACKEND; ErrDisabled, если бэкенд не задан.
+func NewWeatherSource(s Settings) (WeatherSource, error) {
+	switch s.WeatherBackend {
+	case "":
+		return nil, ErrDisabled
+	case BackendOpenMeteo:
+		return &openMeteo{
+			forecastURL:  orDefault(s.WeatherURL, "https://api.open-meteo.com"),
+			geocodingURL: orDefault(s.GeocodingURL, "https://geocoding-api.open-meteo.com"),
+			language:     orDefault(s.Language, "ru"),
+		}, nil
+	}
+	return nil, fmt.Errorf("неизвестный WEATHER_BACKEND %q", s.WeatherBackend)
+}
+
+// NewNewsSource — источник новостей по NEWS_BACKEND; ErrDisabled, если бэкенд не задан.
+func NewNewsSource(s Settings) (NewsSource, error) {
+	switch s.NewsBackend {
+	case "":
+		return nil, ErrDisabled
+	case BackendRSS:
+		if len(s.NewsFeeds) == 0 {
+			return nil, errors.New("NEWS_FEEDS обязателен для NEWS_BACKEND=rss")
+		}
+		return newRSS(s.NewsFeeds), nil
+	}
+	return nil, fmt.Errorf("неизвестный NEWS_BACKEND %q", s.NewsBackend)
+}
+
+func orDefault(v, def string) string {
+	if v == "" {
+		return def
+	}
+	return v
+}
+
+// getJSON — GET с разбором JSON-ответа.
+func getJSON(ctx context.Context, url string, out interface{}) error {
+	body, err := get(ctx, url)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+	return json.NewDecoder(body).Decode(out)
+}
+
+// get — GET; тело ответа закрывает вызывающий.
+func get(ctx context.Context, url string) (io.ReadCloser, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 256))
+		resp.Body.Close()
+		return nil, fmt.Errorf("%s: HTTP %d: %s", url, resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+	return resp.Body, nil
+}
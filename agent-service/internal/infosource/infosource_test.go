@@ -0,0 +1,112 @@
+package infosource
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// TestOpenMeteo — место ищется в начальной форме после «в», прогноз
+// форматируется локально.
+func TestOpenMeteo(t *testing.T) {
+	var searches []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/search":
+			searches = append(searches, r.URL.Query().Get("name"))
+			if r.URL.Query().Get("name") != "москва" {
+				io.WriteString(w, `{}`)
+				return
+			}
+			io.WriteString(w, `{"results":[{"name":"Москва","country":"Россия","latitude":55.75,"longitude":37.62}]}`)
+		case "/v1/forecast":
+			if r.URL.Query().Get("latitude") != "55.75" || r.URL.Query().Get("wind_speed_unit") != "ms" {
+				t.Errorf("запрос прогноза: %s", r.URL.RawQuery)
+			}
+			io.WriteString(w, `{"current":{"temperature_2m":4.6,"apparent_temperature":-0.4,"relative_humidity_2m":81,"wind_speed_10m":3.2,"weather_code":3},`+
+				`"daily":{"time":["2026-10-18","2026-10-19"],"weather_code":[3,63],"temperature_2m_max":[6.1,5],"temperature_2m_min":[1.2,-1.5],"precipitation_sum":[0,2.34]}}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+	src, err := NewWeatherSource(Settings{WeatherBackend: BackendOpenMeteo, WeatherURL: srv.URL, GeocodingURL: srv.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	w, err := src.Weather(context.Background(), "москве")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(searches, ",") != "москве,москва" {
+		t.Errorf("поиск места: %v", searches)
+	}
+	got := FormatWeather(w, time.Date(2026, time.October, 18, 12, 0, 0, 0, time.Local))
+	want := "Погода — Москва, Россия: +5 °C (ощущается как 0 °C), пасмурно, ветер 3 м/с, влажность 81%.\n" +
+		"Сегодня: +1…+6 °C, пасмурно\nЗавтра: -2…+5 °C, дождь, осадки 2.3 мм"
+	if got != want {
+		t.Errorf("ответ:\n%s\nожидалось:\n%s", got, want)
+	}
+	if _, err := src.Weather(context.Background(), "атлантида"); err == nil || !strings.Contains(err.Error(), ErrNotFound.Error()) {
+		t.Errorf("неизвестное место: %v", err)
+	}
+	if _, err := NewWeatherSource(Settings{}); err != ErrDisabled {
+		t.Errorf("без бэкенда: %v", err)
+	}
+}
+
+const rssFeed = `<?xml version="1.0"?><rss version="2.0"><channel><title>Go Blog</title>
+<item><title>Выпуск Go 1.25</title><link>https://go.dev/blog/go1.25</link><pubDate>Tue, 12 Aug 2026 10:00:00 +0000</pubDate></item>
+<item><title>Kubernetes на выборах архитектуры</title><link>https://go.dev/blog/k8s</link><pubDate>Wed, 13 Aug 2026 10:00:00 +0000</pubDate></item>
+</channel></rss>`
+
+const atomFeed = `<?xml version="1.0"?><feed xmlns="http://www.w3.org/2005/Atom"><title>K8s</title>
+<entry><title>Kubernetes 1.35 released</title><link rel="alternate" href="https://k8s.io/1.35"/><updated>2026-09-01T08:00:00Z</updated></entry>
+</feed>`
+
+// TestRSS — RSS и Atom, фильтр по словам запроса, свежие первыми;
+// недоступная лента пропускается, ленты кэшируются.
+func TestRSS(t *testing.T) {
+	var hits atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		switch r.URL.Path {
+		case "/rss":
+			io.WriteString(w, rssFeed)
+		case "/atom":
+			io.WriteString(w, atomFeed)
+		default:
+			http.Error(w, "нет", http.StatusBadGateway)
+		}
+	}))
+	defer srv.Close()
+	src, err := NewNewsSource(Settings{NewsBackend: BackendRSS, NewsFeeds: []string{srv.URL + "/rss", srv.URL + "/atom", srv.URL + "/down"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	items, err := src.News(context.Background(), "kubernetes", 5)
+	if err != nil || len(items) != 2 || items[0].Link != "https://k8s.io/1.35" || items[1].Feed != "Go Blog" {
+		t.Fatalf("новости: %+v, %v", items, err)
+	}
+	if items, _ := src.News(context.Background(), "выборы", 5); len(items) != 1 {
+		t.Errorf("по основе слова: %+v", items)
+	}
+	if items, _ := src.News(context.Background(), "", 2); len(items) != 2 || items[0].Title != "Kubernetes 1.35 released" {
+		t.Errorf("все новости: %+v", items)
+	}
+	if n := hits.Load(); n != 5 { // rss и atom — один раз, недоступная лента — при каждом запросе
+		t.Errorf("запросов к лентам: %d", n)
+	}
+	got := FormatNews("kubernetes", items[:1], time.UTC)
+	if got != "Новости по запросу «kubernetes»:\n1. Kubernetes 1.35 released (K8s, 01.09 08:00)\n   https://k8s.io/1.35" {
+		t.Errorf("ответ:\n%s", got)
+	}
+	if _, err := NewNewsSource(Settings{NewsBackend: BackendRSS}); err == nil {
+		t.Error("rss без лент должен давать ошибку")
+	}
+}
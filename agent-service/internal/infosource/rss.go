@@ -0,0 +1,220 @@
+package infosource
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"log/slog"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// feedTTL — сколько лента хранится в памяти до повторной загрузки.
+const feedTTL = 10 * time.Minute
+
+// maxFeedBytes — предел размера ленты.
+const maxFeedBytes = 5 << 20
+
+// rss — новости из RSS 2.0 и Atom лент; ленты загружаются не чаще feedTTL.
+type rss struct {
+	feeds []string
+	mu    sync.Mutex
+	cache map[string]cachedFeed
+}
+
+type cachedFeed struct {
+	items   []NewsItem
+	fetched time.Time
+}
+
+func newRSS(feeds []string) *rss {
+	return &rss{feeds: feeds, cache: map[string]cachedFeed{}}
+}
+
+// News — новости всех лент, в заголовке которых есть все слова запроса
+// (по основе слова, см. stem), самые свежие первыми. Недоступная лента
+// пропускается; ошибка — только если не загрузилась ни одна.
+func (r *rss) News(ctx context.Context, query string, limit int) ([]NewsItem, error) {
+	words := strings.Fields(strings.ToLower(query))
+	for i, w := range words {
+		words[i] = stem(w)
+	}
+	var all []NewsItem
+	var lastErr error
+	loaded := 0
+	for _, url := range r.feeds {
+		items, err := r.feed(ctx, url)
+		if err != nil {
+			slog.Warn("Лента новостей недоступна", slog.String("url", url), slog.String("ошибка", err.Error()))
+			lastErr = err
+			continue
+		}
+		loaded++
+		for _, it := range items {
+			if matchesAll(strings.ToLower(it.Title), words) {
+				all = append(all, it)
+			}
+		}
+	}
+	if loaded == 0 && lastErr != nil {
+		return nil, lastErr
+	}
+	sort.SliceStable(all, func(i, j int) bool { return all[i].Published.After(all[j].Published) })
+	if limit > 0 && len(all) > limit {
+		all = all[:limit]
+	}
+	return all, nil
+}
+
+// stem — грубая основа слова без окончания, чтобы «выборы» находило
+// «выборах»: у длинных слов отбрасываются две последние буквы, у коротких — одна.
+func stem(w string) string {
+	r := []rune(w)
+	switch {
+	case len(r) >= 6:
+		return string(r[:len(r)-2])
+	case len(r) >= 4:
+		return string(r[:len(r)-1])
+	}
+	return w
+}
+
+func matchesAll(s string, words []string) bool {
+	for _, w := range words {
+		if !strings.Contains(s, w) {
+			return false
+		}
+	}
+	return true
+}
+
+// feed — элементы ленты из кэша или загруженные заново.
+func (r *rss) feed(ctx context.Context, url string) ([]NewsItem, error) {
+	r.mu.Lock()
+	c, ok := r.cache[url]
+	r.mu.Unlock()
+	if ok && time.Since(c.fetched) < feedTTL {
+		return c.items, nil
+	}
+	body, err := get(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+	items, err := ParseFeed(io.LimitReader(body, maxFeedBytes))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", url, err)
+	}
+	r.mu.Lock()
+	r.cache[url] = cachedFeed{items: items, fetched: time.Now()}
+	r.mu.Unlock()
+	return items, nil
+}
+
+// ParseFeed — элементы RSS 2.0 или Atom ленты.
+func ParseFeed(rd io.Reader) ([]NewsItem, error) {
+	var doc struct {
+		XMLName xml.Name
+		// RSS 2.0
+		Channel struct {
+			Title string `xml:"title"`
+			Items []struct {
+				Title   string `xml:"title"`
+				Link    string `xml:"link"`
+				PubDate string `xml:"pubDate"`
+			} `xml:"item"`
+		} `xml:"channel"`
+		// Atom
+		Title   string `xml:"title"`
+		Entries []struct {
+			Title string `xml:"title"`
+			Link  []struct {
+				Href string `xml:"href,attr"`
+				Rel  string `xml:"rel,attr"`
+			} `xml:"link"`
+			Updated   string `xml:"updated"`
+			Published string `xml:"published"`
+		} `xml:"entry"`
+	}
+	dec := xml.NewDecoder(rd)
+	dec.Strict = false
+	if err := dec.Decode(&doc); err != nil {
+		return nil, fmt.Errorf("разбор ленты: %w", err)
+	}
+	var items []NewsItem
+	switch doc.XMLName.Local {
+	case "rss":
+		for _, it := range doc.Channel.Items {
+			items = append(items, NewsItem{Title: clean(it.Title), Link: strings.TrimSpace(it.Link), Feed: clean(doc.Channel.Title), Published: parseTime(it.PubDate)})
+		}
+	case "feed":
+		for _, e := range doc.Entries {
+			item := NewsItem{Title: clean(e.Title), Feed: clean(doc.Title), Published: parseTime(e.Published)}
+			if item.Published.IsZero() {
+				item.Published = parseTime(e.Updated)
+			}
+			for _, l := range e.Link {
+				if l.Rel == "" || l.Rel == "alternate" {
+					item.Link = l.Href
+					break
+				}
+			}
+			items = append(items, item)
+		}
+	default:
+		return nil, fmt.Errorf("не RSS и не Atom: <%s>", doc.XMLName.Local)
+	}
+	return items, nil
+}
+
+// clean — текст без лишних пробелов и переводов строк.
+func clean(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// parseTime — дата публикации в форматах RSS (RFC 1123) и Atom (RFC 3339).
+func parseTime(s string) time.Time {
+	s = strings.TrimSpace(s)
+	for _, layout := range []string{time.RFC1123Z, time.RFC1123, time.RFC3339, "Mon, 2 Jan 2006 15:04:05 -0700", "Mon, 2 Jan 2006 15:04:05 MST"} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// FormatNews — ответ со списком новостей.
+func FormatNews(query string, items []NewsItem, loc *time.Location) string {
+	if len(items) == 0 {
+		if query == "" {
+			return "В лентах новостей пока ничего нет."
+		}
+		return fmt.Sprintf("Свежих новостей по запросу «%s» в лентах нет.", query)
+	}
+	var sb strings.Builder
+	if query == "" {
+		sb.WriteString("Последние новости:")
+	} else {
+		fmt.Fprintf(&sb, "Новости по запросу «%s»:", query)
+	}
+	for i, it := range items {
+		fmt.Fprintf(&sb, "\n%d. %s", i+1, it.Title)
+		var meta []string
+		if it.Feed != "" {
+			meta = append(meta, it.Feed)
+		}
+		if !it.Published.IsZero() {
+			meta = append(meta, it.Published.In(loc).Format("02.01 15:04"))
+		}
+		if len(meta) > 0 {
+			fmt.Fprintf(&sb, " (%s)", strings.Join(meta, ", "))
+		}
+		if it.Link != "" {
+			sb.WriteString("\n   " + it.Link)
+		}
+	}
+	return sb.String()
+}
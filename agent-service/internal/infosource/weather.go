@@ -0,0 +1,178 @@
+package infosource
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// openMeteo — Open-Meteo: поиск места (geocoding API) и прогноз (forecast API).
+type openMeteo struct {
+	forecastURL  string
+	geocodingURL string
+	language     string
+}
+
+// geoPlace — место из geocoding API.
+type geoPlace struct {
+	Name      string  `json:"name"`
+	Country   string  `json:"country"`
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+}
+
+func (o *openMeteo) Weather(ctx context.Context, place string) (*Weather, error) {
+	geo, err := o.locate(ctx, place)
+	if err != nil {
+		return nil, err
+	}
+	q := url.Values{}
+	q.Set("latitude", fmt.Sprint(geo.Latitude))
+	q.Set("longitude", fmt.Sprint(geo.Longitude))
+	q.Set("current", "temperature_2m,apparent_temperature,relative_humidity_2m,wind_speed_10m,weather_code")
+	q.Set("daily", "weather_code,temperature_2m_max,temperature_2m_min,precipitation_sum")
+	q.Set("wind_speed_unit", "ms")
+	q.Set("timezone", "auto")
+	q.Set("forecast_days", "3")
+	var out struct {
+		Current struct {
+			Temperature float64 `json:"temperature_2m"`
+			FeelsLike   float64 `json:"apparent_temperature"`
+			Humidity    float64 `json:"relative_humidity_2m"`
+			WindSpeed   float64 `json:"wind_speed_10m"`
+			Code        int     `json:"weather_code"`
+		} `json:"current"`
+		Daily struct {
+			Time          []string  `json:"time"`
+			Code          []int     `json:"weather_code"`
+			Max           []float64 `json:"temperature_2m_max"`
+			Min           []float64 `json:"temperature_2m_min"`
+			Precipitation []float64 `json:"precipitation_sum"`
+		} `json:"daily"`
+	}
+	if err := getJSON(ctx, strings.TrimRight(o.forecastURL, "/")+"/v1/forecast?"+q.Encode(), &out); err != nil {
+		return nil, fmt.Errorf("прогноз погоды: %w", err)
+	}
+	w := &Weather{
+		Place:       geo.Name,
+		Country:     geo.Country,
+		Temperature: out.Current.Temperature,
+		FeelsLike:   out.Current.FeelsLike,
+		Humidity:    int(math.Round(out.Current.Humidity)),
+		WindSpeed:   out.Current.WindSpeed,
+		Code:        out.Current.Code,
+	}
+	d := out.Daily
+	for i, day := range d.Time {
+		date, err := time.Parse("2006-01-02", day)
+		if err != nil || i >= len(d.Code) || i >= len(d.Max) || i >= len(d.Min) {
+			continue
+		}
+		f := DayForecast{Date: date, Min: d.Min[i], Max: d.Max[i], Code: d.Code[i]}
+		if i < len(d.Precipitation) {
+			f.Precipitation = d.Precipitation[i]
+		}
+		w.Days = append(w.Days, f)
+	}
+	return w, nil
+}
+
+// locate — первое найденное место; название перебирается в начальной форме
+// («в Москве» → «Москве», «Москва»).
+func (o *openMeteo) locate(ctx context.Context, place string) (*geoPlace, error) {
+	for _, name := range placeCandidates(place) {
+		q := url.Values{}
+		q.Set("name", name)
+		q.Set("count", "1")
+		q.Set("language", o.language)
+		var out struct {
+			Results []geoPlace `json:"results"`
+		}
+		if err := getJSON(ctx, strings.TrimRight(o.geocodingURL, "/")+"/v1/search?"+q.Encode(), &out); err != nil {
+			return nil, fmt.Errorf("поиск места: %w", err)
+		}
+		if len(out.Results) > 0 {
+			return &out.Results[0], nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %s", ErrNotFound, place)
+}
+
+// placeCandidates — название места и его вероятные начальные формы после
+// предлога «в»: Москве → Москва, Берлине → Берлин, Казани → Казань.
+func placeCandidates(place string) []string {
+	place = strings.TrimSpace(place)
+	out := []string{place}
+	r := []rune(place)
+	if len(r) < 4 {
+		return out
+	}
+	stem := string(r[:len(r)-1])
+	switch r[len(r)-1] {
+	case 'е':
+		out = append(out, stem+"а", stem, stem+"я")
+	case 'и':
+		out = append(out, stem+"ь", stem+"я")
+	}
+	return out
+}
+
+// weatherCodes — описания кодов погоды WMO (как в Open-Meteo).
+var weatherCodes = map[int]string{
+	0: "ясно", 1: "преимущественно ясно", 2: "переменная облачность", 3: "пасмурно",
+	45: "туман", 48: "изморозь",
+	51: "слабая морось", 53: "морось", 55: "сильная морось", 56: "ледяная морось", 57: "сильная ледяная морось",
+	61: "небольшой дождь", 63: "дождь", 65: "сильный дождь", 66: "ледяной дождь", 67: "сильный ледяной дождь",
+	71: "небольшой снег", 73: "снег", 75: "сильный снег", 77: "снежные зёрна",
+	80: "ливень", 81: "сильный ливень", 82: "очень сильный ливень",
+	85: "снегопад", 86: "сильный снегопад",
+	95: "гроза", 96: "гроза с градом", 99: "сильная гроза с градом",
+}
+
+// Describe — описание кода погоды WMO.
+func Describe(code int) string {
+	if s, ok := weatherCodes[code]; ok {
+		return s
+	}
+	return fmt.Sprintf("код погоды %d", code)
+}
+
+// FormatWeather — ответ о погоде: сейчас и прогноз по дням.
+func FormatWeather(w *Weather, now time.Time) string {
+	var sb strings.Builder
+	place := w.Place
+	if w.Country != "" {
+		place += ", " + w.Country
+	}
+	fmt.Fprintf(&sb, "Погода — %s: %s °C (ощущается как %s °C), %s, ветер %.0f м/с, влажность %d%%.",
+		place, temp(w.Temperature), temp(w.FeelsLike), Describe(w.Code), w.WindSpeed, w.Humidity)
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	for _, d := range w.Days {
+		var day string
+		switch int(math.Round(d.Date.Sub(today).Hours() / 24)) {
+		case 0:
+			day = "Сегодня"
+		case 1:
+			day = "Завтра"
+		default:
+			day = d.Date.Format("02.01")
+		}
+		fmt.Fprintf(&sb, "\n%s: %s…%s °C, %s", day, temp(d.Min), temp(d.Max), Describe(d.Code))
+		if d.Precipitation > 0 {
+			fmt.Fprintf(&sb, ", осадки %.1f мм", d.Precipitation)
+		}
+	}
+	return sb.String()
+}
+
+// temp — температура со знаком: +5, -3, 0.
+func temp(t float64) string {
+	n := int(math.Round(t))
+	if n > 0 {
+		return fmt.Sprintf("+%d", n)
+	}
+	return fmt.Sprint(n)
+}
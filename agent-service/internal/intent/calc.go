@@ -0,0 +1,123 @@
+package intent
+
+import (
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/neo-2022/openclaw-memory/agent-service/internal/calc"
+)
+
+// Интенты детерминированных вычислений: ответ считается на месте, без LLM.
+const (
+	IntentCalculate    = "CALCULATE"
+	IntentConvertUnits = "CONVERT_UNITS"
+	IntentDateTime     = "DATE_TIME"
+)
+
+// Операции интента DATE_TIME (параметр op).
+const (
+	DateOpTimeIn  = "time_in" // Время в часовом поясе: place
+	DateOpShift   = "shift"   // Дата через n единиц unit от date (пусто — сейчас)
+	DateOpDays    = "days"    // Дней от date до date2 (пусто — сегодня)
+	DateOpWeekday = "weekday" // День недели date
+)
+
+// datePattern — дата в сообщении (см. calc.ParseDate).
+const datePattern = `(\d{4}-\d{1,2}-\d{1,2}|\d{1,2}\.\d{1,2}(?:\.\d{4})?|нов(?:ый|ого)\s+год(?:а)?|new\s+year)`
+
+var (
+	reCalcPrefix  = regexp.MustCompile(`^(?:посчитай|вычисли|сосчитай|сколько\s+будет|чему\s+равно|calc|calculate|compute|what\s+is|what's)\s*:?\s*(.+)$`)
+	reCalcPercent = regexp.MustCompile(`^(\d+(?:[.,]\d+)?)\s*%\s*(?:от|of)\s+(.+)$`)
+	// Без слова-команды выражение распознаётся, только если в нём есть
+	// операция, кроме минуса: «2026-03-08» и «8-800-555» — не выражения
+	reCalcOps = regexp.MustCompile(`[+*/^×÷(]`)
+	reISODate = regexp.MustCompile(`\d{4}-\d{1,2}-\d{1,2}`)
+
+	reConvert = regexp.MustCompile(`^(?:переведи|конвертируй|convert|сколько(?:\s+будет)?)?\s*(-?\d+(?:[.,]\d+)?)\s*(.+?)\s+(?:в|во|to|in)\s+(.+)$`)
+
+	reTimeIn   = regexp.MustCompile(`^(?:который\s+(?:сейчас\s+)?час|сколько\s+(?:сейчас\s+)?времени|(?:текущее\s+)?время|what\s+time\s+is\s+it|time)\s+(?:в|во|in)\s+(.+)$`)
+	reWeekday  = regexp.MustCompile(`^(?:какой\s+день\s+недели|what\s+day\s+(?:of\s+the\s+week\s+)?(?:is|was|will\s+be))\s+(?:был\s+|будет\s+)?` + datePattern + `$`)
+	reDaysTo   = regexp.MustCompile(`^(?:сколько\s+)?(?:дней\s+(?:осталось\s+)?до|days\s+(?:until|till|to))\s+` + datePattern + `$`)
+	reDaysFrom = regexp.MustCompile(`^(?:сколько\s+)?(?:дней\s+(?:прошло\s+)?(?:с|со|от)|days\s+since)\s+` + datePattern + `$`)
+	reDaysDiff = regexp.MustCompile(`^(?:сколько\s+)?(?:дней\s+между|days\s+between)\s+` + datePattern + `\s+(?:и|and)\s+` + datePattern + `$`)
+	// Вопрос перед сдвигом даты: «какая дата будет через 45 дней»
+	reShiftPrefix = regexp.MustCompile(`^(?:какая\s+(?:будет\s+|была\s+)?дата|какое\s+(?:будет\s+|было\s+)?число|какой\s+(?:будет\s+|был\s+)?день(?:\s+недели)?|что\s+за\s+дата|what\s+(?:date|day)\s+(?:is\s+it|will\s+it\s+be|was\s+it))\s+(?:будет\s+|была\s+|было\s+|был\s+)?`)
+	reShiftIn     = regexp.MustCompile(`^(?:через|in)\s+(\d+)\s+(\S+)$`)
+	reShiftAgo    = regexp.MustCompile(`^(\d+)\s+(\S+)\s+(?:назад|ago)$`)
+	reShiftFrom   = regexp.MustCompile(`^` + datePattern + `\s*([+-])\s*(\d+)\s*(\S+)$`)
+)
+
+// calcInput — сообщение без завершающих «?», «=» и точки.
+func calcInput(msg string) string {
+	return strings.TrimSpace(strings.TrimRight(msg, " ?!.="))
+}
+
+func matchCalculate(msg string) (Params, bool) {
+	expr, prefixed := calcInput(msg), false
+	if m := reCalcPrefix.FindStringSubmatch(expr); m != nil {
+		expr, prefixed = m[1], true
+	}
+	text := expr
+	if m := reCalcPercent.FindStringSubmatch(expr); m != nil {
+		expr = "(" + m[2] + ")*" + m[1] + "/100"
+		prefixed = true
+	}
+	if !prefixed && !reCalcOps.MatchString(expr) || !strings.ContainsAny(expr, "0123456789") || reISODate.MatchString(expr) {
+		return nil, false
+	}
+	if _, err := calc.Eval(expr); err != nil {
+		return nil, false
+	}
+	return Params{"expr": expr, "text": text}, true
+}
+
+func matchConvertUnits(msg string) (Params, bool) {
+	m := reConvert.FindStringSubmatch(calcInput(msg))
+	if m == nil || !calc.IsUnit(m[2]) || !calc.IsUnit(m[3]) {
+		return nil, false
+	}
+	return Params{"value": m[1], "from": m[2], "to": m[3]}, true
+}
+
+func matchDateTime(msg string) (Params, bool) {
+	s := calcInput(msg)
+	if m := reTimeIn.FindStringSubmatch(s); m != nil {
+		if _, err := calc.Location(m[1]); err == nil {
+			return Params{"op": DateOpTimeIn, "place": m[1]}, true
+		}
+		return nil, false
+	}
+	now := time.Now()
+	valid := func(dates ...string) bool {
+		for _, d := range dates {
+			if _, err := calc.ParseDate(d, now); err != nil {
+				return false
+			}
+		}
+		return true
+	}
+	if m := reWeekday.FindStringSubmatch(s); m != nil && valid(m[1]) {
+		return Params{"op": DateOpWeekday, "date": m[1]}, true
+	}
+	if m := reDaysTo.FindStringSubmatch(s); m != nil && valid(m[1]) {
+		return Params{"op": DateOpDays, "date2": m[1]}, true
+	}
+	if m := reDaysFrom.FindStringSubmatch(s); m != nil && valid(m[1]) {
+		return Params{"op": DateOpDays, "date": m[1]}, true
+	}
+	if m := reDaysDiff.FindStringSubmatch(s); m != nil && valid(m[1], m[2]) {
+		return Params{"op": DateOpDays, "date": m[1], "date2": m[2]}, true
+	}
+	s = reShiftPrefix.ReplaceAllString(s, "")
+	if m := reShiftIn.FindStringSubmatch(s); m != nil && calc.IsShiftUnit(m[2]) {
+		return Params{"op": DateOpShift, "n": m[1], "unit": m[2]}, true
+	}
+	if m := reShiftAgo.FindStringSubmatch(s); m != nil && calc.IsShiftUnit(m[2]) {
+		return Params{"op": DateOpShift, "n": "-" + m[1], "unit": m[2]}, true
+	}
+	if m := reShiftFrom.FindStringSubmatch(s); m != nil && calc.IsShiftUnit(m[4]) && valid(m[1]) {
+		return Params{"op": DateOpShift, "date": m[1], "n": strings.TrimPrefix(m[2], "+") + m[3], "unit": m[4]}, true
+	}
+	return nil, false
+}
@@ -19,53 +19,112 @@ const (
 // Params содержит параметры, извлечённые из интента
 type Params map[string]string
 
-// DetectIntent анализирует сообщение пользователя и возвращает тип интента и параметры
-func DetectIntent(msg string) (string, Params) {
-	msgLower := strings.ToLower(msg)
-	msgLower = strings.TrimSpace(msgLower)
+// Matcher — распознаватель интента. Получает сообщение в нижнем регистре
+// без краевых пробелов; возвращает параметры и признак совпадения.
+type Matcher func(msg string) (Params, bool)
 
-	// Запомни факт: "запомни: ..." или "сохрани: ..."
-	if match := regexp.MustCompile(`^(?:запомни|сохрани|запиши)\s*:\s*(.+)`).FindStringSubmatch(msgLower); match != nil {
-		return IntentRememberFact, Params{"fact": strings.TrimSpace(match[1])}
-	}
+// Builtin — встроенный интент: имя, описание и распознаватель.
+// Обработчики встроенных интентов регистрируются в пакете handlers.
+type Builtin struct {
+	Name        string
+	Description string
+	Match       Matcher
+}
 
-	// Информация о железе – теперь более гибкое, учитывает возможные предшествующие слова
-	if regexp.MustCompile(`(характеристик[иа]|информаци[юя]|что за|какие|все)\s+(железо|пк|компьютер|систем[еы]|оборудование)`).MatchString(msgLower) {
-		return IntentHardwareInfo, nil
-	}
+var (
+	reRememberFact = regexp.MustCompile(`^(?:запомни|сохрани|запиши)\s*:\s*(.+)`)
+	// Информация о железе — учитывает возможные предшествующие слова
+	reHardwareInfo   = regexp.MustCompile(`(характеристик[иа]|информаци[юя]|что за|какие|все)\s+(железо|пк|компьютер|систем[еы]|оборудование)`)
+	reAddToAutostart = regexp.MustCompile(`(?:добавь|помести|положи)\s+(?:приложение|программу)?\s*(.+)\s+(?:в|во|на)\s+(?:автозагрузк[уа]|автозапуск)`)
+	reAddSynonym     = regexp.MustCompile(`^(?:добавь\s+)?синоним\s+([^\s]+)\s+([^\s]+)`)
+	reOpenApp        = regexp.MustCompile(`(?:открой|запусти|открыть|запустить)\s+([а-яa-z0-9\-]+)`)
 
-	// Добавить в автозагрузку
-	if match := regexp.MustCompile(`(?:добавь|помести|положи)\s+(?:приложение|программу)?\s*(.+)\s+(?:в|во|на)\s+(?:автозагрузк[уа]|автозапуск)`).FindStringSubmatch(msgLower); match != nil {
-		return IntentAddToAutostart, Params{"app": strings.TrimSpace(match[1])}
+	// Папки: порядок важен — частные случаи раньше общего «открой папку»
+	folderPatterns = []struct {
+		re     *regexp.Regexp
+		folder string
+	}{
+		{regexp.MustCompile(`(?:открой|открыть)\s+(автозапуск|автозагрузк[ау])`), "autostart"},
+		{regexp.MustCompile(`(?:открой|открыть)\s+(?:папку|директорию|каталог)\s*(?:загрузки|downloads|загрузок)`), "downloads"},
+		{regexp.MustCompile(`(?:открой|открыть)\s+(?:домашнюю|home|личную)\s*(?:папку|директорию)?`), "home"},
+		{regexp.MustCompile(`(?:открой|открыть)\s+(?:корневую|корень|root)\s*(?:папку|директорию)?`), "root"},
+		{regexp.MustCompile(`(?:открой|открыть)\s+папку`), "unspecified"},
 	}
+)
 
-	// Добавить синоним
-	if match := regexp.MustCompile(`^(?:добавь\s+)?синоним\s+([^\s]+)\s+([^\s]+)`).FindStringSubmatch(msgLower); match != nil {
-		return IntentAddSynonym, Params{"wrong": match[1], "right": match[2]}
+// Builtins — встроенные интенты в порядке проверки.
+// Открытие папки проверяется ПЕРЕД открытием приложения,
+// иначе regex приложения перехватит "открой папку" как app="папку".
+func Builtins() []Builtin {
+	return []Builtin{
+		{IntentRememberFact, "Запомнить факт: «запомни: ...»", matchRememberFact},
+		{IntentHardwareInfo, "Характеристики компьютера", matchHardwareInfo},
+		{IntentAddToAutostart, "Добавить приложение в автозагрузку", matchAddToAutostart},
+		{IntentAddSynonym, "Добавить синоним: «синоним неверно верно»", matchAddSynonym},
+		{IntentOpenFolder, "Открыть папку (загрузки, домашняя, корень, автозапуск)", matchOpenFolder},
+		{IntentOpenApp, "Открыть или запустить приложение", matchOpenApp},
+		{IntentCalculate, "Калькулятор: «сколько будет (2+3)*4», «15% от 200»", matchCalculate},
+		{IntentConvertUnits, "Перевод единиц: «переведи 5 км в мили», «100 f в c»", matchConvertUnits},
+		{IntentDateTime, "Даты и время: «через 45 дней», «сколько дней до 31.12», «который час в Токио»", matchDateTime},
+		{IntentWeather, "Погода и прогноз на 3 дня: «погода в Москве» (WEATHER_BACKEND)", matchWeather},
+		{IntentNews, "Новости из RSS-лент: «новости про kubernetes» (NEWS_BACKEND)", matchNews},
 	}
+}
 
-	// Открыть папку — проверяем ПЕРЕД открытием приложения,
-	// иначе regex приложения перехватит "открой папку" как app="папку"
-	if regexp.MustCompile(`(?:открой|открыть)\s+(автозапуск|автозагрузк[ау])`).MatchString(msgLower) {
-		return IntentOpenFolder, Params{"folder": "autostart"}
-	}
-	if regexp.MustCompile(`(?:открой|открыть)\s+(?:папку|директорию|каталог)\s*(?:загрузки|downloads|загрузок)`).MatchString(msgLower) {
-		return IntentOpenFolder, Params{"folder": "downloads"}
+// DetectIntent анализирует сообщение пользователя и возвращает тип встроенного интента и параметры.
+// Пользовательские интенты и отключение по агентам учитывает Registry.Detect.
+func DetectIntent(msg string) (string, Params) {
+	msgLower := normalize(msg)
+	for _, b := range Builtins() {
+		if params, ok := b.Match(msgLower); ok {
+			return b.Name, params
+		}
 	}
-	if regexp.MustCompile(`(?:открой|открыть)\s+(?:домашнюю|home|личную)\s*(?:папку|директорию)?`).MatchString(msgLower) {
-		return IntentOpenFolder, Params{"folder": "home"}
+	return IntentNone, nil
+}
+
+func normalize(msg string) string {
+	return strings.TrimSpace(strings.ToLower(msg))
+}
+
+func matchRememberFact(msg string) (Params, bool) {
+	if match := reRememberFact.FindStringSubmatch(msg); match != nil {
+		return Params{"fact": strings.TrimSpace(match[1])}, true
 	}
-	if regexp.MustCompile(`(?:открой|открыть)\s+(?:корневую|корень|root)\s*(?:папку|директорию)?`).MatchString(msgLower) {
-		return IntentOpenFolder, Params{"folder": "root"}
+	return nil, false
+}
+
+func matchHardwareInfo(msg string) (Params, bool) {
+	return nil, reHardwareInfo.MatchString(msg)
+}
+
+func matchAddToAutostart(msg string) (Params, bool) {
+	if match := reAddToAutostart.FindStringSubmatch(msg); match != nil {
+		return Params{"app": strings.TrimSpace(match[1])}, true
 	}
-	if regexp.MustCompile(`(?:открой|открыть)\s+папку`).MatchString(msgLower) {
-		return IntentOpenFolder, Params{"folder": "unspecified"}
+	return nil, false
+}
+
+func matchAddSynonym(msg string) (Params, bool) {
+	if match := reAddSynonym.FindStringSubmatch(msg); match != nil {
+		return Params{"wrong": match[1], "right": match[2]}, true
 	}
+	return nil, false
+}
 
-	// Открыть приложение — после папок, чтобы "открой папку" не срабатывало как приложение
-	if match := regexp.MustCompile(`(?:открой|запусти|открыть|запустить)\s+([а-яa-z0-9\-]+)`).FindStringSubmatch(msgLower); match != nil {
-		return IntentOpenApp, Params{"app": match[1]}
+func matchOpenFolder(msg string) (Params, bool) {
+	for _, p := range folderPatterns {
+		if p.re.MatchString(msg) {
+			return Params{"folder": p.folder}, true
+		}
 	}
+	return nil, false
+}
 
-	return IntentNone, nil
+// matchOpenApp — после папок, чтобы "открой папку" не срабатывало как приложение
+func matchOpenApp(msg string) (Params, bool) {
+	if match := reOpenApp.FindStringSubmatch(msg); match != nil {
+		return Params{"app": match[1]}, true
+	}
+	return nil, false
 }
@@ -0,0 +1,43 @@
+package intent
+
+import "regexp"
+
+// Интенты внешних данных: ответ из открытого API без вызова LLM. Регистрируются,
+// только если источник настроен (WEATHER_BACKEND, NEWS_BACKEND).
+const (
+	IntentWeather = "WEATHER"
+	IntentNews    = "NEWS"
+)
+
+var (
+	reWeather = []*regexp.Regexp{
+		regexp.MustCompile(`^(?:какая\s+)?(?:(?:сейчас|сегодня|завтра)\s+)?погода(?:\s+(?:сейчас|сегодня|завтра|на\s+завтра|на\s+выходные))?(?:\s+(?:в|во)\s+(.+))?$`),
+		regexp.MustCompile(`^(?:what's|what\s+is|how's|how\s+is)\s+the\s+weather(?:\s+like)?(?:\s+(?:today|tomorrow))?(?:\s+in\s+(.+))?$`),
+		regexp.MustCompile(`^weather(?:\s+in\s+(.+))?$`),
+	}
+	reNews = []*regexp.Regexp{
+		regexp.MustCompile(`^(?:(?:последние|свежие)\s+)?новости(?:\s+(?:про|о|об|по)\s+(.+))?$`),
+		regexp.MustCompile(`^что\s+нового\s+(?:про|о|об|в)\s+(.+)$`),
+		regexp.MustCompile(`^(?:latest\s+)?news(?:\s+(?:about|on)\s+(.+))?$`),
+	}
+)
+
+// matchWeather — «погода в Москве», «какая погода?» (place пуст — город по умолчанию).
+func matchWeather(msg string) (Params, bool) {
+	return matchAny(reWeather, calcInput(msg), "place")
+}
+
+// matchNews — «новости про kubernetes», «последние новости» (query пуст — все).
+func matchNews(msg string) (Params, bool) {
+	return matchAny(reNews, calcInput(msg), "query")
+}
+
+// matchAny — первый совпавший шаблон; первая группа становится параметром key.
+func matchAny(res []*regexp.Regexp, msg, key string) (Params, bool) {
+	for _, re := range res {
+		if m := re.FindStringSubmatch(msg); m != nil {
+			return Params{key: m[1]}, true
+		}
+	}
+	return nil, false
+}
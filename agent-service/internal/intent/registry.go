@@ -0,0 +1,146 @@
+package intent
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Источники интентов.
+const (
+	SourceBuiltin = "builtin" // Встроенный (пакет handlers)
+	SourceConfig  = "config"  // Из файла INTENTS_FILE
+)
+
+// Приоритеты по умолчанию: меньше — проверяется раньше. Пользовательские
+// интенты проверяются до встроенных, чтобы общий «открой ...» их не перехватывал.
+const (
+	DefaultConfigPriority = 50
+	BuiltinPriority       = 100
+)
+
+// Handler — обработчик интента: возвращает готовый ответ пользователю без вызова LLM.
+type Handler func(Params) (string, error)
+
+// Intent — зарегистрированный интент: распознаватель и обработчик.
+type Intent struct {
+	Name        string  `json:"name"`
+	Description string  `json:"description"`
+	Source      string  `json:"source"`
+	Priority    int     `json:"priority"`
+	Match       Matcher `json:"-"`
+	Handle      Handler `json:"-"`
+}
+
+// ErrDuplicate — интент с таким именем уже зарегистрирован.
+var ErrDuplicate = errors.New("интент уже зарегистрирован")
+
+// Registry — реестр интентов, обрабатываемых до вызова LLM.
+// Безопасен для конкурентного использования.
+type Registry struct {
+	mu      sync.RWMutex
+	intents []Intent // Отсортированы по Priority, при равенстве — по порядку регистрации
+}
+
+// NewRegistry — пустой реестр.
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+// Register — добавляет интент. Имя обязательно и уникально, Match и Handle не nil.
+func (r *Registry) Register(in Intent) error {
+	in.Name = strings.TrimSpace(in.Name)
+	if in.Name == "" {
+		return errors.New("имя интента не задано")
+	}
+	if in.Match == nil || in.Handle == nil {
+		return fmt.Errorf("интент %s: не задан распознаватель или обработчик", in.Name)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, existing := range r.intents {
+		if existing.Name == in.Name {
+			return fmt.Errorf("%w: %s", ErrDuplicate, in.Name)
+		}
+	}
+	r.intents = append(r.intents, in)
+	sort.SliceStable(r.intents, func(i, j int) bool {
+		return r.intents[i].Priority < r.intents[j].Priority
+	})
+	return nil
+}
+
+// Unregister — удаляет интент; false, если его не было.
+func (r *Registry) Unregister(name string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, in := range r.intents {
+		if in.Name == name {
+			r.intents = append(r.intents[:i], r.intents[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// Has — зарегистрирован ли интент.
+func (r *Registry) Has(name string) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, in := range r.intents {
+		if in.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// List — интенты в порядке проверки.
+func (r *Registry) List() []Intent {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return append([]Intent(nil), r.intents...)
+}
+
+// Detect — первый совпавший интент из разрешённых.
+// enabled == nil — разрешены все; иначе проверяются только интенты, для которых enabled(name) == true.
+func (r *Registry) Detect(msg string, enabled func(name string) bool) (Intent, Params, bool) {
+	msgLower := normalize(msg)
+	if msgLower == "" {
+		return Intent{}, nil, false
+	}
+	for _, in := range r.List() {
+		if enabled != nil && !enabled(in.Name) {
+			continue
+		}
+		if params, ok := in.Match(msgLower); ok {
+			return in, params, true
+		}
+	}
+	return Intent{}, nil, false
+}
+
+// ParseNames — список имён интентов из строки через запятую (как хранится у агента).
+func ParseNames(s string) map[string]bool {
+	names := map[string]bool{}
+	for _, n := range strings.Split(s, ",") {
+		if n = strings.TrimSpace(n); n != "" {
+			names[n] = true
+		}
+	}
+	return names
+}
+
+// JoinNames — обратное к ParseNames преобразование, имена по алфавиту.
+func JoinNames(names map[string]bool) string {
+	list := make([]string, 0, len(names))
+	for n, on := range names {
+		if on {
+			list = append(list, n)
+		}
+	}
+	sort.Strings(list)
+	return strings.Join(list, ",")
+}
@@ -0,0 +1,157 @@
+package intent
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func echo(name string) Handler {
+	return func(Params) (string, error) { return name, nil }
+}
+
+// TestRegistry_PriorityAndFilter — интенты проверяются по приоритету, отключённые пропускаются.
+func TestRegistry_PriorityAndFilter(t *testing.T) {
+	r := NewRegistry()
+	for i, b := range Builtins() {
+		if err := r.Register(Intent{Name: b.Name, Priority: BuiltinPriority + i, Match: b.Match, Handle: echo(b.Name)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	in, params, ok := r.Detect("Открой папку загрузки", nil)
+	if !ok || in.Name != IntentOpenFolder || params["folder"] != "downloads" {
+		t.Fatalf("ожидалась папка загрузок, получено %q %v", in.Name, params)
+	}
+
+	noFolders := func(name string) bool { return name != IntentOpenFolder }
+	if in, _, _ := r.Detect("открой папку загрузки", noFolders); in.Name != IntentOpenApp {
+		t.Errorf("при отключённом OPEN_FOLDER ожидался OPEN_APP, получено %q", in.Name)
+	}
+	if _, _, ok := r.Detect("расскажи анекдот", nil); ok {
+		t.Error("обычное сообщение не должно распознаваться как интент")
+	}
+	if err := r.Register(Intent{Name: IntentOpenApp, Match: matchOpenApp, Handle: echo("x")}); !errors.Is(err, ErrDuplicate) {
+		t.Errorf("ожидалась ошибка дубликата, получено %v", err)
+	}
+	if !r.Unregister(IntentOpenApp) || r.Has(IntentOpenApp) {
+		t.Error("интент должен удаляться из реестра")
+	}
+}
+
+// TestSpec_ConfigIntent — пользовательский интент из YAML проверяется раньше встроенных.
+func TestSpec_ConfigIntent(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "intents.yaml")
+	yml := `intents:
+  - name: VPN_HELP
+    description: Инструкция по VPN
+    pattern: 'открой\s+vpn\s*(?P<office>[а-яa-z0-9-]*)'
+    response: 'Инструкция для офиса {office}: https://wiki.local/vpn'
+  - name: BROKEN
+    pattern: '(('
+    response: x
+`
+	if err := os.WriteFile(path, []byte(yml), 0644); err != nil {
+		t.Fatal(err)
+	}
+	specs, err := LoadSpecs(path)
+	if err != nil || len(specs) != 2 {
+		t.Fatalf("LoadSpecs: %v, %d", err, len(specs))
+	}
+
+	r := NewRegistry()
+	if err := r.Register(Intent{Name: IntentOpenApp, Priority: BuiltinPriority, Match: matchOpenApp, Handle: echo(IntentOpenApp)}); err != nil {
+		t.Fatal(err)
+	}
+	n, err := RegisterSpecs(r, specs)
+	if n != 1 || err == nil {
+		t.Fatalf("ожидался 1 интент и ошибка для BROKEN, получено %d, %v", n, err)
+	}
+
+	in, params, ok := r.Detect("Открой VPN Москва", nil)
+	if !ok || in.Name != "VPN_HELP" || in.Source != SourceConfig {
+		t.Fatalf("ожидался VPN_HELP, получено %q", in.Name)
+	}
+	resp, _ := in.Handle(params)
+	if resp != "Инструкция для офиса москва: https://wiki.local/vpn" {
+		t.Errorf("ответ: %q", resp)
+	}
+}
+
+// TestParseNames — список отключённых интентов агента.
+func TestParseNames(t *testing.T) {
+	names := ParseNames(" OPEN_APP, ,HARDWARE_INFO")
+	if !names[IntentOpenApp] || !names[IntentHardwareInfo] || len(names) != 2 {
+		t.Fatalf("ParseNames: %v", names)
+	}
+	names[IntentOpenApp] = false
+	if got := JoinNames(names); got != IntentHardwareInfo {
+		t.Errorf("JoinNames: %q", got)
+	}
+}
+
+// TestCalcIntents — вычисления распознаются только когда их можно посчитать;
+// даты, телефоны и обычные вопросы уходят в LLM.
+func TestCalcIntents(t *testing.T) {
+	cases := map[string]string{
+		"2+2*2":                  IntentCalculate,
+		"Сколько будет (2+3)*4?": IntentCalculate,
+		"15% от 200":             IntentCalculate,
+		"переведи 5 км в мили":   IntentConvertUnits,
+		"100 F в C":              IntentConvertUnits,
+		"который час в Токио?":   IntentDateTime,
+		"time in europe/berlin":  IntentDateTime,
+		"какая дата будет через 45 дней":             IntentDateTime,
+		"3 недели назад":                             IntentDateTime,
+		"2026-03-08 + 30 дней":                       IntentDateTime,
+		"сколько дней до нового года":                IntentDateTime,
+		"сколько дней между 01.01.2026 и 08.03.2026": IntentDateTime,
+		"какой день недели 08.03.2026":               IntentDateTime,
+		"2026-03-08":                 "",
+		"2026-03-08 + 30":            "",
+		"позвони +7 (999) 123-45-67": "",
+		"what is kubernetes":         "",
+		"переведи 100 долларов в рубли": "",
+		"который час в Атлантиде":       "",
+	}
+	for msg, want := range cases {
+		got := ""
+		for _, b := range Builtins() {
+			if _, ok := b.Match(normalize(msg)); ok {
+				got = b.Name
+				break
+			}
+		}
+		if got != want {
+			t.Errorf("%q: интент %q, ожидался %q", msg, got, want)
+		}
+	}
+	if _, params := DetectIntent("сколько дней прошло с 01.09"); params["op"] != DateOpDays || params["date"] != "01.09" || params["date2"] != "" {
+		t.Errorf("параметры: %v", params)
+	}
+}
+
+// TestInfoIntents — погода и новости с местом или темой и без них.
+func TestInfoIntents(t *testing.T) {
+	cases := []struct {
+		msg, name, key, value string
+	}{
+		{"Какая погода в Москве?", IntentWeather, "place", "москве"},
+		{"погода завтра во Владивостоке", IntentWeather, "place", "владивостоке"},
+		{"погода", IntentWeather, "place", ""},
+		{"What's the weather like in Berlin", IntentWeather, "place", "berlin"},
+		{"новости про kubernetes", IntentNews, "query", "kubernetes"},
+		{"Последние новости", IntentNews, "query", ""},
+		{"что нового в Go", IntentNews, "query", "go"},
+	}
+	for _, c := range cases {
+		name, params := DetectIntent(c.msg)
+		if name != c.name || params[c.key] != c.value {
+			t.Errorf("%q: %q %v", c.msg, name, params)
+		}
+	}
+	if name, _ := DetectIntent("как погода влияет на настроение"); name != IntentNone {
+		t.Errorf("обычный вопрос распознан как %q", name)
+	}
+}
@@ -0,0 +1,110 @@
+package intent
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Spec — пользовательский интент из YAML-файла (INTENTS_FILE):
+//
+//	intents:
+//	  - name: VPN_HELP
+//	    description: Как подключиться к VPN
+//	    pattern: '(?:как|где)\s+подключиться\s+к\s+vpn\s*(?P<office>[а-яa-z0-9-]*)'
+//	    response: 'Инструкция для офиса {office}: https://wiki.local/vpn'
+//
+// Шаблон проверяется на сообщении в нижнем регистре; именованные группы
+// становятся параметрами и подставляются в ответ как {имя}.
+type Spec struct {
+	Name        string `yaml:"name"`
+	Description string `yaml:"description"`
+	Pattern     string `yaml:"pattern"`
+	Response    string `yaml:"response"`
+	Priority    int    `yaml:"priority"` // 0 — DefaultConfigPriority
+}
+
+// LoadSpecs — читает пользовательские интенты из YAML-файла.
+func LoadSpecs(path string) ([]Spec, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var file struct {
+		Intents []Spec `yaml:"intents"`
+	}
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("разбор %s: %w", path, err)
+	}
+	return file.Intents, nil
+}
+
+// Build — интент из описания: regex-распознаватель и ответ по шаблону.
+func (s Spec) Build() (Intent, error) {
+	if strings.TrimSpace(s.Name) == "" {
+		return Intent{}, fmt.Errorf("интент без имени (pattern %q)", s.Pattern)
+	}
+	if s.Pattern == "" || s.Response == "" {
+		return Intent{}, fmt.Errorf("интент %s: pattern и response обязательны", s.Name)
+	}
+	re, err := regexp.Compile(s.Pattern)
+	if err != nil {
+		return Intent{}, fmt.Errorf("интент %s: %w", s.Name, err)
+	}
+	priority := s.Priority
+	if priority == 0 {
+		priority = DefaultConfigPriority
+	}
+	response := s.Response
+	return Intent{
+		Name:        s.Name,
+		Description: s.Description,
+		Source:      SourceConfig,
+		Priority:    priority,
+		Match: func(msg string) (Params, bool) {
+			match := re.FindStringSubmatch(msg)
+			if match == nil {
+				return nil, false
+			}
+			params := Params{}
+			for i, name := range re.SubexpNames() {
+				if name != "" {
+					params[name] = strings.TrimSpace(match[i])
+				}
+			}
+			return params, true
+		},
+		Handle: func(p Params) (string, error) {
+			pairs := make([]string, 0, len(p)*2)
+			for k, v := range p {
+				pairs = append(pairs, "{"+k+"}", v)
+			}
+			return strings.NewReplacer(pairs...).Replace(response), nil
+		},
+	}, nil
+}
+
+// RegisterSpecs — регистрирует пользовательские интенты; ошибка в одном
+// не мешает остальным, все ошибки возвращаются вместе.
+func RegisterSpecs(r *Registry, specs []Spec) (int, error) {
+	var errs []string
+	registered := 0
+	for _, s := range specs {
+		in, err := s.Build()
+		if err == nil {
+			err = r.Register(in)
+		}
+		if err != nil {
+			errs = append(errs, err.Error())
+			continue
+		}
+		registered++
+	}
+	if len(errs) > 0 {
+		return registered, fmt.Errorf("пользовательские интенты: %s", strings.Join(errs, "; "))
+	}
+	return registered, nil
+}
@@ -0,0 +1,258 @@
+// Package issues — загрузка задач из трекеров (GitHub, GitLab, Jira) по
+// ссылке и превращение их в план работы агента.
+//
+// Ссылка определяет трекер: github.com/owner/repo/issues/N,
+// <gitlab>/group/project/-/issues/N, <jira>/browse/KEY-123. Токены берутся
+// из настроек провайдеров github, gitlab и jira; публичные задачи GitHub
+// читаются без токена.
+package issues
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/neo-2022/openclaw-memory/agent-service/internal/httpclient"
+)
+
+// Трекеры (имя провайдера в ProviderConfig).
+const (
+	TrackerGitHub = "github"
+	TrackerGitLab = "gitlab"
+	TrackerJira   = "jira"
+)
+
+// Ref — задача, разобранная из ссылки.
+type Ref struct {
+	Tracker string // github, gitlab, jira
+	Host    string // Хост трекера
+	Project string // owner/repo, group/project; для Jira — ключ проекта
+	Key     string // Номер задачи (42) или ключ Jira (PROJ-42)
+	URL     string // Исходная ссылка
+}
+
+// Display — короткое обозначение: owner/repo#42 или PROJ-42.
+func (r Ref) Display() string {
+	if r.Tracker == TrackerJira {
+		return r.Key
+	}
+	return r.Project + "#" + r.Key
+}
+
+// Issue — содержимое задачи.
+type Issue struct {
+	Ref
+	Title    string   `json:"title"`
+	Body     string   `json:"body"`
+	State    string   `json:"state"`
+	Labels   []string `json:"labels,omitempty"`
+	Comments []string `json:"comments,omitempty"` // «автор: текст», в хронологическом порядке
+}
+
+// Credentials — доступ к трекеру.
+type Credentials struct {
+	Token   string // GitHub/GitLab — токен; Jira — «email:api_token» (Basic) или PAT (Bearer)
+	BaseURL string // Адрес API: GitHub Enterprise — https://host/api/v3; GitLab и Jira — https://host
+}
+
+var (
+	githubIssueRe = regexp.MustCompile(`^/([^/]+/[^/]+)/(?:issues|pull)/(\d+)`)
+	gitlabIssueRe = regexp.MustCompile(`^/(.+?)/-/(?:issues|work_items)/(\d+)`)
+	jiraIssueRe   = regexp.MustCompile(`/browse/(([A-Z][A-Z0-9_]+)-\d+)`)
+)
+
+// ParseURL — трекер и задача по ссылке.
+func ParseURL(raw string) (Ref, error) {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil || u.Host == "" {
+		return Ref{}, fmt.Errorf("некорректная ссылка на задачу: %s", raw)
+	}
+	ref := Ref{Host: u.Hostname(), URL: u.String()}
+	switch {
+	case jiraIssueRe.MatchString(u.Path):
+		m := jiraIssueRe.FindStringSubmatch(u.Path)
+		ref.Tracker, ref.Key, ref.Project = TrackerJira, m[1], m[2]
+	case gitlabIssueRe.MatchString(u.Path):
+		m := gitlabIssueRe.FindStringSubmatch(u.Path)
+		ref.Tracker, ref.Project, ref.Key = TrackerGitLab, m[1], m[2]
+	case githubIssueRe.MatchString(u.Path) && !strings.Contains(ref.Host, "gitlab"):
+		m := githubIssueRe.FindStringSubmatch(u.Path)
+		ref.Tracker, ref.Project, ref.Key = TrackerGitHub, m[1], m[2]
+	default:
+		return Ref{}, fmt.Errorf("ссылка не похожа на задачу GitHub, GitLab или Jira: %s", raw)
+	}
+	return ref, nil
+}
+
+// ErrNotFound — задача не найдена или нет доступа.
+var ErrNotFound = errors.New("задача не найдена или нет доступа")
+
+var httpClient = httpclient.New("issues", 30*time.Second)
+
+// maxComments — сколько последних комментариев загружать.
+const maxComments = 20
+
+// Fetch — загружает задачу по ссылке.
+func Fetch(ctx context.Context, ref Ref, cred Credentials) (Issue, error) {
+	switch ref.Tracker {
+	case TrackerGitHub:
+		return fetchGitHub(ctx, ref, cred)
+	case TrackerGitLab:
+		return fetchGitLab(ctx, ref, cred)
+	case TrackerJira:
+		return fetchJira(ctx, ref, cred)
+	}
+	return Issue{}, fmt.Errorf("неизвестный трекер: %s", ref.Tracker)
+}
+
+func fetchGitHub(ctx context.Context, ref Ref, cred Credentials) (Issue, error) {
+	base := strings.TrimRight(cred.BaseURL, "/")
+	if base == "" {
+		base = "https://api.github.com"
+	}
+	headers := map[string]string{"Accept": "application/vnd.github+json"}
+	if cred.Token != "" {
+		headers["Authorization"] = "Bearer " + cred.Token
+	}
+	var raw struct {
+		Title  string `json:"title"`
+		Body   string `json:"body"`
+		State  string `json:"state"`
+		Labels []struct {
+			Name string `json:"name"`
+		} `json:"labels"`
+	}
+	endpoint := base + "/repos/" + ref.Project + "/issues/" + ref.Key
+	if err := getJSON(ctx, endpoint, headers, &raw); err != nil {
+		return Issue{}, fmt.Errorf("GitHub: %w", err)
+	}
+	issue := Issue{Ref: ref, Title: raw.Title, Body: raw.Body, State: raw.State}
+	for _, l := range raw.Labels {
+		issue.Labels = append(issue.Labels, l.Name)
+	}
+	var comments []struct {
+		Body string `json:"body"`
+		User struct {
+			Login string `json:"login"`
+		} `json:"user"`
+	}
+	if err := getJSON(ctx, endpoint+fmt.Sprintf("/comments?per_page=%d", maxComments), headers, &comments); err == nil {
+		for _, c := range comments {
+			issue.Comments = append(issue.Comments, c.User.Login+": "+c.Body)
+		}
+	}
+	return issue, nil
+}
+
+func fetchGitLab(ctx context.Context, ref Ref, cred Credentials) (Issue, error) {
+	base := strings.TrimRight(cred.BaseURL, "/")
+	if base == "" {
+		base = "https://" + ref.Host
+	}
+	headers := map[string]string{}
+	if cred.Token != "" {
+		headers["PRIVATE-TOKEN"] = cred.Token
+	}
+	var raw struct {
+		Title       string   `json:"title"`
+		Description string   `json:"description"`
+		State       string   `json:"state"`
+		Labels      []string `json:"labels"`
+	}
+	endpoint := base + "/api/v4/projects/" + url.PathEscape(ref.Project) + "/issues/" + ref.Key
+	if err := getJSON(ctx, endpoint, headers, &raw); err != nil {
+		return Issue{}, fmt.Errorf("GitLab: %w", err)
+	}
+	issue := Issue{Ref: ref, Title: raw.Title, Body: raw.Description, State: raw.State, Labels: raw.Labels}
+	var notes []struct {
+		Body   string `json:"body"`
+		System bool   `json:"system"`
+		Author struct {
+			Username string `json:"username"`
+		} `json:"author"`
+	}
+	if err := getJSON(ctx, endpoint+fmt.Sprintf("/notes?sort=asc&per_page=%d", maxComments), headers, &notes); err == nil {
+		for _, n := range notes {
+			if !n.System {
+				issue.Comments = append(issue.Comments, n.Author.Username+": "+n.Body)
+			}
+		}
+	}
+	return issue, nil
+}
+
+func fetchJira(ctx context.Context, ref Ref, cred Credentials) (Issue, error) {
+	base := strings.TrimRight(cred.BaseURL, "/")
+	if base == "" {
+		base = "https://" + ref.Host
+	}
+	headers := map[string]string{"Accept": "application/json"}
+	if strings.Contains(cred.Token, ":") {
+		headers["Authorization"] = "Basic " + basicAuth(cred.Token)
+	} else if cred.Token != "" {
+		headers["Authorization"] = "Bearer " + cred.Token
+	}
+	// API v2: description и комментарии — в вики-разметке, а не в ADF (v3)
+	var raw struct {
+		Fields struct {
+			Summary     string   `json:"summary"`
+			Description string   `json:"description"`
+			Labels      []string `json:"labels"`
+			Status      struct {
+				Name string `json:"name"`
+			} `json:"status"`
+			Comment struct {
+				Comments []struct {
+					Body   string `json:"body"`
+					Author struct {
+						DisplayName string `json:"displayName"`
+					} `json:"author"`
+				} `json:"comments"`
+			} `json:"comment"`
+		} `json:"fields"`
+	}
+	endpoint := base + "/rest/api/2/issue/" + url.PathEscape(ref.Key) + "?fields=summary,description,labels,status,comment"
+	if err := getJSON(ctx, endpoint, headers, &raw); err != nil {
+		return Issue{}, fmt.Errorf("Jira: %w", err)
+	}
+	f := raw.Fields
+	issue := Issue{Ref: ref, Title: f.Summary, Body: f.Description, State: f.Status.Name, Labels: f.Labels}
+	comments := f.Comment.Comments
+	if len(comments) > maxComments {
+		comments = comments[len(comments)-maxComments:]
+	}
+	for _, c := range comments {
+		issue.Comments = append(issue.Comments, c.Author.DisplayName+": "+c.Body)
+	}
+	return issue, nil
+}
+
+func getJSON(ctx context.Context, endpoint string, headers map[string]string, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return err
+	}
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 4<<20))
+	switch {
+	case resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+		return fmt.Errorf("%w (HTTP %d)", ErrNotFound, resp.StatusCode)
+	case resp.StatusCode >= 300:
+		return fmt.Errorf("HTTP %d: %s", resp.StatusCode, strings.TrimSpace(string(data)))
+	}
+	return json.Unmarshal(data, out)
+}
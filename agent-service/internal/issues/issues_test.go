@@ -0,0 +1,82 @@
+package issues
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// TestParseURL — трекер определяется по виду ссылки.
+func TestParseURL(t *testing.T) {
+	cases := []struct {
+		url, tracker, project, key string
+	}{
+		{"https://github.com/neo/agent/issues/42", TrackerGitHub, "neo/agent", "42"},
+		{"https://github.com/neo/agent/issues/42#issuecomment-1", TrackerGitHub, "neo/agent", "42"},
+		{"https://gitlab.com/group/sub/app/-/issues/7", TrackerGitLab, "group/sub/app", "7"},
+		{"https://acme.atlassian.net/browse/CORE-128", TrackerJira, "CORE", "CORE-128"},
+	}
+	for _, c := range cases {
+		ref, err := ParseURL(c.url)
+		if err != nil || ref.Tracker != c.tracker || ref.Project != c.project || ref.Key != c.key {
+			t.Errorf("ParseURL(%q) = %+v, %v", c.url, ref, err)
+		}
+	}
+	if _, err := ParseURL("https://example.com/docs/page"); err == nil {
+		t.Error("ссылка без задачи должна отклоняться")
+	}
+}
+
+// TestFetch_GitHubAndJira — загрузка задачи и комментариев через API.
+func TestFetch_GitHubAndJira(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/repos/neo/agent/issues/42":
+			w.Write([]byte(`{"title":"Crash on empty diff","body":"Steps:\n1. run lint","state":"open","labels":[{"name":"bug"}]}`))
+		case "/repos/neo/agent/issues/42/comments":
+			w.Write([]byte(`[{"body":"Also on Windows","user":{"login":"ann"}}]`))
+		case "/rest/api/2/issue/CORE-1":
+			if r.Header.Get("Authorization") != "Basic "+basicAuth("me@acme.io:tok") {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			w.Write([]byte(`{"fields":{"summary":"Add export","description":"CSV export","status":{"name":"To Do"},"comment":{"comments":[{"body":"ok","author":{"displayName":"Bob"}}]}}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	ref, _ := ParseURL("https://github.com/neo/agent/issues/42")
+	issue, err := Fetch(context.Background(), ref, Credentials{BaseURL: srv.URL})
+	if err != nil || issue.Title != "Crash on empty diff" || len(issue.Labels) != 1 || issue.Comments[0] != "ann: Also on Windows" {
+		t.Fatalf("GitHub: %+v, %v", issue, err)
+	}
+	if text := issue.Text(0); !strings.Contains(text, "neo/agent#42: Crash on empty diff") || !strings.Contains(text, "Метки: bug") {
+		t.Errorf("Text: %q", text)
+	}
+
+	ref, _ = ParseURL("https://acme.atlassian.net/browse/CORE-1")
+	issue, err = Fetch(context.Background(), ref, Credentials{BaseURL: srv.URL, Token: "me@acme.io:tok"})
+	if err != nil || issue.Title != "Add export" || issue.State != "To Do" || issue.Comments[0] != "Bob: ok" {
+		t.Fatalf("Jira: %+v, %v", issue, err)
+	}
+	if _, err := Fetch(context.Background(), ref, Credentials{BaseURL: srv.URL}); !errors.Is(err, ErrNotFound) {
+		t.Errorf("без токена ожидалась ErrNotFound, получено %v", err)
+	}
+}
+
+// TestParsePlan — разделы резюме и плана, в том числе с markdown-выделением.
+func TestParsePlan(t *testing.T) {
+	summary, steps := ParsePlan("**РЕЗЮМЕ:** Исправить падение lint на пустом diff.\n\n**ПЛАН:**\n1. Изучить diff.go\n2) Добавить проверку\n- Написать тест\n")
+	if summary != "Исправить падение lint на пустом diff." || len(steps) != 3 || steps[2] != "Написать тест" {
+		t.Errorf("ParsePlan: %q %q", summary, steps)
+	}
+	summary, steps = ParsePlan("Просто описание без разделов")
+	if summary != "Просто описание без разделов" || steps != nil {
+		t.Errorf("без разделов: %q %q", summary, steps)
+	}
+}
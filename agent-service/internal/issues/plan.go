@@ -0,0 +1,88 @@
+package issues
+
+import (
+	"encoding/base64"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// MaxBody — сколько символов описания и комментариев задачи уходит в модель
+// и в промпт агента.
+const MaxBody = 8000
+
+// PlanPrompt — системная инструкция модели для резюме и плана.
+const PlanPrompt = "Ты разбираешь задачу из трекера для агента-программиста. Ответь строго в формате:\n" +
+	"РЕЗЮМЕ:\n<2–4 предложения: что нужно сделать и критерии готовности>\n" +
+	"ПЛАН:\n1. <шаг>\n2. <шаг>\n...\n" +
+	"Шаги — конкретные действия с кодом (какие файлы изучить, что изменить, какие тесты добавить), не больше 8."
+
+// Text — задача одним текстом: заголовок, метки, описание и комментарии,
+// обрезанный до limit символов.
+func (i Issue) Text(limit int) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s: %s\n", i.Display(), i.Title)
+	if i.State != "" {
+		fmt.Fprintf(&b, "Статус: %s\n", i.State)
+	}
+	if len(i.Labels) > 0 {
+		fmt.Fprintf(&b, "Метки: %s\n", strings.Join(i.Labels, ", "))
+	}
+	if body := strings.TrimSpace(i.Body); body != "" {
+		b.WriteString("\n" + body + "\n")
+	}
+	if len(i.Comments) > 0 {
+		b.WriteString("\nКомментарии:\n")
+		for _, c := range i.Comments {
+			b.WriteString("- " + strings.TrimSpace(c) + "\n")
+		}
+	}
+	return clip(b.String(), limit)
+}
+
+var (
+	summaryHeadRe = regexp.MustCompile(`(?im)^\s*(?:\*\*)?(?:РЕЗЮМЕ|SUMMARY)(?:\*\*)?\s*:?\s*(?:\*\*)?`)
+	planHeadRe    = regexp.MustCompile(`(?im)^\s*(?:\*\*)?(?:ПЛАН|PLAN)(?:\*\*)?\s*:?\s*(?:\*\*)?\s*$`)
+	stepRe        = regexp.MustCompile(`^\s*(?:\d+[.)]|[-*•])\s+(.+)$`)
+)
+
+// ParsePlan — резюме и шаги из ответа модели в формате PlanPrompt. Если
+// разделов нет, весь ответ считается резюме.
+func ParsePlan(answer string) (summary string, steps []string) {
+	answer = strings.TrimSpace(answer)
+	planLoc := planHeadRe.FindStringIndex(answer)
+	head, tail := answer, ""
+	if planLoc != nil {
+		head, tail = answer[:planLoc[0]], answer[planLoc[1]:]
+	}
+	summary = strings.TrimSpace(summaryHeadRe.ReplaceAllString(head, ""))
+	for _, line := range strings.Split(tail, "\n") {
+		if m := stepRe.FindStringSubmatch(line); m != nil {
+			steps = append(steps, strings.TrimSpace(m[1]))
+		}
+	}
+	return summary, steps
+}
+
+// FallbackSummary — резюме без модели: первый абзац описания.
+func FallbackSummary(i Issue) string {
+	para := strings.TrimSpace(i.Body)
+	if idx := strings.Index(para, "\n\n"); idx > 0 {
+		para = para[:idx]
+	}
+	if para == "" {
+		return i.Title
+	}
+	return clip(para, 600)
+}
+
+func clip(s string, limit int) string {
+	if r := []rune(s); limit > 0 && len(r) > limit {
+		return string(r[:limit]) + "\n… (обрезано)"
+	}
+	return s
+}
+
+func basicAuth(userToken string) string {
+	return base64.StdEncoding.EncodeToString([]byte(userToken))
+}
@@ -0,0 +1,442 @@
+// Package jobqueue — очередь тяжёлых фоновых заданий в базе данных:
+// загрузка папок в RAG, извлечение знаний из диалогов, запуск агентов по
+// расписанию.
+//
+// Задания хранятся в таблице Job и переживают перезапуск сервиса. Обработчик
+// берёт задание в аренду (LockedBy, LockedUntil) и продлевает её, пока
+// работает; если процесс упал, после истечения аренды задание забирает
+// другой обработчик. Захват — условный UPDATE по числу попыток, поэтому
+// несколько процессов (JOBS_WORKER_ONLY) работают с одной очередью без
+// блокировок, специфичных для PostgreSQL. Ошибка обработчика — повтор с
+// растущей паузой, после MaxAttempts попыток — статус failed.
+//
+// Без шины сообщений обработчики других процессов узнают о новых заданиях
+// опросом БД (JOBS_POLL). С шиной (Broker, например NATS) постановка задания
+// публикует сообщение, и один из подписанных процессов берёт задание сразу;
+// опрос остаётся для отложенных заданий и брошенных аренд. БД при этом
+// остаётся единственным хранилищем: сообщение — только сигнал «есть работа»,
+// поэтому потеря сообщения или недоступность шины не теряет заданий.
+package jobqueue
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/neo-2022/openclaw-memory/agent-service/internal/metrics"
+	"github.com/neo-2022/openclaw-memory/agent-service/internal/models"
+)
+
+// Статусы заданий.
+const (
+	StatusPending  = "pending"
+	StatusRunning  = "running"
+	StatusDone     = "done"
+	StatusFailed   = "failed"
+	StatusCanceled = "canceled"
+)
+
+// maxBackoff — предельная пауза перед повтором задания.
+const maxBackoff = 10 * time.Minute
+
+var (
+	// ErrNotFound — задания нет.
+	ErrNotFound = errors.New("задание не найдено")
+	// ErrWrongStatus — операция недоступна в текущем статусе задания.
+	ErrWrongStatus = errors.New("операция недоступна в текущем статусе задания")
+)
+
+// Broker — шина сообщений о готовых заданиях между процессами.
+type Broker interface {
+	// Publish — сообщает, что готово задание типа kind.
+	Publish(kind string) error
+	// Subscribe — вызывает fn на сообщения о заданиях типов kinds; сообщение
+	// получает один из подписанных процессов. Возвращает отписку.
+	Subscribe(kinds []string, fn func()) (unsubscribe func(), err error)
+}
+
+// Handler — обработчик заданий одного типа. result сохраняется в Job.Result
+// как JSON (может быть nil). Отмена ctx — остановка обработчика.
+type Handler func(ctx context.Context, payload json.RawMessage) (result interface{}, err error)
+
+// Queue — очередь заданий; обработчики выполняют только зарегистрированные
+// в этом процессе типы.
+type Queue struct {
+	DB          *gorm.DB
+	Worker      string        // Имя обработчика в LockedBy (хост и PID)
+	Lease       time.Duration // Срок аренды задания, продлевается каждую треть срока
+	MaxAttempts int           // Попыток по умолчанию для новых заданий
+	Broker      Broker        // Шина сообщений о новых заданиях; nil — только опрос БД
+
+	mu       sync.RWMutex
+	handlers map[string]Handler
+	wake     chan struct{}
+	now      func() time.Time
+}
+
+// New — очередь в db; worker — имя этого процесса для LockedBy.
+func New(db *gorm.DB, worker string, lease time.Duration, maxAttempts int) *Queue {
+	return &Queue{
+		DB:          db,
+		Worker:      worker,
+		Lease:       lease,
+		MaxAttempts: maxAttempts,
+		handlers:    make(map[string]Handler),
+		wake:        make(chan struct{}, 1),
+		now:         time.Now,
+	}
+}
+
+// Register — обработчик заданий типа kind.
+func (q *Queue) Register(kind string, h Handler) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.handlers[kind] = h
+}
+
+// Kinds — зарегистрированные типы заданий по алфавиту.
+func (q *Queue) Kinds() []string {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	kinds := make([]string, 0, len(q.handlers))
+	for k := range q.handlers {
+		kinds = append(kinds, k)
+	}
+	sort.Strings(kinds)
+	return kinds
+}
+
+func (q *Queue) handler(kind string) Handler {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	return q.handlers[kind]
+}
+
+// Enqueue — ставит задание kind с параметрами payload; runAt — не раньше
+// какого времени выполнять (нулевое — сразу).
+func (q *Queue) Enqueue(kind string, payload interface{}, runAt time.Time) (*models.Job, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("параметры задания %s: %w", kind, err)
+	}
+	if runAt.IsZero() {
+		runAt = q.now()
+	}
+	job := &models.Job{Kind: kind, Status: StatusPending, Payload: string(data), MaxAttempts: q.MaxAttempts, RunAt: runAt}
+	if err := q.DB.Create(job).Error; err != nil {
+		return nil, err
+	}
+	q.announce(kind)
+	return job, nil
+}
+
+// EnqueueOnce — как Enqueue, но если ожидающее задание kind с теми же
+// параметрами уже есть, возвращает его. Нужно периодическим заданиям:
+// повтор после ошибки не должен планировать следующий запуск второй раз.
+func (q *Queue) EnqueueOnce(kind string, payload interface{}, runAt time.Time) (*models.Job, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("параметры задания %s: %w", kind, err)
+	}
+	var existing models.Job
+	err = q.DB.Where("kind = ? AND status = ? AND payload = ?", kind, StatusPending, string(data)).Take(&existing).Error
+	if err == nil {
+		return &existing, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	return q.Enqueue(kind, json.RawMessage(data), runAt)
+}
+
+// announce — сообщает о готовом задании kind обработчикам этого процесса и,
+// если есть шина, другим процессам. Ошибка шины не мешает заданию: его
+// подхватит опрос.
+func (q *Queue) announce(kind string) {
+	q.notify()
+	if q.Broker == nil {
+		return
+	}
+	if err := q.Broker.Publish(kind); err != nil {
+		slog.Warn("Не удалось отправить сообщение о задании в шину", slog.String("тип", kind), slog.String("ошибка", err.Error()))
+	}
+}
+
+// notify — будит обработчики этого процесса, не дожидаясь опроса.
+func (q *Queue) notify() {
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Claim — берёт в аренду очередное готовое задание зарегистрированного типа:
+// ожидающее со сроком RunAt или брошенное (аренда истекла). nil — заданий нет.
+func (q *Queue) Claim() (*models.Job, error) {
+	kinds := q.Kinds()
+	if len(kinds) == 0 {
+		return nil, nil
+	}
+	for {
+		now := q.now()
+		var job models.Job
+		err := q.DB.Where("kind IN ?", kinds).
+			Where("(status = ? AND run_at <= ?) OR (status = ? AND locked_until < ?)", StatusPending, now, StatusRunning, now).
+			Order("run_at, id").Take(&job).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		until := now.Add(q.Lease)
+		res := q.DB.Model(&models.Job{}).
+			Where("id = ? AND status = ? AND attempts = ?", job.ID, job.Status, job.Attempts).
+			Updates(map[string]interface{}{
+				"status":       StatusRunning,
+				"attempts":     job.Attempts + 1,
+				"locked_by":    q.Worker,
+				"locked_until": until,
+				"started_at":   now,
+			})
+		if res.Error != nil {
+			return nil, res.Error
+		}
+		if res.RowsAffected == 0 {
+			continue // Задание забрал другой обработчик
+		}
+		job.Status, job.Attempts, job.LockedBy, job.LockedUntil, job.StartedAt = StatusRunning, job.Attempts+1, q.Worker, &until, &now
+		return &job, nil
+	}
+}
+
+// Process — выполняет взятое задание и записывает итог. Запись не делается,
+// если аренду за это время перехватил другой обработчик.
+func (q *Queue) Process(ctx context.Context, job *models.Job) {
+	h := q.handler(job.Kind)
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go q.extendLease(ctx, job)
+
+	start := q.now()
+	var (
+		result interface{}
+		err    error
+	)
+	if h == nil {
+		err = fmt.Errorf("нет обработчика заданий %s", job.Kind)
+	} else {
+		result, err = h(ctx, json.RawMessage(job.Payload))
+	}
+	duration := q.now().Sub(start)
+
+	now := q.now()
+	updates := map[string]interface{}{"locked_by": "", "locked_until": nil}
+	status := StatusDone
+	switch {
+	case err == nil:
+		updates["status"], updates["finished_at"], updates["error"] = StatusDone, now, ""
+		if result != nil {
+			if data, mErr := json.Marshal(result); mErr == nil {
+				updates["result"] = string(data)
+			}
+		}
+	case job.Attempts < job.MaxAttempts && ctx.Err() == nil:
+		status = "retry"
+		updates["status"], updates["error"], updates["run_at"] = StatusPending, err.Error(), now.Add(Backoff(job.Attempts))
+	case ctx.Err() != nil:
+		// Остановка процесса: задание вернётся в очередь без траты попытки
+		status = "interrupted"
+		updates["status"], updates["attempts"], updates["run_at"] = StatusPending, job.Attempts-1, now
+	default:
+		status = StatusFailed
+		updates["status"], updates["error"], updates["finished_at"] = StatusFailed, err.Error(), now
+	}
+	metrics.RecordJob(job.Kind, status, duration)
+	res := q.DB.Model(&models.Job{}).
+		Where("id = ? AND status = ? AND attempts = ? AND locked_by = ?", job.ID, StatusRunning, job.Attempts, q.Worker).
+		Updates(updates)
+	if res.Error != nil {
+		slog.Error("Не удалось сохранить итог задания", slog.Uint64("задание", uint64(job.ID)), slog.String("ошибка", res.Error.Error()))
+		return
+	}
+	if err != nil {
+		slog.Warn("Задание завершилось ошибкой",
+			slog.Uint64("задание", uint64(job.ID)),
+			slog.String("тип", job.Kind),
+			slog.Int("попытка", job.Attempts),
+			slog.String("итог", status),
+			slog.String("ошибка", err.Error()))
+	}
+}
+
+// extendLease — продлевает аренду задания, пока его выполняет этот процесс.
+func (q *Queue) extendLease(ctx context.Context, job *models.Job) {
+	ticker := time.NewTicker(q.Lease / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			q.DB.Model(&models.Job{}).
+				Where("id = ? AND status = ? AND attempts = ? AND locked_by = ?", job.ID, StatusRunning, job.Attempts, q.Worker).
+				Update("locked_until", q.now().Add(q.Lease))
+		}
+	}
+}
+
+// Run — workers обработчиков забирают задания до отмены ctx; очередь
+// проверяется каждые poll, сразу после Enqueue в этом процессе и по
+// сообщению шины. Возвращается, когда все начатые задания завершились.
+func (q *Queue) Run(ctx context.Context, workers int, poll time.Duration) {
+	if q.Broker != nil {
+		unsubscribe, err := q.Broker.Subscribe(q.Kinds(), q.notify)
+		if err != nil {
+			slog.Warn("Не удалось подписаться на шину заданий, остаётся опрос БД", slog.String("ошибка", err.Error()))
+		} else {
+			defer unsubscribe()
+		}
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for ctx.Err() == nil {
+				job, err := q.Claim()
+				if err != nil {
+					slog.Warn("Не удалось взять задание из очереди", slog.String("ошибка", err.Error()))
+				}
+				if job != nil {
+					q.Process(ctx, job)
+					continue
+				}
+				timer := time.NewTimer(poll)
+				select {
+				case <-ctx.Done():
+				case <-q.wake:
+				case <-timer.C:
+				}
+				timer.Stop()
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// Backoff — пауза перед повтором после attempt неудачных попыток:
+// 10 с, 40 с, 1,5 мин... не больше maxBackoff.
+func Backoff(attempt int) time.Duration {
+	d := time.Duration(attempt*attempt) * 10 * time.Second
+	if d > maxBackoff || d <= 0 {
+		return maxBackoff
+	}
+	return d
+}
+
+// Get — задание по id.
+func Get(db *gorm.DB, id uint) (*models.Job, error) {
+	var job models.Job
+	err := db.First(&job, id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// List — задания с фильтром по статусу и типу (пустые — все), новые первыми.
+func List(db *gorm.DB, status, kind string, limit int) ([]models.Job, error) {
+	query := db.Order("id DESC").Limit(limit)
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if kind != "" {
+		query = query.Where("kind = ?", kind)
+	}
+	var jobs []models.Job
+	return jobs, query.Find(&jobs).Error
+}
+
+// Counts — число заданий по статусам.
+func Counts(db *gorm.DB) (map[string]int64, error) {
+	var rows []struct {
+		Status string
+		Count  int64
+	}
+	if err := db.Model(&models.Job{}).Select("status, COUNT(*) AS count").Group("status").Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	out := make(map[string]int64, len(rows))
+	for _, r := range rows {
+		out[r.Status] = r.Count
+	}
+	return out, nil
+}
+
+// Retry — возвращает в очередь задание со статусом failed или canceled;
+// счётчик попыток сбрасывается.
+func (q *Queue) Retry(id uint) (*models.Job, error) {
+	return q.transition(id, []string{StatusFailed, StatusCanceled}, map[string]interface{}{
+		"status": StatusPending, "attempts": 0, "error": "", "run_at": q.now(), "finished_at": nil,
+	})
+}
+
+// Cancel — отменяет ожидающее задание. Выполняемое задание не прерывается.
+func (q *Queue) Cancel(id uint) (*models.Job, error) {
+	return q.transition(id, []string{StatusPending}, map[string]interface{}{
+		"status": StatusCanceled, "finished_at": q.now(),
+	})
+}
+
+func (q *Queue) transition(id uint, from []string, updates map[string]interface{}) (*models.Job, error) {
+	res := q.DB.Model(&models.Job{}).Where("id = ? AND status IN ?", id, from).Updates(updates)
+	if res.Error != nil {
+		return nil, res.Error
+	}
+	job, err := Get(q.DB, id)
+	if err != nil {
+		return nil, err
+	}
+	if res.RowsAffected == 0 {
+		return job, ErrWrongStatus
+	}
+	if job.Status == StatusPending {
+		q.announce(job.Kind)
+	}
+	return job, nil
+}
+
+// Prune — удаляет завершённые задания (done, failed, canceled) старше before.
+func Prune(db *gorm.DB, before time.Time) (int64, error) {
+	res := db.Where("status IN ? AND updated_at < ?", []string{StatusDone, StatusFailed, StatusCanceled}, before).Delete(&models.Job{})
+	return res.RowsAffected, res.Error
+}
+
+// RunPruner — раз в час удаляет завершённые задания старше retention() до отмены ctx.
+func RunPruner(ctx context.Context, db *gorm.DB, retention func() time.Duration) {
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+	for {
+		if n, err := Prune(db, time.Now().Add(-retention())); err != nil {
+			slog.Warn("Не удалось удалить старые задания", slog.String("ошибка", err.Error()))
+		} else if n > 0 {
+			slog.Info("Старые задания удалены", slog.Int64("количество", n))
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
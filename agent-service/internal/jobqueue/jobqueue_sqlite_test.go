@@ -0,0 +1,225 @@
+//go:build cgo
+
+package jobqueue
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+
+	"github.com/neo-2022/openclaw-memory/agent-service/internal/models"
+)
+
+func openDB(t *testing.T) *gorm.DB {
+	gdb, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "jobs.db")), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("открытие SQLite: %v", err)
+	}
+	if err := gdb.AutoMigrate(&models.Job{}); err != nil {
+		t.Fatalf("миграция: %v", err)
+	}
+	return gdb
+}
+
+// TestClaimOnce — задание достаётся одному обработчику; брошенное после
+// истечения аренды забирает другой.
+func TestClaimOnce(t *testing.T) {
+	gdb := openDB(t)
+	now := time.Now()
+	a := New(gdb, "a", time.Minute, 3)
+	b := New(gdb, "b", time.Minute, 3)
+	for _, q := range []*Queue{a, b} {
+		q.now = func() time.Time { return now }
+		q.Register("ingest", func(context.Context, json.RawMessage) (interface{}, error) { return nil, nil })
+	}
+	if _, err := a.Enqueue("ingest", map[string]string{"folder": "/docs"}, time.Time{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := a.Enqueue("ingest", nil, now.Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+
+	job, err := a.Claim()
+	if err != nil || job == nil || job.LockedBy != "a" || job.Attempts != 1 {
+		t.Fatalf("захват: %+v %v", job, err)
+	}
+	if other, _ := b.Claim(); other != nil {
+		t.Fatalf("задание взято дважды или раньше срока: %+v", other)
+	}
+
+	// Обработчик a упал: через минуту аренда истекла
+	now = now.Add(2 * time.Minute)
+	again, err := b.Claim()
+	if err != nil || again == nil || again.ID != job.ID || again.LockedBy != "b" || again.Attempts != 2 {
+		t.Fatalf("брошенное задание: %+v %v", again, err)
+	}
+	a.Process(context.Background(), job) // итог устаревшей аренды не записывается
+	if got, _ := Get(gdb, job.ID); got.Status != StatusRunning || got.LockedBy != "b" {
+		t.Errorf("устаревший обработчик перезаписал задание: %+v", got)
+	}
+}
+
+// TestProcessRetryAndFail — ошибка даёт повтор с паузой, после MaxAttempts — failed.
+func TestProcessRetryAndFail(t *testing.T) {
+	gdb := openDB(t)
+	now := time.Now()
+	q := New(gdb, "w", time.Minute, 2)
+	q.now = func() time.Time { return now }
+	q.Register("learn", func(_ context.Context, payload json.RawMessage) (interface{}, error) {
+		return nil, errors.New("memory-service недоступен")
+	})
+	q.Register("ok", func(_ context.Context, payload json.RawMessage) (interface{}, error) {
+		return map[string]int{"files": 3}, nil
+	})
+	enq, _ := q.Enqueue("learn", nil, time.Time{})
+
+	job, _ := q.Claim()
+	q.Process(context.Background(), job)
+	got, _ := Get(gdb, enq.ID)
+	if got.Status != StatusPending || !got.RunAt.Equal(now.Add(Backoff(1))) || got.Error == "" {
+		t.Fatalf("после первой ошибки: %+v", got)
+	}
+
+	now = got.RunAt
+	job, _ = q.Claim()
+	q.Process(context.Background(), job)
+	if got, _ = Get(gdb, enq.ID); got.Status != StatusFailed || got.FinishedAt == nil {
+		t.Fatalf("после последней попытки: %+v", got)
+	}
+
+	if got, err := q.Retry(enq.ID); err != nil || got.Status != StatusPending || got.Attempts != 0 {
+		t.Fatalf("повтор: %+v %v", got, err)
+	}
+	if _, err := q.Retry(enq.ID); !errors.Is(err, ErrWrongStatus) {
+		t.Errorf("повтор ожидающего задания: %v", err)
+	}
+	if got, err := q.Cancel(enq.ID); err != nil || got.Status != StatusCanceled {
+		t.Fatalf("отмена: %+v %v", got, err)
+	}
+
+	okJob, _ := q.Enqueue("ok", nil, time.Time{})
+	job, _ = q.Claim()
+	q.Process(context.Background(), job)
+	if got, _ = Get(gdb, okJob.ID); got.Status != StatusDone || got.Result != `{"files":3}` {
+		t.Errorf("успешное задание: %+v", got)
+	}
+	counts, _ := Counts(gdb)
+	if counts[StatusDone] != 1 || counts[StatusCanceled] != 1 {
+		t.Errorf("счётчики: %v", counts)
+	}
+}
+
+// TestRunStopsOnCancel — остановка возвращает начатое задание в очередь без траты попытки.
+func TestRunStopsOnCancel(t *testing.T) {
+	gdb := openDB(t)
+	q := New(gdb, "w", time.Minute, 3)
+	started := make(chan struct{})
+	q.Register("agent_run", func(ctx context.Context, _ json.RawMessage) (interface{}, error) {
+		close(started)
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+	job, _ := q.Enqueue("agent_run", nil, time.Time{})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() { q.Run(ctx, 1, time.Hour); close(done) }()
+	<-started
+	cancel()
+	<-done
+	if got, _ := Get(gdb, job.ID); got.Status != StatusPending || got.Attempts != 0 || got.LockedBy != "" {
+		t.Errorf("прерванное задание: %+v", got)
+	}
+}
+
+// TestEnqueueOnce — ожидающее задание с теми же параметрами не дублируется.
+func TestEnqueueOnce(t *testing.T) {
+	q := New(openDB(t), "w", time.Minute, 3)
+	payload := map[string]string{"agent": "admin", "prompt": "отчёт", "every": "24h"}
+	first, err := q.EnqueueOnce("agent_run", payload, time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, _ := q.EnqueueOnce("agent_run", payload, time.Now().Add(2*time.Hour))
+	other, _ := q.EnqueueOnce("agent_run", map[string]string{"agent": "coder", "prompt": "отчёт"}, time.Time{})
+	if second.ID != first.ID || other.ID == first.ID {
+		t.Errorf("первое %d, повтор %d, другое %d", first.ID, second.ID, other.ID)
+	}
+}
+
+// fakeBroker — шина в памяти: Publish вызывает подписчиков этого типа.
+type fakeBroker struct {
+	mu         sync.Mutex
+	subs       map[string][]func()
+	published  []string
+	subscribed chan struct{}
+}
+
+func (b *fakeBroker) Publish(kind string) error {
+	b.mu.Lock()
+	b.published = append(b.published, kind)
+	fns := append([]func(){}, b.subs[kind]...)
+	b.mu.Unlock()
+	for _, fn := range fns {
+		fn()
+	}
+	return nil
+}
+
+func (b *fakeBroker) Subscribe(kinds []string, fn func()) (func(), error) {
+	b.mu.Lock()
+	for _, k := range kinds {
+		b.subs[k] = append(b.subs[k], fn)
+	}
+	b.mu.Unlock()
+	close(b.subscribed)
+	return func() {
+		b.mu.Lock()
+		b.subs = map[string][]func(){}
+		b.mu.Unlock()
+	}, nil
+}
+
+// TestBrokerWakesWorker — задание, поставленное другим процессом, будит
+// обработчик через шину, не дожидаясь опроса БД.
+func TestBrokerWakesWorker(t *testing.T) {
+	gdb := openDB(t)
+	broker := &fakeBroker{subs: map[string][]func(){}, subscribed: make(chan struct{})}
+	producer := New(gdb, "api", time.Minute, 3)
+	producer.Broker = broker
+	worker := New(gdb, "worker", time.Minute, 3)
+	worker.Broker = broker
+	processed := make(chan struct{})
+	worker.Register("learning_extract", func(context.Context, json.RawMessage) (interface{}, error) {
+		close(processed)
+		return nil, nil
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() { worker.Run(ctx, 1, time.Hour); close(done) }()
+	<-broker.subscribed
+	time.Sleep(50 * time.Millisecond) // обработчик успел уснуть до первого опроса через час
+
+	if _, err := producer.Enqueue("learning_extract", nil, time.Time{}); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-processed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("обработчик не разбужен сообщением из шины")
+	}
+	cancel()
+	<-done
+	if len(broker.published) != 1 || broker.published[0] != "learning_extract" {
+		t.Errorf("опубликовано: %v", broker.published)
+	}
+}
@@ -0,0 +1,78 @@
+package jobqueue
+
+import (
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+// NATSGroup — группа очереди NATS, общая для обработчиков всех процессов:
+// сообщение о задании получает один из них.
+const NATSGroup = "agent-service-jobs"
+
+// NATSBroker — Broker поверх NATS: сообщение о готовом задании kind уходит в
+// тему <prefix>.<kind>. Core NATS сообщения не хранит — пока шина недоступна,
+// задания подхватываются опросом БД.
+type NATSBroker struct {
+	conn   *nats.Conn
+	prefix string
+}
+
+// NewNATSBroker — подключение к NATS по url; name — имя клиента (видно в
+// мониторинге NATS). Недоступный при старте сервер не ошибка: клиент
+// переподключается в фоне.
+func NewNATSBroker(url, prefix, name string) (*NATSBroker, error) {
+	conn, err := nats.Connect(url,
+		nats.Name(name),
+		nats.RetryOnFailedConnect(true),
+		nats.MaxReconnects(-1),
+		nats.ReconnectWait(2*time.Second),
+		nats.DisconnectErrHandler(func(_ *nats.Conn, err error) {
+			if err != nil {
+				slog.Warn("Соединение с NATS потеряно, задания берутся опросом БД", slog.String("ошибка", err.Error()))
+			}
+		}),
+		nats.ReconnectHandler(func(c *nats.Conn) {
+			slog.Info("Соединение с NATS восстановлено", slog.String("сервер", c.ConnectedUrlRedacted()))
+		}),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &NATSBroker{conn: conn, prefix: prefix}, nil
+}
+
+// Publish — сообщение о готовом задании kind.
+func (b *NATSBroker) Publish(kind string) error {
+	return b.conn.Publish(b.prefix+"."+kind, nil)
+}
+
+// Subscribe — подписка группой NATSGroup на темы типов kinds.
+func (b *NATSBroker) Subscribe(kinds []string, fn func()) (func(), error) {
+	subs := make([]*nats.Subscription, 0, len(kinds))
+	unsubscribe := func() {
+		for _, sub := range subs {
+			sub.Unsubscribe()
+		}
+	}
+	for _, kind := range kinds {
+		sub, err := b.conn.QueueSubscribe(b.prefix+"."+kind, NATSGroup, func(*nats.Msg) { fn() })
+		if err != nil {
+			unsubscribe()
+			return nil, err
+		}
+		subs = append(subs, sub)
+	}
+	return unsubscribe, nil
+}
+
+// Close — отправляет накопленные сообщения и закрывает соединение.
+func (b *NATSBroker) Close() error {
+	err := b.conn.Drain()
+	if errors.Is(err, nats.ErrConnectionClosed) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,129 @@
+// Package kube — инспекция кластера Kubernetes для агента: поды,
+// деплойменты, события, describe ресурсов и хвост логов пода.
+//
+// Модуль работает напрямую с REST API сервера (без client-go) по
+// kubeconfig или учётной записи пода. В режиме только для чтения
+// (K8S_READONLY, по умолчанию включён) транспорт отклоняет любой запрос,
+// кроме GET, — даже если токен позволяет больше.
+package kube
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// ErrReadOnly — попытка изменяющего запроса в режиме только для чтения.
+var ErrReadOnly = errors.New("Kubernetes: режим только для чтения, разрешены лишь GET-запросы")
+
+// ErrNotFound — ресурс не найден.
+var ErrNotFound = errors.New("ресурс не найден")
+
+// maxBody — предел ответа API-сервера (списки в больших кластерах).
+const maxBody = 8 << 20
+
+// Client — клиент REST API Kubernetes.
+type Client struct {
+	cfg  *Config
+	http *http.Client
+}
+
+// New — клиент по конфигурации.
+func New(cfg *Config) *Client {
+	tr := http.DefaultTransport.(*http.Transport).Clone()
+	tr.TLSClientConfig = cfg.TLS
+	var rt http.RoundTripper = tr
+	if cfg.ReadOnly {
+		rt = readOnly{rt}
+	}
+	return &Client{cfg: cfg, http: &http.Client{Transport: rt, Timeout: 30 * time.Second}}
+}
+
+// Namespace — пространство имён по умолчанию.
+func (c *Client) Namespace() string { return c.cfg.Namespace }
+
+// Context — имя используемого контекста.
+func (c *Client) Context() string { return c.cfg.Context }
+
+// ReadOnly — включён ли режим только для чтения.
+func (c *Client) ReadOnly() bool { return c.cfg.ReadOnly }
+
+// readOnly — транспорт, пропускающий только GET.
+type readOnly struct{ next http.RoundTripper }
+
+func (t readOnly) RoundTrip(r *http.Request) (*http.Response, error) {
+	if r.Method != http.MethodGet {
+		return nil, ErrReadOnly
+	}
+	return t.next.RoundTrip(r)
+}
+
+// status — объект Status, которым API-сервер описывает ошибки.
+type status struct {
+	Kind    string `json:"kind"`
+	Message string `json:"message"`
+	Reason  string `json:"reason"`
+	Code    int    `json:"code"`
+}
+
+// get — GET по пути API с параметрами запроса; тело ответа целиком.
+func (c *Client) get(ctx context.Context, path string, query url.Values) ([]byte, error) {
+	u := c.cfg.Server + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	if c.cfg.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.cfg.Token)
+	} else if c.cfg.Username != "" {
+		req.SetBasicAuth(c.cfg.Username, c.cfg.Password)
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("Kubernetes API: %w", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxBody))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusOK {
+		return body, nil
+	}
+	var st status
+	msg := strings.TrimSpace(string(body))
+	if json.Unmarshal(body, &st) == nil && st.Kind == "Status" && st.Message != "" {
+		msg = st.Message
+	}
+	if len(msg) > 300 {
+		msg = msg[:300]
+	}
+	switch resp.StatusCode {
+	case http.StatusNotFound:
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, msg)
+	case http.StatusUnauthorized:
+		return nil, fmt.Errorf("Kubernetes API: не авторизован (проверьте токен или сертификат в kubeconfig): %s", msg)
+	case http.StatusForbidden:
+		return nil, fmt.Errorf("Kubernetes API: нет прав (RBAC): %s", msg)
+	}
+	return nil, fmt.Errorf("Kubernetes API: HTTP %d: %s", resp.StatusCode, msg)
+}
+
+// getJSON — GET с разбором JSON в v.
+func (c *Client) getJSON(ctx context.Context, path string, query url.Values, v interface{}) error {
+	body, err := c.get(ctx, path, query)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(body, v)
+}
@@ -0,0 +1,230 @@
+package kube
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Config — параметры доступа к API-серверу.
+type Config struct {
+	Server    string
+	Context   string // Имя контекста kubeconfig (или in-cluster)
+	Namespace string // Пространство имён по умолчанию
+	Token     string
+	Username  string
+	Password  string
+	TLS       *tls.Config
+	ReadOnly  bool // Разрешены только GET-запросы (K8S_READONLY, по умолчанию true)
+}
+
+// ErrNoConfig — не найден ни kubeconfig, ни учётная запись пода.
+var ErrNoConfig = errors.New("Kubernetes не настроен: нет kubeconfig (KUBECONFIG, ~/.kube/config) и агент запущен не в кластере")
+
+// kubeconfig — нужные поля файла kubeconfig.
+type kubeconfig struct {
+	CurrentContext string `yaml:"current-context"`
+	Clusters       []struct {
+		Name    string `yaml:"name"`
+		Cluster struct {
+			Server                   string `yaml:"server"`
+			CertificateAuthority     string `yaml:"certificate-authority"`
+			CertificateAuthorityData string `yaml:"certificate-authority-data"`
+			InsecureSkipTLSVerify    bool   `yaml:"insecure-skip-tls-verify"`
+			TLSServerName            string `yaml:"tls-server-name"`
+		} `yaml:"cluster"`
+	} `yaml:"clusters"`
+	Users []struct {
+		Name string `yaml:"name"`
+		User struct {
+			Token                 string    `yaml:"token"`
+			TokenFile             string    `yaml:"tokenFile"`
+			ClientCertificate     string    `yaml:"client-certificate"`
+			ClientCertificateData string    `yaml:"client-certificate-data"`
+			ClientKey             string    `yaml:"client-key"`
+			ClientKeyData         string    `yaml:"client-key-data"`
+			Username              string    `yaml:"username"`
+			Password              string    `yaml:"password"`
+			Exec                  *struct{} `yaml:"exec"`
+			AuthProvider          *struct{} `yaml:"auth-provider"`
+		} `yaml:"user"`
+	} `yaml:"users"`
+	Contexts []struct {
+		Name    string `yaml:"name"`
+		Context struct {
+			Cluster   string `yaml:"cluster"`
+			User      string `yaml:"user"`
+			Namespace string `yaml:"namespace"`
+		} `yaml:"context"`
+	} `yaml:"contexts"`
+}
+
+// LoadConfig — доступ из окружения: KUBECONFIG (первый файл списка) или
+// ~/.kube/config с контекстом K8S_CONTEXT (по умолчанию current-context),
+// иначе учётная запись пода (in-cluster). K8S_NAMESPACE переопределяет
+// пространство имён по умолчанию.
+func LoadConfig() (*Config, error) {
+	path := os.Getenv("KUBECONFIG")
+	if i := strings.IndexByte(path, os.PathListSeparator); i >= 0 {
+		path = path[:i]
+	}
+	if path == "" {
+		if home, err := os.UserHomeDir(); err == nil {
+			path = filepath.Join(home, ".kube", "config")
+		}
+	}
+	var cfg *Config
+	var err error
+	if _, statErr := os.Stat(path); statErr == nil {
+		cfg, err = LoadKubeconfig(path, os.Getenv("K8S_CONTEXT"))
+	} else {
+		cfg, err = loadInCluster("/var/run/secrets/kubernetes.io/serviceaccount")
+	}
+	if err != nil {
+		return nil, err
+	}
+	if ns := os.Getenv("K8S_NAMESPACE"); ns != "" {
+		cfg.Namespace = ns
+	}
+	cfg.ReadOnly = os.Getenv("K8S_READONLY") != "false"
+	return cfg, nil
+}
+
+// LoadKubeconfig — конфигурация из файла kubeconfig для контекста name
+// (пусто — current-context). Плагины exec/auth-provider (EKS, GKE) не
+// поддерживаются: для них нужен статический токен сервисного аккаунта.
+func LoadKubeconfig(path, name string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var kc kubeconfig
+	if err := yaml.Unmarshal(data, &kc); err != nil {
+		return nil, fmt.Errorf("kubeconfig %s: %w", path, err)
+	}
+	if name == "" {
+		name = kc.CurrentContext
+	}
+	cfg := &Config{Context: name, Namespace: "default", ReadOnly: true}
+	var clusterName, userName string
+	found := false
+	for _, c := range kc.Contexts {
+		if c.Name == name {
+			clusterName, userName, found = c.Context.Cluster, c.Context.User, true
+			if c.Context.Namespace != "" {
+				cfg.Namespace = c.Context.Namespace
+			}
+		}
+	}
+	if !found {
+		return nil, fmt.Errorf("kubeconfig %s: контекст %q не найден", path, name)
+	}
+	dir := filepath.Dir(path)
+	tlsCfg := &tls.Config{MinVersion: tls.VersionTLS12}
+	for _, c := range kc.Clusters {
+		if c.Name != clusterName {
+			continue
+		}
+		cfg.Server = strings.TrimRight(c.Cluster.Server, "/")
+		tlsCfg.InsecureSkipVerify = c.Cluster.InsecureSkipTLSVerify
+		tlsCfg.ServerName = c.Cluster.TLSServerName
+		ca, err := inlineOrFile(c.Cluster.CertificateAuthorityData, c.Cluster.CertificateAuthority, dir)
+		if err != nil {
+			return nil, fmt.Errorf("certificate-authority: %w", err)
+		}
+		if len(ca) > 0 {
+			pool := x509.NewCertPool()
+			if !pool.AppendCertsFromPEM(ca) {
+				return nil, errors.New("certificate-authority: не удалось разобрать PEM")
+			}
+			tlsCfg.RootCAs = pool
+		}
+	}
+	if cfg.Server == "" {
+		return nil, fmt.Errorf("kubeconfig %s: кластер %q не найден", path, clusterName)
+	}
+	for _, u := range kc.Users {
+		if u.Name != userName {
+			continue
+		}
+		if u.User.Exec != nil || u.User.AuthProvider != nil {
+			return nil, fmt.Errorf("пользователь %q использует exec/auth-provider — не поддерживается; создайте токен сервисного аккаунта (kubectl create token)", userName)
+		}
+		cfg.Token, cfg.Username, cfg.Password = u.User.Token, u.User.Username, u.User.Password
+		if u.User.TokenFile != "" {
+			tok, err := os.ReadFile(resolve(u.User.TokenFile, dir))
+			if err != nil {
+				return nil, fmt.Errorf("tokenFile: %w", err)
+			}
+			cfg.Token = strings.TrimSpace(string(tok))
+		}
+		cert, err := inlineOrFile(u.User.ClientCertificateData, u.User.ClientCertificate, dir)
+		if err != nil {
+			return nil, fmt.Errorf("client-certificate: %w", err)
+		}
+		key, err := inlineOrFile(u.User.ClientKeyData, u.User.ClientKey, dir)
+		if err != nil {
+			return nil, fmt.Errorf("client-key: %w", err)
+		}
+		if len(cert) > 0 && len(key) > 0 {
+			pair, err := tls.X509KeyPair(cert, key)
+			if err != nil {
+				return nil, fmt.Errorf("клиентский сертификат: %w", err)
+			}
+			tlsCfg.Certificates = []tls.Certificate{pair}
+		}
+	}
+	cfg.TLS = tlsCfg
+	return cfg, nil
+}
+
+// loadInCluster — токен и CA сервисного аккаунта пода.
+func loadInCluster(dir string) (*Config, error) {
+	host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+	token, err := os.ReadFile(filepath.Join(dir, "token"))
+	if host == "" || err != nil {
+		return nil, ErrNoConfig
+	}
+	cfg := &Config{
+		Server:    "https://" + host + ":" + port,
+		Context:   "in-cluster",
+		Namespace: "default",
+		Token:     strings.TrimSpace(string(token)),
+		TLS:       &tls.Config{MinVersion: tls.VersionTLS12},
+		ReadOnly:  true,
+	}
+	if ca, err := os.ReadFile(filepath.Join(dir, "ca.crt")); err == nil {
+		pool := x509.NewCertPool()
+		pool.AppendCertsFromPEM(ca)
+		cfg.TLS.RootCAs = pool
+	}
+	if ns, err := os.ReadFile(filepath.Join(dir, "namespace")); err == nil {
+		cfg.Namespace = strings.TrimSpace(string(ns))
+	}
+	return cfg, nil
+}
+
+func inlineOrFile(data, file, dir string) ([]byte, error) {
+	if data != "" {
+		return base64.StdEncoding.DecodeString(data)
+	}
+	if file != "" {
+		return os.ReadFile(resolve(file, dir))
+	}
+	return nil, nil
+}
+
+// resolve — относительные пути kubeconfig считаются от каталога файла.
+func resolve(path, dir string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(dir, path)
+}
@@ -0,0 +1,159 @@
+package kube
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeAPI — API-сервер с одним подом, деплойментом, событиями и ConfigMap.
+func fakeAPI(t *testing.T) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer tok" {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"kind":"Status","message":"Unauthorized","code":401}`))
+			return
+		}
+		q := r.URL.Query()
+		switch r.URL.Path {
+		case "/api/v1/namespaces/prod/pods":
+			if q.Get("labelSelector") != "app=web" {
+				t.Errorf("labelSelector %q", q.Get("labelSelector"))
+			}
+			w.Write([]byte(`{"items":[{"metadata":{"name":"web-1","namespace":"prod","creationTimestamp":"2024-01-01T00:00:00Z"},
+				"spec":{"nodeName":"n1","containers":[{"name":"app"},{"name":"sidecar"}]},
+				"status":{"phase":"Running","podIP":"10.0.0.7","containerStatuses":[
+					{"ready":true,"restartCount":0,"state":{"running":{}}},
+					{"ready":false,"restartCount":7,"state":{"waiting":{"reason":"CrashLoopBackOff"}}}]}}]}`))
+		case "/apis/apps/v1/deployments":
+			w.Write([]byte(`{"items":[{"metadata":{"name":"web","namespace":"prod","creationTimestamp":"2023-12-29T00:00:00Z"},
+				"spec":{"replicas":3,"template":{"spec":{"containers":[{"image":"web:1.2"}]}}},
+				"status":{"readyReplicas":2,"updatedReplicas":3,"availableReplicas":2}}]}`))
+		case "/api/v1/namespaces/prod/events":
+			w.Write([]byte(`{"items":[
+				{"metadata":{"namespace":"prod"},"involvedObject":{"kind":"Pod","name":"web-1"},"type":"Warning","reason":"BackOff","message":"Back-off restarting","count":7,"lastTimestamp":"2024-01-01T23:59:00Z"},
+				{"metadata":{"namespace":"prod"},"involvedObject":{"kind":"Pod","name":"web-1"},"type":"Normal","reason":"Pulled","message":"pulled","lastTimestamp":"2024-01-01T23:00:00Z"}]}`))
+		case "/api/v1/namespaces/prod/configmaps/settings":
+			w.Write([]byte(`{"apiVersion":"v1","kind":"ConfigMap","metadata":{"name":"settings","managedFields":[{}],
+				"annotations":{"kubectl.kubernetes.io/last-applied-configuration":"{}"}},"data":{"db_url":"postgres://u:p@db"}}`))
+		case "/api/v1/namespaces/prod/pods/web-1/log":
+			if q.Get("tailLines") != "20" || q.Get("container") != "app" || q.Get("previous") != "true" || q.Get("sinceSeconds") != "600" {
+				t.Errorf("параметры логов: %v", q)
+			}
+			w.Write([]byte("line1\nline2\n"))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"kind":"Status","message":"not found","reason":"NotFound","code":404}`))
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// TestClient — списки, describe и логи через REST API.
+func TestClient(t *testing.T) {
+	now = func() time.Time { return time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC) }
+	defer func() { now = time.Now }()
+	srv := fakeAPI(t)
+	c := New(&Config{Server: srv.URL, Token: "tok", Namespace: "prod", ReadOnly: true})
+	ctx := context.Background()
+
+	pods, err := c.ListPods(ctx, "", "app=web")
+	if err != nil || len(pods) != 1 {
+		t.Fatalf("ListPods: %+v, %v", pods, err)
+	}
+	if p := pods[0]; p.Ready != "1/2" || p.Restarts != 7 || p.Status != "CrashLoopBackOff" || p.Age != "24h" {
+		t.Errorf("под: %+v", p)
+	}
+
+	deps, err := c.ListDeployments(ctx, AllNamespaces, "")
+	if err != nil || len(deps) != 1 || deps[0].Ready != "2/3" || deps[0].Age != "4d" || deps[0].Images[0] != "web:1.2" {
+		t.Fatalf("ListDeployments: %+v, %v", deps, err)
+	}
+
+	events, err := c.ListEvents(ctx, "prod", EventFilter{Limit: 1})
+	if err != nil || len(events) != 1 || events[0].Reason != "BackOff" || events[0].Object != "pod/web-1" || events[0].Last != "1m" {
+		t.Fatalf("ListEvents: %+v, %v", events, err)
+	}
+
+	res, err := c.Describe(ctx, "cm", "settings", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	obj := res["object"].(map[string]interface{})
+	md := obj["metadata"].(map[string]interface{})
+	if _, ok := md["managedFields"]; ok || md["annotations"] != nil || obj["data"].(map[string]interface{})["db_url"] != "17 байт" {
+		t.Errorf("describe не очищен: %+v", obj)
+	}
+	if _, err := c.Describe(ctx, "secret", "db", ""); err == nil {
+		t.Error("Secret не должен читаться")
+	}
+	if _, err := c.Describe(ctx, "deploy", "missing", ""); !errors.Is(err, ErrNotFound) {
+		t.Errorf("ожидалась ErrNotFound, получено %v", err)
+	}
+
+	logs, err := c.Logs(ctx, "web-1", "", LogOptions{Container: "app", TailLines: 20, Since: 10 * time.Minute, Previous: true})
+	if err != nil || logs != "line1\nline2\n" {
+		t.Fatalf("Logs: %q, %v", logs, err)
+	}
+
+	bad := New(&Config{Server: srv.URL, Token: "wrong", Namespace: "prod"})
+	if _, err := bad.ListPods(ctx, "", ""); err == nil || !strings.Contains(err.Error(), "не авторизован") {
+		t.Errorf("неверный токен: %v", err)
+	}
+}
+
+// TestReadOnly — транспорт режима только для чтения пропускает лишь GET.
+func TestReadOnly(t *testing.T) {
+	srv := fakeAPI(t)
+	c := New(&Config{Server: srv.URL, Token: "tok", Namespace: "prod", ReadOnly: true})
+	req, _ := http.NewRequest(http.MethodDelete, srv.URL+"/api/v1/namespaces/prod/pods/web-1", nil)
+	if _, err := c.http.Do(req); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("ожидалась ErrReadOnly, получено %v", err)
+	}
+}
+
+// TestLoadKubeconfig — контекст, namespace, токен из файла и отказ для exec-плагинов.
+func TestLoadKubeconfig(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "token"), []byte("file-token\n"), 0o600)
+	path := filepath.Join(dir, "config")
+	os.WriteFile(path, []byte(`
+current-context: dev
+clusters:
+- name: c1
+  cluster: {server: "https://k8s.local:6443/", insecure-skip-tls-verify: true}
+users:
+- name: u1
+  user: {tokenFile: token}
+- name: eks
+  user:
+    exec: {command: aws}
+contexts:
+- name: dev
+  context: {cluster: c1, user: u1, namespace: apps}
+- name: cloud
+  context: {cluster: c1, user: eks}
+`), 0o600)
+
+	cfg, err := LoadKubeconfig(path, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Server != "https://k8s.local:6443" || cfg.Namespace != "apps" || cfg.Token != "file-token" || !cfg.TLS.InsecureSkipVerify {
+		t.Errorf("конфигурация: %+v", cfg)
+	}
+	if _, err := LoadKubeconfig(path, "cloud"); err == nil || !strings.Contains(err.Error(), "exec") {
+		t.Errorf("exec-плагин: %v", err)
+	}
+	if _, err := LoadKubeconfig(path, "missing"); err == nil {
+		t.Error("несуществующий контекст должен давать ошибку")
+	}
+}
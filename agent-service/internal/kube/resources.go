@@ -0,0 +1,457 @@
+package kube
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// now — текущее время (подменяется в тестах).
+var now = time.Now
+
+// AllNamespaces — значение namespace для выборки по всем пространствам имён.
+const AllNamespaces = "*"
+
+type meta struct {
+	Name              string            `json:"name"`
+	Namespace         string            `json:"namespace"`
+	CreationTimestamp time.Time         `json:"creationTimestamp"`
+	Labels            map[string]string `json:"labels"`
+}
+
+// Pod — сводка пода в стиле kubectl get pods -o wide.
+type Pod struct {
+	Name      string `json:"name"`
+	Namespace string `json:"namespace"`
+	Status    string `json:"status"`
+	Ready     string `json:"ready"`
+	Restarts  int    `json:"restarts"`
+	Node      string `json:"node,omitempty"`
+	IP        string `json:"ip,omitempty"`
+	Age       string `json:"age"`
+}
+
+type podList struct {
+	Items []struct {
+		Metadata meta `json:"metadata"`
+		Spec     struct {
+			NodeName   string `json:"nodeName"`
+			Containers []struct {
+				Name string `json:"name"`
+			} `json:"containers"`
+		} `json:"spec"`
+		Status struct {
+			Phase             string `json:"phase"`
+			Reason            string `json:"reason"`
+			PodIP             string `json:"podIP"`
+			ContainerStatuses []struct {
+				Ready        bool `json:"ready"`
+				RestartCount int  `json:"restartCount"`
+				State        struct {
+					Waiting *struct {
+						Reason string `json:"reason"`
+					} `json:"waiting"`
+					Terminated *struct {
+						Reason string `json:"reason"`
+					} `json:"terminated"`
+				} `json:"state"`
+			} `json:"containerStatuses"`
+		} `json:"status"`
+	} `json:"items"`
+}
+
+// ListPods — поды пространства имён ns (AllNamespaces — все) с фильтром
+// по меткам selector (синтаксис kubectl -l).
+func (c *Client) ListPods(ctx context.Context, ns, selector string) ([]Pod, error) {
+	var list podList
+	if err := c.getJSON(ctx, c.collection("/api/v1", "pods", ns), selectorQuery(selector), &list); err != nil {
+		return nil, err
+	}
+	pods := make([]Pod, 0, len(list.Items))
+	for _, it := range list.Items {
+		p := Pod{
+			Name:      it.Metadata.Name,
+			Namespace: it.Metadata.Namespace,
+			Status:    it.Status.Phase,
+			Node:      it.Spec.NodeName,
+			IP:        it.Status.PodIP,
+			Age:       age(it.Metadata.CreationTimestamp),
+		}
+		if it.Status.Reason != "" {
+			p.Status = it.Status.Reason
+		}
+		ready := 0
+		for _, cs := range it.Status.ContainerStatuses {
+			if cs.Ready {
+				ready++
+			}
+			p.Restarts += cs.RestartCount
+			// Причина ожидания/завершения контейнера точнее фазы пода
+			// (CrashLoopBackOff, ImagePullBackOff, OOMKilled).
+			if w := cs.State.Waiting; w != nil && w.Reason != "" {
+				p.Status = w.Reason
+			} else if t := cs.State.Terminated; t != nil && t.Reason != "" && !cs.Ready {
+				p.Status = t.Reason
+			}
+		}
+		p.Ready = fmt.Sprintf("%d/%d", ready, len(it.Spec.Containers))
+		pods = append(pods, p)
+	}
+	return pods, nil
+}
+
+// Deployment — сводка деплоймента в стиле kubectl get deploy -o wide.
+type Deployment struct {
+	Name      string   `json:"name"`
+	Namespace string   `json:"namespace"`
+	Ready     string   `json:"ready"`
+	UpToDate  int      `json:"up_to_date"`
+	Available int      `json:"available"`
+	Images    []string `json:"images"`
+	Age       string   `json:"age"`
+}
+
+type deploymentList struct {
+	Items []struct {
+		Metadata meta `json:"metadata"`
+		Spec     struct {
+			Replicas *int `json:"replicas"`
+			Template struct {
+				Spec struct {
+					Containers []struct {
+						Image string `json:"image"`
+					} `json:"containers"`
+				} `json:"spec"`
+			} `json:"template"`
+		} `json:"spec"`
+		Status struct {
+			ReadyReplicas     int `json:"readyReplicas"`
+			UpdatedReplicas   int `json:"updatedReplicas"`
+			AvailableReplicas int `json:"availableReplicas"`
+		} `json:"status"`
+	} `json:"items"`
+}
+
+// ListDeployments — деплойменты пространства имён ns (AllNamespaces — все).
+func (c *Client) ListDeployments(ctx context.Context, ns, selector string) ([]Deployment, error) {
+	var list deploymentList
+	if err := c.getJSON(ctx, c.collection("/apis/apps/v1", "deployments", ns), selectorQuery(selector), &list); err != nil {
+		return nil, err
+	}
+	out := make([]Deployment, 0, len(list.Items))
+	for _, it := range list.Items {
+		want := 1
+		if it.Spec.Replicas != nil {
+			want = *it.Spec.Replicas
+		}
+		d := Deployment{
+			Name:      it.Metadata.Name,
+			Namespace: it.Metadata.Namespace,
+			Ready:     fmt.Sprintf("%d/%d", it.Status.ReadyReplicas, want),
+			UpToDate:  it.Status.UpdatedReplicas,
+			Available: it.Status.AvailableReplicas,
+			Age:       age(it.Metadata.CreationTimestamp),
+		}
+		for _, ct := range it.Spec.Template.Spec.Containers {
+			d.Images = append(d.Images, ct.Image)
+		}
+		out = append(out, d)
+	}
+	return out, nil
+}
+
+// Event — событие кластера.
+type Event struct {
+	Type      string `json:"type"`
+	Reason    string `json:"reason"`
+	Object    string `json:"object"`
+	Namespace string `json:"namespace"`
+	Message   string `json:"message"`
+	Count     int    `json:"count"`
+	Last      string `json:"last_seen"`
+	at        time.Time
+}
+
+type eventList struct {
+	Items []struct {
+		Metadata       meta `json:"metadata"`
+		InvolvedObject struct {
+			Kind string `json:"kind"`
+			Name string `json:"name"`
+		} `json:"involvedObject"`
+		Type          string    `json:"type"`
+		Reason        string    `json:"reason"`
+		Message       string    `json:"message"`
+		Count         int       `json:"count"`
+		LastTimestamp time.Time `json:"lastTimestamp"`
+		EventTime     time.Time `json:"eventTime"`
+	} `json:"items"`
+}
+
+// EventFilter — отбор событий.
+type EventFilter struct {
+	Kind         string // Тип объекта (Pod, Deployment…), вместе с Name
+	Name         string // Имя объекта
+	WarningsOnly bool
+	Limit        int // Последние N событий (по умолчанию 50)
+}
+
+// ListEvents — события пространства имён ns, новые в конце (как kubectl
+// get events --sort-by=.lastTimestamp).
+func (c *Client) ListEvents(ctx context.Context, ns string, f EventFilter) ([]Event, error) {
+	var fields []string
+	if f.Name != "" {
+		fields = append(fields, "involvedObject.name="+f.Name)
+	}
+	if f.Kind != "" {
+		fields = append(fields, "involvedObject.kind="+f.Kind)
+	}
+	if f.WarningsOnly {
+		fields = append(fields, "type=Warning")
+	}
+	q := url.Values{}
+	if len(fields) > 0 {
+		q.Set("fieldSelector", strings.Join(fields, ","))
+	}
+	var list eventList
+	if err := c.getJSON(ctx, c.collection("/api/v1", "events", ns), q, &list); err != nil {
+		return nil, err
+	}
+	events := make([]Event, 0, len(list.Items))
+	for _, it := range list.Items {
+		at := it.LastTimestamp
+		if at.IsZero() {
+			at = it.EventTime
+		}
+		if at.IsZero() {
+			at = it.Metadata.CreationTimestamp
+		}
+		count := it.Count
+		if count == 0 {
+			count = 1
+		}
+		events = append(events, Event{
+			Type:      it.Type,
+			Reason:    it.Reason,
+			Object:    strings.ToLower(it.InvolvedObject.Kind) + "/" + it.InvolvedObject.Name,
+			Namespace: it.Metadata.Namespace,
+			Message:   strings.TrimSpace(it.Message),
+			Count:     count,
+			Last:      age(at),
+			at:        at,
+		})
+	}
+	sort.SliceStable(events, func(i, j int) bool { return events[i].at.Before(events[j].at) })
+	limit := f.Limit
+	if limit <= 0 {
+		limit = 50
+	}
+	if len(events) > limit {
+		events = events[len(events)-limit:]
+	}
+	return events, nil
+}
+
+// kind — описание типа ресурса для describe.
+type kind struct {
+	Kind       string // Имя типа в событиях (involvedObject.kind)
+	Group      string // Префикс API: /api/v1 или /apis/<группа>/<версия>
+	Plural     string
+	Namespaced bool
+}
+
+// kinds — поддерживаемые типы с сокращениями kubectl. Secret намеренно
+// отсутствует: агент не должен видеть содержимое секретов.
+var kinds = map[string]kind{}
+
+func init() {
+	for _, k := range []struct {
+		kind
+		aliases []string
+	}{
+		{kind{"Pod", "/api/v1", "pods", true}, []string{"pod", "po"}},
+		{kind{"Service", "/api/v1", "services", true}, []string{"service", "svc"}},
+		{kind{"ConfigMap", "/api/v1", "configmaps", true}, []string{"configmap", "cm"}},
+		{kind{"PersistentVolumeClaim", "/api/v1", "persistentvolumeclaims", true}, []string{"persistentvolumeclaim", "pvc"}},
+		{kind{"Node", "/api/v1", "nodes", false}, []string{"node", "no"}},
+		{kind{"Namespace", "/api/v1", "namespaces", false}, []string{"namespace", "ns"}},
+		{kind{"Deployment", "/apis/apps/v1", "deployments", true}, []string{"deployment", "deploy"}},
+		{kind{"StatefulSet", "/apis/apps/v1", "statefulsets", true}, []string{"statefulset", "sts"}},
+		{kind{"DaemonSet", "/apis/apps/v1", "daemonsets", true}, []string{"daemonset", "ds"}},
+		{kind{"ReplicaSet", "/apis/apps/v1", "replicasets", true}, []string{"replicaset", "rs"}},
+		{kind{"Job", "/apis/batch/v1", "jobs", true}, []string{"job"}},
+		{kind{"CronJob", "/apis/batch/v1", "cronjobs", true}, []string{"cronjob", "cj"}},
+		{kind{"Ingress", "/apis/networking.k8s.io/v1", "ingresses", true}, []string{"ingress", "ing"}},
+	} {
+		kinds[k.Plural] = k.kind
+		for _, a := range k.aliases {
+			kinds[a] = k.kind
+		}
+	}
+}
+
+// Kinds — поддерживаемые имена типов для describe (для подсказок).
+func Kinds() []string {
+	var out []string
+	for name, k := range kinds {
+		if name == strings.ToLower(k.Kind) {
+			out = append(out, name)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+// Describe — ресурс kind/name без служебного шума (managedFields,
+// last-applied-configuration) и связанные с ним события. Значения
+// ConfigMap заменяются их размером.
+func (c *Client) Describe(ctx context.Context, kindName, name, ns string) (map[string]interface{}, error) {
+	kindName = strings.ToLower(strings.TrimSpace(kindName))
+	if i := strings.IndexByte(name, '/'); i >= 0 && kindName == "" {
+		kindName, name = strings.ToLower(name[:i]), name[i+1:]
+	}
+	if kindName == "secret" || kindName == "secrets" {
+		return nil, errors.New("просмотр Secret запрещён")
+	}
+	k, ok := kinds[kindName]
+	if !ok {
+		return nil, fmt.Errorf("неизвестный тип %q: поддерживаются %s", kindName, strings.Join(Kinds(), ", "))
+	}
+	if name == "" {
+		return nil, errors.New("укажите имя ресурса")
+	}
+	if ns == "" || ns == AllNamespaces || !k.Namespaced {
+		ns = c.cfg.Namespace
+	}
+	path := k.Group + "/" + k.Plural + "/" + url.PathEscape(name)
+	if k.Namespaced {
+		path = k.Group + "/namespaces/" + url.PathEscape(ns) + "/" + k.Plural + "/" + url.PathEscape(name)
+	}
+	var obj map[string]interface{}
+	if err := c.getJSON(ctx, path, nil, &obj); err != nil {
+		return nil, err
+	}
+	trim(obj, k.Kind)
+	res := map[string]interface{}{"kind": k.Kind, "name": name, "object": obj}
+	if k.Namespaced {
+		res["namespace"] = ns
+	}
+	evNS := ns
+	if !k.Namespaced {
+		evNS = AllNamespaces
+	}
+	if events, err := c.ListEvents(ctx, evNS, EventFilter{Kind: k.Kind, Name: name, Limit: 20}); err == nil {
+		res["events"] = events
+	}
+	return res, nil
+}
+
+// trim — убирает из объекта поля, бесполезные для диагностики.
+func trim(obj map[string]interface{}, kind string) {
+	delete(obj, "apiVersion")
+	if m, ok := obj["metadata"].(map[string]interface{}); ok {
+		delete(m, "managedFields")
+		if ann, ok := m["annotations"].(map[string]interface{}); ok {
+			delete(ann, "kubectl.kubernetes.io/last-applied-configuration")
+			if len(ann) == 0 {
+				delete(m, "annotations")
+			}
+		}
+	}
+	if kind == "ConfigMap" {
+		for _, field := range []string{"data", "binaryData"} {
+			if data, ok := obj[field].(map[string]interface{}); ok {
+				for key, v := range data {
+					s, _ := v.(string)
+					data[key] = strconv.Itoa(len(s)) + " байт"
+				}
+			}
+		}
+	}
+}
+
+// LogOptions — параметры чтения логов пода.
+type LogOptions struct {
+	Container string
+	TailLines int // Последние N строк (по умолчанию 100, максимум 1000)
+	Since     time.Duration
+	Previous  bool // Логи предыдущего (упавшего) экземпляра контейнера
+}
+
+// maxLogBytes — предел объёма логов в ответе.
+const maxLogBytes = 256 << 10
+
+// Logs — хвост логов пода.
+func (c *Client) Logs(ctx context.Context, pod, ns string, o LogOptions) (string, error) {
+	if pod == "" {
+		return "", errors.New("укажите имя пода")
+	}
+	if ns == "" || ns == AllNamespaces {
+		ns = c.cfg.Namespace
+	}
+	tail := o.TailLines
+	if tail <= 0 {
+		tail = 100
+	}
+	if tail > 1000 {
+		tail = 1000
+	}
+	q := url.Values{}
+	q.Set("tailLines", strconv.Itoa(tail))
+	q.Set("limitBytes", strconv.Itoa(maxLogBytes))
+	if o.Container != "" {
+		q.Set("container", o.Container)
+	}
+	if o.Since > 0 {
+		q.Set("sinceSeconds", strconv.Itoa(int(o.Since.Seconds())))
+	}
+	if o.Previous {
+		q.Set("previous", "true")
+	}
+	body, err := c.get(ctx, "/api/v1/namespaces/"+url.PathEscape(ns)+"/pods/"+url.PathEscape(pod)+"/log", q)
+	if err != nil {
+		return "", err
+	}
+	return string(body), nil
+}
+
+// collection — путь к списку ресурсов в пространстве имён ns.
+func (c *Client) collection(group, plural, ns string) string {
+	if ns == AllNamespaces || ns == "all" {
+		return group + "/" + plural
+	}
+	if ns == "" {
+		ns = c.cfg.Namespace
+	}
+	return group + "/namespaces/" + url.PathEscape(ns) + "/" + plural
+}
+
+func selectorQuery(selector string) url.Values {
+	q := url.Values{}
+	if selector != "" {
+		q.Set("labelSelector", selector)
+	}
+	return q
+}
+
+// age — возраст в формате kubectl: 45s, 12m, 5h, 3d.
+func age(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	d := now().Sub(t)
+	switch {
+	case d < time.Minute:
+		return strconv.Itoa(int(d.Seconds())) + "s"
+	case d < time.Hour:
+		return strconv.Itoa(int(d.Minutes())) + "m"
+	case d < 48*time.Hour:
+		return strconv.Itoa(int(d.Hours())) + "h"
+	}
+	return strconv.Itoa(int(d.Hours()/24)) + "d"
+}
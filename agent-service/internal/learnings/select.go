@@ -0,0 +1,135 @@
+// Package learnings — отбор накопленных знаний модели перед подстановкой в промпт.
+//
+// memory-service возвращает кандидатов по семантической близости, но без отбора
+// они раздувают контекст: слабые совпадения, десятки однотипных фактов одной
+// категории и перефразированные дубликаты. Select оставляет только то, что
+// проходит порог релевантности, лимит на категорию и бюджет токенов.
+// Закреплённые пользователем знания (pinned) подставляются всегда.
+package learnings
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// DuplicateThreshold — коэффициент Жаккара по словам, начиная с которого
+// два знания считаются перефразированным дубликатом.
+const DuplicateThreshold = 0.8
+
+// Candidate — знание-кандидат из /learnings/search memory-service.
+type Candidate struct {
+	ID       string  `json:"id"`
+	Text     string  `json:"text"`
+	Score    float64 `json:"score"`
+	Category string  `json:"category"`
+	Pinned   bool    `json:"pinned"` // Закреплено пользователем — подставляется всегда
+}
+
+// Limits — параметры отбора (см. config.Tunable).
+type Limits struct {
+	MinScore       float64 // Кандидаты с меньшей оценкой отбрасываются
+	MaxPerCategory int     // Не больше стольких знаний одной категории (0 — без лимита)
+	TokenBudget    int     // Суммарный бюджет токенов на все знания (0 — без лимита)
+}
+
+// Result — отобранные знания и причины отсева остальных.
+type Result struct {
+	Selected []Candidate
+	Tokens   int            // Оценка токенов в Selected
+	Dropped  map[string]int // Причина → количество: low_score, duplicate, category_cap, budget
+}
+
+// Select — отбирает знания в порядке убывания оценки.
+// Закреплённые идут первыми и не проверяются порогом, лимитом категории и бюджетом
+// (но занимают его). Кандидат, не влезающий в бюджет, пропускается, но следующие
+// (более короткие) ещё могут поместиться.
+func Select(cands []Candidate, lim Limits) Result {
+	sorted := append([]Candidate(nil), cands...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sorted[i].Pinned != sorted[j].Pinned {
+			return sorted[i].Pinned
+		}
+		return sorted[i].Score > sorted[j].Score
+	})
+
+	res := Result{Dropped: map[string]int{}}
+	perCategory := map[string]int{}
+	var chosenWords []map[string]struct{}
+	for _, c := range sorted {
+		if c.Pinned && strings.TrimSpace(c.Text) != "" {
+			res.Selected = append(res.Selected, c)
+			res.Tokens += EstimateTokens(c.Text)
+			chosenWords = append(chosenWords, wordSet(c.Text))
+			continue
+		}
+		if strings.TrimSpace(c.Text) == "" || c.Score < lim.MinScore {
+			res.Dropped["low_score"]++
+			continue
+		}
+		words := wordSet(c.Text)
+		if isDuplicate(words, chosenWords) {
+			res.Dropped["duplicate"]++
+			continue
+		}
+		if lim.MaxPerCategory > 0 && perCategory[c.Category] >= lim.MaxPerCategory {
+			res.Dropped["category_cap"]++
+			continue
+		}
+		tokens := EstimateTokens(c.Text)
+		if lim.TokenBudget > 0 && res.Tokens+tokens > lim.TokenBudget {
+			res.Dropped["budget"]++
+			continue
+		}
+		res.Selected = append(res.Selected, c)
+		res.Tokens += tokens
+		perCategory[c.Category]++
+		chosenWords = append(chosenWords, words)
+	}
+	return res
+}
+
+// EstimateTokens — грубая оценка числа токенов: ~4 символа на токен.
+// Точный токенизатор зависит от модели, для бюджета достаточно порядка величины.
+func EstimateTokens(s string) int {
+	return (utf8.RuneCountInString(s) + 3) / 4
+}
+
+// NearDuplicate — тексты совпадают по словам не меньше чем на DuplicateThreshold.
+func NearDuplicate(a, b string) bool {
+	return jaccard(wordSet(a), wordSet(b)) >= DuplicateThreshold
+}
+
+func isDuplicate(words map[string]struct{}, chosen []map[string]struct{}) bool {
+	for _, w := range chosen {
+		if jaccard(words, w) >= DuplicateThreshold {
+			return true
+		}
+	}
+	return false
+}
+
+// wordSet — множество слов текста в нижнем регистре без знаков препинания.
+func wordSet(s string) map[string]struct{} {
+	set := map[string]struct{}{}
+	for _, w := range strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		set[w] = struct{}{}
+	}
+	return set
+}
+
+func jaccard(a, b map[string]struct{}) float64 {
+	if len(a) == 0 && len(b) == 0 {
+		return 1
+	}
+	inter := 0
+	for w := range a {
+		if _, ok := b[w]; ok {
+			inter++
+		}
+	}
+	return float64(inter) / float64(len(a)+len(b)-inter)
+}
@@ -0,0 +1,79 @@
+package learnings
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestSelect_ThresholdAndDuplicates — слабые совпадения и перефразированные дубликаты отбрасываются.
+func TestSelect_ThresholdAndDuplicates(t *testing.T) {
+	res := Select([]Candidate{
+		{Text: "Пользователь предпочитает ответы на русском языке", Score: 0.9, Category: "preference"},
+		{Text: "пользователь предпочитает ответы на русском языке!", Score: 0.85, Category: "preference"},
+		{Text: "Сервер PostgreSQL работает на порту 5432", Score: 0.7, Category: "fact"},
+		{Text: "Случайный слабо связанный факт", Score: 0.1, Category: "fact"},
+	}, Limits{MinScore: 0.3})
+
+	if len(res.Selected) != 2 {
+		t.Fatalf("ожидалось 2 знания, получено %d: %+v", len(res.Selected), res.Selected)
+	}
+	if res.Selected[0].Score != 0.9 || res.Selected[1].Category != "fact" {
+		t.Errorf("неверный порядок или состав: %+v", res.Selected)
+	}
+	if res.Dropped["duplicate"] != 1 || res.Dropped["low_score"] != 1 {
+		t.Errorf("причины отсева: %v", res.Dropped)
+	}
+}
+
+// TestSelect_CategoryCapAndBudget — лимит на категорию и бюджет токенов.
+func TestSelect_CategoryCapAndBudget(t *testing.T) {
+	long := strings.Repeat("длинный текст знания ", 40)
+	res := Select([]Candidate{
+		{Text: "первый факт о проекте", Score: 0.9, Category: "fact"},
+		{Text: "второй факт о сервере", Score: 0.8, Category: "fact"},
+		{Text: "третий факт о базе", Score: 0.7, Category: "fact"},
+		{Text: long, Score: 0.6, Category: "skill"},
+		{Text: "короткая ошибка", Score: 0.5, Category: "error"},
+	}, Limits{MaxPerCategory: 2, TokenBudget: 50})
+
+	var texts []string
+	for _, c := range res.Selected {
+		texts = append(texts, c.Text)
+	}
+	want := []string{"первый факт о проекте", "второй факт о сервере", "короткая ошибка"}
+	if strings.Join(texts, "|") != strings.Join(want, "|") {
+		t.Errorf("ожидалось %v, получено %v", want, texts)
+	}
+	if res.Dropped["category_cap"] != 1 || res.Dropped["budget"] != 1 {
+		t.Errorf("причины отсева: %v", res.Dropped)
+	}
+	if res.Tokens > 50 {
+		t.Errorf("превышен бюджет токенов: %d", res.Tokens)
+	}
+}
+
+// TestNearDuplicate — регистр и пунктуация не мешают распознать дубликат.
+func TestNearDuplicate(t *testing.T) {
+	if !NearDuplicate("Docker запускается через sudo.", "docker запускается через SUDO") {
+		t.Error("ожидался дубликат")
+	}
+	if NearDuplicate("Docker запускается через sudo", "Ollama слушает порт 11434") {
+		t.Error("разные знания не должны считаться дубликатами")
+	}
+}
+
+// TestSelect_Pinned — закреплённое знание попадает в промпт даже при низкой оценке и исчерпанном бюджете.
+func TestSelect_Pinned(t *testing.T) {
+	res := Select([]Candidate{
+		{Text: "обычный релевантный факт", Score: 0.9, Category: "fact"},
+		{Text: strings.Repeat("важное правило пользователя ", 10), Score: 0.05, Category: "fact", Pinned: true},
+		{Text: "Важное правило пользователя", Score: 0.8, Category: "fact"},
+	}, Limits{MinScore: 0.3, MaxPerCategory: 1, TokenBudget: 20})
+
+	if len(res.Selected) != 1 || !res.Selected[0].Pinned {
+		t.Fatalf("ожидалось только закреплённое знание, получено %+v", res.Selected)
+	}
+	if res.Dropped["budget"]+res.Dropped["category_cap"]+res.Dropped["duplicate"] != 2 {
+		t.Errorf("остальные кандидаты должны быть отсеяны: %v", res.Dropped)
+	}
+}
@@ -0,0 +1,142 @@
+// Package limiter — ограничение числа одновременных запросов с очередью.
+//
+// Limiter — семафор на N мест: запрос, которому не хватило места, ждёт в
+// очереди не дольше Wait; если очередь уже заполнена или ожидание истекло,
+// Acquire возвращает ErrSaturated, и обработчик отвечает 429 с Retry-After.
+// Group — отдельный Limiter на каждый ключ (провайдера LLM).
+package limiter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrSaturated — все места заняты, а очередь переполнена или ожидание истекло.
+var ErrSaturated = errors.New("слишком много одновременных запросов")
+
+// Limiter — не больше Limit одновременных запросов и MaxQueue ожидающих.
+// nil-Limiter пропускает всё.
+type Limiter struct {
+	slots    chan struct{}
+	maxQueue int64
+	wait     time.Duration
+	queued   atomic.Int64
+}
+
+// New — Limiter на limit мест; limit <= 0 — без ограничения (nil).
+// maxQueue — сколько запросов может ждать места, wait — сколько каждый ждёт.
+func New(limit, maxQueue int, wait time.Duration) *Limiter {
+	if limit <= 0 {
+		return nil
+	}
+	return &Limiter{slots: make(chan struct{}, limit), maxQueue: int64(maxQueue), wait: wait}
+}
+
+// Acquire — занимает место, при необходимости ожидая в очереди. release
+// освобождает место и безопасен для повторного вызова.
+func (l *Limiter) Acquire(ctx context.Context) (release func(), err error) {
+	if l == nil {
+		return func() {}, nil
+	}
+	select {
+	case l.slots <- struct{}{}:
+		return l.releaser(), nil
+	default:
+	}
+	if l.queued.Add(1) > l.maxQueue {
+		l.queued.Add(-1)
+		return nil, ErrSaturated
+	}
+	defer l.queued.Add(-1)
+	timer := time.NewTimer(l.wait)
+	defer timer.Stop()
+	select {
+	case l.slots <- struct{}{}:
+		return l.releaser(), nil
+	case <-timer.C:
+		return nil, ErrSaturated
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (l *Limiter) releaser() func() {
+	var once sync.Once
+	return func() { once.Do(func() { <-l.slots }) }
+}
+
+// InUse — занятые места.
+func (l *Limiter) InUse() int {
+	if l == nil {
+		return 0
+	}
+	return len(l.slots)
+}
+
+// Queued — запросы, ожидающие места.
+func (l *Limiter) Queued() int {
+	if l == nil {
+		return 0
+	}
+	return int(l.queued.Load())
+}
+
+// Group — Limiter на каждый ключ: лимит из Limits, для прочих ключей — Default.
+type Group struct {
+	Limits   map[string]int
+	Default  int
+	MaxQueue int
+	Wait     time.Duration
+
+	mu       sync.Mutex
+	limiters map[string]*Limiter
+}
+
+// Get — Limiter ключа (nil — ключ без ограничения).
+func (g *Group) Get(key string) *Limiter {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if l, ok := g.limiters[key]; ok {
+		return l
+	}
+	limit, ok := g.Limits[key]
+	if !ok {
+		limit = g.Default
+	}
+	if g.limiters == nil {
+		g.limiters = make(map[string]*Limiter)
+	}
+	l := New(limit, g.MaxQueue, g.Wait)
+	g.limiters[key] = l
+	return l
+}
+
+// Acquire — место в Limiter ключа.
+func (g *Group) Acquire(ctx context.Context, key string) (func(), error) {
+	return g.Get(key).Acquire(ctx)
+}
+
+// ParseLimits — лимиты вида "ollama=2,openrouter=8" (ключ=число через запятую).
+func ParseLimits(s string) (map[string]int, error) {
+	limits := make(map[string]int)
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		key, val, ok := strings.Cut(part, "=")
+		n, err := strconv.Atoi(strings.TrimSpace(val))
+		key = strings.TrimSpace(key)
+		if !ok || key == "" || err != nil || n < 0 {
+			return nil, fmt.Errorf("%q: ожидается ключ=число не меньше нуля", part)
+		}
+		limits[key] = n
+	}
+	return limits, nil
+}
@@ -0,0 +1,77 @@
+package limiter
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// TestLimiterQueue — ожидающий получает освободившееся место, лишний — ErrSaturated.
+func TestLimiterQueue(t *testing.T) {
+	l := New(1, 1, time.Second)
+	release, err := l.Acquire(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := make(chan error, 1)
+	go func() {
+		r, err := l.Acquire(context.Background())
+		if err == nil {
+			r()
+		}
+		got <- err
+	}()
+	for l.Queued() != 1 {
+		time.Sleep(time.Millisecond)
+	}
+	if _, err := l.Acquire(context.Background()); !errors.Is(err, ErrSaturated) {
+		t.Errorf("очередь полна: ожидалась ErrSaturated, получено %v", err)
+	}
+	release()
+	release()
+	if err := <-got; err != nil {
+		t.Errorf("ожидающий не получил место: %v", err)
+	}
+	if l.InUse() != 0 {
+		t.Errorf("занято мест: %d", l.InUse())
+	}
+}
+
+// TestLimiterTimeout — ожидание в очереди ограничено Wait и контекстом.
+func TestLimiterTimeout(t *testing.T) {
+	l := New(1, 5, 10*time.Millisecond)
+	if _, err := l.Acquire(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := l.Acquire(context.Background()); !errors.Is(err, ErrSaturated) {
+		t.Errorf("ожидание истекло: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := l.Acquire(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("контекст отменён: %v", err)
+	}
+	if l.Queued() != 0 {
+		t.Errorf("в очереди осталось %d", l.Queued())
+	}
+}
+
+// TestGroupAndParse — лимиты по ключам из строки, 0 — без ограничения.
+func TestGroupAndParse(t *testing.T) {
+	limits, err := ParseLimits(" ollama=2, openrouter=0 ,")
+	if err != nil || limits["ollama"] != 2 || len(limits) != 2 {
+		t.Fatalf("%v %v", limits, err)
+	}
+	if _, err := ParseLimits("ollama"); err == nil {
+		t.Error("без числа — ошибка")
+	}
+	g := &Group{Limits: limits, Default: 4}
+	if g.Get("openrouter") != nil || cap(g.Get("ollama").slots) != 2 || cap(g.Get("cerebras").slots) != 4 {
+		t.Error("лимиты группы")
+	}
+	if g.Get("ollama") != g.Get("ollama") {
+		t.Error("Limiter ключа создаётся один раз")
+	}
+}
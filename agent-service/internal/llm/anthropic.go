@@ -7,6 +7,9 @@ import (
 	"io"
 	"net/http"
 	"time"
+
+	"github.com/neo-2022/openclaw-memory/agent-service/internal/httpclient"
+	"github.com/neo-2022/openclaw-memory/agent-service/internal/retry"
 )
 
 // AnthropicProvider — провайдер для облачных моделей Anthropic (Claude).
@@ -33,7 +36,7 @@ func NewAnthropicProvider(apiKey, baseURL string) *AnthropicProvider {
 	return &AnthropicProvider{
 		APIKey:  apiKey,
 		BaseURL: baseURL,
-		HTTP:    &http.Client{Timeout: 120 * time.Second},
+		HTTP:    httpclient.New("anthropic", 120*time.Second),
 	}
 }
 
@@ -49,13 +52,15 @@ type anthropicRequest struct {
 	System    string             `json:"system,omitempty"` // Системный промпт (отдельно от сообщений)
 	Messages  []anthropicMessage `json:"messages"`         // Массив сообщений диалога
 	Tools     []anthropicTool    `json:"tools,omitempty"`  // Доступные инструменты
+	// Temperature — температура генерации 0..1 (nil — значение API по умолчанию)
+	Temperature *float64 `json:"temperature,omitempty"`
 }
 
 // anthropicMessage — сообщение в формате Anthropic API.
 // Поддерживает роли: user и assistant (system передаётся отдельно).
 type anthropicMessage struct {
 	Role    string `json:"role"`    // Роль: user или assistant
-	Content string `json:"content"` // Текст сообщения
+	Content any    `json:"content"` // Текст или блоки image/text (см. anthropicContent)
 }
 
 // anthropicTool — описание инструмента в формате Anthropic.
@@ -105,8 +110,12 @@ func (p *AnthropicProvider) Chat(req *ChatRequest) (*ChatResponse, error) {
 	var msgs []anthropicMessage
 	for _, m := range req.Messages {
 		if m.Role == "system" {
-			// Anthropic требует системный промпт отдельным полем
-			systemPrompt = m.Content
+			// Anthropic требует системный промпт отдельным полем; системные
+			// заметки посреди диалога дописываются к нему
+			if systemPrompt != "" {
+				systemPrompt += "\n\n"
+			}
+			systemPrompt += m.Content
 			continue
 		}
 		role := m.Role
@@ -116,7 +125,7 @@ func (p *AnthropicProvider) Chat(req *ChatRequest) (*ChatResponse, error) {
 		}
 		msgs = append(msgs, anthropicMessage{
 			Role:    role,
-			Content: m.Content,
+			Content: anthropicContent(m),
 		})
 	}
 
@@ -137,6 +146,8 @@ func (p *AnthropicProvider) Chat(req *ChatRequest) (*ChatResponse, error) {
 		System:    systemPrompt,
 		Messages:  msgs,
 		Tools:     aTools,
+
+		Temperature: req.Temperature,
 	}
 
 	data, err := json.Marshal(aReq)
@@ -162,7 +173,7 @@ func (p *AnthropicProvider) Chat(req *ChatRequest) (*ChatResponse, error) {
 
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("Anthropic HTTP %d: %s", resp.StatusCode, translateProviderError(resp.StatusCode, string(body)))
+		return nil, retry.FromResponse("Anthropic", resp, translateProviderError(resp.StatusCode, string(body)))
 	}
 
 	var aResp anthropicResponse
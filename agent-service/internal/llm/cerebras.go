@@ -8,12 +8,17 @@ import (
 	"net/http"
 	"strings"
 	"time"
+
+	"github.com/neo-2022/openclaw-memory/agent-service/internal/httpclient"
+	"github.com/neo-2022/openclaw-memory/agent-service/internal/retry"
 )
 
 type CerebrasProvider struct {
 	APIKey  string
 	BaseURL string
 	HTTP    *http.Client
+
+	limits rateLimits // Лимиты из заголовков последнего ответа, см. Quota
 }
 
 func NewCerebrasProvider(apiKey, baseURL string) *CerebrasProvider {
@@ -23,7 +28,7 @@ func NewCerebrasProvider(apiKey, baseURL string) *CerebrasProvider {
 	return &CerebrasProvider{
 		APIKey:  apiKey,
 		BaseURL: baseURL,
-		HTTP:    &http.Client{Timeout: 120 * time.Second},
+		HTTP:    httpclient.New("cerebras", 120*time.Second),
 	}
 }
 
@@ -70,7 +75,9 @@ func (p *CerebrasProvider) Chat(req *ChatRequest) (*ChatResponse, error) {
 		Model:    req.Model,
 		Messages: msgs,
 		Tools:    oaiTools,
-		Stream:   false,
+		Stream:   req.Stream,
+
+		Temperature: req.Temperature,
 	}
 
 	data, err := json.Marshal(oaiReq)
@@ -90,10 +97,15 @@ func (p *CerebrasProvider) Chat(req *ChatRequest) (*ChatResponse, error) {
 		return nil, fmt.Errorf("ошибка отправки запроса к Cerebras: %w", err)
 	}
 	defer resp.Body.Close()
+	p.limits.observe(p.Name(), resp.Header)
 
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("Cerebras HTTP %d: %s", resp.StatusCode, translateProviderError(resp.StatusCode, string(body)))
+		return nil, retry.FromResponse("Cerebras", resp, translateProviderError(resp.StatusCode, string(body)))
+	}
+
+	if oaiReq.Stream {
+		return readOpenAIStream(resp.Body, req.OnDelta)
 	}
 
 	var oaiResp openaiResponse
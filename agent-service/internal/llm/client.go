@@ -7,6 +7,8 @@ import (
 	"io"
 	"net/http"
 	"strings"
+
+	"github.com/neo-2022/openclaw-memory/agent-service/internal/httpclient"
 )
 
 // OllamaRequest представляет запрос к Ollama API.
@@ -17,6 +19,10 @@ type OllamaRequest struct {
 	Stream   bool                   `json:"stream"`
 	Tools    []Tool                 `json:"tools,omitempty"`   // описание инструментов для модели
 	Options  map[string]interface{} `json:"options,omitempty"` // параметры генерации (num_ctx, temperature и др.)
+	// KeepAlive — время удержания модели в памяти после запроса ("30m", "-1")
+	KeepAlive string `json:"keep_alive,omitempty"`
+	// Format — JSON Schema ответа: Ollama ограничивает генерацию грамматикой по схеме
+	Format json.RawMessage `json:"format,omitempty"`
 }
 
 // Message представляет одно сообщение в диалоге
@@ -25,6 +31,8 @@ type Message struct {
 	Content    string     `json:"content"`
 	ToolCalls  []ToolCall `json:"tool_calls,omitempty"`
 	ToolCallID string     `json:"tool_call_id,omitempty"`
+	Images     []string   `json:"images,omitempty"`   // Изображения в base64 (формат Ollama; для других провайдеров конвертируются)
+	Thinking   string     `json:"thinking,omitempty"` // Размышления модели отдельно от ответа (Ollama, только в ответе)
 }
 
 // ToolCall представляет вызов инструмента от модели
@@ -59,6 +67,29 @@ type OllamaResponse struct {
 	CreatedAt string  `json:"created_at"`
 	Message   Message `json:"message"`
 	Done      bool    `json:"done"`
+
+	// Статистика генерации (в наносекундах), приходит в последнем чанке
+	TotalDuration      int64 `json:"total_duration,omitempty"`
+	LoadDuration       int64 `json:"load_duration,omitempty"`
+	PromptEvalCount    int   `json:"prompt_eval_count,omitempty"`
+	PromptEvalDuration int64 `json:"prompt_eval_duration,omitempty"`
+	EvalCount          int   `json:"eval_count,omitempty"`
+	EvalDuration       int64 `json:"eval_duration,omitempty"`
+}
+
+// stats — статистика генерации Ollama в общем формате (nil, если её нет).
+func (r *OllamaResponse) stats() *GenerationStats {
+	if r.EvalCount == 0 && r.TotalDuration == 0 {
+		return nil
+	}
+	return &GenerationStats{
+		PromptTokens:     r.PromptEvalCount,
+		CompletionTokens: r.EvalCount,
+		LoadMs:           float64(r.LoadDuration) / 1e6,
+		PromptEvalMs:     float64(r.PromptEvalDuration) / 1e6,
+		EvalMs:           float64(r.EvalDuration) / 1e6,
+		TotalMs:          float64(r.TotalDuration) / 1e6,
+	}
 }
 
 // translateProviderError — переводит ошибки от облачных LLM-провайдеров на русский язык.
@@ -148,7 +179,7 @@ func NewClient(baseURL string) *Client {
 	}
 	return &Client{
 		BaseURL: baseURL,
-		HTTP:    &http.Client{},
+		HTTP:    httpclient.New("ollama", 0),
 	}
 }
 
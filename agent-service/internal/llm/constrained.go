@@ -0,0 +1,114 @@
+package llm
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// FinalAnswerTool — псевдо-инструмент итогового ответа при ограниченной
+// генерации: модель обязана вернуть вызов, и текст для пользователя тоже
+// приходит вызовом {"name": "final_answer", "arguments": {"text": "..."}}.
+const FinalAnswerTool = "final_answer"
+
+// ToolCallSchema — JSON Schema ответа модели без tool calling: ровно один
+// объект {"name", "arguments"}, где name — один из инструментов или
+// FinalAnswerTool, а arguments соответствуют схеме параметров этого инструмента.
+// Ollama превращает схему в грамматику (поле format), и модель физически не
+// может ответить ничем другим.
+func ToolCallSchema(tools []Tool) json.RawMessage {
+	variants := make([]map[string]interface{}, 0, len(tools)+1)
+	for _, t := range tools {
+		params := t.Function.Parameters
+		if params == nil {
+			params = map[string]interface{}{"type": "object"}
+		}
+		variants = append(variants, callSchema(t.Function.Name, params))
+	}
+	variants = append(variants, callSchema(FinalAnswerTool, map[string]interface{}{
+		"type":       "object",
+		"properties": map[string]interface{}{"text": map[string]interface{}{"type": "string"}},
+		"required":   []string{"text"},
+	}))
+	data, _ := json.Marshal(map[string]interface{}{"oneOf": variants})
+	return data
+}
+
+func callSchema(name string, params interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"name":      map[string]interface{}{"const": name},
+			"arguments": params,
+		},
+		"required": []string{"name", "arguments"},
+	}
+}
+
+// constrainedPrompt — описание инструментов для системного сообщения: схема
+// ограничивает форму ответа, но назначение инструментов модель узнаёт из текста.
+func constrainedPrompt(tools []Tool) string {
+	var b strings.Builder
+	b.WriteString("Ты отвечаешь только JSON-объектом {\"name\": ..., \"arguments\": {...}}.\n")
+	b.WriteString("Чтобы выполнить действие, вызови один из инструментов. Результат придёт следующим сообщением.\n")
+	fmt.Fprintf(&b, "Когда задача решена или инструмент не нужен, ответь пользователю: {\"name\": %q, \"arguments\": {\"text\": \"ответ\"}}.\n\nИнструменты:\n", FinalAnswerTool)
+	for _, t := range tools {
+		params, _ := json.Marshal(t.Function.Parameters)
+		fmt.Fprintf(&b, "- %s: %s Параметры: %s\n", t.Function.Name, t.Function.Description, params)
+	}
+	return b.String()
+}
+
+// constrainMessages — история для модели без tool calling: описание
+// инструментов добавляется к системному сообщению, вызовы ассистента
+// записываются JSON-текстом, результаты инструментов — сообщениями user
+// (шаблоны таких моделей роли tool не знают).
+func constrainMessages(msgs []Message, tools []Tool) []Message {
+	prompt := constrainedPrompt(tools)
+	out := make([]Message, 0, len(msgs)+1)
+	if len(msgs) == 0 || msgs[0].Role != "system" {
+		out = append(out, Message{Role: "system", Content: prompt})
+	}
+	for i, m := range msgs {
+		switch {
+		case i == 0 && m.Role == "system":
+			m.Content = strings.TrimSpace(m.Content + "\n\n" + prompt)
+		case m.Role == "assistant" && len(m.ToolCalls) > 0:
+			tc := m.ToolCalls[0].Function
+			args := tc.Arguments
+			if len(args) == 0 {
+				args = json.RawMessage("{}")
+			}
+			data, _ := json.Marshal(map[string]interface{}{"name": tc.Name, "arguments": args})
+			m = Message{Role: "assistant", Content: string(data)}
+		case m.Role == "assistant":
+			data, _ := json.Marshal(map[string]interface{}{"name": FinalAnswerTool, "arguments": map[string]string{"text": m.Content}})
+			m = Message{Role: "assistant", Content: string(data)}
+		case m.Role == "tool":
+			m = Message{Role: "user", Content: "Результат инструмента:\n" + m.Content}
+		}
+		out = append(out, m)
+	}
+	return out
+}
+
+// parseConstrained — ответ по схеме ToolCallSchema: текст FinalAnswerTool
+// или структурированный вызов инструмента. Ответ не по схеме (обрыв
+// генерации) возвращается как есть.
+func parseConstrained(content string) (string, []ToolCall) {
+	var call struct {
+		Name      string          `json:"name"`
+		Arguments json.RawMessage `json:"arguments"`
+	}
+	if json.Unmarshal([]byte(strings.TrimSpace(content)), &call) != nil || call.Name == "" {
+		return content, nil
+	}
+	if call.Name == FinalAnswerTool {
+		var answer struct {
+			Text string `json:"text"`
+		}
+		json.Unmarshal(call.Arguments, &answer)
+		return answer.Text, nil
+	}
+	return "", []ToolCall{{ID: "call_0", Type: "function", Function: FunctionCall{Name: call.Name, Arguments: call.Arguments}}}
+}
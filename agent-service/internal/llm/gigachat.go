@@ -13,6 +13,9 @@ import (
 	"strings"
 	"sync"
 	"time"
+
+	"github.com/neo-2022/openclaw-memory/agent-service/internal/httpclient"
+	"github.com/neo-2022/openclaw-memory/agent-service/internal/retry"
 )
 
 // GigaChatProvider — провайдер для облачных моделей GigaChat от Сбера.
@@ -57,15 +60,18 @@ func NewGigaChatProvider(clientID, clientSecret, scope, baseURL string) *GigaCha
 		Scope:        scope,
 		BaseURL:      baseURL,
 		AuthURL:      "https://ngw.devices.sberbank.ru:9443/api/v2/oauth",
-		HTTP: &http.Client{
-			Timeout: 120 * time.Second,
-			Transport: &http.Transport{
-				TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
-			},
-		},
+		HTTP:         httpclient.NewWithTransport("gigachat", gigachatTransport(), 120*time.Second),
 	}
 }
 
+// gigachatTransport — транспорт с параметрами общего пула без проверки
+// сертификата Сбера.
+func gigachatTransport() *http.Transport {
+	t := httpclient.Transport().Clone()
+	t.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+	return t
+}
+
 // Name — возвращает имя провайдера ("gigachat").
 func (p *GigaChatProvider) Name() string { return "gigachat" }
 
@@ -145,6 +151,8 @@ type gigachatRequest struct {
 	Model    string            `json:"model"`    // Имя модели (GigaChat, GigaChat-Plus, GigaChat-Pro, GigaChat-Max)
 	Messages []gigachatMessage `json:"messages"` // Массив сообщений диалога
 	Stream   bool              `json:"stream"`   // Режим стриминга (пока не используется)
+	// Temperature — температура генерации (nil — значение API по умолчанию)
+	Temperature *float64 `json:"temperature,omitempty"`
 }
 
 // gigachatMessage — сообщение в формате GigaChat API.
@@ -214,6 +222,8 @@ func (p *GigaChatProvider) Chat(req *ChatRequest) (*ChatResponse, error) {
 		Model:    req.Model,
 		Messages: msgs,
 		Stream:   false,
+
+		Temperature: req.Temperature,
 	}
 
 	data, err := json.Marshal(gReq)
@@ -238,7 +248,7 @@ func (p *GigaChatProvider) Chat(req *ChatRequest) (*ChatResponse, error) {
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
 		log.Printf("GigaChat: ошибка HTTP %d: %s", resp.StatusCode, string(body))
-		return nil, fmt.Errorf("GigaChat HTTP %d: %s", resp.StatusCode, translateProviderError(resp.StatusCode, string(body)))
+		return nil, retry.FromResponse("GigaChat", resp, translateProviderError(resp.StatusCode, string(body)))
 	}
 
 	var gResp gigachatResponse
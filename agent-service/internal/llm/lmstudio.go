@@ -29,6 +29,8 @@ import (
 	"log"
 	"net/http"
 	"time"
+
+	"github.com/neo-2022/openclaw-memory/agent-service/internal/httpclient"
 )
 
 // LMStudioProvider — провайдер для запуска моделей через LM Studio.
@@ -65,7 +67,7 @@ func NewLMStudioProvider(apiKey, baseURL string) *LMStudioProvider {
 		OpenRouterProvider: &OpenRouterProvider{
 			APIKey:  apiKey,
 			BaseURL: baseURL,
-			HTTP:    &http.Client{Timeout: 120 * time.Second},
+			HTTP:    httpclient.New("lmstudio", 120*time.Second),
 			AppName: "AgentCore-NG",
 		},
 	}
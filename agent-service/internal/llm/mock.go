@@ -0,0 +1,153 @@
+package llm
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ErrMockExhausted — сценарий MockProvider закончился, а модель вызвана ещё раз.
+var ErrMockExhausted = errors.New("mock: сценарий ответов закончился")
+
+// MockResponse — один шаг сценария MockProvider: текст, вызовы инструментов
+// или ошибка провайдера.
+type MockResponse struct {
+	Content   string
+	ToolCalls []ToolCall
+	Err       error
+}
+
+// MockText — шаг сценария с текстовым ответом.
+func MockText(content string) MockResponse {
+	return MockResponse{Content: content}
+}
+
+// MockToolCall — вызов инструмента в структурированном формате (tool_calls, как OpenAI).
+func MockToolCall(name string, args map[string]interface{}) MockResponse {
+	data, _ := json.Marshal(args)
+	return MockResponse{ToolCalls: []ToolCall{{
+		ID:       "call_" + name,
+		Type:     "function",
+		Function: FunctionCall{Name: name, Arguments: data},
+	}}}
+}
+
+// MockJSONToolCall — вызов инструмента JSON-объектом в тексте ответа:
+// {"name": "...", "arguments": {...}}.
+func MockJSONToolCall(name string, args map[string]interface{}) MockResponse {
+	data, _ := json.Marshal(map[string]interface{}{"name": name, "arguments": args})
+	return MockResponse{Content: string(data)}
+}
+
+// MockXMLToolCall — вызов инструмента в XML-формате nemotron:
+// <tool_call><function=имя><parameter=ключ>значение</parameter></function></tool_call>.
+// Значения передаются строками.
+func MockXMLToolCall(name string, args map[string]interface{}) MockResponse {
+	keys := make([]string, 0, len(args))
+	for k := range args {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var b strings.Builder
+	fmt.Fprintf(&b, "<tool_call><function=%s>", name)
+	for _, k := range keys {
+		fmt.Fprintf(&b, "<parameter=%s>%v</parameter>", k, args[k])
+	}
+	b.WriteString("</function></tool_call>")
+	return MockResponse{Content: b.String()}
+}
+
+// MockInlineToolCall — вызов инструмента текстом вида имя{"ключ":"значение"} (devstral).
+func MockInlineToolCall(name string, args map[string]interface{}) MockResponse {
+	data, _ := json.Marshal(args)
+	return MockResponse{Content: name + string(data)}
+}
+
+// MockProvider — провайдер для тестов: отвечает по сценарию без сети и
+// запоминает полученные запросы. Без сценария на любой запрос отвечает
+// "mock response".
+type MockProvider struct {
+	name   string
+	models []string
+	err    error // Ошибка на любой вызов
+
+	mu       sync.Mutex
+	script   []MockResponse
+	scripted bool
+	requests []ChatRequest
+}
+
+// NewMockProvider — провайдер name, отвечающий шагами script по порядку.
+func NewMockProvider(name string, script ...MockResponse) *MockProvider {
+	return &MockProvider{name: name, models: []string{"mock-model"}, script: script, scripted: len(script) > 0}
+}
+
+// Name — имя провайдера в реестре.
+func (m *MockProvider) Name() string {
+	return m.name
+}
+
+// Chat — следующий шаг сценария; запрос сохраняется (см. Requests).
+func (m *MockProvider) Chat(req *ChatRequest) (*ChatResponse, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	saved := *req
+	saved.Messages = append([]Message(nil), req.Messages...)
+	saved.OnDelta = nil
+	m.requests = append(m.requests, saved)
+	if m.err != nil {
+		return nil, m.err
+	}
+	if !m.scripted {
+		return &ChatResponse{Content: "mock response", Model: m.name}, nil
+	}
+	if len(m.script) == 0 {
+		return nil, ErrMockExhausted
+	}
+	step := m.script[0]
+	m.script = m.script[1:]
+	if step.Err != nil {
+		return nil, step.Err
+	}
+	if req.Stream && req.OnDelta != nil && step.Content != "" {
+		req.OnDelta(step.Content)
+	}
+	return &ChatResponse{Content: step.Content, ToolCalls: step.ToolCalls, Model: req.Model}, nil
+}
+
+// ListModels — модели провайдера.
+func (m *MockProvider) ListModels() ([]string, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+	return m.models, nil
+}
+
+// ListModelsDetailed — модели провайдера, все доступны.
+func (m *MockProvider) ListModelsDetailed() ([]ModelDetail, error) {
+	var details []ModelDetail
+	for _, model := range m.models {
+		details = append(details, ModelDetail{
+			ID:          model,
+			IsAvailable: true,
+		})
+	}
+	return details, nil
+}
+
+// Requests — запросы, полученные провайдером, по порядку.
+func (m *MockProvider) Requests() []ChatRequest {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]ChatRequest(nil), m.requests...)
+}
+
+// Remaining — сколько шагов сценария не использовано.
+func (m *MockProvider) Remaining() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.script)
+}
@@ -8,6 +8,9 @@ import (
 	"net/http"
 	"strings"
 	"time"
+
+	"github.com/neo-2022/openclaw-memory/agent-service/internal/httpclient"
+	"github.com/neo-2022/openclaw-memory/agent-service/internal/retry"
 )
 
 // OllamaProvider — провайдер для локальных моделей через Ollama.
@@ -28,7 +31,7 @@ func NewOllamaProvider(baseURL string) *OllamaProvider {
 	}
 	return &OllamaProvider{
 		BaseURL: baseURL,
-		HTTP:    &http.Client{Timeout: 5 * time.Minute},
+		HTTP:    httpclient.New("ollama", 5*time.Minute),
 	}
 }
 
@@ -51,6 +54,18 @@ func (p *OllamaProvider) Chat(req *ChatRequest) (*ChatResponse, error) {
 		Options: map[string]interface{}{
 			"num_ctx": 8192,
 		},
+		KeepAlive: req.KeepAlive,
+	}
+	if req.Temperature != nil {
+		ollamaReq.Options["temperature"] = *req.Temperature
+	}
+	// Модель без tool calling: инструменты в промпте, ответ — по схеме вызова
+	constrained := req.ConstrainTools && len(req.Tools) > 0
+	if constrained {
+		ollamaReq.Tools = nil
+		ollamaReq.Messages = constrainMessages(req.Messages, req.Tools)
+		ollamaReq.Format = ToolCallSchema(req.Tools)
+		ollamaReq.Stream = false
 	}
 
 	url := p.BaseURL + "/api/chat"
@@ -68,12 +83,12 @@ func (p *OllamaProvider) Chat(req *ChatRequest) (*ChatResponse, error) {
 
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("Ollama HTTP %d: %s", resp.StatusCode, translateProviderError(resp.StatusCode, string(body)))
+		return nil, retry.FromResponse("Ollama", resp, translateProviderError(resp.StatusCode, string(body)))
 	}
 
 	// Если включён стриминг — читаем ответ по частям
-	if req.Stream {
-		return p.readStream(resp.Body)
+	if ollamaReq.Stream {
+		return p.readStream(resp.Body, req.OnDelta)
 	}
 
 	// Обычный (не стриминговый) режим — парсим весь ответ целиком
@@ -82,22 +97,30 @@ func (p *OllamaProvider) Chat(req *ChatRequest) (*ChatResponse, error) {
 		return nil, fmt.Errorf("ошибка декодирования ответа: %w", err)
 	}
 
+	content, toolCalls := ollamaResp.Message.Content, ollamaResp.Message.ToolCalls
+	if constrained {
+		content, toolCalls = parseConstrained(content)
+	}
 	return &ChatResponse{
-		Content:   ollamaResp.Message.Content,
-		ToolCalls: ollamaResp.Message.ToolCalls,
+		Content:   content,
+		ToolCalls: toolCalls,
 		Model:     ollamaResp.Model,
+		Stats:     ollamaResp.stats(),
+		Reasoning: ollamaResp.Message.Thinking,
 	}, nil
 }
 
 // readStream — читает потоковый ответ от Ollama.
 // Ollama возвращает ответ в виде последовательности JSON-объектов (чанков),
 // каждый из которых содержит часть текста. Последний чанк имеет done=true.
-// Все части текста собираются в единый ответ через strings.Builder.
-func (p *OllamaProvider) readStream(body io.Reader) (*ChatResponse, error) {
+// Все части текста собираются в единый ответ через strings.Builder;
+// onDelta (если задан) получает каждую часть сразу после чтения.
+func (p *OllamaProvider) readStream(body io.Reader, onDelta func(string)) (*ChatResponse, error) {
 	dec := json.NewDecoder(body)
-	var content strings.Builder
+	var content, thinking strings.Builder
 	var toolCalls []ToolCall
 	var model string
+	var stats *GenerationStats
 
 	for {
 		var chunk OllamaResponse
@@ -114,13 +137,16 @@ func (p *OllamaProvider) readStream(body io.Reader) (*ChatResponse, error) {
 		// Собираем текст из каждого чанка
 		if chunk.Message.Content != "" {
 			content.WriteString(chunk.Message.Content)
+			if onDelta != nil {
+				onDelta(chunk.Message.Content)
+			}
 		}
-		// Вызовы инструментов приходят обычно в одном чанке
-		if len(chunk.Message.ToolCalls) > 0 {
-			toolCalls = chunk.Message.ToolCalls
-		}
+		thinking.WriteString(chunk.Message.Thinking)
+		// Вызовы инструментов приходят целиком, но могут быть в разных чанках
+		toolCalls = append(toolCalls, chunk.Message.ToolCalls...)
 		// Флаг done=true означает конец стрима
 		if chunk.Done {
+			stats = chunk.stats()
 			break
 		}
 	}
@@ -129,6 +155,8 @@ func (p *OllamaProvider) readStream(body io.Reader) (*ChatResponse, error) {
 		Content:   content.String(),
 		ToolCalls: toolCalls,
 		Model:     model,
+		Stats:     stats,
+		Reasoning: thinking.String(),
 	}, nil
 }
 
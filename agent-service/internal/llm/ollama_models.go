@@ -0,0 +1,296 @@
+package llm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrModelNotFound — модели нет в Ollama (ни локально, ни в реестре при загрузке).
+var ErrModelNotFound = errors.New("модель не найдена")
+
+// PullProgress — этап загрузки модели (строка потока /api/pull).
+// Completed/Total относятся к текущему слою (digest), Percent — его доля.
+type PullProgress struct {
+	Status    string  `json:"status"`
+	Digest    string  `json:"digest,omitempty"`
+	Completed int64   `json:"completed,omitempty"`
+	Total     int64   `json:"total,omitempty"`
+	Percent   float64 `json:"percent,omitempty"`
+	Error     string  `json:"error,omitempty"`
+}
+
+// RunningModel — модель, загруженная в память Ollama (/api/ps).
+type RunningModel struct {
+	Name      string    `json:"name"`
+	Size      int64     `json:"size"`
+	SizeVRAM  int64     `json:"size_vram"`
+	Processor string    `json:"processor"` // «100% GPU», «30% CPU / 70% GPU»
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// Pull — скачивает модель из реестра Ollama, вызывая progress на каждой
+// строке потока. Загрузка может длиться десятки минут, поэтому общий
+// таймаут HTTP-клиента провайдера не применяется — время ограничивает ctx.
+func (p *OllamaProvider) Pull(ctx context.Context, name string, progress func(PullProgress)) error {
+	body, _ := json.Marshal(map[string]interface{}{"model": name, "stream": true})
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.BaseURL+"/api/pull", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	client := &http.Client{Transport: p.HTTP.Transport}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("не удалось подключиться к Ollama: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return pullError(name, ollamaError(resp).Error())
+	}
+
+	dec := json.NewDecoder(resp.Body)
+	for {
+		var pr PullProgress
+		if err := dec.Decode(&pr); err != nil {
+			if err == io.EOF {
+				return errors.New("поток загрузки оборвался до завершения")
+			}
+			return fmt.Errorf("ошибка чтения потока загрузки: %w", err)
+		}
+		if pr.Error != "" {
+			return pullError(name, "Ollama: "+pr.Error)
+		}
+		if pr.Total > 0 {
+			pr.Percent = float64(int(float64(pr.Completed)/float64(pr.Total)*1000)) / 10
+		}
+		if progress != nil {
+			progress(pr)
+		}
+		if pr.Status == "success" {
+			return nil
+		}
+	}
+}
+
+// pullError — ошибка загрузки; отсутствие модели в реестре — ErrModelNotFound.
+func pullError(name, msg string) error {
+	if strings.Contains(msg, "file does not exist") || strings.Contains(msg, "not found") {
+		return fmt.Errorf("%w в реестре Ollama: %s", ErrModelNotFound, name)
+	}
+	return errors.New(msg)
+}
+
+// Delete — удаляет локальную модель.
+func (p *OllamaProvider) Delete(ctx context.Context, name string) error {
+	body, _ := json.Marshal(map[string]string{"model": name})
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, p.BaseURL+"/api/delete", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := p.HTTP.Do(req)
+	if err != nil {
+		return fmt.Errorf("не удалось подключиться к Ollama: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return fmt.Errorf("%w: %s", ErrModelNotFound, name)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return ollamaError(resp)
+	}
+	return nil
+}
+
+// Load — загружает модель в память без генерации (пустой запрос к
+// /api/generate) и задаёт keepAlive: "-1" — держать всегда, "0" — выгрузить.
+func (p *OllamaProvider) Load(ctx context.Context, name, keepAlive string) error {
+	payload := map[string]interface{}{"model": name}
+	if keepAlive != "" {
+		payload["keep_alive"] = keepAlive
+	}
+	body, _ := json.Marshal(payload)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.BaseURL+"/api/generate", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := p.HTTP.Do(req)
+	if err != nil {
+		return fmt.Errorf("не удалось подключиться к Ollama: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return fmt.Errorf("%w: %s", ErrModelNotFound, name)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return ollamaError(resp)
+	}
+	io.Copy(io.Discard, resp.Body)
+	return nil
+}
+
+// Running — модели, загруженные сейчас в память (аналог ollama ps).
+func (p *OllamaProvider) Running(ctx context.Context) ([]RunningModel, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.BaseURL+"/api/ps", nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := p.HTTP.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось подключиться к Ollama: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, ollamaError(resp)
+	}
+	var result struct {
+		Models []RunningModel `json:"models"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("ошибка парсинга ответа Ollama: %w", err)
+	}
+	for i := range result.Models {
+		result.Models[i].Processor = processor(result.Models[i].Size, result.Models[i].SizeVRAM)
+	}
+	return result.Models, nil
+}
+
+// processor — распределение модели между CPU и GPU в формате ollama ps.
+func processor(size, vram int64) string {
+	switch {
+	case size <= 0 || vram <= 0:
+		return "100% CPU"
+	case vram >= size:
+		return "100% GPU"
+	}
+	gpu := int(float64(vram) / float64(size) * 100)
+	return fmt.Sprintf("%d%% CPU / %d%% GPU", 100-gpu, gpu)
+}
+
+// ollamaError — ошибка из ответа Ollama вида {"error": "..."}.
+func ollamaError(resp *http.Response) error {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	var e struct {
+		Error string `json:"error"`
+	}
+	msg := strings.TrimSpace(string(body))
+	if json.Unmarshal(body, &e) == nil && e.Error != "" {
+		msg = e.Error
+	}
+	return fmt.Errorf("Ollama HTTP %d: %s", resp.StatusCode, msg)
+}
+
+// PullStatus — состояние фоновой загрузки модели.
+type PullStatus struct {
+	Model    string       `json:"model"`
+	Progress PullProgress `json:"progress"`
+	Started  time.Time    `json:"started"`
+	Done     bool         `json:"done"`
+	Error    string       `json:"error,omitempty"`
+}
+
+// PullTracker — фоновые загрузки моделей. Загрузка не привязана к HTTP-запросу:
+// закрытие UI или таймаут инструмента её не прерывают, а повторный запрос
+// той же модели подключается к идущей загрузке.
+type PullTracker struct {
+	// OnDone вызывается после завершения загрузки (err == nil — успех).
+	OnDone  func(model string, err error)
+	Timeout time.Duration // Предел одной загрузки (по умолчанию 2 часа)
+
+	mu    sync.Mutex
+	pulls map[string]*PullStatus
+}
+
+// Start — запускает загрузку, если модель ещё не загружается. Возвращает
+// текущее состояние и признак, что загрузка запущена этим вызовом.
+func (t *PullTracker) Start(p *OllamaProvider, model string) (PullStatus, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.pulls == nil {
+		t.pulls = make(map[string]*PullStatus)
+	}
+	if st, ok := t.pulls[model]; ok && !st.Done {
+		return *st, false
+	}
+	st := &PullStatus{Model: model, Started: time.Now(), Progress: PullProgress{Status: "starting"}}
+	t.pulls[model] = st
+	timeout := t.Timeout
+	if timeout <= 0 {
+		timeout = 2 * time.Hour
+	}
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+		err := p.Pull(ctx, model, func(pr PullProgress) {
+			t.mu.Lock()
+			st.Progress = pr
+			t.mu.Unlock()
+		})
+		t.mu.Lock()
+		st.Done = true
+		if err != nil {
+			st.Error = err.Error()
+		}
+		t.mu.Unlock()
+		if t.OnDone != nil {
+			t.OnDone(model, err)
+		}
+	}()
+	return *st, true
+}
+
+// Status — состояние загрузки модели (в том числе завершённой).
+func (t *PullTracker) Status(model string) (PullStatus, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	st, ok := t.pulls[model]
+	if !ok {
+		return PullStatus{}, false
+	}
+	return *st, true
+}
+
+// Active — незавершённые загрузки.
+func (t *PullTracker) Active() []PullStatus {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var out []PullStatus
+	for _, st := range t.pulls {
+		if !st.Done {
+			out = append(out, *st)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Started.Before(out[j].Started) })
+	return out
+}
+
+// Wait — ждёт завершения загрузки не дольше d; возвращает последнее состояние.
+func (t *PullTracker) Wait(ctx context.Context, model string, d time.Duration) PullStatus {
+	deadline := time.NewTimer(d)
+	defer deadline.Stop()
+	tick := time.NewTicker(200 * time.Millisecond)
+	defer tick.Stop()
+	for {
+		st, _ := t.Status(model)
+		if st.Done {
+			return st
+		}
+		select {
+		case <-ctx.Done():
+			return st
+		case <-deadline.C:
+			return st
+		case <-tick.C:
+		}
+	}
+}
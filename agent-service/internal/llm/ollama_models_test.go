@@ -0,0 +1,84 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// fakeOllama — /api/pull (поток прогресса), /api/delete и /api/ps.
+func fakeOllama(t *testing.T, release <-chan struct{}) *OllamaProvider {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/pull":
+			w.Write([]byte(`{"status":"pulling manifest"}` + "\n"))
+			w.Write([]byte(`{"status":"pulling abc","digest":"sha256:abc","total":200,"completed":50}` + "\n"))
+			w.(http.Flusher).Flush()
+			if release != nil {
+				<-release
+			}
+			w.Write([]byte(`{"status":"verifying sha256 digest"}` + "\n" + `{"status":"success"}` + "\n"))
+		case "/api/delete":
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"error":"model 'x' not found"}`))
+		case "/api/ps":
+			w.Write([]byte(`{"models":[{"name":"qwen2.5:7b","size":1000,"size_vram":700,"expires_at":"2030-01-01T00:00:00Z"}]}`))
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return NewOllamaProvider(srv.URL)
+}
+
+// TestOllamaModels — прогресс загрузки, удаление отсутствующей модели и ps.
+func TestOllamaModels(t *testing.T) {
+	p := fakeOllama(t, nil)
+	ctx := context.Background()
+
+	var steps []PullProgress
+	if err := p.Pull(ctx, "qwen2.5:7b", func(pr PullProgress) { steps = append(steps, pr) }); err != nil {
+		t.Fatal(err)
+	}
+	if len(steps) != 4 || steps[1].Percent != 25 || steps[3].Status != "success" {
+		t.Errorf("прогресс: %+v", steps)
+	}
+	if err := p.Delete(ctx, "x"); !errors.Is(err, ErrModelNotFound) {
+		t.Errorf("ожидалась ErrModelNotFound, получено %v", err)
+	}
+	running, err := p.Running(ctx)
+	if err != nil || len(running) != 1 || running[0].Processor != "30% CPU / 70% GPU" {
+		t.Fatalf("Running: %+v, %v", running, err)
+	}
+}
+
+// TestPullTracker — повторный Start подключается к идущей загрузке.
+func TestPullTracker(t *testing.T) {
+	release := make(chan struct{})
+	p := fakeOllama(t, release)
+	done := make(chan error, 1)
+	tr := &PullTracker{OnDone: func(model string, err error) { done <- err }}
+
+	if _, started := tr.Start(p, "m"); !started {
+		t.Fatal("первый Start должен запускать загрузку")
+	}
+	st := tr.Wait(context.Background(), "m", 300*time.Millisecond)
+	if st.Done || st.Progress.Percent != 25 {
+		t.Errorf("состояние в процессе: %+v", st)
+	}
+	if _, started := tr.Start(p, "m"); started {
+		t.Error("повторный Start не должен запускать вторую загрузку")
+	}
+	if len(tr.Active()) != 1 {
+		t.Errorf("активные: %+v", tr.Active())
+	}
+	close(release)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if st, _ := tr.Status("m"); !st.Done || st.Error != "" || len(tr.Active()) != 0 {
+		t.Errorf("после завершения: %+v", st)
+	}
+}
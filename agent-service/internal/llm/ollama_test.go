@@ -0,0 +1,113 @@
+package llm
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// TestChatTemperature — температура передаётся провайдеру только если задана.
+func TestChatTemperature(t *testing.T) {
+	var got map[string]interface{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = nil
+		json.NewDecoder(r.Body).Decode(&got)
+		if r.URL.Path == "/api/chat" {
+			w.Write([]byte(`{"model":"m","message":{"role":"assistant","content":"ok"},"done":true}`))
+			return
+		}
+		w.Write([]byte(`{"model":"m","choices":[{"message":{"role":"assistant","content":"ok"}}]}`))
+	}))
+	defer srv.Close()
+
+	temp := 0.9
+	ollama := NewOllamaProvider(srv.URL)
+	if _, err := ollama.Chat(&ChatRequest{Model: "m", Messages: []Message{{Role: "user", Content: "q"}}, Temperature: &temp}); err != nil {
+		t.Fatal(err)
+	}
+	if opts, _ := got["options"].(map[string]interface{}); opts["temperature"] != 0.9 {
+		t.Errorf("ollama options: %v", got["options"])
+	}
+	if _, err := ollama.Chat(&ChatRequest{Model: "m", Messages: []Message{{Role: "user", Content: "q"}}}); err != nil {
+		t.Fatal(err)
+	}
+	if opts, _ := got["options"].(map[string]interface{}); opts["temperature"] != nil {
+		t.Errorf("температура не задана, а передана: %v", opts)
+	}
+
+	openai := NewOpenAIProvider("key", srv.URL)
+	if _, err := openai.Chat(&ChatRequest{Model: "m", Messages: []Message{{Role: "user", Content: "q"}}, Temperature: &temp}); err != nil {
+		t.Fatal(err)
+	}
+	if got["temperature"] != 0.9 {
+		t.Errorf("openai temperature: %v", got["temperature"])
+	}
+}
+
+// TestChatConstrainTools — модель без tool calling получает схему ответа и
+// описание инструментов, а вызов из JSON-ответа возвращается структурированным.
+func TestChatConstrainTools(t *testing.T) {
+	var got OllamaRequest
+	reply := `{"name": "read", "arguments": {"path": "/a"}}`
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = OllamaRequest{}
+		json.NewDecoder(r.Body).Decode(&got)
+		data, _ := json.Marshal(map[string]interface{}{"model": "m", "done": true, "message": map[string]string{"role": "assistant", "content": reply}})
+		w.Write(data)
+	}))
+	defer srv.Close()
+
+	tools := []Tool{{Type: "function", Function: FunctionDefinition{Name: "read", Description: "Прочитать файл.", Parameters: map[string]interface{}{
+		"type": "object", "properties": map[string]interface{}{"path": map[string]string{"type": "string"}}, "required": []string{"path"},
+	}}}}
+	req := &ChatRequest{Model: "m", Messages: []Message{{Role: "system", Content: "Ты агент"}, {Role: "user", Content: "прочитай /a"}}, Tools: tools, Stream: true, ConstrainTools: true}
+	ollama := NewOllamaProvider(srv.URL)
+	resp, err := ollama.Chat(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Tools) != 0 || got.Stream || !json.Valid(got.Format) || !strings.Contains(got.Messages[0].Content, "- read: Прочитать файл.") {
+		t.Errorf("запрос к Ollama: tools=%v stream=%v format=%s system=%q", got.Tools, got.Stream, got.Format, got.Messages[0].Content)
+	}
+	if resp.Content != "" || len(resp.ToolCalls) != 1 || resp.ToolCalls[0].Function.Name != "read" || string(resp.ToolCalls[0].Function.Arguments) != `{"path": "/a"}` {
+		t.Fatalf("ответ: %+v", resp)
+	}
+
+	req.Messages = append(req.Messages,
+		Message{Role: "assistant", ToolCalls: resp.ToolCalls},
+		Message{Role: "tool", Content: `{"content":"hello"}`, ToolCallID: "call_0"})
+	reply = `{"name": "final_answer", "arguments": {"text": "В файле hello"}}`
+	if resp, err = ollama.Chat(req); err != nil || resp.Content != "В файле hello" || len(resp.ToolCalls) != 0 {
+		t.Fatalf("итоговый ответ: %+v %v", resp, err)
+	}
+	if n := len(got.Messages); got.Messages[n-2].Content != `{"arguments":{"path":"/a"},"name":"read"}` || got.Messages[n-1].Role != "user" {
+		t.Errorf("история вызовов: %+v", got.Messages[n-2:])
+	}
+}
+
+// TestToolCallSchema — схема допускает вызов каждого инструмента и итоговый ответ.
+func TestToolCallSchema(t *testing.T) {
+	var schema struct {
+		OneOf []struct {
+			Properties struct {
+				Name struct {
+					Const string `json:"const"`
+				} `json:"name"`
+			} `json:"properties"`
+			Required []string `json:"required"`
+		} `json:"oneOf"`
+	}
+	tools := []Tool{{Function: FunctionDefinition{Name: "read"}}, {Function: FunctionDefinition{Name: "execute"}}}
+	if err := json.Unmarshal(ToolCallSchema(tools), &schema); err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, v := range schema.OneOf {
+		names = append(names, v.Properties.Name.Const)
+	}
+	if strings.Join(names, ",") != "read,execute,"+FinalAnswerTool {
+		t.Errorf("варианты: %v", names)
+	}
+}
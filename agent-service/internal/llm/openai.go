@@ -7,6 +7,9 @@ import (
 	"io"
 	"net/http"
 	"time"
+
+	"github.com/neo-2022/openclaw-memory/agent-service/internal/httpclient"
+	"github.com/neo-2022/openclaw-memory/agent-service/internal/retry"
 )
 
 // OpenAIProvider — провайдер для облачных моделей OpenAI.
@@ -30,7 +33,7 @@ func NewOpenAIProvider(apiKey, baseURL string) *OpenAIProvider {
 	return &OpenAIProvider{
 		APIKey:  apiKey,
 		BaseURL: baseURL,
-		HTTP:    &http.Client{Timeout: 120 * time.Second},
+		HTTP:    httpclient.New("openai", 120*time.Second),
 	}
 }
 
@@ -43,14 +46,16 @@ type openaiRequest struct {
 	Model    string          `json:"model"`           // Имя модели (gpt-4o, gpt-4-turbo и т.д.)
 	Messages []openaiMessage `json:"messages"`        // Массив сообщений диалога
 	Tools    []openaiTool    `json:"tools,omitempty"` // Доступные инструменты для вызова
-	Stream   bool            `json:"stream"`          // Режим стриминга (пока не используется)
+	Stream   bool            `json:"stream"`          // Режим стриминга: ответ SSE-событиями (readOpenAIStream)
+	// Temperature — температура генерации (nil — значение API по умолчанию)
+	Temperature *float64 `json:"temperature,omitempty"`
 }
 
 // openaiMessage — сообщение в формате OpenAI API.
 // Поддерживает роли: system, user, assistant, tool.
 type openaiMessage struct {
 	Role       string           `json:"role"`                   // Роль отправителя сообщения
-	Content    string           `json:"content"`                // Текст сообщения
+	Content    any              `json:"content"`                // Текст или части text/image_url (см. openaiContent)
 	ToolCalls  []openaiToolCall `json:"tool_calls,omitempty"`   // Вызовы инструментов (для роли assistant)
 	ToolCallID string           `json:"tool_call_id,omitempty"` // ID вызова инструмента (для роли tool)
 }
@@ -117,11 +122,23 @@ func (p *OpenAIProvider) Chat(req *ChatRequest) (*ChatResponse, error) {
 	// Конвертируем сообщения из универсального формата в формат OpenAI
 	msgs := make([]openaiMessage, len(req.Messages))
 	for i, m := range req.Messages {
-		msgs[i] = openaiMessage{
+		msg := openaiMessage{
 			Role:       m.Role,
-			Content:    m.Content,
+			Content:    openaiContent(m),
 			ToolCallID: m.ToolCallID,
 		}
+		// Вызовы инструментов ассистента: без них API отклоняет следующие сообщения role=tool
+		for _, tc := range m.ToolCalls {
+			msg.ToolCalls = append(msg.ToolCalls, openaiToolCall{
+				ID:   tc.ID,
+				Type: tc.Type,
+				Function: openaiToolCallFunction{
+					Name:      tc.Function.Name,
+					Arguments: string(tc.Function.Arguments),
+				},
+			})
+		}
+		msgs[i] = msg
 	}
 
 	// Конвертируем инструменты из универсального формата в формат OpenAI
@@ -142,7 +159,9 @@ func (p *OpenAIProvider) Chat(req *ChatRequest) (*ChatResponse, error) {
 		Model:    req.Model,
 		Messages: msgs,
 		Tools:    oaiTools,
-		Stream:   false,
+		Stream:   req.Stream,
+
+		Temperature: req.Temperature,
 	}
 
 	data, err := json.Marshal(oaiReq)
@@ -167,7 +186,11 @@ func (p *OpenAIProvider) Chat(req *ChatRequest) (*ChatResponse, error) {
 
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("OpenAI HTTP %d: %s", resp.StatusCode, translateProviderError(resp.StatusCode, string(body)))
+		return nil, retry.FromResponse("OpenAI", resp, translateProviderError(resp.StatusCode, string(body)))
+	}
+
+	if oaiReq.Stream {
+		return readOpenAIStream(resp.Body, req.OnDelta)
 	}
 
 	// Парсим ответ от OpenAI
@@ -29,6 +29,9 @@ import (
 	"net/http"
 	"strings"
 	"time"
+
+	"github.com/neo-2022/openclaw-memory/agent-service/internal/httpclient"
+	"github.com/neo-2022/openclaw-memory/agent-service/internal/retry"
 )
 
 // OpenRouterProvider — провайдер для доступа к моделям через OpenRouter.
@@ -63,7 +66,7 @@ func NewOpenRouterProvider(apiKey, baseURL string) *OpenRouterProvider {
 	return &OpenRouterProvider{
 		APIKey:  apiKey,
 		BaseURL: baseURL,
-		HTTP:    &http.Client{Timeout: 120 * time.Second},
+		HTTP:    httpclient.New("openrouter", 120*time.Second),
 		AppName: "AgentCore-NG",
 	}
 }
@@ -80,6 +83,8 @@ type openrouterRequest struct {
 	Messages []openaiMessage `json:"messages"`        // Массив сообщений диалога (формат OpenAI)
 	Tools    []openaiTool    `json:"tools,omitempty"` // Доступные инструменты для tool calling
 	Stream   bool            `json:"stream"`          // Режим стриминга
+	// Temperature — температура генерации (nil — значение модели по умолчанию)
+	Temperature *float64 `json:"temperature,omitempty"`
 }
 
 // openrouterResponse — структура ответа от OpenRouter API.
@@ -127,7 +132,7 @@ func (p *OpenRouterProvider) Chat(req *ChatRequest) (*ChatResponse, error) {
 	for i, m := range req.Messages {
 		msg := openaiMessage{
 			Role:       m.Role,
-			Content:    m.Content,
+			Content:    openaiContent(m),
 			ToolCallID: m.ToolCallID,
 		}
 		for _, tc := range m.ToolCalls {
@@ -161,7 +166,9 @@ func (p *OpenRouterProvider) Chat(req *ChatRequest) (*ChatResponse, error) {
 		Model:    req.Model,
 		Messages: msgs,
 		Tools:    orTools,
-		Stream:   false,
+		Stream:   req.Stream,
+
+		Temperature: req.Temperature,
 	}
 
 	data, err := json.Marshal(orReq)
@@ -189,7 +196,11 @@ func (p *OpenRouterProvider) Chat(req *ChatRequest) (*ChatResponse, error) {
 
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("OpenRouter HTTP %d: %s", resp.StatusCode, translateProviderError(resp.StatusCode, string(body)))
+		return nil, retry.FromResponse("OpenRouter", resp, translateProviderError(resp.StatusCode, string(body)))
+	}
+
+	if orReq.Stream {
+		return readOpenAIStream(resp.Body, req.OnDelta)
 	}
 
 	// Парсим ответ
@@ -278,6 +289,45 @@ func (p *OpenRouterProvider) fetchModelsRaw() ([]openrouterModelData, error) {
 	return result.Data, nil
 }
 
+// ListModelsETag — список моделей с условным запросом: If-None-Match с etag
+// от прошлого ответа; ErrNotModified, если каталог OpenRouter не менялся.
+func (p *OpenRouterProvider) ListModelsETag(etag string) ([]string, string, error) {
+	if p.APIKey == "" {
+		return nil, "", fmt.Errorf("API-ключ OpenRouter не настроен")
+	}
+	httpReq, err := http.NewRequest("GET", p.BaseURL+"/models", nil)
+	if err != nil {
+		return nil, "", err
+	}
+	httpReq.Header.Set("Authorization", "Bearer "+p.APIKey)
+	if etag != "" {
+		httpReq.Header.Set("If-None-Match", etag)
+	}
+	resp, err := p.HTTP.Do(httpReq)
+	if err != nil {
+		return nil, "", fmt.Errorf("ошибка получения списка моделей OpenRouter: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotModified {
+		return nil, etag, ErrNotModified
+	}
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, "", fmt.Errorf("OpenRouter /models вернул статус %d: %s", resp.StatusCode, string(body))
+	}
+	var result struct {
+		Data []openrouterModelData `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, "", fmt.Errorf("ошибка декодирования списка моделей OpenRouter: %w", err)
+	}
+	models := make([]string, 0, len(result.Data))
+	for _, m := range result.Data {
+		models = append(models, m.ID)
+	}
+	return models, resp.Header.Get("ETag"), nil
+}
+
 // isPopularModel — проверяет, относится ли модель к популярным провайдерам.
 // Фильтрует модели OpenRouter, оставляя только от известных провайдеров
 // (OpenAI, Anthropic, Google, Meta, Mistral, DeepSeek, Qwen, Cohere).
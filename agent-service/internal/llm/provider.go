@@ -3,6 +3,8 @@
 // (OpenAI, Anthropic, YandexGPT, GigaChat).
 package llm
 
+import "errors"
+
 // ChatRequest — универсальный запрос к любому LLM-провайдеру.
 // Содержит имя модели, историю сообщений, список инструментов (tools)
 // и флаг стриминга. Используется всеми провайдерами одинаково —
@@ -11,7 +13,19 @@ type ChatRequest struct {
 	Model    string    `json:"model"`           // Имя модели (например, "gpt-4o", "claude-sonnet-4-20250514", "yandexgpt")
 	Messages []Message `json:"messages"`        // История сообщений диалога (system, user, assistant, tool)
 	Tools    []Tool    `json:"tools,omitempty"` // Список доступных инструментов для вызова моделью
-	Stream   bool      `json:"stream"`          // Включить потоковую передачу ответа (поддерживается только Ollama)
+	Stream   bool      `json:"stream"`          // Включить потоковую передачу ответа (см. SupportsStreaming)
+	// KeepAlive — сколько Ollama держит модель в памяти после запроса
+	// ("30m", "-1" — всегда); пусто — значение сервера Ollama. Другие провайдеры игнорируют.
+	KeepAlive string `json:"keep_alive,omitempty"`
+	// Temperature — температура генерации; nil — значение провайдера по умолчанию.
+	Temperature *float64 `json:"temperature,omitempty"`
+	// ConstrainTools — модель не поддерживает tool calling: Ollama получает
+	// инструменты описанием в промпте и схемой ответа (ToolCallSchema) вместо
+	// поля tools и возвращает вызовы в ToolCalls. Другие провайдеры игнорируют.
+	ConstrainTools bool `json:"constrain_tools,omitempty"`
+	// OnDelta — вызывается с каждым фрагментом текста в режиме Stream
+	// (по мере генерации). Провайдеры без стриминга его игнорируют.
+	OnDelta func(text string) `json:"-"`
 }
 
 // ChatResponse — универсальный ответ от любого LLM-провайдера.
@@ -21,6 +35,21 @@ type ChatResponse struct {
 	Content   string     `json:"content"`              // Текстовый ответ модели
 	ToolCalls []ToolCall `json:"tool_calls,omitempty"` // Вызовы инструментов, запрошенные моделью
 	Model     string     `json:"model"`                // Имя модели, которая сгенерировала ответ
+	// Reasoning — размышления, которые провайдер вернул отдельно от ответа
+	// (Ollama: message.thinking); размышления в тексте ответа остаются в Content.
+	Reasoning string `json:"reasoning,omitempty"`
+	// Stats — токены и тайминги генерации, если провайдер их сообщает (Ollama)
+	Stats *GenerationStats `json:"stats,omitempty"`
+}
+
+// GenerationStats — статистика генерации ответа.
+type GenerationStats struct {
+	PromptTokens     int     `json:"prompt_tokens"`
+	CompletionTokens int     `json:"completion_tokens"`
+	LoadMs           float64 `json:"load_ms"`        // Загрузка модели в память
+	PromptEvalMs     float64 `json:"prompt_eval_ms"` // Обработка промпта (до первого токена)
+	EvalMs           float64 `json:"eval_ms"`        // Генерация ответа
+	TotalMs          float64 `json:"total_ms"`
 }
 
 // ModelDetail — детальная информация о модели провайдера.
@@ -60,3 +89,12 @@ type ChatProvider interface {
 	ListModelsDetailed() ([]ModelDetail, error)   // Получить детальную информацию о моделях (цены, бесплатность, активация)
 	Name() string                                 // Получить имя провайдера
 }
+
+// ErrNotModified — список моделей не изменился с прошлого запроса (HTTP 304).
+var ErrNotModified = errors.New("список моделей не изменился")
+
+// ConditionalModelLister — провайдер, отдающий список моделей по условному
+// запросу с ETag: каталог не скачивается заново, если он не менялся.
+type ConditionalModelLister interface {
+	ListModelsETag(etag string) (models []string, newETag string, err error)
+}
@@ -0,0 +1,271 @@
+package llm
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrQuotaUnsupported — провайдер не сообщает остаток лимитов.
+var ErrQuotaUnsupported = errors.New("провайдер не сообщает остаток лимитов")
+
+// QuotaItem — один лимит провайдера: баланс, токены или запросы за период.
+// Неизвестные значения — nil (например, ключ OpenRouter без лимита).
+type QuotaItem struct {
+	Name      string     `json:"name"`                // credits, requests_day, tokens_minute, GigaChat-Pro...
+	Unit      string     `json:"unit"`                // usd, requests, tokens
+	Limit     *float64   `json:"limit,omitempty"`     // Лимит за период или пополнено всего
+	Used      *float64   `json:"used,omitempty"`      // Израсходовано
+	Remaining *float64   `json:"remaining,omitempty"` // Осталось
+	ResetAt   *time.Time `json:"reset_at,omitempty"`  // Когда лимит обновится
+}
+
+// Fraction — доля остатка от лимита; false, если лимит или остаток неизвестен.
+func (i QuotaItem) Fraction() (float64, bool) {
+	if i.Limit == nil || i.Remaining == nil || *i.Limit <= 0 {
+		return 0, false
+	}
+	return *i.Remaining / *i.Limit, true
+}
+
+// Quota — остаток лимитов провайдера.
+//
+// Source: api — ответ биллингового API провайдера; headers — заголовки
+// x-ratelimit-* последнего ответа (у Cerebras нет отдельного API лимитов).
+type Quota struct {
+	Provider  string      `json:"provider"`
+	Source    string      `json:"source"`
+	Items     []QuotaItem `json:"items"`
+	CheckedAt time.Time   `json:"checked_at"`
+}
+
+// Low — лимит с наименьшей долей остатка, если она не больше frac.
+func (q *Quota) Low(frac float64) (QuotaItem, bool) {
+	var low QuotaItem
+	best, found := frac, false
+	for _, it := range q.Items {
+		if f, ok := it.Fraction(); ok && f <= best {
+			low, best, found = it, f, true
+		}
+	}
+	return low, found
+}
+
+// QuotaProvider — провайдер, умеющий сообщить остаток лимитов.
+type QuotaProvider interface {
+	Quota() (*Quota, error)
+}
+
+// GetQuota — остаток лимитов провайдера или ErrQuotaUnsupported.
+func GetQuota(p ChatProvider) (*Quota, error) {
+	qp, ok := p.(QuotaProvider)
+	if !ok {
+		return nil, ErrQuotaUnsupported
+	}
+	return qp.Quota()
+}
+
+func float(v float64) *float64 { return &v }
+
+// Quota — лимит ключа (GET /key) и баланс аккаунта (GET /credits) OpenRouter.
+func (p *OpenRouterProvider) Quota() (*Quota, error) {
+	if p.APIKey == "" {
+		return nil, fmt.Errorf("API-ключ OpenRouter не настроен")
+	}
+	var key struct {
+		Data struct {
+			Limit          *float64 `json:"limit"`
+			LimitRemaining *float64 `json:"limit_remaining"`
+			Usage          float64  `json:"usage"`
+			IsFreeTier     bool     `json:"is_free_tier"`
+		} `json:"data"`
+	}
+	if err := p.getJSON("/key", &key); err != nil {
+		return nil, err
+	}
+	q := &Quota{Provider: p.Name(), Source: "api", CheckedAt: time.Now()}
+	if key.Data.Limit != nil {
+		q.Items = append(q.Items, QuotaItem{Name: "key_credits", Unit: "usd", Limit: key.Data.Limit, Used: float(key.Data.Usage), Remaining: key.Data.LimitRemaining})
+	}
+	// Баланс аккаунта; без него остаётся лимит ключа
+	var credits struct {
+		Data struct {
+			TotalCredits float64 `json:"total_credits"`
+			TotalUsage   float64 `json:"total_usage"`
+		} `json:"data"`
+	}
+	if err := p.getJSON("/credits", &credits); err == nil {
+		c := credits.Data
+		q.Items = append(q.Items, QuotaItem{Name: "credits", Unit: "usd", Limit: float(c.TotalCredits), Used: float(c.TotalUsage), Remaining: float(c.TotalCredits - c.TotalUsage)})
+	} else if key.Data.Limit == nil {
+		q.Items = append(q.Items, QuotaItem{Name: "key_credits", Unit: "usd", Used: float(key.Data.Usage)})
+	}
+	return q, nil
+}
+
+// getJSON — GET-запрос к API OpenRouter с разбором JSON-ответа.
+func (p *OpenRouterProvider) getJSON(path string, out interface{}) error {
+	httpReq, err := http.NewRequest("GET", p.BaseURL+path, nil)
+	if err != nil {
+		return err
+	}
+	httpReq.Header.Set("Authorization", "Bearer "+p.APIKey)
+	resp, err := p.HTTP.Do(httpReq)
+	if err != nil {
+		return fmt.Errorf("ошибка запроса OpenRouter %s: %w", path, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("OpenRouter %s вернул статус %d: %s", path, resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("ошибка декодирования ответа OpenRouter %s: %w", path, err)
+	}
+	return nil
+}
+
+// Quota — лимиты из заголовков x-ratelimit-* последнего ответа Cerebras;
+// до первого запроса к модели заголовки берутся из GET /models.
+func (p *CerebrasProvider) Quota() (*Quota, error) {
+	if q := p.limits.last(); q != nil {
+		return q, nil
+	}
+	if p.APIKey == "" {
+		return nil, fmt.Errorf("API-ключ Cerebras не настроен")
+	}
+	httpReq, err := http.NewRequest("GET", p.BaseURL+"/models", nil)
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Authorization", "Bearer "+p.APIKey)
+	resp, err := p.HTTP.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка запроса к Cerebras: %w", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Cerebras /models вернул статус %d", resp.StatusCode)
+	}
+	p.limits.observe(p.Name(), resp.Header)
+	if q := p.limits.last(); q != nil {
+		return q, nil
+	}
+	// Лимиты станут известны после первого запроса к модели
+	return &Quota{Provider: p.Name(), Source: "headers", CheckedAt: time.Now()}, nil
+}
+
+// Quota — остаток предоплаченных токенов GigaChat по моделям (GET /balance).
+// Для оплаты по факту использования API баланса недоступен.
+func (p *GigaChatProvider) Quota() (*Quota, error) {
+	token, err := p.getToken()
+	if err != nil {
+		return nil, err
+	}
+	httpReq, err := http.NewRequest("GET", p.BaseURL+"/balance", nil)
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Authorization", "Bearer "+token)
+	httpReq.Header.Set("Accept", "application/json")
+	resp, err := p.HTTP.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка запроса баланса GigaChat: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("GigaChat /balance вернул статус %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	var result struct {
+		Balance []struct {
+			Usage string  `json:"usage"`
+			Value float64 `json:"value"`
+		} `json:"balance"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("ошибка декодирования баланса GigaChat: %w", err)
+	}
+	q := &Quota{Provider: p.Name(), Source: "api", CheckedAt: time.Now()}
+	for _, b := range result.Balance {
+		q.Items = append(q.Items, QuotaItem{Name: b.Usage, Unit: "tokens", Remaining: float(b.Value)})
+	}
+	return q, nil
+}
+
+// rateLimits — лимиты из заголовков x-ratelimit-* последнего ответа провайдера.
+type rateLimits struct {
+	mu    sync.Mutex
+	quota *Quota
+}
+
+// observe — запоминает лимиты из заголовков; ответ без них ничего не меняет.
+func (l *rateLimits) observe(provider string, h http.Header) {
+	if q := quotaFromHeaders(provider, h, time.Now()); q != nil {
+		l.mu.Lock()
+		l.quota = q
+		l.mu.Unlock()
+	}
+}
+
+func (l *rateLimits) last() *Quota {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.quota
+}
+
+// quotaFromHeaders — лимиты из заголовков x-ratelimit-limit-<вид>,
+// x-ratelimit-remaining-<вид> и x-ratelimit-reset-<вид> (секунды или
+// длительность 1m30s); вид — requests-day, tokens-minute и т.п.
+func quotaFromHeaders(provider string, h http.Header, now time.Time) *Quota {
+	const prefix = "X-Ratelimit-Limit-"
+	var items []QuotaItem
+	for k := range h {
+		kind, ok := strings.CutPrefix(http.CanonicalHeaderKey(k), prefix)
+		if !ok {
+			continue
+		}
+		limit, err := strconv.ParseFloat(h.Get(k), 64)
+		if err != nil {
+			continue
+		}
+		it := QuotaItem{
+			Name:  strings.ReplaceAll(strings.ToLower(kind), "-", "_"),
+			Unit:  strings.ToLower(strings.SplitN(kind, "-", 2)[0]),
+			Limit: float(limit),
+		}
+		if v, err := strconv.ParseFloat(h.Get("X-Ratelimit-Remaining-"+kind), 64); err == nil {
+			it.Remaining = float(v)
+			it.Used = float(limit - v)
+		}
+		if reset := parseReset(h.Get("X-Ratelimit-Reset-" + kind)); reset > 0 {
+			at := now.Add(reset)
+			it.ResetAt = &at
+		}
+		items = append(items, it)
+	}
+	if len(items) == 0 {
+		return nil
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].Name < items[j].Name })
+	return &Quota{Provider: provider, Source: "headers", Items: items, CheckedAt: now}
+}
+
+// parseReset — время до сброса лимита: "33011.38" (секунды) или "1m30s".
+func parseReset(v string) time.Duration {
+	if v == "" {
+		return 0
+	}
+	if s, err := strconv.ParseFloat(v, 64); err == nil {
+		return time.Duration(s * float64(time.Second))
+	}
+	d, _ := time.ParseDuration(v)
+	return d
+}
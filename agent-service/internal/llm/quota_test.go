@@ -0,0 +1,105 @@
+package llm
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// TestOpenRouterQuota — лимит ключа и баланс аккаунта.
+func TestOpenRouterQuota(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/key":
+			fmt.Fprint(w, `{"data":{"label":"sk-or-v1-abc","limit":5,"limit_remaining":0.4,"usage":4.6,"is_free_tier":false}}`)
+		case "/credits":
+			fmt.Fprint(w, `{"data":{"total_credits":20,"total_usage":12.5}}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	q, err := NewOpenRouterProvider("sk-or-v1-abc", srv.URL).Quota()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(q.Items) != 2 || q.Items[0].Name != "key_credits" || *q.Items[1].Remaining != 7.5 {
+		t.Fatalf("лимиты: %+v", q.Items)
+	}
+	low, ok := q.Low(0.1)
+	if !ok || low.Name != "key_credits" {
+		t.Errorf("остаток ключа 8%% — меньше порога 10%%: %+v %v", low, ok)
+	}
+}
+
+// TestCerebrasQuota — лимиты из заголовков ответа на запрос к модели.
+func TestCerebrasQuota(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("x-ratelimit-limit-requests-day", "14400")
+		w.Header().Set("x-ratelimit-remaining-requests-day", "14399")
+		w.Header().Set("x-ratelimit-reset-requests-day", "3600.5")
+		w.Header().Set("x-ratelimit-limit-tokens-minute", "60000")
+		w.Header().Set("x-ratelimit-remaining-tokens-minute", "1000")
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"choices":[{"message":{"role":"assistant","content":"ok"}}]}`)
+	}))
+	defer srv.Close()
+
+	p := NewCerebrasProvider("csk-test", srv.URL)
+	if _, err := p.Chat(&ChatRequest{Model: "llama3.1-8b", Messages: []Message{{Role: "user", Content: "hi"}}}); err != nil {
+		t.Fatal(err)
+	}
+	q, err := p.Quota()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if q.Source != "headers" || len(q.Items) != 2 {
+		t.Fatalf("лимиты: %+v", q)
+	}
+	day := q.Items[0]
+	if day.Name != "requests_day" || day.Unit != "requests" || *day.Remaining != 14399 || day.ResetAt == nil {
+		t.Errorf("запросы в день: %+v", day)
+	}
+	if low, ok := q.Low(0.05); !ok || low.Name != "tokens_minute" {
+		t.Errorf("токенов в минуту осталось меньше 5%%: %+v", low)
+	}
+}
+
+// TestGigaChatQuota — остаток токенов по моделям.
+func TestGigaChatQuota(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/oauth":
+			fmt.Fprintf(w, `{"access_token":"tok","expires_at":%d}`, time.Now().Add(time.Hour).UnixMilli())
+		case "/balance":
+			if r.Header.Get("Authorization") != "Bearer tok" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			fmt.Fprint(w, `{"balance":[{"usage":"GigaChat","value":950000},{"usage":"GigaChat-Pro","value":12000}]}`)
+		}
+	}))
+	defer srv.Close()
+
+	p := NewGigaChatProvider("", "secret", "", srv.URL)
+	p.AuthURL = srv.URL + "/oauth"
+	p.HTTP = srv.Client()
+	q, err := p.Quota()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(q.Items) != 2 || q.Items[1].Name != "GigaChat-Pro" || *q.Items[1].Remaining != 12000 {
+		t.Errorf("баланс: %+v", q.Items)
+	}
+}
+
+// TestGetQuotaUnsupported — провайдеры без API лимитов.
+func TestGetQuotaUnsupported(t *testing.T) {
+	if _, err := GetQuota(NewMockProvider("mock")); !errors.Is(err, ErrQuotaUnsupported) {
+		t.Errorf("ожидалась ErrQuotaUnsupported, получено %v", err)
+	}
+}
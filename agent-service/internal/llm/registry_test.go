@@ -8,45 +8,6 @@ import (
 	"time"
 )
 
-// MockProvider — простой mock провайдера для тестирования
-type MockProvider struct {
-	name   string
-	models []string
-	err    error
-}
-
-func (m *MockProvider) Name() string {
-	return m.name
-}
-
-func (m *MockProvider) Chat(req *ChatRequest) (*ChatResponse, error) {
-	if m.err != nil {
-		return nil, m.err
-	}
-	return &ChatResponse{
-		Content: "mock response",
-		Model:   m.name,
-	}, nil
-}
-
-func (m *MockProvider) ListModels() ([]string, error) {
-	if m.err != nil {
-		return nil, m.err
-	}
-	return m.models, nil
-}
-
-func (m *MockProvider) ListModelsDetailed() ([]ModelDetail, error) {
-	var details []ModelDetail
-	for _, model := range m.models {
-		details = append(details, ModelDetail{
-			ID:          model,
-			IsAvailable: true,
-		})
-	}
-	return details, nil
-}
-
 // ===== Тесты для регистрации и получения провайдеров =====
 
 func TestRegistry_Register_And_Get(t *testing.T) {
@@ -18,8 +18,9 @@
 package llm
 
 import (
-	"net/http"
 	"time"
+
+	"github.com/neo-2022/openclaw-memory/agent-service/internal/httpclient"
 )
 
 // RoutewayProvider — провайдер для доступа к моделям через Routeway.
@@ -52,7 +53,7 @@ func NewRoutewayProvider(apiKey, baseURL string) *RoutewayProvider {
 		OpenRouterProvider: &OpenRouterProvider{
 			APIKey:  apiKey,
 			BaseURL: baseURL,
-			HTTP:    &http.Client{Timeout: 120 * time.Second},
+			HTTP:    httpclient.New("routeway", 120*time.Second),
 			AppName: "AgentCore-NG",
 		},
 	}
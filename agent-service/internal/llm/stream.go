@@ -0,0 +1,150 @@
+package llm
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// streamingProviders — провайдеры, которые отдают ответ потоком при
+// ChatRequest.Stream, в том числе вместе с вызовами инструментов.
+var streamingProviders = map[string]bool{
+	"ollama":     true,
+	"openai":     true,
+	"openrouter": true,
+	"routeway":   true,
+	"cerebras":   true,
+	"lmstudio":   true,
+}
+
+// SupportsStreaming — провайдер поддерживает потоковый ответ (OnDelta).
+// Остальные провайдеры игнорируют Stream и отвечают целиком.
+func SupportsStreaming(provider string) bool {
+	return streamingProviders[provider]
+}
+
+// openaiStreamChunk — событие SSE-стрима OpenAI Chat Completions.
+// Текст и аргументы вызовов приходят фрагментами в choices[0].delta.
+type openaiStreamChunk struct {
+	Model   string `json:"model"`
+	Choices []struct {
+		Delta struct {
+			Content   string `json:"content"`
+			ToolCalls []struct {
+				Index    int    `json:"index"` // Номер вызова в ответе: фрагменты одного вызова имеют один index
+				ID       string `json:"id"`    // Приходит только в первом фрагменте вызова
+				Type     string `json:"type"`
+				Function struct {
+					Name      string `json:"name"`
+					Arguments string `json:"arguments"` // Очередной кусок JSON аргументов
+				} `json:"function"`
+			} `json:"tool_calls"`
+		} `json:"delta"`
+	} `json:"choices"`
+	Error *struct {
+		Message string `json:"message"`
+	} `json:"error,omitempty"`
+}
+
+// streamToolCall — вызов инструмента, собираемый из фрагментов.
+type streamToolCall struct {
+	id, typ, name string
+	args          strings.Builder
+}
+
+// readOpenAIStream — читает SSE-стрим OpenAI-совместимого API до [DONE]:
+// текст передаётся в onDelta по мере генерации, фрагменты tool_calls
+// склеиваются по index (id и имя — из первого фрагмента, аргументы —
+// конкатенацией). Если провайдер не передаёт index, новый id означает
+// новый вызов.
+func readOpenAIStream(body io.Reader, onDelta func(string)) (*ChatResponse, error) {
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 4*1024*1024)
+	var content strings.Builder
+	var model string
+	var calls []*streamToolCall
+	byIndex := map[int]*streamToolCall{}
+
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		// Пустые строки разделяют события, строки с «:» — комментарии (keep-alive OpenRouter)
+		data, ok := strings.CutPrefix(line, "data:")
+		if !ok {
+			continue
+		}
+		data = strings.TrimSpace(data)
+		if data == "[DONE]" {
+			break
+		}
+		var chunk openaiStreamChunk
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			return nil, fmt.Errorf("ошибка декодирования чанка стрима: %w", err)
+		}
+		if chunk.Error != nil {
+			return nil, fmt.Errorf("ошибка в стриме: %s", chunk.Error.Message)
+		}
+		if chunk.Model != "" {
+			model = chunk.Model
+		}
+		if len(chunk.Choices) == 0 {
+			continue
+		}
+		delta := chunk.Choices[0].Delta
+		if delta.Content != "" {
+			content.WriteString(delta.Content)
+			if onDelta != nil {
+				onDelta(delta.Content)
+			}
+		}
+		for _, frag := range delta.ToolCalls {
+			call := byIndex[frag.Index]
+			if call == nil || (frag.ID != "" && call.id != "" && frag.ID != call.id) {
+				call = &streamToolCall{}
+				byIndex[frag.Index] = call
+				calls = append(calls, call)
+			}
+			if call.id == "" {
+				call.id = frag.ID
+			}
+			if call.typ == "" {
+				call.typ = frag.Type
+			}
+			if call.name == "" {
+				call.name = frag.Function.Name
+			}
+			call.args.WriteString(frag.Function.Arguments)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка чтения стрима: %w", err)
+	}
+
+	var toolCalls []ToolCall
+	for i, c := range calls {
+		tc := ToolCall{
+			ID:   c.id,
+			Type: c.typ,
+			Function: FunctionCall{
+				Name:      c.name,
+				Arguments: json.RawMessage(c.args.String()),
+			},
+		}
+		if tc.ID == "" {
+			tc.ID = fmt.Sprintf("call_%d", i)
+		}
+		if tc.Type == "" {
+			tc.Type = "function"
+		}
+		if strings.TrimSpace(c.args.String()) == "" {
+			tc.Function.Arguments = json.RawMessage("{}")
+		}
+		toolCalls = append(toolCalls, tc)
+	}
+	return &ChatResponse{
+		Content:   content.String(),
+		ToolCalls: toolCalls,
+		Model:     model,
+	}, nil
+}
@@ -0,0 +1,88 @@
+package llm
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// TestChatStreamToolCalls — текст и вызовы инструментов собираются из
+// фрагментов SSE-стрима OpenAI-совместимого API.
+func TestChatStreamToolCalls(t *testing.T) {
+	events := []string{
+		`{"model":"gpt-4o","choices":[{"delta":{"role":"assistant","content":"Сейчас "}}]}`,
+		`{"choices":[{"delta":{"content":"посмотрю."}}]}`,
+		`{"choices":[{"delta":{"tool_calls":[{"index":0,"id":"call_a","type":"function","function":{"name":"read","arguments":""}}]}}]}`,
+		`{"choices":[{"delta":{"tool_calls":[{"index":0,"function":{"arguments":"{\"pa"}}]}}]}`,
+		`{"choices":[{"delta":{"tool_calls":[{"index":1,"id":"call_b","type":"function","function":{"name":"list","arguments":"{\"dir\":"}}]}}]}`,
+		`{"choices":[{"delta":{"tool_calls":[{"index":0,"function":{"arguments":"th\":\"/etc/hosts\"}"}}]}}]}`,
+		`{"choices":[{"delta":{"tool_calls":[{"index":1,"function":{"arguments":"\"/tmp\"}"}}]}}]}`,
+		`{"choices":[{"delta":{"tool_calls":[{"index":2,"id":"call_c","function":{"name":"sysinfo"}}]}}]}`,
+		`{"choices":[{"delta":{},"finish_reason":"tool_calls"}]}`,
+	}
+	var got map[string]interface{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&got)
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Write([]byte(": OPENROUTER PROCESSING\n\n"))
+		for _, e := range events {
+			w.Write([]byte("data: " + e + "\n\n"))
+		}
+		w.Write([]byte("data: [DONE]\n\n"))
+	}))
+	defer srv.Close()
+
+	var deltas []string
+	resp, err := NewOpenAIProvider("key", srv.URL).Chat(&ChatRequest{
+		Model:    "gpt-4o",
+		Messages: []Message{{Role: "user", Content: "q"}},
+		Tools:    []Tool{{Type: "function", Function: FunctionDefinition{Name: "read"}}},
+		Stream:   true,
+		OnDelta:  func(text string) { deltas = append(deltas, text) },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got["stream"] != true || got["tools"] == nil {
+		t.Errorf("запрос: stream=%v tools=%v", got["stream"], got["tools"])
+	}
+	if resp.Content != "Сейчас посмотрю." || strings.Join(deltas, "|") != "Сейчас |посмотрю." || resp.Model != "gpt-4o" {
+		t.Errorf("текст %q, фрагменты %q, модель %q", resp.Content, deltas, resp.Model)
+	}
+	want := []struct{ id, name, args string }{
+		{"call_a", "read", `{"path":"/etc/hosts"}`},
+		{"call_b", "list", `{"dir":"/tmp"}`},
+		{"call_c", "sysinfo", `{}`},
+	}
+	if len(resp.ToolCalls) != len(want) {
+		t.Fatalf("вызовы: %+v", resp.ToolCalls)
+	}
+	for i, w := range want {
+		tc := resp.ToolCalls[i]
+		if tc.ID != w.id || tc.Type != "function" || tc.Function.Name != w.name || string(tc.Function.Arguments) != w.args {
+			t.Errorf("вызов %d: %s %s %s %s", i, tc.ID, tc.Type, tc.Function.Name, tc.Function.Arguments)
+		}
+	}
+}
+
+// TestReadOpenAIStreamWithoutIndex — провайдер без index: новый id — новый вызов.
+func TestReadOpenAIStreamWithoutIndex(t *testing.T) {
+	body := strings.Join([]string{
+		`data: {"choices":[{"delta":{"tool_calls":[{"id":"1","function":{"name":"read","arguments":"{\"path\":"}}]}}]}`,
+		`data: {"choices":[{"delta":{"tool_calls":[{"function":{"arguments":"\"a\"}"}}]}}]}`,
+		`data: {"choices":[{"delta":{"tool_calls":[{"id":"2","function":{"name":"read","arguments":"{\"path\":\"b\"}"}}]}}]}`,
+		`data: [DONE]`,
+	}, "\n\n")
+	resp, err := readOpenAIStream(strings.NewReader(body), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.ToolCalls) != 2 || string(resp.ToolCalls[0].Function.Arguments) != `{"path":"a"}` || string(resp.ToolCalls[1].Function.Arguments) != `{"path":"b"}` {
+		t.Errorf("вызовы: %+v", resp.ToolCalls)
+	}
+	if _, err := readOpenAIStream(strings.NewReader(`data: {"error":{"message":"rate limit"}}`), nil); err == nil || !strings.Contains(err.Error(), "rate limit") {
+		t.Errorf("ошибка в стриме: %v", err)
+	}
+}
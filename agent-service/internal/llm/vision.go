@@ -0,0 +1,120 @@
+package llm
+
+import (
+	"encoding/base64"
+	"net/http"
+	"strings"
+)
+
+// visionProviders — провайдеры, в запросы которых встроена передача изображений.
+var visionProviders = map[string]bool{
+	"ollama":     true,
+	"openai":     true,
+	"openrouter": true,
+	"anthropic":  true,
+}
+
+// visionModelMarkers — подстроки имён мультимодальных моделей.
+var visionModelMarkers = []string{
+	"gpt-4o", "gpt-4.1", "gpt-4-turbo", "gpt-4-vision", "gpt-5",
+	"claude-3", "claude-sonnet-4", "claude-opus-4", "claude-haiku-4",
+	"llava", "bakllava", "vision", "-vl", "vl:", "minicpm-v", "moondream",
+	"gemma3", "pixtral", "gemini",
+}
+
+// SupportsVision — может ли модель провайдера принимать изображения.
+// Определяется по имени модели; для остальных изображения заменяются
+// текстовым описанием (см. internal/vision).
+func SupportsVision(provider, model string) bool {
+	if !visionProviders[provider] {
+		return false
+	}
+	m := strings.ToLower(model)
+	for _, marker := range visionModelMarkers {
+		if strings.Contains(m, marker) {
+			return true
+		}
+	}
+	return false
+}
+
+// HasImages — есть ли изображения хотя бы в одном сообщении.
+func HasImages(msgs []Message) bool {
+	for _, m := range msgs {
+		if len(m.Images) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// ImageMediaType — MIME-тип изображения в base64 (image/png, image/jpeg, ...).
+func ImageMediaType(b64 string) string {
+	head := b64
+	if len(head) > 64 {
+		head = head[:64]
+	}
+	data, err := base64.StdEncoding.DecodeString(head[:len(head)/4*4])
+	if err != nil {
+		return "image/png"
+	}
+	if t := http.DetectContentType(data); strings.HasPrefix(t, "image/") {
+		return t
+	}
+	return "image/png"
+}
+
+// openaiContentPart — часть мультимодального сообщения в формате OpenAI.
+type openaiContentPart struct {
+	Type     string          `json:"type"`                // text или image_url
+	Text     string          `json:"text,omitempty"`      // Текст (для type=text)
+	ImageURL *openaiImageURL `json:"image_url,omitempty"` // Изображение (для type=image_url)
+}
+
+type openaiImageURL struct {
+	URL string `json:"url"` // data:image/png;base64,...
+}
+
+// openaiContent — содержимое сообщения для OpenAI-совместимых API:
+// строка, а при наличии изображений — массив частей text + image_url.
+func openaiContent(m Message) any {
+	if len(m.Images) == 0 {
+		return m.Content
+	}
+	parts := []openaiContentPart{{Type: "text", Text: m.Content}}
+	for _, img := range m.Images {
+		parts = append(parts, openaiContentPart{
+			Type:     "image_url",
+			ImageURL: &openaiImageURL{URL: "data:" + ImageMediaType(img) + ";base64," + img},
+		})
+	}
+	return parts
+}
+
+// anthropicBlock — блок содержимого сообщения Anthropic (текст или изображение).
+type anthropicBlock struct {
+	Type   string                `json:"type"`             // text или image
+	Text   string                `json:"text,omitempty"`   // Текст (для type=text)
+	Source *anthropicImageSource `json:"source,omitempty"` // Изображение (для type=image)
+}
+
+type anthropicImageSource struct {
+	Type      string `json:"type"`       // base64
+	MediaType string `json:"media_type"` // image/png, image/jpeg, ...
+	Data      string `json:"data"`       // Данные изображения в base64
+}
+
+// anthropicContent — строка или блоки image + text, если есть изображения.
+func anthropicContent(m Message) any {
+	if len(m.Images) == 0 {
+		return m.Content
+	}
+	blocks := make([]anthropicBlock, 0, len(m.Images)+1)
+	for _, img := range m.Images {
+		blocks = append(blocks, anthropicBlock{
+			Type:   "image",
+			Source: &anthropicImageSource{Type: "base64", MediaType: ImageMediaType(img), Data: img},
+		})
+	}
+	return append(blocks, anthropicBlock{Type: "text", Text: m.Content})
+}
@@ -0,0 +1,43 @@
+package llm
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+// TestSupportsVision — мультимодальность определяется по провайдеру и имени модели.
+func TestSupportsVision(t *testing.T) {
+	cases := []struct {
+		provider, model string
+		want            bool
+	}{
+		{"openai", "gpt-4o-mini", true},
+		{"ollama", "llava:13b", true},
+		{"ollama", "llama3.2-vision", true},
+		{"anthropic", "claude-sonnet-4-20250514", true},
+		{"ollama", "llama3.1:8b", false},
+		{"yandexgpt", "gpt-4o", false},
+	}
+	for _, c := range cases {
+		if got := SupportsVision(c.provider, c.model); got != c.want {
+			t.Errorf("SupportsVision(%s, %s) = %v", c.provider, c.model, got)
+		}
+	}
+}
+
+// TestOpenAIContent — при наличии изображений сообщение кодируется частями text + image_url.
+func TestOpenAIContent(t *testing.T) {
+	if c := openaiContent(Message{Content: "текст"}); c != "текст" {
+		t.Errorf("без изображений ожидалась строка, получено %v", c)
+	}
+	png := "iVBORw0KGgoAAAANSUhEUgAA"
+	data, _ := json.Marshal(openaiContent(Message{Content: "что это?", Images: []string{png}}))
+	if !strings.Contains(string(data), `"image_url":{"url":"data:image/png;base64,`+png) {
+		t.Errorf("неверный формат: %s", data)
+	}
+	data, _ = json.Marshal(anthropicContent(Message{Content: "что это?", Images: []string{png}}))
+	if !strings.Contains(string(data), `"media_type":"image/png"`) {
+		t.Errorf("неверный формат Anthropic: %s", data)
+	}
+}
@@ -16,6 +16,9 @@ import (
 	"strings"
 	"sync"
 	"time"
+
+	"github.com/neo-2022/openclaw-memory/agent-service/internal/httpclient"
+	"github.com/neo-2022/openclaw-memory/agent-service/internal/retry"
 )
 
 type YandexGPTProvider struct {
@@ -47,7 +50,7 @@ func NewYandexGPTProvider(apiKey, folderID, baseURL, saJSON string) *YandexGPTPr
 		FolderID:           folderID,
 		BaseURL:            baseURL,
 		ServiceAccountJSON: saJSON,
-		HTTP:               &http.Client{Timeout: 120 * time.Second},
+		HTTP:               httpclient.New("yandexgpt", 120*time.Second),
 	}
 }
 
@@ -283,6 +286,9 @@ func (p *YandexGPTProvider) Chat(req *ChatRequest) (*ChatResponse, error) {
 		},
 		Messages: msgs,
 	}
+	if req.Temperature != nil {
+		yReq.CompletionOptions.Temperature = *req.Temperature
+	}
 
 	data, err := json.Marshal(yReq)
 	if err != nil {
@@ -306,7 +312,7 @@ func (p *YandexGPTProvider) Chat(req *ChatRequest) (*ChatResponse, error) {
 
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("YandexGPT HTTP %d: %s", resp.StatusCode, translateProviderError(resp.StatusCode, string(body)))
+		return nil, retry.FromResponse("YandexGPT", resp, translateProviderError(resp.StatusCode, string(body)))
 	}
 
 	var yResp yandexResponse
@@ -377,7 +383,7 @@ func (p *YandexGPTProvider) Validate() error {
 
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("YandexGPT HTTP %d: %s", resp.StatusCode, translateProviderError(resp.StatusCode, string(body)))
+		return retry.FromResponse("YandexGPT", resp, translateProviderError(resp.StatusCode, string(body)))
 	}
 
 	var yResp yandexResponse
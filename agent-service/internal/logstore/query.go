@@ -0,0 +1,161 @@
+package logstore
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/neo-2022/openclaw-memory/agent-service/internal/models"
+	"gorm.io/gorm"
+)
+
+// ftsConfig — конфигурация полнотекстового поиска Postgres (стемминг русского;
+// английские слова и имена вроде "Ollama" совпадают как есть).
+const ftsConfig = "russian"
+
+// ftsDocument — выражение, по которому строится индекс и выполняется поиск.
+// Должно совпадать с выражением индекса idx_system_logs_fts, иначе индекс не используется.
+const ftsDocument = "to_tsvector('" + ftsConfig + "', coalesce(message, '') || ' ' || coalesce(details, ''))"
+
+// EnsureSearchIndex — создаёт GIN-индекс полнотекстового поиска по message и details.
+// Для других СУБД ничего не делает: поиск выполняется через LIKE.
+func EnsureSearchIndex(db *gorm.DB) error {
+	if db.Dialector.Name() != "postgres" {
+		return nil
+	}
+	return db.Exec("CREATE INDEX IF NOT EXISTS idx_system_logs_fts ON system_logs USING GIN (" + ftsDocument + ")").Error
+}
+
+// MaxLimit — предел числа записей одной выборки: больший limit урезается.
+const MaxLimit = 1000
+
+// Query — фильтры выборки системного лога.
+type Query struct {
+	Text          string    // Полнотекстовый поиск по message и details
+	Level         string    // error, warn, info, debug
+	Service       string    // Сервис-источник
+	CorrelationID string    // X-Request-ID запроса
+	Resolved      *bool     // Только исправленные / неисправленные
+	Since         time.Time // Не раньше (включительно)
+	Until         time.Time // Раньше (не включительно)
+	AfterID       uint      // Только записи с id больше (для tail)
+	Limit         int       // Максимум записей (по умолчанию 100, не больше MaxLimit)
+}
+
+// ParseTime — разбирает границу интервала: RFC3339, дату 2006-01-02
+// или длительность назад от now ("1h", "30m", "7d").
+func ParseTime(s string, now time.Time) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02", s, time.Local); err == nil {
+		return t, nil
+	}
+	if strings.HasSuffix(s, "d") {
+		if days, err := strconv.Atoi(strings.TrimSuffix(s, "d")); err == nil && days >= 0 {
+			return now.Add(-time.Duration(days) * 24 * time.Hour), nil
+		}
+	}
+	if d, err := time.ParseDuration(s); err == nil && d >= 0 {
+		return now.Add(-d), nil
+	}
+	return time.Time{}, fmt.Errorf("некорректное время %q: ожидается RFC3339, 2006-01-02 или длительность (1h, 7d)", s)
+}
+
+// ParseQuery — собирает фильтры из параметров GET /logs:
+// q, level, service, correlation_id, resolved, since, until, limit
+// (не больше MaxLimit).
+func ParseQuery(v url.Values, now time.Time) (Query, error) {
+	q := Query{
+		Text:          strings.TrimSpace(v.Get("q")),
+		Level:         v.Get("level"),
+		Service:       v.Get("service"),
+		CorrelationID: v.Get("correlation_id"),
+		Limit:         100,
+	}
+	if r := v.Get("resolved"); r != "" {
+		b := r == "true"
+		q.Resolved = &b
+	}
+	var err error
+	if q.Since, err = ParseTime(v.Get("since"), now); err != nil {
+		return q, err
+	}
+	if q.Until, err = ParseTime(v.Get("until"), now); err != nil {
+		return q, err
+	}
+	if l, err := strconv.Atoi(v.Get("limit")); err == nil && l > 0 {
+		q.Limit = min(l, MaxLimit)
+	}
+	return q, nil
+}
+
+// Apply — добавляет фильтры к запросу по таблице system_logs.
+func (q Query) Apply(db *gorm.DB) *gorm.DB {
+	db = db.Model(&models.SystemLog{})
+	if q.Level != "" {
+		db = db.Where("level = ?", q.Level)
+	}
+	if q.Service != "" {
+		db = db.Where("service = ?", q.Service)
+	}
+	if q.CorrelationID != "" {
+		db = db.Where("correlation_id = ?", q.CorrelationID)
+	}
+	if q.Resolved != nil {
+		db = db.Where("resolved = ?", *q.Resolved)
+	}
+	if !q.Since.IsZero() {
+		db = db.Where("created_at >= ?", q.Since)
+	}
+	if !q.Until.IsZero() {
+		db = db.Where("created_at < ?", q.Until)
+	}
+	if q.AfterID > 0 {
+		db = db.Where("id > ?", q.AfterID)
+	}
+	if q.Text != "" {
+		if db.Dialector.Name() == "postgres" {
+			db = db.Where(ftsDocument+" @@ plainto_tsquery('"+ftsConfig+"', ?)", q.Text)
+		} else {
+			like := "%" + strings.ToLower(q.Text) + "%"
+			db = db.Where("(LOWER(message) LIKE ? OR LOWER(details) LIKE ?)", like, like)
+		}
+	}
+	return db
+}
+
+// Find — последние записи, подходящие под фильтры (новые первыми).
+func (q Query) Find(db *gorm.DB) ([]models.SystemLog, error) {
+	var logs []models.SystemLog
+	err := q.Apply(db).Order("created_at DESC").Limit(q.Limit).Find(&logs).Error
+	return logs, err
+}
+
+// Bucket — одна группа агрегации.
+type Bucket struct {
+	Key   string `json:"key"`
+	Count int64  `json:"count"`
+}
+
+// groupColumns — допустимые поля группировки (защита от подстановки произвольного SQL).
+var groupColumns = map[string]string{
+	"service": "service",
+	"level":   "level",
+}
+
+// Aggregate — количество записей по полю field (service или level) с учётом фильтров.
+func (q Query) Aggregate(db *gorm.DB, field string) ([]Bucket, error) {
+	col, ok := groupColumns[field]
+	if !ok {
+		return nil, fmt.Errorf("группировка возможна только по service или level")
+	}
+	var buckets []Bucket
+	err := q.Apply(db).Select(col + " AS key, COUNT(*) AS count").Group(col).Order("count DESC").Scan(&buckets).Error
+	return buckets, err
+}
@@ -0,0 +1,67 @@
+package logstore
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/neo-2022/openclaw-memory/agent-service/internal/models"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+// TestParseTime — абсолютные даты и длительности назад от текущего момента.
+func TestParseTime(t *testing.T) {
+	now := time.Date(2025, 3, 10, 12, 0, 0, 0, time.UTC)
+	cases := map[string]time.Time{
+		"1h":                   now.Add(-time.Hour),
+		"7d":                   now.Add(-7 * 24 * time.Hour),
+		"2025-03-01T08:00:00Z": time.Date(2025, 3, 1, 8, 0, 0, 0, time.UTC),
+	}
+	for in, want := range cases {
+		got, err := ParseTime(in, now)
+		if err != nil || !got.Equal(want) {
+			t.Errorf("%s: ожидалось %v, получено %v (%v)", in, want, got, err)
+		}
+	}
+	if _, err := ParseTime("вчера", now); err == nil {
+		t.Error("ожидалась ошибка для некорректного значения")
+	}
+}
+
+// TestParseQuery_Limit — limit по умолчанию, из параметра и урезанный до MaxLimit.
+func TestParseQuery_Limit(t *testing.T) {
+	for in, want := range map[string]int{"": 100, "50": 50, "0": 100, "-5": 100, "abc": 100, "1000000": MaxLimit} {
+		q, err := ParseQuery(url.Values{"limit": {in}}, time.Now())
+		if err != nil || q.Limit != want {
+			t.Errorf("limit=%q: ожидалось %d, получено %d (%v)", in, want, q.Limit, err)
+		}
+	}
+}
+
+// TestQuery_FullTextSQL — текстовый поиск и интервал превращаются в условия Postgres.
+func TestQuery_FullTextSQL(t *testing.T) {
+	db, err := gorm.Open(postgres.New(postgres.Config{DSN: "host=localhost"}), &gorm.Config{
+		DryRun:               true,
+		DisableAutomaticPing: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	q, err := ParseQuery(url.Values{"q": {"ollama"}, "level": {"error"}, "since": {"1h"}}, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var logs []models.SystemLog
+	sql := q.Apply(db).Find(&logs).Statement.SQL.String()
+	for _, part := range []string{"level = $", "created_at >= $", "@@ plainto_tsquery('russian'", ftsDocument} {
+		if !strings.Contains(sql, part) {
+			t.Errorf("в запросе нет %q: %s", part, sql)
+		}
+	}
+
+	if _, err := q.Aggregate(db, "message; DROP TABLE system_logs"); err == nil {
+		t.Error("группировка по произвольному выражению должна отклоняться")
+	}
+}
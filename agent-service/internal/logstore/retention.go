@@ -0,0 +1,279 @@
+// Package logstore — обслуживание таблицы системного лога (SystemLog):
+// политика хранения, фоновая очистка и архивирование старых записей.
+package logstore
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/neo-2022/openclaw-memory/agent-service/internal/models"
+	"gorm.io/gorm"
+)
+
+// pruneBatchSize — сколько записей удаляется (и архивируется) за один запрос к БД.
+const pruneBatchSize = 5000
+
+// RetentionConfig — политика хранения системного лога.
+type RetentionConfig struct {
+	MaxAge   time.Duration // Записи старше удаляются (0 — без ограничения по возрасту)
+	MaxRows  int           // Сколько последних записей хранить (0 — без ограничения)
+	Interval time.Duration // Период фоновой очистки
+	// ArchiveDir — каталог для архивов .jsonl.gz удаляемых записей (пусто — без архива)
+	ArchiveDir string
+	// ArchiveYDiskDir — папка на Яндекс.Диске для архивов (пусто — не выгружать)
+	ArchiveYDiskDir string
+}
+
+// LoadRetentionConfig — читает политику из переменных окружения:
+// LOG_RETENTION_DAYS (30), LOG_RETENTION_MAX_ROWS (100000),
+// LOG_RETENTION_INTERVAL (1h), LOG_ARCHIVE_DIR, LOG_ARCHIVE_YDISK_DIR.
+func LoadRetentionConfig() RetentionConfig {
+	cfg := RetentionConfig{
+		MaxAge:          30 * 24 * time.Hour,
+		MaxRows:         100000,
+		Interval:        time.Hour,
+		ArchiveDir:      os.Getenv("LOG_ARCHIVE_DIR"),
+		ArchiveYDiskDir: os.Getenv("LOG_ARCHIVE_YDISK_DIR"),
+	}
+	if v, err := strconv.Atoi(os.Getenv("LOG_RETENTION_DAYS")); err == nil && v >= 0 {
+		cfg.MaxAge = time.Duration(v) * 24 * time.Hour
+	}
+	if v, err := strconv.Atoi(os.Getenv("LOG_RETENTION_MAX_ROWS")); err == nil && v >= 0 {
+		cfg.MaxRows = v
+	}
+	if v, err := time.ParseDuration(os.Getenv("LOG_RETENTION_INTERVAL")); err == nil && v > 0 {
+		cfg.Interval = v
+	}
+	return cfg
+}
+
+// Pruner — удаляет устаревшие записи системного лога, предварительно
+// сохраняя их в архив (локальный файл и/или Яндекс.Диск).
+type Pruner struct {
+	DB  *gorm.DB
+	Cfg RetentionConfig
+	// Upload — выгрузка текстового архива на Яндекс.Диск (через tools-service).
+	// Если nil, ArchiveYDiskDir игнорируется.
+	Upload func(path, content string) error
+}
+
+// PruneResult — итог одной очистки.
+type PruneResult struct {
+	Deleted  int64    `json:"deleted"`
+	Archives []string `json:"archives,omitempty"`
+}
+
+// Run — периодически применяет политику хранения до отмены ctx.
+func (p *Pruner) Run(ctx context.Context) {
+	if p.Cfg.MaxAge == 0 && p.Cfg.MaxRows == 0 {
+		slog.Info("Очистка системного лога отключена")
+		return
+	}
+	ticker := time.NewTicker(p.Cfg.Interval)
+	defer ticker.Stop()
+	for {
+		if res, err := p.ApplyPolicy(ctx); err != nil {
+			slog.Error("Ошибка очистки системного лога", slog.String("ошибка", err.Error()))
+		} else if res.Deleted > 0 {
+			slog.Info("Системный лог очищен", slog.Int64("удалено", res.Deleted), slog.Any("архивы", res.Archives))
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// ApplyPolicy — удаляет записи старше MaxAge и сверх MaxRows последних.
+func (p *Pruner) ApplyPolicy(ctx context.Context) (PruneResult, error) {
+	var total PruneResult
+	if p.Cfg.MaxAge > 0 {
+		res, err := p.PruneBefore(ctx, time.Now().Add(-p.Cfg.MaxAge))
+		total.Deleted += res.Deleted
+		total.Archives = append(total.Archives, res.Archives...)
+		if err != nil {
+			return total, err
+		}
+	}
+	if p.Cfg.MaxRows > 0 {
+		var boundary models.SystemLog
+		err := p.DB.WithContext(ctx).Unscoped().Order("id DESC").Offset(p.Cfg.MaxRows).Limit(1).
+			Select("id").Find(&boundary).Error
+		if err != nil {
+			return total, fmt.Errorf("поиск границы по числу записей: %w", err)
+		}
+		if boundary.ID > 0 {
+			res, err := p.prune(ctx, "id <= ?", boundary.ID)
+			total.Deleted += res.Deleted
+			total.Archives = append(total.Archives, res.Archives...)
+			if err != nil {
+				return total, err
+			}
+		}
+	}
+	return total, nil
+}
+
+// PruneBefore — удаляет (с архивированием) все записи, созданные раньше before.
+func (p *Pruner) PruneBefore(ctx context.Context, before time.Time) (PruneResult, error) {
+	return p.prune(ctx, "created_at < ?", before)
+}
+
+// prune — пачками выбирает записи по условию, архивирует и удаляет их физически
+// (gorm.Model по умолчанию только помечает запись удалённой). Пачка удаляется
+// только после того, как она записана в архив на диск.
+func (p *Pruner) prune(ctx context.Context, cond string, arg interface{}) (res PruneResult, err error) {
+	archive, err := p.newArchive()
+	if err != nil {
+		return res, err
+	}
+	defer func() {
+		name, ok, closeErr := archive.close()
+		if ok {
+			res.Archives = append(res.Archives, name)
+		}
+		if closeErr != nil && err == nil {
+			err = fmt.Errorf("закрытие архива: %w", closeErr)
+		}
+	}()
+
+	for part := 1; ; part++ {
+		var batch []models.SystemLog
+		err := p.DB.WithContext(ctx).Unscoped().Where(cond, arg).Order("id").Limit(pruneBatchSize).Find(&batch).Error
+		if err != nil {
+			return res, fmt.Errorf("выборка записей лога: %w", err)
+		}
+		if len(batch) == 0 {
+			return res, nil
+		}
+		text, err := archive.write(batch)
+		if err == nil {
+			err = archive.sync()
+		}
+		if err != nil {
+			return res, fmt.Errorf("запись архива: %w", err)
+		}
+		if p.Cfg.ArchiveYDiskDir != "" && p.Upload != nil {
+			remote := fmt.Sprintf("%s/systemlog-%s-%d.jsonl", strings.TrimRight(p.Cfg.ArchiveYDiskDir, "/"), archive.stamp, part)
+			if err := p.Upload(remote, text); err != nil {
+				return res, fmt.Errorf("выгрузка архива на Яндекс.Диск: %w", err)
+			}
+			res.Archives = append(res.Archives, "ydisk:"+remote)
+		}
+		ids := make([]uint, len(batch))
+		for i, l := range batch {
+			ids[i] = l.ID
+		}
+		del := p.DB.WithContext(ctx).Unscoped().Where("id IN ?", ids).Delete(&models.SystemLog{})
+		if del.Error != nil {
+			return res, fmt.Errorf("удаление записей лога: %w", del.Error)
+		}
+		res.Deleted += del.RowsAffected
+		if len(batch) < pruneBatchSize {
+			return res, nil
+		}
+	}
+}
+
+// archiveRecord — строка архива (JSON Lines).
+type archiveRecord struct {
+	ID            uint      `json:"id"`
+	Time          time.Time `json:"time"`
+	Level         string    `json:"level"`
+	Service       string    `json:"service"`
+	Message       string    `json:"message"`
+	Details       string    `json:"details,omitempty"`
+	CorrelationID string    `json:"correlation_id,omitempty"`
+	Resolved      bool      `json:"resolved,omitempty"`
+}
+
+// archive — файл .jsonl.gz, создаваемый лениво при первой записи.
+type archive struct {
+	dir   string
+	stamp string
+	path  string
+	file  *os.File
+	gz    *gzip.Writer
+	buf   *bufio.Writer
+}
+
+func (p *Pruner) newArchive() (*archive, error) {
+	a := &archive{dir: p.Cfg.ArchiveDir, stamp: time.Now().Format("20060102-150405")}
+	if a.dir != "" {
+		if err := os.MkdirAll(a.dir, 0755); err != nil {
+			return nil, fmt.Errorf("каталог архива логов: %w", err)
+		}
+	}
+	return a, nil
+}
+
+// write — дописывает пачку в локальный архив и возвращает её в виде JSON Lines.
+func (a *archive) write(batch []models.SystemLog) (string, error) {
+	var sb strings.Builder
+	for _, l := range batch {
+		line, err := json.Marshal(archiveRecord{
+			ID: l.ID, Time: l.CreatedAt, Level: l.Level, Service: l.Service,
+			Message: l.Message, Details: l.Details, CorrelationID: l.CorrelationID, Resolved: l.Resolved,
+		})
+		if err != nil {
+			return "", err
+		}
+		sb.Write(line)
+		sb.WriteByte('\n')
+	}
+	if a.dir == "" {
+		return sb.String(), nil
+	}
+	if a.file == nil {
+		a.path = filepath.Join(a.dir, "systemlog-"+a.stamp+".jsonl.gz")
+		f, err := os.OpenFile(a.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			return "", err
+		}
+		a.file = f
+		a.gz = gzip.NewWriter(f)
+		a.buf = bufio.NewWriter(a.gz)
+	}
+	_, err := a.buf.WriteString(sb.String())
+	return sb.String(), err
+}
+
+// sync — сбрасывает записанное на диск: после него пачку можно удалять из БД.
+func (a *archive) sync() error {
+	if a.file == nil {
+		return nil
+	}
+	if err := a.buf.Flush(); err != nil {
+		return err
+	}
+	if err := a.gz.Flush(); err != nil {
+		return err
+	}
+	return a.file.Sync()
+}
+
+// close — закрывает архив; возвращает путь, если что-то было записано, и
+// первую ошибку сброса или закрытия.
+func (a *archive) close() (string, bool, error) {
+	if a.file == nil {
+		return "", false, nil
+	}
+	err := a.buf.Flush()
+	if gzErr := a.gz.Close(); err == nil {
+		err = gzErr
+	}
+	if fileErr := a.file.Close(); err == nil {
+		err = fileErr
+	}
+	return a.path, true, err
+}
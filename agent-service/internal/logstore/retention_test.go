@@ -0,0 +1,80 @@
+package logstore
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/neo-2022/openclaw-memory/agent-service/internal/models"
+)
+
+// TestLoadRetentionConfig — значения по умолчанию и переопределение из окружения.
+func TestLoadRetentionConfig(t *testing.T) {
+	cfg := LoadRetentionConfig()
+	if cfg.MaxAge != 30*24*time.Hour || cfg.MaxRows != 100000 || cfg.Interval != time.Hour {
+		t.Fatalf("неверные значения по умолчанию: %+v", cfg)
+	}
+	t.Setenv("LOG_RETENTION_DAYS", "7")
+	t.Setenv("LOG_RETENTION_MAX_ROWS", "0")
+	t.Setenv("LOG_RETENTION_INTERVAL", "10m")
+	cfg = LoadRetentionConfig()
+	if cfg.MaxAge != 7*24*time.Hour || cfg.MaxRows != 0 || cfg.Interval != 10*time.Minute {
+		t.Fatalf("переменные окружения не применены: %+v", cfg)
+	}
+}
+
+// TestArchive_WritesGzipJSONLines — архив читается как gzip с JSON Lines.
+func TestArchive_WritesGzipJSONLines(t *testing.T) {
+	p := &Pruner{Cfg: RetentionConfig{ArchiveDir: t.TempDir()}}
+	a, err := p.newArchive()
+	if err != nil {
+		t.Fatal(err)
+	}
+	batch := []models.SystemLog{
+		{Level: "error", Service: "tools-service", Message: "нет доступа", CorrelationID: "req-1"},
+		{Level: "warn", Service: "agent-service", Message: "медленный ответ"},
+	}
+	batch[0].ID, batch[1].ID = 1, 2
+	text, err := a.write(batch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := a.sync(); err != nil {
+		t.Fatal(err)
+	}
+	path, ok, err := a.close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ok {
+		t.Fatal("архив должен быть создан")
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatalf("архив не в формате gzip: %v", err)
+	}
+	sc := bufio.NewScanner(zr)
+	var lines []archiveRecord
+	for sc.Scan() {
+		var rec archiveRecord
+		if err := json.Unmarshal(sc.Bytes(), &rec); err != nil {
+			t.Fatalf("строка архива не JSON: %v", err)
+		}
+		lines = append(lines, rec)
+	}
+	if len(lines) != 2 || lines[0].CorrelationID != "req-1" || lines[1].Service != "agent-service" {
+		t.Fatalf("неверное содержимое архива: %+v", lines)
+	}
+	if len(text) == 0 {
+		t.Error("текстовая версия пачки (для Яндекс.Диска) не должна быть пустой")
+	}
+}
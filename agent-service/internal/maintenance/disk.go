@@ -0,0 +1,77 @@
+package maintenance
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+)
+
+// Disk — заполнение файловой системы с каталогами сервиса.
+type Disk struct {
+	Paths     []string `json:"paths"` // Каталоги на этой файловой системе
+	Total     uint64   `json:"total_bytes"`
+	Free      uint64   `json:"free_bytes"` // Доступно непривилегированному процессу
+	UsedShare float64  `json:"used_share"` // 0..1
+}
+
+// CheckDisks — заполнение файловых систем каталогов paths (каталоги на одной
+// файловой системе объединяются); несуществующие каталоги пропускаются.
+// warn — доля заполнения, с которой диск попадает в предупреждение.
+func CheckDisks(paths []string, warn float64) (Outcome, error) {
+	byFS := map[uint64]*Disk{}
+	var order []uint64
+	var lastErr error
+	for _, p := range paths {
+		if p == "" {
+			continue
+		}
+		abs, err := filepath.Abs(p)
+		if err != nil {
+			continue
+		}
+		st, err := diskUsage(abs)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		d := byFS[st.id]
+		if d == nil {
+			d = &Disk{Total: st.total, Free: st.free}
+			if st.total > 0 {
+				d.UsedShare = float64(st.total-st.free) / float64(st.total)
+			}
+			byFS[st.id] = d
+			order = append(order, st.id)
+		}
+		d.Paths = append(d.Paths, abs)
+	}
+	if len(order) == 0 && lastErr != nil {
+		return Outcome{}, lastErr
+	}
+	disks := make([]Disk, 0, len(order))
+	for _, id := range order {
+		disks = append(disks, *byFS[id])
+	}
+	sort.SliceStable(disks, func(i, j int) bool { return disks[i].UsedShare > disks[j].UsedShare })
+	out := Outcome{Details: disks, Summary: "нет данных о дисках"}
+	if len(disks) > 0 {
+		d := disks[0]
+		out.Summary = fmt.Sprintf("занято %.0f%%, свободно %s (%s)", d.UsedShare*100, humanBytes(d.Free), d.Paths[0])
+		out.Warning = d.UsedShare >= warn
+	}
+	return out, nil
+}
+
+// fsStat — размер и свободное место файловой системы; id различает файловые системы.
+type fsStat struct {
+	id          uint64
+	total, free uint64
+}
+
+func humanBytes(n uint64) string {
+	const gb = 1 << 30
+	if n >= gb {
+		return fmt.Sprintf("%.1f GB", float64(n)/gb)
+	}
+	return fmt.Sprintf("%d MB", n>>20)
+}
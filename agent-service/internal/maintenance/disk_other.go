@@ -0,0 +1,9 @@
+//go:build !linux && !darwin
+
+package maintenance
+
+import "errors"
+
+func diskUsage(string) (fsStat, error) {
+	return fsStat{}, errors.New("проверка места на диске не поддерживается в этой ОС")
+}
@@ -0,0 +1,17 @@
+//go:build linux || darwin
+
+package maintenance
+
+import "syscall"
+
+func diskUsage(path string) (fsStat, error) {
+	var fs syscall.Statfs_t
+	if err := syscall.Statfs(path, &fs); err != nil {
+		return fsStat{}, err
+	}
+	var st syscall.Stat_t
+	if err := syscall.Stat(path, &st); err != nil {
+		return fsStat{}, err
+	}
+	return fsStat{id: uint64(st.Dev), total: fs.Blocks * uint64(fs.Bsize), free: fs.Bavail * uint64(fs.Bsize)}, nil
+}
@@ -0,0 +1,189 @@
+// Package maintenance — ночное обслуживание: очистка системного лога,
+// уплотнение индекса RAG, обновление списков моделей, проверка места на
+// диске и утренняя сводка.
+//
+// Конвейер — список задач (Task), которые выполняются по очереди: ошибка
+// одной задачи попадает в отчёт и не останавливает остальные. Отчёт (Report)
+// сохраняется артефактом и может отправляться в webhook уведомлений, чтобы
+// утром было видно, что произошло за ночь. Запуск раз в сутки в заданное
+// время (MAINTENANCE_TIME) — Schedule.
+package maintenance
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Статусы задачи и отчёта.
+const (
+	StatusOK      = "ok"
+	StatusWarning = "warning"
+	StatusError   = "error"
+)
+
+// disabledPoll — как часто проверять, не включили ли расписание.
+const disabledPoll = time.Minute
+
+// Outcome — итог задачи.
+type Outcome struct {
+	Summary string      // Одна строка для сводки
+	Details interface{} // Подробности для JSON-отчёта
+	Warning bool        // Задача выполнена, но требует внимания (например, мало места)
+}
+
+// Task — задача обслуживания.
+type Task struct {
+	Name  string // Идентификатор (log_pruning, rag_compaction…)
+	Title string // Заголовок в сводке
+	Run   func(ctx context.Context) (Outcome, error)
+}
+
+// TaskResult — итог задачи в отчёте.
+type TaskResult struct {
+	Name       string      `json:"name"`
+	Title      string      `json:"title"`
+	Status     string      `json:"status"`
+	Summary    string      `json:"summary,omitempty"`
+	Error      string      `json:"error,omitempty"`
+	DurationMs int64       `json:"duration_ms"`
+	Details    interface{} `json:"details,omitempty"`
+}
+
+// Report — отчёт одного прогона.
+type Report struct {
+	StartedAt   time.Time    `json:"started_at"`
+	FinishedAt  time.Time    `json:"finished_at"`
+	Status      string       `json:"status"` // Худший статус задач
+	Tasks       []TaskResult `json:"tasks"`
+	ArtifactID  uint         `json:"artifact_id,omitempty"`  // Сводка в хранилище артефактов
+	Notified    bool         `json:"notified"`               // Сводка отправлена в webhook
+	NotifyError string       `json:"notify_error,omitempty"` // Почему не отправлена
+}
+
+// Run — выполняет задачи по очереди; отмена ctx прерывает конвейер, и
+// оставшиеся задачи попадают в отчёт с ошибкой.
+func Run(ctx context.Context, tasks []Task) Report {
+	rep := Report{StartedAt: time.Now(), Status: StatusOK, Tasks: make([]TaskResult, 0, len(tasks))}
+	for _, t := range tasks {
+		res := TaskResult{Name: t.Name, Title: t.Title, Status: StatusOK}
+		start := time.Now()
+		if err := ctx.Err(); err != nil {
+			res.Status, res.Error = StatusError, "прервано: "+err.Error()
+		} else {
+			out, err := t.Run(ctx)
+			res.Summary, res.Details = out.Summary, out.Details
+			switch {
+			case err != nil:
+				res.Status, res.Error = StatusError, err.Error()
+			case out.Warning:
+				res.Status = StatusWarning
+			}
+		}
+		res.DurationMs = time.Since(start).Milliseconds()
+		rep.Status = worse(rep.Status, res.Status)
+		rep.Tasks = append(rep.Tasks, res)
+		slog.Info("Задача обслуживания выполнена", slog.String("задача", t.Name), slog.String("статус", res.Status), slog.Int64("мс", res.DurationMs))
+	}
+	rep.FinishedAt = time.Now()
+	return rep
+}
+
+// worse — более тяжёлый из двух статусов.
+func worse(a, b string) string {
+	rank := map[string]int{StatusOK: 0, StatusWarning: 1, StatusError: 2}
+	if rank[b] > rank[a] {
+		return b
+	}
+	return a
+}
+
+// Headline — одна строка итога: для системного лога и заголовка уведомления.
+func (r Report) Headline() string {
+	var warn, failed int
+	for _, t := range r.Tasks {
+		switch t.Status {
+		case StatusWarning:
+			warn++
+		case StatusError:
+			failed++
+		}
+	}
+	switch {
+	case failed > 0:
+		return fmt.Sprintf("Ночное обслуживание: ошибок %d, предупреждений %d из %d задач", failed, warn, len(r.Tasks))
+	case warn > 0:
+		return fmt.Sprintf("Ночное обслуживание: предупреждений %d из %d задач", warn, len(r.Tasks))
+	}
+	return fmt.Sprintf("Ночное обслуживание: все %d задач выполнены", len(r.Tasks))
+}
+
+// Markdown — утренняя сводка.
+func (r Report) Markdown() string {
+	marks := map[string]string{StatusOK: "OK", StatusWarning: "ВНИМАНИЕ", StatusError: "ОШИБКА"}
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Сводка обслуживания за %s\n\n", r.StartedAt.Format("02.01.2006"))
+	fmt.Fprintf(&b, "%s. Начало %s, длительность %s.\n\n", r.Headline(), r.StartedAt.Format("15:04:05"), r.FinishedAt.Sub(r.StartedAt).Round(time.Second))
+	for _, t := range r.Tasks {
+		fmt.Fprintf(&b, "- **%s** — %s", t.Title, marks[t.Status])
+		if t.Summary != "" {
+			b.WriteString(": " + t.Summary)
+		}
+		if t.Error != "" {
+			b.WriteString(" (" + t.Error + ")")
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// ParseTime — время запуска ЧЧ:ММ.
+func ParseTime(at string) (hour, minute int, err error) {
+	h, m, ok := strings.Cut(strings.TrimSpace(at), ":")
+	hour, errH := strconv.Atoi(h)
+	minute, errM := strconv.Atoi(m)
+	if !ok || errH != nil || errM != nil || hour < 0 || hour > 23 || minute < 0 || minute > 59 {
+		return 0, 0, fmt.Errorf("время %q, ожидается ЧЧ:ММ", at)
+	}
+	return hour, minute, nil
+}
+
+// NextRun — ближайший момент после now со временем at (в часовом поясе now).
+func NextRun(now time.Time, at string) (time.Time, error) {
+	hour, minute, err := ParseTime(at)
+	if err != nil {
+		return time.Time{}, err
+	}
+	next := time.Date(now.Year(), now.Month(), now.Day(), hour, minute, 0, 0, now.Location())
+	if !next.After(now) {
+		next = next.AddDate(0, 0, 1)
+	}
+	return next, nil
+}
+
+// Schedule — вызывает run каждый день во время at() до отмены ctx; пустое
+// at() — расписание выключено (изменение подхватывается без перезапуска).
+func Schedule(ctx context.Context, at func() string, run func(ctx context.Context)) {
+	for {
+		wait := disabledPoll
+		due := false
+		if next, err := NextRun(time.Now(), at()); err == nil {
+			if d := time.Until(next); d <= disabledPoll {
+				wait, due = d, true
+			}
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+		if due {
+			run(ctx)
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package maintenance
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRun(t *testing.T) {
+	var order []string
+	task := func(name string, out Outcome, err error) Task {
+		return Task{Name: name, Title: "Задача " + name, Run: func(context.Context) (Outcome, error) {
+			order = append(order, name)
+			return out, err
+		}}
+	}
+	rep := Run(context.Background(), []Task{
+		task("logs", Outcome{Summary: "удалено 10"}, nil),
+		task("rag", Outcome{}, errors.New("memory-service недоступен")),
+		task("disk", Outcome{Summary: "занято 95%", Warning: true}, nil),
+	})
+	if strings.Join(order, ",") != "logs,rag,disk" {
+		t.Errorf("ошибка задачи не останавливает остальные: %v", order)
+	}
+	if rep.Status != StatusError || rep.Tasks[1].Error != "memory-service недоступен" || rep.Tasks[2].Status != StatusWarning {
+		t.Errorf("отчёт: %+v", rep)
+	}
+	md := rep.Markdown()
+	for _, want := range []string{"ошибок 1, предупреждений 1 из 3", "**Задача disk** — ВНИМАНИЕ: занято 95%", "(memory-service недоступен)"} {
+		if !strings.Contains(md, want) {
+			t.Errorf("в сводке нет %q:\n%s", want, md)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	order = nil
+	if rep := Run(ctx, []Task{task("logs", Outcome{}, nil)}); len(order) != 0 || rep.Tasks[0].Status != StatusError {
+		t.Errorf("отмена прерывает конвейер: %v, %+v", order, rep)
+	}
+}
+
+func TestNextRun(t *testing.T) {
+	now := time.Date(2026, 3, 10, 2, 30, 0, 0, time.UTC)
+	for at, want := range map[string]time.Time{
+		"03:00": time.Date(2026, 3, 10, 3, 0, 0, 0, time.UTC),
+		"02:30": time.Date(2026, 3, 11, 2, 30, 0, 0, time.UTC),
+		"0:05":  time.Date(2026, 3, 11, 0, 5, 0, 0, time.UTC),
+	} {
+		if got, err := NextRun(now, at); err != nil || !got.Equal(want) {
+			t.Errorf("NextRun(%s) = %v, %v; ожидали %v", at, got, err, want)
+		}
+	}
+	for _, at := range []string{"", "3", "24:00", "03:60", "ab:cd"} {
+		if _, err := NextRun(now, at); err == nil {
+			t.Errorf("%q: ожидалась ошибка", at)
+		}
+	}
+}
+
+func TestCheckDisks(t *testing.T) {
+	dir := t.TempDir()
+	out, err := CheckDisks([]string{dir, dir + "/нет", ""}, 1.01)
+	if err != nil {
+		t.Skipf("проверка диска недоступна: %v", err)
+	}
+	disks := out.Details.([]Disk)
+	if len(disks) != 1 || disks[0].Total == 0 || out.Warning {
+		t.Errorf("диски: %+v", out)
+	}
+	if out, _ := CheckDisks([]string{dir}, 0); !out.Warning {
+		t.Error("порог 0 — всегда предупреждение")
+	}
+}
@@ -1,12 +1,14 @@
 package metrics
 
 import (
+	"database/sql"
 	"fmt"
 	"log"
 	"net/http"
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
 	"github.com/prometheus/client_golang/prometheus/promauto"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
@@ -45,6 +47,192 @@ var (
 		[]string{"agent", "provider", "model", "error_type"},
 	)
 
+	routerDecisionsTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "agent_service_router_decisions_total",
+			Help: "Total number of per-request model routing decisions",
+		},
+		[]string{"agent", "tier", "classifier"},
+	)
+
+	speculativeTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "agent_service_speculative_total",
+			Help: "Total number of speculative dual-dispatch requests by outcome",
+		},
+		[]string{"outcome"},
+	)
+
+	speculativeAgreement = promauto.NewHistogram(
+		prometheus.HistogramOpts{
+			Name:    "agent_service_speculative_agreement",
+			Help:    "Word-level similarity between the draft and the primary answer (0..1)",
+			Buckets: prometheus.LinearBuckets(0.1, 0.1, 10),
+		},
+	)
+
+	speculativeLead = promauto.NewHistogram(
+		prometheus.HistogramOpts{
+			Name:    "agent_service_speculative_lead_seconds",
+			Help:    "How much earlier the draft answer was shown than the primary one",
+			Buckets: []float64{.25, .5, 1, 2, 5, 10, 20, 40, 80},
+		},
+	)
+
+	guardFindingsTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "agent_service_guard_findings_total",
+			Help: "Total number of prompt-injection and output-safety findings",
+		},
+		[]string{"source", "kind", "action"},
+	)
+
+	toolRiskTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "agent_service_tool_risk_total",
+			Help: "Total number of tool calls by risk level and confirmation decision",
+		},
+		[]string{"level", "decision"},
+	)
+
+	toolCorrectionsTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "agent_service_tool_corrections_total",
+			Help: "Total number of failed tool calls the model was asked to retry, by model and outcome",
+		},
+		[]string{"model", "outcome"},
+	)
+
+	llmRetriesTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "agent_service_llm_retries_total",
+			Help: "Total number of LLM request retries after transient provider errors, by provider and reason",
+		},
+		[]string{"provider", "reason"},
+	)
+
+	llmRetryBudgetExhaustedTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "agent_service_llm_retry_budget_exhausted_total",
+			Help: "Total number of LLM retries skipped because the provider retry budget was exhausted",
+		},
+		[]string{"provider"},
+	)
+
+	llmRetryBudgetRemaining = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "agent_service_llm_retry_budget_remaining",
+			Help: "Retries left in the current one-minute budget window, by provider (-1 means unlimited)",
+		},
+		[]string{"provider"},
+	)
+
+	llmCircuitState = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "agent_service_llm_circuit_state",
+			Help: "LLM provider circuit breaker state (0 closed, 1 open, 2 half-open)",
+		},
+		[]string{"provider"},
+	)
+
+	llmCircuitRejectedTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "agent_service_llm_circuit_rejected_total",
+			Help: "Total number of LLM requests rejected by an open provider circuit breaker",
+		},
+		[]string{"provider"},
+	)
+
+	llmQuotaRemaining = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "agent_service_llm_quota_remaining",
+			Help: "Remaining cloud provider quota by limit (credits in USD, tokens or requests)",
+		},
+		[]string{"provider", "limit"},
+	)
+
+	httpClientRequestDuration = promauto.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "agent_service_http_client_request_duration_seconds",
+			Help:    "Outgoing HTTP request duration until response headers, by destination service or provider",
+			Buckets: prometheus.DefBuckets,
+		},
+		[]string{"destination", "status"},
+	)
+
+	httpClientConnsTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "agent_service_http_client_connections_total",
+			Help: "Total number of connections used by outgoing HTTP requests, new or reused from the keep-alive pool",
+		},
+		[]string{"destination", "reused"},
+	)
+
+	concurrencyRejectedTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "agent_service_concurrency_rejected_total",
+			Help: "Total number of requests rejected with 429 by a route or provider concurrency limit",
+		},
+		[]string{"scope"},
+	)
+
+	modelCatalogChangesTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "agent_service_model_catalog_changes_total",
+			Help: "Total number of models added to or removed from cloud provider catalogs",
+		},
+		[]string{"provider", "change"},
+	)
+
+	budgetExceededTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "agent_service_budget_exceeded_total",
+			Help: "Total number of cloud LLM requests stopped by an exhausted budget",
+		},
+		[]string{"scope", "action"},
+	)
+
+	responseCacheTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "agent_service_response_cache_total",
+			Help: "Total number of LLM response cache lookups by result",
+		},
+		[]string{"result"},
+	)
+
+	batchItemsTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "agent_service_batch_items_total",
+			Help: "Total number of /chat/batch items processed by status",
+		},
+		[]string{"status"},
+	)
+
+	jobsTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "agent_service_jobs_total",
+			Help: "Total number of background queue job attempts by kind and outcome",
+		},
+		[]string{"kind", "status"},
+	)
+
+	jobDuration = promauto.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "agent_service_job_duration_seconds",
+			Help:    "Background queue job attempt duration in seconds",
+			Buckets: []float64{0.1, 0.5, 1, 5, 15, 60, 300, 900},
+		},
+		[]string{"kind"},
+	)
+
+	toolResultsTruncatedTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "agent_service_tool_results_truncated_total",
+			Help: "Total number of tool results truncated before being added to the LLM context",
+		},
+		[]string{"tool"},
+	)
+
 	ragSearchesTotal = promauto.NewCounterVec(
 		prometheus.CounterOpts{
 			Name: "agent_service_rag_searches_total",
@@ -102,8 +290,28 @@ var (
 		},
 		[]string{"tool_name"},
 	)
+
+	dbQueryDuration = promauto.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "agent_service_db_query_duration_seconds",
+			Help:    "Database query duration in seconds",
+			Buckets: []float64{.001, .005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+		},
+		[]string{"operation", "table"},
+	)
+
+	dbQueryErrors = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "agent_service_db_query_errors_total",
+			Help: "Total number of failed database queries",
+		},
+		[]string{"operation", "table"},
+	)
 )
 
+// dbStatsCollector — метрики пула соединений (go_sql_*), задаётся RegisterDBStats.
+var dbStatsCollector prometheus.Collector
+
 var registered = false
 
 func Init() {
@@ -114,6 +322,29 @@ func Init() {
 			httpRequestDuration,
 			chatRequestsTotal,
 			chatRequestsErrors,
+			routerDecisionsTotal,
+			speculativeTotal,
+			speculativeAgreement,
+			speculativeLead,
+			guardFindingsTotal,
+			toolRiskTotal,
+			toolCorrectionsTotal,
+			llmRetriesTotal,
+			llmRetryBudgetExhaustedTotal,
+			llmRetryBudgetRemaining,
+			llmCircuitState,
+			llmCircuitRejectedTotal,
+			llmQuotaRemaining,
+			modelCatalogChangesTotal,
+			concurrencyRejectedTotal,
+			httpClientRequestDuration,
+			httpClientConnsTotal,
+			budgetExceededTotal,
+			responseCacheTotal,
+			batchItemsTotal,
+			jobsTotal,
+			jobDuration,
+			toolResultsTruncatedTotal,
 			ragSearchesTotal,
 			ragSearchDuration,
 			llmRequestsTotal,
@@ -121,6 +352,8 @@ func Init() {
 			llmRequestDuration,
 			toolCallsTotal,
 			toolCallDuration,
+			dbQueryDuration,
+			dbQueryErrors,
 		)
 		registered = true
 	}
@@ -137,6 +370,29 @@ func InitPrometheusHandler() http.Handler {
 			httpRequestDuration,
 			chatRequestsTotal,
 			chatRequestsErrors,
+			routerDecisionsTotal,
+			speculativeTotal,
+			speculativeAgreement,
+			speculativeLead,
+			guardFindingsTotal,
+			toolRiskTotal,
+			toolCorrectionsTotal,
+			llmRetriesTotal,
+			llmRetryBudgetExhaustedTotal,
+			llmRetryBudgetRemaining,
+			llmCircuitState,
+			llmCircuitRejectedTotal,
+			llmQuotaRemaining,
+			modelCatalogChangesTotal,
+			concurrencyRejectedTotal,
+			httpClientRequestDuration,
+			httpClientConnsTotal,
+			budgetExceededTotal,
+			responseCacheTotal,
+			batchItemsTotal,
+			jobsTotal,
+			jobDuration,
+			toolResultsTruncatedTotal,
 			ragSearchesTotal,
 			ragSearchDuration,
 			llmRequestsTotal,
@@ -144,7 +400,12 @@ func InitPrometheusHandler() http.Handler {
 			llmRequestDuration,
 			toolCallsTotal,
 			toolCallDuration,
+			dbQueryDuration,
+			dbQueryErrors,
 		)
+		if dbStatsCollector != nil {
+			metricsRegistry.MustRegister(dbStatsCollector)
+		}
 		log.Printf("[METRICS] Prometheus endpoint инициализирован")
 	}
 	return promhttp.HandlerFor(metricsRegistry, promhttp.HandlerOpts{})
@@ -163,6 +424,117 @@ func RecordChatError(agent, provider, model, errorType string) {
 	chatRequestsErrors.WithLabelValues(agent, provider, model, errorType).Inc()
 }
 
+// RecordRouterDecision — выбор модели маршрутизатором: tier — fast или strong.
+func RecordRouterDecision(agent, tier, classifier string) {
+	routerDecisionsTotal.WithLabelValues(agent, tier, classifier).Inc()
+}
+
+// RecordSpeculative — исход двойной отправки; согласие и выигрыш во времени
+// учитываются, только если черновик был показан (draft_first).
+func RecordSpeculative(outcome string, agreement float64, lead time.Duration) {
+	speculativeTotal.WithLabelValues(outcome).Inc()
+	if outcome == "draft_first" {
+		speculativeAgreement.Observe(agreement)
+		speculativeLead.Observe(lead.Seconds())
+	}
+}
+
+// RecordGuardFinding — находка защитного слоя: source — rag, tool, web, output;
+// action — flagged или blocked.
+func RecordGuardFinding(source, kind, action string) {
+	guardFindingsTotal.WithLabelValues(source, kind, action).Inc()
+}
+
+// RecordToolRisk — оценка вызова инструмента: level — read_only, modifying,
+// privileged, destructive; decision — executed, approved, auto_approved,
+// confirmation_required.
+func RecordToolRisk(level, decision string) {
+	toolRiskTotal.WithLabelValues(level, decision).Inc()
+}
+
+// RecordToolCorrection — исход подсказки после ошибки инструмента: outcome —
+// corrected (следующий вызов успешен) или failed (ошибка осталась).
+func RecordToolCorrection(model, outcome string) {
+	toolCorrectionsTotal.WithLabelValues(model, outcome).Inc()
+}
+
+// RecordLLMRetry — повтор запроса к провайдеру: reason — rate_limit или
+// unavailable; remaining — остаток бюджета повторов (-1 — без ограничения).
+func RecordLLMRetry(provider, reason string, remaining int) {
+	llmRetriesTotal.WithLabelValues(provider, reason).Inc()
+	llmRetryBudgetRemaining.WithLabelValues(provider).Set(float64(remaining))
+}
+
+// RecordLLMRetryBudgetExhausted — повтор не выполнен: бюджет провайдера исчерпан.
+func RecordLLMRetryBudgetExhausted(provider string) {
+	llmRetryBudgetExhaustedTotal.WithLabelValues(provider).Inc()
+	llmRetryBudgetRemaining.WithLabelValues(provider).Set(0)
+}
+
+// RecordLLMCircuit — состояние предохранителя провайдера: 0 — замкнут,
+// 1 — разомкнут, 2 — полуоткрыт; rejected — запрос отклонён без отправки.
+func RecordLLMCircuit(provider string, state int, rejected bool) {
+	llmCircuitState.WithLabelValues(provider).Set(float64(state))
+	if rejected {
+		llmCircuitRejectedTotal.WithLabelValues(provider).Inc()
+	}
+}
+
+// RecordLLMQuota — остаток лимита limit провайдера (GET /providers/{name}/quota).
+func RecordLLMQuota(provider, limit string, remaining float64) {
+	llmQuotaRemaining.WithLabelValues(provider, limit).Set(remaining)
+}
+
+// RecordModelCatalogChange — в каталоге провайдера появилось (added) или
+// пропало (removed) n моделей.
+func RecordModelCatalogChange(provider, change string, n int) {
+	modelCatalogChangesTotal.WithLabelValues(provider, change).Add(float64(n))
+}
+
+// RecordConcurrencyRejected — запрос отклонён лимитом одновременных запросов
+// scope (маршрут /chat... или имя провайдера).
+func RecordConcurrencyRejected(scope string) {
+	concurrencyRejectedTotal.WithLabelValues(scope).Inc()
+}
+
+// RecordHTTPClientRequest — исходящий запрос к destination: статус (2xx, 5xx,
+// error) и время до заголовков ответа.
+func RecordHTTPClientRequest(destination, status string, duration time.Duration) {
+	httpClientRequestDuration.WithLabelValues(destination, status).Observe(duration.Seconds())
+}
+
+// RecordHTTPClientConn — соединение исходящего запроса: новое или из пула keep-alive.
+func RecordHTTPClientConn(destination string, reused bool) {
+	httpClientConnsTotal.WithLabelValues(destination, fmt.Sprint(reused)).Inc()
+}
+
+// RecordBudgetExceeded — запрос к облачной модели упёрся в бюджет: scope — agent
+// или key; action — refused или fallback.
+func RecordBudgetExceeded(scope, action string) {
+	budgetExceededTotal.WithLabelValues(scope, action).Inc()
+}
+
+// RecordResponseCache — обращение к кэшу ответов: hit, semantic_hit, miss, bypass.
+func RecordResponseCache(result string) {
+	responseCacheTotal.WithLabelValues(result).Inc()
+}
+
+// RecordBatchItem — обработан запрос пакетного задания: status — done, failed или canceled.
+func RecordBatchItem(status string) {
+	batchItemsTotal.WithLabelValues(status).Inc()
+}
+
+// RecordJob — попытка выполнить задание очереди: status — done, retry, failed или interrupted.
+func RecordJob(kind, status string, duration time.Duration) {
+	jobsTotal.WithLabelValues(kind, status).Inc()
+	jobDuration.WithLabelValues(kind).Observe(duration.Seconds())
+}
+
+// RecordToolResultTruncated — результат инструмента усечён, полный текст сохранён артефактом.
+func RecordToolResultTruncated(tool string) {
+	toolResultsTruncatedTotal.WithLabelValues(tool).Inc()
+}
+
 func RecordRAGSearch(status string, documentsFound int, duration time.Duration) {
 	ragSearchesTotal.WithLabelValues(status, fmt.Sprintf("%d", documentsFound)).Inc()
 	ragSearchDuration.Observe(duration.Seconds())
@@ -184,3 +556,20 @@ func RecordToolCall(toolName, status string, duration time.Duration) {
 	toolCallsTotal.WithLabelValues(toolName, status).Inc()
 	toolCallDuration.WithLabelValues(toolName).Observe(duration.Seconds())
 }
+
+// RecordDBQuery — длительность запроса к БД; failed — запрос завершился ошибкой.
+func RecordDBQuery(operation, table string, duration time.Duration, failed bool) {
+	dbQueryDuration.WithLabelValues(operation, table).Observe(duration.Seconds())
+	if failed {
+		dbQueryErrors.WithLabelValues(operation, table).Inc()
+	}
+}
+
+// RegisterDBStats — экспорт состояния пула соединений (открытые, занятые,
+// ожидания) в /metrics. Вызывается один раз после подключения к БД.
+func RegisterDBStats(db *sql.DB) {
+	dbStatsCollector = collectors.NewDBStatsCollector(db, "agent")
+	if metricsRegistry != nil {
+		metricsRegistry.MustRegister(dbStatsCollector)
+	}
+}
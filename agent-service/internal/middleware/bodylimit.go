@@ -0,0 +1,43 @@
+// Package middleware — HTTP-обёртки agent-service: лимит тела запроса, gzip-сжатие ответов
+// и ограничение одновременных запросов.
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/neo-2022/openclaw-memory/agent-service/internal/apierror"
+)
+
+// BodyLimit — ограничивает размер тела запроса.
+//
+// Для путей с префиксами из uploadPrefixes (загрузка файлов в RAG, аватары)
+// действует лимит uploadBytes, для остальных — maxBytes. Запрос с заведомо
+// большим Content-Length отклоняется сразу (413), остальные тела оборачиваются
+// в http.MaxBytesReader. Лимит <= 0 отключает проверку.
+func BodyLimit(maxBytes, uploadBytes int64, uploadPrefixes []string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			limit := maxBytes
+			for _, prefix := range uploadPrefixes {
+				if strings.HasPrefix(r.URL.Path, prefix) {
+					limit = uploadBytes
+					break
+				}
+			}
+			if limit <= 0 || r.Body == nil || r.Body == http.NoBody {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if r.ContentLength > limit {
+				apierror.PayloadTooLarge(w, r.Header.Get("X-Request-ID"),
+					fmt.Sprintf("тело запроса %d байт превышает лимит %d байт", r.ContentLength, limit),
+					"Уменьшите размер загружаемого файла или разбейте его на части")
+				return
+			}
+			r.Body = http.MaxBytesReader(w, r.Body, limit)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
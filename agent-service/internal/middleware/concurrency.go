@@ -0,0 +1,52 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/neo-2022/openclaw-memory/agent-service/internal/apierror"
+	"github.com/neo-2022/openclaw-memory/agent-service/internal/limiter"
+)
+
+// SaturatedRetryAfter — через сколько клиенту повторить запрос после 429.
+const SaturatedRetryAfter = 5 * time.Second
+
+// Concurrency — ограничивает число одновременных запросов к next.
+//
+// Места занимаются в limiters по порядку (сначала лимит маршрута, потом
+// общий — ожидание в очереди маршрута не держит общее место) и освобождаются
+// после ответа. Если места нет и очередь полна или ожидание истекло —
+// 429 с Retry-After и вызов onReject (может быть nil). nil-limiter пропускается.
+func Concurrency(next http.HandlerFunc, onReject func(), limiters ...*limiter.Limiter) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		for _, l := range limiters {
+			release, err := l.Acquire(r.Context())
+			if err != nil {
+				if errors.Is(err, limiter.ErrSaturated) {
+					if onReject != nil {
+						onReject()
+					}
+					WriteSaturated(w, r.Header.Get("X-Request-ID"))
+				}
+				// Клиент отключился, пока ждал в очереди, — отвечать некому
+				return
+			}
+			defer release()
+		}
+		next(w, r)
+	}
+}
+
+// WriteSaturated — ответ 429 при исчерпании лимита одновременных запросов.
+func WriteSaturated(w http.ResponseWriter, requestID string) {
+	w.Header().Set("Retry-After", strconv.Itoa(int(SaturatedRetryAfter/time.Second)))
+	apierror.Write(w, http.StatusTooManyRequests, apierror.Response{
+		Code:      "CONCURRENCY_LIMIT",
+		Message:   "Слишком много одновременных запросов",
+		Hint:      "Повторите запрос через несколько секунд",
+		RequestID: requestID,
+		Retryable: true,
+	})
+}
@@ -0,0 +1,41 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/neo-2022/openclaw-memory/agent-service/internal/limiter"
+)
+
+// TestConcurrency — сверх лимита и очереди запрос получает 429 с Retry-After.
+func TestConcurrency(t *testing.T) {
+	release := make(chan struct{})
+	started := make(chan struct{})
+	rejected := 0
+	h := Concurrency(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+		w.WriteHeader(http.StatusOK)
+	}, func() { rejected++ }, nil, limiter.New(1, 0, time.Second))
+
+	inflight := httptest.NewRecorder()
+	done := make(chan struct{})
+	go func() {
+		h(inflight, httptest.NewRequest("POST", "/chat", nil))
+		close(done)
+	}()
+	<-started
+
+	rr := httptest.NewRecorder()
+	h(rr, httptest.NewRequest("POST", "/chat", nil))
+	if rr.Code != http.StatusTooManyRequests || rr.Header().Get("Retry-After") != "5" || rejected != 1 {
+		t.Fatalf("ожидался 429 с Retry-After, получен %d (отклонено %d)", rr.Code, rejected)
+	}
+	close(release)
+	<-done
+	if inflight.Code != http.StatusOK {
+		t.Errorf("начатый запрос: %d", inflight.Code)
+	}
+}
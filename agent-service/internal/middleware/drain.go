@@ -0,0 +1,95 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"sync/atomic"
+
+	"github.com/neo-2022/openclaw-memory/agent-service/internal/apierror"
+)
+
+// Drainer — режим «хромой утки» (lame duck) для плавной остановки сервиса.
+//
+// После StartDraining новые запросы к отслеживаемым эндпоинтам (/chat)
+// получают 503 с Retry-After, а уже начатые — включая циклы вызова
+// инструментов — дорабатывают до конца. Wait ждёт их завершения.
+type Drainer struct {
+	draining atomic.Bool
+	active   atomic.Int64
+	mu       sync.Mutex
+	idle     chan struct{} // закрывается, когда active падает до нуля во время остановки
+}
+
+// NewDrainer — создаёт Drainer в рабочем режиме.
+func NewDrainer() *Drainer {
+	return &Drainer{}
+}
+
+// Draining — идёт ли остановка сервиса.
+func (d *Drainer) Draining() bool {
+	return d.draining.Load()
+}
+
+// Active — число выполняющихся отслеживаемых запросов.
+func (d *Drainer) Active() int64 {
+	return d.active.Load()
+}
+
+// StartDraining — перестаёт принимать новые отслеживаемые запросы.
+func (d *Drainer) StartDraining() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.draining.Swap(true) {
+		return
+	}
+	d.idle = make(chan struct{})
+	if d.active.Load() == 0 {
+		close(d.idle)
+	}
+}
+
+// Wait — ждёт завершения активных запросов после StartDraining или отмены ctx.
+func (d *Drainer) Wait(ctx context.Context) error {
+	d.mu.Lock()
+	idle := d.idle
+	d.mu.Unlock()
+	if idle == nil {
+		return nil
+	}
+	select {
+	case <-idle:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (d *Drainer) done() {
+	if d.active.Add(-1) == 0 && d.draining.Load() {
+		d.mu.Lock()
+		defer d.mu.Unlock()
+		select {
+		case <-d.idle:
+		default:
+			close(d.idle)
+		}
+	}
+}
+
+// Track — учитывает запрос как активный; во время остановки отвечает 503.
+func (d *Drainer) Track(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		d.active.Add(1)
+		defer d.done()
+		if d.draining.Load() {
+			w.Header().Set("Retry-After", "5")
+			w.Header().Set("Connection", "close")
+			apierror.ServiceUnavailable(w, r.Header.Get("X-Request-ID"),
+				"Сервис перезапускается и не принимает новые запросы",
+				"Повторите запрос через несколько секунд")
+			return
+		}
+		next(w, r)
+	}
+}
@@ -0,0 +1,49 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// TestDrainer — во время остановки новые запросы получают 503,
+// а Wait дожидается завершения уже начатых.
+func TestDrainer(t *testing.T) {
+	d := NewDrainer()
+	release := make(chan struct{})
+	started := make(chan struct{})
+	h := d.Track(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+		w.WriteHeader(http.StatusOK)
+	})
+
+	inflight := httptest.NewRecorder()
+	go h(inflight, httptest.NewRequest("POST", "/chat", nil))
+	<-started
+
+	d.StartDraining()
+	rr := httptest.NewRecorder()
+	h(rr, httptest.NewRequest("POST", "/chat", nil))
+	if rr.Code != http.StatusServiceUnavailable || rr.Header().Get("Retry-After") == "" {
+		t.Fatalf("новый запрос во время остановки: ожидался 503 с Retry-After, получен %d", rr.Code)
+	}
+
+	short, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := d.Wait(short); err == nil {
+		t.Fatal("Wait не должен завершаться, пока активный чат не закончен")
+	}
+
+	close(release)
+	ctx, cancel2 := context.WithTimeout(context.Background(), time.Second)
+	defer cancel2()
+	if err := d.Wait(ctx); err != nil {
+		t.Fatalf("Wait должен дождаться завершения активного чата: %v", err)
+	}
+	if inflight.Code != http.StatusOK {
+		t.Errorf("активный чат должен завершиться успешно, статус %d", inflight.Code)
+	}
+}
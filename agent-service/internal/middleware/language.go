@@ -0,0 +1,18 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/neo-2022/openclaw-memory/agent-service/internal/i18n"
+)
+
+// Language — выбирает язык ответа (i18n.Negotiate), кладёт его в контекст
+// запроса и в заголовок Content-Language, по которому apierror переводит ошибки.
+func Language(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lang := i18n.Negotiate(r)
+		w.Header().Set("Content-Language", lang)
+		w.Header().Add("Vary", "Accept-Language")
+		next.ServeHTTP(w, r.WithContext(i18n.WithLanguage(r.Context(), lang)))
+	})
+}
@@ -0,0 +1,110 @@
+package middleware
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// TestBodyLimit — большие тела отклоняются с 413, для загрузок действует свой лимит.
+func TestBodyLimit(t *testing.T) {
+	h := BodyLimit(10, 100, []string{"/rag/"})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, err := io.ReadAll(r.Body); err != nil {
+			w.WriteHeader(http.StatusRequestEntityTooLarge)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest("POST", "/chat", strings.NewReader(strings.Repeat("x", 50))))
+	if rr.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("ожидался 413 для /chat, получен %d", rr.Code)
+	}
+	if !strings.Contains(rr.Body.String(), "PAYLOAD_TOO_LARGE") {
+		t.Errorf("ожидался код PAYLOAD_TOO_LARGE, тело: %s", rr.Body.String())
+	}
+
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest("POST", "/rag/add", strings.NewReader(strings.Repeat("x", 50))))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("ожидался 200 для /rag/add в пределах лимита загрузки, получен %d", rr.Code)
+	}
+
+	// Тело без Content-Length ограничивается при чтении
+	req := httptest.NewRequest("POST", "/chat", strings.NewReader(strings.Repeat("x", 50)))
+	req.ContentLength = -1
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	if rr.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("ожидалась ошибка чтения тела без Content-Length, получен %d", rr.Code)
+	}
+}
+
+// TestGzip — JSON сжимается при Accept-Encoding: gzip, изображения — нет.
+func TestGzip(t *testing.T) {
+	body := strings.Repeat(`{"k":"v"}`, 100)
+	h := Gzip(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/img" {
+			w.Header().Set("Content-Type", "image/png")
+		} else {
+			w.Header().Set("Content-Type", "application/json")
+		}
+		w.Write([]byte(body))
+	}))
+
+	req := httptest.NewRequest("GET", "/models", nil)
+	req.Header.Set("Accept-Encoding", "gzip, deflate")
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	if rr.Header().Get("Content-Encoding") != "gzip" {
+		t.Fatalf("ожидался Content-Encoding: gzip, заголовки: %v", rr.Header())
+	}
+	zr, err := gzip.NewReader(rr.Body)
+	if err != nil {
+		t.Fatalf("ответ не в формате gzip: %v", err)
+	}
+	got, _ := io.ReadAll(zr)
+	if string(got) != body {
+		t.Fatal("распакованное тело не совпадает с исходным")
+	}
+
+	req = httptest.NewRequest("GET", "/img", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	if rr.Header().Get("Content-Encoding") != "" {
+		t.Error("изображения не должны сжиматься")
+	}
+
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest("GET", "/models", nil))
+	if rr.Header().Get("Content-Encoding") != "" || rr.Body.String() != body {
+		t.Error("без Accept-Encoding ответ должен отдаваться как есть")
+	}
+}
+
+// TestLanguage — ошибки переводятся на язык из Accept-Language.
+func TestLanguage(t *testing.T) {
+	h := Language(BodyLimit(10, 10, nil)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})))
+
+	req := httptest.NewRequest("POST", "/chat", strings.NewReader(strings.Repeat("x", 50)))
+	req.Header.Set("Accept-Language", "en-US,en;q=0.9")
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	if rr.Header().Get("Content-Language") != "en" {
+		t.Fatalf("ожидался Content-Language: en, получен %q", rr.Header().Get("Content-Language"))
+	}
+	if !strings.Contains(rr.Body.String(), "request body of 50 bytes exceeds the 10 byte limit") {
+		t.Errorf("ожидалась ошибка на английском, тело: %s", rr.Body.String())
+	}
+
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest("POST", "/chat", strings.NewReader(strings.Repeat("x", 50))))
+	if !strings.Contains(rr.Body.String(), "превышает лимит") {
+		t.Errorf("по умолчанию ошибка на русском, тело: %s", rr.Body.String())
+	}
+}
@@ -0,0 +1,175 @@
+// Package modelrank — ранжирование моделей для роли агента (инструмент
+// list_models_for_role): статические пометки о пригодности модели для роли
+// дополняются эмпирическими данными — замером POST /models/benchmark,
+// фактической успешностью вызовов инструментов и задержкой ответов этой
+// роли и оценками пользователей 👍/👎.
+//
+// Каждая составляющая даёт долю от 0 до 1; нет данных — нейтральные 0.5, чтобы
+// незамеренная модель не оказывалась ниже модели с плохими замерами. Итог —
+// взвешенная сумма по шкале 0–100.
+package modelrank
+
+import (
+	"fmt"
+	"sort"
+
+	"gorm.io/gorm"
+
+	"github.com/neo-2022/openclaw-memory/agent-service/internal/feedback"
+	"github.com/neo-2022/openclaw-memory/agent-service/internal/models"
+)
+
+// Веса составляющих оценки (в сумме 100).
+const (
+	WeightRole     = 40 // Модель подходит для роли по статическим пометкам
+	WeightTools    = 25 // Успешность вызовов инструментов
+	WeightSpeed    = 15 // Задержка ответа или скорость генерации
+	WeightFeedback = 20 // Оценки пользователей в роли
+)
+
+// Пороги, с которых фактическим данным роли доверяют больше, чем замеру.
+const (
+	MinToolCalls = 5 // Вызовов инструментов в роли
+	MinAnswers   = 3 // Ответов в роли
+)
+
+// Опорные значения скорости: при них доля скорости равна 0.5.
+const (
+	refLatencyMs    = 10000.0 // Средняя задержка ответа в роли
+	refTokensPerSec = 20.0    // Скорость генерации по замеру
+)
+
+// Usage — фактическая работа модели в роли: ответы, их средняя задержка и
+// вызовы инструментов.
+type Usage struct {
+	ModelName    string  `json:"model"`
+	Answers      int64   `json:"answers"`
+	AvgLatencyMs float64 `json:"avg_latency_ms"`
+	ToolCalls    int64   `json:"tool_calls"`
+	ToolErrors   int64   `json:"tool_errors"`
+}
+
+// ToolSuccessRate — доля вызовов инструментов без ошибки.
+func (u Usage) ToolSuccessRate() float64 {
+	if u.ToolCalls == 0 {
+		return 0
+	}
+	return float64(u.ToolCalls-u.ToolErrors) / float64(u.ToolCalls)
+}
+
+// UsageForRole — фактическая работа моделей в роли agentName по сохранённым
+// ответам (ключ — имя модели). Ответы без замеров (сохранённые до появления
+// LatencyMs) в среднюю задержку не входят.
+func UsageForRole(db *gorm.DB, agentName string) (map[string]Usage, error) {
+	var rows []Usage
+	err := db.Model(&models.Message{}).
+		Select("messages.llm_model AS model_name, "+
+			"SUM(CASE WHEN messages.latency_ms > 0 THEN 1 ELSE 0 END) AS answers, "+
+			"COALESCE(AVG(CASE WHEN messages.latency_ms > 0 THEN messages.latency_ms END), 0) AS avg_latency_ms, "+
+			"COALESCE(SUM(messages.tool_calls), 0) AS tool_calls, "+
+			"COALESCE(SUM(messages.tool_errors), 0) AS tool_errors").
+		Joins("JOIN agents ON agents.id = messages.agent_id").
+		Where("agents.name = ? AND messages.role = ? AND messages.llm_model <> ''", agentName, "assistant").
+		Group("messages.llm_model").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	out := make(map[string]Usage, len(rows))
+	for _, u := range rows {
+		out[u.ModelName] = u
+	}
+	return out, nil
+}
+
+// Candidate — модель и известные о ней данные; nil — данных нет.
+type Candidate struct {
+	Name      string
+	Suitable  bool // Роль в SuitableRoles модели
+	Benchmark *models.ModelBenchmark
+	Usage     *Usage
+	Feedback  *feedback.Stat
+}
+
+// Ranked — оценка модели и данные, на которых она основана.
+type Ranked struct {
+	Name            string   `json:"name"`
+	Rank            int      `json:"rank"`
+	Score           float64  `json:"score"` // 0–100
+	ToolSuccessRate *float64 `json:"tool_success_rate,omitempty"`
+	ToolSource      string   `json:"tool_source,omitempty"` // usage — ответы роли, benchmark — замер
+	AvgLatencyMs    *float64 `json:"avg_latency_ms,omitempty"`
+	TokensPerSec    *float64 `json:"tokens_per_sec,omitempty"`
+	Rating          *float64 `json:"rating,omitempty"` // Оценки пользователей: (👍 − 👎) / всего, от −1 до 1
+	Votes           int64    `json:"votes,omitempty"`
+	Reasons         []string `json:"reasons,omitempty"`
+}
+
+// Score — оценка модели для роли.
+func Score(c Candidate) Ranked {
+	r := Ranked{Name: c.Name}
+	role := 0.0
+	if c.Suitable {
+		role = 1
+	} else {
+		r.Reasons = append(r.Reasons, "не отмечена как подходящая для роли")
+	}
+
+	tools := 0.5
+	switch {
+	case c.Usage != nil && c.Usage.ToolCalls >= MinToolCalls:
+		rate := c.Usage.ToolSuccessRate()
+		tools, r.ToolSuccessRate, r.ToolSource = rate, &rate, "usage"
+		r.Reasons = append(r.Reasons, fmt.Sprintf("инструменты в роли: %d из %d без ошибок", c.Usage.ToolCalls-c.Usage.ToolErrors, c.Usage.ToolCalls))
+	case c.Benchmark != nil && c.Benchmark.ToolCalls > 0:
+		rate := c.Benchmark.ToolSuccessRate
+		tools, r.ToolSuccessRate, r.ToolSource = rate, &rate, "benchmark"
+		r.Reasons = append(r.Reasons, fmt.Sprintf("инструменты по замеру: %d из %d", c.Benchmark.ToolCallsOK, c.Benchmark.ToolCalls))
+	}
+
+	speed := 0.5
+	if c.Benchmark != nil && c.Benchmark.TokensPerSec > 0 {
+		tps := c.Benchmark.TokensPerSec
+		r.TokensPerSec = &tps
+		speed = tps / (tps + refTokensPerSec)
+	}
+	if c.Usage != nil && c.Usage.Answers >= MinAnswers && c.Usage.AvgLatencyMs > 0 {
+		lat := c.Usage.AvgLatencyMs
+		r.AvgLatencyMs = &lat
+		speed = refLatencyMs / (refLatencyMs + lat)
+		r.Reasons = append(r.Reasons, fmt.Sprintf("средний ответ в роли: %.1f с", lat/1000))
+	}
+
+	votes := 0.5
+	if c.Feedback != nil && c.Feedback.Total > 0 {
+		// Мало оценок — доля тянется к нейтральной: одна 👎 не топит модель
+		n := float64(c.Feedback.Total)
+		trust := n / (n + feedback.FlagMinVotes)
+		rating := c.Feedback.Score
+		r.Rating, r.Votes = &rating, c.Feedback.Total
+		votes = 0.5 + trust*rating/2
+		r.Reasons = append(r.Reasons, fmt.Sprintf("оценки пользователей: 👍 %d, 👎 %d", c.Feedback.Up, c.Feedback.Down))
+	}
+
+	total := WeightRole*role + WeightTools*tools + WeightSpeed*speed + WeightFeedback*votes
+	r.Score = float64(int64(total*10+0.5)) / 10
+	return r
+}
+
+// Rank — оценки кандидатов по убыванию; равные — по имени. Rank — место с 1.
+func Rank(candidates []Candidate) []Ranked {
+	out := make([]Ranked, len(candidates))
+	for i, c := range candidates {
+		out[i] = Score(c)
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		if out[i].Score != out[j].Score {
+			return out[i].Score > out[j].Score
+		}
+		return out[i].Name < out[j].Name
+	})
+	for i := range out {
+		out[i].Rank = i + 1
+	}
+	return out
+}
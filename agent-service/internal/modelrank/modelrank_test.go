@@ -0,0 +1,51 @@
+package modelrank
+
+import (
+	"testing"
+
+	"github.com/neo-2022/openclaw-memory/agent-service/internal/feedback"
+	"github.com/neo-2022/openclaw-memory/agent-service/internal/models"
+)
+
+func TestScore(t *testing.T) {
+	// Без данных — только пригодность для роли и нейтральные доли
+	if r := Score(Candidate{Name: "a", Suitable: true}); r.Score != 70 || r.ToolSuccessRate != nil {
+		t.Errorf("без данных: %+v", r)
+	}
+	if r := Score(Candidate{Name: "a"}); r.Score != 30 || len(r.Reasons) != 1 {
+		t.Errorf("неподходящая без данных: %+v", r)
+	}
+
+	// Фактические вызовы роли важнее замера, если их достаточно
+	bench := &models.ModelBenchmark{ToolCalls: 4, ToolCallsOK: 4, ToolSuccessRate: 1, TokensPerSec: 20}
+	r := Score(Candidate{Name: "a", Suitable: true, Benchmark: bench, Usage: &Usage{ToolCalls: 10, ToolErrors: 5, Answers: 1}})
+	if r.ToolSource != "usage" || *r.ToolSuccessRate != 0.5 || r.AvgLatencyMs != nil || *r.TokensPerSec != 20 {
+		t.Errorf("инструменты по ответам роли: %+v", r)
+	}
+	r = Score(Candidate{Name: "a", Suitable: true, Benchmark: bench, Usage: &Usage{ToolCalls: 2, ToolErrors: 2}})
+	if r.ToolSource != "benchmark" || *r.ToolSuccessRate != 1 {
+		t.Errorf("мало вызовов в роли — по замеру: %+v", r)
+	}
+
+	// Одна 👎 влияет слабее пяти
+	one := Score(Candidate{Name: "a", Feedback: &feedback.Stat{Down: 1, Total: 1, Score: -1}})
+	many := Score(Candidate{Name: "a", Feedback: &feedback.Stat{Down: 5, Total: 5, Score: -1}})
+	if !(one.Score < 30 && many.Score < one.Score) || many.Votes != 5 {
+		t.Errorf("оценки: одна %.1f, пять %.1f", one.Score, many.Score)
+	}
+}
+
+func TestRank(t *testing.T) {
+	ranked := Rank([]Candidate{
+		{Name: "slow", Suitable: true, Usage: &Usage{Answers: 10, AvgLatencyMs: 60000}},
+		{Name: "unsuitable"},
+		{Name: "fast", Suitable: true, Usage: &Usage{Answers: 10, AvgLatencyMs: 2000, ToolCalls: 20}},
+		{Name: "bare", Suitable: true},
+	})
+	want := []string{"fast", "bare", "slow", "unsuitable"}
+	for i, r := range ranked {
+		if r.Name != want[i] || r.Rank != i+1 {
+			t.Fatalf("порядок: %+v", ranked)
+		}
+	}
+}
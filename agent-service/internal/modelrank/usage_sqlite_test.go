@@ -0,0 +1,45 @@
+//go:build cgo
+
+package modelrank
+
+import (
+	"path/filepath"
+	"testing"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+
+	"github.com/neo-2022/openclaw-memory/agent-service/internal/models"
+)
+
+// TestUsageForRole — ответы роли сводятся по модели; ответы других агентов
+// и сообщения пользователя не учитываются.
+func TestUsageForRole(t *testing.T) {
+	gdb, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "usage.db")), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("открытие SQLite: %v", err)
+	}
+	if err := gdb.AutoMigrate(&models.Chat{}, &models.Agent{}, &models.Message{}); err != nil {
+		t.Fatalf("миграция: %v", err)
+	}
+	admin, coder := models.Agent{Name: "admin"}, models.Agent{Name: "coder"}
+	gdb.Create(&admin)
+	gdb.Create(&coder)
+	gdb.Create(&[]models.Message{
+		{Role: "user", AgentID: admin.ID, Content: "вопрос"},
+		{Role: "assistant", AgentID: admin.ID, LLMModel: "qwen", LatencyMs: 1000, ToolCalls: 3, ToolErrors: 1},
+		{Role: "assistant", AgentID: admin.ID, LLMModel: "qwen", LatencyMs: 3000, ToolCalls: 2},
+		{Role: "assistant", AgentID: admin.ID, LLMModel: "qwen"}, // Сохранён до замеров
+		{Role: "assistant", AgentID: coder.ID, LLMModel: "qwen", LatencyMs: 90000, ToolCalls: 9, ToolErrors: 9},
+	})
+
+	usage, err := UsageForRole(gdb, "admin")
+	if err != nil {
+		t.Fatalf("UsageForRole: %v", err)
+	}
+	u := usage["qwen"]
+	if len(usage) != 1 || u.Answers != 2 || u.AvgLatencyMs != 2000 || u.ToolCalls != 5 || u.ToolErrors != 1 || u.ToolSuccessRate() != 0.8 {
+		t.Errorf("статистика роли: %+v", usage)
+	}
+}
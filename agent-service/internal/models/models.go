@@ -6,6 +6,7 @@
 //
 //	Workspace → Chat → Message
 //	Workspace → Agent
+//	Workspace → WorkspaceSymbol
 //	Agent → Message
 //	Agent → ProviderConfig (через поле Provider)
 //	ModelToolSupport — независимая таблица-кэш
@@ -15,6 +16,8 @@ package models
 import (
 	"time"
 
+	"github.com/google/uuid"
+
 	"gorm.io/gorm"
 )
 
@@ -33,6 +36,8 @@ import (
 //   - Avatar: имя файла аватара агента в директории uploads/avatars/.
 //   - CurrentPromptFile: имя файла, из которого загружен текущий промпт.
 //     Пустая строка, если промпт введён вручную.
+//   - LearningsEnabled: подставлять ли накопленные знания модели в промпт агента
+//     и извлекать ли новые из его диалогов.
 //   - Messages: связь один-ко-многим с сообщениями агента.
 //   - WorkspaceID: внешний ключ на рабочее пространство (может быть NULL).
 type Agent struct {
@@ -43,7 +48,11 @@ type Agent struct {
 	Provider          string    `json:"provider" gorm:"default:ollama"` // Провайдер (ollama, openai и др.)
 	SupportsTools     bool      // Поддержка tool calling
 	Avatar            string    // Имя файла аватара
-	CurrentPromptFile string    `json:"prompt_file"` // Файл промпта (если загружен из файла)
+	CurrentPromptFile string    `json:"prompt_file"`                           // Файл промпта (если загружен из файла)
+	LearningsEnabled  bool      `json:"learnings_enabled" gorm:"default:true"` // Накопленные знания модели
+	ToolsEnabled      bool      `json:"tools_enabled" gorm:"default:true"`     // Инструменты включены (команда /tools on|off)
+	DisabledIntents   string    `json:"disabled_intents"`                      // Отключённые интенты через запятую
+	Persona           string    `json:"persona"`                               // Активный образ (Persona); пусто — собственные настройки агента
 	Messages          []Message // Сообщения агента
 	WorkspaceID       *uint     `json:"workspace_id"` // Привязка к рабочему пространству
 }
@@ -58,6 +67,9 @@ type Agent struct {
 //   - ToolCallID: идентификатор вызова инструмента (для tool-сообщений).
 //   - AgentID: внешний ключ на агента, которому принадлежит сообщение.
 //   - ChatID: внешний ключ на чат (UUID).
+//   - LLMModel, Provider: модель и провайдер, сгенерировавшие ответ (для assistant-сообщений).
+//   - LatencyMs, ToolCalls, ToolErrors: фактическая работа модели над ответом —
+//     по ним list_models_for_role ранжирует модели роли (пакет modelrank).
 type Message struct {
 	gorm.Model
 	Role       string  // Роль: user, assistant, system, tool
@@ -66,21 +78,34 @@ type Message struct {
 	AgentID    uint    // Внешний ключ на Agent
 	Agent      Agent   // Связь с агентом
 	ChatID     *string // Внешний ключ на Chat.ID (UUID), может быть NULL
+	LLMModel   string  // Модель, сгенерировавшая ответ
+	Provider   string  // Провайдер модели
+	RouteTier  string  // Уровень модели при маршрутизации: fast, strong (пусто — без маршрутизации)
+	Persona    string  // Образ агента, которым дан ответ (пусто — без образа)
+	LatencyMs  int64   // Время ответа от запроса до итогового текста, мс (для assistant)
+	ToolCalls  int     // Вызовов инструментов за ответ
+	ToolErrors int     // Из них завершились ошибкой
+	// AlternativeOf — первый ответ, вариантом которого является сообщение (POST /chat/regenerate)
+	AlternativeOf *uint `gorm:"index"`
 }
 
 // Chat — модель чата (сессии) пользователя.
 // Каждый чат имеет уникальный UUID, может быть привязан к рабочему пространству.
-// UUID генерируется автоматически на уровне PostgreSQL через gen_random_uuid().
+// UUID генерируется в BeforeCreate, поэтому модель одинаково работает в PostgreSQL и SQLite.
 //
 // Поля:
-//   - ID: уникальный идентификатор чата (UUID, генерируется PostgreSQL).
+//   - ID: уникальный идентификатор чата (UUID).
 //   - Name: отображаемое имя чата.
 //   - UserID: идентификатор пользователя (для будущей многопользовательности).
 //   - WorkspaceID: привязка к рабочему пространству (может быть NULL).
+//   - AgentName: агент, с которым идёт диалог.
+//   - Title: название, придуманное моделью после первого обмена репликами (GET /conversations).
 //   - Messages: связь один-ко-многим с сообщениями чата.
 type Chat struct {
-	ID          string         `gorm:"primaryKey;type:uuid;default:gen_random_uuid()"` // UUID чата
+	ID          string         `gorm:"primaryKey;type:uuid"` // UUID чата
 	Name        string         // Имя чата
+	AgentName   string         `gorm:"index"` // Агент диалога
+	Title       string         // Автоматическое название (пусто — ещё не сгенерировано)
 	UserID      string         // ID пользователя (для многопользовательности)
 	WorkspaceID *uint          // Привязка к рабочему пространству
 	CreatedAt   time.Time      // Время создания
@@ -89,6 +114,14 @@ type Chat struct {
 	Messages    []Message      // Сообщения чата
 }
 
+// BeforeCreate — присваивает чату UUID, если он не задан.
+func (c *Chat) BeforeCreate(tx *gorm.DB) error {
+	if c.ID == "" {
+		c.ID = uuid.NewString()
+	}
+	return nil
+}
+
 // PromptFile — модель файла с системным промптом.
 // Хранит содержимое файла промпта, привязанного к определённому агенту.
 // Файлы промптов загружаются из директории prompts/{agent_name}/.
@@ -117,6 +150,9 @@ type PromptFile struct {
 //   - SuitableRoles: JSON-массив подходящих ролей агентов (["admin"]).
 //   - RoleNotes: JSON-объект с пояснениями для каждой роли.
 //   - CheckedAt: время последней проверки.
+//   - ToolCallFormat, SupportsJSON, ContextLength, UsableContext, ProbedAt:
+//     результаты эмпирической проверки (repository.ProbeModel); ProbedAt = nil —
+//     модель ещё не проверялась.
 type ModelToolSupport struct {
 	ModelName     string    `gorm:"primaryKey"` // Имя модели (первичный ключ)
 	SupportsTools bool      // Поддерживает ли модель tool calling
@@ -126,6 +162,12 @@ type ModelToolSupport struct {
 	SuitableRoles string    `gorm:"type:text"` // JSON-массив подходящих ролей
 	RoleNotes     string    `gorm:"type:text"` // JSON-объект с пояснениями
 	CheckedAt     time.Time // Время проверки
+
+	ToolCallFormat string     // native — структурные tool_calls, text — вызов текстом в ответе, none
+	SupportsJSON   bool       // Возвращает валидный JSON в режиме format=json
+	ContextLength  int        // Контекст по метаданным модели (токенов)
+	UsableContext  int        // Наибольший контекст, в котором модель нашла метку
+	ProbedAt       *time.Time // Время эмпирической проверки
 }
 
 // ProviderConfig — модель настроек облачного LLM-провайдера.
@@ -168,6 +210,27 @@ type SystemLog struct {
 	Message  string `gorm:"type:text;not null"` // Текст сообщения
 	Details  string `gorm:"type:text"`          // Доп. данные (стек, параметры)
 	Resolved bool   `gorm:"default:false"`      // Исправлена ли ошибка
+	// CorrelationID — X-Request-ID запроса, в рамках которого произошло событие
+	CorrelationID string `gorm:"index"`
+}
+
+// MessageFeedback — оценка ответа агента пользователем (👍/👎).
+// Агент, модель и провайдер копируются из сообщения, чтобы статистика
+// не зависела от последующей смены модели агента.
+//
+// Поля:
+//   - MessageID: оцениваемое сообщение ассистента.
+//   - AgentName, ModelName, Provider: кто сгенерировал ответ.
+//   - Rating: +1 — полезный ответ, -1 — неудачный.
+//   - Comment: пояснение пользователя (необязательно).
+type MessageFeedback struct {
+	gorm.Model
+	MessageID uint   `gorm:"index;not null"` // Оцениваемое сообщение
+	AgentName string `gorm:"index"`          // Агент (роль)
+	ModelName string `gorm:"index"`          // Модель
+	Provider  string // Провайдер
+	Rating    int    `gorm:"not null"`  // +1 или -1
+	Comment   string `gorm:"type:text"` // Комментарий пользователя
 }
 
 // Workspace — модель рабочего пространства (проекта).
@@ -181,10 +244,131 @@ type SystemLog struct {
 //   - Agents: связь один-ко-многим с агентами, привязанными к пространству.
 type Workspace struct {
 	gorm.Model
-	Name   string  `gorm:"not null"` // Имя пространства
-	Path   string  // Путь к рабочей директории
-	Chats  []Chat  // Чаты пространства
-	Agents []Agent // Агенты пространства
+	Name        string     `gorm:"not null"` // Имя пространства
+	Path        string     // Путь к рабочей директории
+	IndexedAt   *time.Time `json:"indexed_at"`   // Время последнего построения индекса символов
+	FileCount   int        `json:"file_count"`   // Файлов в индексе символов
+	SymbolCount int        `json:"symbol_count"` // Символов в индексе
+	Chats       []Chat     // Чаты пространства
+	Agents      []Agent    // Агенты пространства
+}
+
+// WorkspaceSymbol — символ исходного кода рабочего пространства (функция,
+// метод, тип, класс). Индекс перестраивается целиком при переиндексации,
+// поэтому мягкое удаление не используется.
+//
+// Поля:
+//   - WorkspaceID: пространство, к которому относится символ.
+//   - File: путь к файлу относительно Workspace.Path.
+//   - Name, Kind, Parent: имя, вид (func, method, type, interface, class, const, var)
+//     и класс или тип-получатель для методов.
+//   - Line, Signature: строка объявления и однострочная сигнатура.
+type WorkspaceSymbol struct {
+	ID          uint   `gorm:"primaryKey" json:"-"`
+	WorkspaceID uint   `gorm:"index;not null" json:"-"`
+	File        string `gorm:"index" json:"file"`
+	Name        string `gorm:"index" json:"name"`
+	Kind        string `json:"kind"`
+	Parent      string `json:"parent,omitempty"`
+	Line        int    `json:"line"`
+	Signature   string `gorm:"type:text" json:"signature"`
+	Exported    bool   `json:"exported"`
+}
+
+// AgentTask — задача агента, импортированная из трекера (GitHub, GitLab, Jira).
+// Пока задача активна (planned или in_progress), её описание и план
+// подставляются в системный промпт агента; коммиты скила git_pull_request
+// ссылаются на задачу и записываются в Commits.
+//
+// Поля:
+//   - Agent: агент-исполнитель; WorkspaceID — пространство с репозиторием.
+//   - Tracker, IssueKey, IssueURL: трекер, обозначение (owner/repo#42, CORE-1) и ссылка.
+//   - Context: текст задачи с комментариями; Summary и Plan (шаги по строкам) — от модели.
+//   - Status: planned, in_progress, review, done, cancelled.
+//   - Commits: «sha ветка pr_url» по строке на каждый коммит.
+type AgentTask struct {
+	gorm.Model
+	Agent       string `gorm:"index;not null" json:"agent"`
+	WorkspaceID *uint  `json:"workspace_id,omitempty"`
+	Title       string `json:"title"`
+	Tracker     string `json:"tracker"`
+	IssueKey    string `json:"issue_key"`
+	IssueURL    string `gorm:"index" json:"issue_url"`
+	Context     string `gorm:"type:text" json:"context"`
+	Summary     string `gorm:"type:text" json:"summary"`
+	Plan        string `gorm:"type:text" json:"plan"`
+	Status      string `gorm:"index;default:planned" json:"status"`
+	Commits     string `gorm:"type:text" json:"commits"`
+}
+
+// Статусы AgentTask.
+const (
+	TaskPlanned    = "planned"
+	TaskInProgress = "in_progress"
+	TaskReview     = "review"
+	TaskDone       = "done"
+	TaskCancelled  = "cancelled"
+)
+
+// ModelBenchmark — результат прогона POST /models/benchmark.
+// История хранится для сравнения моделей в выборе модели.
+//
+// Поля:
+//   - TokensPerSec: скорость генерации; FirstTokenMs — задержка до первого токена
+//     (у Ollama — загрузка + обработка промпта, у облачных — полное время ответа).
+//   - ToolCalls/ToolCallsOK: число тестов вызова инструментов и успешных из них.
+//   - ContextTokens: наибольший контекст (≈ токенов), в котором модель нашла метку.
+//   - Details: JSON с результатами каждого теста.
+type ModelBenchmark struct {
+	gorm.Model
+	Provider        string  `gorm:"index" json:"provider"`
+	ModelName       string  `gorm:"index;not null" json:"model"`
+	TokensPerSec    float64 `json:"tokens_per_sec"`
+	FirstTokenMs    float64 `json:"first_token_ms"`
+	ToolCalls       int     `json:"tool_calls"`
+	ToolCallsOK     int     `json:"tool_calls_ok"`
+	ToolSuccessRate float64 `json:"tool_success_rate"`
+	ContextTokens   int     `json:"context_tokens"`
+	DurationMs      int64   `json:"duration_ms"`
+	Details         string  `gorm:"type:text" json:"details"`
+}
+
+// UsageRecord — расход токенов одним запросом к LLM (для бюджетов и GET /usage).
+//
+// Поля:
+//   - KeyID: отпечаток API-ключа клиента (Authorization: Bearer или X-API-Key), пусто — без ключа.
+//   - Cloud: запрос к облачному провайдеру (бюджеты ограничивают только облачные вызовы).
+//   - Estimated: провайдер не сообщил токены, число оценено по длине текста.
+//   - Cost: стоимость по ценам BUDGET_PRICES (0 — цена модели не задана).
+type UsageRecord struct {
+	ID               uint      `gorm:"primarykey" json:"id"`
+	CreatedAt        time.Time `gorm:"index" json:"created_at"`
+	AgentName        string    `gorm:"index" json:"agent"`
+	KeyID            string    `gorm:"index" json:"key_id,omitempty"`
+	Provider         string    `json:"provider"`
+	ModelName        string    `json:"model"`
+	Cloud            bool      `json:"cloud"`
+	PromptTokens     int       `json:"prompt_tokens"`
+	CompletionTokens int       `json:"completion_tokens"`
+	Cost             float64   `json:"cost"`
+	Estimated        bool      `json:"estimated"`
+}
+
+// Budget — лимит расхода облачных моделей на агента или API-ключ за день или месяц.
+//
+// Поля:
+//   - Scope/Subject: "agent" и имя агента или "key" и отпечаток ключа.
+//   - Period: "daily" или "monthly" (календарные сутки и месяц по времени сервера).
+//   - TokenLimit, CostLimit: лимиты токенов и стоимости; 0 — не ограничено.
+//   - Action: "refuse" — отказ с объяснением, "fallback" — ответ локальной моделью.
+type Budget struct {
+	gorm.Model
+	Scope      string  `gorm:"uniqueIndex:idx_budget_subject;not null" json:"scope"`
+	Subject    string  `gorm:"uniqueIndex:idx_budget_subject;not null" json:"subject"`
+	Period     string  `gorm:"uniqueIndex:idx_budget_subject;not null" json:"period"`
+	TokenLimit int64   `json:"token_limit"`
+	CostLimit  float64 `json:"cost_limit"`
+	Action     string  `json:"action"`
 }
 
 // RagDocument — документ в базе знаний RAG.
@@ -196,6 +380,7 @@ type Workspace struct {
 //   - Source: источник документа (user-upload, file, web и т.д.)
 //   - ChunkIndex: индекс чанка (если документ разбит на части)
 //   - TotalChunks: общее количество чанков документа
+//   - Version: номер текущей версии; прежние хранятся в RagDocumentVersion
 type RagDocument struct {
 	gorm.Model
 	Title       string `gorm:"not null"`  // Название документа
@@ -204,4 +389,218 @@ type RagDocument struct {
 	ChunkIndex  int    // Индекс чанка
 	TotalChunks int    // Всего чанков
 	WorkspaceID *uint  // Привязка к рабочему пространству
+	Version     int    `gorm:"not null;default:1"` // Текущая версия
+}
+
+// RagDocumentVersion — прежняя версия документа RAG. При повторной загрузке
+// того же названия и источника с другим содержимым текущее содержимое
+// переносится сюда, а строка RagDocument получает новое и следующий номер.
+// В поиск (ChromaDB) попадает только текущая версия.
+//
+// Поля:
+//   - DocumentID, Version: строка RagDocument и номер версии (уникальная пара).
+//   - CreatedAt: когда версия была загружена, а не когда её заменили.
+type RagDocumentVersion struct {
+	ID         uint      `gorm:"primarykey" json:"id"`
+	DocumentID uint      `gorm:"uniqueIndex:idx_rag_document_version;not null" json:"document_id"`
+	Version    int       `gorm:"uniqueIndex:idx_rag_document_version;not null" json:"version"`
+	Content    string    `gorm:"type:text" json:"-"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// Artifact — файл, созданный инструментом агента: скриншот, PDF, отчёт, скрипт.
+// Копия хранится в ARTIFACTS_DIR под именем StorageKey; наружу файл отдаётся
+// по ID через /artifacts/{id}/download, без путей файловой системы.
+//
+// Поля:
+//   - ChatID: диалог /conversations, в котором создан файл (NULL — вне диалога).
+//   - Tool: инструмент, создавший файл (browser_screenshot, write, run_code…).
+//   - Name: имя файла для пользователя.
+//   - Kind: image, pdf, text или file — для отображения в интерфейсе.
+type Artifact struct {
+	ID         uint           `gorm:"primarykey" json:"id"`
+	CreatedAt  time.Time      `gorm:"index" json:"created_at"`
+	DeletedAt  gorm.DeletedAt `gorm:"index" json:"-"`
+	ChatID     *string        `gorm:"index" json:"chat_id,omitempty"`
+	AgentName  string         `gorm:"index" json:"agent"`
+	Tool       string         `json:"tool"`
+	Name       string         `json:"name"`
+	MimeType   string         `json:"mime_type"`
+	Kind       string         `json:"kind"`
+	Size       int64          `json:"size"`
+	SHA256     string         `json:"sha256"`
+	StorageKey string         `json:"-"`
+}
+
+// FileChange — изменение файла инструментом edit_file или write: unified diff
+// до и после. Привязано к ответу ассистента, в ходе которого сделано.
+//
+// Поля:
+//   - MessageID: ответ ассистента (0 — ответ не сохранён).
+//   - Path, Workspace: как в FileBackup.
+//   - Created: файла не было, diff показывает его целиком.
+type FileChange struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	MessageID uint      `gorm:"index" json:"message_id,omitempty"`
+	ChatID    *string   `gorm:"index" json:"chat_id,omitempty"`
+	AgentName string    `gorm:"index" json:"agent"`
+	Tool      string    `json:"tool"`
+	Path      string    `json:"path"`
+	Workspace string    `json:"workspace,omitempty"`
+	Diff      string    `gorm:"type:text" json:"diff"`
+	Added     int       `json:"added"`
+	Removed   int       `json:"removed"`
+	Created   bool      `json:"created,omitempty"`
+}
+
+// FileBackup — содержимое файла перед изменением инструментом write, edit_file
+// или delete. POST /rollback возвращает файл в это состояние.
+//
+// Поля:
+//   - RequestID: запрос /chat (X-Request-ID), в ходе которого изменён файл.
+//   - MessageID: ответ ассистента этого запроса (0 — ответ не сохранён).
+//   - Path: абсолютный путь, если файл задан относительно рабочего пространства.
+//   - Workspace: каталог рабочего пространства запроса; откат выполняется в нём же.
+//   - Existed: файл существовал; иначе откат удаляет созданный файл.
+//   - RestoredAt: когда файл восстановлен из этой копии.
+type FileBackup struct {
+	ID         uint       `gorm:"primarykey" json:"id"`
+	CreatedAt  time.Time  `gorm:"index" json:"created_at"`
+	RequestID  string     `gorm:"index" json:"request_id,omitempty"`
+	MessageID  uint       `gorm:"index" json:"message_id,omitempty"`
+	ChatID     *string    `gorm:"index" json:"chat_id,omitempty"`
+	AgentName  string     `gorm:"index" json:"agent"`
+	Tool       string     `json:"tool"`
+	Path       string     `json:"path"`
+	Workspace  string     `json:"workspace,omitempty"`
+	Existed    bool       `json:"existed"`
+	Content    string     `gorm:"type:text" json:"-"`
+	Size       int        `json:"size"`
+	RestoredAt *time.Time `json:"restored_at,omitempty"`
+}
+
+// ChatRecording — запись запроса /chat для воспроизведения: тело запроса,
+// ответы модели и результаты инструментов (POST /chat/replay).
+//
+// Поля:
+//   - Request: тело запроса /chat (JSON).
+//   - Tape: ответы модели и вызовы инструментов по порядку (JSON, см. пакет replay).
+//   - Response: итоговый ответ агента.
+type ChatRecording struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time `gorm:"index" json:"created_at"`
+	RequestID string    `gorm:"index" json:"request_id,omitempty"`
+	AgentName string    `gorm:"index" json:"agent"`
+	Provider  string    `json:"provider"`
+	Model     string    `json:"model"`
+	Request   string    `gorm:"type:text" json:"-"`
+	Tape      string    `gorm:"type:text" json:"-"`
+	Response  string    `gorm:"type:text" json:"response"`
+	LLMCalls  int       `json:"llm_calls"`
+	ToolCalls int       `json:"tool_calls"`
+}
+
+// Job — задание очереди фоновых задач (пакет jobqueue): загрузка папки в
+// RAG, извлечение знаний из диалога, запуск агента по расписанию.
+//
+// Поля:
+//   - Kind: тип задания, по нему выбирается обработчик.
+//   - Payload: параметры задания (JSON), Result — итог обработчика (JSON).
+//   - RunAt: не раньше какого времени выполнять (расписание и пауза перед повтором).
+//   - LockedBy, LockedUntil: обработчик, взявший задание, и срок аренды;
+//     после истечения аренды задание забирает другой обработчик.
+type Job struct {
+	ID          uint       `gorm:"primarykey" json:"id"`
+	CreatedAt   time.Time  `gorm:"index" json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+	Kind        string     `gorm:"index" json:"kind"`
+	Status      string     `gorm:"index" json:"status"` // pending, running, done, failed, canceled
+	Payload     string     `gorm:"type:text" json:"payload"`
+	Result      string     `gorm:"type:text" json:"result,omitempty"`
+	Error       string     `gorm:"type:text" json:"error,omitempty"`
+	Attempts    int        `json:"attempts"`
+	MaxAttempts int        `json:"max_attempts"`
+	RunAt       time.Time  `gorm:"index" json:"run_at"`
+	LockedBy    string     `json:"locked_by,omitempty"`
+	LockedUntil *time.Time `json:"locked_until,omitempty"`
+	StartedAt   *time.Time `json:"started_at,omitempty"`
+	FinishedAt  *time.Time `json:"finished_at,omitempty"`
+}
+
+// EnvVar — переменная окружения инструментов execute и run_code (пакет
+// envvars), привязанная к рабочему пространству или диалогу — ровно к одному
+// из них.
+//
+// Поля:
+//   - Key: имя переменной (GITHUB_TOKEN), уникально в пределах пространства или диалога.
+//   - Value: значение; через API не отдаётся, если Secret.
+//   - Secret: значение не показывается модели, в логах и ответах API и
+//     вырезается из вывода инструментов.
+type EnvVar struct {
+	ID          uint      `gorm:"primarykey" json:"id"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+	WorkspaceID *uint     `gorm:"index" json:"workspace_id,omitempty"`
+	ChatID      *string   `gorm:"index" json:"chat_id,omitempty"`
+	Key         string    `gorm:"not null" json:"key"`
+	Value       string    `gorm:"type:text" json:"-"`
+	Secret      bool      `json:"secret"`
+}
+
+// CompoundSkill — версия составного скила (рецепта, пакет recipe),
+// импортированного через /skills/compound. Повторный импорт того же имени
+// создаёт следующую версию; у имени активна ровно одна версия.
+//
+// Поля:
+//   - Name: имя рецепта (инструмента модели).
+//   - Version: номер версии в пределах имени (с 1).
+//   - Source: откуда импортирован: URL или upload.
+//   - SHA256: хэш текста рецепта — повторный импорт того же текста не создаёт версию.
+//   - Spec: текст рецепта (YAML).
+//   - Active: версия, доступная агентам.
+type CompoundSkill struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	Name      string    `gorm:"not null;uniqueIndex:idx_compound_skill_version" json:"name"`
+	Version   int       `gorm:"not null;uniqueIndex:idx_compound_skill_version" json:"version"`
+	Source    string    `json:"source"`
+	SHA256    string    `gorm:"column:sha256" json:"sha256"`
+	Spec      string    `gorm:"type:text" json:"-"`
+	Active    bool      `gorm:"index" json:"active"`
+}
+
+// CompoundSkillAgent — включение или отключение составного скила для агента.
+// Переключатель важнее списка agents рецепта; нет записи — действует рецепт.
+type CompoundSkillAgent struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	UpdatedAt time.Time `json:"updated_at"`
+	Name      string    `gorm:"not null;uniqueIndex:idx_compound_skill_agent" json:"name"`
+	Agent     string    `gorm:"not null;uniqueIndex:idx_compound_skill_agent" json:"agent"`
+	Enabled   bool      `json:"enabled"`
+}
+
+// Persona — сохранённый образ агента (пакет persona): системный промпт,
+// температура, политика инструментов и аватар. PUT /agents/{name}/persona
+// переключает агента между образами, активный хранится в Agent.Persona.
+//
+// Поля:
+//   - Prompt: системный промпт образа; пусто — промпт агента.
+//   - PromptFile: файл prompts/{agent}/, из которого взят Prompt; при
+//     переключении на образ файл перечитывается.
+//   - Temperature: температура генерации (nil — значение провайдера).
+//   - ToolPolicy: all, none, allow или deny; Tools — инструменты через запятую для allow и deny.
+//   - Avatar: аватар образа в uploads/avatars/ (пусто — аватар агента).
+type Persona struct {
+	ID          uint      `gorm:"primarykey" json:"id"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+	Agent       string    `gorm:"not null;uniqueIndex:idx_persona_agent_name" json:"agent"`
+	Name        string    `gorm:"not null;uniqueIndex:idx_persona_agent_name" json:"name"`
+	Prompt      string    `gorm:"type:text" json:"prompt"`
+	PromptFile  string    `json:"prompt_file,omitempty"`
+	Temperature *float64  `json:"temperature,omitempty"`
+	ToolPolicy  string    `json:"tool_policy"`
+	Tools       string    `json:"-"`
+	Avatar      string    `json:"avatar,omitempty"`
 }
@@ -0,0 +1,192 @@
+// Package modelwatch — фоновое обновление списков моделей облачных
+// провайдеров и обнаружение изменений каталога.
+//
+// Watcher периодически запрашивает ListModels у включённых провайдеров,
+// хранит последний список с ETag и сравнивает новый ответ с прошлым:
+// добавленные и удалённые модели передаются в OnChange. Провайдеры с
+// условными запросами (llm.ConditionalModelLister) получают If-None-Match
+// и не скачивают каталог заново, если он не менялся.
+package modelwatch
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"log/slog"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/neo-2022/openclaw-memory/agent-service/internal/llm"
+)
+
+// disabledPoll — как часто проверять, не включили ли обновление (интервал 0).
+const disabledPoll = time.Minute
+
+// Snapshot — последний полученный список моделей провайдера.
+//
+// ETag — хэш отсортированного списка: отдаётся клиентам /cloud-models и
+// меняется только вместе с составом каталога. upstreamETag — ETag ответа
+// провайдера для следующего условного запроса.
+type Snapshot struct {
+	Models    []string  `json:"models"`
+	ETag      string    `json:"etag"`
+	FetchedAt time.Time `json:"fetched_at"`
+
+	upstreamETag string
+}
+
+// Change — изменение каталога моделей провайдера.
+type Change struct {
+	Provider string    `json:"provider"`
+	Added    []string  `json:"added,omitempty"`
+	Removed  []string  `json:"removed,omitempty"`
+	At       time.Time `json:"at"`
+}
+
+// Watcher — кэш списков моделей с обнаружением изменений.
+type Watcher struct {
+	// OnChange вызывается при каждом изменении каталога (кроме первого
+	// получения списка — оно только запоминает исходное состояние).
+	OnChange func(Change)
+
+	mu        sync.RWMutex
+	snapshots map[string]*Snapshot
+	now       func() time.Time
+}
+
+// New — пустой Watcher.
+func New() *Watcher {
+	return &Watcher{snapshots: make(map[string]*Snapshot), now: time.Now}
+}
+
+// Get — последний список моделей провайдера; false, если его ещё не получали.
+func (w *Watcher) Get(provider string) (Snapshot, bool) {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	s, ok := w.snapshots[provider]
+	if !ok {
+		return Snapshot{}, false
+	}
+	return *s, true
+}
+
+// Refresh — запрашивает список моделей провайдера и сравнивает с прошлым.
+// Возвращает изменение или nil, если состав каталога прежний.
+func (w *Watcher) Refresh(p llm.ChatProvider) (*Change, error) {
+	name := p.Name()
+	w.mu.RLock()
+	prev := w.snapshots[name]
+	w.mu.RUnlock()
+
+	var (
+		models []string
+		etag   string
+		err    error
+	)
+	if cl, ok := p.(llm.ConditionalModelLister); ok {
+		var since string
+		if prev != nil {
+			since = prev.upstreamETag
+		}
+		models, etag, err = cl.ListModelsETag(since)
+		if errors.Is(err, llm.ErrNotModified) && prev != nil {
+			w.mu.Lock()
+			prev.FetchedAt = w.now()
+			w.mu.Unlock()
+			return nil, nil
+		}
+	} else {
+		models, err = p.ListModels()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	models = normalize(models)
+	next := &Snapshot{Models: models, ETag: hashETag(models), FetchedAt: w.now(), upstreamETag: etag}
+	w.mu.Lock()
+	w.snapshots[name] = next
+	w.mu.Unlock()
+
+	if prev == nil || prev.ETag == next.ETag {
+		return nil, nil
+	}
+	ch := &Change{Provider: name, At: next.FetchedAt}
+	ch.Added, ch.Removed = diff(prev.Models, models)
+	if w.OnChange != nil {
+		w.OnChange(*ch)
+	}
+	return ch, nil
+}
+
+// Run — обновляет списки моделей providers() каждые interval(); интервал
+// читается заново на каждом шаге, 0 приостанавливает обновление.
+// Завершается при отмене ctx.
+func (w *Watcher) Run(ctx context.Context, interval func() time.Duration, providers func() []llm.ChatProvider) {
+	for {
+		wait := interval()
+		if wait > 0 {
+			for _, p := range providers() {
+				if ctx.Err() != nil {
+					return
+				}
+				if _, err := w.Refresh(p); err != nil {
+					slog.Warn("Не удалось обновить список моделей", slog.String("провайдер", p.Name()), slog.String("ошибка", err.Error()))
+				}
+			}
+		} else {
+			wait = disabledPoll
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+	}
+}
+
+// normalize — отсортированный список без пустых имён и повторов.
+func normalize(models []string) []string {
+	seen := make(map[string]bool, len(models))
+	out := make([]string, 0, len(models))
+	for _, m := range models {
+		m = strings.TrimSpace(m)
+		if m == "" || seen[m] {
+			continue
+		}
+		seen[m] = true
+		out = append(out, m)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// hashETag — слабый ETag по составу списка.
+func hashETag(models []string) string {
+	sum := sha256.Sum256([]byte(strings.Join(models, "\n")))
+	return `W/"` + hex.EncodeToString(sum[:8]) + `"`
+}
+
+// diff — модели, появившиеся в next и пропавшие из prev (оба списка отсортированы).
+func diff(prev, next []string) (added, removed []string) {
+	i, j := 0, 0
+	for i < len(prev) || j < len(next) {
+		switch {
+		case j == len(next) || (i < len(prev) && prev[i] < next[j]):
+			removed = append(removed, prev[i])
+			i++
+		case i == len(prev) || next[j] < prev[i]:
+			added = append(added, next[j])
+			j++
+		default:
+			i++
+			j++
+		}
+	}
+	return added, removed
+}
@@ -0,0 +1,79 @@
+package modelwatch
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/neo-2022/openclaw-memory/agent-service/internal/llm"
+)
+
+// listProvider — провайдер с задаваемым списком моделей.
+type listProvider struct {
+	*llm.MockProvider
+	models []string
+}
+
+func (p *listProvider) ListModels() ([]string, error) { return p.models, nil }
+
+// TestRefreshDetectsChanges — первый список только запоминается, затем diff.
+func TestRefreshDetectsChanges(t *testing.T) {
+	p := &listProvider{MockProvider: llm.NewMockProvider("cerebras"), models: []string{"llama3.1-8b", "qwen-3-32b"}}
+	w := New()
+	var got []Change
+	w.OnChange = func(c Change) { got = append(got, c) }
+
+	if ch, err := w.Refresh(p); err != nil || ch != nil {
+		t.Fatalf("первое получение: %v %v", ch, err)
+	}
+	first, _ := w.Get("cerebras")
+
+	p.models = []string{"qwen-3-32b", "llama3.1-8b", ""}
+	if ch, _ := w.Refresh(p); ch != nil {
+		t.Errorf("порядок и пустые имена не считаются изменением: %+v", ch)
+	}
+
+	p.models = []string{"gpt-oss-120b", "qwen-3-32b"}
+	ch, err := w.Refresh(p)
+	if err != nil || ch == nil {
+		t.Fatalf("изменение не обнаружено: %v", err)
+	}
+	if !reflect.DeepEqual(ch.Added, []string{"gpt-oss-120b"}) || !reflect.DeepEqual(ch.Removed, []string{"llama3.1-8b"}) {
+		t.Errorf("изменение: %+v", ch)
+	}
+	if len(got) != 1 {
+		t.Errorf("OnChange вызван %d раз", len(got))
+	}
+	if s, _ := w.Get("cerebras"); s.ETag == first.ETag {
+		t.Error("ETag не изменился вместе со списком")
+	}
+}
+
+// TestRefreshConditional — повторный запрос с If-None-Match, 304 сохраняет список.
+func TestRefreshConditional(t *testing.T) {
+	var conditional int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			conditional++
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		fmt.Fprint(w, `{"data":[{"id":"openai/gpt-4o"},{"id":"anthropic/claude-sonnet-4"}]}`)
+	}))
+	defer srv.Close()
+
+	p := llm.NewOpenRouterProvider("sk-or-v1-abc", srv.URL)
+	w := New()
+	for i := 0; i < 2; i++ {
+		if _, err := w.Refresh(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s, ok := w.Get("openrouter")
+	if !ok || conditional != 1 || !reflect.DeepEqual(s.Models, []string{"anthropic/claude-sonnet-4", "openai/gpt-4o"}) {
+		t.Errorf("условных запросов %d, список %+v", conditional, s)
+	}
+}
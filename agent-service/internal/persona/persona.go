@@ -0,0 +1,116 @@
+// Package persona — образы агента (persona packs): сохранённый набор из
+// системного промпта, температуры, политики инструментов и аватара.
+//
+// Образ накладывается поверх настроек агента: пустой промпт или аватар
+// образа — действуют промпт и аватар агента. Промпт может быть взят из файла
+// prompts/{agent}/ — тогда он перечитывается при каждом переключении на образ.
+// Переключение меняет только Agent.Persona, поэтому мгновенно и обратимо:
+// пустое имя возвращает агенту собственные настройки. Каждый ответ помнит
+// образ, которым он дан (Message.Persona).
+package persona
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/neo-2022/openclaw-memory/agent-service/internal/models"
+	"github.com/neo-2022/openclaw-memory/agent-service/internal/promptpack"
+)
+
+// Политики инструментов образа.
+const (
+	ToolsAll   = "all"   // Все инструменты агента
+	ToolsNone  = "none"  // Без инструментов
+	ToolsAllow = "allow" // Только перечисленные в Tools
+	ToolsDeny  = "deny"  // Все, кроме перечисленных в Tools
+)
+
+var (
+	// ErrNotFound — у агента нет образа с таким именем.
+	ErrNotFound = errors.New("образ не найден")
+)
+
+var personaName = regexp.MustCompile(`^[\p{L}\p{N}_-]{1,64}$`)
+
+// ValidName — имя образа: буквы, цифры, «_» и «-», до 64 символов.
+func ValidName(name string) bool {
+	return personaName.MatchString(name)
+}
+
+// Normalize — проверяет образ перед сохранением и приводит поля к
+// каноническому виду: политика по умолчанию all, список инструментов без
+// пробелов и повторов, по алфавиту.
+func Normalize(p *models.Persona) error {
+	if !ValidName(p.Name) {
+		return fmt.Errorf("некорректное имя образа %q: буквы, цифры, «_» и «-», до 64 символов", p.Name)
+	}
+	if p.PromptFile != "" && !promptpack.ValidFilename(p.PromptFile) {
+		return fmt.Errorf("имя файла промпта %q: ожидается имя без пути с расширением %s", p.PromptFile, strings.Join(promptpack.Extensions, ", "))
+	}
+	if len(p.Prompt) > promptpack.MaxPromptBytes {
+		return fmt.Errorf("промпт больше %d КБ", promptpack.MaxPromptBytes>>10)
+	}
+	if p.Temperature != nil && (*p.Temperature < 0 || *p.Temperature > 2) {
+		return errors.New("temperature должна быть от 0 до 2")
+	}
+	if p.Avatar != "" && (p.Avatar != filepath.Base(p.Avatar) || strings.HasPrefix(p.Avatar, ".")) {
+		return fmt.Errorf("некорректное имя файла аватара %q", p.Avatar)
+	}
+	if p.ToolPolicy == "" {
+		p.ToolPolicy = ToolsAll
+	}
+	tools := SplitTools(p.Tools)
+	switch p.ToolPolicy {
+	case ToolsAll, ToolsNone:
+		tools = nil
+	case ToolsAllow, ToolsDeny:
+		if len(tools) == 0 {
+			return fmt.Errorf("для tool_policy=%s нужен список tools", p.ToolPolicy)
+		}
+	default:
+		return fmt.Errorf("неизвестная tool_policy %q: ожидается %s, %s, %s или %s", p.ToolPolicy, ToolsAll, ToolsNone, ToolsAllow, ToolsDeny)
+	}
+	p.Tools = strings.Join(tools, ",")
+	return nil
+}
+
+// SplitTools — список инструментов из строки через запятую: без пробелов,
+// пустых и повторов, по алфавиту.
+func SplitTools(s string) []string {
+	seen := map[string]bool{}
+	var out []string
+	for _, t := range strings.Split(s, ",") {
+		if t = strings.TrimSpace(t); t != "" && !seen[t] {
+			seen[t] = true
+			out = append(out, t)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+// AllowsTool — доступен ли инструмент модели, когда агент отвечает образом p
+// (nil — без образа, доступно всё).
+func AllowsTool(p *models.Persona, tool string) bool {
+	if p == nil {
+		return true
+	}
+	switch p.ToolPolicy {
+	case ToolsNone:
+		return false
+	case ToolsAllow, ToolsDeny:
+		listed := false
+		for _, t := range SplitTools(p.Tools) {
+			if t == tool {
+				listed = true
+				break
+			}
+		}
+		return listed == (p.ToolPolicy == ToolsAllow)
+	}
+	return true
+}
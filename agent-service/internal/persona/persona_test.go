@@ -0,0 +1,56 @@
+package persona
+
+import (
+	"testing"
+
+	"github.com/neo-2022/openclaw-memory/agent-service/internal/models"
+)
+
+func TestNormalize(t *testing.T) {
+	p := &models.Persona{Name: "reviewer", ToolPolicy: ToolsDeny, Tools: " write, execute,write ,"}
+	if err := Normalize(p); err != nil {
+		t.Fatalf("Normalize: %v", err)
+	}
+	if p.Tools != "execute,write" {
+		t.Errorf("список инструментов: %q", p.Tools)
+	}
+	p = &models.Persona{Name: "plain", Tools: "read"}
+	if err := Normalize(p); err != nil || p.ToolPolicy != ToolsAll || p.Tools != "" {
+		t.Errorf("политика по умолчанию: %+v, %v", p, err)
+	}
+
+	hot := 2.5
+	for name, bad := range map[string]models.Persona{
+		"имя с пробелом":   {Name: "code review"},
+		"путь в файле":     {Name: "a", PromptFile: "../admin/x.md"},
+		"температура":      {Name: "a", Temperature: &hot},
+		"неизвестная":      {Name: "a", ToolPolicy: "some"},
+		"allow без списка": {Name: "a", ToolPolicy: ToolsAllow},
+		"аватар с путём":   {Name: "a", Avatar: "../secret.webp"},
+	} {
+		if err := Normalize(&bad); err == nil {
+			t.Errorf("%s: ожидалась ошибка", name)
+		}
+	}
+}
+
+func TestAllowsTool(t *testing.T) {
+	cases := []struct {
+		p     *models.Persona
+		tool  string
+		allow bool
+	}{
+		{nil, "execute", true},
+		{&models.Persona{ToolPolicy: ToolsAll}, "execute", true},
+		{&models.Persona{ToolPolicy: ToolsNone}, "read", false},
+		{&models.Persona{ToolPolicy: ToolsAllow, Tools: "read,list"}, "read", true},
+		{&models.Persona{ToolPolicy: ToolsAllow, Tools: "read,list"}, "execute", false},
+		{&models.Persona{ToolPolicy: ToolsDeny, Tools: "execute"}, "execute", false},
+		{&models.Persona{ToolPolicy: ToolsDeny, Tools: "execute"}, "read", true},
+	}
+	for _, c := range cases {
+		if got := AllowsTool(c.p, c.tool); got != c.allow {
+			t.Errorf("AllowsTool(%+v, %s) = %v", c.p, c.tool, got)
+		}
+	}
+}
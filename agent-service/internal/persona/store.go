@@ -0,0 +1,148 @@
+package persona
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"gorm.io/gorm"
+
+	"github.com/neo-2022/openclaw-memory/agent-service/internal/models"
+	"github.com/neo-2022/openclaw-memory/agent-service/internal/promptpack"
+)
+
+// Store — образы агентов в БД; промпты из файлов читаются из PromptsDir.
+type Store struct {
+	db         *gorm.DB
+	PromptsDir string
+}
+
+// NewStore — хранилище образов поверх db; файлы промптов — в promptsDir/{agent}/.
+func NewStore(db *gorm.DB, promptsDir string) *Store {
+	return &Store{db: db, PromptsDir: promptsDir}
+}
+
+// List — образы агента по имени.
+func (s *Store) List(agent string) ([]models.Persona, error) {
+	var out []models.Persona
+	err := s.db.Where("agent = ?", agent).Order("name").Find(&out).Error
+	return out, err
+}
+
+// Get — образ агента по имени; ErrNotFound, если его нет.
+func (s *Store) Get(agent, name string) (*models.Persona, error) {
+	var p models.Persona
+	err := s.db.Where("agent = ? AND name = ?", agent, name).First(&p).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, name)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// Save — создаёт образ или заменяет образ агента с тем же именем (аватар
+// прежнего образа сохраняется, если не задан новый). Если задан PromptFile,
+// Prompt берётся из файла. Возвращает true, если образ создан.
+func (s *Store) Save(p *models.Persona) (bool, error) {
+	if err := Normalize(p); err != nil {
+		return false, err
+	}
+	if p.PromptFile != "" {
+		f, err := promptpack.Read(s.PromptsDir, p.Agent, p.PromptFile)
+		if err != nil {
+			return false, fmt.Errorf("файл промпта %s: %w", p.PromptFile, err)
+		}
+		p.Prompt = f.Content
+	}
+	old, err := s.Get(p.Agent, p.Name)
+	if errors.Is(err, ErrNotFound) {
+		return true, s.db.Create(p).Error
+	}
+	if err != nil {
+		return false, err
+	}
+	p.ID, p.CreatedAt = old.ID, old.CreatedAt
+	if p.Avatar == "" {
+		p.Avatar = old.Avatar
+	}
+	return false, s.db.Save(p).Error
+}
+
+// Delete — удаляет образ; агент, у которого он активен, возвращается к
+// собственным настройкам. Возвращает удалённый образ (его аватар удаляет
+// вызывающий).
+func (s *Store) Delete(agent, name string) (*models.Persona, error) {
+	p, err := s.Get(agent, name)
+	if err != nil {
+		return nil, err
+	}
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(p).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.Agent{}).Where("name = ? AND persona = ?", agent, name).Update("persona", "").Error
+	})
+	return p, err
+}
+
+// Switch — делает образ name активным для агента; пустое имя возвращает
+// агенту собственные настройки (результат nil). Промпт образа из файла
+// перечитывается; если файла больше нет, остаётся сохранённый текст.
+func (s *Store) Switch(agent *models.Agent, name string) (*models.Persona, error) {
+	var p *models.Persona
+	if name != "" {
+		var err error
+		if p, err = s.Get(agent.Name, name); err != nil {
+			return nil, err
+		}
+		s.refreshPrompt(p)
+	}
+	if err := s.db.Model(agent).Update("persona", name).Error; err != nil {
+		return nil, err
+	}
+	agent.Persona = name
+	return p, nil
+}
+
+// refreshPrompt — перечитывает промпт образа из PromptFile и сохраняет, если он изменился.
+func (s *Store) refreshPrompt(p *models.Persona) {
+	if p.PromptFile == "" {
+		return
+	}
+	f, err := promptpack.Read(s.PromptsDir, p.Agent, p.PromptFile)
+	if err != nil {
+		slog.Warn("Файл промпта образа не прочитан, используется сохранённый текст",
+			slog.String("агент", p.Agent), slog.String("образ", p.Name), slog.String("файл", p.PromptFile), slog.String("ошибка", err.Error()))
+		return
+	}
+	if f.Content != p.Prompt {
+		p.Prompt = f.Content
+		s.db.Model(p).Update("prompt", p.Prompt)
+	}
+}
+
+// Active — активный образ агента (nil — без образа или образ удалён).
+func (s *Store) Active(agent *models.Agent) *models.Persona {
+	if agent.Persona == "" {
+		return nil
+	}
+	p, err := s.Get(agent.Name, agent.Persona)
+	if err != nil {
+		slog.Warn("Активный образ агента не найден", slog.String("агент", agent.Name), slog.String("образ", agent.Persona), slog.String("ошибка", err.Error()))
+		return nil
+	}
+	return p
+}
+
+// ActiveByAgent — активные образы агентов списка: имя агента → образ.
+func (s *Store) ActiveByAgent(agents []models.Agent) map[string]*models.Persona {
+	out := map[string]*models.Persona{}
+	for i := range agents {
+		if p := s.Active(&agents[i]); p != nil {
+			out[agents[i].Name] = p
+		}
+	}
+	return out
+}
@@ -0,0 +1,119 @@
+// Package progress — промежуточный прогресс долгого запроса /chat.
+//
+// Цикл инструментов и составные скилы (setup_git_automation, run_commands,
+// project_init) сообщают, какой раунд идёт, какой инструмент выполняется и
+// какая часть плана пройдена. Обработчик потокового /chat (Accept:
+// text/event-stream) передаёт события клиенту как event: progress, чтобы
+// пользователь видел ход задачи, а не индикатор ожидания. Без подписчика
+// Report ничего не делает.
+package progress
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Event — событие прогресса.
+type Event struct {
+	Round     int    `json:"round"`             // Раунд цикла инструментов (с 1)
+	Tool      string `json:"tool,omitempty"`    // Выполняемый инструмент
+	Step      int    `json:"step,omitempty"`    // Шаг составного скила (с 1)
+	Steps     int    `json:"steps,omitempty"`   // Всего шагов скила
+	Percent   int    `json:"percent,omitempty"` // Выполненная часть плана скила, 0–100
+	Detail    string `json:"detail,omitempty"`  // Что происходит — для показа пользователю
+	ElapsedMs int64  `json:"elapsed_ms"`        // От начала запроса
+}
+
+// Func — получатель событий; вызывается синхронно из цикла /chat.
+type Func func(Event)
+
+// Tracker — прогресс одного запроса: текущий раунд и инструмент
+// подставляются в события шагов скила.
+type Tracker struct {
+	mu    sync.Mutex
+	fn    Func
+	start time.Time
+	round int
+	tool  string
+}
+
+type trackerKey struct{}
+
+// With — контекст, события которого уходят в fn.
+func With(ctx context.Context, fn Func) context.Context {
+	return context.WithValue(ctx, trackerKey{}, &Tracker{fn: fn, start: time.Now()})
+}
+
+// From — прогресс запроса; nil — событий никто не ждёт.
+func From(ctx context.Context) *Tracker {
+	t, _ := ctx.Value(trackerKey{}).(*Tracker)
+	return t
+}
+
+// Round — начался раунд цикла инструментов (round с 0, как в цикле).
+func Round(ctx context.Context, round int, detail string) {
+	t := From(ctx)
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	t.round, t.tool = round+1, ""
+	t.mu.Unlock()
+	t.emit(Event{Detail: detail})
+}
+
+// Tool — начат вызов инструмента.
+func Tool(ctx context.Context, tool string) {
+	t := From(ctx)
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	t.tool = tool
+	t.mu.Unlock()
+	t.emit(Event{Detail: "выполняется " + tool})
+}
+
+// Note — инструменты раунда выполнены, идёт другая работа (например, запрос к модели).
+func Note(ctx context.Context, detail string) {
+	t := From(ctx)
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	t.tool = ""
+	t.mu.Unlock()
+	t.emit(Event{Detail: detail})
+}
+
+// Step — начат шаг step из steps составного скила; процент — по завершённым шагам.
+func Step(ctx context.Context, step, steps int, detail string) {
+	t := From(ctx)
+	if t == nil || steps <= 0 {
+		return
+	}
+	t.emit(Event{Step: step, Steps: steps, Percent: Percent(step-1, steps), Detail: detail})
+}
+
+// Percent — доля done из total в процентах (0–100).
+func Percent(done, total int) int {
+	switch {
+	case total <= 0 || done <= 0:
+		return 0
+	case done >= total:
+		return 100
+	}
+	return done * 100 / total
+}
+
+func (t *Tracker) emit(e Event) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	e.Round = t.round
+	if e.Tool == "" {
+		e.Tool = t.tool
+	}
+	e.ElapsedMs = time.Since(t.start).Milliseconds()
+	t.fn(e)
+}
@@ -0,0 +1,42 @@
+package progress
+
+import (
+	"context"
+	"testing"
+)
+
+func TestEvents(t *testing.T) {
+	var got []Event
+	ctx := With(context.Background(), func(e Event) { got = append(got, e) })
+	Round(ctx, 0, "запрос к модели")
+	Tool(ctx, "setup_git_automation")
+	Step(ctx, 3, 7, "создание autocommit.sh")
+	Round(ctx, 1, "")
+
+	if len(got) != 4 {
+		t.Fatalf("событий %d: %+v", len(got), got)
+	}
+	if s := got[2]; s.Round != 1 || s.Tool != "setup_git_automation" || s.Step != 3 || s.Steps != 7 || s.Percent != 28 {
+		t.Errorf("шаг скила: %+v", s)
+	}
+	if r := got[3]; r.Round != 2 || r.Tool != "" {
+		t.Errorf("новый раунд сбрасывает инструмент: %+v", r)
+	}
+}
+
+func TestNoSubscriber(t *testing.T) {
+	ctx := context.Background()
+	Round(ctx, 0, "")
+	Tool(ctx, "read")
+	Step(ctx, 1, 2, "")
+}
+
+func TestPercent(t *testing.T) {
+	for _, tc := range []struct{ done, total, want int }{
+		{0, 5, 0}, {1, 4, 25}, {4, 4, 100}, {9, 4, 100}, {1, 0, 0},
+	} {
+		if got := Percent(tc.done, tc.total); got != tc.want {
+			t.Errorf("Percent(%d, %d) = %d, ожидали %d", tc.done, tc.total, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,197 @@
+package promptpack
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrNotFound — файла промпта нет.
+	ErrNotFound = errors.New("файл промпта не найден")
+	// ErrExists — файл с таким именем уже есть.
+	ErrExists = errors.New("файл промпта с таким именем уже существует")
+	// ErrChanged — файл изменён после того, как его прочитали (base_sha256 не совпал).
+	ErrChanged = errors.New("файл промпта изменён другим пользователем")
+)
+
+// File — файл промпта prompts/{agent}/{filename}. Content заполняется только
+// при чтении одного файла.
+type File struct {
+	Agent    string    `json:"agent"`
+	Filename string    `json:"filename"`
+	Size     int64     `json:"size"`
+	ModTime  time.Time `json:"mod_time"`
+	SHA256   string    `json:"sha256"`
+	Content  string    `json:"content,omitempty"`
+}
+
+// path — путь к файлу промпта; имена агента и файла проверяются, чтобы
+// запрос не вышел за пределы dir.
+func path(dir, agent, name string) (string, error) {
+	if !ValidAgent(agent) {
+		return "", fmt.Errorf("некорректное имя агента %q", agent)
+	}
+	if !ValidFilename(name) {
+		return "", fmt.Errorf("имя файла %q: ожидается имя без пути с расширением %s", name, strings.Join(Extensions, ", "))
+	}
+	return filepath.Join(dir, agent, name), nil
+}
+
+// checkContent — содержимое, пригодное для файла промпта.
+func checkContent(content string) error {
+	if len(content) > MaxPromptBytes {
+		return fmt.Errorf("промпт больше %d КБ", MaxPromptBytes>>10)
+	}
+	return nil
+}
+
+// List — файлы промптов агента без содержимого, по имени.
+func List(dir, agent string) ([]File, error) {
+	prompts, err := ReadDir(dir, agent)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]File, 0, len(prompts))
+	for _, p := range prompts {
+		info, err := os.Stat(filepath.Join(dir, agent, p.Filename))
+		if err != nil {
+			continue
+		}
+		out = append(out, File{Agent: agent, Filename: p.Filename, Size: info.Size(), ModTime: info.ModTime(), SHA256: Checksum(p.Content)})
+	}
+	return out, nil
+}
+
+// Read — файл промпта с содержимым.
+func Read(dir, agent, name string) (File, error) {
+	p, err := path(dir, agent, name)
+	if err != nil {
+		return File{}, err
+	}
+	info, err := os.Lstat(p)
+	if errors.Is(err, os.ErrNotExist) || (err == nil && !info.Mode().IsRegular()) {
+		return File{}, ErrNotFound
+	}
+	if err != nil {
+		return File{}, err
+	}
+	data, err := os.ReadFile(p)
+	if err != nil {
+		return File{}, err
+	}
+	return File{Agent: agent, Filename: name, Size: info.Size(), ModTime: info.ModTime(), SHA256: Checksum(string(data)), Content: string(data)}, nil
+}
+
+// Create — новый файл промпта; существующий не перезаписывается (ErrExists).
+func Create(dir, agent, name, content string) (File, error) {
+	p, err := path(dir, agent, name)
+	if err != nil {
+		return File{}, err
+	}
+	if err := checkContent(content); err != nil {
+		return File{}, err
+	}
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		return File{}, err
+	}
+	f, err := os.OpenFile(p, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if errors.Is(err, os.ErrExist) {
+		return File{}, ErrExists
+	}
+	if err != nil {
+		return File{}, err
+	}
+	_, err = f.WriteString(content)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(p)
+		return File{}, err
+	}
+	return Read(dir, agent, name)
+}
+
+// Update — новое содержимое файла. baseSHA256 — контрольная сумма версии,
+// которую правил пользователь (пусто — без проверки): если файл с тех пор
+// изменился, возвращается ErrChanged, а файл не трогается.
+func Update(dir, agent, name, content, baseSHA256 string) (File, error) {
+	cur, err := Read(dir, agent, name)
+	if err != nil {
+		return File{}, err
+	}
+	if err := checkContent(content); err != nil {
+		return File{}, err
+	}
+	if baseSHA256 != "" && !strings.EqualFold(baseSHA256, cur.SHA256) {
+		return File{}, ErrChanged
+	}
+	if err := writeFile(filepath.Join(dir, agent), name, content); err != nil {
+		return File{}, err
+	}
+	return Read(dir, agent, name)
+}
+
+// Rename — переименование файла промпта; существующий файл с новым именем
+// не перезаписывается (ErrExists).
+func Rename(dir, agent, name, newName string) (File, error) {
+	if _, err := Read(dir, agent, name); err != nil {
+		return File{}, err
+	}
+	dst, err := path(dir, agent, newName)
+	if err != nil {
+		return File{}, err
+	}
+	if name == newName {
+		return Read(dir, agent, name)
+	}
+	// Link не заменяет существующий файл, в отличие от Rename
+	if err := os.Link(filepath.Join(dir, agent, name), dst); err != nil {
+		if errors.Is(err, os.ErrExist) {
+			return File{}, ErrExists
+		}
+		return File{}, err
+	}
+	if err := os.Remove(filepath.Join(dir, agent, name)); err != nil {
+		return File{}, err
+	}
+	return Read(dir, agent, newName)
+}
+
+// Delete — удаление файла промпта.
+func Delete(dir, agent, name string) error {
+	if _, err := Read(dir, agent, name); err != nil {
+		return err
+	}
+	return os.Remove(filepath.Join(dir, agent, name))
+}
+
+// writeFile — запись через временный файл: прерванная запись не оставит
+// половину промпта.
+func writeFile(dir, name, content string) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(dir, ".write-*")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.WriteString(content)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Chmod(tmp.Name(), 0o644)
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), filepath.Join(dir, name))
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+	}
+	return err
+}
@@ -0,0 +1,70 @@
+package promptpack
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestFiles — создание, правка с проверкой версии, переименование и удаление.
+func TestFiles(t *testing.T) {
+	dir := t.TempDir()
+	f, err := Create(dir, "admin", "ops.md", "v1")
+	if err != nil || f.Content != "v1" || f.SHA256 != Checksum("v1") {
+		t.Fatalf("Create: %+v %v", f, err)
+	}
+	if _, err := Create(dir, "admin", "ops.md", "v2"); !errors.Is(err, ErrExists) {
+		t.Errorf("повторное создание: %v", err)
+	}
+	if info, err := os.Stat(filepath.Join(dir, "admin", "ops.md")); err != nil || info.Mode().Perm() != 0o644 {
+		t.Errorf("права файла: %v %v", info, err)
+	}
+
+	if _, err := Update(dir, "admin", "ops.md", "v2", Checksum("другое")); !errors.Is(err, ErrChanged) {
+		t.Errorf("правка устаревшей версии: %v", err)
+	}
+	f, err = Update(dir, "admin", "ops.md", "v2", f.SHA256)
+	if err != nil || f.Content != "v2" {
+		t.Fatalf("Update: %+v %v", f, err)
+	}
+	if info, _ := os.Stat(filepath.Join(dir, "admin", "ops.md")); info.Mode().Perm() != 0o644 {
+		t.Errorf("права после правки: %v", info.Mode())
+	}
+
+	if _, err := Create(dir, "admin", "other.txt", "x"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Rename(dir, "admin", "ops.md", "other.txt"); !errors.Is(err, ErrExists) {
+		t.Errorf("переименование поверх существующего: %v", err)
+	}
+	if f, err = Rename(dir, "admin", "ops.md", "devops.md"); err != nil || f.Content != "v2" {
+		t.Fatalf("Rename: %+v %v", f, err)
+	}
+	if _, err := Read(dir, "admin", "ops.md"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("старое имя после переименования: %v", err)
+	}
+
+	list, err := List(dir, "admin")
+	if err != nil || len(list) != 2 || list[0].Filename != "devops.md" || list[0].Content != "" || list[0].Size != 2 {
+		t.Errorf("List: %+v %v", list, err)
+	}
+
+	if err := Delete(dir, "admin", "devops.md"); err != nil {
+		t.Fatal(err)
+	}
+	if err := Delete(dir, "admin", "devops.md"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("повторное удаление: %v", err)
+	}
+
+	// Выход за пределы каталога и недопустимые имена
+	for _, c := range [][2]string{{"admin", "../../etc/passwd"}, {"..", "a.md"}, {"admin", ".hidden.md"}, {"admin", "run.sh"}, {"a/b", "a.md"}} {
+		if _, err := Create(dir, c[0], c[1], "x"); err == nil || errors.Is(err, ErrExists) {
+			t.Errorf("%s/%s: ожидалась ошибка имени, получено %v", c[0], c[1], err)
+		}
+	}
+	if _, err := Create(dir, "admin", "big.md", strings.Repeat("x", MaxPromptBytes+1)); err == nil {
+		t.Error("слишком большой промпт должен быть отклонён")
+	}
+}
@@ -0,0 +1,283 @@
+// Package promptpack — переносимые наборы промптов (prompt packs).
+//
+// Набор — один YAML-файл (JSON тоже читается): манифест с названием, автором
+// и версией формата и список промптов с метаданными — агент, имя файла,
+// описание, теги, модель, на которой промпт проверен, и SHA-256 содержимого.
+// Экспорт собирает набор из prompts/{agent}/ или текущего промпта агента,
+// импорт раскладывает промпты по prompts/{agent}/{filename}. Если файл с
+// таким именем уже есть и отличается, решает политика конфликтов: пропустить,
+// перезаписать или сохранить под новым именем.
+//
+// Отдельные файлы prompts/{agent}/ создаются, правятся, переименовываются и
+// удаляются через функции files.go (API /prompts/files).
+package promptpack
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Format и Version — обязательные поля манифеста; набор другого формата
+// или более новой версии не импортируется.
+const (
+	Format  = "agent-regart/prompt-pack"
+	Version = 1
+)
+
+// Лимиты набора: импорт принимает файлы от других людей.
+const (
+	MaxPrompts     = 200
+	MaxPromptBytes = 256 << 10
+)
+
+// Extensions — расширения файлов промптов в prompts/{agent}/.
+var Extensions = []string{".txt", ".prompt", ".md"}
+
+// Pack — манифест и промпты набора.
+type Pack struct {
+	Format      string    `yaml:"format" json:"format"`
+	Version     int       `yaml:"version" json:"version"`
+	Name        string    `yaml:"name" json:"name"`
+	Description string    `yaml:"description,omitempty" json:"description,omitempty"`
+	Author      string    `yaml:"author,omitempty" json:"author,omitempty"`
+	CreatedAt   time.Time `yaml:"created_at" json:"created_at"`
+	Prompts     []Prompt  `yaml:"prompts" json:"prompts"`
+}
+
+// Prompt — один промпт набора.
+type Prompt struct {
+	Agent       string   `yaml:"agent" json:"agent"`
+	Filename    string   `yaml:"filename" json:"filename"`
+	Description string   `yaml:"description,omitempty" json:"description,omitempty"`
+	Tags        []string `yaml:"tags,omitempty" json:"tags,omitempty"`
+	Provider    string   `yaml:"provider,omitempty" json:"provider,omitempty"` // Провайдер и модель, на которых промпт проверен
+	Model       string   `yaml:"model,omitempty" json:"model,omitempty"`
+	SHA256      string   `yaml:"sha256" json:"sha256"`
+	Content     string   `yaml:"content" json:"content"`
+}
+
+var agentName = regexp.MustCompile(`^[\p{L}\p{N}_-]{1,64}$`)
+
+// ValidAgent — имя агента, пригодное для каталога prompts/{agent}.
+func ValidAgent(name string) bool {
+	return agentName.MatchString(name)
+}
+
+// ValidFilename — имя файла промпта без пути, не скрытое, с расширением из
+// Extensions.
+func ValidFilename(name string) bool {
+	if name == "" || name != filepath.Base(name) || strings.HasPrefix(name, ".") || strings.ContainsAny(name, `/\`+"\x00") {
+		return false
+	}
+	ext := strings.ToLower(filepath.Ext(name))
+	for _, e := range Extensions {
+		if ext == e {
+			return true
+		}
+	}
+	return false
+}
+
+// Checksum — SHA-256 содержимого промпта в hex.
+func Checksum(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
+
+// New — набор name из prompts; SHA256 заполняется по содержимому.
+func New(name string, prompts []Prompt, now time.Time) *Pack {
+	for i := range prompts {
+		prompts[i].SHA256 = Checksum(prompts[i].Content)
+	}
+	return &Pack{Format: Format, Version: Version, Name: name, CreatedAt: now.UTC().Truncate(time.Second), Prompts: prompts}
+}
+
+// Encode — набор в YAML.
+func Encode(p *Pack) ([]byte, error) {
+	return yaml.Marshal(p)
+}
+
+// Parse — разбирает и проверяет набор (YAML или JSON).
+func Parse(data []byte) (*Pack, error) {
+	var p Pack
+	if err := yaml.Unmarshal(data, &p); err != nil {
+		return nil, fmt.Errorf("набор промптов: %w", err)
+	}
+	if err := p.Validate(); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// Validate — формат и версия манифеста, имена агентов и файлов, размер и
+// контрольные суммы промптов. Возвращает все найденные ошибки сразу.
+func (p *Pack) Validate() error {
+	if p.Format != Format {
+		return fmt.Errorf("format: %q, ожидается %q", p.Format, Format)
+	}
+	if p.Version < 1 || p.Version > Version {
+		return fmt.Errorf("version: %d, поддерживается до %d", p.Version, Version)
+	}
+	if len(p.Prompts) == 0 || len(p.Prompts) > MaxPrompts {
+		return fmt.Errorf("prompts: от 1 до %d промптов", MaxPrompts)
+	}
+	var errs []error
+	seen := make(map[string]bool, len(p.Prompts))
+	for i, pr := range p.Prompts {
+		switch {
+		case !ValidAgent(pr.Agent):
+			errs = append(errs, fmt.Errorf("prompts[%d].agent: некорректное имя %q", i, pr.Agent))
+		case !ValidFilename(pr.Filename):
+			errs = append(errs, fmt.Errorf("prompts[%d].filename: %q, ожидается имя файла %s", i, pr.Filename, strings.Join(Extensions, ", ")))
+		case strings.TrimSpace(pr.Content) == "":
+			errs = append(errs, fmt.Errorf("prompts[%d]: пустой промпт", i))
+		case len(pr.Content) > MaxPromptBytes:
+			errs = append(errs, fmt.Errorf("prompts[%d]: больше %d КБ", i, MaxPromptBytes>>10))
+		case pr.SHA256 != "" && !strings.EqualFold(pr.SHA256, Checksum(pr.Content)):
+			errs = append(errs, fmt.Errorf("prompts[%d]: sha256 не совпадает с содержимым — файл повреждён или изменён", i))
+		case seen[pr.Agent+"/"+pr.Filename]:
+			errs = append(errs, fmt.Errorf("prompts[%d]: %s/%s повторяется", i, pr.Agent, pr.Filename))
+		}
+		seen[pr.Agent+"/"+pr.Filename] = true
+	}
+	return errors.Join(errs...)
+}
+
+// ReadDir — промпты агента из dir/{agent}/ по имени файла.
+func ReadDir(dir, agent string) ([]Prompt, error) {
+	if !ValidAgent(agent) {
+		return nil, fmt.Errorf("некорректное имя агента %q", agent)
+	}
+	entries, err := os.ReadDir(filepath.Join(dir, agent))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var out []Prompt
+	for _, e := range entries {
+		if e.IsDir() || !ValidFilename(e.Name()) {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, agent, e.Name()))
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, Prompt{Agent: agent, Filename: e.Name(), Content: string(data)})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Filename < out[j].Filename })
+	return out, nil
+}
+
+// Conflict — что делать, если файл с таким именем уже есть и отличается.
+type Conflict string
+
+const (
+	ConflictSkip      Conflict = "skip"      // Оставить существующий файл (по умолчанию)
+	ConflictOverwrite Conflict = "overwrite" // Заменить содержимым из набора
+	ConflictRename    Conflict = "rename"    // Сохранить рядом под именем name-2.md
+)
+
+// ParseConflict — политика из параметра запроса; пусто — ConflictSkip.
+func ParseConflict(s string) (Conflict, error) {
+	switch c := Conflict(s); c {
+	case "":
+		return ConflictSkip, nil
+	case ConflictSkip, ConflictOverwrite, ConflictRename:
+		return c, nil
+	}
+	return "", fmt.Errorf("on_conflict: %q, ожидается skip, overwrite или rename", s)
+}
+
+// Статусы промпта в результате импорта.
+const (
+	StatusCreated     = "created"     // Новый файл
+	StatusUnchanged   = "unchanged"   // Файл уже есть с тем же содержимым
+	StatusConflict    = "conflict"    // Файл отличается, оставлен как был (ConflictSkip)
+	StatusOverwritten = "overwritten" // Файл заменён (ConflictOverwrite)
+	StatusRenamed     = "renamed"     // Сохранён под новым именем (ConflictRename)
+	StatusError       = "error"
+)
+
+// Result — итог импорта одного промпта; Filename — имя, под которым промпт
+// сохранён (при StatusRenamed отличается от имени в наборе).
+type Result struct {
+	Agent    string `json:"agent"`
+	Filename string `json:"filename"`
+	Original string `json:"original,omitempty"`
+	Status   string `json:"status"`
+	Error    string `json:"error,omitempty"`
+}
+
+// Import — раскладывает промпты набора по dir/{agent}/{filename}. dryRun —
+// только показать, что будет сделано. Ошибка одного файла не прерывает
+// остальные.
+func Import(dir string, p *Pack, conflict Conflict, dryRun bool) []Result {
+	results := make([]Result, 0, len(p.Prompts))
+	for _, pr := range p.Prompts {
+		res := Result{Agent: pr.Agent, Filename: pr.Filename}
+		status, name, err := importOne(filepath.Join(dir, pr.Agent), pr, conflict, dryRun)
+		res.Status = status
+		if err != nil {
+			res.Status, res.Error = StatusError, err.Error()
+		}
+		if name != pr.Filename {
+			res.Original, res.Filename = pr.Filename, name
+		}
+		results = append(results, res)
+	}
+	return results
+}
+
+func importOne(dir string, pr Prompt, conflict Conflict, dryRun bool) (string, string, error) {
+	name := pr.Filename
+	status := StatusCreated
+	existing, err := os.ReadFile(filepath.Join(dir, name))
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+	case err != nil:
+		return "", name, err
+	case string(existing) == pr.Content:
+		return StatusUnchanged, name, nil
+	case conflict == ConflictOverwrite:
+		status = StatusOverwritten
+	case conflict == ConflictRename:
+		if name, err = freeName(dir, name); err != nil {
+			return "", pr.Filename, err
+		}
+		status = StatusRenamed
+	default:
+		return StatusConflict, name, nil
+	}
+	if dryRun {
+		return status, name, nil
+	}
+	if err := writeFile(dir, name, pr.Content); err != nil {
+		return "", name, err
+	}
+	return status, name, nil
+}
+
+// freeName — name-2.ext, name-3.ext... — первое имя, которого нет в dir.
+func freeName(dir, name string) (string, error) {
+	ext := filepath.Ext(name)
+	base := strings.TrimSuffix(name, ext)
+	for i := 2; i <= 1000; i++ {
+		candidate := fmt.Sprintf("%s-%d%s", base, i, ext)
+		if _, err := os.Stat(filepath.Join(dir, candidate)); errors.Is(err, os.ErrNotExist) {
+			return candidate, nil
+		}
+	}
+	return "", fmt.Errorf("нет свободного имени для %s", name)
+}
@@ -0,0 +1,110 @@
+package promptpack
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestRoundTrip — экспортированный набор читается обратно, изменённое
+// содержимое ловится по sha256.
+func TestRoundTrip(t *testing.T) {
+	pack := New("admin", []Prompt{{Agent: "admin", Filename: "devops.md", Model: "llama3.1:8b", Tags: []string{"ops"}, Content: "Ты — DevOps-инженер.\nОтвечай кратко.\n"}}, time.Now())
+	data, err := Encode(pack)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := Parse(data)
+	if err != nil {
+		t.Fatalf("Parse: %v\n%s", err, data)
+	}
+	if got.Prompts[0].Content != pack.Prompts[0].Content || got.Prompts[0].Model != "llama3.1:8b" || got.Format != Format {
+		t.Errorf("набор после чтения: %+v", got)
+	}
+	tampered := strings.Replace(string(data), "кратко", "подробно", 1)
+	if _, err := Parse([]byte(tampered)); err == nil || !strings.Contains(err.Error(), "sha256") {
+		t.Errorf("изменённое содержимое: %v", err)
+	}
+	// JSON тоже принимается, sha256 необязателен
+	if _, err := Parse([]byte(`{"format":"` + Format + `","version":1,"name":"x","prompts":[{"agent":"coder","filename":"a.txt","content":"hi"}]}`)); err != nil {
+		t.Errorf("JSON: %v", err)
+	}
+}
+
+// TestValidate — формат, версия, имена файлов и агентов.
+func TestValidate(t *testing.T) {
+	for name, p := range map[string]Pack{
+		"формат":   {Format: "other", Version: 1, Prompts: []Prompt{{Agent: "a", Filename: "a.md", Content: "x"}}},
+		"версия":   {Format: Format, Version: Version + 1, Prompts: []Prompt{{Agent: "a", Filename: "a.md", Content: "x"}}},
+		"пусто":    {Format: Format, Version: 1},
+		"путь":     {Format: Format, Version: 1, Prompts: []Prompt{{Agent: "a", Filename: "../a.md", Content: "x"}}},
+		"агент":    {Format: Format, Version: 1, Prompts: []Prompt{{Agent: "../a", Filename: "a.md", Content: "x"}}},
+		"тип":      {Format: Format, Version: 1, Prompts: []Prompt{{Agent: "a", Filename: "a.sh", Content: "x"}}},
+		"скрытый":  {Format: Format, Version: 1, Prompts: []Prompt{{Agent: "a", Filename: ".a.md", Content: "x"}}},
+		"дубль":    {Format: Format, Version: 1, Prompts: []Prompt{{Agent: "a", Filename: "a.md", Content: "x"}, {Agent: "a", Filename: "a.md", Content: "y"}}},
+		"содержим": {Format: Format, Version: 1, Prompts: []Prompt{{Agent: "a", Filename: "a.md", Content: "  "}}},
+	} {
+		if err := p.Validate(); err == nil {
+			t.Errorf("%s: ожидалась ошибка", name)
+		}
+	}
+}
+
+// TestImport — новые файлы, одинаковое содержимое и три политики конфликтов.
+func TestImport(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "admin"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	write := func(name, content string) {
+		if err := os.WriteFile(filepath.Join(dir, "admin", name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	read := func(name string) string {
+		data, _ := os.ReadFile(filepath.Join(dir, "admin", name))
+		return string(data)
+	}
+	write("same.md", "same")
+	write("diff.md", "old")
+	pack := New("p", []Prompt{
+		{Agent: "admin", Filename: "new.md", Content: "new"},
+		{Agent: "admin", Filename: "same.md", Content: "same"},
+		{Agent: "admin", Filename: "diff.md", Content: "incoming"},
+	}, time.Now())
+
+	status := func(res []Result) string {
+		var out []string
+		for _, r := range res {
+			out = append(out, r.Filename+"="+r.Status)
+		}
+		return strings.Join(out, " ")
+	}
+
+	if got := status(Import(dir, pack, ConflictSkip, true)); got != "new.md=created same.md=unchanged diff.md=conflict" {
+		t.Errorf("dry run: %s", got)
+	}
+	if read("new.md") != "" {
+		t.Error("dry run не должен писать файлы")
+	}
+	if got := status(Import(dir, pack, ConflictSkip, false)); got != "new.md=created same.md=unchanged diff.md=conflict" || read("diff.md") != "old" || read("new.md") != "new" {
+		t.Errorf("skip: %s", got)
+	}
+	res := Import(dir, pack, ConflictRename, false)
+	if got := status(res); got != "new.md=unchanged same.md=unchanged diff-2.md=renamed" || res[2].Original != "diff.md" || read("diff-2.md") != "incoming" || read("diff.md") != "old" {
+		t.Errorf("rename: %s", got)
+	}
+	if got := status(Import(dir, pack, ConflictOverwrite, false)); got != "new.md=unchanged same.md=unchanged diff.md=overwritten" || read("diff.md") != "incoming" {
+		t.Errorf("overwrite: %s", got)
+	}
+
+	list, err := ReadDir(dir, "admin")
+	if err != nil || len(list) != 4 || list[0].Filename != "diff-2.md" {
+		t.Errorf("ReadDir: %+v %v", list, err)
+	}
+	if _, err := ParseConflict("merge"); err == nil {
+		t.Error("ParseConflict: ожидалась ошибка")
+	}
+}
@@ -0,0 +1,124 @@
+// Package providerguide — руководства по подключению LLM-провайдеров и
+// подсказки к ошибкам подключения.
+//
+// Тексты лежат в YAML-файлах guides/<язык>/<провайдер>.yaml, встроенных в
+// бинарник. Каталог PROVIDER_GUIDES_DIR с той же структурой переопределяет
+// их без пересборки: файлы читаются при каждом запросе, поэтому правка текста
+// видна в UI сразу. Заполненные поля файла из каталога заменяют встроенные.
+//
+// Поля собираются слоями: default.yaml, затем файл провайдера; в каждом слое
+// сначала русский текст (исходный язык), поверх него — язык запроса. Так
+// провайдер без перевода или без подсказки получает русский текст или общую
+// подсказку, а не пустое поле.
+package providerguide
+
+import (
+	"embed"
+	"errors"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+
+	"gopkg.in/yaml.v3"
+)
+
+//go:embed guides
+var embedded embed.FS
+
+// Default — общее руководство для провайдеров без своего файла.
+const Default = "default"
+
+// sourceLang — язык, на котором написаны все встроенные тексты.
+const sourceLang = "ru"
+
+// Guide — руководство по провайдеру для UI: подсказка к ошибке подключения и
+// пошаговые инструкции (подключение, выбор модели, оплата, проверка баланса).
+type Guide struct {
+	Hint         string `yaml:"hint" json:"hint,omitempty"`
+	HowToConnect string `yaml:"how_to_connect" json:"how_to_connect"`
+	HowToChoose  string `yaml:"how_to_choose" json:"how_to_choose"`
+	HowToPay     string `yaml:"how_to_pay" json:"how_to_pay"`
+	HowToBalance string `yaml:"how_to_balance" json:"how_to_balance"`
+}
+
+// overlay — заполненные поля o заменяют поля g.
+func (g *Guide) overlay(o Guide) {
+	for _, f := range []struct {
+		dst *string
+		src string
+	}{
+		{&g.Hint, o.Hint},
+		{&g.HowToConnect, o.HowToConnect},
+		{&g.HowToChoose, o.HowToChoose},
+		{&g.HowToPay, o.HowToPay},
+		{&g.HowToBalance, o.HowToBalance},
+	} {
+		if f.src != "" {
+			*f.dst = f.src
+		}
+	}
+}
+
+// nameRe — допустимое имя провайдера и языка (имя становится частью пути к файлу).
+var nameRe = regexp.MustCompile(`^[a-z0-9_-]+$`)
+
+// Load — руководство провайдера на языке lang; dir — каталог переопределений
+// ("" — только встроенные тексты). found — для провайдера есть свой файл;
+// иначе возвращается общее руководство.
+func Load(dir, provider, lang string) (guide Guide, found bool) {
+	if !nameRe.MatchString(lang) {
+		lang = sourceLang
+	}
+	langs := []string{sourceLang}
+	if lang != sourceLang {
+		langs = append(langs, lang)
+	}
+	names := []string{Default}
+	if provider != Default && nameRe.MatchString(provider) {
+		names = append(names, provider)
+	}
+	for _, name := range names {
+		for _, l := range langs {
+			for _, fsys := range sources(dir) {
+				g, ok := read(fsys, l+"/"+name+".yaml")
+				if !ok {
+					continue
+				}
+				guide.overlay(g)
+				if name != Default {
+					found = true
+				}
+			}
+		}
+	}
+	return guide, found
+}
+
+// sources — встроенные тексты и каталог переопределений (по возрастанию приоритета).
+func sources(dir string) []fs.FS {
+	builtin, _ := fs.Sub(embedded, "guides")
+	if dir == "" {
+		return []fs.FS{builtin}
+	}
+	return []fs.FS{builtin, os.DirFS(dir)}
+}
+
+// read — файл руководства; отсутствующий файл пропускается молча,
+// некорректный — с предупреждением в логе.
+func read(fsys fs.FS, name string) (Guide, bool) {
+	var g Guide
+	data, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			slog.Warn("Не удалось прочитать руководство провайдера", slog.String("файл", filepath.FromSlash(name)), slog.String("ошибка", err.Error()))
+		}
+		return g, false
+	}
+	if err := yaml.Unmarshal(data, &g); err != nil {
+		slog.Warn("Некорректное руководство провайдера", slog.String("файл", filepath.FromSlash(name)), slog.String("ошибка", err.Error()))
+		return g, false
+	}
+	return g, true
+}
@@ -0,0 +1,55 @@
+package providerguide
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestLoadBuiltin — встроенные руководства на обоих языках, общее для неизвестного провайдера.
+func TestLoadBuiltin(t *testing.T) {
+	g, found := Load("", "openrouter", "ru")
+	if !found || !strings.Contains(g.HowToConnect, "Зарегистрируйтесь на https://openrouter.ai") || g.Hint == "" {
+		t.Fatalf("openrouter/ru: %+v", g)
+	}
+	g, _ = Load("", "openrouter", "en")
+	if !strings.HasPrefix(g.HowToConnect, "1. Sign up at https://openrouter.ai") || !strings.HasPrefix(g.Hint, "OpenRouter: enter") {
+		t.Errorf("openrouter/en: %+v", g)
+	}
+	// У routeway нет своей подсказки — берётся общая
+	if g, _ := Load("", "routeway", "en"); g.Hint != "Check the API key and connection settings." {
+		t.Errorf("routeway/en: подсказка %q", g.Hint)
+	}
+	g, found = Load("", "../config", "ru")
+	if found || g.HowToConnect != "Проверьте правильность API-ключа и параметров подключения." {
+		t.Errorf("неизвестный провайдер: %v %+v", found, g)
+	}
+}
+
+// TestLoadOverride — файл из каталога заменяет заполненные поля, язык без перевода — русский текст.
+func TestLoadOverride(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "ru"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "ru", "openai.yaml"), []byte("how_to_pay: Оплата через бухгалтерию\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "ru", "localai.yaml"), []byte("how_to_connect: Адрес http://localai:8080\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	g, _ := Load(dir, "openai", "ru")
+	if g.HowToPay != "Оплата через бухгалтерию" || !strings.Contains(g.HowToConnect, "platform.openai.com") {
+		t.Errorf("openai: %+v", g)
+	}
+	// Английский файл встроенный, русское переопределение его не трогает
+	if g, _ := Load(dir, "openai", "en"); strings.Contains(g.HowToPay, "бухгалтерию") {
+		t.Errorf("openai/en: %q", g.HowToPay)
+	}
+	g, found := Load(dir, "localai", "en")
+	if !found || g.HowToConnect != "Адрес http://localai:8080" || g.HowToChoose != "Choose a model that fits your task." {
+		t.Errorf("localai/en: %v %+v", found, g)
+	}
+}
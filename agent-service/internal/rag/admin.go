@@ -0,0 +1,356 @@
+package rag
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/neo-2022/openclaw-memory/agent-service/internal/httpclient"
+)
+
+// Collection — коллекция ChromaDB с документами базы знаний.
+const Collection = "rag_docs"
+
+// Метка коллекции базы знаний agent-service в её метаданных ChromaDB:
+// пересборка удаляет записи, которых нет в БД, поэтому она разрешена только
+// для Collection и коллекций с этой меткой.
+const (
+	OwnerKey = "owner"
+	Owner    = "agent-service-rag"
+)
+
+// chromaPage — сколько записей читать и отправлять за один запрос к ChromaDB.
+const chromaPage = 500
+
+// maxListed — сколько расхождений перечислять в отчёте сверки (число — всегда полное).
+const maxListed = 100
+
+// ErrChromaDisabled — CHROMA_URL не задан.
+var ErrChromaDisabled = errors.New("ChromaDB не настроен")
+
+// ErrForeignCollection — коллекция не принадлежит базе знаний agent-service.
+var ErrForeignCollection = errors.New("коллекция не принадлежит базе знаний agent-service")
+
+// CollectionInfo — коллекция ChromaDB.
+type CollectionInfo struct {
+	Name     string                 `json:"name"`
+	ID       string                 `json:"id,omitempty"`
+	Count    int                    `json:"count"`
+	Metadata map[string]interface{} `json:"metadata,omitempty"`
+}
+
+// ChromaEntry — запись коллекции: идентификатор и метаданные, по которым
+// она сопоставляется со строкой RagDocument.
+type ChromaEntry struct {
+	ID     string `json:"id"`
+	Title  string `json:"title,omitempty"`
+	Source string `json:"source,omitempty"`
+	DBID   uint   `json:"db_id,omitempty"` // 0 — запись добавлена без привязки к строке БД
+}
+
+// StoredDoc — документ таблицы RagDocument для сверки и пересборки коллекции.
+type StoredDoc struct {
+	DBID    uint   `json:"db_id"`
+	Title   string `json:"title"`
+	Source  string `json:"source,omitempty"`
+	Version int    `json:"version,omitempty"`
+	Content string `json:"-"`
+	// UpdatedAt, WorkspaceID — для метаданных фильтров поиска (см. Metadata)
+	UpdatedAt   time.Time `json:"-"`
+	WorkspaceID uint      `json:"-"`
+}
+
+// ChromaID — идентификатор записи ChromaDB для строки RagDocument.
+func ChromaID(dbID uint) string {
+	return "ragdoc-" + strconv.FormatUint(uint64(dbID), 10)
+}
+
+// chroma — запрос к HTTP API ChromaDB; out == nil — тело ответа не разбирается.
+func (d *DBRetriever) chroma(ctx context.Context, method, path string, in, out interface{}) error {
+	if d.chromaURL == "" {
+		return ErrChromaDisabled
+	}
+	var body io.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, fmt.Sprintf("%s/api/%s%s", d.chromaURL, d.chromaAPIVer, path), body)
+	if err != nil {
+		return err
+	}
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := httpclient.New("chroma", 0).Do(req)
+	if err != nil {
+		return fmt.Errorf("ChromaDB недоступен: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("ChromaDB %s %s: HTTP %d: %s", method, path, resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// collectionPath — путь API коллекции name с действием action (/get, /upsert...).
+func collectionPath(name, action string) string {
+	return "/collections/" + url.PathEscape(name) + action
+}
+
+// Collections — коллекции ChromaDB с числом записей.
+func (d *DBRetriever) Collections(ctx context.Context) ([]CollectionInfo, error) {
+	var list []CollectionInfo
+	if err := d.chroma(ctx, http.MethodGet, "/collections", nil, &list); err != nil {
+		return nil, err
+	}
+	for i := range list {
+		if err := d.chroma(ctx, http.MethodGet, collectionPath(list[i].Name, "/count"), nil, &list[i].Count); err != nil {
+			return nil, err
+		}
+	}
+	return list, nil
+}
+
+// Entries — все записи коллекции (без текста и эмбеддингов), постранично.
+func (d *DBRetriever) Entries(ctx context.Context, collection string) ([]ChromaEntry, error) {
+	var out []ChromaEntry
+	for offset := 0; ; offset += chromaPage {
+		var page struct {
+			IDs       []string                 `json:"ids"`
+			Metadatas []map[string]interface{} `json:"metadatas"`
+		}
+		req := map[string]interface{}{"limit": chromaPage, "offset": offset, "include": []string{"metadatas"}}
+		if err := d.chroma(ctx, http.MethodPost, collectionPath(collection, "/get"), req, &page); err != nil {
+			return nil, err
+		}
+		for i, id := range page.IDs {
+			e := ChromaEntry{ID: id}
+			if i < len(page.Metadatas) {
+				meta := page.Metadatas[i]
+				e.Title, _ = meta["title"].(string)
+				e.Source, _ = meta["source"].(string)
+				if n, ok := meta["db_id"].(float64); ok && n > 0 {
+					e.DBID = uint(n)
+				}
+			}
+			out = append(out, e)
+		}
+		if len(page.IDs) < chromaPage {
+			return out, nil
+		}
+	}
+}
+
+// Consistency — итог сверки таблицы RagDocument с коллекцией ChromaDB.
+type Consistency struct {
+	Collection  string        `json:"collection"`
+	DBCount     int           `json:"db_count"`
+	ChromaCount int           `json:"chroma_count"`
+	Matched     int           `json:"matched"`
+	Missing     int           `json:"missing"`                    // Строк БД без записи в ChromaDB
+	Orphaned    int           `json:"orphaned"`                   // Записей ChromaDB без строки БД (удалённые документы)
+	Duplicates  int           `json:"duplicates"`                 // Лишних записей одной строки БД
+	Unlinked    int           `json:"unlinked"`                   // Записей без db_id, сопоставленных по названию и источнику
+	MissingDocs []StoredDoc   `json:"missing_docs,omitempty"`     // Первые maxListed
+	OrphanedIDs []ChromaEntry `json:"orphaned_entries,omitempty"` // Первые maxListed
+	Consistent  bool          `json:"consistent"`
+}
+
+// Verify — сопоставляет строки БД с записями коллекции. Запись с db_id
+// относится к своей строке; записи без db_id (добавленные до пересборки)
+// сопоставляются по паре название + источник, по одной на строку.
+func Verify(collection string, docs []StoredDoc, entries []ChromaEntry) Consistency {
+	rep := Consistency{Collection: collection, DBCount: len(docs), ChromaCount: len(entries)}
+	byID := make(map[uint]int, len(entries))
+	legacy := map[string][]int{} // Название + источник → индексы записей без db_id
+	for i, e := range entries {
+		if e.DBID > 0 {
+			byID[e.DBID]++
+		} else {
+			key := e.Title + "\x00" + e.Source
+			legacy[key] = append(legacy[key], i)
+		}
+	}
+	known := make(map[uint]bool, len(docs))
+	used := make(map[int]bool)
+	for _, doc := range docs {
+		known[doc.DBID] = true
+		if n := byID[doc.DBID]; n > 0 {
+			rep.Matched++
+			rep.Duplicates += n - 1
+			continue
+		}
+		key := doc.Title + "\x00" + doc.Source
+		if l := legacy[key]; len(l) > 0 {
+			used[l[0]] = true
+			legacy[key] = l[1:]
+			rep.Matched++
+			rep.Unlinked++
+			continue
+		}
+		rep.Missing++
+		if len(rep.MissingDocs) < maxListed {
+			rep.MissingDocs = append(rep.MissingDocs, doc)
+		}
+	}
+	for i, e := range entries {
+		if (e.DBID > 0 && known[e.DBID]) || (e.DBID == 0 && used[i]) {
+			continue
+		}
+		rep.Orphaned++
+		if len(rep.OrphanedIDs) < maxListed {
+			rep.OrphanedIDs = append(rep.OrphanedIDs, e)
+		}
+	}
+	rep.Consistent = rep.Missing == 0 && rep.Orphaned == 0 && rep.Duplicates == 0
+	return rep
+}
+
+// RebuildResult — итог пересборки коллекции.
+type RebuildResult struct {
+	Collection string `json:"collection"`
+	Indexed    int    `json:"indexed"` // Записей добавлено или обновлено
+	Removed    int    `json:"removed"` // Лишних записей удалено
+}
+
+// Rebuild — приводит коллекцию к содержимому таблицы: для каждой строки
+// заново считается эмбеддинг и запись ragdoc-<id> обновляется (upsert),
+// затем удаляются записи, которых нет среди строк. Коллекция не
+// пересоздаётся, поэтому поиск работает и во время пересборки.
+func (d *DBRetriever) Rebuild(ctx context.Context, collection string, docs []StoredDoc) (RebuildResult, error) {
+	res := RebuildResult{Collection: collection}
+	if err := d.CheckOwned(ctx, collection); err != nil {
+		return res, err
+	}
+	create := map[string]interface{}{"name": collection, "get_or_create": true, "metadata": map[string]interface{}{OwnerKey: Owner}}
+	if err := d.chroma(ctx, http.MethodPost, "/collections", create, nil); err != nil {
+		return res, err
+	}
+	keep := make(map[string]bool, len(docs))
+	for start := 0; start < len(docs); start += chromaPage {
+		batch := docs[start:min(start+chromaPage, len(docs))]
+		ids := make([]string, 0, len(batch))
+		embs := make([][]float64, 0, len(batch))
+		metas := make([]map[string]interface{}, 0, len(batch))
+		texts := make([]string, 0, len(batch))
+		for _, doc := range batch {
+			if err := ctx.Err(); err != nil {
+				return res, err
+			}
+			emb, err := d.embedding.Compute(doc.Content)
+			if err != nil {
+				return res, fmt.Errorf("эмбеддинг документа %d: %w", doc.DBID, err)
+			}
+			id := ChromaID(doc.DBID)
+			keep[id] = true
+			ids = append(ids, id)
+			embs = append(embs, emb)
+			metas = append(metas, Metadata(RagDoc{Title: doc.Title, Source: doc.Source, DBID: doc.DBID, Version: doc.Version, CreatedAt: doc.UpdatedAt, WorkspaceID: doc.WorkspaceID}))
+			texts = append(texts, doc.Content)
+		}
+		req := map[string]interface{}{"ids": ids, "embeddings": embs, "metadatas": metas, "documents": texts}
+		if err := d.chroma(ctx, http.MethodPost, collectionPath(collection, "/upsert"), req, nil); err != nil {
+			return res, err
+		}
+		res.Indexed += len(ids)
+	}
+
+	entries, err := d.Entries(ctx, collection)
+	if err != nil {
+		return res, err
+	}
+	var stale []string
+	for _, e := range entries {
+		if !keep[e.ID] {
+			stale = append(stale, e.ID)
+		}
+	}
+	res.Removed, err = d.deleteEntries(ctx, collection, stale)
+	return res, err
+}
+
+// CheckOwned — nil, если коллекцию можно пересобирать: это Collection или
+// существующая коллекция с меткой Owner; иначе ErrForeignCollection.
+func (d *DBRetriever) CheckOwned(ctx context.Context, collection string) error {
+	if collection == Collection {
+		return nil
+	}
+	var list []CollectionInfo
+	if err := d.chroma(ctx, http.MethodGet, "/collections", nil, &list); err != nil {
+		return err
+	}
+	for _, c := range list {
+		if c.Name == collection && c.Metadata[OwnerKey] == Owner {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: %s (нет метаданных %s=%s)", ErrForeignCollection, collection, OwnerKey, Owner)
+}
+
+// DeleteDocs — удаляет из коллекции записи документов docs: по db_id, а
+// записи без него — по названию и источнику. Возвращает число удалённых.
+func (d *DBRetriever) DeleteDocs(ctx context.Context, collection string, docs []StoredDoc) (int, error) {
+	if len(docs) == 0 {
+		return 0, nil
+	}
+	ids := make(map[uint]bool, len(docs))
+	keys := make(map[string]bool, len(docs))
+	for _, doc := range docs {
+		ids[doc.DBID] = true
+		keys[doc.Title+"\x00"+doc.Source] = true
+	}
+	entries, err := d.Entries(ctx, collection)
+	if err != nil {
+		return 0, err
+	}
+	var stale []string
+	for _, e := range entries {
+		if (e.DBID > 0 && ids[e.DBID]) || (e.DBID == 0 && keys[e.Title+"\x00"+e.Source]) {
+			stale = append(stale, e.ID)
+		}
+	}
+	return d.deleteEntries(ctx, collection, stale)
+}
+
+// Purge — удаляет все записи коллекции (сама коллекция остаётся).
+func (d *DBRetriever) Purge(ctx context.Context, collection string) (int, error) {
+	entries, err := d.Entries(ctx, collection)
+	if err != nil {
+		return 0, err
+	}
+	ids := make([]string, len(entries))
+	for i, e := range entries {
+		ids[i] = e.ID
+	}
+	return d.deleteEntries(ctx, collection, ids)
+}
+
+// deleteEntries — удаление записей по идентификаторам пачками; возвращает
+// число удалённых до ошибки.
+func (d *DBRetriever) deleteEntries(ctx context.Context, collection string, ids []string) (int, error) {
+	removed := 0
+	for start := 0; start < len(ids); start += chromaPage {
+		batch := ids[start:min(start+chromaPage, len(ids))]
+		if err := d.chroma(ctx, http.MethodPost, collectionPath(collection, "/delete"), map[string]interface{}{"ids": batch}, nil); err != nil {
+			return removed, err
+		}
+		removed += len(batch)
+	}
+	return removed, nil
+}
@@ -0,0 +1,224 @@
+package rag
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeChroma — коллекции ChromaDB в памяти: id → метаданные.
+type fakeChroma struct {
+	mu          sync.Mutex
+	collections map[string]map[string]map[string]interface{}
+	meta        map[string]map[string]interface{} // Метаданные коллекций
+	where       map[string]interface{}            // Условие последнего запроса query
+}
+
+func (f *fakeChroma) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	path := strings.TrimPrefix(r.URL.EscapedPath(), "/api/v2/collections")
+	var req struct {
+		Name      string                   `json:"name"`
+		Metadata  map[string]interface{}   `json:"metadata"`
+		IDs       []string                 `json:"ids"`
+		Metadatas []map[string]interface{} `json:"metadatas"`
+		Limit     int                      `json:"limit"`
+		Offset    int                      `json:"offset"`
+		NResults  int                      `json:"n_results"`
+		Where     map[string]interface{}   `json:"where"`
+	}
+	json.NewDecoder(r.Body).Decode(&req)
+	if path == "" {
+		if r.Method == http.MethodPost {
+			if f.collections[req.Name] == nil {
+				f.collections[req.Name] = map[string]map[string]interface{}{}
+				if f.meta == nil {
+					f.meta = map[string]map[string]interface{}{}
+				}
+				f.meta[req.Name] = req.Metadata
+			}
+			return
+		}
+		var out []map[string]interface{}
+		for name := range f.collections {
+			out = append(out, map[string]interface{}{"name": name, "metadata": f.meta[name]})
+		}
+		json.NewEncoder(w).Encode(out)
+		return
+	}
+	name, action, _ := strings.Cut(strings.TrimPrefix(path, "/"), "/")
+	name, _ = url.PathUnescape(name)
+	coll := f.collections[name]
+	if coll == nil {
+		http.Error(w, "нет коллекции", http.StatusNotFound)
+		return
+	}
+	switch action {
+	case "count":
+		json.NewEncoder(w).Encode(len(coll))
+	case "upsert":
+		for i, id := range req.IDs {
+			coll[id] = req.Metadatas[i]
+		}
+	case "delete":
+		for _, id := range req.IDs {
+			delete(coll, id)
+		}
+	case "query":
+		f.where = req.Where
+		// Ближе — записи с меньшим id; текст записи — её название
+		ids := make([]string, 0, len(coll))
+		for id := range coll {
+			ids = append(ids, id)
+		}
+		sort.Strings(ids)
+		ids = ids[:min(req.NResults, len(ids))]
+		docs, metas, dists := make([]*string, len(ids)), make([]map[string]interface{}, len(ids)), make([]float64, len(ids))
+		for i, id := range ids {
+			title, _ := coll[id]["title"].(string)
+			docs[i], metas[i], dists[i] = &title, coll[id], float64(i)/10
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"ids": [][]string{ids}, "documents": [][]*string{docs}, "metadatas": [][]map[string]interface{}{metas}, "distances": [][]float64{dists},
+		})
+	case "get":
+		ids := make([]string, 0, len(coll))
+		for id := range coll {
+			ids = append(ids, id)
+		}
+		sort.Strings(ids)
+		ids = ids[min(req.Offset, len(ids)):min(req.Offset+req.Limit, len(ids))]
+		metas := make([]map[string]interface{}, len(ids))
+		for i, id := range ids {
+			metas[i] = coll[id]
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"ids": ids, "metadatas": metas})
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// TestVerify — строки сопоставляются по db_id, старые записи — по названию
+// и источнику; лишние записи и дубли делают коллекцию несогласованной.
+func TestVerify(t *testing.T) {
+	docs := []StoredDoc{{DBID: 1, Title: "a.md"}, {DBID: 2, Title: "b.md", Source: "folder:/docs"}, {DBID: 3, Title: "c.md"}}
+	entries := []ChromaEntry{
+		{ID: "ragdoc-1", DBID: 1},
+		{ID: "doc-111", Title: "b.md", Source: "folder:/docs"},
+		{ID: "doc-222", Title: "b.md", Source: "folder:/docs"}, // та же строка второй раз
+		{ID: "ragdoc-9", DBID: 9},                              // строка удалена из БД
+	}
+	rep := Verify(Collection, docs, entries)
+	if rep.Matched != 2 || rep.Unlinked != 1 || rep.Missing != 1 || rep.MissingDocs[0].DBID != 3 || rep.Orphaned != 2 || rep.Consistent {
+		t.Errorf("сверка: %+v", rep)
+	}
+	if rep.OrphanedIDs[0].ID != "doc-222" || rep.OrphanedIDs[1].ID != "ragdoc-9" {
+		t.Errorf("лишние записи: %+v", rep.OrphanedIDs)
+	}
+	if rep := Verify(Collection, docs[:1], entries[:1]); !rep.Consistent {
+		t.Errorf("согласованная коллекция: %+v", rep)
+	}
+}
+
+// TestRebuild — пересборка добавляет строки БД с db_id, удаляет лишние
+// записи, и после неё сверка не находит расхождений.
+func TestRebuild(t *testing.T) {
+	chroma := &fakeChroma{collections: map[string]map[string]map[string]interface{}{
+		Collection: {"doc-1": {"title": "old.md"}, "ragdoc-1": {"title": "a.md", "db_id": 1}},
+	}}
+	srv := httptest.NewServer(chroma)
+	defer srv.Close()
+	d := NewDBRetriever(&Config{ChromaURL: srv.URL})
+	d.chromaAPIVer = "v2"
+
+	var docs []StoredDoc
+	for i := 1; i <= chromaPage+2; i++ {
+		docs = append(docs, StoredDoc{DBID: uint(i), Title: "doc.md", Content: strings.Repeat("x", i)})
+	}
+	res, err := d.Rebuild(context.Background(), Collection, docs)
+	if err != nil || res.Indexed != len(docs) || res.Removed != 1 {
+		t.Fatalf("пересборка: %+v, %v", res, err)
+	}
+	entries, err := d.Entries(context.Background(), Collection)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rep := Verify(Collection, docs, entries); !rep.Consistent || rep.Unlinked != 0 {
+		t.Errorf("после пересборки: %+v", rep)
+	}
+
+	list, err := d.Collections(context.Background())
+	if err != nil || len(list) != 1 || list[0].Count != len(docs) {
+		t.Errorf("коллекции: %+v, %v", list, err)
+	}
+	if _, err := NewDBRetriever(&Config{}).Collections(context.Background()); err != ErrChromaDisabled {
+		t.Errorf("без CHROMA_URL: %v", err)
+	}
+}
+
+// TestRebuild_ForeignCollection — чужие коллекции (без метки Owner) не
+// пересобираются и не очищаются; имя коллекции экранируется в пути.
+func TestRebuild_ForeignCollection(t *testing.T) {
+	chroma := &fakeChroma{
+		collections: map[string]map[string]map[string]interface{}{
+			"memory": {"m-1": {"text": "чужая запись"}},
+			"a/b":    {},
+		},
+		meta: map[string]map[string]interface{}{"a/b": {OwnerKey: Owner}},
+	}
+	srv := httptest.NewServer(chroma)
+	defer srv.Close()
+	d := NewDBRetriever(&Config{ChromaURL: srv.URL})
+	d.chromaAPIVer = "v2"
+
+	docs := []StoredDoc{{DBID: 1, Title: "a.md", Content: "x"}}
+	for _, name := range []string{"memory", "missing"} {
+		if _, err := d.Rebuild(context.Background(), name, docs); !errors.Is(err, ErrForeignCollection) {
+			t.Errorf("%s: ожидалась ErrForeignCollection, получено %v", name, err)
+		}
+	}
+	if len(chroma.collections["memory"]) != 1 || chroma.collections["missing"] != nil {
+		t.Fatalf("чужие коллекции изменены: %v", chroma.collections)
+	}
+	if res, err := d.Rebuild(context.Background(), "a/b", docs); err != nil || res.Indexed != 1 || len(chroma.collections["a/b"]) != 1 {
+		t.Errorf("коллекция с меткой: %+v, %v", res, err)
+	}
+}
+
+// TestDeleteDocs — удаляются записи удалённых строк (по db_id и по названию
+// с источником у старых записей), остальные остаются; Purge очищает коллекцию.
+func TestDeleteDocs(t *testing.T) {
+	chroma := &fakeChroma{collections: map[string]map[string]map[string]interface{}{
+		Collection: {
+			"ragdoc-1": {"title": "a.md", "source": "folder:/docs", "db_id": 1},
+			"ragdoc-2": {"title": "b.md", "source": "folder:/other", "db_id": 2},
+			"doc-1":    {"title": "c.md", "source": "folder:/docs"},
+			"doc-2":    {"title": "c.md", "source": "upload"},
+		},
+	}}
+	srv := httptest.NewServer(chroma)
+	defer srv.Close()
+	d := NewDBRetriever(&Config{ChromaURL: srv.URL})
+	d.chromaAPIVer = "v2"
+
+	docs := []StoredDoc{{DBID: 1, Title: "a.md", Source: "folder:/docs"}, {DBID: 3, Title: "c.md", Source: "folder:/docs"}}
+	n, err := d.DeleteDocs(context.Background(), Collection, docs)
+	if err != nil || n != 2 {
+		t.Fatalf("DeleteDocs: %d, %v", n, err)
+	}
+	coll := chroma.collections[Collection]
+	if len(coll) != 2 || coll["ragdoc-2"] == nil || coll["doc-2"] == nil {
+		t.Errorf("осталось: %v", coll)
+	}
+	if n, err := d.Purge(context.Background(), Collection); err != nil || n != 2 || len(coll) != 0 {
+		t.Errorf("Purge: %d, %v, %v", n, err, coll)
+	}
+}
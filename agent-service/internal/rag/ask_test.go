@@ -0,0 +1,49 @@
+package rag
+
+import (
+	"context"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// TestQuery — результаты с текстом, метаданными и оценкой из ответа ChromaDB.
+func TestQuery(t *testing.T) {
+	chroma := &fakeChroma{collections: map[string]map[string]map[string]interface{}{
+		Collection: {
+			"ragdoc-1": {"title": "Установка", "source": "folder:/docs", "db_id": 1, "version": 2},
+			"ragdoc-2": {"title": "Настройка", "db_id": 2},
+			"ragdoc-3": {"title": "Обновление", "db_id": 3},
+		},
+	}}
+	srv := httptest.NewServer(chroma)
+	defer srv.Close()
+	d := NewDBRetriever(&Config{ChromaURL: srv.URL})
+	d.chromaAPIVer = "v2"
+
+	res, err := d.Query(context.Background(), "как установить", 2, Filter{})
+	if err != nil || len(res) != 2 {
+		t.Fatalf("Query: %+v, %v", res, err)
+	}
+	first := res[0]
+	if first.Doc.Content != "Установка" || first.Doc.Source != "folder:/docs" || first.Doc.DBID != 1 || first.Doc.Version != 2 || first.Score != 1 || res[1].Score != 0.9 {
+		t.Errorf("результат: %+v", res)
+	}
+}
+
+// TestCitations — ссылки [n] и [n, m] отмечают фрагменты, лишние номера пропускаются.
+func TestCitations(t *testing.T) {
+	results := []SearchResult{
+		{Doc: RagDoc{Title: "a.md", Content: "первый"}},
+		{Doc: RagDoc{Title: "b.md", Content: "второй"}},
+		{Doc: RagDoc{Title: "c.md", Content: "третий"}},
+	}
+	cites := Citations("Ставится командой make [1]. Настройки в config [1, 3], см. также [7].", results, 100)
+	if len(cites) != 3 || !cites[0].Cited || cites[1].Cited || !cites[2].Cited || cites[2].N != 3 {
+		t.Errorf("ссылки: %+v", cites)
+	}
+	msg := AskUserMessage("Как поставить?", results[:1])
+	if !strings.Contains(msg, "[1] a.md\nпервый") || !strings.HasSuffix(msg, "Вопрос: Как поставить?") {
+		t.Errorf("сообщение: %q", msg)
+	}
+}
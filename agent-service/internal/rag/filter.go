@@ -0,0 +1,105 @@
+package rag
+
+import (
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Filter — условия на метаданные записей при поиске. Пустые поля не
+// ограничивают, заданные объединяются через И. Условия передаются в ChromaDB
+// (where), поэтому topK отбирается среди подходящих записей, а не после.
+//
+// Записи, добавленные до появления метаданных ext, updated_at и
+// workspace_id, под такие условия не попадают — их дополняет
+// POST /rag/admin/rebuild.
+type Filter struct {
+	Collection  string    // Коллекция (по умолчанию Collection)
+	Sources     []string  // Точные источники, например folder:/docs/api
+	Extensions  []string  // Расширения названия: ".md" или "md"
+	Since       time.Time // Текущая версия загружена не раньше
+	Until       time.Time // и не позже
+	WorkspaceID uint      // Рабочее пространство (0 — любое)
+}
+
+// Empty — фильтр ничего не ограничивает (коллекция — не условие).
+func (f Filter) Empty() bool {
+	return len(f.Sources) == 0 && len(f.Extensions) == 0 && f.Since.IsZero() && f.Until.IsZero() && f.WorkspaceID == 0
+}
+
+// collection — коллекция поиска.
+func (f Filter) collection() string {
+	if f.Collection == "" {
+		return Collection
+	}
+	return f.Collection
+}
+
+// Where — условие where для запроса к ChromaDB; nil — без условий.
+func (f Filter) Where() map[string]interface{} {
+	var conds []map[string]interface{}
+	if len(f.Sources) > 0 {
+		conds = append(conds, map[string]interface{}{"source": map[string]interface{}{"$in": f.Sources}})
+	}
+	if len(f.Extensions) > 0 {
+		exts := make([]string, len(f.Extensions))
+		for i, e := range f.Extensions {
+			exts[i] = normExt(e)
+		}
+		conds = append(conds, map[string]interface{}{"ext": map[string]interface{}{"$in": exts}})
+	}
+	if !f.Since.IsZero() {
+		conds = append(conds, map[string]interface{}{"updated_at": map[string]interface{}{"$gte": f.Since.Unix()}})
+	}
+	if !f.Until.IsZero() {
+		conds = append(conds, map[string]interface{}{"updated_at": map[string]interface{}{"$lte": f.Until.Unix()}})
+	}
+	if f.WorkspaceID > 0 {
+		conds = append(conds, map[string]interface{}{"workspace_id": map[string]interface{}{"$eq": f.WorkspaceID}})
+	}
+	switch len(conds) {
+	case 0:
+		return nil
+	case 1:
+		return conds[0]
+	default:
+		return map[string]interface{}{"$and": conds}
+	}
+}
+
+// Ext — расширение названия документа в нижнем регистре с точкой (".md");
+// пусто, если расширения нет.
+func Ext(title string) string {
+	return strings.ToLower(filepath.Ext(title))
+}
+
+// normExt — расширение из фильтра в виде метаданных ext: "MD" → ".md".
+func normExt(e string) string {
+	e = strings.ToLower(strings.TrimSpace(e))
+	if e != "" && !strings.HasPrefix(e, ".") {
+		e = "." + e
+	}
+	return e
+}
+
+// Metadata — метаданные записи ChromaDB документа: db_id и version связывают
+// запись со строкой БД, ext, updated_at и workspace_id нужны фильтрам поиска.
+func Metadata(doc RagDoc) map[string]interface{} {
+	meta := map[string]interface{}{"title": doc.Title, "source": doc.Source}
+	if doc.DBID > 0 {
+		meta["db_id"] = doc.DBID
+	}
+	if doc.Version > 0 {
+		meta["version"] = doc.Version
+	}
+	if ext := Ext(doc.Title); ext != "" {
+		meta["ext"] = ext
+	}
+	if !doc.CreatedAt.IsZero() {
+		meta["updated_at"] = doc.CreatedAt.Unix()
+	}
+	if doc.WorkspaceID > 0 {
+		meta["workspace_id"] = doc.WorkspaceID
+	}
+	return meta
+}
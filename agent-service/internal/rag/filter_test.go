@@ -0,0 +1,47 @@
+package rag
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// TestFilterWhere — одно условие передаётся как есть, несколько — через $and;
+// расширения приводятся к виду метаданных ext.
+func TestFilterWhere(t *testing.T) {
+	if w := (Filter{Collection: "other"}).Where(); w != nil {
+		t.Errorf("без условий: %v", w)
+	}
+	one, _ := json.Marshal(Filter{Extensions: []string{"MD", ".go"}}.Where())
+	if string(one) != `{"ext":{"$in":[".md",".go"]}}` {
+		t.Errorf("одно условие: %s", one)
+	}
+	since := time.Unix(1700000000, 0)
+	all, _ := json.Marshal(Filter{Sources: []string{"folder:/docs/api"}, Since: since, WorkspaceID: 3}.Where())
+	want := `{"$and":[{"source":{"$in":["folder:/docs/api"]}},{"updated_at":{"$gte":1700000000}},{"workspace_id":{"$eq":3}}]}`
+	if string(all) != want {
+		t.Errorf("несколько условий:\n%s\n%s", all, want)
+	}
+	meta := Metadata(RagDoc{Title: "api/Auth.MD", Source: "folder:/docs", DBID: 5, CreatedAt: since})
+	if meta["ext"] != ".md" || meta["updated_at"] != int64(1700000000) || meta["db_id"] != uint(5) || meta["workspace_id"] != nil {
+		t.Errorf("метаданные: %v", meta)
+	}
+}
+
+// TestQueryFilter — фильтр уходит в ChromaDB в поле where запроса к коллекции.
+func TestQueryFilter(t *testing.T) {
+	chroma := &fakeChroma{collections: map[string]map[string]map[string]interface{}{"api": {"ragdoc-1": {"title": "a.md"}}}}
+	srv := httptest.NewServer(chroma)
+	defer srv.Close()
+	d := NewDBRetriever(&Config{ChromaURL: srv.URL})
+	d.chromaAPIVer = "v2"
+	res, err := d.Query(context.Background(), "токен", 3, Filter{Collection: "api", Extensions: []string{"md"}})
+	if err != nil || len(res) != 1 {
+		t.Fatalf("Query: %+v, %v", res, err)
+	}
+	if got, _ := json.Marshal(chroma.where); string(got) != `{"ext":{"$in":[".md"]}}` {
+		t.Errorf("where: %s", got)
+	}
+}
@@ -13,6 +13,7 @@
 package rag
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
 	"fmt"
@@ -22,10 +23,12 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 	"time"
 
 	_ "github.com/jackc/pgx/v5/stdlib"
 	"github.com/neo-2022/openclaw-memory/agent-service/internal/embeddings"
+	"github.com/neo-2022/openclaw-memory/agent-service/internal/httpclient"
 )
 
 // RagDoc — документ в RAG-системе.
@@ -37,6 +40,10 @@ type RagDoc struct {
 	Source    string    `json:"source"`
 	CreatedAt time.Time `json:"created_at"`
 	Embedding []float64 `json:"embedding,omitempty"`
+	DBID      uint      `json:"db_id,omitempty"`   // Строка RagDocument (0 — без привязки)
+	Version   int       `json:"version,omitempty"` // Версия документа (в индексе только текущая)
+	// WorkspaceID — рабочее пространство документа (0 — общий)
+	WorkspaceID uint `json:"workspace_id,omitempty"`
 }
 
 // SearchResult — результат поиска документа с оценкой релевантности и рангом.
@@ -108,6 +115,7 @@ func LimitContext(results []SearchResult, maxTotalLen int) []SearchResult {
 // DBRetriever — основной компонент RAG-системы.
 // Обеспечивает работу с ChromaDB и fallback-поиском документов.
 type DBRetriever struct {
+	mu           sync.RWMutex // Защищает лимиты поиска в config (меняются через SetLimits)
 	config       *Config
 	embedding    embeddings.EmbeddingModel
 	chromaURL    string
@@ -134,6 +142,27 @@ func (d *DBRetriever) Config() *Config {
 	return d.config
 }
 
+// EmbeddingDimension — размерность эмбеддингов, с которыми индексируются документы.
+func (d *DBRetriever) EmbeddingDimension() int {
+	return d.embedding.Dimension()
+}
+
+// SetLimits — меняет параметры поиска на лету (перезагрузка конфигурации).
+func (d *DBRetriever) SetLimits(topK, maxChunkLen, maxContextLen int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.config.TopK = topK
+	d.config.MaxChunkLen = maxChunkLen
+	d.config.MaxContextLen = maxContextLen
+}
+
+// limits — текущие параметры поиска: topK, длина фрагмента, длина контекста.
+func (d *DBRetriever) limits() (int, int, int) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.config.TopK, d.config.MaxChunkLen, d.config.MaxContextLen
+}
+
 // EnsureTable — создаёт таблицу rag_docs в PostgreSQL, если её нет.
 // Использует подключение через database/sql с параметрами из Config.
 func (d *DBRetriever) EnsureTable() error {
@@ -185,6 +214,8 @@ func (d *DBRetriever) EnsureTable() error {
 //  1. Если ChromaDB не настроен (chromaURL пуст) — метод завершает работу без ошибки.
 //  2. Вычисляет эмбеддинг по содержимому документа.
 //  3. Отправляет документ, эмбеддинг и метаданные в коллекцию rag_docs через HTTP API ChromaDB.
+//     Запись с тем же ID заменяется (upsert) — так новая версия документа
+//     вытесняет прежнюю из поиска.
 func (d *DBRetriever) AddDocument(doc RagDoc) error {
 	if d.chromaURL == "" {
 		fmt.Printf("[RAG] ChromA не настроен, документ %s не добавлен\n", doc.Title)
@@ -196,20 +227,19 @@ func (d *DBRetriever) AddDocument(doc RagDoc) error {
 		return fmt.Errorf("ошибка вычисления эмбеддинга: %w", err)
 	}
 
-	url := fmt.Sprintf("%s/api/%s/collections/rag_docs/add", d.chromaURL, d.chromaAPIVer)
+	meta := Metadata(doc)
+	url := fmt.Sprintf("%s/api/%s/collections/%s/upsert", d.chromaURL, d.chromaAPIVer, Collection)
 	body, _ := json.Marshal(map[string]interface{}{
 		"ids":        []string{doc.ID},
 		"embeddings": [][]float64{emb},
-		"metadatas": []map[string]interface{}{
-			{"title": doc.Title, "source": doc.Source},
-		},
-		"documents": []string{doc.Content},
+		"metadatas":  []map[string]interface{}{meta},
+		"documents":  []string{doc.Content},
 	})
 
 	req, _ := http.NewRequest("POST", url, strings.NewReader(string(body)))
 	req.Header.Set("Content-Type", "application/json")
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := httpclient.New("chroma", 0).Do(req)
 	if err != nil {
 		return fmt.Errorf("ошибка добавления в ChromA: %w", err)
 	}
@@ -339,8 +369,9 @@ func (d *DBRetriever) seedFromPath(path string) error {
 // Сначала пытается использовать ChromaDB, при неудаче — fallback.
 // Результаты обрезаются по MaxChunkLen и ограничиваются по MaxContextLen.
 func (d *DBRetriever) Search(query string, topK int) ([]SearchResult, error) {
+	defaultTopK, maxChunk, maxCtx := d.limits()
 	if topK <= 0 {
-		topK = d.config.TopK
+		topK = defaultTopK
 		if topK <= 0 {
 			topK = 5
 		}
@@ -367,12 +398,10 @@ func (d *DBRetriever) Search(query string, topK int) ([]SearchResult, error) {
 		return nil, err
 	}
 
-	maxChunk := d.config.MaxChunkLen
 	for i := range results {
 		results[i].Doc.Content = TruncateChunk(results[i].Doc.Content, maxChunk)
 	}
 
-	maxCtx := d.config.MaxContextLen
 	results = LimitContext(results, maxCtx)
 
 	return results, nil
@@ -381,69 +410,7 @@ func (d *DBRetriever) Search(query string, topK int) ([]SearchResult, error) {
 // searchChroma — выполняет поиск документов через HTTP API ChromaDB.
 // Отправляет эмбеддинг запроса и получает topK ближайших результатов.
 func (d *DBRetriever) searchChroma(query string, queryEmb []float64, topK int) ([]SearchResult, error) {
-	url := fmt.Sprintf("%s/api/%s/collections/rag_docs/query", d.chromaURL, d.chromaAPIVer)
-
-	body, _ := json.Marshal(map[string]interface{}{
-		"query_embeddings": [][]float64{queryEmb},
-		"n_results":        topK,
-	})
-
-	req, _ := http.NewRequest("POST", url, strings.NewReader(string(body)))
-	req.Header.Set("Content-Type", "application/json")
-
-	resp, err := http.DefaultClient.Do(req)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != 200 {
-		return nil, fmt.Errorf("ChromaDB вернул статус %d", resp.StatusCode)
-	}
-
-	var result map[string]interface{}
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return nil, err
-	}
-
-	// Парсим результаты
-	ids, _ := result["ids"].([][]interface{})
-	distances, _ := result["distances"].([][]interface{})
-	metadatas, _ := result["metadatas"].([][]interface{})
-
-	var results []SearchResult
-	for i := 0; i < len(ids) && i < topK; i++ {
-		score := 1.0
-		if len(distances) > 0 && i < len(distances[i]) {
-			if d, ok := distances[i][0].(float64); ok {
-				score = 1.0 - d
-			}
-		}
-
-		doc := RagDoc{
-			ID:     fmt.Sprintf("%v", ids[i][0]),
-			Source: "chroma",
-		}
-
-		if len(metadatas) > 0 && i < len(metadatas[i]) {
-			if m, ok := metadatas[i][0].(map[string]interface{}); ok {
-				if t, ok := m["title"].(string); ok {
-					doc.Title = t
-				}
-				if c, ok := m["content"].(string); ok {
-					doc.Content = c
-				}
-			}
-		}
-
-		results = append(results, SearchResult{
-			Doc:   doc,
-			Score: score,
-			Rank:  i + 1,
-		})
-	}
-
-	return results, nil
+	return d.query(context.Background(), queryEmb, topK, Filter{})
 }
 
 // searchFallback — имитация поиска для демо-режима (без ChromaDB).
@@ -524,9 +491,10 @@ func (d *DBRetriever) MultiHopSearch(query string, hops int) ([]SearchResult, er
 
 	var allResults []SearchResult
 	currentQuery := query
+	topK, _, _ := d.limits()
 
 	for h := 0; h < hops; h++ {
-		results, err := d.Search(currentQuery, d.config.TopK)
+		results, err := d.Search(currentQuery, topK)
 		if err != nil {
 			return allResults, err
 		}
@@ -554,8 +522,8 @@ func (d *DBRetriever) MultiHopSearch(query string, hops int) ([]SearchResult, er
 	}
 
 	// Ограничиваем топ-K после всех хопов
-	if len(deduped) > d.config.TopK {
-		deduped = deduped[:d.config.TopK]
+	if len(deduped) > topK {
+		deduped = deduped[:topK]
 	}
 
 	return deduped, nil
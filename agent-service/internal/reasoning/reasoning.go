@@ -0,0 +1,153 @@
+// Package reasoning — блоки размышлений reasoning-моделей в тексте ответа.
+//
+// Модели оборачивают размышления перед ответом в теги: <think> (deepseek-r1,
+// qwq, qwen3), <thinking>, <reasoning>, <scratchpad>, [THINK] (ministral) —
+// или пишут их в канал analysis формата harmony (gpt-oss). Split отделяет
+// размышления от ответа: ответ показывается пользователю и разбирается на
+// вызовы инструментов, размышления можно вернуть отдельно (поле reasoning).
+//
+// Учитываются незакрытые теги (генерация оборвалась — остаток считается
+// размышлениями), закрывающий тег без открывающего (открывающий подставил
+// шаблон промпта) и начало тега, обрезанное в конце ответа.
+package reasoning
+
+import "strings"
+
+// tags — пары тегов размышлений.
+var tags = [][2]string{
+	{"<think>", "</think>"},
+	{"<thinking>", "</thinking>"},
+	{"<reasoning>", "</reasoning>"},
+	{"<scratchpad>", "</scratchpad>"},
+	{"[think]", "[/think]"},
+}
+
+// Маркеры формата harmony (gpt-oss).
+const (
+	harmonyChannel = "<|channel|>"
+	harmonyMessage = "<|message|>"
+	harmonyFinal   = "final"
+)
+
+var harmonyEnds = []string{"<|end|>", "<|return|>", "<|call|>", "<|start|>"}
+
+// Split — ответ без размышлений и сами размышления (блоки через пустую строку).
+func Split(content string) (answer, thoughts string) {
+	if strings.Contains(content, harmonyChannel) {
+		return splitHarmony(content)
+	}
+	lower := asciiLower(content)
+	var ans, th []string
+
+	// Закрывающий тег раньше открывающего: начало ответа — размышления
+	for _, t := range tags {
+		closeAt := strings.Index(lower, t[1])
+		if closeAt < 0 {
+			continue
+		}
+		if openAt := strings.Index(lower, t[0]); openAt < 0 || closeAt < openAt {
+			th = append(th, content[:closeAt])
+			content, lower = content[closeAt+len(t[1]):], lower[closeAt+len(t[1]):]
+			break
+		}
+	}
+
+	for {
+		openAt, tag := -1, [2]string{}
+		for _, t := range tags {
+			if i := strings.Index(lower, t[0]); i >= 0 && (openAt < 0 || i < openAt) {
+				openAt, tag = i, t
+			}
+		}
+		if openAt < 0 {
+			ans = append(ans, content)
+			break
+		}
+		ans = append(ans, content[:openAt])
+		content, lower = content[openAt+len(tag[0]):], lower[openAt+len(tag[0]):]
+		closeAt := strings.Index(lower, tag[1])
+		if closeAt < 0 {
+			// Незакрытый тег — генерация оборвалась на размышлениях
+			th = append(th, content)
+			break
+		}
+		th = append(th, content[:closeAt])
+		content, lower = content[closeAt+len(tag[1]):], lower[closeAt+len(tag[1]):]
+	}
+	return trimPartialTag(strings.TrimSpace(strings.Join(ans, ""))), join(th)
+}
+
+// Strip — ответ без размышлений.
+func Strip(content string) string {
+	answer, _ := Split(content)
+	return answer
+}
+
+// splitHarmony — сообщения harmony: канал final — ответ, остальные
+// (analysis, commentary) — размышления. Текст вне каналов относится к ответу.
+func splitHarmony(content string) (string, string) {
+	var ans, th []string
+	parts := strings.Split(content, harmonyChannel)
+	ans = append(ans, stripHarmonyMarkers(parts[0]))
+	for _, part := range parts[1:] {
+		channel, text, ok := strings.Cut(part, harmonyMessage)
+		if !ok {
+			continue
+		}
+		for _, end := range harmonyEnds {
+			if i := strings.Index(text, end); i >= 0 {
+				text = text[:i]
+			}
+		}
+		if strings.HasPrefix(strings.TrimSpace(channel), harmonyFinal) {
+			ans = append(ans, text)
+		} else {
+			th = append(th, text)
+		}
+	}
+	return strings.TrimSpace(strings.Join(ans, "")), join(th)
+}
+
+// stripHarmonyMarkers — текст до первого канала без служебных маркеров.
+func stripHarmonyMarkers(s string) string {
+	if i := strings.Index(s, "<|"); i >= 0 {
+		return s[:i]
+	}
+	return s
+}
+
+// trimPartialTag — убирает начало открывающего тега, обрезанное в конце ответа
+// («Готово. <thi»).
+func trimPartialTag(s string) string {
+	lower := asciiLower(s)
+	for _, t := range tags {
+		for n := len(t[0]) - 1; n >= 3; n-- {
+			if strings.HasSuffix(lower, t[0][:n]) {
+				return strings.TrimSpace(s[:len(s)-n])
+			}
+		}
+	}
+	return s
+}
+
+func join(parts []string) string {
+	var out []string
+	for _, p := range parts {
+		if p = strings.TrimSpace(p); p != "" {
+			out = append(out, p)
+		}
+	}
+	return strings.Join(out, "\n\n")
+}
+
+// asciiLower — нижний регистр только для ASCII: длина и позиции байтов
+// совпадают с исходной строкой.
+func asciiLower(s string) string {
+	b := []byte(s)
+	for i, c := range b {
+		if c >= 'A' && c <= 'Z' {
+			b[i] = c + 'a' - 'A'
+		}
+	}
+	return string(b)
+}
@@ -0,0 +1,27 @@
+package reasoning
+
+import "testing"
+
+// TestSplit — размышления разных моделей отделяются от ответа.
+func TestSplit(t *testing.T) {
+	cases := []struct {
+		name, content, answer, thoughts string
+	}{
+		{"deepseek-r1", "<think>\nСчитаю 2+2\n</think>\n\nОтвет: 4", "Ответ: 4", "Считаю 2+2"},
+		{"ministral", "[THINK]план[/THINK]Готово", "Готово", "план"},
+		{"thinking", "<thinking>a</thinking>b<thinking>c</thinking>d", "bd", "a\n\nc"},
+		{"reasoning", "<Reasoning>шаги</Reasoning> итог", "итог", "шаги"},
+		{"scratchpad", "<scratchpad>черновик</scratchpad>Чистовик", "Чистовик", "черновик"},
+		{"no-open", "размышления без открывающего тега</think>Ответ", "Ответ", "размышления без открывающего тега"},
+		{"unclosed", "Начало. <think>генерация оборвалась", "Начало.", "генерация оборвалась"},
+		{"partial-open", "Готово. <thi", "Готово.", ""},
+		{"gpt-oss", "<|channel|>analysis<|message|>Нужно ответить кратко<|end|><|start|>assistant<|channel|>final<|message|>Привет!<|return|>", "Привет!", "Нужно ответить кратко"},
+		{"plain", "Обычный ответ с <b>разметкой</b> и a < b", "Обычный ответ с <b>разметкой</b> и a < b", ""},
+	}
+	for _, c := range cases {
+		answer, thoughts := Split(c.content)
+		if answer != c.answer || thoughts != c.thoughts {
+			t.Errorf("%s: %q / %q, ожидалось %q / %q", c.name, answer, thoughts, c.answer, c.thoughts)
+		}
+	}
+}
@@ -0,0 +1,539 @@
+// Package recipe — составные скилы из декларативных рецептов.
+//
+// Составной скил (LEGO-блок) выполняет цепочку инструментов за один вызов,
+// чтобы слабой модели не нужно было строить её самой. Встроенные блоки
+// (setup_git_automation, project_init) написаны на Go; рецепт описывает
+// такую же цепочку в YAML-файле каталога RECIPES_DIR и подхватывается при
+// запуске без изменения кода:
+//
+//	name: python_venv
+//	description: Создать виртуальное окружение Python и установить зависимости
+//	parameters:
+//	  - name: path
+//	    required: true
+//	    description: Каталог проекта
+//	  - name: requirements
+//	    default: requirements.txt
+//	steps:
+//	  - name: venv
+//	    tool: execute
+//	    args:
+//	      command: 'python3 -m venv {{shq .path}}/.venv'
+//	  - name: install
+//	    tool: execute
+//	    when: '{{ne .requirements ""}}'
+//	    retries: 2
+//	    retry_delay: 5s
+//	    args:
+//	      command: 'cd {{shq .path}} && .venv/bin/pip install -r {{shq .requirements}}'
+//	message: 'Окружение готово: {{.path}}/.venv'
+//
+// Строки в args, when и message — шаблоны text/template: параметры вызова
+// доступны как {{.имя}}, результаты выполненных шагов — как
+// {{.steps.имя_шага.поле}}. Функции: shq (кавычки для shell), default, join,
+// json. Шаг с ошибкой (ключ error или ненулевой returncode) повторяется
+// retries раз, затем рецепт останавливается (on_error: continue — идти дальше).
+package recipe
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/neo-2022/openclaw-memory/agent-service/internal/llm"
+	"github.com/neo-2022/openclaw-memory/agent-service/internal/progress"
+)
+
+// Лимиты рецепта.
+const (
+	MaxSteps          = 50
+	MaxRetries        = 5
+	DefaultRetryDelay = time.Second
+)
+
+// Поведение при ошибке шага.
+const (
+	OnErrorStop     = "stop"
+	OnErrorContinue = "continue"
+)
+
+var nameRe = regexp.MustCompile(`^[a-z][a-z0-9_]{0,63}$`)
+
+// Param — параметр рецепта (аргумент вызова модели).
+type Param struct {
+	Name        string      `yaml:"name" json:"name"`
+	Type        string      `yaml:"type" json:"type,omitempty"` // string (по умолчанию), number, integer, boolean, array
+	Required    bool        `yaml:"required" json:"required,omitempty"`
+	Default     interface{} `yaml:"default" json:"default,omitempty"`
+	Description string      `yaml:"description" json:"description,omitempty"`
+}
+
+// Step — шаг рецепта: вызов одного инструмента.
+type Step struct {
+	Name       string                 `yaml:"name" json:"name"`
+	Tool       string                 `yaml:"tool" json:"tool"`
+	Args       map[string]interface{} `yaml:"args" json:"args,omitempty"`
+	When       string                 `yaml:"when" json:"when,omitempty"`               // Шаблон условия: пусто, false, 0, no — шаг пропускается
+	Retries    int                    `yaml:"retries" json:"retries,omitempty"`         // Повторов после ошибки
+	RetryDelay time.Duration          `yaml:"retry_delay" json:"retry_delay,omitempty"` // Пауза между повторами (по умолчанию 1s)
+	OnError    string                 `yaml:"on_error" json:"on_error,omitempty"`       // stop (по умолчанию) | continue
+}
+
+// Recipe — составной скил из YAML-файла.
+type Recipe struct {
+	Name        string   `yaml:"name" json:"name"`
+	Description string   `yaml:"description" json:"description"`
+	Version     string   `yaml:"version" json:"version,omitempty"` // Версия автора рецепта (например, 1.2.0)
+	Author      string   `yaml:"author" json:"author,omitempty"`
+	Parameters  []Param  `yaml:"parameters" json:"parameters,omitempty"`
+	Agents      []string `yaml:"agents" json:"agents,omitempty"` // Кому доступен; пусто — всем агентам
+	Steps       []Step   `yaml:"steps" json:"steps"`
+	Message     string   `yaml:"message" json:"message,omitempty"` // Шаблон итогового сообщения
+
+	Source   string `yaml:"-" json:"source"`             // file — из RECIPES_DIR, import — через /skills/compound
+	File     string `yaml:"-" json:"file,omitempty"`     // Файл в RECIPES_DIR
+	Revision int    `yaml:"-" json:"revision,omitempty"` // Номер импортированной версии
+}
+
+// Источник рецепта.
+const (
+	SourceFile   = "file"
+	SourceImport = "import"
+)
+
+// MaxBytes — предел размера файла рецепта.
+const MaxBytes = 256 << 10
+
+// Parse — рецепт из YAML с проверкой (см. Validate). Неизвестные поля —
+// ошибка: опечатка в ключе (retry вместо retries) не должна молча менять поведение.
+func Parse(data []byte) (*Recipe, error) {
+	if len(data) > MaxBytes {
+		return nil, fmt.Errorf("рецепт больше %d КБ", MaxBytes>>10)
+	}
+	var r Recipe
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&r); err != nil {
+		return nil, err
+	}
+	if err := r.Validate(); err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// Validate — проверяет имя, шаги и шаблоны; безымянные шаги получают имена step1, step2...
+func (r *Recipe) Validate() error {
+	if !nameRe.MatchString(r.Name) {
+		return fmt.Errorf("name: %q, ожидается имя вида setup_project (латиница, цифры, _)", r.Name)
+	}
+	if strings.TrimSpace(r.Description) == "" {
+		return fmt.Errorf("%s: description обязателен — по нему модель выбирает скил", r.Name)
+	}
+	if len(r.Steps) == 0 || len(r.Steps) > MaxSteps {
+		return fmt.Errorf("%s: нужно от 1 до %d шагов", r.Name, MaxSteps)
+	}
+	params := map[string]bool{"steps": true}
+	for _, p := range r.Parameters {
+		if !nameRe.MatchString(p.Name) || params[p.Name] {
+			return fmt.Errorf("%s: некорректный или повторяющийся параметр %q", r.Name, p.Name)
+		}
+		switch p.Type {
+		case "", "string", "number", "integer", "boolean", "array":
+		default:
+			return fmt.Errorf("%s: параметр %s: тип %q не поддерживается", r.Name, p.Name, p.Type)
+		}
+		params[p.Name] = true
+	}
+	steps := map[string]bool{}
+	for i := range r.Steps {
+		s := &r.Steps[i]
+		if s.Name == "" {
+			s.Name = fmt.Sprintf("step%d", i+1)
+		}
+		if !nameRe.MatchString(s.Name) || steps[s.Name] {
+			return fmt.Errorf("%s: некорректное или повторяющееся имя шага %q", r.Name, s.Name)
+		}
+		steps[s.Name] = true
+		if s.Tool == "" {
+			return fmt.Errorf("%s: шаг %s без tool", r.Name, s.Name)
+		}
+		if s.Tool == r.Name {
+			return fmt.Errorf("%s: шаг %s вызывает сам рецепт", r.Name, s.Name)
+		}
+		if s.Retries < 0 || s.Retries > MaxRetries {
+			return fmt.Errorf("%s: шаг %s: retries от 0 до %d", r.Name, s.Name, MaxRetries)
+		}
+		switch s.OnError {
+		case "", OnErrorStop, OnErrorContinue:
+		default:
+			return fmt.Errorf("%s: шаг %s: on_error %q, ожидается stop или continue", r.Name, s.Name, s.OnError)
+		}
+		if err := checkTemplates(s.Args); err != nil {
+			return fmt.Errorf("%s: шаг %s: %w", r.Name, s.Name, err)
+		}
+		if _, err := parse(s.When); err != nil {
+			return fmt.Errorf("%s: шаг %s: when: %w", r.Name, s.Name, err)
+		}
+	}
+	if _, err := parse(r.Message); err != nil {
+		return fmt.Errorf("%s: message: %w", r.Name, err)
+	}
+	return nil
+}
+
+// Load — рецепты из *.yaml и *.yml каталога dir; файл с ошибкой пропускается,
+// ошибки возвращаются вместе. Нет каталога — нет рецептов.
+func Load(dir string) ([]*Recipe, error) {
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var out []*Recipe
+	var errs []string
+	seen := map[string]string{}
+	for _, e := range entries {
+		ext := strings.ToLower(filepath.Ext(e.Name()))
+		if e.IsDir() || (ext != ".yaml" && ext != ".yml") {
+			continue
+		}
+		path := filepath.Join(dir, e.Name())
+		data, err := os.ReadFile(path)
+		if err != nil {
+			errs = append(errs, err.Error())
+			continue
+		}
+		r, err := Parse(data)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", e.Name(), err))
+			continue
+		}
+		if prev, dup := seen[r.Name]; dup {
+			errs = append(errs, fmt.Sprintf("%s: рецепт %s уже задан в %s", e.Name(), r.Name, prev))
+			continue
+		}
+		seen[r.Name] = e.Name()
+		r.Source, r.File = SourceFile, e.Name()
+		out = append(out, r)
+	}
+	if len(errs) > 0 {
+		return out, fmt.Errorf("рецепты: %s", strings.Join(errs, "; "))
+	}
+	return out, nil
+}
+
+// CheckTools — все шаги вызывают известные инструменты.
+func (r *Recipe) CheckTools(known func(string) bool) error {
+	for _, s := range r.Steps {
+		if !known(s.Tool) {
+			return fmt.Errorf("%s: шаг %s: неизвестный инструмент %s", r.Name, s.Name, s.Tool)
+		}
+	}
+	return nil
+}
+
+// AvailableTo — доступен ли рецепт агенту по списку agents.
+func (r *Recipe) AvailableTo(agent string) bool {
+	if len(r.Agents) == 0 {
+		return true
+	}
+	for _, a := range r.Agents {
+		if strings.EqualFold(a, agent) {
+			return true
+		}
+	}
+	return false
+}
+
+// Tool — описание рецепта для модели (JSON Schema параметров).
+func (r *Recipe) Tool() llm.Tool {
+	props := map[string]any{}
+	required := []string{}
+	for _, p := range r.Parameters {
+		typ := p.Type
+		if typ == "" {
+			typ = "string"
+		}
+		prop := map[string]any{"type": typ, "description": p.Description}
+		if typ == "array" {
+			prop["items"] = map[string]any{"type": "string"}
+		}
+		if p.Default != nil {
+			prop["default"] = p.Default
+		}
+		props[p.Name] = prop
+		if p.Required {
+			required = append(required, p.Name)
+		}
+	}
+	tools := make([]string, len(r.Steps))
+	for i, s := range r.Steps {
+		tools[i] = s.Tool
+	}
+	return llm.Tool{
+		Type: "function",
+		Function: llm.FunctionDefinition{
+			Name:        r.Name,
+			Description: fmt.Sprintf("%s (составной скил: %s за один вызов)", r.Description, strings.Join(tools, " → ")),
+			Parameters:  map[string]any{"type": "object", "properties": props, "required": required},
+		},
+	}
+}
+
+// CallFunc — вызов инструмента шагом рецепта.
+type CallFunc func(ctx context.Context, tool string, args map[string]interface{}) map[string]interface{}
+
+// StepResult — итог шага в ответе рецепта.
+type StepResult struct {
+	Step     string                 `json:"step"`
+	Tool     string                 `json:"tool"`
+	Status   string                 `json:"status"` // ok | error | skipped
+	Attempts int                    `json:"attempts,omitempty"`
+	Error    string                 `json:"error,omitempty"`
+	Result   map[string]interface{} `json:"result,omitempty"`
+}
+
+// Run — выполняет шаги по порядку; результат — как у встроенных составных
+// скилов: success, message, steps (и error, если рецепт остановлен).
+func (r *Recipe) Run(ctx context.Context, args map[string]interface{}, call CallFunc) map[string]interface{} {
+	data := map[string]interface{}{}
+	for _, p := range r.Parameters {
+		v, ok := args[p.Name]
+		switch {
+		case ok && v != nil:
+			data[p.Name] = v
+		case p.Default != nil:
+			data[p.Name] = p.Default
+		case p.Required:
+			return map[string]interface{}{"error": fmt.Sprintf("%s: параметр %s обязателен", r.Name, p.Name)}
+		default:
+			data[p.Name] = ""
+		}
+	}
+	outputs := map[string]interface{}{}
+	data["steps"] = outputs
+
+	var results []StepResult
+	failed := false
+	for i, s := range r.Steps {
+		progress.Step(ctx, i+1, len(r.Steps), s.Name+": "+s.Tool)
+		res := StepResult{Step: s.Name, Tool: s.Tool}
+		if s.When != "" {
+			cond, err := render(s.When, data)
+			if err != nil {
+				res.Status, res.Error = "error", "when: "+err.Error()
+				results = append(results, res)
+				failed = true
+				break
+			}
+			if !truthy(cond) {
+				res.Status = "skipped"
+				results = append(results, res)
+				continue
+			}
+		}
+		stepArgs, err := renderValue(s.Args, data)
+		if err != nil {
+			res.Status, res.Error = "error", "args: "+err.Error()
+			results = append(results, res)
+			failed = true
+			break
+		}
+		argMap, _ := stepArgs.(map[string]interface{})
+		if argMap == nil {
+			argMap = map[string]interface{}{}
+		}
+		var out map[string]interface{}
+		var errText string
+		for attempt := 0; attempt <= s.Retries; attempt++ {
+			if attempt > 0 && !sleep(ctx, s.RetryDelay) {
+				break
+			}
+			res.Attempts = attempt + 1
+			out = call(ctx, s.Tool, argMap)
+			if errText = StepError(out); errText == "" {
+				break
+			}
+		}
+		outputs[s.Name] = out
+		res.Result = out
+		if errText == "" {
+			res.Status = "ok"
+			results = append(results, res)
+			continue
+		}
+		res.Status, res.Error = "error", errText
+		results = append(results, res)
+		if s.OnError != OnErrorContinue {
+			failed = true
+			break
+		}
+	}
+
+	resp := map[string]interface{}{"success": !failed, "recipe": r.Name, "steps": results, "steps_count": len(results)}
+	if failed {
+		last := results[len(results)-1]
+		resp["error"] = fmt.Sprintf("рецепт %s остановлен на шаге %s (%s): %s", r.Name, last.Step, last.Tool, last.Error)
+		return resp
+	}
+	msg := fmt.Sprintf("Рецепт %s выполнен: шагов %d", r.Name, len(results))
+	if r.Message != "" {
+		if m, err := render(r.Message, data); err == nil {
+			msg = m
+		}
+	}
+	resp["message"] = msg
+	return resp
+}
+
+// StepError — текст ошибки шага: ключ error результата или ненулевой
+// returncode команды; "" — шаг успешен.
+func StepError(out map[string]interface{}) string {
+	if out == nil {
+		return "пустой результат"
+	}
+	if e, ok := out["error"]; ok && e != nil && fmt.Sprint(e) != "" {
+		return fmt.Sprint(e)
+	}
+	if code, ok := out["returncode"].(float64); ok && code != 0 {
+		return fmt.Sprintf("команда завершилась с кодом %v", code)
+	}
+	if code, ok := out["returncode"].(int); ok && code != 0 {
+		return fmt.Sprintf("команда завершилась с кодом %d", code)
+	}
+	return ""
+}
+
+func sleep(ctx context.Context, d time.Duration) bool {
+	if d <= 0 {
+		d = DefaultRetryDelay
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-t.C:
+		return true
+	}
+}
+
+func truthy(s string) bool {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "", "false", "0", "no", "<no value>":
+		return false
+	}
+	return true
+}
+
+var funcs = template.FuncMap{
+	// shq — значение в одинарных кавычках для shell
+	"shq": func(v interface{}) string {
+		return "'" + strings.ReplaceAll(fmt.Sprint(v), "'", `'\''`) + "'"
+	},
+	"default": func(def, v interface{}) interface{} {
+		if v == nil || fmt.Sprint(v) == "" {
+			return def
+		}
+		return v
+	},
+	"join": func(sep string, v interface{}) string {
+		list, _ := v.([]interface{})
+		parts := make([]string, len(list))
+		for i, p := range list {
+			parts[i] = fmt.Sprint(p)
+		}
+		return strings.Join(parts, sep)
+	},
+	"json": func(v interface{}) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+}
+
+func parse(text string) (*template.Template, error) {
+	return template.New("").Funcs(funcs).Option("missingkey=error").Parse(text)
+}
+
+func render(text string, data map[string]interface{}) (string, error) {
+	if !strings.Contains(text, "{{") {
+		return text, nil
+	}
+	t, err := parse(text)
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	if err := t.Execute(&b, data); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// renderValue — аргументы шага с подставленными шаблонами во всех строках.
+func renderValue(v interface{}, data map[string]interface{}) (interface{}, error) {
+	switch x := v.(type) {
+	case string:
+		return render(x, data)
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(x))
+		for k, item := range x {
+			r, err := renderValue(item, data)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", k, err)
+			}
+			out[k] = r
+		}
+		return out, nil
+	case []interface{}:
+		out := make([]interface{}, len(x))
+		for i, item := range x {
+			r, err := renderValue(item, data)
+			if err != nil {
+				return nil, err
+			}
+			out[i] = r
+		}
+		return out, nil
+	}
+	return v, nil
+}
+
+func checkTemplates(v interface{}) error {
+	switch x := v.(type) {
+	case string:
+		_, err := parse(x)
+		return err
+	case map[string]interface{}:
+		keys := make([]string, 0, len(x))
+		for k := range x {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			if err := checkTemplates(x[k]); err != nil {
+				return fmt.Errorf("args.%s: %w", k, err)
+			}
+		}
+	case []interface{}:
+		for _, item := range x {
+			if err := checkTemplates(item); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
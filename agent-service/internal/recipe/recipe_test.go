@@ -0,0 +1,178 @@
+package recipe
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const venvRecipe = `
+name: python_venv
+description: Виртуальное окружение Python
+parameters:
+  - name: path
+    required: true
+  - name: requirements
+    default: requirements.txt
+steps:
+  - name: venv
+    tool: execute
+    args:
+      command: 'python3 -m venv {{shq .path}}/.venv'
+  - name: install
+    tool: execute
+    when: '{{ne .requirements ""}}'
+    retries: 1
+    retry_delay: 1ms
+    args:
+      command: 'pip install -r {{shq .requirements}} # {{.steps.venv.stdout}}'
+message: 'Готово: {{.path}}'
+`
+
+type call struct {
+	tool string
+	args map[string]interface{}
+}
+
+func TestRun(t *testing.T) {
+	r, err := Parse([]byte(venvRecipe))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var calls []call
+	fail := 1 // install падает один раз, затем проходит
+	out := r.Run(context.Background(), map[string]interface{}{"path": "/srv/my app"}, func(_ context.Context, tool string, args map[string]interface{}) map[string]interface{} {
+		calls = append(calls, call{tool, args})
+		if len(calls) > 1 && fail > 0 {
+			fail--
+			return map[string]interface{}{"stderr": "network", "returncode": float64(1)}
+		}
+		return map[string]interface{}{"stdout": "ok", "returncode": float64(0)}
+	})
+	if out["success"] != true || out["message"] != "Готово: /srv/my app" {
+		t.Fatalf("результат: %+v", out)
+	}
+	if len(calls) != 3 {
+		t.Fatalf("вызовов %d, ожидали 3 (повтор install)", len(calls))
+	}
+	if got := calls[0].args["command"]; got != `python3 -m venv '/srv/my app'/.venv` {
+		t.Errorf("команда venv: %q", got)
+	}
+	if got := calls[2].args["command"]; got != `pip install -r 'requirements.txt' # ok` {
+		t.Errorf("команда install: %q", got)
+	}
+	if steps := out["steps"].([]StepResult); steps[1].Attempts != 2 {
+		t.Errorf("попыток install: %+v", steps[1])
+	}
+}
+
+func TestRun_StopAndSkip(t *testing.T) {
+	r, err := Parse([]byte(venvRecipe))
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := 0
+	out := r.Run(context.Background(), map[string]interface{}{"path": "/p", "requirements": ""}, func(context.Context, string, map[string]interface{}) map[string]interface{} {
+		n++
+		return map[string]interface{}{"ok": true}
+	})
+	steps := out["steps"].([]StepResult)
+	if n != 1 || steps[1].Status != "skipped" || out["success"] != true {
+		t.Errorf("пустой requirements — install пропускается: %d вызовов, %+v", n, steps)
+	}
+
+	out = r.Run(context.Background(), map[string]interface{}{"path": "/p"}, func(context.Context, string, map[string]interface{}) map[string]interface{} {
+		return map[string]interface{}{"error": "нет python3"}
+	})
+	if out["success"] != false || !strings.Contains(out["error"].(string), "venv") || len(out["steps"].([]StepResult)) != 1 {
+		t.Errorf("ошибка шага останавливает рецепт: %+v", out)
+	}
+
+	if out := r.Run(context.Background(), nil, nil); out["error"] == nil {
+		t.Error("без обязательного параметра — ошибка")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	for name, src := range map[string]string{
+		"имя":         "name: Bad-Name\ndescription: x\nsteps: [{tool: read}]",
+		"описание":    "name: r\nsteps: [{tool: read}]",
+		"без шагов":   "name: r\ndescription: x",
+		"без tool":    "name: r\ndescription: x\nsteps: [{name: a}]",
+		"рекурсия":    "name: r\ndescription: x\nsteps: [{tool: r}]",
+		"шаблон":      "name: r\ndescription: x\nsteps: [{tool: read, args: {path: '{{.p'}}]",
+		"on_error":    "name: r\ndescription: x\nsteps: [{tool: read, on_error: retry}]",
+		"повтор шага": "name: r\ndescription: x\nsteps: [{name: a, tool: read}, {name: a, tool: list}]",
+		"лишний ключ": "name: r\ndescription: x\nsteps: [{tool: read, retry: 2}]",
+	} {
+		if _, err := Parse([]byte(src)); err == nil {
+			t.Errorf("%s: ожидалась ошибка", name)
+		}
+	}
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "venv.yaml"), []byte(venvRecipe), 0o644)
+	os.WriteFile(filepath.Join(dir, "broken.yml"), []byte("name: broken\n"), 0o644)
+	os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("не рецепт"), 0o644)
+	list, err := Load(dir)
+	if err == nil || !strings.Contains(err.Error(), "broken.yml") {
+		t.Errorf("ошибка в broken.yml: %v", err)
+	}
+	if len(list) != 1 || list[0].Name != "python_venv" || list[0].File != "venv.yaml" {
+		t.Fatalf("рецепты: %+v", list)
+	}
+	if list, err := Load(filepath.Join(dir, "нет")); list != nil || err != nil {
+		t.Errorf("нет каталога — нет рецептов: %v, %v", list, err)
+	}
+	tool := list[0].Tool()
+	if tool.Function.Name != "python_venv" || !strings.Contains(tool.Function.Description, "execute → execute") {
+		t.Errorf("описание инструмента: %+v", tool.Function)
+	}
+	if req := tool.Function.Parameters.(map[string]any)["required"].([]string); len(req) != 1 || req[0] != "path" {
+		t.Errorf("required: %v", req)
+	}
+}
+
+func TestRun_Cancelled(t *testing.T) {
+	r, err := Parse([]byte("name: r\ndescription: x\nsteps: [{tool: read, retries: 3, retry_delay: 1h}]"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	n := 0
+	out := r.Run(ctx, nil, func(context.Context, string, map[string]interface{}) map[string]interface{} {
+		n++
+		return map[string]interface{}{"error": "x"}
+	})
+	if n != 1 || out["success"] != false {
+		t.Errorf("отмена запроса прерывает паузу повтора: %d вызовов, %+v", n, out)
+	}
+}
+
+func TestRegistry(t *testing.T) {
+	all, _ := Parse([]byte("name: a\ndescription: x\nsteps: [{tool: read}]"))
+	coder, _ := Parse([]byte("name: b\ndescription: x\nagents: [coder]\nsteps: [{tool: read}]"))
+	g := NewRegistry()
+	g.Replace([]*Recipe{coder, all}, map[string]map[string]bool{"a": {"Admin": false}, "b": {"admin": true}})
+	if list := g.All(); len(list) != 2 || list[0].Name != "a" {
+		t.Fatalf("All: %+v", list)
+	}
+	if g.Enabled("a", "admin") || !g.Enabled("a", "coder") {
+		t.Error("переключатель агента отключает скил только ему")
+	}
+	if !g.Enabled("b", "admin") || g.Enabled("b", "viewer") {
+		t.Error("переключатель важнее списка agents рецепта")
+	}
+	if list := g.ForAgent("coder"); len(list) != 2 {
+		t.Errorf("ForAgent(coder): %+v", list)
+	}
+	if g.Enabled("нет", "admin") {
+		t.Error("неизвестный рецепт")
+	}
+}
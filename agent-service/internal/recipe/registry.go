@@ -0,0 +1,95 @@
+package recipe
+
+import (
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Registry — установленные рецепты и их включение по агентам; безопасен
+// для параллельных вызовов (рецепты меняются через API во время работы).
+type Registry struct {
+	mu      sync.RWMutex
+	recipes map[string]*Recipe
+	toggles map[string]map[string]bool // рецепт → агент (в нижнем регистре) → включён
+}
+
+// NewRegistry — пустой реестр.
+func NewRegistry() *Registry {
+	return &Registry{recipes: map[string]*Recipe{}, toggles: map[string]map[string]bool{}}
+}
+
+// Replace — заменяет набор рецептов и переключатели агентов целиком.
+func (g *Registry) Replace(list []*Recipe, toggles map[string]map[string]bool) {
+	recipes := make(map[string]*Recipe, len(list))
+	for _, r := range list {
+		recipes[r.Name] = r
+	}
+	norm := map[string]map[string]bool{}
+	for name, agents := range toggles {
+		norm[name] = map[string]bool{}
+		for agent, on := range agents {
+			norm[name][strings.ToLower(agent)] = on
+		}
+	}
+	g.mu.Lock()
+	g.recipes, g.toggles = recipes, norm
+	g.mu.Unlock()
+}
+
+// Get — рецепт по имени.
+func (g *Registry) Get(name string) (*Recipe, bool) {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	r, ok := g.recipes[name]
+	return r, ok
+}
+
+// All — все рецепты по имени.
+func (g *Registry) All() []*Recipe {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	out := make([]*Recipe, 0, len(g.recipes))
+	for _, r := range g.recipes {
+		out = append(out, r)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+// Enabled — доступен ли рецепт агенту: переключатель агента важнее списка
+// agents рецепта.
+func (g *Registry) Enabled(name, agent string) bool {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	r, ok := g.recipes[name]
+	if !ok {
+		return false
+	}
+	if on, set := g.toggles[name][strings.ToLower(agent)]; set {
+		return on
+	}
+	return r.AvailableTo(agent)
+}
+
+// Toggles — переключатели агентов рецепта (копия).
+func (g *Registry) Toggles(name string) map[string]bool {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	out := make(map[string]bool, len(g.toggles[name]))
+	for agent, on := range g.toggles[name] {
+		out[agent] = on
+	}
+	return out
+}
+
+// ForAgent — рецепты, доступные агенту, по имени.
+func (g *Registry) ForAgent(agent string) []*Recipe {
+	var out []*Recipe
+	for _, r := range g.All() {
+		if g.Enabled(r.Name, agent) {
+			out = append(out, r)
+		}
+	}
+	return out
+}
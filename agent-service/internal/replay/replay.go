@@ -0,0 +1,182 @@
+// Package replay — запись запроса /chat и его воспроизведение для отладки.
+//
+// Запись (Tape) — ответы модели на каждом шаге и результаты вызовов
+// инструментов по порядку. При воспроизведении запрос выполняется заново:
+// инструменты не вызываются, а отвечают записанными результатами, модель
+// может быть другой или тоже заменена записью. Полная замена (MockLLM)
+// детерминирована и проверяет изменения разбора ответов и промптов без
+// обращения к провайдерам.
+package replay
+
+import (
+	"encoding/json"
+	"errors"
+	"sync"
+
+	"github.com/neo-2022/openclaw-memory/agent-service/internal/llm"
+)
+
+// ErrTapeExhausted — модель вызвана больше раз, чем в записи.
+var ErrTapeExhausted = errors.New("replay: записанные ответы модели закончились")
+
+// Exchange — ответ модели на один вызов.
+type Exchange struct {
+	Content   string         `json:"content"`
+	ToolCalls []llm.ToolCall `json:"tool_calls,omitempty"`
+	Model     string         `json:"model,omitempty"`
+}
+
+// ToolCall — вызов инструмента и его результат.
+type ToolCall struct {
+	Name   string                 `json:"name"`
+	Args   map[string]interface{} `json:"args,omitempty"`
+	Result map[string]interface{} `json:"result"`
+}
+
+// Tape — запись одного запроса.
+type Tape struct {
+	Exchanges []Exchange `json:"exchanges"`
+	Tools     []ToolCall `json:"tools"`
+}
+
+// Recorder — собирает запись во время запроса; безопасен для параллельных вызовов.
+type Recorder struct {
+	mu   sync.Mutex
+	tape Tape
+}
+
+// RecordLLM — добавляет ответ модели. Безопасен для nil: запрос без записи
+// ничего не сохраняет.
+func (r *Recorder) RecordLLM(resp *llm.ChatResponse) {
+	if r == nil || resp == nil {
+		return
+	}
+	r.mu.Lock()
+	r.tape.Exchanges = append(r.tape.Exchanges, Exchange{Content: resp.Content, ToolCalls: resp.ToolCalls, Model: resp.Model})
+	r.mu.Unlock()
+}
+
+// RecordTool — добавляет вызов инструмента. Аргументы и результат
+// копируются через JSON: после записи их можно менять.
+func (r *Recorder) RecordTool(name string, args, result map[string]interface{}) {
+	if r == nil {
+		return
+	}
+	call := ToolCall{Name: name, Args: clone(args), Result: clone(result)}
+	r.mu.Lock()
+	r.tape.Tools = append(r.tape.Tools, call)
+	r.mu.Unlock()
+}
+
+// Tape — копия записи.
+func (r *Recorder) Tape() Tape {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return Tape{
+		Exchanges: append([]Exchange(nil), r.tape.Exchanges...),
+		Tools:     append([]ToolCall(nil), r.tape.Tools...),
+	}
+}
+
+// Stats — насколько воспроизведение совпало с записью.
+type Stats struct {
+	LLMReplayed int `json:"llm_replayed"`  // Ответов модели взято из записи
+	ToolsExact  int `json:"tools_exact"`   // Вызовов с теми же аргументами
+	ToolsByName int `json:"tools_by_name"` // Вызовов того же инструмента с другими аргументами
+	ToolsMissed int `json:"tools_missed"`  // Вызовов, которых нет в записи
+	ToolsUnused int `json:"tools_unused"`  // Записанных вызовов, которые не повторились
+}
+
+// Player — воспроизведение записи.
+type Player struct {
+	MockLLM bool // Ответы модели из записи вместо провайдера
+
+	mu      sync.Mutex
+	tape    Tape
+	nextLLM int
+	used    []bool
+	stats   Stats
+}
+
+// NewPlayer — воспроизведение tape; mockLLM — заменять и ответы модели.
+func NewPlayer(tape Tape, mockLLM bool) *Player {
+	return &Player{MockLLM: mockLLM, tape: tape, used: make([]bool, len(tape.Tools))}
+}
+
+// NextLLM — следующий записанный ответ модели.
+func (p *Player) NextLLM() (*llm.ChatResponse, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.nextLLM >= len(p.tape.Exchanges) {
+		return nil, ErrTapeExhausted
+	}
+	e := p.tape.Exchanges[p.nextLLM]
+	p.nextLLM++
+	p.stats.LLMReplayed++
+	return &llm.ChatResponse{Content: e.Content, ToolCalls: e.ToolCalls, Model: e.Model}, nil
+}
+
+// Tool — записанный результат вызова: сначала первый неиспользованный
+// вызов с теми же аргументами, затем — того же инструмента по порядку.
+// false — подходящего вызова в записи нет.
+func (p *Player) Tool(name string, args map[string]interface{}) (map[string]interface{}, bool) {
+	key := canonical(args)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	byName := -1
+	for i, call := range p.tape.Tools {
+		if p.used[i] || call.Name != name {
+			continue
+		}
+		if canonical(call.Args) == key {
+			p.used[i] = true
+			p.stats.ToolsExact++
+			return clone(call.Result), true
+		}
+		if byName < 0 {
+			byName = i
+		}
+	}
+	if byName < 0 {
+		p.stats.ToolsMissed++
+		return nil, false
+	}
+	p.used[byName] = true
+	p.stats.ToolsByName++
+	return clone(p.tape.Tools[byName].Result), true
+}
+
+// Stats — итог воспроизведения.
+func (p *Player) Stats() Stats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	s := p.stats
+	for _, u := range p.used {
+		if !u {
+			s.ToolsUnused++
+		}
+	}
+	return s
+}
+
+// canonical — аргументы в виде JSON с упорядоченными ключами.
+func canonical(args map[string]interface{}) string {
+	if len(args) == 0 {
+		return "{}"
+	}
+	data, _ := json.Marshal(args)
+	return string(data)
+}
+
+func clone(m map[string]interface{}) map[string]interface{} {
+	if m == nil {
+		return nil
+	}
+	data, err := json.Marshal(m)
+	if err != nil {
+		return nil
+	}
+	var out map[string]interface{}
+	json.Unmarshal(data, &out)
+	return out
+}
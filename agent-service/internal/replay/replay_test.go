@@ -0,0 +1,43 @@
+package replay
+
+import (
+	"testing"
+
+	"github.com/neo-2022/openclaw-memory/agent-service/internal/llm"
+)
+
+// TestRecordAndPlay — запись ответов модели и инструментов и их воспроизведение.
+func TestRecordAndPlay(t *testing.T) {
+	var r Recorder
+	r.RecordLLM(&llm.ChatResponse{Content: "", ToolCalls: []llm.ToolCall{{ID: "1", Function: llm.FunctionCall{Name: "read"}}}})
+	args := map[string]interface{}{"path": "/srv/a.go"}
+	result := map[string]interface{}{"content": "package a"}
+	r.RecordTool("read", args, result)
+	r.RecordTool("read", map[string]interface{}{"path": "/srv/b.go"}, map[string]interface{}{"content": "package b"})
+	r.RecordLLM(&llm.ChatResponse{Content: "готово"})
+	result["content"] = "изменено после записи"
+
+	p := NewPlayer(r.Tape(), true)
+	if resp, err := p.NextLLM(); err != nil || len(resp.ToolCalls) != 1 {
+		t.Fatalf("первый ответ: %+v %v", resp, err)
+	}
+	if got, ok := p.Tool("read", map[string]interface{}{"path": "/srv/b.go"}); !ok || got["content"] != "package b" {
+		t.Errorf("точное совпадение: %v %v", got, ok)
+	}
+	if got, ok := p.Tool("read", map[string]interface{}{"path": "/srv/c.go"}); !ok || got["content"] != "package a" {
+		t.Errorf("совпадение по имени: %v %v", got, ok)
+	}
+	if _, ok := p.Tool("read", args); ok {
+		t.Error("все записанные вызовы read уже использованы")
+	}
+	if resp, err := p.NextLLM(); err != nil || resp.Content != "готово" {
+		t.Errorf("второй ответ: %+v %v", resp, err)
+	}
+	if _, err := p.NextLLM(); err != ErrTapeExhausted {
+		t.Errorf("ожидалась ErrTapeExhausted, получено %v", err)
+	}
+	want := Stats{LLMReplayed: 2, ToolsExact: 1, ToolsByName: 1, ToolsMissed: 1}
+	if s := p.Stats(); s != want {
+		t.Errorf("итог: %+v, ожидалось %+v", s, want)
+	}
+}
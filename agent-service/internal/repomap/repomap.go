@@ -0,0 +1,242 @@
+package repomap
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Ограничения сканирования: большие и сгенерированные файлы не индексируются.
+const (
+	MaxFileBytes = 512 << 10 // Файлы больше пропускаются
+	MaxFiles     = 5000      // Не больше файлов за одно сканирование
+	// DefaultBudget — бюджет карты в символах (~1500 токенов).
+	DefaultBudget = 6000
+)
+
+// skipDirs — каталоги зависимостей, сборки и служебные.
+var skipDirs = map[string]bool{
+	".git": true, ".hg": true, ".svn": true, ".idea": true, ".vscode": true,
+	"node_modules": true, "vendor": true, "dist": true, "build": true, "target": true,
+	"__pycache__": true, ".venv": true, "venv": true, ".mypy_cache": true, ".pytest_cache": true,
+	".next": true, "coverage": true,
+}
+
+// File — проиндексированный файл.
+type File struct {
+	Path    string `json:"path"`    // Путь относительно корня
+	Symbols int    `json:"symbols"` // Количество символов
+}
+
+// Index — результат сканирования пространства.
+type Index struct {
+	Root    string   `json:"root"`
+	Files   []File   `json:"files"`
+	Symbols []Symbol `json:"symbols"`
+	Skipped int      `json:"skipped"` // Файлов пропущено по размеру или лимиту MaxFiles
+}
+
+// Scan — обходит каталог root и извлекает символы поддерживаемых файлов.
+func Scan(root string) (Index, error) {
+	info, err := os.Stat(root)
+	if err != nil {
+		return Index{}, err
+	}
+	if !info.IsDir() {
+		return Index{}, fmt.Errorf("%s — не каталог", root)
+	}
+	idx := Index{Root: root}
+	err = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil // Нечитаемые каталоги пропускаются
+		}
+		if d.IsDir() {
+			if path != root && (skipDirs[d.Name()] || strings.HasPrefix(d.Name(), ".")) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() || !Supported(path) || isGenerated(d.Name()) {
+			return nil
+		}
+		if len(idx.Files) >= MaxFiles {
+			idx.Skipped++
+			return nil
+		}
+		fi, err := d.Info()
+		if err != nil || fi.Size() > MaxFileBytes {
+			idx.Skipped++
+			return nil
+		}
+		src, err := os.ReadFile(path)
+		if err != nil {
+			return nil
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return nil
+		}
+		rel = filepath.ToSlash(rel)
+		syms := Extract(rel, src)
+		idx.Files = append(idx.Files, File{Path: rel, Symbols: len(syms)})
+		idx.Symbols = append(idx.Symbols, syms...)
+		return nil
+	})
+	return idx, err
+}
+
+// isGenerated — сгенерированные и минифицированные файлы по имени.
+func isGenerated(name string) bool {
+	return strings.HasSuffix(name, ".pb.go") || strings.HasSuffix(name, "_gen.go") ||
+		strings.HasSuffix(name, ".min.js") || strings.HasSuffix(name, ".d.ts")
+}
+
+// Search — символы, имя которых (или Parent.Name) содержит запрос без учёта
+// регистра: сначала точные совпадения, затем по префиксу, по подстроке имени
+// и, наконец, методы совпавшего класса; внутри
+// группы — публичные раньше, далее по файлу и строке.
+func Search(syms []Symbol, query string, limit int) []Symbol {
+	q := strings.ToLower(strings.TrimSpace(query))
+	if q == "" {
+		return nil
+	}
+	type hit struct {
+		sym  Symbol
+		rank int
+	}
+	var hits []hit
+	for _, s := range syms {
+		name := strings.ToLower(s.Name)
+		full := name
+		if s.Parent != "" {
+			full = strings.ToLower(s.Parent) + "." + name
+		}
+		switch {
+		case name == q || full == q:
+			hits = append(hits, hit{s, 0})
+		case strings.HasPrefix(name, q):
+			hits = append(hits, hit{s, 1})
+		case strings.Contains(name, q):
+			hits = append(hits, hit{s, 2})
+		case strings.Contains(full, q):
+			hits = append(hits, hit{s, 3}) // Совпало только имя класса или получателя
+		}
+	}
+	sort.SliceStable(hits, func(i, j int) bool {
+		a, b := hits[i], hits[j]
+		if a.rank != b.rank {
+			return a.rank < b.rank
+		}
+		if a.sym.Exported != b.sym.Exported {
+			return a.sym.Exported
+		}
+		if a.sym.File != b.sym.File {
+			return a.sym.File < b.sym.File
+		}
+		return a.sym.Line < b.sym.Line
+	})
+	if limit > 0 && len(hits) > limit {
+		hits = hits[:limit]
+	}
+	out := make([]Symbol, len(hits))
+	for i, h := range hits {
+		out[i] = h.sym
+	}
+	return out
+}
+
+// kindOrder — порядок символов внутри файла в карте: сначала типы, затем функции.
+var kindOrder = map[string]int{
+	KindInterface: 0, KindType: 1, KindClass: 1, KindFunc: 2, KindMethod: 3, KindConst: 4, KindVar: 5,
+}
+
+// ErrEmpty — в индексе нет символов.
+var ErrEmpty = errors.New("индекс пуст")
+
+// Render — компактная карта репозитория в пределах budget байт:
+//
+//	internal/llm/client.go
+//	  type Client interface
+//	  func NewClient(provider string) (Client, error)
+//	  (OpenAI) Chat
+//
+// Файлы упорядочены по числу публичных символов (ядро проекта — первым),
+// константы и переменные не выводятся, у методов — только имя с получателем.
+// Если файлы не помещаются, в конце указывается, сколько пропущено.
+func Render(syms []Symbol, budget int) (string, error) {
+	if len(syms) == 0 {
+		return "", ErrEmpty
+	}
+	if budget <= 0 {
+		budget = DefaultBudget
+	}
+	byFile := map[string][]Symbol{}
+	for _, s := range syms {
+		if s.Kind == KindConst || s.Kind == KindVar {
+			continue
+		}
+		byFile[s.File] = append(byFile[s.File], s)
+	}
+	files := make([]string, 0, len(byFile))
+	weight := map[string]int{}
+	for f, list := range byFile {
+		files = append(files, f)
+		for _, s := range list {
+			if s.Exported {
+				weight[f]++
+			}
+		}
+	}
+	sort.Slice(files, func(i, j int) bool {
+		if weight[files[i]] != weight[files[j]] {
+			return weight[files[i]] > weight[files[j]]
+		}
+		return files[i] < files[j]
+	})
+
+	var b strings.Builder
+	omitted := 0
+	for i, f := range files {
+		block := renderFile(f, byFile[f])
+		if b.Len()+len(block) > budget {
+			omitted = len(files) - i
+			break
+		}
+		b.WriteString(block)
+	}
+	if omitted > 0 {
+		fmt.Fprintf(&b, "... ещё файлов: %d\n", omitted)
+	}
+	return strings.TrimRight(b.String(), "\n"), nil
+}
+
+// renderFile — блок карты для одного файла (не больше 12 символов).
+func renderFile(file string, syms []Symbol) string {
+	sort.SliceStable(syms, func(i, j int) bool {
+		if kindOrder[syms[i].Kind] != kindOrder[syms[j].Kind] {
+			return kindOrder[syms[i].Kind] < kindOrder[syms[j].Kind]
+		}
+		if syms[i].Exported != syms[j].Exported {
+			return syms[i].Exported
+		}
+		return syms[i].Line < syms[j].Line
+	})
+	var b strings.Builder
+	b.WriteString(file + "\n")
+	for i, s := range syms {
+		if i == 12 {
+			fmt.Fprintf(&b, "  ... ещё %d\n", len(syms)-i)
+			break
+		}
+		if s.Kind == KindMethod && s.Parent != "" {
+			fmt.Fprintf(&b, "  (%s) %s\n", s.Parent, s.Name)
+			continue
+		}
+		b.WriteString("  " + s.Signature + "\n")
+	}
+	return b.String()
+}
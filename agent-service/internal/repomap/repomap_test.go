@@ -0,0 +1,114 @@
+package repomap
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// find — символ по имени или nil.
+func find(syms []Symbol, name string) *Symbol {
+	for i := range syms {
+		if syms[i].Name == name {
+			return &syms[i]
+		}
+	}
+	return nil
+}
+
+// TestExtractGo — функции, методы с получателем, интерфейсы и константы.
+func TestExtractGo(t *testing.T) {
+	src := "package llm\n\ntype Client interface {\n\tChat() error\n}\n\ntype openAI struct{}\n\nconst DefaultModel = \"gpt\"\n\nfunc NewClient(provider string) (Client, error) {\n\treturn nil, nil\n}\n\nfunc (c *openAI) Chat() error { return nil }\n"
+	syms := Extract("internal/llm/client.go", []byte(src))
+	if s := find(syms, "Client"); s == nil || s.Kind != KindInterface || s.Line != 3 {
+		t.Errorf("Client: %+v", s)
+	}
+	if s := find(syms, "NewClient"); s == nil || s.Signature != "func NewClient(provider string) (Client, error)" || !s.Exported {
+		t.Errorf("NewClient: %+v", s)
+	}
+	if s := find(syms, "Chat"); s == nil || s.Kind != KindMethod || s.Parent != "openAI" {
+		t.Errorf("Chat: %+v", s)
+	}
+	if s := find(syms, "DefaultModel"); s == nil || s.Kind != KindConst {
+		t.Errorf("DefaultModel: %+v", s)
+	}
+}
+
+// TestExtractPythonAndJS — методы классов определяются по вложенности.
+func TestExtractPythonAndJS(t *testing.T) {
+	py := "class Store:\n    def add(self, x):\n        pass\n\n    async def _flush(self):\n        pass\n\ndef main() -> None:\n    pass\n"
+	syms := Extract("app/store.py", []byte(py))
+	if s := find(syms, "add"); s == nil || s.Kind != KindMethod || s.Parent != "Store" {
+		t.Errorf("add: %+v", s)
+	}
+	if s := find(syms, "_flush"); s == nil || s.Exported {
+		t.Errorf("_flush должен быть приватным: %+v", s)
+	}
+	if s := find(syms, "main"); s == nil || s.Kind != KindFunc || s.Parent != "" || s.Signature != "def main() -> None" {
+		t.Errorf("main: %+v", s)
+	}
+
+	js := "export class Api {\n  async fetchAgents(id) {\n    if (id) {\n    }\n  }\n}\nexport const useChat = (agent: string) => {\n};\nfunction helper() {}\n"
+	syms = Extract("web/api.ts", []byte(js))
+	if s := find(syms, "fetchAgents"); s == nil || s.Kind != KindMethod || s.Parent != "Api" {
+		t.Errorf("fetchAgents: %+v", s)
+	}
+	if s := find(syms, "useChat"); s == nil || s.Kind != KindFunc || !s.Exported {
+		t.Errorf("useChat: %+v", s)
+	}
+	if find(syms, "if") != nil {
+		t.Error("ключевые слова не должны становиться методами")
+	}
+	if s := find(syms, "helper"); s == nil || s.Parent != "" {
+		t.Errorf("helper: %+v", s)
+	}
+}
+
+// TestScanSearchRender — сканирование пропускает зависимости, поиск ранжирует,
+// карта укладывается в бюджет.
+func TestScanSearchRender(t *testing.T) {
+	root := t.TempDir()
+	write := func(rel, content string) {
+		path := filepath.Join(root, rel)
+		os.MkdirAll(filepath.Dir(path), 0o755)
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("main.go", "package main\n\nfunc main() {}\n\nfunc runServer() {}\n")
+	write("internal/chat/chat.go", "package chat\n\ntype Handler struct{}\n\nfunc (h *Handler) ServeHTTP() {}\n\nfunc NewHandler() *Handler { return nil }\n")
+	write("node_modules/lib/index.js", "function ignored() {}\n")
+	write("README.md", "# docs\n")
+
+	idx, err := Scan(root)
+	if err != nil {
+		t.Fatalf("Scan: %v", err)
+	}
+	if len(idx.Files) != 2 {
+		t.Fatalf("ожидалось 2 файла, получено %+v", idx.Files)
+	}
+	if find(idx.Symbols, "ignored") != nil {
+		t.Error("node_modules должен пропускаться")
+	}
+
+	hits := Search(idx.Symbols, "handler", 10)
+	if len(hits) < 2 || hits[0].Name != "Handler" || hits[1].Name != "NewHandler" {
+		t.Errorf("неожиданный порядок результатов: %+v", hits)
+	}
+
+	full, err := Render(idx.Symbols, 0)
+	if err != nil {
+		t.Fatalf("Render: %v", err)
+	}
+	if !strings.HasPrefix(full, "internal/chat/chat.go\n  type Handler struct") || !strings.Contains(full, "(Handler) ServeHTTP") {
+		t.Errorf("неожиданная карта:\n%s", full)
+	}
+	short, _ := Render(idx.Symbols, 100)
+	if !strings.Contains(short, "... ещё файлов: 1") || strings.Contains(short, "main.go") {
+		t.Errorf("карта должна обрезаться по бюджету:\n%s", short)
+	}
+	if _, err := Render(nil, 0); err != ErrEmpty {
+		t.Errorf("ожидалась ErrEmpty, получено %v", err)
+	}
+}
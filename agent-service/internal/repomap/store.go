@@ -0,0 +1,198 @@
+package repomap
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/neo-2022/openclaw-memory/agent-service/internal/models"
+)
+
+// StaleAfter — через сколько индекс считается устаревшим и перестраивается
+// в фоне при следующем обращении агента.
+const StaleAfter = 30 * time.Minute
+
+// Store — индекс символов рабочих пространств в БД и кэш отрисованных карт.
+type Store struct {
+	db *gorm.DB
+
+	mu       sync.Mutex
+	maps     map[uint]map[int]string // workspace → бюджет → карта
+	indexing map[uint]bool           // Пространства, индексируемые в фоне
+}
+
+// NewStore — хранилище индекса поверх db.
+func NewStore(db *gorm.DB) *Store {
+	return &Store{db: db, maps: map[uint]map[int]string{}, indexing: map[uint]bool{}}
+}
+
+// Reindex — сканирует ws.Path и заменяет индекс пространства целиком;
+// обновляет IndexedAt и счётчики в ws.
+func (s *Store) Reindex(ws *models.Workspace) (Index, error) {
+	if strings.TrimSpace(ws.Path) == "" {
+		return Index{}, fmt.Errorf("у пространства %q не задан путь", ws.Name)
+	}
+	idx, err := Scan(ws.Path)
+	if err != nil {
+		return Index{}, err
+	}
+	rows := make([]models.WorkspaceSymbol, len(idx.Symbols))
+	for i, sym := range idx.Symbols {
+		rows[i] = toModel(ws.ID, sym)
+	}
+	now := time.Now()
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("workspace_id = ?", ws.ID).Delete(&models.WorkspaceSymbol{}).Error; err != nil {
+			return err
+		}
+		if len(rows) > 0 {
+			if err := tx.CreateInBatches(rows, 500).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Model(ws).Updates(map[string]any{
+			"indexed_at":   now,
+			"file_count":   len(idx.Files),
+			"symbol_count": len(idx.Symbols),
+		}).Error
+	})
+	if err != nil {
+		return Index{}, err
+	}
+	ws.IndexedAt, ws.FileCount, ws.SymbolCount = &now, len(idx.Files), len(idx.Symbols)
+	s.mu.Lock()
+	delete(s.maps, ws.ID)
+	s.mu.Unlock()
+	return idx, nil
+}
+
+// ReindexAsync — Reindex в фоне; повторный вызов, пока индексация идёт, игнорируется.
+func (s *Store) ReindexAsync(ws models.Workspace) {
+	s.mu.Lock()
+	if s.indexing[ws.ID] {
+		s.mu.Unlock()
+		return
+	}
+	s.indexing[ws.ID] = true
+	s.mu.Unlock()
+	go func() {
+		defer func() {
+			s.mu.Lock()
+			delete(s.indexing, ws.ID)
+			s.mu.Unlock()
+		}()
+		start := time.Now()
+		idx, err := s.Reindex(&ws)
+		if err != nil {
+			slog.Warn("Не удалось проиндексировать пространство", slog.String("пространство", ws.Name), slog.String("ошибка", err.Error()))
+			return
+		}
+		slog.Info("Пространство проиндексировано",
+			slog.String("пространство", ws.Name),
+			slog.Int("файлов", len(idx.Files)),
+			slog.Int("символов", len(idx.Symbols)),
+			slog.Duration("длительность", time.Since(start)),
+		)
+	}()
+}
+
+// Stale — нужно ли (пере)строить индекс пространства.
+func Stale(ws models.Workspace) bool {
+	return ws.IndexedAt == nil || time.Since(*ws.IndexedAt) > StaleAfter
+}
+
+// Symbols — символы пространства с фильтрами по файлу и виду, по файлу и строке.
+func (s *Store) Symbols(workspaceID uint, file, kind string, limit int) ([]Symbol, error) {
+	q := s.db.Where("workspace_id = ?", workspaceID)
+	if file != "" {
+		q = q.Where("file = ?", file)
+	}
+	if kind != "" {
+		q = q.Where("kind = ?", kind)
+	}
+	if limit > 0 {
+		q = q.Limit(limit)
+	}
+	var rows []models.WorkspaceSymbol
+	if err := q.Order("file, line").Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	return fromModels(rows), nil
+}
+
+// Search — поиск символов по имени (см. Search); кандидаты отбираются в БД.
+func (s *Store) Search(workspaceID uint, query string, limit int) ([]Symbol, error) {
+	q := strings.ToLower(strings.TrimSpace(query))
+	if q == "" {
+		return nil, nil
+	}
+	like := "%" + strings.NewReplacer("%", "", "_", "").Replace(q) + "%"
+	var rows []models.WorkspaceSymbol
+	err := s.db.Where("workspace_id = ? AND (LOWER(name) LIKE ? OR LOWER(parent) LIKE ?)", workspaceID, like, like).
+		Limit(2000).Find(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	return Search(fromModels(rows), query, limit), nil
+}
+
+// Map — карта репозитория пространства (см. Render), кэшируется до переиндексации.
+func (s *Store) Map(workspaceID uint, budget int) (string, error) {
+	s.mu.Lock()
+	if m, ok := s.maps[workspaceID][budget]; ok {
+		s.mu.Unlock()
+		return m, nil
+	}
+	s.mu.Unlock()
+
+	var rows []models.WorkspaceSymbol
+	err := s.db.Where("workspace_id = ? AND kind NOT IN ?", workspaceID, []string{KindConst, KindVar}).
+		Order("file, line").Find(&rows).Error
+	if err != nil {
+		return "", err
+	}
+	m, err := Render(fromModels(rows), budget)
+	if err != nil {
+		return "", err
+	}
+	s.mu.Lock()
+	if s.maps[workspaceID] == nil {
+		s.maps[workspaceID] = map[int]string{}
+	}
+	s.maps[workspaceID][budget] = m
+	s.mu.Unlock()
+	return m, nil
+}
+
+// Drop — удаляет индекс пространства (при удалении пространства).
+func (s *Store) Drop(workspaceID uint) error {
+	s.mu.Lock()
+	delete(s.maps, workspaceID)
+	s.mu.Unlock()
+	return s.db.Where("workspace_id = ?", workspaceID).Delete(&models.WorkspaceSymbol{}).Error
+}
+
+func toModel(workspaceID uint, s Symbol) models.WorkspaceSymbol {
+	return models.WorkspaceSymbol{
+		WorkspaceID: workspaceID,
+		File:        s.File,
+		Name:        s.Name,
+		Kind:        s.Kind,
+		Parent:      s.Parent,
+		Line:        s.Line,
+		Signature:   s.Signature,
+		Exported:    s.Exported,
+	}
+}
+
+func fromModels(rows []models.WorkspaceSymbol) []Symbol {
+	out := make([]Symbol, len(rows))
+	for i, r := range rows {
+		out[i] = Symbol{Name: r.Name, Kind: r.Kind, File: r.File, Line: r.Line, Signature: r.Signature, Parent: r.Parent, Exported: r.Exported}
+	}
+	return out
+}
@@ -0,0 +1,71 @@
+//go:build cgo
+
+package repomap
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+
+	"github.com/neo-2022/openclaw-memory/agent-service/internal/models"
+)
+
+// TestStore — переиндексация заменяет индекс, поиск и карта читают из БД.
+func TestStore(t *testing.T) {
+	gdb, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "ws.db")), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("открытие SQLite: %v", err)
+	}
+	if err := gdb.AutoMigrate(&models.Workspace{}, &models.WorkspaceSymbol{}); err != nil {
+		t.Fatalf("миграция: %v", err)
+	}
+	root := t.TempDir()
+	src := filepath.Join(root, "server.go")
+	os.WriteFile(src, []byte("package main\n\ntype Server struct{}\n\nfunc (s *Server) Start() {}\n\nconst port = 80\n"), 0o644)
+
+	ws := models.Workspace{Name: "demo", Path: root}
+	gdb.Create(&ws)
+	store := NewStore(gdb)
+	if !Stale(ws) {
+		t.Fatal("непроиндексированное пространство должно считаться устаревшим")
+	}
+	if _, err := store.Reindex(&ws); err != nil {
+		t.Fatalf("Reindex: %v", err)
+	}
+	if ws.SymbolCount != 3 || ws.FileCount != 1 || Stale(ws) {
+		t.Fatalf("неожиданные счётчики: %+v", ws)
+	}
+
+	hits, err := store.Search(ws.ID, "server", 10)
+	if err != nil || len(hits) != 2 || hits[0].Name != "Server" || hits[1].Parent != "Server" {
+		t.Fatalf("Search: %+v, %v", hits, err)
+	}
+	m, err := store.Map(ws.ID, 0)
+	if err != nil || !strings.Contains(m, "(Server) Start") || strings.Contains(m, "port") {
+		t.Fatalf("Map: %q, %v", m, err)
+	}
+
+	// Переиндексация после правки файла сбрасывает кэш карты
+	os.WriteFile(src, []byte("package main\n\nfunc Run() {}\n"), 0o644)
+	if _, err := store.Reindex(&ws); err != nil {
+		t.Fatalf("Reindex: %v", err)
+	}
+	if m, _ := store.Map(ws.ID, 0); strings.Contains(m, "Server") || !strings.Contains(m, "func Run()") {
+		t.Errorf("карта не обновилась: %q", m)
+	}
+	syms, _ := store.Symbols(ws.ID, "server.go", "", 0)
+	if len(syms) != 1 {
+		t.Errorf("старые символы не удалены: %+v", syms)
+	}
+	if err := store.Drop(ws.ID); err != nil {
+		t.Fatal(err)
+	}
+	if syms, _ := store.Symbols(ws.ID, "", "", 0); len(syms) != 0 {
+		t.Errorf("индекс не удалён: %+v", syms)
+	}
+}
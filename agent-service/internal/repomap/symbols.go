@@ -0,0 +1,332 @@
+// Package repomap — индекс символов рабочего пространства и компактная карта
+// репозитория для контекста агента.
+//
+// Сканирование обходит каталог проекта и извлекает из исходников функции,
+// методы, типы и классы со строками и сигнатурами. Go разбирается полноценным
+// парсером (go/parser). Python, JavaScript/TypeScript и Rust — эвристика:
+// регулярные выражения по строкам объявлений и отступы для вложенности, без
+// синтаксического дерева (tree-sitter не подключён). Объявления, разбитые на
+// несколько строк, а также код внутри строковых литералов и комментариев
+// могут распознаваться неверно. Результат — плоский список символов с
+// родителем (классом или типом-получателем).
+//
+// Индекс хранится в БД (models.WorkspaceSymbol); Render строит из него карту
+// «файл → ключевые символы» в пределах бюджета символов, которая
+// подставляется в системный промпт агента, привязанного к пространству.
+package repomap
+
+import (
+	"bytes"
+	"go/ast"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// Виды символов.
+const (
+	KindFunc      = "func"
+	KindMethod    = "method"
+	KindType      = "type"
+	KindInterface = "interface"
+	KindClass     = "class"
+	KindConst     = "const"
+	KindVar       = "var"
+)
+
+// Symbol — объявление в исходном файле.
+type Symbol struct {
+	Name      string `json:"name"`             // Имя (без пакета и получателя)
+	Kind      string `json:"kind"`             // func, method, type, interface, class, const, var
+	File      string `json:"file"`             // Путь относительно корня пространства
+	Line      int    `json:"line"`             // Номер строки объявления (с 1)
+	Signature string `json:"signature"`        // Однострочная сигнатура для карты
+	Parent    string `json:"parent,omitempty"` // Класс или тип-получатель метода
+	Exported  bool   `json:"exported"`         // Публичный символ (для приоритета в карте)
+}
+
+// extractor — распознаватель символов одного языка.
+type extractor func(file string, src []byte) []Symbol
+
+// extractors — распознаватели по расширению файла.
+var extractors = map[string]extractor{
+	".go":  extractGo,
+	".py":  extractPython,
+	".js":  extractJS,
+	".jsx": extractJS,
+	".mjs": extractJS,
+	".ts":  extractJS,
+	".tsx": extractJS,
+	".rs":  extractRust,
+}
+
+// Supported — есть ли распознаватель для файла.
+func Supported(path string) bool {
+	_, ok := extractors[strings.ToLower(filepath.Ext(path))]
+	return ok
+}
+
+// Extract — символы файла; file — путь относительно корня пространства.
+func Extract(file string, src []byte) []Symbol {
+	ex, ok := extractors[strings.ToLower(filepath.Ext(file))]
+	if !ok {
+		return nil
+	}
+	return ex(file, src)
+}
+
+// extractGo — символы Go через go/parser: функции, методы, типы, константы
+// и переменные уровня пакета. Файлы с синтаксическими ошибками разбираются
+// частично (parser возвращает то, что успел построить).
+func extractGo(file string, src []byte) []Symbol {
+	fset := token.NewFileSet()
+	f, _ := parser.ParseFile(fset, file, src, parser.SkipObjectResolution)
+	if f == nil {
+		return nil
+	}
+	var syms []Symbol
+	for _, decl := range f.Decls {
+		switch d := decl.(type) {
+		case *ast.FuncDecl:
+			s := Symbol{
+				Name:     d.Name.Name,
+				Kind:     KindFunc,
+				File:     file,
+				Line:     fset.Position(d.Pos()).Line,
+				Exported: d.Name.IsExported(),
+			}
+			if d.Recv != nil && len(d.Recv.List) > 0 {
+				s.Kind = KindMethod
+				s.Parent = receiverName(d.Recv.List[0].Type)
+			}
+			body := d.Body
+			d.Body = nil
+			s.Signature = clip(nodeString(fset, d))
+			d.Body = body
+			syms = append(syms, s)
+		case *ast.GenDecl:
+			for _, spec := range d.Specs {
+				switch sp := spec.(type) {
+				case *ast.TypeSpec:
+					kind := KindType
+					sig := "type " + sp.Name.Name
+					switch t := sp.Type.(type) {
+					case *ast.InterfaceType:
+						kind = KindInterface
+						sig += " interface"
+					case *ast.StructType:
+						sig += " struct"
+					default:
+						sig += " " + nodeString(fset, t)
+					}
+					syms = append(syms, Symbol{
+						Name: sp.Name.Name, Kind: kind, File: file,
+						Line: fset.Position(sp.Pos()).Line, Signature: sig, Exported: sp.Name.IsExported(),
+					})
+				case *ast.ValueSpec:
+					kind := KindVar
+					if d.Tok == token.CONST {
+						kind = KindConst
+					}
+					for _, n := range sp.Names {
+						if n.Name == "_" {
+							continue
+						}
+						syms = append(syms, Symbol{
+							Name: n.Name, Kind: kind, File: file,
+							Line: fset.Position(n.Pos()).Line, Signature: d.Tok.String() + " " + n.Name, Exported: n.IsExported(),
+						})
+					}
+				}
+			}
+		}
+	}
+	return syms
+}
+
+// receiverName — имя типа получателя метода (*T, T[K] → T).
+func receiverName(expr ast.Expr) string {
+	switch t := expr.(type) {
+	case *ast.StarExpr:
+		return receiverName(t.X)
+	case *ast.IndexExpr:
+		return receiverName(t.X)
+	case *ast.IndexListExpr:
+		return receiverName(t.X)
+	case *ast.Ident:
+		return t.Name
+	}
+	return ""
+}
+
+// nodeString — однострочное представление узла AST.
+func nodeString(fset *token.FileSet, node any) string {
+	var buf bytes.Buffer
+	if err := printer.Fprint(&buf, fset, node); err != nil {
+		return ""
+	}
+	return strings.Join(strings.Fields(buf.String()), " ")
+}
+
+// lineRule — построчный распознаватель объявления; номер группы с именем
+// зависит от языка (у Python и Rust первая группа — отступ или видимость).
+type lineRule struct {
+	kind string
+	re   *regexp.Regexp
+}
+
+var pythonRules = []lineRule{
+	{KindClass, regexp.MustCompile(`^(\s*)class\s+([A-Za-z_]\w*)`)},
+	{KindFunc, regexp.MustCompile(`^(\s*)(?:async\s+)?def\s+([A-Za-z_]\w*)\s*\(`)},
+}
+
+// extractPython — классы и функции; функция внутри класса становится методом
+// этого класса (определяется по отступу).
+func extractPython(file string, src []byte) []Symbol {
+	type scope struct {
+		indent int
+		name   string
+	}
+	var syms []Symbol
+	var classes []scope
+	for i, line := range strings.Split(string(src), "\n") {
+		for _, rule := range pythonRules {
+			m := rule.re.FindStringSubmatch(line)
+			if m == nil {
+				continue
+			}
+			indent := len(strings.ReplaceAll(m[1], "\t", "    "))
+			for len(classes) > 0 && classes[len(classes)-1].indent >= indent {
+				classes = classes[:len(classes)-1]
+			}
+			s := Symbol{
+				Name: m[2], Kind: rule.kind, File: file, Line: i + 1,
+				Signature: signatureLine(line, ":"), Exported: !strings.HasPrefix(m[2], "_"),
+			}
+			if len(classes) > 0 {
+				s.Parent = classes[len(classes)-1].name
+				if rule.kind == KindFunc {
+					s.Kind = KindMethod
+				}
+			}
+			if rule.kind == KindClass {
+				classes = append(classes, scope{indent, m[2]})
+			}
+			syms = append(syms, s)
+			break
+		}
+	}
+	return syms
+}
+
+var jsRules = []lineRule{
+	{KindClass, regexp.MustCompile(`^\s*(?:export\s+)?(?:default\s+)?(?:abstract\s+)?class\s+([A-Za-z_$][\w$]*)`)},
+	{KindInterface, regexp.MustCompile(`^\s*(?:export\s+)?interface\s+([A-Za-z_$][\w$]*)`)},
+	{KindType, regexp.MustCompile(`^\s*(?:export\s+)?type\s+([A-Za-z_$][\w$]*)\s*(?:<[^=]*>)?\s*=`)},
+	{KindFunc, regexp.MustCompile(`^\s*(?:export\s+)?(?:default\s+)?(?:async\s+)?function\s*\*?\s*([A-Za-z_$][\w$]*)`)},
+	{KindFunc, regexp.MustCompile(`^\s*(?:export\s+)?(?:const|let|var)\s+([A-Za-z_$][\w$]*)\s*(?::[^=]+)?=\s*(?:async\s+)?(?:\([^)]*\)|[A-Za-z_$][\w$]*)\s*(?::[^=]+)?=>`)},
+}
+
+// jsMethod — метод класса: отступ, имя, скобка; ключевые слова отсеиваются отдельно.
+var jsMethod = regexp.MustCompile(`^(\s+)(?:(?:public|private|protected|static|async|readonly|get|set)\s+)*([A-Za-z_$][\w$]*)\s*\([^;]*\)\s*(?::\s*[^{]+)?\{\s*$`)
+
+var jsKeywords = map[string]bool{"if": true, "for": true, "while": true, "switch": true, "catch": true, "function": true, "return": true, "constructor": true}
+
+// extractJS — JavaScript/TypeScript: классы, интерфейсы, типы, функции,
+// стрелочные функции в константах и методы классов.
+func extractJS(file string, src []byte) []Symbol {
+	var syms []Symbol
+	class := ""
+	classIndent := -1
+	for i, line := range strings.Split(string(src), "\n") {
+		indent := len(line) - len(strings.TrimLeft(line, " \t"))
+		if class != "" && strings.TrimSpace(line) != "" && indent <= classIndent && !strings.HasPrefix(strings.TrimSpace(line), "}") {
+			class = ""
+		}
+		matched := false
+		for _, rule := range jsRules {
+			m := rule.re.FindStringSubmatch(line)
+			if m == nil {
+				continue
+			}
+			exported := strings.HasPrefix(strings.TrimSpace(line), "export")
+			syms = append(syms, Symbol{Name: m[1], Kind: rule.kind, File: file, Line: i + 1, Signature: signatureLine(line, "{"), Exported: exported})
+			if rule.kind == KindClass {
+				class, classIndent = m[1], indent
+			}
+			matched = true
+			break
+		}
+		if matched || class == "" {
+			continue
+		}
+		if m := jsMethod.FindStringSubmatch(line); m != nil && !jsKeywords[m[2]] {
+			syms = append(syms, Symbol{
+				Name: m[2], Kind: KindMethod, File: file, Line: i + 1, Parent: class,
+				Signature: signatureLine(line, "{"), Exported: !strings.HasPrefix(m[2], "_") && !strings.Contains(line, "private "),
+			})
+		}
+	}
+	return syms
+}
+
+var rustRules = []lineRule{
+	{KindFunc, regexp.MustCompile(`^\s*(pub(?:\([^)]*\))?\s+)?(?:const\s+)?(?:async\s+)?(?:unsafe\s+)?fn\s+([A-Za-z_]\w*)`)},
+	{KindType, regexp.MustCompile(`^\s*(pub(?:\([^)]*\))?\s+)?(?:struct|enum|type)\s+([A-Za-z_]\w*)`)},
+	{KindInterface, regexp.MustCompile(`^\s*(pub(?:\([^)]*\))?\s+)?trait\s+([A-Za-z_]\w*)`)},
+}
+
+var rustImpl = regexp.MustCompile(`^impl(?:<[^>]*>)?\s+(?:[\w:<>]+\s+for\s+)?([A-Za-z_]\w*)`)
+
+// extractRust — функции, структуры, перечисления, трейты; fn внутри impl — метод типа.
+func extractRust(file string, src []byte) []Symbol {
+	var syms []Symbol
+	impl := ""
+	for i, line := range strings.Split(string(src), "\n") {
+		if m := rustImpl.FindStringSubmatch(line); m != nil {
+			impl = m[1]
+			continue
+		}
+		if strings.HasPrefix(line, "}") {
+			impl = ""
+		}
+		for _, rule := range rustRules {
+			m := rule.re.FindStringSubmatch(line)
+			if m == nil {
+				continue
+			}
+			s := Symbol{Name: m[2], Kind: rule.kind, File: file, Line: i + 1, Signature: signatureLine(line, "{"), Exported: m[1] != ""}
+			if impl != "" && rule.kind == KindFunc && line != strings.TrimLeft(line, " \t") {
+				s.Kind, s.Parent = KindMethod, impl
+			}
+			syms = append(syms, s)
+			break
+		}
+	}
+	return syms
+}
+
+// signatureLine — строка объявления без тела и хвостовых пробелов.
+func signatureLine(line, bodyStart string) string {
+	line = strings.TrimSpace(line)
+	if i := strings.LastIndex(line, bodyStart); i > 0 {
+		line = strings.TrimSpace(line[:i])
+	}
+	return clip(line)
+}
+
+// clip — сигнатура не длиннее 160 байт (по границе руны).
+func clip(sig string) string {
+	if len(sig) <= 160 {
+		return sig
+	}
+	cut := 157
+	for cut > 0 && !utf8.RuneStart(sig[cut]) {
+		cut--
+	}
+	return sig[:cut] + "..."
+}
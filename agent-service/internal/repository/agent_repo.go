@@ -4,12 +4,12 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"net/http"
 	"os"
 	"os/exec"
 	"strings"
 
 	"github.com/neo-2022/openclaw-memory/agent-service/internal/db"
+	"github.com/neo-2022/openclaw-memory/agent-service/internal/httpclient"
 	"github.com/neo-2022/openclaw-memory/agent-service/internal/models"
 	"gorm.io/gorm"
 )
@@ -51,7 +51,7 @@ func GetOllamaModels() ([]string, error) {
 	}
 
 	// Если ollama list не сработал, пробуем через API
-	resp, err := http.Get(getOllamaAPIURL() + "/api/tags")
+	resp, err := httpclient.New("ollama", 0).Get(getOllamaAPIURL() + "/api/tags")
 	if err != nil {
 		return nil, fmt.Errorf("cannot connect to Ollama: %w", err)
 	}
@@ -245,20 +245,52 @@ func CreateDefaultAgents() error {
 				"• list(path?) — содержимое директории\n" +
 				"• edit_file(file_path, old_text, new_text) — заменить текст в файле\n" +
 				"• delete(path) — удалить файл\n" +
-				"• debug_code(file_path, args?) — запустить скрипт и вернуть stdout/stderr\n\n" +
+				"• debug_code(file_path, args?) — запустить скрипт и вернуть stdout/stderr\n" +
+				"• run_code(language, code, stdin?) — выполнить фрагмент python/go/javascript в изолированном окружении\n" +
+				"• lint_code(paths?, diff?, root?) — замечания линтеров по файлам или правке\n" +
+				"• format_code(path, write?) — отформатировать файл (gofmt/eslint/black)\n" +
+				"• git_pull_request(repo_path, files?, branch?, base?) — ветка, коммит, push и PR; вернуть пользователю ссылку pr_url\n" +
+				"• import_issue(url) — взять в работу задачу GitHub/GitLab/Jira: резюме и план\n\n" +
 				"--- Системная информация ---\n" +
 				"• sysinfo() — ОС, архитектура, хост, пользователь\n" +
 				"• sysload() — загрузка CPU, память, диски\n" +
+				"• disk_usage(path?, depth?, top?) — самые большие каталоги и файлы: чем занят диск\n" +
+				"• security_audit(checks?) — аудит безопасности: порты, sudo, SSH, обновления, права на запись; замечания по важности\n" +
 				"• cputemp() — температура процессора\n\n" +
 				"--- Приложения ---\n" +
 				"• findapp(name) — найти .desktop файл приложения\n" +
 				"• launchapp(desktop_file) — запустить приложение\n" +
 				"• addautostart(app_name) — добавить в автозагрузку\n\n" +
+				"--- Почта ---\n" +
+				"• mail_list(query?, from?, since?, unseen?) — последние письма ящика\n" +
+				"• mail_read(uid) — текст письма\n" +
+				"• mail_send(to, subject, body, reply_to_uid?) — отправить письмо (отчёт, ответ)\n\n" +
+				"--- Календарь ---\n" +
+				"• calendar_list(from?, to?, days?) — события календаря\n" +
+				"• calendar_create(summary, start, end?, reminder_minutes?) — создать событие («завтра 22:00»)\n" +
+				"• calendar_update(uid, start?, end?, summary?, reminder_minutes?) — изменить событие\n\n" +
+				"--- Умный дом (если настроен) ---\n" +
+				"• home_sensor(entity_id? | topic?, query?) — показания датчиков\n" +
+				"• home_switch(entity_id | topic, action) — включить/выключить устройство\n" +
+				"• home_scene(entity_id | topic) — запустить сцену\n\n" +
+				"--- Kubernetes (если есть kubeconfig, только чтение) ---\n" +
+				"• k8s_pods(namespace?, selector?) — поды: статус, перезапуски\n" +
+				"• k8s_deployments(namespace?) — деплойменты и реплики\n" +
+				"• k8s_events(namespace?, kind?, name?, warnings_only?) — события кластера\n" +
+				"• k8s_describe(kind, name) — подробности ресурса с событиями\n" +
+				"• k8s_logs(name, container?, tail_lines?, previous?) — логи пода\n\n" +
 				"--- Мониторинг и логи ---\n" +
 				"• view_logs(level?, service?, limit?) — системные логи\n" +
+				"• search_knowledge(query, source_prefix?, extensions?, since?) — поиск по базе знаний RAG с фильтрами, например только по папке документации API\n" +
+				"• prometheus_query(query, range?, instance?) — метрики Prometheus: значение или тренд (cpu, memory, disk, load, network или PromQL)\n" +
+				"• net_ping(host, port?) / net_traceroute(host) — доступность хоста и маршрут до него\n" +
+				"• net_dns(name, types?, server?) — DNS-записи; net_public_ip() — внешний IP; net_interfaces(sample_sec?) — интерфейсы и трафик\n" +
 				"• configure_agent(agent_name, model?, provider?, prompt?) — настроить агента\n" +
 				"• get_agent_info(agent_name) — информация об агенте\n" +
-				"• list_models_for_role(role) — список моделей с рекомендациями\n\n" +
+				"• list_models_for_role(role) — список моделей с рекомендациями\n" +
+				"• ollama_pull(name) — скачать локальную модель Ollama\n" +
+				"• ollama_delete(name) — удалить локальную модель (только с подтверждения пользователя)\n" +
+				"• ollama_ps() — модели в памяти и идущие загрузки\n\n" +
 				"ПОРЯДОК РАБОТЫ:\n" +
 				"1. Получил задачу → определи какие инструменты нужны\n" +
 				"2. Каждый шаг — дебаг: выполнил действие → проверил результат → следующий шаг\n" +
@@ -0,0 +1,311 @@
+// model_probe.go — эмпирическая проверка возможностей новых моделей Ollama.
+//
+// Метаданные и эвристики по имени не говорят, умеет ли модель на деле
+// вызывать инструменты, отвечать в JSON-режиме и сколько контекста она
+// реально удерживает. При первой синхронизации каждая новая модель
+// прогоняется через короткий набор запросов, результат сохраняется в
+// ModelToolSupport и уточняет классификацию ролей.
+
+package repository
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/neo-2022/openclaw-memory/agent-service/internal/benchmark"
+	"github.com/neo-2022/openclaw-memory/agent-service/internal/db"
+	"github.com/neo-2022/openclaw-memory/agent-service/internal/httpclient"
+	"github.com/neo-2022/openclaw-memory/agent-service/internal/models"
+)
+
+// Форматы вызова инструментов в ответе модели.
+const (
+	ToolCallNative = "native" // Структурные message.tool_calls
+	ToolCallText   = "text"   // Вызов описан текстом (JSON в content) — агенту не подходит
+	ToolCallNone   = "none"
+)
+
+// ProbeResult — результат проверки модели. Пустой ToolCallFormat — проверка
+// инструментов не выполнена (Ollama недоступна).
+type ProbeResult struct {
+	ToolCallFormat string   `json:"tool_call_format"`
+	SupportsJSON   bool     `json:"supports_json"`
+	ContextLength  int      `json:"context_length"` // По метаданным /api/show
+	UsableContext  int      `json:"usable_context"` // Наибольший пройденный размер
+	Errors         []string `json:"errors,omitempty"`
+}
+
+// SupportsTools — модель вызывает инструменты структурно.
+func (p ProbeResult) SupportsTools() bool { return p.ToolCallFormat == ToolCallNative }
+
+// probeEnabled — MODEL_PROBE=false отключает проверку (например, на слабом железе).
+func probeEnabled() bool { return os.Getenv("MODEL_PROBE") != "false" }
+
+// probeMaxContext — верхняя граница проверки контекста (MODEL_PROBE_MAX_CONTEXT, 32768).
+func probeMaxContext() int {
+	if n, err := strconv.Atoi(os.Getenv("MODEL_PROBE_MAX_CONTEXT")); err == nil && n >= 2048 {
+		return n
+	}
+	return 32768
+}
+
+// probeClient — загрузка модели и длинный контекст на CPU занимают минуты.
+var probeClient = httpclient.New("ollama", 5*time.Minute)
+
+// probeReply — ответ Ollama /api/chat без потока.
+type probeReply struct {
+	Message struct {
+		Content   string `json:"content"`
+		ToolCalls []struct {
+			Function struct {
+				Name string `json:"name"`
+			} `json:"function"`
+		} `json:"tool_calls"`
+	} `json:"message"`
+	Error string `json:"error"`
+}
+
+func probeChat(request map[string]interface{}) (*probeReply, error) {
+	request["stream"] = false
+	data, err := json.Marshal(request)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := probeClient.Post(getOllamaBaseURL()+"/api/chat", "application/json", bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	var reply probeReply
+	if err := json.NewDecoder(resp.Body).Decode(&reply); err != nil {
+		return nil, err
+	}
+	if reply.Error != "" {
+		return nil, fmt.Errorf("ollama: %s", reply.Error)
+	}
+	return &reply, nil
+}
+
+// ProbeModel — проверяет модель: вызов инструментов, JSON-режим и размер
+// контекста (по степеням двойки от 2048 до меньшего из контекста модели и
+// MODEL_PROBE_MAX_CONTEXT, до первой неудачи). Ошибки отдельных проверок
+// попадают в Errors, остальные проверки продолжаются.
+func ProbeModel(modelName string) ProbeResult {
+	var res ProbeResult
+	fail := func(what string, err error) {
+		res.Errors = append(res.Errors, what+": "+err.Error())
+	}
+
+	if format, err := probeTools(modelName); err != nil {
+		fail("инструменты", err)
+	} else {
+		res.ToolCallFormat = format
+	}
+	if ok, err := probeJSON(modelName); err != nil {
+		fail("json", err)
+	} else {
+		res.SupportsJSON = ok
+	}
+
+	res.ContextLength = GetModelContextLength(modelName)
+	limit := probeMaxContext()
+	if res.ContextLength > 0 && res.ContextLength < limit {
+		limit = res.ContextLength
+	}
+	for size := 2048; size <= limit; size *= 2 {
+		ok, err := probeContext(modelName, size)
+		if err != nil {
+			fail(fmt.Sprintf("контекст %d", size), err)
+			break
+		}
+		if !ok {
+			break
+		}
+		res.UsableContext = size
+	}
+	return res
+}
+
+// probeTools — просит модель вызвать инструмент погоды и смотрит, как она это сделала.
+func probeTools(modelName string) (string, error) {
+	weather := map[string]interface{}{
+		"type": "function",
+		"function": map[string]interface{}{
+			"name":        "get_weather",
+			"description": "Текущая погода в городе",
+			"parameters": map[string]interface{}{
+				"type":       "object",
+				"properties": map[string]interface{}{"city": map[string]string{"type": "string"}},
+				"required":   []string{"city"},
+			},
+		},
+	}
+	reply, err := probeChat(map[string]interface{}{
+		"model":    modelName,
+		"messages": []map[string]string{{"role": "user", "content": "Какая сейчас погода в Москве? Используй инструмент."}},
+		"tools":    []interface{}{weather},
+	})
+	if err != nil {
+		return ToolCallNone, err
+	}
+	for _, tc := range reply.Message.ToolCalls {
+		if tc.Function.Name == "get_weather" {
+			return ToolCallNative, nil
+		}
+	}
+	if strings.Contains(reply.Message.Content, "get_weather") {
+		return ToolCallText, nil
+	}
+	return ToolCallNone, nil
+}
+
+// probeJSON — запрос в режиме format=json: ответ должен быть JSON-объектом с нужным полем.
+func probeJSON(modelName string) (bool, error) {
+	reply, err := probeChat(map[string]interface{}{
+		"model":    modelName,
+		"format":   "json",
+		"messages": []map[string]string{{"role": "user", "content": `Верни JSON-объект вида {"city": "...", "population": число} для Москвы.`}},
+	})
+	if err != nil {
+		return false, err
+	}
+	var obj map[string]interface{}
+	if json.Unmarshal([]byte(strings.TrimSpace(reply.Message.Content)), &obj) != nil {
+		return false, nil
+	}
+	_, ok := obj["city"]
+	return ok, nil
+}
+
+// probeContext — «иголка в стоге сена» размером size токенов с num_ctx = size.
+func probeContext(modelName string, size int) (bool, error) {
+	code := fmt.Sprintf("%04d-ПРОБА", 1000+size%9000)
+	reply, err := probeChat(map[string]interface{}{
+		"model":    modelName,
+		"messages": []map[string]string{{"role": "user", "content": benchmark.Haystack(size, code)}},
+		"options":  map[string]interface{}{"num_ctx": size},
+	})
+	if err != nil {
+		return false, err
+	}
+	return strings.Contains(strings.ToUpper(reply.Message.Content), code), nil
+}
+
+// GetModelContextLength — размер контекста из метаданных /api/show
+// (ключ "<архитектура>.context_length"); 0 — неизвестно.
+func GetModelContextLength(modelName string) int {
+	reqBody, _ := json.Marshal(map[string]string{"name": modelName})
+	resp, err := probeClient.Post(getOllamaBaseURL()+"/api/show", "application/json", bytes.NewReader(reqBody))
+	if err != nil {
+		return 0
+	}
+	defer resp.Body.Close()
+	var result struct {
+		ModelInfo map[string]interface{} `json:"model_info"`
+	}
+	if json.NewDecoder(resp.Body).Decode(&result) != nil {
+		return 0
+	}
+	for key, v := range result.ModelInfo {
+		if n, ok := v.(float64); ok && strings.HasSuffix(key, ".context_length") {
+			return int(n)
+		}
+	}
+	return 0
+}
+
+// probeQueue — модели, ожидающие проверки. Проверки идут по одной, чтобы
+// модели не вытесняли друг друга из VRAM.
+var probeQueue = struct {
+	sync.Mutex
+	pending map[string]bool
+	running bool
+	order   []string
+}{pending: map[string]bool{}}
+
+// ScheduleProbe — ставит модели в очередь фоновой проверки (повторы игнорируются).
+func ScheduleProbe(names ...string) {
+	if !probeEnabled() {
+		return
+	}
+	probeQueue.Lock()
+	defer probeQueue.Unlock()
+	for _, name := range names {
+		if !probeQueue.pending[name] {
+			probeQueue.pending[name] = true
+			probeQueue.order = append(probeQueue.order, name)
+		}
+	}
+	if !probeQueue.running && len(probeQueue.order) > 0 {
+		probeQueue.running = true
+		go runProbes()
+	}
+}
+
+func runProbes() {
+	for {
+		probeQueue.Lock()
+		if len(probeQueue.order) == 0 {
+			probeQueue.running = false
+			probeQueue.Unlock()
+			return
+		}
+		name := probeQueue.order[0]
+		probeQueue.order = probeQueue.order[1:]
+		probeQueue.Unlock()
+
+		ProbeAndStore(name)
+
+		probeQueue.Lock()
+		delete(probeQueue.pending, name)
+		probeQueue.Unlock()
+	}
+}
+
+// ProbeAndStore — проверяет модель и обновляет её запись: результаты
+// проверки заменяют эвристическую поддержку инструментов, роли
+// пересчитываются.
+func ProbeAndStore(modelName string) (ProbeResult, error) {
+	start := time.Now()
+	res := ProbeModel(modelName)
+	if res.ToolCallFormat == "" {
+		// Модель не ответила — запись не помечаем, проверка повторится при следующей синхронизации
+		return res, errors.New(strings.Join(res.Errors, "; "))
+	}
+
+	var record models.ModelToolSupport
+	if err := db.DB.Where("model_name = ?", modelName).First(&record).Error; err != nil {
+		return res, err
+	}
+	details := OllamaModelDetails{Family: record.Family, ParameterSize: record.ParameterSize}
+	roleInfo := ClassifyModelRoles(modelName, res.SupportsTools(), details)
+	rolesJSON, _ := json.Marshal(roleInfo.SuitableRoles)
+	notesJSON, _ := json.Marshal(roleInfo.RoleNotes)
+	now := time.Now()
+	err := db.DB.Model(&record).Updates(map[string]interface{}{
+		"supports_tools":   res.SupportsTools(),
+		"tool_call_format": res.ToolCallFormat,
+		"supports_json":    res.SupportsJSON,
+		"context_length":   res.ContextLength,
+		"usable_context":   res.UsableContext,
+		"suitable_roles":   string(rolesJSON),
+		"role_notes":       string(notesJSON),
+		"probed_at":        &now,
+	}).Error
+	slog.Info("Модель проверена",
+		slog.String("модель", modelName),
+		slog.String("инструменты", res.ToolCallFormat),
+		slog.Bool("json", res.SupportsJSON),
+		slog.Int("контекст", res.UsableContext),
+		slog.Duration("время", time.Since(start)),
+		slog.Int("ошибок", len(res.Errors)))
+	return res, err
+}
@@ -0,0 +1,53 @@
+package repository
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+)
+
+// TestProbeModel — проверка на имитации Ollama: модель вызывает инструмент
+// текстом, держит JSON-режим и удерживает контекст до 8192 токенов.
+func TestProbeModel(t *testing.T) {
+	code := regexp.MustCompile(`код доступа — (\S+)\.`)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/show" {
+			w.Write([]byte(`{"model_info":{"general.architecture":"llama","llama.context_length":131072}}`))
+			return
+		}
+		var req struct {
+			Format   string                     `json:"format"`
+			Tools    []json.RawMessage          `json:"tools"`
+			Messages []struct{ Content string } `json:"messages"`
+			Options  struct {
+				NumCtx int `json:"num_ctx"`
+			} `json:"options"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		content := "не знаю"
+		switch {
+		case len(req.Tools) > 0:
+			content = `{"name": "get_weather", "arguments": {"city": "Москва"}}`
+		case req.Format == "json":
+			content = `{"city": "Москва", "population": 13000000}`
+		default:
+			if m := code.FindStringSubmatch(req.Messages[0].Content); m != nil && req.Options.NumCtx <= 8192 {
+				content = m[1]
+			}
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"message": map[string]string{"content": content}})
+	}))
+	defer srv.Close()
+	t.Setenv("OLLAMA_URL", srv.URL)
+	t.Setenv("MODEL_PROBE_MAX_CONTEXT", "16384")
+
+	res := ProbeModel("llama3.2:3b")
+	if res.ToolCallFormat != ToolCallText || res.SupportsTools() {
+		t.Errorf("инструменты: %q", res.ToolCallFormat)
+	}
+	if !res.SupportsJSON || res.ContextLength != 131072 || res.UsableContext != 8192 || len(res.Errors) != 0 {
+		t.Errorf("результат: %+v", res)
+	}
+}
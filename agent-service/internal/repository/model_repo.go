@@ -6,6 +6,7 @@
 //   - Список моделей — из Ollama API /api/tags (или ollama list)
 //   - Метаданные (семейство, размер) — из Ollama API /api/show
 //   - Поддержка инструментов — тестовый запрос к модели
+//   - JSON-режим и рабочий размер контекста — фоновая проверка (model_probe.go)
 //   - Классификация ролей — автоматически на основе метаданных
 //
 // Никаких жёстких привязок моделей в коде нет. Всё определяется автоматически.
@@ -15,13 +16,13 @@ import (
 	"bytes"
 	"encoding/json"
 	"log"
-	"net/http"
 	"os"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/neo-2022/openclaw-memory/agent-service/internal/db"
+	"github.com/neo-2022/openclaw-memory/agent-service/internal/httpclient"
 	"github.com/neo-2022/openclaw-memory/agent-service/internal/models"
 )
 
@@ -56,7 +57,7 @@ type ModelRoleInfo struct {
 // Если Ollama недоступна или модель не найдена — возвращает пустую структуру без ошибки.
 func GetModelDetails(modelName string) OllamaModelDetails {
 	reqBody, _ := json.Marshal(map[string]string{"name": modelName})
-	resp, err := http.Post(getOllamaBaseURL()+"/api/show", "application/json", bytes.NewReader(reqBody))
+	resp, err := httpclient.New("ollama", 0).Post(getOllamaBaseURL()+"/api/show", "application/json", bytes.NewReader(reqBody))
 	if err != nil {
 		log.Printf("Не удалось получить метаданные модели %s: %v", modelName, err)
 		return OllamaModelDetails{}
@@ -177,7 +178,7 @@ func CheckModelToolSupport(modelName string) (bool, error) {
 	if err != nil {
 		return false, err
 	}
-	resp, err := http.Post(getOllamaBaseURL()+"/api/chat", "application/json", bytes.NewReader(data))
+	resp, err := httpclient.New("ollama", 0).Post(getOllamaBaseURL()+"/api/chat", "application/json", bytes.NewReader(data))
 	if err != nil {
 		return false, err
 	}
@@ -266,7 +267,8 @@ func GetModelFullInfo(modelName string) (*models.ModelToolSupport, error) {
 }
 
 // SyncModels — синхронизирует кэш моделей с текущим списком из Ollama.
-// Для новых моделей — выполняет полную классификацию (tool support + метаданные + роли).
+// Для новых моделей — выполняет полную классификацию (tool support + метаданные + роли)
+// и ставит их в очередь эмпирической проверки (ScheduleProbe).
 // Для удалённых моделей — удаляет записи из кэша.
 func SyncModels(ollamaModels []string) error {
 	var existing []models.ModelToolSupport
@@ -277,8 +279,9 @@ func SyncModels(ollamaModels []string) error {
 		existingMap[rec.ModelName] = rec
 	}
 
+	var unprobed []string
 	for _, model := range ollamaModels {
-		if _, ok := existingMap[model]; !ok {
+		if rec, ok := existingMap[model]; !ok {
 			supports, err := CheckModelToolSupport(model)
 			if err != nil {
 				supports = false
@@ -300,8 +303,13 @@ func SyncModels(ollamaModels []string) error {
 				CheckedAt:     time.Now(),
 			}
 			db.DB.Create(&newRec)
+			unprobed = append(unprobed, model)
+		} else if rec.ProbedAt == nil {
+			unprobed = append(unprobed, model)
 		}
 	}
+	// Эмпирическая проверка идёт в фоне: она занимает до нескольких минут на модель
+	ScheduleProbe(unprobed...)
 
 	ollamaSet := make(map[string]bool)
 	for _, m := range ollamaModels {
@@ -314,3 +322,30 @@ func SyncModels(ollamaModels []string) error {
 	}
 	return nil
 }
+
+// SaveBenchmark — сохраняет результат замера модели в историю.
+func SaveBenchmark(b *models.ModelBenchmark) error {
+	return db.DB.Create(b).Error
+}
+
+// ListBenchmarks — история замеров (новые первыми); пустое modelName — все модели.
+func ListBenchmarks(modelName string, limit int) ([]models.ModelBenchmark, error) {
+	q := db.DB.Order("created_at DESC, id DESC").Limit(limit)
+	if modelName != "" {
+		q = q.Where("model_name = ?", modelName)
+	}
+	var list []models.ModelBenchmark
+	err := q.Find(&list).Error
+	return list, err
+}
+
+// LatestBenchmarks — последний замер каждой модели (ключ — имя модели).
+func LatestBenchmarks() map[string]models.ModelBenchmark {
+	var list []models.ModelBenchmark
+	db.DB.Where("id IN (?)", db.DB.Model(&models.ModelBenchmark{}).Select("MAX(id)").Group("model_name")).Find(&list)
+	latest := make(map[string]models.ModelBenchmark, len(list))
+	for _, b := range list {
+		latest[b.ModelName] = b
+	}
+	return latest
+}
@@ -0,0 +1,249 @@
+// Package respcache — кэш ответов LLM для повторяющихся запросов.
+//
+// Ключ — модель, инструменты и сообщения с нормализованными пробелами.
+// В режиме exact ответ возвращается только на тот же запрос; в режиме
+// semantic — ещё и на перефразированный последний вопрос пользователя,
+// если остальной контекст совпадает, а близость слов вопросов не ниже
+// порога. Так проверки здоровья и демонстрационные вопросы не оплачиваются
+// у облачного провайдера повторно.
+package respcache
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"math"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/neo-2022/openclaw-memory/agent-service/internal/llm"
+)
+
+// Режимы кэша.
+const (
+	ModeOff      = "off"
+	ModeExact    = "exact"
+	ModeSemantic = "semantic"
+)
+
+// Результаты поиска в кэше (метка метрики).
+const (
+	ResultHit         = "hit"
+	ResultSemanticHit = "semantic_hit"
+	ResultMiss        = "miss"
+	ResultBypass      = "bypass"
+)
+
+// Options — параметры кэша; читаются из конфигурации при каждом обращении.
+type Options struct {
+	Mode       string
+	TTL        time.Duration
+	MaxEntries int
+	Similarity float64 // Порог близости вопросов 0..1 для режима semantic
+}
+
+type bypassKey struct{}
+
+// WithBypass — запрос не читает и не пополняет кэш (флаг no_cache).
+func WithBypass(ctx context.Context) context.Context {
+	return context.WithValue(ctx, bypassKey{}, true)
+}
+
+// Bypassed — для запроса кэш отключён.
+func Bypassed(ctx context.Context) bool {
+	b, _ := ctx.Value(bypassKey{}).(bool)
+	return b
+}
+
+type entry struct {
+	key     string
+	context string         // Всё, кроме последнего вопроса пользователя
+	words   map[string]int // Слова последнего вопроса (для semantic)
+	resp    llm.ChatResponse
+	expires time.Time
+}
+
+// Cache — LRU-кэш ответов с ограниченным временем жизни.
+type Cache struct {
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	order   *list.List // Начало — недавно использованные
+	now     func() time.Time
+}
+
+// New — пустой кэш.
+func New() *Cache {
+	return &Cache{entries: map[string]*list.Element{}, order: list.New(), now: time.Now}
+}
+
+// Get — сохранённый ответ на запрос. Возвращает копию ответа и результат
+// (hit, semantic_hit, miss); при ModeOff всегда miss.
+func (c *Cache) Get(req *llm.ChatRequest, opt Options) (*llm.ChatResponse, string) {
+	if opt.Mode != ModeExact && opt.Mode != ModeSemantic {
+		return nil, ResultMiss
+	}
+	key, ctxKey, question := fingerprint(req)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := c.now()
+	if el, ok := c.entries[key]; ok {
+		e := el.Value.(*entry)
+		if now.Before(e.expires) {
+			c.order.MoveToFront(el)
+			return clone(e.resp), ResultHit
+		}
+		c.remove(el)
+	}
+	if opt.Mode != ModeSemantic || question == "" {
+		return nil, ResultMiss
+	}
+	words := Words(question)
+	var best *list.Element
+	bestScore := 0.0
+	for el := c.order.Front(); el != nil; {
+		next := el.Next()
+		e := el.Value.(*entry)
+		switch {
+		case !now.Before(e.expires):
+			c.remove(el)
+		case e.context == ctxKey && e.words != nil:
+			if s := Similarity(words, e.words); s >= opt.Similarity && s > bestScore {
+				best, bestScore = el, s
+			}
+		}
+		el = next
+	}
+	if best == nil {
+		return nil, ResultMiss
+	}
+	c.order.MoveToFront(best)
+	return clone(best.Value.(*entry).resp), ResultSemanticHit
+}
+
+// Put — сохраняет ответ. Пустые ответы не кэшируются.
+func (c *Cache) Put(req *llm.ChatRequest, resp *llm.ChatResponse, opt Options) {
+	if opt.Mode != ModeExact && opt.Mode != ModeSemantic || opt.TTL <= 0 || opt.MaxEntries <= 0 {
+		return
+	}
+	if resp == nil || (strings.TrimSpace(resp.Content) == "" && len(resp.ToolCalls) == 0) {
+		return
+	}
+	key, ctxKey, question := fingerprint(req)
+	e := &entry{key: key, context: ctxKey, resp: *clone(*resp), expires: c.now().Add(opt.TTL)}
+	if question != "" {
+		e.words = Words(question)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.entries[key]; ok {
+		c.remove(el)
+	}
+	c.entries[key] = c.order.PushFront(e)
+	for c.order.Len() > opt.MaxEntries {
+		c.remove(c.order.Back())
+	}
+}
+
+// Len — число записей (включая ещё не удалённые просроченные).
+func (c *Cache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
+
+// Clear — удаляет все записи, возвращает их число.
+func (c *Cache) Clear() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	n := c.order.Len()
+	c.entries = map[string]*list.Element{}
+	c.order.Init()
+	return n
+}
+
+func (c *Cache) remove(el *list.Element) {
+	delete(c.entries, el.Value.(*entry).key)
+	c.order.Remove(el)
+}
+
+// fingerprint — ключ всего запроса, ключ контекста без последнего вопроса
+// пользователя и сам вопрос (пусто, если запрос заканчивается не вопросом,
+// например результатом инструмента).
+func fingerprint(req *llm.ChatRequest) (key, ctxKey, question string) {
+	var b strings.Builder
+	b.WriteString(req.Model)
+	for _, t := range req.Tools {
+		b.WriteString("\x00tool:" + t.Function.Name)
+	}
+	msgs := req.Messages
+	if n := len(msgs); n > 0 && msgs[n-1].Role == "user" && len(msgs[n-1].Images) == 0 {
+		question = normalize(msgs[n-1].Content)
+		msgs = msgs[:n-1]
+	}
+	for _, m := range msgs {
+		writeMessage(&b, m)
+	}
+	ctxKey = hash(b.String())
+	if question != "" {
+		b.WriteString("\x00user\x00" + question)
+	}
+	return hash(b.String()), ctxKey, question
+}
+
+func writeMessage(b *strings.Builder, m llm.Message) {
+	b.WriteString("\x00" + m.Role + "\x00" + normalize(m.Content))
+	for _, tc := range m.ToolCalls {
+		b.WriteString("\x00call:" + tc.Function.Name + ":" + string(tc.Function.Arguments))
+	}
+	for _, img := range m.Images {
+		b.WriteString("\x00image:" + hash(img))
+	}
+}
+
+// normalize — пробелы и переводы строк схлопываются, края обрезаются.
+func normalize(s string) string { return strings.Join(strings.Fields(s), " ") }
+
+func hash(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+// Words — частоты слов текста в нижнем регистре без знаков препинания.
+func Words(s string) map[string]int {
+	words := map[string]int{}
+	for _, w := range strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		words[strings.ReplaceAll(w, "ё", "е")]++
+	}
+	return words
+}
+
+// Similarity — косинусная близость частот слов (1 — одинаковый набор слов).
+func Similarity(a, b map[string]int) float64 {
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+	var dot, na, nb float64
+	for w, x := range a {
+		dot += float64(x * b[w])
+		na += float64(x * x)
+	}
+	for _, y := range b {
+		nb += float64(y * y)
+	}
+	return dot / math.Sqrt(na*nb)
+}
+
+// clone — копия ответа, чтобы вызывающий не менял запись кэша.
+func clone(r llm.ChatResponse) *llm.ChatResponse {
+	r.ToolCalls = append([]llm.ToolCall(nil), r.ToolCalls...)
+	if r.Stats != nil {
+		stats := *r.Stats
+		r.Stats = &stats
+	}
+	return &r
+}
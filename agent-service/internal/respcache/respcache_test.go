@@ -0,0 +1,84 @@
+package respcache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/neo-2022/openclaw-memory/agent-service/internal/llm"
+)
+
+func chatReq(model, question string) *llm.ChatRequest {
+	return &llm.ChatRequest{Model: model, Messages: []llm.Message{
+		{Role: "system", Content: "Ты — помощник."},
+		{Role: "user", Content: question},
+	}}
+}
+
+// TestExact — тот же запрос с другими пробелами попадает в кэш, другая модель — нет.
+func TestExact(t *testing.T) {
+	c := New()
+	opt := Options{Mode: ModeExact, TTL: time.Minute, MaxEntries: 10}
+	c.Put(chatReq("gpt-4o", "Сервис жив?"), &llm.ChatResponse{Content: "Да"}, opt)
+
+	resp, res := c.Get(chatReq("gpt-4o", "  Сервис   жив?\n"), opt)
+	if res != ResultHit || resp.Content != "Да" {
+		t.Fatalf("ожидалось попадание: %s %+v", res, resp)
+	}
+	resp.Content = "изменено"
+	if again, _ := c.Get(chatReq("gpt-4o", "Сервис жив?"), opt); again.Content != "Да" {
+		t.Error("запись кэша изменена через возвращённый ответ")
+	}
+	if _, res := c.Get(chatReq("gpt-4o-mini", "Сервис жив?"), opt); res != ResultMiss {
+		t.Error("другая модель не должна попадать в кэш")
+	}
+	if _, res := c.Get(chatReq("gpt-4o", "сервис жив"), opt); res != ResultMiss {
+		t.Error("в режиме exact перефразированный вопрос — промах")
+	}
+	if _, res := c.Get(chatReq("gpt-4o", "Сервис жив?"), Options{Mode: ModeOff}); res != ResultMiss {
+		t.Error("выключенный кэш — промах")
+	}
+}
+
+// TestSemantic — близкий вопрос в том же контексте попадает, другой контекст или смысл — нет.
+func TestSemantic(t *testing.T) {
+	c := New()
+	opt := Options{Mode: ModeSemantic, TTL: time.Minute, MaxEntries: 10, Similarity: 0.9}
+	c.Put(chatReq("gpt-4o", "Какая столица Франции?"), &llm.ChatResponse{Content: "Париж"}, opt)
+
+	if resp, res := c.Get(chatReq("gpt-4o", "какая столица франции"), opt); res != ResultSemanticHit || resp.Content != "Париж" {
+		t.Errorf("ожидалось семантическое попадание: %s", res)
+	}
+	if _, res := c.Get(chatReq("gpt-4o", "Какая столица Германии?"), opt); res != ResultMiss {
+		t.Error("другой вопрос — промах")
+	}
+	other := chatReq("gpt-4o", "Какая столица Франции?")
+	other.Messages[0].Content = "Ты — географ."
+	if _, res := c.Get(other, opt); res != ResultMiss {
+		t.Error("другой системный промпт — промах")
+	}
+}
+
+// TestExpiry — записи истекают по TTL и вытесняются сверх MaxEntries.
+func TestExpiry(t *testing.T) {
+	now := time.Now()
+	c := New()
+	c.now = func() time.Time { return now }
+	opt := Options{Mode: ModeExact, TTL: time.Minute, MaxEntries: 2}
+	for _, q := range []string{"a", "b", "c"} {
+		c.Put(chatReq("m", q), &llm.ChatResponse{Content: q}, opt)
+	}
+	if c.Len() != 2 {
+		t.Errorf("записей %d, ожидалось 2", c.Len())
+	}
+	if _, res := c.Get(chatReq("m", "a"), opt); res != ResultMiss {
+		t.Error("старейшая запись должна быть вытеснена")
+	}
+	now = now.Add(2 * time.Minute)
+	if _, res := c.Get(chatReq("m", "c"), opt); res != ResultMiss {
+		t.Error("запись должна истечь")
+	}
+	c.Put(chatReq("m", "d"), &llm.ChatResponse{}, opt)
+	if _, res := c.Get(chatReq("m", "d"), opt); res != ResultMiss {
+		t.Error("пустой ответ не кэшируется")
+	}
+}
@@ -0,0 +1,195 @@
+// Package retry — повтор запросов к LLM-провайдерам при временных ошибках.
+//
+// Провайдеры возвращают HTTPError с кодом ответа и заголовком Retry-After.
+// Classify решает, стоит ли повторять: 429 (rate limit) и 502/503/504
+// (провайдер временно недоступен); остальные ошибки возвращаются сразу.
+// Policy задаёт число попыток и паузу: экспонента от BaseDelay со случайной
+// добавкой (jitter), чтобы параллельные запросы не повторялись одновременно;
+// Retry-After провайдера важнее расчётной паузы. Budget ограничивает число
+// повторов к одному провайдеру в минуту: при массовом сбое запросы не
+// умножают нагрузку на провайдер, а сразу возвращают ошибку.
+package retry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Причина повтора (метка метрики).
+const (
+	ReasonRateLimit   = "rate_limit"
+	ReasonUnavailable = "unavailable"
+)
+
+// HTTPError — провайдер ответил кодом ошибки.
+type HTTPError struct {
+	Provider   string        // Имя провайдера в тексте ошибки (OpenAI, Ollama...)
+	Status     int           // HTTP-код ответа
+	RetryAfter time.Duration // Заголовок Retry-After (0 — не задан)
+	Message    string        // Понятное описание ошибки
+}
+
+func (e *HTTPError) Error() string {
+	return fmt.Sprintf("%s HTTP %d: %s", e.Provider, e.Status, e.Message)
+}
+
+// FromResponse — ошибка по ответу провайдера с учётом Retry-After.
+func FromResponse(provider string, resp *http.Response, message string) *HTTPError {
+	return &HTTPError{
+		Provider:   provider,
+		Status:     resp.StatusCode,
+		RetryAfter: ParseRetryAfter(resp.Header.Get("Retry-After"), time.Now()),
+		Message:    message,
+	}
+}
+
+// ParseRetryAfter — значение Retry-After: секунды или HTTP-дата. 0 — не задано
+// или некорректно.
+func ParseRetryAfter(value string, now time.Time) time.Duration {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0
+	}
+	if sec, err := strconv.Atoi(value); err == nil {
+		if sec < 0 {
+			return 0
+		}
+		return time.Duration(sec) * time.Second
+	}
+	if at, err := http.ParseTime(value); err == nil && at.After(now) {
+		return at.Sub(now)
+	}
+	return 0
+}
+
+// Classify — причина повтора и Retry-After; ok=false — ошибку не повторяют.
+func Classify(err error) (reason string, retryAfter time.Duration, ok bool) {
+	var he *HTTPError
+	if !errors.As(err, &he) {
+		return "", 0, false
+	}
+	switch he.Status {
+	case http.StatusTooManyRequests:
+		return ReasonRateLimit, he.RetryAfter, true
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return ReasonUnavailable, he.RetryAfter, true
+	}
+	return "", 0, false
+}
+
+// Policy — число попыток и паузы между ними.
+type Policy struct {
+	MaxAttempts int           // Всего попыток, включая первую
+	BaseDelay   time.Duration // Пауза после первой неудачи (без jitter)
+	MaxDelay    time.Duration // Предел паузы; Retry-After длиннее — повтор бессмыслен
+	// Rand — случайное число [0, 1) для jitter; nil — math/rand
+	Rand func() float64
+}
+
+// Delay — пауза после attempt-й неудачной попытки (с 1): BaseDelay·2^(attempt-1)
+// не больше MaxDelay, из которых случайна вторая половина. Если провайдер
+// прислал Retry-After, ждём не меньше него; ok=false — Retry-After больше
+// MaxDelay (например, суточный лимит), повторять не стоит.
+func (p Policy) Delay(attempt int, retryAfter time.Duration) (time.Duration, bool) {
+	if retryAfter > p.MaxDelay {
+		return 0, false
+	}
+	d := p.BaseDelay
+	for i := 1; i < attempt && d < p.MaxDelay; i++ {
+		d *= 2
+	}
+	if d > p.MaxDelay {
+		d = p.MaxDelay
+	}
+	random := p.Rand
+	if random == nil {
+		random = rand.Float64
+	}
+	d = d/2 + time.Duration(random()*float64(d/2))
+	if d < retryAfter {
+		d = retryAfter
+	}
+	return d, true
+}
+
+// Sleep — пауза, прерываемая отменой контекста.
+func Sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+// Budget — не больше limit повторов к каждому провайдеру за window.
+// Безопасен для параллельного использования.
+type Budget struct {
+	mu     sync.Mutex
+	limit  int
+	window time.Duration
+	spent  map[string][]time.Time
+	now    func() time.Time
+}
+
+// NewBudget — бюджет повторов; limit <= 0 — без ограничения.
+func NewBudget(limit int, window time.Duration) *Budget {
+	return &Budget{limit: limit, window: window, spent: map[string][]time.Time{}, now: time.Now}
+}
+
+// SetLimit — новый лимит (перезагрузка конфигурации); потраченное сохраняется.
+func (b *Budget) SetLimit(limit int) {
+	b.mu.Lock()
+	b.limit = limit
+	b.mu.Unlock()
+}
+
+// Allow — списывает повтор к provider; false — бюджет исчерпан.
+func (b *Budget) Allow(provider string) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.limit <= 0 {
+		return true
+	}
+	spent := b.recent(provider)
+	if len(spent) >= b.limit {
+		return false
+	}
+	b.spent[provider] = append(spent, b.now())
+	return true
+}
+
+// Remaining — сколько повторов к provider осталось в окне; -1 — без ограничения.
+func (b *Budget) Remaining(provider string) int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.limit <= 0 {
+		return -1
+	}
+	if n := b.limit - len(b.recent(provider)); n > 0 {
+		return n
+	}
+	return 0
+}
+
+// recent — повторы к provider внутри окна (старые отбрасываются).
+func (b *Budget) recent(provider string) []time.Time {
+	cutoff := b.now().Add(-b.window)
+	spent := b.spent[provider]
+	i := 0
+	for i < len(spent) && !spent[i].After(cutoff) {
+		i++
+	}
+	spent = spent[i:]
+	b.spent[provider] = spent
+	return spent
+}
@@ -0,0 +1,88 @@
+package retry
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// TestClassify — повторяются только rate limit и недоступность провайдера.
+func TestClassify(t *testing.T) {
+	cases := []struct {
+		err    error
+		reason string
+		after  time.Duration
+	}{
+		{&HTTPError{Provider: "OpenAI", Status: 429, RetryAfter: 5 * time.Second}, ReasonRateLimit, 5 * time.Second},
+		{fmt.Errorf("попытка: %w", &HTTPError{Status: 503}), ReasonUnavailable, 0},
+		{&HTTPError{Status: 504}, ReasonUnavailable, 0},
+		{&HTTPError{Status: 401}, "", 0},
+		{&HTTPError{Status: 500}, "", 0},
+		{errors.New("HTTP 503 в тексте не считается"), "", 0},
+	}
+	for _, c := range cases {
+		reason, after, ok := Classify(c.err)
+		if reason != c.reason || after != c.after || ok != (c.reason != "") {
+			t.Errorf("%v: %q %v %v", c.err, reason, after, ok)
+		}
+	}
+}
+
+// TestParseRetryAfter — секунды и HTTP-дата.
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	cases := map[string]time.Duration{
+		"":                              0,
+		"7":                             7 * time.Second,
+		"-1":                            0,
+		"Sun, 18 Oct 2026 12:00:30 GMT": 30 * time.Second,
+		"Sun, 18 Oct 2026 11:00:00 GMT": 0,
+		"скоро":                         0,
+	}
+	for value, want := range cases {
+		if got := ParseRetryAfter(value, now); got != want {
+			t.Errorf("%q: %v, ожидалось %v", value, got, want)
+		}
+	}
+}
+
+// TestDelay — экспонента с jitter, предел и Retry-After.
+func TestDelay(t *testing.T) {
+	min := Policy{BaseDelay: time.Second, MaxDelay: 10 * time.Second, Rand: func() float64 { return 0 }}
+	max := min
+	max.Rand = func() float64 { return 0.999999 }
+	for attempt, want := range map[int]time.Duration{1: time.Second, 2: 2 * time.Second, 3: 4 * time.Second, 5: 10 * time.Second} {
+		lo, _ := min.Delay(attempt, 0)
+		hi, _ := max.Delay(attempt, 0)
+		if lo != want/2 || hi < want-time.Millisecond || hi > want {
+			t.Errorf("попытка %d: %v..%v, ожидалось %v..%v", attempt, lo, hi, want/2, want)
+		}
+	}
+	if d, ok := min.Delay(1, 8*time.Second); !ok || d != 8*time.Second {
+		t.Errorf("Retry-After: %v %v", d, ok)
+	}
+	if _, ok := min.Delay(1, time.Hour); ok {
+		t.Error("Retry-After больше предела — без повтора")
+	}
+}
+
+// TestBudget — лимит повторов на провайдер в окне.
+func TestBudget(t *testing.T) {
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	b := NewBudget(2, time.Minute)
+	b.now = func() time.Time { return now }
+	if !b.Allow("openai") || !b.Allow("openai") || b.Allow("openai") {
+		t.Error("третий повтор за минуту должен быть отклонён")
+	}
+	if b.Remaining("openai") != 0 || b.Remaining("ollama") != 2 || !b.Allow("ollama") {
+		t.Error("бюджеты провайдеров независимы")
+	}
+	now = now.Add(61 * time.Second)
+	if b.Remaining("openai") != 2 {
+		t.Errorf("окно прошло, осталось %d", b.Remaining("openai"))
+	}
+	if NewBudget(0, time.Minute).Remaining("openai") != -1 {
+		t.Error("без ограничения")
+	}
+}
@@ -0,0 +1,170 @@
+package risk
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// ConfirmTTL — сколько ждать ответа пользователя на запрос подтверждения.
+const ConfirmTTL = 15 * time.Minute
+
+// Approvals — запросы подтверждения и подтверждённые вызовы по областям
+// (scope): агент в конкретном диалоге или сессии, чтобы «да» одного
+// пользователя не разрешало вызовы, запрошенные в чужом диалоге с тем же
+// агентом. Подтверждение одноразовое: вызов выполняется один раз, затем
+// снова потребует согласия. Пустая область — запрос вне диалога: в ней
+// ничего не запоминается и не разрешается.
+type Approvals struct {
+	mu      sync.Mutex
+	pending map[string]entry // область → ожидающие подтверждения
+	granted map[string]entry // область → подтверждённые
+	now     func() time.Time
+}
+
+type entry struct {
+	calls map[string]Assessment // Key → оценка
+	until time.Time
+}
+
+// NewApprovals — пустое хранилище.
+func NewApprovals() *Approvals {
+	return &Approvals{pending: map[string]entry{}, granted: map[string]entry{}, now: time.Now}
+}
+
+// Request — запоминает вызовы, для которых у пользователя спрошено подтверждение.
+func (a *Approvals) Request(scope string, calls []Assessment) {
+	if scope == "" {
+		return
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	e := a.live(a.pending, scope)
+	for _, c := range calls {
+		e.calls[c.Key()] = c
+	}
+	e.until = a.now().Add(ConfirmTTL)
+	a.pending[scope] = e
+}
+
+// Pending — вызовы области, ожидающие подтверждения.
+func (a *Approvals) Pending(scope string) []Assessment {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	var out []Assessment
+	for _, c := range a.live(a.pending, scope).calls {
+		out = append(out, c)
+	}
+	return out
+}
+
+// Approve — пользователь согласился: ожидающие вызовы разрешаются на ConfirmTTL.
+// Возвращает число разрешённых вызовов.
+func (a *Approvals) Approve(scope string) int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	p := a.live(a.pending, scope)
+	delete(a.pending, scope)
+	if len(p.calls) == 0 {
+		return 0
+	}
+	g := a.live(a.granted, scope)
+	for k, c := range p.calls {
+		g.calls[k] = c
+	}
+	g.until = a.now().Add(ConfirmTTL)
+	a.granted[scope] = g
+	return len(p.calls)
+}
+
+// Reject — пользователь отказался: запросы и разрешения области сбрасываются.
+// Возвращает число отменённых вызовов.
+func (a *Approvals) Reject(scope string) int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	n := len(a.live(a.pending, scope).calls)
+	delete(a.pending, scope)
+	delete(a.granted, scope)
+	return n
+}
+
+// Consume — вызов подтверждён пользователем; разрешение расходуется.
+func (a *Approvals) Consume(scope string, call Assessment) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	g := a.live(a.granted, scope)
+	if _, ok := g.calls[call.Key()]; !ok {
+		return false
+	}
+	delete(g.calls, call.Key())
+	return true
+}
+
+// live — непросроченная запись области (пустая, если нет или истекла).
+func (a *Approvals) live(m map[string]entry, scope string) entry {
+	e, ok := m[scope]
+	if !ok || a.now().After(e.until) {
+		delete(m, scope)
+		return entry{calls: map[string]Assessment{}}
+	}
+	return e
+}
+
+var (
+	yesRe = regexp.MustCompile(`^(да|ага|подтверждаю|подтверждено|выполняй|выполни|делай|давай|разрешаю|согласен|согласна|ок|ok|okay|yes|y|confirm)([\s,.!]|$)`)
+	noRe  = regexp.MustCompile(`^(нет|не надо|не нужно|не выполняй|отмена|отменить|отмени|стоп|no|n|cancel)([\s,.!]|$)`)
+)
+
+// Scope — область подтверждений агента agent: диалог conversation, иначе
+// сессия session; "" — ни того, ни другого.
+func Scope(agent, conversation, session string) string {
+	switch {
+	case conversation != "":
+		return agent + "\x00chat:" + conversation
+	case session != "":
+		return agent + "\x00session:" + session
+	}
+	return ""
+}
+
+// Answer — ответ пользователя на запрос подтверждения: true — согласие,
+// false — отказ; ok=false — сообщение не похоже ни на то, ни на другое.
+func Answer(message string) (yes, ok bool) {
+	msg := strings.ToLower(strings.TrimSpace(message))
+	if utf8.RuneCountInString(msg) > 60 {
+		return false, false
+	}
+	switch {
+	case noRe.MatchString(msg):
+		return false, true
+	case yesRe.MatchString(msg):
+		return true, true
+	}
+	return false, false
+}
+
+// Prompt — запрос подтверждения, добавляемый к ответу ассистента.
+func Prompt(calls []Assessment) string {
+	var b strings.Builder
+	b.WriteString("\n\n**Требуется подтверждение.** Следующие действия не выполнены, потому что их нельзя отменить:\n")
+	for _, c := range calls {
+		target := c.Target
+		if utf8.RuneCountInString(target) > 200 {
+			target = string([]rune(target)[:200]) + "…"
+		}
+		reason := c.Reason
+		if reason == "" {
+			reason = c.Level.Title()
+		}
+		if target != "" {
+			fmt.Fprintf(&b, "- `%s`: `%s` — %s\n", c.Tool, target, reason)
+		} else {
+			fmt.Fprintf(&b, "- `%s` — %s\n", c.Tool, reason)
+		}
+	}
+	b.WriteString("\nОтветьте «да», чтобы выполнить, или «нет», чтобы отменить.")
+	return b.String()
+}
@@ -0,0 +1,222 @@
+// Package risk — оценка риска вызовов инструментов перед выполнением.
+//
+// Каждый вызов (инструмент и его аргументы — команда, путь) получает уровень:
+// только чтение, изменение, привилегированное действие или разрушительное.
+// Уровень определяется движком правил: базовый уровень инструмента плюс
+// шаблоны команд и путей; итог — наивысший из сработавших. Разрушительные
+// вызовы без автоподтверждения (RISK_AUTO_APPROVE) не выполняются, пока
+// пользователь не ответит «да» на запрос подтверждения.
+package risk
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Level — уровень риска.
+type Level string
+
+const (
+	LevelReadOnly    Level = "read_only"   // Только чтение
+	LevelModifying   Level = "modifying"   // Изменяет файлы или состояние
+	LevelPrivileged  Level = "privileged"  // Права root, системные каталоги, службы, пакеты
+	LevelDestructive Level = "destructive" // Удаление, перезапись, необратимые действия
+)
+
+var levelRank = map[Level]int{LevelReadOnly: 0, LevelModifying: 1, LevelPrivileged: 2, LevelDestructive: 3}
+
+// ParseLevel — уровень из строки правила.
+func ParseLevel(s string) (Level, error) {
+	l := Level(strings.ToLower(strings.TrimSpace(s)))
+	if _, ok := levelRank[l]; !ok {
+		return "", fmt.Errorf("неизвестный уровень %q, ожидается read_only, modifying, privileged или destructive", s)
+	}
+	return l, nil
+}
+
+// AtLeast — уровень не ниже other.
+func (l Level) AtLeast(other Level) bool { return levelRank[l] >= levelRank[other] }
+
+// Title — название уровня для пользователя.
+func (l Level) Title() string {
+	switch l {
+	case LevelReadOnly:
+		return "только чтение"
+	case LevelModifying:
+		return "изменение"
+	case LevelPrivileged:
+		return "привилегированное действие"
+	case LevelDestructive:
+		return "разрушительное действие"
+	}
+	return string(l)
+}
+
+// Rule — правило оценки. Правило срабатывает, если инструмент входит в Tools
+// (пусто — любой) и совпали все заданные шаблоны: Command — по команде,
+// Path — по пути в аргументах. Правило без шаблонов задаёт базовый уровень инструмента.
+type Rule struct {
+	Name    string   `yaml:"name" json:"name"`
+	Level   Level    `yaml:"level" json:"level"`
+	Tools   []string `yaml:"tools,omitempty" json:"tools,omitempty"`
+	Command string   `yaml:"command,omitempty" json:"command,omitempty"`
+	Path    string   `yaml:"path,omitempty" json:"path,omitempty"`
+	Reason  string   `yaml:"reason" json:"reason"`
+
+	command, path *regexp.Regexp
+	tools         map[string]bool
+}
+
+// compile — проверяет правило и готовит шаблоны.
+func (r *Rule) compile() error {
+	if r.Name == "" {
+		return fmt.Errorf("правило без имени (reason %q)", r.Reason)
+	}
+	level, err := ParseLevel(string(r.Level))
+	if err != nil {
+		return fmt.Errorf("правило %s: %w", r.Name, err)
+	}
+	r.Level = level
+	if len(r.Tools) == 0 && r.Command == "" && r.Path == "" {
+		return fmt.Errorf("правило %s: нужны tools, command или path", r.Name)
+	}
+	if r.Command != "" {
+		if r.command, err = regexp.Compile(r.Command); err != nil {
+			return fmt.Errorf("правило %s: command: %w", r.Name, err)
+		}
+	}
+	if r.Path != "" {
+		if r.path, err = regexp.Compile(r.Path); err != nil {
+			return fmt.Errorf("правило %s: path: %w", r.Name, err)
+		}
+	}
+	r.tools = map[string]bool{}
+	for _, t := range r.Tools {
+		r.tools[t] = true
+	}
+	return nil
+}
+
+func (r *Rule) match(call Call) bool {
+	if len(r.tools) > 0 && !r.tools[call.Tool] {
+		return false
+	}
+	if r.command != nil && (call.Command == "" || !r.command.MatchString(call.Command)) {
+		return false
+	}
+	if r.path != nil && (call.Path == "" || !r.path.MatchString(call.Path)) {
+		return false
+	}
+	return true
+}
+
+// Call — вызов инструмента в виде, удобном для правил.
+type Call struct {
+	Tool    string
+	Command string // Команда оболочки (command, commands через « && »)
+	Path    string // Путь (path, file_path)
+}
+
+// NewCall — извлекает команду и путь из аргументов вызова.
+func NewCall(tool string, args map[string]interface{}) Call {
+	c := Call{Tool: tool}
+	if s, ok := args["command"].(string); ok {
+		c.Command = s
+	}
+	switch v := args["commands"].(type) {
+	case []interface{}:
+		var parts []string
+		for _, p := range v {
+			if s, ok := p.(string); ok {
+				parts = append(parts, s)
+			}
+		}
+		c.Command = strings.Join(parts, " && ")
+	case []string:
+		c.Command = strings.Join(v, " && ")
+	case string:
+		c.Command = v
+	}
+	for _, key := range []string{"path", "file_path"} {
+		if s, ok := args[key].(string); ok && s != "" {
+			c.Path = s
+			break
+		}
+	}
+	c.Command = strings.Join(strings.Fields(c.Command), " ")
+	return c
+}
+
+// Target — что именно затрагивает вызов (для сообщений и сравнения при подтверждении).
+func (c Call) Target() string {
+	switch {
+	case c.Command != "":
+		return c.Command
+	case c.Path != "":
+		return c.Path
+	}
+	return ""
+}
+
+// Assessment — оценка вызова.
+type Assessment struct {
+	Tool    string   `json:"tool"`
+	Target  string   `json:"target,omitempty"`
+	Level   Level    `json:"level"`
+	Reason  string   `json:"reason,omitempty"` // Причина итогового уровня
+	Rules   []string `json:"rules,omitempty"`
+	Reasons []string `json:"reasons,omitempty"`
+}
+
+// Key — идентификатор вызова: инструмент и цель.
+func (a Assessment) Key() string { return a.Tool + "\x00" + a.Target }
+
+// Engine — набор правил.
+type Engine struct {
+	rules []Rule
+	// Default — уровень инструмента, для которого нет базового правила
+	Default Level
+}
+
+// NewEngine — движок со встроенными правилами и дополнительными (extra)
+// из RISK_RULES_FILE. Дополнительные правила только добавляются: понизить
+// уровень встроенного правила нельзя.
+func NewEngine(extra ...Rule) (*Engine, error) {
+	e := &Engine{Default: LevelModifying}
+	for _, r := range append(DefaultRules(), extra...) {
+		if err := r.compile(); err != nil {
+			return nil, err
+		}
+		e.rules = append(e.rules, r)
+	}
+	return e, nil
+}
+
+// Classify — оценивает вызов: наивысший уровень среди сработавших правил.
+func (e *Engine) Classify(tool string, args map[string]interface{}) Assessment {
+	call := NewCall(tool, args)
+	a := Assessment{Tool: tool, Target: call.Target(), Level: LevelReadOnly}
+	based := false
+	for i := range e.rules {
+		r := &e.rules[i]
+		if !r.match(call) {
+			continue
+		}
+		if r.command == nil && r.path == nil {
+			based = true
+		}
+		if !a.Level.AtLeast(r.Level) {
+			a.Level, a.Reason = r.Level, r.Reason
+		}
+		if r.Level != LevelReadOnly {
+			a.Rules = append(a.Rules, r.Name)
+			a.Reasons = append(a.Reasons, r.Reason)
+		}
+	}
+	if !based && !a.Level.AtLeast(e.Default) {
+		a.Level, a.Reason = e.Default, "инструмент без правила"
+		a.Reasons = append(a.Reasons, a.Reason)
+	}
+	return a
+}
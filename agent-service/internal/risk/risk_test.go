@@ -0,0 +1,137 @@
+package risk
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestClassify — уровни типичных вызовов.
+func TestClassify(t *testing.T) {
+	e, err := NewEngine()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		tool string
+		args map[string]interface{}
+		want Level
+	}{
+		{"execute", map[string]interface{}{"command": "ls -la /var/log && df -h"}, LevelReadOnly},
+		{"execute", map[string]interface{}{"command": "journalctl -u nginx 2>&1 | tail -n 50 > /dev/null"}, LevelReadOnly},
+		{"execute", map[string]interface{}{"command": "echo ok > report.txt"}, LevelModifying},
+		{"execute", map[string]interface{}{"command": "git commit -am fix"}, LevelModifying},
+		{"execute", map[string]interface{}{"command": "sudo systemctl restart nginx"}, LevelPrivileged},
+		{"execute", map[string]interface{}{"command": "apt-get -y install htop"}, LevelPrivileged},
+		{"execute", map[string]interface{}{"command": "cd /tmp && rm -rf build"}, LevelDestructive},
+		{"execute", map[string]interface{}{"command": "sudo  rm   /etc/nginx/sites-enabled/default"}, LevelDestructive},
+		{"execute", map[string]interface{}{"command": "git push --force origin main"}, LevelDestructive},
+		{"run_commands", map[string]interface{}{"commands": []interface{}{"docker ps", "docker system prune -af"}}, LevelDestructive},
+		{"execute", map[string]interface{}{"command": `psql -c "DROP TABLE users"`}, LevelDestructive},
+		{"read", map[string]interface{}{"path": "/etc/hosts"}, LevelReadOnly},
+		{"write", map[string]interface{}{"path": "/home/user/notes.md", "content": "x"}, LevelModifying},
+		{"write", map[string]interface{}{"path": "/etc/hosts", "content": "x"}, LevelPrivileged},
+		{"delete", map[string]interface{}{"path": "/home/user/old.log"}, LevelDestructive},
+		{"ollama_delete", map[string]interface{}{"name": "llama3:8b"}, LevelDestructive},
+		{"install_packages", map[string]interface{}{"packages": []interface{}{"htop"}}, LevelPrivileged},
+		{"unknown_tool", nil, LevelModifying},
+	}
+	for _, c := range cases {
+		a := e.Classify(c.tool, c.args)
+		if a.Level != c.want {
+			t.Errorf("%s %v: %s (%v), ожидалось %s", c.tool, c.args, a.Level, a.Rules, c.want)
+		}
+	}
+	a := e.Classify("execute", map[string]interface{}{"command": "sudo  rm   /etc/x"})
+	if a.Target != "sudo rm /etc/x" || a.Reason != "удаление файлов" {
+		t.Errorf("цель и причина: %+v", a)
+	}
+}
+
+// TestLoadRules — пользовательские правила добавляются к встроенным, ошибки сообщаются.
+func TestLoadRules(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "risk.yaml")
+	os.WriteFile(path, []byte("rules:\n  - name: prod_db\n    level: destructive\n    command: 'psql\\s.*prod'\n    reason: боевая база\n"), 0o644)
+	rules, err := LoadRules(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e, err := NewEngine(rules...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a := e.Classify("execute", map[string]interface{}{"command": "psql -h prod-db -c 'select 1'"}); a.Level != LevelDestructive || a.Reason != "боевая база" {
+		t.Errorf("пользовательское правило: %+v", a)
+	}
+	if _, err := NewEngine(Rule{Name: "bad", Level: "dangerous", Command: "x"}); err == nil {
+		t.Error("ожидалась ошибка неизвестного уровня")
+	}
+}
+
+// TestApprovals — подтверждение одноразовое, отказ сбрасывает запрос, запрос истекает.
+func TestApprovals(t *testing.T) {
+	now := time.Now()
+	a := NewApprovals()
+	a.now = func() time.Time { return now }
+	call := Assessment{Tool: "execute", Target: "rm -rf build", Level: LevelDestructive}
+
+	a.Request("admin", []Assessment{call})
+	if a.Consume("admin", call) {
+		t.Error("без согласия вызов не разрешён")
+	}
+	if n := a.Approve("admin"); n != 1 || !a.Consume("admin", call) || a.Consume("admin", call) {
+		t.Errorf("согласие разрешает вызов один раз (n=%d)", n)
+	}
+
+	a.Request("admin", []Assessment{call})
+	if n := a.Reject("admin"); n != 1 || a.Approve("admin") != 0 {
+		t.Error("отказ сбрасывает запрос")
+	}
+
+	a.Request("admin", []Assessment{call})
+	now = now.Add(ConfirmTTL + time.Second)
+	if len(a.Pending("admin")) != 0 || a.Approve("admin") != 0 {
+		t.Error("запрос должен истечь")
+	}
+}
+
+// TestApprovals_Scope — согласие в одном диалоге не разрешает вызов в другом
+// диалоге того же агента; вне диалога и сессии подтверждать нечего.
+func TestApprovals_Scope(t *testing.T) {
+	a := NewApprovals()
+	call := Assessment{Tool: "execute", Target: "rm -rf build", Level: LevelDestructive}
+	mine, other := Scope("admin", "chat-1", ""), Scope("admin", "chat-2", "")
+	if mine == other || Scope("admin", "", "s-1") == mine || Scope("admin", "", "") != "" {
+		t.Fatal("области должны различаться по диалогу и сессии")
+	}
+	a.Request(mine, []Assessment{call})
+	if len(a.Pending(other)) != 0 || a.Approve(other) != 0 || a.Consume(other, call) {
+		t.Error("чужой диалог не должен видеть и подтверждать запрос")
+	}
+	if a.Approve(mine) != 1 || a.Consume(other, call) || !a.Consume(mine, call) {
+		t.Error("разрешение действует только в своём диалоге")
+	}
+	a.Request("", []Assessment{call})
+	if a.Approve("") != 0 {
+		t.Error("вне диалога запрос не запоминается")
+	}
+}
+
+// TestAnswer — распознавание согласия и отказа.
+func TestAnswer(t *testing.T) {
+	for msg, want := range map[string]bool{"Да": true, "да, выполняй": true, "ok": true, "Нет.": false, "отмена": false} {
+		if yes, ok := Answer(msg); !ok || yes != want {
+			t.Errorf("%q: yes=%v ok=%v", msg, yes, ok)
+		}
+	}
+	for _, msg := range []string{"давление в шинах", "данные за вчера покажи", "а что будет, если удалить build?"} {
+		if _, ok := Answer(msg); ok {
+			t.Errorf("%q не ответ на подтверждение", msg)
+		}
+	}
+	if p := Prompt([]Assessment{{Tool: "delete", Target: "/srv/app", Level: LevelDestructive, Reason: "удаление"}}); !strings.Contains(p, "`/srv/app`") || !strings.Contains(p, "«да»") {
+		t.Errorf("запрос подтверждения: %s", p)
+	}
+}
@@ -0,0 +1,103 @@
+package risk
+
+import (
+	"fmt"
+	"os"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Шаблоны команд применяются к команде с одинарными пробелами.
+const (
+	cmdStart   = `(?i)(^|[;&|(]\s*|\bsudo\s+|\bxargs\s+)`
+	systemPath = `^(/$|~/\.ssh(/|$)|/(etc|boot|usr|bin|sbin|lib|lib64|opt|root|srv|var/lib)(/|$))`
+)
+
+// DefaultRules — встроенные правила: базовые уровни инструментов и шаблоны команд и путей.
+func DefaultRules() []Rule {
+	return []Rule{
+		// --- Базовые уровни инструментов ---
+		{Name: "read_tools", Level: LevelReadOnly, Reason: "инструмент только читает данные", Tools: []string{
+			"read", "list", "sysinfo", "cputemp", "sysload", "disk_usage", "findapp", "view_logs", "search_knowledge", "full_system_report",
+			"check_stack", "diagnose_service", "security_audit", "check_resources_batch", "web_research", "internet_search",
+			"crawler_fetch", "crawler_robots_txt", "check_url_access", "check_multiple_urls", "prometheus_query",
+			"net_ping", "net_traceroute", "net_dns", "net_public_ip", "net_interfaces",
+			"get_agent_info", "list_models_for_role", "ollama_ps", "lint_code", "mail_list", "mail_read",
+			"calendar_list", "home_sensor", "k8s_pods", "k8s_deployments", "k8s_events", "k8s_describe", "k8s_logs",
+			"browser_get_dom", "browser_get_text", "browser_get_title", "browser_screenshot", "browser_pdf",
+			"browser_detect_captcha", "get_artifact",
+		}},
+		// Команды оболочки оцениваются по шаблонам ниже
+		{Name: "shell_tools", Level: LevelReadOnly, Reason: "команда оболочки", Tools: []string{"execute", "run_commands", "debug_code", "run_code"}},
+		{Name: "write_tools", Level: LevelModifying, Reason: "инструмент изменяет файлы или настройки", Tools: []string{
+			"write", "edit_file", "create_script", "format_code", "generate_report", "project_init", "configure_agent",
+			"git_pull_request", "import_issue", "ollama_pull", "setup_git_automation", "mail_send", "calendar_create",
+			"calendar_update", "home_switch", "home_scene", "launchapp", "browser_open_visible", "browser_execute_js",
+			"input_key_press", "input_type_text", "input_mouse_click", "input_mouse_scroll", "input_tab_action",
+			"input_window_action", "input_clipboard",
+		}},
+		{Name: "system_tools", Level: LevelPrivileged, Reason: "установка пакетов, автозапуск и расписание", Tools: []string{"install_packages", "addautostart", "setup_cron_job"}},
+		{Name: "delete_tools", Level: LevelDestructive, Reason: "удаление без возможности восстановления", Tools: []string{"delete", "ollama_delete"}},
+
+		// --- Пути ---
+		{Name: "system_path", Level: LevelPrivileged, Reason: "системный каталог или ключи SSH", Path: systemPath,
+			Tools: []string{"write", "edit_file", "create_script", "format_code", "project_init"}},
+		{Name: "delete_system_path", Level: LevelDestructive, Reason: "удаление в системном каталоге", Path: systemPath, Tools: []string{"delete"}},
+
+		// --- Команды: разрушительные ---
+		{Name: "rm", Level: LevelDestructive, Reason: "удаление файлов", Command: cmdStart + `(rm|rmdir|shred|unlink)\s`},
+		{Name: "find_delete", Level: LevelDestructive, Reason: "массовое удаление через find", Command: `(?i)\bfind\s.*(-delete\b|-exec\s+rm\b)`},
+		{Name: "disk_format", Level: LevelDestructive, Reason: "форматирование или перезапись диска", Command: cmdStart + `(mkfs(\.\w+)?|wipefs|fdisk|parted|sgdisk)\b|\bdd\s.*\bof=|>\s*/dev/(sd|nvme|vd|hd)`},
+		{Name: "truncate", Level: LevelDestructive, Reason: "очистка содержимого файла", Command: cmdStart + `truncate\s|(^|[;&|]\s*)(:|true)?\s*>\s*[^\s&>]`},
+		{Name: "git_destructive", Level: LevelDestructive, Reason: "необратимая операция git", Command: `(?i)\bgit\s+(push\s.*(--force\b|-f\b)|reset\s+--hard|clean\s+-\w*f|branch\s+-D\b|checkout\s+--\s|stash\s+(drop|clear))`},
+		{Name: "sql_destructive", Level: LevelDestructive, Reason: "удаление данных в базе", Command: `(?i)\b(drop\s+(table|database|schema|index)|truncate\s+table|delete\s+from)\b`},
+		{Name: "container_destructive", Level: LevelDestructive, Reason: "удаление контейнеров, образов или томов", Command: `(?i)\b(docker|podman)\s+(rm|rmi|volume\s+rm|system\s+prune|image\s+prune|container\s+prune|compose\s+down\s.*(-v|--volumes))\b|\bkubectl\s+delete\b|\bhelm\s+(uninstall|delete)\b`},
+		{Name: "power", Level: LevelDestructive, Reason: "выключение или перезагрузка системы", Command: cmdStart + `(shutdown|reboot|poweroff|halt)\b|\bsystemctl\s+(reboot|poweroff|halt)\b`},
+		{Name: "accounts_destructive", Level: LevelDestructive, Reason: "удаление пользователей или расписания", Command: cmdStart + `(userdel|groupdel)\b|\bcrontab\s+-r\b`},
+		{Name: "kill_all", Level: LevelDestructive, Reason: "завершение процессов по имени", Command: cmdStart + `(killall|pkill)\s|\bkill\s+-9\s+-1\b`},
+
+		// --- Команды: привилегированные ---
+		{Name: "sudo", Level: LevelPrivileged, Reason: "выполнение с правами root", Command: `(?i)(^|[;&|(]\s*)(sudo|su|doas|pkexec)\b`},
+		{Name: "services", Level: LevelPrivileged, Reason: "управление системными службами", Command: `(?i)\b(systemctl|service)\s+(\S+\s+)?(start|stop|restart|reload|enable|disable|mask|unmask|daemon-reload)\b`},
+		{Name: "packages", Level: LevelPrivileged, Reason: "установка или удаление пакетов", Command: cmdStart + `(apt|apt-get|dnf|yum|zypper|pacman|apk|snap|flatpak)\s+(-\S+\s+)*(install|remove|purge|upgrade|dist-upgrade|autoremove|-S|-R)\b`},
+		{Name: "permissions", Level: LevelPrivileged, Reason: "смена владельца или прав", Command: cmdStart + `(chmod|chown|chgrp|setfacl|chattr)\s`},
+		{Name: "accounts", Level: LevelPrivileged, Reason: "управление пользователями и паролями", Command: cmdStart + `(useradd|usermod|groupadd|passwd|chpasswd|visudo)\b`},
+		{Name: "network", Level: LevelPrivileged, Reason: "изменение сетевых правил", Command: cmdStart + `(iptables|ip6tables|nft|ufw|firewall-cmd)\s|\bip\s+(link|addr|route)\s+(add|del|set)\b`},
+		{Name: "kernel", Level: LevelPrivileged, Reason: "монтирование, модули ядра, параметры ядра", Command: cmdStart + `(mount|umount|modprobe|rmmod|insmod|swapoff|swapon)\b|\bsysctl\s+-w\b`},
+		{Name: "system_write", Level: LevelPrivileged, Reason: "запись в системный каталог", Command: `(?i)(>|\btee\s+(-a\s+)?|\b(cp|mv|ln|sed\s+-i)\s.*\s)/(etc|boot|usr|bin|sbin|lib|root)/`},
+
+		// --- Команды: изменяющие ---
+		{Name: "fs_write", Level: LevelModifying, Reason: "создание, перемещение или изменение файлов", Command: cmdStart + `(mv|cp|mkdir|touch|ln|tee|install|rsync|tar\s+-?\w*x|unzip)\s|\bsed\s+-i\b|[^2&>]>\s*([^\s&/]|/[^\sd]|/d[^e])|>>`},
+		{Name: "git_write", Level: LevelModifying, Reason: "изменение репозитория git", Command: `(?i)\bgit\s+(commit|push|pull|merge|rebase|checkout|switch|reset|add|rm|mv|tag|stash)\b`},
+		{Name: "containers", Level: LevelModifying, Reason: "запуск или остановка контейнеров", Command: `(?i)\b(docker|podman)\s+(run|start|stop|restart|kill|compose\s+(up|down|restart|stop))\b|\bkubectl\s+(apply|create|scale|rollout|patch|edit|set)\b`},
+		{Name: "processes", Level: LevelModifying, Reason: "завершение процесса", Command: cmdStart + `kill\s`},
+		{Name: "lang_packages", Level: LevelModifying, Reason: "установка зависимостей", Command: `(?i)\b(pip3?|npm|yarn|pnpm|go|cargo|gem)\s+(install|add|get|uninstall|remove)\b`},
+		{Name: "cron", Level: LevelModifying, Reason: "изменение расписания", Command: `(?i)\bcrontab\s+(-e\b|\S+$)`},
+	}
+}
+
+// LoadRules — дополнительные правила из YAML-файла (RISK_RULES_FILE):
+//
+//	rules:
+//	  - name: prod_db
+//	    level: destructive
+//	    command: 'psql\s.*prod'
+//	    reason: запрос к боевой базе
+//	  - name: deploy_dir
+//	    level: privileged
+//	    tools: [write, edit_file]
+//	    path: '^/srv/deploy/'
+//	    reason: каталог выкладки
+func LoadRules(path string) ([]Rule, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var file struct {
+		Rules []Rule `yaml:"rules"`
+	}
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("разбор %s: %w", path, err)
+	}
+	return file.Rules, nil
+}
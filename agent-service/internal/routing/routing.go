@@ -0,0 +1,188 @@
+// Package routing — выбор модели под каждый запрос по его сложности.
+//
+// В режиме маршрутизации простые запросы (приветствия, короткие вопросы,
+// перевод слова) уходят быстрой дешёвой модели, а сложные (код, анализ,
+// многошаговые задачи, действия через инструменты) — основной модели агента.
+// Сложность оценивает эвристика или, если задана, маленькая модель-классификатор;
+// при ошибке классификатора используется эвристика.
+package routing
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/neo-2022/openclaw-memory/agent-service/internal/llm"
+)
+
+// Уровни модели.
+const (
+	TierFast   = "fast"   // Быстрая модель
+	TierStrong = "strong" // Основная модель агента
+)
+
+// Способы оценки сложности.
+const (
+	ClassifierHeuristic = "heuristic"
+	ClassifierModel     = "model"
+)
+
+// Decision — выбор модели для запроса; возвращается клиенту в ChatResponse.routing.
+type Decision struct {
+	Tier       string   `json:"tier"`
+	Provider   string   `json:"provider"`
+	Model      string   `json:"model"`
+	Score      float64  `json:"score"` // Сложность 0..1
+	Threshold  float64  `json:"threshold"`
+	Classifier string   `json:"classifier"`
+	Reasons    []string `json:"reasons,omitempty"`
+}
+
+// Target — провайдер и модель.
+type Target struct {
+	Provider string
+	Model    string
+}
+
+// Router — настройки маршрутизации.
+type Router struct {
+	Fast      Target
+	Threshold float64 // Сложность, начиная с которой выбирается основная модель
+
+	// Classifier — маленькая модель для оценки сложности (nil — только эвристика).
+	Classifier      llm.ChatProvider
+	ClassifierModel string
+}
+
+// Input — запрос, который нужно направить.
+type Input struct {
+	Message        string // Последнее сообщение пользователя
+	Turns          int    // Число сообщений в диалоге
+	HasImages      bool
+	HasAttachments bool
+}
+
+// Route — выбирает между быстрой моделью и основной (strong).
+func (r *Router) Route(in Input, strong Target) Decision {
+	score, reasons := Score(in)
+	d := Decision{Threshold: r.Threshold, Classifier: ClassifierHeuristic, Reasons: reasons}
+	if r.Classifier != nil && r.ClassifierModel != "" {
+		if s, err := r.classify(in.Message); err == nil {
+			// Явные признаки (вложения, изображения) классификатор не видит — берём максимум
+			if s > score {
+				score = s
+			}
+			d.Classifier = ClassifierModel
+		} else {
+			d.Reasons = append(d.Reasons, "классификатор недоступен: "+err.Error())
+		}
+	}
+	d.Score = round(score)
+	if d.Score >= r.Threshold || r.Fast.Model == "" {
+		d.Tier, d.Provider, d.Model = TierStrong, strong.Provider, strong.Model
+	} else {
+		d.Tier, d.Provider, d.Model = TierFast, r.Fast.Provider, r.Fast.Model
+	}
+	return d
+}
+
+// signal — признак сложности: вес и пояснение.
+type signal struct {
+	re     *regexp.Regexp
+	weight float64
+	reason string
+}
+
+var signals = []signal{
+	{regexp.MustCompile("```|\\bfunc\\b|\\bdef\\b|\\bclass\\b|=>|;\\s*$"), 0.45, "код в запросе"},
+	{regexp.MustCompile(`(напиши|исправь|отладь|оптимизируй|отрефактори|реализуй)\S*\s+(код|функци|скрипт|класс|программ|тест|sql|запрос)|рефакторинг|алгоритм`), 0.45, "программирование"},
+	{regexp.MustCompile(`проанализируй|сравни|оцени|обоснуй|докажи|спроектируй|архитектур|разработай план|составь план|стратеги`), 0.45, "анализ"},
+	{regexp.MustCompile(`почему|объясни|в чём разница|чем отличается|как работает`), 0.2, "объяснение"},
+	{regexp.MustCompile(`(сначала|затем|после этого|потом|в конце)\s|\n\s*(\d+[.)]|[-*])\s`), 0.25, "несколько шагов"},
+	{regexp.MustCompile(`(создай|удали|запусти|выполни|установи|перезапусти|скачай|открой|найди|перемести|переименуй|отправь)\S*\s+\S`), 0.35, "действие через инструменты"},
+	{regexp.MustCompile(`(файл|папк|каталог|директори|репозитори|сервер|контейнер|деплой)`), 0.15, "работа с системой"},
+}
+
+// simple — короткие бытовые реплики, с которыми справится любая модель.
+var simple = regexp.MustCompile(`^(привет|здравствуй|добрый|спасибо|благодарю|пока|ок|ладно|да|нет|как дела|кто ты|что ты умеешь|переведи)`)
+
+// Score — эвристическая оценка сложности 0..1 и признаки, которые на неё повлияли.
+func Score(in Input) (float64, []string) {
+	msg := strings.ToLower(strings.TrimSpace(in.Message))
+	var score float64
+	var reasons []string
+	add := func(w float64, reason string) {
+		score += w
+		reasons = append(reasons, reason)
+	}
+	if in.HasAttachments {
+		add(0.6, "вложения")
+	}
+	if in.HasImages {
+		add(0.6, "изображения")
+	}
+	switch n := utf8.RuneCountInString(msg); {
+	case n > 1500:
+		add(0.5, "длинный запрос")
+	case n > 400:
+		add(0.25, "развёрнутый запрос")
+	case n < 60 && simple.MatchString(msg):
+		add(-0.2, "короткая реплика")
+	}
+	for _, s := range signals {
+		if s.re.MatchString(msg) {
+			add(s.weight, s.reason)
+		}
+	}
+	if in.Turns > 12 {
+		add(0.15, "длинный диалог")
+	}
+	if score < 0 {
+		score = 0
+	}
+	if score > 1 {
+		score = 1
+	}
+	return score, reasons
+}
+
+// classifierPrompt — инструкция модели-классификатору.
+const classifierPrompt = "Оцени сложность запроса пользователя для ИИ-ассистента по шкале от 0 до 10: " +
+	"0–3 — приветствие, короткий факт, перевод слова; 4–6 — объяснение, небольшой текст; " +
+	"7–10 — код, анализ, многошаговая задача, действия в системе. Ответь только числом.\n\nЗапрос: "
+
+var number = regexp.MustCompile(`\d+(?:[.,]\d+)?`)
+
+// classify — оценка сложности моделью-классификатором (0..1).
+func (r *Router) classify(message string) (float64, error) {
+	if utf8.RuneCountInString(message) > 2000 {
+		message = string([]rune(message)[:2000])
+	}
+	resp, err := r.Classifier.Chat(&llm.ChatRequest{
+		Model:    r.ClassifierModel,
+		Messages: []llm.Message{{Role: "user", Content: classifierPrompt + message}},
+	})
+	if err != nil {
+		return 0, err
+	}
+	m := number.FindString(resp.Content)
+	if m == "" {
+		return 0, fmt.Errorf("нет оценки в ответе %q", truncate(resp.Content, 40))
+	}
+	v, err := strconv.ParseFloat(strings.Replace(m, ",", ".", 1), 64)
+	if err != nil || v < 0 || v > 10 {
+		return 0, fmt.Errorf("оценка вне шкалы: %q", m)
+	}
+	return v / 10, nil
+}
+
+func truncate(s string, n int) string {
+	if r := []rune(s); len(r) > n {
+		return string(r[:n]) + "…"
+	}
+	return s
+}
+
+func round(f float64) float64 { return float64(int(f*100+0.5)) / 100 }
@@ -0,0 +1,67 @@
+package routing
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/neo-2022/openclaw-memory/agent-service/internal/llm"
+)
+
+var strong = Target{Provider: "openrouter", Model: "qwen3-coder"}
+
+// TestRouteHeuristic — простые реплики уходят быстрой модели, код и действия — основной.
+func TestRouteHeuristic(t *testing.T) {
+	r := &Router{Fast: Target{Provider: "ollama", Model: "qwen2.5:1.5b"}, Threshold: 0.4}
+	tests := []struct {
+		in   Input
+		tier string
+	}{
+		{Input{Message: "Привет! Как дела?"}, TierFast},
+		{Input{Message: "Сколько будет 2+2?"}, TierFast},
+		{Input{Message: "Напиши функцию на Go, которая разворачивает строку"}, TierStrong},
+		{Input{Message: "Создай файл notes.txt в домашней папке"}, TierStrong},
+		{Input{Message: "Проанализируй архитектуру сервиса и составь план миграции"}, TierStrong},
+		{Input{Message: "Что в документе?", HasAttachments: true}, TierStrong},
+	}
+	for _, tc := range tests {
+		d := r.Route(tc.in, strong)
+		if d.Tier != tc.tier {
+			t.Errorf("%q: %s (score %.2f, %v), ожидался %s", tc.in.Message, d.Tier, d.Score, d.Reasons, tc.tier)
+		}
+	}
+	if d := r.Route(Input{Message: "Привет"}, strong); d.Model != "qwen2.5:1.5b" || d.Provider != "ollama" || d.Classifier != ClassifierHeuristic {
+		t.Errorf("решение: %+v", d)
+	}
+}
+
+// classifier — модель-классификатор с заданным ответом.
+type classifier struct {
+	content string
+	err     error
+}
+
+func (c classifier) Name() string                                   { return "ollama" }
+func (c classifier) ListModels() ([]string, error)                  { return nil, nil }
+func (c classifier) ListModelsDetailed() ([]llm.ModelDetail, error) { return nil, nil }
+func (c classifier) Chat(*llm.ChatRequest) (*llm.ChatResponse, error) {
+	return &llm.ChatResponse{Content: c.content}, c.err
+}
+
+// TestRouteClassifier — оценка модели повышает сложность, ошибка — откат к эвристике.
+func TestRouteClassifier(t *testing.T) {
+	r := &Router{Fast: Target{Provider: "ollama", Model: "fast"}, Threshold: 0.5, ClassifierModel: "tiny"}
+
+	r.Classifier = classifier{content: "Оценка: 8"}
+	if d := r.Route(Input{Message: "Как ускорить сборку?"}, strong); d.Tier != TierStrong || d.Score != 0.8 || d.Classifier != ClassifierModel {
+		t.Errorf("классификатор: %+v", d)
+	}
+	r.Classifier = classifier{err: errors.New("timeout")}
+	if d := r.Route(Input{Message: "Спасибо"}, strong); d.Tier != TierFast || d.Classifier != ClassifierHeuristic || len(d.Reasons) == 0 {
+		t.Errorf("откат к эвристике: %+v", d)
+	}
+	// Без быстрой модели маршрутизация всегда выбирает основную
+	r.Fast.Model = ""
+	if d := r.Route(Input{Message: "Спасибо"}, strong); d.Tier != TierStrong {
+		t.Errorf("без быстрой модели: %+v", d)
+	}
+}
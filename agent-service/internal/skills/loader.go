@@ -21,6 +21,8 @@ import (
 	"strings"
 	"sync"
 	"time"
+
+	"github.com/neo-2022/openclaw-memory/agent-service/internal/httpclient"
 )
 
 // SkillParameter — описание параметра навыка.
@@ -96,11 +98,9 @@ type SkillLoader struct {
 // Автоматически загружает все навыки из директории при создании.
 func NewSkillLoader(skillsDir string) (*SkillLoader, error) {
 	loader := &SkillLoader{
-		skillsDir: skillsDir,
-		skills:    make(map[string]*Skill),
-		httpClient: &http.Client{
-			Timeout: 30 * time.Second,
-		},
+		skillsDir:  skillsDir,
+		skills:     make(map[string]*Skill),
+		httpClient: httpclient.New("skills", 30*time.Second),
 	}
 
 	// Загружаем все навыки из директории
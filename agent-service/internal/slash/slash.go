@@ -0,0 +1,96 @@
+// Package slash — разбор slash-команд чата (/model, /provider, /clear, /tools, /workspace).
+//
+// Команды позволяют перенастроить агента, не выходя из чата: chatHandler
+// разбирает последнее сообщение до вызова LLM и, если это известная команда,
+// выполняет её и отвечает сразу. Сообщения, начинающиеся с «/», но не
+// являющиеся известной командой (например, путь «/etc/hosts что это?»),
+// передаются модели как обычный текст.
+package slash
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Имена команд.
+const (
+	CmdHelp      = "help"
+	CmdModel     = "model"
+	CmdProvider  = "provider"
+	CmdClear     = "clear"
+	CmdTools     = "tools"
+	CmdWorkspace = "workspace"
+)
+
+// Spec — описание команды для /help.
+type Spec struct {
+	Name        string
+	Usage       string
+	Description string
+}
+
+// Commands — известные команды в порядке вывода в /help.
+func Commands() []Spec {
+	return []Spec{
+		{CmdModel, "/model <имя>", "сменить модель агента (без аргумента — текущая)"},
+		{CmdProvider, "/provider <имя>", "сменить провайдера LLM (ollama, openai, openrouter, ...)"},
+		{CmdClear, "/clear", "очистить историю чата агента"},
+		{CmdTools, "/tools on|off", "включить или отключить инструменты агента"},
+		{CmdWorkspace, "/workspace <имя>", "привязать агента к рабочему пространству"},
+		{CmdHelp, "/help", "список команд"},
+	}
+}
+
+// Command — разобранная команда.
+type Command struct {
+	Name string   // Имя без «/», в нижнем регистре
+	Args []string // Аргументы, разделённые пробелами
+}
+
+// Arg — первый аргумент или пустая строка.
+func (c Command) Arg() string {
+	if len(c.Args) == 0 {
+		return ""
+	}
+	return c.Args[0]
+}
+
+// Parse — распознаёт известную команду в сообщении.
+func Parse(msg string) (Command, bool) {
+	msg = strings.TrimSpace(msg)
+	if !strings.HasPrefix(msg, "/") {
+		return Command{}, false
+	}
+	fields := strings.Fields(msg[1:])
+	if len(fields) == 0 {
+		return Command{}, false
+	}
+	name := strings.ToLower(fields[0])
+	for _, spec := range Commands() {
+		if spec.Name == name {
+			return Command{Name: name, Args: fields[1:]}, true
+		}
+	}
+	return Command{}, false
+}
+
+// ParseSwitch — аргумент вида on/off (вкл/выкл) для /tools.
+func ParseSwitch(arg string) (bool, error) {
+	switch strings.ToLower(arg) {
+	case "on", "вкл", "true", "1":
+		return true, nil
+	case "off", "выкл", "false", "0":
+		return false, nil
+	}
+	return false, fmt.Errorf("ожидалось on или off, получено %q", arg)
+}
+
+// Help — текст ответа на /help.
+func Help() string {
+	var b strings.Builder
+	b.WriteString("Доступные команды:\n")
+	for _, spec := range Commands() {
+		fmt.Fprintf(&b, "  %s — %s\n", spec.Usage, spec.Description)
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
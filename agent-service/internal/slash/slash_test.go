@@ -0,0 +1,38 @@
+package slash
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestParse — известные команды распознаются, пути и неизвестные команды — нет.
+func TestParse(t *testing.T) {
+	cmd, ok := Parse("  /Model llama3.1:8b ")
+	if !ok || cmd.Name != CmdModel || cmd.Arg() != "llama3.1:8b" {
+		t.Fatalf("ожидалась /model llama3.1:8b, получено %+v, %v", cmd, ok)
+	}
+	if cmd, ok := Parse("/clear"); !ok || cmd.Name != CmdClear || cmd.Arg() != "" {
+		t.Errorf("ожидалась /clear без аргументов, получено %+v", cmd)
+	}
+	for _, msg := range []string{"/etc/hosts что это?", "/", "привет /model", "/unknown x"} {
+		if _, ok := Parse(msg); ok {
+			t.Errorf("%q не должно распознаваться как команда", msg)
+		}
+	}
+}
+
+// TestParseSwitch — on/off и русские варианты.
+func TestParseSwitch(t *testing.T) {
+	if on, err := ParseSwitch("OFF"); err != nil || on {
+		t.Errorf("off: %v, %v", on, err)
+	}
+	if on, err := ParseSwitch("вкл"); err != nil || !on {
+		t.Errorf("вкл: %v, %v", on, err)
+	}
+	if _, err := ParseSwitch("maybe"); err == nil {
+		t.Error("ожидалась ошибка для неизвестного значения")
+	}
+	if !strings.Contains(Help(), "/tools on|off") {
+		t.Error("в /help нет команды /tools")
+	}
+}
@@ -0,0 +1,147 @@
+package smarthome
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/neo-2022/openclaw-memory/agent-service/internal/httpclient"
+)
+
+// HomeAssistant — REST API Home Assistant (/api/states, /api/services).
+type HomeAssistant struct {
+	BaseURL string // http://homeassistant.local:8123
+	Token   string // Long-lived access token
+	HTTP    *http.Client
+}
+
+// NewHomeAssistant — клиент с таймаутом 10 секунд.
+func NewHomeAssistant(baseURL, token string) *HomeAssistant {
+	return &HomeAssistant{
+		BaseURL: strings.TrimRight(baseURL, "/"),
+		Token:   token,
+		HTTP:    httpclient.New("homeassistant", 10*time.Second),
+	}
+}
+
+// Entity — состояние сущности в компактном виде.
+type Entity struct {
+	EntityID    string                 `json:"entity_id"`
+	Name        string                 `json:"name,omitempty"`
+	State       string                 `json:"state"`
+	Unit        string                 `json:"unit,omitempty"`
+	LastChanged time.Time              `json:"last_changed"`
+	Attributes  map[string]interface{} `json:"attributes,omitempty"`
+}
+
+type haState struct {
+	EntityID    string                 `json:"entity_id"`
+	State       string                 `json:"state"`
+	LastChanged time.Time              `json:"last_changed"`
+	Attributes  map[string]interface{} `json:"attributes"`
+}
+
+func (s haState) entity(withAttributes bool) Entity {
+	e := Entity{EntityID: s.EntityID, State: s.State, LastChanged: s.LastChanged}
+	e.Name, _ = s.Attributes["friendly_name"].(string)
+	e.Unit, _ = s.Attributes["unit_of_measurement"].(string)
+	if withAttributes {
+		e.Attributes = map[string]interface{}{}
+		for k, v := range s.Attributes {
+			if k != "friendly_name" && k != "unit_of_measurement" && k != "icon" && k != "entity_picture" {
+				e.Attributes[k] = v
+			}
+		}
+	}
+	return e
+}
+
+func (h *HomeAssistant) do(ctx context.Context, method, path string, body interface{}, out interface{}) error {
+	var rd io.Reader
+	if body != nil {
+		data, _ := json.Marshal(body)
+		rd = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, h.BaseURL+path, rd)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+h.Token)
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := h.HTTP.Do(req)
+	if err != nil {
+		return fmt.Errorf("Home Assistant недоступен: %w", err)
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 4<<20))
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized:
+		return fmt.Errorf("Home Assistant отклонил токен — обновите api_key провайдера %s", ProviderHomeAssistant)
+	case resp.StatusCode == http.StatusNotFound:
+		return ErrNotFound
+	case resp.StatusCode >= 300:
+		return fmt.Errorf("Home Assistant вернул %d: %s", resp.StatusCode, strings.TrimSpace(string(data)))
+	}
+	if out != nil {
+		return json.Unmarshal(data, out)
+	}
+	return nil
+}
+
+// State — состояние одной сущности со всеми атрибутами.
+func (h *HomeAssistant) State(ctx context.Context, entityID string) (Entity, error) {
+	var s haState
+	if err := h.do(ctx, http.MethodGet, "/api/states/"+entityID, nil, &s); err != nil {
+		return Entity{}, err
+	}
+	return s.entity(true), nil
+}
+
+// States — сущности доменов domains, имя или id которых содержит query.
+func (h *HomeAssistant) States(ctx context.Context, domains []string, query string) ([]Entity, error) {
+	var all []haState
+	if err := h.do(ctx, http.MethodGet, "/api/states", nil, &all); err != nil {
+		return nil, err
+	}
+	query = strings.ToLower(query)
+	var out []Entity
+	for _, s := range all {
+		if !inDomains(s.EntityID, domains) {
+			continue
+		}
+		e := s.entity(false)
+		if query != "" && !strings.Contains(strings.ToLower(e.EntityID+" "+e.Name), query) {
+			continue
+		}
+		out = append(out, e)
+	}
+	return out, nil
+}
+
+// CallService — вызов службы domain.service для сущности.
+func (h *HomeAssistant) CallService(ctx context.Context, domain, service, entityID string) error {
+	return h.do(ctx, http.MethodPost, "/api/services/"+domain+"/"+service, map[string]string{"entity_id": entityID}, nil)
+}
+
+func domainOf(entityID string) string {
+	d, _, _ := strings.Cut(entityID, ".")
+	return d
+}
+
+func inDomains(entityID string, domains []string) bool {
+	if len(domains) == 0 {
+		return true
+	}
+	d := domainOf(entityID)
+	for _, x := range domains {
+		if d == x {
+			return true
+		}
+	}
+	return false
+}
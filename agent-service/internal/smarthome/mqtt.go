@@ -0,0 +1,271 @@
+package smarthome
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// MQTT — брокер MQTT 3.1.1: публикация команд и чтение последнего (retained)
+// значения топика. Соединение открывается на каждую операцию — команды
+// агента редкие, держать сессию незачем.
+type MQTT struct {
+	Addr     string // host:port
+	TLS      bool
+	User     string
+	Password string
+	Timeout  time.Duration
+}
+
+// ParseMQTT — брокер из base_url (mqtt://host:1883, mqtts://host:8883, tcp://, ssl://)
+// и api_key вида user:password.
+func ParseMQTT(baseURL, credentials string) (*MQTT, error) {
+	u, err := url.Parse(baseURL)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("некорректный адрес брокера %q: ожидается mqtt://host:1883", baseURL)
+	}
+	m := &MQTT{Timeout: 5 * time.Second}
+	switch u.Scheme {
+	case "mqtt", "tcp":
+	case "mqtts", "ssl", "tls":
+		m.TLS = true
+	default:
+		return nil, fmt.Errorf("неизвестная схема %q: ожидается mqtt:// или mqtts://", u.Scheme)
+	}
+	m.Addr = u.Host
+	if u.Port() == "" {
+		port := "1883"
+		if m.TLS {
+			port = "8883"
+		}
+		m.Addr = net.JoinHostPort(u.Hostname(), port)
+	}
+	if credentials != "" {
+		m.User, m.Password, _ = strings.Cut(credentials, ":")
+	} else if u.User != nil {
+		m.User = u.User.Username()
+		m.Password, _ = u.User.Password()
+	}
+	return m, nil
+}
+
+// Типы пакетов MQTT 3.1.1.
+const (
+	packetConnect    = 1
+	packetConnack    = 2
+	packetPublish    = 3
+	packetPuback     = 4
+	packetSubscribe  = 8
+	packetSuback     = 9
+	packetDisconnect = 14
+)
+
+type mqttConn struct {
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+// connect — TCP/TLS, CONNECT и ожидание CONNACK.
+func (m *MQTT) connect(ctx context.Context) (*mqttConn, error) {
+	d := &net.Dialer{Timeout: m.Timeout}
+	var conn net.Conn
+	var err error
+	if m.TLS {
+		host, _, _ := net.SplitHostPort(m.Addr)
+		conn, err = (&tls.Dialer{NetDialer: d, Config: &tls.Config{ServerName: host}}).DialContext(ctx, "tcp", m.Addr)
+	} else {
+		conn, err = d.DialContext(ctx, "tcp", m.Addr)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("MQTT: подключение к %s: %w", m.Addr, err)
+	}
+	conn.SetDeadline(time.Now().Add(m.Timeout))
+	c := &mqttConn{conn: conn, r: bufio.NewReader(conn)}
+
+	id := make([]byte, 6)
+	rand.Read(id)
+	var flags byte = 0x02 // clean session
+	payload := mqttString("agent-" + hex.EncodeToString(id))
+	if m.User != "" {
+		flags |= 0x80
+		payload = append(payload, mqttString(m.User)...)
+		if m.Password != "" {
+			flags |= 0x40
+			payload = append(payload, mqttString(m.Password)...)
+		}
+	}
+	body := append(mqttString("MQTT"), 4, flags, 0, 60) // уровень протокола 4, keepalive 60 с
+	body = append(body, payload...)
+	if err := c.write(packetConnect<<4, body); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	typ, data, err := c.read()
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("MQTT: CONNACK: %w", err)
+	}
+	if typ != packetConnack || len(data) < 2 {
+		conn.Close()
+		return nil, errors.New("MQTT: брокер не ответил CONNACK")
+	}
+	if rc := data[1]; rc != 0 {
+		conn.Close()
+		if rc == 4 || rc == 5 {
+			return nil, errors.New("MQTT: брокер отклонил логин/пароль — проверьте api_key провайдера mqtt")
+		}
+		return nil, fmt.Errorf("MQTT: брокер отказал в подключении (код %d)", rc)
+	}
+	return c, nil
+}
+
+func (c *mqttConn) close() {
+	c.write(packetDisconnect<<4, nil)
+	c.conn.Close()
+}
+
+func mqttString(s string) []byte {
+	b := make([]byte, 2, 2+len(s))
+	binary.BigEndian.PutUint16(b, uint16(len(s)))
+	return append(b, s...)
+}
+
+// write — пакет с фиксированным заголовком и длиной переменной длины.
+func (c *mqttConn) write(header byte, body []byte) error {
+	pkt := []byte{header}
+	n := len(body)
+	for {
+		b := byte(n % 128)
+		n /= 128
+		if n > 0 {
+			b |= 0x80
+		}
+		pkt = append(pkt, b)
+		if n == 0 {
+			break
+		}
+	}
+	_, err := c.conn.Write(append(pkt, body...))
+	return err
+}
+
+// read — следующий пакет: тип (старшие 4 бита), флаги и тело.
+func (c *mqttConn) read() (byte, []byte, error) {
+	typ, _, data, err := c.readFlags()
+	return typ, data, err
+}
+
+func (c *mqttConn) readFlags() (byte, byte, []byte, error) {
+	h, err := c.r.ReadByte()
+	if err != nil {
+		return 0, 0, nil, err
+	}
+	n, mul := 0, 1
+	for i := 0; i < 4; i++ {
+		b, err := c.r.ReadByte()
+		if err != nil {
+			return 0, 0, nil, err
+		}
+		n += int(b&0x7f) * mul
+		if b&0x80 == 0 {
+			break
+		}
+		mul *= 128
+	}
+	data := make([]byte, n)
+	if _, err := io.ReadFull(c.r, data); err != nil {
+		return 0, 0, nil, err
+	}
+	return h >> 4, h & 0x0f, data, nil
+}
+
+// Publish — публикация payload в topic (QoS 1: ждём PUBACK брокера).
+func (m *MQTT) Publish(ctx context.Context, topic, payload string, retain bool) error {
+	if topic == "" || strings.ContainsAny(topic, "#+") {
+		return fmt.Errorf("некорректный топик %q для публикации", topic)
+	}
+	c, err := m.connect(ctx)
+	if err != nil {
+		return err
+	}
+	defer c.close()
+	var header byte = packetPublish<<4 | 0x02 // QoS 1
+	if retain {
+		header |= 0x01
+	}
+	body := append(mqttString(topic), 0, 1) // packet id = 1
+	body = append(body, payload...)
+	if err := c.write(header, body); err != nil {
+		return fmt.Errorf("MQTT: PUBLISH: %w", err)
+	}
+	for {
+		typ, _, err := c.read()
+		if err != nil {
+			return fmt.Errorf("MQTT: PUBACK: %w", err)
+		}
+		if typ == packetPuback {
+			return nil
+		}
+	}
+}
+
+// ErrNoMessage — в топике нет сохранённого значения и за время ожидания ничего не пришло.
+var ErrNoMessage = errors.New("в топике нет значения: устройство не публикует retained-сообщения или молчит")
+
+// Read — последнее значение топика: подписка и ожидание первого сообщения
+// (retained приходит сразу, иначе — ближайшая публикация в пределах wait).
+func (m *MQTT) Read(ctx context.Context, topic string, wait time.Duration) (string, error) {
+	c, err := m.connect(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer c.close()
+	body := append([]byte{0, 1}, mqttString(topic)...) // packet id = 1
+	body = append(body, 0)                             // QoS 0
+	if err := c.write(packetSubscribe<<4|0x02, body); err != nil {
+		return "", fmt.Errorf("MQTT: SUBSCRIBE: %w", err)
+	}
+	c.conn.SetDeadline(time.Now().Add(m.Timeout + wait))
+	subscribed := false
+	for {
+		typ, flags, data, err := c.readFlags()
+		if err != nil {
+			var ne net.Error
+			if errors.As(err, &ne) && ne.Timeout() && subscribed {
+				return "", ErrNoMessage
+			}
+			return "", fmt.Errorf("MQTT: %w", err)
+		}
+		switch typ {
+		case packetSuback:
+			if len(data) >= 3 && data[2] == 0x80 {
+				return "", fmt.Errorf("MQTT: брокер запретил подписку на %s", topic)
+			}
+			subscribed = true
+			c.conn.SetDeadline(time.Now().Add(wait))
+		case packetPublish:
+			if len(data) < 2 {
+				continue
+			}
+			n := int(binary.BigEndian.Uint16(data))
+			if len(data) < 2+n {
+				continue
+			}
+			rest := data[2+n:]
+			if (flags>>1)&0x03 > 0 && len(rest) >= 2 {
+				rest = rest[2:] // packet id для QoS > 0
+			}
+			return string(rest), nil
+		}
+	}
+}
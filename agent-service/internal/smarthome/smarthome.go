@@ -0,0 +1,178 @@
+// Package smarthome — умный дом для агента: датчики, выключатели и сцены
+// через REST API Home Assistant или напрямую через MQTT-брокер.
+//
+// Модуль необязательный: инструменты home_* выдаются агенту, только если
+// сохранён провайдер homeassistant или mqtt (POST /providers). Управлять
+// можно лишь сущностями «переключаемых» доменов и сценами — климат, замки
+// и сигнализация агенту недоступны.
+package smarthome
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Имена провайдеров в настройках (POST /providers).
+const (
+	ProviderHomeAssistant = "homeassistant"
+	ProviderMQTT          = "mqtt"
+)
+
+// Ошибки модуля.
+var (
+	ErrNotConfigured = errors.New("умный дом не настроен: сохраните провайдер homeassistant (base_url, api_key) или mqtt (base_url) через POST /providers")
+	ErrNotFound      = errors.New("сущность не найдена")
+)
+
+// SensorDomains — домены, состояние которых возвращает home_sensor без entity_id.
+var SensorDomains = []string{"sensor", "binary_sensor"}
+
+// SwitchDomains — домены, которые разрешено переключать.
+var SwitchDomains = []string{"switch", "light", "fan", "input_boolean"}
+
+// SceneDomains — домены, которые разрешено запускать как сцену.
+var SceneDomains = []string{"scene", "script"}
+
+// Hub — подключённые бэкенды; любой из них может отсутствовать.
+type Hub struct {
+	HA   *HomeAssistant
+	MQTT *MQTT
+}
+
+// Configured — есть ли хотя бы один бэкенд.
+func (h Hub) Configured() bool {
+	return h.HA != nil || h.MQTT != nil
+}
+
+// Request — параметры инструмента home_*: сущность Home Assistant или топик MQTT.
+type Request struct {
+	EntityID string
+	Query    string // Поиск сущностей по имени, если EntityID не задан
+	Action   string // on, off, toggle
+	Topic    string
+	Payload  string
+}
+
+// Sensor — состояние датчика: сущность HA, значение топика MQTT или список
+// датчиков HA (с фильтром Query), если ни то ни другое не указано.
+func (h Hub) Sensor(ctx context.Context, r Request) (map[string]interface{}, error) {
+	switch {
+	case r.Topic != "":
+		if h.MQTT == nil {
+			return nil, ErrNotConfigured
+		}
+		value, err := h.MQTT.Read(ctx, r.Topic, 3*time.Second)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{"topic": r.Topic, "value": value}, nil
+	case h.HA == nil:
+		return nil, ErrNotConfigured
+	case r.EntityID != "":
+		e, err := h.HA.State(ctx, r.EntityID)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{"entity": e}, nil
+	default:
+		list, err := h.HA.States(ctx, SensorDomains, r.Query)
+		if err != nil {
+			return nil, err
+		}
+		if len(list) > 50 {
+			list = list[:50]
+		}
+		return map[string]interface{}{"sensors": list, "count": len(list)}, nil
+	}
+}
+
+// Switch — включить/выключить/переключить устройство. Для MQTT публикуется
+// payload (по умолчанию ON/OFF) в командный топик.
+func (h Hub) Switch(ctx context.Context, r Request) (map[string]interface{}, error) {
+	action := strings.ToLower(r.Action)
+	if action == "" {
+		action = "toggle"
+	}
+	if action != "on" && action != "off" && action != "toggle" {
+		return nil, fmt.Errorf("action: ожидается on, off или toggle")
+	}
+	if r.Topic != "" {
+		if h.MQTT == nil {
+			return nil, ErrNotConfigured
+		}
+		payload := r.Payload
+		if payload == "" {
+			if action == "toggle" {
+				return nil, errors.New("для MQTT укажите action on/off или payload")
+			}
+			payload = strings.ToUpper(action)
+		}
+		if err := h.MQTT.Publish(ctx, r.Topic, payload, false); err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{"topic": r.Topic, "payload": payload, "published": true}, nil
+	}
+	if h.HA == nil {
+		return nil, ErrNotConfigured
+	}
+	if r.EntityID == "" {
+		list, err := h.HA.States(ctx, SwitchDomains, r.Query)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{"error": "укажите entity_id", "switches": list}, nil
+	}
+	domain := domainOf(r.EntityID)
+	if !inDomains(r.EntityID, SwitchDomains) {
+		return nil, fmt.Errorf("домен %s нельзя переключать: разрешены %s", domain, strings.Join(SwitchDomains, ", "))
+	}
+	service := "turn_" + action
+	if action == "toggle" {
+		service = "toggle"
+	}
+	if err := h.HA.CallService(ctx, domain, service, r.EntityID); err != nil {
+		return nil, err
+	}
+	e, err := h.HA.State(ctx, r.EntityID)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{"entity_id": r.EntityID, "state": e.State}, nil
+}
+
+// Scene — запуск сцены или скрипта Home Assistant, либо публикация payload
+// в топик MQTT.
+func (h Hub) Scene(ctx context.Context, r Request) (map[string]interface{}, error) {
+	if r.Topic != "" {
+		if h.MQTT == nil {
+			return nil, ErrNotConfigured
+		}
+		if err := h.MQTT.Publish(ctx, r.Topic, r.Payload, false); err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{"topic": r.Topic, "payload": r.Payload, "published": true}, nil
+	}
+	if h.HA == nil {
+		return nil, ErrNotConfigured
+	}
+	if r.EntityID == "" {
+		list, err := h.HA.States(ctx, SceneDomains, r.Query)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{"error": "укажите entity_id сцены", "scenes": list}, nil
+	}
+	if !strings.Contains(r.EntityID, ".") {
+		r.EntityID = "scene." + r.EntityID
+	}
+	if !inDomains(r.EntityID, SceneDomains) {
+		return nil, fmt.Errorf("%s не сцена и не скрипт", r.EntityID)
+	}
+	if err := h.HA.CallService(ctx, domainOf(r.EntityID), "turn_on", r.EntityID); err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{"entity_id": r.EntityID, "activated": true}, nil
+}
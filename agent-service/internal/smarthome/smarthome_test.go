@@ -0,0 +1,163 @@
+package smarthome
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestHomeAssistant — датчики, переключение и сцены через REST API.
+func TestHomeAssistant(t *testing.T) {
+	state := "off"
+	var called []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer tok" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch {
+		case r.URL.Path == "/api/states":
+			w.Write([]byte(`[
+				{"entity_id":"sensor.kitchen_temperature","state":"21.5","attributes":{"friendly_name":"Кухня","unit_of_measurement":"°C"}},
+				{"entity_id":"sensor.hall_humidity","state":"40","attributes":{"friendly_name":"Прихожая"}},
+				{"entity_id":"switch.server_fan","state":"` + state + `","attributes":{}}
+			]`))
+		case r.URL.Path == "/api/states/switch.server_fan":
+			w.Write([]byte(`{"entity_id":"switch.server_fan","state":"` + state + `","attributes":{"friendly_name":"Вентилятор"}}`))
+		case strings.HasPrefix(r.URL.Path, "/api/services/"):
+			var body map[string]string
+			json.NewDecoder(r.Body).Decode(&body)
+			called = append(called, strings.TrimPrefix(r.URL.Path, "/api/services/")+" "+body["entity_id"])
+			if strings.HasSuffix(r.URL.Path, "/turn_on") {
+				state = "on"
+			}
+			w.Write([]byte(`[]`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+	hub := Hub{HA: NewHomeAssistant(srv.URL+"/", "tok")}
+	ctx := context.Background()
+
+	res, err := hub.Sensor(ctx, Request{Query: "кухня"})
+	if err != nil || res["count"] != 1 || res["sensors"].([]Entity)[0].Unit != "°C" {
+		t.Fatalf("Sensor: %+v, %v", res, err)
+	}
+	if _, err := hub.Sensor(ctx, Request{EntityID: "sensor.missing"}); err != ErrNotFound {
+		t.Errorf("ожидалась ErrNotFound, получено %v", err)
+	}
+
+	res, err = hub.Switch(ctx, Request{EntityID: "switch.server_fan", Action: "on"})
+	if err != nil || res["state"] != "on" {
+		t.Fatalf("Switch: %+v, %v", res, err)
+	}
+	if _, err := hub.Switch(ctx, Request{EntityID: "lock.front_door", Action: "off"}); err == nil {
+		t.Error("замок не должен переключаться")
+	}
+	if _, err := hub.Scene(ctx, Request{EntityID: "movie_night"}); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(called, ";") != "switch/turn_on switch.server_fan;scene/turn_on scene.movie_night" {
+		t.Errorf("вызовы служб: %q", called)
+	}
+	if _, err := (Hub{}).Scene(ctx, Request{EntityID: "scene.x"}); err != ErrNotConfigured {
+		t.Errorf("ожидалась ErrNotConfigured, получено %v", err)
+	}
+}
+
+// fakeBroker — MQTT-брокер с retained-значениями retained: отвечает на CONNECT,
+// SUBSCRIBE (сразу отдаёт retained) и PUBLISH (PUBACK); публикации пишет в published.
+func fakeBroker(t *testing.T, retained map[string]string, published chan<- string) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func(conn net.Conn) {
+				defer conn.Close()
+				c := &mqttConn{conn: conn, r: bufio.NewReader(conn)}
+				for {
+					typ, flags, data, err := c.readFlags()
+					if err != nil {
+						return
+					}
+					switch typ {
+					case packetConnect:
+						rc := byte(0)
+						if !strings.Contains(string(data), "secret") {
+							rc = 5
+						}
+						c.write(packetConnack<<4, []byte{0, rc})
+					case packetSubscribe:
+						topic := string(data[4 : 4+int(data[3])])
+						c.write(packetSuback<<4, []byte{data[0], data[1], 0})
+						if v, ok := retained[topic]; ok {
+							c.write(packetPublish<<4|0x01, append(mqttString(topic), v...))
+						}
+					case packetPublish:
+						n := int(data[1])
+						topic, rest := string(data[2:2+n]), data[2+n:]
+						if flags&0x06 != 0 {
+							c.write(packetPuback<<4, rest[:2])
+							rest = rest[2:]
+						}
+						published <- topic + "=" + string(rest)
+					case packetDisconnect:
+						return
+					}
+				}
+			}(conn)
+		}
+	}()
+	return "mqtt://" + ln.Addr().String()
+}
+
+// TestMQTT — чтение retained-значения и публикация команды.
+func TestMQTT(t *testing.T) {
+	published := make(chan string, 1)
+	addr := fakeBroker(t, map[string]string{"home/boiler/temp": "54.2"}, published)
+	m, err := ParseMQTT(addr, "agent:secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	hub := Hub{MQTT: m}
+	ctx := context.Background()
+
+	res, err := hub.Sensor(ctx, Request{Topic: "home/boiler/temp"})
+	if err != nil || res["value"] != "54.2" {
+		t.Fatalf("Sensor: %+v, %v", res, err)
+	}
+	m.Timeout = 200 * time.Millisecond
+	if _, err := m.Read(ctx, "home/unknown", 100*time.Millisecond); err != ErrNoMessage {
+		t.Errorf("ожидалась ErrNoMessage, получено %v", err)
+	}
+
+	if _, err := hub.Switch(ctx, Request{Topic: "home/pump/set", Action: "off"}); err != nil {
+		t.Fatal(err)
+	}
+	if got := <-published; got != "home/pump/set=OFF" {
+		t.Errorf("публикация: %q", got)
+	}
+
+	bad, _ := ParseMQTT(addr, "agent:wrong")
+	if _, err := bad.Read(ctx, "home/boiler/temp", time.Second); err == nil || !strings.Contains(err.Error(), "логин") {
+		t.Errorf("неверный пароль: %v", err)
+	}
+	if _, err := ParseMQTT("http://broker", ""); err == nil {
+		t.Error("схема http должна отклоняться")
+	}
+}
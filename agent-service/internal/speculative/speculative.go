@@ -0,0 +1,107 @@
+// Package speculative — экспериментальный режим двойной отправки запроса.
+//
+// Один и тот же запрос одновременно уходит быстрой локальной модели
+// (черновик) и основной модели агента. Черновик показывается сразу, как
+// только готов, и заменяется ответом основной модели. Если основная модель
+// ответила раньше, черновик отбрасывается. Для оценки режима считается
+// согласие черновика с итоговым ответом и выигрыш во времени.
+package speculative
+
+import (
+	"context"
+	"math"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Исходы гонки.
+const (
+	OutcomeDraftFirst   = "draft_first"   // Черновик показан до ответа основной модели
+	OutcomePrimaryFirst = "primary_first" // Основная модель ответила раньше — черновик не нужен
+	OutcomeDraftFailed  = "draft_failed"  // Черновая модель вернула ошибку или пустой ответ
+)
+
+// Answer — ответ одной из моделей.
+type Answer struct {
+	Content  string
+	Provider string
+	Model    string
+	Latency  time.Duration
+	Err      error
+}
+
+func (a Answer) ok() bool { return a.Err == nil && strings.TrimSpace(a.Content) != "" }
+
+// Result — итог двойной отправки.
+type Result struct {
+	Primary   Answer
+	Draft     *Answer // nil — черновик не показан
+	Outcome   string
+	Agreement float64       // Сходство черновика с итоговым ответом 0..1 (только при OutcomeDraftFirst)
+	Lead      time.Duration // На сколько раньше пользователь увидел черновик
+}
+
+// Func — запрос к модели; контекст отменяется, когда ответ больше не нужен.
+type Func func(ctx context.Context) Answer
+
+// Run — запускает черновую и основную модели параллельно. onDraft
+// вызывается (в горутине Run) с готовым черновиком, если он успел раньше
+// основного ответа. Возвращает, когда готов основной ответ.
+func Run(ctx context.Context, draft, primary Func, onDraft func(Answer)) Result {
+	draftCtx, cancelDraft := context.WithCancel(ctx)
+	defer cancelDraft()
+	draftCh := make(chan Answer, 1)
+	primaryCh := make(chan Answer, 1)
+	go func() { draftCh <- draft(draftCtx) }()
+	go func() { primaryCh <- primary(ctx) }()
+
+	var res Result
+	select {
+	case d := <-draftCh:
+		if !d.ok() {
+			res.Outcome = OutcomeDraftFailed
+			res.Primary = <-primaryCh
+			return res
+		}
+		onDraft(d)
+		res.Draft = &d
+		res.Outcome = OutcomeDraftFirst
+		res.Primary = <-primaryCh
+		if res.Primary.ok() {
+			res.Agreement = Agreement(d.Content, res.Primary.Content)
+		}
+		res.Lead = res.Primary.Latency - d.Latency
+	case res.Primary = <-primaryCh:
+		res.Outcome = OutcomePrimaryFirst
+	}
+	return res
+}
+
+// Agreement — сходство двух ответов 0..1: косинус частот слов без учёта
+// регистра и пунктуации. Грубая, но дешёвая мера «сказали ли модели одно и то же».
+func Agreement(a, b string) float64 {
+	fa, fb := words(a), words(b)
+	if len(fa) == 0 || len(fb) == 0 {
+		return 0
+	}
+	var dot, na, nb float64
+	for w, x := range fa {
+		dot += x * fb[w]
+		na += x * x
+	}
+	for _, y := range fb {
+		nb += y * y
+	}
+	return math.Round(dot/math.Sqrt(na*nb)*100) / 100
+}
+
+func words(s string) map[string]float64 {
+	freq := map[string]float64{}
+	for _, w := range strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		freq[w]++
+	}
+	return freq
+}
@@ -0,0 +1,55 @@
+package speculative
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func answer(content string, delay time.Duration) Func {
+	return func(ctx context.Context) Answer {
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return Answer{Err: ctx.Err()}
+		}
+		return Answer{Content: content, Latency: delay}
+	}
+}
+
+// TestRun — черновик показывается только если успел раньше основного ответа.
+func TestRun(t *testing.T) {
+	var shown []string
+	onDraft := func(a Answer) { shown = append(shown, a.Content) }
+
+	res := Run(context.Background(), answer("Столица Франции — Париж.", 10*time.Millisecond), answer("Париж — столица Франции.", 60*time.Millisecond), onDraft)
+	if res.Outcome != OutcomeDraftFirst || len(shown) != 1 || res.Agreement != 1 || res.Lead != 50*time.Millisecond {
+		t.Errorf("черновик первым: %+v, показано %v", res, shown)
+	}
+
+	shown = nil
+	res = Run(context.Background(), answer("черновик", 80*time.Millisecond), answer("ответ", 5*time.Millisecond), onDraft)
+	if res.Outcome != OutcomePrimaryFirst || len(shown) != 0 || res.Draft != nil || res.Primary.Content != "ответ" {
+		t.Errorf("основная первой: %+v", res)
+	}
+
+	failed := func(context.Context) Answer { return Answer{Err: errors.New("модель не загружена")} }
+	res = Run(context.Background(), failed, answer("ответ", 20*time.Millisecond), onDraft)
+	if res.Outcome != OutcomeDraftFailed || len(shown) != 0 || res.Primary.Content != "ответ" {
+		t.Errorf("ошибка черновика: %+v", res)
+	}
+}
+
+// TestAgreement — одинаковые по смыслу слова дают высокое согласие, разные — низкое.
+func TestAgreement(t *testing.T) {
+	if got := Agreement("Да, можно.", "да можно"); got != 1 {
+		t.Errorf("одинаковые ответы: %v", got)
+	}
+	if got := Agreement("Запусти docker compose up", "Используй systemctl restart nginx"); got != 0 {
+		t.Errorf("разные ответы: %v", got)
+	}
+	if got := Agreement("", "текст"); got != 0 {
+		t.Errorf("пустой ответ: %v", got)
+	}
+}
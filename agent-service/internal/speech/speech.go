@@ -0,0 +1,246 @@
+// Package speech — распознавание (STT) и синтез (TTS) речи для голосового чата.
+//
+// POST /chat/audio принимает аудиозапись, распознаёт её выбранным бэкендом
+// (локальный сервер whisper.cpp или облачный OpenAI-совместимый API),
+// передаёт текст в обычный конвейер /chat и, по запросу, озвучивает ответ.
+package speech
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/neo-2022/openclaw-memory/agent-service/internal/httpclient"
+)
+
+// Бэкенды распознавания и синтеза.
+const (
+	BackendWhisperCPP = "whisper_cpp" // Сервер whisper.cpp (POST /inference)
+	BackendOpenAI     = "openai"      // OpenAI-совместимый API (/audio/transcriptions, /audio/speech)
+	BackendHTTP       = "http"        // TTS: произвольный сервер, POST {"text","voice"} → аудио
+)
+
+// ErrDisabled — бэкенд не настроен.
+var ErrDisabled = errors.New("бэкенд речи не настроен")
+
+// Settings — параметры бэкендов (см. STT_* и TTS_* в config).
+type Settings struct {
+	STTBackend  string
+	STTURL      string
+	STTModel    string
+	STTLanguage string
+	TTSBackend  string
+	TTSURL      string
+	TTSModel    string
+	TTSVoice    string
+	APIKey      string // Ключ облачного API (для бэкенда openai)
+}
+
+// Transcriber — распознавание речи.
+type Transcriber interface {
+	Transcribe(ctx context.Context, audio []byte, filename string) (string, error)
+}
+
+// Synthesizer — синтез речи; возвращает аудио и его MIME-тип.
+type Synthesizer interface {
+	Synthesize(ctx context.Context, text string) ([]byte, string, error)
+}
+
+var httpClient = httpclient.New("speech", 2*time.Minute)
+
+// NewTranscriber — распознаватель по STT_BACKEND; ErrDisabled, если бэкенд не задан.
+func NewTranscriber(s Settings) (Transcriber, error) {
+	switch s.STTBackend {
+	case "":
+		return nil, ErrDisabled
+	case BackendWhisperCPP:
+		return &whisperCPP{url: orDefault(s.STTURL, "http://localhost:8178"), language: s.STTLanguage}, nil
+	case BackendOpenAI:
+		return &openAISTT{
+			baseURL:  orDefault(s.STTURL, "https://api.openai.com/v1"),
+			model:    orDefault(s.STTModel, "whisper-1"),
+			language: s.STTLanguage,
+			apiKey:   s.APIKey,
+		}, nil
+	}
+	return nil, fmt.Errorf("неизвестный STT_BACKEND %q", s.STTBackend)
+}
+
+// NewSynthesizer — синтезатор по TTS_BACKEND; ErrDisabled, если бэкенд не задан.
+func NewSynthesizer(s Settings) (Synthesizer, error) {
+	switch s.TTSBackend {
+	case "":
+		return nil, ErrDisabled
+	case BackendOpenAI:
+		return &openAITTS{
+			baseURL: orDefault(s.TTSURL, "https://api.openai.com/v1"),
+			model:   orDefault(s.TTSModel, "tts-1"),
+			voice:   orDefault(s.TTSVoice, "alloy"),
+			apiKey:  s.APIKey,
+		}, nil
+	case BackendHTTP:
+		if s.TTSURL == "" {
+			return nil, errors.New("TTS_URL обязателен для TTS_BACKEND=http")
+		}
+		return &httpTTS{url: s.TTSURL, voice: s.TTSVoice}, nil
+	}
+	return nil, fmt.Errorf("неизвестный TTS_BACKEND %q", s.TTSBackend)
+}
+
+func orDefault(v, def string) string {
+	if v == "" {
+		return def
+	}
+	return v
+}
+
+// whisperCPP — сервер whisper.cpp (examples/server): multipart file → {"text": ...}.
+type whisperCPP struct {
+	url      string
+	language string
+}
+
+func (w *whisperCPP) Transcribe(ctx context.Context, audio []byte, filename string) (string, error) {
+	fields := map[string]string{"response_format": "json", "temperature": "0"}
+	if w.language != "" {
+		fields["language"] = w.language
+	}
+	return postTranscription(ctx, strings.TrimRight(w.url, "/")+"/inference", "", audio, filename, fields)
+}
+
+// openAISTT — OpenAI /audio/transcriptions (и совместимые API).
+type openAISTT struct {
+	baseURL  string
+	model    string
+	language string
+	apiKey   string
+}
+
+func (o *openAISTT) Transcribe(ctx context.Context, audio []byte, filename string) (string, error) {
+	if o.apiKey == "" {
+		return "", errors.New("не задан SPEECH_API_KEY для распознавания через OpenAI")
+	}
+	fields := map[string]string{"model": o.model}
+	if o.language != "" {
+		fields["language"] = o.language
+	}
+	return postTranscription(ctx, strings.TrimRight(o.baseURL, "/")+"/audio/transcriptions", o.apiKey, audio, filename, fields)
+}
+
+// postTranscription — multipart-запрос с аудио, ответ {"text": ...}.
+func postTranscription(ctx context.Context, url, apiKey string, audio []byte, filename string, fields map[string]string) (string, error) {
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	part, err := mw.CreateFormFile("file", orDefault(filename, "audio.wav"))
+	if err != nil {
+		return "", err
+	}
+	if _, err := part.Write(audio); err != nil {
+		return "", err
+	}
+	for k, v := range fields {
+		if err := mw.WriteField(k, v); err != nil {
+			return "", err
+		}
+	}
+	if err := mw.Close(); err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, &body)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	if apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+apiKey)
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("ошибка запроса к STT: %w", err)
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("STT HTTP %d: %s", resp.StatusCode, truncate(string(data), 200))
+	}
+	var result struct {
+		Text string `json:"text"`
+	}
+	if err := json.Unmarshal(data, &result); err != nil {
+		return "", fmt.Errorf("ошибка разбора ответа STT: %w", err)
+	}
+	return strings.TrimSpace(result.Text), nil
+}
+
+// openAITTS — OpenAI /audio/speech.
+type openAITTS struct {
+	baseURL string
+	model   string
+	voice   string
+	apiKey  string
+}
+
+func (o *openAITTS) Synthesize(ctx context.Context, text string) ([]byte, string, error) {
+	if o.apiKey == "" {
+		return nil, "", errors.New("не задан SPEECH_API_KEY для синтеза через OpenAI")
+	}
+	payload := map[string]string{"model": o.model, "input": text, "voice": o.voice, "response_format": "mp3"}
+	return postSpeech(ctx, strings.TrimRight(o.baseURL, "/")+"/audio/speech", o.apiKey, payload)
+}
+
+// httpTTS — локальный сервер синтеза (Piper, Silero и др. за HTTP-обёрткой).
+type httpTTS struct {
+	url   string
+	voice string
+}
+
+func (h *httpTTS) Synthesize(ctx context.Context, text string) ([]byte, string, error) {
+	return postSpeech(ctx, h.url, "", map[string]string{"text": text, "voice": h.voice})
+}
+
+func postSpeech(ctx context.Context, url, apiKey string, payload map[string]string) ([]byte, string, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, "", err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(data))
+	if err != nil {
+		return nil, "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+apiKey)
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, "", fmt.Errorf("ошибка запроса к TTS: %w", err)
+	}
+	defer resp.Body.Close()
+	audio, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, "", err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", fmt.Errorf("TTS HTTP %d: %s", resp.StatusCode, truncate(string(audio), 200))
+	}
+	contentType := resp.Header.Get("Content-Type")
+	if contentType == "" || strings.HasPrefix(contentType, "application/octet-stream") {
+		contentType = http.DetectContentType(audio)
+	}
+	return audio, contentType, nil
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n] + "..."
+}
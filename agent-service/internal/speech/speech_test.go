@@ -0,0 +1,79 @@
+package speech
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// TestWhisperCPP — аудио и язык уходят multipart-запросом на /inference.
+func TestWhisperCPP(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/inference" {
+			t.Errorf("путь: %s", r.URL.Path)
+		}
+		file, _, err := r.FormFile("file")
+		if err != nil {
+			t.Fatalf("нет файла: %v", err)
+		}
+		data, _ := io.ReadAll(file)
+		if string(data) != "RIFF-audio" || r.FormValue("language") != "ru" {
+			t.Errorf("файл %q, язык %q", data, r.FormValue("language"))
+		}
+		w.Write([]byte(`{"text": " покажи загрузку диска \n"}`))
+	}))
+	defer srv.Close()
+
+	stt, err := NewTranscriber(Settings{STTBackend: BackendWhisperCPP, STTURL: srv.URL, STTLanguage: "ru"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	text, err := stt.Transcribe(context.Background(), []byte("RIFF-audio"), "voice.wav")
+	if err != nil || text != "покажи загрузку диска" {
+		t.Fatalf("Transcribe: %q, %v", text, err)
+	}
+}
+
+// TestOpenAITTS — синтез через /audio/speech с ключом и голосом.
+func TestOpenAITTS(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]string
+		json.NewDecoder(r.Body).Decode(&body)
+		if r.URL.Path != "/audio/speech" || r.Header.Get("Authorization") != "Bearer key" || body["voice"] != "nova" {
+			t.Errorf("запрос: %s %v %v", r.URL.Path, r.Header, body)
+		}
+		w.Header().Set("Content-Type", "audio/mpeg")
+		w.Write([]byte("ID3-mp3"))
+	}))
+	defer srv.Close()
+
+	tts, err := NewSynthesizer(Settings{TTSBackend: BackendOpenAI, TTSURL: srv.URL, TTSVoice: "nova", APIKey: "key"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	audio, contentType, err := tts.Synthesize(context.Background(), "Готово")
+	if err != nil || string(audio) != "ID3-mp3" || contentType != "audio/mpeg" {
+		t.Fatalf("Synthesize: %q %q %v", audio, contentType, err)
+	}
+}
+
+// TestBackends — пустой бэкенд отключён, неизвестный — ошибка, облачному нужен ключ.
+func TestBackends(t *testing.T) {
+	if _, err := NewTranscriber(Settings{}); !errors.Is(err, ErrDisabled) {
+		t.Errorf("ожидался ErrDisabled, получено %v", err)
+	}
+	if _, err := NewSynthesizer(Settings{TTSBackend: "festival"}); err == nil {
+		t.Error("неизвестный бэкенд должен отклоняться")
+	}
+	if _, err := NewSynthesizer(Settings{TTSBackend: BackendHTTP}); err == nil {
+		t.Error("для http-бэкенда нужен TTS_URL")
+	}
+	stt, _ := NewTranscriber(Settings{STTBackend: BackendOpenAI})
+	if _, err := stt.Transcribe(context.Background(), []byte("x"), ""); err == nil {
+		t.Error("без ключа облачное распознавание должно возвращать ошибку")
+	}
+}
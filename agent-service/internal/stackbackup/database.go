@@ -0,0 +1,153 @@
+package stackbackup
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"slices"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// Database — дамп и восстановление базы данных агентов.
+type Database interface {
+	Driver() string                                 // postgres или sqlite — копия восстанавливается только в базу того же драйвера
+	Dump(ctx context.Context, path string) error    // Записать дамп в файл path
+	Restore(ctx context.Context, path string) error // Применить дамп из файла path
+}
+
+// Postgres — база PostgreSQL через pg_dump и psql (пакет postgresql-client).
+// Дамп в виде SQL с DROP … IF EXISTS, поэтому применяется поверх существующей
+// базы; восстановление идёт одной транзакцией и откатывается при первой ошибке.
+type Postgres struct {
+	DSN string   // Строка подключения libpq (URL или key=value) без пароля — она видна в аргументах процесса
+	Env []string // Дополнительные переменные окружения (PGPASSWORD)
+}
+
+// Driver — postgres.
+func (Postgres) Driver() string { return "postgres" }
+
+// Dump — pg_dump без владельцев и прав: копия переносится на сервер с другими ролями.
+func (p Postgres) Dump(ctx context.Context, path string) error {
+	return p.run(ctx, "pg_dump", "--clean", "--if-exists", "--no-owner", "--no-privileges", "--format=plain", "--file="+path, "--dbname="+p.DSN)
+}
+
+// Restore — psql одной транзакцией с остановкой на первой ошибке.
+func (p Postgres) Restore(ctx context.Context, path string) error {
+	return p.run(ctx, "psql", "--no-psqlrc", "--quiet", "--single-transaction", "--set=ON_ERROR_STOP=1", "--file="+path, "--dbname="+p.DSN)
+}
+
+// run — запуск клиентской утилиты PostgreSQL; в ошибку попадает хвост stderr.
+func (p Postgres) run(ctx context.Context, name string, args ...string) error {
+	bin, err := exec.LookPath(name)
+	if err != nil {
+		return fmt.Errorf("%s не найден: установите postgresql-client", name)
+	}
+	cmd := exec.CommandContext(ctx, bin, args...)
+	cmd.Env = append(os.Environ(), p.Env...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		msg := strings.TrimSpace(stderr.String())
+		if len(msg) > 2000 {
+			msg = "…" + msg[len(msg)-2000:]
+		}
+		return fmt.Errorf("%s: %w: %s", name, err, msg)
+	}
+	return nil
+}
+
+// SQLite — база SQLite открытого подключения GORM. Дамп — VACUUM INTO
+// (согласованная копия без остановки записи). Восстановление не подменяет
+// файл под открытым подключением, а переносит строки всех таблиц из
+// подключённой копии в одной транзакции.
+type SQLite struct {
+	DB *gorm.DB
+}
+
+// Driver — sqlite.
+func (SQLite) Driver() string { return "sqlite" }
+
+// Dump — копия базы в новый файл path.
+func (s SQLite) Dump(ctx context.Context, path string) error {
+	return s.DB.WithContext(ctx).Exec("VACUUM INTO ?", path).Error
+}
+
+// Restore — содержимое таблиц из копии path. Таблицы, которых нет в копии,
+// очищаются; переносятся только столбцы, общие для копии и текущей схемы,
+// поэтому копия старой версии восстанавливается после миграций.
+func (s SQLite) Restore(ctx context.Context, path string) error {
+	// ATTACH и PRAGMA действуют на соединение, поэтому всё — на одном
+	return s.DB.WithContext(ctx).Connection(func(conn *gorm.DB) error {
+		if err := conn.Exec("ATTACH DATABASE ? AS snapshot", path).Error; err != nil {
+			return err
+		}
+		defer conn.Exec("DETACH DATABASE snapshot")
+		if err := conn.Exec("PRAGMA foreign_keys = OFF").Error; err != nil {
+			return err
+		}
+		defer conn.Exec("PRAGMA foreign_keys = ON")
+
+		var tables, saved []string
+		if err := conn.Raw("SELECT name FROM main.sqlite_master WHERE type = 'table' AND name NOT LIKE 'sqlite_%'").Scan(&tables).Error; err != nil {
+			return err
+		}
+		if err := conn.Raw("SELECT name FROM snapshot.sqlite_master WHERE type = 'table' AND name NOT LIKE 'sqlite_%'").Scan(&saved).Error; err != nil {
+			return err
+		}
+		return conn.Transaction(func(tx *gorm.DB) error {
+			for _, t := range tables {
+				if err := tx.Exec("DELETE FROM main." + quoteIdent(t)).Error; err != nil {
+					return fmt.Errorf("%s: %w", t, err)
+				}
+				if !slices.Contains(saved, t) {
+					continue
+				}
+				cols, err := commonColumns(tx, t)
+				if err != nil {
+					return fmt.Errorf("%s: %w", t, err)
+				}
+				if len(cols) == 0 {
+					continue
+				}
+				list := strings.Join(cols, ", ")
+				if err := tx.Exec("INSERT INTO main." + quoteIdent(t) + " (" + list + ") SELECT " + list + " FROM snapshot." + quoteIdent(t)).Error; err != nil {
+					return fmt.Errorf("%s: %w", t, err)
+				}
+			}
+			return nil
+		})
+	})
+}
+
+// commonColumns — столбцы таблицы, которые есть и в базе, и в копии (в кавычках).
+func commonColumns(tx *gorm.DB, table string) ([]string, error) {
+	columns := func(schema string) ([]string, error) {
+		var names []string
+		err := tx.Raw("SELECT name FROM pragma_table_info(?, ?)", table, schema).Scan(&names).Error
+		return names, err
+	}
+	current, err := columns("main")
+	if err != nil {
+		return nil, err
+	}
+	saved, err := columns("snapshot")
+	if err != nil {
+		return nil, err
+	}
+	var out []string
+	for _, c := range current {
+		if slices.Contains(saved, c) {
+			out = append(out, quoteIdent(c))
+		}
+	}
+	return out, nil
+}
+
+// quoteIdent — идентификатор SQLite в двойных кавычках.
+func quoteIdent(s string) string {
+	return `"` + strings.ReplaceAll(s, `"`, `""`) + `"`
+}
@@ -0,0 +1,50 @@
+//go:build cgo
+
+package stackbackup
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// TestSQLiteRestore — строки возвращаются из копии через открытое
+// подключение; столбец, добавленный после копии, не мешает восстановлению.
+func TestSQLiteRestore(t *testing.T) {
+	gdb, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "agent.db")), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("открытие SQLite: %v", err)
+	}
+	gdb.Exec("CREATE TABLE agents (id INTEGER PRIMARY KEY, name TEXT)")
+	gdb.Exec("CREATE TABLE messages (id INTEGER PRIMARY KEY, agent_id INTEGER REFERENCES agents(id), text TEXT)")
+	gdb.Exec("INSERT INTO agents (id, name) VALUES (1, 'admin'), (2, 'coder')")
+	gdb.Exec("INSERT INTO messages (id, agent_id, text) VALUES (1, 1, 'привет')")
+
+	database := SQLite{DB: gdb}
+	dump := filepath.Join(t.TempDir(), "agent.db")
+	if err := database.Dump(context.Background(), dump); err != nil {
+		t.Fatalf("Dump: %v", err)
+	}
+
+	gdb.Exec("DELETE FROM messages")
+	gdb.Exec("UPDATE agents SET name = 'испорчено'")
+	gdb.Exec("ALTER TABLE agents ADD COLUMN model TEXT")
+	gdb.Exec("CREATE TABLE tasks (id INTEGER PRIMARY KEY)")
+	gdb.Exec("INSERT INTO tasks (id) VALUES (7)")
+	if err := database.Restore(context.Background(), dump); err != nil {
+		t.Fatalf("Restore: %v", err)
+	}
+
+	var names []string
+	gdb.Raw("SELECT name FROM agents ORDER BY id").Scan(&names)
+	var messages, tasks int64
+	gdb.Raw("SELECT COUNT(*) FROM messages").Scan(&messages)
+	gdb.Raw("SELECT COUNT(*) FROM tasks").Scan(&tasks)
+	if len(names) != 2 || names[0] != "admin" || messages != 1 || tasks != 0 {
+		t.Errorf("после восстановления: %v, сообщений %d, задач %d", names, messages, tasks)
+	}
+}
@@ -0,0 +1,217 @@
+package stackbackup
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Remote — внешнее хранилище копий.
+type Remote interface {
+	// Upload — отправить архив name размера size; возвращает расположение копии.
+	Upload(ctx context.Context, name string, r io.Reader, size int64) (string, error)
+}
+
+// Credentials — доступ к внешним хранилищам.
+type Credentials struct {
+	YandexDiskToken string // OAuth-токен Яндекс.Диска
+	S3Endpoint      string // https://storage.yandexcloud.net, https://s3.amazonaws.com…
+	S3Region        string
+	S3AccessKey     string
+	S3SecretKey     string
+}
+
+// ParseRemote — хранилище по строке STACK_BACKUP_REMOTE:
+//
+//	ydisk:/Backups/agent        — папка на Яндекс.Диске
+//	s3://bucket/prefix          — бакет S3-совместимого хранилища
+//
+// Пустая строка — nil (только локальные копии).
+func ParseRemote(spec string, creds Credentials, client *http.Client) (Remote, error) {
+	switch {
+	case spec == "":
+		return nil, nil
+	case strings.HasPrefix(spec, "ydisk:"):
+		if creds.YandexDiskToken == "" {
+			return nil, fmt.Errorf("%s: не задан YANDEX_DISK_TOKEN", spec)
+		}
+		dir := "/" + strings.Trim(strings.TrimPrefix(spec, "ydisk:"), "/")
+		return &YandexDisk{Dir: dir, Token: creds.YandexDiskToken, BaseURL: "https://cloud-api.yandex.net/v1/disk", Client: client}, nil
+	case strings.HasPrefix(spec, "s3://"):
+		bucket, prefix, _ := strings.Cut(strings.TrimPrefix(spec, "s3://"), "/")
+		if bucket == "" || creds.S3AccessKey == "" || creds.S3SecretKey == "" {
+			return nil, fmt.Errorf("%s: нужны бакет, STACK_BACKUP_S3_ACCESS_KEY и STACK_BACKUP_S3_SECRET_KEY", spec)
+		}
+		return &S3{Endpoint: strings.TrimRight(creds.S3Endpoint, "/"), Region: creds.S3Region, Bucket: bucket, Prefix: strings.Trim(prefix, "/"),
+			AccessKey: creds.S3AccessKey, SecretKey: creds.S3SecretKey, Client: client}, nil
+	default:
+		return nil, fmt.Errorf("%q: ожидается ydisk:/папка или s3://бакет/префикс", spec)
+	}
+}
+
+// YandexDisk — папка на Яндекс.Диске (REST API диска).
+type YandexDisk struct {
+	Dir     string // Папка, например /Backups/agent
+	Token   string
+	BaseURL string
+	Client  *http.Client
+}
+
+// Upload — создаёт папку (если её нет), запрашивает ссылку загрузки и отправляет архив.
+func (y *YandexDisk) Upload(ctx context.Context, name string, r io.Reader, size int64) (string, error) {
+	// Папки создаются по одной от корня; уже существующая (409) — не ошибка
+	var dir string
+	for _, part := range strings.Split(strings.Trim(y.Dir, "/"), "/") {
+		if part == "" {
+			continue
+		}
+		dir += "/" + part
+		resp, err := y.do(ctx, http.MethodPut, "/resources?path="+url.QueryEscape(dir))
+		if err != nil {
+			return "", err
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusConflict {
+			return "", fmt.Errorf("Яндекс.Диск: создание папки %s: HTTP %d", dir, resp.StatusCode)
+		}
+	}
+
+	dest := dir + "/" + name
+	resp, err := y.do(ctx, http.MethodGet, "/resources/upload?overwrite=true&path="+url.QueryEscape(dest))
+	if err != nil {
+		return "", err
+	}
+	var link struct {
+		Href   string `json:"href"`
+		Method string `json:"method"`
+	}
+	err = json.NewDecoder(resp.Body).Decode(&link)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || err != nil || link.Href == "" {
+		return "", fmt.Errorf("Яндекс.Диск: ссылка загрузки: HTTP %d", resp.StatusCode)
+	}
+	if link.Method == "" {
+		link.Method = http.MethodPut
+	}
+	req, err := http.NewRequestWithContext(ctx, link.Method, link.Href, r)
+	if err != nil {
+		return "", err
+	}
+	req.ContentLength = size
+	resp, err = y.Client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusAccepted && resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("Яндекс.Диск: загрузка: HTTP %d", resp.StatusCode)
+	}
+	return "ydisk:" + dest, nil
+}
+
+// do — запрос к REST API диска с OAuth-токеном.
+func (y *YandexDisk) do(ctx context.Context, method, path string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, y.BaseURL+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "OAuth "+y.Token)
+	return y.Client.Do(req)
+}
+
+// S3 — бакет S3-совместимого хранилища (AWS, Yandex Object Storage, MinIO).
+// Запрос подписывается AWS Signature V4 с UNSIGNED-PAYLOAD, чтобы не читать
+// архив дважды; адресация бакета — путём (endpoint/bucket/key).
+type S3 struct {
+	Endpoint  string
+	Region    string
+	Bucket    string
+	Prefix    string
+	AccessKey string
+	SecretKey string
+	Client    *http.Client
+	now       func() time.Time // Для тестов
+}
+
+// Upload — PUT объекта prefix/name.
+func (s *S3) Upload(ctx context.Context, name string, r io.Reader, size int64) (string, error) {
+	key := name
+	if s.Prefix != "" {
+		key = s.Prefix + "/" + name
+	}
+	uri := "/" + s3Escape(s.Bucket) + "/" + s3Escape(key)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, s.Endpoint+uri, r)
+	if err != nil {
+		return "", err
+	}
+	req.ContentLength = size
+	now := time.Now
+	if s.now != nil {
+		now = s.now
+	}
+	s.sign(req, uri, now().UTC())
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return "", fmt.Errorf("S3: HTTP %d: %s", resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+	return "s3://" + s.Bucket + "/" + key, nil
+}
+
+// sign — заголовки x-amz-* и Authorization по AWS Signature V4.
+func (s *S3) sign(req *http.Request, uri string, t time.Time) {
+	const payload = "UNSIGNED-PAYLOAD"
+	amzDate := t.Format("20060102T150405Z")
+	day := t.Format("20060102")
+	req.Header.Set("x-amz-content-sha256", payload)
+	req.Header.Set("x-amz-date", amzDate)
+
+	const signed = "host;x-amz-content-sha256;x-amz-date"
+	canonical := strings.Join([]string{
+		req.Method, uri, "",
+		"host:" + req.URL.Host, "x-amz-content-sha256:" + payload, "x-amz-date:" + amzDate, "",
+		signed, payload,
+	}, "\n")
+	scope := day + "/" + s.Region + "/s3/aws4_request"
+	sum := sha256.Sum256([]byte(canonical))
+	toSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(sum[:])
+
+	key := hmacSHA256([]byte("AWS4"+s.SecretKey), day)
+	for _, part := range []string{s.Region, "s3", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	req.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential="+s.AccessKey+"/"+scope+
+		", SignedHeaders="+signed+", Signature="+hex.EncodeToString(hmacSHA256(key, toSign)))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// s3Escape — кодирование пути по правилам SigV4: всё, кроме A-Z a-z 0-9 - _ . ~ и /.
+func s3Escape(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || strings.IndexByte("-_.~/", c) >= 0 {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
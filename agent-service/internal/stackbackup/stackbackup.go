@@ -0,0 +1,634 @@
+// Package stackbackup — резервная копия всего стека одним архивом и
+// восстановление из него: база данных (pg_dump или копия файла SQLite),
+// загруженные файлы, промпты и файлы конфигурации.
+//
+// Архив — tar.gz: первым идёт manifest.json (версия формата, время,
+// драйвер БД, состав), за ним db/, uploads/, prompts/ и config/. Копии
+// складываются в локальный каталог (STACK_BACKUP_DIR) и при настройке
+// отправляются на Яндекс.Диск или в S3-совместимое хранилище (см. Remote).
+//
+// Restore сначала распаковывает и проверяет архив целиком во временный
+// каталог и только потом меняет данные; пути за пределами компонентов,
+// ссылки и устройства в архиве отклоняются.
+package stackbackup
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// FormatVersion — версия формата архива; архивы новее не восстанавливаются.
+const FormatVersion = 1
+
+// Компоненты резервной копии.
+const (
+	ComponentDB      = "db"
+	ComponentUploads = "uploads"
+	ComponentPrompts = "prompts"
+	ComponentConfig  = "config"
+)
+
+// Components — все компоненты в порядке записи в архив.
+var Components = []string{ComponentDB, ComponentUploads, ComponentPrompts, ComponentConfig}
+
+// manifestName — первая запись архива.
+const manifestName = "manifest.json"
+
+var (
+	// ErrUnknownComponent — в запросе компонент не из Components.
+	ErrUnknownComponent = errors.New("неизвестный компонент")
+	// ErrNoSource — компонент запрошен, но не настроен (например, нет БД).
+	ErrNoSource = errors.New("компонент не настроен")
+	// ErrBadArchive — архив повреждён, без манифеста или с недопустимыми записями.
+	ErrBadArchive = errors.New("недопустимый архив")
+	// ErrNotFound — копии с таким именем нет в каталоге.
+	ErrNotFound = errors.New("резервная копия не найдена")
+)
+
+// Manifest — описание архива.
+type Manifest struct {
+	Version    int               `json:"version"`
+	CreatedAt  time.Time         `json:"created_at"`
+	Host       string            `json:"host,omitempty"`
+	DBDriver   string            `json:"db_driver,omitempty"`
+	Components []string          `json:"components"`
+	Files      map[string]int    `json:"files"`            // Число файлов по компонентам
+	Config     map[string]string `json:"config,omitempty"` // Имя в архиве → исходный путь
+}
+
+// Source — что копировать и куда восстанавливать.
+type Source struct {
+	DB     Database          // nil — без базы данных
+	Dirs   map[string]string // Компонент → каталог (uploads, prompts)
+	Config []string          // Файлы конфигурации; отсутствующие пропускаются
+}
+
+// Options — параметры Create.
+type Options struct {
+	Components []string // Пусто — все настроенные
+	Label      string   // Суффикс имени (pre-restore…)
+}
+
+// Info — сохранённая копия.
+type Info struct {
+	Name      string    `json:"name"`
+	Size      int64     `json:"size"`
+	SHA256    string    `json:"sha256,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	Manifest  *Manifest `json:"manifest,omitempty"`
+}
+
+// available — компоненты, для которых в src есть источник.
+func (src Source) available() []string {
+	var out []string
+	for _, c := range Components {
+		switch c {
+		case ComponentDB:
+			if src.DB != nil {
+				out = append(out, c)
+			}
+		case ComponentConfig:
+			if len(src.Config) > 0 {
+				out = append(out, c)
+			}
+		default:
+			if src.Dirs[c] != "" {
+				out = append(out, c)
+			}
+		}
+	}
+	return out
+}
+
+// selectComponents — запрошенные компоненты в порядке Components; пусто — все из have.
+func selectComponents(want, have []string) ([]string, error) {
+	if len(want) == 0 {
+		return have, nil
+	}
+	var out []string
+	for _, c := range Components {
+		if !slices.Contains(want, c) {
+			continue
+		}
+		if !slices.Contains(have, c) {
+			return nil, fmt.Errorf("%w: %s", ErrNoSource, c)
+		}
+		out = append(out, c)
+	}
+	for _, c := range want {
+		if !slices.Contains(Components, c) {
+			return nil, fmt.Errorf("%w: %q", ErrUnknownComponent, c)
+		}
+	}
+	return out, nil
+}
+
+// configNames — имена файлов конфигурации в архиве: config/<имя файла>,
+// при совпадении имён — с номером.
+func configNames(paths []string) map[string]string {
+	out := make(map[string]string, len(paths))
+	for i, p := range paths {
+		name := filepath.Base(p)
+		if _, dup := out[name]; dup {
+			name = strconv.Itoa(i) + "-" + name
+		}
+		out[name] = p
+	}
+	return out
+}
+
+// dbFile — имя дампа в архиве по драйверу.
+func dbFile(driver string) string {
+	if driver == "sqlite" {
+		return "agent.db"
+	}
+	return "dump.sql"
+}
+
+// archiveFile — файл для записи в архив.
+type archiveFile struct {
+	name string // Путь в архиве
+	path string // Путь на диске
+}
+
+// Create — снимает копию в каталог dir: stack-ГГГГММДД-ЧЧММСС[-label].tar.gz.
+// Архив пишется во временный файл и переименовывается только целиком.
+func Create(ctx context.Context, dir string, src Source, opts Options) (Info, error) {
+	comps, err := selectComponents(opts.Components, src.available())
+	if err != nil {
+		return Info{}, err
+	}
+	if len(comps) == 0 {
+		return Info{}, fmt.Errorf("%w: нечего копировать", ErrNoSource)
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return Info{}, err
+	}
+	tmpDir, err := os.MkdirTemp("", "stack-backup-")
+	if err != nil {
+		return Info{}, err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	host, _ := os.Hostname()
+	man := Manifest{Version: FormatVersion, CreatedAt: time.Now().UTC(), Host: host, Components: comps, Files: map[string]int{}}
+	var files []archiveFile
+	for _, c := range comps {
+		var part []archiveFile
+		switch c {
+		case ComponentDB:
+			man.DBDriver = src.DB.Driver()
+			dump := filepath.Join(tmpDir, dbFile(man.DBDriver))
+			if err := src.DB.Dump(ctx, dump); err != nil {
+				return Info{}, fmt.Errorf("дамп базы: %w", err)
+			}
+			part = []archiveFile{{name: "db/" + dbFile(man.DBDriver), path: dump}}
+		case ComponentConfig:
+			man.Config = map[string]string{}
+			for name, p := range configNames(src.Config) {
+				if st, err := os.Stat(p); err != nil || !st.Mode().IsRegular() {
+					continue
+				}
+				man.Config[name] = p
+				part = append(part, archiveFile{name: "config/" + name, path: p})
+			}
+			sort.Slice(part, func(i, j int) bool { return part[i].name < part[j].name })
+		default:
+			if part, err = dirFiles(src.Dirs[c], c); err != nil {
+				return Info{}, fmt.Errorf("%s: %w", c, err)
+			}
+		}
+		man.Files[c] = len(part)
+		files = append(files, part...)
+	}
+
+	base := "stack-" + man.CreatedAt.Local().Format("20060102-150405")
+	if opts.Label != "" {
+		base += "-" + opts.Label
+	}
+	// Две копии за одну секунду не должны затирать друг друга
+	name := base + ".tar.gz"
+	for i := 2; ; i++ {
+		if _, err := os.Stat(filepath.Join(dir, name)); errors.Is(err, fs.ErrNotExist) {
+			break
+		}
+		name = base + "-" + strconv.Itoa(i) + ".tar.gz"
+	}
+	tmp, err := os.CreateTemp(dir, ".stack-*.tmp")
+	if err != nil {
+		return Info{}, err
+	}
+	defer os.Remove(tmp.Name())
+	sum := sha256.New()
+	size, err := writeArchive(ctx, io.MultiWriter(tmp, sum), man, files)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return Info{}, err
+	}
+	if err := os.Rename(tmp.Name(), filepath.Join(dir, name)); err != nil {
+		return Info{}, err
+	}
+	return Info{Name: name, Size: size, SHA256: hex.EncodeToString(sum.Sum(nil)), CreatedAt: man.CreatedAt, Manifest: &man}, nil
+}
+
+// dirFiles — обычные файлы каталога root с путями prefix/… в архиве.
+// Отсутствующий каталог — пустой компонент; ссылки не копируются.
+func dirFiles(root, prefix string) ([]archiveFile, error) {
+	var out []archiveFile
+	err := filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if p == root && errors.Is(err, fs.ErrNotExist) {
+				return filepath.SkipDir
+			}
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			return err
+		}
+		out = append(out, archiveFile{name: prefix + "/" + filepath.ToSlash(rel), path: p})
+		return nil
+	})
+	return out, err
+}
+
+// countingWriter — считает записанные байты.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// writeArchive — манифест и файлы в tar.gz; возвращает размер архива.
+func writeArchive(ctx context.Context, w io.Writer, man Manifest, files []archiveFile) (int64, error) {
+	cw := &countingWriter{w: w}
+	gz := gzip.NewWriter(cw)
+	tw := tar.NewWriter(gz)
+	data, err := json.MarshalIndent(man, "", "  ")
+	if err != nil {
+		return 0, err
+	}
+	if err := tw.WriteHeader(&tar.Header{Name: manifestName, Mode: 0600, Size: int64(len(data)), ModTime: man.CreatedAt, Typeflag: tar.TypeReg}); err != nil {
+		return 0, err
+	}
+	if _, err := tw.Write(data); err != nil {
+		return 0, err
+	}
+	for _, f := range files {
+		if err := ctx.Err(); err != nil {
+			return 0, err
+		}
+		if err := addFile(tw, f); err != nil {
+			return 0, fmt.Errorf("%s: %w", f.name, err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return 0, err
+	}
+	if err := gz.Close(); err != nil {
+		return 0, err
+	}
+	return cw.n, nil
+}
+
+// addFile — одна запись архива. Размер берётся из открытого файла, чтобы
+// запись, изменившаяся во время копирования, не испортила архив.
+func addFile(tw *tar.Writer, f archiveFile) error {
+	src, err := os.Open(f.path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	st, err := src.Stat()
+	if err != nil {
+		return err
+	}
+	hdr := &tar.Header{Name: f.name, Mode: int64(st.Mode().Perm()), Size: st.Size(), ModTime: st.ModTime(), Typeflag: tar.TypeReg}
+	if err := tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	_, err = io.CopyN(tw, src, st.Size())
+	return err
+}
+
+// List — копии в каталоге dir, новые первыми. Отсутствующий каталог — пустой список.
+func List(dir string) ([]Info, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "stack-*.tar.gz"))
+	if err != nil {
+		return nil, err
+	}
+	out := make([]Info, 0, len(paths))
+	for _, p := range paths {
+		st, err := os.Stat(p)
+		if err != nil {
+			continue
+		}
+		info := Info{Name: filepath.Base(p), Size: st.Size(), CreatedAt: st.ModTime()}
+		if man, err := ReadManifest(p); err == nil {
+			info.Manifest, info.CreatedAt = &man, man.CreatedAt
+		}
+		out = append(out, info)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].CreatedAt.After(out[j].CreatedAt) })
+	return out, nil
+}
+
+// Path — путь к копии name в каталоге dir; имя проверяется, чтобы запрос
+// не вышел за пределы каталога.
+func Path(dir, name string) (string, error) {
+	if name != filepath.Base(name) || !strings.HasPrefix(name, "stack-") || !strings.HasSuffix(name, ".tar.gz") {
+		return "", fmt.Errorf("%w: %q", ErrNotFound, name)
+	}
+	p := filepath.Join(dir, name)
+	if _, err := os.Stat(p); err != nil {
+		return "", fmt.Errorf("%w: %q", ErrNotFound, name)
+	}
+	return p, nil
+}
+
+// Prune — оставляет keep новейших копий и возвращает имена удалённых (keep ≤ 0 — не удалять).
+func Prune(dir string, keep int) ([]string, error) {
+	if keep <= 0 {
+		return nil, nil
+	}
+	list, err := List(dir)
+	if err != nil || len(list) <= keep {
+		return nil, err
+	}
+	var removed []string
+	for _, info := range list[keep:] {
+		if err := os.Remove(filepath.Join(dir, info.Name)); err != nil {
+			return removed, err
+		}
+		removed = append(removed, info.Name)
+	}
+	return removed, nil
+}
+
+// ReadManifest — манифест архива без распаковки остального.
+func ReadManifest(archivePath string) (Manifest, error) {
+	f, err := os.Open(archivePath)
+	if err != nil {
+		return Manifest{}, err
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return Manifest{}, fmt.Errorf("%w: %v", ErrBadArchive, err)
+	}
+	tr := tar.NewReader(gz)
+	hdr, err := tr.Next()
+	if err != nil || hdr.Name != manifestName {
+		return Manifest{}, fmt.Errorf("%w: первой записью должен быть %s", ErrBadArchive, manifestName)
+	}
+	return decodeManifest(tr)
+}
+
+// decodeManifest — разбор и проверка манифеста.
+func decodeManifest(r io.Reader) (Manifest, error) {
+	var man Manifest
+	if err := json.NewDecoder(io.LimitReader(r, 1<<20)).Decode(&man); err != nil {
+		return Manifest{}, fmt.Errorf("%w: манифест: %v", ErrBadArchive, err)
+	}
+	if man.Version < 1 || man.Version > FormatVersion {
+		return Manifest{}, fmt.Errorf("%w: версия формата %d, поддерживается до %d", ErrBadArchive, man.Version, FormatVersion)
+	}
+	return man, nil
+}
+
+// RestoreResult — итог восстановления (или проверки при DryRun).
+type RestoreResult struct {
+	Manifest   Manifest       `json:"manifest"`
+	DryRun     bool           `json:"dry_run"`
+	Components []string       `json:"components"`        // Восстановленные (при DryRun — которые были бы)
+	Files      map[string]int `json:"files"`             // Файлов по компонентам
+	Skipped    []string       `json:"skipped,omitempty"` // Файлы конфигурации, которых нет в текущей установке
+}
+
+// Restore — восстанавливает компоненты из архива r (пусто — все, что есть
+// в архиве). Файлы каталогов перезаписываются, лишние файлы остаются;
+// конфигурация пишется только в пути, настроенные в dst.Config.
+func Restore(ctx context.Context, r io.Reader, dst Source, components []string, dryRun bool) (RestoreResult, error) {
+	staging, err := os.MkdirTemp("", "stack-restore-")
+	if err != nil {
+		return RestoreResult{}, err
+	}
+	defer os.RemoveAll(staging)
+	man, err := extract(ctx, r, staging)
+	if err != nil {
+		return RestoreResult{}, err
+	}
+	comps, err := selectComponents(components, man.Components)
+	if err != nil {
+		return RestoreResult{}, err
+	}
+	res := RestoreResult{Manifest: man, DryRun: dryRun, Components: comps, Files: map[string]int{}}
+
+	// Всё, что может не сойтись, проверяется до первой записи
+	dbPath := filepath.Join(staging, "db", dbFile(man.DBDriver))
+	for _, c := range comps {
+		switch c {
+		case ComponentDB:
+			if dst.DB == nil {
+				return RestoreResult{}, fmt.Errorf("%w: %s", ErrNoSource, c)
+			}
+			if man.DBDriver != dst.DB.Driver() {
+				return RestoreResult{}, fmt.Errorf("%w: копия базы %s, текущая база %s", ErrBadArchive, man.DBDriver, dst.DB.Driver())
+			}
+			if _, err := os.Stat(dbPath); err != nil {
+				return RestoreResult{}, fmt.Errorf("%w: нет дампа базы", ErrBadArchive)
+			}
+			res.Files[c] = 1
+		case ComponentConfig:
+			targets := configNames(dst.Config)
+			for name := range man.Config {
+				if _, ok := targets[name]; ok {
+					res.Files[c]++
+				} else {
+					res.Skipped = append(res.Skipped, name)
+				}
+			}
+			sort.Strings(res.Skipped)
+		default:
+			if dst.Dirs[c] == "" {
+				return RestoreResult{}, fmt.Errorf("%w: %s", ErrNoSource, c)
+			}
+			files, err := dirFiles(filepath.Join(staging, c), c)
+			if err != nil {
+				return RestoreResult{}, err
+			}
+			res.Files[c] = len(files)
+		}
+	}
+	if dryRun {
+		return res, nil
+	}
+
+	for _, c := range comps {
+		switch c {
+		case ComponentDB:
+			if err := dst.DB.Restore(ctx, dbPath); err != nil {
+				return res, fmt.Errorf("восстановление базы: %w", err)
+			}
+		case ComponentConfig:
+			targets := configNames(dst.Config)
+			for name := range man.Config {
+				if target, ok := targets[name]; ok {
+					if err := copyFile(filepath.Join(staging, c, name), target); err != nil {
+						return res, fmt.Errorf("%s: %w", target, err)
+					}
+				}
+			}
+		default:
+			if err := copyTree(filepath.Join(staging, c), dst.Dirs[c]); err != nil {
+				return res, fmt.Errorf("%s: %w", c, err)
+			}
+		}
+	}
+	return res, nil
+}
+
+// extract — распаковка архива в каталог dir с проверкой каждой записи.
+func extract(ctx context.Context, r io.Reader, dir string) (Manifest, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return Manifest{}, fmt.Errorf("%w: %v", ErrBadArchive, err)
+	}
+	tr := tar.NewReader(gz)
+	hdr, err := tr.Next()
+	if err != nil || hdr.Name != manifestName {
+		return Manifest{}, fmt.Errorf("%w: первой записью должен быть %s", ErrBadArchive, manifestName)
+	}
+	man, err := decodeManifest(tr)
+	if err != nil {
+		return Manifest{}, err
+	}
+	for {
+		if err := ctx.Err(); err != nil {
+			return Manifest{}, err
+		}
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return Manifest{}, fmt.Errorf("%w: %v", ErrBadArchive, err)
+		}
+		if hdr.Typeflag == tar.TypeDir {
+			continue
+		}
+		name, err := entryName(hdr, man)
+		if err != nil {
+			return Manifest{}, err
+		}
+		target := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(target), 0700); err != nil {
+			return Manifest{}, err
+		}
+		f, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+		if err != nil {
+			return Manifest{}, err
+		}
+		_, err = io.Copy(f, tr)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			return Manifest{}, fmt.Errorf("%w: %s: %v", ErrBadArchive, name, err)
+		}
+	}
+	return man, nil
+}
+
+// entryName — проверенный путь записи: обычный файл внутри компонента из манифеста.
+func entryName(hdr *tar.Header, man Manifest) (string, error) {
+	if hdr.Typeflag != tar.TypeReg {
+		return "", fmt.Errorf("%w: %s — не обычный файл", ErrBadArchive, hdr.Name)
+	}
+	name := path.Clean(hdr.Name)
+	comp, rest, ok := strings.Cut(name, "/")
+	if !ok || rest == "" || path.IsAbs(name) || strings.HasPrefix(name, "../") || !slices.Contains(man.Components, comp) {
+		return "", fmt.Errorf("%w: недопустимый путь %q", ErrBadArchive, hdr.Name)
+	}
+	if comp == ComponentConfig && strings.Contains(rest, "/") {
+		return "", fmt.Errorf("%w: недопустимый путь %q", ErrBadArchive, hdr.Name)
+	}
+	return name, nil
+}
+
+// copyTree — копирует файлы каталога src в dst поверх существующих.
+func copyTree(src, dst string) error {
+	return filepath.WalkDir(src, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if p == src && errors.Is(err, fs.ErrNotExist) {
+				return filepath.SkipDir
+			}
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(src, p)
+		if err != nil {
+			return err
+		}
+		return copyFile(p, filepath.Join(dst, rel))
+	})
+}
+
+// copyFile — атомарная замена файла dst содержимым src (через временный файл рядом).
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(dst), ".restore-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	_, err = io.Copy(tmp, in)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), dst)
+}
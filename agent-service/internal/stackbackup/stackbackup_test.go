@@ -0,0 +1,249 @@
+package stackbackup
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeDB — база, дамп которой — содержимое поля data.
+type fakeDB struct {
+	data     string
+	restored string
+}
+
+func (f *fakeDB) Driver() string { return "postgres" }
+
+func (f *fakeDB) Dump(_ context.Context, path string) error {
+	return os.WriteFile(path, []byte(f.data), 0600)
+}
+
+func (f *fakeDB) Restore(_ context.Context, path string) error {
+	data, err := os.ReadFile(path)
+	f.restored = string(data)
+	return err
+}
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+// TestCreateRestore — копия всех компонентов восстанавливается поверх
+// изменённых данных; проверка (dry run) ничего не меняет.
+func TestCreateRestore(t *testing.T) {
+	root := t.TempDir()
+	uploads, prompts := filepath.Join(root, "uploads"), filepath.Join(root, "prompts")
+	cfgFile := filepath.Join(root, "etc", "agent.yaml")
+	writeFile(t, filepath.Join(uploads, "avatars", "admin.png"), "png")
+	writeFile(t, filepath.Join(prompts, "admin", "system.md"), "Ты админ")
+	writeFile(t, cfgFile, "port: 8083")
+	database := &fakeDB{data: "CREATE TABLE agents;"}
+	src := Source{DB: database, Dirs: map[string]string{ComponentUploads: uploads, ComponentPrompts: prompts}, Config: []string{cfgFile, filepath.Join(root, "missing.yaml")}}
+
+	dir := filepath.Join(root, "backups")
+	info, err := Create(context.Background(), dir, src, Options{})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if info.Manifest.DBDriver != "postgres" || info.Manifest.Files[ComponentUploads] != 1 || info.Manifest.Files[ComponentConfig] != 1 || len(info.SHA256) != 64 {
+		t.Errorf("копия: %+v %+v", info, info.Manifest)
+	}
+	list, err := List(dir)
+	if err != nil || len(list) != 1 || list[0].Name != info.Name || list[0].Size != info.Size || list[0].Manifest == nil {
+		t.Fatalf("список: %+v, %v", list, err)
+	}
+
+	writeFile(t, filepath.Join(prompts, "admin", "system.md"), "испорчено")
+	writeFile(t, cfgFile, "port: 1")
+	path, err := Path(dir, info.Name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, _ := os.Open(path)
+	res, err := Restore(context.Background(), f, src, nil, true)
+	f.Close()
+	if err != nil || !res.DryRun || len(res.Components) != 4 || database.restored != "" || readFile(t, cfgFile) != "port: 1" {
+		t.Fatalf("проверка: %+v, %v", res, err)
+	}
+
+	f, _ = os.Open(path)
+	res, err = Restore(context.Background(), f, src, []string{ComponentDB, ComponentPrompts, ComponentConfig}, false)
+	f.Close()
+	if err != nil {
+		t.Fatalf("Restore: %v", err)
+	}
+	if database.restored != "CREATE TABLE agents;" || readFile(t, filepath.Join(prompts, "admin", "system.md")) != "Ты админ" || readFile(t, cfgFile) != "port: 8083" {
+		t.Errorf("восстановлено не всё: %+v", res)
+	}
+	if res.Files[ComponentPrompts] != 1 || strings.Join(res.Components, ",") != "db,prompts,config" {
+		t.Errorf("итог: %+v", res)
+	}
+}
+
+// TestCreateComponents — неизвестный или не настроенный компонент — ошибка.
+func TestCreateComponents(t *testing.T) {
+	src := Source{Dirs: map[string]string{ComponentPrompts: t.TempDir()}}
+	if _, err := Create(context.Background(), t.TempDir(), src, Options{Components: []string{"secrets"}}); !errors.Is(err, ErrUnknownComponent) {
+		t.Errorf("неизвестный компонент: %v", err)
+	}
+	if _, err := Create(context.Background(), t.TempDir(), src, Options{Components: []string{ComponentDB}}); !errors.Is(err, ErrNoSource) {
+		t.Errorf("нет базы: %v", err)
+	}
+	info, err := Create(context.Background(), t.TempDir(), src, Options{Label: "pre-restore"})
+	if err != nil || !strings.HasSuffix(info.Name, "-pre-restore.tar.gz") || strings.Join(info.Manifest.Components, ",") != ComponentPrompts {
+		t.Errorf("только промпты: %+v, %v", info, err)
+	}
+}
+
+// archive — tar.gz с манифестом и записями name → content.
+func archive(t *testing.T, manifest string, entries ...tar.Header) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	tw.WriteHeader(&tar.Header{Name: manifestName, Mode: 0600, Size: int64(len(manifest)), Typeflag: tar.TypeReg})
+	tw.Write([]byte(manifest))
+	for _, h := range entries {
+		h := h
+		tw.WriteHeader(&h)
+		if h.Typeflag == tar.TypeReg {
+			tw.Write(bytes.Repeat([]byte("x"), int(h.Size)))
+		}
+	}
+	tw.Close()
+	gz.Close()
+	return &buf
+}
+
+// TestRestoreRejects — выход за пределы каталога, ссылки, чужой драйвер БД
+// и новая версия формата отклоняются до изменения данных.
+func TestRestoreRejects(t *testing.T) {
+	dst := Source{DB: &fakeDB{}, Dirs: map[string]string{ComponentUploads: t.TempDir()}}
+	man := `{"version":1,"components":["uploads"]}`
+	cases := map[string]*bytes.Buffer{
+		"обход":        archive(t, man, tar.Header{Name: "uploads/../../etc/passwd", Size: 1, Typeflag: tar.TypeReg}),
+		"абсолютный":   archive(t, man, tar.Header{Name: "/uploads/a", Size: 1, Typeflag: tar.TypeReg}),
+		"ссылка":       archive(t, man, tar.Header{Name: "uploads/a", Linkname: "/etc/passwd", Typeflag: tar.TypeSymlink}),
+		"компонент":    archive(t, man, tar.Header{Name: "prompts/a", Size: 1, Typeflag: tar.TypeReg}),
+		"версия":       archive(t, `{"version":99,"components":["uploads"]}`),
+		"драйвер":      archive(t, `{"version":1,"db_driver":"sqlite","components":["db"]}`, tar.Header{Name: "db/agent.db", Size: 1, Typeflag: tar.TypeReg}),
+		"не архив":     bytes.NewBufferString("hello"),
+		"без дампа БД": archive(t, `{"version":1,"db_driver":"postgres","components":["db"]}`),
+	}
+	for name, buf := range cases {
+		if _, err := Restore(context.Background(), buf, dst, nil, false); !errors.Is(err, ErrBadArchive) {
+			t.Errorf("%s: %v", name, err)
+		}
+	}
+	if entries, _ := os.ReadDir(dst.Dirs[ComponentUploads]); len(entries) != 0 {
+		t.Errorf("каталог изменён: %v", entries)
+	}
+}
+
+// TestPathPrune — имя вне каталога не принимается, старые копии удаляются.
+func TestPathPrune(t *testing.T) {
+	dir := t.TempDir()
+	for i, name := range []string{"stack-20260101-030000.tar.gz", "stack-20260102-030000.tar.gz", "stack-20260103-030000.tar.gz"} {
+		writeFile(t, filepath.Join(dir, name), "x")
+		mt := time.Date(2026, 1, i+1, 3, 0, 0, 0, time.UTC)
+		os.Chtimes(filepath.Join(dir, name), mt, mt)
+	}
+	if _, err := Path(dir, "../stack-20260101-030000.tar.gz"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("обход: %v", err)
+	}
+	removed, err := Prune(dir, 2)
+	if err != nil || len(removed) != 1 || removed[0] != "stack-20260101-030000.tar.gz" {
+		t.Errorf("Prune: %v, %v", removed, err)
+	}
+}
+
+// TestYandexDiskUpload — папки создаются по уровням, архив уходит по выданной ссылке.
+func TestYandexDiskUpload(t *testing.T) {
+	var calls []string
+	var body string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls = append(calls, r.Method+" "+r.URL.Path+" "+r.URL.Query().Get("path"))
+		switch {
+		case r.URL.Path == "/resources" && r.Header.Get("Authorization") == "OAuth tok":
+			if r.URL.Query().Get("path") == "/Backups" {
+				w.WriteHeader(http.StatusConflict)
+				return
+			}
+			w.WriteHeader(http.StatusCreated)
+		case r.URL.Path == "/resources/upload":
+			io.WriteString(w, `{"href":"http://`+r.Host+`/put","method":"PUT"}`)
+		case r.URL.Path == "/put":
+			data, _ := io.ReadAll(r.Body)
+			body = string(data)
+			w.WriteHeader(http.StatusCreated)
+		default:
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	defer srv.Close()
+	remote, err := ParseRemote("ydisk:/Backups/agent/", Credentials{YandexDiskToken: "tok"}, srv.Client())
+	if err != nil {
+		t.Fatal(err)
+	}
+	remote.(*YandexDisk).BaseURL = srv.URL
+	loc, err := remote.Upload(context.Background(), "stack-1.tar.gz", strings.NewReader("archive"), 7)
+	if err != nil || loc != "ydisk:/Backups/agent/stack-1.tar.gz" || body != "archive" {
+		t.Fatalf("загрузка: %q, %v, %q", loc, err, body)
+	}
+	if len(calls) != 4 || calls[2] != "GET /resources/upload /Backups/agent/stack-1.tar.gz" {
+		t.Errorf("запросы: %v", calls)
+	}
+}
+
+// TestS3Upload — путь объекта и заголовки подписи SigV4.
+func TestS3Upload(t *testing.T) {
+	var got *http.Request
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r
+		io.Copy(io.Discard, r.Body)
+	}))
+	defer srv.Close()
+	remote, err := ParseRemote("s3://backups/agent", Credentials{S3Endpoint: srv.URL + "/", S3Region: "ru-central1", S3AccessKey: "AK", S3SecretKey: "SK"}, srv.Client())
+	if err != nil {
+		t.Fatal(err)
+	}
+	s3 := remote.(*S3)
+	s3.now = func() time.Time { return time.Date(2026, 10, 18, 3, 0, 0, 0, time.UTC) }
+	loc, err := s3.Upload(context.Background(), "stack-1.tar.gz", strings.NewReader("archive"), 7)
+	if err != nil || loc != "s3://backups/agent/stack-1.tar.gz" {
+		t.Fatalf("загрузка: %q, %v", loc, err)
+	}
+	auth := got.Header.Get("Authorization")
+	if got.URL.Path != "/backups/agent/stack-1.tar.gz" || got.Header.Get("x-amz-date") != "20261018T030000Z" ||
+		!strings.HasPrefix(auth, "AWS4-HMAC-SHA256 Credential=AK/20261018/ru-central1/s3/aws4_request, SignedHeaders=host;x-amz-content-sha256;x-amz-date, Signature=") {
+		t.Errorf("запрос: %s %v", got.URL.Path, got.Header)
+	}
+	if _, err := ParseRemote("ftp://host", Credentials{}, nil); err == nil {
+		t.Error("неизвестная схема принята")
+	}
+}
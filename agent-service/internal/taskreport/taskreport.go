@@ -0,0 +1,132 @@
+// Package taskreport — структурированный итог запроса /chat: какие
+// инструменты вызвал агент, какие файлы создал или изменил и чем
+// закончилась задача. Программным клиентам и интерфейсу не нужно
+// разбирать текст ответа, чтобы узнать, что произошло.
+package taskreport
+
+import (
+	"fmt"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// Итог задачи (поле status ответа /chat).
+const (
+	StatusCompleted         = "completed"          // Ответ получен, все вызовы инструментов успешны
+	StatusPartial           = "partial"            // Часть вызовов инструментов завершилась ошибкой
+	StatusNeedsConfirmation = "needs_confirmation" // Разрушительные действия ждут подтверждения пользователя
+	StatusNeedsInput        = "needs_input"        // Агент задал уточняющий вопрос (ask_user) и ждёт ответа
+	StatusFailed            = "failed"             // Ответ не получен (поле error)
+)
+
+// Итог одного вызова инструмента.
+const (
+	ActionOK                   = "ok"
+	ActionError                = "error"
+	ActionConfirmationRequired = "confirmation_required"
+)
+
+// maxErrorChars — предел длины текста ошибки в действии.
+const maxErrorChars = 300
+
+// Action — вызов инструмента.
+type Action struct {
+	Tool       string `json:"tool"`
+	Target     string `json:"target,omitempty"` // Путь, команда, URL или запрос — как в оценке риска
+	Status     string `json:"status"`           // ok | error | confirmation_required
+	Error      string `json:"error,omitempty"`
+	DurationMs int64  `json:"duration_ms"`
+}
+
+// Artifact — созданный или изменённый файл: ID и URL — для файлов из
+// /artifacts, Path — для файлов, изменённых на диске (edit_file, write).
+type Artifact struct {
+	ID   uint   `json:"id,omitempty"`
+	Name string `json:"name,omitempty"`
+	Path string `json:"path,omitempty"`
+	URL  string `json:"url,omitempty"`
+}
+
+// Report — действия и файлы одного запроса; безопасен для параллельных вызовов.
+type Report struct {
+	mu        sync.Mutex
+	actions   []Action
+	artifacts []Artifact
+}
+
+// ActionStatus — итог вызова по результату инструмента: ключ error —
+// ошибка (с текстом), confirmation_required — вызов ждёт подтверждения.
+func ActionStatus(result map[string]interface{}) (status, errText string) {
+	if confirm, _ := result["confirmation_required"].(bool); confirm {
+		return ActionConfirmationRequired, ""
+	}
+	if msg, failed := result["error"]; failed {
+		return ActionError, truncate(fmt.Sprint(msg), maxErrorChars)
+	}
+	return ActionOK, ""
+}
+
+// Record — добавляет вызов инструмента по его результату (см. ActionStatus).
+func (r *Report) Record(tool, target string, result map[string]interface{}, elapsed time.Duration) {
+	a := Action{Tool: tool, Target: target, DurationMs: elapsed.Milliseconds()}
+	a.Status, a.Error = ActionStatus(result)
+	r.mu.Lock()
+	r.actions = append(r.actions, a)
+	r.mu.Unlock()
+}
+
+// AddArtifacts — добавляет файлы; повтор того же ID или пути не дублируется.
+func (r *Report) AddArtifacts(list ...Artifact) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, a := range list {
+		dup := false
+		for _, cur := range r.artifacts {
+			if (a.ID != 0 && cur.ID == a.ID) || (a.ID == 0 && a.Path != "" && cur.ID == 0 && cur.Path == a.Path) {
+				dup = true
+				break
+			}
+		}
+		if !dup {
+			r.artifacts = append(r.artifacts, a)
+		}
+	}
+}
+
+// Actions — копия списка действий.
+func (r *Report) Actions() []Action {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Action(nil), r.actions...)
+}
+
+// Artifacts — копия списка файлов.
+func (r *Report) Artifacts() []Artifact {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Artifact(nil), r.artifacts...)
+}
+
+// Status — итог задачи: подтверждение важнее ошибок инструментов.
+func (r *Report) Status() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	status := StatusCompleted
+	for _, a := range r.actions {
+		switch a.Status {
+		case ActionConfirmationRequired:
+			return StatusNeedsConfirmation
+		case ActionError:
+			status = StatusPartial
+		}
+	}
+	return status
+}
+
+func truncate(s string, n int) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	return string([]rune(s)[:n]) + "…"
+}
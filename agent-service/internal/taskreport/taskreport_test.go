@@ -0,0 +1,41 @@
+package taskreport
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestStatus — ошибка инструмента даёт partial, ожидание подтверждения важнее.
+func TestStatus(t *testing.T) {
+	var r Report
+	if r.Status() != StatusCompleted {
+		t.Errorf("без действий: %s", r.Status())
+	}
+	r.Record("read", "/srv/a.go", map[string]interface{}{"content": "x"}, 15*time.Millisecond)
+	r.Record("execute", "make", map[string]interface{}{"error": strings.Repeat("е", 500)}, time.Second)
+	if r.Status() != StatusPartial {
+		t.Errorf("с ошибкой: %s", r.Status())
+	}
+	actions := r.Actions()
+	if len(actions) != 2 || actions[0].Status != ActionOK || actions[0].DurationMs != 15 || actions[1].Status != ActionError {
+		t.Fatalf("действия: %+v", actions)
+	}
+	if n := len([]rune(actions[1].Error)); n != maxErrorChars+1 {
+		t.Errorf("длина ошибки %d", n)
+	}
+	r.Record("delete", "/srv", map[string]interface{}{"error": "нужно подтверждение", "confirmation_required": true}, 0)
+	if r.Status() != StatusNeedsConfirmation {
+		t.Errorf("с подтверждением: %s", r.Status())
+	}
+}
+
+// TestAddArtifacts — повтор ID или пути не дублируется.
+func TestAddArtifacts(t *testing.T) {
+	var r Report
+	r.AddArtifacts(Artifact{ID: 1, Name: "shot.png"}, Artifact{Path: "/srv/a.go"})
+	r.AddArtifacts(Artifact{ID: 1, Name: "shot.png"}, Artifact{Path: "/srv/a.go"}, Artifact{ID: 2, Path: "/srv/a.go"})
+	if got := r.Artifacts(); len(got) != 3 {
+		t.Errorf("файлы: %+v", got)
+	}
+}
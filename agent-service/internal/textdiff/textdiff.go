@@ -0,0 +1,193 @@
+// Package textdiff — построчное сравнение текстов в формате unified diff
+// (как git diff): используется, чтобы показать, что именно изменили
+// инструменты edit_file и write.
+//
+// Общие начало и конец отбрасываются сразу, середина сравнивается через
+// наибольшую общую подпоследовательность строк. Если середина слишком
+// велика для сравнения, она целиком показывается как удалённая и добавленная.
+package textdiff
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Context — сколько неизменённых строк показывать вокруг изменений.
+const Context = 3
+
+// maxCells — предел размера таблицы сравнения (строк до × строк после).
+const maxCells = 4 << 20
+
+// Diff — результат сравнения.
+type Diff struct {
+	Text    string // unified diff; пусто — тексты совпадают
+	Added   int    // Добавлено строк
+	Removed int    // Удалено строк
+}
+
+type op struct {
+	kind byte // ' ', '-', '+'
+	line string
+	a, b int // Сколько строк старого и нового текста прошло до этой
+}
+
+// Unified — разница между before и after для файла name.
+func Unified(name, before, after string) Diff {
+	a, b := splitLines(before), splitLines(after)
+	ops := compare(a, b)
+	var d Diff
+	for _, o := range ops {
+		switch o.kind {
+		case '+':
+			d.Added++
+		case '-':
+			d.Removed++
+		}
+	}
+	if d.Added == 0 && d.Removed == 0 {
+		return d
+	}
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "--- a/%s\n+++ b/%s\n", strings.TrimPrefix(name, "/"), strings.TrimPrefix(name, "/"))
+	for _, h := range hunks(ops) {
+		writeHunk(&sb, ops[h[0]:h[1]])
+	}
+	d.Text = sb.String()
+	return d
+}
+
+// splitLines — строки текста без завершающего перевода строки.
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
+
+// compare — последовательность операций, превращающая a в b.
+func compare(a, b []string) []op {
+	pre := 0
+	for pre < len(a) && pre < len(b) && a[pre] == b[pre] {
+		pre++
+	}
+	suf := 0
+	for suf < len(a)-pre && suf < len(b)-pre && a[len(a)-1-suf] == b[len(b)-1-suf] {
+		suf++
+	}
+	ops := make([]op, 0, len(a)+len(b))
+	for i := 0; i < pre; i++ {
+		ops = append(ops, op{' ', a[i], i, i})
+	}
+	ma, mb := a[pre:len(a)-suf], b[pre:len(b)-suf]
+	ops = append(ops, middle(ma, mb, pre, pre)...)
+	for i := 0; i < suf; i++ {
+		ai, bi := len(a)-suf+i, len(b)-suf+i
+		ops = append(ops, op{' ', a[ai], ai, bi})
+	}
+	return ops
+}
+
+// middle — операции для различающейся середины; offA, offB — её начало.
+func middle(a, b []string, offA, offB int) []op {
+	var ops []op
+	if len(a)*len(b) > maxCells || len(a) == 0 || len(b) == 0 {
+		for i, l := range a {
+			ops = append(ops, op{'-', l, offA + i, offB})
+		}
+		for j, l := range b {
+			ops = append(ops, op{'+', l, offA + len(a), offB + j})
+		}
+		return ops
+	}
+	// lcs[i][j] — длина общей подпоследовательности a[i:] и b[j:]
+	lcs := make([][]int32, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int32, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			ops = append(ops, op{' ', a[i], offA + i, offB + j})
+			i, j = i+1, j+1
+		case j == len(b) || (i < len(a) && lcs[i+1][j] >= lcs[i][j+1]):
+			ops = append(ops, op{'-', a[i], offA + i, offB + j})
+			i++
+		default:
+			ops = append(ops, op{'+', b[j], offA + i, offB + j})
+			j++
+		}
+	}
+	return ops
+}
+
+// hunks — границы [начало, конец) фрагментов с изменениями и Context строк вокруг.
+func hunks(ops []op) [][2]int {
+	var out [][2]int
+	for i := 0; i < len(ops); i++ {
+		if ops[i].kind == ' ' {
+			continue
+		}
+		start := max(i-Context, 0)
+		end := i
+		for end < len(ops) {
+			if ops[end].kind != ' ' {
+				end++
+				continue
+			}
+			// Неизменённых строк до следующего изменения больше 2×Context — фрагмент кончился
+			k := end
+			for k < len(ops) && ops[k].kind == ' ' {
+				k++
+			}
+			if k == len(ops) || k-end > 2*Context {
+				end = min(end+Context, len(ops))
+				break
+			}
+			end = k
+		}
+		if n := len(out); n > 0 && start <= out[n-1][1] {
+			out[n-1][1] = end
+		} else {
+			out = append(out, [2]int{start, end})
+		}
+		i = end - 1
+	}
+	return out
+}
+
+func writeHunk(sb *strings.Builder, ops []op) {
+	aStart, bStart := ops[0].a+1, ops[0].b+1
+	var aCount, bCount int
+	for _, o := range ops {
+		if o.kind != '+' {
+			aCount++
+		}
+		if o.kind != '-' {
+			bCount++
+		}
+	}
+	if aCount == 0 {
+		aStart--
+	}
+	if bCount == 0 {
+		bStart--
+	}
+	fmt.Fprintf(sb, "@@ -%d,%d +%d,%d @@\n", aStart, aCount, bStart, bCount)
+	for _, o := range ops {
+		sb.WriteByte(o.kind)
+		sb.WriteString(o.line)
+		sb.WriteByte('\n')
+	}
+}
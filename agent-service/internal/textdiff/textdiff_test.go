@@ -0,0 +1,39 @@
+package textdiff
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestUnified — замена строки в середине, контекст и номера строк заголовка.
+func TestUnified(t *testing.T) {
+	before := "a\nb\nc\nd\ne\nf\ng\nh\n"
+	after := "a\nb\nc\nd\nE\nf\ng\nh\n"
+	d := Unified("/srv/app/main.go", before, after)
+	want := "--- a/srv/app/main.go\n+++ b/srv/app/main.go\n@@ -2,7 +2,7 @@\n b\n c\n d\n-e\n+E\n f\n g\n h\n"
+	if d.Text != want || d.Added != 1 || d.Removed != 1 {
+		t.Errorf("diff:\n%s\nожидалось:\n%s", d.Text, want)
+	}
+	if d := Unified("x", before, before); d.Text != "" || d.Added+d.Removed != 0 {
+		t.Errorf("одинаковые тексты: %+v", d)
+	}
+}
+
+// TestUnifiedNewFile — новый файл целиком добавлен, далёкие изменения — разные фрагменты.
+func TestUnifiedNewFile(t *testing.T) {
+	d := Unified("new.sh", "", "#!/bin/sh\necho ok\n")
+	if !strings.Contains(d.Text, "@@ -0,0 +1,2 @@\n+#!/bin/sh\n+echo ok\n") || d.Added != 2 {
+		t.Errorf("новый файл:\n%s", d.Text)
+	}
+
+	var lines []string
+	for i := 0; i < 30; i++ {
+		lines = append(lines, "строка")
+	}
+	before := strings.Join(lines, "\n")
+	lines[1], lines[28] = "первая правка", "вторая правка"
+	d = Unified("f", before, strings.Join(lines, "\n"))
+	if n := strings.Count(d.Text, "@@ -"); n != 2 || d.Added != 2 || d.Removed != 2 {
+		t.Errorf("ожидалось два фрагмента (%d):\n%s", n, d.Text)
+	}
+}
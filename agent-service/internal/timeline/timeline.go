@@ -0,0 +1,156 @@
+// Package timeline — хронология запроса /chat для отладки: выбор модели,
+// вызовы LLM и их повторы, смена провайдера, вызовы инструментов и их
+// длительность. Хронологии хранятся в памяти (последние N запросов, не
+// дольше TTL) и отдаются по X-Request-ID через GET /chat/{request_id}/events.
+package timeline
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// Типы событий.
+const (
+	EventStarted        = "started"         // Запрос принят
+	EventRouting        = "routing"         // Маршрутизатор выбрал модель
+	EventProviderSwitch = "provider_switch" // Смена провайдера или модели (бюджет, повтор без инструментов)
+	EventLLMCall        = "llm_call"        // Вызов модели (одна попытка)
+	EventLLMRetry       = "llm_retry"       // Транзиентная ошибка, будет повтор
+	EventCacheHit       = "cache_hit"       // Ответ взят из кэша
+	EventToolCall       = "tool_call"       // Вызов инструмента
+	EventToolOutput     = "tool_output"     // Фрагмент вывода команды (Target — stdout или stderr), по gRPC
+	EventFinished       = "finished"        // Ответ отправлен
+)
+
+// Event — одно событие хронологии.
+type Event struct {
+	Seq        int       `json:"seq"`
+	Type       string    `json:"type"`
+	Time       time.Time `json:"time"`
+	OffsetMs   int64     `json:"offset_ms"` // От начала запроса
+	DurationMs int64     `json:"duration_ms,omitempty"`
+	Provider   string    `json:"provider,omitempty"`
+	Model      string    `json:"model,omitempty"`
+	Tool       string    `json:"tool,omitempty"`
+	Target     string    `json:"target,omitempty"`
+	Attempt    int       `json:"attempt,omitempty"`
+	Status     string    `json:"status,omitempty"` // ok | error | confirmation_required и т.п.
+	Detail     string    `json:"detail,omitempty"`
+}
+
+// Record — хронология запроса в ответе API.
+type Record struct {
+	RequestID  string     `json:"request_id"`
+	Agent      string     `json:"agent,omitempty"`
+	StartedAt  time.Time  `json:"started_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+	DurationMs int64      `json:"duration_ms,omitempty"`
+	Status     string     `json:"status"` // running или итог запроса (completed, partial, needs_confirmation, failed)
+	Events     []Event    `json:"events"`
+}
+
+// Timeline — события одного запроса; безопасна для параллельных вызовов.
+type Timeline struct {
+	mu  sync.Mutex
+	rec Record
+	now func() time.Time
+}
+
+// maxEvents — предел событий одного запроса (цикл инструментов ограничен, но
+// хронология не должна расти без границ).
+const maxEvents = 500
+
+// Add — добавляет событие; Time, Seq и OffsetMs заполняются автоматически.
+// Безопасен для nil: запрос без хронологии ничего не записывает.
+func (t *Timeline) Add(e Event) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if len(t.rec.Events) >= maxEvents {
+		return
+	}
+	e.Time = t.now()
+	e.Seq = len(t.rec.Events) + 1
+	e.OffsetMs = e.Time.Sub(t.rec.StartedAt).Milliseconds()
+	t.rec.Events = append(t.rec.Events, e)
+}
+
+// Finish — завершает хронологию с итогом status (как поле status ответа
+// /chat); повторный вызов ничего не меняет.
+func (t *Timeline) Finish(status, detail string) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	if t.rec.FinishedAt != nil {
+		t.mu.Unlock()
+		return
+	}
+	now := t.now()
+	t.rec.FinishedAt = &now
+	t.rec.DurationMs = now.Sub(t.rec.StartedAt).Milliseconds()
+	t.rec.Status = status
+	t.mu.Unlock()
+	t.Add(Event{Type: EventFinished, Status: status, Detail: detail})
+}
+
+// snapshot — копия для ответа API.
+func (t *Timeline) snapshot() Record {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	rec := t.rec
+	rec.Events = append([]Event(nil), t.rec.Events...)
+	return rec
+}
+
+// Store — хронологии последних запросов: не больше max, не дольше ttl.
+type Store struct {
+	mu    sync.Mutex
+	max   int
+	ttl   time.Duration
+	order *list.List // *Timeline, новые в начале
+	items map[string]*list.Element
+	now   func() time.Time
+}
+
+// NewStore — хранилище на max запросов со сроком хранения ttl.
+func NewStore(max int, ttl time.Duration) *Store {
+	return &Store{max: max, ttl: ttl, order: list.New(), items: map[string]*list.Element{}, now: time.Now}
+}
+
+// Start — новая хронология запроса requestID (повтор ID заменяет прежнюю).
+func (s *Store) Start(requestID, agent string) *Timeline {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t := &Timeline{rec: Record{RequestID: requestID, Agent: agent, StartedAt: s.now(), Status: "running"}, now: s.now}
+	if el, ok := s.items[requestID]; ok {
+		s.order.Remove(el)
+	}
+	s.items[requestID] = s.order.PushFront(t)
+	for s.order.Len() > s.max {
+		old := s.order.Back()
+		s.order.Remove(old)
+		delete(s.items, old.Value.(*Timeline).rec.RequestID)
+	}
+	t.Add(Event{Type: EventStarted})
+	return t
+}
+
+// Get — копия хронологии запроса; false — нет или истекла.
+func (s *Store) Get(requestID string) (Record, bool) {
+	s.mu.Lock()
+	el, ok := s.items[requestID]
+	if ok && s.now().Sub(el.Value.(*Timeline).rec.StartedAt) > s.ttl {
+		s.order.Remove(el)
+		delete(s.items, requestID)
+		ok = false
+	}
+	s.mu.Unlock()
+	if !ok {
+		return Record{}, false
+	}
+	return el.Value.(*Timeline).snapshot(), true
+}
@@ -0,0 +1,54 @@
+package timeline
+
+import (
+	"testing"
+	"time"
+)
+
+// TestTimeline — порядок событий, смещения и завершение.
+func TestTimeline(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	s := NewStore(10, time.Hour)
+	s.now = func() time.Time { return now }
+	tl := s.Start("req-1", "admin")
+	now = now.Add(250 * time.Millisecond)
+	tl.Add(Event{Type: EventToolCall, Tool: "read", DurationMs: 40, Status: "ok"})
+	now = now.Add(time.Second)
+	tl.Finish("completed", "")
+	tl.Finish("failed", "")
+
+	rec, ok := s.Get("req-1")
+	if !ok || rec.Status != "completed" || rec.DurationMs != 1250 || len(rec.Events) != 3 {
+		t.Fatalf("хронология: %+v", rec)
+	}
+	if e := rec.Events[1]; e.Seq != 2 || e.OffsetMs != 250 || e.Tool != "read" {
+		t.Errorf("событие: %+v", e)
+	}
+	if rec.Events[0].Type != EventStarted || rec.Events[2].Type != EventFinished {
+		t.Errorf("типы: %s, %s", rec.Events[0].Type, rec.Events[2].Type)
+	}
+
+	var none *Timeline
+	none.Add(Event{Type: EventLLMCall})
+	none.Finish("completed", "")
+}
+
+// TestStore — вытеснение старых и истечение хронологий.
+func TestStore(t *testing.T) {
+	now := time.Now()
+	s := NewStore(2, time.Hour)
+	s.now = func() time.Time { return now }
+	s.Start("a", "")
+	s.Start("b", "")
+	s.Start("c", "")
+	if _, ok := s.Get("a"); ok {
+		t.Error("старейшая хронология должна быть вытеснена")
+	}
+	if _, ok := s.Get("b"); !ok {
+		t.Error("хронология b должна быть доступна")
+	}
+	now = now.Add(2 * time.Hour)
+	if _, ok := s.Get("c"); ok {
+		t.Error("хронология должна истечь")
+	}
+}
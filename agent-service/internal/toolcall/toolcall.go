@@ -0,0 +1,347 @@
+// Package toolcall — разбор вызовов инструментов из текста ответа модели.
+//
+// Не все модели возвращают структурированные tool_calls: часть пишет вызов
+// в тексте ответа. Parse распознаёт все встреченные форматы:
+//
+//   - JSON: {"name": "read", "arguments": {...}} (или "parameters"), массив
+//     таких объектов, обёртка ```json, префикс [TOOL_CALLS] (mistral) и
+//     объект OpenAI {"type": "function", "function": {...}} (llama, qwen, gemma);
+//   - XML в тегах <tool_call>: <function=имя><parameter=ключ>значение</parameter>
+//     (nemotron), имя{"ключ": ...} (glm) или JSON внутри тега (qwen, hermes);
+//   - inline: имя{"ключ": ...} (devstral).
+//
+// Блоки размышлений reasoning-моделей удаляются до разбора (пакет reasoning).
+// Вызовов в одном ответе может быть несколько, в том числе посреди текста.
+package toolcall
+
+import (
+	"encoding/json"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/neo-2022/openclaw-memory/agent-service/internal/reasoning"
+)
+
+// Формат, в котором вызов найден в тексте.
+const (
+	FormatJSON   = "json"
+	FormatXML    = "xml"
+	FormatInline = "inline"
+)
+
+// Call — вызов инструмента из текста ответа.
+type Call struct {
+	Name   string
+	Args   map[string]interface{}
+	Format string
+}
+
+// Parser — разбор с проверкой имён инструментов.
+//
+// Ответ целиком из одного вызова и вызовы в тегах <tool_call> распознаются
+// всегда. Вызов посреди обычного текста принимается, только если Known
+// подтверждает имя инструмента: иначе пример JSON в объяснении модели
+// выполнился бы как команда. Без Known такие вызовы не ищутся.
+type Parser struct {
+	Known func(name string) bool
+}
+
+// Parse — вызовы из content без проверки имён (см. Parser).
+func Parse(content string) []Call {
+	return Parser{}.Parse(content)
+}
+
+var (
+	nameRe   = regexp.MustCompile(`^[A-Za-z_][\w.-]*$`)
+	leadRe   = regexp.MustCompile(`^[A-Za-z_][\w.-]*\s*`)
+	fnRe     = regexp.MustCompile(`<function=([^>]+)>`)
+	paramRe  = regexp.MustCompile(`<parameter=([^>]+)>\s*([\s\S]*?)\s*</parameter>`)
+	simpleRe = regexp.MustCompile(`^([A-Za-z_][\w.-]*)\s*([\s\S]*)$`)
+	fenceRe  = regexp.MustCompile("^```[\\w-]*\\s*\\n?([\\s\\S]*?)\\n?```$")
+)
+
+const (
+	openTag    = "<tool_call>"
+	closeTag   = "</tool_call>"
+	mistralTag = "[TOOL_CALLS]"
+)
+
+// found — вызов и его позиция в тексте.
+type found struct {
+	pos  int
+	call Call
+}
+
+// Parse — вызовы из content в порядке появления. nil — вызовов нет.
+func (p Parser) Parse(content string) []Call {
+	text := reasoning.Strip(content)
+	if text == "" {
+		return nil
+	}
+	if calls := whole(text); calls != nil {
+		return calls
+	}
+
+	var all []found
+	// Блоки <tool_call> заменяются пробелами той же длины: позиции сохраняются,
+	// а JSON внутри тегов не находится повторно.
+	masked := []byte(text)
+	for start := 0; ; {
+		i := strings.Index(text[start:], openTag)
+		if i < 0 {
+			break
+		}
+		i += start
+		inner := text[i+len(openTag):]
+		end := len(text)
+		if j := strings.Index(inner, closeTag); j >= 0 {
+			inner = inner[:j]
+			end = i + len(openTag) + j + len(closeTag)
+		}
+		for _, c := range tagged(inner) {
+			all = append(all, found{i, c})
+		}
+		for k := i; k < end; k++ {
+			masked[k] = ' '
+		}
+		start = end
+	}
+	if p.Known != nil {
+		all = append(all, p.embedded(string(masked))...)
+	}
+	if len(all) == 0 {
+		return nil
+	}
+	sort.SliceStable(all, func(i, j int) bool { return all[i].pos < all[j].pos })
+	calls := make([]Call, len(all))
+	for i, f := range all {
+		calls[i] = f.call
+	}
+	return calls
+}
+
+// Arguments — аргументы вызова из JSON. Ollama передаёт объект, OpenAI и
+// OpenRouter — строку с JSON; число, массив или строка без JSON оборачиваются
+// в {"value": ...}. Невалидный JSON — пустые аргументы.
+func Arguments(raw json.RawMessage) map[string]interface{} {
+	if len(raw) == 0 {
+		return map[string]interface{}{}
+	}
+	var args map[string]interface{}
+	if err := json.Unmarshal(raw, &args); err == nil {
+		if args == nil {
+			return map[string]interface{}{}
+		}
+		return args
+	}
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		if err := json.Unmarshal([]byte(s), &args); err == nil && args != nil {
+			return args
+		}
+		return map[string]interface{}{"value": s}
+	}
+	var v interface{}
+	if err := json.Unmarshal(raw, &v); err == nil {
+		return map[string]interface{}{"value": v}
+	}
+	return map[string]interface{}{}
+}
+
+// whole — ответ целиком состоит из вызовов: один или несколько JSON-вызовов
+// и inline-вызовов подряд, массив вызовов. Имена не проверяются, аргументы
+// необязательны: модель ответила только вызовами.
+func whole(text string) []Call {
+	if m := fenceRe.FindStringSubmatch(text); m != nil {
+		text = m[1]
+	}
+	text = strings.TrimPrefix(strings.TrimSpace(text), mistralTag)
+	var calls []Call
+	for text = strings.TrimSpace(text); text != ""; text = strings.TrimSpace(text) {
+		name := ""
+		if text[0] != '{' && text[0] != '[' {
+			lead := leadRe.FindString(text)
+			if lead == "" || !strings.HasPrefix(text[len(lead):], "{") {
+				return nil
+			}
+			name, text = strings.TrimSpace(lead), text[len(lead):]
+		}
+		raw, n, ok := decode(text)
+		if !ok {
+			return nil
+		}
+		text = text[n:]
+		if name == "" {
+			c := fromJSON(raw, false, nil)
+			if c == nil {
+				return nil
+			}
+			calls = append(calls, c...)
+			continue
+		}
+		var args map[string]interface{}
+		if json.Unmarshal(raw, &args) != nil || args == nil {
+			return nil
+		}
+		calls = append(calls, Call{Name: name, Args: args, Format: FormatInline})
+	}
+	return calls
+}
+
+// tagged — вызовы внутри одного тега <tool_call>.
+func tagged(inner string) []Call {
+	inner = strings.TrimSpace(inner)
+	if locs := fnRe.FindAllStringSubmatchIndex(inner, -1); locs != nil {
+		var calls []Call
+		for i, loc := range locs {
+			name := strings.TrimSpace(inner[loc[2]:loc[3]])
+			if !nameRe.MatchString(name) {
+				continue
+			}
+			body := inner[loc[1]:]
+			if i+1 < len(locs) {
+				body = inner[loc[1]:locs[i+1][0]]
+			}
+			args := map[string]interface{}{}
+			for _, m := range paramRe.FindAllStringSubmatch(body, -1) {
+				args[strings.TrimSpace(m[1])] = strings.TrimSpace(m[2])
+			}
+			calls = append(calls, Call{Name: name, Args: args, Format: FormatXML})
+		}
+		return calls
+	}
+	if strings.HasPrefix(inner, "{") || strings.HasPrefix(inner, "[") {
+		if raw, _, ok := decode(inner); ok {
+			calls := fromJSON(raw, false, nil)
+			for i := range calls {
+				calls[i].Format = FormatXML
+			}
+			return calls
+		}
+		return nil
+	}
+	m := simpleRe.FindStringSubmatch(inner)
+	if m == nil {
+		return nil
+	}
+	args := map[string]interface{}{}
+	if rest := strings.TrimSpace(m[2]); rest != "" {
+		if !strings.HasPrefix(rest, "{") {
+			return nil
+		}
+		json.Unmarshal([]byte(rest), &args)
+		if args == nil {
+			args = map[string]interface{}{}
+		}
+	}
+	return []Call{{Name: m[1], Args: args, Format: FormatXML}}
+}
+
+// embedded — вызовы посреди текста: JSON-объекты и массивы с аргументами и
+// inline-вызовы, имя которых подтверждает Known. Любой другой валидный JSON
+// пропускается целиком, вложенные в него объекты не рассматриваются.
+func (p Parser) embedded(text string) []found {
+	var out []found
+	for i := 0; i < len(text); i++ {
+		if text[i] != '{' && text[i] != '[' {
+			continue
+		}
+		raw, n, ok := decode(text[i:])
+		if !ok {
+			continue
+		}
+		if name := identBefore(text, i); name != "" && text[i] == '{' && p.Known(name) {
+			var args map[string]interface{}
+			if json.Unmarshal(raw, &args) == nil && args != nil {
+				out = append(out, found{i - len(name), Call{Name: name, Args: args, Format: FormatInline}})
+				i += n - 1
+				continue
+			}
+		}
+		for _, c := range fromJSON(raw, true, p.Known) {
+			out = append(out, found{i, c})
+		}
+		i += n - 1
+	}
+	return out
+}
+
+// identBefore — имя инструмента вплотную перед позицией i, начинающееся
+// с начала строки, после пробела или обратной кавычки.
+func identBefore(text string, i int) string {
+	j := i
+	for j > 0 && isIdent(text[j-1]) {
+		j--
+	}
+	if j == i || (j > 0 && !strings.ContainsRune(" \t\n\r`", rune(text[j-1]))) {
+		return ""
+	}
+	name := text[j:i]
+	if !nameRe.MatchString(name) {
+		return ""
+	}
+	return name
+}
+
+func isIdent(c byte) bool {
+	return c == '_' || c == '.' || c == '-' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+// decode — первое JSON-значение в начале s и длина его записи.
+func decode(s string) (json.RawMessage, int, bool) {
+	dec := json.NewDecoder(strings.NewReader(s))
+	var raw json.RawMessage
+	if err := dec.Decode(&raw); err != nil {
+		return nil, 0, false
+	}
+	return raw, int(dec.InputOffset()), true
+}
+
+// fromJSON — вызовы из объекта или массива объектов. strict — объект обязан
+// содержать аргументы, а имя — пройти known; массив принимается, только если
+// вызовами являются все его элементы.
+func fromJSON(raw json.RawMessage, strict bool, known func(string) bool) []Call {
+	var list []json.RawMessage
+	if json.Unmarshal(raw, &list) != nil {
+		list = []json.RawMessage{raw}
+	}
+	if len(list) == 0 {
+		return nil
+	}
+	calls := make([]Call, 0, len(list))
+	for _, item := range list {
+		c, ok := fromObject(item, strict)
+		if !ok || (strict && !known(c.Name)) {
+			return nil
+		}
+		calls = append(calls, c)
+	}
+	return calls
+}
+
+// fromObject — вызов из объекта {"name", "arguments"|"parameters"} или
+// {"function": {...}}.
+func fromObject(raw json.RawMessage, strict bool) (Call, bool) {
+	var obj map[string]json.RawMessage
+	if json.Unmarshal(raw, &obj) != nil || obj == nil {
+		return Call{}, false
+	}
+	if fn, ok := obj["function"]; ok && len(fn) > 0 && fn[0] == '{' {
+		if json.Unmarshal(fn, &obj) != nil || obj == nil {
+			return Call{}, false
+		}
+	}
+	var name string
+	if json.Unmarshal(obj["name"], &name) != nil || !nameRe.MatchString(name) {
+		return Call{}, false
+	}
+	args, hasArgs := obj["arguments"]
+	if params, ok := obj["parameters"]; ok && (!hasArgs || len(Arguments(args)) == 0) {
+		args, hasArgs = params, true
+	}
+	if strict && !hasArgs {
+		return Call{}, false
+	}
+	return Call{Name: name, Args: Arguments(args), Format: FormatJSON}, true
+}
@@ -0,0 +1,164 @@
+package toolcall
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/neo-2022/openclaw-memory/agent-service/internal/llm"
+)
+
+// known — инструменты агента в тестах.
+func known(name string) bool {
+	switch name {
+	case "read", "write", "execute", "list_directory", "edit_file":
+		return true
+	}
+	return false
+}
+
+// golden — ответы моделей разных семейств и ожидаемые вызовы.
+var golden = []struct {
+	family  string
+	content string
+	want    []Call
+}{
+	{"llama3", `{"name": "execute", "parameters": {"command": "ls -la"}}`,
+		[]Call{{"execute", map[string]interface{}{"command": "ls -la"}, FormatJSON}}},
+	{"openai-text", `{"type": "function", "function": {"name": "read", "arguments": "{\"path\": \"/etc/hosts\"}"}}`,
+		[]Call{{"read", map[string]interface{}{"path": "/etc/hosts"}, FormatJSON}}},
+	{"gemma-fenced", "```json\n{\"name\": \"read\", \"arguments\": {\"path\": \"/srv/a.go\"}}\n```",
+		[]Call{{"read", map[string]interface{}{"path": "/srv/a.go"}, FormatJSON}}},
+	{"mistral", `[TOOL_CALLS][{"name": "read", "arguments": {"path": "a"}}, {"name": "read", "arguments": {"path": "b"}}]`,
+		[]Call{{"read", map[string]interface{}{"path": "a"}, FormatJSON}, {"read", map[string]interface{}{"path": "b"}, FormatJSON}}},
+	{"nemotron", "<tool_call>\n<function=write>\n<parameter=path>\n/tmp/x.txt\n</parameter>\n<parameter=content>\nhello\n</parameter>\n</function>\n</tool_call>",
+		[]Call{{"write", map[string]interface{}{"path": "/tmp/x.txt", "content": "hello"}, FormatXML}}},
+	{"glm", `<tool_call>list_directory{"path": "/srv"}</tool_call>`,
+		[]Call{{"list_directory", map[string]interface{}{"path": "/srv"}, FormatXML}}},
+	{"glm-no-args", `<tool_call>list_directory</tool_call>`,
+		[]Call{{"list_directory", map[string]interface{}{}, FormatXML}}},
+	{"qwen-hermes", "Проверю файлы.\n<tool_call>\n{\"name\": \"read\", \"arguments\": {\"path\": \"a\"}}\n</tool_call>\n<tool_call>\n{\"name\": \"read\", \"arguments\": {\"path\": \"b\"}}\n</tool_call>",
+		[]Call{{"read", map[string]interface{}{"path": "a"}, FormatXML}, {"read", map[string]interface{}{"path": "b"}, FormatXML}}},
+	{"devstral", `execute{"command": "uptime"}`,
+		[]Call{{"execute", map[string]interface{}{"command": "uptime"}, FormatInline}}},
+	{"devstral-multiline", "execute{\n  \"command\": \"df -h\"\n}",
+		[]Call{{"execute", map[string]interface{}{"command": "df -h"}, FormatInline}}},
+	{"devstral-sequence", "read{\"path\": \"a\"}\nexecute{\"command\": \"wc -l a\"}\nread{\"path\": \"b\"}",
+		[]Call{{"read", map[string]interface{}{"path": "a"}, FormatInline}, {"execute", map[string]interface{}{"command": "wc -l a"}, FormatInline}, {"read", map[string]interface{}{"path": "b"}, FormatInline}}},
+	{"llama3-sequence", "{\"name\": \"read\", \"parameters\": {\"path\": \"a\"}}\n{\"name\": \"write\", \"parameters\": {\"path\": \"b\"}}",
+		[]Call{{"read", map[string]interface{}{"path": "a"}, FormatJSON}, {"write", map[string]interface{}{"path": "b"}, FormatJSON}}},
+	{"nemotron-multi", "<tool_call><function=read><parameter=path>a</parameter></function><function=read><parameter=path>b</parameter></function></tool_call>",
+		[]Call{{"read", map[string]interface{}{"path": "a"}, FormatXML}, {"read", map[string]interface{}{"path": "b"}, FormatXML}}},
+	{"mixed-order", "Сначала `read{\"path\": \"a\"}`, потом <tool_call>execute{\"command\": \"make\"}</tool_call> и {\"name\": \"read\", \"arguments\": {\"path\": \"b\"}}",
+		[]Call{{"read", map[string]interface{}{"path": "a"}, FormatInline}, {"execute", map[string]interface{}{"command": "make"}, FormatXML}, {"read", map[string]interface{}{"path": "b"}, FormatJSON}}},
+	{"deepseek-r1", "<think>Нужно посмотреть {\"name\": \"write\", \"arguments\": {}}</think>\n{\"name\": \"read\", \"arguments\": {\"path\": \"/a\"}}",
+		[]Call{{"read", map[string]interface{}{"path": "/a"}, FormatJSON}}},
+	{"deepseek-r1-no-open", "размышления без открывающего тега</think>execute{\"command\": \"id\"}",
+		[]Call{{"execute", map[string]interface{}{"command": "id"}, FormatInline}}},
+	{"ministral-reasoning", "[THINK]план[/THINK]<tool_call><function=read><parameter=path>/b</parameter></function></tool_call>",
+		[]Call{{"read", map[string]interface{}{"path": "/b"}, FormatXML}}},
+	{"mid-text", "Сначала прочитаю файл: {\"name\": \"read\", \"arguments\": {\"path\": \"/a\"}}, затем выполню `execute{\"command\": \"make\"}`.",
+		[]Call{{"read", map[string]interface{}{"path": "/a"}, FormatJSON}, {"execute", map[string]interface{}{"command": "make"}, FormatInline}}},
+	{"mid-text-fenced", "Вот вызов:\n```json\n{\"name\": \"read\", \"arguments\": {\"path\": \"/c\"}}\n```\nи всё.",
+		[]Call{{"read", map[string]interface{}{"path": "/c"}, FormatJSON}}},
+	{"unclosed-tag", `Выполняю <tool_call><function=execute><parameter=command>ls</parameter>`,
+		[]Call{{"execute", map[string]interface{}{"command": "ls"}, FormatXML}}},
+	{"plain-text", "Файл содержит настройки nginx, перезапуск не нужен.", nil},
+	{"json-example", "Конфиг выглядит так: {\"name\": \"app\", \"port\": 8080}", nil},
+	{"unknown-mid-text", "Пример: {\"name\": \"rm_rf\", \"arguments\": {}} — так делать не надо.", nil},
+	{"nested-not-call", "Ответ: {\"tool\": {\"name\": \"read\", \"arguments\": {\"path\": \"/a\"}}}", nil},
+	{"code", "func main() { fmt.Println(map[string]int{\"a\": 1}) }", nil},
+}
+
+// TestGolden — разбор ответов моделей разных семейств.
+func TestGolden(t *testing.T) {
+	p := Parser{Known: known}
+	for _, g := range golden {
+		if got := p.Parse(g.content); !reflect.DeepEqual(got, g.want) {
+			t.Errorf("%s: %+v, ожидалось %+v", g.family, got, g.want)
+		}
+	}
+}
+
+// TestWithoutKnown — без списка инструментов вызовы посреди текста не ищутся,
+// а ответ целиком из вызовов распознаётся с любыми именами.
+func TestWithoutKnown(t *testing.T) {
+	if got := Parse(`Сначала: {"name": "read", "arguments": {"path": "/a"}}`); got != nil {
+		t.Errorf("вызов посреди текста без Known: %+v", got)
+	}
+	if got := Parse(`{"name": "custom_tool"}`); len(got) != 1 || got[0].Name != "custom_tool" || got[0].Args == nil {
+		t.Errorf("ответ из одного вызова: %+v", got)
+	}
+	if got := Parse("custom_a{}\ncustom_b{\"x\": 1}"); len(got) != 2 || got[0].Name != "custom_a" || got[1].Name != "custom_b" {
+		t.Errorf("ответ из нескольких вызовов: %+v", got)
+	}
+	if got := Parse("custom_a{} и текст"); got != nil {
+		t.Errorf("вызов с текстом после: %+v", got)
+	}
+}
+
+// TestArguments — аргументы в виде объекта, строки с JSON и других значений.
+func TestArguments(t *testing.T) {
+	cases := map[string]map[string]interface{}{
+		``:                 {},
+		`{"a": 1}`:         {"a": float64(1)},
+		`"{\"a\": \"b\"}"`: {"a": "b"},
+		`"просто текст"`:   {"value": "просто текст"},
+		`42`:               {"value": float64(42)},
+		`null`:             {},
+		`{сломанный json`:  {},
+	}
+	for raw, want := range cases {
+		if got := Arguments(json.RawMessage(raw)); !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: %v, ожидалось %v", raw, got, want)
+		}
+	}
+}
+
+// FuzzParse — разбор произвольного текста не паникует, имена вызовов
+// корректны, аргументы не nil.
+func FuzzParse(f *testing.F) {
+	for _, g := range golden {
+		f.Add(g.content)
+	}
+	f.Add("<tool_call><function=")
+	f.Add("{{{{[[[[")
+	f.Add("[TOOL_CALLS]")
+	f.Fuzz(func(t *testing.T, content string) {
+		for _, c := range (Parser{Known: known}).Parse(content) {
+			if !nameRe.MatchString(c.Name) || c.Args == nil {
+				t.Fatalf("некорректный вызов %+v из %q", c, content)
+			}
+		}
+		Parse(content)
+	})
+}
+
+// FuzzRoundTrip — вызов, записанный в любом из форматов, разбирается обратно
+// и целиком, и посреди текста.
+func FuzzRoundTrip(f *testing.F) {
+	f.Add("read", "path", "/etc/hosts")
+	f.Add("execute", "command", "ls -la | grep go")
+	f.Add("write", "content", "строка с \"кавычками\" и {скобками}")
+	f.Fuzz(func(t *testing.T, name, key, value string) {
+		if !known(name) || !nameRe.MatchString(key) || !utf8.ValidString(value) || strings.TrimSpace(value) != value || strings.ContainsAny(value, "<>") {
+			t.Skip()
+		}
+		args := map[string]interface{}{key: value}
+		want := []Call{{Name: name, Args: args}}
+		for format, resp := range map[string]llm.MockResponse{
+			FormatJSON:   llm.MockJSONToolCall(name, args),
+			FormatXML:    llm.MockXMLToolCall(name, args),
+			FormatInline: llm.MockInlineToolCall(name, args),
+		} {
+			want[0].Format = format
+			for _, content := range []string{resp.Content, "Выполняю.\n" + resp.Content + "\nГотово."} {
+				if got := (Parser{Known: known}).Parse(content); !reflect.DeepEqual(got, want) {
+					t.Fatalf("%s %q: %+v, ожидалось %+v", format, content, got, want)
+				}
+			}
+		}
+	})
+}
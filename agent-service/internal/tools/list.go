@@ -131,6 +131,28 @@ func GetCompoundSkillTools() []llm.Tool {
 				},
 			},
 		},
+		{
+			Type: "function",
+			Function: llm.FunctionDefinition{
+				Name:        "security_audit",
+				Description: "LEGO-блок: аудит безопасности хоста. Проверяет открытые порты, пользователей с sudo (и без пароля), слабые настройки SSH, ожидающие обновления безопасности и файлы с записью для всех. Возвращает замечания по убыванию важности (critical → info) с советом, как исправить. Ничего не меняет в системе.",
+				Parameters: map[string]any{
+					"type": "object",
+					"properties": map[string]any{
+						"checks": map[string]any{
+							"type":        "array",
+							"items":       map[string]any{"type": "string", "enum": []string{"ports", "sudo", "ssh", "updates", "writable"}},
+							"description": "Какие проверки выполнить (по умолчанию все)",
+						},
+						"paths": map[string]any{
+							"type":        "array",
+							"items":       map[string]any{"type": "string"},
+							"description": "Каталоги для поиска файлов с записью для всех (по умолчанию /etc, /usr/local, /usr/bin, /opt, /home и др.)",
+						},
+					},
+				},
+			},
+		},
 		// =====================================================================
 		// БЛОК 2: Интернет — поиск, проверка доступности
 		// =====================================================================
@@ -322,6 +344,7 @@ func GetCompoundSkillTools() []llm.Tool {
 				},
 			},
 		},
+		gitPullRequestTool,
 		// =====================================================================
 		// БЛОК 6: Установка ПО
 		// =====================================================================
@@ -352,8 +375,75 @@ func GetCompoundSkillTools() []llm.Tool {
 	}
 }
 
+// gitPullRequestTool — скил git_pull_request: нужен и слабым, и сильным
+// моделям, потому что PR создаётся через API хостинга, а не командой.
+var gitPullRequestTool = llm.Tool{
+	Type: "function",
+	Function: llm.FunctionDefinition{
+		Name:        "git_pull_request",
+		Description: "Оформить изменения в git-репозитории как pull request: создать ветку, закоммитить изменённые файлы с сообщением в формате Conventional Commits (генерируется по diff, если не задано), выполнить push и открыть PR (GitHub) или MR (GitLab). Возвращает ссылку на PR. Токен хостинга берётся из настроек провайдера github/gitlab.",
+		Parameters: map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"repo_path": map[string]any{
+					"type":        "string",
+					"description": "Путь к репозиторию",
+				},
+				"files": map[string]any{
+					"type":        "array",
+					"items":       map[string]any{"type": "string"},
+					"description": "Файлы для коммита (по умолчанию — все изменения)",
+				},
+				"branch": map[string]any{
+					"type":        "string",
+					"description": "Имя новой ветки (по умолчанию — из сообщения коммита)",
+				},
+				"base": map[string]any{
+					"type":        "string",
+					"description": "Целевая ветка PR (по умолчанию — основная ветка origin)",
+				},
+				"message": map[string]any{
+					"type":        "string",
+					"description": "Сообщение коммита (по умолчанию генерируется по diff)",
+				},
+				"title": map[string]any{
+					"type":        "string",
+					"description": "Заголовок PR (по умолчанию — заголовок коммита)",
+				},
+				"body": map[string]any{
+					"type":        "string",
+					"description": "Описание PR",
+				},
+				"draft": map[string]any{
+					"type":        "boolean",
+					"description": "Открыть как черновик",
+				},
+			},
+			"required": []string{"repo_path"},
+		},
+	},
+}
+
 func GetAdminTools() []llm.Tool {
 	return []llm.Tool{
+		gitPullRequestTool,
+		{
+			Type: "function",
+			Function: llm.FunctionDefinition{
+				Name:        "import_issue",
+				Description: "Импортировать задачу из трекера по ссылке (issue GitHub/GitLab или тикет Jira): загрузить описание и комментарии, составить резюме и план. Задача становится текущей — её описание попадёт в контекст следующих запросов, а коммиты git_pull_request сошлются на неё.",
+				Parameters: map[string]any{
+					"type": "object",
+					"properties": map[string]any{
+						"url": map[string]any{
+							"type":        "string",
+							"description": "Ссылка на задачу, например https://github.com/owner/repo/issues/42",
+						},
+					},
+					"required": []string{"url"},
+				},
+			},
+		},
 		{
 			Type: "function",
 			Function: llm.FunctionDefinition{
@@ -375,6 +465,87 @@ func GetAdminTools() []llm.Tool {
 				},
 			},
 		},
+		{
+			Type: "function",
+			Function: llm.FunctionDefinition{
+				Name:        "run_code",
+				Description: "Выполнить фрагмент кода (python, go, javascript) в изолированном одноразовом окружении без сети и вернуть stdout/stderr, код возврата и список созданных файлов. Для вычислений, проверки гипотез и обработки данных; не требует белого списка команд.",
+				Parameters: map[string]any{
+					"type": "object",
+					"properties": map[string]any{
+						"language": map[string]any{
+							"type":        "string",
+							"enum":        []string{"python", "go", "javascript"},
+							"description": "Язык фрагмента",
+						},
+						"code": map[string]any{
+							"type":        "string",
+							"description": "Исходный код целиком (для go — package main с func main)",
+						},
+						"stdin": map[string]any{
+							"type":        "string",
+							"description": "Данные стандартного ввода (опционально)",
+						},
+						"timeout_sec": map[string]any{
+							"type":        "integer",
+							"description": "Таймаут в секундах (опционально, по умолчанию 20)",
+						},
+					},
+					"required": []string{"language", "code"},
+				},
+			},
+		},
+		{
+			Type: "function",
+			Function: llm.FunctionDefinition{
+				Name:        "lint_code",
+				Description: "Проверить файлы или правку (unified diff) линтерами: gofmt, golangci-lint (Go), eslint (JS/TS), black (Python). Возвращает замечания с файлом, строкой, правилом и сообщением; для diff — только по изменённым строкам.",
+				Parameters: map[string]any{
+					"type": "object",
+					"properties": map[string]any{
+						"paths": map[string]any{
+							"type":        "array",
+							"items":       map[string]any{"type": "string"},
+							"description": "Пути к файлам",
+						},
+						"diff": map[string]any{
+							"type":        "string",
+							"description": "Unified diff правки (опционально, вместо или вместе с paths)",
+						},
+						"root": map[string]any{
+							"type":        "string",
+							"description": "Корень проекта для относительных путей в diff",
+						},
+						"linters": map[string]any{
+							"type":        "array",
+							"items":       map[string]any{"type": "string", "enum": []string{"gofmt", "golangci-lint", "eslint", "black"}},
+							"description": "Ограничить набором линтеров (опционально)",
+						},
+					},
+				},
+			},
+		},
+		{
+			Type: "function",
+			Function: llm.FunctionDefinition{
+				Name:        "format_code",
+				Description: "Отформатировать файл подходящим форматтером (gofmt, eslint --fix, black). Без write возвращает отформатированный текст, не меняя файл.",
+				Parameters: map[string]any{
+					"type": "object",
+					"properties": map[string]any{
+						"path": map[string]any{
+							"type":        "string",
+							"description": "Путь к файлу",
+						},
+						"write": map[string]any{
+							"type":        "boolean",
+							"description": "Записать результат в файл (по умолчанию false)",
+						},
+					},
+					"required": []string{"path"},
+				},
+			},
+		},
 		{
 			Type: "function",
 			Function: llm.FunctionDefinition{
@@ -410,97 +581,544 @@ func GetAdminTools() []llm.Tool {
 					"properties": map[string]any{
 						"path": map[string]any{
 							"type":        "string",
-							"description": "Путь к файлу для удаления",
+							"description": "Путь к файлу для удаления",
+						},
+					},
+					"required": []string{"path"},
+				},
+			},
+		},
+		{
+			Type: "function",
+			Function: llm.FunctionDefinition{
+				Name:        "configure_agent",
+				Description: "Настроить агента: изменить модель, провайдера, промпт или включить/выключить накопленные знания.",
+				Parameters: map[string]any{
+					"type": "object",
+					"properties": map[string]any{
+						"agent_name": map[string]any{
+							"type":        "string",
+							"description": "Имя агента для настройки (admin)",
+						},
+						"model": map[string]any{
+							"type":        "string",
+							"description": "Новая модель для агента (опционально)",
+						},
+						"provider": map[string]any{
+							"type":        "string",
+							"description": "Провайдер модели: ollama, yandexgpt, gigachat (опционально)",
+						},
+						"prompt": map[string]any{
+							"type":        "string",
+							"description": "Новый системный промпт для агента (опционально)",
+						},
+						"learnings_enabled": map[string]any{
+							"type":        "boolean",
+							"description": "Подставлять ли накопленные знания модели в промпт агента (опционально)",
+						},
+					},
+					"required": []string{"agent_name"},
+				},
+			},
+		},
+		{
+			Type: "function",
+			Function: llm.FunctionDefinition{
+				Name:        "get_agent_info",
+				Description: "Получить полную информацию об агенте: текущая модель, провайдер, промпт, поддержка инструментов.",
+				Parameters: map[string]any{
+					"type": "object",
+					"properties": map[string]any{
+						"agent_name": map[string]any{
+							"type":        "string",
+							"description": "Имя агента (admin)",
+						},
+					},
+					"required": []string{"agent_name"},
+				},
+			},
+		},
+		{
+			Type: "function",
+			Function: llm.FunctionDefinition{
+				Name:        "list_models_for_role",
+				Description: "Получить список доступных моделей с рекомендациями для указанной роли агента, от лучшей к худшей. Показывает, какие модели подходят, их оценку (score 0–100) с учётом замеров скорости, успешности вызовов инструментов, задержки ответов в роли и оценок пользователей, и рекомендуемую модель (recommended).",
+				Parameters: map[string]any{
+					"type": "object",
+					"properties": map[string]any{
+						"role": map[string]any{
+							"type":        "string",
+							"description": "Роль агента: admin",
+						},
+					},
+					"required": []string{"role"},
+				},
+			},
+		},
+		{
+			Type: "function",
+			Function: llm.FunctionDefinition{
+				Name:        "ollama_pull",
+				Description: "Скачать локальную модель Ollama из реестра (например qwen2.5:7b, llama3.1:8b). Большие модели загружаются в фоне: если ответ «загружается в фоне», вызовите инструмент ещё раз с тем же именем, чтобы узнать прогресс.",
+				Parameters: map[string]any{
+					"type": "object",
+					"properties": map[string]any{
+						"name": map[string]any{
+							"type":        "string",
+							"description": "Имя модели с тегом, например qwen2.5:7b",
+						},
+					},
+					"required": []string{"name"},
+				},
+			},
+		},
+		{
+			Type: "function",
+			Function: llm.FunctionDefinition{
+				Name:        "ollama_delete",
+				Description: "Удалить локальную модель Ollama и освободить диск. Модель, назначенную агенту, удалить нельзя — сначала смените модель через configure_agent. Перед удалением спросите подтверждение пользователя.",
+				Parameters: map[string]any{
+					"type": "object",
+					"properties": map[string]any{
+						"name": map[string]any{
+							"type":        "string",
+							"description": "Имя модели с тегом",
+						},
+					},
+					"required": []string{"name"},
+				},
+			},
+		},
+		{
+			Type: "function",
+			Function: llm.FunctionDefinition{
+				Name:        "ollama_ps",
+				Description: "Модели, загруженные сейчас в память Ollama (размер, доля GPU/CPU, когда будут выгружены), и идущие загрузки моделей.",
+				Parameters: map[string]any{
+					"type":       "object",
+					"properties": map[string]any{},
+				},
+			},
+		},
+		{
+			Type: "function",
+			Function: llm.FunctionDefinition{
+				Name:        "view_logs",
+				Description: "Просмотреть системные логи ошибок и событий из всех микросервисов. Можно фильтровать по уровню (error, warn, info), сервису (agent-service, tools-service, browser-service, memory-service, api-gateway), correlation_id запроса, тексту и интервалу времени. Например, все ошибки с упоминанием Ollama за последний час: level=error, query=ollama, since=1h.",
+				Parameters: map[string]any{
+					"type": "object",
+					"properties": map[string]any{
+						"level": map[string]any{
+							"type":        "string",
+							"description": "Фильтр по уровню: error, warn, info, debug (опционально)",
+						},
+						"service": map[string]any{
+							"type":        "string",
+							"description": "Фильтр по сервису: agent-service, tools-service, browser-service, memory-service, api-gateway (опционально)",
+						},
+						"correlation_id": map[string]any{
+							"type":        "string",
+							"description": "Показать все записи одного запроса по его X-Request-ID (опционально)",
+						},
+						"query": map[string]any{
+							"type":        "string",
+							"description": "Полнотекстовый поиск по тексту и деталям записи, например \"ollama\" (опционально)",
+						},
+						"since": map[string]any{
+							"type":        "string",
+							"description": "Начало интервала: длительность назад (1h, 30m, 7d), дата 2025-01-31 или RFC3339 (опционально)",
+						},
+						"until": map[string]any{
+							"type":        "string",
+							"description": "Конец интервала в том же формате (опционально)",
+						},
+						"group_by": map[string]any{
+							"type":        "string",
+							"enum":        []string{"service", "level"},
+							"description": "Вместо записей вернуть их количество по сервисам или уровням (опционально)",
+						},
+						"limit": map[string]any{
+							"type":        "number",
+							"description": "Количество записей (по умолчанию 20)",
+						},
+					},
+				},
+			},
+		},
+		// --- База знаний (agent-service, ChromaDB) ---
+		{
+			Type: "function",
+			Function: llm.FunctionDefinition{
+				Name:        "search_knowledge",
+				Description: "Семантический поиск по базе знаний RAG с фильтрами по метаданным. Используй, когда нужно искать только в части базы, например «только документация API»: source_prefix=folder:/docs/api, extensions=[\"md\"]. Возвращает фрагменты с названием, источником и оценкой.",
+				Parameters: map[string]any{
+					"type": "object",
+					"properties": map[string]any{
+						"query": map[string]any{
+							"type":        "string",
+							"description": "Что искать",
+						},
+						"source": map[string]any{
+							"type":        "string",
+							"description": "Точный источник документов, например folder:/home/user/docs (опционально)",
+						},
+						"source_prefix": map[string]any{
+							"type":        "string",
+							"description": "Префикс источника — папка и её подпапки, загруженные отдельно (опционально)",
+						},
+						"extensions": map[string]any{
+							"type":        "array",
+							"items":       map[string]any{"type": "string"},
+							"description": "Расширения файлов: md, go, yaml (опционально)",
+						},
+						"since": map[string]any{
+							"type":        "string",
+							"description": "Загружены не раньше: 7d, 2025-01-31 или RFC3339 (опционально)",
+						},
+						"until": map[string]any{
+							"type":        "string",
+							"description": "Загружены не позже, в том же формате (опционально)",
+						},
+						"top_k": map[string]any{
+							"type":        "number",
+							"description": "Сколько фрагментов вернуть (по умолчанию 5)",
+						},
+					},
+					"required": []string{"query"},
+				},
+			},
+		},
+		// --- Метрики (tools-service /prometheus/query) ---
+		{
+			Type: "function",
+			Function: llm.FunctionDefinition{
+				Name:        "prometheus_query",
+				Description: "Запрос к Prometheus: мгновенное значение или тренд за период (range). Для каждого ряда возвращает first/last/min/max/avg, изменение в %, тренд (рост/падение/стабильно) и усреднённые точки. Вместо PromQL можно передать пресет: cpu, memory, disk (% использования), load, network (байт/с). Пример: «тренд CPU за сутки» — query=cpu, range=24h.",
+				Parameters: map[string]any{
+					"type": "object",
+					"properties": map[string]any{
+						"query": map[string]any{
+							"type":        "string",
+							"description": "PromQL или пресет: cpu, memory, disk, load, network",
+						},
+						"range": map[string]any{
+							"type":        "string",
+							"description": "Период для тренда: 1h, 24h, 7d (без него — текущее значение)",
+						},
+						"instance": map[string]any{
+							"type":        "string",
+							"description": "Фильтр хоста для пресетов, подстрока instance (опционально)",
+						},
+						"step": map[string]any{
+							"type":        "string",
+							"description": "Шаг выборки, например 5m (опционально)",
+						},
+					},
+					"required": []string{"query"},
+				},
+			},
+		},
+		// --- Сеть (tools-service /net/*) ---
+		{
+			Type: "function",
+			Function: llm.FunctionDefinition{
+				Name:        "net_ping",
+				Description: "Проверить доступность хоста: потери пакетов и время ответа min/avg/max. С port — замер TCP-подключения к порту (если ICMP закрыт).",
+				Parameters: map[string]any{
+					"type": "object",
+					"properties": map[string]any{
+						"host": map[string]any{
+							"type":        "string",
+							"description": "Имя хоста или IP-адрес",
+						},
+						"count": map[string]any{
+							"type":        "integer",
+							"description": "Число запросов, 1–20 (по умолчанию 4)",
+						},
+						"port": map[string]any{
+							"type":        "integer",
+							"description": "TCP-порт для замера подключения (опционально)",
+						},
+					},
+					"required": []string{"host"},
+				},
+			},
+		},
+		{
+			Type: "function",
+			Function: llm.FunctionDefinition{
+				Name:        "net_traceroute",
+				Description: "Маршрут до хоста: узлы (TTL, адрес, время ответа); пустой адрес — узел не ответил. Помогает найти, где теряются пакеты.",
+				Parameters: map[string]any{
+					"type": "object",
+					"properties": map[string]any{
+						"host": map[string]any{
+							"type":        "string",
+							"description": "Имя хоста или IP-адрес",
+						},
+						"max_hops": map[string]any{
+							"type":        "integer",
+							"description": "Максимум узлов, до 40 (по умолчанию 20)",
+						},
+					},
+					"required": []string{"host"},
+				},
+			},
+		},
+		{
+			Type: "function",
+			Function: llm.FunctionDefinition{
+				Name:        "net_dns",
+				Description: "DNS-записи имени: A, AAAA, MX, TXT, CNAME, NS. Можно спросить конкретный DNS-сервер, чтобы сравнить ответы.",
+				Parameters: map[string]any{
+					"type": "object",
+					"properties": map[string]any{
+						"name": map[string]any{
+							"type":        "string",
+							"description": "Доменное имя",
+						},
+						"server": map[string]any{
+							"type":        "string",
+							"description": "DNS-сервер IP[:порт], например 8.8.8.8 (по умолчанию системный)",
+						},
+						"types": map[string]any{
+							"type":        "array",
+							"items":       map[string]any{"type": "string", "enum": []string{"A", "AAAA", "MX", "TXT", "CNAME", "NS"}},
+							"description": "Типы записей (по умолчанию A, AAAA, MX, TXT)",
+						},
+					},
+					"required": []string{"name"},
+				},
+			},
+		},
+		{
+			Type: "function",
+			Function: llm.FunctionDefinition{
+				Name:        "net_public_ip",
+				Description: "Внешний (публичный) IP-адрес хоста, под которым он виден в интернете.",
+				Parameters: map[string]any{
+					"type":       "object",
+					"properties": map[string]any{},
+				},
+			},
+		},
+		{
+			Type: "function",
+			Function: llm.FunctionDefinition{
+				Name:        "net_interfaces",
+				Description: "Сетевые интерфейсы: адреса, MTU, состояние, счётчики трафика и ошибок; с sample_sec — текущая скорость приёма и передачи (байт/с).",
+				Parameters: map[string]any{
+					"type": "object",
+					"properties": map[string]any{
+						"sample_sec": map[string]any{
+							"type":        "integer",
+							"description": "Замерить скорость за N секунд, до 10 (по умолчанию только счётчики)",
+						},
+					},
+				},
+			},
+		},
+		// --- Почта (tools-service /mail/*) ---
+		{
+			Type: "function",
+			Function: llm.FunctionDefinition{
+				Name:        "mail_list",
+				Description: "Список последних писем настроенного почтового ящика (IMAP): uid, отправитель, тема, дата, прочитано ли. Поиск по тексту, отправителю, дате. Письма не помечаются прочитанными.",
+				Parameters: map[string]any{
+					"type": "object",
+					"properties": map[string]any{
+						"query": map[string]any{
+							"type":        "string",
+							"description": "Подстрока в теме, адресах или тексте (опционально)",
+						},
+						"from": map[string]any{
+							"type":        "string",
+							"description": "Отправитель (опционально)",
+						},
+						"since": map[string]any{
+							"type":        "string",
+							"description": "Письма начиная с даты YYYY-MM-DD (опционально)",
+						},
+						"unseen": map[string]any{
+							"type":        "boolean",
+							"description": "Только непрочитанные (опционально)",
+						},
+						"mailbox": map[string]any{
+							"type":        "string",
+							"description": "Папка, по умолчанию INBOX (опционально)",
+						},
+						"limit": map[string]any{
+							"type":        "number",
+							"description": "Количество писем (по умолчанию 20)",
+						},
+					},
+				},
+			},
+		},
+		{
+			Type: "function",
+			Function: llm.FunctionDefinition{
+				Name:        "mail_read",
+				Description: "Прочитать письмо по uid из mail_list: заголовки, текст (HTML очищается от разметки) и имена вложений.",
+				Parameters: map[string]any{
+					"type": "object",
+					"properties": map[string]any{
+						"uid": map[string]any{
+							"type":        "number",
+							"description": "UID письма из mail_list",
+						},
+						"mailbox": map[string]any{
+							"type":        "string",
+							"description": "Папка, по умолчанию INBOX (опционально)",
 						},
 					},
-					"required": []string{"path"},
+					"required": []string{"uid"},
 				},
 			},
 		},
 		{
 			Type: "function",
 			Function: llm.FunctionDefinition{
-				Name:        "configure_agent",
-				Description: "Настроить агента: изменить модель, провайдера или промпт.",
+				Name:        "mail_send",
+				Description: "Отправить письмо по SMTP (например, отчёт о выполненной задаче). Адресаты ограничены списком MAIL_ALLOWED_TO. Для ответа укажи reply_to_uid — тема и In-Reply-To возьмутся из исходного письма.",
 				Parameters: map[string]any{
 					"type": "object",
 					"properties": map[string]any{
-						"agent_name": map[string]any{
-							"type":        "string",
-							"description": "Имя агента для настройки (admin)",
+						"to": map[string]any{
+							"type":        "array",
+							"items":       map[string]any{"type": "string"},
+							"description": "Адреса получателей",
 						},
-						"model": map[string]any{
-							"type":        "string",
-							"description": "Новая модель для агента (опционально)",
+						"cc": map[string]any{
+							"type":        "array",
+							"items":       map[string]any{"type": "string"},
+							"description": "Копия (опционально)",
 						},
-						"provider": map[string]any{
+						"subject": map[string]any{
 							"type":        "string",
-							"description": "Провайдер модели: ollama, yandexgpt, gigachat (опционально)",
+							"description": "Тема",
 						},
-						"prompt": map[string]any{
+						"body": map[string]any{
 							"type":        "string",
-							"description": "Новый системный промпт для агента (опционально)",
+							"description": "Текст письма",
+						},
+						"reply_to_uid": map[string]any{
+							"type":        "number",
+							"description": "UID письма, на которое это ответ (опционально)",
 						},
 					},
-					"required": []string{"agent_name"},
+					"required": []string{"to", "body"},
 				},
 			},
 		},
+		// --- Календарь (tools-service /calendar/*) ---
 		{
 			Type: "function",
 			Function: llm.FunctionDefinition{
-				Name:        "get_agent_info",
-				Description: "Получить полную информацию об агенте: текущая модель, провайдер, промпт, поддержка инструментов.",
+				Name:        "calendar_list",
+				Description: "События календаря (CalDAV) за интервал: uid, название, начало, конец, напоминание. Без параметров — ближайшие 7 дней. В ответе есть поле now — текущее время календаря.",
 				Parameters: map[string]any{
 					"type": "object",
 					"properties": map[string]any{
-						"agent_name": map[string]any{
+						"from": map[string]any{
 							"type":        "string",
-							"description": "Имя агента (admin)",
+							"description": "Начало интервала: 2026-01-31T10:00, 2026-01-31 или «завтра» (по умолчанию — сейчас)",
+						},
+						"to": map[string]any{
+							"type":        "string",
+							"description": "Конец интервала (опционально)",
+						},
+						"days": map[string]any{
+							"type":        "number",
+							"description": "Длина интервала в днях, если to не указан (по умолчанию 7)",
 						},
 					},
-					"required": []string{"agent_name"},
 				},
 			},
 		},
 		{
 			Type: "function",
 			Function: llm.FunctionDefinition{
-				Name:        "list_models_for_role",
-				Description: "Получить список доступных моделей с рекомендациями для указанной роли агента. Показывает какие модели подходят, а какие нет.",
+				Name:        "calendar_create",
+				Description: "Создать событие в календаре, например окно обслуживания. Время: 2026-01-31T22:00, «завтра 22:00» или дата 2026-01-31 для события на весь день. reminder_minutes — напоминание за N минут до начала.",
 				Parameters: map[string]any{
 					"type": "object",
 					"properties": map[string]any{
-						"role": map[string]any{
+						"summary": map[string]any{
 							"type":        "string",
-							"description": "Роль агента: admin",
+							"description": "Название события",
+						},
+						"start": map[string]any{
+							"type":        "string",
+							"description": "Начало: 2026-01-31T22:00 или «завтра 22:00»",
+						},
+						"end": map[string]any{
+							"type":        "string",
+							"description": "Конец (опционально; по умолчанию через час)",
+						},
+						"duration_minutes": map[string]any{
+							"type":        "number",
+							"description": "Длительность в минутах вместо end (опционально)",
+						},
+						"reminder_minutes": map[string]any{
+							"type":        "number",
+							"description": "Напомнить за N минут (опционально)",
+						},
+						"description": map[string]any{
+							"type":        "string",
+							"description": "Описание (опционально)",
+						},
+						"location": map[string]any{
+							"type":        "string",
+							"description": "Место (опционально)",
 						},
 					},
-					"required": []string{"role"},
+					"required": []string{"summary", "start"},
 				},
 			},
 		},
 		{
 			Type: "function",
 			Function: llm.FunctionDefinition{
-				Name:        "view_logs",
-				Description: "Просмотреть системные логи ошибок и событий из всех микросервисов. Можно фильтровать по уровню (error, warn, info) и сервису (agent-service, tools-service, memory-service, api-gateway).",
+				Name:        "calendar_update",
+				Description: "Изменить событие по uid из calendar_list: перенести, переименовать, поменять напоминание. Меняются только переданные поля; при переносе start без end длительность сохраняется.",
 				Parameters: map[string]any{
 					"type": "object",
 					"properties": map[string]any{
-						"level": map[string]any{
+						"uid": map[string]any{
 							"type":        "string",
-							"description": "Фильтр по уровню: error, warn, info, debug (опционально)",
+							"description": "UID события",
 						},
-						"service": map[string]any{
+						"summary": map[string]any{
 							"type":        "string",
-							"description": "Фильтр по сервису: agent-service, tools-service, memory-service, api-gateway (опционально)",
+							"description": "Новое название (опционально)",
 						},
-						"limit": map[string]any{
+						"start": map[string]any{
+							"type":        "string",
+							"description": "Новое начало (опционально)",
+						},
+						"end": map[string]any{
+							"type":        "string",
+							"description": "Новый конец (опционально)",
+						},
+						"duration_minutes": map[string]any{
 							"type":        "number",
-							"description": "Количество записей (по умолчанию 20)",
+							"description": "Новая длительность вместо end (опционально)",
+						},
+						"reminder_minutes": map[string]any{
+							"type":        "number",
+							"description": "Напоминание за N минут; 0 — убрать (опционально)",
+						},
+						"description": map[string]any{
+							"type":        "string",
+							"description": "Новое описание (опционально)",
+						},
+						"location": map[string]any{
+							"type":        "string",
+							"description": "Новое место (опционально)",
 						},
 					},
+					"required": []string{"uid"},
 				},
 			},
 		},
@@ -1037,6 +1655,34 @@ func GetBaseTools() []llm.Tool {
 				},
 			},
 		},
+		{
+			Type: "function",
+			Function: llm.FunctionDefinition{
+				Name:        "disk_usage",
+				Description: "Чем занят диск: самые большие каталоги (до depth уровней вложенности) и файлы под path с размером и долей в %. Используй вместо du, когда спрашивают «почему кончилось место». Обход ограничен по времени; truncated=true — результат неполный.",
+				Parameters: map[string]any{
+					"type": "object",
+					"properties": map[string]any{
+						"path": map[string]any{
+							"type":        "string",
+							"description": "Каталог для анализа, например '/', '/var' или '~' (по умолчанию домашний)",
+						},
+						"depth": map[string]any{
+							"type":        "integer",
+							"description": "Глубина сводки по каталогам, 1–6 (по умолчанию 2)",
+						},
+						"top": map[string]any{
+							"type":        "integer",
+							"description": "Сколько каталогов и файлов показать (по умолчанию 15)",
+						},
+						"min_size": map[string]any{
+							"type":        "integer",
+							"description": "Не показывать файлы меньше этого размера, байт (опционально)",
+						},
+					},
+				},
+			},
+		},
 		{
 			Type: "function",
 			Function: llm.FunctionDefinition{
@@ -1090,3 +1736,290 @@ func GetBaseTools() []llm.Tool {
 		},
 	}
 }
+
+// GetSmartHomeTools — инструменты умного дома (Home Assistant / MQTT).
+// Добавляются к набору агента, только если настроен провайдер homeassistant
+// или mqtt; каждый инструмент — один вызов, поэтому подходит и слабым моделям.
+func GetSmartHomeTools() []llm.Tool {
+	return []llm.Tool{
+		{
+			Type: "function",
+			Function: llm.FunctionDefinition{
+				Name:        "home_sensor",
+				Description: "Показания датчика умного дома: сущность Home Assistant (entity_id, например sensor.kitchen_temperature) или значение MQTT-топика (topic). Без entity_id и topic — список датчиков Home Assistant, можно отфильтровать query.",
+				Parameters: map[string]any{
+					"type": "object",
+					"properties": map[string]any{
+						"entity_id": map[string]any{
+							"type":        "string",
+							"description": "ID сущности Home Assistant (опционально)",
+						},
+						"topic": map[string]any{
+							"type":        "string",
+							"description": "MQTT-топик с показанием, например home/boiler/temp (опционально)",
+						},
+						"query": map[string]any{
+							"type":        "string",
+							"description": "Поиск датчиков по имени, например «кухня» (опционально)",
+						},
+					},
+				},
+			},
+		},
+		{
+			Type: "function",
+			Function: llm.FunctionDefinition{
+				Name:        "home_switch",
+				Description: "Включить, выключить или переключить устройство: выключатель, свет, вентилятор (Home Assistant, домены switch/light/fan/input_boolean) или публикация команды в MQTT-топик. Возвращает новое состояние.",
+				Parameters: map[string]any{
+					"type": "object",
+					"properties": map[string]any{
+						"entity_id": map[string]any{
+							"type":        "string",
+							"description": "ID сущности Home Assistant, например switch.server_fan",
+						},
+						"action": map[string]any{
+							"type":        "string",
+							"enum":        []string{"on", "off", "toggle"},
+							"description": "Действие (по умолчанию toggle)",
+						},
+						"topic": map[string]any{
+							"type":        "string",
+							"description": "Командный MQTT-топик вместо entity_id (опционально)",
+						},
+						"payload": map[string]any{
+							"type":        "string",
+							"description": "Сообщение для MQTT (по умолчанию ON/OFF)",
+						},
+					},
+				},
+			},
+		},
+		{
+			Type: "function",
+			Function: llm.FunctionDefinition{
+				Name:        "home_scene",
+				Description: "Запустить сцену или скрипт Home Assistant (scene.*, script.*) либо отправить payload в MQTT-топик. Без entity_id возвращает список сцен.",
+				Parameters: map[string]any{
+					"type": "object",
+					"properties": map[string]any{
+						"entity_id": map[string]any{
+							"type":        "string",
+							"description": "Сцена или скрипт, например scene.movie_night",
+						},
+						"query": map[string]any{
+							"type":        "string",
+							"description": "Поиск сцен по имени (опционально)",
+						},
+						"topic": map[string]any{
+							"type":        "string",
+							"description": "MQTT-топик сцены (опционально)",
+						},
+						"payload": map[string]any{
+							"type":        "string",
+							"description": "Сообщение для MQTT (опционально)",
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+// GetClarifyTools — уточняющий вопрос пользователю (ASK_USER_ENABLED). Вызов
+// не уходит в tools-service: цикл /chat останавливается до ответа, см. пакет clarify.
+func GetClarifyTools() []llm.Tool {
+	return []llm.Tool{
+		{
+			Type: "function",
+			Function: llm.FunctionDefinition{
+				Name:        "ask_user",
+				Description: "Задать пользователю уточняющий вопрос и дождаться ответа. Используй, когда не хватает параметров, особенно для разрушительных действий (удаление, перезапись, push, миграции): не угадывай путь, ветку или имя ресурса — спроси. Ответ придёт результатом этого вызова.",
+				Parameters: map[string]any{
+					"type": "object",
+					"properties": map[string]any{
+						"question": map[string]any{
+							"type":        "string",
+							"description": "Вопрос пользователю — коротко и по существу",
+						},
+						"options": map[string]any{
+							"type":        "array",
+							"items":       map[string]any{"type": "string"},
+							"description": "Варианты ответа, если они известны (например, найденные ветки)",
+						},
+						"reason": map[string]any{
+							"type":        "string",
+							"description": "Зачем нужен ответ: что будет сделано после него",
+						},
+					},
+					"required": []string{"question"},
+				},
+			},
+		},
+	}
+}
+
+// GetArtifactTools — дочитывание полного результата инструмента, который был
+// усечён перед отправкой модели (TOOL_RESULT_MAX_CHARS).
+func GetArtifactTools() []llm.Tool {
+	return []llm.Tool{
+		{
+			Type: "function",
+			Function: llm.FunctionDefinition{
+				Name:        "get_artifact",
+				Description: "Прочитать полный результат инструмента, который был сокращён (в результате есть artifact_id). Можно запросить фрагмент по смещению offset/length или только строки, содержащие query.",
+				Parameters: map[string]any{
+					"type": "object",
+					"properties": map[string]any{
+						"id": map[string]any{
+							"type":        "string",
+							"description": "Идентификатор артефакта (artifact_id из сокращённого результата)",
+						},
+						"offset": map[string]any{
+							"type":        "integer",
+							"description": "С какого символа читать (по умолчанию 0)",
+						},
+						"length": map[string]any{
+							"type":        "integer",
+							"description": "Сколько символов прочитать (по умолчанию и не больше лимита результата)",
+						},
+						"query": map[string]any{
+							"type":        "string",
+							"description": "Вернуть только строки, содержащие этот текст (без учёта регистра)",
+						},
+					},
+					"required": []string{"id"},
+				},
+			},
+		},
+	}
+}
+
+// GetKubeTools — инструменты инспекции Kubernetes. Добавляются к набору
+// агента, только если найден kubeconfig (или агент запущен в кластере);
+// все запросы только на чтение, Secret недоступны.
+func GetKubeTools() []llm.Tool {
+	namespace := map[string]any{
+		"type":        "string",
+		"description": "Пространство имён (по умолчанию из kubeconfig; * — все)",
+	}
+	selector := map[string]any{
+		"type":        "string",
+		"description": "Фильтр по меткам, как kubectl -l: app=web,tier!=db (опционально)",
+	}
+	return []llm.Tool{
+		{
+			Type: "function",
+			Function: llm.FunctionDefinition{
+				Name:        "k8s_pods",
+				Description: "Список подов Kubernetes как kubectl get pods -o wide: статус (включая CrashLoopBackOff, OOMKilled), готовность контейнеров, перезапуски, узел, возраст.",
+				Parameters: map[string]any{
+					"type": "object",
+					"properties": map[string]any{
+						"namespace": namespace,
+						"selector":  selector,
+					},
+				},
+			},
+		},
+		{
+			Type: "function",
+			Function: llm.FunctionDefinition{
+				Name:        "k8s_deployments",
+				Description: "Список деплойментов Kubernetes: готовые/желаемые реплики, обновлённые, доступные, образы.",
+				Parameters: map[string]any{
+					"type": "object",
+					"properties": map[string]any{
+						"namespace": namespace,
+						"selector":  selector,
+					},
+				},
+			},
+		},
+		{
+			Type: "function",
+			Function: llm.FunctionDefinition{
+				Name:        "k8s_events",
+				Description: "События кластера Kubernetes (новые в конце): причины падений, ошибки планировщика, проблемы с образами. Можно отфильтровать по объекту и только предупреждения.",
+				Parameters: map[string]any{
+					"type": "object",
+					"properties": map[string]any{
+						"namespace": namespace,
+						"kind": map[string]any{
+							"type":        "string",
+							"description": "Тип объекта: Pod, Deployment, Node… (опционально)",
+						},
+						"name": map[string]any{
+							"type":        "string",
+							"description": "Имя объекта (опционально)",
+						},
+						"warnings_only": map[string]any{
+							"type":        "boolean",
+							"description": "Только события Warning",
+						},
+						"limit": map[string]any{
+							"type":        "integer",
+							"description": "Сколько последних событий вернуть (по умолчанию 50)",
+						},
+					},
+				},
+			},
+		},
+		{
+			Type: "function",
+			Function: llm.FunctionDefinition{
+				Name:        "k8s_describe",
+				Description: "Подробности ресурса Kubernetes (как kubectl describe) вместе со связанными событиями. Типы: pod, deploy, sts, ds, rs, svc, ing, cm, pvc, job, cronjob, node, ns. Secret недоступны.",
+				Parameters: map[string]any{
+					"type": "object",
+					"properties": map[string]any{
+						"kind": map[string]any{
+							"type":        "string",
+							"description": "Тип ресурса, например pod или deploy",
+						},
+						"name": map[string]any{
+							"type":        "string",
+							"description": "Имя ресурса",
+						},
+						"namespace": namespace,
+					},
+					"required": []string{"kind", "name"},
+				},
+			},
+		},
+		{
+			Type: "function",
+			Function: llm.FunctionDefinition{
+				Name:        "k8s_logs",
+				Description: "Последние строки логов пода Kubernetes. Для упавшего контейнера укажите previous=true.",
+				Parameters: map[string]any{
+					"type": "object",
+					"properties": map[string]any{
+						"name": map[string]any{
+							"type":        "string",
+							"description": "Имя пода",
+						},
+						"namespace": namespace,
+						"container": map[string]any{
+							"type":        "string",
+							"description": "Контейнер (обязателен, если в поде их несколько)",
+						},
+						"tail_lines": map[string]any{
+							"type":        "integer",
+							"description": "Сколько последних строк (по умолчанию 100, максимум 1000)",
+						},
+						"since": map[string]any{
+							"type":        "string",
+							"description": "Только за период, например 15m или 2h (опционально)",
+						},
+						"previous": map[string]any{
+							"type":        "boolean",
+							"description": "Логи предыдущего экземпляра контейнера",
+						},
+					},
+					"required": []string{"name"},
+				},
+			},
+		},
+	}
+}
@@ -0,0 +1,84 @@
+// Package toolstest — tools-service для тестов: HTTP-сервер, который отвечает
+// на вызовы инструментов заданными обработчиками и запоминает вызовы.
+// Позволяет проверять цикл инструментов chatHandler без сети и контейнеров.
+//
+//	srv := toolstest.NewServer()
+//	defer srv.Close()
+//	srv.Handle("/read", toolstest.Reply(map[string]interface{}{"content": "hello"}))
+//	cfg.ToolsServiceURL = srv.URL
+package toolstest
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+)
+
+// Handler — ответ инструмента на аргументы вызова.
+type Handler func(args map[string]interface{}) (status int, body interface{})
+
+// Reply — обработчик, всегда отвечающий body со статусом 200.
+func Reply(body interface{}) Handler {
+	return func(map[string]interface{}) (int, interface{}) { return http.StatusOK, body }
+}
+
+// Call — полученный вызов.
+type Call struct {
+	Path      string
+	Args      map[string]interface{}
+	Workspace string // Заголовок X-Workspace-Root
+}
+
+// Server — тестовый tools-service. Неизвестный путь — 404 в формате apierror.
+type Server struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	handlers map[string]Handler
+	calls    []Call
+}
+
+// NewServer — запущенный сервер без обработчиков.
+func NewServer() *Server {
+	s := &Server{handlers: map[string]Handler{}}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serve))
+	return s
+}
+
+// Handle — обработчик для пути инструмента ("/read", "/execute", "/run-code").
+func (s *Server) Handle(path string, h Handler) {
+	s.mu.Lock()
+	s.handlers[path] = h
+	s.mu.Unlock()
+}
+
+// Calls — полученные вызовы по порядку.
+func (s *Server) Calls() []Call {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Call(nil), s.calls...)
+}
+
+func (s *Server) serve(w http.ResponseWriter, r *http.Request) {
+	var args map[string]interface{}
+	if data, _ := io.ReadAll(r.Body); len(data) > 0 {
+		json.Unmarshal(data, &args)
+	}
+	s.mu.Lock()
+	s.calls = append(s.calls, Call{Path: r.URL.Path, Args: args, Workspace: r.Header.Get("X-Workspace-Root")})
+	h, ok := s.handlers[r.URL.Path]
+	s.mu.Unlock()
+
+	status, body := http.StatusNotFound, interface{}(map[string]interface{}{
+		"code":    "NOT_FOUND",
+		"message": "инструмент " + r.URL.Path + " не настроен в тесте",
+	})
+	if ok {
+		status, body = h(args)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
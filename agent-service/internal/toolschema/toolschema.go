@@ -0,0 +1,243 @@
+// Package toolschema — проверка аргументов вызова инструмента по схеме
+// параметров (JSON Schema из llm.FunctionDefinition.Parameters).
+//
+// Проверяются обязательные поля, типы, enum и элементы массивов — то, что
+// объявляют инструменты агента. Значения, которые модель передала строкой
+// («8083», «true», «["go"]» — XML-формат вызова иначе не умеет), приводятся
+// к типу схемы на месте; ошибкой считается только то, что привести нельзя.
+// Ошибки возвращаются модели, чтобы она исправила вызов сама.
+package toolschema
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Violation — нарушение схемы в одном поле.
+type Violation struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+func (v Violation) String() string {
+	return v.Field + ": " + v.Message
+}
+
+// Schema — подмножество JSON Schema, которое используют инструменты.
+type Schema struct {
+	Type       interface{}        `json:"type"` // Строка или список типов
+	Properties map[string]*Schema `json:"properties"`
+	Required   []string           `json:"required"`
+	Enum       []interface{}      `json:"enum"`
+	Items      *Schema            `json:"items"`
+}
+
+// Parse — схема из Parameters инструмента (map, структура или JSON).
+// nil — схемы нет или она не разбирается: аргументы не проверяются.
+func Parse(params interface{}) *Schema {
+	if params == nil {
+		return nil
+	}
+	data, ok := params.(json.RawMessage)
+	if !ok {
+		var err error
+		if data, err = json.Marshal(params); err != nil {
+			return nil
+		}
+	}
+	var s Schema
+	if json.Unmarshal(data, &s) != nil {
+		return nil
+	}
+	return &s
+}
+
+// Check — проверяет args по схеме и приводит строковые значения к типам
+// схемы. Пустой результат — аргументы корректны.
+func (s *Schema) Check(args map[string]interface{}) []Violation {
+	if s == nil {
+		return nil
+	}
+	var out []Violation
+	for _, name := range s.Required {
+		if v, ok := args[name]; !ok || v == nil {
+			out = append(out, Violation{name, "обязательное поле отсутствует"})
+		}
+	}
+	names := make([]string, 0, len(args))
+	for name := range args {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		prop := s.Properties[name]
+		if prop == nil || args[name] == nil {
+			continue
+		}
+		v, errs := prop.value(name, args[name])
+		args[name] = v
+		out = append(out, errs...)
+	}
+	return out
+}
+
+// value — проверенное и приведённое значение поля.
+func (s *Schema) value(field string, v interface{}) (interface{}, []Violation) {
+	types := s.types()
+	if len(types) > 0 {
+		matched := false
+		for _, t := range types {
+			if c, ok := coerce(t, v); ok {
+				v, matched = c, true
+				break
+			}
+		}
+		if !matched {
+			return v, []Violation{{field, fmt.Sprintf("ожидается %s, получено %s", strings.Join(types, " или "), typeName(v))}}
+		}
+	}
+	if len(s.Enum) > 0 && !inEnum(s.Enum, v) {
+		allowed := make([]string, len(s.Enum))
+		for i, e := range s.Enum {
+			allowed[i] = fmt.Sprint(e)
+		}
+		return v, []Violation{{field, fmt.Sprintf("недопустимое значение %v, допустимые: %s", v, strings.Join(allowed, ", "))}}
+	}
+	var out []Violation
+	switch val := v.(type) {
+	case []interface{}:
+		if s.Items != nil {
+			for i, item := range val {
+				c, errs := s.Items.value(fmt.Sprintf("%s[%d]", field, i), item)
+				val[i] = c
+				out = append(out, errs...)
+			}
+		}
+	case map[string]interface{}:
+		if len(s.Properties) > 0 || len(s.Required) > 0 {
+			for _, e := range s.Check(val) {
+				out = append(out, Violation{field + "." + e.Field, e.Message})
+			}
+		}
+	}
+	return v, out
+}
+
+func (s *Schema) types() []string {
+	switch t := s.Type.(type) {
+	case string:
+		return []string{t}
+	case []interface{}:
+		var out []string
+		for _, x := range t {
+			if str, ok := x.(string); ok {
+				out = append(out, str)
+			}
+		}
+		return out
+	}
+	return nil
+}
+
+// coerce — значение типа t: как есть или приведённое из строки.
+func coerce(t string, v interface{}) (interface{}, bool) {
+	s, isString := v.(string)
+	s = strings.TrimSpace(s)
+	switch t {
+	case "string":
+		switch n := v.(type) {
+		case string:
+			return v, true
+		case float64:
+			// Модель передала число там, где ждут строку (порт, идентификатор)
+			return strconv.FormatFloat(n, 'f', -1, 64), true
+		}
+	case "number":
+		if _, ok := v.(float64); ok {
+			return v, true
+		}
+		if isString {
+			if f, err := strconv.ParseFloat(s, 64); err == nil {
+				return f, true
+			}
+		}
+	case "integer":
+		if f, ok := v.(float64); ok {
+			return v, f == math.Trunc(f)
+		}
+		if isString {
+			if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+				return float64(n), true
+			}
+		}
+	case "boolean":
+		if _, ok := v.(bool); ok {
+			return v, true
+		}
+		if isString {
+			if b, err := strconv.ParseBool(s); err == nil {
+				return b, true
+			}
+		}
+	case "array":
+		if _, ok := v.([]interface{}); ok {
+			return v, true
+		}
+		if isString {
+			var arr []interface{}
+			if strings.HasPrefix(s, "[") && json.Unmarshal([]byte(s), &arr) == nil {
+				return arr, true
+			}
+			if s != "" && !strings.HasPrefix(s, "[") {
+				return []interface{}{s}, true
+			}
+		}
+	case "object":
+		if _, ok := v.(map[string]interface{}); ok {
+			return v, true
+		}
+		if isString {
+			var obj map[string]interface{}
+			if json.Unmarshal([]byte(s), &obj) == nil && obj != nil {
+				return obj, true
+			}
+		}
+	case "null":
+		return v, v == nil
+	default:
+		// Неизвестный тип схемы не проверяется
+		return v, true
+	}
+	return v, false
+}
+
+func inEnum(enum []interface{}, v interface{}) bool {
+	for _, e := range enum {
+		if fmt.Sprint(e) == fmt.Sprint(v) {
+			return true
+		}
+	}
+	return false
+}
+
+func typeName(v interface{}) string {
+	switch v.(type) {
+	case string:
+		return "string"
+	case float64:
+		return "number"
+	case bool:
+		return "boolean"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	case nil:
+		return "null"
+	}
+	return fmt.Sprintf("%T", v)
+}
@@ -0,0 +1,80 @@
+package toolschema
+
+import (
+	"reflect"
+	"testing"
+)
+
+var diagnose = Parse(map[string]any{
+	"type": "object",
+	"properties": map[string]any{
+		"service_name": map[string]any{"type": "string"},
+		"port":         map[string]any{"type": "number"},
+		"verbose":      map[string]any{"type": "boolean"},
+		"language":     map[string]any{"type": "string", "enum": []string{"python", "go"}},
+		"programs":     map[string]any{"type": "array", "items": map[string]any{"type": "string"}},
+		"limits":       map[string]any{"type": "object", "properties": map[string]any{"lines": map[string]any{"type": "integer"}}, "required": []string{"lines"}},
+	},
+	"required": []string{"service_name", "port"},
+})
+
+// TestCheckCoerces — строковые значения из XML-вызовов приводятся к типам схемы.
+func TestCheckCoerces(t *testing.T) {
+	args := map[string]interface{}{
+		"service_name": "nginx",
+		"port":         "8083",
+		"verbose":      "true",
+		"language":     "go",
+		"programs":     `["go", "git"]`,
+		"limits":       `{"lines": "50"}`,
+		"extra":        "неизвестные поля не проверяются",
+	}
+	if v := diagnose.Check(args); len(v) != 0 {
+		t.Fatalf("нарушения: %v", v)
+	}
+	want := map[string]interface{}{
+		"service_name": "nginx",
+		"port":         float64(8083),
+		"verbose":      true,
+		"language":     "go",
+		"programs":     []interface{}{"go", "git"},
+		"limits":       map[string]interface{}{"lines": float64(50)},
+		"extra":        "неизвестные поля не проверяются",
+	}
+	if !reflect.DeepEqual(args, want) {
+		t.Errorf("приведённые аргументы: %v", args)
+	}
+}
+
+// TestCheckViolations — обязательные поля, типы, enum и вложенные значения.
+func TestCheckViolations(t *testing.T) {
+	args := map[string]interface{}{
+		"port":     "восемь",
+		"verbose":  "может быть",
+		"language": "rust",
+		"programs": []interface{}{"go", float64(1), map[string]interface{}{}},
+		"limits":   map[string]interface{}{"lines": 1.5},
+	}
+	got := diagnose.Check(args)
+	want := []Violation{
+		{"service_name", "обязательное поле отсутствует"},
+		{"language", "недопустимое значение rust, допустимые: python, go"},
+		{"limits.lines", "ожидается integer, получено number"},
+		{"port", "ожидается number, получено string"},
+		{"programs[2]", "ожидается string, получено object"},
+		{"verbose", "ожидается boolean, получено string"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("нарушения:\n%v\nожидалось:\n%v", got, want)
+	}
+	if args["programs"].([]interface{})[1] != "1" {
+		t.Errorf("число в массиве строк не приведено: %v", args["programs"])
+	}
+}
+
+// TestParseNil — без схемы аргументы не проверяются.
+func TestParseNil(t *testing.T) {
+	if s := Parse(nil); s != nil || len(s.Check(map[string]interface{}{"x": 1})) != 0 {
+		t.Errorf("схема: %+v", s)
+	}
+}
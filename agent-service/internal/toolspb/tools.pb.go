@@ -0,0 +1,764 @@
+// Внутренний API tools-service для agent-service.
+//
+// Работает параллельно с HTTP JSON API: внешние клиенты и api-gateway
+// по-прежнему ходят по HTTP, а agent-service вызывает по gRPC команды и
+// файловые инструменты — одно долгоживущее HTTP/2-соединение вместо запроса
+// на каждый вызов и потоковый вывод команд. Остальные инструменты
+// agent-service вызывает по HTTP.
+//
+// Авторизация — метаданные authorization: Bearer <токен> (те же токены
+// TOOLS_AUTH_TOKENS и роли, что у одноимённых HTTP-эндпоинтов). Метаданные
+// x-request-id, traceparent и x-workspace-root — как одноимённые заголовки HTTP.
+//
+// Ошибки — статусы gRPC (INVALID_ARGUMENT, UNAUTHENTICATED, PERMISSION_DENIED,
+// NOT_FOUND, INTERNAL) с google.rpc.ErrorInfo: reason — код apierror
+// (FORBIDDEN, NOT_FOUND...), metadata["hint"] — подсказка, domain — tools-service.
+//
+// Go-код: make proto (пакеты internal/toolspb в agent-service и tools-service).
+
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.11
+// 	protoc        v5.29.3
+// source: tools/v1/tools.proto
+
+package toolspb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type ExecuteRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Command       string                 `protobuf:"bytes,1,opt,name=command,proto3" json:"command,omitempty"`
+	Env           map[string]string      `protobuf:"bytes,2,rep,name=env,proto3" json:"env,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"` // Переменные окружения команды (секреты диалога или рабочего пространства)
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ExecuteRequest) Reset() {
+	*x = ExecuteRequest{}
+	mi := &file_tools_v1_tools_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ExecuteRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ExecuteRequest) ProtoMessage() {}
+
+func (x *ExecuteRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_tools_v1_tools_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ExecuteRequest.ProtoReflect.Descriptor instead.
+func (*ExecuteRequest) Descriptor() ([]byte, []int) {
+	return file_tools_v1_tools_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *ExecuteRequest) GetCommand() string {
+	if x != nil {
+		return x.Command
+	}
+	return ""
+}
+
+func (x *ExecuteRequest) GetEnv() map[string]string {
+	if x != nil {
+		return x.Env
+	}
+	return nil
+}
+
+type ExecuteEvent struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Types that are valid to be assigned to Event:
+	//
+	//	*ExecuteEvent_Output
+	//	*ExecuteEvent_Result
+	Event         isExecuteEvent_Event `protobuf_oneof:"event"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ExecuteEvent) Reset() {
+	*x = ExecuteEvent{}
+	mi := &file_tools_v1_tools_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ExecuteEvent) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ExecuteEvent) ProtoMessage() {}
+
+func (x *ExecuteEvent) ProtoReflect() protoreflect.Message {
+	mi := &file_tools_v1_tools_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ExecuteEvent.ProtoReflect.Descriptor instead.
+func (*ExecuteEvent) Descriptor() ([]byte, []int) {
+	return file_tools_v1_tools_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *ExecuteEvent) GetEvent() isExecuteEvent_Event {
+	if x != nil {
+		return x.Event
+	}
+	return nil
+}
+
+func (x *ExecuteEvent) GetOutput() *OutputChunk {
+	if x != nil {
+		if x, ok := x.Event.(*ExecuteEvent_Output); ok {
+			return x.Output
+		}
+	}
+	return nil
+}
+
+func (x *ExecuteEvent) GetResult() *ExecuteResult {
+	if x != nil {
+		if x, ok := x.Event.(*ExecuteEvent_Result); ok {
+			return x.Result
+		}
+	}
+	return nil
+}
+
+type isExecuteEvent_Event interface {
+	isExecuteEvent_Event()
+}
+
+type ExecuteEvent_Output struct {
+	Output *OutputChunk `protobuf:"bytes,1,opt,name=output,proto3,oneof"`
+}
+
+type ExecuteEvent_Result struct {
+	Result *ExecuteResult `protobuf:"bytes,2,opt,name=result,proto3,oneof"`
+}
+
+func (*ExecuteEvent_Output) isExecuteEvent_Event() {}
+
+func (*ExecuteEvent_Result) isExecuteEvent_Event() {}
+
+type OutputChunk struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Stream        string                 `protobuf:"bytes,1,opt,name=stream,proto3" json:"stream,omitempty"` // stdout или stderr
+	Data          []byte                 `protobuf:"bytes,2,opt,name=data,proto3" json:"data,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *OutputChunk) Reset() {
+	*x = OutputChunk{}
+	mi := &file_tools_v1_tools_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *OutputChunk) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*OutputChunk) ProtoMessage() {}
+
+func (x *OutputChunk) ProtoReflect() protoreflect.Message {
+	mi := &file_tools_v1_tools_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use OutputChunk.ProtoReflect.Descriptor instead.
+func (*OutputChunk) Descriptor() ([]byte, []int) {
+	return file_tools_v1_tools_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *OutputChunk) GetStream() string {
+	if x != nil {
+		return x.Stream
+	}
+	return ""
+}
+
+func (x *OutputChunk) GetData() []byte {
+	if x != nil {
+		return x.Data
+	}
+	return nil
+}
+
+type ExecuteResult struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Stdout        string                 `protobuf:"bytes,1,opt,name=stdout,proto3" json:"stdout,omitempty"`
+	Stderr        string                 `protobuf:"bytes,2,opt,name=stderr,proto3" json:"stderr,omitempty"`
+	ReturnCode    int32                  `protobuf:"varint,3,opt,name=return_code,json=returnCode,proto3" json:"return_code,omitempty"`
+	Error         string                 `protobuf:"bytes,4,opt,name=error,proto3" json:"error,omitempty"` // Ошибка запуска или таймаут; код возврата команды — в return_code
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ExecuteResult) Reset() {
+	*x = ExecuteResult{}
+	mi := &file_tools_v1_tools_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ExecuteResult) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ExecuteResult) ProtoMessage() {}
+
+func (x *ExecuteResult) ProtoReflect() protoreflect.Message {
+	mi := &file_tools_v1_tools_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ExecuteResult.ProtoReflect.Descriptor instead.
+func (*ExecuteResult) Descriptor() ([]byte, []int) {
+	return file_tools_v1_tools_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *ExecuteResult) GetStdout() string {
+	if x != nil {
+		return x.Stdout
+	}
+	return ""
+}
+
+func (x *ExecuteResult) GetStderr() string {
+	if x != nil {
+		return x.Stderr
+	}
+	return ""
+}
+
+func (x *ExecuteResult) GetReturnCode() int32 {
+	if x != nil {
+		return x.ReturnCode
+	}
+	return 0
+}
+
+func (x *ExecuteResult) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+// Пути файловых инструментов — относительно рабочего пространства
+// (x-workspace-root), если оно задано.
+type ReadFileRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Path          string                 `protobuf:"bytes,1,opt,name=path,proto3" json:"path,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ReadFileRequest) Reset() {
+	*x = ReadFileRequest{}
+	mi := &file_tools_v1_tools_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ReadFileRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ReadFileRequest) ProtoMessage() {}
+
+func (x *ReadFileRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_tools_v1_tools_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ReadFileRequest.ProtoReflect.Descriptor instead.
+func (*ReadFileRequest) Descriptor() ([]byte, []int) {
+	return file_tools_v1_tools_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *ReadFileRequest) GetPath() string {
+	if x != nil {
+		return x.Path
+	}
+	return ""
+}
+
+type ReadFileResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Content       string                 `protobuf:"bytes,1,opt,name=content,proto3" json:"content,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ReadFileResponse) Reset() {
+	*x = ReadFileResponse{}
+	mi := &file_tools_v1_tools_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ReadFileResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ReadFileResponse) ProtoMessage() {}
+
+func (x *ReadFileResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_tools_v1_tools_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ReadFileResponse.ProtoReflect.Descriptor instead.
+func (*ReadFileResponse) Descriptor() ([]byte, []int) {
+	return file_tools_v1_tools_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *ReadFileResponse) GetContent() string {
+	if x != nil {
+		return x.Content
+	}
+	return ""
+}
+
+type WriteFileRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Path          string                 `protobuf:"bytes,1,opt,name=path,proto3" json:"path,omitempty"`
+	Content       string                 `protobuf:"bytes,2,opt,name=content,proto3" json:"content,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *WriteFileRequest) Reset() {
+	*x = WriteFileRequest{}
+	mi := &file_tools_v1_tools_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *WriteFileRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WriteFileRequest) ProtoMessage() {}
+
+func (x *WriteFileRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_tools_v1_tools_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use WriteFileRequest.ProtoReflect.Descriptor instead.
+func (*WriteFileRequest) Descriptor() ([]byte, []int) {
+	return file_tools_v1_tools_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *WriteFileRequest) GetPath() string {
+	if x != nil {
+		return x.Path
+	}
+	return ""
+}
+
+func (x *WriteFileRequest) GetContent() string {
+	if x != nil {
+		return x.Content
+	}
+	return ""
+}
+
+type WriteFileResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *WriteFileResponse) Reset() {
+	*x = WriteFileResponse{}
+	mi := &file_tools_v1_tools_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *WriteFileResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WriteFileResponse) ProtoMessage() {}
+
+func (x *WriteFileResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_tools_v1_tools_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use WriteFileResponse.ProtoReflect.Descriptor instead.
+func (*WriteFileResponse) Descriptor() ([]byte, []int) {
+	return file_tools_v1_tools_proto_rawDescGZIP(), []int{7}
+}
+
+type ListDirRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Path          string                 `protobuf:"bytes,1,opt,name=path,proto3" json:"path,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListDirRequest) Reset() {
+	*x = ListDirRequest{}
+	mi := &file_tools_v1_tools_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListDirRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListDirRequest) ProtoMessage() {}
+
+func (x *ListDirRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_tools_v1_tools_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListDirRequest.ProtoReflect.Descriptor instead.
+func (*ListDirRequest) Descriptor() ([]byte, []int) {
+	return file_tools_v1_tools_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *ListDirRequest) GetPath() string {
+	if x != nil {
+		return x.Path
+	}
+	return ""
+}
+
+type ListDirResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Files         []string               `protobuf:"bytes,1,rep,name=files,proto3" json:"files,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListDirResponse) Reset() {
+	*x = ListDirResponse{}
+	mi := &file_tools_v1_tools_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListDirResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListDirResponse) ProtoMessage() {}
+
+func (x *ListDirResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_tools_v1_tools_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListDirResponse.ProtoReflect.Descriptor instead.
+func (*ListDirResponse) Descriptor() ([]byte, []int) {
+	return file_tools_v1_tools_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *ListDirResponse) GetFiles() []string {
+	if x != nil {
+		return x.Files
+	}
+	return nil
+}
+
+type DeleteFileRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Path          string                 `protobuf:"bytes,1,opt,name=path,proto3" json:"path,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DeleteFileRequest) Reset() {
+	*x = DeleteFileRequest{}
+	mi := &file_tools_v1_tools_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DeleteFileRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeleteFileRequest) ProtoMessage() {}
+
+func (x *DeleteFileRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_tools_v1_tools_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeleteFileRequest.ProtoReflect.Descriptor instead.
+func (*DeleteFileRequest) Descriptor() ([]byte, []int) {
+	return file_tools_v1_tools_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *DeleteFileRequest) GetPath() string {
+	if x != nil {
+		return x.Path
+	}
+	return ""
+}
+
+type DeleteFileResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DeleteFileResponse) Reset() {
+	*x = DeleteFileResponse{}
+	mi := &file_tools_v1_tools_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DeleteFileResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeleteFileResponse) ProtoMessage() {}
+
+func (x *DeleteFileResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_tools_v1_tools_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeleteFileResponse.ProtoReflect.Descriptor instead.
+func (*DeleteFileResponse) Descriptor() ([]byte, []int) {
+	return file_tools_v1_tools_proto_rawDescGZIP(), []int{11}
+}
+
+var File_tools_v1_tools_proto protoreflect.FileDescriptor
+
+const file_tools_v1_tools_proto_rawDesc = "" +
+	"\n" +
+	"\x14tools/v1/tools.proto\x12\x14agentregart.tools.v1\"\xa3\x01\n" +
+	"\x0eExecuteRequest\x12\x18\n" +
+	"\acommand\x18\x01 \x01(\tR\acommand\x12?\n" +
+	"\x03env\x18\x02 \x03(\v2-.agentregart.tools.v1.ExecuteRequest.EnvEntryR\x03env\x1a6\n" +
+	"\bEnvEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"\x93\x01\n" +
+	"\fExecuteEvent\x12;\n" +
+	"\x06output\x18\x01 \x01(\v2!.agentregart.tools.v1.OutputChunkH\x00R\x06output\x12=\n" +
+	"\x06result\x18\x02 \x01(\v2#.agentregart.tools.v1.ExecuteResultH\x00R\x06resultB\a\n" +
+	"\x05event\"9\n" +
+	"\vOutputChunk\x12\x16\n" +
+	"\x06stream\x18\x01 \x01(\tR\x06stream\x12\x12\n" +
+	"\x04data\x18\x02 \x01(\fR\x04data\"v\n" +
+	"\rExecuteResult\x12\x16\n" +
+	"\x06stdout\x18\x01 \x01(\tR\x06stdout\x12\x16\n" +
+	"\x06stderr\x18\x02 \x01(\tR\x06stderr\x12\x1f\n" +
+	"\vreturn_code\x18\x03 \x01(\x05R\n" +
+	"returnCode\x12\x14\n" +
+	"\x05error\x18\x04 \x01(\tR\x05error\"%\n" +
+	"\x0fReadFileRequest\x12\x12\n" +
+	"\x04path\x18\x01 \x01(\tR\x04path\",\n" +
+	"\x10ReadFileResponse\x12\x18\n" +
+	"\acontent\x18\x01 \x01(\tR\acontent\"@\n" +
+	"\x10WriteFileRequest\x12\x12\n" +
+	"\x04path\x18\x01 \x01(\tR\x04path\x12\x18\n" +
+	"\acontent\x18\x02 \x01(\tR\acontent\"\x13\n" +
+	"\x11WriteFileResponse\"$\n" +
+	"\x0eListDirRequest\x12\x12\n" +
+	"\x04path\x18\x01 \x01(\tR\x04path\"'\n" +
+	"\x0fListDirResponse\x12\x14\n" +
+	"\x05files\x18\x01 \x03(\tR\x05files\"'\n" +
+	"\x11DeleteFileRequest\x12\x12\n" +
+	"\x04path\x18\x01 \x01(\tR\x04path\"\x14\n" +
+	"\x12DeleteFileResponse2\xd0\x03\n" +
+	"\x05Tools\x12U\n" +
+	"\aExecute\x12$.agentregart.tools.v1.ExecuteRequest\x1a\".agentregart.tools.v1.ExecuteEvent0\x01\x12Y\n" +
+	"\bReadFile\x12%.agentregart.tools.v1.ReadFileRequest\x1a&.agentregart.tools.v1.ReadFileResponse\x12\\\n" +
+	"\tWriteFile\x12&.agentregart.tools.v1.WriteFileRequest\x1a'.agentregart.tools.v1.WriteFileResponse\x12V\n" +
+	"\aListDir\x12$.agentregart.tools.v1.ListDirRequest\x1a%.agentregart.tools.v1.ListDirResponse\x12_\n" +
+	"\n" +
+	"DeleteFile\x12'.agentregart.tools.v1.DeleteFileRequest\x1a(.agentregart.tools.v1.DeleteFileResponseb\x06proto3"
+
+var (
+	file_tools_v1_tools_proto_rawDescOnce sync.Once
+	file_tools_v1_tools_proto_rawDescData []byte
+)
+
+func file_tools_v1_tools_proto_rawDescGZIP() []byte {
+	file_tools_v1_tools_proto_rawDescOnce.Do(func() {
+		file_tools_v1_tools_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_tools_v1_tools_proto_rawDesc), len(file_tools_v1_tools_proto_rawDesc)))
+	})
+	return file_tools_v1_tools_proto_rawDescData
+}
+
+var file_tools_v1_tools_proto_msgTypes = make([]protoimpl.MessageInfo, 13)
+var file_tools_v1_tools_proto_goTypes = []any{
+	(*ExecuteRequest)(nil),     // 0: agentregart.tools.v1.ExecuteRequest
+	(*ExecuteEvent)(nil),       // 1: agentregart.tools.v1.ExecuteEvent
+	(*OutputChunk)(nil),        // 2: agentregart.tools.v1.OutputChunk
+	(*ExecuteResult)(nil),      // 3: agentregart.tools.v1.ExecuteResult
+	(*ReadFileRequest)(nil),    // 4: agentregart.tools.v1.ReadFileRequest
+	(*ReadFileResponse)(nil),   // 5: agentregart.tools.v1.ReadFileResponse
+	(*WriteFileRequest)(nil),   // 6: agentregart.tools.v1.WriteFileRequest
+	(*WriteFileResponse)(nil),  // 7: agentregart.tools.v1.WriteFileResponse
+	(*ListDirRequest)(nil),     // 8: agentregart.tools.v1.ListDirRequest
+	(*ListDirResponse)(nil),    // 9: agentregart.tools.v1.ListDirResponse
+	(*DeleteFileRequest)(nil),  // 10: agentregart.tools.v1.DeleteFileRequest
+	(*DeleteFileResponse)(nil), // 11: agentregart.tools.v1.DeleteFileResponse
+	nil,                        // 12: agentregart.tools.v1.ExecuteRequest.EnvEntry
+}
+var file_tools_v1_tools_proto_depIdxs = []int32{
+	12, // 0: agentregart.tools.v1.ExecuteRequest.env:type_name -> agentregart.tools.v1.ExecuteRequest.EnvEntry
+	2,  // 1: agentregart.tools.v1.ExecuteEvent.output:type_name -> agentregart.tools.v1.OutputChunk
+	3,  // 2: agentregart.tools.v1.ExecuteEvent.result:type_name -> agentregart.tools.v1.ExecuteResult
+	0,  // 3: agentregart.tools.v1.Tools.Execute:input_type -> agentregart.tools.v1.ExecuteRequest
+	4,  // 4: agentregart.tools.v1.Tools.ReadFile:input_type -> agentregart.tools.v1.ReadFileRequest
+	6,  // 5: agentregart.tools.v1.Tools.WriteFile:input_type -> agentregart.tools.v1.WriteFileRequest
+	8,  // 6: agentregart.tools.v1.Tools.ListDir:input_type -> agentregart.tools.v1.ListDirRequest
+	10, // 7: agentregart.tools.v1.Tools.DeleteFile:input_type -> agentregart.tools.v1.DeleteFileRequest
+	1,  // 8: agentregart.tools.v1.Tools.Execute:output_type -> agentregart.tools.v1.ExecuteEvent
+	5,  // 9: agentregart.tools.v1.Tools.ReadFile:output_type -> agentregart.tools.v1.ReadFileResponse
+	7,  // 10: agentregart.tools.v1.Tools.WriteFile:output_type -> agentregart.tools.v1.WriteFileResponse
+	9,  // 11: agentregart.tools.v1.Tools.ListDir:output_type -> agentregart.tools.v1.ListDirResponse
+	11, // 12: agentregart.tools.v1.Tools.DeleteFile:output_type -> agentregart.tools.v1.DeleteFileResponse
+	8,  // [8:13] is the sub-list for method output_type
+	3,  // [3:8] is the sub-list for method input_type
+	3,  // [3:3] is the sub-list for extension type_name
+	3,  // [3:3] is the sub-list for extension extendee
+	0,  // [0:3] is the sub-list for field type_name
+}
+
+func init() { file_tools_v1_tools_proto_init() }
+func file_tools_v1_tools_proto_init() {
+	if File_tools_v1_tools_proto != nil {
+		return
+	}
+	file_tools_v1_tools_proto_msgTypes[1].OneofWrappers = []any{
+		(*ExecuteEvent_Output)(nil),
+		(*ExecuteEvent_Result)(nil),
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_tools_v1_tools_proto_rawDesc), len(file_tools_v1_tools_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   13,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_tools_v1_tools_proto_goTypes,
+		DependencyIndexes: file_tools_v1_tools_proto_depIdxs,
+		MessageInfos:      file_tools_v1_tools_proto_msgTypes,
+	}.Build()
+	File_tools_v1_tools_proto = out.File
+	file_tools_v1_tools_proto_goTypes = nil
+	file_tools_v1_tools_proto_depIdxs = nil
+}
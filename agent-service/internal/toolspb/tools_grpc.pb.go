@@ -0,0 +1,307 @@
+// Внутренний API tools-service для agent-service.
+//
+// Работает параллельно с HTTP JSON API: внешние клиенты и api-gateway
+// по-прежнему ходят по HTTP, а agent-service вызывает по gRPC команды и
+// файловые инструменты — одно долгоживущее HTTP/2-соединение вместо запроса
+// на каждый вызов и потоковый вывод команд. Остальные инструменты
+// agent-service вызывает по HTTP.
+//
+// Авторизация — метаданные authorization: Bearer <токен> (те же токены
+// TOOLS_AUTH_TOKENS и роли, что у одноимённых HTTP-эндпоинтов). Метаданные
+// x-request-id, traceparent и x-workspace-root — как одноимённые заголовки HTTP.
+//
+// Ошибки — статусы gRPC (INVALID_ARGUMENT, UNAUTHENTICATED, PERMISSION_DENIED,
+// NOT_FOUND, INTERNAL) с google.rpc.ErrorInfo: reason — код apierror
+// (FORBIDDEN, NOT_FOUND...), metadata["hint"] — подсказка, domain — tools-service.
+//
+// Go-код: make proto (пакеты internal/toolspb в agent-service и tools-service).
+
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             v5.29.3
+// source: tools/v1/tools.proto
+
+package toolspb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	Tools_Execute_FullMethodName    = "/agentregart.tools.v1.Tools/Execute"
+	Tools_ReadFile_FullMethodName   = "/agentregart.tools.v1.Tools/ReadFile"
+	Tools_WriteFile_FullMethodName  = "/agentregart.tools.v1.Tools/WriteFile"
+	Tools_ListDir_FullMethodName    = "/agentregart.tools.v1.Tools/ListDir"
+	Tools_DeleteFile_FullMethodName = "/agentregart.tools.v1.Tools/DeleteFile"
+)
+
+// ToolsClient is the client API for Tools service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type ToolsClient interface {
+	// Execute — выполнение команды (как POST /execute, роль admin) с потоковой
+	// передачей stdout и stderr по мере появления; последнее событие — итог.
+	Execute(ctx context.Context, in *ExecuteRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[ExecuteEvent], error)
+	// ReadFile — содержимое файла (POST /read, роль viewer).
+	ReadFile(ctx context.Context, in *ReadFileRequest, opts ...grpc.CallOption) (*ReadFileResponse, error)
+	// WriteFile — запись файла (POST /write, роль operator).
+	WriteFile(ctx context.Context, in *WriteFileRequest, opts ...grpc.CallOption) (*WriteFileResponse, error)
+	// ListDir — содержимое каталога (POST /list, роль viewer).
+	ListDir(ctx context.Context, in *ListDirRequest, opts ...grpc.CallOption) (*ListDirResponse, error)
+	// DeleteFile — удаление файла (POST /delete, роль operator).
+	DeleteFile(ctx context.Context, in *DeleteFileRequest, opts ...grpc.CallOption) (*DeleteFileResponse, error)
+}
+
+type toolsClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewToolsClient(cc grpc.ClientConnInterface) ToolsClient {
+	return &toolsClient{cc}
+}
+
+func (c *toolsClient) Execute(ctx context.Context, in *ExecuteRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[ExecuteEvent], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Tools_ServiceDesc.Streams[0], Tools_Execute_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[ExecuteRequest, ExecuteEvent]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Tools_ExecuteClient = grpc.ServerStreamingClient[ExecuteEvent]
+
+func (c *toolsClient) ReadFile(ctx context.Context, in *ReadFileRequest, opts ...grpc.CallOption) (*ReadFileResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ReadFileResponse)
+	err := c.cc.Invoke(ctx, Tools_ReadFile_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *toolsClient) WriteFile(ctx context.Context, in *WriteFileRequest, opts ...grpc.CallOption) (*WriteFileResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(WriteFileResponse)
+	err := c.cc.Invoke(ctx, Tools_WriteFile_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *toolsClient) ListDir(ctx context.Context, in *ListDirRequest, opts ...grpc.CallOption) (*ListDirResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListDirResponse)
+	err := c.cc.Invoke(ctx, Tools_ListDir_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *toolsClient) DeleteFile(ctx context.Context, in *DeleteFileRequest, opts ...grpc.CallOption) (*DeleteFileResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(DeleteFileResponse)
+	err := c.cc.Invoke(ctx, Tools_DeleteFile_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// ToolsServer is the server API for Tools service.
+// All implementations must embed UnimplementedToolsServer
+// for forward compatibility.
+type ToolsServer interface {
+	// Execute — выполнение команды (как POST /execute, роль admin) с потоковой
+	// передачей stdout и stderr по мере появления; последнее событие — итог.
+	Execute(*ExecuteRequest, grpc.ServerStreamingServer[ExecuteEvent]) error
+	// ReadFile — содержимое файла (POST /read, роль viewer).
+	ReadFile(context.Context, *ReadFileRequest) (*ReadFileResponse, error)
+	// WriteFile — запись файла (POST /write, роль operator).
+	WriteFile(context.Context, *WriteFileRequest) (*WriteFileResponse, error)
+	// ListDir — содержимое каталога (POST /list, роль viewer).
+	ListDir(context.Context, *ListDirRequest) (*ListDirResponse, error)
+	// DeleteFile — удаление файла (POST /delete, роль operator).
+	DeleteFile(context.Context, *DeleteFileRequest) (*DeleteFileResponse, error)
+	mustEmbedUnimplementedToolsServer()
+}
+
+// UnimplementedToolsServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedToolsServer struct{}
+
+func (UnimplementedToolsServer) Execute(*ExecuteRequest, grpc.ServerStreamingServer[ExecuteEvent]) error {
+	return status.Errorf(codes.Unimplemented, "method Execute not implemented")
+}
+func (UnimplementedToolsServer) ReadFile(context.Context, *ReadFileRequest) (*ReadFileResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ReadFile not implemented")
+}
+func (UnimplementedToolsServer) WriteFile(context.Context, *WriteFileRequest) (*WriteFileResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method WriteFile not implemented")
+}
+func (UnimplementedToolsServer) ListDir(context.Context, *ListDirRequest) (*ListDirResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListDir not implemented")
+}
+func (UnimplementedToolsServer) DeleteFile(context.Context, *DeleteFileRequest) (*DeleteFileResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteFile not implemented")
+}
+func (UnimplementedToolsServer) mustEmbedUnimplementedToolsServer() {}
+func (UnimplementedToolsServer) testEmbeddedByValue()               {}
+
+// UnsafeToolsServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to ToolsServer will
+// result in compilation errors.
+type UnsafeToolsServer interface {
+	mustEmbedUnimplementedToolsServer()
+}
+
+func RegisterToolsServer(s grpc.ServiceRegistrar, srv ToolsServer) {
+	// If the following call pancis, it indicates UnimplementedToolsServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&Tools_ServiceDesc, srv)
+}
+
+func _Tools_Execute_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(ExecuteRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(ToolsServer).Execute(m, &grpc.GenericServerStream[ExecuteRequest, ExecuteEvent]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Tools_ExecuteServer = grpc.ServerStreamingServer[ExecuteEvent]
+
+func _Tools_ReadFile_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ReadFileRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ToolsServer).ReadFile(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Tools_ReadFile_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ToolsServer).ReadFile(ctx, req.(*ReadFileRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Tools_WriteFile_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(WriteFileRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ToolsServer).WriteFile(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Tools_WriteFile_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ToolsServer).WriteFile(ctx, req.(*WriteFileRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Tools_ListDir_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListDirRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ToolsServer).ListDir(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Tools_ListDir_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ToolsServer).ListDir(ctx, req.(*ListDirRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Tools_DeleteFile_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteFileRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ToolsServer).DeleteFile(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Tools_DeleteFile_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ToolsServer).DeleteFile(ctx, req.(*DeleteFileRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// Tools_ServiceDesc is the grpc.ServiceDesc for Tools service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Tools_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "agentregart.tools.v1.Tools",
+	HandlerType: (*ToolsServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "ReadFile",
+			Handler:    _Tools_ReadFile_Handler,
+		},
+		{
+			MethodName: "WriteFile",
+			Handler:    _Tools_WriteFile_Handler,
+		},
+		{
+			MethodName: "ListDir",
+			Handler:    _Tools_ListDir_Handler,
+		},
+		{
+			MethodName: "DeleteFile",
+			Handler:    _Tools_DeleteFile_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Execute",
+			Handler:       _Tools_Execute_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "tools/v1/tools.proto",
+}
@@ -0,0 +1,158 @@
+// Package toolsrpc — gRPC-клиент внутреннего API tools-service (контракт —
+// proto/tools/v1/tools.proto). Включается TOOLS_GRPC_ADDR; без него
+// инструменты вызываются по HTTP.
+//
+// По gRPC вызываются команды (Execute с потоковым stdout и stderr) и файловые
+// инструменты; остальные инструменты tools-service остаются на HTTP. Ошибку
+// вызова HTTPError переводит в HTTP-статус и тело apierror, поэтому разбор
+// ответа инструмента не зависит от транспорта.
+package toolsrpc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/neo-2022/openclaw-memory/agent-service/internal/apierror"
+	"github.com/neo-2022/openclaw-memory/agent-service/internal/toolspb"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// forwardedHeaders — заголовки HTTP-запроса, передаваемые как метаданные gRPC.
+var forwardedHeaders = []string{"Authorization", "X-Request-ID", "Traceparent", "X-Workspace-Root"}
+
+// errorDomain — domain в ErrorInfo ошибок tools-service.
+const errorDomain = "tools-service"
+
+// httpStatuses — HTTP-статус ошибки tools-service по статусу gRPC.
+var httpStatuses = map[codes.Code]int{
+	codes.InvalidArgument:  http.StatusBadRequest,
+	codes.Unauthenticated:  http.StatusUnauthorized,
+	codes.PermissionDenied: http.StatusForbidden,
+	codes.NotFound:         http.StatusNotFound,
+	codes.Internal:         http.StatusInternalServerError,
+}
+
+// Client — соединение с tools-service; безопасен для параллельных вызовов.
+type Client struct {
+	conn  *grpc.ClientConn
+	tools toolspb.ToolsClient
+}
+
+// New — клиент для addr (host:port). Соединение устанавливается при первом
+// вызове и восстанавливается автоматически.
+func New(addr string) (*Client, error) {
+	conn, err := grpc.NewClient(addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		return nil, err
+	}
+	return &Client{conn: conn, tools: toolspb.NewToolsClient(conn)}, nil
+}
+
+// Close — закрывает соединение.
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
+
+// Execute — выполнение команды с потоковым выводом: onOutput вызывается для
+// каждого фрагмента stdout или stderr (может быть nil). env — дополнительные
+// переменные окружения команды, header — заголовки запроса (токен,
+// X-Request-ID, трассировка, рабочее пространство).
+func (c *Client) Execute(ctx context.Context, command string, env map[string]string, header http.Header, onOutput func(stream string, data []byte)) (*toolspb.ExecuteResult, error) {
+	stream, err := c.tools.Execute(outgoing(ctx, header), &toolspb.ExecuteRequest{Command: command, Env: env})
+	if err != nil {
+		return nil, err
+	}
+	for {
+		ev, err := stream.Recv()
+		if err == io.EOF {
+			return nil, errors.New("tools-service завершил поток без результата")
+		}
+		if err != nil {
+			return nil, err
+		}
+		if out := ev.GetOutput(); out != nil {
+			if onOutput != nil {
+				onOutput(out.GetStream(), out.GetData())
+			}
+			continue
+		}
+		if res := ev.GetResult(); res != nil {
+			return res, nil
+		}
+	}
+}
+
+// ReadFile — содержимое файла path.
+func (c *Client) ReadFile(ctx context.Context, path string, header http.Header) (string, error) {
+	resp, err := c.tools.ReadFile(outgoing(ctx, header), &toolspb.ReadFileRequest{Path: path})
+	return resp.GetContent(), err
+}
+
+// WriteFile — записывает content в файл path.
+func (c *Client) WriteFile(ctx context.Context, path, content string, header http.Header) error {
+	_, err := c.tools.WriteFile(outgoing(ctx, header), &toolspb.WriteFileRequest{Path: path, Content: content})
+	return err
+}
+
+// ListDir — имена файлов каталога path.
+func (c *Client) ListDir(ctx context.Context, path string, header http.Header) ([]string, error) {
+	resp, err := c.tools.ListDir(outgoing(ctx, header), &toolspb.ListDirRequest{Path: path})
+	return resp.GetFiles(), err
+}
+
+// DeleteFile — удаляет файл path.
+func (c *Client) DeleteFile(ctx context.Context, path string, header http.Header) error {
+	_, err := c.tools.DeleteFile(outgoing(ctx, header), &toolspb.DeleteFileRequest{Path: path})
+	return err
+}
+
+// HTTPError — ошибка, которую вернул tools-service, как HTTP-статус и тело
+// apierror того же эндпоинта HTTP API. ok=false — ошибка транспорта
+// (сервис недоступен, истёк таймаут): её обрабатывают как сбой HTTP-запроса.
+func HTTPError(err error) (int, []byte, bool) {
+	st, ok := status.FromError(err)
+	if !ok {
+		return 0, nil, false
+	}
+	for _, d := range st.Details() {
+		info, ok := d.(*errdetails.ErrorInfo)
+		if !ok || info.GetDomain() != errorDomain {
+			continue
+		}
+		code, ok := httpStatuses[st.Code()]
+		if !ok {
+			code = http.StatusInternalServerError
+		}
+		body, _ := json.Marshal(apierror.Response{
+			Code:      info.GetReason(),
+			Message:   st.Message(),
+			Hint:      info.GetMetadata()["hint"],
+			RequestID: info.GetMetadata()["request_id"],
+			Retryable: code >= 500,
+		})
+		return code, body, true
+	}
+	return 0, nil, false
+}
+
+func outgoing(ctx context.Context, header http.Header) context.Context {
+	var kv []string
+	for _, name := range forwardedHeaders {
+		if v := header.Get(name); v != "" {
+			kv = append(kv, strings.ToLower(name), v)
+		}
+	}
+	if len(kv) == 0 {
+		return ctx
+	}
+	return metadata.AppendToOutgoingContext(ctx, kv...)
+}
@@ -0,0 +1,101 @@
+package toolsrpc
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"testing"
+
+	"github.com/neo-2022/openclaw-memory/agent-service/internal/apierror"
+	"github.com/neo-2022/openclaw-memory/agent-service/internal/toolspb"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// fakeTools — tools-service, проверяющий токен и отвечающий выводом команды.
+type fakeTools struct {
+	toolspb.UnimplementedToolsServer
+}
+
+func (fakeTools) ReadFile(ctx context.Context, req *toolspb.ReadFileRequest) (*toolspb.ReadFileResponse, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	if v := md.Get("authorization"); len(v) == 0 || v[0] != "Bearer secret" {
+		return nil, status.Error(codes.Unauthenticated, "нет токена")
+	}
+	if req.Path == "missing.txt" {
+		st, _ := status.New(codes.NotFound, "файл не найден").WithDetails(&errdetails.ErrorInfo{
+			Reason: "NOT_FOUND", Domain: errorDomain, Metadata: map[string]string{"request_id": md.Get("x-request-id")[0]},
+		})
+		return nil, st.Err()
+	}
+	return &toolspb.ReadFileResponse{Content: "content of " + req.Path}, nil
+}
+
+func (fakeTools) Execute(req *toolspb.ExecuteRequest, stream toolspb.Tools_ExecuteServer) error {
+	for _, chunk := range []string{"a\n", "b\n"} {
+		stream.Send(&toolspb.ExecuteEvent{Event: &toolspb.ExecuteEvent_Output{Output: &toolspb.OutputChunk{Stream: "stdout", Data: []byte(chunk)}}})
+	}
+	return stream.Send(&toolspb.ExecuteEvent{Event: &toolspb.ExecuteEvent_Result{Result: &toolspb.ExecuteResult{Stdout: req.Command + " " + req.Env["GREETING"]}}})
+}
+
+func newTestClient(t *testing.T) *Client {
+	lis := bufconn.Listen(1 << 20)
+	srv := grpc.NewServer()
+	toolspb.RegisterToolsServer(srv, fakeTools{})
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := &Client{conn: conn, tools: toolspb.NewToolsClient(conn)}
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+// TestReadFileForwardsHeaders — токен и X-Request-ID из заголовков доходят до
+// сервера метаданными; ошибка tools-service становится ответом apierror.
+func TestReadFileForwardsHeaders(t *testing.T) {
+	c := newTestClient(t)
+	header := http.Header{}
+	header.Set("Authorization", "Bearer secret")
+	header.Set("X-Request-ID", "req-1")
+	content, err := c.ReadFile(context.Background(), "a.txt", header)
+	if err != nil || content != "content of a.txt" {
+		t.Fatalf("содержимое %q, ошибка %v", content, err)
+	}
+
+	_, err = c.ReadFile(context.Background(), "missing.txt", header)
+	code, body, ok := HTTPError(err)
+	var resp apierror.Response
+	json.Unmarshal(body, &resp)
+	if !ok || code != http.StatusNotFound || resp.Code != "NOT_FOUND" || resp.RequestID != "req-1" {
+		t.Errorf("ошибка tools-service: %d %s %v", code, body, ok)
+	}
+
+	// Ошибка без ErrorInfo tools-service — сбой транспорта
+	_, err = c.ReadFile(context.Background(), "a.txt", http.Header{})
+	if _, _, ok := HTTPError(err); err == nil || ok {
+		t.Errorf("без токена: %v", err)
+	}
+}
+
+// TestExecuteStreamsOutput — фрагменты вывода приходят до результата,
+// переменные окружения доходят до сервера.
+func TestExecuteStreamsOutput(t *testing.T) {
+	var out string
+	res, err := newTestClient(t).Execute(context.Background(), "ls", map[string]string{"GREETING": "hi"}, http.Header{}, func(stream string, data []byte) {
+		out += stream + ":" + string(data)
+	})
+	if err != nil || res.Stdout != "ls hi" || out != "stdout:a\nstdout:b\n" {
+		t.Fatalf("итог %+v, вывод %q, ошибка %v", res, out, err)
+	}
+}
@@ -0,0 +1,133 @@
+// Package tracing — распределённая трассировка agent-service на OpenTelemetry.
+//
+// Входящий заголовок traceparent (W3C Trace Context) от api-gateway продолжает
+// трассировку, вызовы tools-service и browser-service получают traceparent
+// текущего спана. Спаны экспортируются в OTLP/HTTP (Jaeger, Tempo,
+// OpenTelemetry Collector), если задан OTEL_EXPORTER_OTLP_ENDPOINT; без него
+// спаны не записываются, но заголовки передаются дальше по цепочке.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracerName — имя инструментирующей библиотеки в экспортируемых спанах.
+const tracerName = "agent-service"
+
+// Init — настраивает W3C-пропагацию и, если задан OTEL_EXPORTER_OTLP_ENDPOINT
+// (или OTEL_EXPORTER_OTLP_TRACES_ENDPOINT), экспорт спанов в OTLP/HTTP.
+// Остальные параметры экспортера и семплера читаются SDK из стандартных
+// переменных OTEL_*. Возвращает функцию остановки с досылкой накопленных спанов.
+func Init(ctx context.Context, serviceName string) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{}, propagation.Baggage{},
+	))
+
+	if os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") == "" && os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT") == "" {
+		return func(context.Context) error { return nil }, nil
+	}
+	if v := os.Getenv("OTEL_SERVICE_NAME"); v != "" {
+		serviceName = v
+	}
+
+	exporter, err := otlptracehttp.New(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("создание OTLP-экспортера: %w", err)
+	}
+	res, err := resource.Merge(resource.Default(), resource.NewSchemaless(
+		attribute.String("service.name", serviceName),
+	))
+	if err != nil {
+		return nil, fmt.Errorf("описание ресурса трассировки: %w", err)
+	}
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+	)
+	otel.SetTracerProvider(tp)
+	slog.Info("Экспорт трассировки OTLP включён", slog.String("сервис", serviceName))
+	return tp.Shutdown, nil
+}
+
+// Start — открывает внутренний спан, дочерний к спану из ctx.
+func Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(tracerName).Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// StartClient — открывает клиентский спан исходящего вызова другого сервиса.
+func StartClient(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(tracerName).Start(ctx, name,
+		trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(attrs...))
+}
+
+// Inject — записывает traceparent текущего спана в заголовки исходящего запроса.
+func Inject(ctx context.Context, h http.Header) {
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(h))
+}
+
+// RecordError — помечает спан ошибкой.
+func RecordError(span trace.Span, err error) {
+	if err == nil {
+		return
+	}
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
+
+// statusRecorder — запоминает HTTP-статус ответа для атрибутов спана.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(code int) {
+	r.status = code
+	r.ResponseWriter.WriteHeader(code)
+}
+
+// Unwrap — даёт http.ResponseController доступ к исходному writer.
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+// Middleware — открывает серверный спан на каждый входящий запрос,
+// продолжая трассировку из заголовка traceparent.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+		ctx, span := otel.Tracer(tracerName).Start(ctx, r.Method+" "+r.URL.Path,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				attribute.String("http.request.method", r.Method),
+				attribute.String("url.path", r.URL.Path),
+			))
+		defer span.End()
+		if rid := r.Header.Get("X-Request-ID"); rid != "" {
+			span.SetAttributes(attribute.String("request_id", rid))
+		}
+		if sc := span.SpanContext(); sc.HasTraceID() {
+			w.Header().Set("X-Trace-ID", sc.TraceID().String())
+		}
+
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r.WithContext(ctx))
+
+		span.SetAttributes(attribute.Int("http.response.status_code", rec.status))
+		if rec.status >= 500 {
+			span.SetStatus(codes.Error, http.StatusText(rec.status))
+		}
+	})
+}
@@ -0,0 +1,57 @@
+package tracing
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// TestMiddleware_PropagatesTraceparent — входящая трассировка продолжается
+// в спанах сервиса и передаётся в исходящие вызовы инструментов.
+func TestMiddleware_PropagatesTraceparent(t *testing.T) {
+	if _, err := Init(context.Background(), "test"); err != nil {
+		t.Fatal(err)
+	}
+	rec := tracetest.NewSpanRecorder()
+	prev := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(rec)))
+	defer otel.SetTracerProvider(prev)
+
+	const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	var outgoing string
+	h := Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, span := StartClient(r.Context(), "tool.call execute")
+		defer span.End()
+		out := http.Header{}
+		Inject(ctx, out)
+		outgoing = out.Get("traceparent")
+	}))
+	req := httptest.NewRequest("POST", "/chat", nil)
+	req.Header.Set("traceparent", "00-"+traceID+"-00f067aa0ba902b7-01")
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+
+	if len(outgoing) != 55 || outgoing[3:35] != traceID {
+		t.Fatalf("исходящий traceparent должен продолжать трассировку, получен %q", outgoing)
+	}
+	if rr.Header().Get("X-Trace-ID") != traceID {
+		t.Errorf("ожидался X-Trace-ID %s, получен %q", traceID, rr.Header().Get("X-Trace-ID"))
+	}
+
+	spans := rec.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("ожидалось 2 спана (сервер и вызов инструмента), получено %d", len(spans))
+	}
+	client, server := spans[0], spans[1]
+	if server.Parent().SpanID().String() != "00f067aa0ba902b7" {
+		t.Errorf("серверный спан должен быть дочерним к спану шлюза, родитель %s", server.Parent().SpanID())
+	}
+	if client.Parent().SpanID() != server.SpanContext().SpanID() {
+		t.Error("спан вызова инструмента должен быть дочерним к серверному спану")
+	}
+}
@@ -0,0 +1,157 @@
+// Package uploads — раздача загруженных файлов (/uploads/) без лишнего.
+//
+// http.FileServer отдаёт всё, что лежит в каталоге, включая списки файлов
+// в директориях и HTML, который браузер выполнит в origin сервиса. Handler
+// отдаёт только файлы из разрешённых подкаталогов (UPLOADS_PUBLIC_PATHS),
+// не показывает содержимое директорий, скрытые файлы и символические ссылки
+// за пределы каталога, а тип содержимого выбирает сам по расширению:
+// для просмотра в браузере (inline) — только растровые изображения и PDF,
+// всё остальное — скачивание (attachment) как application/octet-stream.
+package uploads
+
+import (
+	"errors"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// inlineTypes — типы, безопасные для показа в браузере. SVG сюда не входит:
+// в нём может быть скрипт.
+var inlineTypes = map[string]string{
+	".png":  "image/png",
+	".jpg":  "image/jpeg",
+	".jpeg": "image/jpeg",
+	".gif":  "image/gif",
+	".webp": "image/webp",
+	".pdf":  "application/pdf",
+}
+
+// Inline — можно ли показать содержимое mimeType в браузере.
+func Inline(mimeType string) bool {
+	mt, _, _ := mime.ParseMediaType(mimeType)
+	for _, t := range inlineTypes {
+		if mt == t {
+			return true
+		}
+	}
+	return false
+}
+
+// SetHeaders — заголовки ответа с файлом name: тип содержимого, inline или
+// attachment и запрет на выполнение содержимого. inline для типов вне
+// Inline не действует.
+func SetHeaders(w http.ResponseWriter, name, mimeType string, inline bool) {
+	disposition := "attachment"
+	if inline && Inline(mimeType) {
+		disposition = "inline"
+	}
+	if mimeType == "" {
+		mimeType = "application/octet-stream"
+	}
+	h := w.Header()
+	h.Set("Content-Type", mimeType)
+	h.Set("Content-Disposition", mime.FormatMediaType(disposition, map[string]string{"filename": name}))
+	h.Set("X-Content-Type-Options", "nosniff")
+	h.Set("Content-Security-Policy", "default-src 'none'; style-src 'unsafe-inline'; sandbox")
+}
+
+// ParsePaths — разрешённые подкаталоги через запятую ("avatars/,public/").
+// Завершающий слэш добавляется, ".." и абсолютные пути — ошибка.
+func ParsePaths(s string) ([]string, error) {
+	var out []string
+	for _, p := range strings.Split(s, ",") {
+		p = strings.Trim(strings.TrimSpace(p), "/")
+		if p == "" {
+			continue
+		}
+		if path.Clean(p) != p || p == "." || p == ".." || strings.HasPrefix(p, "../") {
+			return nil, errors.New("путь " + p + ": ожидается подкаталог без .. и лишних слэшей")
+		}
+		out = append(out, p+"/")
+	}
+	return out, nil
+}
+
+// Handler — файлы каталога dir из подкаталогов allowed (результат ParsePaths).
+// Путь запроса — относительно dir (обработчик ставится за http.StripPrefix).
+// Всё, что не разрешено, — 404, чтобы не раскрывать, что файл существует.
+func Handler(dir string, allowed []string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+		name, ok := allowedPath(r.URL.Path, allowed)
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		f, info, err := open(dir, name)
+		if err != nil {
+			http.NotFound(w, r)
+			return
+		}
+		defer f.Close()
+		ext := strings.ToLower(filepath.Ext(name))
+		mimeType, inline := inlineTypes[ext]
+		if !inline {
+			mimeType = "application/octet-stream"
+		}
+		SetHeaders(w, path.Base(name), mimeType, inline)
+		http.ServeContent(w, r, "", info.ModTime(), f)
+	})
+}
+
+// allowedPath — очищенный относительный путь, если он в разрешённом
+// подкаталоге и ни один его элемент не скрытый.
+func allowedPath(p string, allowed []string) (string, bool) {
+	if strings.Contains(p, "\\") || strings.Contains(p, "\x00") {
+		return "", false
+	}
+	name := strings.TrimPrefix(path.Clean("/"+p), "/")
+	if name == "" || strings.HasSuffix(p, "/") {
+		return "", false
+	}
+	for _, part := range strings.Split(name, "/") {
+		if strings.HasPrefix(part, ".") {
+			return "", false
+		}
+	}
+	for _, prefix := range allowed {
+		if strings.HasPrefix(name, prefix) {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// open — обычный файл name внутри dir; символические ссылки, ведущие за
+// пределы dir, и директории не открываются.
+func open(dir, name string) (*os.File, os.FileInfo, error) {
+	root, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return nil, nil, err
+	}
+	real, err := filepath.EvalSymlinks(filepath.Join(root, filepath.FromSlash(name)))
+	if err != nil {
+		return nil, nil, err
+	}
+	if rel, err := filepath.Rel(root, real); err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return nil, nil, os.ErrNotExist
+	}
+	f, err := os.Open(real)
+	if err != nil {
+		return nil, nil, err
+	}
+	info, err := f.Stat()
+	if err != nil || !info.Mode().IsRegular() {
+		f.Close()
+		return nil, nil, os.ErrNotExist
+	}
+	return f, info, nil
+}
@@ -0,0 +1,88 @@
+package uploads
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestHandler — отдаются только файлы разрешённых подкаталогов, без списков
+// директорий, скрытых файлов и ссылок за пределы каталога.
+func TestHandler(t *testing.T) {
+	dir := t.TempDir()
+	outside := t.TempDir()
+	write := func(path, data string) {
+		t.Helper()
+		full := filepath.Join(dir, filepath.FromSlash(path))
+		if err := os.MkdirAll(filepath.Dir(full), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(full, []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("avatars/a_256.webp", "webp")
+	write("avatars/page.html", "<script>alert(1)</script>")
+	write("avatars/.secret", "x")
+	write("docs/private.txt", "secret")
+	if err := os.WriteFile(filepath.Join(outside, "passwd"), []byte("root"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(outside, "passwd"), filepath.Join(dir, "avatars", "link.webp")); err != nil {
+		t.Fatal(err)
+	}
+
+	allowed, err := ParsePaths("avatars")
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := Handler(dir, allowed)
+	get := func(path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.URL.Path = path
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := get("avatars/a_256.webp")
+	if rec.Code != http.StatusOK || rec.Body.String() != "webp" {
+		t.Fatalf("аватар: %d %q", rec.Code, rec.Body.String())
+	}
+	if rec.Header().Get("Content-Type") != "image/webp" || rec.Header().Get("X-Content-Type-Options") != "nosniff" {
+		t.Errorf("заголовки аватара: %v", rec.Header())
+	}
+	if cd := rec.Header().Get("Content-Disposition"); cd != `inline; filename=a_256.webp` {
+		t.Errorf("Content-Disposition аватара: %q", cd)
+	}
+
+	rec = get("avatars/page.html")
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "application/octet-stream" ||
+		rec.Header().Get("Content-Disposition") != `attachment; filename=page.html` {
+		t.Errorf("HTML должен отдаваться как вложение: %d %v", rec.Code, rec.Header())
+	}
+
+	for _, path := range []string{"avatars/", "avatars", "docs/private.txt", "avatars/.secret", "avatars/../docs/private.txt", "avatars/link.webp", ""} {
+		if rec := get(path); rec.Code != http.StatusNotFound {
+			t.Errorf("%q: ожидался 404, получено %d", path, rec.Code)
+		}
+	}
+}
+
+// TestParsePaths — разрешённые подкаталоги и отказ от выхода за каталог.
+func TestParsePaths(t *testing.T) {
+	got, err := ParsePaths(" avatars/, public ,")
+	if err != nil || len(got) != 2 || got[0] != "avatars/" || got[1] != "public/" {
+		t.Fatalf("ParsePaths: %v %v", got, err)
+	}
+	for _, bad := range []string{"../etc", "a/../../b", ".", "a//b"} {
+		if _, err := ParsePaths(bad); err == nil {
+			t.Errorf("%q: ожидалась ошибка", bad)
+		}
+	}
+	if !Inline("image/png") || Inline("image/svg+xml") || Inline("text/html; charset=utf-8") {
+		t.Error("Inline")
+	}
+}
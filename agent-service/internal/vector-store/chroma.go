@@ -12,6 +12,8 @@ import (
 	"io"
 	"net/http"
 	"os"
+
+	"github.com/neo-2022/openclaw-memory/agent-service/internal/httpclient"
 )
 
 // ChromaStore — клиент для взаимодействия с ChromaDB.
@@ -89,7 +91,7 @@ func (c *ChromaStore) AddDocuments(docs []map[string]interface{}) error {
 	}
 	req.Header.Set("Content-Type", "application/json")
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := httpclient.New("chroma", 0).Do(req)
 	if err != nil {
 		return fmt.Errorf("ошибка HTTP-запроса к ChromaDB: %w", err)
 	}
@@ -130,7 +132,7 @@ func (c *ChromaStore) Search(query string, n int) ([]map[string]interface{}, err
 	}
 	req.Header.Set("Content-Type", "application/json")
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := httpclient.New("chroma", 0).Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("ошибка HTTP-запроса к ChromaDB: %w", err)
 	}
@@ -0,0 +1,137 @@
+// Package vision — подготовка изображений из чата для LLM.
+//
+// Изображения приходят в /chat в base64 (или data URL) либо ссылкой на файл
+// из каталога загрузок. Мультимодальные модели (gpt-4o, llava и др., см.
+// llm.SupportsVision) получают их как есть. Для текстовых моделей каждое
+// изображение заменяется текстом: распознанным текстом (OCR через tesseract,
+// если установлен) и описанием от резервной мультимодальной модели.
+package vision
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/neo-2022/openclaw-memory/agent-service/internal/llm"
+)
+
+// ErrNotImage — данные не являются изображением.
+var ErrNotImage = errors.New("вложение не является изображением")
+
+// ErrNoOCR — tesseract не установлен.
+var ErrNoOCR = errors.New("tesseract не установлен")
+
+// DescribePrompt — запрос к резервной модели для описания изображения.
+const DescribePrompt = "Опиши изображение для ассистента, который его не видит: что на нём изображено, " +
+	"ключевые детали, а также дословно весь видимый текст (ошибки, команды, значения). Отвечай кратко."
+
+// Normalize — base64 изображения без префикса data URL; проверяет, что это изображение.
+func Normalize(s string) (string, error) {
+	s = strings.TrimSpace(s)
+	if strings.HasPrefix(s, "data:") {
+		idx := strings.Index(s, ";base64,")
+		if idx < 0 {
+			return "", fmt.Errorf("data URL без base64")
+		}
+		s = s[idx+len(";base64,"):]
+	}
+	data, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return "", fmt.Errorf("некорректный base64: %w", err)
+	}
+	if !strings.HasPrefix(http.DetectContentType(data), "image/") {
+		return "", ErrNotImage
+	}
+	return s, nil
+}
+
+// LoadFile — изображение из каталога загрузок по имени файла в base64.
+// Путь внутри имени игнорируется, чтобы нельзя было прочитать файл вне dir.
+func LoadFile(dir, name string) (string, error) {
+	base := filepath.Base(filepath.Clean("/" + name))
+	if base == "/" || base == "." {
+		return "", fmt.Errorf("пустое имя файла")
+	}
+	data, err := os.ReadFile(filepath.Join(dir, base))
+	if err != nil {
+		return "", err
+	}
+	if !strings.HasPrefix(http.DetectContentType(data), "image/") {
+		return "", fmt.Errorf("%s: %w", base, ErrNotImage)
+	}
+	return base64.StdEncoding.EncodeToString(data), nil
+}
+
+// Describer — замена изображения текстом для моделей без поддержки изображений.
+type Describer struct {
+	Provider llm.ChatProvider                  // Резервная мультимодальная модель (nil — без описания)
+	Model    string                            // Имя резервной модели
+	OCR      func(data []byte) (string, error) // Распознавание текста (nil — без OCR)
+}
+
+// Text — текстовое представление изображения: OCR и описание.
+func (d Describer) Text(b64 string) string {
+	var parts []string
+	if d.OCR != nil {
+		if data, err := base64.StdEncoding.DecodeString(b64); err == nil {
+			if text, err := d.OCR(data); err == nil && strings.TrimSpace(text) != "" {
+				parts = append(parts, "Текст на изображении:\n"+strings.TrimSpace(text))
+			}
+		}
+	}
+	if d.Provider != nil && d.Model != "" {
+		resp, err := d.Provider.Chat(&llm.ChatRequest{
+			Model:    d.Model,
+			Messages: []llm.Message{{Role: "user", Content: DescribePrompt, Images: []string{b64}}},
+		})
+		if err == nil && strings.TrimSpace(resp.Content) != "" {
+			parts = append(parts, "Описание: "+strings.TrimSpace(resp.Content))
+		}
+	}
+	if len(parts) == 0 {
+		return "не удалось распознать (модель не поддерживает изображения)"
+	}
+	return strings.Join(parts, "\n")
+}
+
+// ReplaceImages — копия сообщений, в которой изображения заменены текстом describe.
+func ReplaceImages(msgs []llm.Message, describe func(b64 string) string) []llm.Message {
+	out := make([]llm.Message, len(msgs))
+	n := 0
+	for i, m := range msgs {
+		out[i] = m
+		if len(m.Images) == 0 {
+			continue
+		}
+		var b strings.Builder
+		b.WriteString(m.Content)
+		for _, img := range m.Images {
+			n++
+			fmt.Fprintf(&b, "\n\n[Изображение %d]\n%s", n, describe(img))
+		}
+		out[i].Content = b.String()
+		out[i].Images = nil
+	}
+	return out
+}
+
+// TesseractOCR — распознавание текста на изображении (русский и английский).
+func TesseractOCR(data []byte) (string, error) {
+	bin, err := exec.LookPath("tesseract")
+	if err != nil {
+		return "", ErrNoOCR
+	}
+	cmd := exec.Command(bin, "stdin", "stdout", "-l", "rus+eng")
+	cmd.Stdin = bytes.NewReader(data)
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("tesseract: %w", err)
+	}
+	return string(out), nil
+}
@@ -0,0 +1,85 @@
+package vision
+
+import (
+	"encoding/base64"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/neo-2022/openclaw-memory/agent-service/internal/llm"
+)
+
+// pngHeader — минимальная сигнатура PNG, достаточная для определения типа.
+var pngHeader = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+
+// TestNormalize — data URL и «голый» base64 приводятся к base64, не-изображения отклоняются.
+func TestNormalize(t *testing.T) {
+	b64 := base64.StdEncoding.EncodeToString(pngHeader)
+	for _, in := range []string{b64, "data:image/png;base64," + b64} {
+		got, err := Normalize(in)
+		if err != nil || got != b64 {
+			t.Errorf("Normalize(%q...) = %q, %v", in[:10], got, err)
+		}
+	}
+	if _, err := Normalize(base64.StdEncoding.EncodeToString([]byte("просто текст"))); !errors.Is(err, ErrNotImage) {
+		t.Errorf("текст должен отклоняться, получено %v", err)
+	}
+	if _, err := Normalize("не base64!"); err == nil {
+		t.Error("ожидалась ошибка base64")
+	}
+}
+
+// TestLoadFile — файл читается только из каталога загрузок.
+func TestLoadFile(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "shot.png"), pngHeader, 0644); err != nil {
+		t.Fatal(err)
+	}
+	got, err := LoadFile(dir, "../../"+filepath.Base(dir)+"/shot.png")
+	if err != nil || got != base64.StdEncoding.EncodeToString(pngHeader) {
+		t.Fatalf("LoadFile: %q, %v", got, err)
+	}
+}
+
+type stubProvider struct {
+	llm.ChatProvider
+	got *llm.ChatRequest
+}
+
+func (p *stubProvider) Chat(req *llm.ChatRequest) (*llm.ChatResponse, error) {
+	p.got = req
+	return &llm.ChatResponse{Content: "скриншот терминала"}, nil
+}
+
+// TestReplaceImages — для текстовой модели изображения заменяются OCR и описанием.
+func TestReplaceImages(t *testing.T) {
+	stub := &stubProvider{}
+	d := Describer{
+		Provider: stub,
+		Model:    "llava:7b",
+		OCR:      func([]byte) (string, error) { return "permission denied", nil },
+	}
+	b64 := base64.StdEncoding.EncodeToString(pngHeader)
+	msgs := []llm.Message{
+		{Role: "system", Content: "промпт"},
+		{Role: "user", Content: "Что за ошибка?", Images: []string{b64}},
+	}
+	out := ReplaceImages(msgs, d.Text)
+
+	if len(out[1].Images) != 0 || len(msgs[1].Images) != 1 {
+		t.Fatal("изображения должны удаляться только из копии")
+	}
+	for _, want := range []string{"Что за ошибка?", "[Изображение 1]", "permission denied", "скриншот терминала"} {
+		if !strings.Contains(out[1].Content, want) {
+			t.Errorf("в сообщении нет %q: %s", want, out[1].Content)
+		}
+	}
+	if stub.got == nil || stub.got.Model != "llava:7b" || len(stub.got.Messages[0].Images) != 1 {
+		t.Errorf("резервная модель должна получить изображение: %+v", stub.got)
+	}
+	if text := (Describer{}).Text(b64); !strings.Contains(text, "не удалось") {
+		t.Errorf("без OCR и модели ожидалось сообщение о неудаче, получено %q", text)
+	}
+}
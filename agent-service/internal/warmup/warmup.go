@@ -0,0 +1,269 @@
+// Package warmup — прогрев локальных моделей Ollama и удержание их в памяти.
+//
+// При старте модели, назначенные агентам, загружаются в память заранее,
+// чтобы первый запрос в чат не ждал холодной загрузки (секунды — десятки
+// секунд для больших моделей). Время удержания (keep_alive) передаётся с
+// каждым запросом и зависит от частоты использования: активные модели
+// держатся дольше, редкие — выгружаются и освобождают VRAM.
+package warmup
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/neo-2022/openclaw-memory/agent-service/internal/llm"
+)
+
+// Config — политика прогрева и удержания.
+type Config struct {
+	Enabled         bool          // Прогревать модели агентов при старте
+	KeepAlive       time.Duration // Удержание после запроса для редко используемых моделей
+	ActiveKeepAlive time.Duration // Удержание для активных и назначенных агентам моделей (<0 — всегда)
+	ActiveRequests  int           // Сколько запросов за Window делают модель активной
+	Window          time.Duration // Окно подсчёта запросов
+}
+
+// LoadConfig — читает политику из переменных окружения:
+// OLLAMA_WARMUP (true), OLLAMA_KEEP_ALIVE (30m), OLLAMA_KEEP_ALIVE_ACTIVE (2h,
+// -1 — не выгружать), OLLAMA_ACTIVE_REQUESTS (3 запроса за час).
+func LoadConfig() Config {
+	cfg := Config{
+		Enabled:         os.Getenv("OLLAMA_WARMUP") != "false",
+		KeepAlive:       30 * time.Minute,
+		ActiveKeepAlive: 2 * time.Hour,
+		ActiveRequests:  3,
+		Window:          time.Hour,
+	}
+	if v, err := time.ParseDuration(os.Getenv("OLLAMA_KEEP_ALIVE")); err == nil && v >= 0 {
+		cfg.KeepAlive = v
+	}
+	if v := os.Getenv("OLLAMA_KEEP_ALIVE_ACTIVE"); v == "-1" {
+		cfg.ActiveKeepAlive = -1
+	} else if d, err := time.ParseDuration(v); err == nil && d > 0 {
+		cfg.ActiveKeepAlive = d
+	}
+	if v, err := strconv.Atoi(os.Getenv("OLLAMA_ACTIVE_REQUESTS")); err == nil && v > 0 {
+		cfg.ActiveRequests = v
+	}
+	return cfg
+}
+
+// Ollama — операции Ollama, нужные менеджеру (реализует *llm.OllamaProvider).
+type Ollama interface {
+	Load(ctx context.Context, model, keepAlive string) error
+	Running(ctx context.Context) ([]llm.RunningModel, error)
+}
+
+// usage — история запросов к модели.
+type usage struct {
+	recent []time.Time // Запросы в пределах окна
+	total  int
+	last   time.Time
+}
+
+// Manager — учёт использования моделей и выбор keep_alive.
+type Manager struct {
+	cfg Config
+	now func() time.Time
+
+	mu     sync.Mutex
+	usage  map[string]*usage
+	pinned map[string]bool // Модели, назначенные агентам
+}
+
+// New — менеджер с политикой cfg.
+func New(cfg Config) *Manager {
+	return &Manager{cfg: cfg, now: time.Now, usage: map[string]*usage{}, pinned: map[string]bool{}}
+}
+
+// SetPinned — модели, назначенные агентам (заменяет прежний набор).
+func (m *Manager) SetPinned(models []string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.pinned = make(map[string]bool, len(models))
+	for _, name := range models {
+		if name != "" {
+			m.pinned[canonical(name)] = true
+		}
+	}
+}
+
+// Touch — учитывает запрос к модели.
+func (m *Manager) Touch(model string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	model = canonical(model)
+	u := m.usage[model]
+	if u == nil {
+		u = &usage{}
+		m.usage[model] = u
+	}
+	now := m.now()
+	u.recent = append(m.trim(u.recent, now), now)
+	u.total++
+	u.last = now
+}
+
+// trim — отбрасывает запросы за пределами окна.
+func (m *Manager) trim(times []time.Time, now time.Time) []time.Time {
+	cutoff := now.Add(-m.cfg.Window)
+	i := 0
+	for i < len(times) && times[i].Before(cutoff) {
+		i++
+	}
+	return times[i:]
+}
+
+// active — модель назначена агенту или часто используется (m.mu захвачен).
+func (m *Manager) active(model string) bool {
+	if m.pinned[model] {
+		return true
+	}
+	u := m.usage[model]
+	return u != nil && len(m.trim(u.recent, m.now())) >= m.cfg.ActiveRequests
+}
+
+// KeepAlive — значение keep_alive для очередного запроса к модели.
+func (m *Manager) KeepAlive(model string) string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.active(canonical(model)) {
+		return formatKeepAlive(m.cfg.ActiveKeepAlive)
+	}
+	return formatKeepAlive(m.cfg.KeepAlive)
+}
+
+// canonical — имя модели в том виде, как его возвращает /api/ps: без тега
+// Ollama подразумевает :latest.
+func canonical(name string) string {
+	if name != "" && !strings.Contains(name, ":") {
+		return name + ":latest"
+	}
+	return name
+}
+
+// formatKeepAlive — длительность в формате Ollama: "-1" — бессрочно, "30m", "90s".
+func formatKeepAlive(d time.Duration) string {
+	switch {
+	case d < 0:
+		return "-1"
+	case d%time.Minute == 0:
+		return strconv.Itoa(int(d/time.Minute)) + "m"
+	}
+	return strconv.Itoa(int(d/time.Second)) + "s"
+}
+
+// Result — итог прогрева одной модели.
+type Result struct {
+	Model    string `json:"model"`
+	Duration int64  `json:"duration_ms"`
+	Skipped  bool   `json:"skipped,omitempty"` // Уже была в памяти
+	Error    string `json:"error,omitempty"`
+}
+
+// Warm — последовательно загружает модели в память с keep_alive активных
+// моделей. Уже загруженные пропускаются; модели грузятся по одной, чтобы не
+// вытеснять друг друга из VRAM параллельной загрузкой.
+func (m *Manager) Warm(ctx context.Context, ol Ollama, models []string) []Result {
+	loaded := map[string]bool{}
+	if running, err := ol.Running(ctx); err == nil {
+		for _, r := range running {
+			loaded[r.Name] = true
+		}
+	}
+	var results []Result
+	seen := map[string]bool{}
+	for _, name := range models {
+		name = canonical(name)
+		if name == "" || seen[name] {
+			continue
+		}
+		seen[name] = true
+		if loaded[name] {
+			results = append(results, Result{Model: name, Skipped: true})
+			continue
+		}
+		start := m.now()
+		err := ol.Load(ctx, name, m.KeepAlive(name))
+		took := m.now().Sub(start)
+		res := Result{Model: name, Duration: took.Milliseconds()}
+		if err != nil {
+			res.Error = err.Error()
+			slog.Warn("Не удалось прогреть модель", slog.String("модель", name), slog.String("ошибка", err.Error()))
+		} else {
+			slog.Info("Модель прогрета", slog.String("модель", name), slog.Duration("время", took))
+		}
+		results = append(results, res)
+	}
+	return results
+}
+
+// Loaded — модель в памяти Ollama вместе со статистикой использования.
+type Loaded struct {
+	llm.RunningModel
+	Pinned    bool       `json:"pinned"`
+	Requests  int        `json:"requests_last_window"`
+	Total     int        `json:"requests_total"`
+	LastUsed  *time.Time `json:"last_used,omitempty"`
+	KeepAlive string     `json:"keep_alive"`
+}
+
+// Report — ответ /models/loaded.
+type Report struct {
+	Models        []Loaded `json:"models"`
+	TotalVRAM     int64    `json:"total_vram"`
+	TotalVRAMText string   `json:"total_vram_human"`
+	Window        string   `json:"window"`
+	NotLoaded     []string `json:"pinned_not_loaded"` // Модели агентов, которых нет в памяти
+}
+
+// Report — загруженные модели (по убыванию VRAM) со статистикой использования.
+func (m *Manager) Report(ctx context.Context, ol Ollama) (Report, error) {
+	running, err := ol.Running(ctx)
+	if err != nil {
+		return Report{}, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	rep := Report{Models: []Loaded{}, NotLoaded: []string{}, Window: m.cfg.Window.String()}
+	inMemory := map[string]bool{}
+	for _, r := range running {
+		inMemory[r.Name] = true
+		l := Loaded{RunningModel: r, Pinned: m.pinned[r.Name], KeepAlive: formatKeepAlive(m.cfg.KeepAlive)}
+		if m.active(r.Name) {
+			l.KeepAlive = formatKeepAlive(m.cfg.ActiveKeepAlive)
+		}
+		if u := m.usage[r.Name]; u != nil {
+			l.Requests = len(m.trim(u.recent, m.now()))
+			l.Total = u.total
+			last := u.last
+			l.LastUsed = &last
+		}
+		rep.TotalVRAM += r.SizeVRAM
+		rep.Models = append(rep.Models, l)
+	}
+	sort.Slice(rep.Models, func(i, j int) bool { return rep.Models[i].SizeVRAM > rep.Models[j].SizeVRAM })
+	for name := range m.pinned {
+		if !inMemory[name] {
+			rep.NotLoaded = append(rep.NotLoaded, name)
+		}
+	}
+	sort.Strings(rep.NotLoaded)
+	rep.TotalVRAMText = humanBytes(rep.TotalVRAM)
+	return rep, nil
+}
+
+func humanBytes(n int64) string {
+	const gb = 1 << 30
+	if n >= gb {
+		return fmt.Sprintf("%.1f GB", float64(n)/gb)
+	}
+	return fmt.Sprintf("%d MB", n>>20)
+}
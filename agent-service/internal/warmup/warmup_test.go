@@ -0,0 +1,76 @@
+package warmup
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/neo-2022/openclaw-memory/agent-service/internal/llm"
+)
+
+// fakeOllama — запоминает загрузки, Running возвращает уже загруженные.
+type fakeOllama struct {
+	running []llm.RunningModel
+	loads   []string
+}
+
+func (f *fakeOllama) Load(_ context.Context, model, keepAlive string) error {
+	f.loads = append(f.loads, model+"@"+keepAlive)
+	f.running = append(f.running, llm.RunningModel{Name: model, Size: 4 << 30, SizeVRAM: 4 << 30})
+	return nil
+}
+
+func (f *fakeOllama) Running(context.Context) ([]llm.RunningModel, error) {
+	return f.running, nil
+}
+
+// TestKeepAlive — назначенные и часто используемые модели держатся дольше.
+func TestKeepAlive(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	m := New(Config{KeepAlive: 5 * time.Minute, ActiveKeepAlive: -1, ActiveRequests: 2, Window: time.Hour})
+	m.now = func() time.Time { return now }
+	m.SetPinned([]string{"qwen2.5"})
+
+	if got := m.KeepAlive("qwen2.5:latest"); got != "-1" {
+		t.Errorf("назначенная модель: %q", got)
+	}
+	if got := m.KeepAlive("llama3.1:8b"); got != "5m" {
+		t.Errorf("редкая модель: %q", got)
+	}
+	m.Touch("llama3.1:8b")
+	m.Touch("llama3.1:8b")
+	if got := m.KeepAlive("llama3.1:8b"); got != "-1" {
+		t.Errorf("активная модель: %q", got)
+	}
+	now = now.Add(2 * time.Hour)
+	if got := m.KeepAlive("llama3.1:8b"); got != "5m" {
+		t.Errorf("после окна модель снова редкая: %q", got)
+	}
+	if got := formatKeepAlive(90 * time.Second); got != "90s" {
+		t.Errorf("formatKeepAlive: %q", got)
+	}
+}
+
+// TestWarmAndReport — прогрев пропускает загруженные модели, отчёт считает VRAM.
+func TestWarmAndReport(t *testing.T) {
+	m := New(Config{KeepAlive: 30 * time.Minute, ActiveKeepAlive: 2 * time.Hour, ActiveRequests: 3, Window: time.Hour})
+	m.SetPinned([]string{"qwen2.5:7b", "mistral"})
+	ol := &fakeOllama{running: []llm.RunningModel{{Name: "mistral:latest", Size: 2 << 30, SizeVRAM: 1 << 30}}}
+
+	res := m.Warm(context.Background(), ol, []string{"qwen2.5:7b", "mistral", "qwen2.5:7b"})
+	if len(res) != 2 || !res[1].Skipped || len(ol.loads) != 1 || ol.loads[0] != "qwen2.5:7b@120m" {
+		t.Fatalf("прогрев: %+v, загрузки %v", res, ol.loads)
+	}
+
+	m.Touch("qwen2.5:7b")
+	rep, err := m.Report(context.Background(), ol)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rep.Models) != 2 || rep.Models[0].Name != "qwen2.5:7b" || rep.Models[0].Total != 1 || !rep.Models[0].Pinned {
+		t.Errorf("модели: %+v", rep.Models)
+	}
+	if rep.TotalVRAM != 5<<30 || rep.TotalVRAMText != "5.0 GB" || len(rep.NotLoaded) != 0 {
+		t.Errorf("отчёт: %+v", rep)
+	}
+}
@@ -0,0 +1,393 @@
+// Package watchdog — сторож микросервисов: периодическая проверка здоровья и
+// перезапуск упавшего сервиса (самовосстановление).
+//
+// Сервис считается упавшим после Failures неудачных проверок подряд: одна
+// сетевая ошибка не повод его перезапускать. Тогда сторож собирает
+// диагностику (порт, процесс, журнал — как инструмент diagnose_service),
+// выполняет действие перезапуска (systemctl restart, docker restart или своя
+// команда) и записывает инцидент. Следующий перезапуск того же сервиса — не
+// раньше Cooldown, чтобы не перезапускать сервис, который падает сразу после
+// старта. Сервис без действия перезапуска только отслеживается: инцидент
+// записывается, но ничего не выполняется.
+package watchdog
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/neo-2022/openclaw-memory/agent-service/internal/httpclient"
+)
+
+// Действия перезапуска.
+const (
+	RestartNone    = "none"
+	RestartSystemd = "systemd"
+	RestartDocker  = "docker"
+	RestartCommand = "command"
+)
+
+// Итог инцидента.
+const (
+	ActionRestarted     = "restarted"      // Команда перезапуска выполнена
+	ActionRestartFailed = "restart_failed" // Команда перезапуска завершилась ошибкой
+	ActionMonitorOnly   = "monitor_only"   // Перезапуск не настроен
+)
+
+// Лимиты сторожа.
+const (
+	CheckTimeout = 5 * time.Second
+	MaxIncidents = 100
+)
+
+// disabledPoll — как часто проверять, не включили ли сторож (интервал 0).
+const disabledPoll = time.Minute
+
+// nameRe — имя сервиса, systemd-юнита или Docker-контейнера: подставляется в команды.
+var nameRe = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.@:-]{0,127}$`)
+
+// Service — отслеживаемый сервис.
+type Service struct {
+	Name      string `yaml:"name" json:"name"`
+	HealthURL string `yaml:"health_url" json:"health_url"`
+	Restart   string `yaml:"restart" json:"restart"`               // none (по умолчанию), systemd, docker, command
+	Unit      string `yaml:"unit" json:"unit,omitempty"`           // systemd-юнит (по умолчанию name)
+	Container string `yaml:"container" json:"container,omitempty"` // Docker-контейнер (по умолчанию name)
+	Command   string `yaml:"command" json:"command,omitempty"`     // Команда для restart: command
+}
+
+// Validate — проверяет сервис и подставляет значения по умолчанию.
+func (s *Service) Validate() error {
+	if s.Name == "" || s.HealthURL == "" {
+		return errors.New("name и health_url обязательны")
+	}
+	if !nameRe.MatchString(s.Name) {
+		return fmt.Errorf("некорректное имя сервиса %q", s.Name)
+	}
+	if s.Restart == "" {
+		s.Restart = RestartNone
+	}
+	switch s.Restart {
+	case RestartNone:
+	case RestartSystemd:
+		if s.Unit == "" {
+			s.Unit = s.Name
+		}
+		if !nameRe.MatchString(s.Unit) {
+			return fmt.Errorf("%s: некорректный unit %q", s.Name, s.Unit)
+		}
+	case RestartDocker:
+		if s.Container == "" {
+			s.Container = s.Name
+		}
+		if !nameRe.MatchString(s.Container) {
+			return fmt.Errorf("%s: некорректный container %q", s.Name, s.Container)
+		}
+	case RestartCommand:
+		if s.Command == "" {
+			return fmt.Errorf("%s: для restart: command нужна command", s.Name)
+		}
+	default:
+		return fmt.Errorf("%s: restart %q, ожидается none, systemd, docker или command", s.Name, s.Restart)
+	}
+	return nil
+}
+
+// RestartCommand — команда перезапуска; пусто — перезапуск не настроен.
+func (s Service) RestartCommand() string {
+	switch s.Restart {
+	case RestartSystemd:
+		return "systemctl restart " + s.Unit
+	case RestartDocker:
+		return "docker restart " + s.Container
+	case RestartCommand:
+		return s.Command
+	}
+	return ""
+}
+
+// LoadServices — сервисы из YAML-файла вида services: [{name, health_url, restart}].
+func LoadServices(path string) ([]Service, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var file struct {
+		Services []Service `yaml:"services"`
+	}
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("разбор %s: %w", path, err)
+	}
+	seen := map[string]bool{}
+	for i := range file.Services {
+		s := &file.Services[i]
+		if err := s.Validate(); err != nil {
+			return nil, fmt.Errorf("%s: сервис %d: %w", path, i+1, err)
+		}
+		if seen[s.Name] {
+			return nil, fmt.Errorf("%s: сервис %s описан дважды", path, s.Name)
+		}
+		seen[s.Name] = true
+	}
+	return file.Services, nil
+}
+
+// Config — политика перезапуска.
+type Config struct {
+	Failures int           // Неудачных проверок подряд до перезапуска
+	Cooldown time.Duration // Пауза между перезапусками одного сервиса
+}
+
+// Incident — падение сервиса и что с ним сделано.
+type Incident struct {
+	ID           int                    `json:"id"`
+	Service      string                 `json:"service"`
+	At           time.Time              `json:"at"`
+	Failures     int                    `json:"failures"` // Неудачных проверок подряд
+	Error        string                 `json:"error"`    // Последняя ошибка проверки
+	Action       string                 `json:"action"`   // restarted, restart_failed, monitor_only
+	Command      string                 `json:"command,omitempty"`
+	RestartError string                 `json:"restart_error,omitempty"`
+	Diagnosis    map[string]interface{} `json:"diagnosis,omitempty"`
+	RecoveredAt  *time.Time             `json:"recovered_at,omitempty"` // Когда сервис снова прошёл проверку
+}
+
+// Status — состояние сервиса для GET /watchdog.
+type Status struct {
+	Service
+	Healthy     bool       `json:"healthy"`
+	Failures    int        `json:"failures"`
+	LastCheck   *time.Time `json:"last_check,omitempty"`
+	LastError   string     `json:"last_error,omitempty"`
+	LastRestart *time.Time `json:"last_restart,omitempty"`
+	Restarts    int        `json:"restarts"`
+}
+
+// state — состояние сервиса между проверками.
+type state struct {
+	Status
+	open    int  // ID инцидента, который ждёт восстановления сервиса
+	pending bool // Инцидент оформляется: диагностика или перезапуск ещё идут
+}
+
+// Watchdog — сторож сервисов.
+type Watchdog struct {
+	// Check — проверка здоровья (по умолчанию HTTPCheck).
+	Check func(ctx context.Context, s Service) error
+	// Restart — выполнение команды перезапуска.
+	Restart func(ctx context.Context, s Service, command string) error
+	// Diagnose — диагностика упавшего сервиса для инцидента (может быть nil).
+	Diagnose func(ctx context.Context, s Service) map[string]interface{}
+	// OnIncident — вызывается для каждого нового инцидента и при восстановлении сервиса.
+	OnIncident func(Incident)
+
+	cfg func() Config
+	now func() time.Time
+
+	mu        sync.Mutex
+	states    map[string]*state
+	incidents []Incident
+	nextID    int
+}
+
+// New — сторож с политикой cfg (читается на каждой проверке).
+func New(cfg func() Config) *Watchdog {
+	return &Watchdog{Check: HTTPCheck, cfg: cfg, now: time.Now, states: map[string]*state{}}
+}
+
+// HTTPCheck — GET health_url: ответ 2xx за CheckTimeout.
+func HTTPCheck(ctx context.Context, s Service) error {
+	ctx, cancel := context.WithTimeout(ctx, CheckTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.HealthURL, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := httpclient.New("watchdog", CheckTimeout).Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// Tick — одна проверка всех сервисов (параллельно) и перезапуск упавших.
+func (w *Watchdog) Tick(ctx context.Context, services []Service) {
+	errs := make([]error, len(services))
+	var wg sync.WaitGroup
+	for i, s := range services {
+		wg.Add(1)
+		go func(i int, s Service) {
+			defer wg.Done()
+			errs[i] = w.Check(ctx, s)
+		}(i, s)
+	}
+	wg.Wait()
+	if ctx.Err() != nil {
+		return
+	}
+	for i, s := range services {
+		w.handle(ctx, s, errs[i])
+	}
+}
+
+// handle — учитывает результат проверки сервиса и при необходимости перезапускает его.
+func (w *Watchdog) handle(ctx context.Context, s Service, checkErr error) {
+	cfg := w.cfg()
+	now := w.now()
+	w.mu.Lock()
+	st := w.states[s.Name]
+	if st == nil {
+		st = &state{Status: Status{Healthy: true}}
+		w.states[s.Name] = st
+	}
+	st.Service = s
+	st.LastCheck = &now
+	if checkErr == nil {
+		st.Healthy, st.Failures, st.LastError = true, 0, ""
+		recovered, ok := w.recover(st, now)
+		w.mu.Unlock()
+		if ok {
+			slog.Info("Сервис восстановлен", slog.String("сервис", s.Name), slog.Int("инцидент", recovered.ID))
+			w.notify(recovered)
+		}
+		return
+	}
+	st.Healthy = false
+	st.Failures++
+	st.LastError = checkErr.Error()
+	failures := st.Failures
+	due := failures >= max(cfg.Failures, 1)
+	if s.RestartCommand() == "" {
+		// Без перезапуска — один инцидент на падение
+		due = due && st.open == 0
+	} else if st.LastRestart != nil && now.Sub(*st.LastRestart) < cfg.Cooldown {
+		due = false
+	}
+	// Параллельная проверка (Tick и POST /watchdog/check) не должна оформить
+	// второй инцидент и перезапустить сервис повторно, пока идёт первый:
+	// перезапуск учитывается до освобождения w.mu
+	due = due && !st.pending
+	restart := due && s.RestartCommand() != "" && w.Restart != nil
+	if due {
+		st.pending = true
+	}
+	if restart {
+		st.LastRestart = &now
+		st.Restarts++
+		st.Failures = 0 // Следующий перезапуск — снова после Failures проверок и Cooldown
+	}
+	w.mu.Unlock()
+	if !due {
+		slog.Warn("Сервис не прошёл проверку", slog.String("сервис", s.Name), slog.Int("подряд", failures), slog.String("ошибка", checkErr.Error()))
+		return
+	}
+
+	inc := Incident{Service: s.Name, At: now, Failures: failures, Error: checkErr.Error(), Action: ActionMonitorOnly}
+	if w.Diagnose != nil {
+		inc.Diagnosis = w.Diagnose(ctx, s)
+	}
+	if restart {
+		cmd := s.RestartCommand()
+		inc.Command, inc.Action = cmd, ActionRestarted
+		if err := w.Restart(ctx, s, cmd); err != nil {
+			inc.Action, inc.RestartError = ActionRestartFailed, err.Error()
+		}
+	}
+
+	w.mu.Lock()
+	w.nextID++
+	inc.ID = w.nextID
+	st.open = inc.ID
+	st.pending = false
+	w.incidents = append(w.incidents, inc)
+	if len(w.incidents) > MaxIncidents {
+		w.incidents = w.incidents[len(w.incidents)-MaxIncidents:]
+	}
+	w.mu.Unlock()
+
+	slog.Error("Сервис упал",
+		slog.String("сервис", s.Name),
+		slog.Int("подряд", failures),
+		slog.String("ошибка", inc.Error),
+		slog.String("действие", inc.Action),
+		slog.String("ошибка_перезапуска", inc.RestartError))
+	w.notify(inc)
+}
+
+// recover — отмечает восстановление по открытому инциденту (w.mu захвачен).
+func (w *Watchdog) recover(st *state, now time.Time) (Incident, bool) {
+	if st.open == 0 {
+		return Incident{}, false
+	}
+	id := st.open
+	st.open = 0
+	for i := range w.incidents {
+		if w.incidents[i].ID == id {
+			w.incidents[i].RecoveredAt = &now
+			return w.incidents[i], true
+		}
+	}
+	return Incident{}, false
+}
+
+func (w *Watchdog) notify(inc Incident) {
+	if w.OnIncident != nil {
+		w.OnIncident(inc)
+	}
+}
+
+// Run — проверяет сервисы каждые interval() до отмены ctx; интервал 0 —
+// сторож выключен (переключение подхватывается без перезапуска).
+func (w *Watchdog) Run(ctx context.Context, interval func() time.Duration, services func() []Service) {
+	for {
+		wait := interval()
+		if wait > 0 {
+			w.Tick(ctx, services())
+		} else {
+			wait = disabledPoll
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+	}
+}
+
+// Statuses — состояние сервисов по имени; ещё не проверенные не показываются.
+func (w *Watchdog) Statuses() []Status {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	out := make([]Status, 0, len(w.states))
+	for _, st := range w.states {
+		out = append(out, st.Status)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+// Incidents — последние инциденты, новые первыми.
+func (w *Watchdog) Incidents() []Incident {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	out := make([]Incident, len(w.incidents))
+	for i, inc := range w.incidents {
+		out[len(out)-1-i] = inc
+	}
+	return out
+}
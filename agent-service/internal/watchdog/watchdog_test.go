@@ -0,0 +1,182 @@
+package watchdog
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fake — сервис, который падает по команде, и журнал перезапусков.
+type fake struct {
+	down     bool
+	restarts []string
+}
+
+func newWatchdog(f *fake, cfg Config) (*Watchdog, *time.Time) {
+	now := time.Now()
+	w := New(func() Config { return cfg })
+	w.now = func() time.Time { return now }
+	w.Check = func(context.Context, Service) error {
+		if f.down {
+			return errors.New("connection refused")
+		}
+		return nil
+	}
+	w.Restart = func(_ context.Context, _ Service, cmd string) error {
+		f.restarts = append(f.restarts, cmd)
+		return nil
+	}
+	return w, &now
+}
+
+func TestTick_RestartAfterFailures(t *testing.T) {
+	f := &fake{}
+	w, now := newWatchdog(f, Config{Failures: 2, Cooldown: 10 * time.Minute})
+	var notified []Incident
+	w.OnIncident = func(inc Incident) { notified = append(notified, inc) }
+	svc := []Service{{Name: "tools-service", HealthURL: "http://tools/health", Restart: RestartSystemd, Unit: "agent-tools"}}
+
+	f.down = true
+	w.Tick(context.Background(), svc)
+	if len(f.restarts) != 0 {
+		t.Fatal("одна неудачная проверка — не повод перезапускать")
+	}
+	w.Tick(context.Background(), svc)
+	if len(f.restarts) != 1 || f.restarts[0] != "systemctl restart agent-tools" {
+		t.Fatalf("перезапуски: %v", f.restarts)
+	}
+
+	// Сервис падает снова сразу после перезапуска — пауза Cooldown
+	w.Tick(context.Background(), svc)
+	w.Tick(context.Background(), svc)
+	if len(f.restarts) != 1 {
+		t.Errorf("перезапуск до истечения Cooldown: %v", f.restarts)
+	}
+	*now = now.Add(11 * time.Minute)
+	w.Tick(context.Background(), svc)
+	if len(f.restarts) != 2 {
+		t.Errorf("после Cooldown — повторный перезапуск: %v", f.restarts)
+	}
+
+	f.down = false
+	w.Tick(context.Background(), svc)
+	incidents := w.Incidents()
+	if len(incidents) != 2 || incidents[0].RecoveredAt == nil || incidents[0].Action != ActionRestarted {
+		t.Errorf("инциденты: %+v", incidents)
+	}
+	if len(notified) != 3 || notified[2].RecoveredAt == nil {
+		t.Errorf("уведомления: два перезапуска и восстановление, получено %+v", notified)
+	}
+	if st := w.Statuses(); len(st) != 1 || !st[0].Healthy || st[0].Restarts != 2 {
+		t.Errorf("состояние: %+v", st)
+	}
+}
+
+func TestTick_MonitorOnly(t *testing.T) {
+	f := &fake{down: true}
+	w, _ := newWatchdog(f, Config{Failures: 1})
+	svc := []Service{{Name: "memory-service", HealthURL: "http://memory/health", Restart: RestartNone}}
+	for i := 0; i < 3; i++ {
+		w.Tick(context.Background(), svc)
+	}
+	incidents := w.Incidents()
+	if len(f.restarts) != 0 || len(incidents) != 1 || incidents[0].Action != ActionMonitorOnly || incidents[0].Failures != 1 {
+		t.Errorf("без перезапуска — один инцидент на падение: %v, %+v", f.restarts, incidents)
+	}
+}
+
+// TestTick_ConcurrentRestartOnce — проверка, пришедшая во время перезапуска,
+// не перезапускает сервис второй раз.
+func TestTick_ConcurrentRestartOnce(t *testing.T) {
+	f := &fake{down: true}
+	w, _ := newWatchdog(f, Config{Failures: 1, Cooldown: time.Minute})
+	started, release := make(chan struct{}), make(chan struct{})
+	var restarts atomic.Int32
+	w.Restart = func(context.Context, Service, string) error {
+		if restarts.Add(1) == 1 {
+			close(started)
+			<-release
+		}
+		return nil
+	}
+	svc := []Service{{Name: "tools-service", HealthURL: "http://tools/health", Restart: RestartSystemd, Unit: "agent-tools"}}
+
+	done := make(chan struct{})
+	go func() { w.Tick(context.Background(), svc); close(done) }()
+	<-started
+	w.Tick(context.Background(), svc) // POST /watchdog/check во время перезапуска
+	close(release)
+	<-done
+	if n := restarts.Load(); n != 1 || len(w.Incidents()) != 1 {
+		t.Errorf("перезапусков %d, инциденты %+v", n, w.Incidents())
+	}
+}
+
+func TestTick_RestartFailed(t *testing.T) {
+	f := &fake{down: true}
+	w, _ := newWatchdog(f, Config{Failures: 1})
+	w.Restart = func(context.Context, Service, string) error { return errors.New("Unit not found") }
+	w.Diagnose = func(_ context.Context, s Service) map[string]interface{} {
+		return map[string]interface{}{"service": s.Name}
+	}
+	w.Tick(context.Background(), []Service{{Name: "browser", HealthURL: "http://b/health", Restart: RestartDocker, Container: "browser"}})
+	inc := w.Incidents()[0]
+	if inc.Action != ActionRestartFailed || inc.RestartError != "Unit not found" || inc.Command != "docker restart browser" || inc.Diagnosis["service"] != "browser" {
+		t.Errorf("инцидент: %+v", inc)
+	}
+}
+
+func TestHTTPCheck(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/health" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer srv.Close()
+	if err := HTTPCheck(context.Background(), Service{HealthURL: srv.URL + "/health"}); err != nil {
+		t.Errorf("здоровый сервис: %v", err)
+	}
+	if err := HTTPCheck(context.Background(), Service{HealthURL: srv.URL + "/ready"}); err == nil || !strings.Contains(err.Error(), "503") {
+		t.Errorf("503: %v", err)
+	}
+}
+
+func TestLoadServices(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "watchdog.yaml")
+	os.WriteFile(path, []byte(`
+services:
+  - name: tools-service
+    health_url: http://localhost:8082/health
+    restart: systemd
+  - name: memory-service
+    health_url: http://localhost:8001/health
+`), 0o644)
+	list, err := LoadServices(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if list[0].Unit != "tools-service" || list[1].Restart != RestartNone || list[1].RestartCommand() != "" {
+		t.Errorf("значения по умолчанию: %+v", list)
+	}
+
+	for name, src := range map[string]string{
+		"без url":        "services: [{name: a}]",
+		"действие":       "services: [{name: a, health_url: 'http://a', restart: kill}]",
+		"юнит":           "services: [{name: a, health_url: 'http://a', restart: systemd, unit: 'a; rm -rf /'}]",
+		"без команды":    "services: [{name: a, health_url: 'http://a', restart: command}]",
+		"повтор сервиса": "services: [{name: a, health_url: 'http://a'}, {name: a, health_url: 'http://b'}]",
+	} {
+		os.WriteFile(path, []byte(src), 0o644)
+		if _, err := LoadServices(path); err == nil {
+			t.Errorf("%s: ожидалась ошибка", name)
+		}
+	}
+}
@@ -5,63 +5,66 @@
 //   - /tools/*   → tools-service (Go, порт 8082) — выполнение команд, работа с файлами
 //   - /agents/*  → agent-service (Go, порт 8083) — управление агентами, чат, LLM
 //   - /chat, /models, /providers, /workspaces и др. → agent-service
+//   - /browser/*, /input/*, /search/*, /crawler/*, /access/* → browser-service (Go, порт 8084)
 //
 // Функции:
 //   - Reverse proxy для всех микросервисов
 //   - CORS-защита с настраиваемым белым списком доменов
 //   - Фильтрация HTTP-методов для каждого маршрута
 //   - Два режима проксирования: с удалением префикса (Strip) и без
+//   - Таблица маршрутов из JSON-файла с горячей перезагрузкой по SIGHUP
+//   - GET /gateway/config — конфигурация шлюза без секретов; POST /gateway/config/reload
+//     (как и SIGHUP) перечитывает конфигурацию и таблицу маршрутов
+//   - /ready — готовность: доступность /health бэкенд-сервисов (503, если agent-service недоступен)
 //
-// Конфигурация через переменные окружения:
+// Конфигурация (пакет config) — YAML-файл GATEWAY_CONFIG_FILE и переменные окружения:
 //   - MEMORY_SERVICE_URL  — URL memory-service (по умолчанию http://localhost:8001)
 //   - TOOLS_SERVICE_URL   — URL tools-service (по умолчанию http://localhost:8082)
 //   - AGENT_SERVICE_URL   — URL agent-service (по умолчанию http://localhost:8083)
+//   - BROWSER_SERVICE_URL — URL browser-service (по умолчанию http://localhost:8084)
 //   - GATEWAY_PORT        — порт API Gateway (по умолчанию 8080)
+//   - GATEWAY_ROUTES_FILE — JSON-файл маршрутов (по умолчанию routes.json, иначе встроенная таблица)
+//   - GATEWAY_AUTH_TOKENS — токены клиентов для маршрутов с auth: true (через запятую)
+//   - GATEWAY_CACHE_ENABLED — кэш GET-ответов для маршрутов с cache_ttl (по умолчанию true)
+//   - GATEWAY_MAX_BODY_BYTES — лимит тела запроса по умолчанию (10 МБ), превышение — 413
+//   - GATEWAY_GZIP_ENABLED — сжатие текстовых ответов в br или gzip (по умолчанию true)
+//   - RATE_LIMIT_RPS, RATE_LIMIT_WINDOW — запросов с одного адреса за окно (по умолчанию 60 за 1m)
+//   - OTEL_EXPORTER_OTLP_ENDPOINT — адрес OTLP/HTTP-коллектора (Jaeger, Tempo) для экспорта спанов
 //   - CORS_ALLOWED_ORIGINS — белый список доменов для CORS (через запятую)
+//   - DEFAULT_LANGUAGE — язык ошибок без ?lang=, X-Language и Accept-Language: ru (по умолчанию) или en
 package main
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
 	"net/http"
-	"net/url"
 	"os"
 	"os/signal"
-	"strconv"
+	"sort"
 	"strings"
+	"sync"
 	"syscall"
 	"time"
 
 	"github.com/neo-2022/openclaw-memory/api-gateway/gates"
 	"github.com/neo-2022/openclaw-memory/api-gateway/internal/apierror"
+	"github.com/neo-2022/openclaw-memory/api-gateway/internal/config"
+	"github.com/neo-2022/openclaw-memory/api-gateway/internal/health"
+	"github.com/neo-2022/openclaw-memory/api-gateway/internal/i18n"
 	"github.com/neo-2022/openclaw-memory/api-gateway/internal/logger"
 	"github.com/neo-2022/openclaw-memory/api-gateway/internal/middleware"
+	"github.com/neo-2022/openclaw-memory/api-gateway/internal/tracing"
 )
 
-// parseAllowedOrigins — разбирает переменную окружения CORS_ALLOWED_ORIGINS
-// и возвращает множество (map) разрешённых доменов.
-// По умолчанию разрешены: http://localhost:3000 (React dev), http://localhost:5173 (Vite dev).
-// Пустые строки в списке игнорируются. Пробелы вокруг доменов удаляются.
-func parseAllowedOrigins() map[string]struct{} {
-	origins := strings.Split(getEnv("CORS_ALLOWED_ORIGINS", "http://localhost:3000,http://localhost:5173"), ",")
-	allowed := make(map[string]struct{}, len(origins))
-	for _, o := range origins {
-		o = strings.TrimSpace(o)
-		if o == "" {
-			continue
-		}
-		allowed[o] = struct{}{}
-	}
-	return allowed
-}
-
 // corsMiddleware — middleware для обработки CORS (Cross-Origin Resource Sharing).
 // Проверяет заголовок Origin запроса по белому списку разрешённых доменов.
 // Если Origin присутствует в белом списке — устанавливает заголовки:
 //   - Access-Control-Allow-Origin: <origin>
 //   - Access-Control-Allow-Methods: <список методов>
-//   - Access-Control-Allow-Headers: Content-Type, Authorization
+//   - Access-Control-Allow-Headers: Content-Type, Authorization, X-Language
 //   - Vary: Origin (для корректного кэширования)
 //
 // Для preflight-запросов (OPTIONS) возвращает 204 No Content без дальнейшей обработки.
@@ -85,7 +88,11 @@ func requestIDMiddleware(next http.HandlerFunc) http.HandlerFunc {
 		}
 		w.Header().Set("X-Request-ID", requestID)
 		r.Header.Set("X-Request-ID", requestID)
-		next.ServeHTTP(w, r)
+		// Язык ответа: ошибки шлюза переводит apierror, сервисам язык передаётся в X-Language
+		lang := i18n.Negotiate(r)
+		w.Header().Set("Content-Language", lang)
+		r.Header.Set("X-Language", lang)
+		next.ServeHTTP(w, r.WithContext(i18n.WithLanguage(r.Context(), lang)))
 	}
 }
 
@@ -108,7 +115,8 @@ func timeoutMiddleware(next http.HandlerFunc, timeout time.Duration) http.Handle
 		start := time.Now()
 		next.ServeHTTP(w, r)
 		duration := time.Since(start)
-		if duration > timeout {
+		// Для SSE/WebSocket длительность — это время жизни потока, а не задержка
+		if duration > timeout && !middleware.IsStreamingRequest(r) {
 			cid := r.Header.Get("X-Request-ID")
 			ctx := logger.WithCorrelationID(r.Context(), cid)
 			logger.С(ctx).Warn("Медленный запрос", slog.String("метод", r.Method), slog.String("путь", r.URL.Path), slog.Duration("длительность", duration), slog.Duration("лимит", timeout))
@@ -127,7 +135,7 @@ func corsMiddleware(next http.HandlerFunc, allowedMethods []string, allowedOrigi
 		}
 
 		w.Header().Set("Access-Control-Allow-Methods", strings.Join(allowedMethods, ", "))
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-Language")
 
 		if r.Method == http.MethodOptions {
 			w.WriteHeader(http.StatusNoContent)
@@ -138,163 +146,70 @@ func corsMiddleware(next http.HandlerFunc, allowedMethods []string, allowedOrigi
 	}
 }
 
+// Таймауты HTTP-сервера шлюза. Маршрутам с timeout больше serverWriteTimeout
+// дедлайны соединения продлевает DeadlineMiddleware.
+const (
+	serverReadTimeout  = 15 * time.Second
+	serverWriteTimeout = 320 * time.Second
+)
+
 // main — точка входа API Gateway.
 // Конфигурирует все маршруты, создаёт reverse proxy для каждого микросервиса,
 // оборачивает обработчики в CORS middleware и запускает HTTP-сервер.
 func main() {
 	logger.Init("api-gateway")
 
-	memoryURL := getEnv("MEMORY_SERVICE_URL", "http://localhost:8001")
-	toolsURL := getEnv("TOOLS_SERVICE_URL", "http://localhost:8082")
-	agentURL := getEnv("AGENT_SERVICE_URL", "http://localhost:8083")
-	port := getEnv("GATEWAY_PORT", "8080")
+	cfg, err := config.Load()
+	if err == nil {
+		err = cfg.Validate()
+	}
+	if err != nil {
+		slog.Error("Некорректная конфигурация", slog.String("ошибка", err.Error()))
+		os.Exit(1)
+	}
+	config.Set(cfg)
+	port := cfg.Port
 
-	rlLimit, _ := strconv.Atoi(getEnv("RATE_LIMIT_RPS", "60"))
-	rlWindow, _ := time.ParseDuration(getEnv("RATE_LIMIT_WINDOW", "1m"))
-	rateLimiter := middleware.NewRateLimiter(rlLimit, rlWindow)
+	rateLimiter := middleware.NewRateLimiter(cfg.RateLimitRPS, cfg.RateLimitWindow)
 	rateLimitMW := middleware.RateLimitMiddleware(rateLimiter)
-	slog.Info("Ограничитель частоты настроен", slog.Int("лимит", rlLimit), slog.Duration("окно", rlWindow))
-
-	// Предохранители от отказов для каждого бэкенда
-	cbMemory := middleware.NewCircuitBreaker(5, 30*time.Second)
-	cbTools := middleware.NewCircuitBreaker(5, 30*time.Second)
-	cbAgent := middleware.NewCircuitBreaker(10, 30*time.Second)
+	slog.Info("Ограничитель частоты настроен", slog.Int("лимит", cfg.RateLimitRPS), slog.Duration("окно", cfg.RateLimitWindow))
 
-	// Мидлварь распределённой трассировки
+	// Мидлварь распределённой трассировки (W3C traceparent, экспорт в OTLP)
+	shutdownTracing := tracing.Init("api-gateway")
 	traceMW := middleware.TracingMiddleware("api-gateway")
 
-	// Парсим URL для создания reverse proxy
-	memoryTarget, _ := url.Parse(memoryURL)
-	toolsTarget, _ := url.Parse(toolsURL)
-	agentTarget, _ := url.Parse(agentURL)
-
-	// Таблица маршрутов: путь → целевой сервис, методы, режим проксирования.
-	// Strip=true: удаляет префикс пути (например, /memory/search → /search)
-	// Strip=false: передаёт путь как есть (например, /chat → /chat)
-	routes := []struct {
-		Path    string   // Префикс URL-пути
-		Target  *url.URL // Целевой микросервис
-		Methods []string // Разрешённые HTTP-методы
-		Strip   bool     // Удалять ли префикс пути при проксировании
-	}{
-		// Маршруты с удалением префикса — для сервисов с собственной маршрутизацией
-		{Path: "/memory/", Target: memoryTarget, Methods: []string{"GET", "POST", "PATCH", "DELETE"}, Strip: true},
-		{Path: "/tools/", Target: toolsTarget, Methods: []string{"GET", "POST", "DELETE"}, Strip: true},
-		{Path: "/agents/", Target: agentTarget, Methods: []string{"GET", "POST", "DELETE"}, Strip: true},
-		// Маршруты без удаления префикса — точные пути agent-service
-		{Path: "/models", Target: agentTarget, Methods: []string{"GET"}, Strip: false},
-		{Path: "/update-model", Target: agentTarget, Methods: []string{"POST"}, Strip: false},
-		{Path: "/avatar", Target: agentTarget, Methods: []string{"POST"}, Strip: false},
-		{Path: "/avatar-info", Target: agentTarget, Methods: []string{"GET"}, Strip: false},
-		{Path: "/prompts/load", Target: agentTarget, Methods: []string{"POST"}, Strip: false},
-		{Path: "/prompts", Target: agentTarget, Methods: []string{"GET"}, Strip: false},
-		{Path: "/agent/prompt", Target: agentTarget, Methods: []string{"POST"}, Strip: false},
-		{Path: "/chat", Target: agentTarget, Methods: []string{"POST"}, Strip: false},
-		// Новые маршруты для облачных провайдеров и рабочих пространств
-		{Path: "/providers", Target: agentTarget, Methods: []string{"GET", "POST"}, Strip: false},
-		{Path: "/cloud-models", Target: agentTarget, Methods: []string{"GET"}, Strip: false},
-		{Path: "/workspaces", Target: agentTarget, Methods: []string{"GET", "POST", "DELETE"}, Strip: false},
-		// Маршрут для статистики обучения агентов (проксируется на agent-service)
-		{Path: "/learning-stats", Target: agentTarget, Methods: []string{"GET"}, Strip: false},
-		// Яндекс.Диск — облачное хранилище (проксируется на tools-service)
-		// Все операции: просмотр, загрузка, скачивание, создание папок, удаление, перемещение, поиск
-		{Path: "/ydisk/", Target: toolsTarget, Methods: []string{"GET", "POST", "DELETE"}, Strip: false},
-		// Статика аватаров: без удаления префикса, чтобы /uploads/... шёл как есть
-		{Path: "/uploads/", Target: agentTarget, Methods: []string{"GET"}, Strip: false},
-		// RAG — база знаний (проксируется на agent-service)
-		{Path: "/rag/", Target: agentTarget, Methods: []string{"GET", "POST", "PATCH", "DELETE"}, Strip: false},
-		// Метрики сценариев и auto-skill паттерны (проксируется на agent-service)
-		{Path: "/scenario-metrics", Target: agentTarget, Methods: []string{"GET"}, Strip: false},
-		{Path: "/autoskill/", Target: agentTarget, Methods: []string{"GET"}, Strip: false},
-		// Системные логи (проксируется на agent-service)
-		{Path: "/logs", Target: agentTarget, Methods: []string{"GET", "POST", "PATCH"}, Strip: false},
-		// Skill Engine — управление навыками (проксируется на agent-service → memory-service)
-		{Path: "/skills/search", Target: agentTarget, Methods: []string{"POST"}, Strip: false},
-		{Path: "/skills/from-dialog", Target: agentTarget, Methods: []string{"POST"}, Strip: false},
-		{Path: "/skills/", Target: agentTarget, Methods: []string{"GET", "POST", "PUT", "DELETE"}, Strip: false},
-		{Path: "/skills", Target: agentTarget, Methods: []string{"GET", "POST"}, Strip: false},
-		// Graph Engine — граф знаний (проксируется на agent-service → memory-service)
-		{Path: "/graph/relationships/", Target: agentTarget, Methods: []string{"DELETE"}, Strip: false},
-		{Path: "/graph/relationships", Target: agentTarget, Methods: []string{"GET", "POST"}, Strip: false},
-		{Path: "/graph/neighbors/", Target: agentTarget, Methods: []string{"GET"}, Strip: false},
-		{Path: "/graph/traverse", Target: agentTarget, Methods: []string{"POST"}, Strip: false},
-		// Статус эмбеддингов (проксируется на agent-service → memory-service)
-		{Path: "/embeddings/status", Target: agentTarget, Methods: []string{"GET"}, Strip: false},
-		// Проверка здоровья через memory-service
-		{Path: "/health", Target: memoryTarget, Methods: []string{"GET"}, Strip: false},
+	deps := &routeDeps{
+		rateLimitMW: rateLimitMW,
+		traceMW:     traceMW,
+		breakers:    make(map[string]*middleware.CircuitBreaker),
 	}
-
-	// Загружаем белый список доменов для CORS
-	allowedOrigins := parseAllowedOrigins()
-
-	// Регистрируем обработчики с CORS для каждого маршрута
-	for _, r := range routes {
-		var proxy http.Handler
-		if r.Strip {
-			// Режим с удалением префикса: /memory/search → /search
-			proxy = gates.NewCustomProxy(r.Target, r.Path)
-		} else {
-			// Режим без удаления: /chat → /chat
-			proxy = gates.NewProxyWithoutStrip(r.Target)
-		}
-		// Оборачиваем proxy в CORS middleware с проверкой допустимых HTTP-методов
-		routeTimeout := 60 * time.Second
-		if r.Path == "/chat" || r.Path == "/agents/" {
-			routeTimeout = 300 * time.Second
-		}
-
-		// Выбираем предохранитель по целевому сервису
-		var cb *middleware.CircuitBreaker
-		var svcName string
-		switch r.Target {
-		case memoryTarget:
-			cb = cbMemory
-			svcName = "memory"
-		case toolsTarget:
-			cb = cbTools
-			svcName = "tools"
-		default:
-			cb = cbAgent
-			svcName = "agent"
-		}
-		cbMW := middleware.CircuitBreakerMiddleware(cb, svcName)
-
-		handler := requestIDMiddleware(
-			traceMW(
-				rateLimitMW(
-					panicRecoveryMiddleware(
-						timeoutMiddleware(
-							cbMW(
-								corsMiddleware(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
-									cid := req.Header.Get("X-Request-ID")
-									ctx := logger.WithCorrelationID(req.Context(), cid)
-									logger.С(ctx).Info("Проксирование запроса", slog.String("метод", req.Method), slog.String("путь", req.URL.Path), slog.String("маршрут", r.Path), slog.String("цель", r.Target.Host))
-									for _, m := range r.Methods {
-										if m == req.Method {
-											proxy.ServeHTTP(w, req)
-											return
-										}
-									}
-									logger.С(ctx).Warn("Метод не разрешён", slog.String("метод", req.Method), slog.String("путь", req.URL.Path))
-									apierror.MethodNotAllowed(w, cid)
-								}), r.Methods, allowedOrigins),
-							),
-							routeTimeout,
-						),
-					),
-				),
-			),
-		)
-
-		http.Handle(r.Path, handler)
+	deps.apply(cfg)
+	if cfg.CacheEnabled {
+		deps.cache = middleware.NewResponseCache(1000)
+		slog.Info("Кэш GET-ответов включён")
 	}
 
-	http.HandleFunc("/metrics", middleware.MetricsHandler)
+	// Таблица маршрутов загружается из файла (GATEWAY_ROUTES_FILE),
+	// при его отсутствии используется встроенная таблица.
+	routes, err := loadRoutesConfig(cfg.RoutesFile)
+	if err != nil {
+		slog.Error("Ошибка загрузки маршрутов", slog.String("файл", cfg.RoutesFile), slog.String("ошибка", err.Error()))
+		os.Exit(1)
+	}
+	mux, err := buildMux(routes, deps)
+	if err != nil {
+		slog.Error("Ошибка построения маршрутов", slog.String("ошибка", err.Error()))
+		os.Exit(1)
+	}
+	deps.routes = routes
+	router := gates.NewRouter(mux)
+	deps.reload = func() (config.ReloadResult, error) { return reload(router, deps) }
 
 	srv := &http.Server{
 		Addr:         ":" + port,
-		ReadTimeout:  15 * time.Second,
-		WriteTimeout: 320 * time.Second,
+		Handler:      router,
+		ReadTimeout:  serverReadTimeout,
+		WriteTimeout: serverWriteTimeout,
 		IdleTimeout:  60 * time.Second,
 	}
 
@@ -306,6 +221,16 @@ func main() {
 		}
 	}()
 
+	// SIGHUP — горячая перезагрузка конфигурации и таблицы маршрутов без перезапуска.
+	// При ошибке в файле маршрутов продолжаем работать на прежней таблице.
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for range hup {
+			deps.reload()
+		}
+	}()
+
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	sig := <-quit
@@ -316,14 +241,257 @@ func main() {
 	if err := srv.Shutdown(ctx); err != nil {
 		slog.Error("Ошибка при завершении сервера", slog.String("ошибка", err.Error()))
 	}
+	shutdownTracing(ctx)
 	slog.Info("Сервер корректно остановлен")
 }
 
-// getEnv — вспомогательная функция для чтения переменной окружения.
-// Если переменная не задана или пуста, возвращает значение по умолчанию.
-func getEnv(key, defaultValue string) string {
-	if value := os.Getenv(key); value != "" {
-		return value
+// routeDeps — общие для всех таблиц маршрутов зависимости.
+// Сохраняются между перезагрузками, чтобы не сбрасывать счётчики
+// ограничителя частоты и состояние предохранителей.
+type routeDeps struct {
+	rateLimitMW    func(http.HandlerFunc) http.HandlerFunc
+	traceMW        func(http.HandlerFunc) http.HandlerFunc
+	authMW         func(http.HandlerFunc) http.HandlerFunc
+	allowedOrigins map[string]struct{}
+	cache          *middleware.ResponseCache // nil, если кэш выключен (GATEWAY_CACHE_ENABLED=false)
+	maxBody        int64                     // Лимит тела запроса по умолчанию (GATEWAY_MAX_BODY_BYTES)
+	gzip           bool                      // Сжатие ответов br/gzip (GATEWAY_GZIP_ENABLED)
+
+	routes *gates.Config                       // Действующая таблица маршрутов
+	reload func() (config.ReloadResult, error) // Перезагрузка конфигурации и маршрутов (SIGHUP, POST /gateway/config/reload)
+
+	mu       sync.Mutex
+	breakers map[string]*middleware.CircuitBreaker
+}
+
+// apply — переносит в зависимости параметры конфигурации, применяемые на лету.
+// Действуют для таблиц маршрутов, построенных после вызова.
+func (d *routeDeps) apply(cfg *config.Config) {
+	tokens := cfg.AuthTokenSet()
+	if len(tokens) == 0 {
+		slog.Warn("GATEWAY_AUTH_TOKENS не задан — маршруты с auth: true доступны без токена")
+	}
+	d.authMW = middleware.AuthMiddleware(tokens)
+	d.allowedOrigins = cfg.AllowedOrigins()
+	d.maxBody = cfg.MaxBodyBytes
+	d.gzip = cfg.GzipEnabled
+	i18n.SetDefault(cfg.DefaultLanguage)
+}
+
+// reloadMu — перезагрузки по SIGHUP и через API выполняются по одной.
+var reloadMu sync.Mutex
+
+// reload — перечитывает конфигурацию и файл маршрутов и подменяет таблицу
+// маршрутов. Изменённые параметры Tunable применяются, даже если файл
+// маршрутов содержит ошибку: тогда пересобирается прежняя таблица, а ошибка
+// возвращается вызывающему.
+func reload(router *gates.Router, deps *routeDeps) (config.ReloadResult, error) {
+	reloadMu.Lock()
+	defer reloadMu.Unlock()
+	res, err := config.Reload()
+	if err != nil {
+		slog.Error("Перезагрузка конфигурации отклонена", slog.String("ошибка", err.Error()))
+		return res, err
+	}
+	cfg := config.Current()
+	deps.apply(cfg)
+	routes, routesErr := loadRoutesConfig(cfg.RoutesFile)
+	if routesErr != nil {
+		routesErr = fmt.Errorf("файл маршрутов %s: %w", cfg.RoutesFile, routesErr)
+		routes = deps.routes
+	}
+	mux, err := buildMux(routes, deps)
+	if err != nil {
+		routesErr = errors.Join(routesErr, err)
+		if mux, err = buildMux(deps.routes, deps); err != nil {
+			slog.Error("Перезагрузка маршрутов отклонена", slog.String("ошибка", err.Error()))
+			return res, err
+		}
+		routes = deps.routes
+	}
+	router.Swap(mux)
+	deps.routes = routes
+	if routesErr != nil {
+		slog.Error("Перезагрузка маршрутов отклонена", slog.String("ошибка", routesErr.Error()))
+		return res, routesErr
+	}
+	slog.Info("Конфигурация и маршруты перезагружены",
+		slog.Any("применено", res.Applied), slog.Any("требует_перезапуска", res.RestartRequired),
+		slog.String("файл", cfg.RoutesFile), slog.Int("маршрутов", len(routes.Routes)))
+	return res, nil
+}
+
+// breaker — возвращает предохранитель сервиса, создавая его при первом обращении.
+func (d *routeDeps) breaker(service string, maxFailures int) *middleware.CircuitBreaker {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if cb, ok := d.breakers[service]; ok {
+		return cb
+	}
+	if maxFailures <= 0 {
+		maxFailures = 5
+	}
+	cb := middleware.NewCircuitBreaker(maxFailures, 30*time.Second)
+	d.breakers[service] = cb
+	return cb
+}
+
+// loadRoutesConfig — читает файл маршрутов. Если файл по пути по умолчанию
+// отсутствует, возвращает встроенную таблицу gates.DefaultConfig().
+func loadRoutesConfig(path string) (*gates.Config, error) {
+	if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) && path == config.DefaultRoutesFile {
+		slog.Info("Файл маршрутов не найден, используется встроенная таблица", slog.String("файл", path))
+		return gates.DefaultConfig(), nil
+	}
+	return gates.LoadConfig(path)
+}
+
+// buildMux — строит таблицу маршрутов: reverse proxy для каждого правила,
+// обёрнутый в цепочку middleware (request-id → трассировка → rate limit →
+// panic recovery → стриминг → сжатие → таймаут → лимит тела → авторизация → предохранитель → CORS → кэш → проверка метода).
+func buildMux(cfg *gates.Config, deps *routeDeps) (*http.ServeMux, error) {
+	mux := http.NewServeMux()
+	for _, rc := range cfg.Routes {
+		r := rc
+		target, err := cfg.ServiceURL(r.Service)
+		if err != nil {
+			return nil, fmt.Errorf("маршрут %s: %w", r.Path, err)
+		}
+		var proxy http.Handler
+		if r.Stream {
+			// Стриминговый маршрут: SSE/WebSocket без буферизации
+			proxy = gates.NewStreamingProxy(target, r.Path, r.Strip)
+		} else if r.Strip {
+			// Режим с удалением префикса: /memory/search → /search
+			proxy = gates.NewCustomProxy(target, r.Path)
+		} else {
+			// Режим без удаления: /chat → /chat
+			proxy = gates.NewProxyWithoutStrip(target)
+		}
+
+		streamMW := middleware.StreamMiddleware(r.Stream)
+		cbMW := middleware.CircuitBreakerMiddleware(deps.breaker(r.Service, cfg.Services[r.Service].MaxFailures), r.Service)
+		passMW := func(next http.HandlerFunc) http.HandlerFunc { return next }
+		deadlineMW := passMW
+		if r.TimeoutFor() > serverWriteTimeout && !r.Stream {
+			// Архивы и долгие операции: дедлайны соединения — timeout маршрута
+			deadlineMW = middleware.DeadlineMiddleware(r.TimeoutFor())
+		}
+		authMW := passMW
+		if r.Auth {
+			authMW = deps.authMW
+		} else if len(r.AuthMethods) > 0 {
+			authMW = middleware.ForMethods(r.AuthMethods, deps.authMW)
+		}
+		maxBody := r.MaxBody
+		if maxBody == 0 {
+			maxBody = deps.maxBody
+		}
+		bodyMW := middleware.BodyLimitMiddleware(maxBody)
+		compressMW := passMW
+		if deps.gzip && !r.Stream {
+			compressMW = middleware.CompressMiddleware
+		}
+		cacheMW := passMW
+		if deps.cache != nil && (r.CacheTTL > 0 || len(r.Invalidates) > 0) {
+			cacheMW = middleware.CacheMiddleware(deps.cache, time.Duration(r.CacheTTL), r.Invalidates)
+		}
+
+		handler := requestIDMiddleware(
+			deps.traceMW(
+				deps.rateLimitMW(
+					panicRecoveryMiddleware(
+						streamMW(deadlineMW(compressMW(timeoutMiddleware(
+							bodyMW(authMW(
+								cbMW(
+									corsMiddleware(cacheMW(func(w http.ResponseWriter, req *http.Request) {
+										cid := req.Header.Get("X-Request-ID")
+										ctx := logger.WithCorrelationID(req.Context(), cid)
+										logger.С(ctx).Info("Проксирование запроса", slog.String("метод", req.Method), slog.String("путь", req.URL.Path), slog.String("маршрут", r.Path), slog.String("цель", target.Host))
+										for _, m := range r.Methods {
+											if m == req.Method {
+												proxy.ServeHTTP(w, req)
+												return
+											}
+										}
+										logger.С(ctx).Warn("Метод не разрешён", slog.String("метод", req.Method), slog.String("путь", req.URL.Path))
+										apierror.MethodNotAllowed(w, cid)
+									}), r.Methods, deps.allowedOrigins),
+								),
+							)),
+							r.TimeoutFor(),
+						)))),
+					),
+				),
+			),
+		)
+
+		mux.Handle(r.Path, handler)
+	}
+
+	mux.HandleFunc("/metrics", middleware.MetricsHandler)
+	mux.HandleFunc("/ready", health.Handler("api-gateway", readinessChecks(cfg)))
+	mux.HandleFunc("/gateway/config", requestIDMiddleware(configHandler))
+	mux.HandleFunc("/gateway/config/reload", requestIDMiddleware(deps.authMW(configReloadHandler(deps))))
+	return mux, nil
+}
+
+// configHandler — конфигурация шлюза без секретов (GET /gateway/config).
+// Поле reloadable перечисляет параметры, применяемые без перезапуска.
+func configHandler(w http.ResponseWriter, r *http.Request) {
+	cid := r.Header.Get("X-Request-ID")
+	if r.Method != http.MethodGet {
+		apierror.MethodNotAllowed(w, cid)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"config":     config.Current().Sanitized(),
+		"reloadable": config.Reloadable(),
+	})
+}
+
+// configReloadHandler — перечитывает конфигурацию и маршруты (POST /gateway/config/reload),
+// как и SIGHUP. Некорректная конфигурация отклоняется с 400.
+func configReloadHandler(deps *routeDeps) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		cid := r.Header.Get("X-Request-ID")
+		if r.Method != http.MethodPost {
+			apierror.MethodNotAllowed(w, cid)
+			return
+		}
+		res, err := deps.reload()
+		if err != nil {
+			apierror.BadRequest(w, cid, "Конфигурация не применена: "+err.Error(), "Исправьте файл конфигурации, файл маршрутов или переменные окружения")
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(res)
+	}
+}
+
+// readinessChecks — проверки /ready: доступность /health каждого бэкенд-сервиса
+// и, если у сервиса задан адрес gRPC, его внутреннего gRPC API (<имя>-grpc).
+// Без agent-service шлюз бесполезен, поэтому только он обязателен;
+// остальные сервисы отражаются в ответе, но не снимают шлюз с балансировки.
+func readinessChecks(cfg *gates.Config) func() []health.Check {
+	names := make([]string, 0, len(cfg.Services))
+	for name := range cfg.Services {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return func() []health.Check {
+		checks := make([]health.Check, 0, len(names))
+		for _, name := range names {
+			target, err := cfg.ServiceURL(name)
+			if err != nil {
+				continue
+			}
+			checks = append(checks, health.HTTPCheck(name, strings.TrimRight(target.String(), "/")+"/health", name == "agent"))
+			if addr := cfg.ServiceGRPCAddr(name); addr != "" {
+				checks = append(checks, health.GRPCCheck(name+"-grpc", addr, false))
+			}
+		}
+		return checks
 	}
-	return defaultValue
 }
@@ -0,0 +1,281 @@
+package gates
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Duration — длительность, которая в JSON записывается строкой ("60s", "5m").
+type Duration time.Duration
+
+// UnmarshalJSON — разбирает строку формата time.ParseDuration.
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return fmt.Errorf("длительность должна быть строкой (например, \"60s\"): %w", err)
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+// MarshalJSON — записывает длительность строкой.
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// ServiceConfig — описание бэкенд-сервиса, на который проксируются маршруты.
+//
+// URL можно переопределить переменной окружения <ИМЯ>_SERVICE_URL
+// (например, AGENT_SERVICE_URL для сервиса "agent"), адрес gRPC —
+// переменной <ИМЯ>_GRPC_ADDR.
+type ServiceConfig struct {
+	URL         string `json:"url"`                    // URL сервиса по умолчанию
+	MaxFailures int    `json:"max_failures,omitempty"` // Порог предохранителя (по умолчанию 5)
+	// GRPC — адрес внутреннего gRPC API (host:port); /ready проверяет его по grpc.health.v1
+	GRPC string `json:"grpc,omitempty"`
+}
+
+// RouteConfig — одно правило проксирования из файла маршрутов.
+type RouteConfig struct {
+	Path    string   `json:"path"`              // Префикс URL-пути
+	Service string   `json:"service"`           // Имя сервиса из секции services
+	Methods []string `json:"methods"`           // Разрешённые HTTP-методы
+	Strip   bool     `json:"strip"`             // Удалять ли префикс пути при проксировании
+	Timeout Duration `json:"timeout,omitempty"` // Лимит длительности запроса (по умолчанию 60s)
+	Auth    bool     `json:"auth,omitempty"`    // Требовать Bearer-токен от клиента
+	Stream  bool     `json:"stream,omitempty"`  // SSE/WebSocket: без буферизации и без таймаута записи
+	// AuthMethods — методы, для которых нужен Bearer-токен при auth: false
+	// (чтение открыто, изменение — с токеном)
+	AuthMethods []string `json:"auth_methods,omitempty"`
+	// CacheTTL — время жизни кэша GET-ответов маршрута (0 — не кэшировать)
+	CacheTTL Duration `json:"cache_ttl,omitempty"`
+	// MaxBody — лимит тела запроса в байтах (0 — значение GATEWAY_MAX_BODY_BYTES)
+	MaxBody int64 `json:"max_body,omitempty"`
+	// Invalidates — префиксы путей, кэш которых сбрасывается после успешного POST/PUT/PATCH/DELETE
+	Invalidates []string `json:"invalidates,omitempty"`
+}
+
+// Config — содержимое файла маршрутов API Gateway.
+type Config struct {
+	Services map[string]ServiceConfig `json:"services"`
+	Routes   []RouteConfig            `json:"routes"`
+}
+
+// DefaultRouteTimeout — лимит длительности запроса, если в маршруте не указан timeout.
+const DefaultRouteTimeout = 60 * time.Second
+
+// LoadConfig — читает и проверяет файл маршрутов в формате JSON.
+func LoadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("чтение файла маршрутов: %w", err)
+	}
+	var cfg Config
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&cfg); err != nil {
+		return nil, fmt.Errorf("разбор файла маршрутов %s: %w", path, err)
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+// Validate — проверяет, что каждый маршрут ссылается на известный сервис,
+// пути не дублируются, а методы и URL заданы корректно.
+func (c *Config) Validate() error {
+	if len(c.Routes) == 0 {
+		return fmt.Errorf("в конфигурации нет ни одного маршрута")
+	}
+	for name, svc := range c.Services {
+		if _, err := url.Parse(svc.URL); err != nil || svc.URL == "" {
+			return fmt.Errorf("сервис %q: некорректный url %q", name, svc.URL)
+		}
+	}
+	seen := make(map[string]bool, len(c.Routes))
+	for i, r := range c.Routes {
+		if !strings.HasPrefix(r.Path, "/") {
+			return fmt.Errorf("маршрут #%d: путь %q должен начинаться с /", i, r.Path)
+		}
+		if seen[r.Path] {
+			return fmt.Errorf("маршрут %s объявлен дважды", r.Path)
+		}
+		seen[r.Path] = true
+		if _, ok := c.Services[r.Service]; !ok {
+			return fmt.Errorf("маршрут %s: неизвестный сервис %q", r.Path, r.Service)
+		}
+		if len(r.Methods) == 0 {
+			return fmt.Errorf("маршрут %s: не указаны методы", r.Path)
+		}
+		if r.Timeout < 0 {
+			return fmt.Errorf("маршрут %s: отрицательный timeout", r.Path)
+		}
+		for _, m := range r.AuthMethods {
+			if !slices.Contains(r.Methods, m) {
+				return fmt.Errorf("маршрут %s: метод %s из auth_methods не входит в methods", r.Path, m)
+			}
+		}
+	}
+	return nil
+}
+
+// ServiceURL — возвращает URL сервиса с учётом переменной окружения <ИМЯ>_SERVICE_URL.
+func (c *Config) ServiceURL(name string) (*url.URL, error) {
+	raw := c.Services[name].URL
+	envKey := strings.ToUpper(strings.ReplaceAll(name, "-", "_")) + "_SERVICE_URL"
+	if v := os.Getenv(envKey); v != "" {
+		raw = v
+	}
+	return url.Parse(raw)
+}
+
+// ServiceGRPCAddr — адрес gRPC сервиса с учётом переменной окружения
+// <ИМЯ>_GRPC_ADDR; пусто — у сервиса нет gRPC API.
+func (c *Config) ServiceGRPCAddr(name string) string {
+	envKey := strings.ToUpper(strings.ReplaceAll(name, "-", "_")) + "_GRPC_ADDR"
+	if v := os.Getenv(envKey); v != "" {
+		return v
+	}
+	return c.Services[name].GRPC
+}
+
+// TimeoutFor — возвращает лимит длительности маршрута или значение по умолчанию.
+func (r RouteConfig) TimeoutFor() time.Duration {
+	if r.Timeout == 0 {
+		return DefaultRouteTimeout
+	}
+	return time.Duration(r.Timeout)
+}
+
+// DefaultConfig — встроенная таблица маршрутов.
+// Используется, если файл маршрутов не найден, и совпадает с routes.json из репозитория.
+func DefaultConfig() *Config {
+	all := []string{"GET", "POST", "PATCH", "DELETE"}
+	return &Config{
+		Services: map[string]ServiceConfig{
+			"memory":  {URL: "http://localhost:8001", MaxFailures: 5},
+			"tools":   {URL: "http://localhost:8082", MaxFailures: 5, GRPC: "localhost:9082"},
+			"agent":   {URL: "http://localhost:8083", MaxFailures: 10},
+			"browser": {URL: "http://localhost:8084", MaxFailures: 5, GRPC: "localhost:9084"},
+		},
+		Routes: []RouteConfig{
+			// Маршруты с удалением префикса — для сервисов с собственной маршрутизацией
+			{Path: "/memory/", Service: "memory", Methods: all, Strip: true},
+			{Path: "/tools/", Service: "tools", Methods: []string{"GET", "POST", "DELETE"}, Strip: true},
+			{Path: "/agents/", Service: "agent", Methods: []string{"GET", "POST", "DELETE"}, Strip: true, Timeout: Duration(300 * time.Second), CacheTTL: Duration(10 * time.Second), Invalidates: []string{"/agents/"}},
+			// Маршруты без удаления префикса — точные пути agent-service.
+			// Часто опрашиваемые UI списки кэшируются, изменения сбрасывают кэш.
+			{Path: "/models", Service: "agent", Methods: []string{"GET"}, CacheTTL: Duration(30 * time.Second)},
+			{Path: "/models/loaded", Service: "agent", Methods: []string{"GET"}},
+			{Path: "/models/benchmark", Service: "agent", Methods: []string{"GET", "POST"}, Timeout: Duration(900 * time.Second), Invalidates: []string{"/models"}},
+			{Path: "/update-model", Service: "agent", Methods: []string{"POST"}, Invalidates: []string{"/models", "/agents/"}},
+			{Path: "/avatar", Service: "agent", Methods: []string{"POST"}, MaxBody: 20 << 20, Invalidates: []string{"/agents/"}},
+			{Path: "/avatar-info", Service: "agent", Methods: []string{"GET"}},
+			{Path: "/prompts/load", Service: "agent", Methods: []string{"POST"}},
+			{Path: "/prompts/export", Service: "agent", Methods: []string{"GET"}},
+			{Path: "/prompts/import", Service: "agent", Methods: []string{"POST"}, Auth: true},
+			{Path: "/prompts/files/", Service: "agent", Methods: []string{"GET", "PUT", "DELETE"}, AuthMethods: []string{"PUT", "DELETE"}, Invalidates: []string{"/agents/"}},
+			{Path: "/prompts/files", Service: "agent", Methods: []string{"GET", "POST"}, AuthMethods: []string{"POST"}, Invalidates: []string{"/agents/"}},
+			{Path: "/prompts", Service: "agent", Methods: []string{"GET"}},
+			{Path: "/agent/prompt", Service: "agent", Methods: []string{"POST"}, Invalidates: []string{"/agents/"}},
+			{Path: "/chat", Service: "agent", Methods: []string{"POST"}, Timeout: Duration(300 * time.Second)},
+			{Path: "/chat/audio", Service: "agent", Methods: []string{"POST"}, Timeout: Duration(300 * time.Second), MaxBody: 32 << 20},
+			// Черновик и итоговый ответ приходят потоком SSE
+			{Path: "/chat/speculative", Service: "agent", Methods: []string{"POST"}, Timeout: Duration(300 * time.Second), Stream: true},
+			// Пакетная обработка: задание выполняется в фоне, состояние — по идентификатору
+			{Path: "/chat/batch/", Service: "agent", Methods: []string{"GET", "DELETE"}},
+			{Path: "/chat/batch", Service: "agent", Methods: []string{"GET", "POST"}},
+			{Path: "/chat/regenerate", Service: "agent", Methods: []string{"GET", "POST"}, Timeout: Duration(300 * time.Second)},
+			{Path: "/chat/replay", Service: "agent", Methods: []string{"POST"}, Timeout: Duration(300 * time.Second)},
+			{Path: "/chat/recordings/", Service: "agent", Methods: []string{"GET", "DELETE"}},
+			{Path: "/chat/recordings", Service: "agent", Methods: []string{"GET"}},
+			{Path: "/chat/", Service: "agent", Methods: []string{"GET"}},
+			{Path: "/providers", Service: "agent", Methods: []string{"GET", "POST"}, CacheTTL: Duration(30 * time.Second), Invalidates: []string{"/providers", "/cloud-models", "/models"}},
+			{Path: "/providers/", Service: "agent", Methods: []string{"GET"}},
+			{Path: "/cloud-models", Service: "agent", Methods: []string{"GET"}, CacheTTL: Duration(60 * time.Second)},
+			// Загрузка модели Ollama отдаёт прогресс потоком SSE и может идти долго
+			{Path: "/ollama/", Service: "agent", Methods: []string{"GET", "POST", "DELETE"}, Timeout: Duration(2 * time.Hour), Invalidates: []string{"/models", "/providers"}},
+			{Path: "/workspaces", Service: "agent", Methods: []string{"GET", "POST", "DELETE"}},
+			{Path: "/workspace/", Service: "agent", Methods: []string{"GET", "POST"}},
+			{Path: "/env-vars/", Service: "agent", Methods: []string{"DELETE"}, Auth: true},
+			{Path: "/env-vars", Service: "agent", Methods: []string{"GET", "POST"}, Auth: true},
+			{Path: "/watchdog/check", Service: "agent", Methods: []string{"POST"}, Auth: true},
+			{Path: "/watchdog", Service: "agent", Methods: []string{"GET"}},
+			{Path: "/maintenance/run", Service: "agent", Methods: []string{"POST"}, Auth: true},
+			{Path: "/maintenance", Service: "agent", Methods: []string{"GET"}},
+			// Резервная копия и восстановление стека — только с токеном клиента
+			{Path: "/admin/backup/", Service: "agent", Methods: []string{"GET"}, Auth: true, Timeout: Duration(time.Hour)},
+			{Path: "/admin/backup", Service: "agent", Methods: []string{"GET", "POST"}, Auth: true, Timeout: Duration(time.Hour)},
+			{Path: "/admin/restore", Service: "agent", Methods: []string{"POST"}, Auth: true, Timeout: Duration(time.Hour), MaxBody: 4 << 30},
+			{Path: "/artifacts/", Service: "agent", Methods: []string{"GET", "POST", "DELETE"}},
+			{Path: "/artifacts", Service: "agent", Methods: []string{"GET"}},
+			{Path: "/rollback/", Service: "agent", Methods: []string{"GET", "POST"}, Auth: true},
+			{Path: "/rollback", Service: "agent", Methods: []string{"GET", "POST"}, Auth: true},
+			{Path: "/conversations/", Service: "agent", Methods: []string{"GET", "PATCH", "DELETE"}},
+			{Path: "/conversations", Service: "agent", Methods: []string{"GET", "POST"}},
+			{Path: "/tasks", Service: "agent", Methods: []string{"GET", "POST"}},
+			{Path: "/tasks/", Service: "agent", Methods: []string{"GET", "POST", "DELETE"}},
+			{Path: "/learning-stats", Service: "agent", Methods: []string{"GET"}},
+			{Path: "/learnings/", Service: "agent", Methods: []string{"GET", "POST", "PATCH", "DELETE"}},
+			// Яндекс.Диск — облачное хранилище (tools-service)
+			{Path: "/ydisk/", Service: "tools", Methods: []string{"GET", "POST", "DELETE"}},
+			{Path: "/uploads/", Service: "agent", Methods: []string{"GET"}},
+			// Пересборка коллекции ChromaDB считает эмбеддинги всех документов
+			{Path: "/rag/ask", Service: "agent", Methods: []string{"POST"}, Timeout: Duration(300 * time.Second)},
+			{Path: "/rag/admin/", Service: "agent", Methods: []string{"GET", "POST"}, Auth: true, Timeout: Duration(30 * time.Minute)},
+			{Path: "/rag/", Service: "agent", Methods: all, MaxBody: 100 << 20},
+			{Path: "/scenario-metrics", Service: "agent", Methods: []string{"GET"}},
+			{Path: "/autoskill/", Service: "agent", Methods: []string{"GET"}},
+			{Path: "/logs", Service: "agent", Methods: []string{"GET", "POST", "PATCH", "DELETE"}},
+			// Конфигурация сервиса: просмотр без секретов, перезагрузка — только с токеном
+			{Path: "/config", Service: "agent", Methods: []string{"GET"}},
+			{Path: "/config/reload", Service: "agent", Methods: []string{"POST"}, Auth: true},
+			// Очередь фоновых заданий: список, постановка, повтор и отмена — только с токеном
+			{Path: "/jobs", Service: "agent", Methods: []string{"GET", "POST"}, Auth: true},
+			{Path: "/jobs/", Service: "agent", Methods: []string{"GET", "POST", "DELETE"}, Auth: true},
+			// Оценки ответов (👍/👎) и статистика по моделям
+			{Path: "/feedback/stats", Service: "agent", Methods: []string{"GET"}},
+			{Path: "/router/stats", Service: "agent", Methods: []string{"GET"}},
+			{Path: "/feedback", Service: "agent", Methods: []string{"POST"}},
+			{Path: "/intents", Service: "agent", Methods: []string{"GET", "POST"}},
+			// Расход токенов и бюджеты облачных моделей
+			{Path: "/usage/budgets", Service: "agent", Methods: []string{"GET", "POST", "DELETE"}},
+			{Path: "/usage", Service: "agent", Methods: []string{"GET"}},
+			// Кэш ответов LLM
+			{Path: "/response-cache", Service: "agent", Methods: []string{"GET", "DELETE"}},
+			// Skill Engine и Graph Engine (agent-service → memory-service)
+			{Path: "/skills/search", Service: "agent", Methods: []string{"POST"}},
+			{Path: "/skills/from-dialog", Service: "agent", Methods: []string{"POST"}, Auth: true},
+			{Path: "/skills/", Service: "agent", Methods: []string{"GET", "POST", "PUT", "DELETE"}, AuthMethods: []string{"POST", "PUT", "DELETE"}},
+			{Path: "/skills", Service: "agent", Methods: []string{"GET", "POST"}, AuthMethods: []string{"POST"}},
+			{Path: "/graph/relationships/", Service: "agent", Methods: []string{"DELETE"}},
+			{Path: "/graph/relationships", Service: "agent", Methods: []string{"GET", "POST"}},
+			{Path: "/graph/neighbors/", Service: "agent", Methods: []string{"GET"}},
+			{Path: "/graph/traverse", Service: "agent", Methods: []string{"POST"}},
+			{Path: "/embeddings/status", Service: "agent", Methods: []string{"GET"}},
+			// browser-service — браузер, ввод, поиск, краулер, проверка доступности.
+			// Скриншоты и краулинг могут идти долго, поэтому лимит увеличен.
+			{Path: "/browser/", Service: "browser", Methods: []string{"GET", "POST"}, Timeout: Duration(120 * time.Second)},
+			{Path: "/input/", Service: "browser", Methods: []string{"GET", "POST"}},
+			{Path: "/search", Service: "browser", Methods: []string{"POST"}},
+			{Path: "/search/", Service: "browser", Methods: []string{"POST"}},
+			{Path: "/crawler/", Service: "browser", Methods: []string{"GET", "POST"}, Timeout: Duration(120 * time.Second)},
+			{Path: "/access/", Service: "browser", Methods: []string{"POST"}},
+			// GET /browser/config проходит маршрутом /browser/; перезагрузка — только с токеном
+			{Path: "/browser/config/reload", Service: "browser", Methods: []string{"POST"}, Auth: true},
+			// Проверка здоровья через memory-service
+			{Path: "/health", Service: "memory", Methods: []string{"GET"}},
+		},
+	}
+}
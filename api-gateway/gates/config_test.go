@@ -0,0 +1,106 @@
+package gates
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestLoadConfig_RepoFileMatchesDefault(t *testing.T) {
+	cfg, err := LoadConfig("../routes.json")
+	if err != nil {
+		t.Fatalf("routes.json не загружается: %v", err)
+	}
+	if !reflect.DeepEqual(cfg, DefaultConfig()) {
+		t.Fatal("routes.json расходится со встроенной таблицей DefaultConfig()")
+	}
+}
+
+func TestLoadConfig_Timeout(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "routes.json")
+	data := `{"services":{"agent":{"url":"http://localhost:8083"}},
+		"routes":[{"path":"/chat","service":"agent","methods":["POST"],"timeout":"5m"},
+		          {"path":"/models","service":"agent","methods":["GET"]}]}`
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("ошибка загрузки: %v", err)
+	}
+	if got := cfg.Routes[0].TimeoutFor(); got != 5*time.Minute {
+		t.Errorf("ожидался таймаут 5m, получен %v", got)
+	}
+	if got := cfg.Routes[1].TimeoutFor(); got != DefaultRouteTimeout {
+		t.Errorf("ожидался таймаут по умолчанию, получен %v", got)
+	}
+}
+
+func TestConfig_Validate(t *testing.T) {
+	cases := map[string]Config{
+		"пустая таблица": {Services: map[string]ServiceConfig{"a": {URL: "http://a"}}},
+		"неизвестный сервис": {
+			Services: map[string]ServiceConfig{"a": {URL: "http://a"}},
+			Routes:   []RouteConfig{{Path: "/x", Service: "b", Methods: []string{"GET"}}},
+		},
+		"дубликат пути": {
+			Services: map[string]ServiceConfig{"a": {URL: "http://a"}},
+			Routes: []RouteConfig{
+				{Path: "/x", Service: "a", Methods: []string{"GET"}},
+				{Path: "/x", Service: "a", Methods: []string{"POST"}},
+			},
+		},
+		"без методов": {
+			Services: map[string]ServiceConfig{"a": {URL: "http://a"}},
+			Routes:   []RouteConfig{{Path: "/x", Service: "a"}},
+		},
+		"auth_methods вне methods": {
+			Services: map[string]ServiceConfig{"a": {URL: "http://a"}},
+			Routes:   []RouteConfig{{Path: "/x", Service: "a", Methods: []string{"GET"}, AuthMethods: []string{"POST"}}},
+		},
+		"путь без слеша": {
+			Services: map[string]ServiceConfig{"a": {URL: "http://a"}},
+			Routes:   []RouteConfig{{Path: "x", Service: "a", Methods: []string{"GET"}}},
+		},
+	}
+	for name, cfg := range cases {
+		if err := cfg.Validate(); err == nil {
+			t.Errorf("%s: ожидалась ошибка валидации", name)
+		}
+	}
+}
+
+func TestConfig_ServiceURL_EnvOverride(t *testing.T) {
+	cfg := DefaultConfig()
+	t.Setenv("AGENT_SERVICE_URL", "http://agent:9000")
+	u, err := cfg.ServiceURL("agent")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.Host != "agent:9000" {
+		t.Errorf("ожидался хост из AGENT_SERVICE_URL, получен %q", u.Host)
+	}
+}
+
+func TestRouter_Swap(t *testing.T) {
+	first := http.NewServeMux()
+	first.HandleFunc("/x", func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) })
+	router := NewRouter(first)
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("GET", "/x", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("ожидался 200, получен %d", rr.Code)
+	}
+
+	router.Swap(http.NewServeMux())
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("GET", "/x", nil))
+	if rr.Code != http.StatusNotFound {
+		t.Fatalf("после замены ожидался 404, получен %d", rr.Code)
+	}
+}
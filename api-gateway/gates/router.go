@@ -0,0 +1,30 @@
+package gates
+
+import (
+	"net/http"
+	"sync/atomic"
+)
+
+// Router — HTTP-обработчик с атомарно заменяемой таблицей маршрутов.
+// Позволяет перезагружать конфигурацию (SIGHUP) без перезапуска сервера:
+// запросы, начатые до замены, дорабатывают на старой таблице.
+type Router struct {
+	mux atomic.Pointer[http.ServeMux]
+}
+
+// NewRouter — создаёт Router с начальной таблицей маршрутов.
+func NewRouter(mux *http.ServeMux) *Router {
+	r := &Router{}
+	r.mux.Store(mux)
+	return r
+}
+
+// Swap — заменяет таблицу маршрутов.
+func (r *Router) Swap(mux *http.ServeMux) {
+	r.mux.Store(mux)
+}
+
+// ServeHTTP — передаёт запрос текущей таблице маршрутов.
+func (r *Router) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	r.mux.Load().ServeHTTP(w, req)
+}
@@ -1,12 +1,17 @@
 package gates
 
 import (
+	"errors"
+	"fmt"
+	"log"
 	"net"
 	"net/http"
 	"net/http/httputil"
 	"net/url"
 	"strings"
 	"time"
+
+	"github.com/neo-2022/openclaw-memory/api-gateway/internal/apierror"
 )
 
 // Route определяет одно прокси-правило.
@@ -22,10 +27,35 @@ var longTransport = &http.Transport{
 	IdleConnTimeout:       90 * time.Second,
 }
 
+// stripBackendCORS — удаляет CORS-заголовки бэкенда: за CORS отвечает шлюз,
+// а повторный Access-Control-Allow-Origin (например, "*" от browser-service)
+// браузер считает ошибкой. Content-Language шлюз тоже выставляет сам.
+func stripBackendCORS(resp *http.Response) error {
+	for _, h := range []string{"Access-Control-Allow-Origin", "Access-Control-Allow-Methods", "Access-Control-Allow-Headers", "Content-Language"} {
+		resp.Header.Del(h)
+	}
+	return nil
+}
+
+// proxyErrorHandler — ответ при ошибке проксирования в едином формате apierror.
+// Превышение лимита тела (http.MaxBytesReader) отдаётся как 413, остальное — 502.
+func proxyErrorHandler(w http.ResponseWriter, r *http.Request, err error) {
+	requestID := r.Header.Get("X-Request-ID")
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		apierror.PayloadTooLarge(w, requestID, fmt.Sprintf("тело запроса превышает лимит %d байт", tooLarge.Limit), "Уменьшите размер загружаемого файла")
+		return
+	}
+	log.Printf("[PROXY] ошибка проксирования %s %s: %v", r.Method, r.URL.Path, err)
+	apierror.BadGateway(w, requestID, "сервис не ответил", err.Error())
+}
+
 // NewCustomProxy создает обратный прокси для заданного целевого URL с удалением префикса.
 func NewCustomProxy(target *url.URL, prefix string) *httputil.ReverseProxy {
 	return &httputil.ReverseProxy{
-		Transport: longTransport,
+		Transport:      longTransport,
+		ModifyResponse: stripBackendCORS,
+		ErrorHandler:   proxyErrorHandler,
 		Director: func(req *http.Request) {
 			req.URL.Scheme = target.Scheme
 			req.URL.Host = target.Host
@@ -38,10 +68,27 @@ func NewCustomProxy(target *url.URL, prefix string) *httputil.ReverseProxy {
 	}
 }
 
+// NewStreamingProxy — обратный прокси для SSE/WebSocket-маршрутов:
+// ответ передаётся клиенту без буферизации (FlushInterval = -1).
+// WebSocket-апгрейд httputil.ReverseProxy обрабатывает сам, если
+// ResponseWriter поддерживает Hijack (через Unwrap в обёртках middleware).
+func NewStreamingProxy(target *url.URL, prefix string, strip bool) *httputil.ReverseProxy {
+	var p *httputil.ReverseProxy
+	if strip {
+		p = NewCustomProxy(target, prefix)
+	} else {
+		p = NewProxyWithoutStrip(target)
+	}
+	p.FlushInterval = -1
+	return p
+}
+
 // NewProxyWithoutStrip создает обратный прокси, который не изменяет путь запроса.
 func NewProxyWithoutStrip(target *url.URL) *httputil.ReverseProxy {
 	return &httputil.ReverseProxy{
-		Transport: longTransport,
+		Transport:      longTransport,
+		ModifyResponse: stripBackendCORS,
+		ErrorHandler:   proxyErrorHandler,
 		Director: func(req *http.Request) {
 			req.URL.Scheme = target.Scheme
 			req.URL.Host = target.Host
@@ -0,0 +1,24 @@
+package gates
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// TestProxyErrorHandler_BodyTooLarge — превышение лимита при чтении тела прокси превращается в 413.
+func TestProxyErrorHandler_BodyTooLarge(t *testing.T) {
+	req := httptest.NewRequest("POST", "/rag/add", nil)
+	rr := httptest.NewRecorder()
+	proxyErrorHandler(rr, req, &http.MaxBytesError{Limit: 10})
+	if rr.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("ожидался 413, получен %d", rr.Code)
+	}
+
+	rr = httptest.NewRecorder()
+	proxyErrorHandler(rr, req, http.ErrHandlerTimeout)
+	if rr.Code != http.StatusBadGateway || !strings.Contains(rr.Body.String(), "BAD_GATEWAY") {
+		t.Fatalf("ожидался 502 BAD_GATEWAY, получен %d: %s", rr.Code, rr.Body.String())
+	}
+}
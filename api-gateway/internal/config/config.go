@@ -0,0 +1,322 @@
+// Package config — централизованная конфигурация API Gateway.
+//
+// Параметры собираются в три слоя: значения по умолчанию → YAML-файл
+// (GATEWAY_CONFIG_FILE, необязателен) → переменные окружения. Конфигурация
+// проверяется при старте (порт, лимиты, язык); часть параметров (Tunable)
+// применяется на лету через Reload — шлюз пересобирает таблицу маршрутов.
+// Таблица маршрутов и адреса сервисов по-прежнему задаются GATEWAY_ROUTES_FILE
+// (см. пакет gates).
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/neo-2022/openclaw-memory/api-gateway/internal/i18n"
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultRoutesFile — файл маршрутов по умолчанию; если его нет, шлюз
+// использует встроенную таблицу.
+const DefaultRoutesFile = "routes.json"
+
+// Config — структура конфигурации API Gateway.
+type Config struct {
+	Port         string `yaml:"port" json:"port"`                   // Порт шлюза (по умолчанию 8080)
+	RoutesFile   string `yaml:"routes_file" json:"routes_file"`     // JSON-файл маршрутов (перечитывается при Reload)
+	CacheEnabled bool   `yaml:"cache_enabled" json:"cache_enabled"` // Кэш GET-ответов для маршрутов с cache_ttl
+
+	RateLimitRPS    int           `yaml:"rate_limit_rps" json:"rate_limit_rps"`       // Запросов с одного адреса за окно
+	RateLimitWindow time.Duration `yaml:"rate_limit_window" json:"rate_limit_window"` // Окно ограничителя частоты
+
+	Tunable `yaml:",inline"`
+}
+
+// Tunable — параметры, которые применяются на лету при Reload.
+type Tunable struct {
+	CORSAllowedOrigins string `yaml:"cors_allowed_origins" json:"cors_allowed_origins"` // Белый список доменов CORS через запятую
+	AuthTokens         string `yaml:"auth_tokens" json:"auth_tokens"`                   // Токены клиентов для маршрутов с auth: true (через запятую)
+	MaxBodyBytes       int64  `yaml:"max_body_bytes" json:"max_body_bytes"`             // Лимит тела запроса по умолчанию, превышение — 413
+	GzipEnabled        bool   `yaml:"gzip_enabled" json:"gzip_enabled"`                 // Сжатие текстовых ответов (br или gzip по Accept-Encoding)
+	DefaultLanguage    string `yaml:"default_language" json:"default_language"`         // ru или en
+}
+
+// Defaults — конфигурация по умолчанию.
+func Defaults() *Config {
+	return &Config{
+		Port:            "8080",
+		RoutesFile:      DefaultRoutesFile,
+		CacheEnabled:    true,
+		RateLimitRPS:    60,
+		RateLimitWindow: time.Minute,
+		Tunable: Tunable{
+			CORSAllowedOrigins: "http://localhost:3000,http://localhost:5173",
+			MaxBodyBytes:       10 << 20,
+			GzipEnabled:        true,
+			DefaultLanguage:    i18n.Russian,
+		},
+	}
+}
+
+// Load — загружает конфигурацию: значения по умолчанию, затем YAML-файл
+// из GATEWAY_CONFIG_FILE (если задан), затем переменные окружения.
+// Возвращает ошибку, если файл не читается или значения некорректны.
+func Load() (*Config, error) {
+	c := Defaults()
+	if path := os.Getenv("GATEWAY_CONFIG_FILE"); path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("чтение файла конфигурации: %w", err)
+		}
+		if err := yaml.Unmarshal(data, c); err != nil {
+			return nil, fmt.Errorf("разбор файла конфигурации %s: %w", path, err)
+		}
+	}
+	var errs []error
+	envString(&c.Port, "GATEWAY_PORT")
+	envString(&c.RoutesFile, "GATEWAY_ROUTES_FILE")
+	errs = append(errs, envBool(&c.CacheEnabled, "GATEWAY_CACHE_ENABLED"))
+	errs = append(errs, envInt(&c.RateLimitRPS, "RATE_LIMIT_RPS"))
+	errs = append(errs, envDuration(&c.RateLimitWindow, "RATE_LIMIT_WINDOW"))
+	envString(&c.CORSAllowedOrigins, "CORS_ALLOWED_ORIGINS")
+	envString(&c.AuthTokens, "GATEWAY_AUTH_TOKENS")
+	errs = append(errs, envInt64(&c.MaxBodyBytes, "GATEWAY_MAX_BODY_BYTES"))
+	errs = append(errs, envBool(&c.GzipEnabled, "GATEWAY_GZIP_ENABLED"))
+	envString(&c.DefaultLanguage, "DEFAULT_LANGUAGE")
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Validate — проверяет конфигурацию: порт, лимиты и язык.
+// Возвращает все найденные ошибки сразу.
+func (c *Config) Validate() error {
+	var errs []error
+	if p, err := strconv.Atoi(c.Port); err != nil || p < 1 || p > 65535 {
+		errs = append(errs, fmt.Errorf("port: некорректный порт %q", c.Port))
+	}
+	if c.RoutesFile == "" {
+		errs = append(errs, errors.New("routes_file: не задан файл маршрутов"))
+	}
+	if c.RateLimitRPS <= 0 {
+		errs = append(errs, errors.New("rate_limit_rps: должен быть больше нуля"))
+	}
+	if c.RateLimitWindow <= 0 {
+		errs = append(errs, errors.New("rate_limit_window: должен быть больше нуля"))
+	}
+	if c.MaxBodyBytes <= 0 {
+		errs = append(errs, errors.New("max_body_bytes: должен быть больше нуля"))
+	}
+	if !i18n.Supported(c.DefaultLanguage) {
+		errs = append(errs, fmt.Errorf("default_language: %q, ожидается ru или en", c.DefaultLanguage))
+	}
+	return errors.Join(errs...)
+}
+
+// AllowedOrigins — множество доменов из CORSAllowedOrigins; пробелы вокруг
+// доменов и пустые элементы отбрасываются.
+func (c *Config) AllowedOrigins() map[string]struct{} {
+	return splitSet(c.CORSAllowedOrigins)
+}
+
+// AuthTokenSet — множество токенов клиентов из AuthTokens.
+func (c *Config) AuthTokenSet() map[string]struct{} {
+	return splitSet(c.AuthTokens)
+}
+
+// splitSet — непустые элементы списка через запятую.
+func splitSet(s string) map[string]struct{} {
+	out := map[string]struct{}{}
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			out[v] = struct{}{}
+		}
+	}
+	return out
+}
+
+// secretMask — замена секретов в Sanitized.
+const secretMask = "***"
+
+// Sanitized — копия конфигурации без секретов, пригодная для GET /gateway/config.
+func (c *Config) Sanitized() Config {
+	s := *c
+	if s.AuthTokens != "" {
+		s.AuthTokens = secretMask
+	}
+	return s
+}
+
+var (
+	current  atomic.Pointer[Config]
+	reloadMu sync.Mutex
+	hooksMu  sync.Mutex
+	hooks    []func(*Config)
+)
+
+// Current — действующая конфигурация. До Set возвращает значения по умолчанию.
+func Current() *Config {
+	if c := current.Load(); c != nil {
+		return c
+	}
+	return Defaults()
+}
+
+// Set — делает c действующей конфигурацией (вызывается при старте).
+func Set(c *Config) {
+	current.Store(c)
+}
+
+// OnReload — регистрирует обработчик, вызываемый после применения Reload
+// (например, для пересборки таблицы маршрутов с новыми параметрами).
+func OnReload(fn func(*Config)) {
+	hooksMu.Lock()
+	hooks = append(hooks, fn)
+	hooksMu.Unlock()
+}
+
+// ReloadResult — итог перезагрузки конфигурации.
+type ReloadResult struct {
+	Applied         []string `json:"applied"`          // Изменённые параметры, применённые на лету
+	RestartRequired []string `json:"restart_required"` // Изменённые параметры, требующие перезапуска
+}
+
+// Reload — перечитывает файл и окружение и применяет изменения Tunable.
+// Остальные изменения не применяются и перечисляются в RestartRequired.
+// При ошибке загрузки или проверки действующая конфигурация не меняется.
+func Reload() (ReloadResult, error) {
+	reloadMu.Lock()
+	defer reloadMu.Unlock()
+	res := ReloadResult{Applied: []string{}, RestartRequired: []string{}}
+	fresh, err := Load()
+	if err != nil {
+		return res, err
+	}
+	if err := fresh.Validate(); err != nil {
+		return res, err
+	}
+	old := Current()
+	next := *old
+	next.Tunable = fresh.Tunable
+	res.Applied = diff(old.Tunable, fresh.Tunable)
+
+	oldRest, freshRest := *old, *fresh
+	oldRest.Tunable, freshRest.Tunable = Tunable{}, Tunable{}
+	res.RestartRequired = diff(oldRest, freshRest)
+
+	current.Store(&next)
+	if len(res.Applied) > 0 {
+		hooksMu.Lock()
+		fns := append([]func(*Config){}, hooks...)
+		hooksMu.Unlock()
+		for _, fn := range fns {
+			fn(&next)
+		}
+	}
+	return res, nil
+}
+
+// Reloadable — имена (yaml-теги) параметров, применяемых без перезапуска.
+func Reloadable() []string {
+	return fieldNames(reflect.TypeOf(Tunable{}))
+}
+
+// diff — имена (yaml-теги) полей, значения которых различаются в a и b.
+func diff(a, b interface{}) []string {
+	va, vb := reflect.ValueOf(a), reflect.ValueOf(b)
+	t := va.Type()
+	changed := []string{}
+	for i := 0; i < t.NumField(); i++ {
+		if t.Field(i).Anonymous {
+			continue
+		}
+		if !reflect.DeepEqual(va.Field(i).Interface(), vb.Field(i).Interface()) {
+			changed = append(changed, yamlName(t.Field(i)))
+		}
+	}
+	return changed
+}
+
+// fieldNames — yaml-имена всех полей структуры t.
+func fieldNames(t reflect.Type) []string {
+	names := make([]string, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		names = append(names, yamlName(t.Field(i)))
+	}
+	return names
+}
+
+func yamlName(f reflect.StructField) string {
+	name, _, _ := strings.Cut(f.Tag.Get("yaml"), ",")
+	return name
+}
+
+// envString — записывает в dst первое непустое значение из переменных keys.
+func envString(dst *string, keys ...string) {
+	for _, k := range keys {
+		if v := os.Getenv(k); v != "" {
+			*dst = v
+			return
+		}
+	}
+}
+
+func envInt(dst *int, key string) error {
+	v := os.Getenv(key)
+	if v == "" {
+		return nil
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(v))
+	if err != nil {
+		return fmt.Errorf("%s: ожидается целое число, получено %q", key, v)
+	}
+	*dst = n
+	return nil
+}
+
+func envInt64(dst *int64, key string) error {
+	v := os.Getenv(key)
+	if v == "" {
+		return nil
+	}
+	n, err := strconv.ParseInt(strings.TrimSpace(v), 10, 64)
+	if err != nil {
+		return fmt.Errorf("%s: ожидается целое число, получено %q", key, v)
+	}
+	*dst = n
+	return nil
+}
+
+func envBool(dst *bool, key string) error {
+	v := os.Getenv(key)
+	if v == "" {
+		return nil
+	}
+	b, err := strconv.ParseBool(strings.TrimSpace(v))
+	if err != nil {
+		return fmt.Errorf("%s: ожидается true или false, получено %q", key, v)
+	}
+	*dst = b
+	return nil
+}
+
+func envDuration(dst *time.Duration, key string) error {
+	v := os.Getenv(key)
+	if v == "" {
+		return nil
+	}
+	d, err := time.ParseDuration(strings.TrimSpace(v))
+	if err != nil {
+		return fmt.Errorf("%s: ожидается длительность (30s, 5m), получено %q", key, v)
+	}
+	*dst = d
+	return nil
+}
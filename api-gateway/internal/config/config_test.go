@@ -0,0 +1,86 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestLoadLayers — переменные окружения перекрывают YAML-файл, файл — значения по умолчанию.
+func TestLoadLayers(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "gateway.yaml")
+	yml := "port: \"9000\"\nrate_limit_window: 30s\ncors_allowed_origins: https://a.example\n"
+	if err := os.WriteFile(path, []byte(yml), 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("GATEWAY_CONFIG_FILE", path)
+	t.Setenv("CORS_ALLOWED_ORIGINS", " https://b.example, ,https://c.example")
+	t.Setenv("GATEWAY_GZIP_ENABLED", "false")
+
+	c, err := Load()
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if c.Port != "9000" || c.RateLimitWindow != 30*time.Second || c.GzipEnabled {
+		t.Errorf("значения из файла и окружения не применены: %+v", c)
+	}
+	origins := c.AllowedOrigins()
+	if _, ok := origins["https://b.example"]; !ok || len(origins) != 2 {
+		t.Errorf("CORS_ALLOWED_ORIGINS из окружения должен перекрыть файл, получено %v", origins)
+	}
+	if c.RoutesFile != DefaultRoutesFile || c.RateLimitRPS != 60 {
+		t.Errorf("незаданные параметры должны остаться по умолчанию: %+v", c)
+	}
+
+	t.Setenv("GATEWAY_MAX_BODY_BYTES", "много")
+	if _, err := Load(); err == nil || !strings.Contains(err.Error(), "GATEWAY_MAX_BODY_BYTES") {
+		t.Fatalf("ожидалась ошибка с именем переменной, получено %v", err)
+	}
+}
+
+// TestValidate — проверка порта, лимитов и языка; все ошибки возвращаются сразу.
+func TestValidate(t *testing.T) {
+	if err := Defaults().Validate(); err != nil {
+		t.Fatalf("конфигурация по умолчанию должна быть корректной: %v", err)
+	}
+	c := Defaults()
+	c.Port, c.RateLimitRPS, c.MaxBodyBytes, c.DefaultLanguage = "80a", 0, 0, "de"
+	err := c.Validate()
+	for _, want := range []string{"port", "rate_limit_rps", "max_body_bytes", "default_language"} {
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("в ошибке нет %q: %v", want, err)
+		}
+	}
+}
+
+// TestReload — на лету применяются только Tunable, токены скрываются в Sanitized.
+func TestReload(t *testing.T) {
+	Set(Defaults())
+	defer current.Store(nil)
+	calls := 0
+	OnReload(func(*Config) { calls++ })
+	defer func() { hooks = nil }()
+
+	t.Setenv("GATEWAY_AUTH_TOKENS", "t1,t2")
+	t.Setenv("RATE_LIMIT_RPS", "10")
+	res, err := Reload()
+	if err != nil {
+		t.Fatalf("Reload: %v", err)
+	}
+	if len(Current().AuthTokenSet()) != 2 || Current().RateLimitRPS != 60 || calls != 1 {
+		t.Errorf("неверная конфигурация после перезагрузки: %+v", Current())
+	}
+	if len(res.Applied) != 1 || res.Applied[0] != "auth_tokens" || len(res.RestartRequired) != 1 || res.RestartRequired[0] != "rate_limit_rps" {
+		t.Errorf("неверный итог: %+v", res)
+	}
+	if s := Current().Sanitized(); s.AuthTokens != secretMask {
+		t.Errorf("токены не скрыты: %q", s.AuthTokens)
+	}
+
+	t.Setenv("DEFAULT_LANGUAGE", "de")
+	if _, err := Reload(); err == nil {
+		t.Fatal("некорректная конфигурация должна отклоняться")
+	}
+}
@@ -0,0 +1,135 @@
+// Package health — проверка готовности сервиса (readiness) для /ready.
+//
+// /health отвечает «процесс жив» (liveness) и не зависит от окружения.
+// /ready выполняет набор проверок зависимостей параллельно и возвращает 503,
+// если не прошла хотя бы одна обязательная, — так Kubernetes и systemd
+// watchdog не направляют трафик в сервис, который не может его обслужить.
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// CheckTimeout — лимит на одну проверку; у каждой проверки свой срок.
+const CheckTimeout = 3 * time.Second
+
+// Check — одна проверка готовности.
+type Check struct {
+	Name     string                          // Имя в ответе (database, tools-service…)
+	Required bool                            // Провал обязательной проверки делает сервис неготовым
+	Run      func(ctx context.Context) error // nil-ошибка — проверка пройдена
+}
+
+// Result — итог одной проверки.
+type Result struct {
+	OK        bool   `json:"ok"`
+	Required  bool   `json:"required"`
+	LatencyMs int64  `json:"latency_ms"`
+	Error     string `json:"error,omitempty"`
+}
+
+// Report — ответ /ready.
+type Report struct {
+	Status  string            `json:"status"` // ready или not_ready
+	Service string            `json:"service"`
+	Checks  map[string]Result `json:"checks"`
+}
+
+// Run — выполняет проверки параллельно, каждую с лимитом CheckTimeout:
+// медленная проверка не отнимает время у остальных.
+func Run(ctx context.Context, service string, checks []Check) Report {
+	report := Report{Status: "ready", Service: service, Checks: make(map[string]Result, len(checks))}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, c := range checks {
+		wg.Add(1)
+		go func(c Check) {
+			defer wg.Done()
+			checkCtx, cancel := context.WithTimeout(ctx, CheckTimeout)
+			defer cancel()
+			start := time.Now()
+			err := c.Run(checkCtx)
+			res := Result{OK: err == nil, Required: c.Required, LatencyMs: time.Since(start).Milliseconds()}
+			if err != nil {
+				res.Error = err.Error()
+			}
+			mu.Lock()
+			report.Checks[c.Name] = res
+			if err != nil && c.Required {
+				report.Status = "not_ready"
+			}
+			mu.Unlock()
+		}(c)
+	}
+	wg.Wait()
+	return report
+}
+
+// Handler — HTTP-обработчик /ready: 200 при готовности, 503 — иначе.
+func Handler(service string, checks func() []Check) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		report := Run(r.Context(), service, checks())
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		if report.Status != "ready" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		json.NewEncoder(w).Encode(report)
+	}
+}
+
+// HTTPCheck — проверка доступности зависимого сервиса: GET url должен вернуть 2xx.
+func HTTPCheck(name, url string, required bool) Check {
+	return Check{
+		Name:     name,
+		Required: required,
+		Run: func(ctx context.Context) error {
+			req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+			if err != nil {
+				return err
+			}
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				return err
+			}
+			resp.Body.Close()
+			if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+				return fmt.Errorf("%s ответил %d", url, resp.StatusCode)
+			}
+			return nil
+		},
+	}
+}
+
+// GRPCCheck — проверка внутреннего gRPC API сервиса по стандартному
+// протоколу grpc.health.v1: addr (host:port) должен ответить SERVING.
+func GRPCCheck(name, addr string, required bool) Check {
+	return Check{
+		Name:     name,
+		Required: required,
+		Run: func(ctx context.Context) error {
+			conn, err := grpc.NewClient(addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+			if err != nil {
+				return err
+			}
+			defer conn.Close()
+			resp, err := healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{})
+			if err != nil {
+				return err
+			}
+			if resp.GetStatus() != healthpb.HealthCheckResponse_SERVING {
+				return fmt.Errorf("gRPC %s: %s", addr, resp.GetStatus())
+			}
+			return nil
+		},
+	}
+}
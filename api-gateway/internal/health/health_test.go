@@ -0,0 +1,121 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	grpchealth "google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// TestHandlerReady — все проверки пройдены: 200 и status=ready.
+func TestHandlerReady(t *testing.T) {
+	checks := func() []Check {
+		return []Check{{Name: "db", Required: true, Run: func(context.Context) error { return nil }}}
+	}
+	rec := httptest.NewRecorder()
+	Handler("svc", checks)(rec, httptest.NewRequest(http.MethodGet, "/ready", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("ожидался 200, получен %d", rec.Code)
+	}
+	var rep Report
+	if err := json.Unmarshal(rec.Body.Bytes(), &rep); err != nil {
+		t.Fatalf("некорректный JSON: %v", err)
+	}
+	if rep.Status != "ready" || !rep.Checks["db"].OK {
+		t.Errorf("неожиданный отчёт: %+v", rep)
+	}
+}
+
+// TestHandlerRequiredFailure — провал обязательной проверки даёт 503,
+// провал необязательной — нет.
+func TestHandlerRequiredFailure(t *testing.T) {
+	fail := func(context.Context) error { return errors.New("недоступен") }
+	optional := func() []Check { return []Check{{Name: "ollama", Run: fail}} }
+	rec := httptest.NewRecorder()
+	Handler("svc", optional)(rec, httptest.NewRequest(http.MethodGet, "/ready", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("необязательная проверка не должна влиять на готовность, получен %d", rec.Code)
+	}
+
+	required := func() []Check { return []Check{{Name: "db", Required: true, Run: fail}} }
+	rec = httptest.NewRecorder()
+	Handler("svc", required)(rec, httptest.NewRequest(http.MethodGet, "/ready", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("ожидался 503, получен %d", rec.Code)
+	}
+	var rep Report
+	json.Unmarshal(rec.Body.Bytes(), &rep)
+	if rep.Status != "not_ready" || rep.Checks["db"].Error != "недоступен" {
+		t.Errorf("неожиданный отчёт: %+v", rep)
+	}
+}
+
+// TestHTTPCheck — 2xx считается успехом, иной статус — ошибкой.
+func TestHTTPCheck(t *testing.T) {
+	ok := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer ok.Close()
+	bad := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer bad.Close()
+
+	if err := HTTPCheck("ok", ok.URL, true).Run(context.Background()); err != nil {
+		t.Errorf("ожидался успех: %v", err)
+	}
+	if err := HTTPCheck("bad", bad.URL, true).Run(context.Background()); err == nil {
+		t.Error("ожидалась ошибка для статуса 503")
+	}
+}
+
+// TestGRPCCheck — SERVING считается успехом, NOT_SERVING и закрытый порт — ошибкой.
+func TestGRPCCheck(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := grpc.NewServer()
+	hs := grpchealth.NewServer()
+	healthpb.RegisterHealthServer(srv, hs)
+	go srv.Serve(lis)
+	defer srv.Stop()
+
+	addr := lis.Addr().String()
+	if err := GRPCCheck("tools-grpc", addr, false).Run(context.Background()); err != nil {
+		t.Errorf("ожидался успех: %v", err)
+	}
+	hs.Shutdown()
+	if err := GRPCCheck("tools-grpc", addr, false).Run(context.Background()); err == nil {
+		t.Error("ожидалась ошибка для NOT_SERVING")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), CheckTimeout)
+	defer cancel()
+	if err := GRPCCheck("closed", "127.0.0.1:1", false).Run(ctx); err == nil {
+		t.Error("ожидалась ошибка для закрытого порта")
+	}
+}
+
+// TestRunPerCheckTimeout — каждая проверка получает собственный срок CheckTimeout.
+func TestRunPerCheckTimeout(t *testing.T) {
+	var deadlines [2]time.Time
+	check := func(i int) Check {
+		return Check{Name: string(rune('a' + i)), Run: func(ctx context.Context) error {
+			deadlines[i], _ = ctx.Deadline()
+			return nil
+		}}
+	}
+	start := time.Now()
+	Run(context.Background(), "svc", []Check{check(0), check(1)})
+	for i, d := range deadlines {
+		if d.IsZero() || d.Sub(start) > CheckTimeout+time.Second || d.Before(start.Add(CheckTimeout)) {
+			t.Errorf("проверка %d: срок %v, начало %v", i, d, start)
+		}
+	}
+}
@@ -0,0 +1,24 @@
+package i18n
+
+// en — английский каталог: русский текст → перевод.
+var en = map[string]string{
+	"внутренняя ошибка сервера":                                            "internal server error",
+	"Метод не поддерживается":                                              "Method not allowed",
+	"отсутствует Bearer-токен":                                             "Bearer token is missing",
+	"Добавьте заголовок Authorization: Bearer <token>":                     "Add the Authorization: Bearer <token> header",
+	"невалидный токен":                                                     "invalid token",
+	"Проверьте GATEWAY_AUTH_TOKENS":                                        "Check GATEWAY_AUTH_TOKENS",
+	"сервис недоступен":                                                    "service unavailable",
+	"сервис не ответил":                                                    "service did not respond",
+	"превышен лимит запросов":                                              "rate limit exceeded",
+	"Попробуйте повторить запрос позже":                                    "Try the request again later",
+	"тело запроса %d байт превышает лимит %d байт":                         "request body of %d bytes exceeds the %d byte limit",
+	"тело запроса превышает лимит %d байт":                                 "request body exceeds the %d byte limit",
+	"Уменьшите размер загружаемого файла или разбейте его на части":        "Reduce the upload size or split the file into parts",
+	"Уменьшите размер загружаемого файла":                                  "Reduce the upload size",
+	"Конфигурация не применена: %s":                                        "Configuration not applied: %s",
+	"Исправьте файл конфигурации, файл маршрутов или переменные окружения": "Fix the configuration file, the routes file or environment variables",
+}
+
+// enFragments — предложения, заменяемые внутри текста.
+var enFragments = map[string]string{}
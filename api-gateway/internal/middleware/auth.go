@@ -0,0 +1,52 @@
+package middleware
+
+import (
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/neo-2022/openclaw-memory/api-gateway/internal/apierror"
+)
+
+// AuthMiddleware — проверяет Bearer-токен клиента для маршрутов с auth: true
+// (токены — GATEWAY_AUTH_TOKENS, см. config.AuthTokenSet).
+// Если список токенов пуст, запрос пропускается (legacy-режим).
+// Preflight-запросы OPTIONS не проверяются, чтобы CORS продолжал работать.
+func AuthMiddleware(tokens map[string]struct{}) func(http.HandlerFunc) http.HandlerFunc {
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			if len(tokens) == 0 || r.Method == http.MethodOptions {
+				next(w, r)
+				return
+			}
+			requestID := r.Header.Get("X-Request-ID")
+			header := r.Header.Get("Authorization")
+			if !strings.HasPrefix(header, "Bearer ") {
+				apierror.Unauthorized(w, requestID, "отсутствует Bearer-токен", "Добавьте заголовок Authorization: Bearer <token>")
+				return
+			}
+			if _, ok := tokens[strings.TrimPrefix(header, "Bearer ")]; !ok {
+				slog.Warn("Невалидный токен клиента", slog.String("путь", r.URL.Path))
+				apierror.Unauthorized(w, requestID, "невалидный токен", "Проверьте GATEWAY_AUTH_TOKENS")
+				return
+			}
+			next(w, r)
+		}
+	}
+}
+
+// ForMethods — применяет mw только к запросам с методом из methods (auth_methods
+// маршрута); остальные запросы проходят без него.
+func ForMethods(methods []string, mw func(http.HandlerFunc) http.HandlerFunc) func(http.HandlerFunc) http.HandlerFunc {
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		guarded := mw(next)
+		return func(w http.ResponseWriter, r *http.Request) {
+			if slices.Contains(methods, r.Method) {
+				guarded(w, r)
+				return
+			}
+			next(w, r)
+		}
+	}
+}
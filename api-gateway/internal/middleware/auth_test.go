@@ -0,0 +1,32 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// TestForMethods — токен нужен только для методов из auth_methods.
+func TestForMethods(t *testing.T) {
+	mw := ForMethods([]string{http.MethodPost}, AuthMiddleware(map[string]struct{}{"secret": {}}))
+	h := mw(func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) })
+	cases := []struct {
+		method, token string
+		want          int
+	}{
+		{http.MethodGet, "", http.StatusOK},
+		{http.MethodPost, "", http.StatusUnauthorized},
+		{http.MethodPost, "secret", http.StatusOK},
+	}
+	for _, c := range cases {
+		req := httptest.NewRequest(c.method, "/prompts/files", nil)
+		if c.token != "" {
+			req.Header.Set("Authorization", "Bearer "+c.token)
+		}
+		rr := httptest.NewRecorder()
+		h(rr, req)
+		if rr.Code != c.want {
+			t.Errorf("%s с токеном %q: %d, ожидался %d", c.method, c.token, rr.Code, c.want)
+		}
+	}
+}
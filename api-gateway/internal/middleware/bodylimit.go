@@ -0,0 +1,33 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/neo-2022/openclaw-memory/api-gateway/internal/apierror"
+)
+
+// BodyLimitMiddleware — ограничивает размер тела запроса.
+//
+// Запрос с Content-Length больше maxBytes отклоняется сразу (413).
+// Тело без Content-Length (chunked) оборачивается в http.MaxBytesReader:
+// при превышении лимита чтение прерывается, и прокси отвечает 413
+// (см. gates.proxyErrorHandler). maxBytes <= 0 отключает проверку.
+func BodyLimitMiddleware(maxBytes int64) func(http.HandlerFunc) http.HandlerFunc {
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			if maxBytes <= 0 || r.Body == nil || r.Body == http.NoBody {
+				next(w, r)
+				return
+			}
+			if r.ContentLength > maxBytes {
+				apierror.PayloadTooLarge(w, r.Header.Get("X-Request-ID"),
+					fmt.Sprintf("тело запроса %d байт превышает лимит %d байт", r.ContentLength, maxBytes),
+					"Уменьшите размер загружаемого файла или разбейте его на части")
+				return
+			}
+			r.Body = http.MaxBytesReader(w, r.Body, maxBytes)
+			next(w, r)
+		}
+	}
+}
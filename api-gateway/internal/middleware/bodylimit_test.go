@@ -0,0 +1,29 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// TestBodyLimitMiddleware — тело больше лимита отклоняется с 413 до проксирования.
+func TestBodyLimitMiddleware(t *testing.T) {
+	called := false
+	h := BodyLimitMiddleware(10)(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	})
+	rr := httptest.NewRecorder()
+	h(rr, httptest.NewRequest("POST", "/chat", strings.NewReader(strings.Repeat("x", 50))))
+	if rr.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("ожидался 413, получен %d", rr.Code)
+	}
+	if called {
+		t.Error("бэкенд не должен вызываться при превышении лимита")
+	}
+
+	h(httptest.NewRecorder(), httptest.NewRequest("POST", "/chat", strings.NewReader("ok")))
+	if !called {
+		t.Error("запрос в пределах лимита должен проходить")
+	}
+}
@@ -0,0 +1,205 @@
+package middleware
+
+import (
+	"bytes"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxCachedBody — ответы крупнее этого размера не кэшируются.
+const maxCachedBody = 2 << 20
+
+// cacheEntry — сохранённый ответ бэкенда.
+type cacheEntry struct {
+	status  int
+	header  http.Header
+	body    []byte
+	expires time.Time
+}
+
+// ResponseCache — in-memory кэш ответов на идемпотентные GET-запросы.
+//
+// Ключ — путь и query-строка запроса на шлюзе. Записи живут TTL маршрута
+// и сбрасываются при успешных изменяющих запросах (POST/PUT/PATCH/DELETE)
+// на связанные маршруты. При переполнении удаляются просроченные записи,
+// а если их нет — произвольная запись.
+type ResponseCache struct {
+	mu         sync.RWMutex
+	entries    map[string]*cacheEntry
+	maxEntries int
+}
+
+// NewResponseCache — создаёт кэш с ограничением на число записей.
+func NewResponseCache(maxEntries int) *ResponseCache {
+	if maxEntries <= 0 {
+		maxEntries = 1000
+	}
+	return &ResponseCache{
+		entries:    make(map[string]*cacheEntry),
+		maxEntries: maxEntries,
+	}
+}
+
+// get — возвращает неистёкшую запись по ключу.
+func (c *ResponseCache) get(key string) (*cacheEntry, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	e, ok := c.entries[key]
+	if !ok || time.Now().After(e.expires) {
+		return nil, false
+	}
+	return e, true
+}
+
+// set — сохраняет запись, освобождая место при переполнении.
+func (c *ResponseCache) set(key string, e *cacheEntry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.entries) >= c.maxEntries {
+		now := time.Now()
+		for k, old := range c.entries {
+			if now.After(old.expires) {
+				delete(c.entries, k)
+			}
+		}
+		for k := range c.entries {
+			if len(c.entries) < c.maxEntries {
+				break
+			}
+			delete(c.entries, k)
+		}
+	}
+	c.entries[key] = e
+}
+
+// InvalidatePrefix — удаляет все записи, путь которых начинается с prefix.
+// Возвращает количество удалённых записей.
+func (c *ResponseCache) InvalidatePrefix(prefix string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	n := 0
+	for k := range c.entries {
+		if strings.HasPrefix(k, prefix) {
+			delete(c.entries, k)
+			n++
+		}
+	}
+	return n
+}
+
+// Len — текущее количество записей в кэше.
+func (c *ResponseCache) Len() int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return len(c.entries)
+}
+
+// cacheRecorder — пропускает ответ клиенту и одновременно копирует его для кэша.
+type cacheRecorder struct {
+	http.ResponseWriter
+	status   int
+	buf      bytes.Buffer
+	overflow bool
+}
+
+func (r *cacheRecorder) WriteHeader(code int) {
+	r.status = code
+	r.ResponseWriter.WriteHeader(code)
+}
+
+func (r *cacheRecorder) Write(b []byte) (int, error) {
+	if !r.overflow {
+		if r.buf.Len()+len(b) > maxCachedBody {
+			r.overflow = true
+			r.buf.Reset()
+		} else {
+			r.buf.Write(b)
+		}
+	}
+	return r.ResponseWriter.Write(b)
+}
+
+// Unwrap — даёт http.ResponseController доступ к исходному writer.
+func (r *cacheRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+// CacheMiddleware — кэширует успешные GET-ответы маршрута на ttl и сбрасывает
+// кэш по префиксам invalidates после успешного изменяющего запроса.
+//
+// Заголовок запроса Cache-Control: no-cache заставляет сходить в бэкенд.
+// В ответ добавляется X-Cache: HIT или MISS.
+func CacheMiddleware(cache *ResponseCache, ttl time.Duration, invalidates []string) func(http.HandlerFunc) http.HandlerFunc {
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodGet && r.Method != http.MethodHead {
+				rec := &cacheRecorder{ResponseWriter: w, status: http.StatusOK}
+				next(rec, r)
+				if rec.status < 400 && r.Method != http.MethodOptions {
+					for _, prefix := range invalidates {
+						if n := cache.InvalidatePrefix(prefix); n > 0 {
+							log.Printf("[CACHE] сброшено %d записей по префиксу %s после %s %s", n, prefix, r.Method, r.URL.Path)
+						}
+					}
+				}
+				return
+			}
+			if ttl <= 0 {
+				next(w, r)
+				return
+			}
+
+			key := r.URL.Path
+			if r.URL.RawQuery != "" {
+				key += "?" + r.URL.RawQuery
+			}
+			// Тексты ответа переводятся на язык запроса (X-Language выставляет шлюз)
+			if lang := r.Header.Get("X-Language"); lang != "" {
+				key += "\x00" + lang
+			}
+			// Бэкенд может сжать ответ — версии для br, gzip и без сжатия храним раздельно
+			if enc := NegotiateEncoding(r.Header.Get("Accept-Encoding")); enc != "" {
+				key += "\x00" + enc
+			}
+
+			if !strings.Contains(r.Header.Get("Cache-Control"), "no-cache") {
+				if e, ok := cache.get(key); ok {
+					for k, v := range e.header {
+						w.Header()[k] = v
+					}
+					w.Header().Set("X-Cache", "HIT")
+					w.WriteHeader(e.status)
+					if r.Method == http.MethodGet {
+						w.Write(e.body)
+					}
+					return
+				}
+			}
+
+			w.Header().Set("X-Cache", "MISS")
+			rec := &cacheRecorder{ResponseWriter: w, status: http.StatusOK}
+			next(rec, r)
+			if r.Method != http.MethodGet || rec.status != http.StatusOK || rec.overflow {
+				return
+			}
+			if strings.Contains(w.Header().Get("Cache-Control"), "no-store") {
+				return
+			}
+			header := make(http.Header)
+			for _, k := range []string{"Content-Type", "Content-Encoding", "ETag", "Last-Modified"} {
+				if v := w.Header().Values(k); len(v) > 0 {
+					header[k] = v
+				}
+			}
+			cache.set(key, &cacheEntry{
+				status:  rec.status,
+				header:  header,
+				body:    append([]byte(nil), rec.buf.Bytes()...),
+				expires: time.Now().Add(ttl),
+			})
+		}
+	}
+}
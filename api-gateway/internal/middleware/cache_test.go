@@ -0,0 +1,134 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// TestCacheMiddleware_HitAndInvalidate — повторный GET отдаётся из кэша,
+// успешный POST на связанный маршрут сбрасывает запись.
+func TestCacheMiddleware_HitAndInvalidate(t *testing.T) {
+	cache := NewResponseCache(10)
+	calls := 0
+	backend := func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"models":["a"]}`))
+	}
+	get := CacheMiddleware(cache, time.Minute, nil)(backend)
+	post := CacheMiddleware(cache, 0, []string{"/models"})(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	for i := 0; i < 2; i++ {
+		rr := httptest.NewRecorder()
+		get(rr, httptest.NewRequest("GET", "/models", nil))
+		if rr.Body.String() != `{"models":["a"]}` {
+			t.Fatalf("запрос %d: неверное тело %q", i, rr.Body.String())
+		}
+	}
+	if calls != 1 {
+		t.Fatalf("ожидался 1 вызов бэкенда, получено %d", calls)
+	}
+
+	rr := httptest.NewRecorder()
+	get(rr, httptest.NewRequest("GET", "/models", nil))
+	if rr.Header().Get("X-Cache") != "HIT" || rr.Header().Get("Content-Type") != "application/json" {
+		t.Fatalf("ожидался HIT с Content-Type, получено %v", rr.Header())
+	}
+
+	post(httptest.NewRecorder(), httptest.NewRequest("POST", "/update-model", nil))
+	if cache.Len() != 0 {
+		t.Fatalf("после POST кэш должен быть пуст, записей: %d", cache.Len())
+	}
+	get(httptest.NewRecorder(), httptest.NewRequest("GET", "/models", nil))
+	if calls != 2 {
+		t.Fatalf("после сброса ожидался повторный вызов бэкенда, вызовов: %d", calls)
+	}
+}
+
+// TestCacheMiddleware_SkipsErrorsAndNoCache — ошибки не кэшируются,
+// Cache-Control: no-cache обходит кэш.
+func TestCacheMiddleware_SkipsErrorsAndNoCache(t *testing.T) {
+	cache := NewResponseCache(10)
+	status := http.StatusBadGateway
+	calls := 0
+	h := CacheMiddleware(cache, time.Minute, nil)(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(status)
+	})
+
+	h(httptest.NewRecorder(), httptest.NewRequest("GET", "/providers", nil))
+	if cache.Len() != 0 {
+		t.Fatal("ответ 502 не должен попадать в кэш")
+	}
+
+	status = http.StatusOK
+	h(httptest.NewRecorder(), httptest.NewRequest("GET", "/providers", nil))
+	req := httptest.NewRequest("GET", "/providers", nil)
+	req.Header.Set("Cache-Control", "no-cache")
+	h(httptest.NewRecorder(), req)
+	if calls != 3 {
+		t.Fatalf("no-cache должен обходить кэш, вызовов бэкенда: %d", calls)
+	}
+}
+
+// TestCacheMiddleware_Expiry — запись перестаёт отдаваться после TTL.
+func TestCacheMiddleware_Expiry(t *testing.T) {
+	cache := NewResponseCache(10)
+	calls := 0
+	h := CacheMiddleware(cache, 20*time.Millisecond, nil)(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Write([]byte("ok"))
+	})
+	h(httptest.NewRecorder(), httptest.NewRequest("GET", "/cloud-models", nil))
+	time.Sleep(30 * time.Millisecond)
+	h(httptest.NewRecorder(), httptest.NewRequest("GET", "/cloud-models", nil))
+	if calls != 2 {
+		t.Fatalf("после истечения TTL ожидался повторный вызов, вызовов: %d", calls)
+	}
+}
+
+// TestCacheMiddleware_Language — ответы на разных языках кэшируются раздельно.
+func TestCacheMiddleware_Language(t *testing.T) {
+	cache := NewResponseCache(10)
+	h := CacheMiddleware(cache, time.Minute, nil)(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Header.Get("X-Language")))
+	})
+	for _, lang := range []string{"ru", "en", "ru"} {
+		req := httptest.NewRequest("GET", "/providers", nil)
+		req.Header.Set("X-Language", lang)
+		rr := httptest.NewRecorder()
+		h(rr, req)
+		if rr.Body.String() != lang {
+			t.Fatalf("запрос на %s получил ответ %q", lang, rr.Body.String())
+		}
+	}
+	if cache.Len() != 2 {
+		t.Errorf("ожидались записи для ru и en, записей: %d", cache.Len())
+	}
+}
+
+// TestCacheMiddleware_Encoding — ответы для br, gzip и без сжатия кэшируются раздельно.
+func TestCacheMiddleware_Encoding(t *testing.T) {
+	cache := NewResponseCache(10)
+	h := CacheMiddleware(cache, time.Minute, nil)(func(w http.ResponseWriter, r *http.Request) {
+		enc := NegotiateEncoding(r.Header.Get("Accept-Encoding"))
+		w.Header().Set("Content-Encoding", enc)
+		w.Write([]byte(enc))
+	})
+	for _, accept := range []string{"gzip, br", "gzip", "", "br"} {
+		req := httptest.NewRequest("GET", "/models", nil)
+		req.Header.Set("Accept-Encoding", accept)
+		rr := httptest.NewRecorder()
+		h(rr, req)
+		if want := NegotiateEncoding(accept); rr.Body.String() != want || rr.Header().Get("Content-Encoding") != want {
+			t.Fatalf("Accept-Encoding %q получил %q", accept, rr.Body.String())
+		}
+	}
+	if cache.Len() != 3 {
+		t.Errorf("ожидались записи для br, gzip и без сжатия, записей: %d", cache.Len())
+	}
+}
@@ -121,6 +121,13 @@ func (w *circuitResponseWriter) WriteHeader(code int) {
 	w.ResponseWriter.WriteHeader(code)
 }
 
+// Unwrap — возвращает исходный ResponseWriter. Нужен http.ResponseController,
+// через который reverse proxy сбрасывает буфер SSE (Flush) и захватывает
+// соединение при WebSocket-апгрейде (Hijack).
+func (w *circuitResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
 // CircuitBreakerMiddleware — HTTP-мидлварь, оборачивающая обработчик в Circuit Breaker.
 //
 // Если Circuit Breaker в состоянии Open — сразу отклоняет запрос (503 Service Unavailable).
@@ -0,0 +1,165 @@
+package middleware
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/andybalholm/brotli"
+)
+
+// brotliLevel — уровень brotli для сжатия на лету: заметно плотнее gzip
+// при сопоставимой скорости (уровни выше 6 для динамических ответов медленны).
+const brotliLevel = 5
+
+// encoder — потоковый компрессор (gzip.Writer, brotli.Writer).
+type encoder interface {
+	io.Writer
+	Flush() error
+	Close() error
+	Reset(io.Writer)
+}
+
+// encoderPools — пулы компрессоров по Content-Encoding, чтобы не выделять
+// буферы на каждый ответ.
+var encoderPools = map[string]*sync.Pool{
+	"br": {New: func() interface{} {
+		return brotli.NewWriterLevel(nil, brotliLevel)
+	}},
+	"gzip": {New: func() interface{} {
+		w, _ := gzip.NewWriterLevel(nil, gzip.DefaultCompression)
+		return w
+	}},
+}
+
+// NegotiateEncoding — кодировка сжатия по Accept-Encoding: br, если клиент её
+// принимает, иначе gzip; пусто — сжатие не поддерживается. Кодировки с q=0
+// исключаются, "*" разрешает любую не названную явно.
+func NegotiateEncoding(acceptEncoding string) string {
+	q := map[string]float64{}
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		weight := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if f, err := strconv.ParseFloat(v, 64); err == nil {
+				weight = f
+			}
+		}
+		q[name] = weight
+	}
+	best, bestQ := "", 0.0
+	for _, enc := range []string{"br", "gzip"} {
+		w, ok := q[enc]
+		if !ok {
+			w, ok = q["*"]
+		}
+		if ok && w > bestQ {
+			best, bestQ = enc, w
+		}
+	}
+	return best
+}
+
+// compressibleType — сжимаются только текстовые форматы; изображения,
+// архивы и PDF уже сжаты.
+func compressibleType(ct string) bool {
+	if ct == "" {
+		return false
+	}
+	ct = strings.ToLower(ct)
+	if strings.HasPrefix(ct, "text/event-stream") {
+		return false
+	}
+	return strings.HasPrefix(ct, "text/") ||
+		strings.Contains(ct, "json") ||
+		strings.Contains(ct, "javascript") ||
+		strings.Contains(ct, "xml")
+}
+
+// compressResponseWriter — решает, сжимать ли ответ, в момент отправки заголовков.
+type compressResponseWriter struct {
+	http.ResponseWriter
+	encoding    string // br или gzip
+	enc         encoder
+	decided     bool
+	compressing bool
+}
+
+func (w *compressResponseWriter) decide(status int) {
+	if w.decided {
+		return
+	}
+	w.decided = true
+	h := w.Header()
+	if status < 200 || status == http.StatusNoContent || status == http.StatusNotModified ||
+		h.Get("Content-Encoding") != "" || !compressibleType(h.Get("Content-Type")) {
+		return
+	}
+	w.compressing = true
+	h.Set("Content-Encoding", w.encoding)
+	h.Add("Vary", "Accept-Encoding")
+	h.Del("Content-Length")
+	w.enc = encoderPools[w.encoding].Get().(encoder)
+	w.enc.Reset(w.ResponseWriter)
+}
+
+func (w *compressResponseWriter) WriteHeader(code int) {
+	w.decide(code)
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *compressResponseWriter) Write(b []byte) (int, error) {
+	if !w.decided {
+		if w.Header().Get("Content-Type") == "" {
+			w.Header().Set("Content-Type", http.DetectContentType(b))
+		}
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.compressing {
+		return w.enc.Write(b)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// Flush — сбрасывает сжатые данные клиенту (для постепенной отдачи).
+func (w *compressResponseWriter) Flush() {
+	if w.compressing {
+		w.enc.Flush()
+	}
+	http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+// Unwrap — даёт http.ResponseController доступ к исходному writer.
+func (w *compressResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func (w *compressResponseWriter) close() {
+	if w.compressing {
+		w.enc.Close()
+		encoderPools[w.encoding].Put(w.enc)
+	}
+}
+
+// CompressMiddleware — сжимает текстовые ответы (JSON, HTML, текст) в br или
+// gzip — что выбирает NegotiateEncoding по Accept-Encoding клиента.
+// SSE и WebSocket не сжимаются.
+func CompressMiddleware(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		encoding := NegotiateEncoding(r.Header.Get("Accept-Encoding"))
+		if encoding == "" || IsStreamingRequest(r) {
+			next(w, r)
+			return
+		}
+		cw := &compressResponseWriter{ResponseWriter: w, encoding: encoding}
+		defer cw.close()
+		next(cw, r)
+	}
+}
@@ -0,0 +1,82 @@
+package middleware
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+)
+
+// TestCompressMiddleware — JSON сжимается в выбранную кодировку, SSE и
+// клиенты без поддержки сжатия получают ответ как есть.
+func TestCompressMiddleware(t *testing.T) {
+	body := strings.Repeat(`{"model":"llama"}`, 50)
+	h := CompressMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Content-Length", "850")
+		w.Write([]byte(body))
+	})
+	get := func(accept string, extra ...string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/models", nil)
+		req.Header.Set("Accept-Encoding", accept)
+		for i := 0; i+1 < len(extra); i += 2 {
+			req.Header.Set(extra[i], extra[i+1])
+		}
+		rr := httptest.NewRecorder()
+		h(rr, req)
+		return rr
+	}
+
+	rr := get("gzip")
+	if rr.Header().Get("Content-Encoding") != "gzip" || rr.Header().Get("Content-Length") != "" {
+		t.Fatalf("ожидался gzip без Content-Length, заголовки: %v", rr.Header())
+	}
+	zr, err := gzip.NewReader(rr.Body)
+	if err != nil {
+		t.Fatalf("ответ не в формате gzip: %v", err)
+	}
+	if got, _ := io.ReadAll(zr); string(got) != body {
+		t.Fatal("распакованное тело не совпадает с исходным")
+	}
+
+	rr = get("gzip, deflate, br")
+	if rr.Header().Get("Content-Encoding") != "br" || rr.Header().Get("Vary") != "Accept-Encoding" {
+		t.Fatalf("ожидался br, заголовки: %v", rr.Header())
+	}
+	if got, _ := io.ReadAll(brotli.NewReader(rr.Body)); string(got) != body {
+		t.Fatal("распакованное brotli-тело не совпадает с исходным")
+	}
+
+	if rr = get("identity"); rr.Header().Get("Content-Encoding") != "" || rr.Body.String() != body {
+		t.Error("клиент без поддержки сжатия должен получить ответ как есть")
+	}
+	if rr = get("gzip", "Accept", "text/event-stream"); rr.Header().Get("Content-Encoding") != "" {
+		t.Error("SSE-запрос не должен сжиматься")
+	}
+}
+
+// TestNegotiateEncoding — br предпочтительнее gzip, q=0 исключает кодировку.
+func TestNegotiateEncoding(t *testing.T) {
+	cases := map[string]string{
+		"":                      "",
+		"identity":              "",
+		"gzip":                  "gzip",
+		"gzip, deflate, br":     "br",
+		"BR;q=1.0":              "br",
+		"br;q=0, gzip":          "gzip",
+		"br;q=0.5, gzip;q=0.8":  "gzip",
+		"*":                     "br",
+		"*;q=0.1, br;q=0":       "gzip",
+		"gzip;q=0, deflate":     "",
+		" gzip ; q=0.9 , zstd ": "gzip",
+	}
+	for accept, want := range cases {
+		if got := NegotiateEncoding(accept); got != want {
+			t.Errorf("NegotiateEncoding(%q) = %q, ожидалось %q", accept, got, want)
+		}
+	}
+}
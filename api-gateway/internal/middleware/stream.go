@@ -0,0 +1,65 @@
+package middleware
+
+import (
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// IsStreamingRequest — определяет долгоживущий запрос:
+// WebSocket (Connection: Upgrade + Upgrade: websocket) или SSE (Accept: text/event-stream).
+func IsStreamingRequest(r *http.Request) bool {
+	if strings.EqualFold(r.Header.Get("Upgrade"), "websocket") &&
+		strings.Contains(strings.ToLower(r.Header.Get("Connection")), "upgrade") {
+		return true
+	}
+	return strings.Contains(r.Header.Get("Accept"), "text/event-stream")
+}
+
+// StreamMiddleware — снимает серверные таймауты чтения/записи для стриминговых запросов.
+//
+// http.Server.WriteTimeout обрывает SSE-поток и WebSocket через фиксированное время,
+// поэтому для таких запросов дедлайны соединения сбрасываются через
+// http.ResponseController. Заголовок X-Accel-Buffering: no отключает буферизацию
+// во внешнем nginx. Если force=true (маршрут помечен stream в конфигурации),
+// дедлайны снимаются для любого запроса маршрута.
+func StreamMiddleware(force bool) func(http.HandlerFunc) http.HandlerFunc {
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			if force || IsStreamingRequest(r) {
+				rc := http.NewResponseController(w)
+				if err := rc.SetWriteDeadline(time.Time{}); err != nil {
+					log.Printf("[STREAM] не удалось снять дедлайн записи: %v", err)
+				}
+				if err := rc.SetReadDeadline(time.Time{}); err != nil {
+					log.Printf("[STREAM] не удалось снять дедлайн чтения: %v", err)
+				}
+				w.Header().Set("X-Accel-Buffering", "no")
+			}
+			next.ServeHTTP(w, r)
+		}
+	}
+}
+
+// DeadlineMiddleware — продлевает дедлайны чтения и записи соединения до
+// timeout от начала запроса.
+//
+// ReadTimeout и WriteTimeout сервера рассчитаны на обычные запросы: загрузка
+// многогигабайтного архива оборвалась бы через ReadTimeout, скачивание — через
+// WriteTimeout. Маршруты с timeout больше серверного получают свой лимит.
+func DeadlineMiddleware(timeout time.Duration) func(http.HandlerFunc) http.HandlerFunc {
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			deadline := time.Now().Add(timeout)
+			rc := http.NewResponseController(w)
+			if err := rc.SetReadDeadline(deadline); err != nil {
+				log.Printf("[DEADLINE] не удалось продлить дедлайн чтения: %v", err)
+			}
+			if err := rc.SetWriteDeadline(deadline); err != nil {
+				log.Printf("[DEADLINE] не удалось продлить дедлайн записи: %v", err)
+			}
+			next.ServeHTTP(w, r)
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package middleware
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestIsStreamingRequest — распознавание WebSocket и SSE по заголовкам.
+func TestIsStreamingRequest(t *testing.T) {
+	ws := httptest.NewRequest("GET", "/ws", nil)
+	ws.Header.Set("Connection", "keep-alive, Upgrade")
+	ws.Header.Set("Upgrade", "websocket")
+	if !IsStreamingRequest(ws) {
+		t.Error("WebSocket-запрос должен считаться стриминговым")
+	}
+
+	sse := httptest.NewRequest("GET", "/events", nil)
+	sse.Header.Set("Accept", "text/event-stream")
+	if !IsStreamingRequest(sse) {
+		t.Error("SSE-запрос должен считаться стриминговым")
+	}
+
+	plain := httptest.NewRequest("GET", "/models", nil)
+	if IsStreamingRequest(plain) {
+		t.Error("обычный запрос не должен считаться стриминговым")
+	}
+}
+
+// TestCircuitBreakerMiddleware_Flush — Flush проходит через обёртку предохранителя.
+func TestCircuitBreakerMiddleware_Flush(t *testing.T) {
+	cb := NewCircuitBreaker(3, time.Second)
+	handler := CircuitBreakerMiddleware(cb, "test")(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("data: 1\n\n"))
+		if err := http.NewResponseController(w).Flush(); err != nil {
+			t.Errorf("Flush через обёртку не поддерживается: %v", err)
+		}
+	})
+	rr := httptest.NewRecorder()
+	handler(rr, httptest.NewRequest("GET", "/events", nil))
+	if !rr.Flushed {
+		t.Error("ответ должен быть сброшен клиенту")
+	}
+}
+
+// TestStreamMiddleware_WebSocketUpgrade — апгрейд соединения через reverse proxy,
+// обёрнутый в StreamMiddleware и предохранитель, доходит до клиента (101).
+func TestStreamMiddleware_WebSocketUpgrade(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, brw, err := http.NewResponseController(w).Hijack()
+		if err != nil {
+			t.Errorf("бэкенд: hijack: %v", err)
+			return
+		}
+		defer conn.Close()
+		brw.WriteString("HTTP/1.1 101 Switching Protocols\r\nConnection: Upgrade\r\nUpgrade: websocket\r\n\r\n")
+		brw.Flush()
+	}))
+	defer backend.Close()
+
+	target, _ := url.Parse(backend.URL)
+	proxy := httputil.NewSingleHostReverseProxy(target)
+	cb := NewCircuitBreaker(3, time.Second)
+	gw := httptest.NewUnstartedServer(StreamMiddleware(false)(CircuitBreakerMiddleware(cb, "test")(proxy.ServeHTTP)))
+	gw.Config.WriteTimeout = 50 * time.Millisecond
+	gw.Start()
+	defer gw.Close()
+
+	req, _ := http.NewRequest("GET", gw.URL+"/ws", nil)
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	conn, err := net.Dial("tcp", gw.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	req.Write(conn)
+	resp, err := http.ReadResponse(bufio.NewReader(conn), req)
+	if err != nil {
+		t.Fatalf("чтение ответа: %v", err)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("ожидался 101, получен %d", resp.StatusCode)
+	}
+	if !strings.EqualFold(resp.Header.Get("Upgrade"), "websocket") {
+		t.Errorf("ожидался заголовок Upgrade: websocket, получен %q", resp.Header.Get("Upgrade"))
+	}
+}
+
+// TestDeadlineMiddleware — тело, которое передаётся дольше ReadTimeout
+// сервера, дочитывается на маршруте с продлённым дедлайном и обрывается без него.
+func TestDeadlineMiddleware(t *testing.T) {
+	upload := func(h http.HandlerFunc) (int, error) {
+		srv := httptest.NewUnstartedServer(h)
+		srv.Config.ReadTimeout = 100 * time.Millisecond
+		srv.Start()
+		defer srv.Close()
+
+		pr, pw := io.Pipe()
+		go func() {
+			for i := 0; i < 5; i++ {
+				time.Sleep(60 * time.Millisecond)
+				pw.Write(bytes.Repeat([]byte("x"), 1024))
+			}
+			pw.Close()
+		}()
+		resp, err := http.Post(srv.URL+"/admin/restore", "application/gzip", pr)
+		if err != nil {
+			return 0, err
+		}
+		defer resp.Body.Close()
+		return resp.StatusCode, nil
+	}
+	readAll := func(w http.ResponseWriter, r *http.Request) {
+		if n, err := io.Copy(io.Discard, r.Body); err != nil || n != 5*1024 {
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}
+
+	if status, err := upload(DeadlineMiddleware(5 * time.Second)(readAll)); err != nil || status != http.StatusOK {
+		t.Fatalf("загрузка с продлённым дедлайном: статус %d, ошибка %v", status, err)
+	}
+	if status, err := upload(readAll); err == nil && status == http.StatusOK {
+		t.Error("без продления дедлайна загрузка должна обрываться по ReadTimeout")
+	}
+}
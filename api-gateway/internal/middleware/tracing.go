@@ -1,72 +1,46 @@
 package middleware
 
 import (
-	"fmt"
 	"log"
 	"net/http"
-	"sync/atomic"
 	"time"
+
+	"github.com/neo-2022/openclaw-memory/api-gateway/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // TracingMiddleware — HTTP-мидлварь для распределённой трассировки запросов.
 //
 // Для каждого входящего запроса:
-// 1. Проверяет наличие заголовка X-Trace-ID (для продолжения существующей трассировки).
-// 2. Если заголовка нет — генерирует новый Trace ID.
-// 3. Генерирует уникальный Span ID для текущего участка обработки.
-// 4. Сохраняет Parent Span ID, если запрос пришёл с уже установленным X-Span-ID.
-// 5. Устанавливает заголовки X-Trace-ID и X-Span-ID в ответ.
-// 6. Логирует время обработки запроса.
-//
-// Совместим со стилем OpenTelemetry, но не требует полного SDK.
+//  1. Продолжает трассировку из заголовка traceparent (W3C Trace Context)
+//     или начинает новую.
+//  2. Открывает серверный спан шлюза и передаёт бэкенду traceparent с его
+//     идентификатором — спаны agent-service, tools-service и browser-service
+//     становятся дочерними.
+//  3. Устанавливает заголовки X-Trace-ID и X-Span-ID в ответ.
+//  4. Логирует время обработки запроса; спан экспортируется в OTLP,
+//     если включён tracing.Init.
 func TracingMiddleware(serviceName string) func(http.HandlerFunc) http.HandlerFunc {
 	return func(next http.HandlerFunc) http.HandlerFunc {
 		return func(w http.ResponseWriter, r *http.Request) {
-			// Получить или сгенерировать Trace ID
-			traceID := r.Header.Get("X-Trace-ID")
-			if traceID == "" {
-				traceID = generateTraceID()
-			}
-			// Сгенерировать Span ID для текущего участка
-			spanID := generateSpanID()
-			// Сохранить родительский Span ID (если есть)
-			parentSpanID := r.Header.Get("X-Span-ID")
-
-			// Установить заголовки для дальнейшей передачи по цепочке сервисов
-			r.Header.Set("X-Trace-ID", traceID)
-			r.Header.Set("X-Span-ID", spanID)
-			if parentSpanID != "" {
-				r.Header.Set("X-Parent-Span-ID", parentSpanID)
+			start := time.Now()
+			parentSpanID := ""
+			if parent := trace.SpanContextFromContext(tracing.Extract(r.Context(), r.Header)); parent.IsValid() {
+				parentSpanID = parent.SpanID().String()
 			}
 
-			// Установить заголовки в ответ клиенту
-			w.Header().Set("X-Trace-ID", traceID)
-			w.Header().Set("X-Span-ID", spanID)
-
-			// Замерить время обработки запроса
-			start := time.Now()
-			next.ServeHTTP(w, r)
-			duration := time.Since(start)
+			var sc trace.SpanContext
+			tracing.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				span := trace.SpanFromContext(r.Context())
+				span.SetAttributes(attribute.String("service.route", serviceName))
+				sc = span.SpanContext()
+				w.Header().Set("X-Span-ID", sc.SpanID().String())
+				next.ServeHTTP(w, r)
+			})).ServeHTTP(w, r)
 
 			log.Printf("[ТРАССИРОВКА] сервис=%s trace_id=%s span_id=%s parent=%s метод=%s путь=%s длительность=%v",
-				serviceName, traceID, spanID, parentSpanID, r.Method, r.URL.Path, duration)
+				serviceName, sc.TraceID(), sc.SpanID(), parentSpanID, r.Method, r.URL.Path, time.Since(start))
 		}
 	}
 }
-
-// traceCounter — атомарный счётчик для генерации уникальных ID трассировки.
-var traceCounter uint64
-
-// generateTraceID — генерирует уникальный идентификатор трассировки.
-// Формат: trace-{unix_nano}-{порядковый_номер}.
-func generateTraceID() string {
-	n := atomic.AddUint64(&traceCounter, 1)
-	return fmt.Sprintf("trace-%d-%d", time.Now().UnixNano(), n)
-}
-
-// generateSpanID — генерирует уникальный идентификатор участка (span).
-// Формат: span-{unix_nano}-{порядковый_номер}.
-func generateSpanID() string {
-	n := atomic.AddUint64(&traceCounter, 1)
-	return fmt.Sprintf("span-%d-%d", time.Now().UnixNano(), n)
-}
@@ -0,0 +1,133 @@
+package tracing
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+// traceparentOf — контекст спана из заголовка traceparent.
+func traceparentOf(v string) trace.SpanContext {
+	h := http.Header{}
+	h.Set("traceparent", v)
+	return trace.SpanContextFromContext(Extract(context.Background(), h))
+}
+
+// TestMiddleware_ContinuesTrace — серверный спан продолжает входящую трассировку
+// и подменяет traceparent для следующего сервиса.
+func TestMiddleware_ContinuesTrace(t *testing.T) {
+	defer Init("test-service")(context.Background())
+	const incoming = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	var downstream, proxied string
+	h := Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		out := http.Header{}
+		Inject(r.Context(), out)
+		downstream = out.Get("traceparent")
+		proxied = r.Header.Get("traceparent")
+	}))
+	req := httptest.NewRequest("GET", "/models", nil)
+	req.Header.Set("traceparent", incoming)
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+
+	sc := traceparentOf(downstream)
+	if !sc.IsValid() || sc.TraceID().String() != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Fatalf("исходящий traceparent должен продолжать трассировку, получен %q", downstream)
+	}
+	if sc.SpanID().String() == "00f067aa0ba902b7" {
+		t.Error("исходящий traceparent должен ссылаться на спан сервиса, а не на родителя")
+	}
+	if proxied != downstream {
+		t.Errorf("traceparent запроса для прокси %q, ожидался %q", proxied, downstream)
+	}
+	if rr.Header().Get("X-Trace-ID") != sc.TraceID().String() {
+		t.Errorf("ожидался X-Trace-ID %s, получен %q", sc.TraceID(), rr.Header().Get("X-Trace-ID"))
+	}
+}
+
+// TestExporter_SendsOTLP — завершённые спаны уходят в коллектор по OTLP/HTTP.
+func TestExporter_SendsOTLP(t *testing.T) {
+	received := make(chan *coltracepb.ExportTraceServiceRequest, 1)
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/traces" {
+			t.Errorf("неверный путь коллектора: %s", r.URL.Path)
+		}
+		body, _ := io.ReadAll(r.Body)
+		req := &coltracepb.ExportTraceServiceRequest{}
+		if err := proto.Unmarshal(body, req); err != nil {
+			t.Errorf("тело не в формате OTLP: %v", err)
+		}
+		received <- req
+	}))
+	defer collector.Close()
+
+	t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", collector.URL)
+	shutdown := Init("test-service")
+	ctx, span := otel.Tracer("test").Start(context.Background(), "tool.call", trace.WithSpanKind(trace.SpanKindClient))
+	span.SetStatus(codes.Error, "timeout")
+	_, child := otel.Tracer("test").Start(ctx, "child")
+	child.End()
+	span.End()
+
+	sctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	shutdown(sctx)
+
+	select {
+	case req := <-received:
+		spans := req.ResourceSpans[0].ScopeSpans[0].Spans
+		if len(spans) != 2 {
+			t.Fatalf("ожидалось 2 спана, получено %d", len(spans))
+		}
+		child, parent := spans[0], spans[1]
+		if parent.Status.GetMessage() != "timeout" {
+			t.Errorf("ошибка спана не экспортирована: %+v", parent.Status)
+		}
+		if string(child.ParentSpanId) != string(parent.SpanId) || string(child.TraceId) != string(parent.TraceId) {
+			t.Error("дочерний спан должен ссылаться на родителя")
+		}
+	default:
+		t.Fatal("коллектор не получил спаны")
+	}
+}
+
+// TestSampler — OTEL_TRACES_SAMPLER: доля трассировок по trace ID и флаг родителя.
+func TestSampler(t *testing.T) {
+	t.Setenv("OTEL_TRACES_SAMPLER", "parentbased_traceidratio")
+	t.Setenv("OTEL_TRACES_SAMPLER_ARG", "0.1")
+	defer Init("test-service")(context.Background())
+
+	tracer := otel.Tracer("test")
+	sampled := 0
+	for i := 0; i < 10000; i++ {
+		_, span := tracer.Start(context.Background(), "job")
+		if span.SpanContext().IsSampled() {
+			sampled++
+		}
+		span.End()
+	}
+	if sampled < 800 || sampled > 1200 {
+		t.Errorf("при доле 0.1 семплировано %d из 10000", sampled)
+	}
+	parent := traceparentOf("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	_, child := tracer.Start(trace.ContextWithRemoteSpanContext(context.Background(), parent), "child")
+	if !child.SpanContext().IsSampled() {
+		t.Error("parentbased: решение родителя должно сохраняться")
+	}
+
+	t.Setenv("OTEL_TRACES_SAMPLER", "always_off")
+	defer Init("test-service")(context.Background())
+	_, span := otel.Tracer("test").Start(trace.ContextWithRemoteSpanContext(context.Background(), parent), "job")
+	if span.SpanContext().IsSampled() {
+		t.Error("always_off не учитывает родителя")
+	}
+}
@@ -18,18 +18,32 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"log"
+	"log/slog"
+	"net"
 	"net/http"
 	"os"
-	"strconv"
+	"os/signal"
+	"syscall"
 
 	"github.com/neo-2022/openclaw-memory/browser-service/internal/access"
+	"github.com/neo-2022/openclaw-memory/browser-service/internal/apierror"
 	"github.com/neo-2022/openclaw-memory/browser-service/internal/browser"
+	"github.com/neo-2022/openclaw-memory/browser-service/internal/browserpb"
+	"github.com/neo-2022/openclaw-memory/browser-service/internal/config"
 	"github.com/neo-2022/openclaw-memory/browser-service/internal/crawler"
+	"github.com/neo-2022/openclaw-memory/browser-service/internal/health"
 	"github.com/neo-2022/openclaw-memory/browser-service/internal/input"
+	"github.com/neo-2022/openclaw-memory/browser-service/internal/logger"
+	"github.com/neo-2022/openclaw-memory/browser-service/internal/rpc"
 	"github.com/neo-2022/openclaw-memory/browser-service/internal/search"
+	"github.com/neo-2022/openclaw-memory/browser-service/internal/tracing"
+	"google.golang.org/grpc"
+	grpchealth "google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 )
 
 // ============================================================================
@@ -149,12 +163,12 @@ type CheckURLsRequest struct {
 // POST /browser/dom
 func handleGetDOM(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		httpError(w, "Метод не поддерживается. Используйте POST.", http.StatusMethodNotAllowed)
+		httpError(w, r, "Метод не поддерживается. Используйте POST.", http.StatusMethodNotAllowed)
 		return
 	}
 	var req URLRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		httpError(w, "Некорректный JSON: "+err.Error(), http.StatusBadRequest)
+		httpError(w, r, "Некорректный JSON: "+err.Error(), http.StatusBadRequest)
 		return
 	}
 	result := browser.GetDOM(req.URL)
@@ -165,12 +179,12 @@ func handleGetDOM(w http.ResponseWriter, r *http.Request) {
 // POST /browser/open
 func handleOpenVisible(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		httpError(w, "Метод не поддерживается", http.StatusMethodNotAllowed)
+		httpError(w, r, "Метод не поддерживается", http.StatusMethodNotAllowed)
 		return
 	}
 	var req URLRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		httpError(w, "Некорректный JSON: "+err.Error(), http.StatusBadRequest)
+		httpError(w, r, "Некорректный JSON: "+err.Error(), http.StatusBadRequest)
 		return
 	}
 	result := browser.OpenVisible(req.URL)
@@ -181,12 +195,12 @@ func handleOpenVisible(w http.ResponseWriter, r *http.Request) {
 // POST /browser/screenshot
 func handleScreenshot(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		httpError(w, "Метод не поддерживается", http.StatusMethodNotAllowed)
+		httpError(w, r, "Метод не поддерживается", http.StatusMethodNotAllowed)
 		return
 	}
 	var req URLRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		httpError(w, "Некорректный JSON: "+err.Error(), http.StatusBadRequest)
+		httpError(w, r, "Некорректный JSON: "+err.Error(), http.StatusBadRequest)
 		return
 	}
 	result := browser.Screenshot(req.URL, req.OutputPath, req.WindowSize)
@@ -197,12 +211,12 @@ func handleScreenshot(w http.ResponseWriter, r *http.Request) {
 // POST /browser/pdf
 func handlePrintToPDF(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		httpError(w, "Метод не поддерживается", http.StatusMethodNotAllowed)
+		httpError(w, r, "Метод не поддерживается", http.StatusMethodNotAllowed)
 		return
 	}
 	var req URLRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		httpError(w, "Некорректный JSON: "+err.Error(), http.StatusBadRequest)
+		httpError(w, r, "Некорректный JSON: "+err.Error(), http.StatusBadRequest)
 		return
 	}
 	result := browser.PrintToPDF(req.URL, req.OutputPath)
@@ -213,12 +227,12 @@ func handlePrintToPDF(w http.ResponseWriter, r *http.Request) {
 // POST /browser/text
 func handleGetText(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		httpError(w, "Метод не поддерживается", http.StatusMethodNotAllowed)
+		httpError(w, r, "Метод не поддерживается", http.StatusMethodNotAllowed)
 		return
 	}
 	var req URLRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		httpError(w, "Некорректный JSON: "+err.Error(), http.StatusBadRequest)
+		httpError(w, r, "Некорректный JSON: "+err.Error(), http.StatusBadRequest)
 		return
 	}
 	result := browser.GetText(req.URL)
@@ -229,12 +243,12 @@ func handleGetText(w http.ResponseWriter, r *http.Request) {
 // POST /browser/title
 func handleGetTitle(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		httpError(w, "Метод не поддерживается", http.StatusMethodNotAllowed)
+		httpError(w, r, "Метод не поддерживается", http.StatusMethodNotAllowed)
 		return
 	}
 	var req URLRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		httpError(w, "Некорректный JSON: "+err.Error(), http.StatusBadRequest)
+		httpError(w, r, "Некорректный JSON: "+err.Error(), http.StatusBadRequest)
 		return
 	}
 	result := browser.GetTitle(req.URL)
@@ -245,12 +259,12 @@ func handleGetTitle(w http.ResponseWriter, r *http.Request) {
 // POST /browser/js
 func handleExecuteJS(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		httpError(w, "Метод не поддерживается", http.StatusMethodNotAllowed)
+		httpError(w, r, "Метод не поддерживается", http.StatusMethodNotAllowed)
 		return
 	}
 	var req JSRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		httpError(w, "Некорректный JSON: "+err.Error(), http.StatusBadRequest)
+		httpError(w, r, "Некорректный JSON: "+err.Error(), http.StatusBadRequest)
 		return
 	}
 	result := browser.ExecuteJS(req.URL, req.JSCode)
@@ -261,12 +275,12 @@ func handleExecuteJS(w http.ResponseWriter, r *http.Request) {
 // POST /browser/captcha
 func handleDetectCaptcha(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		httpError(w, "Метод не поддерживается", http.StatusMethodNotAllowed)
+		httpError(w, r, "Метод не поддерживается", http.StatusMethodNotAllowed)
 		return
 	}
 	var req URLRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		httpError(w, "Некорректный JSON: "+err.Error(), http.StatusBadRequest)
+		httpError(w, r, "Некорректный JSON: "+err.Error(), http.StatusBadRequest)
 		return
 	}
 	result := browser.DetectCaptcha(req.URL)
@@ -279,12 +293,12 @@ func handleDetectCaptcha(w http.ResponseWriter, r *http.Request) {
 // POST /input/key
 func handleKeyPress(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		httpError(w, "Метод не поддерживается", http.StatusMethodNotAllowed)
+		httpError(w, r, "Метод не поддерживается", http.StatusMethodNotAllowed)
 		return
 	}
 	var req KeyRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		httpError(w, "Некорректный JSON: "+err.Error(), http.StatusBadRequest)
+		httpError(w, r, "Некорректный JSON: "+err.Error(), http.StatusBadRequest)
 		return
 	}
 	result := input.KeyPress(req.Keys, req.WindowID)
@@ -295,12 +309,12 @@ func handleKeyPress(w http.ResponseWriter, r *http.Request) {
 // POST /input/type
 func handleTypeText(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		httpError(w, "Метод не поддерживается", http.StatusMethodNotAllowed)
+		httpError(w, r, "Метод не поддерживается", http.StatusMethodNotAllowed)
 		return
 	}
 	var req TypeRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		httpError(w, "Некорректный JSON: "+err.Error(), http.StatusBadRequest)
+		httpError(w, r, "Некорректный JSON: "+err.Error(), http.StatusBadRequest)
 		return
 	}
 	result := input.TypeText(req.Text, req.WindowID, req.Delay)
@@ -311,12 +325,12 @@ func handleTypeText(w http.ResponseWriter, r *http.Request) {
 // POST /input/click
 func handleMouseClick(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		httpError(w, "Метод не поддерживается", http.StatusMethodNotAllowed)
+		httpError(w, r, "Метод не поддерживается", http.StatusMethodNotAllowed)
 		return
 	}
 	var req MouseClickRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		httpError(w, "Некорректный JSON: "+err.Error(), http.StatusBadRequest)
+		httpError(w, r, "Некорректный JSON: "+err.Error(), http.StatusBadRequest)
 		return
 	}
 	if req.Button == 0 {
@@ -333,12 +347,12 @@ func handleMouseClick(w http.ResponseWriter, r *http.Request) {
 // POST /input/move
 func handleMouseMove(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		httpError(w, "Метод не поддерживается", http.StatusMethodNotAllowed)
+		httpError(w, r, "Метод не поддерживается", http.StatusMethodNotAllowed)
 		return
 	}
 	var req MouseMoveRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		httpError(w, "Некорректный JSON: "+err.Error(), http.StatusBadRequest)
+		httpError(w, r, "Некорректный JSON: "+err.Error(), http.StatusBadRequest)
 		return
 	}
 	result := input.MouseMove(req.X, req.Y)
@@ -349,12 +363,12 @@ func handleMouseMove(w http.ResponseWriter, r *http.Request) {
 // POST /input/scroll
 func handleMouseScroll(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		httpError(w, "Метод не поддерживается", http.StatusMethodNotAllowed)
+		httpError(w, r, "Метод не поддерживается", http.StatusMethodNotAllowed)
 		return
 	}
 	var req MouseScrollRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		httpError(w, "Некорректный JSON: "+err.Error(), http.StatusBadRequest)
+		httpError(w, r, "Некорректный JSON: "+err.Error(), http.StatusBadRequest)
 		return
 	}
 	if req.Clicks == 0 {
@@ -368,12 +382,12 @@ func handleMouseScroll(w http.ResponseWriter, r *http.Request) {
 // POST /input/drag
 func handleMouseDrag(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		httpError(w, "Метод не поддерживается", http.StatusMethodNotAllowed)
+		httpError(w, r, "Метод не поддерживается", http.StatusMethodNotAllowed)
 		return
 	}
 	var req MouseDragRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		httpError(w, "Некорректный JSON: "+err.Error(), http.StatusBadRequest)
+		httpError(w, r, "Некорректный JSON: "+err.Error(), http.StatusBadRequest)
 		return
 	}
 	result := input.MouseDrag(req.FromX, req.FromY, req.ToX, req.ToY)
@@ -384,12 +398,12 @@ func handleMouseDrag(w http.ResponseWriter, r *http.Request) {
 // POST /input/tab
 func handleTabAction(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		httpError(w, "Метод не поддерживается", http.StatusMethodNotAllowed)
+		httpError(w, r, "Метод не поддерживается", http.StatusMethodNotAllowed)
 		return
 	}
 	var req TabRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		httpError(w, "Некорректный JSON: "+err.Error(), http.StatusBadRequest)
+		httpError(w, r, "Некорректный JSON: "+err.Error(), http.StatusBadRequest)
 		return
 	}
 	result := input.TabAction(req.Action, req.Param)
@@ -400,12 +414,12 @@ func handleTabAction(w http.ResponseWriter, r *http.Request) {
 // POST /input/window
 func handleWindowAction(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		httpError(w, "Метод не поддерживается", http.StatusMethodNotAllowed)
+		httpError(w, r, "Метод не поддерживается", http.StatusMethodNotAllowed)
 		return
 	}
 	var req WindowRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		httpError(w, "Некорректный JSON: "+err.Error(), http.StatusBadRequest)
+		httpError(w, r, "Некорректный JSON: "+err.Error(), http.StatusBadRequest)
 		return
 	}
 	result := input.WindowAction(req.Action, req.Target, req.Params)
@@ -416,12 +430,12 @@ func handleWindowAction(w http.ResponseWriter, r *http.Request) {
 // POST /input/clipboard
 func handleClipboard(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		httpError(w, "Метод не поддерживается", http.StatusMethodNotAllowed)
+		httpError(w, r, "Метод не поддерживается", http.StatusMethodNotAllowed)
 		return
 	}
 	var req ClipboardRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		httpError(w, "Некорректный JSON: "+err.Error(), http.StatusBadRequest)
+		httpError(w, r, "Некорректный JSON: "+err.Error(), http.StatusBadRequest)
 		return
 	}
 	result := input.ClipboardAction(req.Action, req.Text)
@@ -432,12 +446,12 @@ func handleClipboard(w http.ResponseWriter, r *http.Request) {
 // POST /input/zoom
 func handleZoom(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		httpError(w, "Метод не поддерживается", http.StatusMethodNotAllowed)
+		httpError(w, r, "Метод не поддерживается", http.StatusMethodNotAllowed)
 		return
 	}
 	var req ZoomRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		httpError(w, "Некорректный JSON: "+err.Error(), http.StatusBadRequest)
+		httpError(w, r, "Некорректный JSON: "+err.Error(), http.StatusBadRequest)
 		return
 	}
 	result := input.ZoomAction(req.Action)
@@ -448,7 +462,7 @@ func handleZoom(w http.ResponseWriter, r *http.Request) {
 // POST /input/devtools
 func handleDevTools(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		httpError(w, "Метод не поддерживается", http.StatusMethodNotAllowed)
+		httpError(w, r, "Метод не поддерживается", http.StatusMethodNotAllowed)
 		return
 	}
 	result := input.ToggleDevTools()
@@ -459,12 +473,12 @@ func handleDevTools(w http.ResponseWriter, r *http.Request) {
 // POST /input/find
 func handleFindText(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		httpError(w, "Метод не поддерживается", http.StatusMethodNotAllowed)
+		httpError(w, r, "Метод не поддерживается", http.StatusMethodNotAllowed)
 		return
 	}
 	var req FindRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		httpError(w, "Некорректный JSON: "+err.Error(), http.StatusBadRequest)
+		httpError(w, r, "Некорректный JSON: "+err.Error(), http.StatusBadRequest)
 		return
 	}
 	result := input.FindText(req.Text)
@@ -498,15 +512,19 @@ func handleGetScreenResolution(w http.ResponseWriter, r *http.Request) {
 // POST /search
 func handleSearch(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		httpError(w, "Метод не поддерживается", http.StatusMethodNotAllowed)
+		httpError(w, r, "Метод не поддерживается", http.StatusMethodNotAllowed)
 		return
 	}
 	var req SearchRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		httpError(w, "Некорректный JSON: "+err.Error(), http.StatusBadRequest)
+		httpError(w, r, "Некорректный JSON: "+err.Error(), http.StatusBadRequest)
 		return
 	}
-	result := search.Search(req.Query, req.MaxResults, req.Engine)
+	cfg := config.Current()
+	if req.MaxResults <= 0 {
+		req.MaxResults = cfg.SearchMaxResults
+	}
+	result := search.Search(req.Query, req.MaxResults, req.Engine, cfg.SearXNGURL)
 	jsonResponse(w, result)
 }
 
@@ -514,14 +532,17 @@ func handleSearch(w http.ResponseWriter, r *http.Request) {
 // POST /search/duckduckgo
 func handleSearchDuckDuckGo(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		httpError(w, "Метод не поддерживается", http.StatusMethodNotAllowed)
+		httpError(w, r, "Метод не поддерживается", http.StatusMethodNotAllowed)
 		return
 	}
 	var req SearchRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		httpError(w, "Некорректный JSON: "+err.Error(), http.StatusBadRequest)
+		httpError(w, r, "Некорректный JSON: "+err.Error(), http.StatusBadRequest)
 		return
 	}
+	if req.MaxResults <= 0 {
+		req.MaxResults = config.Current().SearchMaxResults
+	}
 	result := search.SearchDuckDuckGo(req.Query, req.MaxResults)
 	jsonResponse(w, result)
 }
@@ -530,14 +551,21 @@ func handleSearchDuckDuckGo(w http.ResponseWriter, r *http.Request) {
 // POST /search/searxng
 func handleSearchSearXNG(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		httpError(w, "Метод не поддерживается", http.StatusMethodNotAllowed)
+		httpError(w, r, "Метод не поддерживается", http.StatusMethodNotAllowed)
 		return
 	}
 	var req SearchRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		httpError(w, "Некорректный JSON: "+err.Error(), http.StatusBadRequest)
+		httpError(w, r, "Некорректный JSON: "+err.Error(), http.StatusBadRequest)
 		return
 	}
+	cfg := config.Current()
+	if req.MaxResults <= 0 {
+		req.MaxResults = cfg.SearchMaxResults
+	}
+	if req.CustomInstance == "" {
+		req.CustomInstance = cfg.SearXNGURL
+	}
 	result := search.SearchSearXNG(req.Query, req.MaxResults, req.CustomInstance)
 	jsonResponse(w, result)
 }
@@ -548,12 +576,12 @@ func handleSearchSearXNG(w http.ResponseWriter, r *http.Request) {
 // POST /crawler/fetch
 func handleCrawl(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		httpError(w, "Метод не поддерживается", http.StatusMethodNotAllowed)
+		httpError(w, r, "Метод не поддерживается", http.StatusMethodNotAllowed)
 		return
 	}
 	var req CrawlRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		httpError(w, "Некорректный JSON: "+err.Error(), http.StatusBadRequest)
+		httpError(w, r, "Некорректный JSON: "+err.Error(), http.StatusBadRequest)
 		return
 	}
 	var result crawler.CrawlResult
@@ -569,12 +597,12 @@ func handleCrawl(w http.ResponseWriter, r *http.Request) {
 // POST /crawler/robots
 func handleCrawlRobotsTxt(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		httpError(w, "Метод не поддерживается", http.StatusMethodNotAllowed)
+		httpError(w, r, "Метод не поддерживается", http.StatusMethodNotAllowed)
 		return
 	}
 	var req CrawlRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		httpError(w, "Некорректный JSON: "+err.Error(), http.StatusBadRequest)
+		httpError(w, r, "Некорректный JSON: "+err.Error(), http.StatusBadRequest)
 		return
 	}
 	mode := crawler.BotMode(req.Mode)
@@ -598,12 +626,12 @@ func handleCrawlModes(w http.ResponseWriter, r *http.Request) {
 // POST /access/check
 func handleCheckURL(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		httpError(w, "Метод не поддерживается", http.StatusMethodNotAllowed)
+		httpError(w, r, "Метод не поддерживается", http.StatusMethodNotAllowed)
 		return
 	}
 	var req URLRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		httpError(w, "Некорректный JSON: "+err.Error(), http.StatusBadRequest)
+		httpError(w, r, "Некорректный JSON: "+err.Error(), http.StatusBadRequest)
 		return
 	}
 	result := access.CheckURL(req.URL)
@@ -614,12 +642,12 @@ func handleCheckURL(w http.ResponseWriter, r *http.Request) {
 // POST /access/check-multiple
 func handleCheckMultipleURLs(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		httpError(w, "Метод не поддерживается", http.StatusMethodNotAllowed)
+		httpError(w, r, "Метод не поддерживается", http.StatusMethodNotAllowed)
 		return
 	}
 	var req CheckURLsRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		httpError(w, "Некорректный JSON: "+err.Error(), http.StatusBadRequest)
+		httpError(w, r, "Некорректный JSON: "+err.Error(), http.StatusBadRequest)
 		return
 	}
 	results := access.CheckMultipleURLs(req.URLs)
@@ -635,7 +663,7 @@ func handleHealth(w http.ResponseWriter, r *http.Request) {
 	health := map[string]interface{}{
 		"status":  "ok",
 		"service": "browser-service",
-		"port":    getPort(),
+		"port":    config.Current().Port,
 	}
 	if chromeErr != nil {
 		health["chrome"] = "не найден"
@@ -646,6 +674,19 @@ func handleHealth(w http.ResponseWriter, r *http.Request) {
 	jsonResponse(w, health)
 }
 
+// readinessChecks — проверки /ready: без браузера сервис не выполняет
+// основную часть операций (навигация, скриншоты, PDF), поэтому Chrome обязателен.
+func readinessChecks() []health.Check {
+	return []health.Check{{
+		Name:     "chrome",
+		Required: true,
+		Run: func(ctx context.Context) error {
+			_, err := browser.FindChromeBinary()
+			return err
+		},
+	}}
+}
+
 // handleInfo — информация о сервисе и доступных эндпоинтах.
 // GET /info
 func handleInfo(w http.ResponseWriter, r *http.Request) {
@@ -663,6 +704,8 @@ func handleInfo(w http.ResponseWriter, r *http.Request) {
 				"POST /browser/title — заголовок страницы",
 				"POST /browser/js — выполнить JavaScript",
 				"POST /browser/captcha — проверить на CAPTCHA",
+				"GET /browser/config — конфигурация сервиса",
+				"POST /browser/config/reload — перечитать конфигурацию",
 			},
 			"input": []string{
 				"POST /input/key — нажать клавишу",
@@ -697,6 +740,7 @@ func handleInfo(w http.ResponseWriter, r *http.Request) {
 			},
 			"service": []string{
 				"GET /health — здоровье сервиса",
+				"GET /ready — готовность к работе (503, если Chrome не найден)",
 				"GET /info — информация о сервисе",
 			},
 		},
@@ -717,23 +761,72 @@ func jsonResponse(w http.ResponseWriter, data interface{}) {
 	json.NewEncoder(w).Encode(data)
 }
 
-// httpError — отправляет JSON-ошибку клиенту.
-func httpError(w http.ResponseWriter, message string, code int) {
-	w.Header().Set("Content-Type", "application/json; charset=utf-8")
-	w.WriteHeader(code)
-	json.NewEncoder(w).Encode(map[string]string{"error": message})
+// httpError — отправляет JSON-ошибку клиенту в едином формате apierror
+// (code, message, hint, request_id, retryable).
+func httpError(w http.ResponseWriter, r *http.Request, message string, code int) {
+	resp := apierror.Response{
+		Code:      apierror.CodeForStatus(code),
+		Message:   message,
+		RequestID: r.Header.Get("X-Request-ID"),
+		Retryable: code >= 500 || code == http.StatusTooManyRequests,
+	}
+	switch code {
+	case http.StatusMethodNotAllowed:
+		resp.Hint = "Проверьте HTTP-метод запроса"
+	case http.StatusBadRequest:
+		resp.Hint = "Проверьте формат тела запроса"
+	}
+	if code >= 500 {
+		// Серверные ошибки уходят и в системный лог agent-service (см. logger.Init)
+		slog.Error(message,
+			slog.String("correlation_id", resp.RequestID),
+			slog.String("метод", r.Method),
+			slog.String("путь", r.URL.Path),
+			slog.Int("статус", code),
+		)
+	}
+	apierror.Write(w, code, resp)
+}
+
+// --- Конфигурация ---
+
+// handleConfig — текущая конфигурация сервиса без секретов.
+// GET /browser/config; поле reloadable перечисляет параметры, применяемые без перезапуска.
+func handleConfig(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		httpError(w, r, "Метод не поддерживается", http.StatusMethodNotAllowed)
+		return
+	}
+	jsonResponse(w, map[string]interface{}{
+		"config":     config.Current().Sanitized(),
+		"reloadable": config.Reloadable(),
+	})
 }
 
-// getPort — получает порт из переменной окружения или возвращает 8084.
-func getPort() string {
-	port := os.Getenv("BROWSER_SERVICE_PORT")
-	if port == "" {
-		port = "8084"
+// handleConfigReload — перечитывает конфигурацию, как и SIGHUP.
+// POST /browser/config/reload; некорректная конфигурация отклоняется с 400.
+func handleConfigReload(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		httpError(w, r, "Метод не поддерживается", http.StatusMethodNotAllowed)
+		return
 	}
-	if _, err := strconv.Atoi(port); err != nil {
-		port = "8084"
+	res, err := reloadConfig()
+	if err != nil {
+		httpError(w, r, "Конфигурация не применена: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	jsonResponse(w, res)
+}
+
+// reloadConfig — перезагрузка конфигурации с записью результата в лог.
+func reloadConfig() (config.ReloadResult, error) {
+	res, err := config.Reload()
+	if err != nil {
+		slog.Error("Перезагрузка конфигурации отклонена", slog.String("ошибка", err.Error()))
+		return res, err
 	}
-	return port
+	slog.Info("Конфигурация перезагружена", slog.Any("применено", res.Applied), slog.Any("требует_перезапуска", res.RestartRequired))
+	return res, nil
 }
 
 // ============================================================================
@@ -741,7 +834,17 @@ func getPort() string {
 // ============================================================================
 
 func main() {
-	port := getPort()
+	logger.Init("browser-service")
+	cfg, err := config.Load()
+	if err == nil {
+		err = cfg.Validate()
+	}
+	if err != nil {
+		slog.Error("Некорректная конфигурация", slog.String("ошибка", err.Error()))
+		os.Exit(1)
+	}
+	config.Set(cfg)
+	port := cfg.Port
 
 	// --- Браузер (навигация, контент) ---
 	http.HandleFunc("/browser/dom", handleGetDOM)
@@ -786,13 +889,48 @@ func main() {
 
 	// --- Служебные ---
 	http.HandleFunc("/health", handleHealth)
+	http.HandleFunc("/ready", health.Handler("browser-service", readinessChecks))
 	http.HandleFunc("/info", handleInfo)
+	http.HandleFunc("/browser/config", handleConfig)
+	http.HandleFunc("/browser/config/reload", handleConfigReload)
+
+	// SIGHUP — перезагрузка изменяемых на лету параметров (SEARXNG_URL и др.)
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for range hup {
+			reloadConfig()
+		}
+	}()
 
 	log.Printf("=== browser-service запущен на порту %s ===", port)
 	log.Printf("Эндпоинты: /browser/*, /input/*, /search/*, /crawler/*, /access/*")
 	log.Printf("Информация: GET /info")
 
-	if err := http.ListenAndServe(fmt.Sprintf(":%s", port), nil); err != nil {
+	// Трассировка: продолжение traceparent от agent-service и экспорт спанов в OTLP
+	tracing.Init("browser-service")
+
+	handler := tracing.Middleware(http.DefaultServeMux)
+
+	// Внутренний gRPC API для agent-service (proto/browser/v1/browser.proto); 0 — отключён.
+	// Стандартный grpc.health.v1 проверяет api-gateway в /ready.
+	if grpcPort := cfg.GRPCPort; grpcPort != "0" {
+		lis, err := net.Listen("tcp", ":"+grpcPort)
+		if err != nil {
+			log.Fatalf("Не удалось открыть порт gRPC %s: %v", grpcPort, err)
+		}
+		grpcSrv := grpc.NewServer()
+		browserpb.RegisterBrowserServer(grpcSrv, &rpc.Server{Handler: handler})
+		healthpb.RegisterHealthServer(grpcSrv, grpchealth.NewServer())
+		go func() {
+			log.Printf("gRPC API browser-service запускается на порту %s", grpcPort)
+			if err := grpcSrv.Serve(lis); err != nil {
+				log.Printf("Ошибка gRPC-сервера: %v", err)
+			}
+		}()
+	}
+
+	if err := http.ListenAndServe(fmt.Sprintf(":%s", port), handler); err != nil {
 		log.Fatalf("Ошибка запуска сервера: %v", err)
 	}
 }
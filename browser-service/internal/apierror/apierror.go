@@ -0,0 +1,165 @@
+// Пакет apierror — единый формат ошибок HTTP API для всех микросервисов.
+//
+// Каждая ошибка содержит машиночитаемый code, текст message, подсказку hint,
+// идентификатор корреляции request_id и признак retryable. Одинаковая копия
+// формата живёт в api-gateway, tools-service, browser-service и agent-service,
+// поэтому ответы любого сервиса разбираются одной функцией Parse.
+package apierror
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+type Response struct {
+	Code      string `json:"code"`
+	Message   string `json:"message"`
+	Hint      string `json:"hint,omitempty"`
+	RequestID string `json:"request_id,omitempty"`
+	Retryable bool   `json:"retryable"`
+}
+
+func Write(w http.ResponseWriter, status int, resp Response) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(resp)
+}
+
+func BadRequest(w http.ResponseWriter, requestID, message, hint string) {
+	Write(w, http.StatusBadRequest, Response{
+		Code:      "BAD_REQUEST",
+		Message:   message,
+		Hint:      hint,
+		RequestID: requestID,
+		Retryable: false,
+	})
+}
+
+func Forbidden(w http.ResponseWriter, requestID, message, hint string) {
+	Write(w, http.StatusForbidden, Response{
+		Code:      "FORBIDDEN",
+		Message:   message,
+		Hint:      hint,
+		RequestID: requestID,
+		Retryable: false,
+	})
+}
+
+func Unauthorized(w http.ResponseWriter, requestID, message string) {
+	Write(w, http.StatusUnauthorized, Response{
+		Code:      "UNAUTHORIZED",
+		Message:   message,
+		RequestID: requestID,
+		Retryable: false,
+	})
+}
+
+func InternalError(w http.ResponseWriter, requestID, message, hint string) {
+	Write(w, http.StatusInternalServerError, Response{
+		Code:      "INTERNAL_ERROR",
+		Message:   message,
+		Hint:      hint,
+		RequestID: requestID,
+		Retryable: true,
+	})
+}
+
+func ServiceUnavailable(w http.ResponseWriter, requestID, message, hint string) {
+	Write(w, http.StatusServiceUnavailable, Response{
+		Code:      "SERVICE_UNAVAILABLE",
+		Message:   message,
+		Hint:      hint,
+		RequestID: requestID,
+		Retryable: true,
+	})
+}
+
+func MethodNotAllowed(w http.ResponseWriter, requestID string) {
+	Write(w, http.StatusMethodNotAllowed, Response{
+		Code:      "METHOD_NOT_ALLOWED",
+		Message:   "Метод не поддерживается",
+		RequestID: requestID,
+		Retryable: false,
+	})
+}
+
+func NotFound(w http.ResponseWriter, requestID, message string) {
+	Write(w, http.StatusNotFound, Response{
+		Code:      "NOT_FOUND",
+		Message:   message,
+		RequestID: requestID,
+		Retryable: false,
+	})
+}
+
+// CodeForStatus — возвращает машиночитаемый код ошибки по HTTP-статусу.
+// Используется, когда удалённый сервис не прислал собственный code.
+func CodeForStatus(status int) string {
+	switch status {
+	case http.StatusBadRequest:
+		return "BAD_REQUEST"
+	case http.StatusUnauthorized:
+		return "UNAUTHORIZED"
+	case http.StatusForbidden:
+		return "FORBIDDEN"
+	case http.StatusNotFound:
+		return "NOT_FOUND"
+	case http.StatusMethodNotAllowed:
+		return "METHOD_NOT_ALLOWED"
+	case http.StatusRequestEntityTooLarge:
+		return "PAYLOAD_TOO_LARGE"
+	case http.StatusTooManyRequests:
+		return "RATE_LIMITED"
+	case http.StatusBadGateway:
+		return "BAD_GATEWAY"
+	case http.StatusServiceUnavailable:
+		return "SERVICE_UNAVAILABLE"
+	case http.StatusGatewayTimeout:
+		return "GATEWAY_TIMEOUT"
+	}
+	if status >= 500 {
+		return "INTERNAL_ERROR"
+	}
+	return "HTTP_" + strconv.Itoa(status)
+}
+
+// Parse — разбирает тело ответа с ошибкой от другого сервиса в единый формат.
+//
+// Понимает три варианта тела:
+//   - структурированный Response ({"code", "message", "hint", "request_id"});
+//   - устаревший формат {"error": "..."};
+//   - произвольный текст (обрезается до 500 символов).
+//
+// Если сервис не прислал code или request_id, они заполняются по статусу
+// и заголовку X-Request-ID соответственно.
+func Parse(status int, header http.Header, body []byte) Response {
+	var resp Response
+	if err := json.Unmarshal(body, &resp); err != nil || resp.Message == "" {
+		resp = Response{}
+		var legacy struct {
+			Error string `json:"error"`
+		}
+		if json.Unmarshal(body, &legacy) == nil && legacy.Error != "" {
+			resp.Message = legacy.Error
+		} else {
+			text := strings.TrimSpace(string(body))
+			if r := []rune(text); len(r) > 500 {
+				text = string(r[:500]) + "..."
+			}
+			resp.Message = text
+		}
+		resp.Retryable = status >= 500 || status == http.StatusTooManyRequests
+	}
+	if resp.Message == "" {
+		resp.Message = http.StatusText(status)
+	}
+	if resp.Code == "" {
+		resp.Code = CodeForStatus(status)
+	}
+	if resp.RequestID == "" && header != nil {
+		resp.RequestID = header.Get("X-Request-ID")
+	}
+	return resp
+}
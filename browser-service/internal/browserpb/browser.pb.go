@@ -0,0 +1,201 @@
+// Внутренний API browser-service для agent-service.
+//
+// Работает параллельно с HTTP JSON API: внешние клиенты и api-gateway
+// по-прежнему ходят по HTTP, а agent-service вызывает инструменты браузера,
+// ввода и поиска по gRPC — одно долгоживущее HTTP/2-соединение вместо запроса
+// на каждый вызов. x-request-id и traceparent передаются как одноимённые
+// заголовки HTTP.
+//
+// Go-код: make proto (пакеты internal/browserpb в agent-service и browser-service).
+
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.11
+// 	protoc        v5.29.3
+// source: browser/v1/browser.proto
+
+package browserpb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type InvokeRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Path          string                 `protobuf:"bytes,1,opt,name=path,proto3" json:"path,omitempty"` // Эндпоинт HTTP API: /browser/dom, /input/click, /search...
+	Body          []byte                 `protobuf:"bytes,2,opt,name=body,proto3" json:"body,omitempty"` // JSON-тело запроса
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *InvokeRequest) Reset() {
+	*x = InvokeRequest{}
+	mi := &file_browser_v1_browser_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *InvokeRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*InvokeRequest) ProtoMessage() {}
+
+func (x *InvokeRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_browser_v1_browser_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use InvokeRequest.ProtoReflect.Descriptor instead.
+func (*InvokeRequest) Descriptor() ([]byte, []int) {
+	return file_browser_v1_browser_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *InvokeRequest) GetPath() string {
+	if x != nil {
+		return x.Path
+	}
+	return ""
+}
+
+func (x *InvokeRequest) GetBody() []byte {
+	if x != nil {
+		return x.Body
+	}
+	return nil
+}
+
+type InvokeResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Status        int32                  `protobuf:"varint,1,opt,name=status,proto3" json:"status,omitempty"` // HTTP-статус ответа
+	Body          []byte                 `protobuf:"bytes,2,opt,name=body,proto3" json:"body,omitempty"`      // JSON-тело ответа (при ошибке — формат apierror)
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *InvokeResponse) Reset() {
+	*x = InvokeResponse{}
+	mi := &file_browser_v1_browser_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *InvokeResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*InvokeResponse) ProtoMessage() {}
+
+func (x *InvokeResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_browser_v1_browser_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use InvokeResponse.ProtoReflect.Descriptor instead.
+func (*InvokeResponse) Descriptor() ([]byte, []int) {
+	return file_browser_v1_browser_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *InvokeResponse) GetStatus() int32 {
+	if x != nil {
+		return x.Status
+	}
+	return 0
+}
+
+func (x *InvokeResponse) GetBody() []byte {
+	if x != nil {
+		return x.Body
+	}
+	return nil
+}
+
+var File_browser_v1_browser_proto protoreflect.FileDescriptor
+
+const file_browser_v1_browser_proto_rawDesc = "" +
+	"\n" +
+	"\x18browser/v1/browser.proto\x12\x16agentregart.browser.v1\"7\n" +
+	"\rInvokeRequest\x12\x12\n" +
+	"\x04path\x18\x01 \x01(\tR\x04path\x12\x12\n" +
+	"\x04body\x18\x02 \x01(\fR\x04body\"<\n" +
+	"\x0eInvokeResponse\x12\x16\n" +
+	"\x06status\x18\x01 \x01(\x05R\x06status\x12\x12\n" +
+	"\x04body\x18\x02 \x01(\fR\x04body2b\n" +
+	"\aBrowser\x12W\n" +
+	"\x06Invoke\x12%.agentregart.browser.v1.InvokeRequest\x1a&.agentregart.browser.v1.InvokeResponseb\x06proto3"
+
+var (
+	file_browser_v1_browser_proto_rawDescOnce sync.Once
+	file_browser_v1_browser_proto_rawDescData []byte
+)
+
+func file_browser_v1_browser_proto_rawDescGZIP() []byte {
+	file_browser_v1_browser_proto_rawDescOnce.Do(func() {
+		file_browser_v1_browser_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_browser_v1_browser_proto_rawDesc), len(file_browser_v1_browser_proto_rawDesc)))
+	})
+	return file_browser_v1_browser_proto_rawDescData
+}
+
+var file_browser_v1_browser_proto_msgTypes = make([]protoimpl.MessageInfo, 2)
+var file_browser_v1_browser_proto_goTypes = []any{
+	(*InvokeRequest)(nil),  // 0: agentregart.browser.v1.InvokeRequest
+	(*InvokeResponse)(nil), // 1: agentregart.browser.v1.InvokeResponse
+}
+var file_browser_v1_browser_proto_depIdxs = []int32{
+	0, // 0: agentregart.browser.v1.Browser.Invoke:input_type -> agentregart.browser.v1.InvokeRequest
+	1, // 1: agentregart.browser.v1.Browser.Invoke:output_type -> agentregart.browser.v1.InvokeResponse
+	1, // [1:2] is the sub-list for method output_type
+	0, // [0:1] is the sub-list for method input_type
+	0, // [0:0] is the sub-list for extension type_name
+	0, // [0:0] is the sub-list for extension extendee
+	0, // [0:0] is the sub-list for field type_name
+}
+
+func init() { file_browser_v1_browser_proto_init() }
+func file_browser_v1_browser_proto_init() {
+	if File_browser_v1_browser_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_browser_v1_browser_proto_rawDesc), len(file_browser_v1_browser_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   2,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_browser_v1_browser_proto_goTypes,
+		DependencyIndexes: file_browser_v1_browser_proto_depIdxs,
+		MessageInfos:      file_browser_v1_browser_proto_msgTypes,
+	}.Build()
+	File_browser_v1_browser_proto = out.File
+	file_browser_v1_browser_proto_goTypes = nil
+	file_browser_v1_browser_proto_depIdxs = nil
+}
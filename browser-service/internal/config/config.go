@@ -0,0 +1,238 @@
+// Package config — централизованная конфигурация browser-service.
+//
+// Параметры собираются в три слоя: значения по умолчанию → YAML-файл
+// (BROWSER_CONFIG_FILE, необязателен) → переменные окружения. Конфигурация
+// проверяется при старте (порт, URL); часть параметров (Tunable) можно
+// перечитать на лету через Reload без перезапуска сервиса.
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Config — структура конфигурации browser-service.
+type Config struct {
+	Port     string `yaml:"port" json:"port"`           // Порт HTTP-сервера (по умолчанию 8084)
+	GRPCPort string `yaml:"grpc_port" json:"grpc_port"` // Порт gRPC (по умолчанию 9084; 0 — gRPC выключен)
+
+	Tunable `yaml:",inline"`
+}
+
+// Tunable — параметры, которые применяются на лету при Reload.
+type Tunable struct {
+	SearXNGURL       string `yaml:"searxng_url" json:"searxng_url"`               // Свой инстанс SearXNG (пусто — публичные инстансы)
+	SearchMaxResults int    `yaml:"search_max_results" json:"search_max_results"` // Результатов поиска, если max_results не задан
+}
+
+// Defaults — конфигурация по умолчанию.
+func Defaults() *Config {
+	return &Config{
+		Port:     "8084",
+		GRPCPort: "9084",
+		Tunable: Tunable{
+			SearchMaxResults: 10,
+		},
+	}
+}
+
+// Load — загружает конфигурацию: значения по умолчанию, затем YAML-файл
+// из BROWSER_CONFIG_FILE (если задан), затем переменные окружения.
+// Возвращает ошибку, если файл не читается или значения некорректны.
+func Load() (*Config, error) {
+	c := Defaults()
+	if path := os.Getenv("BROWSER_CONFIG_FILE"); path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("чтение файла конфигурации: %w", err)
+		}
+		if err := yaml.Unmarshal(data, c); err != nil {
+			return nil, fmt.Errorf("разбор файла конфигурации %s: %w", path, err)
+		}
+	}
+	envString(&c.Port, "BROWSER_SERVICE_PORT")
+	envString(&c.GRPCPort, "BROWSER_GRPC_PORT")
+	envString(&c.SearXNGURL, "SEARXNG_URL")
+	if err := envInt(&c.SearchMaxResults, "SEARCH_MAX_RESULTS"); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Validate — проверяет конфигурацию: порты, URL SearXNG и число результатов.
+// Возвращает все найденные ошибки сразу.
+func (c *Config) Validate() error {
+	var errs []error
+	if p, err := strconv.Atoi(c.Port); err != nil || p < 1 || p > 65535 {
+		errs = append(errs, fmt.Errorf("port: некорректный порт %q", c.Port))
+	}
+	if p, err := strconv.Atoi(c.GRPCPort); err != nil || p < 0 || p > 65535 {
+		errs = append(errs, fmt.Errorf("grpc_port: некорректный порт %q", c.GRPCPort))
+	}
+	if c.SearXNGURL != "" {
+		if err := validateURL(c.SearXNGURL); err != nil {
+			errs = append(errs, fmt.Errorf("searxng_url: %w", err))
+		}
+	}
+	if c.SearchMaxResults < 1 || c.SearchMaxResults > 100 {
+		errs = append(errs, fmt.Errorf("search_max_results: ожидается от 1 до 100, получено %d", c.SearchMaxResults))
+	}
+	return errors.Join(errs...)
+}
+
+// validateURL — URL должен быть абсолютным http(s)-адресом.
+func validateURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return err
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("ожидается http(s)://хост, получено %q", raw)
+	}
+	return nil
+}
+
+// Sanitized — копия конфигурации для GET /config: пароль в URL SearXNG скрыт.
+func (c *Config) Sanitized() Config {
+	s := *c
+	if u, err := url.Parse(s.SearXNGURL); err == nil && u.User != nil {
+		s.SearXNGURL = u.Redacted()
+	}
+	return s
+}
+
+var (
+	current  atomic.Pointer[Config]
+	reloadMu sync.Mutex
+	hooksMu  sync.Mutex
+	hooks    []func(*Config)
+)
+
+// Current — действующая конфигурация. До Set возвращает значения по умолчанию.
+func Current() *Config {
+	if c := current.Load(); c != nil {
+		return c
+	}
+	return Defaults()
+}
+
+// Set — делает c действующей конфигурацией (вызывается при старте).
+func Set(c *Config) {
+	current.Store(c)
+}
+
+// OnReload — регистрирует обработчик, вызываемый после применения Reload
+// (например, для передачи новых параметров обработчикам).
+func OnReload(fn func(*Config)) {
+	hooksMu.Lock()
+	hooks = append(hooks, fn)
+	hooksMu.Unlock()
+}
+
+// ReloadResult — итог перезагрузки конфигурации.
+type ReloadResult struct {
+	Applied         []string `json:"applied"`          // Изменённые параметры, применённые на лету
+	RestartRequired []string `json:"restart_required"` // Изменённые параметры, требующие перезапуска
+}
+
+// Reload — перечитывает файл и окружение и применяет изменения Tunable.
+// Остальные изменения не применяются и перечисляются в RestartRequired.
+// При ошибке загрузки или проверки действующая конфигурация не меняется.
+func Reload() (ReloadResult, error) {
+	reloadMu.Lock()
+	defer reloadMu.Unlock()
+	res := ReloadResult{Applied: []string{}, RestartRequired: []string{}}
+	fresh, err := Load()
+	if err != nil {
+		return res, err
+	}
+	if err := fresh.Validate(); err != nil {
+		return res, err
+	}
+	old := Current()
+	next := *old
+	next.Tunable = fresh.Tunable
+	res.Applied = diff(old.Tunable, fresh.Tunable)
+
+	oldRest, freshRest := *old, *fresh
+	oldRest.Tunable, freshRest.Tunable = Tunable{}, Tunable{}
+	res.RestartRequired = diff(oldRest, freshRest)
+
+	current.Store(&next)
+	if len(res.Applied) > 0 {
+		hooksMu.Lock()
+		fns := append([]func(*Config){}, hooks...)
+		hooksMu.Unlock()
+		for _, fn := range fns {
+			fn(&next)
+		}
+	}
+	return res, nil
+}
+
+// Reloadable — имена (yaml-теги) параметров, применяемых без перезапуска.
+func Reloadable() []string {
+	return fieldNames(reflect.TypeOf(Tunable{}))
+}
+
+// diff — имена (yaml-теги) полей, значения которых различаются в a и b.
+func diff(a, b interface{}) []string {
+	va, vb := reflect.ValueOf(a), reflect.ValueOf(b)
+	t := va.Type()
+	changed := []string{}
+	for i := 0; i < t.NumField(); i++ {
+		if t.Field(i).Anonymous {
+			continue
+		}
+		if !reflect.DeepEqual(va.Field(i).Interface(), vb.Field(i).Interface()) {
+			changed = append(changed, yamlName(t.Field(i)))
+		}
+	}
+	return changed
+}
+
+// fieldNames — yaml-имена всех полей структуры t.
+func fieldNames(t reflect.Type) []string {
+	names := make([]string, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		names = append(names, yamlName(t.Field(i)))
+	}
+	return names
+}
+
+func yamlName(f reflect.StructField) string {
+	name, _, _ := strings.Cut(f.Tag.Get("yaml"), ",")
+	return name
+}
+
+// envString — записывает в dst первое непустое значение из переменных keys.
+func envString(dst *string, keys ...string) {
+	for _, k := range keys {
+		if v := os.Getenv(k); v != "" {
+			*dst = v
+			return
+		}
+	}
+}
+
+func envInt(dst *int, key string) error {
+	v := os.Getenv(key)
+	if v == "" {
+		return nil
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(v))
+	if err != nil {
+		return fmt.Errorf("%s: ожидается целое число, получено %q", key, v)
+	}
+	*dst = n
+	return nil
+}
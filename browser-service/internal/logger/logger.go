@@ -0,0 +1,56 @@
+// Пакет logger — структурированное JSON-логирование для browser-service.
+// Использует стандартный пакет log/slog для вывода логов в формате JSON.
+// Каждая запись содержит: время, уровень, сообщение, сервис, correlation-id.
+// События уровня warn и error дополнительно отправляются в системный лог
+// agent-service (POST /logs), чтобы view_logs видел ошибки всей системы.
+package logger
+
+import (
+	"context"
+	"log/slog"
+	"os"
+)
+
+// ctxKey — тип ключа для хранения значений в контексте.
+type ctxKey string
+
+// CorrelationIDKey — ключ для хранения идентификатора корреляции в контексте запроса.
+const CorrelationIDKey ctxKey = "correlation_id"
+
+// shipper — отправитель warn/error-событий в agent-service (nil, если отключён).
+var shipper *Shipper
+
+// Инициализация — вызывается один раз при старте сервиса.
+// Устанавливает глобальный логгер с JSON-форматом и именем сервиса.
+func Init(serviceName string) {
+	var handler slog.Handler = slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{
+		Level: slog.LevelInfo,
+	})
+	if url := shipURL(); url != "" {
+		shipper = NewShipper(url, serviceName)
+		handler = &shipHandler{Handler: handler, shipper: shipper}
+	}
+	logger := slog.New(handler).With(slog.String("сервис", serviceName))
+	slog.SetDefault(logger)
+}
+
+// Flush — досылает накопленные записи в системный лог перед остановкой сервиса.
+func Flush(ctx context.Context) {
+	if shipper != nil {
+		shipper.Close(ctx)
+	}
+}
+
+// С возвращает логгер с привязанным идентификатором корреляции из контекста.
+// Если в контексте нет correlation-id — возвращает логгер без него.
+func С(ctx context.Context) *slog.Logger {
+	if cid, ok := ctx.Value(CorrelationIDKey).(string); ok && cid != "" {
+		return slog.Default().With(slog.String("correlation_id", cid))
+	}
+	return slog.Default()
+}
+
+// WithCorrelationID — добавляет идентификатор корреляции в контекст.
+func WithCorrelationID(ctx context.Context, cid string) context.Context {
+	return context.WithValue(ctx, CorrelationIDKey, cid)
+}
@@ -0,0 +1,212 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	shipQueueSize = 1000
+	shipBatchSize = 50
+	shipInterval  = 2 * time.Second
+)
+
+// ShipEntry — запись системного лога в формате POST /logs agent-service.
+type ShipEntry struct {
+	Level         string `json:"level"`
+	Service       string `json:"service"`
+	Message       string `json:"message"`
+	Details       string `json:"details,omitempty"`
+	CorrelationID string `json:"correlation_id,omitempty"`
+}
+
+// Shipper — асинхронная отправка warn/error-событий в центральный системный лог.
+//
+// Записи копятся в очереди и уходят пачками; при недоступности agent-service
+// или переполнении очереди записи отбрасываются — логирование никогда
+// не блокирует обработку запросов.
+type Shipper struct {
+	url     string
+	service string
+	client  *http.Client
+	queue   chan ShipEntry
+	dropped atomic.Int64
+	done    chan struct{}
+	flushed chan struct{}
+	once    sync.Once
+}
+
+// NewShipper — создаёт и запускает отправитель логов на адрес url.
+func NewShipper(url, service string) *Shipper {
+	s := &Shipper{
+		url:     url,
+		service: service,
+		client:  &http.Client{Timeout: 5 * time.Second},
+		queue:   make(chan ShipEntry, shipQueueSize),
+		done:    make(chan struct{}),
+		flushed: make(chan struct{}),
+	}
+	go s.run()
+	return s
+}
+
+// Enqueue — ставит запись в очередь без блокировки.
+func (s *Shipper) Enqueue(e ShipEntry) {
+	select {
+	case s.queue <- e:
+	default:
+		s.dropped.Add(1)
+	}
+}
+
+// Close — отправляет накопленные записи и останавливает отправитель.
+func (s *Shipper) Close(ctx context.Context) {
+	s.once.Do(func() { close(s.done) })
+	select {
+	case <-s.flushed:
+	case <-ctx.Done():
+	}
+}
+
+func (s *Shipper) run() {
+	defer close(s.flushed)
+	ticker := time.NewTicker(shipInterval)
+	defer ticker.Stop()
+	batch := make([]ShipEntry, 0, shipBatchSize)
+	flush := func() {
+		if n := s.dropped.Swap(0); n > 0 {
+			batch = append(batch, ShipEntry{
+				Level:   "warn",
+				Service: s.service,
+				Message: fmt.Sprintf("Очередь отправки логов переполнена, отброшено записей: %d", n),
+			})
+		}
+		if len(batch) == 0 {
+			return
+		}
+		if err := s.send(batch); err != nil {
+			// Пишем напрямую в stderr: через slog запись снова попала бы в очередь
+			fmt.Fprintf(os.Stderr, "отправка %d записей лога в %s не удалась: %v\n", len(batch), s.url, err)
+		}
+		batch = batch[:0]
+	}
+	for {
+		select {
+		case e := <-s.queue:
+			batch = append(batch, e)
+			if len(batch) >= shipBatchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		case <-s.done:
+			for {
+				select {
+				case e := <-s.queue:
+					batch = append(batch, e)
+				default:
+					flush()
+					return
+				}
+			}
+		}
+	}
+}
+
+func (s *Shipper) send(batch []ShipEntry) error {
+	data, err := json.Marshal(batch)
+	if err != nil {
+		return err
+	}
+	resp, err := s.client.Post(s.url, "application/json", bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("статус %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// shipHandler — slog.Handler, который помимо обычного вывода передаёт
+// записи уровня warn и выше в Shipper. Атрибуты записи попадают в details
+// (JSON), correlation_id — в отдельное поле.
+type shipHandler struct {
+	slog.Handler
+	shipper *Shipper
+	attrs   []slog.Attr
+}
+
+func (h *shipHandler) Handle(ctx context.Context, r slog.Record) error {
+	err := h.Handler.Handle(ctx, r)
+	if r.Level < slog.LevelWarn {
+		return err
+	}
+	entry := ShipEntry{Level: "warn", Service: h.shipper.service, Message: r.Message}
+	if r.Level >= slog.LevelError {
+		entry.Level = "error"
+	}
+	details := make(map[string]interface{})
+	collect := func(a slog.Attr) bool {
+		switch a.Key {
+		case "correlation_id":
+			entry.CorrelationID = a.Value.String()
+		case "сервис":
+		default:
+			details[a.Key] = a.Value.Any()
+		}
+		return true
+	}
+	for _, a := range h.attrs {
+		collect(a)
+	}
+	r.Attrs(collect)
+	if cid, ok := ctx.Value(CorrelationIDKey).(string); ok && entry.CorrelationID == "" {
+		entry.CorrelationID = cid
+	}
+	if len(details) > 0 {
+		if b, mErr := json.Marshal(details); mErr == nil {
+			entry.Details = string(b)
+		}
+	}
+	h.shipper.Enqueue(entry)
+	return err
+}
+
+func (h *shipHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &shipHandler{
+		Handler: h.Handler.WithAttrs(attrs),
+		shipper: h.shipper,
+		attrs:   append(append([]slog.Attr(nil), h.attrs...), attrs...),
+	}
+}
+
+func (h *shipHandler) WithGroup(name string) slog.Handler {
+	return &shipHandler{Handler: h.Handler.WithGroup(name), shipper: h.shipper, attrs: h.attrs}
+}
+
+// shipURL — адрес POST /logs agent-service из LOG_SHIP_URL или AGENT_SERVICE_URL.
+// LOG_SHIP_URL=off отключает отправку.
+func shipURL() string {
+	if v := os.Getenv("LOG_SHIP_URL"); v != "" {
+		if v == "off" {
+			return ""
+		}
+		return v
+	}
+	base := os.Getenv("AGENT_SERVICE_URL")
+	if base == "" {
+		base = "http://localhost:8083"
+	}
+	return strings.TrimRight(base, "/") + "/logs"
+}
@@ -0,0 +1,55 @@
+// Package rpc — gRPC-сервер внутреннего API browser-service для agent-service
+// (контракт — proto/browser/v1/browser.proto).
+//
+// Вызовы проходят через тот же http.Handler, что и HTTP API: трассировка и
+// обработчики эндпоинтов не дублируются. Метаданные x-request-id и
+// traceparent становятся одноимёнными заголовками запроса.
+package rpc
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	"github.com/neo-2022/openclaw-memory/browser-service/internal/browserpb"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// forwardedHeaders — метаданные gRPC, передаваемые обработчику как заголовки.
+var forwardedHeaders = map[string]string{
+	"x-request-id": "X-Request-ID",
+	"traceparent":  "Traceparent",
+}
+
+// Server — реализация browserpb.BrowserServer поверх HTTP-обработчика browser-service.
+type Server struct {
+	browserpb.UnimplementedBrowserServer
+	Handler http.Handler
+}
+
+// Invoke — POST path с JSON-телом; ответ обработчика возвращается как есть.
+func (s *Server) Invoke(ctx context.Context, req *browserpb.InvokeRequest) (*browserpb.InvokeResponse, error) {
+	path := req.GetPath()
+	if !strings.HasPrefix(path, "/") {
+		return nil, status.Errorf(codes.InvalidArgument, "путь %q должен начинаться с /", path)
+	}
+	r, err := http.NewRequestWithContext(ctx, http.MethodPost, path, bytes.NewReader(req.GetBody()))
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	r.RequestURI = path
+	r.Header.Set("Content-Type", "application/json")
+	md, _ := metadata.FromIncomingContext(ctx)
+	for key, header := range forwardedHeaders {
+		if v := md.Get(key); len(v) > 0 {
+			r.Header.Set(header, v[0])
+		}
+	}
+	rec := httptest.NewRecorder()
+	s.Handler.ServeHTTP(rec, r)
+	return &browserpb.InvokeResponse{Status: int32(rec.Code), Body: rec.Body.Bytes()}, nil
+}
@@ -362,7 +362,8 @@ func trySearXNGInstance(instanceURL, query string, maxResults int) SearchRespons
 //   - query: поисковый запрос
 //   - maxResults: максимальное количество результатов
 //   - preferredEngine: предпочитаемый поисковик ("duckduckgo", "searxng", "" = авто)
-func Search(query string, maxResults int, preferredEngine string) SearchResponse {
+//   - searxngInstance: URL собственного инстанса SearXNG (пусто = публичные)
+func Search(query string, maxResults int, preferredEngine, searxngInstance string) SearchResponse {
 	if maxResults <= 0 {
 		maxResults = 10
 	}
@@ -371,7 +372,7 @@ func Search(query string, maxResults int, preferredEngine string) SearchResponse
 	case "duckduckgo":
 		return SearchDuckDuckGo(query, maxResults)
 	case "searxng":
-		return SearchSearXNG(query, maxResults, "")
+		return SearchSearXNG(query, maxResults, searxngInstance)
 	default:
 		// Автовыбор: сначала DuckDuckGo, потом SearXNG
 		result := SearchDuckDuckGo(query, maxResults)
@@ -379,7 +380,7 @@ func Search(query string, maxResults int, preferredEngine string) SearchResponse
 			return result
 		}
 
-		result = SearchSearXNG(query, maxResults, "")
+		result = SearchSearXNG(query, maxResults, searxngInstance)
 		if result.Success && len(result.Results) > 0 {
 			return result
 		}
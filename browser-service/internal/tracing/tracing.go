@@ -0,0 +1,112 @@
+// Package tracing — трассировка browser-service на OpenTelemetry SDK.
+//
+// Действия браузера продолжают трассировку agent-service из traceparent.
+// Экспорт — OTLP/HTTP при заданном OTEL_EXPORTER_OTLP_ENDPOINT, семплер —
+// OTEL_TRACES_SAMPLER и OTEL_TRACES_SAMPLER_ARG.
+package tracing
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"os"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracerName — имя инструментирующей библиотеки в экспортируемых спанах.
+const tracerName = "browser-service"
+
+// Init — настраивает W3C-пропагацию, провайдер спанов с семплером из
+// OTEL_TRACES_SAMPLER и, если задан OTEL_EXPORTER_OTLP_ENDPOINT (или полный
+// адрес OTEL_EXPORTER_OTLP_TRACES_ENDPOINT), экспорт в OTLP/HTTP. Имя сервиса
+// берётся из OTEL_SERVICE_NAME, иначе используется serviceName.
+//
+// Возвращает функцию остановки, которая отправляет накопленные спаны.
+func Init(serviceName string) func(context.Context) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{}, propagation.Baggage{},
+	))
+	if v := os.Getenv("OTEL_SERVICE_NAME"); v != "" {
+		serviceName = v
+	}
+	res, err := resource.Merge(resource.Default(), resource.NewSchemaless(
+		attribute.String("service.name", serviceName),
+	))
+	if err != nil {
+		log.Printf("[ТРАССИРОВКА] описание ресурса: %v", err)
+		res = resource.Default()
+	}
+	opts := []sdktrace.TracerProviderOption{sdktrace.WithResource(res)}
+	if os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") != "" || os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT") != "" {
+		exporter, err := otlptracehttp.New(context.Background())
+		if err != nil {
+			log.Printf("[ТРАССИРОВКА] экспорт спанов выключен: %v", err)
+		} else {
+			opts = append(opts, sdktrace.WithBatcher(exporter))
+			log.Printf("[ТРАССИРОВКА] экспорт спанов OTLP включён (сервис %s)", serviceName)
+		}
+	}
+	tp := sdktrace.NewTracerProvider(opts...)
+	otel.SetTracerProvider(tp)
+
+	return func(ctx context.Context) {
+		if err := tp.Shutdown(ctx); err != nil {
+			log.Printf("[ТРАССИРОВКА] остановка: %v", err)
+		}
+	}
+}
+
+// Extract — контекст с родительским спаном из заголовка traceparent.
+func Extract(ctx context.Context, h http.Header) context.Context {
+	return otel.GetTextMapPropagator().Extract(ctx, propagation.HeaderCarrier(h))
+}
+
+// statusRecorder — запоминает HTTP-статус ответа для атрибутов спана.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(code int) {
+	r.status = code
+	r.ResponseWriter.WriteHeader(code)
+}
+
+// Unwrap — даёт http.ResponseController доступ к исходному writer.
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+// Middleware — открывает серверный спан на каждый входящий запрос,
+// продолжая трассировку из заголовка traceparent.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, span := otel.Tracer(tracerName).Start(Extract(r.Context(), r.Header), r.Method+" "+r.URL.Path,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				attribute.String("http.request.method", r.Method),
+				attribute.String("url.path", r.URL.Path),
+			))
+		defer span.End()
+		if rid := r.Header.Get("X-Request-ID"); rid != "" {
+			span.SetAttributes(attribute.String("request_id", rid))
+		}
+		w.Header().Set("X-Trace-ID", span.SpanContext().TraceID().String())
+
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r.WithContext(ctx))
+
+		span.SetAttributes(attribute.Int("http.response.status_code", rec.status))
+		if rec.status >= 500 {
+			span.SetStatus(codes.Error, http.StatusText(rec.status))
+		}
+	})
+}
@@ -79,6 +79,12 @@ class Settings:
     # Максимум кандидатов для проверки на противоречие
     CONTRADICTION_TOP_K = int(os.getenv("CONTRADICTION_TOP_K", "3"))
 
+    # === Дедупликация содержимого (см. app/dedup.py) ===
+    # Перед вставкой фрагмента файла, знания или эпизода ищется точный (по хешу
+    # нормализованного текста) или почти точный (по косинусной близости) дубликат.
+    DEDUP_ENABLED = os.getenv("DEDUP_ENABLED", "true").lower() == "true"
+    DEDUP_SIMILARITY_THRESHOLD = float(os.getenv("DEDUP_SIMILARITY_THRESHOLD", "0.97"))
+
     # === Skill Engine (Eternal RAG: раздел 5.3) ===
     # Confidence по умолчанию при создании нового навыка (0.0-1.0).
     SKILL_CONFIDENCE_DEFAULT = float(os.getenv("SKILL_CONFIDENCE_DEFAULT", "0.5"))
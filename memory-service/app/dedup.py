@@ -0,0 +1,166 @@
+"""
+Дедупликация содержимого перед вставкой в векторные коллекции.
+
+При повторной индексации папок и повторяющихся диалогах база знаний быстро
+заполняется одинаковыми записями: они вытесняют полезные результаты поиска и
+раздувают контекст модели. Перед вставкой фрагмента файла (RAG) или знания
+модели выполняются две проверки в пределах области (workspace, модель):
+
+1. Точный дубликат — совпадает SHA-256 нормализованного текста (content_hash
+   хранится в метаданных записи).
+2. Почти дубликат — косинусная близость эмбеддинга к ближайшей активной записи
+   не ниже DEDUP_SIMILARITY_THRESHOLD и одинаковые числа в текстах: «Python 3.12»
+   и «Python 3.11» близки по смыслу, но это обновление знания, а не дубликат.
+"""
+
+import hashlib
+import logging
+import re
+import threading
+import unicodedata
+from typing import Any, Dict, List, Optional
+
+logger = logging.getLogger(__name__)
+
+# Статусы записей, которые не считаются существующими (удалены или заменены новой версией).
+INACTIVE_STATUSES = {"deleted", "superseded"}
+
+_NON_WORD = re.compile(r"[\W_]+", re.UNICODE)
+_NUMBER = re.compile(r"\d+")
+
+
+def normalize_text(text: str) -> str:
+    """
+    Нормализует текст для сравнения: NFKC, нижний регистр, без пунктуации
+    и лишних пробелов. «Docker  запускается через sudo.» и
+    «docker запускается через SUDO» дают одинаковый результат.
+    """
+    normalized = unicodedata.normalize("NFKC", text or "").lower()
+    return _NON_WORD.sub(" ", normalized).strip()
+
+
+def content_hash(text: str) -> str:
+    """SHA-256 нормализованного текста — ключ точной дедупликации."""
+    return hashlib.sha256(normalize_text(text).encode("utf-8")).hexdigest()
+
+
+def numbers_in(text: str) -> List[str]:
+    """Числа в тексте по порядку — версии, порты, размеры."""
+    return _NUMBER.findall(text or "")
+
+
+def _scope_filter(scope: Dict[str, Any], extra: Optional[Dict[str, Any]] = None) -> Dict[str, Any]:
+    """Плоский фильтр where из области поиска дубликатов (неявный AND по ключам)."""
+    where = {key: value for key, value in scope.items() if value not in (None, "")}
+    if extra:
+        where.update(extra)
+    return where
+
+
+def _is_active(meta: Dict[str, Any]) -> bool:
+    return str(meta.get("status", "active")) not in INACTIVE_STATUSES
+
+
+class DedupService:
+    """
+    Поиск дубликата перед вставкой в коллекцию.
+
+    Счётчики пропущенных вставок доступны через stats() (GET /dedup/stats).
+    """
+
+    def __init__(self, enabled: bool = True, similarity_threshold: float = 0.97):
+        self.enabled = enabled
+        self.similarity_threshold = similarity_threshold
+        self._lock = threading.Lock()
+        self._stats: Dict[str, Dict[str, int]] = {}
+
+    def find_duplicate(
+        self,
+        collection: Any,
+        text: str,
+        embedding: List[float],
+        scope: Dict[str, Any],
+        kind: str,
+    ) -> Optional[Dict[str, Any]]:
+        """
+        Возвращает существующую активную запись-дубликат или None.
+
+        Args:
+            collection: Коллекция (QdrantCollectionCompat)
+            text: Текст новой записи
+            embedding: Эмбеддинг новой записи
+            scope: Область поиска, например {"workspace_id": "default"}
+            kind: Тип записи для статистики (files, learnings)
+
+        Returns:
+            {"id", "reason": "hash"|"similarity", "similarity", "metadata"} или None
+        """
+        if not self.enabled:
+            return None
+        self._count(kind, "checked")
+        try:
+            duplicate = self._find_by_hash(collection, text, scope) or self._find_by_similarity(
+                collection, text, embedding, scope
+            )
+        except Exception as e:
+            # Ошибка проверки не должна блокировать вставку
+            logger.warning(f"Ошибка проверки дубликата ({kind}): {e}")
+            return None
+        if duplicate:
+            self._count(kind, f"skipped_{duplicate['reason']}")
+            logger.info(
+                f"Пропущен дубликат ({kind}): совпадает с {duplicate['id']} "
+                f"({duplicate['reason']}, близость {duplicate['similarity']:.3f})"
+            )
+        return duplicate
+
+    def _find_by_hash(self, collection: Any, text: str, scope: Dict[str, Any]) -> Optional[Dict[str, Any]]:
+        data = collection.get(where=_scope_filter(scope, {"content_hash": content_hash(text)}), include=["metadatas"])
+        ids = data.get("ids", []) if data else []
+        metas = data.get("metadatas", []) if data else []
+        for idx, record_id in enumerate(ids):
+            meta = metas[idx] if idx < len(metas) and isinstance(metas[idx], dict) else {}
+            if _is_active(meta):
+                return {"id": record_id, "reason": "hash", "similarity": 1.0, "metadata": meta}
+        return None
+
+    def _find_by_similarity(
+        self, collection: Any, text: str, embedding: List[float], scope: Dict[str, Any]
+    ) -> Optional[Dict[str, Any]]:
+        if collection.count() == 0:
+            return None
+        results = collection.query(
+            query_embeddings=[embedding],
+            n_results=3,
+            include=["documents", "distances", "metadatas"],
+            where=_scope_filter(scope) or None,
+        )
+        ids = (results.get("ids") or [[]])[0]
+        docs = (results.get("documents") or [[]])[0]
+        dists = (results.get("distances") or [[]])[0]
+        metas = (results.get("metadatas") or [[]])[0]
+        numbers = numbers_in(text)
+        for idx, record_id in enumerate(ids):
+            similarity = 1.0 - (dists[idx] if idx < len(dists) else 1.0)
+            meta = metas[idx] if idx < len(metas) and isinstance(metas[idx], dict) else {}
+            doc = docs[idx] if idx < len(docs) else ""
+            if similarity < self.similarity_threshold or not _is_active(meta):
+                continue
+            if numbers_in(doc) != numbers:
+                continue
+            return {"id": record_id, "reason": "similarity", "similarity": similarity, "metadata": meta}
+        return None
+
+    def _count(self, kind: str, key: str) -> None:
+        with self._lock:
+            bucket = self._stats.setdefault(kind, {"checked": 0, "skipped_hash": 0, "skipped_similarity": 0})
+            bucket[key] = bucket.get(key, 0) + 1
+
+    def stats(self) -> Dict[str, Any]:
+        """Счётчики проверок и пропущенных дубликатов по типам записей."""
+        with self._lock:
+            return {
+                "enabled": self.enabled,
+                "similarity_threshold": self.similarity_threshold,
+                "by_kind": {kind: dict(bucket) for kind, bucket in self._stats.items()},
+            }
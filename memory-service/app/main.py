@@ -91,6 +91,25 @@ async def health_check():
     return {"status": "ok", "service": "memory-service"}
 
 
+@app.get("/ready", tags=["Health"])
+async def readiness_check():
+    """
+    Проверка готовности: хранилище ChromaDB отвечает на запросы.
+
+    В отличие от /health возвращает 503, если коллекции недоступны,
+    чтобы балансировщик не направлял запросы в неработающий экземпляр.
+    """
+    try:
+        stats = memory_store.get_stats()
+    except Exception as e:
+        logger.error(f"Хранилище не готово: {e}")
+        return JSONResponse(
+            status_code=status.HTTP_503_SERVICE_UNAVAILABLE,
+            content={"status": "not_ready", "service": "memory-service", "error": str(e)},
+        )
+    return {"status": "ready", "service": "memory-service", "checks": stats}
+
+
 @app.post("/facts", response_model=models.FactAddResponse, tags=["Facts"])
 async def add_fact(request: models.FactAddRequest):
     """
@@ -124,6 +143,46 @@ async def search(request: models.SearchRequest):
         raise HTTPException(status_code=500, detail=str(e))
 
 
+@app.post("/episodes", response_model=models.FactAddResponse, tags=["Episodes"])
+async def add_episode(request: models.EpisodeAddRequest):
+    """
+    Сохранить эпизод — краткое резюме завершённого диалога агента.
+    Вызывается agent-service после ответа, если включена эпизодическая память.
+    """
+    try:
+        episode_id = memory_store.add_episode(
+            summary=request.summary,
+            agent_name=request.agent_name,
+            model_name=request.model_name or "",
+            workspace_id=request.workspace_id,
+            metadata=request.metadata,
+        )
+        return models.FactAddResponse(id=episode_id, message="Episode added")
+    except Exception as e:
+        logger.exception("Ошибка при добавлении эпизода")
+        raise HTTPException(status_code=500, detail=str(e))
+
+
+@app.post("/episodes/search", response_model=models.SearchResponse, tags=["Episodes"])
+async def search_episodes(request: models.EpisodeSearchRequest):
+    """
+    Поиск прошлых эпизодов агента, похожих на текущий запрос
+    («ты уже делал похожее: …»).
+    """
+    try:
+        results = memory_store.search_episodes(
+            query=request.query,
+            agent_name=request.agent_name,
+            top_k=request.top_k,
+            min_score=request.min_score,
+            workspace_id=request.workspace_id,
+        )
+        return models.SearchResponse(results=results, count=len(results))
+    except Exception as e:
+        logger.exception("Ошибка при поиске эпизодов")
+        raise HTTPException(status_code=500, detail=str(e))
+
+
 @app.post("/files/chunks", response_model=models.FileChunkAddResponse, tags=["Files"])
 async def add_file_chunk(request: models.FileChunkAddRequest):
     """
@@ -440,6 +499,7 @@ async def search_learnings(request: models.LearningSearchRequest):
             category=request.category,
             workspace_id=request.workspace_id,
             min_priority=request.min_priority,
+            include_pinned=request.include_pinned,
         )
         return models.LearningSearchResponse(
             results=results,
@@ -467,6 +527,86 @@ async def get_learning_stats():
         raise HTTPException(status_code=500, detail=str(e))
 
 
+@app.get("/learnings/list/{model_name}", response_model=models.LearningListResponse, tags=["Learnings"])
+async def list_learnings(model_name: str, category: str = None, workspace_id: str = None, pinned_only: bool = False):
+    """
+    Список активных знаний модели — чтобы пользователь видел, что модель «помнит»,
+    и мог исправить, удалить или закрепить конкретное знание.
+    """
+    try:
+        items = memory_store.list_learnings(
+            model_name=model_name,
+            category=category,
+            workspace_id=workspace_id,
+            pinned_only=pinned_only,
+        )
+        return models.LearningListResponse(model_name=model_name, learnings=items, count=len(items))
+    except Exception as e:
+        logger.exception("Ошибка получения списка знаний")
+        raise HTTPException(status_code=500, detail=str(e))
+
+
+@app.patch("/learnings/item/{learning_id}", response_model=models.LearningItem, tags=["Learnings"])
+async def update_learning(learning_id: str, request: models.LearningUpdateRequest):
+    """Исправить ошибочное знание (текст пересчитывается в эмбеддинг заново)."""
+    if request.text is None and request.category is None:
+        raise HTTPException(status_code=400, detail="Укажите text и/или category")
+    try:
+        item = memory_store.update_learning(learning_id, text=request.text, category=request.category)
+        if item is None:
+            raise HTTPException(status_code=404, detail=f"Знание '{learning_id}' не найдено")
+        return item
+    except HTTPException:
+        raise
+    except Exception as e:
+        logger.exception("Ошибка редактирования знания")
+        raise HTTPException(status_code=500, detail=str(e))
+
+
+@app.delete("/learnings/item/{learning_id}", tags=["Learnings"])
+async def delete_learning(learning_id: str):
+    """Удалить одно знание (soft delete — остаётся в истории версий)."""
+    try:
+        if not memory_store.delete_learning(learning_id):
+            raise HTTPException(status_code=404, detail=f"Знание '{learning_id}' не найдено")
+        return {"id": learning_id, "status": "deleted"}
+    except HTTPException:
+        raise
+    except Exception as e:
+        logger.exception("Ошибка удаления знания")
+        raise HTTPException(status_code=500, detail=str(e))
+
+
+@app.post("/learnings/item/{learning_id}/pin", response_model=models.LearningItem, tags=["Learnings"])
+async def pin_learning(learning_id: str):
+    """Закрепить знание — подставляется в промпт всегда, независимо от релевантности."""
+    try:
+        item = memory_store.pin_learning(learning_id, pinned=True)
+        if item is None:
+            raise HTTPException(status_code=404, detail=f"Знание '{learning_id}' не найдено")
+        return item
+    except HTTPException:
+        raise
+    except Exception as e:
+        logger.exception("Ошибка закрепления знания")
+        raise HTTPException(status_code=500, detail=str(e))
+
+
+@app.delete("/learnings/item/{learning_id}/pin", response_model=models.LearningItem, tags=["Learnings"])
+async def unpin_learning(learning_id: str):
+    """Открепить знание — снова подставляется только по релевантности."""
+    try:
+        item = memory_store.pin_learning(learning_id, pinned=False)
+        if item is None:
+            raise HTTPException(status_code=404, detail=f"Знание '{learning_id}' не найдено")
+        return item
+    except HTTPException:
+        raise
+    except Exception as e:
+        logger.exception("Ошибка открепления знания")
+        raise HTTPException(status_code=500, detail=str(e))
+
+
 @app.delete("/learnings/{model_name}", tags=["Learnings"])
 async def delete_learnings(model_name: str, category: str = None, workspace_id: str = None):
     """
@@ -504,6 +644,12 @@ async def get_learning_versions(model_name: str, category: str = None, workspace
         raise HTTPException(status_code=500, detail=str(e))
 
 
+@app.get("/dedup/stats", tags=["Maintenance"])
+async def get_dedup_stats():
+    """Счётчики дедупликации: сколько вставок проверено и пропущено как дубликаты."""
+    return memory_store.dedup.stats()
+
+
 @app.get("/audit/logs", response_model=models.AuditLogsResponse, tags=["Maintenance"])
 async def get_audit_logs(top_k: int = 100, workspace_id: str = None, model_name: str = None):
     """Получить аудит операций памяти с фильтрами по workspace/model."""
@@ -11,6 +11,7 @@ from qdrant_client import QdrantClient
 from sentence_transformers import SentenceTransformer
 
 from .config import settings
+from .dedup import DedupService, content_hash
 from .qdrant_store import QdrantCollectionCompat
 from .ranking import build_rank_score, blend_relevance_scores, resolve_priority_score
 from .vector_backend import VECTOR_BACKEND_QDRANT
@@ -64,6 +65,9 @@ class MemoryStore:
         # Это позволяет каждой модели накапливать свою уникальную базу знаний.
         self.learnings_collection = self._get_or_create_collection("agent_learnings")
         self.audit_collection = self._get_or_create_collection("agent_memory_audit")
+        # Эпизодическая память — краткие резюме прошлых диалогов («задача → результат»),
+        # по которым агент вспоминает, что уже делал похожее в прошлых сессиях.
+        self.episodes_collection = self._get_or_create_collection("agent_episodes")
 
         # === Skill Engine & Graph Engine (Eternal RAG: разделы 5.3, 5.4) ===
         # Коллекции для навыков и связей графа знаний.
@@ -127,6 +131,16 @@ class MemoryStore:
         """Проверяет, является ли запись активной (не superseded и не deleted)."""
         return meta.get("status", LEARNING_STATUS_ACTIVE) == LEARNING_STATUS_ACTIVE
 
+    @property
+    def dedup(self) -> DedupService:
+        """Сервис дедупликации (создаётся лениво, настройки — DEDUP_* в config)."""
+        if getattr(self, "_dedup", None) is None:
+            self._dedup = DedupService(
+                enabled=settings.DEDUP_ENABLED,
+                similarity_threshold=settings.DEDUP_SIMILARITY_THRESHOLD,
+            )
+        return self._dedup
+
     def _encode_to_list(self, text: str) -> list:
         """
         Кодирует текст в вектор и возвращает как список (list).
@@ -356,6 +370,101 @@ class MemoryStore:
         logger.info(f"Добавлен факт (ID: {fact_id}): {fact_text[:50]}...")
         return fact_id
     
+    def add_episode(self, summary: str, agent_name: str, model_name: str = "",
+                    workspace_id: Optional[str] = None,
+                    metadata: Optional[Dict[str, Any]] = None) -> str:
+        """
+        Сохранение эпизода — краткого резюме завершённого диалога.
+
+        Args:
+            summary: Резюме «задача → результат»
+            agent_name: Агент, который вёл диалог
+            model_name: Модель, давшая ответ
+            workspace_id: Рабочее пространство (изоляция эпизодов)
+            metadata: Дополнительные метаданные (инструменты, ID сообщения)
+
+        Returns:
+            ID эпизода
+        """
+        if not summary or not summary.strip():
+            logger.warning("Попытка добавить пустой эпизод")
+            return ""
+
+        embedding = self._encode_to_list(summary)
+        episode_metadata = dict(metadata or {})
+        episode_metadata.update({
+            "agent_name": agent_name,
+            "model_name": model_name,
+            "workspace_id": workspace_id or "default",
+            "created_at": self._utc_now_iso(),
+            "content_hash": content_hash(summary),
+        })
+        duplicate = self.dedup.find_duplicate(
+            self.episodes_collection, summary, embedding,
+            scope={"agent_name": agent_name, "workspace_id": episode_metadata["workspace_id"]}, kind="episodes",
+        )
+        if duplicate:
+            return duplicate["id"]
+
+        episode_id = str(uuid.uuid4())
+        self.episodes_collection.add(
+            embeddings=[embedding],
+            documents=[summary],
+            metadatas=[episode_metadata],
+            ids=[episode_id],
+        )
+        self._add_audit_log(
+            event_type="episode_added",
+            model_name=model_name or None,
+            workspace_id=episode_metadata["workspace_id"],
+            details={"episode_id": episode_id, "agent_name": agent_name},
+        )
+        logger.info(f"Добавлен эпизод агента {agent_name} (ID: {episode_id})")
+        return episode_id
+
+    def search_episodes(self, query: str, agent_name: str, top_k: int = 3,
+                        min_score: float = 0.0,
+                        workspace_id: Optional[str] = None) -> List[Dict[str, Any]]:
+        """
+        Поиск прошлых эпизодов агента, похожих на текущий запрос.
+
+        Оценка — косинусная близость (0..1); эпизоды ниже min_score отбрасываются,
+        чтобы агент не «вспоминал» несвязанные разговоры.
+        """
+        if self.episodes_collection.count() == 0:
+            return []
+        where_filter: Dict[str, Any] = {"agent_name": agent_name}
+        if workspace_id:
+            where_filter = {"$and": [where_filter, {"workspace_id": workspace_id}]}
+        try:
+            results = self.episodes_collection.query(
+                query_embeddings=[self._encode_to_list(query)],
+                n_results=top_k,
+                include=["documents", "distances", "metadatas"],
+                where=where_filter,
+            )
+            docs = results.get("documents", [[]])[0] if results else []
+            dists = results.get("distances", [[]])[0] if results else []
+            metas = results.get("metadatas", [[]])[0] if results else []
+            ids = results.get("ids", [[]])[0] if results else []
+            items: List[Dict[str, Any]] = []
+            for i, doc in enumerate(docs):
+                score = max(0.0, 1.0 - (dists[i] if i < len(dists) else 1.0))
+                if score < min_score:
+                    continue
+                items.append({
+                    "id": ids[i] if i < len(ids) else "",
+                    "text": doc,
+                    "score": score,
+                    "source": "episodes",
+                    "metadata": metas[i] if i < len(metas) else {},
+                })
+            items.sort(key=lambda x: x["score"], reverse=True)
+            return items
+        except Exception as e:
+            logger.error(f"Ошибка поиска эпизодов агента {agent_name}: {e}")
+            return []
+
     def search_facts(
         self,
         query: str,
@@ -470,12 +579,21 @@ class MemoryStore:
         if not chunk_text or not chunk_text.strip():
             return ""
         
-        chunk_id = str(uuid.uuid4())
         embedding = self._encode_to_list(chunk_text)
         
         file_metadata = dict(metadata)
         file_metadata.setdefault("workspace_id", "default")
+        file_metadata["content_hash"] = content_hash(chunk_text)
 
+        # Повторная индексация папки не должна плодить одинаковые фрагменты
+        duplicate = self.dedup.find_duplicate(
+            self.files_collection, chunk_text, embedding,
+            scope={"workspace_id": file_metadata["workspace_id"]}, kind="files",
+        )
+        if duplicate:
+            return duplicate["id"]
+
+        chunk_id = str(uuid.uuid4())
         self.files_collection.add(
             embeddings=[embedding],
             documents=[chunk_text],
@@ -1021,10 +1139,21 @@ class MemoryStore:
             logger.warning("Попытка добавить пустое знание")
             return ""
         
-        learning_id = str(uuid.uuid4())
         embedding = self._encode_to_list(text)
 
         normalized_workspace = workspace_id or (metadata or {}).get("workspace_id") or "default"
+
+        # Повторяющиеся диалоги дают одно и то же знание: вместо новой версии
+        # усиливаем существующее (frequency участвует в ранжировании)
+        duplicate = self.dedup.find_duplicate(
+            self.learnings_collection, text, embedding,
+            scope={"model_name": model_name, "workspace_id": normalized_workspace}, kind="learnings",
+        )
+        if duplicate:
+            self._reinforce_learning(duplicate["id"], duplicate["metadata"])
+            return duplicate["id"]
+
+        learning_id = str(uuid.uuid4())
         learning_key = self._build_learning_key(
             model_name=f"{normalized_workspace}:{model_name}",
             category=category,
@@ -1077,6 +1206,7 @@ class MemoryStore:
             # Семантические противоречия с другими знаниями
             "contradictions_count": len(contradictions),
             "contradictions_json": json.dumps(contradictions, ensure_ascii=False) if contradictions else "",
+            "content_hash": content_hash(text),
         }
         if metadata:
             learning_metadata.update(metadata)
@@ -1112,14 +1242,49 @@ class MemoryStore:
         )
         return learning_id
     
+    def _reinforce_learning(self, learning_id: str, meta: Dict[str, Any]) -> None:
+        """Повтор знания: увеличивает frequency и счётчик повторов вместо вставки дубликата."""
+        updated = dict(meta)
+        updated["duplicate_count"] = self._as_int(updated.get("duplicate_count"), 0) + 1
+        try:
+            frequency = float(updated.get("frequency", 0.5))
+        except (TypeError, ValueError):
+            frequency = 0.5
+        updated["frequency"] = min(1.0, frequency + 0.1)
+        updated["last_seen_at"] = self._utc_now_iso()
+        self.learnings_collection.update(ids=[learning_id], metadatas=[updated])
+        self._add_audit_log(
+            event_type="learning_deduplicated",
+            model_name=updated.get("model_name"),
+            workspace_id=updated.get("workspace_id"),
+            learning_id=learning_id,
+            details={"duplicate_count": updated["duplicate_count"]},
+        )
+
     def search_learnings(self, query: str, model_name: str,
                          top_k: int = 5, category: Optional[str] = None,
                          workspace_id: Optional[str] = None,
-                         min_priority: Optional[str] = None) -> List[Dict[str, Any]]:
+                         min_priority: Optional[str] = None,
+                         include_pinned: bool = False) -> List[Dict[str, Any]]:
         """
         Поиск релевантных знаний для конкретной модели LLM.
         Возвращает структурированные результаты: text, score, source, metadata.
+        С include_pinned к результатам добавляются все закреплённые знания модели
+        (source="pinned"), даже если они не похожи на запрос.
         """
+        items = self._search_learnings(query, model_name, top_k, category, workspace_id, min_priority)
+        if include_pinned:
+            found = {item["id"] for item in items}
+            for pinned in self.list_learnings(model_name, workspace_id=workspace_id, pinned_only=True):
+                if pinned["id"] not in found:
+                    items.append({"id": pinned["id"], "text": pinned["text"], "score": 1.0,
+                                  "source": "pinned", "metadata": pinned["metadata"]})
+        return items
+
+    def _search_learnings(self, query: str, model_name: str, top_k: int,
+                          category: Optional[str], workspace_id: Optional[str],
+                          min_priority: Optional[str]) -> List[Dict[str, Any]]:
+        """Семантический поиск знаний модели (без закреплённых вне выдачи)."""
         start_ts = time.perf_counter()
         if self.learnings_collection.count() == 0:
             self._record_search_metrics(start_ts=start_ts, results_count=0, is_error=False)
@@ -1338,6 +1503,154 @@ class MemoryStore:
             logger.error(f"Ошибка удаления знаний модели {model_name}: {e}")
             return 0
 
+    @staticmethod
+    def _is_pinned_learning(meta: Dict[str, Any]) -> bool:
+        """Закреплённое знание подставляется в промпт независимо от релевантности."""
+        return str(meta.get("pinned", "false")).lower() == "true"
+
+    def _learning_item(self, learning_id: str, text: str, meta: Dict[str, Any]) -> Dict[str, Any]:
+        """Представление знания для API управления (/learnings/list, /learnings/item)."""
+        return {
+            "id": learning_id,
+            "text": text,
+            "category": str(meta.get("category", "general")),
+            "version": self._as_int(meta.get("version"), 1),
+            "status": str(meta.get("status", LEARNING_STATUS_ACTIVE)),
+            "pinned": self._is_pinned_learning(meta),
+            "created_at": str(meta.get("created_at", "")),
+            "metadata": meta,
+        }
+
+    def _get_active_learning(self, learning_id: str) -> Optional[Dict[str, Any]]:
+        """Возвращает активное знание по ID (document + metadata) или None."""
+        data = self.learnings_collection.get(ids=[learning_id], include=["metadatas", "documents"])
+        ids = data.get("ids", []) if data else []
+        if not ids:
+            return None
+        metas = data.get("metadatas", [])
+        docs = data.get("documents", [])
+        meta = dict(metas[0]) if metas and isinstance(metas[0], dict) else {}
+        if not self._is_active_learning(meta):
+            return None
+        return {"id": ids[0], "document": docs[0] if docs else "", "metadata": meta}
+
+    def list_learnings(self, model_name: str, category: Optional[str] = None,
+                       workspace_id: Optional[str] = None,
+                       pinned_only: bool = False) -> List[Dict[str, Any]]:
+        """
+        Список активных знаний модели для просмотра и правки пользователем.
+
+        Закреплённые знания идут первыми, остальные — от новых к старым.
+        """
+        where_filter: Dict[str, Any] = {"model_name": model_name}
+        if workspace_id:
+            where_filter["workspace_id"] = workspace_id
+        if category:
+            where_filter["category"] = category
+        try:
+            data = self.learnings_collection.get(where=where_filter, include=["metadatas", "documents"])
+            ids = data.get("ids", []) if data else []
+            metas = data.get("metadatas", []) if data else []
+            docs = data.get("documents", []) if data else []
+            items: List[Dict[str, Any]] = []
+            for idx, learning_id in enumerate(ids):
+                meta = metas[idx] if idx < len(metas) and isinstance(metas[idx], dict) else {}
+                if not self._is_active_learning(meta):
+                    continue
+                if pinned_only and not self._is_pinned_learning(meta):
+                    continue
+                items.append(self._learning_item(learning_id, docs[idx] if idx < len(docs) else "", meta))
+            items.sort(key=lambda item: item["created_at"], reverse=True)
+            items.sort(key=lambda item: item["pinned"], reverse=True)
+            return items
+        except Exception as e:
+            logger.error(f"Ошибка получения списка знаний модели {model_name}: {e}")
+            return []
+
+    def update_learning(self, learning_id: str, text: Optional[str] = None,
+                        category: Optional[str] = None) -> Optional[Dict[str, Any]]:
+        """
+        Правка ошибочного знания: новый текст (с пересчётом эмбеддинга) и/или категория.
+
+        Returns:
+            Обновлённое знание или None, если активное знание не найдено
+        """
+        current = self._get_active_learning(learning_id)
+        if current is None:
+            return None
+        meta = current["metadata"]
+        document = current["document"]
+        if category:
+            meta["category"] = category
+        meta["edited_at"] = self._utc_now_iso()
+
+        if text is not None and text.strip() and text.strip() != document.strip():
+            document = text.strip()
+            # add с тем же ID перезаписывает точку вместе с вектором
+            self.learnings_collection.add(
+                embeddings=[self._encode_to_list(document)],
+                documents=[document],
+                metadatas=[meta],
+                ids=[learning_id],
+            )
+        else:
+            self.learnings_collection.update(ids=[learning_id], metadatas=[meta])
+
+        self._add_audit_log(
+            event_type="learning_edited",
+            model_name=meta.get("model_name"),
+            workspace_id=meta.get("workspace_id"),
+            learning_id=learning_id,
+            details={"category": meta.get("category", "")},
+        )
+        logger.info(f"Знание {learning_id} отредактировано")
+        return self._learning_item(learning_id, document, meta)
+
+    def delete_learning(self, learning_id: str) -> bool:
+        """Soft-delete одного знания (как delete_model_learnings, но по ID)."""
+        current = self._get_active_learning(learning_id)
+        if current is None:
+            return False
+        meta = current["metadata"]
+        meta["status"] = LEARNING_STATUS_DELETED
+        meta["deleted_at"] = self._utc_now_iso()
+        self.learnings_collection.update(ids=[learning_id], metadatas=[meta])
+        self._add_audit_log(
+            event_type="learning_soft_deleted",
+            model_name=meta.get("model_name"),
+            workspace_id=meta.get("workspace_id"),
+            learning_id=learning_id,
+            details={},
+        )
+        logger.info(f"Знание {learning_id} помечено удалённым")
+        return True
+
+    def pin_learning(self, learning_id: str, pinned: bool = True) -> Optional[Dict[str, Any]]:
+        """
+        Закрепление/открепление знания.
+
+        Закреплённые знания подставляются в промпт всегда, независимо от
+        семантической близости к запросу (см. include_pinned в search_learnings).
+        """
+        current = self._get_active_learning(learning_id)
+        if current is None:
+            return None
+        meta = current["metadata"]
+        meta["pinned"] = "true" if pinned else "false"
+        if pinned:
+            meta["priority"] = "pinned"
+        elif meta.get("priority") == "pinned":
+            meta["priority"] = "normal"
+        self.learnings_collection.update(ids=[learning_id], metadatas=[meta])
+        self._add_audit_log(
+            event_type="learning_pinned" if pinned else "learning_unpinned",
+            model_name=meta.get("model_name"),
+            workspace_id=meta.get("workspace_id"),
+            learning_id=learning_id,
+            details={},
+        )
+        return self._learning_item(learning_id, current["document"], meta)
+
     def get_embedding_status(self) -> Dict[str, Any]:
         """
         Возвращает статус модели эмбеддингов для мониторинга в UI.
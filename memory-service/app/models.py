@@ -50,6 +50,24 @@ class SearchResponse(BaseModel):
     count: int
 
 
+class EpisodeAddRequest(BaseModel):
+    """Запрос на сохранение эпизода — резюме завершённого диалога."""
+    summary: str = Field(..., description="Резюме «задача → результат»", min_length=1, max_length=MAX_TEXT_LENGTH)
+    agent_name: str = Field(..., description="Агент, который вёл диалог", min_length=1)
+    model_name: Optional[str] = Field("", description="Модель, давшая ответ")
+    workspace_id: Optional[str] = Field(None, description="Рабочее пространство")
+    metadata: Optional[Dict[str, Any]] = Field(default_factory=dict, description="Доп. метаданные (инструменты и др.)")
+
+
+class EpisodeSearchRequest(BaseModel):
+    """Запрос на поиск похожих прошлых эпизодов агента."""
+    query: str = Field(..., description="Текущий запрос пользователя", min_length=1, max_length=MAX_QUERY_LENGTH)
+    agent_name: str = Field(..., description="Агент", min_length=1)
+    top_k: int = Field(3, description="Количество эпизодов", ge=1, le=10)
+    min_score: float = Field(0.0, description="Минимальная близость (0..1)", ge=0.0, le=1.0)
+    workspace_id: Optional[str] = Field(None, description="Рабочее пространство")
+
+
 class FileChunkAddRequest(BaseModel):
     """Запрос на добавление фрагмента файла."""
     text: str = Field(..., description="Текст фрагмента", min_length=1, max_length=MAX_TEXT_LENGTH)
@@ -138,6 +156,7 @@ class LearningSearchRequest(BaseModel):
     top_k: Optional[int] = Field(5, description="Количество результатов", ge=1, le=20)
     category: Optional[str] = Field(None, description="Фильтр по категории знания")
     min_priority: Optional[str] = Field(None, description="Минимальный приоритет знаний: critical|pinned|reinforced|normal|archived")
+    include_pinned: bool = Field(False, description="Добавить все закреплённые знания модели независимо от релевантности")
 
 
 class LearningSearchResponse(BaseModel):
@@ -169,6 +188,31 @@ class LearningVersionItem(BaseModel):
     metadata: Dict[str, Any] = Field(default_factory=dict)
 
 
+class LearningItem(BaseModel):
+    """Активное знание модели для просмотра и правки пользователем."""
+    id: str
+    text: str
+    category: str
+    version: int
+    status: str
+    pinned: bool = False
+    created_at: str = ""
+    metadata: Dict[str, Any] = Field(default_factory=dict)
+
+
+class LearningListResponse(BaseModel):
+    """Список активных знаний модели (закреплённые — первыми)."""
+    model_name: str
+    learnings: List[LearningItem] = Field(default_factory=list)
+    count: int
+
+
+class LearningUpdateRequest(BaseModel):
+    """Правка знания: новый текст и/или категория."""
+    text: Optional[str] = Field(None, description="Исправленный текст знания", min_length=1, max_length=MAX_TEXT_LENGTH)
+    category: Optional[str] = Field(None, description="Новая категория знания", min_length=1)
+
+
 class LearningVersionsResponse(BaseModel):
     """Ответ со списком версий знания по модели/категории/workspace."""
     model_name: str
@@ -0,0 +1,69 @@
+from unittest.mock import Mock
+
+from app.dedup import DedupService, content_hash, normalize_text, numbers_in
+from tests.test_memory import MockQdrantCollection
+
+
+def _collection_with(text, metadata=None):
+    collection = MockQdrantCollection()
+    meta = {"workspace_id": "default", "content_hash": content_hash(text)}
+    meta.update(metadata or {})
+    collection.add(embeddings=[[0.1] * 4], documents=[text], metadatas=[meta], ids=["existing"])
+    return collection
+
+
+class TestNormalize:
+    """Нормализация текста и хэш содержимого."""
+
+    def test_case_and_punctuation_ignored(self):
+        """Регистр, пунктуация и лишние пробелы не влияют на хэш."""
+        assert normalize_text("Docker  запускается через sudo.") == "docker запускается через sudo"
+        assert content_hash("Docker  запускается через sudo.") == content_hash("docker запускается через SUDO")
+        assert content_hash("Docker запускается через sudo") != content_hash("Podman запускается без sudo")
+
+    def test_numbers_in(self):
+        """Числа извлекаются по порядку."""
+        assert numbers_in("Python 3.12 на порту 8080") == ["3", "12", "8080"]
+        assert numbers_in("") == []
+
+
+class TestDedupService:
+    """Поиск дубликатов по хэшу и по близости эмбеддинга."""
+
+    def test_hash_duplicate_in_scope(self):
+        """Точный дубликат находится только в своей области."""
+        collection = _collection_with("Ollama слушает порт 11434")
+        dedup = DedupService()
+        found = dedup.find_duplicate(collection, "ollama слушает порт 11434!", [0.1] * 4, {"workspace_id": "default"}, "files")
+        assert found["id"] == "existing"
+        assert found["reason"] == "hash"
+        other = dedup.find_duplicate(collection, "Ollama слушает порт 11434", [0.1] * 4, {"workspace_id": "team"}, "files")
+        assert other is None
+        assert dedup.stats()["by_kind"]["files"] == {"checked": 2, "skipped_hash": 1, "skipped_similarity": 0}
+
+    def test_inactive_record_not_duplicate(self):
+        """Удалённая или заменённая запись не мешает вставке."""
+        collection = _collection_with("Ollama слушает порт 11434", {"status": "deleted"})
+        collection.query = Mock(return_value={"ids": [[]], "documents": [[]], "distances": [[]], "metadatas": [[]]})
+        assert DedupService().find_duplicate(collection, "Ollama слушает порт 11434", [0.1] * 4, {}, "files") is None
+
+    def test_similarity_duplicate_and_numbers_guard(self):
+        """Близкий по смыслу текст — дубликат, если числа в текстах совпадают."""
+        collection = _collection_with("Python version is 3.12")
+        collection.query = Mock(return_value={
+            "ids": [["existing"]],
+            "documents": [["Python version is 3.12"]],
+            "distances": [[0.01]],
+            "metadatas": [[{"workspace_id": "default"}]],
+        })
+        dedup = DedupService(similarity_threshold=0.97)
+        found = dedup.find_duplicate(collection, "The Python version is 3.12", [0.1] * 4, {}, "learnings")
+        assert found["reason"] == "similarity"
+        assert dedup.find_duplicate(collection, "Python version is 3.11", [0.1] * 4, {}, "learnings") is None
+
+    def test_disabled(self):
+        """При DEDUP_ENABLED=false проверка не выполняется."""
+        collection = _collection_with("Ollama слушает порт 11434")
+        dedup = DedupService(enabled=False)
+        assert dedup.find_duplicate(collection, "Ollama слушает порт 11434", [0.1] * 4, {}, "files") is None
+        assert dedup.stats()["by_kind"] == {}
@@ -4,6 +4,7 @@
 Используют TestClient для проверки HTTP-эндпоинтов
 без запуска реального сервера. Покрывают:
 - /health — проверка здоровья сервиса
+- /ready — проверка готовности хранилища
 - /stats — статистика коллекций
 - /facts — добавление фактов
 - /search — поиск фактов
@@ -33,6 +34,15 @@ def test_health(client):
     assert data["service"] == "memory-service"
 
 
+def test_ready(client):
+    """Проверяет эндпоинт /ready: хранилище доступно, status=ready."""
+    resp = client.get("/ready")
+    assert resp.status_code == 200
+    data = resp.json()
+    assert data["status"] == "ready"
+    assert "facts_count" in data["checks"]
+
+
 def test_stats(client):
     """Проверяет эндпоинт /stats: наличие полей facts_count, files_count, learnings_count."""
     resp = client.get("/stats")
@@ -185,7 +195,7 @@ def test_learning_versioning_and_soft_delete(client):
     assert first_data["version"] == 1
     assert first_data["conflict_detected"] is False
 
-    second = client.post("/learnings", json=payload)
+    second = client.post("/learnings", json={**payload, "text": "Use markdown headers (h2, h3) in answers"})
     assert second.status_code == 200
     second_data = second.json()
     assert second_data["version"] == 2
@@ -295,6 +305,44 @@ def test_learning_versions_endpoint(client):
     assert "superseded" in statuses
 
 
+def test_learnings_management_endpoints(client):
+    """Проверяет список, правку, закрепление и удаление знания через /learnings/list и /learnings/item."""
+    model_name = f"manage-model-{uuid.uuid4()}"
+    added = client.post("/learnings", json={
+        "text": "Deploy via docker compose",
+        "model_name": model_name,
+        "agent_name": "admin",
+        "category": "fact",
+    })
+    assert added.status_code == 200
+    learning_id = added.json()["id"]
+
+    listed = client.get(f"/learnings/list/{model_name}")
+    assert listed.status_code == 200
+    assert [item["id"] for item in listed.json()["learnings"]] == [learning_id]
+
+    edited = client.patch(f"/learnings/item/{learning_id}", json={"text": "Deploy via podman compose"})
+    assert edited.status_code == 200
+    assert edited.json()["text"] == "Deploy via podman compose"
+
+    pinned = client.post(f"/learnings/item/{learning_id}/pin")
+    assert pinned.status_code == 200
+    assert pinned.json()["pinned"] is True
+
+    search = client.post("/learnings/search", json={
+        "query": "unrelated question about weather",
+        "model_name": model_name,
+        "include_pinned": True,
+    })
+    assert search.status_code == 200
+    assert learning_id in {item["id"] for item in search.json()["results"]}
+
+    deleted = client.delete(f"/learnings/item/{learning_id}")
+    assert deleted.status_code == 200
+    assert client.delete(f"/learnings/item/{learning_id}").status_code == 404
+    assert client.get(f"/learnings/list/{model_name}").json()["count"] == 0
+
+
 def test_audit_logs_endpoint(client):
     """Проверяет, что /audit/logs возвращает события и поддерживает фильтр workspace."""
     model_name = f"audit-model-{uuid.uuid4()}"
@@ -100,6 +100,7 @@ def mock_memory_store():
         store.facts_collection = MockQdrantCollection()
         store.files_collection = MockQdrantCollection()
         store.audit_collection = MockQdrantCollection()
+        store.episodes_collection = MockQdrantCollection()
         store._metrics_lock = __import__("threading").Lock()
         store._retrieval_metrics = {
             "search_requests_total": 0,
@@ -1171,3 +1172,135 @@ class TestListDeletedFiles:
 
         result = mock_memory_store.list_deleted_files()
         assert result == ["a_file.txt", "m_file.txt", "z_file.txt"]
+
+
+class TestLearningsManagement:
+    """Управление знаниями: список, правка, удаление, закрепление."""
+
+    @staticmethod
+    def _add(store, learning_id, text, category="fact", **extra):
+        meta = {
+            "model_name": "model1",
+            "category": category,
+            "version": 1,
+            "status": LEARNING_STATUS_ACTIVE,
+            "created_at": f"2025-01-0{len(store.learnings_collection.data) + 1}T00:00:00+00:00",
+        }
+        meta.update(extra)
+        store.learnings_collection.add(
+            embeddings=[[0.1] * 384], documents=[text], metadatas=[meta], ids=[learning_id],
+        )
+
+    def test_list_pinned_first_and_only_active(self, mock_memory_store):
+        """Закреплённые знания идут первыми, удалённые не показываются."""
+        self._add(mock_memory_store, "l1", "Старое знание")
+        self._add(mock_memory_store, "l2", "Новое знание")
+        self._add(mock_memory_store, "l3", "Важное правило", pinned="true")
+        self._add(mock_memory_store, "l4", "Удалённое", status=LEARNING_STATUS_DELETED)
+
+        items = mock_memory_store.list_learnings("model1")
+        assert [item["id"] for item in items] == ["l3", "l2", "l1"]
+        assert items[0]["pinned"] is True
+
+        pinned = mock_memory_store.list_learnings("model1", pinned_only=True)
+        assert [item["id"] for item in pinned] == ["l3"]
+
+    def test_update_learning_reencodes_text(self, mock_memory_store):
+        """Правка текста пересчитывает эмбеддинг и сохраняет категорию."""
+        self._add(mock_memory_store, "l1", "Python 3.11")
+        mock_memory_store.encoder.encode = Mock(return_value=[0.5] * 384)
+
+        item = mock_memory_store.update_learning("l1", text="Python 3.12", category="correction")
+        stored = mock_memory_store.learnings_collection.data["l1"]
+        assert item["text"] == "Python 3.12"
+        assert stored["document"] == "Python 3.12"
+        assert stored["embedding"] == [0.5] * 384
+        assert stored["metadata"]["category"] == "correction"
+        assert "edited_at" in stored["metadata"]
+
+        assert mock_memory_store.update_learning("missing", text="x") is None
+
+    def test_delete_learning_soft(self, mock_memory_store):
+        """Удаление одного знания — soft delete, повторное удаление не находит его."""
+        self._add(mock_memory_store, "l1", "Ошибочный факт")
+        assert mock_memory_store.delete_learning("l1") is True
+        assert mock_memory_store.learnings_collection.data["l1"]["metadata"]["status"] == LEARNING_STATUS_DELETED
+        assert mock_memory_store.delete_learning("l1") is False
+
+    def test_pinned_always_included_in_search(self, mock_memory_store):
+        """С include_pinned закреплённое знание возвращается даже без семантического совпадения."""
+        self._add(mock_memory_store, "l1", "Отвечай кратко")
+        assert mock_memory_store.pin_learning("l1")["pinned"] is True
+        assert mock_memory_store.learnings_collection.data["l1"]["metadata"]["priority"] == "pinned"
+
+        results = mock_memory_store.search_learnings("как дела", "model1", include_pinned=True)
+        assert [r["id"] for r in results] == ["l1"]
+        assert results[0]["source"] == "pinned"
+        assert mock_memory_store.search_learnings("как дела", "model1") == []
+
+        unpinned = mock_memory_store.pin_learning("l1", pinned=False)
+        assert unpinned["pinned"] is False
+        assert unpinned["metadata"]["priority"] == "normal"
+
+
+class TestEpisodes:
+    """Эпизодическая память: резюме прошлых диалогов."""
+
+    def test_add_episode_metadata(self, mock_memory_store):
+        """Эпизод сохраняется с агентом, моделью, workspace и временем создания."""
+        episode_id = mock_memory_store.add_episode(
+            summary="Задача: настроить nginx. Результат: добавлен proxy_pass",
+            agent_name="admin",
+            model_name="qwen2.5:7b",
+            metadata={"tools": "execute"},
+        )
+        stored = mock_memory_store.episodes_collection.data[episode_id]
+        assert stored["metadata"]["agent_name"] == "admin"
+        assert stored["metadata"]["workspace_id"] == "default"
+        assert stored["metadata"]["tools"] == "execute"
+        assert "created_at" in stored["metadata"]
+        assert mock_memory_store.add_episode("  ", agent_name="admin") == ""
+
+    def test_search_episodes_min_score(self, mock_memory_store):
+        """Эпизоды ниже min_score не возвращаются, остальные отсортированы по близости."""
+        mock_memory_store.add_episode("Задача: nginx", agent_name="admin")
+        mock_memory_store.episodes_collection.query = Mock(return_value={
+            "documents": [["Задача: nginx", "Задача: погода"]],
+            "distances": [[0.1, 0.7]],
+            "metadatas": [[{"agent_name": "admin"}, {"agent_name": "admin"}]],
+            "ids": [["e1", "e2"]],
+        })
+        results = mock_memory_store.search_episodes("настрой nginx", agent_name="admin", min_score=0.5)
+        assert [r["id"] for r in results] == ["e1"]
+        assert results[0]["source"] == "episodes"
+        assert abs(results[0]["score"] - 0.9) < 1e-9
+
+
+class TestDedup:
+    """Дедупликация знаний и фрагментов файлов перед вставкой."""
+
+    def test_repeated_learning_reinforces_existing(self, mock_memory_store):
+        """Повтор знания не создаёт новую версию, а увеличивает duplicate_count и frequency."""
+        first_id = mock_memory_store.add_learning(
+            text="Пользователь предпочитает ответы на русском",
+            model_name="test-model",
+            agent_name="admin",
+            category="preference",
+        )
+        second_id = mock_memory_store.add_learning(
+            text="пользователь предпочитает ответы на русском.",
+            model_name="test-model",
+            agent_name="admin",
+            category="preference",
+        )
+        assert second_id == first_id
+        assert len(mock_memory_store.learnings_collection.data) == 1
+        meta = mock_memory_store.learnings_collection.data[first_id]["metadata"]
+        assert meta["duplicate_count"] == 1
+        assert meta["version"] == 1
+
+    def test_same_learning_for_other_model_not_deduplicated(self, mock_memory_store):
+        """Область дедупликации знаний — модель: у другой модели своя запись."""
+        first_id = mock_memory_store.add_learning(text="Ответы на русском", model_name="model-a", agent_name="admin")
+        second_id = mock_memory_store.add_learning(text="Ответы на русском", model_name="model-b", agent_name="admin")
+        assert first_id != second_id
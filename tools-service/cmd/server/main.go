@@ -3,11 +3,17 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log/slog"
+	"net"
 	"net/http"
 	"os"
+	"os/exec"
 	"os/signal"
+	"path/filepath"
+	"sort"
 	"strings"
 	"sync/atomic"
 	"syscall"
@@ -15,13 +21,32 @@ import (
 
 	"github.com/neo-2022/openclaw-memory/tools-service/internal/apierror"
 	"github.com/neo-2022/openclaw-memory/tools-service/internal/auth"
+	"github.com/neo-2022/openclaw-memory/tools-service/internal/calendar"
+	"github.com/neo-2022/openclaw-memory/tools-service/internal/coderun"
+	"github.com/neo-2022/openclaw-memory/tools-service/internal/config"
+	"github.com/neo-2022/openclaw-memory/tools-service/internal/diskusage"
 	"github.com/neo-2022/openclaw-memory/tools-service/internal/execmode"
 	"github.com/neo-2022/openclaw-memory/tools-service/internal/executor"
+	"github.com/neo-2022/openclaw-memory/tools-service/internal/health"
+	"github.com/neo-2022/openclaw-memory/tools-service/internal/lint"
 	"github.com/neo-2022/openclaw-memory/tools-service/internal/logger"
+	"github.com/neo-2022/openclaw-memory/tools-service/internal/mailbox"
+	"github.com/neo-2022/openclaw-memory/tools-service/internal/netdiag"
+	"github.com/neo-2022/openclaw-memory/tools-service/internal/promquery"
+	"github.com/neo-2022/openclaw-memory/tools-service/internal/rpc"
+	"github.com/neo-2022/openclaw-memory/tools-service/internal/sandbox"
+	"github.com/neo-2022/openclaw-memory/tools-service/internal/secaudit"
+	"github.com/neo-2022/openclaw-memory/tools-service/internal/toolspb"
+	"github.com/neo-2022/openclaw-memory/tools-service/internal/tracing"
+	"github.com/neo-2022/openclaw-memory/tools-service/internal/workspace"
+	"google.golang.org/grpc"
+	grpchealth "google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 )
 
 type ExecuteRequest struct {
-	Command string `json:"command"`
+	Command string            `json:"command"`
+	Env     map[string]string `json:"env,omitempty"` // Переменные окружения команды; значения не логируются
 }
 
 type ExecuteResponse struct {
@@ -81,6 +106,33 @@ func healthHandler(w http.ResponseWriter, r *http.Request) {
 	w.Write([]byte(`{"status":"ok","service":"tools-service"}`))
 }
 
+// readinessChecks — проверки /ready: запись во временный каталог (команды и загрузки
+// используют его) и доступность Docker, если включена песочница (SANDBOX_ENABLED).
+func readinessChecks() []health.Check {
+	checks := []health.Check{{
+		Name:     "tempdir",
+		Required: true,
+		Run: func(ctx context.Context) error {
+			f, err := os.CreateTemp("", "tools-ready-*")
+			if err != nil {
+				return err
+			}
+			f.Close()
+			return os.Remove(f.Name())
+		},
+	}}
+	if sandbox.DefaultConfig().Enabled {
+		checks = append(checks, health.Check{
+			Name:     "docker",
+			Required: true,
+			Run: func(ctx context.Context) error {
+				return exec.CommandContext(ctx, "docker", "info").Run()
+			},
+		})
+	}
+	return checks
+}
+
 func executeHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		apierror.MethodNotAllowed(w, r.Header.Get("X-Request-ID"))
@@ -96,25 +148,23 @@ func executeHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	role := auth.RoleFromContext(r.Context())
-	subCmds, err := executor.CheckCommand(req.Command)
-	if err != nil {
-		logger.С(ctx).Warn("Команда заблокирована", slog.String("команда", req.Command), slog.String("ошибка", err.Error()))
-		apierror.Forbidden(w, cid, err.Error(), "Команда не прошла проверку безопасности")
+	if hint, err := auth.CheckCommand(role, req.Command); err != nil {
+		logger.С(ctx).Warn("Команда отклонена", slog.String("команда", req.Command), slog.String("роль", string(role)), slog.String("ошибка", err.Error()))
+		apierror.Forbidden(w, cid, err.Error(), hint)
 		return
 	}
-	for _, sub := range subCmds {
-		if !auth.RoleAllowedCommand(role, sub) {
-			logger.С(ctx).Warn("Команда запрещена для роли",
-				slog.String("роль", string(role)),
-				slog.String("команда", sub),
-			)
-			apierror.Forbidden(w, cid, "команда "+sub+" недоступна для роли "+string(role), "Требуется роль с более высоким уровнем доступа")
-			return
-		}
-	}
 
-	logger.С(ctx).Info("Выполнение команды", slog.String("команда", req.Command), slog.String("роль", string(role)))
-	result := executor.ExecuteCommand(req.Command)
+	if err := executor.ValidateEnv(req.Env); err != nil {
+		apierror.BadRequest(w, cid, err.Error(), "Имена переменных — латиница, цифры и _; PATH, LD_PRELOAD и подобные задавать нельзя")
+		return
+	}
+	// Команда выполняется в каталоге рабочего пространства, если оно задано
+	dir, ok := workspaceRoot(w, r, cid)
+	if !ok {
+		return
+	}
+	logger.С(ctx).Info("Выполнение команды", slog.String("команда", req.Command), slog.String("роль", string(role)), slog.String("каталог", dir), slog.Any("env", envNames(req.Env)))
+	result := executor.ExecuteCommandIn(dir, req.Env, req.Command, nil)
 	logger.С(ctx).Info("Результат выполнения", slog.Int("код", result.ReturnCode), slog.Int("stdout_байт", len(result.Stdout)), slog.Int("stderr_байт", len(result.Stderr)))
 	resp := ExecuteResponse{
 		Stdout:     result.Stdout,
@@ -126,6 +176,866 @@ func executeHandler(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(resp)
 }
 
+// envNames — имена переменных окружения запроса для лога (значения — секреты).
+func envNames(env map[string]string) []string {
+	names := make([]string, 0, len(env))
+	for k := range env {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// codeRunner — интерпретатор кода для /run-code.
+var codeRunner = coderun.NewRunner(coderun.DefaultConfig())
+
+// runCodeHandler — POST /run-code: выполнение фрагмента Python/Go/JavaScript
+// в одноразовом окружении. В отличие от /execute белый список команд не
+// применяется: изоляцию обеспечивают контейнер или ограниченный процесс.
+func runCodeHandler(w http.ResponseWriter, r *http.Request) {
+	cid := r.Header.Get("X-Request-ID")
+	if r.Method != http.MethodPost {
+		apierror.MethodNotAllowed(w, cid)
+		return
+	}
+	ctx := logger.WithCorrelationID(r.Context(), cid)
+	var req coderun.Request
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		logger.С(ctx).Error("Ошибка парсинга JSON", slog.String("обработчик", "run-code"), slog.String("ошибка", err.Error()))
+		apierror.BadRequest(w, cid, "Невалидный JSON", "Проверьте формат тела запроса")
+		return
+	}
+	if _, ok := coderun.LookupLanguage(req.Language); !ok {
+		apierror.BadRequest(w, cid, "язык не поддерживается: "+req.Language, "Доступны: "+strings.Join(coderun.Languages(), ", "))
+		return
+	}
+	if strings.TrimSpace(req.Code) == "" {
+		apierror.BadRequest(w, cid, "код не задан", "Передайте исходный код в поле code")
+		return
+	}
+	if err := executor.ValidateEnv(req.Env); err != nil {
+		apierror.BadRequest(w, cid, err.Error(), "Имена переменных — латиница, цифры и _; PATH, LD_PRELOAD и подобные задавать нельзя")
+		return
+	}
+
+	logger.С(ctx).Info("Запуск кода", slog.String("язык", req.Language), slog.Int("байт", len(req.Code)), slog.Any("env", envNames(req.Env)))
+	result, err := codeRunner.Run(ctx, req)
+	if errors.Is(err, coderun.ErrNoSandbox) {
+		logger.С(ctx).Warn("Запуск кода отклонён", slog.String("ошибка", err.Error()))
+		apierror.ServiceUnavailable(w, cid, err.Error(), "Запустите docker, задайте CODE_RUN_BACKEND=process или разрешите переход CODE_RUN_ALLOW_PROCESS_FALLBACK=true")
+		return
+	}
+	if err != nil {
+		logger.С(ctx).Error("Ошибка запуска кода", slog.String("язык", req.Language), slog.String("ошибка", err.Error()))
+		apierror.InternalError(w, cid, err.Error(), "Проверьте, что интерпретатор установлен или доступен Docker (CODE_RUN_BACKEND)")
+		return
+	}
+	logger.С(ctx).Info("Код выполнен",
+		slog.String("run_id", result.RunID),
+		slog.String("backend", result.Backend),
+		slog.Int("код", result.ExitCode),
+		slog.Bool("таймаут", result.TimedOut),
+		slog.Int("артефактов", len(result.Artifacts)),
+	)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+// runCodeArtifactHandler — GET /run-code/artifact?run_id=...&name=...:
+// скачивание файла, созданного кодом.
+func runCodeArtifactHandler(w http.ResponseWriter, r *http.Request) {
+	cid := r.Header.Get("X-Request-ID")
+	if r.Method != http.MethodGet {
+		apierror.MethodNotAllowed(w, cid)
+		return
+	}
+	runID := r.URL.Query().Get("run_id")
+	name := r.URL.Query().Get("name")
+	path, err := codeRunner.ArtifactPath(runID, name)
+	if err != nil {
+		apierror.NotFound(w, cid, "артефакт не найден: "+err.Error())
+		return
+	}
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filepath.Base(path)))
+	http.ServeFile(w, r, path)
+}
+
+// lintRunner — линтеры с настройками из config; пересоздаются при перезагрузке конфигурации.
+var lintRunner atomic.Pointer[lint.Runner]
+
+// newLintRunner — линтеры с настройками конфигурации c.
+func newLintRunner(c *config.Config) *lint.Runner {
+	return lint.NewRunner(lint.NewConfig(c.LinterList(), c.LintTimeout))
+}
+
+// LintRequest — тело POST /lint: файлы и/или unified diff.
+type LintRequest struct {
+	Path    string   `json:"path"`
+	Paths   []string `json:"paths"`
+	Diff    string   `json:"diff"`
+	Root    string   `json:"root"`
+	Linters []string `json:"linters"`
+}
+
+// lintHandler — POST /lint: запуск линтеров по файлам или diff, замечания
+// в едином формате (файл, строка, правило, сообщение).
+func lintHandler(w http.ResponseWriter, r *http.Request) {
+	cid := r.Header.Get("X-Request-ID")
+	if r.Method != http.MethodPost {
+		apierror.MethodNotAllowed(w, cid)
+		return
+	}
+	ctx := logger.WithCorrelationID(r.Context(), cid)
+	var req LintRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		logger.С(ctx).Error("Ошибка парсинга JSON", slog.String("обработчик", "lint"), slog.String("ошибка", err.Error()))
+		apierror.BadRequest(w, cid, "Невалидный JSON", "Проверьте формат тела запроса")
+		return
+	}
+	if req.Path != "" {
+		req.Paths = append(req.Paths, req.Path)
+	}
+	paths := make([]string, 0, len(req.Paths))
+	for _, p := range req.Paths {
+		clean, err := executor.ValidatePath(p)
+		if err != nil {
+			apierror.Forbidden(w, cid, err.Error(), "Путь запрещён политикой безопасности tools-service")
+			return
+		}
+		paths = append(paths, clean)
+	}
+	root := req.Root
+	if root != "" {
+		clean, err := executor.ValidatePath(root)
+		if err != nil {
+			apierror.Forbidden(w, cid, err.Error(), "Путь запрещён политикой безопасности tools-service")
+			return
+		}
+		root = clean
+	}
+
+	report, err := lintRunner.Load().Run(ctx, lint.Request{Paths: paths, Diff: req.Diff, Root: root, Linters: req.Linters})
+	if errors.Is(err, lint.ErrPathForbidden) {
+		apierror.Forbidden(w, cid, err.Error(), "Путь запрещён политикой безопасности tools-service")
+		return
+	}
+	if err != nil {
+		apierror.BadRequest(w, cid, err.Error(), "Передайте path/paths или diff (для относительных путей — root)")
+		return
+	}
+	logger.С(ctx).Info("Проверка линтерами",
+		slog.Int("файлов", report.Files),
+		slog.String("линтеры", strings.Join(report.Ran, ",")),
+		slog.Int("замечаний", len(report.Findings)),
+	)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
+
+// FormatRequest — тело POST /format.
+type FormatRequest struct {
+	Path  string `json:"path"`
+	Write bool   `json:"write"` // Записать результат в файл
+}
+
+// formatHandler — POST /format: форматирование файла gofmt/eslint/black.
+func formatHandler(w http.ResponseWriter, r *http.Request) {
+	cid := r.Header.Get("X-Request-ID")
+	if r.Method != http.MethodPost {
+		apierror.MethodNotAllowed(w, cid)
+		return
+	}
+	ctx := logger.WithCorrelationID(r.Context(), cid)
+	var req FormatRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		logger.С(ctx).Error("Ошибка парсинга JSON", slog.String("обработчик", "format"), slog.String("ошибка", err.Error()))
+		apierror.BadRequest(w, cid, "Невалидный JSON", "Проверьте формат тела запроса")
+		return
+	}
+	path, err := executor.ValidatePath(req.Path)
+	if err != nil {
+		apierror.Forbidden(w, cid, err.Error(), "Путь запрещён политикой безопасности tools-service")
+		return
+	}
+	tool, out, changed, err := lintRunner.Load().Format(ctx, path, req.Write)
+	switch {
+	case errors.Is(err, lint.ErrNoFormatter):
+		apierror.BadRequest(w, cid, err.Error(), "Поддерживаются .go (gofmt), .js/.ts (eslint), .py (black); проверьте LINTERS")
+		return
+	case errors.Is(err, os.ErrNotExist):
+		apierror.NotFound(w, cid, "файл не найден: "+req.Path)
+		return
+	case err != nil:
+		logger.С(ctx).Error("Ошибка форматирования", slog.String("путь", path), slog.String("ошибка", err.Error()))
+		apierror.BadRequest(w, cid, err.Error(), "Исправьте синтаксические ошибки и повторите")
+		return
+	}
+	logger.С(ctx).Info("Форматирование файла", slog.String("путь", path), slog.String("форматтер", tool), slog.Bool("изменён", changed), slog.Bool("записан", changed && req.Write))
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"path":    path,
+		"tool":    tool,
+		"changed": changed,
+		"written": changed && req.Write,
+		"content": string(out),
+	})
+}
+
+// DiskUsageRequest — тело POST /diskusage.
+type DiskUsageRequest struct {
+	Path       string `json:"path"`        // Каталог (по умолчанию — домашний)
+	Depth      int    `json:"depth"`       // Глубина сводки по каталогам (по умолчанию 2, не больше 6)
+	Top        int    `json:"top"`         // Сколько каталогов и файлов вернуть (по умолчанию 15)
+	TimeoutSec int    `json:"timeout_sec"` // Предельное время обхода (по умолчанию 20, не больше 120)
+	MinSize    int64  `json:"min_size"`    // Не показывать файлы меньше, байт
+}
+
+// diskUsageHandler — POST /diskusage: самые большие каталоги и файлы под
+// путём. Запрещённые политикой пути (/proc, /sys…) и другие файловые системы
+// пропускаются; при ограничении времени — частичный результат с truncated.
+func diskUsageHandler(w http.ResponseWriter, r *http.Request) {
+	cid := r.Header.Get("X-Request-ID")
+	if r.Method != http.MethodPost {
+		apierror.MethodNotAllowed(w, cid)
+		return
+	}
+	ctx := logger.WithCorrelationID(r.Context(), cid)
+	var req DiskUsageRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		logger.С(ctx).Error("Ошибка парсинга JSON", slog.String("обработчик", "diskusage"), slog.String("ошибка", err.Error()))
+		apierror.BadRequest(w, cid, "Невалидный JSON", "Проверьте формат тела запроса")
+		return
+	}
+	if req.Path == "" {
+		req.Path = "~"
+	}
+	path, err := executor.ValidatePath(req.Path)
+	if err != nil {
+		apierror.Forbidden(w, cid, err.Error(), "Путь запрещён политикой безопасности tools-service")
+		return
+	}
+	rep, err := diskusage.Scan(ctx, path, diskusage.Options{
+		Depth:   req.Depth,
+		Top:     req.Top,
+		Timeout: time.Duration(req.TimeoutSec) * time.Second,
+		MinSize: req.MinSize,
+		Skip: func(p string) bool {
+			_, err := executor.ValidatePath(p)
+			return err != nil
+		},
+	})
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		apierror.NotFound(w, cid, "каталог не найден: "+req.Path)
+		return
+	case errors.Is(err, diskusage.ErrNotDir):
+		apierror.BadRequest(w, cid, err.Error(), "Укажите каталог; размер файла показывает list")
+		return
+	case err != nil:
+		logger.С(ctx).Error("Ошибка анализа диска", slog.String("путь", path), slog.String("ошибка", err.Error()))
+		apierror.InternalError(w, cid, err.Error(), "Проверьте путь и права доступа")
+		return
+	}
+	logger.С(ctx).Info("Анализ места на диске",
+		slog.String("путь", path),
+		slog.String("размер", rep.Human),
+		slog.Int("файлов", rep.Files),
+		slog.Bool("неполный", rep.Truncated),
+		slog.Int64("мс", rep.DurationMs),
+	)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(rep)
+}
+
+var promClient = promquery.New(promquery.DefaultConfig())
+
+// PrometheusQueryRequest — тело POST /prometheus/query.
+type PrometheusQueryRequest struct {
+	Query    string `json:"query"`    // PromQL или пресет: cpu, memory, disk, load, network
+	Instance string `json:"instance"` // Фильтр instance для пресетов (подстрока)
+	Range    string `json:"range"`    // Длительность диапазона (24h, 7d); пусто — мгновенный запрос
+	Step     string `json:"step"`     // Шаг диапазонного запроса (по умолчанию ~240 точек)
+	Time     string `json:"time"`     // Конец диапазона / момент мгновенного запроса (RFC 3339)
+}
+
+// prometheusQueryHandler — POST /prometheus/query: мгновенный или диапазонный
+// запрос PromQL со сводкой рядов (min/max/avg, тренд, усреднённые точки).
+func prometheusQueryHandler(w http.ResponseWriter, r *http.Request) {
+	cid := r.Header.Get("X-Request-ID")
+	if r.Method != http.MethodPost {
+		apierror.MethodNotAllowed(w, cid)
+		return
+	}
+	ctx := logger.WithCorrelationID(r.Context(), cid)
+	var req PrometheusQueryRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		logger.С(ctx).Error("Ошибка парсинга JSON", slog.String("обработчик", "prometheus_query"), slog.String("ошибка", err.Error()))
+		apierror.BadRequest(w, cid, "Невалидный JSON", "Проверьте формат тела запроса")
+		return
+	}
+	if strings.TrimSpace(req.Query) == "" {
+		apierror.BadRequest(w, cid, "не указан query", "Передайте PromQL или пресет: cpu, memory, disk, load, network")
+		return
+	}
+	var at time.Time
+	if req.Time != "" {
+		t, err := time.Parse(time.RFC3339, req.Time)
+		if err != nil {
+			apierror.BadRequest(w, cid, "time: ожидается RFC 3339", "Например 2026-01-31T10:00:00Z")
+			return
+		}
+		at = t
+	}
+	query := promquery.Expand(req.Query, req.Instance)
+
+	var res promquery.Result
+	var err error
+	if req.Range != "" {
+		var span, step time.Duration
+		if span, err = promquery.ParseDuration(req.Range); err != nil {
+			apierror.BadRequest(w, cid, err.Error(), "Например range=24h или range=7d")
+			return
+		}
+		if req.Step != "" {
+			if step, err = promquery.ParseDuration(req.Step); err != nil {
+				apierror.BadRequest(w, cid, err.Error(), "Например step=5m")
+				return
+			}
+		}
+		if at.IsZero() {
+			at = time.Now()
+		}
+		res, err = promClient.Range(ctx, query, at.Add(-span), at, step)
+	} else {
+		res, err = promClient.Instant(ctx, query, at)
+	}
+	switch {
+	case errors.Is(err, promquery.ErrNotConfigured):
+		apierror.ServiceUnavailable(w, cid, err.Error(), "Укажите адрес Prometheus в PROMETHEUS_URL и перезапустите tools-service")
+		return
+	case errors.Is(err, promquery.ErrBadQuery):
+		apierror.BadRequest(w, cid, err.Error(), "Проверьте синтаксис PromQL или используйте пресет: cpu, memory, disk, load, network")
+		return
+	case err != nil:
+		logger.С(ctx).Error("Ошибка запроса к Prometheus", slog.String("запрос", query), slog.String("ошибка", err.Error()))
+		apierror.ServiceUnavailable(w, cid, err.Error(), "Проверьте доступность Prometheus")
+		return
+	}
+	logger.С(ctx).Info("Запрос к Prometheus", slog.String("запрос", query), slog.String("диапазон", req.Range), slog.Int("рядов", res.Total))
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(res)
+}
+
+var netDiag = netdiag.New(netdiag.DefaultConfig())
+
+// NetPingRequest — тело POST /net/ping.
+type NetPingRequest struct {
+	Host  string `json:"host"`
+	Count int    `json:"count"` // Запросов (по умолчанию 4, не больше 20)
+	Port  int    `json:"port"`  // TCP-порт: замер подключения вместо ICMP
+}
+
+// NetTracerouteRequest — тело POST /net/traceroute.
+type NetTracerouteRequest struct {
+	Host    string `json:"host"`
+	MaxHops int    `json:"max_hops"` // По умолчанию 20, не больше 40
+}
+
+// NetDNSRequest — тело POST /net/dns.
+type NetDNSRequest struct {
+	Name   string   `json:"name"`
+	Types  []string `json:"types"`  // A, AAAA, MX, TXT, CNAME, NS (по умолчанию A, AAAA, MX, TXT)
+	Server string   `json:"server"` // DNS-сервер IP[:порт]; пусто — системный
+}
+
+// NetInterfacesRequest — тело POST /net/interfaces.
+type NetInterfacesRequest struct {
+	SampleSec int `json:"sample_sec"` // Замер скорости за N секунд (0 — только счётчики, не больше 10)
+}
+
+// decodeNet — разбор тела запроса /net/*; пустое тело допустимо.
+func decodeNet(w http.ResponseWriter, r *http.Request, name string, v interface{}) bool {
+	cid := r.Header.Get("X-Request-ID")
+	if r.Method != http.MethodPost {
+		apierror.MethodNotAllowed(w, cid)
+		return false
+	}
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil && !errors.Is(err, io.EOF) {
+		logger.С(logger.WithCorrelationID(r.Context(), cid)).Error("Ошибка парсинга JSON", slog.String("обработчик", name), slog.String("ошибка", err.Error()))
+		apierror.BadRequest(w, cid, "Невалидный JSON", "Проверьте формат тела запроса")
+		return false
+	}
+	return true
+}
+
+// writeNet — ответ /net/* или ошибка в формате apierror.
+func writeNet(w http.ResponseWriter, r *http.Request, name string, res interface{}, err error) {
+	cid := r.Header.Get("X-Request-ID")
+	ctx := logger.WithCorrelationID(r.Context(), cid)
+	switch {
+	case errors.Is(err, netdiag.ErrBadHost):
+		apierror.BadRequest(w, cid, err.Error(), "Передайте имя хоста (example.com) или IP-адрес")
+		return
+	case errors.Is(err, netdiag.ErrNoTool):
+		apierror.ServiceUnavailable(w, cid, err.Error(), "Установите пакет traceroute (или iputils-tracepath) на хосте tools-service")
+		return
+	case err != nil:
+		logger.С(ctx).Warn("Ошибка сетевой диагностики", slog.String("операция", name), slog.String("ошибка", err.Error()))
+		apierror.BadRequest(w, cid, err.Error(), "Проверьте имя хоста и доступность сети")
+		return
+	}
+	logger.С(ctx).Info("Сетевая диагностика", slog.String("операция", name))
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(res)
+}
+
+// netPingHandler — POST /net/ping: потери и min/avg/max времени ответа.
+func netPingHandler(w http.ResponseWriter, r *http.Request) {
+	var req NetPingRequest
+	if !decodeNet(w, r, "net_ping", &req) {
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), netDiag.Timeout())
+	defer cancel()
+	res, err := netDiag.Ping(ctx, req.Host, req.Count, req.Port)
+	writeNet(w, r, "ping", res, err)
+}
+
+// netTracerouteHandler — POST /net/traceroute: узлы маршрута до хоста.
+func netTracerouteHandler(w http.ResponseWriter, r *http.Request) {
+	var req NetTracerouteRequest
+	if !decodeNet(w, r, "net_traceroute", &req) {
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), netDiag.Timeout())
+	defer cancel()
+	res, err := netDiag.Traceroute(ctx, req.Host, req.MaxHops)
+	writeNet(w, r, "traceroute", res, err)
+}
+
+// netDNSHandler — POST /net/dns: записи имени по типам.
+func netDNSHandler(w http.ResponseWriter, r *http.Request) {
+	var req NetDNSRequest
+	if !decodeNet(w, r, "net_dns", &req) {
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), netDiag.Timeout())
+	defer cancel()
+	res, err := netDiag.Lookup(ctx, req.Name, req.Types, req.Server)
+	writeNet(w, r, "dns", res, err)
+}
+
+// netPublicIPHandler — POST /net/publicip: внешний IP-адрес.
+func netPublicIPHandler(w http.ResponseWriter, r *http.Request) {
+	var req struct{}
+	if !decodeNet(w, r, "net_public_ip", &req) {
+		return
+	}
+	res, err := netDiag.PublicIP(r.Context())
+	if err != nil {
+		apierror.ServiceUnavailable(w, r.Header.Get("X-Request-ID"), err.Error(), "Проверьте доступ в интернет или задайте NET_PUBLIC_IP_URL")
+		return
+	}
+	writeNet(w, r, "publicip", res, nil)
+}
+
+// netInterfacesHandler — POST /net/interfaces: адреса, счётчики и скорость интерфейсов.
+func netInterfacesHandler(w http.ResponseWriter, r *http.Request) {
+	var req NetInterfacesRequest
+	if !decodeNet(w, r, "net_interfaces", &req) {
+		return
+	}
+	list, err := netDiag.Interfaces(r.Context(), time.Duration(req.SampleSec)*time.Second)
+	writeNet(w, r, "interfaces", map[string]interface{}{"interfaces": list}, err)
+}
+
+var securityAuditor = secaudit.New()
+
+// SecurityWritableRequest — тело POST /security/writable.
+type SecurityWritableRequest struct {
+	Paths []string `json:"paths"` // Каталоги поиска (по умолчанию /etc, /usr/local, /usr/bin, /opt, /home и др.)
+}
+
+// securityHandler — POST /security/{ports,sudo,ssh,updates,writable}: одна
+// проверка безопасности хоста — собранные данные и замечания с уровнем
+// важности. Роль admin: ответ раскрывает учётные записи и слабые места.
+func securityHandler(w http.ResponseWriter, r *http.Request) {
+	cid := r.Header.Get("X-Request-ID")
+	if r.Method != http.MethodPost {
+		apierror.MethodNotAllowed(w, cid)
+		return
+	}
+	ctx := logger.WithCorrelationID(r.Context(), cid)
+	check := strings.TrimPrefix(r.URL.Path, "/security/")
+	var res interface{}
+	var err error
+	switch check {
+	case "ports":
+		res, err = securityAuditor.Ports()
+	case "sudo":
+		res, err = securityAuditor.Sudo()
+	case "ssh":
+		res, err = securityAuditor.SSH()
+	case "updates":
+		tctx, cancel := context.WithTimeout(ctx, 3*time.Minute)
+		defer cancel()
+		res, err = securityAuditor.Updates(tctx)
+	case "writable":
+		var req SecurityWritableRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+			apierror.BadRequest(w, cid, "Невалидный JSON", "Проверьте формат тела запроса")
+			return
+		}
+		for i, p := range req.Paths {
+			if !filepath.IsAbs(p) || strings.Contains(p, "..") {
+				apierror.BadRequest(w, cid, "paths: ожидаются абсолютные пути без ..", "Например [\"/etc\", \"/opt\"]")
+				return
+			}
+			req.Paths[i] = filepath.Clean(p)
+		}
+		res, err = securityAuditor.WorldWritable(ctx, req.Paths)
+	default:
+		apierror.NotFound(w, cid, "Неизвестная проверка: "+check)
+		return
+	}
+	if err != nil {
+		logger.С(ctx).Warn("Проверка безопасности не выполнена", slog.String("проверка", check), slog.String("ошибка", err.Error()))
+		apierror.ServiceUnavailable(w, cid, err.Error(), "Проверка недоступна на этом хосте")
+		return
+	}
+	logger.С(ctx).Info("Проверка безопасности", slog.String("проверка", check))
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(res)
+}
+
+var calendarClient = calendar.New(calendar.DefaultConfig())
+
+// CalendarListRequest — тело POST /calendar/list.
+type CalendarListRequest struct {
+	From string `json:"from"` // По умолчанию — сейчас
+	To   string `json:"to"`   // По умолчанию — from + days
+	Days int    `json:"days"` // Длина интервала в днях (по умолчанию 7)
+}
+
+// CalendarEventRequest — тело POST /calendar/create и /calendar/update.
+// Для update пустые поля не меняются.
+type CalendarEventRequest struct {
+	UID         string  `json:"uid"`
+	Summary     *string `json:"summary"`
+	Description *string `json:"description"`
+	Location    *string `json:"location"`
+	Start       string  `json:"start"`
+	End         string  `json:"end"`
+	Duration    int     `json:"duration_minutes"`
+	Reminder    *int    `json:"reminder_minutes"`
+}
+
+// calendarError — ответ на ошибку CalDAV.
+func calendarError(w http.ResponseWriter, ctx context.Context, cid, op string, err error) {
+	switch {
+	case errors.Is(err, calendar.ErrNotConfigured):
+		apierror.ServiceUnavailable(w, cid, err.Error(), "Настройте CALDAV_URL в .env и перезапустите tools-service")
+	case errors.Is(err, calendar.ErrNotFound):
+		apierror.NotFound(w, cid, err.Error())
+	case errors.Is(err, calendar.ErrConflict):
+		apierror.Write(w, http.StatusConflict, apierror.Response{Code: apierror.CodeForStatus(http.StatusConflict), Message: err.Error(), Hint: "Запросите событие через /calendar/list и повторите", RequestID: cid, Retryable: true})
+	default:
+		logger.С(ctx).Error("Ошибка календаря", slog.String("операция", op), slog.String("ошибка", err.Error()))
+		apierror.BadRequest(w, cid, err.Error(), "Проверьте параметры события и доступность CalDAV-сервера")
+	}
+}
+
+// eventTimes — начало и конец события из запроса (конец — по end или duration_minutes).
+func eventTimes(req CalendarEventRequest, now time.Time) (start, end time.Time, allDay bool, err error) {
+	if req.Start != "" {
+		if start, allDay, err = calendar.ParseTime(req.Start, now); err != nil {
+			return
+		}
+	}
+	switch {
+	case req.End != "":
+		end, _, err = calendar.ParseTime(req.End, now)
+	case req.Duration > 0 && !start.IsZero():
+		end = start.Add(time.Duration(req.Duration) * time.Minute)
+	}
+	return
+}
+
+// calendarListHandler — POST /calendar/list: события за интервал (по умолчанию неделя от текущего момента).
+func calendarListHandler(w http.ResponseWriter, r *http.Request) {
+	cid := r.Header.Get("X-Request-ID")
+	if r.Method != http.MethodPost {
+		apierror.MethodNotAllowed(w, cid)
+		return
+	}
+	ctx := logger.WithCorrelationID(r.Context(), cid)
+	var req CalendarListRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		logger.С(ctx).Error("Ошибка парсинга JSON", slog.String("обработчик", "calendar_list"), slog.String("ошибка", err.Error()))
+		apierror.BadRequest(w, cid, "Невалидный JSON", "Проверьте формат тела запроса")
+		return
+	}
+	now := calendarClient.Now()
+	from, to := now, time.Time{}
+	var err error
+	if req.From != "" {
+		if from, _, err = calendar.ParseTime(req.From, now); err != nil {
+			apierror.BadRequest(w, cid, err.Error(), "Формат: 2026-01-31T10:00, 2026-01-31 или «завтра 10:00»")
+			return
+		}
+	}
+	if req.To != "" {
+		if to, _, err = calendar.ParseTime(req.To, now); err != nil {
+			apierror.BadRequest(w, cid, err.Error(), "Формат: 2026-01-31T10:00, 2026-01-31 или «завтра 10:00»")
+			return
+		}
+	} else {
+		if req.Days <= 0 {
+			req.Days = 7
+		}
+		to = from.AddDate(0, 0, req.Days)
+	}
+	events, err := calendarClient.List(ctx, from, to)
+	if err != nil {
+		calendarError(w, ctx, cid, "list", err)
+		return
+	}
+	logger.С(ctx).Info("Список событий календаря", slog.Int("событий", len(events)))
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"events": events, "from": from, "to": to, "now": now})
+}
+
+// calendarCreateHandler — POST /calendar/create: новое событие с напоминанием.
+func calendarCreateHandler(w http.ResponseWriter, r *http.Request) {
+	cid := r.Header.Get("X-Request-ID")
+	if r.Method != http.MethodPost {
+		apierror.MethodNotAllowed(w, cid)
+		return
+	}
+	ctx := logger.WithCorrelationID(r.Context(), cid)
+	var req CalendarEventRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		logger.С(ctx).Error("Ошибка парсинга JSON", slog.String("обработчик", "calendar_create"), slog.String("ошибка", err.Error()))
+		apierror.BadRequest(w, cid, "Невалидный JSON", "Проверьте формат тела запроса")
+		return
+	}
+	start, end, allDay, err := eventTimes(req, calendarClient.Now())
+	if err != nil {
+		apierror.BadRequest(w, cid, err.Error(), "Формат: 2026-01-31T10:00, 2026-01-31 или «завтра 10:00»")
+		return
+	}
+	ev := calendar.Event{UID: req.UID, Start: start, End: end, AllDay: allDay}
+	if req.Summary != nil {
+		ev.Summary = *req.Summary
+	}
+	if req.Description != nil {
+		ev.Description = *req.Description
+	}
+	if req.Location != nil {
+		ev.Location = *req.Location
+	}
+	if req.Reminder != nil {
+		ev.Reminder = *req.Reminder
+	}
+	ev, err = calendarClient.Create(ctx, ev)
+	if err != nil {
+		calendarError(w, ctx, cid, "create", err)
+		return
+	}
+	logger.С(ctx).Info("Событие создано", slog.String("uid", ev.UID), slog.String("начало", ev.Start.Format(time.RFC3339)))
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(ev)
+}
+
+// calendarUpdateHandler — POST /calendar/update: изменение переданных полей события по uid.
+func calendarUpdateHandler(w http.ResponseWriter, r *http.Request) {
+	cid := r.Header.Get("X-Request-ID")
+	if r.Method != http.MethodPost {
+		apierror.MethodNotAllowed(w, cid)
+		return
+	}
+	ctx := logger.WithCorrelationID(r.Context(), cid)
+	var req CalendarEventRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		logger.С(ctx).Error("Ошибка парсинга JSON", slog.String("обработчик", "calendar_update"), slog.String("ошибка", err.Error()))
+		apierror.BadRequest(w, cid, "Невалидный JSON", "Проверьте формат тела запроса")
+		return
+	}
+	if req.UID == "" {
+		apierror.BadRequest(w, cid, "не указан uid", "Возьмите uid из ответа /calendar/list")
+		return
+	}
+	start, end, allDay, err := eventTimes(req, calendarClient.Now())
+	if err != nil {
+		apierror.BadRequest(w, cid, err.Error(), "Формат: 2026-01-31T10:00, 2026-01-31 или «завтра 10:00»")
+		return
+	}
+	patch := calendar.Patch{Summary: req.Summary, Description: req.Description, Location: req.Location, Reminder: req.Reminder}
+	if !start.IsZero() {
+		patch.Start, patch.AllDay = &start, &allDay
+	}
+	if !end.IsZero() {
+		patch.End = &end
+	}
+	ev, err := calendarClient.Update(ctx, req.UID, patch)
+	if err != nil {
+		calendarError(w, ctx, cid, "update", err)
+		return
+	}
+	logger.С(ctx).Info("Событие изменено", slog.String("uid", ev.UID), slog.String("начало", ev.Start.Format(time.RFC3339)))
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(ev)
+}
+
+var mailClient = mailbox.New(mailbox.DefaultConfig())
+
+// MailListRequest — тело POST /mail/list.
+type MailListRequest struct {
+	Mailbox string `json:"mailbox"`
+	Query   string `json:"query"`
+	From    string `json:"from"`
+	Since   string `json:"since"` // YYYY-MM-DD
+	Unseen  bool   `json:"unseen"`
+	Limit   int    `json:"limit"`
+}
+
+// MailReadRequest — тело POST /mail/read.
+type MailReadRequest struct {
+	Mailbox string `json:"mailbox"`
+	UID     uint32 `json:"uid"`
+}
+
+// mailError — ответ на ошибку почтового сервера.
+func mailError(w http.ResponseWriter, ctx context.Context, cid, op string, err error) {
+	switch {
+	case errors.Is(err, mailbox.ErrIMAPNotConfigured), errors.Is(err, mailbox.ErrSMTPNotConfigured):
+		apierror.ServiceUnavailable(w, cid, err.Error(), "Настройте почту в .env и перезапустите tools-service")
+	case errors.Is(err, mailbox.ErrNotFound):
+		apierror.NotFound(w, cid, err.Error())
+	default:
+		logger.С(ctx).Error("Ошибка почты", slog.String("операция", op), slog.String("ошибка", err.Error()))
+		apierror.BadRequest(w, cid, err.Error(), "Проверьте параметры запроса и доступность почтового сервера")
+	}
+}
+
+// mailListHandler — POST /mail/list: последние письма ящика (поиск по тексту,
+// отправителю, дате, непрочитанным), только заголовки.
+func mailListHandler(w http.ResponseWriter, r *http.Request) {
+	cid := r.Header.Get("X-Request-ID")
+	if r.Method != http.MethodPost {
+		apierror.MethodNotAllowed(w, cid)
+		return
+	}
+	ctx := logger.WithCorrelationID(r.Context(), cid)
+	var req MailListRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		logger.С(ctx).Error("Ошибка парсинга JSON", slog.String("обработчик", "mail_list"), slog.String("ошибка", err.Error()))
+		apierror.BadRequest(w, cid, "Невалидный JSON", "Проверьте формат тела запроса")
+		return
+	}
+	list, total, err := mailClient.List(ctx, mailbox.Query{
+		Mailbox: req.Mailbox, Text: req.Query, From: req.From, Since: req.Since, Unseen: req.Unseen, Limit: req.Limit,
+	})
+	if err != nil {
+		mailError(w, ctx, cid, "list", err)
+		return
+	}
+	logger.С(ctx).Info("Список писем", slog.Int("найдено", total), slog.Int("возвращено", len(list)))
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"messages": list, "total": total})
+}
+
+// mailReadHandler — POST /mail/read: текст письма по UID (без отметки «прочитано»).
+func mailReadHandler(w http.ResponseWriter, r *http.Request) {
+	cid := r.Header.Get("X-Request-ID")
+	if r.Method != http.MethodPost {
+		apierror.MethodNotAllowed(w, cid)
+		return
+	}
+	ctx := logger.WithCorrelationID(r.Context(), cid)
+	var req MailReadRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		logger.С(ctx).Error("Ошибка парсинга JSON", slog.String("обработчик", "mail_read"), slog.String("ошибка", err.Error()))
+		apierror.BadRequest(w, cid, "Невалидный JSON", "Проверьте формат тела запроса")
+		return
+	}
+	if req.UID == 0 {
+		apierror.BadRequest(w, cid, "не указан uid", "Возьмите uid из ответа /mail/list")
+		return
+	}
+	msg, err := mailClient.Read(ctx, req.Mailbox, req.UID)
+	if err != nil {
+		mailError(w, ctx, cid, "read", err)
+		return
+	}
+	logger.С(ctx).Info("Чтение письма", slog.Int("uid", int(req.UID)), slog.Int("символов", len([]rune(msg.Text))))
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(msg)
+}
+
+// mailSendHandler — POST /mail/send: отправка письма по SMTP
+// (адресаты ограничены MAIL_ALLOWED_TO).
+func mailSendHandler(w http.ResponseWriter, r *http.Request) {
+	cid := r.Header.Get("X-Request-ID")
+	if r.Method != http.MethodPost {
+		apierror.MethodNotAllowed(w, cid)
+		return
+	}
+	ctx := logger.WithCorrelationID(r.Context(), cid)
+	var req mailbox.Outgoing
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		logger.С(ctx).Error("Ошибка парсинга JSON", slog.String("обработчик", "mail_send"), slog.String("ошибка", err.Error()))
+		apierror.BadRequest(w, cid, "Невалидный JSON", "Проверьте формат тела запроса")
+		return
+	}
+	if strings.TrimSpace(req.Body) == "" {
+		apierror.BadRequest(w, cid, "пустое письмо", "Передайте текст письма в поле body")
+		return
+	}
+	sent, err := mailClient.Send(ctx, req)
+	if err != nil {
+		if errors.Is(err, mailbox.ErrRecipientNotAllowed) {
+			apierror.Forbidden(w, cid, err.Error(), "Добавьте адрес или домен в MAIL_ALLOWED_TO")
+			return
+		}
+		mailError(w, ctx, cid, "send", err)
+		return
+	}
+	logger.С(ctx).Info("Письмо отправлено", slog.String("получатели", strings.Join(sent.Recipients, ",")), slog.String("message_id", sent.MessageID))
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(sent)
+}
+
+// workspaceRoot — каталог рабочего пространства из X-Workspace-Root; при
+// ошибке пишет ответ 400 и возвращает ok=false.
+func workspaceRoot(w http.ResponseWriter, r *http.Request, cid string) (string, bool) {
+	root, err := workspace.Root(r)
+	if err != nil {
+		logger.С(logger.WithCorrelationID(r.Context(), cid)).Warn("Некорректное рабочее пространство", slog.String("ошибка", err.Error()))
+		apierror.BadRequest(w, cid, err.Error(), "Проверьте каталог рабочего пространства")
+		return "", false
+	}
+	return root, true
+}
+
+// workspacePath — путь файлового инструмента относительно рабочего
+// пространства запроса (без пространства — как есть). Выход за пределы
+// пространства — 403.
+func workspacePath(w http.ResponseWriter, r *http.Request, cid, path string) (string, bool) {
+	root, ok := workspaceRoot(w, r, cid)
+	if !ok {
+		return "", false
+	}
+	resolved, err := workspace.Resolve(root, path, workspace.Confined())
+	if err != nil {
+		logger.С(logger.WithCorrelationID(r.Context(), cid)).Warn("Путь вне рабочего пространства", slog.String("путь", path), slog.String("пространство", root))
+		apierror.Forbidden(w, cid, err.Error(), "Используйте путь внутри рабочего пространства или переключитесь на другое")
+		return "", false
+	}
+	return resolved, true
+}
+
 func readFileHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		apierror.MethodNotAllowed(w, r.Header.Get("X-Request-ID"))
@@ -139,8 +1049,18 @@ func readFileHandler(w http.ResponseWriter, r *http.Request) {
 		apierror.BadRequest(w, cid, "Невалидный JSON", "Проверьте формат тела запроса")
 		return
 	}
+	path, ok := workspacePath(w, r, cid, req.Path)
+	if !ok {
+		return
+	}
+	req.Path = path
 	logger.С(ctx).Info("Чтение файла", slog.String("путь", req.Path))
 	content, err := executor.ReadFile(req.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		// 404 отличает отсутствующий файл от ошибки чтения (agent-service перед записью решает, что сохранить для отката)
+		apierror.NotFound(w, cid, err.Error())
+		return
+	}
 	if err != nil {
 		logger.С(ctx).Error("Ошибка чтения файла", slog.String("путь", req.Path), slog.String("ошибка", err.Error()))
 		apierror.InternalError(w, cid, err.Error(), "Проверьте путь и права доступа")
@@ -164,6 +1084,11 @@ func writeFileHandler(w http.ResponseWriter, r *http.Request) {
 		apierror.BadRequest(w, cid, "Невалидный JSON", "Проверьте формат тела запроса")
 		return
 	}
+	path, ok := workspacePath(w, r, cid, req.Path)
+	if !ok {
+		return
+	}
+	req.Path = path
 	logger.С(ctx).Info("Запись файла", slog.String("путь", req.Path), slog.Int("байт", len(req.Content)))
 	err := executor.WriteFile(req.Path, req.Content)
 	if err != nil {
@@ -176,6 +1101,46 @@ func writeFileHandler(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
 }
 
+// workspaceInitHandler — подготовка каталога нового рабочего пространства
+// (POST /workspace/init): проверка пути и прав на запись, по флагам —
+// создание каталога, git init и манифест. Ошибки — с кодом и подсказкой
+// (PATH_NOT_FOUND, PATH_NOT_WRITABLE, GIT_UNAVAILABLE...), их показывает UI.
+func workspaceInitHandler(w http.ResponseWriter, r *http.Request) {
+	cid := r.Header.Get("X-Request-ID")
+	if r.Method != http.MethodPost {
+		apierror.MethodNotAllowed(w, cid)
+		return
+	}
+	ctx := logger.WithCorrelationID(r.Context(), cid)
+	var req executor.WorkspaceOptions
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		logger.С(ctx).Error("Ошибка парсинга JSON", slog.String("обработчик", "workspace/init"), slog.String("ошибка", err.Error()))
+		apierror.BadRequest(w, cid, "Невалидный JSON", "Проверьте формат тела запроса")
+		return
+	}
+	res, err := executor.InitWorkspace(ctx, req)
+	if err != nil {
+		logger.С(ctx).Warn("Каталог рабочего пространства не подготовлен", slog.String("путь", req.Path), slog.String("ошибка", err.Error()))
+		var wsErr *executor.WorkspaceError
+		if !errors.As(err, &wsErr) {
+			apierror.InternalError(w, cid, err.Error(), "")
+			return
+		}
+		status := http.StatusUnprocessableEntity
+		if wsErr.Code == "PATH_FORBIDDEN" {
+			status = http.StatusForbidden
+		}
+		apierror.Write(w, status, apierror.Response{Code: wsErr.Code, Message: wsErr.Message, Hint: wsErr.Hint, RequestID: cid})
+		return
+	}
+	logger.С(ctx).Info("Каталог рабочего пространства готов",
+		slog.String("путь", res.Path),
+		slog.Bool("создан", res.Created),
+		slog.Bool("git_init", res.GitInitialized))
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(res)
+}
+
 func listDirHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		apierror.MethodNotAllowed(w, r.Header.Get("X-Request-ID"))
@@ -189,6 +1154,11 @@ func listDirHandler(w http.ResponseWriter, r *http.Request) {
 		apierror.BadRequest(w, cid, "Невалидный JSON", "Проверьте формат тела запроса")
 		return
 	}
+	path, ok := workspacePath(w, r, cid, req.Path)
+	if !ok {
+		return
+	}
+	req.Path = path
 	logger.С(ctx).Info("Листинг директории", slog.String("путь", req.Path))
 	files, err := executor.ListDirectory(req.Path)
 	if err != nil {
@@ -214,6 +1184,11 @@ func deleteFileHandler(w http.ResponseWriter, r *http.Request) {
 		apierror.BadRequest(w, cid, "Невалидный JSON", "Проверьте формат тела запроса")
 		return
 	}
+	path, ok := workspacePath(w, r, cid, req.Path)
+	if !ok {
+		return
+	}
+	req.Path = path
 	logger.С(ctx).Info("Удаление файла", slog.String("путь", req.Path))
 	err := executor.DeleteFile(req.Path)
 	if err != nil {
@@ -373,13 +1348,10 @@ func addAutostartHandler(w http.ResponseWriter, r *http.Request) {
 // HTTP-обработчики для Яндекс.Диска (REST API)
 // ============================================================================
 
-// getYandexDiskClient — создаёт клиент Яндекс.Диска из переменной окружения.
-// Сначала проверяет YANDEX_DISK_TOKEN, затем Yandex_Disk.
+// getYandexDiskClient — создаёт клиент Яндекс.Диска с токеном из конфигурации
+// (YANDEX_DISK_TOKEN или Yandex_Disk); токен перечитывается при перезагрузке.
 func getYandexDiskClient() (*executor.YandexDiskClient, error) {
-	token := os.Getenv("YANDEX_DISK_TOKEN")
-	if token == "" {
-		token = os.Getenv("Yandex_Disk")
-	}
+	token := config.Current().YandexDiskToken
 	if token == "" {
 		return nil, fmt.Errorf("токен Яндекс.Диска не настроен (YANDEX_DISK_TOKEN или Yandex_Disk)")
 	}
@@ -709,17 +1681,80 @@ func ydiskSearchHandler(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// configHandler — текущая конфигурация сервиса без секретов (GET /config).
+// Поле reloadable перечисляет параметры, применяемые без перезапуска.
+func configHandler(w http.ResponseWriter, r *http.Request) {
+	cid := r.Header.Get("X-Request-ID")
+	if r.Method != http.MethodGet {
+		apierror.MethodNotAllowed(w, cid)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"config":     config.Current().Sanitized(),
+		"reloadable": config.Reloadable(),
+	})
+}
+
+// configReloadHandler — перечитывает конфигурацию (POST /config/reload), как и SIGHUP.
+// Некорректная конфигурация отклоняется с 400, действующая остаётся без изменений.
+func configReloadHandler(w http.ResponseWriter, r *http.Request) {
+	cid := r.Header.Get("X-Request-ID")
+	if r.Method != http.MethodPost {
+		apierror.MethodNotAllowed(w, cid)
+		return
+	}
+	res, err := reloadConfig()
+	if err != nil {
+		apierror.BadRequest(w, cid, "Конфигурация не применена: "+err.Error(), "Исправьте файл конфигурации или переменные окружения")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(res)
+}
+
+// reloadConfig — перезагрузка конфигурации с записью результата в лог.
+func reloadConfig() (config.ReloadResult, error) {
+	res, err := config.Reload()
+	if err != nil {
+		slog.Error("Перезагрузка конфигурации отклонена", slog.String("ошибка", err.Error()))
+		return res, err
+	}
+	slog.Info("Конфигурация перезагружена", slog.Any("применено", res.Applied), slog.Any("требует_перезапуска", res.RestartRequired))
+	return res, nil
+}
+
 func main() {
 	logger.Init("tools-service")
+	cfg, err := config.Load()
+	if err == nil {
+		err = cfg.Validate()
+	}
+	if err != nil {
+		slog.Error("Некорректная конфигурация", slog.String("ошибка", err.Error()))
+		os.Exit(1)
+	}
+	config.Set(cfg)
+	lintRunner.Store(newLintRunner(cfg))
+	config.OnReload(func(c *config.Config) {
+		lintRunner.Store(newLintRunner(c))
+	})
+	// Трассировка: продолжение traceparent от agent-service и экспорт спанов в OTLP
+	shutdownTracing := tracing.Init("tools-service")
 	execmode.Init()
+	workspace.Init()
 
 	tokenRoles := auth.LoadTokensFromEnv()
 
 	mux := http.NewServeMux()
 	mux.HandleFunc("/health", healthHandler)
+	mux.HandleFunc("/ready", health.Handler("tools-service", readinessChecks))
+	mux.HandleFunc("/config", auth.WithAuth(auth.RoleViewer, tokenRoles, configHandler))
+	mux.HandleFunc("/config/reload", auth.WithAuth(auth.RoleAdmin, tokenRoles, configReloadHandler))
 
 	mux.HandleFunc("/execute", auth.WithAuth(auth.RoleAdmin, tokenRoles, executeHandler))
 	mux.HandleFunc("/addautostart", auth.WithAuth(auth.RoleAdmin, tokenRoles, addAutostartHandler))
+	mux.HandleFunc("/security/", auth.WithAuth(auth.RoleAdmin, tokenRoles, securityHandler))
 
 	mux.HandleFunc("/read", auth.WithAuth(auth.RoleViewer, tokenRoles, readFileHandler))
 	mux.HandleFunc("/list", auth.WithAuth(auth.RoleViewer, tokenRoles, listDirHandler))
@@ -729,10 +1764,28 @@ func main() {
 	mux.HandleFunc("/meminfo", auth.WithAuth(auth.RoleViewer, tokenRoles, memInfoHandler))
 	mux.HandleFunc("/cputemp", auth.WithAuth(auth.RoleViewer, tokenRoles, cpuTemperatureHandler))
 	mux.HandleFunc("/sysload", auth.WithAuth(auth.RoleViewer, tokenRoles, systemLoadHandler))
+	mux.HandleFunc("/diskusage", auth.WithAuth(auth.RoleViewer, tokenRoles, diskUsageHandler))
+	mux.HandleFunc("/net/ping", auth.WithAuth(auth.RoleViewer, tokenRoles, netPingHandler))
+	mux.HandleFunc("/net/traceroute", auth.WithAuth(auth.RoleViewer, tokenRoles, netTracerouteHandler))
+	mux.HandleFunc("/net/dns", auth.WithAuth(auth.RoleViewer, tokenRoles, netDNSHandler))
+	mux.HandleFunc("/net/publicip", auth.WithAuth(auth.RoleViewer, tokenRoles, netPublicIPHandler))
+	mux.HandleFunc("/net/interfaces", auth.WithAuth(auth.RoleViewer, tokenRoles, netInterfacesHandler))
 
 	mux.HandleFunc("/write", auth.WithAuth(auth.RoleOperator, tokenRoles, writeFileHandler))
 	mux.HandleFunc("/delete", auth.WithAuth(auth.RoleOperator, tokenRoles, deleteFileHandler))
+	mux.HandleFunc("/workspace/init", auth.WithAuth(auth.RoleOperator, tokenRoles, workspaceInitHandler))
 	mux.HandleFunc("/launchapp", auth.WithAuth(auth.RoleOperator, tokenRoles, launchAppHandler))
+	mux.HandleFunc("/run-code", auth.WithAuth(auth.RoleOperator, tokenRoles, runCodeHandler))
+	mux.HandleFunc("/run-code/artifact", auth.WithAuth(auth.RoleViewer, tokenRoles, runCodeArtifactHandler))
+	mux.HandleFunc("/lint", auth.WithAuth(auth.RoleViewer, tokenRoles, lintHandler))
+	mux.HandleFunc("/format", auth.WithAuth(auth.RoleOperator, tokenRoles, formatHandler))
+	mux.HandleFunc("/mail/list", auth.WithAuth(auth.RoleViewer, tokenRoles, mailListHandler))
+	mux.HandleFunc("/mail/read", auth.WithAuth(auth.RoleViewer, tokenRoles, mailReadHandler))
+	mux.HandleFunc("/mail/send", auth.WithAuth(auth.RoleOperator, tokenRoles, mailSendHandler))
+	mux.HandleFunc("/calendar/list", auth.WithAuth(auth.RoleViewer, tokenRoles, calendarListHandler))
+	mux.HandleFunc("/calendar/create", auth.WithAuth(auth.RoleOperator, tokenRoles, calendarCreateHandler))
+	mux.HandleFunc("/calendar/update", auth.WithAuth(auth.RoleOperator, tokenRoles, calendarUpdateHandler))
+	mux.HandleFunc("/prometheus/query", auth.WithAuth(auth.RoleViewer, tokenRoles, prometheusQueryHandler))
 
 	mux.HandleFunc("/ydisk/info", auth.WithAuth(auth.RoleViewer, tokenRoles, ydiskInfoHandler))
 	mux.HandleFunc("/ydisk/list", auth.WithAuth(auth.RoleViewer, tokenRoles, ydiskListHandler))
@@ -748,14 +1801,12 @@ func main() {
 	mux.HandleFunc("/browser/fetch", auth.WithAuth(auth.RoleViewer, tokenRoles, fetchURLHandler))
 	mux.HandleFunc("/browser/ai-chat", auth.WithAuth(auth.RoleOperator, tokenRoles, sendToAIChatHandler))
 
-	port := os.Getenv("TOOLS_PORT")
-	if port == "" {
-		port = "8082"
-	}
+	port := cfg.Port
 
+	handler := requestIDMiddleware(tracing.Middleware(mux))
 	srv := &http.Server{
 		Addr:         ":" + port,
-		Handler:      requestIDMiddleware(mux),
+		Handler:      handler,
 		ReadTimeout:  15 * time.Second,
 		WriteTimeout: 60 * time.Second,
 		IdleTimeout:  60 * time.Second,
@@ -769,6 +1820,40 @@ func main() {
 		}
 	}()
 
+	// Внутренний gRPC API для agent-service (proto/tools/v1/tools.proto); 0 — отключён.
+	// Стандартный grpc.health.v1 проверяет api-gateway в /ready.
+	grpcPort := cfg.GRPCPort
+	var (
+		grpcSrv    *grpc.Server
+		grpcHealth *grpchealth.Server
+	)
+	if grpcPort != "0" {
+		lis, err := net.Listen("tcp", ":"+grpcPort)
+		if err != nil {
+			slog.Error("Не удалось открыть порт gRPC", slog.String("порт", grpcPort), slog.String("ошибка", err.Error()))
+			os.Exit(1)
+		}
+		grpcSrv = grpc.NewServer()
+		toolspb.RegisterToolsServer(grpcSrv, &rpc.Server{Tokens: tokenRoles})
+		grpcHealth = grpchealth.NewServer()
+		healthpb.RegisterHealthServer(grpcSrv, grpcHealth)
+		go func() {
+			slog.Info("gRPC API tools-service запускается", slog.String("порт", grpcPort))
+			if err := grpcSrv.Serve(lis); err != nil {
+				slog.Error("Ошибка gRPC-сервера", slog.String("ошибка", err.Error()))
+			}
+		}()
+	}
+
+	// SIGHUP — перезагрузка изменяемых на лету параметров (LINTERS и др.)
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for range hup {
+			reloadConfig()
+		}
+	}()
+
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	sig := <-quit
@@ -779,5 +1864,11 @@ func main() {
 	if err := srv.Shutdown(ctx); err != nil {
 		slog.Error("Ошибка при завершении сервера", slog.String("ошибка", err.Error()))
 	}
+	if grpcSrv != nil {
+		grpcHealth.Shutdown() // NOT_SERVING для /ready шлюза, пока закрываются соединения
+		grpcSrv.GracefulStop()
+	}
+	shutdownTracing(ctx)
+	logger.Flush(ctx)
 	slog.Info("Сервер корректно остановлен")
 }
@@ -1,8 +1,16 @@
+// Пакет apierror — единый формат ошибок HTTP API для всех микросервисов.
+//
+// Каждая ошибка содержит машиночитаемый code, текст message, подсказку hint,
+// идентификатор корреляции request_id и признак retryable. Одинаковая копия
+// формата живёт в api-gateway, tools-service, browser-service и agent-service,
+// поэтому ответы любого сервиса разбираются одной функцией Parse.
 package apierror
 
 import (
 	"encoding/json"
 	"net/http"
+	"strconv"
+	"strings"
 )
 
 type Response struct {
@@ -85,3 +93,73 @@ func NotFound(w http.ResponseWriter, requestID, message string) {
 		Retryable: false,
 	})
 }
+
+// CodeForStatus — возвращает машиночитаемый код ошибки по HTTP-статусу.
+// Используется, когда удалённый сервис не прислал собственный code.
+func CodeForStatus(status int) string {
+	switch status {
+	case http.StatusBadRequest:
+		return "BAD_REQUEST"
+	case http.StatusUnauthorized:
+		return "UNAUTHORIZED"
+	case http.StatusForbidden:
+		return "FORBIDDEN"
+	case http.StatusNotFound:
+		return "NOT_FOUND"
+	case http.StatusMethodNotAllowed:
+		return "METHOD_NOT_ALLOWED"
+	case http.StatusRequestEntityTooLarge:
+		return "PAYLOAD_TOO_LARGE"
+	case http.StatusTooManyRequests:
+		return "RATE_LIMITED"
+	case http.StatusBadGateway:
+		return "BAD_GATEWAY"
+	case http.StatusServiceUnavailable:
+		return "SERVICE_UNAVAILABLE"
+	case http.StatusGatewayTimeout:
+		return "GATEWAY_TIMEOUT"
+	}
+	if status >= 500 {
+		return "INTERNAL_ERROR"
+	}
+	return "HTTP_" + strconv.Itoa(status)
+}
+
+// Parse — разбирает тело ответа с ошибкой от другого сервиса в единый формат.
+//
+// Понимает три варианта тела:
+//   - структурированный Response ({"code", "message", "hint", "request_id"});
+//   - устаревший формат {"error": "..."};
+//   - произвольный текст (обрезается до 500 символов).
+//
+// Если сервис не прислал code или request_id, они заполняются по статусу
+// и заголовку X-Request-ID соответственно.
+func Parse(status int, header http.Header, body []byte) Response {
+	var resp Response
+	if err := json.Unmarshal(body, &resp); err != nil || resp.Message == "" {
+		resp = Response{}
+		var legacy struct {
+			Error string `json:"error"`
+		}
+		if json.Unmarshal(body, &legacy) == nil && legacy.Error != "" {
+			resp.Message = legacy.Error
+		} else {
+			text := strings.TrimSpace(string(body))
+			if r := []rune(text); len(r) > 500 {
+				text = string(r[:500]) + "..."
+			}
+			resp.Message = text
+		}
+		resp.Retryable = status >= 500 || status == http.StatusTooManyRequests
+	}
+	if resp.Message == "" {
+		resp.Message = http.StatusText(status)
+	}
+	if resp.Code == "" {
+		resp.Code = CodeForStatus(status)
+	}
+	if resp.RequestID == "" && header != nil {
+		resp.RequestID = header.Get("X-Request-ID")
+	}
+	return resp
+}
@@ -9,12 +9,12 @@ package auth
 
 import (
 	"context"
-	"encoding/json"
 	"log/slog"
 	"net/http"
 	"os"
 	"strings"
 
+	"github.com/neo-2022/openclaw-memory/tools-service/internal/apierror"
 	"github.com/neo-2022/openclaw-memory/tools-service/internal/execmode"
 )
 
@@ -79,72 +79,73 @@ func LoadTokensFromEnv() map[string]Role {
 	return tokens
 }
 
-// WithAuth — middleware для проверки Bearer-токена и минимальной роли.
-// Если tokenRoles пуст (legacy-режим), пропускает все запросы с предупреждением.
-func WithAuth(requiredRole Role, tokenRoles map[string]Role, next http.HandlerFunc) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		requestID := r.Header.Get("X-Request-ID")
-
-		if len(tokenRoles) == 0 {
-			role := RoleAdmin
-			if execmode.IsSafe() {
-				role = RoleViewer
-			}
-			ctx := context.WithValue(r.Context(), roleContextKey, role)
-			next(w, r.WithContext(ctx))
-			return
-		}
+// Denial — отказ в доступе: HTTP-статус и ответ в формате apierror.
+type Denial struct {
+	Status  int
+	Code    string
+	Message string
+	Hint    string
+}
 
-		authHeader := r.Header.Get("Authorization")
-		if authHeader == "" {
-			writeAuthError(w, http.StatusUnauthorized, "UNAUTHORIZED", "отсутствует заголовок Authorization", "Добавьте Authorization: Bearer <token>", requestID)
-			return
+// Authorize — роль по значению заголовка Authorization (Bearer-токен) и
+// проверка минимальной роли; общая для HTTP-эндпоинтов и gRPC API.
+// endpoint — путь или метод для лога. Если tokenRoles пуст (legacy-режим),
+// возвращает admin (viewer в SAFE_MODE) без проверки.
+func Authorize(requiredRole Role, tokenRoles map[string]Role, authHeader, endpoint string) (Role, *Denial) {
+	if len(tokenRoles) == 0 {
+		if execmode.IsSafe() {
+			return RoleViewer, nil
 		}
+		return RoleAdmin, nil
+	}
 
-		if !strings.HasPrefix(authHeader, "Bearer ") {
-			writeAuthError(w, http.StatusUnauthorized, "UNAUTHORIZED", "формат: Authorization: Bearer <token>", "Используйте Bearer-токен", requestID)
-			return
-		}
+	if authHeader == "" {
+		return "", &Denial{http.StatusUnauthorized, "UNAUTHORIZED", "отсутствует заголовок Authorization", "Добавьте Authorization: Bearer <token>"}
+	}
 
-		token := strings.TrimPrefix(authHeader, "Bearer ")
-		role, ok := tokenRoles[token]
-		if !ok {
-			slog.Warn("Невалидный токен", slog.String("endpoint", r.URL.Path))
-			writeAuthError(w, http.StatusUnauthorized, "UNAUTHORIZED", "невалидный токен", "Проверьте TOOLS_AUTH_TOKENS", requestID)
-			return
-		}
+	if !strings.HasPrefix(authHeader, "Bearer ") {
+		return "", &Denial{http.StatusUnauthorized, "UNAUTHORIZED", "формат: Authorization: Bearer <token>", "Используйте Bearer-токен"}
+	}
 
-		if execmode.IsSafe() {
-			role = RoleViewer
-		}
+	token := strings.TrimPrefix(authHeader, "Bearer ")
+	role, ok := tokenRoles[token]
+	if !ok {
+		slog.Warn("Невалидный токен", slog.String("endpoint", endpoint))
+		return "", &Denial{http.StatusUnauthorized, "UNAUTHORIZED", "невалидный токен", "Проверьте TOOLS_AUTH_TOKENS"}
+	}
+
+	if execmode.IsSafe() {
+		role = RoleViewer
+	}
+
+	if !HasAccess(role, requiredRole) {
+		slog.Warn("Недостаточно прав",
+			slog.String("роль", string(role)),
+			slog.String("требуется", string(requiredRole)),
+			slog.String("endpoint", endpoint),
+		)
+		return "", &Denial{http.StatusForbidden, "FORBIDDEN", "недостаточно прав (требуется " + string(requiredRole) + ")", "Используйте токен с ролью " + string(requiredRole) + " или выше"}
+	}
+	return role, nil
+}
 
-		if !HasAccess(role, requiredRole) {
-			slog.Warn("Недостаточно прав",
-				slog.String("роль", string(role)),
-				slog.String("требуется", string(requiredRole)),
-				slog.String("endpoint", r.URL.Path),
-			)
-			writeAuthError(w, http.StatusForbidden, "FORBIDDEN", "недостаточно прав (требуется "+string(requiredRole)+")", "Используйте токен с ролью "+string(requiredRole)+" или выше", requestID)
+// WithAuth — middleware для проверки Bearer-токена и минимальной роли.
+// Если tokenRoles пуст (legacy-режим), пропускает все запросы с предупреждением.
+func WithAuth(requiredRole Role, tokenRoles map[string]Role, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		role, denial := Authorize(requiredRole, tokenRoles, r.Header.Get("Authorization"), r.URL.Path)
+		if denial != nil {
+			writeAuthError(w, denial.Status, denial.Code, denial.Message, denial.Hint, r.Header.Get("X-Request-ID"))
 			return
 		}
-
 		ctx := context.WithValue(r.Context(), roleContextKey, role)
 		next(w, r.WithContext(ctx))
 	}
 }
 
-type authErrorResponse struct {
-	Code      string `json:"code"`
-	Message   string `json:"message"`
-	Hint      string `json:"hint,omitempty"`
-	RequestID string `json:"request_id,omitempty"`
-	Retryable bool   `json:"retryable"`
-}
-
+// writeAuthError — отправляет ошибку авторизации в едином формате apierror.
 func writeAuthError(w http.ResponseWriter, status int, code, message, hint, requestID string) {
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(status)
-	json.NewEncoder(w).Encode(authErrorResponse{
+	apierror.Write(w, status, apierror.Response{
 		Code:      code,
 		Message:   message,
 		Hint:      hint,
@@ -11,7 +11,10 @@
 package auth
 
 import (
+	"fmt"
+
 	"github.com/neo-2022/openclaw-memory/tools-service/internal/execmode"
+	"github.com/neo-2022/openclaw-memory/tools-service/internal/executor"
 )
 
 // ViewerCommands — команды, доступные роли viewer (только чтение).
@@ -68,3 +71,19 @@ func RoleAllowedCommand(role Role, cmd string) bool {
 		return false
 	}
 }
+
+// CheckCommand — проверка команды перед выполнением (/execute и gRPC Execute):
+// ограничения executor.CheckCommand и доступ роли к каждой подкоманде.
+// hint — подсказка клиенту к ошибке.
+func CheckCommand(role Role, command string) (hint string, err error) {
+	subCmds, err := executor.CheckCommand(command)
+	if err != nil {
+		return "Команда не прошла проверку безопасности", err
+	}
+	for _, sub := range subCmds {
+		if !RoleAllowedCommand(role, sub) {
+			return "Требуется роль с более высоким уровнем доступа", fmt.Errorf("команда %s недоступна для роли %s", sub, role)
+		}
+	}
+	return "", nil
+}
@@ -0,0 +1,345 @@
+// Package calendar — календарь агента через CalDAV (RFC 4791): список событий
+// за интервал, создание и изменение событий с напоминаниями (VALARM).
+//
+// Работает с одной коллекцией CALDAV_URL (Nextcloud, Radicale, Яндекс,
+// iCloud, Fastmail и др.). При изменении события правятся только переданные
+// поля, остальные свойства объекта сохраняются.
+package calendar
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Config — подключение к CalDAV-коллекции.
+type Config struct {
+	URL      string         // CALDAV_URL: адрес календаря (коллекции), например https://cloud/remote.php/dav/calendars/user/personal/
+	User     string         // CALDAV_USER
+	Password string         // CALDAV_PASSWORD (пароль приложения)
+	Location *time.Location // CALDAV_TIMEZONE: часовой пояс для времени без зоны (по умолчанию локальный)
+	Timeout  time.Duration
+}
+
+// DefaultConfig — настройки из переменных окружения.
+func DefaultConfig() Config {
+	cfg := Config{
+		URL:      os.Getenv("CALDAV_URL"),
+		User:     os.Getenv("CALDAV_USER"),
+		Password: os.Getenv("CALDAV_PASSWORD"),
+		Location: time.Local,
+		Timeout:  20 * time.Second,
+	}
+	if tz := os.Getenv("CALDAV_TIMEZONE"); tz != "" {
+		if loc, err := time.LoadLocation(tz); err == nil {
+			cfg.Location = loc
+		}
+	}
+	if cfg.URL != "" && !strings.HasSuffix(cfg.URL, "/") {
+		cfg.URL += "/"
+	}
+	return cfg
+}
+
+// Ошибки календаря.
+var (
+	ErrNotConfigured = errors.New("календарь не настроен: задайте CALDAV_URL, CALDAV_USER, CALDAV_PASSWORD")
+	ErrNotFound      = errors.New("событие не найдено")
+	ErrConflict      = errors.New("событие изменено на сервере — получите его заново и повторите")
+)
+
+// Event — событие календаря.
+type Event struct {
+	UID         string    `json:"uid"`
+	Summary     string    `json:"summary"`
+	Description string    `json:"description,omitempty"`
+	Location    string    `json:"location,omitempty"`
+	Start       time.Time `json:"start"`
+	End         time.Time `json:"end"`
+	AllDay      bool      `json:"all_day,omitempty"`
+	Reminder    int       `json:"reminder_minutes,omitempty"` // Напоминание за N минут до начала
+	Recurrence  string    `json:"recurrence,omitempty"`       // RRULE повторяющегося события
+	Href        string    `json:"-"`
+	ETag        string    `json:"-"`
+}
+
+// Client — CalDAV-клиент.
+type Client struct {
+	cfg  Config
+	http *http.Client
+	now  func() time.Time
+}
+
+// New — клиент календаря.
+func New(cfg Config) *Client {
+	if cfg.Location == nil {
+		cfg.Location = time.Local
+	}
+	return &Client{cfg: cfg, http: &http.Client{Timeout: cfg.Timeout}, now: time.Now}
+}
+
+// Now — текущее время в часовом поясе календаря (для относительных дат в запросах агента).
+func (c *Client) Now() time.Time {
+	return c.now().In(c.cfg.Location)
+}
+
+func (c *Client) do(ctx context.Context, method, target string, body []byte, header map[string]string) (*http.Response, []byte, error) {
+	if c.cfg.URL == "" {
+		return nil, nil, ErrNotConfigured
+	}
+	req, err := http.NewRequestWithContext(ctx, method, target, bytes.NewReader(body))
+	if err != nil {
+		return nil, nil, err
+	}
+	if c.cfg.User != "" {
+		req.SetBasicAuth(c.cfg.User, c.cfg.Password)
+	}
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, nil, fmt.Errorf("CalDAV: %w", err)
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 8<<20))
+	if resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden {
+		return resp, nil, fmt.Errorf("CalDAV: доступ запрещён (%d) — проверьте CALDAV_USER и CALDAV_PASSWORD", resp.StatusCode)
+	}
+	return resp, data, nil
+}
+
+// multistatus — ответ REPORT (RFC 4918 §13).
+type multistatus struct {
+	Responses []struct {
+		Href     string `xml:"DAV: href"`
+		Propstat []struct {
+			Prop struct {
+				ETag string `xml:"DAV: getetag"`
+				Data string `xml:"urn:ietf:params:xml:ns:caldav calendar-data"`
+			} `xml:"DAV: prop"`
+		} `xml:"DAV: propstat"`
+	} `xml:"DAV: response"`
+}
+
+// query — REPORT calendar-query с фильтром filter внутри VEVENT.
+func (c *Client) query(ctx context.Context, filter string) ([]Event, error) {
+	body := `<?xml version="1.0" encoding="utf-8"?>
+<c:calendar-query xmlns:d="DAV:" xmlns:c="urn:ietf:params:xml:ns:caldav">
+  <d:prop><d:getetag/><c:calendar-data/></d:prop>
+  <c:filter><c:comp-filter name="VCALENDAR"><c:comp-filter name="VEVENT">` + filter + `</c:comp-filter></c:comp-filter></c:filter>
+</c:calendar-query>`
+	resp, data, err := c.do(ctx, "REPORT", c.cfg.URL, []byte(body), map[string]string{
+		"Content-Type": "application/xml; charset=utf-8",
+		"Depth":        "1",
+	})
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusMultiStatus {
+		return nil, fmt.Errorf("CalDAV: REPORT вернул %d: %s", resp.StatusCode, snippet(data))
+	}
+	var ms multistatus
+	if err := xml.Unmarshal(data, &ms); err != nil {
+		return nil, fmt.Errorf("CalDAV: разбор ответа: %w", err)
+	}
+	base, _ := url.Parse(c.cfg.URL)
+	var out []Event
+	for _, r := range ms.Responses {
+		href := r.Href
+		if u, err := base.Parse(r.Href); err == nil {
+			href = u.String()
+		}
+		for _, ps := range r.Propstat {
+			if ps.Prop.Data == "" {
+				continue
+			}
+			for _, ev := range parseEvents(ps.Prop.Data, c.cfg.Location) {
+				ev.Href, ev.ETag = href, ps.Prop.ETag
+				out = append(out, ev)
+			}
+		}
+	}
+	return out, nil
+}
+
+// List — события, пересекающие интервал [from, to), по времени начала.
+func (c *Client) List(ctx context.Context, from, to time.Time) ([]Event, error) {
+	filter := fmt.Sprintf(`<c:time-range start="%s" end="%s"/>`,
+		from.UTC().Format("20060102T150405Z"), to.UTC().Format("20060102T150405Z"))
+	events, err := c.query(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(events, func(i, j int) bool { return events[i].Start.Before(events[j].Start) })
+	return events, nil
+}
+
+// Get — событие по UID.
+func (c *Client) Get(ctx context.Context, uid string) (Event, error) {
+	filter := `<c:prop-filter name="UID"><c:text-match collation="i;octet">` + xmlEscape(uid) + `</c:text-match></c:prop-filter>`
+	events, err := c.query(ctx, filter)
+	if err != nil {
+		return Event{}, err
+	}
+	for _, ev := range events {
+		if ev.UID == uid {
+			return ev, nil
+		}
+	}
+	return Event{}, ErrNotFound
+}
+
+// Create — новое событие; UID генерируется, если не задан.
+func (c *Client) Create(ctx context.Context, ev Event) (Event, error) {
+	if err := validate(&ev); err != nil {
+		return Event{}, err
+	}
+	if ev.UID == "" {
+		buf := make([]byte, 16)
+		rand.Read(buf)
+		ev.UID = hex.EncodeToString(buf)
+	}
+	ev.Href = c.cfg.URL + url.PathEscape(ev.UID) + ".ics"
+	resp, data, err := c.do(ctx, http.MethodPut, ev.Href, []byte(buildCalendar(ev, c.now())), map[string]string{
+		"Content-Type":  "text/calendar; charset=utf-8",
+		"If-None-Match": "*",
+	})
+	if err != nil {
+		return Event{}, err
+	}
+	switch resp.StatusCode {
+	case http.StatusCreated, http.StatusNoContent, http.StatusOK:
+	case http.StatusPreconditionFailed:
+		return Event{}, fmt.Errorf("событие с uid %s уже существует", ev.UID)
+	default:
+		return Event{}, fmt.Errorf("CalDAV: PUT вернул %d: %s", resp.StatusCode, snippet(data))
+	}
+	ev.ETag = resp.Header.Get("ETag")
+	return ev, nil
+}
+
+// Patch — изменяемые поля события; nil — без изменений.
+type Patch struct {
+	Summary     *string
+	Description *string
+	Location    *string
+	Start       *time.Time
+	End         *time.Time
+	AllDay      *bool // Вместе со Start: событие на весь день
+	Reminder    *int
+}
+
+// Update — изменение события по UID. При переносе начала без нового конца
+// длительность сохраняется. Запись идёт с If-Match, чтобы не затереть
+// параллельную правку.
+func (c *Client) Update(ctx context.Context, uid string, p Patch) (Event, error) {
+	ev, err := c.Get(ctx, uid)
+	if err != nil {
+		return Event{}, err
+	}
+	resp, raw, err := c.do(ctx, http.MethodGet, ev.Href, nil, nil)
+	if err != nil {
+		return Event{}, err
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		return Event{}, ErrNotFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		return Event{}, fmt.Errorf("CalDAV: GET вернул %d: %s", resp.StatusCode, snippet(raw))
+	}
+	if etag := resp.Header.Get("ETag"); etag != "" {
+		ev.ETag = etag
+	}
+	changed := map[string]bool{}
+	if p.Summary != nil {
+		ev.Summary, changed["summary"] = *p.Summary, true
+	}
+	if p.Description != nil {
+		ev.Description, changed["description"] = *p.Description, true
+	}
+	if p.Location != nil {
+		ev.Location, changed["location"] = *p.Location, true
+	}
+	if p.Start != nil {
+		dur := ev.End.Sub(ev.Start)
+		ev.Start, changed["start"] = *p.Start, true
+		ev.End = ev.Start.Add(dur)
+		if p.AllDay != nil {
+			ev.AllDay = *p.AllDay
+		}
+	}
+	if p.End != nil {
+		ev.End, changed["end"] = *p.End, true
+	}
+	if p.Reminder != nil {
+		ev.Reminder, changed["reminder"] = *p.Reminder, true
+	}
+	if len(changed) == 0 {
+		return ev, nil
+	}
+	if err := validate(&ev); err != nil {
+		return Event{}, err
+	}
+	header := map[string]string{"Content-Type": "text/calendar; charset=utf-8"}
+	if ev.ETag != "" {
+		header["If-Match"] = ev.ETag
+	}
+	resp, raw, err = c.do(ctx, http.MethodPut, ev.Href, []byte(patchEvent(string(raw), uid, ev, changed, c.now())), header)
+	if err != nil {
+		return Event{}, err
+	}
+	switch resp.StatusCode {
+	case http.StatusCreated, http.StatusNoContent, http.StatusOK:
+	case http.StatusPreconditionFailed:
+		return Event{}, ErrConflict
+	default:
+		return Event{}, fmt.Errorf("CalDAV: PUT вернул %d: %s", resp.StatusCode, snippet(raw))
+	}
+	ev.ETag = resp.Header.Get("ETag")
+	return ev, nil
+}
+
+func validate(ev *Event) error {
+	if strings.TrimSpace(ev.Summary) == "" {
+		return errors.New("не указано название события (summary)")
+	}
+	if ev.Start.IsZero() {
+		return errors.New("не указано время начала (start)")
+	}
+	if ev.End.IsZero() {
+		ev.End = ev.Start.Add(time.Hour)
+		if ev.AllDay {
+			ev.End = ev.Start.AddDate(0, 0, 1)
+		}
+	}
+	if !ev.End.After(ev.Start) {
+		return errors.New("конец события должен быть позже начала")
+	}
+	return nil
+}
+
+func xmlEscape(s string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
+
+func snippet(data []byte) string {
+	s := strings.TrimSpace(string(data))
+	if len(s) > 200 {
+		s = s[:200] + "…"
+	}
+	return s
+}
@@ -0,0 +1,173 @@
+package calendar
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeCalDAV — коллекция в памяти: PUT/GET объектов и REPORT (все объекты,
+// фильтр по UID — подстрокой).
+type fakeCalDAV struct {
+	mu      sync.Mutex
+	objects map[string]string // путь → iCalendar
+	etags   map[string]int
+}
+
+func (f *fakeCalDAV) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if u, p, _ := r.BasicAuth(); u != "agent" || p != "secret" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	etag := func(path string) string { return fmt.Sprintf(`"%d"`, f.etags[path]) }
+	switch r.Method {
+	case http.MethodPut:
+		_, exists := f.objects[r.URL.Path]
+		if r.Header.Get("If-None-Match") == "*" && exists {
+			w.WriteHeader(http.StatusPreconditionFailed)
+			return
+		}
+		if m := r.Header.Get("If-Match"); m != "" && m != etag(r.URL.Path) {
+			w.WriteHeader(http.StatusPreconditionFailed)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		f.objects[r.URL.Path] = string(body)
+		f.etags[r.URL.Path]++
+		w.Header().Set("ETag", etag(r.URL.Path))
+		w.WriteHeader(http.StatusCreated)
+	case http.MethodGet:
+		obj, ok := f.objects[r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("ETag", etag(r.URL.Path))
+		io.WriteString(w, obj)
+	case "REPORT":
+		body, _ := io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusMultiStatus)
+		io.WriteString(w, `<?xml version="1.0"?><d:multistatus xmlns:d="DAV:" xmlns:cal="urn:ietf:params:xml:ns:caldav">`)
+		for path, obj := range f.objects {
+			if i := strings.Index(string(body), "<c:text-match"); i >= 0 {
+				uid := string(body)[strings.Index(string(body)[i:], ">")+i+1 : strings.Index(string(body), "</c:text-match>")]
+				if !strings.Contains(obj, "UID:"+uid) {
+					continue
+				}
+			}
+			fmt.Fprintf(w, `<d:response><d:href>%s</d:href><d:propstat><d:prop><d:getetag>%s</d:getetag><cal:calendar-data>%s</cal:calendar-data></d:prop></d:propstat></d:response>`,
+				path, etag(path), xmlEscape(obj))
+		}
+		io.WriteString(w, `</d:multistatus>`)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// TestCreateListUpdate — создание события с напоминанием, список, перенос
+// с сохранением длительности и чужих свойств объекта.
+func TestCreateListUpdate(t *testing.T) {
+	fake := &fakeCalDAV{objects: map[string]string{}, etags: map[string]int{}}
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+	msk := time.FixedZone("MSK", 3*3600)
+	c := New(Config{URL: srv.URL + "/cal/", User: "agent", Password: "secret", Location: msk, Timeout: 5 * time.Second})
+	ctx := context.Background()
+
+	start := time.Date(2026, 10, 19, 22, 0, 0, 0, msk)
+	ev, err := c.Create(ctx, Event{Summary: "Окно обслуживания, БД", Start: start, Reminder: 30})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ev.End.Equal(start.Add(time.Hour)) || ev.UID == "" {
+		t.Fatalf("Create: %+v", ev)
+	}
+	obj := fake.objects["/cal/"+ev.UID+".ics"]
+	for _, want := range []string{"SUMMARY:Окно обслуживания\\, БД", "DTSTART:20261019T190000Z", "TRIGGER:-PT30M"} {
+		if !strings.Contains(obj, want) {
+			t.Errorf("в объекте нет %q:\n%s", want, obj)
+		}
+	}
+	// Свойство, о котором клиент не знает, должно пережить изменение
+	fake.objects["/cal/"+ev.UID+".ics"] = strings.Replace(obj, "END:VEVENT", "ATTENDEE:mailto:ops@example.com\r\nEND:VEVENT", 1)
+
+	events, err := c.List(ctx, start.Add(-time.Hour), start.Add(24*time.Hour))
+	if err != nil || len(events) != 1 || events[0].Summary != "Окно обслуживания, БД" || events[0].Reminder != 30 {
+		t.Fatalf("List: %+v, %v", events, err)
+	}
+
+	moved := start.Add(time.Hour)
+	ev, err = c.Update(ctx, ev.UID, Patch{Start: &moved})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ev.End.Equal(moved.Add(time.Hour)) {
+		t.Errorf("длительность не сохранена: %v — %v", ev.Start, ev.End)
+	}
+	obj = fake.objects["/cal/"+ev.UID+".ics"]
+	if !strings.Contains(obj, "DTSTART:20261019T200000Z") || !strings.Contains(obj, "ATTENDEE:mailto:ops@example.com") ||
+		strings.Count(obj, "DTSTART") != 1 || !strings.Contains(obj, "TRIGGER:-PT30M") {
+		t.Errorf("Update:\n%s", obj)
+	}
+
+	if _, err := c.Update(ctx, "missing", Patch{Start: &moved}); err != ErrNotFound {
+		t.Errorf("ожидалась ErrNotFound, получено %v", err)
+	}
+	if _, err := New(Config{}).List(ctx, start, start); err != ErrNotConfigured {
+		t.Errorf("ожидалась ErrNotConfigured, получено %v", err)
+	}
+}
+
+// TestParseEvents — время с TZID, весь день, DURATION и перенос строк.
+func TestParseEvents(t *testing.T) {
+	data := "BEGIN:VCALENDAR\r\nBEGIN:VEVENT\r\nUID:a\r\nSUMMARY:Длинное наз\r\n вание\r\n" +
+		"DTSTART;TZID=Europe/Moscow:20261020T100000\r\nDURATION:PT1H30M\r\nEND:VEVENT\r\n" +
+		"BEGIN:VEVENT\r\nUID:b\r\nSUMMARY:Отпуск\r\nDTSTART;VALUE=DATE:20261101\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n"
+	events := parseEvents(data, time.UTC)
+	if len(events) != 2 || events[0].Summary != "Длинное название" || events[0].End.Sub(events[0].Start) != 90*time.Minute {
+		t.Fatalf("parseEvents: %+v", events)
+	}
+	if events[0].Start.UTC().Hour() != 7 {
+		t.Errorf("TZID не учтён: %v", events[0].Start)
+	}
+	if !events[1].AllDay || events[1].End.Sub(events[1].Start) != 24*time.Hour {
+		t.Errorf("весь день: %+v", events[1])
+	}
+	if line := fold(strings.Repeat("я", 60)); strings.Contains(line, "\xd1\r") || !strings.Contains(line, "\r\n ") {
+		t.Errorf("fold разорвал символ: %q", line)
+	}
+}
+
+// TestParseTime — форматы времени из запросов агента.
+func TestParseTime(t *testing.T) {
+	now := time.Date(2026, 10, 18, 15, 0, 0, 0, time.FixedZone("MSK", 3*3600))
+	cases := []struct {
+		in     string
+		want   string
+		allDay bool
+	}{
+		{"завтра 22:00", "2026-10-19T22:00:00+03:00", false},
+		{"tomorrow в 22:00", "2026-10-19T22:00:00+03:00", false},
+		{"2026-10-20T09:30", "2026-10-20T09:30:00+03:00", false},
+		{"2026-10-20T09:30:00Z", "2026-10-20T09:30:00Z", false},
+		{"2026-11-01", "2026-11-01T00:00:00+03:00", true},
+		{"сегодня", "2026-10-18T00:00:00+03:00", true},
+	}
+	for _, c := range cases {
+		got, allDay, err := ParseTime(c.in, now)
+		if err != nil || got.Format(time.RFC3339) != c.want || allDay != c.allDay {
+			t.Errorf("ParseTime(%q) = %v %v %v", c.in, got.Format(time.RFC3339), allDay, err)
+		}
+	}
+	if _, _, err := ParseTime("когда-нибудь", now); err == nil {
+		t.Error("непонятное время должно давать ошибку")
+	}
+}
@@ -0,0 +1,358 @@
+package calendar
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// property — строка iCalendar: NAME;PARAM=...:VALUE.
+type property struct {
+	Name   string
+	Params map[string]string
+	Value  string
+}
+
+// unfold — строки iCalendar с учётом переноса (RFC 5545 §3.1: продолжение
+// начинается с пробела или табуляции).
+func unfold(data string) []string {
+	data = strings.ReplaceAll(data, "\r\n", "\n")
+	var lines []string
+	for _, l := range strings.Split(data, "\n") {
+		if (strings.HasPrefix(l, " ") || strings.HasPrefix(l, "\t")) && len(lines) > 0 {
+			lines[len(lines)-1] += l[1:]
+			continue
+		}
+		if l != "" {
+			lines = append(lines, l)
+		}
+	}
+	return lines
+}
+
+// fold — перенос строк длиннее 75 байт, не разрывая UTF-8.
+func fold(line string) string {
+	if len(line) <= 75 {
+		return line
+	}
+	var b strings.Builder
+	n := 0
+	for _, r := range line {
+		size := len(string(r))
+		if n+size > 75 {
+			b.WriteString("\r\n ")
+			n = 1
+		}
+		b.WriteRune(r)
+		n += size
+	}
+	return b.String()
+}
+
+func parseProperty(line string) property {
+	p := property{Params: map[string]string{}}
+	// Двоеточие внутри значения параметра в кавычках не разделяет имя и значение
+	inQuote, colon := false, -1
+	for i, r := range line {
+		if r == '"' {
+			inQuote = !inQuote
+		}
+		if r == ':' && !inQuote {
+			colon = i
+			break
+		}
+	}
+	if colon < 0 {
+		p.Name = strings.ToUpper(line)
+		return p
+	}
+	head := strings.Split(line[:colon], ";")
+	p.Name = strings.ToUpper(head[0])
+	for _, kv := range head[1:] {
+		if k, v, ok := strings.Cut(kv, "="); ok {
+			p.Params[strings.ToUpper(k)] = strings.Trim(v, `"`)
+		}
+	}
+	p.Value = line[colon+1:]
+	return p
+}
+
+var textEscaper = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\n", `\n`)
+var textUnescaper = strings.NewReplacer(`\\`, `\`, `\;`, ";", `\,`, ",", `\n`, "\n", `\N`, "\n")
+
+func escapeText(s string) string   { return textEscaper.Replace(strings.ReplaceAll(s, "\r\n", "\n")) }
+func unescapeText(s string) string { return textUnescaper.Replace(s) }
+
+// parseICalTime — DTSTART/DTEND: UTC (…Z), локальное время с TZID или дата.
+func parseICalTime(p property, loc *time.Location) (time.Time, bool, error) {
+	if p.Params["VALUE"] == "DATE" || len(p.Value) == 8 {
+		t, err := time.ParseInLocation("20060102", p.Value, loc)
+		return t, true, err
+	}
+	if strings.HasSuffix(p.Value, "Z") {
+		t, err := time.Parse("20060102T150405Z", p.Value)
+		return t, false, err
+	}
+	if tz := p.Params["TZID"]; tz != "" {
+		if l, err := time.LoadLocation(tz); err == nil {
+			loc = l
+		}
+	}
+	t, err := time.ParseInLocation("20060102T150405", p.Value, loc)
+	return t, false, err
+}
+
+func formatICalTime(name string, t time.Time, allDay bool) string {
+	if allDay {
+		return name + ";VALUE=DATE:" + t.Format("20060102")
+	}
+	return name + ":" + t.UTC().Format("20060102T150405Z")
+}
+
+// parseEvents — события VEVENT из календарного объекта.
+func parseEvents(data string, loc *time.Location) []Event {
+	var out []Event
+	var ev *Event
+	depth := 0 // Вложенные компоненты (VALARM) внутри VEVENT
+	for _, line := range unfold(data) {
+		p := parseProperty(line)
+		switch {
+		case p.Name == "BEGIN" && p.Value == "VEVENT":
+			ev = &Event{}
+			continue
+		case p.Name == "END" && p.Value == "VEVENT" && ev != nil:
+			if ev.End.IsZero() {
+				ev.End = ev.Start
+				if ev.AllDay {
+					ev.End = ev.Start.AddDate(0, 0, 1)
+				}
+			}
+			out = append(out, *ev)
+			ev = nil
+			continue
+		case ev == nil:
+			continue
+		case p.Name == "BEGIN":
+			depth++
+			continue
+		case p.Name == "END":
+			depth--
+			continue
+		}
+		if depth > 0 {
+			if p.Name == "TRIGGER" && ev.Reminder == 0 {
+				ev.Reminder = triggerMinutes(p.Value)
+			}
+			continue
+		}
+		switch p.Name {
+		case "UID":
+			ev.UID = p.Value
+		case "SUMMARY":
+			ev.Summary = unescapeText(p.Value)
+		case "DESCRIPTION":
+			ev.Description = unescapeText(p.Value)
+		case "LOCATION":
+			ev.Location = unescapeText(p.Value)
+		case "RRULE":
+			ev.Recurrence = p.Value
+		case "DTSTART":
+			ev.Start, ev.AllDay, _ = parseICalTime(p, loc)
+		case "DTEND":
+			ev.End, _, _ = parseICalTime(p, loc)
+		case "DURATION":
+			if d, ok := parseDuration(p.Value); ok && !ev.Start.IsZero() {
+				ev.End = ev.Start.Add(d)
+			}
+		}
+	}
+	return out
+}
+
+// triggerMinutes — напоминание «за N минут» из TRIGGER:-PT15M.
+func triggerMinutes(v string) int {
+	if !strings.HasPrefix(v, "-") {
+		return 0
+	}
+	d, ok := parseDuration(v[1:])
+	if !ok {
+		return 0
+	}
+	return int(d.Minutes())
+}
+
+// parseDuration — длительность iCalendar: P1D, PT1H30M, P1W.
+func parseDuration(v string) (time.Duration, bool) {
+	v = strings.TrimPrefix(strings.TrimPrefix(v, "+"), "P")
+	var d time.Duration
+	num := ""
+	inTime := false
+	for _, r := range v {
+		switch {
+		case r >= '0' && r <= '9':
+			num += string(r)
+		case r == 'T':
+			inTime = true
+		default:
+			n, err := strconv.Atoi(num)
+			if err != nil {
+				return 0, false
+			}
+			num = ""
+			switch {
+			case r == 'W':
+				d += time.Duration(n) * 7 * 24 * time.Hour
+			case r == 'D':
+				d += time.Duration(n) * 24 * time.Hour
+			case r == 'H' && inTime:
+				d += time.Duration(n) * time.Hour
+			case r == 'M' && inTime:
+				d += time.Duration(n) * time.Minute
+			case r == 'S' && inTime:
+				d += time.Duration(n) * time.Second
+			default:
+				return 0, false
+			}
+		}
+	}
+	return d, num == ""
+}
+
+// buildCalendar — новый календарный объект с одним событием.
+func buildCalendar(ev Event, now time.Time) string {
+	lines := []string{"BEGIN:VCALENDAR", "VERSION:2.0", "PRODID:-//agent-RegArt//tools-service//RU", "BEGIN:VEVENT"}
+	lines = append(lines, "UID:"+ev.UID, "DTSTAMP:"+now.UTC().Format("20060102T150405Z"))
+	lines = append(lines, eventProps(ev)...)
+	lines = append(lines, alarm(ev)...)
+	lines = append(lines, "END:VEVENT", "END:VCALENDAR")
+	return joinLines(lines)
+}
+
+func eventProps(ev Event) []string {
+	lines := []string{
+		formatICalTime("DTSTART", ev.Start, ev.AllDay),
+		formatICalTime("DTEND", ev.End, ev.AllDay),
+		"SUMMARY:" + escapeText(ev.Summary),
+	}
+	if ev.Description != "" {
+		lines = append(lines, "DESCRIPTION:"+escapeText(ev.Description))
+	}
+	if ev.Location != "" {
+		lines = append(lines, "LOCATION:"+escapeText(ev.Location))
+	}
+	return lines
+}
+
+func alarm(ev Event) []string {
+	if ev.Reminder <= 0 {
+		return nil
+	}
+	return []string{
+		"BEGIN:VALARM", "ACTION:DISPLAY", "DESCRIPTION:" + escapeText(ev.Summary),
+		fmt.Sprintf("TRIGGER:-PT%dM", ev.Reminder), "END:VALARM",
+	}
+}
+
+func joinLines(lines []string) string {
+	var b strings.Builder
+	for _, l := range lines {
+		b.WriteString(fold(l))
+		b.WriteString("\r\n")
+	}
+	return b.String()
+}
+
+// patchEvent — изменение события в существующем объекте: заменяются только
+// затронутые свойства основного VEVENT с этим UID (без RECURRENCE-ID),
+// остальное (участники, повторения, часовые пояса) сохраняется как есть.
+func patchEvent(data string, uid string, ev Event, changed map[string]bool, now time.Time) string {
+	lines := unfold(data)
+	var out []string
+	inEvent, target, override, depth := false, false, false, 0
+	var body []string // Строки текущего VEVENT
+	flush := func() {
+		if !target || override {
+			out = append(out, body...)
+			return
+		}
+		var kept []string
+		skipAlarm := false
+		for _, l := range body {
+			p := parseProperty(l)
+			if p.Name == "BEGIN" && p.Value == "VALARM" && changed["reminder"] {
+				skipAlarm = true
+			}
+			if skipAlarm {
+				if p.Name == "END" && p.Value == "VALARM" {
+					skipAlarm = false
+				}
+				continue
+			}
+			switch p.Name {
+			case "DTSTART", "DTEND", "DURATION":
+				if changed["start"] || changed["end"] {
+					continue
+				}
+			case "SUMMARY", "DESCRIPTION", "LOCATION":
+				if changed[strings.ToLower(p.Name)] {
+					continue
+				}
+			case "DTSTAMP", "LAST-MODIFIED":
+				continue
+			case "SEQUENCE":
+				n, _ := strconv.Atoi(p.Value)
+				l = "SEQUENCE:" + strconv.Itoa(n+1)
+			}
+			if p.Name == "END" && p.Value == "VEVENT" {
+				kept = append(kept, "DTSTAMP:"+now.UTC().Format("20060102T150405Z"), "LAST-MODIFIED:"+now.UTC().Format("20060102T150405Z"))
+				if changed["start"] || changed["end"] {
+					kept = append(kept, formatICalTime("DTSTART", ev.Start, ev.AllDay), formatICalTime("DTEND", ev.End, ev.AllDay))
+				}
+				if changed["summary"] {
+					kept = append(kept, "SUMMARY:"+escapeText(ev.Summary))
+				}
+				if changed["description"] && ev.Description != "" {
+					kept = append(kept, "DESCRIPTION:"+escapeText(ev.Description))
+				}
+				if changed["location"] && ev.Location != "" {
+					kept = append(kept, "LOCATION:"+escapeText(ev.Location))
+				}
+				if changed["reminder"] {
+					kept = append(kept, alarm(ev)...)
+				}
+			}
+			kept = append(kept, l)
+		}
+		out = append(out, kept...)
+	}
+	for _, l := range lines {
+		p := parseProperty(l)
+		switch {
+		case p.Name == "BEGIN" && p.Value == "VEVENT" && !inEvent:
+			inEvent, target, override, depth, body = true, false, false, 0, []string{l}
+			continue
+		case inEvent:
+			body = append(body, l)
+			if p.Name == "BEGIN" {
+				depth++
+			}
+			if p.Name == "END" && p.Value != "VEVENT" {
+				depth--
+			}
+			if depth == 0 && p.Name == "UID" && p.Value == uid {
+				target = true
+			}
+			if depth == 0 && p.Name == "RECURRENCE-ID" {
+				override = true
+			}
+			if p.Name == "END" && p.Value == "VEVENT" {
+				flush()
+				inEvent = false
+			}
+			continue
+		}
+		out = append(out, l)
+	}
+	return joinLines(out)
+}
@@ -0,0 +1,48 @@
+package calendar
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// relativeDays — относительные дни, которые агент может передать вместо даты.
+var relativeDays = map[string]int{
+	"today": 0, "сегодня": 0,
+	"tomorrow": 1, "завтра": 1,
+	"послезавтра": 2,
+	"yesterday":   -1, "вчера": -1,
+}
+
+// ParseTime — время из запроса агента: RFC 3339, «2006-01-02T15:04»,
+// «2006-01-02 15:04», дата «2006-01-02» (весь день) или «завтра 22:00» /
+// «tomorrow 22:00». Время без зоны — в часовом поясе календаря.
+func ParseTime(s string, now time.Time) (t time.Time, allDay bool, err error) {
+	s = strings.TrimSpace(s)
+	loc := now.Location()
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, false, nil
+	}
+	for _, layout := range []string{"2006-01-02T15:04:05", "2006-01-02T15:04", "2006-01-02 15:04"} {
+		if t, err := time.ParseInLocation(layout, s, loc); err == nil {
+			return t, false, nil
+		}
+	}
+	if t, err := time.ParseInLocation("2006-01-02", s, loc); err == nil {
+		return t, true, nil
+	}
+	word, clock, _ := strings.Cut(strings.ToLower(s), " ")
+	if days, ok := relativeDays[word]; ok {
+		day := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc).AddDate(0, 0, days)
+		clock = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(clock), "в "))
+		if clock == "" {
+			return day, true, nil
+		}
+		hm, err := time.Parse("15:04", clock)
+		if err != nil {
+			return time.Time{}, false, fmt.Errorf("время %q: ожидается ЧЧ:ММ", clock)
+		}
+		return day.Add(time.Duration(hm.Hour())*time.Hour + time.Duration(hm.Minute())*time.Minute), false, nil
+	}
+	return time.Time{}, false, fmt.Errorf("не удалось разобрать время %q: ожидается 2006-01-02T15:04, 2006-01-02 или «завтра 22:00»", s)
+}
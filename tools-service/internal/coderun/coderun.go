@@ -0,0 +1,545 @@
+// Package coderun — интерпретатор кода: выполнение фрагментов Python, Go и
+// JavaScript в одноразовом окружении.
+//
+// В отличие от /execute (bash-команды по белому списку) здесь выполняется
+// произвольный код, поэтому каждый запуск изолирован:
+//   - backend docker — одноразовый контейнер без сети, с лимитами памяти,
+//     CPU и процессов, файловая система только для чтения, кроме каталога запуска;
+//   - backend process — отдельный процесс с урезанным окружением и
+//     ulimit-ограничениями (CPU-время, размер файлов, память), в своей группе
+//     процессов, которая целиком завершается по таймауту.
+//
+// Backend auto без docker переходит на process только при явном разрешении
+// CODE_RUN_ALLOW_PROCESS_FALLBACK=true и никогда — в SAFE_MODE; иначе запуск
+// отклоняется с ErrNoSandbox.
+//
+// Каждый запуск получает собственный каталог: туда пишется исходник, он же
+// рабочий каталог программы. Файлы, созданные кодом, возвращаются как
+// артефакты и доступны для скачивания до истечения ArtifactTTL.
+package coderun
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/neo-2022/openclaw-memory/tools-service/internal/execmode"
+)
+
+// Backend-ы выполнения.
+const (
+	BackendAuto    = "auto"    // docker, если доступен, иначе process (только с AllowProcessFallback)
+	BackendDocker  = "docker"  // Одноразовый контейнер
+	BackendProcess = "process" // Ограниченный локальный процесс
+)
+
+// Language — поддерживаемый язык: имя файла исходника, образ и команда запуска.
+type Language struct {
+	Name    string   // python, go, javascript
+	File    string   // Имя файла исходника в каталоге запуска
+	Image   string   // Docker-образ по умолчанию
+	Command []string // Команда запуска; исходник передаётся относительным путём
+	// LimitAddressSpace — применять ли ulimit -v в backend process.
+	// Компилятор Go и V8 резервируют гигабайты виртуальной памяти и
+	// не стартуют с таким лимитом, для них память ограничивает только docker.
+	LimitAddressSpace bool
+}
+
+var languages = map[string]Language{
+	"python":     {Name: "python", File: "main.py", Image: "python:3.12-slim", Command: []string{"python3", "-I", "main.py"}, LimitAddressSpace: true},
+	"go":         {Name: "go", File: "main.go", Image: "golang:1.22-alpine", Command: []string{"go", "run", "main.go"}},
+	"javascript": {Name: "javascript", File: "main.js", Image: "node:20-alpine", Command: []string{"node", "main.js"}},
+}
+
+// aliases — альтернативные имена языков.
+var aliases = map[string]string{
+	"py": "python", "python3": "python",
+	"golang": "go",
+	"js":     "javascript", "node": "javascript", "nodejs": "javascript",
+}
+
+// LookupLanguage — язык по имени или псевдониму (без учёта регистра).
+func LookupLanguage(name string) (Language, bool) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if canonical, ok := aliases[name]; ok {
+		name = canonical
+	}
+	lang, ok := languages[name]
+	return lang, ok
+}
+
+// Languages — имена поддерживаемых языков по алфавиту.
+func Languages() []string {
+	names := make([]string, 0, len(languages))
+	for name := range languages {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Config — настройки интерпретатора (переменные окружения CODE_RUN_*).
+type Config struct {
+	Backend string // auto, docker или process (CODE_RUN_BACKEND)
+	// AllowProcessFallback — разрешён ли backend auto переход на process,
+	// когда docker недоступен (CODE_RUN_ALLOW_PROCESS_FALLBACK).
+	AllowProcessFallback bool
+	Dir                  string        // Корень каталогов запусков (CODE_RUN_DIR)
+	Timeout              time.Duration // Таймаут по умолчанию (CODE_RUN_TIMEOUT_SEC)
+	MaxTimeout           time.Duration // Максимальный таймаут, который может запросить клиент
+	MemoryMB             int           // Лимит памяти, МБ (CODE_RUN_MEMORY_MB)
+	CPULimit             string        // Лимит CPU для docker (CODE_RUN_CPUS)
+	MaxOutputBytes       int           // Лимит stdout и stderr по отдельности (CODE_RUN_MAX_OUTPUT)
+	MaxFileBytes         int64         // Лимит размера одного создаваемого файла
+	ArtifactTTL          time.Duration // Время хранения каталогов запусков
+	DirUID               int           // Владелец каталога запуска на хосте при userns-remap docker, -1 — uid сервиса (CODE_RUN_DIR_UID)
+	Images               map[string]string
+}
+
+// DefaultConfig — настройки из переменных окружения со значениями по умолчанию.
+func DefaultConfig() Config {
+	return Config{
+		Backend:              strings.ToLower(getEnv("CODE_RUN_BACKEND", BackendAuto)),
+		AllowProcessFallback: strings.EqualFold(os.Getenv("CODE_RUN_ALLOW_PROCESS_FALLBACK"), "true"),
+		Dir:                  getEnv("CODE_RUN_DIR", filepath.Join(os.TempDir(), "code-runs")),
+		Timeout:              time.Duration(getEnvInt("CODE_RUN_TIMEOUT_SEC", 20)) * time.Second,
+		MaxTimeout:           50 * time.Second, // Меньше WriteTimeout сервера (60 с)
+		MemoryMB:             getEnvInt("CODE_RUN_MEMORY_MB", 256),
+		CPULimit:             getEnv("CODE_RUN_CPUS", "1"),
+		MaxOutputBytes:       getEnvInt("CODE_RUN_MAX_OUTPUT", 64*1024),
+		MaxFileBytes:         32 << 20,
+		ArtifactTTL:          time.Hour,
+		DirUID:               getEnvUID("CODE_RUN_DIR_UID"),
+		Images: map[string]string{
+			"python":     getEnv("CODE_RUN_IMAGE_PYTHON", languages["python"].Image),
+			"go":         getEnv("CODE_RUN_IMAGE_GO", languages["go"].Image),
+			"javascript": getEnv("CODE_RUN_IMAGE_JS", languages["javascript"].Image),
+		},
+	}
+}
+
+// Request — запрос на выполнение фрагмента кода.
+type Request struct {
+	Language   string `json:"language"`              // python, go, javascript (или псевдоним)
+	Code       string `json:"code"`                  // Исходный код
+	Stdin      string `json:"stdin,omitempty"`       // Данные стандартного ввода
+	TimeoutSec int    `json:"timeout_sec,omitempty"` // 0 — таймаут по умолчанию
+
+	Env map[string]string `json:"env,omitempty"` // Дополнительные переменные окружения (секреты диалога)
+}
+
+// Artifact — файл, созданный кодом в каталоге запуска.
+type Artifact struct {
+	Name string `json:"name"` // Путь относительно каталога запуска
+	Size int64  `json:"size"` // Размер в байтах
+}
+
+// Result — результат запуска.
+type Result struct {
+	RunID      string     `json:"run_id"`              // Идентификатор запуска (имя каталога)
+	Language   string     `json:"language"`            // Каноническое имя языка
+	Backend    string     `json:"backend"`             // docker или process
+	Stdout     string     `json:"stdout"`              // Стандартный вывод
+	Stderr     string     `json:"stderr"`              // Поток ошибок
+	ExitCode   int        `json:"exit_code"`           // Код возврата (-1 — процесс не завершился сам)
+	TimedOut   bool       `json:"timed_out"`           // Выполнение прервано по таймауту
+	Truncated  bool       `json:"truncated"`           // Вывод обрезан до MaxOutputBytes
+	DurationMs int64      `json:"duration_ms"`         // Длительность выполнения
+	Artifacts  []Artifact `json:"artifacts,omitempty"` // Созданные файлы
+}
+
+// ErrUnsupportedLanguage — язык не поддерживается.
+var ErrUnsupportedLanguage = errors.New("язык не поддерживается")
+
+// ErrNoSandbox — backend auto: docker недоступен, а переход на process не
+// разрешён (или включён SAFE_MODE).
+var ErrNoSandbox = errors.New("docker недоступен, переход на backend process не разрешён")
+
+// Runner — выполняет запросы с заданными настройками.
+type Runner struct {
+	cfg             Config
+	dockerAvailable func() bool
+}
+
+// NewRunner — интерпретатор с настройками cfg.
+func NewRunner(cfg Config) *Runner {
+	return &Runner{cfg: cfg, dockerAvailable: dockerAvailable}
+}
+
+// Config — текущие настройки.
+func (r *Runner) Config() Config {
+	return r.cfg
+}
+
+// ResolveBackend — backend, который будет использован для запуска. Для auto
+// без docker — process с предупреждением в лог, если переход разрешён
+// AllowProcessFallback и SAFE_MODE выключен, иначе ErrNoSandbox.
+func (r *Runner) ResolveBackend() (string, error) {
+	switch r.cfg.Backend {
+	case BackendDocker, BackendProcess:
+		return r.cfg.Backend, nil
+	}
+	if r.dockerAvailable() {
+		return BackendDocker, nil
+	}
+	if execmode.IsSafe() {
+		return "", fmt.Errorf("%w: включён SAFE_MODE", ErrNoSandbox)
+	}
+	if !r.cfg.AllowProcessFallback {
+		return "", ErrNoSandbox
+	}
+	slog.Warn("Docker недоступен — код выполняется локальным процессом без контейнера", slog.String("backend", BackendProcess))
+	return BackendProcess, nil
+}
+
+// Run — выполняет фрагмент кода в новом каталоге запуска.
+// Ошибка возвращается, только если запуск не удалось подготовить или начать;
+// ненулевой код возврата, паника программы и таймаут — это Result.
+func (r *Runner) Run(ctx context.Context, req Request) (Result, error) {
+	lang, ok := LookupLanguage(req.Language)
+	if !ok {
+		return Result{}, fmt.Errorf("%w: %q (доступны: %s)", ErrUnsupportedLanguage, req.Language, strings.Join(Languages(), ", "))
+	}
+	if strings.TrimSpace(req.Code) == "" {
+		return Result{}, errors.New("код не задан")
+	}
+	timeout := r.cfg.Timeout
+	if req.TimeoutSec > 0 {
+		timeout = time.Duration(req.TimeoutSec) * time.Second
+	}
+	if r.cfg.MaxTimeout > 0 && timeout > r.cfg.MaxTimeout {
+		timeout = r.cfg.MaxTimeout
+	}
+
+	backend, err := r.ResolveBackend()
+	if err != nil {
+		return Result{}, err
+	}
+	r.Prune()
+	runID, dir, err := r.newRunDir()
+	if err != nil {
+		return Result{}, fmt.Errorf("каталог запуска: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, lang.File), []byte(req.Code), 0o644); err != nil {
+		return Result{}, fmt.Errorf("запись исходника: %w", err)
+	}
+
+	var cmd *exec.Cmd
+	if backend == BackendDocker {
+		cmd = r.dockerCommand(lang, dir, runID, req.Env)
+	} else {
+		cmd = r.processCommand(lang, dir, timeout)
+		cmd.Env = append(cmd.Env, envList(req.Env)...)
+	}
+
+	stdout := &limitedBuffer{limit: r.cfg.MaxOutputBytes}
+	stderr := &limitedBuffer{limit: r.cfg.MaxOutputBytes}
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	cmd.Stdin = strings.NewReader(req.Stdin)
+
+	start := time.Now()
+	err = runWithTimeout(ctx, cmd, timeout)
+	res := Result{
+		RunID:      runID,
+		Language:   lang.Name,
+		Backend:    backend,
+		Stdout:     stdout.String(),
+		Stderr:     stderr.String(),
+		Truncated:  stdout.truncated || stderr.truncated,
+		DurationMs: time.Since(start).Milliseconds(),
+		TimedOut:   errors.Is(err, errTimeout),
+	}
+	if backend == BackendDocker && err != nil && !errors.As(err, new(*exec.ExitError)) {
+		// Завершение клиента docker не останавливает контейнер
+		exec.Command("docker", "rm", "-f", containerName(runID)).Run()
+	}
+	var exitErr *exec.ExitError
+	switch {
+	case err == nil:
+	case errors.As(err, &exitErr):
+		res.ExitCode = exitErr.ExitCode()
+	case res.TimedOut:
+		res.ExitCode = -1
+	default:
+		return Result{}, fmt.Errorf("запуск %s: %w", lang.Name, err)
+	}
+	res.Artifacts = collectArtifacts(dir, lang.File)
+	return res, nil
+}
+
+// dockerCommand — docker run в одноразовом контейнере: каталог запуска
+// смонтирован в /work, остальная файловая система только для чтения, без сети.
+// Переменные env передаются как -e NAME со значением из окружения клиента
+// docker, чтобы значения не попадали в аргументы процесса (ps).
+func (r *Runner) dockerCommand(lang Language, dir, runID string, env map[string]string) *exec.Cmd {
+	image := r.cfg.Images[lang.Name]
+	if image == "" {
+		image = lang.Image
+	}
+	mem := strconv.Itoa(r.cfg.MemoryMB) + "m"
+	args := []string{
+		"run", "--rm", "-i",
+		"--name", containerName(runID),
+		"--network", "none",
+		"--memory", mem, "--memory-swap", mem,
+		"--cpus", r.cfg.CPULimit,
+		"--pids-limit", "64",
+		"--read-only",
+		"--tmpfs", "/tmp:rw,nosuid,size=256m",
+		"--security-opt", "no-new-privileges",
+		"--cap-drop", "ALL",
+		"--user", fmt.Sprintf("%d:%d", os.Getuid(), os.Getgid()),
+		"-e", "HOME=/tmp", "-e", "GOCACHE=/tmp/gocache", "-e", "GOFLAGS=-mod=mod",
+		"-v", dir + ":/work:rw",
+		"-w", "/work",
+	}
+	extra := envList(env)
+	for _, kv := range extra {
+		args = append(args, "-e", kv[:strings.IndexByte(kv, '=')])
+	}
+	args = append(append(args, image), lang.Command...)
+	cmd := exec.Command("docker", args...)
+	if len(extra) > 0 {
+		cmd.Env = append(os.Environ(), extra...)
+	}
+	return cmd
+}
+
+// envList — env в виде NAME=value, отсортированные по имени.
+func envList(env map[string]string) []string {
+	out := make([]string, 0, len(env))
+	for k, v := range env {
+		out = append(out, k+"="+v)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// containerName — имя контейнера запуска.
+func containerName(runID string) string {
+	return "coderun-" + runID
+}
+
+// processCommand — локальный процесс через sh с ulimit-ограничениями
+// и минимальным окружением (без переменных tools-service, в том числе токенов).
+func (r *Runner) processCommand(lang Language, dir string, timeout time.Duration) *exec.Cmd {
+	limits := []string{
+		"ulimit -t " + strconv.Itoa(int(timeout.Seconds())+1),
+		"ulimit -f " + strconv.FormatInt(r.cfg.MaxFileBytes/512, 10),
+	}
+	if lang.LimitAddressSpace && r.cfg.MemoryMB > 0 {
+		limits = append(limits, "ulimit -v "+strconv.Itoa(r.cfg.MemoryMB*1024))
+	}
+	script := strings.Join(limits, " && ") + ` && exec "$@"`
+	args := append([]string{"-c", script, "coderun"}, lang.Command...)
+	cmd := exec.Command("sh", args...)
+	cmd.Dir = dir
+	cmd.Env = []string{
+		"PATH=" + os.Getenv("PATH"),
+		"HOME=" + dir,
+		"TMPDIR=" + dir,
+		"LANG=C.UTF-8",
+		"GOCACHE=" + filepath.Join(os.TempDir(), "code-runs-gocache"),
+		"GOPATH=" + filepath.Join(os.TempDir(), "code-runs-gopath"),
+		"GOFLAGS=-mod=mod",
+		"GOTOOLCHAIN=local",
+		"PYTHONDONTWRITEBYTECODE=1",
+	}
+	setProcessGroup(cmd)
+	return cmd
+}
+
+var errTimeout = errors.New("превышен таймаут выполнения")
+
+// runWithTimeout — запускает cmd и ждёт завершения; по таймауту или отмене
+// ctx завершает процесс вместе с дочерними.
+func runWithTimeout(ctx context.Context, cmd *exec.Cmd, timeout time.Duration) error {
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	done := make(chan error, 1)
+	go func() { done <- cmd.Wait() }()
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case err := <-done:
+		return err
+	case <-timer.C:
+		killProcess(cmd)
+		<-done
+		return errTimeout
+	case <-ctx.Done():
+		killProcess(cmd)
+		<-done
+		return ctx.Err()
+	}
+}
+
+// ArtifactPath — абсолютный путь к артефакту запуска с защитой от выхода
+// за пределы каталога запуска.
+func (r *Runner) ArtifactPath(runID, name string) (string, error) {
+	if !validRunID(runID) {
+		return "", errors.New("некорректный run_id")
+	}
+	dir := filepath.Join(r.cfg.Dir, runID)
+	path := filepath.Join(dir, filepath.Clean("/"+name))
+	if path == dir {
+		return "", errors.New("не задано имя артефакта")
+	}
+	// Lstat: симлинк, созданный кодом, не должен вести к файлам сервиса
+	info, err := os.Lstat(path)
+	if err != nil {
+		return "", err
+	}
+	if !info.Mode().IsRegular() {
+		return "", fmt.Errorf("%s — не обычный файл", name)
+	}
+	// Симлинком может оказаться и промежуточный каталог пути
+	realDir, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return "", err
+	}
+	realPath, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return "", err
+	}
+	if !strings.HasPrefix(realPath, realDir+string(filepath.Separator)) {
+		return "", fmt.Errorf("%s — за пределами каталога запуска", name)
+	}
+	return realPath, nil
+}
+
+// Prune — удаляет каталоги запусков старше ArtifactTTL.
+func (r *Runner) Prune() {
+	entries, err := os.ReadDir(r.cfg.Dir)
+	if err != nil {
+		return
+	}
+	cutoff := time.Now().Add(-r.cfg.ArtifactTTL)
+	for _, e := range entries {
+		if !e.IsDir() || !validRunID(e.Name()) {
+			continue
+		}
+		if info, err := e.Info(); err == nil && info.ModTime().Before(cutoff) {
+			os.RemoveAll(filepath.Join(r.cfg.Dir, e.Name()))
+		}
+	}
+}
+
+// newRunDir — создаёт каталог запуска с уникальным идентификатором.
+func (r *Runner) newRunDir() (string, string, error) {
+	if err := os.MkdirAll(r.cfg.Dir, 0o755); err != nil {
+		return "", "", err
+	}
+	buf := make([]byte, 8)
+	if _, err := rand.Read(buf); err != nil {
+		return "", "", err
+	}
+	runID := "run-" + hex.EncodeToString(buf)
+	dir := filepath.Join(r.cfg.Dir, runID)
+	// 0700: docker запускает код с --user uid сервиса, так что владелец совпадает
+	if err := os.Mkdir(dir, 0o700); err != nil {
+		return "", "", err
+	}
+	if r.cfg.DirUID >= 0 {
+		// userns-remap: uid в контейнере отображается в другой uid на хосте
+		if err := os.Chown(dir, r.cfg.DirUID, -1); err != nil {
+			os.RemoveAll(dir)
+			return "", "", fmt.Errorf("смена владельца каталога запуска: %w", err)
+		}
+	}
+	return runID, dir, nil
+}
+
+// validRunID — run-<16 hex>; не даёт подставить путь вместо идентификатора.
+func validRunID(id string) bool {
+	if !strings.HasPrefix(id, "run-") || len(id) != len("run-")+16 {
+		return false
+	}
+	_, err := hex.DecodeString(id[len("run-"):])
+	return err == nil
+}
+
+// collectArtifacts — файлы каталога запуска, кроме исходника.
+func collectArtifacts(dir, source string) []Artifact {
+	var artifacts []Artifact
+	filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return nil
+		}
+		rel, relErr := filepath.Rel(dir, path)
+		if relErr != nil || rel == source {
+			return nil
+		}
+		if info, infoErr := d.Info(); infoErr == nil && info.Mode().IsRegular() {
+			artifacts = append(artifacts, Artifact{Name: filepath.ToSlash(rel), Size: info.Size()})
+		}
+		return nil
+	})
+	return artifacts
+}
+
+// limitedBuffer — буфер, сохраняющий не больше limit байт.
+type limitedBuffer struct {
+	buf       bytes.Buffer
+	limit     int
+	truncated bool
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if room := b.limit - b.buf.Len(); room < len(p) {
+		b.truncated = true
+		if room > 0 {
+			b.buf.Write(p[:room])
+		}
+		return len(p), nil
+	}
+	return b.buf.Write(p)
+}
+
+func (b *limitedBuffer) String() string {
+	return b.buf.String()
+}
+
+// dockerAvailable — отвечает ли docker daemon.
+func dockerAvailable() bool {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	return exec.CommandContext(ctx, "docker", "info").Run() == nil
+}
+
+// getEnv — значение переменной окружения или fallback.
+func getEnv(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return fallback
+}
+
+// getEnvInt — целое из переменной окружения или fallback, если не задано или некорректно.
+func getEnvInt(key string, fallback int) int {
+	if n, err := strconv.Atoi(os.Getenv(key)); err == nil && n > 0 {
+		return n
+	}
+	return fallback
+}
+
+// getEnvUID — uid из переменной окружения; -1, если не задан или некорректен.
+func getEnvUID(key string) int {
+	if n, err := strconv.Atoi(os.Getenv(key)); err == nil && n >= 0 {
+		return n
+	}
+	return -1
+}
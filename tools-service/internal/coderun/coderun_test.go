@@ -0,0 +1,183 @@
+package coderun
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/neo-2022/openclaw-memory/tools-service/internal/execmode"
+)
+
+// testRunner — backend process с каталогом запусков во временной директории.
+func testRunner(t *testing.T) *Runner {
+	t.Helper()
+	cfg := DefaultConfig()
+	cfg.Backend = BackendProcess
+	cfg.Dir = t.TempDir()
+	cfg.Timeout = 10 * time.Second
+	cfg.MaxOutputBytes = 1024
+	return NewRunner(cfg)
+}
+
+// TestLookupLanguage — канонические имена и псевдонимы.
+func TestLookupLanguage(t *testing.T) {
+	for alias, want := range map[string]string{"Python": "python", "py": "python", "golang": "go", "JS": "javascript", "node": "javascript"} {
+		lang, ok := LookupLanguage(alias)
+		if !ok || lang.Name != want {
+			t.Errorf("%s: ожидался %s, получено %q", alias, want, lang.Name)
+		}
+	}
+	if _, ok := LookupLanguage("cobol"); ok {
+		t.Error("cobol не должен поддерживаться")
+	}
+}
+
+// TestRun_UnsupportedLanguage — неизвестный язык отклоняется до запуска.
+func TestRun_UnsupportedLanguage(t *testing.T) {
+	_, err := testRunner(t).Run(context.Background(), Request{Language: "cobol", Code: "DISPLAY 'HI'."})
+	if !errors.Is(err, ErrUnsupportedLanguage) {
+		t.Fatalf("ожидалась ErrUnsupportedLanguage, получено %v", err)
+	}
+}
+
+// TestRun_PythonArtifacts — stdout, stdin, код возврата и созданные файлы.
+func TestRun_PythonArtifacts(t *testing.T) {
+	if _, err := exec.LookPath("python3"); err != nil {
+		t.Skip("python3 не установлен")
+	}
+	r := testRunner(t)
+	code := "import sys\nname = sys.stdin.read().strip()\nopen('out.txt', 'w').write('hi ' + name)\nprint('hello', name)\nsys.exit(3)\n"
+	res, err := r.Run(context.Background(), Request{Language: "python", Code: code, Stdin: "bob"})
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if res.Stdout != "hello bob\n" || res.ExitCode != 3 || res.Backend != BackendProcess {
+		t.Fatalf("неожиданный результат: %+v", res)
+	}
+	if len(res.Artifacts) != 1 || res.Artifacts[0].Name != "out.txt" || res.Artifacts[0].Size != 6 {
+		t.Fatalf("ожидался артефакт out.txt, получено %+v", res.Artifacts)
+	}
+	if _, err := r.ArtifactPath(res.RunID, "out.txt"); err != nil {
+		t.Errorf("ArtifactPath: %v", err)
+	}
+	if _, err := r.ArtifactPath(res.RunID, "../../etc/passwd"); err == nil {
+		t.Error("выход за пределы каталога запуска должен отклоняться")
+	}
+	if _, err := r.ArtifactPath("../"+res.RunID, "out.txt"); err == nil {
+		t.Error("некорректный run_id должен отклоняться")
+	}
+}
+
+// TestArtifactPath_Symlinks — симлинки, созданные кодом, не отдаются ни как
+// файл, ни как промежуточный каталог; каталог запуска закрыт от других uid.
+func TestArtifactPath_Symlinks(t *testing.T) {
+	r := testRunner(t)
+	runID, dir, err := r.newRunDir()
+	if err != nil {
+		t.Fatalf("newRunDir: %v", err)
+	}
+	if info, err := os.Stat(dir); err != nil || info.Mode().Perm() != 0o700 {
+		t.Fatalf("ожидались права 0700 каталога запуска, получено %v (%v)", info.Mode().Perm(), err)
+	}
+	outside := t.TempDir()
+	os.WriteFile(filepath.Join(outside, "secret"), []byte("x"), 0o600)
+	if err := os.Symlink(filepath.Join(outside, "secret"), filepath.Join(dir, "link")); err != nil {
+		t.Skipf("симлинки недоступны: %v", err)
+	}
+	os.Symlink(outside, filepath.Join(dir, "sub"))
+	for _, name := range []string{"link", "sub/secret"} {
+		if _, err := r.ArtifactPath(runID, name); err == nil {
+			t.Errorf("%s: симлинк за пределы каталога запуска должен отклоняться", name)
+		}
+	}
+}
+
+// TestRun_TimeoutAndTruncation — бесконечный цикл прерывается, вывод обрезается.
+func TestRun_TimeoutAndTruncation(t *testing.T) {
+	if _, err := exec.LookPath("python3"); err != nil {
+		t.Skip("python3 не установлен")
+	}
+	res, err := testRunner(t).Run(context.Background(), Request{
+		Language:   "python",
+		Code:       "while True:\n    print('x' * 100, flush=True)\n",
+		TimeoutSec: 1,
+	})
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if !res.TimedOut || res.ExitCode != -1 {
+		t.Errorf("ожидался таймаут, получено %+v", res)
+	}
+	if !res.Truncated || len(res.Stdout) != 1024 {
+		t.Errorf("ожидался обрезанный вывод 1024 байт, получено %d (truncated=%v)", len(res.Stdout), res.Truncated)
+	}
+}
+
+// TestProcessCommand_Env — переменные tools-service (токены) не передаются коду.
+func TestProcessCommand_Env(t *testing.T) {
+	t.Setenv("TOOLS_AUTH_TOKENS", "secret:admin")
+	r := testRunner(t)
+	lang, _ := LookupLanguage("python")
+	cmd := r.processCommand(lang, t.TempDir(), time.Second)
+	for _, kv := range cmd.Env {
+		if strings.Contains(kv, "secret") {
+			t.Fatalf("секрет попал в окружение: %s", kv)
+		}
+	}
+	if !strings.Contains(cmd.Args[2], "ulimit -v") {
+		t.Errorf("для python ожидался лимит памяти: %s", cmd.Args[2])
+	}
+}
+
+// TestDockerCommand_Env — значения переменных не попадают в аргументы docker.
+func TestDockerCommand_Env(t *testing.T) {
+	r := testRunner(t)
+	lang, _ := LookupLanguage("python")
+	cmd := r.dockerCommand(lang, t.TempDir(), "run1", map[string]string{"GITHUB_TOKEN": "ghp_secret"})
+	args := strings.Join(cmd.Args, " ")
+	if strings.Contains(args, "ghp_secret") || !strings.Contains(args, "-e GITHUB_TOKEN ") {
+		t.Errorf("аргументы docker: %s", args)
+	}
+	found := false
+	for _, kv := range cmd.Env {
+		found = found || kv == "GITHUB_TOKEN=ghp_secret"
+	}
+	if !found {
+		t.Error("значение переменной должно быть в окружении клиента docker")
+	}
+}
+
+// TestResolveBackend_AutoFallback — без docker auto переходит на process только
+// с AllowProcessFallback и никогда в SAFE_MODE.
+func TestResolveBackend_AutoFallback(t *testing.T) {
+	r := testRunner(t)
+	r.cfg.Backend = BackendAuto
+	r.dockerAvailable = func() bool { return false }
+
+	if _, err := r.ResolveBackend(); !errors.Is(err, ErrNoSandbox) {
+		t.Fatalf("без разрешения ожидалась ErrNoSandbox, получено %v", err)
+	}
+	if _, err := r.Run(context.Background(), Request{Language: "python", Code: "print(1)"}); !errors.Is(err, ErrNoSandbox) {
+		t.Fatalf("Run без разрешения: %v", err)
+	}
+
+	r.cfg.AllowProcessFallback = true
+	if backend, err := r.ResolveBackend(); err != nil || backend != BackendProcess {
+		t.Fatalf("с разрешением: %q, %v", backend, err)
+	}
+
+	t.Setenv("SAFE_MODE", "true")
+	execmode.Init()
+	t.Cleanup(func() {
+		os.Unsetenv("SAFE_MODE")
+		execmode.Init()
+	})
+	if _, err := r.ResolveBackend(); !errors.Is(err, ErrNoSandbox) {
+		t.Fatalf("в SAFE_MODE ожидалась ErrNoSandbox, получено %v", err)
+	}
+}
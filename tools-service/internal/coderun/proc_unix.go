@@ -0,0 +1,26 @@
+//go:build unix
+
+package coderun
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// setProcessGroup — запуск в отдельной группе процессов, чтобы по таймауту
+// завершить и порождённые кодом процессы.
+func setProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+}
+
+// killProcess — SIGKILL всей группе процессов (или одному процессу для docker run).
+func killProcess(cmd *exec.Cmd) {
+	if cmd.Process == nil {
+		return
+	}
+	if cmd.SysProcAttr != nil && cmd.SysProcAttr.Setpgid {
+		syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+		return
+	}
+	cmd.Process.Kill()
+}
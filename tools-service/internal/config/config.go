@@ -0,0 +1,252 @@
+// Package config — централизованная конфигурация tools-service.
+//
+// Параметры собираются в три слоя: значения по умолчанию → YAML-файл
+// (TOOLS_CONFIG_FILE, необязателен) → переменные окружения. Конфигурация
+// проверяется при старте (порты, таймауты); часть параметров (Tunable) можно
+// перечитать на лету через Reload без перезапуска сервиса. Настройки
+// подсистем (песочница, запуск кода, почта, календарь) по-прежнему читаются
+// их пакетами.
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Config — структура конфигурации tools-service.
+type Config struct {
+	Port     string `yaml:"port" json:"port"`           // Порт HTTP-сервера (по умолчанию 8082)
+	GRPCPort string `yaml:"grpc_port" json:"grpc_port"` // Порт gRPC (по умолчанию 9082; 0 — gRPC выключен)
+
+	Tunable `yaml:",inline"`
+}
+
+// Tunable — параметры, которые применяются на лету при Reload.
+type Tunable struct {
+	Linters     string        `yaml:"linters" json:"linters"`           // Разрешённые линтеры через запятую (пусто — все)
+	LintTimeout time.Duration `yaml:"lint_timeout" json:"lint_timeout"` // Таймаут одного запуска линтера
+
+	YandexDiskToken string `yaml:"yandex_disk_token" json:"yandex_disk_token"` // OAuth-токен Яндекс.Диска для /ydisk/*
+}
+
+// Defaults — конфигурация по умолчанию.
+func Defaults() *Config {
+	return &Config{
+		Port:     "8082",
+		GRPCPort: "9082",
+		Tunable: Tunable{
+			LintTimeout: 60 * time.Second,
+		},
+	}
+}
+
+// Load — загружает конфигурацию: значения по умолчанию, затем YAML-файл
+// из TOOLS_CONFIG_FILE (если задан), затем переменные окружения.
+// Возвращает ошибку, если файл не читается или значения некорректны.
+func Load() (*Config, error) {
+	c := Defaults()
+	if path := os.Getenv("TOOLS_CONFIG_FILE"); path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("чтение файла конфигурации: %w", err)
+		}
+		if err := yaml.Unmarshal(data, c); err != nil {
+			return nil, fmt.Errorf("разбор файла конфигурации %s: %w", path, err)
+		}
+	}
+	var errs []error
+	envString(&c.Port, "TOOLS_PORT", "TOOLS_SERVICE_PORT")
+	envString(&c.GRPCPort, "TOOLS_GRPC_PORT")
+	envString(&c.Linters, "LINTERS")
+	errs = append(errs, envSeconds(&c.LintTimeout, "LINT_TIMEOUT_SEC"))
+	envString(&c.YandexDiskToken, "YANDEX_DISK_TOKEN", "Yandex_Disk")
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Validate — проверяет конфигурацию: порты и таймаут линтеров.
+// Возвращает все найденные ошибки сразу.
+func (c *Config) Validate() error {
+	var errs []error
+	if !validPort(c.Port, false) {
+		errs = append(errs, fmt.Errorf("port: некорректный порт %q", c.Port))
+	}
+	if !validPort(c.GRPCPort, true) {
+		errs = append(errs, fmt.Errorf("grpc_port: некорректный порт %q", c.GRPCPort))
+	}
+	if c.LintTimeout <= 0 {
+		errs = append(errs, errors.New("lint_timeout: должен быть больше нуля"))
+	}
+	return errors.Join(errs...)
+}
+
+// LinterList — разрешённые линтеры из Linters без пробелов и пустых имён.
+func (c *Config) LinterList() []string {
+	var out []string
+	for _, n := range strings.Split(c.Linters, ",") {
+		if n = strings.TrimSpace(n); n != "" {
+			out = append(out, n)
+		}
+	}
+	return out
+}
+
+// validPort — номер порта от 1 до 65535; zero разрешает 0 (выключено).
+func validPort(s string, zero bool) bool {
+	p, err := strconv.Atoi(s)
+	if err != nil {
+		return false
+	}
+	return (zero && p == 0) || (p >= 1 && p <= 65535)
+}
+
+// secretMask — замена секретов в Sanitized.
+const secretMask = "***"
+
+// Sanitized — копия конфигурации без секретов, пригодная для GET /config.
+func (c *Config) Sanitized() Config {
+	s := *c
+	if s.YandexDiskToken != "" {
+		s.YandexDiskToken = secretMask
+	}
+	return s
+}
+
+var (
+	current  atomic.Pointer[Config]
+	reloadMu sync.Mutex
+	hooksMu  sync.Mutex
+	hooks    []func(*Config)
+)
+
+// Current — действующая конфигурация. До Set возвращает значения по умолчанию.
+func Current() *Config {
+	if c := current.Load(); c != nil {
+		return c
+	}
+	return Defaults()
+}
+
+// Set — делает c действующей конфигурацией (вызывается при старте).
+func Set(c *Config) {
+	current.Store(c)
+}
+
+// OnReload — регистрирует обработчик, вызываемый после применения Reload
+// (например, для пересоздания линтеров с новыми настройками).
+func OnReload(fn func(*Config)) {
+	hooksMu.Lock()
+	hooks = append(hooks, fn)
+	hooksMu.Unlock()
+}
+
+// ReloadResult — итог перезагрузки конфигурации.
+type ReloadResult struct {
+	Applied         []string `json:"applied"`          // Изменённые параметры, применённые на лету
+	RestartRequired []string `json:"restart_required"` // Изменённые параметры, требующие перезапуска
+}
+
+// Reload — перечитывает файл и окружение и применяет изменения Tunable.
+// Остальные изменения не применяются и перечисляются в RestartRequired.
+// При ошибке загрузки или проверки действующая конфигурация не меняется.
+func Reload() (ReloadResult, error) {
+	reloadMu.Lock()
+	defer reloadMu.Unlock()
+	res := ReloadResult{Applied: []string{}, RestartRequired: []string{}}
+	fresh, err := Load()
+	if err != nil {
+		return res, err
+	}
+	if err := fresh.Validate(); err != nil {
+		return res, err
+	}
+	old := Current()
+	next := *old
+	next.Tunable = fresh.Tunable
+	res.Applied = diff(old.Tunable, fresh.Tunable)
+
+	oldRest, freshRest := *old, *fresh
+	oldRest.Tunable, freshRest.Tunable = Tunable{}, Tunable{}
+	res.RestartRequired = diff(oldRest, freshRest)
+
+	current.Store(&next)
+	if len(res.Applied) > 0 {
+		hooksMu.Lock()
+		fns := append([]func(*Config){}, hooks...)
+		hooksMu.Unlock()
+		for _, fn := range fns {
+			fn(&next)
+		}
+	}
+	return res, nil
+}
+
+// Reloadable — имена (yaml-теги) параметров, применяемых без перезапуска.
+func Reloadable() []string {
+	return fieldNames(reflect.TypeOf(Tunable{}))
+}
+
+// diff — имена (yaml-теги) полей, значения которых различаются в a и b.
+func diff(a, b interface{}) []string {
+	va, vb := reflect.ValueOf(a), reflect.ValueOf(b)
+	t := va.Type()
+	changed := []string{}
+	for i := 0; i < t.NumField(); i++ {
+		if t.Field(i).Anonymous {
+			continue
+		}
+		if !reflect.DeepEqual(va.Field(i).Interface(), vb.Field(i).Interface()) {
+			changed = append(changed, yamlName(t.Field(i)))
+		}
+	}
+	return changed
+}
+
+// fieldNames — yaml-имена всех полей структуры t.
+func fieldNames(t reflect.Type) []string {
+	names := make([]string, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		names = append(names, yamlName(t.Field(i)))
+	}
+	return names
+}
+
+func yamlName(f reflect.StructField) string {
+	name, _, _ := strings.Cut(f.Tag.Get("yaml"), ",")
+	return name
+}
+
+// envString — записывает в dst первое непустое значение из переменных keys.
+func envString(dst *string, keys ...string) {
+	for _, k := range keys {
+		if v := os.Getenv(k); v != "" {
+			*dst = v
+			return
+		}
+	}
+}
+
+// envSeconds — длительность в секундах (допускается дробное число).
+func envSeconds(dst *time.Duration, key string) error {
+	v := os.Getenv(key)
+	if v == "" {
+		return nil
+	}
+	f, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+	if err != nil {
+		return fmt.Errorf("%s: ожидается число секунд, получено %q", key, v)
+	}
+	*dst = time.Duration(f * float64(time.Second))
+	return nil
+}
@@ -0,0 +1,93 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestLoadLayers — переменные окружения перекрывают YAML-файл, файл — значения по умолчанию.
+func TestLoadLayers(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tools.yaml")
+	yml := "port: \"9000\"\nlinters: gofmt\nlint_timeout: 2m\n"
+	if err := os.WriteFile(path, []byte(yml), 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("TOOLS_CONFIG_FILE", path)
+	t.Setenv("LINTERS", "gofmt, black")
+	t.Setenv("Yandex_Disk", "y0_token")
+
+	c, err := Load()
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if c.Port != "9000" || c.LintTimeout != 2*time.Minute {
+		t.Errorf("значения из файла не применены: %+v", c)
+	}
+	if got := c.LinterList(); len(got) != 2 || got[1] != "black" {
+		t.Errorf("LINTERS из окружения должен перекрыть файл, получено %v", got)
+	}
+	if c.YandexDiskToken != "y0_token" || c.GRPCPort != "9082" {
+		t.Errorf("неверные значения: %+v", c)
+	}
+
+	t.Setenv("LINT_TIMEOUT_SEC", "долго")
+	if _, err := Load(); err == nil || !strings.Contains(err.Error(), "LINT_TIMEOUT_SEC") {
+		t.Fatalf("ожидалась ошибка с именем переменной, получено %v", err)
+	}
+}
+
+// TestValidate — проверка портов и таймаута; все ошибки возвращаются сразу.
+func TestValidate(t *testing.T) {
+	if err := Defaults().Validate(); err != nil {
+		t.Fatalf("конфигурация по умолчанию должна быть корректной: %v", err)
+	}
+	c := Defaults()
+	c.GRPCPort = "0"
+	if err := c.Validate(); err != nil {
+		t.Fatalf("grpc_port=0 выключает gRPC: %v", err)
+	}
+	c.Port, c.GRPCPort, c.LintTimeout = "0", "x", 0
+	err := c.Validate()
+	for _, want := range []string{"port", "grpc_port", "lint_timeout"} {
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("в ошибке нет %q: %v", want, err)
+		}
+	}
+}
+
+// TestReload — на лету применяются только Tunable, токен скрывается в Sanitized.
+func TestReload(t *testing.T) {
+	Set(Defaults())
+	defer current.Store(nil)
+	var hookLinters string
+	OnReload(func(c *Config) { hookLinters = c.Linters })
+	defer func() { hooks = nil }()
+
+	t.Setenv("LINTERS", "gofmt")
+	t.Setenv("YANDEX_DISK_TOKEN", "y0_secret")
+	t.Setenv("TOOLS_PORT", "9999")
+	res, err := Reload()
+	if err != nil {
+		t.Fatalf("Reload: %v", err)
+	}
+	if Current().Linters != "gofmt" || hookLinters != "gofmt" || Current().Port != "8082" {
+		t.Errorf("неверная конфигурация после перезагрузки: %+v", Current())
+	}
+	if len(res.Applied) != 2 || len(res.RestartRequired) != 1 || res.RestartRequired[0] != "port" {
+		t.Errorf("неверный итог: %+v", res)
+	}
+	if s := Current().Sanitized(); s.YandexDiskToken != secretMask {
+		t.Errorf("токен не скрыт: %q", s.YandexDiskToken)
+	}
+
+	t.Setenv("LINT_TIMEOUT_SEC", "0")
+	if _, err := Reload(); err == nil {
+		t.Fatal("некорректная конфигурация должна отклоняться")
+	}
+	if Current().LintTimeout != 60*time.Second {
+		t.Errorf("после отклонённой перезагрузки конфигурация изменилась: %v", Current().LintTimeout)
+	}
+}
@@ -0,0 +1,227 @@
+// Package diskusage — анализ занятого места: самые большие каталоги и файлы
+// под заданным путём в структурированном виде, чтобы на вопрос «чем забит
+// диск» агент отвечал по готовой таблице, а не разбирал вывод du.
+//
+// Обход ограничен глубиной сводки по каталогам (Depth), временем (Timeout) и
+// числом записей (MaxEntries); при срабатывании ограничения возвращается
+// частичный результат с Truncated. Символические ссылки не разыменовываются,
+// другие файловые системы (смонтированные внутрь пути) не обходятся —
+// как du -x.
+package diskusage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/neo-2022/openclaw-memory/tools-service/internal/executor"
+)
+
+// Ограничения по умолчанию и предельные.
+const (
+	DefaultDepth      = 2
+	MaxDepth          = 6
+	DefaultTop        = 15
+	MaxTop            = 100
+	DefaultTimeout    = 20 * time.Second
+	MaxTimeout        = 2 * time.Minute
+	DefaultMaxEntries = 1_000_000
+)
+
+// Options — параметры обхода; нулевые значения заменяются значениями по умолчанию.
+type Options struct {
+	Depth      int           // Глубина сводки по каталогам относительно корня
+	Top        int           // Сколько каталогов и файлов вернуть
+	Timeout    time.Duration // Предельное время обхода
+	MaxEntries int           // Предельное число просмотренных записей
+	MinSize    int64         // Не показывать файлы меньше (байт)
+	Skip       func(path string) bool
+}
+
+// normalize — значения по умолчанию и предельные.
+func (o Options) normalize() Options {
+	if o.Depth <= 0 {
+		o.Depth = DefaultDepth
+	}
+	o.Depth = min(o.Depth, MaxDepth)
+	if o.Top <= 0 {
+		o.Top = DefaultTop
+	}
+	o.Top = min(o.Top, MaxTop)
+	if o.Timeout <= 0 {
+		o.Timeout = DefaultTimeout
+	}
+	o.Timeout = min(o.Timeout, MaxTimeout)
+	if o.MaxEntries <= 0 {
+		o.MaxEntries = DefaultMaxEntries
+	}
+	return o
+}
+
+// Entry — каталог или файл в отчёте.
+type Entry struct {
+	Path  string  `json:"path"`
+	Size  int64   `json:"size"`            // Занято на диске, байт
+	Human string  `json:"human"`           // Размер в читаемом виде
+	Share float64 `json:"share"`           // Доля от размера корня, %
+	Files int     `json:"files,omitempty"` // Файлов внутри (для каталогов)
+}
+
+// Report — результат анализа.
+type Report struct {
+	Path       string  `json:"path"`
+	Size       int64   `json:"size"`
+	Human      string  `json:"human"`
+	Files      int     `json:"files"`
+	Dirs       int     `json:"dirs"`
+	Depth      int     `json:"depth"`
+	TopDirs    []Entry `json:"top_dirs"`
+	TopFiles   []Entry `json:"top_files"`
+	Skipped    int     `json:"skipped,omitempty"` // Нет доступа, запрещённые пути, другие ФС
+	Truncated  bool    `json:"truncated"`
+	Reason     string  `json:"reason,omitempty"` // Почему обход остановлен раньше
+	DurationMs int64   `json:"duration_ms"`
+}
+
+// ErrNotDir — путь не каталог (для файла размер виден из list/read).
+var ErrNotDir = errors.New("путь не является каталогом")
+
+// errStop — обход прерван ограничением.
+var errStop = errors.New("stop")
+
+// Scan — обход root и сводка по самым большим каталогам и файлам.
+func Scan(ctx context.Context, root string, opts Options) (Report, error) {
+	opts = opts.normalize()
+	info, err := os.Lstat(root)
+	if err != nil {
+		return Report{}, err
+	}
+	if !info.IsDir() {
+		return Report{}, fmt.Errorf("%w: %s", ErrNotDir, root)
+	}
+	rootDev, _ := usage(info)
+
+	ctx, cancel := context.WithTimeout(ctx, opts.Timeout)
+	defer cancel()
+	start := time.Now()
+	rep := Report{Path: root, Depth: opts.Depth}
+	dirs := map[string]*Entry{}
+	var files []Entry
+	seen := 0
+
+	err = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			rep.Skipped++
+			if d != nil && d.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if seen++; seen%1024 == 0 && ctx.Err() != nil {
+			rep.Reason = "превышено время обхода " + opts.Timeout.String()
+			return errStop
+		}
+		if seen > opts.MaxEntries {
+			rep.Reason = fmt.Sprintf("просмотрено больше %d записей", opts.MaxEntries)
+			return errStop
+		}
+		if path == root {
+			return nil
+		}
+		if opts.Skip != nil && opts.Skip(path) {
+			rep.Skipped++
+			if d.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			rep.Skipped++
+			return nil
+		}
+		dev, size := usage(info)
+		if d.IsDir() {
+			if dev != rootDev {
+				rep.Skipped++
+				return fs.SkipDir
+			}
+			rep.Dirs++
+		} else {
+			rep.Files++
+		}
+		rep.Size += size
+
+		rel, _ := filepath.Rel(root, path)
+		parts := strings.Split(rel, string(filepath.Separator))
+		levels := min(len(parts), opts.Depth)
+		if !d.IsDir() {
+			levels = min(len(parts)-1, opts.Depth)
+		}
+		for i := 1; i <= levels; i++ {
+			key := filepath.Join(root, filepath.Join(parts[:i]...))
+			e := dirs[key]
+			if e == nil {
+				e = &Entry{Path: key}
+				dirs[key] = e
+			}
+			e.Size += size
+			if !d.IsDir() {
+				e.Files++
+			}
+		}
+		if d.Type().IsRegular() && size >= opts.MinSize {
+			files = append(files, Entry{Path: path, Size: size})
+			if len(files) >= 4*opts.Top {
+				files = top(files, opts.Top)
+			}
+		}
+		return nil
+	})
+	if errors.Is(err, errStop) {
+		rep.Truncated = true
+	} else if err != nil {
+		return Report{}, err
+	}
+
+	list := make([]Entry, 0, len(dirs))
+	for _, e := range dirs {
+		list = append(list, *e)
+	}
+	rep.TopDirs = finish(top(list, opts.Top), rep.Size)
+	rep.TopFiles = finish(top(files, opts.Top), rep.Size)
+	rep.Human = executor.FormatSize(rep.Size)
+	rep.DurationMs = time.Since(start).Milliseconds()
+	return rep, nil
+}
+
+// top — n самых больших записей по убыванию размера (при равенстве — по пути).
+func top(list []Entry, n int) []Entry {
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Size != list[j].Size {
+			return list[i].Size > list[j].Size
+		}
+		return list[i].Path < list[j].Path
+	})
+	if len(list) > n {
+		list = list[:n]
+	}
+	return list
+}
+
+// finish — читаемый размер и доля от корня.
+func finish(list []Entry, total int64) []Entry {
+	for i := range list {
+		list[i].Human = executor.FormatSize(list[i].Size)
+		if total > 0 {
+			list[i].Share = float64(int(float64(list[i].Size)/float64(total)*1000+0.5)) / 10
+		}
+	}
+	return list
+}
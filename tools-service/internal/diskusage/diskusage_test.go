@@ -0,0 +1,59 @@
+package diskusage
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func write(t *testing.T, path string, size int) {
+	t.Helper()
+	os.MkdirAll(filepath.Dir(path), 0o755)
+	if err := os.WriteFile(path, []byte(strings.Repeat("x", size)), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestScan(t *testing.T) {
+	root := t.TempDir()
+	write(t, filepath.Join(root, "logs", "app", "big.log"), 400_000)
+	write(t, filepath.Join(root, "logs", "small.log"), 10_000)
+	write(t, filepath.Join(root, "cache", "a.bin"), 100_000)
+	write(t, filepath.Join(root, "secret", "key"), 900_000)
+	write(t, filepath.Join(root, "readme.txt"), 100)
+
+	rep, err := Scan(context.Background(), root, Options{Depth: 1, Top: 2, Skip: func(p string) bool {
+		return filepath.Base(p) == "secret"
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rep.TopDirs) != 2 || rep.TopDirs[0].Path != filepath.Join(root, "logs") || rep.TopDirs[0].Files != 2 || rep.TopDirs[1].Path != filepath.Join(root, "cache") {
+		t.Errorf("каталоги первого уровня по убыванию: %+v", rep.TopDirs)
+	}
+	if len(rep.TopFiles) != 2 || filepath.Base(rep.TopFiles[0].Path) != "big.log" || rep.TopFiles[0].Share < 50 {
+		t.Errorf("файлы: %+v", rep.TopFiles)
+	}
+	if rep.Files != 4 || rep.Skipped != 1 || rep.Truncated || rep.Human == "" {
+		t.Errorf("итог: %+v", rep)
+	}
+
+	deep, _ := Scan(context.Background(), root, Options{Depth: 2, Top: 10})
+	found := false
+	for _, d := range deep.TopDirs {
+		found = found || d.Path == filepath.Join(root, "logs", "app")
+	}
+	if !found {
+		t.Errorf("depth=2 — вложенные каталоги: %+v", deep.TopDirs)
+	}
+
+	if rep, _ := Scan(context.Background(), root, Options{MaxEntries: 3}); !rep.Truncated || rep.Reason == "" {
+		t.Errorf("ограничение числа записей: %+v", rep)
+	}
+	if _, err := Scan(context.Background(), filepath.Join(root, "readme.txt"), Options{}); !errors.Is(err, ErrNotDir) {
+		t.Errorf("файл вместо каталога: %v", err)
+	}
+}
@@ -0,0 +1,10 @@
+//go:build !unix
+
+package diskusage
+
+import "os"
+
+// usage — без сведений об устройстве и блоках: логический размер файла.
+func usage(info os.FileInfo) (dev uint64, size int64) {
+	return 0, info.Size()
+}
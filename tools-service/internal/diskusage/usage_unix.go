@@ -0,0 +1,17 @@
+//go:build unix
+
+package diskusage
+
+import (
+	"os"
+	"syscall"
+)
+
+// usage — устройство и место, фактически занятое на диске (блоки, как du;
+// для разреженных файлов меньше Size).
+func usage(info os.FileInfo) (dev uint64, size int64) {
+	if st, ok := info.Sys().(*syscall.Stat_t); ok {
+		return uint64(st.Dev), st.Blocks * 512
+	}
+	return 0, info.Size()
+}
@@ -0,0 +1,96 @@
+package executor
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// MaxEnvVars — сколько переменных окружения можно передать с одной командой.
+const MaxEnvVars = 64
+
+var envName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]{0,127}$`)
+
+// Переменные запроса пропускаются по белому списку: список опасных имён
+// никогда не полон (GIT_CONFIG_*, GIT_ASKPASS, PAGER, NODE_OPTIONS,
+// PYTHONSTARTUP, PERL5OPT...), а секретам и настройкам пользователя хватает
+// нескольких шаблонов имён. Тот же список проверяет agent-service (envvars).
+
+// allowedEnv — разрешённые имена, не подходящие под шаблоны.
+var allowedEnv = map[string]bool{
+	"GIT_AUTHOR_NAME": true, "GIT_AUTHOR_EMAIL": true, "GIT_COMMITTER_NAME": true, "GIT_COMMITTER_EMAIL": true,
+	"AWS_REGION": true, "AWS_DEFAULT_REGION": true, "DATABASE_URL": true, "CI": true, "TZ": true, "NO_COLOR": true,
+}
+
+// allowedEnvSuffixes — окончания имён секретов и учётных данных (GITHUB_TOKEN,
+// OPENAI_API_KEY, AWS_ACCESS_KEY_ID); имя может совпадать с окончанием без «_».
+var allowedEnvSuffixes = []string{"_TOKEN", "_KEY", "_KEY_ID", "_SECRET", "_PASSWORD", "_USER", "_USERNAME"}
+
+// AllowedEnvPrefix — префикс произвольных переменных пользователя (APP_REGION).
+const AllowedEnvPrefix = "APP_"
+
+// deniedEnvPrefixes — пространства имён загрузчика, shell и git: в них имя не
+// разрешается даже по окончанию.
+var deniedEnvPrefixes = []string{"LD_", "DYLD_", "BASH_", "GIT_"}
+
+// EnvAllowed — можно ли передать переменную с запросом.
+func EnvAllowed(name string) bool {
+	name = strings.ToUpper(name)
+	if allowedEnv[name] {
+		return true
+	}
+	for _, p := range deniedEnvPrefixes {
+		if strings.HasPrefix(name, p) {
+			return false
+		}
+	}
+	if strings.HasPrefix(name, AllowedEnvPrefix) && len(name) > len(AllowedEnvPrefix) {
+		return true
+	}
+	for _, s := range allowedEnvSuffixes {
+		if strings.HasSuffix(name, s) || name == s[1:] {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidateEnv — проверяет переменные окружения из запроса (/execute, /run-code):
+// имена вида [A-Za-z_][A-Za-z0-9_]* из белого списка (EnvAllowed).
+func ValidateEnv(env map[string]string) error {
+	if len(env) > MaxEnvVars {
+		return fmt.Errorf("env: больше %d переменных", MaxEnvVars)
+	}
+	for k, v := range env {
+		if !envName.MatchString(k) {
+			return fmt.Errorf("env: некорректное имя переменной %q", k)
+		}
+		if !EnvAllowed(k) {
+			return fmt.Errorf("env: переменную %s задавать нельзя: разрешены *_TOKEN, *_KEY, *_SECRET, *_PASSWORD, *_USER и %s*", k, AllowedEnvPrefix)
+		}
+		if strings.ContainsRune(v, 0) {
+			return fmt.Errorf("env: значение %s содержит нулевой байт", k)
+		}
+	}
+	return nil
+}
+
+// commandEnv — окружение tools-service с добавленными env (nil — без изменений,
+// команда наследует окружение процесса).
+func commandEnv(env map[string]string) []string {
+	if len(env) == 0 {
+		return nil
+	}
+	keys := make([]string, 0, len(env))
+	for k := range env {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	out := os.Environ()
+	for _, k := range keys {
+		out = append(out, k+"="+env[k])
+	}
+	return out
+}
@@ -10,6 +10,7 @@
 package executor
 
 import (
+	"bytes"
 	"fmt"
 	"log/slog"
 	"os/exec"
@@ -175,6 +176,23 @@ func CheckCommand(command string) ([]string, error) {
 //
 // Параметр command — строка как в терминале (например, "ls -la && df -h").
 func ExecuteCommand(command string) Result {
+	return ExecuteCommandStream(command, nil)
+}
+
+// OutputFunc — получает вывод команды по мере появления; stream — stdout или
+// stderr. Вызывается из разных горутин для stdout и stderr.
+type OutputFunc func(stream string, data []byte)
+
+// ExecuteCommandStream — ExecuteCommand с передачей вывода в onOutput по мере
+// выполнения (gRPC Execute); итоговый Result тот же. onOutput может быть nil.
+func ExecuteCommandStream(command string, onOutput OutputFunc) Result {
+	return ExecuteCommandIn("", nil, command, onOutput)
+}
+
+// ExecuteCommandIn — ExecuteCommandStream в каталоге dir (рабочее
+// пространство запроса; пустой dir — текущий каталог tools-service) с
+// дополнительными переменными окружения env (проверяются ValidateEnv).
+func ExecuteCommandIn(dir string, env map[string]string, command string, onOutput OutputFunc) Result {
 	trusted := execmode.IsTrusted()
 	cmdLower := strings.ToLower(strings.TrimSpace(command))
 
@@ -223,19 +241,24 @@ func ExecuteCommand(command string) Result {
 
 	slog.Info("Выполнение команды", slog.String("команда", command), slog.String("режим", execmode.String()))
 	cmd := exec.Command("bash", "-c", command)
+	cmd.Dir = dir
+	cmd.Env = commandEnv(env)
 
-	stdout, err := cmd.Output()
+	var stdout, errOut bytes.Buffer
+	cmd.Stdout = &outputWriter{buf: &stdout, stream: "stdout", on: onOutput}
+	cmd.Stderr = &outputWriter{buf: &errOut, stream: "stderr", on: onOutput}
+	err := cmd.Run()
 	stderr := ""
 	if err != nil {
-		if exitErr, ok := err.(*exec.ExitError); ok {
-			stderr = string(exitErr.Stderr)
+		if _, ok := err.(*exec.ExitError); ok {
+			stderr = errOut.String()
 		}
 		exitCode := -1
 		if cmd.ProcessState != nil {
 			exitCode = cmd.ProcessState.ExitCode()
 		}
 		return Result{
-			Stdout:     string(stdout),
+			Stdout:     stdout.String(),
 			Stderr:     stderr,
 			ReturnCode: exitCode,
 			Error:      err.Error(),
@@ -243,8 +266,23 @@ func ExecuteCommand(command string) Result {
 	}
 
 	return Result{
-		Stdout:     string(stdout),
+		Stdout:     stdout.String(),
 		Stderr:     stderr,
 		ReturnCode: 0,
 	}
 }
+
+// outputWriter — копит вывод команды и передаёт каждый фрагмент в on.
+type outputWriter struct {
+	buf    *bytes.Buffer
+	stream string
+	on     OutputFunc
+}
+
+func (w *outputWriter) Write(p []byte) (int, error) {
+	w.buf.Write(p)
+	if w.on != nil {
+		w.on(w.stream, append([]byte(nil), p...))
+	}
+	return len(p), nil
+}
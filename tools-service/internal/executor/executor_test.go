@@ -102,3 +102,32 @@ func TestExtractSubCommands(t *testing.T) {
 		}
 	}
 }
+
+func TestExecuteCommandIn_Env(t *testing.T) {
+	res := ExecuteCommandIn(t.TempDir(), map[string]string{"GREETING": "привет"}, "echo $GREETING", nil)
+	if res.Error != "" || res.Stdout != "привет\n" {
+		t.Errorf("ожидался stdout 'привет\\n', получено %q (ошибка %q)", res.Stdout, res.Error)
+	}
+}
+
+func TestValidateEnv(t *testing.T) {
+	if err := ValidateEnv(map[string]string{"GITHUB_TOKEN": "x", "APP_A1": "", "aws_secret_access_key": "y"}); err != nil {
+		t.Errorf("корректные имена отклонены: %v", err)
+	}
+	for _, env := range []map[string]string{
+		{"PATH": "/tmp"},
+		{"ld_preload": "x.so"},
+		{"BASH_FUNC_ls%%": "() { :; }"},
+		{"GIT_CONFIG_KEY_0": "core.pager"},
+		{"EDITOR": "sh"},
+		{"PERL5OPT": "-Mx"},
+		{"GIT_SSH_KEY": "x"},
+		{"1ABC": "x"},
+		{"A-B": "x"},
+		{"A": "x\x00y"},
+	} {
+		if err := ValidateEnv(env); err == nil {
+			t.Errorf("ожидалась ошибка для %v", env)
+		}
+	}
+}
@@ -62,6 +62,12 @@ func validatePath(path string) (string, error) {
 	return cleanPath, nil
 }
 
+// ValidatePath — проверка пути по тем же правилам, что у файловых операций;
+// используется эндпоинтами, которые передают пути внешним утилитам (/lint, /format).
+func ValidatePath(path string) (string, error) {
+	return validatePath(path)
+}
+
 // ReadFile — безопасное чтение файла по указанному пути.
 // Проверяет путь на безопасность и ограничивает размер файла до MaxFileSize.
 func ReadFile(path string) (string, error) {
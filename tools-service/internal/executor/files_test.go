@@ -1,7 +1,10 @@
 package executor
 
 import (
+	"context"
+	"errors"
 	"os"
+	"os/exec"
 	"path/filepath"
 	"testing"
 )
@@ -449,3 +452,52 @@ func TestMultipleOperations_DifferentFiles(t *testing.T) {
 		}
 	}
 }
+
+// ===== Тесты подготовки рабочего пространства =====
+
+func TestInitWorkspace_CreateGitManifest(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git не установлен")
+	}
+	path := filepath.Join(t.TempDir(), "project")
+	res, err := InitWorkspace(context.Background(), WorkspaceOptions{Path: path, Name: "app", Create: true, Git: true, Manifest: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !res.Created || !res.Writable || !res.GitInitialized || !res.ManifestWritten {
+		t.Errorf("результат: %+v", res)
+	}
+	if _, err := os.Stat(filepath.Join(path, ".git")); err != nil {
+		t.Error("репозиторий не создан")
+	}
+	// Повторный вызов ничего не пересоздаёт
+	res, err = InitWorkspace(context.Background(), WorkspaceOptions{Path: path, Name: "app", Create: true, Git: true, Manifest: true})
+	if err != nil || res.Created || res.GitInitialized || res.ManifestWritten || !res.GitRepo {
+		t.Errorf("повторный вызов: %+v %v", res, err)
+	}
+}
+
+func TestInitWorkspace_Errors(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "file.txt")
+	if err := os.WriteFile(file, []byte("x"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cases := map[string]WorkspaceOptions{
+		"PATH_REQUIRED":     {},
+		"PATH_NOT_ABSOLUTE": {Path: "relative/dir"},
+		"PATH_FORBIDDEN":    {Path: "/etc/app", Create: true},
+		"PATH_NOT_FOUND":    {Path: filepath.Join(dir, "missing")},
+		"NOT_A_DIRECTORY":   {Path: file},
+	}
+	for code, opt := range cases {
+		_, err := InitWorkspace(context.Background(), opt)
+		var wsErr *WorkspaceError
+		if !errors.As(err, &wsErr) || wsErr.Code != code || wsErr.Hint == "" {
+			t.Errorf("%s: получено %v", code, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "missing")); !os.IsNotExist(err) {
+		t.Error("без create каталог не должен создаваться")
+	}
+}
@@ -0,0 +1,146 @@
+package executor
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// WorkspaceManifest — имя файла-описания рабочего пространства в его корне.
+const WorkspaceManifest = ".agent-workspace.json"
+
+// WorkspaceOptions — что сделать с каталогом нового рабочего пространства.
+// Без флагов каталог только проверяется: существует и доступен для записи.
+type WorkspaceOptions struct {
+	Path     string `json:"path"`
+	Name     string `json:"name"`
+	Create   bool   `json:"create"`   // Создать каталог, если его нет
+	Git      bool   `json:"git"`      // git init, если это ещё не репозиторий
+	Manifest bool   `json:"manifest"` // Записать WorkspaceManifest, если его нет
+}
+
+// WorkspaceResult — итог подготовки каталога.
+type WorkspaceResult struct {
+	Path            string `json:"path"`
+	Created         bool   `json:"created"`         // Каталог создан сейчас
+	Writable        bool   `json:"writable"`        // Запись проверена пробным файлом
+	GitRepo         bool   `json:"git_repo"`        // Каталог — репозиторий git
+	GitInitialized  bool   `json:"git_initialized"` // Репозиторий создан сейчас
+	Manifest        string `json:"manifest,omitempty"`
+	ManifestWritten bool   `json:"manifest_written"` // Манифест записан сейчас
+}
+
+// WorkspaceError — ошибка подготовки каталога с кодом и подсказкой для UI.
+type WorkspaceError struct {
+	Code    string
+	Message string
+	Hint    string
+}
+
+func (e *WorkspaceError) Error() string { return e.Message }
+
+// InitWorkspace — проверяет и при необходимости подготавливает каталог
+// рабочего пространства. Все проверки (путь, наличие git) выполняются до
+// изменений на диске, чтобы отказ не оставлял полусозданный каталог.
+func InitWorkspace(ctx context.Context, opt WorkspaceOptions) (WorkspaceResult, error) {
+	raw := strings.TrimSpace(opt.Path)
+	if raw == "" {
+		return WorkspaceResult{}, &WorkspaceError{"PATH_REQUIRED", "Не указан путь рабочего пространства", "Укажите абсолютный путь, например /home/user/projects/app"}
+	}
+	resolved := resolveHomePath(raw)
+	if !filepath.IsAbs(resolved) {
+		return WorkspaceResult{}, &WorkspaceError{"PATH_NOT_ABSOLUTE", fmt.Sprintf("Путь %s не абсолютный", raw), "Укажите путь от корня (/...) или от домашнего каталога (~/...)"}
+	}
+	path, err := validatePath(resolved)
+	if err != nil {
+		return WorkspaceResult{}, &WorkspaceError{"PATH_FORBIDDEN", err.Error(), "Выберите каталог вне системных директорий"}
+	}
+	if path == "/" {
+		return WorkspaceResult{}, &WorkspaceError{"PATH_FORBIDDEN", "Корень файловой системы нельзя сделать рабочим пространством", "Выберите отдельный каталог проекта"}
+	}
+	res := WorkspaceResult{Path: path}
+
+	info, err := os.Stat(path)
+	missing := errors.Is(err, os.ErrNotExist)
+	switch {
+	case missing && !opt.Create:
+		return res, &WorkspaceError{"PATH_NOT_FOUND", fmt.Sprintf("Каталог %s не существует", path), "Создайте каталог или включите create_dir"}
+	case missing:
+	case err != nil:
+		return res, &WorkspaceError{"PATH_NOT_ACCESSIBLE", fmt.Sprintf("Нет доступа к %s: %v", path, err), "Проверьте права tools-service на каталог"}
+	case !info.IsDir():
+		return res, &WorkspaceError{"NOT_A_DIRECTORY", fmt.Sprintf("%s — файл, а не каталог", path), "Укажите каталог"}
+	}
+	if _, statErr := os.Stat(filepath.Join(path, ".git")); statErr == nil {
+		res.GitRepo = true
+	}
+	if opt.Git && !res.GitRepo {
+		if _, err := exec.LookPath("git"); err != nil {
+			return res, &WorkspaceError{"GIT_UNAVAILABLE", "git не установлен на хосте tools-service", "Установите git или создайте пространство без git_init"}
+		}
+	}
+
+	if missing {
+		if err := os.MkdirAll(path, 0o755); err != nil {
+			return res, &WorkspaceError{"PATH_NOT_WRITABLE", fmt.Sprintf("Не удалось создать каталог %s: %v", path, err), "Проверьте права на родительский каталог"}
+		}
+		res.Created = true
+	}
+	probe, err := os.CreateTemp(path, ".write-check-*")
+	if err != nil {
+		return res, &WorkspaceError{"PATH_NOT_WRITABLE", fmt.Sprintf("Каталог %s недоступен для записи: %v", path, err), "Выдайте пользователю tools-service права на запись (chown/chmod)"}
+	}
+	probe.Close()
+	os.Remove(probe.Name())
+	res.Writable = true
+
+	if opt.Git && !res.GitRepo {
+		out, err := exec.CommandContext(ctx, "git", "init", "-q", path).CombinedOutput()
+		if err != nil {
+			return res, &WorkspaceError{"GIT_INIT_FAILED", fmt.Sprintf("git init: %v: %s", err, strings.TrimSpace(string(out))), "Проверьте каталог и повторите"}
+		}
+		res.GitRepo, res.GitInitialized = true, true
+	}
+
+	if opt.Manifest {
+		res.Manifest = filepath.Join(path, WorkspaceManifest)
+		written, err := writeManifest(res.Manifest, opt.Name)
+		if err != nil {
+			return res, &WorkspaceError{"MANIFEST_FAILED", fmt.Sprintf("Не удалось записать %s: %v", WorkspaceManifest, err), "Проверьте права на каталог"}
+		}
+		res.ManifestWritten = written
+	}
+	return res, nil
+}
+
+// writeManifest — описание пространства; существующий манифест не
+// перезаписывается (false без ошибки).
+func writeManifest(path, name string) (bool, error) {
+	data, err := json.MarshalIndent(map[string]interface{}{
+		"name":       name,
+		"created_at": time.Now().UTC().Format(time.RFC3339),
+		"created_by": "agent-regart",
+		"version":    1,
+	}, "", "  ")
+	if err != nil {
+		return false, err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if errors.Is(err, os.ErrExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	_, err = f.Write(append(data, '\n'))
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err == nil, err
+}
@@ -0,0 +1,121 @@
+package lint
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// LineRange — диапазон строк [Start, End] включительно.
+type LineRange struct {
+	Start int `json:"start"`
+	End   int `json:"end"`
+}
+
+func inRanges(line int, ranges []LineRange) bool {
+	for _, r := range ranges {
+		if line >= r.Start && line <= r.End {
+			return true
+		}
+	}
+	return false
+}
+
+// hunk — изменение из unified diff: первая изменённая строка исходного
+// файла и первая строка в новом файле, количество строк в новом файле.
+type hunk struct {
+	OldLine  int // Первая удалённая/изменённая строка исходного файла
+	NewStart int // Начало фрагмента в новом файле
+	NewLines int // Длина фрагмента в новом файле
+}
+
+var hunkHeader = regexp.MustCompile(`^@@ -(\d+)(?:,(\d+))? \+(\d+)(?:,(\d+))? @@`)
+
+// parseDiff — изменения по файлам из unified diff (git diff, gofmt -d, black --diff).
+// Файл берётся из строки «+++»: префикс b/ от git и метка времени отбрасываются.
+func parseDiff(diff string) map[string][]hunk {
+	out := map[string][]hunk{}
+	file := ""
+	var cur *hunk
+	oldLine := 0
+	for _, line := range strings.Split(diff, "\n") {
+		switch {
+		case strings.HasPrefix(line, "+++ "):
+			file = diffPath(line[4:])
+			cur = nil
+		case strings.HasPrefix(line, "--- "):
+			cur = nil
+		case strings.HasPrefix(line, "@@"):
+			m := hunkHeader.FindStringSubmatch(line)
+			if m == nil || file == "" {
+				cur = nil
+				continue
+			}
+			oldStart, _ := strconv.Atoi(m[1])
+			newStart, _ := strconv.Atoi(m[3])
+			newLines := 1
+			if m[4] != "" {
+				newLines, _ = strconv.Atoi(m[4])
+			}
+			out[file] = append(out[file], hunk{NewStart: newStart, NewLines: newLines})
+			cur = &out[file][len(out[file])-1]
+			oldLine = oldStart
+		case cur != nil && (strings.HasPrefix(line, "-") || strings.HasPrefix(line, "+")):
+			if cur.OldLine == 0 {
+				cur.OldLine = oldLine
+			}
+			if line[0] == '-' {
+				oldLine++
+			}
+		case cur != nil && strings.HasPrefix(line, " "):
+			oldLine++
+		}
+	}
+	return out
+}
+
+// diffPath — путь из заголовка «+++ b/path<TAB>метка времени».
+func diffPath(s string) string {
+	if i := strings.IndexByte(s, '\t'); i >= 0 {
+		s = s[:i]
+	}
+	s = strings.TrimSpace(s)
+	if s == "/dev/null" {
+		return ""
+	}
+	return strings.TrimPrefix(s, "b/")
+}
+
+// ChangedLines — изменённые строки новых версий файлов из unified diff.
+// Удалённые файлы (+++ /dev/null) не возвращаются.
+func ChangedLines(diff string) map[string][]LineRange {
+	out := map[string][]LineRange{}
+	for file, hunks := range parseDiff(diff) {
+		for _, h := range hunks {
+			if h.NewLines == 0 {
+				continue
+			}
+			out[file] = append(out[file], LineRange{Start: h.NewStart, End: h.NewStart + h.NewLines - 1})
+		}
+	}
+	return out
+}
+
+// formatFindings — замечания форматтера: по одному на фрагмент diff,
+// строка — первая изменённая строка исходного файла.
+func formatFindings(tool, file, diff string) []Finding {
+	var out []Finding
+	for _, hunks := range parseDiff(diff) {
+		for _, h := range hunks {
+			line := h.OldLine
+			if line == 0 {
+				line = h.NewStart
+			}
+			out = append(out, Finding{
+				Tool: tool, File: file, Line: line, Severity: SeverityWarning, Rule: "format",
+				Message: "форматирование не соответствует " + tool,
+			})
+		}
+	}
+	return out
+}
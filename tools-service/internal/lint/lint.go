@@ -0,0 +1,306 @@
+// Package lint — запуск линтеров и форматтеров (gofmt, golangci-lint, eslint,
+// black) и приведение их вывода к единому машиночитаемому формату.
+//
+// Каждый линтер — внешняя утилита; если она не установлена, линтер
+// пропускается с пояснением, а не считается ошибкой. Линтер выбирается по
+// расширению файла. Для проверки правки (diff) замечания фильтруются по
+// изменённым строкам, чтобы агент видел только то, что внёс сам.
+package lint
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/neo-2022/openclaw-memory/tools-service/internal/executor"
+)
+
+// Уровни серьёзности замечаний.
+const (
+	SeverityError   = "error"
+	SeverityWarning = "warning"
+	SeverityInfo    = "info"
+)
+
+// Finding — замечание линтера или форматтера.
+type Finding struct {
+	Tool     string `json:"tool"`             // gofmt, golangci-lint, eslint, black
+	File     string `json:"file"`             // Путь к файлу
+	Line     int    `json:"line"`             // Строка (0 — замечание ко всему файлу)
+	Column   int    `json:"column,omitempty"` // Колонка
+	Severity string `json:"severity"`         // error, warning, info
+	Rule     string `json:"rule,omitempty"`   // Правило (errcheck, no-unused-vars, format, ...)
+	Message  string `json:"message"`          // Текст замечания
+}
+
+// Linter — внешняя утилита проверки и (опционально) форматирования.
+type Linter struct {
+	Name   string   // Имя (совпадает с LINTERS)
+	Binary string   // Исполняемый файл
+	Exts   []string // Расширения файлов
+	// Check — замечания по файлам (пути абсолютные).
+	Check func(ctx context.Context, bin string, files []string) ([]Finding, error)
+	// Format — отформатированное содержимое файла; nil — линтер не форматирует.
+	Format func(ctx context.Context, bin, file string, src []byte) ([]byte, error)
+}
+
+// registry — известные линтеры в порядке запуска.
+var registry = []Linter{
+	{Name: "gofmt", Binary: "gofmt", Exts: []string{".go"}, Check: checkGofmt, Format: formatGofmt},
+	{Name: "golangci-lint", Binary: "golangci-lint", Exts: []string{".go"}, Check: checkGolangci},
+	{Name: "eslint", Binary: "eslint", Exts: []string{".js", ".jsx", ".mjs", ".cjs", ".ts", ".tsx"}, Check: checkESLint, Format: formatESLint},
+	{Name: "black", Binary: "black", Exts: []string{".py"}, Check: checkBlack, Format: formatBlack},
+}
+
+// Names — имена всех известных линтеров.
+func Names() []string {
+	names := make([]string, len(registry))
+	for i, l := range registry {
+		names[i] = l.Name
+	}
+	return names
+}
+
+// Config — настройки запуска.
+type Config struct {
+	Enabled map[string]bool // Разрешённые линтеры (config.Linters); пусто — все
+	Timeout time.Duration   // Таймаут одного запуска утилиты (config.LintTimeout)
+}
+
+// NewConfig — настройки линтеров: разрешённые имена (пусто — все) и таймаут.
+func NewConfig(linters []string, timeout time.Duration) Config {
+	cfg := Config{Enabled: map[string]bool{}, Timeout: timeout}
+	for _, n := range linters {
+		cfg.Enabled[n] = true
+	}
+	return cfg
+}
+
+// Report — результат проверки.
+type Report struct {
+	Findings []Finding         `json:"findings"`          // Замечания по файлу и строке
+	Ran      []string          `json:"ran"`               // Запущенные линтеры
+	Skipped  map[string]string `json:"skipped,omitempty"` // Пропущенные линтеры и причина
+	Files    int               `json:"files"`             // Проверено файлов
+}
+
+// Runner — запускает линтеры с заданными настройками.
+type Runner struct {
+	cfg      Config
+	lookPath func(string) (string, error)
+}
+
+// NewRunner — линтеры с настройками cfg.
+func NewRunner(cfg Config) *Runner {
+	return &Runner{cfg: cfg, lookPath: exec.LookPath}
+}
+
+// Request — что проверить: файлы и/или unified diff.
+type Request struct {
+	Paths   []string // Файлы (абсолютные пути)
+	Diff    string   // Unified diff; файлы из него добавляются к Paths, замечания — только по изменённым строкам
+	Root    string   // Корень для относительных путей diff
+	Linters []string // Ограничить набором линтеров (пусто — все подходящие)
+}
+
+// ErrPathForbidden — путь из diff выходит за root или запрещён политикой
+// безопасности файловых операций.
+var ErrPathForbidden = errors.New("путь запрещён")
+
+// Run — запускает подходящие линтеры.
+func (r *Runner) Run(ctx context.Context, req Request) (Report, error) {
+	changed := map[string][]LineRange{}
+	files := append([]string(nil), req.Paths...)
+	if strings.TrimSpace(req.Diff) != "" {
+		for file, ranges := range ChangedLines(req.Diff) {
+			abs, err := changedFile(req.Root, file)
+			if err != nil {
+				return Report{}, err
+			}
+			changed[abs] = ranges
+			files = append(files, abs)
+		}
+	}
+	files = dedup(files)
+	if len(files) == 0 {
+		return Report{}, errors.New("не заданы файлы для проверки")
+	}
+	only := map[string]bool{}
+	for _, n := range req.Linters {
+		only[n] = true
+	}
+
+	rep := Report{Findings: []Finding{}, Skipped: map[string]string{}, Files: len(files)}
+	for _, l := range registry {
+		if len(only) > 0 && !only[l.Name] {
+			continue
+		}
+		targets := filterExt(files, l.Exts)
+		if len(targets) == 0 {
+			continue
+		}
+		if len(r.cfg.Enabled) > 0 && !r.cfg.Enabled[l.Name] {
+			rep.Skipped[l.Name] = "отключён в LINTERS"
+			continue
+		}
+		bin, err := r.lookPath(l.Binary)
+		if err != nil {
+			rep.Skipped[l.Name] = l.Binary + " не установлен"
+			continue
+		}
+		runCtx, cancel := context.WithTimeout(ctx, r.cfg.Timeout)
+		findings, err := l.Check(runCtx, bin, targets)
+		cancel()
+		if err != nil {
+			rep.Skipped[l.Name] = err.Error()
+			continue
+		}
+		rep.Ran = append(rep.Ran, l.Name)
+		rep.Findings = append(rep.Findings, onlyChanged(findings, changed)...)
+	}
+	sort.SliceStable(rep.Findings, func(i, j int) bool {
+		a, b := rep.Findings[i], rep.Findings[j]
+		if a.File != b.File {
+			return a.File < b.File
+		}
+		return a.Line < b.Line
+	})
+	return rep, nil
+}
+
+// changedFile — абсолютный путь файла из diff. Относительный путь берётся от
+// root и не может выходить за него; итог проверяется по тем же правилам, что
+// пути из запроса.
+func changedFile(root, file string) (string, error) {
+	abs := file
+	if !filepath.IsAbs(abs) {
+		if root == "" {
+			return "", errors.New("для относительных путей diff укажите root")
+		}
+		abs = filepath.Join(root, file)
+		if rel, err := filepath.Rel(root, abs); err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return "", fmt.Errorf("%w: %s выходит за root", ErrPathForbidden, file)
+		}
+	}
+	clean, err := executor.ValidatePath(abs)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrPathForbidden, err)
+	}
+	return clean, nil
+}
+
+// ErrNoFormatter — для файла нет доступного форматтера.
+var ErrNoFormatter = errors.New("нет форматтера для файла")
+
+// Format — форматирует файл первым подходящим форматтером; при write=true
+// записывает результат. Возвращает имя форматтера, новое содержимое и
+// признак изменения.
+func (r *Runner) Format(ctx context.Context, file string, write bool) (string, []byte, bool, error) {
+	src, err := os.ReadFile(file)
+	if err != nil {
+		return "", nil, false, err
+	}
+	for _, l := range registry {
+		if l.Format == nil || len(filterExt([]string{file}, l.Exts)) == 0 {
+			continue
+		}
+		if len(r.cfg.Enabled) > 0 && !r.cfg.Enabled[l.Name] {
+			continue
+		}
+		bin, err := r.lookPath(l.Binary)
+		if err != nil {
+			continue
+		}
+		runCtx, cancel := context.WithTimeout(ctx, r.cfg.Timeout)
+		out, err := l.Format(runCtx, bin, file, src)
+		cancel()
+		if err != nil {
+			return l.Name, nil, false, err
+		}
+		changed := !bytes.Equal(out, src)
+		if changed && write {
+			info, statErr := os.Stat(file)
+			if statErr != nil {
+				return l.Name, nil, false, statErr
+			}
+			if err := os.WriteFile(file, out, info.Mode().Perm()); err != nil {
+				return l.Name, nil, false, err
+			}
+		}
+		return l.Name, out, changed, nil
+	}
+	return "", nil, false, fmt.Errorf("%w: %s", ErrNoFormatter, filepath.Base(file))
+}
+
+// run — запуск утилиты; ненулевой код возврата не ошибка (линтеры так
+// сообщают о замечаниях), ошибка — только если процесс не запустился или
+// прерван по таймауту.
+func run(ctx context.Context, dir string, stdin []byte, name string, args ...string) (stdout, stderr []byte, code int, err error) {
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Dir = dir
+	if stdin != nil {
+		cmd.Stdin = bytes.NewReader(stdin)
+	}
+	var out, errOut bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &out, &errOut
+	err = cmd.Run()
+	if ctx.Err() != nil {
+		return nil, nil, -1, fmt.Errorf("%s: превышен таймаут", filepath.Base(name))
+	}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return out.Bytes(), errOut.Bytes(), exitErr.ExitCode(), nil
+	}
+	return out.Bytes(), errOut.Bytes(), 0, err
+}
+
+// filterExt — файлы с одним из расширений.
+func filterExt(files, exts []string) []string {
+	var out []string
+	for _, f := range files {
+		ext := strings.ToLower(filepath.Ext(f))
+		for _, e := range exts {
+			if ext == e {
+				out = append(out, f)
+				break
+			}
+		}
+	}
+	return out
+}
+
+func dedup(files []string) []string {
+	seen := map[string]bool{}
+	out := files[:0]
+	for _, f := range files {
+		f = filepath.Clean(f)
+		if !seen[f] {
+			seen[f] = true
+			out = append(out, f)
+		}
+	}
+	return out
+}
+
+// onlyChanged — замечания по изменённым строкам; для файлов не из diff и
+// замечаний ко всему файлу (Line == 0) фильтр не применяется.
+func onlyChanged(findings []Finding, changed map[string][]LineRange) []Finding {
+	if len(changed) == 0 {
+		return findings
+	}
+	out := findings[:0]
+	for _, f := range findings {
+		ranges, ok := changed[filepath.Clean(f.File)]
+		if !ok || f.Line == 0 || inRanges(f.Line, ranges) {
+			out = append(out, f)
+		}
+	}
+	return out
+}
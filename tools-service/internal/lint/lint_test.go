@@ -0,0 +1,112 @@
+package lint
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const sampleDiff = `diff --git a/app/main.go b/app/main.go
+--- a/app/main.go
++++ b/app/main.go
+@@ -10,3 +10,4 @@ func main() {
+ 	a := 1
+-	b := 2
++	b := 3
++	c := 4
+ }
+--- a/old.py
++++ /dev/null
+@@ -1,2 +0,0 @@
+-x = 1
+-y = 2
+`
+
+// TestChangedLines — диапазоны новых строк по файлам, удалённые файлы пропускаются.
+func TestChangedLines(t *testing.T) {
+	got := ChangedLines(sampleDiff)
+	if len(got) != 1 {
+		t.Fatalf("ожидался один файл, получено %+v", got)
+	}
+	r := got["app/main.go"]
+	if len(r) != 1 || r[0] != (LineRange{Start: 10, End: 13}) {
+		t.Errorf("неожиданные диапазоны: %+v", r)
+	}
+	hunks := parseDiff(sampleDiff)["app/main.go"]
+	if len(hunks) != 1 || hunks[0].OldLine != 11 {
+		t.Errorf("первая изменённая строка должна быть 11: %+v", hunks)
+	}
+}
+
+// TestRun_SkipsMissingAndFiltersDiff — отсутствующий линтер пропускается,
+// замечания по неизменённым строкам отбрасываются.
+func TestRun_SkipsMissingAndFiltersDiff(t *testing.T) {
+	r := NewRunner(Config{Timeout: 10_000_000_000})
+	r.lookPath = func(string) (string, error) { return "", errors.New("not found") }
+	rep, err := r.Run(context.Background(), Request{Paths: []string{"/src/a.py", "/src/b.ts"}})
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if len(rep.Ran) != 0 || rep.Skipped["black"] == "" || rep.Skipped["eslint"] == "" || rep.Skipped["gofmt"] != "" {
+		t.Errorf("неожиданный отчёт: %+v", rep)
+	}
+	if _, err := r.Run(context.Background(), Request{Diff: sampleDiff}); err == nil {
+		t.Error("для относительных путей diff без root ожидалась ошибка")
+	}
+	escape := "--- a/../../etc/x.go\n+++ b/../../etc/x.go\n@@ -1 +1 @@\n-a\n+b\n"
+	if _, err := r.Run(context.Background(), Request{Diff: escape, Root: "/r/app"}); !errors.Is(err, ErrPathForbidden) {
+		t.Errorf("путь diff за пределами root должен быть отклонён: %v", err)
+	}
+	forbidden := "--- /etc/shadow\n+++ /etc/shadow\n@@ -1 +1 @@\n-a\n+b\n"
+	if _, err := r.Run(context.Background(), Request{Diff: forbidden}); !errors.Is(err, ErrPathForbidden) {
+		t.Errorf("запрещённый путь diff должен быть отклонён: %v", err)
+	}
+
+	findings := []Finding{{File: "/r/app/main.go", Line: 5}, {File: "/r/app/main.go", Line: 12}, {File: "/r/app/main.go"}, {File: "/r/other.go", Line: 1}}
+	kept := onlyChanged(findings, map[string][]LineRange{"/r/app/main.go": {{Start: 10, End: 13}}})
+	if len(kept) != 3 || kept[0].Line != 12 {
+		t.Errorf("неожиданная фильтрация: %+v", kept)
+	}
+}
+
+// TestGofmt — проверка и форматирование реальным gofmt.
+func TestGofmt(t *testing.T) {
+	if _, err := exec.LookPath("gofmt"); err != nil {
+		t.Skip("gofmt не установлен")
+	}
+	file := filepath.Join(t.TempDir(), "main.go")
+	os.WriteFile(file, []byte("package main\n\nfunc main() {\nx:=1\n_ = x\n}\n"), 0o644)
+	r := NewRunner(Config{Enabled: map[string]bool{"gofmt": true}, Timeout: 10_000_000_000})
+
+	rep, err := r.Run(context.Background(), Request{Paths: []string{file}})
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if len(rep.Findings) != 1 || rep.Findings[0].Line != 4 || rep.Findings[0].Rule != "format" {
+		t.Fatalf("ожидалось замечание gofmt на строке 4: %+v", rep)
+	}
+	if rep.Skipped["golangci-lint"] != "отключён в LINTERS" {
+		t.Errorf("golangci-lint должен быть отключён: %+v", rep.Skipped)
+	}
+
+	tool, out, changed, err := r.Format(context.Background(), file, true)
+	if err != nil || tool != "gofmt" || !changed || !strings.Contains(string(out), "\tx := 1") {
+		t.Fatalf("Format: %s, %q, %v, %v", tool, out, changed, err)
+	}
+	if data, _ := os.ReadFile(file); string(data) != string(out) {
+		t.Error("файл не перезаписан")
+	}
+	if rep, _ := r.Run(context.Background(), Request{Paths: []string{file}}); len(rep.Findings) != 0 {
+		t.Errorf("после форматирования замечаний быть не должно: %+v", rep.Findings)
+	}
+
+	os.WriteFile(file, []byte("package main\nfunc {\n"), 0o644)
+	rep, _ = r.Run(context.Background(), Request{Paths: []string{file}})
+	if len(rep.Findings) == 0 || rep.Findings[0].Rule != "syntax" || rep.Findings[0].Line != 2 {
+		t.Errorf("ожидалась синтаксическая ошибка на строке 2: %+v", rep.Findings)
+	}
+}
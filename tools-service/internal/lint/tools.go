@@ -0,0 +1,213 @@
+package lint
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// checkGofmt — gofmt -d по каждому файлу: фрагмент diff — одно замечание.
+func checkGofmt(ctx context.Context, bin string, files []string) ([]Finding, error) {
+	var out []Finding
+	for _, f := range files {
+		stdout, stderr, code, err := run(ctx, "", nil, bin, "-d", f)
+		if err != nil {
+			return nil, err
+		}
+		// Код 1 — есть различия, 2 — синтаксическая ошибка (file.go:3:1: expected ...)
+		if code > 1 || len(stderr) > 0 {
+			out = append(out, parseColonFindings("gofmt", string(stderr))...)
+			continue
+		}
+		out = append(out, formatFindings("gofmt", f, string(stdout))...)
+	}
+	return out, nil
+}
+
+func formatGofmt(ctx context.Context, bin, file string, src []byte) ([]byte, error) {
+	stdout, stderr, code, err := run(ctx, "", src, bin)
+	if err != nil {
+		return nil, err
+	}
+	if code != 0 {
+		return nil, fmt.Errorf("gofmt: %s", strings.TrimSpace(strings.ReplaceAll(string(stderr), "<standard input>", filepath.Base(file))))
+	}
+	return stdout, nil
+}
+
+// parseColonFindings — строки вида file:line:col: message.
+func parseColonFindings(tool, text string) []Finding {
+	var out []Finding
+	for _, line := range strings.Split(text, "\n") {
+		parts := strings.SplitN(line, ":", 4)
+		if len(parts) < 4 {
+			continue
+		}
+		var ln, col int
+		if _, err := fmt.Sscan(parts[1], &ln); err != nil {
+			continue
+		}
+		fmt.Sscan(parts[2], &col)
+		out = append(out, Finding{Tool: tool, File: parts[0], Line: ln, Column: col, Severity: SeverityError, Rule: "syntax", Message: strings.TrimSpace(parts[3])})
+	}
+	return out
+}
+
+// golangciReport — фрагмент JSON-вывода golangci-lint.
+type golangciReport struct {
+	Issues []struct {
+		FromLinter string `json:"FromLinter"`
+		Text       string `json:"Text"`
+		Severity   string `json:"Severity"`
+		Pos        struct {
+			Filename string `json:"Filename"`
+			Line     int    `json:"Line"`
+			Column   int    `json:"Column"`
+		} `json:"Pos"`
+	} `json:"Issues"`
+}
+
+// checkGolangci — golangci-lint по пакетам (каталогам) файлов; замечания
+// к другим файлам пакета отбрасываются.
+func checkGolangci(ctx context.Context, bin string, files []string) ([]Finding, error) {
+	byDir := map[string]map[string]bool{}
+	for _, f := range files {
+		dir := filepath.Dir(f)
+		if byDir[dir] == nil {
+			byDir[dir] = map[string]bool{}
+		}
+		byDir[dir][filepath.Base(f)] = true
+	}
+	var out []Finding
+	for dir, names := range byDir {
+		stdout, stderr, code, err := run(ctx, dir, nil, bin, "run", "--out-format", "json", "--issues-exit-code", "1", ".")
+		if err != nil {
+			return nil, err
+		}
+		var rep golangciReport
+		if err := json.Unmarshal(stdout, &rep); err != nil {
+			if code != 0 {
+				return nil, fmt.Errorf("golangci-lint: %s", firstLine(string(stderr)))
+			}
+			return nil, fmt.Errorf("golangci-lint: разбор вывода: %w", err)
+		}
+		for _, is := range rep.Issues {
+			if !names[filepath.Base(is.Pos.Filename)] {
+				continue
+			}
+			sev := is.Severity
+			if sev == "" {
+				sev = SeverityWarning
+			}
+			out = append(out, Finding{
+				Tool: "golangci-lint", File: filepath.Join(dir, filepath.Base(is.Pos.Filename)),
+				Line: is.Pos.Line, Column: is.Pos.Column, Severity: sev, Rule: is.FromLinter, Message: is.Text,
+			})
+		}
+	}
+	return out, nil
+}
+
+// eslintResult — элемент JSON-вывода eslint (-f json).
+type eslintResult struct {
+	FilePath string  `json:"filePath"`
+	Output   *string `json:"output"` // Есть при --fix-dry-run, если исправления применимы
+	Messages []struct {
+		RuleID   string `json:"ruleId"`
+		Severity int    `json:"severity"` // 1 — warning, 2 — error
+		Message  string `json:"message"`
+		Line     int    `json:"line"`
+		Column   int    `json:"column"`
+	} `json:"messages"`
+}
+
+// checkESLint — eslint -f json; запускается из каталога первого файла,
+// чтобы подхватить конфигурацию проекта.
+func checkESLint(ctx context.Context, bin string, files []string) ([]Finding, error) {
+	args := append([]string{"-f", "json", "--no-error-on-unmatched-pattern"}, files...)
+	stdout, stderr, code, err := run(ctx, filepath.Dir(files[0]), nil, bin, args...)
+	if err != nil {
+		return nil, err
+	}
+	var results []eslintResult
+	if err := json.Unmarshal(stdout, &results); err != nil {
+		if code != 0 {
+			return nil, fmt.Errorf("eslint: %s", firstLine(string(stderr)))
+		}
+		return nil, fmt.Errorf("eslint: разбор вывода: %w", err)
+	}
+	var out []Finding
+	for _, res := range results {
+		for _, m := range res.Messages {
+			sev := SeverityWarning
+			if m.Severity == 2 {
+				sev = SeverityError
+			}
+			out = append(out, Finding{Tool: "eslint", File: res.FilePath, Line: m.Line, Column: m.Column, Severity: sev, Rule: m.RuleID, Message: m.Message})
+		}
+	}
+	return out, nil
+}
+
+// formatESLint — автоисправления eslint (--fix-dry-run) без записи на диск.
+func formatESLint(ctx context.Context, bin, file string, src []byte) ([]byte, error) {
+	stdout, stderr, code, err := run(ctx, filepath.Dir(file), nil, bin, "-f", "json", "--fix-dry-run", file)
+	if err != nil {
+		return nil, err
+	}
+	var results []eslintResult
+	if err := json.Unmarshal(stdout, &results); err != nil {
+		if code != 0 {
+			return nil, fmt.Errorf("eslint: %s", firstLine(string(stderr)))
+		}
+		return nil, fmt.Errorf("eslint: разбор вывода: %w", err)
+	}
+	if len(results) > 0 && results[0].Output != nil {
+		return []byte(*results[0].Output), nil
+	}
+	return src, nil
+}
+
+// checkBlack — black --check --diff: код 1 — есть что переформатировать,
+// 123 — синтаксическая ошибка.
+func checkBlack(ctx context.Context, bin string, files []string) ([]Finding, error) {
+	var out []Finding
+	for _, f := range files {
+		stdout, stderr, code, err := run(ctx, filepath.Dir(f), nil, bin, "--check", "--diff", "-q", f)
+		if err != nil {
+			return nil, err
+		}
+		switch code {
+		case 0:
+		case 1:
+			out = append(out, formatFindings("black", f, string(stdout))...)
+		default:
+			out = append(out, Finding{Tool: "black", File: f, Severity: SeverityError, Rule: "syntax", Message: firstLine(string(stderr))})
+		}
+	}
+	return out, nil
+}
+
+func formatBlack(ctx context.Context, bin, file string, src []byte) ([]byte, error) {
+	stdout, stderr, code, err := run(ctx, filepath.Dir(file), src, bin, "-q", "--stdin-filename", file, "-")
+	if err != nil {
+		return nil, err
+	}
+	if code != 0 {
+		return nil, fmt.Errorf("black: %s", firstLine(string(stderr)))
+	}
+	return stdout, nil
+}
+
+func firstLine(s string) string {
+	s = strings.TrimSpace(s)
+	if i := strings.IndexByte(s, '\n'); i >= 0 {
+		s = s[:i]
+	}
+	if s == "" {
+		return "утилита завершилась с ошибкой"
+	}
+	return s
+}
@@ -0,0 +1,58 @@
+package logger
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestShipHandler_ForwardsWarnAndError — warn/error уходят в /logs с correlation_id,
+// info остаётся только в локальном выводе.
+func TestShipHandler_ForwardsWarnAndError(t *testing.T) {
+	var mu sync.Mutex
+	var got []ShipEntry
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var batch []ShipEntry
+		if err := json.NewDecoder(r.Body).Decode(&batch); err != nil {
+			t.Errorf("тело не является массивом записей: %v", err)
+		}
+		mu.Lock()
+		got = append(got, batch...)
+		mu.Unlock()
+	}))
+	defer srv.Close()
+
+	s := NewShipper(srv.URL, "tools-service")
+	log := slog.New(&shipHandler{Handler: slog.NewJSONHandler(&discard{}, nil), shipper: s}).
+		With(slog.String("сервис", "tools-service"))
+	ctx := WithCorrelationID(context.Background(), "req-1")
+
+	log.Info("обычное событие")
+	log.With(slog.String("correlation_id", "req-1")).Warn("команда заблокирована", slog.String("команда", "rm -rf /"))
+	log.ErrorContext(ctx, "ошибка чтения файла", slog.String("ошибка", "нет доступа"))
+
+	closeCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	s.Close(closeCtx)
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(got) != 2 {
+		t.Fatalf("ожидалось 2 записи, получено %d: %+v", len(got), got)
+	}
+	if got[0].Level != "warn" || got[0].CorrelationID != "req-1" || got[0].Service != "tools-service" {
+		t.Errorf("неверная запись warn: %+v", got[0])
+	}
+	if got[1].Level != "error" || got[1].CorrelationID != "req-1" || got[1].Details != `{"ошибка":"нет доступа"}` {
+		t.Errorf("неверная запись error: %+v", got[1])
+	}
+}
+
+type discard struct{}
+
+func (discard) Write(p []byte) (int, error) { return len(p), nil }
@@ -0,0 +1,220 @@
+package mailbox
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// imapConn — минимальный IMAP4rev1-клиент (RFC 3501): LOGIN, SELECT/EXAMINE,
+// UID SEARCH, UID FETCH, LOGOUT. Достаточно для чтения ящика без внешних
+// зависимостей.
+type imapConn struct {
+	conn net.Conn
+	r    *bufio.Reader
+	tag  int
+}
+
+// imapResponse — непомеченный ответ сервера: строка и литералы {n} по порядку.
+type imapResponse struct {
+	Line     string   // Строка ответа; литералы заменены на «{n}»
+	Literals [][]byte // Содержимое литералов
+}
+
+// dialIMAP — подключение (TLS или открытый текст) и приветствие сервера.
+func dialIMAP(ctx context.Context, cfg IMAPConfig) (*imapConn, error) {
+	addr := net.JoinHostPort(cfg.Host, strconv.Itoa(cfg.Port))
+	d := &net.Dialer{Timeout: cfg.Timeout}
+	var conn net.Conn
+	var err error
+	if cfg.TLS {
+		conn, err = (&tls.Dialer{NetDialer: d, Config: &tls.Config{ServerName: cfg.Host}}).DialContext(ctx, "tcp", addr)
+	} else {
+		conn, err = d.DialContext(ctx, "tcp", addr)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("IMAP: подключение к %s: %w", addr, err)
+	}
+	if dl, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(dl)
+	} else {
+		conn.SetDeadline(time.Now().Add(cfg.Timeout))
+	}
+	c := &imapConn{conn: conn, r: bufio.NewReader(conn)}
+	greeting, err := c.r.ReadString('\n')
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("IMAP: приветствие: %w", err)
+	}
+	if !strings.HasPrefix(greeting, "* OK") && !strings.HasPrefix(greeting, "* PREAUTH") {
+		conn.Close()
+		return nil, fmt.Errorf("IMAP: сервер отказал: %s", strings.TrimSpace(greeting))
+	}
+	return c, nil
+}
+
+func (c *imapConn) Close() error {
+	c.cmd("LOGOUT")
+	return c.conn.Close()
+}
+
+// cmd — команда и непомеченные ответы до завершающего «tag OK».
+func (c *imapConn) cmd(format string, args ...interface{}) ([]imapResponse, error) {
+	c.tag++
+	tag := fmt.Sprintf("A%03d", c.tag)
+	if _, err := fmt.Fprintf(c.conn, "%s %s\r\n", tag, fmt.Sprintf(format, args...)); err != nil {
+		return nil, err
+	}
+	var out []imapResponse
+	for {
+		resp, err := c.readResponse()
+		if err != nil {
+			return nil, err
+		}
+		if strings.HasPrefix(resp.Line, tag+" ") {
+			status := strings.TrimPrefix(resp.Line, tag+" ")
+			if !strings.HasPrefix(status, "OK") {
+				return out, fmt.Errorf("IMAP: %s", status)
+			}
+			return out, nil
+		}
+		if strings.HasPrefix(resp.Line, "* ") {
+			out = append(out, resp)
+		}
+	}
+}
+
+// readResponse — строка ответа с литералами: «... {123}\r\n<123 байта>...».
+func (c *imapConn) readResponse() (imapResponse, error) {
+	var resp imapResponse
+	var line strings.Builder
+	for {
+		part, err := c.r.ReadString('\n')
+		if err != nil {
+			return resp, err
+		}
+		part = strings.TrimRight(part, "\r\n")
+		n, ok := literalSize(part)
+		if !ok {
+			line.WriteString(part)
+			resp.Line = line.String()
+			return resp, nil
+		}
+		line.WriteString(part)
+		lit := make([]byte, n)
+		if _, err := io.ReadFull(c.r, lit); err != nil {
+			return resp, err
+		}
+		resp.Literals = append(resp.Literals, lit)
+	}
+}
+
+// literalSize — размер литерала, если строка заканчивается на {n}.
+func literalSize(line string) (int, bool) {
+	if !strings.HasSuffix(line, "}") {
+		return 0, false
+	}
+	i := strings.LastIndexByte(line, '{')
+	if i < 0 {
+		return 0, false
+	}
+	n, err := strconv.Atoi(line[i+1 : len(line)-1])
+	return n, err == nil
+}
+
+// quote — строка IMAP в кавычках.
+func quote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+func (c *imapConn) login(user, password string) error {
+	_, err := c.cmd("LOGIN %s %s", quote(user), quote(password))
+	if err != nil {
+		return errors.New("IMAP: ошибка входа — проверьте IMAP_USER и IMAP_PASSWORD")
+	}
+	return nil
+}
+
+// examine — открывает ящик только для чтения (флаги \Seen не меняются).
+func (c *imapConn) examine(mailbox string) error {
+	_, err := c.cmd("EXAMINE %s", quote(mailbox))
+	return err
+}
+
+// search — UID писем по критериям IMAP SEARCH.
+func (c *imapConn) search(criteria string) ([]uint32, error) {
+	resps, err := c.cmd("UID SEARCH CHARSET UTF-8 %s", criteria)
+	if err != nil {
+		// Не все серверы принимают CHARSET — повтор без него (для ASCII-критериев)
+		if resps, err = c.cmd("UID SEARCH %s", criteria); err != nil {
+			return nil, err
+		}
+	}
+	var uids []uint32
+	for _, r := range resps {
+		if !strings.HasPrefix(r.Line, "* SEARCH") {
+			continue
+		}
+		for _, f := range strings.Fields(strings.TrimPrefix(r.Line, "* SEARCH")) {
+			if n, err := strconv.ParseUint(f, 10, 32); err == nil {
+				uids = append(uids, uint32(n))
+			}
+		}
+	}
+	return uids, nil
+}
+
+// fetch — UID FETCH: для каждого письма — первый литерал ответа и флаги.
+func (c *imapConn) fetch(uids []uint32, item string) (map[uint32]fetched, error) {
+	set := make([]string, len(uids))
+	for i, u := range uids {
+		set[i] = strconv.FormatUint(uint64(u), 10)
+	}
+	resps, err := c.cmd("UID FETCH %s (UID FLAGS RFC822.SIZE %s)", strings.Join(set, ","), item)
+	if err != nil {
+		return nil, err
+	}
+	out := map[uint32]fetched{}
+	for _, r := range resps {
+		if !strings.Contains(r.Line, " FETCH (") {
+			continue
+		}
+		f := fetched{Seen: strings.Contains(r.Line, `\Seen`), Size: atoiAfter(r.Line, "RFC822.SIZE ")}
+		uid := uint32(atoiAfter(r.Line, "UID "))
+		if len(r.Literals) > 0 {
+			f.Data = r.Literals[0]
+		}
+		if uid != 0 {
+			out[uid] = f
+		}
+	}
+	return out, nil
+}
+
+// fetched — данные письма из FETCH.
+type fetched struct {
+	Data []byte
+	Seen bool
+	Size int
+}
+
+func atoiAfter(s, key string) int {
+	i := strings.Index(s, key)
+	if i < 0 {
+		return 0
+	}
+	s = s[i+len(key):]
+	end := strings.IndexFunc(s, func(r rune) bool { return r < '0' || r > '9' })
+	if end >= 0 {
+		s = s[:end]
+	}
+	n, _ := strconv.Atoi(s)
+	return n
+}
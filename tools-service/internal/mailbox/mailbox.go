@@ -0,0 +1,237 @@
+// Package mailbox — почта для агента: чтение ящика по IMAP (список, поиск,
+// текст письма) и отправка по SMTP.
+//
+// Ящик открывается командой EXAMINE (только чтение), поэтому просмотр писем
+// агентом не снимает с них отметку «непрочитано». Отправка ограничивается
+// списком разрешённых адресатов MAIL_ALLOWED_TO, если он задан.
+package mailbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// IMAPConfig — входящая почта.
+type IMAPConfig struct {
+	Host     string        // IMAP_HOST
+	Port     int           // IMAP_PORT (993)
+	User     string        // IMAP_USER
+	Password string        // IMAP_PASSWORD
+	TLS      bool          // IMAP_TLS (true): неявный TLS; false — открытый текст (локальные серверы)
+	Mailbox  string        // IMAP_MAILBOX (INBOX)
+	Timeout  time.Duration // Таймаут сеанса
+}
+
+// SMTPConfig — исходящая почта.
+type SMTPConfig struct {
+	Host     string   // SMTP_HOST
+	Port     int      // SMTP_PORT (587)
+	User     string   // SMTP_USER (по умолчанию IMAP_USER)
+	Password string   // SMTP_PASSWORD (по умолчанию IMAP_PASSWORD)
+	From     string   // SMTP_FROM (по умолчанию SMTP_USER)
+	Security string   // SMTP_SECURITY: starttls (по умолчанию), tls, none
+	Allowed  []string // MAIL_ALLOWED_TO: адреса или домены (@example.com); пусто — любые
+	Timeout  time.Duration
+}
+
+// Config — настройки почты.
+type Config struct {
+	IMAP    IMAPConfig
+	SMTP    SMTPConfig
+	MaxText int // MAIL_MAX_TEXT: предел текста письма в символах
+}
+
+// DefaultConfig — настройки из переменных окружения.
+func DefaultConfig() Config {
+	cfg := Config{
+		IMAP: IMAPConfig{
+			Host:     os.Getenv("IMAP_HOST"),
+			Port:     envInt("IMAP_PORT", 993),
+			User:     os.Getenv("IMAP_USER"),
+			Password: os.Getenv("IMAP_PASSWORD"),
+			TLS:      os.Getenv("IMAP_TLS") != "false",
+			Mailbox:  envStr("IMAP_MAILBOX", "INBOX"),
+			Timeout:  30 * time.Second,
+		},
+		SMTP: SMTPConfig{
+			Host:     os.Getenv("SMTP_HOST"),
+			Port:     envInt("SMTP_PORT", 587),
+			User:     envStr("SMTP_USER", os.Getenv("IMAP_USER")),
+			Password: envStr("SMTP_PASSWORD", os.Getenv("IMAP_PASSWORD")),
+			Security: envStr("SMTP_SECURITY", "starttls"),
+			Timeout:  30 * time.Second,
+		},
+		MaxText: envInt("MAIL_MAX_TEXT", 20000),
+	}
+	cfg.SMTP.From = envStr("SMTP_FROM", cfg.SMTP.User)
+	for _, a := range strings.Split(os.Getenv("MAIL_ALLOWED_TO"), ",") {
+		if a = strings.ToLower(strings.TrimSpace(a)); a != "" {
+			cfg.SMTP.Allowed = append(cfg.SMTP.Allowed, a)
+		}
+	}
+	return cfg
+}
+
+func envStr(key, def string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return def
+}
+
+func envInt(key string, def int) int {
+	if n, err := strconv.Atoi(os.Getenv(key)); err == nil && n > 0 {
+		return n
+	}
+	return def
+}
+
+// Ошибки конфигурации.
+var (
+	ErrIMAPNotConfigured = errors.New("IMAP не настроен: задайте IMAP_HOST, IMAP_USER, IMAP_PASSWORD")
+	ErrSMTPNotConfigured = errors.New("SMTP не настроен: задайте SMTP_HOST и учётные данные")
+)
+
+// Client — почтовый клиент с настройками cfg.
+type Client struct {
+	cfg Config
+}
+
+// New — клиент почты.
+func New(cfg Config) *Client {
+	return &Client{cfg: cfg}
+}
+
+// Query — критерии поиска писем.
+type Query struct {
+	Mailbox string // Ящик (по умолчанию IMAP_MAILBOX)
+	Text    string // Подстрока в теме, адресах или тексте
+	From    string // Отправитель
+	Since   string // Дата YYYY-MM-DD
+	Unseen  bool   // Только непрочитанные
+	Limit   int    // Сколько последних писем вернуть (по умолчанию 20, максимум 100)
+}
+
+// criteria — строка IMAP SEARCH.
+func (q Query) criteria() (string, error) {
+	parts := []string{}
+	if q.Unseen {
+		parts = append(parts, "UNSEEN")
+	}
+	if q.From != "" {
+		parts = append(parts, "FROM "+quote(q.From))
+	}
+	if q.Text != "" {
+		parts = append(parts, "TEXT "+quote(q.Text))
+	}
+	if q.Since != "" {
+		d, err := time.Parse("2006-01-02", q.Since)
+		if err != nil {
+			return "", fmt.Errorf("since: ожидается дата YYYY-MM-DD")
+		}
+		parts = append(parts, "SINCE "+d.Format("2-Jan-2006"))
+	}
+	if len(parts) == 0 {
+		return "ALL", nil
+	}
+	return strings.Join(parts, " "), nil
+}
+
+// session — вход и открытие ящика.
+func (c *Client) session(ctx context.Context, mailbox string) (*imapConn, error) {
+	cfg := c.cfg.IMAP
+	if cfg.Host == "" || cfg.User == "" {
+		return nil, ErrIMAPNotConfigured
+	}
+	conn, err := dialIMAP(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+	if err := conn.login(cfg.User, cfg.Password); err != nil {
+		conn.conn.Close()
+		return nil, err
+	}
+	if mailbox == "" {
+		mailbox = cfg.Mailbox
+	}
+	if err := conn.examine(mailbox); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("ящик %s: %w", mailbox, err)
+	}
+	return conn, nil
+}
+
+// List — последние письма по критериям, новые первыми.
+func (c *Client) List(ctx context.Context, q Query) ([]Summary, int, error) {
+	crit, err := q.criteria()
+	if err != nil {
+		return nil, 0, err
+	}
+	if q.Limit <= 0 {
+		q.Limit = 20
+	}
+	if q.Limit > 100 {
+		q.Limit = 100
+	}
+	conn, err := c.session(ctx, q.Mailbox)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer conn.Close()
+	uids, err := conn.search(crit)
+	if err != nil {
+		return nil, 0, err
+	}
+	total := len(uids)
+	sort.Slice(uids, func(i, j int) bool { return uids[i] > uids[j] })
+	if len(uids) > q.Limit {
+		uids = uids[:q.Limit]
+	}
+	if len(uids) == 0 {
+		return []Summary{}, total, nil
+	}
+	data, err := conn.fetch(uids, "BODY.PEEK[HEADER.FIELDS (FROM TO SUBJECT DATE)]")
+	if err != nil {
+		return nil, 0, err
+	}
+	out := make([]Summary, 0, len(uids))
+	for _, uid := range uids {
+		f, ok := data[uid]
+		if !ok {
+			continue
+		}
+		s := parseSummary(uid, f.Data)
+		s.Seen, s.Size = f.Seen, f.Size
+		out = append(out, s)
+	}
+	return out, total, nil
+}
+
+// ErrNotFound — письма с таким UID нет.
+var ErrNotFound = errors.New("письмо не найдено")
+
+// Read — письмо целиком по UID (без отметки «прочитано»).
+func (c *Client) Read(ctx context.Context, mailbox string, uid uint32) (Message, error) {
+	conn, err := c.session(ctx, mailbox)
+	if err != nil {
+		return Message{}, err
+	}
+	defer conn.Close()
+	data, err := conn.fetch([]uint32{uid}, "BODY.PEEK[]")
+	if err != nil {
+		return Message{}, err
+	}
+	f, ok := data[uid]
+	if !ok {
+		return Message{}, ErrNotFound
+	}
+	m := parseMessage(uid, f.Data, c.cfg.MaxText)
+	m.Seen, m.Size = f.Seen, f.Size
+	return m, nil
+}
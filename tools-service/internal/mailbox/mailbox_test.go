@@ -0,0 +1,171 @@
+package mailbox
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeIMAP — IMAP-сервер на localhost с письмами messages (UID → текст).
+func fakeIMAP(t *testing.T, messages map[uint32]string) IMAPConfig {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go serveIMAP(conn, messages)
+		}
+	}()
+	addr := ln.Addr().(*net.TCPAddr)
+	return IMAPConfig{Host: "127.0.0.1", Port: addr.Port, User: "bot", Password: "secret", Mailbox: "INBOX", Timeout: 5 * time.Second}
+}
+
+func serveIMAP(conn net.Conn, messages map[uint32]string) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	fmt.Fprint(conn, "* OK fake ready\r\n")
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		tag, cmd, _ := strings.Cut(strings.TrimSpace(line), " ")
+		switch {
+		case strings.HasPrefix(cmd, "LOGIN"):
+			if !strings.Contains(cmd, `"secret"`) {
+				fmt.Fprintf(conn, "%s NO auth failed\r\n", tag)
+				continue
+			}
+		case strings.HasPrefix(cmd, "UID SEARCH"):
+			if strings.Contains(cmd, "UNSEEN") {
+				fmt.Fprint(conn, "* SEARCH 3\r\n")
+			} else {
+				fmt.Fprint(conn, "* SEARCH 1 2 3\r\n")
+			}
+		case strings.HasPrefix(cmd, "UID FETCH"):
+			set := strings.Fields(cmd)[2]
+			for _, s := range strings.Split(set, ",") {
+				var uid uint32
+				fmt.Sscan(s, &uid)
+				msg, ok := messages[uid]
+				if !ok {
+					continue
+				}
+				flags := `\Seen`
+				if uid == 3 {
+					flags = ""
+				}
+				if strings.Contains(cmd, "HEADER.FIELDS") {
+					msg, _, _ = strings.Cut(msg, "\r\n\r\n")
+				}
+				fmt.Fprintf(conn, "* %d FETCH (UID %d FLAGS (%s) RFC822.SIZE %d BODY[] {%d}\r\n%s)\r\n", uid, uid, flags, len(messages[uid]), len(msg), msg)
+			}
+		case cmd == "LOGOUT":
+			fmt.Fprintf(conn, "* BYE\r\n%s OK bye\r\n", tag)
+			return
+		}
+		fmt.Fprintf(conn, "%s OK done\r\n", tag)
+	}
+}
+
+func testMessage(uid int, subject string) string {
+	return fmt.Sprintf("From: Alice <alice@example.com>\r\nTo: bot@example.com\r\nSubject: %s\r\nDate: Mon, 0%d Jun 2026 10:00:00 +0000\r\nMessage-ID: <m%d@example.com>\r\n\r\nBody %d\r\n", subject, uid, uid, uid)
+}
+
+// TestList_NewestFirst — список писем: новые первыми, флаги и лимит.
+func TestList_NewestFirst(t *testing.T) {
+	imap := fakeIMAP(t, map[uint32]string{
+		1: testMessage(1, "First"),
+		2: testMessage(2, "=?utf-8?B?0J7RgtGH0ZHRgg==?="),
+		3: testMessage(3, "Third"),
+	})
+	c := New(Config{IMAP: imap, MaxText: 100})
+
+	list, total, err := c.List(context.Background(), Query{Limit: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 3 || len(list) != 2 || list[0].UID != 3 || list[1].Subject != "Отчёт" {
+		t.Fatalf("List: total=%d %+v", total, list)
+	}
+	if list[0].Seen || !list[1].Seen || list[0].From != "Alice <alice@example.com>" {
+		t.Errorf("флаги/заголовки: %+v", list)
+	}
+
+	msg, err := c.Read(context.Background(), "", 1)
+	if err != nil || msg.Text != "Body 1" || msg.MessageID != "<m1@example.com>" {
+		t.Fatalf("Read: %+v, %v", msg, err)
+	}
+	if _, err := c.Read(context.Background(), "", 9); err != ErrNotFound {
+		t.Errorf("ожидалась ErrNotFound, получено %v", err)
+	}
+
+	imap.Password = "wrong"
+	if _, _, err := New(Config{IMAP: imap}).List(context.Background(), Query{}); err == nil {
+		t.Error("неверный пароль должен давать ошибку")
+	}
+	if _, _, err := New(Config{}).List(context.Background(), Query{}); err != ErrIMAPNotConfigured {
+		t.Errorf("ожидалась ErrIMAPNotConfigured, получено %v", err)
+	}
+}
+
+// TestParseMessage_Multipart — текст из multipart в cp1251 и имена вложений.
+func TestParseMessage_Multipart(t *testing.T) {
+	raw := "From: =?windows-1251?B?z/Do4uXy?= <a@b.c>\r\n" +
+		"Subject: test\r\n" +
+		"Content-Type: multipart/mixed; boundary=XX\r\n\r\n" +
+		"--XX\r\n" +
+		"Content-Type: text/html; charset=utf-8\r\n\r\n" +
+		"<p>html</p>\r\n" +
+		"--XX\r\n" +
+		"Content-Type: text/plain; charset=windows-1251\r\n" +
+		"Content-Transfer-Encoding: base64\r\n\r\n" +
+		"z/Do4uXy\r\n" +
+		"--XX\r\n" +
+		"Content-Type: application/pdf; name=\"report.pdf\"\r\n" +
+		"Content-Disposition: attachment; filename=\"report.pdf\"\r\n\r\n" +
+		"JVBERi0=\r\n" +
+		"--XX--\r\n"
+	m := parseMessage(7, []byte(raw), 0)
+	if m.From != "Привет <a@b.c>" || m.Text != "Привет" || len(m.Attachments) != 1 || m.Attachments[0] != "report.pdf" {
+		t.Fatalf("parseMessage: %+v", m)
+	}
+
+	html := "Content-Type: text/html; charset=utf-8\r\n\r\n<style>p{}</style><p>Один &amp; два</p><br>три"
+	if m := parseMessage(1, []byte(html), 6); m.Text != "Один &" || !m.Truncated {
+		t.Errorf("HTML: %q truncated=%v", m.Text, m.Truncated)
+	}
+}
+
+// TestSend_Recipients — проверка адресатов по MAIL_ALLOWED_TO и сборка письма.
+func TestSend_Recipients(t *testing.T) {
+	c := New(Config{SMTP: SMTPConfig{Host: "smtp.example.com", From: "bot@example.com", Allowed: []string{"boss@corp.io", "@example.com"}}})
+	if _, err := c.checkRecipients([]string{"Boss <BOSS@corp.io>", "dev@example.com"}); err != nil {
+		t.Errorf("разрешённые адреса отклонены: %v", err)
+	}
+	if _, err := c.Send(context.Background(), Outgoing{To: []string{"evil@attacker.io"}}); !errors.Is(err, ErrRecipientNotAllowed) {
+		t.Errorf("адрес вне списка должен отклоняться: %v", err)
+	}
+
+	data := string(buildMessage("bot@example.com", []string{"a@example.com"}, nil, "Отчёт", "строка 1\nстрока 2", "<id@example.com>", "<m1@example.com>", time.Unix(0, 0)))
+	for _, want := range []string{"Subject: =?utf-8?q?", "In-Reply-To: <m1@example.com>", "charset=utf-8", "\r\n\r\n"} {
+		if !strings.Contains(data, want) {
+			t.Errorf("в письме нет %q:\n%s", want, data)
+		}
+	}
+	if m := parseMessage(0, []byte(data), 0); m.Subject != "Отчёт" || m.Text != "строка 1\nстрока 2" {
+		t.Errorf("письмо не разбирается обратно: %+v", m)
+	}
+}
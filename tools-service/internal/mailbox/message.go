@@ -0,0 +1,169 @@
+package mailbox
+
+import (
+	"bytes"
+	"encoding/base64"
+	"html"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Summary — письмо в списке: заголовки без тела.
+type Summary struct {
+	UID     uint32    `json:"uid"`
+	From    string    `json:"from"`
+	To      string    `json:"to"`
+	Subject string    `json:"subject"`
+	Date    time.Time `json:"date"`
+	Seen    bool      `json:"seen"`
+	Size    int       `json:"size"`
+}
+
+// Message — письмо целиком: заголовки, текст и имена вложений.
+type Message struct {
+	Summary
+	Cc          string   `json:"cc,omitempty"`
+	MessageID   string   `json:"message_id,omitempty"`
+	Text        string   `json:"text"`
+	Truncated   bool     `json:"truncated,omitempty"`
+	Attachments []string `json:"attachments,omitempty"`
+}
+
+var wordDecoder = &mime.WordDecoder{CharsetReader: charsetReader}
+
+// decodeHeader — заголовок с кодированными словами (=?utf-8?B?...?=).
+func decodeHeader(v string) string {
+	if out, err := wordDecoder.DecodeHeader(v); err == nil {
+		return out
+	}
+	return v
+}
+
+// parseSummary — заголовки письма из BODY[HEADER.FIELDS ...] или полного текста.
+func parseSummary(uid uint32, raw []byte) Summary {
+	s := Summary{UID: uid}
+	msg, err := mail.ReadMessage(bytes.NewReader(append(raw, '\r', '\n')))
+	if err != nil {
+		return s
+	}
+	s.From = decodeHeader(msg.Header.Get("From"))
+	s.To = decodeHeader(msg.Header.Get("To"))
+	s.Subject = decodeHeader(msg.Header.Get("Subject"))
+	s.Date, _ = msg.Header.Date()
+	return s
+}
+
+// parseMessage — текст письма: text/plain, иначе text/html без разметки;
+// вложения перечисляются по именам.
+func parseMessage(uid uint32, raw []byte, maxText int) Message {
+	m := Message{Summary: parseSummary(uid, raw)}
+	msg, err := mail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		m.Text = string(raw)
+		return m
+	}
+	m.Cc = decodeHeader(msg.Header.Get("Cc"))
+	m.MessageID = msg.Header.Get("Message-Id")
+
+	var plain, htmlText string
+	walkPart(msg.Header.Get("Content-Type"), msg.Header.Get("Content-Transfer-Encoding"), msg.Header.Get("Content-Disposition"), msg.Body,
+		func(mediaType, charset, filename string, body []byte) {
+			switch {
+			case filename != "":
+				m.Attachments = append(m.Attachments, filename)
+			case mediaType == "text/plain" && plain == "":
+				plain = decodeCharset(charset, body)
+			case mediaType == "text/html" && htmlText == "":
+				htmlText = decodeCharset(charset, body)
+			}
+		})
+	m.Text = plain
+	if strings.TrimSpace(m.Text) == "" {
+		m.Text = stripHTML(htmlText)
+	}
+	m.Text = strings.TrimSpace(strings.ReplaceAll(m.Text, "\r\n", "\n"))
+	if r := []rune(m.Text); maxText > 0 && len(r) > maxText {
+		m.Text, m.Truncated = string(r[:maxText]), true
+	}
+	return m
+}
+
+// walkPart — обход MIME-дерева; для листьев вызывается fn с декодированным телом.
+func walkPart(contentType, encoding, disposition string, body io.Reader, fn func(mediaType, charset, filename string, body []byte)) {
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		mediaType, params = "text/plain", map[string]string{}
+	}
+	if strings.HasPrefix(mediaType, "multipart/") {
+		mr := multipart.NewReader(body, params["boundary"])
+		for {
+			p, err := mr.NextRawPart()
+			if err != nil {
+				return
+			}
+			walkPart(p.Header.Get("Content-Type"), p.Header.Get("Content-Transfer-Encoding"), p.Header.Get("Content-Disposition"), p, fn)
+		}
+	}
+	filename := ""
+	if _, dp, err := mime.ParseMediaType(disposition); err == nil {
+		filename = dp["filename"]
+	}
+	if filename == "" && !strings.HasPrefix(mediaType, "text/") {
+		filename = params["name"]
+	}
+	if filename != "" {
+		fn(mediaType, "", decodeHeader(filename), nil)
+		return
+	}
+	var r io.Reader = body
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case "base64":
+		r = base64.NewDecoder(base64.StdEncoding, &newlineStripper{r: body})
+	case "quoted-printable":
+		r = quotedprintable.NewReader(body)
+	}
+	data, _ := io.ReadAll(io.LimitReader(r, 4<<20))
+	fn(mediaType, params["charset"], "", data)
+}
+
+// newlineStripper — убирает переводы строк из base64 перед декодированием.
+type newlineStripper struct{ r io.Reader }
+
+func (n *newlineStripper) Read(p []byte) (int, error) {
+	buf := make([]byte, len(p))
+	for {
+		k, err := n.r.Read(buf)
+		j := 0
+		for _, b := range buf[:k] {
+			if b != '\r' && b != '\n' {
+				p[j] = b
+				j++
+			}
+		}
+		if j > 0 || err != nil {
+			return j, err
+		}
+	}
+}
+
+var (
+	scriptRe = regexp.MustCompile(`(?is)<(script|style)[^>]*>.*?</(script|style)>`)
+	breakRe  = regexp.MustCompile(`(?i)<(br|/p|/div|/tr|/li|/h\d)[^>]*>`)
+	tagRe    = regexp.MustCompile(`<[^>]+>`)
+	blankRe  = regexp.MustCompile(`\n[ \t]*\n(\s*\n)+`)
+)
+
+// stripHTML — текст из HTML-письма.
+func stripHTML(s string) string {
+	s = scriptRe.ReplaceAllString(s, "")
+	s = breakRe.ReplaceAllString(s, "\n")
+	s = tagRe.ReplaceAllString(s, "")
+	s = html.UnescapeString(s)
+	return blankRe.ReplaceAllString(s, "\n\n")
+}
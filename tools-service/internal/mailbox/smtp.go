@@ -0,0 +1,201 @@
+package mailbox
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"mime"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Outgoing — письмо для отправки.
+type Outgoing struct {
+	To      []string `json:"to"`
+	Cc      []string `json:"cc,omitempty"`
+	Subject string   `json:"subject"`
+	Body    string   `json:"body"`
+	ReplyTo uint32   `json:"reply_to_uid,omitempty"` // Ответ на письмо (In-Reply-To берётся из ящика)
+}
+
+// Sent — результат отправки.
+type Sent struct {
+	MessageID  string   `json:"message_id"`
+	Recipients []string `json:"recipients"`
+}
+
+// ErrRecipientNotAllowed — адресат не входит в MAIL_ALLOWED_TO.
+var ErrRecipientNotAllowed = errors.New("адрес не входит в MAIL_ALLOWED_TO")
+
+// checkRecipients — разбор адресов и проверка по MAIL_ALLOWED_TO.
+func (c *Client) checkRecipients(list []string) ([]string, error) {
+	var out []string
+	for _, raw := range list {
+		addrs, err := mail.ParseAddressList(raw)
+		if err != nil {
+			return nil, fmt.Errorf("некорректный адрес %q", raw)
+		}
+		for _, a := range addrs {
+			addr := strings.ToLower(a.Address)
+			if !allowed(addr, c.cfg.SMTP.Allowed) {
+				return nil, fmt.Errorf("%w: %s", ErrRecipientNotAllowed, addr)
+			}
+			out = append(out, addr)
+		}
+	}
+	return out, nil
+}
+
+func allowed(addr string, list []string) bool {
+	if len(list) == 0 {
+		return true
+	}
+	for _, a := range list {
+		if addr == a || (strings.HasPrefix(a, "@") && strings.HasSuffix(addr, a)) {
+			return true
+		}
+	}
+	return false
+}
+
+// Send — отправка письма по SMTP. Для ответа (ReplyTo) заголовки In-Reply-To
+// и тема «Re: ...» берутся из исходного письма.
+func (c *Client) Send(ctx context.Context, out Outgoing) (Sent, error) {
+	cfg := c.cfg.SMTP
+	if cfg.Host == "" || cfg.From == "" {
+		return Sent{}, ErrSMTPNotConfigured
+	}
+	to, err := c.checkRecipients(out.To)
+	if err != nil {
+		return Sent{}, err
+	}
+	cc, err := c.checkRecipients(out.Cc)
+	if err != nil {
+		return Sent{}, err
+	}
+	if len(to) == 0 {
+		return Sent{}, fmt.Errorf("не указан получатель")
+	}
+	inReplyTo := ""
+	if out.ReplyTo != 0 {
+		orig, err := c.Read(ctx, "", out.ReplyTo)
+		if err != nil {
+			return Sent{}, fmt.Errorf("исходное письмо: %w", err)
+		}
+		inReplyTo = orig.MessageID
+		if out.Subject == "" {
+			out.Subject = orig.Subject
+			if !strings.HasPrefix(strings.ToLower(out.Subject), "re:") {
+				out.Subject = "Re: " + out.Subject
+			}
+		}
+	}
+
+	msgID := newMessageID(cfg.From)
+	data := buildMessage(cfg.From, to, cc, out.Subject, out.Body, msgID, inReplyTo, time.Now())
+	if err := sendMail(ctx, cfg, append(to, cc...), data); err != nil {
+		return Sent{}, err
+	}
+	return Sent{MessageID: msgID, Recipients: append(to, cc...)}, nil
+}
+
+// buildMessage — письмо text/plain в UTF-8 (quoted-printable).
+func buildMessage(from string, to, cc []string, subject, body, msgID, inReplyTo string, now time.Time) []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "From: %s\r\n", from)
+	fmt.Fprintf(&b, "To: %s\r\n", strings.Join(to, ", "))
+	if len(cc) > 0 {
+		fmt.Fprintf(&b, "Cc: %s\r\n", strings.Join(cc, ", "))
+	}
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&b, "Date: %s\r\n", now.Format(time.RFC1123Z))
+	fmt.Fprintf(&b, "Message-ID: %s\r\n", msgID)
+	if inReplyTo != "" {
+		fmt.Fprintf(&b, "In-Reply-To: %s\r\nReferences: %s\r\n", inReplyTo, inReplyTo)
+	}
+	b.WriteString("MIME-Version: 1.0\r\nContent-Type: text/plain; charset=utf-8\r\nContent-Transfer-Encoding: quoted-printable\r\n\r\n")
+	qp := quotedprintable.NewWriter(&b)
+	qp.Write([]byte(strings.ReplaceAll(strings.ReplaceAll(body, "\r\n", "\n"), "\n", "\r\n")))
+	qp.Close()
+	b.WriteString("\r\n")
+	return b.Bytes()
+}
+
+func newMessageID(from string) string {
+	domain := "localhost"
+	if a, err := mail.ParseAddress(from); err == nil {
+		if i := strings.LastIndexByte(a.Address, '@'); i >= 0 {
+			domain = a.Address[i+1:]
+		}
+	}
+	buf := make([]byte, 12)
+	rand.Read(buf)
+	return "<" + hex.EncodeToString(buf) + "@" + domain + ">"
+}
+
+// sendMail — SMTP-сеанс: неявный TLS (465), STARTTLS или открытый текст.
+func sendMail(ctx context.Context, cfg SMTPConfig, rcpts []string, data []byte) error {
+	addr := net.JoinHostPort(cfg.Host, strconv.Itoa(cfg.Port))
+	d := &net.Dialer{Timeout: cfg.Timeout}
+	var conn net.Conn
+	var err error
+	if cfg.Security == "tls" {
+		conn, err = (&tls.Dialer{NetDialer: d, Config: &tls.Config{ServerName: cfg.Host}}).DialContext(ctx, "tcp", addr)
+	} else {
+		conn, err = d.DialContext(ctx, "tcp", addr)
+	}
+	if err != nil {
+		return fmt.Errorf("SMTP: подключение к %s: %w", addr, err)
+	}
+	conn.SetDeadline(time.Now().Add(cfg.Timeout))
+	client, err := smtp.NewClient(conn, cfg.Host)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("SMTP: %w", err)
+	}
+	defer client.Close()
+	if cfg.Security == "starttls" {
+		if err := client.StartTLS(&tls.Config{ServerName: cfg.Host}); err != nil {
+			return fmt.Errorf("SMTP: STARTTLS: %w", err)
+		}
+	}
+	if cfg.User != "" {
+		if ok, _ := client.Extension("AUTH"); ok {
+			if err := client.Auth(smtp.PlainAuth("", cfg.User, cfg.Password, cfg.Host)); err != nil {
+				return fmt.Errorf("SMTP: ошибка входа: %w", err)
+			}
+		}
+	}
+	from := cfg.From
+	if a, err := mail.ParseAddress(from); err == nil {
+		from = a.Address
+	}
+	if err := client.Mail(from); err != nil {
+		return fmt.Errorf("SMTP: MAIL FROM: %w", err)
+	}
+	for _, r := range rcpts {
+		if err := client.Rcpt(r); err != nil {
+			return fmt.Errorf("SMTP: RCPT TO %s: %w", r, err)
+		}
+	}
+	w, err := client.Data()
+	if err != nil {
+		return fmt.Errorf("SMTP: DATA: %w", err)
+	}
+	if _, err := w.Write(data); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("SMTP: %w", err)
+	}
+	return client.Quit()
+}
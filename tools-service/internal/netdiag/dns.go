@@ -0,0 +1,124 @@
+package netdiag
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+)
+
+// RecordTypes — поддерживаемые типы DNS-записей.
+var RecordTypes = []string{"A", "AAAA", "MX", "TXT", "CNAME", "NS"}
+
+// DefaultRecordTypes — типы записей по умолчанию.
+var DefaultRecordTypes = []string{"A", "AAAA", "MX", "TXT"}
+
+// MX — почтовый сервер.
+type MX struct {
+	Host string `json:"host"`
+	Pref uint16 `json:"pref"`
+}
+
+// DNSResult — записи имени по типам.
+type DNSResult struct {
+	Name    string            `json:"name"`
+	Server  string            `json:"server,omitempty"` // Пусто — системный резолвер
+	A       []string          `json:"a,omitempty"`
+	AAAA    []string          `json:"aaaa,omitempty"`
+	MX      []MX              `json:"mx,omitempty"`
+	TXT     []string          `json:"txt,omitempty"`
+	CNAME   string            `json:"cname,omitempty"`
+	NS      []string          `json:"ns,omitempty"`
+	Errors  map[string]string `json:"errors,omitempty"` // Ошибки по типам (кроме «записей нет»)
+	Elapsed int64             `json:"elapsed_ms"`
+}
+
+// Lookup — записи name заданных типов; server — DNS-сервер (IP или IP:порт),
+// пусто — системный резолвер.
+func (d *Diag) Lookup(ctx context.Context, name string, types []string, server string) (DNSResult, error) {
+	name = strings.TrimSuffix(strings.TrimSpace(name), ".")
+	if err := ValidHost(name); err != nil {
+		return DNSResult{}, err
+	}
+	if len(types) == 0 {
+		types = DefaultRecordTypes
+	}
+	resolver := net.DefaultResolver
+	if server != "" {
+		addr, err := dnsServer(server)
+		if err != nil {
+			return DNSResult{}, err
+		}
+		server = addr
+		resolver = &net.Resolver{PreferGo: true, Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			var dialer net.Dialer
+			return dialer.DialContext(ctx, network, addr)
+		}}
+	}
+	res := DNSResult{Name: name, Server: server, Errors: map[string]string{}}
+	start := time.Now()
+	for _, t := range types {
+		var err error
+		switch strings.ToUpper(t) {
+		case "A":
+			res.A, err = lookupIP(ctx, resolver, "ip4", name)
+		case "AAAA":
+			res.AAAA, err = lookupIP(ctx, resolver, "ip6", name)
+		case "MX":
+			var mx []*net.MX
+			mx, err = resolver.LookupMX(ctx, name)
+			for _, m := range mx {
+				res.MX = append(res.MX, MX{Host: strings.TrimSuffix(m.Host, "."), Pref: m.Pref})
+			}
+		case "TXT":
+			res.TXT, err = resolver.LookupTXT(ctx, name)
+		case "CNAME":
+			var cname string
+			cname, err = resolver.LookupCNAME(ctx, name)
+			if cname = strings.TrimSuffix(cname, "."); cname != name {
+				res.CNAME = cname
+			}
+		case "NS":
+			var ns []*net.NS
+			ns, err = resolver.LookupNS(ctx, name)
+			for _, n := range ns {
+				res.NS = append(res.NS, strings.TrimSuffix(n.Host, "."))
+			}
+		default:
+			return DNSResult{}, fmt.Errorf("неизвестный тип записи %q, поддерживаются: %s", t, strings.Join(RecordTypes, ", "))
+		}
+		var dnsErr *net.DNSError
+		if err != nil && !(errors.As(err, &dnsErr) && dnsErr.IsNotFound) {
+			res.Errors[strings.ToUpper(t)] = err.Error()
+		}
+	}
+	res.Elapsed = time.Since(start).Milliseconds()
+	if len(res.Errors) == 0 {
+		res.Errors = nil
+	}
+	return res, nil
+}
+
+// lookupIP — адреса одного семейства строками.
+func lookupIP(ctx context.Context, r *net.Resolver, network, name string) ([]string, error) {
+	ips, err := r.LookupIP(ctx, network, name)
+	out := make([]string, 0, len(ips))
+	for _, ip := range ips {
+		out = append(out, ip.String())
+	}
+	return out, err
+}
+
+// dnsServer — адрес DNS-сервера с портом (53 по умолчанию).
+func dnsServer(server string) (string, error) {
+	if ip := net.ParseIP(server); ip != nil {
+		return net.JoinHostPort(ip.String(), "53"), nil
+	}
+	host, port, err := net.SplitHostPort(server)
+	if err != nil || net.ParseIP(host) == nil || port == "" {
+		return "", fmt.Errorf("%w: DNS-сервер %q, ожидается IP или IP:порт", ErrBadHost, server)
+	}
+	return server, nil
+}
@@ -0,0 +1,112 @@
+package netdiag
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Counters — счётчики интерфейса из /proc/net/dev.
+type Counters struct {
+	RxBytes   uint64 `json:"rx_bytes"`
+	RxPackets uint64 `json:"rx_packets"`
+	RxErrors  uint64 `json:"rx_errors"`
+	RxDropped uint64 `json:"rx_dropped"`
+	TxBytes   uint64 `json:"tx_bytes"`
+	TxPackets uint64 `json:"tx_packets"`
+	TxErrors  uint64 `json:"tx_errors"`
+	TxDropped uint64 `json:"tx_dropped"`
+}
+
+// Interface — сетевой интерфейс.
+type Interface struct {
+	Name     string    `json:"name"`
+	MAC      string    `json:"mac,omitempty"`
+	MTU      int       `json:"mtu"`
+	Up       bool      `json:"up"`
+	Loopback bool      `json:"loopback"`
+	Addrs    []string  `json:"addrs"`
+	Counters *Counters `json:"counters,omitempty"`
+	RxBps    *float64  `json:"rx_bytes_per_sec,omitempty"` // Скорость за интервал замера
+	TxBps    *float64  `json:"tx_bytes_per_sec,omitempty"`
+}
+
+// Interfaces — интерфейсы с адресами и счётчиками; sample > 0 — замер
+// скорости приёма и передачи за этот интервал (не больше MaxSample).
+func (d *Diag) Interfaces(ctx context.Context, sample time.Duration) ([]Interface, error) {
+	list, err := net.Interfaces()
+	if err != nil {
+		return nil, err
+	}
+	before, _ := readCounters(d.procDev)
+	var after map[string]Counters
+	if sample > 0 && before != nil {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(min(sample, MaxSample)):
+		}
+		after, _ = readCounters(d.procDev)
+	}
+	out := make([]Interface, 0, len(list))
+	for _, ifc := range list {
+		item := Interface{
+			Name:     ifc.Name,
+			MAC:      ifc.HardwareAddr.String(),
+			MTU:      ifc.MTU,
+			Up:       ifc.Flags&net.FlagUp != 0,
+			Loopback: ifc.Flags&net.FlagLoopback != 0,
+			Addrs:    []string{},
+		}
+		if addrs, err := ifc.Addrs(); err == nil {
+			for _, a := range addrs {
+				item.Addrs = append(item.Addrs, a.String())
+			}
+		}
+		if c, ok := before[ifc.Name]; ok {
+			item.Counters = &c
+			if a, ok := after[ifc.Name]; ok {
+				secs := min(sample, MaxSample).Seconds()
+				rx, tx := float64(a.RxBytes-c.RxBytes)/secs, float64(a.TxBytes-c.TxBytes)/secs
+				item.RxBps, item.TxBps = &rx, &tx
+				item.Counters = &a
+			}
+		}
+		out = append(out, item)
+	}
+	return out, nil
+}
+
+// readCounters — разбор /proc/net/dev: «iface: rx(8 полей) tx(8 полей)».
+func readCounters(path string) (map[string]Counters, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	out := map[string]Counters{}
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		name, rest, ok := strings.Cut(sc.Text(), ":")
+		if !ok {
+			continue
+		}
+		fields := strings.Fields(rest)
+		if len(fields) < 16 {
+			continue
+		}
+		v := make([]uint64, 16)
+		for i := range v {
+			v[i], _ = strconv.ParseUint(fields[i], 10, 64)
+		}
+		out[strings.TrimSpace(name)] = Counters{
+			RxBytes: v[0], RxPackets: v[1], RxErrors: v[2], RxDropped: v[3],
+			TxBytes: v[8], TxPackets: v[9], TxErrors: v[10], TxDropped: v[11],
+		}
+	}
+	return out, sc.Err()
+}
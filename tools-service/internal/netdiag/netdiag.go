@@ -0,0 +1,121 @@
+// Package netdiag — сетевая диагностика в структурированном виде: ping,
+// traceroute, DNS-записи, внешний IP и сетевые интерфейсы со счётчиками
+// трафика. Агенту не нужно собирать это из команд оболочки и разбирать их
+// вывод.
+//
+// ping и traceroute запускают системные утилиты (ICMP требует привилегий);
+// без утилиты ping переходит на TCP-подключение, traceroute — на tracepath.
+// Имя хоста проверяется до запуска, чтобы его нельзя было передать как флаг.
+package netdiag
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Ограничения запросов.
+const (
+	DefaultCount   = 4
+	MaxCount       = 20
+	DefaultMaxHops = 20
+	MaxHops        = 40
+	MaxSample      = 10 * time.Second
+)
+
+// Ошибки диагностики.
+var (
+	ErrBadHost = errors.New("некорректное имя хоста или IP-адрес")
+	ErrNoTool  = errors.New("утилита не установлена")
+)
+
+// hostRe — имя хоста по RFC 1123 (IP-адреса проверяются отдельно).
+var hostRe = regexp.MustCompile(`^[A-Za-z0-9]([A-Za-z0-9-]{0,62})(\.[A-Za-z0-9]([A-Za-z0-9-]{0,62}))*\.?$`)
+
+// ValidHost — хост без пробелов и ведущего «-», пригодный для аргумента утилиты.
+func ValidHost(host string) error {
+	if net.ParseIP(host) != nil {
+		return nil
+	}
+	if len(host) == 0 || len(host) > 253 || !hostRe.MatchString(host) {
+		return fmt.Errorf("%w: %q", ErrBadHost, host)
+	}
+	return nil
+}
+
+// Config — настройки диагностики.
+type Config struct {
+	PublicIPURLs []string      // NET_PUBLIC_IP_URL: сервисы, отвечающие внешним IP (по очереди)
+	Timeout      time.Duration // Общий таймаут одного запроса
+}
+
+// DefaultConfig — настройки из переменных окружения.
+func DefaultConfig() Config {
+	cfg := Config{
+		PublicIPURLs: []string{"https://api.ipify.org", "https://ifconfig.me/ip", "https://icanhazip.com"},
+		Timeout:      60 * time.Second,
+	}
+	if v := os.Getenv("NET_PUBLIC_IP_URL"); v != "" {
+		cfg.PublicIPURLs = nil
+		for _, u := range strings.Split(v, ",") {
+			if u = strings.TrimSpace(u); u != "" {
+				cfg.PublicIPURLs = append(cfg.PublicIPURLs, u)
+			}
+		}
+	}
+	return cfg
+}
+
+// Diag — сетевая диагностика с настройками cfg.
+type Diag struct {
+	cfg      Config
+	lookPath func(string) (string, error)
+	run      func(ctx context.Context, name string, args ...string) (stdout, stderr []byte, code int, err error)
+	procDev  string // Счётчики интерфейсов (Linux)
+}
+
+// New — диагностика с настройками cfg.
+func New(cfg Config) *Diag {
+	return &Diag{cfg: cfg, lookPath: exec.LookPath, run: run, procDev: "/proc/net/dev"}
+}
+
+// Timeout — общий таймаут одного запроса.
+func (d *Diag) Timeout() time.Duration {
+	return d.cfg.Timeout
+}
+
+// run — запуск утилиты; ненулевой код возврата не ошибка (ping так сообщает
+// о потерях), ошибка — только если процесс не запустился или прерван по таймауту.
+func run(ctx context.Context, name string, args ...string) (stdout, stderr []byte, code int, err error) {
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Env = append(os.Environ(), "LC_ALL=C")
+	var out, errOut bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &out, &errOut
+	err = cmd.Run()
+	if ctx.Err() != nil {
+		return out.Bytes(), errOut.Bytes(), -1, fmt.Errorf("%s: превышен таймаут", filepath.Base(name))
+	}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return out.Bytes(), errOut.Bytes(), exitErr.ExitCode(), nil
+	}
+	return out.Bytes(), errOut.Bytes(), 0, err
+}
+
+// firstLine — первая непустая строка вывода (для сообщений об ошибке).
+func firstLine(b []byte) string {
+	for _, line := range strings.Split(string(b), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			return line
+		}
+	}
+	return ""
+}
@@ -0,0 +1,155 @@
+package netdiag
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// fakeTools — утилиты из списка installed отвечают заданным выводом.
+func fakeTools(d *Diag, installed map[string]string) *[]string {
+	var calls []string
+	d.lookPath = func(name string) (string, error) {
+		if _, ok := installed[name]; ok {
+			return "/usr/bin/" + name, nil
+		}
+		return "", errors.New("not found")
+	}
+	d.run = func(_ context.Context, name string, args ...string) ([]byte, []byte, int, error) {
+		calls = append(calls, name+" "+strings.Join(args, " "))
+		return []byte(installed[filepath.Base(name)]), nil, 0, nil
+	}
+	return &calls
+}
+
+func TestValidHost(t *testing.T) {
+	for _, h := range []string{"example.com", "a-b.example.org.", "1.1.1.1", "2001:db8::1", "localhost"} {
+		if err := ValidHost(h); err != nil {
+			t.Errorf("%s: %v", h, err)
+		}
+	}
+	for _, h := range []string{"", "-c 100 evil", "a b", "host;rm", "-help", strings.Repeat("a", 64) + ".com"} {
+		if err := ValidHost(h); !errors.Is(err, ErrBadHost) {
+			t.Errorf("%q: ожидалась ошибка", h)
+		}
+	}
+}
+
+func TestPing(t *testing.T) {
+	d := New(DefaultConfig())
+	calls := fakeTools(d, map[string]string{"ping": `PING example.com (93.184.216.34) 56(84) bytes of data.
+64 bytes from 93.184.216.34: icmp_seq=1 ttl=56 time=10.2 ms
+64 bytes from 93.184.216.34: icmp_seq=2 ttl=56 time=12.4 ms
+64 bytes from 93.184.216.34: icmp_seq=4 ttl=56 time=11.0 ms
+
+--- example.com ping statistics ---
+4 packets transmitted, 3 received, 25% packet loss, time 3005ms
+rtt min/avg/max/mdev = 10.200/11.200/12.400/0.900 ms
+`})
+	res, err := d.Ping(context.Background(), "example.com", 4, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Address != "93.184.216.34" || res.Transmitted != 4 || res.Received != 3 || res.Loss != 25 || res.MinMs != 10.2 || res.MaxMs != 12.4 || res.AvgMs != 11.2 || !res.Reachable {
+		t.Errorf("разбор: %+v", res)
+	}
+	if (*calls)[0] != "/usr/bin/ping -c 4 -W 2 example.com" {
+		t.Errorf("вызов: %v", *calls)
+	}
+	if _, err := d.Ping(context.Background(), "-f", 4, 0); !errors.Is(err, ErrBadHost) {
+		t.Errorf("флаг вместо хоста: %v", err)
+	}
+
+	// busybox (alpine)
+	bb, ok := parsePing("PING 1.1.1.1 (1.1.1.1): 56 data bytes\n64 bytes from 1.1.1.1: seq=0 ttl=57 time=9.5 ms\n\n--- 1.1.1.1 ping statistics ---\n1 packets transmitted, 1 packets received, 0% packet loss\nround-trip min/avg/max = 9.5/9.5/9.5 ms\n")
+	if !ok || bb.Received != 1 || bb.AvgMs != 9.5 {
+		t.Errorf("busybox: %+v", bb)
+	}
+
+	// BSD/macOS
+	bsd, ok := parsePing("PING 1.1.1.1 (1.1.1.1): 56 data bytes\n\n--- 1.1.1.1 ping statistics ---\n2 packets transmitted, 0 packets received, 100.0% packet loss\n")
+	if !ok || bsd.Received != 0 || bsd.Loss != 100 || bsd.Reachable {
+		t.Errorf("BSD: %+v", bsd)
+	}
+}
+
+func TestTraceroute(t *testing.T) {
+	d := New(DefaultConfig())
+	fakeTools(d, map[string]string{"traceroute": `traceroute to 1.1.1.1 (1.1.1.1), 20 hops max, 60 byte packets
+ 1  192.168.1.1  0.512 ms
+ 2  *
+ 3  1.1.1.1  9.870 ms
+`})
+	res, err := d.Traceroute(context.Background(), "1.1.1.1", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Tool != "traceroute" || len(res.Hops) != 3 || res.Hops[0].Address != "192.168.1.1" || res.Hops[1].Address != "" || res.Hops[2].RttMs != 9.87 || !res.Reached {
+		t.Errorf("traceroute: %+v", res)
+	}
+
+	fakeTools(d, map[string]string{"tracepath": ` 1?: [LOCALHOST]                      pmtu 1500
+ 1:  10.0.0.1                                              0.410ms
+ 1:  10.0.0.1                                              0.380ms
+ 2:  no reply
+     Too many hops: pmtu 1500
+     Resume: pmtu 1500
+`})
+	res, err = d.Traceroute(context.Background(), "example.com", 2)
+	if err != nil || res.Tool != "tracepath" || len(res.Hops) != 2 || res.Hops[0].RttMs != 0.41 || res.Reached {
+		t.Errorf("tracepath: %+v, %v", res, err)
+	}
+
+	fakeTools(d, map[string]string{})
+	if _, err := d.Traceroute(context.Background(), "example.com", 0); !errors.Is(err, ErrNoTool) {
+		t.Errorf("без утилит: %v", err)
+	}
+}
+
+func TestInterfaces(t *testing.T) {
+	d := New(DefaultConfig())
+	d.procDev = filepath.Join(t.TempDir(), "dev")
+	os.WriteFile(d.procDev, []byte(`Inter-|   Receive                                                |  Transmit
+ face |bytes    packets errs drop fifo frame compressed multicast|bytes    packets errs drop fifo colls carrier compressed
+    lo:  1000      10    0    0    0     0          0         0     1000      10    0    0    0     0       0          0
+`), 0o644)
+	list, err := d.Interfaces(context.Background(), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, ifc := range list {
+		if ifc.Loopback && ifc.Name == "lo" && (ifc.Counters == nil || ifc.Counters.RxBytes != 1000 || ifc.Counters.TxPackets != 10) {
+			t.Errorf("счётчики lo: %+v", ifc.Counters)
+		}
+	}
+}
+
+func TestLookupValidation(t *testing.T) {
+	d := New(DefaultConfig())
+	if _, err := d.Lookup(context.Background(), "example.com", []string{"SRV"}, ""); err == nil {
+		t.Error("неизвестный тип записи")
+	}
+	if _, err := d.Lookup(context.Background(), "example.com", nil, "dns.google"); !errors.Is(err, ErrBadHost) {
+		t.Errorf("DNS-сервер не IP: %v", err)
+	}
+	if addr, err := dnsServer("2001:db8::1"); err != nil || addr != "[2001:db8::1]:53" {
+		t.Errorf("порт по умолчанию: %s, %v", addr, err)
+	}
+}
+
+func TestPublicIP(t *testing.T) {
+	bad := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) { w.Write([]byte("<html>")) }))
+	defer bad.Close()
+	good := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) { w.Write([]byte("203.0.113.7\n")) }))
+	defer good.Close()
+	d := New(Config{PublicIPURLs: []string{bad.URL, good.URL}})
+	res, err := d.PublicIP(context.Background())
+	if err != nil || res.IP != "203.0.113.7" || res.Version != 4 || res.Source != good.URL {
+		t.Errorf("внешний IP: %+v, %v", res, err)
+	}
+}
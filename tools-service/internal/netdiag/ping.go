@@ -0,0 +1,151 @@
+package netdiag
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// PingResult — итог ping.
+type PingResult struct {
+	Host        string    `json:"host"`
+	Address     string    `json:"address,omitempty"` // IP, на который шли запросы
+	Method      string    `json:"method"`            // icmp или tcp (без утилиты ping или с port)
+	Port        int       `json:"port,omitempty"`
+	Transmitted int       `json:"transmitted"`
+	Received    int       `json:"received"`
+	Loss        float64   `json:"loss_percent"`
+	MinMs       float64   `json:"min_ms,omitempty"`
+	AvgMs       float64   `json:"avg_ms,omitempty"`
+	MaxMs       float64   `json:"max_ms,omitempty"`
+	Times       []float64 `json:"times_ms,omitempty"` // Время каждого ответа
+	Reachable   bool      `json:"reachable"`
+}
+
+var (
+	pingReplyRe = regexp.MustCompile(`from ([^\s:]+).*time[=<]([\d.]+) ?ms`)
+	pingHeadRe  = regexp.MustCompile(`^PING \S+ \(([^)]+)\)`)
+	pingStatsRe = regexp.MustCompile(`(\d+) packets transmitted, (\d+) (?:packets )?received`)
+)
+
+// Ping — count эхо-запросов (ICMP через утилиту ping). port > 0, отсутствие
+// утилиты или прав на ICMP — замер времени TCP-подключения к port (по умолчанию 443).
+func (d *Diag) Ping(ctx context.Context, host string, count, port int) (PingResult, error) {
+	if err := ValidHost(host); err != nil {
+		return PingResult{}, err
+	}
+	if count <= 0 {
+		count = DefaultCount
+	}
+	count = min(count, MaxCount)
+	bin, err := d.lookPath("ping")
+	if port > 0 || err != nil {
+		if port <= 0 {
+			port = 443
+		}
+		return tcpPing(ctx, host, count, port)
+	}
+	stdout, stderr, code, err := d.run(ctx, bin, "-c", strconv.Itoa(count), "-W", "2", host)
+	if err != nil {
+		return PingResult{}, err
+	}
+	res, ok := parsePing(string(stdout))
+	if !ok {
+		msg := firstLine(stderr)
+		// Без прав на ICMP-сокет (непривилегированный контейнер) — TCP
+		if lower := strings.ToLower(msg); strings.Contains(lower, "permission denied") || strings.Contains(lower, "not permitted") {
+			return tcpPing(ctx, host, count, 443)
+		}
+		if msg == "" {
+			msg = fmt.Sprintf("код возврата %d", code)
+		}
+		return PingResult{}, fmt.Errorf("ping %s: %s", host, msg)
+	}
+	res.Host = host
+	return res, nil
+}
+
+// parsePing — разбор вывода ping (iputils, busybox, BSD).
+func parsePing(out string) (PingResult, bool) {
+	res := PingResult{Method: "icmp"}
+	found := false
+	for _, line := range strings.Split(out, "\n") {
+		if m := pingHeadRe.FindStringSubmatch(line); m != nil {
+			res.Address = m[1]
+		}
+		if m := pingReplyRe.FindStringSubmatch(line); m != nil {
+			if ms, err := strconv.ParseFloat(m[2], 64); err == nil {
+				res.Times = append(res.Times, ms)
+			}
+			if res.Address == "" {
+				res.Address = m[1]
+			}
+		}
+		if m := pingStatsRe.FindStringSubmatch(line); m != nil {
+			res.Transmitted, _ = strconv.Atoi(m[1])
+			res.Received, _ = strconv.Atoi(m[2])
+			found = true
+		}
+	}
+	if !found {
+		return res, false
+	}
+	summarize(&res)
+	return res, true
+}
+
+// summarize — потери и min/avg/max по времени ответов.
+func summarize(res *PingResult) {
+	if res.Transmitted > 0 {
+		res.Loss = float64(int(float64(res.Transmitted-res.Received)/float64(res.Transmitted)*1000+0.5)) / 10
+	}
+	res.Reachable = res.Received > 0
+	for i, t := range res.Times {
+		if i == 0 || t < res.MinMs {
+			res.MinMs = t
+		}
+		res.MaxMs = max(res.MaxMs, t)
+		res.AvgMs += t / float64(len(res.Times))
+	}
+	res.AvgMs = float64(int(res.AvgMs*1000+0.5)) / 1000
+}
+
+// tcpPing — время установления TCP-соединения вместо ICMP.
+func tcpPing(ctx context.Context, host string, count, port int) (PingResult, error) {
+	res := PingResult{Host: host, Method: "tcp", Port: port}
+	addrs, err := net.DefaultResolver.LookupHost(ctx, host)
+	if err != nil {
+		return PingResult{}, fmt.Errorf("ping %s: %w", host, err)
+	}
+	res.Address = addrs[0]
+	target := net.JoinHostPort(res.Address, strconv.Itoa(port))
+	dialer := net.Dialer{Timeout: 2 * time.Second}
+	for i := 0; i < count; i++ {
+		if i > 0 {
+			select {
+			case <-ctx.Done():
+				return PingResult{}, ctx.Err()
+			case <-time.After(time.Second):
+			}
+		}
+		res.Transmitted++
+		start := time.Now()
+		conn, err := dialer.DialContext(ctx, "tcp", target)
+		if err != nil {
+			if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+				return PingResult{}, err
+			}
+			continue
+		}
+		conn.Close()
+		res.Received++
+		res.Times = append(res.Times, float64(time.Since(start).Microseconds())/1000)
+	}
+	summarize(&res)
+	return res, nil
+}
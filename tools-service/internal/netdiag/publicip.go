@@ -0,0 +1,60 @@
+package netdiag
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// PublicIPResult — внешний адрес.
+type PublicIPResult struct {
+	IP      string `json:"ip"`
+	Version int    `json:"version"` // 4 или 6
+	Source  string `json:"source"`  // Сервис, который ответил
+}
+
+// PublicIP — внешний IP по сервисам из NET_PUBLIC_IP_URL (первый ответивший).
+func (d *Diag) PublicIP(ctx context.Context) (PublicIPResult, error) {
+	client := &http.Client{Timeout: 10 * time.Second}
+	var errs []string
+	for _, u := range d.cfg.PublicIPURLs {
+		ip, err := fetchIP(ctx, client, u)
+		if err != nil {
+			errs = append(errs, err.Error())
+			continue
+		}
+		res := PublicIPResult{IP: ip.String(), Version: 6, Source: u}
+		if ip.To4() != nil {
+			res.Version = 4
+		}
+		return res, nil
+	}
+	return PublicIPResult{}, fmt.Errorf("внешний IP не определён: %s", strings.Join(errs, "; "))
+}
+
+// fetchIP — IP из текстового ответа сервиса.
+func fetchIP(ctx context.Context, client *http.Client, url string) (net.IP, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", "curl/8")
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 256))
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s: HTTP %d", url, resp.StatusCode)
+	}
+	ip := net.ParseIP(strings.TrimSpace(string(body)))
+	if ip == nil {
+		return nil, fmt.Errorf("%s: ответ не IP-адрес", url)
+	}
+	return ip, nil
+}
@@ -0,0 +1,93 @@
+package netdiag
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Hop — узел маршрута.
+type Hop struct {
+	TTL     int     `json:"ttl"`
+	Address string  `json:"address,omitempty"` // Пусто — узел не ответил
+	RttMs   float64 `json:"rtt_ms,omitempty"`
+}
+
+// TraceResult — маршрут до хоста.
+type TraceResult struct {
+	Host    string `json:"host"`
+	Tool    string `json:"tool"` // traceroute или tracepath
+	Hops    []Hop  `json:"hops"`
+	Reached bool   `json:"reached"` // Последний узел ответил
+}
+
+var (
+	traceHopRe = regexp.MustCompile(`^\s*(\d+)\??:?\s+(.*)$`)
+	traceRttRe = regexp.MustCompile(`([\d.]+)\s*ms`)
+)
+
+// Traceroute — маршрут до хоста через traceroute (или tracepath).
+func (d *Diag) Traceroute(ctx context.Context, host string, maxHops int) (TraceResult, error) {
+	if err := ValidHost(host); err != nil {
+		return TraceResult{}, err
+	}
+	if maxHops <= 0 {
+		maxHops = DefaultMaxHops
+	}
+	maxHops = min(maxHops, MaxHops)
+	hops := strconv.Itoa(maxHops)
+	tool, args := "traceroute", []string{"-n", "-q", "1", "-w", "2", "-m", hops, host}
+	bin, err := d.lookPath(tool)
+	if err != nil {
+		tool, args = "tracepath", []string{"-n", "-m", hops, host}
+		if bin, err = d.lookPath(tool); err != nil {
+			return TraceResult{}, fmt.Errorf("%w: traceroute или tracepath", ErrNoTool)
+		}
+	}
+	stdout, stderr, code, err := d.run(ctx, bin, args...)
+	if err != nil {
+		return TraceResult{}, err
+	}
+	res := TraceResult{Host: host, Tool: tool, Hops: parseTrace(string(stdout))}
+	if len(res.Hops) == 0 {
+		msg := firstLine(stderr)
+		if msg == "" {
+			msg = fmt.Sprintf("код возврата %d", code)
+		}
+		return TraceResult{}, fmt.Errorf("%s %s: %s", tool, host, msg)
+	}
+	res.Reached = res.Hops[len(res.Hops)-1].Address != ""
+	return res, nil
+}
+
+// parseTrace — узлы из вывода traceroute -n -q 1 или tracepath -n; у
+// tracepath строки одного TTL повторяются — берётся первая с ответом.
+func parseTrace(out string) []Hop {
+	byTTL := map[int]Hop{}
+	for _, line := range strings.Split(out, "\n") {
+		m := traceHopRe.FindStringSubmatch(line)
+		if m == nil || strings.Contains(line, "LOCALHOST") {
+			continue
+		}
+		ttl, _ := strconv.Atoi(m[1])
+		hop := Hop{TTL: ttl}
+		if fields := strings.Fields(m[2]); len(fields) > 0 && fields[0] != "*" && fields[0] != "no" {
+			hop.Address = fields[0]
+			if r := traceRttRe.FindStringSubmatch(m[2]); r != nil {
+				hop.RttMs, _ = strconv.ParseFloat(r[1], 64)
+			}
+		}
+		if prev, ok := byTTL[ttl]; !ok || prev.Address == "" {
+			byTTL[ttl] = hop
+		}
+	}
+	hops := make([]Hop, 0, len(byTTL))
+	for _, h := range byTTL {
+		hops = append(hops, h)
+	}
+	sort.Slice(hops, func(i, j int) bool { return hops[i].TTL < hops[j].TTL })
+	return hops
+}
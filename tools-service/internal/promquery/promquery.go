@@ -0,0 +1,392 @@
+// Package promquery — запросы к Prometheus (HTTP API v1) с компактной сводкой
+// рядов, чтобы агент отвечал о трендах по реальным метрикам, а не по
+// одномоментному sysload.
+//
+// Для диапазонного запроса каждый ряд сворачивается в first/last/min/max/avg,
+// направление тренда и несколько усреднённых точек — модели не нужно
+// разбирать сотни сэмплов.
+package promquery
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Config — подключение к Prometheus.
+type Config struct {
+	URL       string // PROMETHEUS_URL, например http://prometheus:9090
+	Token     string // PROMETHEUS_TOKEN: Bearer-токен (опционально)
+	MaxSeries int    // Сколько рядов возвращать (остальные отбрасываются)
+	Samples   int    // Точек в сводке ряда
+	Timeout   time.Duration
+}
+
+// DefaultConfig — настройки из переменных окружения.
+func DefaultConfig() Config {
+	return Config{
+		URL:       strings.TrimRight(os.Getenv("PROMETHEUS_URL"), "/"),
+		Token:     os.Getenv("PROMETHEUS_TOKEN"),
+		MaxSeries: 10,
+		Samples:   12,
+		Timeout:   30 * time.Second,
+	}
+}
+
+// Ошибки запросов.
+var (
+	ErrNotConfigured = errors.New("Prometheus не настроен: задайте PROMETHEUS_URL")
+	ErrBadQuery      = errors.New("некорректный запрос PromQL")
+)
+
+// Presets — готовые запросы для типовых вопросов; $sel — дополнительный
+// селектор instance (см. Expand).
+var Presets = map[string]string{
+	"cpu":     `100 - avg by (instance) (rate(node_cpu_seconds_total{mode="idle"$sel}[5m])) * 100`,
+	"memory":  `(1 - node_memory_MemAvailable_bytes{$sel} / node_memory_MemTotal_bytes{$sel}) * 100`,
+	"disk":    `(1 - node_filesystem_avail_bytes{fstype!~"tmpfs|overlay"$sel} / node_filesystem_size_bytes{fstype!~"tmpfs|overlay"$sel}) * 100`,
+	"load":    `node_load1{$sel}`,
+	"network": `sum by (instance) (rate(node_network_receive_bytes_total{device!="lo"$sel}[5m]) + rate(node_network_transmit_bytes_total{device!="lo"$sel}[5m]))`,
+}
+
+// Expand — PromQL для пресета (cpu, memory, disk, load, network) с фильтром
+// по подстроке instance; обычный запрос возвращается как есть.
+func Expand(query, instance string) string {
+	preset, ok := Presets[strings.ToLower(strings.TrimSpace(query))]
+	if !ok {
+		return query
+	}
+	sel := ""
+	if instance != "" {
+		sel = `,instance=~".*` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(regexpQuote(instance)) + `.*"`
+	}
+	return strings.ReplaceAll(strings.ReplaceAll(preset, "$sel", sel), "{,", "{")
+}
+
+func regexpQuote(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if strings.ContainsRune(`.+*?()|[]{}^$`, r) {
+			b.WriteRune('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// Series — сводка одного ряда.
+type Series struct {
+	Metric  string            `json:"metric"` // Имя и метки: node_load1{instance="web:9100"}
+	Labels  map[string]string `json:"-"`
+	Value   *float64          `json:"value,omitempty"` // Мгновенный запрос
+	Points  int               `json:"points,omitempty"`
+	First   *float64          `json:"first,omitempty"`
+	Last    *float64          `json:"last,omitempty"`
+	Min     *float64          `json:"min,omitempty"`
+	Max     *float64          `json:"max,omitempty"`
+	Avg     *float64          `json:"avg,omitempty"`
+	Change  *float64          `json:"change_pct,omitempty"` // Изменение last относительно first, %
+	Trend   string            `json:"trend,omitempty"`      // рост, падение, стабильно
+	Samples []float64         `json:"samples,omitempty"`    // Усреднённые точки по равным интервалам
+}
+
+// Result — ответ на запрос.
+type Result struct {
+	Query   string     `json:"query"`
+	Type    string     `json:"type"`            // vector, matrix, scalar
+	Start   *time.Time `json:"start,omitempty"` // Диапазонный запрос
+	End     *time.Time `json:"end,omitempty"`
+	Step    string     `json:"step,omitempty"`
+	Series  []Series   `json:"series"`
+	Total   int        `json:"total"` // Рядов до ограничения MaxSeries
+	Warning string     `json:"warning,omitempty"`
+}
+
+// Client — клиент Prometheus.
+type Client struct {
+	cfg  Config
+	http *http.Client
+}
+
+// New — клиент с настройками cfg.
+func New(cfg Config) *Client {
+	if cfg.MaxSeries <= 0 {
+		cfg.MaxSeries = 10
+	}
+	if cfg.Samples <= 0 {
+		cfg.Samples = 12
+	}
+	return &Client{cfg: cfg, http: &http.Client{Timeout: cfg.Timeout}}
+}
+
+type apiResponse struct {
+	Status    string   `json:"status"`
+	ErrorType string   `json:"errorType"`
+	Error     string   `json:"error"`
+	Warnings  []string `json:"warnings"`
+	Data      struct {
+		ResultType string          `json:"resultType"`
+		Result     json.RawMessage `json:"result"`
+	} `json:"data"`
+}
+
+type sample [2]interface{} // [unix-время, "значение"]
+
+func (s sample) value() (float64, bool) {
+	str, _ := s[1].(string)
+	v, err := strconv.ParseFloat(str, 64)
+	if err != nil || math.IsNaN(v) || math.IsInf(v, 0) {
+		return 0, false
+	}
+	return v, true
+}
+
+func (c *Client) get(ctx context.Context, path string, params url.Values) (*apiResponse, error) {
+	if c.cfg.URL == "" {
+		return nil, ErrNotConfigured
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.cfg.URL+path+"?"+params.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	if c.cfg.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.cfg.Token)
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("Prometheus недоступен: %w", err)
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 32<<20))
+	var out apiResponse
+	if err := json.Unmarshal(data, &out); err != nil {
+		return nil, fmt.Errorf("Prometheus вернул %d: %s", resp.StatusCode, strings.TrimSpace(string(data)))
+	}
+	if out.Status != "success" {
+		if out.ErrorType == "bad_data" {
+			return nil, fmt.Errorf("%w: %s", ErrBadQuery, out.Error)
+		}
+		return nil, fmt.Errorf("Prometheus: %s: %s", out.ErrorType, out.Error)
+	}
+	return &out, nil
+}
+
+// Instant — мгновенный запрос (/api/v1/query) на момент at (нулевое — сейчас).
+func (c *Client) Instant(ctx context.Context, query string, at time.Time) (Result, error) {
+	params := url.Values{"query": {query}}
+	if !at.IsZero() {
+		params.Set("time", strconv.FormatInt(at.Unix(), 10))
+	}
+	resp, err := c.get(ctx, "/api/v1/query", params)
+	if err != nil {
+		return Result{}, err
+	}
+	res := Result{Query: query, Type: resp.Data.ResultType, Warning: strings.Join(resp.Warnings, "; ")}
+	switch resp.Data.ResultType {
+	case "vector":
+		var vec []struct {
+			Metric map[string]string `json:"metric"`
+			Value  sample            `json:"value"`
+		}
+		json.Unmarshal(resp.Data.Result, &vec)
+		for _, v := range vec {
+			s := Series{Metric: metricName(v.Metric), Labels: v.Metric}
+			if f, ok := v.Value.value(); ok {
+				s.Value = round(f)
+			}
+			res.Series = append(res.Series, s)
+		}
+	case "scalar", "string":
+		var v sample
+		json.Unmarshal(resp.Data.Result, &v)
+		s := Series{Metric: "scalar"}
+		if f, ok := v.value(); ok {
+			s.Value = round(f)
+		}
+		res.Series = []Series{s}
+	default:
+		return Result{}, fmt.Errorf("%w: результат %s — используйте range вместо селектора [диапазон]", ErrBadQuery, resp.Data.ResultType)
+	}
+	c.limit(&res, func(s Series) float64 { return deref(s.Value) })
+	return res, nil
+}
+
+// Range — диапазонный запрос (/api/v1/query_range) за [start, end] с шагом step
+// (0 — подбирается так, чтобы вышло ~240 точек).
+func (c *Client) Range(ctx context.Context, query string, start, end time.Time, step time.Duration) (Result, error) {
+	if !end.After(start) {
+		return Result{}, fmt.Errorf("%w: конец интервала раньше начала", ErrBadQuery)
+	}
+	if step <= 0 {
+		step = end.Sub(start) / 240
+		if step < 15*time.Second {
+			step = 15 * time.Second
+		}
+		step = step.Round(time.Second)
+	}
+	params := url.Values{
+		"query": {query},
+		"start": {strconv.FormatInt(start.Unix(), 10)},
+		"end":   {strconv.FormatInt(end.Unix(), 10)},
+		"step":  {strconv.FormatFloat(step.Seconds(), 'f', -1, 64)},
+	}
+	resp, err := c.get(ctx, "/api/v1/query_range", params)
+	if err != nil {
+		return Result{}, err
+	}
+	var matrix []struct {
+		Metric map[string]string `json:"metric"`
+		Values []sample          `json:"values"`
+	}
+	json.Unmarshal(resp.Data.Result, &matrix)
+	res := Result{Query: query, Type: resp.Data.ResultType, Start: &start, End: &end, Step: step.String(), Warning: strings.Join(resp.Warnings, "; ")}
+	for _, m := range matrix {
+		values := make([]float64, 0, len(m.Values))
+		for _, v := range m.Values {
+			if f, ok := v.value(); ok {
+				values = append(values, f)
+			}
+		}
+		res.Series = append(res.Series, summarize(metricName(m.Metric), m.Metric, values, c.cfg.Samples))
+	}
+	c.limit(&res, func(s Series) float64 { return deref(s.Avg) })
+	return res, nil
+}
+
+// limit — оставляет MaxSeries рядов с наибольшим значением key.
+func (c *Client) limit(res *Result, key func(Series) float64) {
+	res.Total = len(res.Series)
+	if res.Series == nil {
+		res.Series = []Series{}
+	}
+	sort.SliceStable(res.Series, func(i, j int) bool { return key(res.Series[i]) > key(res.Series[j]) })
+	if len(res.Series) > c.cfg.MaxSeries {
+		res.Series = res.Series[:c.cfg.MaxSeries]
+	}
+}
+
+// summarize — сводка ряда: крайние и средние значения, тренд и n усреднённых точек.
+func summarize(name string, labels map[string]string, values []float64, n int) Series {
+	s := Series{Metric: name, Labels: labels, Points: len(values)}
+	if len(values) == 0 {
+		return s
+	}
+	first, last := values[0], values[len(values)-1]
+	lo, hi, sum := first, first, 0.0
+	for _, v := range values {
+		lo, hi, sum = math.Min(lo, v), math.Max(hi, v), sum+v
+	}
+	avg := sum / float64(len(values))
+	s.First, s.Last, s.Min, s.Max, s.Avg = round(first), round(last), round(lo), round(hi), round(avg)
+
+	s.Trend = "стабильно"
+	if first != 0 {
+		s.Change = round((last - first) / math.Abs(first) * 100)
+	}
+	// Тренд — по средним первой и последней четверти, чтобы единичный выброс его не определял
+	q := len(values) / 4
+	if q == 0 {
+		q = 1
+	}
+	head, tail := mean(values[:q]), mean(values[len(values)-q:])
+	if spread := hi - lo; spread > 0 && math.Abs(tail-head) > spread*0.1 {
+		if tail > head {
+			s.Trend = "рост"
+		} else {
+			s.Trend = "падение"
+		}
+	}
+
+	if n > len(values) {
+		n = len(values)
+	}
+	for i := 0; i < n; i++ {
+		from, to := i*len(values)/n, (i+1)*len(values)/n
+		s.Samples = append(s.Samples, deref(round(mean(values[from:to]))))
+	}
+	return s
+}
+
+func mean(v []float64) float64 {
+	if len(v) == 0 {
+		return 0
+	}
+	sum := 0.0
+	for _, x := range v {
+		sum += x
+	}
+	return sum / float64(len(v))
+}
+
+// round — значение с тремя значащими знаками после запятой (для компактного JSON).
+func round(v float64) *float64 {
+	r := math.Round(v*1000) / 1000
+	return &r
+}
+
+func deref(v *float64) float64 {
+	if v == nil {
+		return math.Inf(-1)
+	}
+	return *v
+}
+
+// metricName — имя ряда в записи PromQL: name{k="v",...}.
+func metricName(labels map[string]string) string {
+	name := labels["__name__"]
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		if k != "__name__" {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	parts := make([]string, len(keys))
+	for i, k := range keys {
+		parts[i] = k + "=" + strconv.Quote(labels[k])
+	}
+	if len(parts) == 0 && name == "" {
+		return "{}"
+	}
+	if len(parts) == 0 {
+		return name
+	}
+	return name + "{" + strings.Join(parts, ",") + "}"
+}
+
+// ParseDuration — длительность в записи Prometheus: 30s, 15m, 6h, 7d, 2w или их сочетание (1h30m).
+func ParseDuration(s string) (time.Duration, error) {
+	s = strings.TrimSpace(s)
+	var d time.Duration
+	num := ""
+	for _, r := range s {
+		if r >= '0' && r <= '9' {
+			num += string(r)
+			continue
+		}
+		n, err := strconv.Atoi(num)
+		if err != nil {
+			return 0, fmt.Errorf("длительность %q: ожидается вида 24h, 7d", s)
+		}
+		num = ""
+		unit, ok := map[rune]time.Duration{'s': time.Second, 'm': time.Minute, 'h': time.Hour, 'd': 24 * time.Hour, 'w': 7 * 24 * time.Hour}[r]
+		if !ok {
+			return 0, fmt.Errorf("длительность %q: неизвестная единица %q", s, r)
+		}
+		d += time.Duration(n) * unit
+	}
+	if num != "" || d <= 0 {
+		return 0, fmt.Errorf("длительность %q: ожидается вида 24h, 7d", s)
+	}
+	return d, nil
+}
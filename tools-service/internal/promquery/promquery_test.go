@@ -0,0 +1,98 @@
+package promquery
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestRange_Summary — диапазонный запрос сворачивается в сводку с трендом.
+func TestRange_Summary(t *testing.T) {
+	var gotQuery, gotStep string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer tok" {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"status":"error","errorType":"unauthorized","error":"no token"}`))
+			return
+		}
+		switch r.URL.Path {
+		case "/api/v1/query_range":
+			gotQuery, gotStep = r.URL.Query().Get("query"), r.URL.Query().Get("step")
+			var values []string
+			for i := 0; i < 96; i++ {
+				values = append(values, fmt.Sprintf(`[%d,"%d"]`, 1700000000+i*900, 10+i/2))
+			}
+			fmt.Fprintf(w, `{"status":"success","data":{"resultType":"matrix","result":[
+				{"metric":{"instance":"web:9100"},"values":[%s]},
+				{"metric":{"instance":"db:9100"},"values":[[1700000000,"5"],[1700000900,"NaN"],[1700001800,"5"]]}
+			]}}`, strings.Join(values, ","))
+		case "/api/v1/query":
+			if strings.Contains(r.URL.Query().Get("query"), "(") && !strings.Contains(r.URL.Query().Get("query"), ")") {
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte(`{"status":"error","errorType":"bad_data","error":"unclosed left parenthesis"}`))
+				return
+			}
+			w.Write([]byte(`{"status":"success","data":{"resultType":"vector","result":[
+				{"metric":{"__name__":"up","job":"node"},"value":[1700000000,"1"]},
+				{"metric":{"__name__":"up","job":"api"},"value":[1700000000,"0"]}
+			]}}`))
+		}
+	}))
+	defer srv.Close()
+	c := New(Config{URL: srv.URL, Token: "tok", Samples: 4, Timeout: 5 * time.Second})
+	ctx := context.Background()
+
+	end := time.Unix(1700000000, 0).Add(24 * time.Hour)
+	res, err := c.Range(ctx, Expand("cpu", "web"), end.Add(-24*time.Hour), end, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(gotQuery, `{mode="idle",instance=~".*web.*"}`) || gotStep != "360" {
+		t.Errorf("запрос %q, шаг %q", gotQuery, gotStep)
+	}
+	if res.Total != 2 || res.Series[0].Metric != `{instance="web:9100"}` {
+		t.Fatalf("ряды: %+v", res.Series)
+	}
+	web := res.Series[0]
+	if web.Points != 96 || *web.First != 10 || *web.Last != 57 || web.Trend != "рост" || len(web.Samples) != 4 || *web.Change != 470 {
+		t.Errorf("сводка: %+v", web)
+	}
+	if db := res.Series[1]; db.Points != 2 || db.Trend != "стабильно" {
+		t.Errorf("NaN должен пропускаться: %+v", db)
+	}
+
+	inst, err := c.Instant(ctx, "up", time.Time{})
+	if err != nil || len(inst.Series) != 2 || inst.Series[0].Metric != `up{job="node"}` || *inst.Series[1].Value != 0 {
+		t.Fatalf("Instant: %+v, %v", inst, err)
+	}
+	if _, err := c.Instant(ctx, "rate(up[5m]", time.Time{}); !errors.Is(err, ErrBadQuery) {
+		t.Errorf("ожидалась ErrBadQuery, получено %v", err)
+	}
+	if _, err := New(Config{}).Instant(ctx, "up", time.Time{}); err != ErrNotConfigured {
+		t.Errorf("ожидалась ErrNotConfigured, получено %v", err)
+	}
+}
+
+// TestExpand — пресеты с фильтром instance, обычный PromQL без изменений и длительности.
+func TestExpand(t *testing.T) {
+	if got := Expand("load", ""); got != "node_load1{}" {
+		t.Errorf("load: %q", got)
+	}
+	if got := Expand("Memory", "10.0.0.5"); !strings.Contains(got, `node_memory_MemTotal_bytes{instance=~".*10\\.0\\.0\\.5.*"}`) {
+		t.Errorf("memory: %q", got)
+	}
+	if got := Expand("sum(rate(http_requests_total[5m]))", "web"); got != "sum(rate(http_requests_total[5m]))" {
+		t.Errorf("PromQL изменён: %q", got)
+	}
+	if d, err := ParseDuration("1d12h"); err != nil || d != 36*time.Hour {
+		t.Errorf("ParseDuration: %v %v", d, err)
+	}
+	if _, err := ParseDuration("5x"); err == nil {
+		t.Error("неизвестная единица должна давать ошибку")
+	}
+}
@@ -0,0 +1,256 @@
+// Package rpc — gRPC-сервер внутреннего API tools-service для agent-service
+// (контракт — proto/tools/v1/tools.proto).
+//
+// Методы вызывают executor напрямую с теми же проверками, что и HTTP-эндпоинты:
+// токен и роль (auth.Authorize), ограничения команд (auth.CheckCommand) и
+// рабочее пространство (workspace). Метаданные authorization, x-request-id,
+// traceparent и x-workspace-root — как одноимённые заголовки HTTP. Ошибки —
+// статусы gRPC с google.rpc.ErrorInfo, reason — код apierror.
+package rpc
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"os"
+	"sync"
+
+	"github.com/neo-2022/openclaw-memory/tools-service/internal/auth"
+	"github.com/neo-2022/openclaw-memory/tools-service/internal/executor"
+	"github.com/neo-2022/openclaw-memory/tools-service/internal/logger"
+	"github.com/neo-2022/openclaw-memory/tools-service/internal/toolspb"
+	"github.com/neo-2022/openclaw-memory/tools-service/internal/tracing"
+	"github.com/neo-2022/openclaw-memory/tools-service/internal/workspace"
+	"go.opentelemetry.io/otel/attribute"
+	otelcodes "go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// errorDomain — domain в ErrorInfo ошибок tools-service.
+const errorDomain = "tools-service"
+
+// grpcCodes — статус gRPC для HTTP-статуса ошибки.
+var grpcCodes = map[int]codes.Code{
+	http.StatusBadRequest:          codes.InvalidArgument,
+	http.StatusUnauthorized:        codes.Unauthenticated,
+	http.StatusForbidden:           codes.PermissionDenied,
+	http.StatusNotFound:            codes.NotFound,
+	http.StatusInternalServerError: codes.Internal,
+}
+
+// Server — реализация toolspb.ToolsServer.
+type Server struct {
+	toolspb.UnimplementedToolsServer
+	// Tokens — токены TOOLS_AUTH_TOKENS; пусто — legacy-режим, как у HTTP API.
+	Tokens map[string]auth.Role
+}
+
+// call — состояние одного вызова: роль, X-Request-ID, каталог пространства и спан.
+type call struct {
+	ctx  context.Context
+	role auth.Role
+	cid  string
+	root string
+	span trace.Span
+}
+
+// begin — открывает спан вызова, проверяет токен и роль и каталог рабочего
+// пространства. Ошибку begin возвращает клиенту, спан закрывается в end.
+func (s *Server) begin(ctx context.Context, method string, required auth.Role) (*call, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	ctx, span := tracing.StartRPC(ctx, method)
+	c := &call{span: span, cid: first(md, "x-request-id")}
+	if c.cid != "" {
+		span.SetAttributes(attribute.String("request_id", c.cid))
+	}
+	c.ctx = logger.WithCorrelationID(ctx, c.cid)
+	role, denial := auth.Authorize(required, s.Tokens, first(md, "authorization"), method)
+	if denial != nil {
+		return c, c.fail(denial.Status, denial.Code, denial.Message, denial.Hint)
+	}
+	c.role = role
+	root, err := workspace.Dir(first(md, "x-workspace-root"))
+	if err != nil {
+		logger.С(c.ctx).Warn("Некорректное рабочее пространство", slog.String("ошибка", err.Error()))
+		return c, c.fail(http.StatusBadRequest, "BAD_REQUEST", err.Error(), "Проверьте каталог рабочего пространства")
+	}
+	c.root = root
+	return c, nil
+}
+
+// end — закрывает спан вызова.
+func (c *call) end() {
+	c.span.End()
+}
+
+// fail — ошибка вызова: статус gRPC по HTTP-статусу, код apierror и подсказка в ErrorInfo.
+func (c *call) fail(httpStatus int, code, message, hint string) error {
+	c.span.SetStatus(otelcodes.Error, message)
+	grpcCode, ok := grpcCodes[httpStatus]
+	if !ok {
+		grpcCode = codes.Unknown
+	}
+	info := &errdetails.ErrorInfo{Reason: code, Domain: errorDomain, Metadata: map[string]string{}}
+	if hint != "" {
+		info.Metadata["hint"] = hint
+	}
+	if c.cid != "" {
+		info.Metadata["request_id"] = c.cid
+	}
+	st, err := status.New(grpcCode, message).WithDetails(info)
+	if err != nil {
+		return status.Error(grpcCode, message)
+	}
+	return st.Err()
+}
+
+// path — путь файлового инструмента в рабочем пространстве; выход за его
+// пределы — PERMISSION_DENIED, как 403 у HTTP API.
+func (c *call) path(path string) (string, error) {
+	resolved, err := workspace.Resolve(c.root, path, workspace.Confined())
+	if err != nil {
+		logger.С(c.ctx).Warn("Путь вне рабочего пространства", slog.String("путь", path), slog.String("пространство", c.root))
+		return "", c.fail(http.StatusForbidden, "FORBIDDEN", err.Error(), "Используйте путь внутри рабочего пространства или переключитесь на другое")
+	}
+	return resolved, nil
+}
+
+// Execute — выполнение команды; stdout и stderr отправляются клиенту по мере
+// появления, последним событием — итог команды.
+func (s *Server) Execute(req *toolspb.ExecuteRequest, stream toolspb.Tools_ExecuteServer) error {
+	c, err := s.begin(stream.Context(), "Execute", auth.RoleAdmin)
+	defer c.end()
+	if err != nil {
+		return err
+	}
+	if hint, err := auth.CheckCommand(c.role, req.GetCommand()); err != nil {
+		logger.С(c.ctx).Warn("Команда отклонена", slog.String("команда", req.GetCommand()), slog.String("роль", string(c.role)), slog.String("ошибка", err.Error()))
+		return c.fail(http.StatusForbidden, "FORBIDDEN", err.Error(), hint)
+	}
+	if err := executor.ValidateEnv(req.GetEnv()); err != nil {
+		return c.fail(http.StatusBadRequest, "BAD_REQUEST", err.Error(), "Имена переменных — латиница, цифры и _; PATH, LD_PRELOAD и подобные задавать нельзя")
+	}
+
+	var (
+		mu      sync.Mutex
+		sendErr error
+	)
+	output := func(name string, data []byte) {
+		mu.Lock()
+		defer mu.Unlock()
+		if sendErr == nil {
+			sendErr = stream.Send(&toolspb.ExecuteEvent{Event: &toolspb.ExecuteEvent_Output{
+				Output: &toolspb.OutputChunk{Stream: name, Data: data},
+			}})
+		}
+	}
+	logger.С(c.ctx).Info("Выполнение команды", slog.String("команда", req.GetCommand()), slog.String("роль", string(c.role)), slog.String("каталог", c.root), slog.Int("переменных_env", len(req.GetEnv())))
+	result := executor.ExecuteCommandIn(c.root, req.GetEnv(), req.GetCommand(), output)
+	logger.С(c.ctx).Info("Результат выполнения", slog.Int("код", result.ReturnCode), slog.Int("stdout_байт", len(result.Stdout)), slog.Int("stderr_байт", len(result.Stderr)))
+
+	mu.Lock()
+	defer mu.Unlock()
+	if sendErr != nil {
+		return sendErr
+	}
+	return stream.Send(&toolspb.ExecuteEvent{Event: &toolspb.ExecuteEvent_Result{Result: &toolspb.ExecuteResult{
+		Stdout:     result.Stdout,
+		Stderr:     result.Stderr,
+		ReturnCode: int32(result.ReturnCode),
+		Error:      result.Error,
+	}}})
+}
+
+// ReadFile — содержимое файла; отсутствующий файл — NOT_FOUND.
+func (s *Server) ReadFile(ctx context.Context, req *toolspb.ReadFileRequest) (*toolspb.ReadFileResponse, error) {
+	c, err := s.begin(ctx, "ReadFile", auth.RoleViewer)
+	defer c.end()
+	if err != nil {
+		return nil, err
+	}
+	path, err := c.path(req.GetPath())
+	if err != nil {
+		return nil, err
+	}
+	logger.С(c.ctx).Info("Чтение файла", slog.String("путь", path))
+	content, err := executor.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, c.fail(http.StatusNotFound, "NOT_FOUND", err.Error(), "")
+	}
+	if err != nil {
+		logger.С(c.ctx).Error("Ошибка чтения файла", slog.String("путь", path), slog.String("ошибка", err.Error()))
+		return nil, c.fail(http.StatusInternalServerError, "INTERNAL_ERROR", err.Error(), "Проверьте путь и права доступа")
+	}
+	return &toolspb.ReadFileResponse{Content: content}, nil
+}
+
+// WriteFile — запись файла.
+func (s *Server) WriteFile(ctx context.Context, req *toolspb.WriteFileRequest) (*toolspb.WriteFileResponse, error) {
+	c, err := s.begin(ctx, "WriteFile", auth.RoleOperator)
+	defer c.end()
+	if err != nil {
+		return nil, err
+	}
+	path, err := c.path(req.GetPath())
+	if err != nil {
+		return nil, err
+	}
+	logger.С(c.ctx).Info("Запись файла", slog.String("путь", path), slog.Int("байт", len(req.GetContent())))
+	if err := executor.WriteFile(path, req.GetContent()); err != nil {
+		logger.С(c.ctx).Error("Ошибка записи файла", slog.String("путь", path), slog.String("ошибка", err.Error()))
+		return nil, c.fail(http.StatusInternalServerError, "INTERNAL_ERROR", err.Error(), "Проверьте путь и права доступа")
+	}
+	return &toolspb.WriteFileResponse{}, nil
+}
+
+// ListDir — содержимое каталога.
+func (s *Server) ListDir(ctx context.Context, req *toolspb.ListDirRequest) (*toolspb.ListDirResponse, error) {
+	c, err := s.begin(ctx, "ListDir", auth.RoleViewer)
+	defer c.end()
+	if err != nil {
+		return nil, err
+	}
+	path, err := c.path(req.GetPath())
+	if err != nil {
+		return nil, err
+	}
+	logger.С(c.ctx).Info("Листинг директории", slog.String("путь", path))
+	files, err := executor.ListDirectory(path)
+	if err != nil {
+		logger.С(c.ctx).Error("Ошибка чтения директории", slog.String("путь", path), slog.String("ошибка", err.Error()))
+		return nil, c.fail(http.StatusInternalServerError, "INTERNAL_ERROR", err.Error(), "Проверьте путь и права доступа")
+	}
+	return &toolspb.ListDirResponse{Files: files}, nil
+}
+
+// DeleteFile — удаление файла.
+func (s *Server) DeleteFile(ctx context.Context, req *toolspb.DeleteFileRequest) (*toolspb.DeleteFileResponse, error) {
+	c, err := s.begin(ctx, "DeleteFile", auth.RoleOperator)
+	defer c.end()
+	if err != nil {
+		return nil, err
+	}
+	path, err := c.path(req.GetPath())
+	if err != nil {
+		return nil, err
+	}
+	logger.С(c.ctx).Info("Удаление файла", slog.String("путь", path))
+	if err := executor.DeleteFile(path); err != nil {
+		logger.С(c.ctx).Error("Ошибка удаления файла", slog.String("путь", path), slog.String("ошибка", err.Error()))
+		return nil, c.fail(http.StatusInternalServerError, "INTERNAL_ERROR", err.Error(), "Проверьте путь и права доступа")
+	}
+	return &toolspb.DeleteFileResponse{}, nil
+}
+
+// first — первое значение ключа метаданных ("" — нет).
+func first(md metadata.MD, key string) string {
+	if v := md.Get(key); len(v) > 0 {
+		return v[0]
+	}
+	return ""
+}
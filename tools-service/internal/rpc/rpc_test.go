@@ -0,0 +1,135 @@
+package rpc
+
+import (
+	"context"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/neo-2022/openclaw-memory/tools-service/internal/auth"
+	"github.com/neo-2022/openclaw-memory/tools-service/internal/toolspb"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// tokens — токены тестового сервера.
+var tokens = map[string]auth.Role{"admin-token": auth.RoleAdmin, "viewer-token": auth.RoleViewer}
+
+// newClient — клиент gRPC к Server через соединение в памяти.
+func newClient(t *testing.T) toolspb.ToolsClient {
+	lis := bufconn.Listen(1 << 20)
+	srv := grpc.NewServer()
+	toolspb.RegisterToolsServer(srv, &Server{Tokens: tokens})
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return toolspb.NewToolsClient(conn)
+}
+
+// withToken — исходящий контекст с токеном и рабочим пространством root.
+func withToken(token, root string) context.Context {
+	return metadata.AppendToOutgoingContext(context.Background(),
+		"authorization", "Bearer "+token, "x-request-id", "req-1", "x-workspace-root", root)
+}
+
+// reason — код apierror из ErrorInfo ошибки.
+func reason(err error) string {
+	for _, d := range status.Convert(err).Details() {
+		if info, ok := d.(*errdetails.ErrorInfo); ok {
+			return info.Reason
+		}
+	}
+	return ""
+}
+
+// TestFiles — файловые методы работают в рабочем пространстве и проверяют роль.
+func TestFiles(t *testing.T) {
+	root := t.TempDir()
+	client := newClient(t)
+	admin := withToken("admin-token", root)
+
+	if _, err := client.WriteFile(admin, &toolspb.WriteFileRequest{Path: "a.txt", Content: "hello"}); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(root, "a.txt")); string(data) != "hello" {
+		t.Fatalf("файл не записан в пространство: %q", data)
+	}
+	read, err := client.ReadFile(withToken("viewer-token", root), &toolspb.ReadFileRequest{Path: "a.txt"})
+	if err != nil || read.Content != "hello" {
+		t.Fatalf("чтение: %+v, %v", read, err)
+	}
+	list, err := client.ListDir(admin, &toolspb.ListDirRequest{})
+	if err != nil || len(list.Files) != 1 {
+		t.Fatalf("список: %+v, %v", list, err)
+	}
+	if _, err := client.DeleteFile(admin, &toolspb.DeleteFileRequest{Path: "a.txt"}); err != nil {
+		t.Fatal(err)
+	}
+	_, err = client.ReadFile(admin, &toolspb.ReadFileRequest{Path: "a.txt"})
+	if status.Code(err) != codes.NotFound || reason(err) != "NOT_FOUND" {
+		t.Errorf("удалённый файл: %v", err)
+	}
+
+	_, err = client.WriteFile(withToken("viewer-token", root), &toolspb.WriteFileRequest{Path: "b.txt"})
+	if status.Code(err) != codes.PermissionDenied || reason(err) != "FORBIDDEN" {
+		t.Errorf("запись с ролью viewer: %v", err)
+	}
+	_, err = client.ReadFile(context.Background(), &toolspb.ReadFileRequest{Path: "a.txt"})
+	if status.Code(err) != codes.Unauthenticated {
+		t.Errorf("без токена: %v", err)
+	}
+	_, err = client.ReadFile(admin, &toolspb.ReadFileRequest{Path: "../outside.txt"})
+	if status.Code(err) != codes.PermissionDenied {
+		t.Errorf("путь вне пространства: %v", err)
+	}
+}
+
+// TestExecute — вывод приходит до итога команды; роль и белый список проверяются.
+func TestExecute(t *testing.T) {
+	root := t.TempDir()
+	client := newClient(t)
+	stream, err := client.Execute(withToken("admin-token", root), &toolspb.ExecuteRequest{Command: "echo line"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out string
+	var result *toolspb.ExecuteResult
+	for {
+		ev, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if o := ev.GetOutput(); o != nil {
+			if result != nil {
+				t.Fatal("вывод после итога команды")
+			}
+			out += o.Stream + ":" + string(o.Data)
+		} else {
+			result = ev.GetResult()
+		}
+	}
+	if out != "stdout:line\n" || result == nil || result.Stdout != "line\n" || result.ReturnCode != 0 {
+		t.Fatalf("вывод %q, итог %+v", out, result)
+	}
+
+	stream, _ = client.Execute(withToken("viewer-token", root), &toolspb.ExecuteRequest{Command: "echo line"})
+	if _, err := stream.Recv(); status.Code(err) != codes.PermissionDenied {
+		t.Errorf("execute с ролью viewer: %v", err)
+	}
+}
@@ -0,0 +1,166 @@
+package secaudit
+
+import (
+	"encoding/hex"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Listener — слушающий сокет.
+type Listener struct {
+	Proto   string `json:"proto"` // tcp, tcp6, udp, udp6
+	Address string `json:"address"`
+	Port    int    `json:"port"`
+	Public  bool   `json:"public"`            // Слушает все интерфейсы, а не только loopback
+	Process string `json:"process,omitempty"` // Имя процесса, если удалось определить
+}
+
+// PortsReport — результат проверки портов.
+type PortsReport struct {
+	Listeners []Listener `json:"listeners"`
+	Findings  []Finding  `json:"findings"`
+}
+
+// riskyPorts — сервисы, которые не должны быть доступны снаружи.
+var riskyPorts = map[int]string{
+	21:    "FTP (пароли открытым текстом)",
+	23:    "Telnet (пароли открытым текстом)",
+	111:   "rpcbind",
+	445:   "SMB",
+	2375:  "Docker API без TLS (полный контроль над хостом)",
+	3306:  "MySQL",
+	3389:  "RDP",
+	5432:  "PostgreSQL",
+	5900:  "VNC",
+	6379:  "Redis",
+	9200:  "Elasticsearch",
+	11211: "Memcached",
+	27017: "MongoDB",
+}
+
+// Ports — слушающие TCP-порты и UDP-сокеты из /proc/net (Linux).
+func (a *Auditor) Ports() (PortsReport, error) {
+	rep := PortsReport{Listeners: []Listener{}, Findings: []Finding{}}
+	procs := a.socketProcesses()
+	found := false
+	for _, proto := range []string{"tcp", "tcp6", "udp", "udp6"} {
+		data, ok, err := a.readFile("/proc/net/" + proto)
+		if err != nil {
+			rep.Findings = append(rep.Findings, unreadable("ports", "/proc/net/"+proto, err))
+			continue
+		}
+		if !ok {
+			continue
+		}
+		found = true
+		rep.Listeners = append(rep.Listeners, parseSockets(proto, data, procs)...)
+	}
+	if !found {
+		return rep, fmt.Errorf("нет /proc/net: проверка портов доступна только в Linux")
+	}
+	sort.Slice(rep.Listeners, func(i, j int) bool {
+		if rep.Listeners[i].Port != rep.Listeners[j].Port {
+			return rep.Listeners[i].Port < rep.Listeners[j].Port
+		}
+		return rep.Listeners[i].Proto < rep.Listeners[j].Proto
+	})
+	seen := map[int]bool{}
+	for _, l := range rep.Listeners {
+		if !l.Public || seen[l.Port] || !strings.HasPrefix(l.Proto, "tcp") {
+			continue
+		}
+		seen[l.Port] = true
+		who := ""
+		if l.Process != "" {
+			who = " (" + l.Process + ")"
+		}
+		if name, ok := riskyPorts[l.Port]; ok {
+			rep.Findings = append(rep.Findings, Finding{
+				Check:    "ports",
+				Severity: SeverityHigh,
+				Title:    fmt.Sprintf("Порт %d открыт на всех интерфейсах: %s", l.Port, name),
+				Detail:   l.Address + ":" + strconv.Itoa(l.Port) + who,
+				Fix:      "Привяжите сервис к 127.0.0.1 или закройте порт файрволом (ufw deny " + strconv.Itoa(l.Port) + ")",
+			})
+			continue
+		}
+		rep.Findings = append(rep.Findings, Finding{
+			Check:    "ports",
+			Severity: SeverityInfo,
+			Title:    fmt.Sprintf("Порт %d доступен снаружи", l.Port),
+			Detail:   l.Address + ":" + strconv.Itoa(l.Port) + who,
+			Fix:      "Убедитесь, что сервис должен быть доступен из сети",
+		})
+	}
+	return rep, nil
+}
+
+// parseSockets — слушающие сокеты из /proc/net/{tcp,udp}[6]: для TCP
+// состояние 0A (LISTEN), для UDP — несвязанные (07) с локальным портом.
+func parseSockets(proto string, data []byte, procs map[string]string) []Listener {
+	var out []Listener
+	for i, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		if i == 0 || len(fields) < 10 {
+			continue
+		}
+		state := fields[3]
+		if (strings.HasPrefix(proto, "tcp") && state != "0A") || (strings.HasPrefix(proto, "udp") && state != "07") {
+			continue
+		}
+		host, port, ok := parseHexAddr(fields[1])
+		if !ok || port == 0 {
+			continue
+		}
+		out = append(out, Listener{
+			Proto:   proto,
+			Address: host.String(),
+			Port:    port,
+			Public:  !host.IsLoopback(),
+			Process: procs[fields[9]],
+		})
+	}
+	return out
+}
+
+// parseHexAddr — адрес вида 0100007F:1F90 (байты в порядке хоста, little-endian по 4 байта).
+func parseHexAddr(s string) (net.IP, int, bool) {
+	h, p, ok := strings.Cut(s, ":")
+	raw, err := hex.DecodeString(h)
+	port, errP := strconv.ParseUint(p, 16, 16)
+	if !ok || err != nil || errP != nil || (len(raw) != 4 && len(raw) != 16) {
+		return nil, 0, false
+	}
+	ip := make(net.IP, len(raw))
+	for i := 0; i < len(raw); i += 4 {
+		ip[i], ip[i+1], ip[i+2], ip[i+3] = raw[i+3], raw[i+2], raw[i+1], raw[i]
+	}
+	return ip, int(port), true
+}
+
+// socketProcesses — inode сокета → имя процесса по /proc/*/fd; без прав на
+// чужие процессы видны только собственные.
+func (a *Auditor) socketProcesses() map[string]string {
+	out := map[string]string{}
+	fds, _ := filepath.Glob(a.path("/proc/[0-9]*/fd/*"))
+	for _, fd := range fds {
+		target, err := os.Readlink(fd)
+		if err != nil || !strings.HasPrefix(target, "socket:[") {
+			continue
+		}
+		inode := strings.TrimSuffix(strings.TrimPrefix(target, "socket:["), "]")
+		if _, ok := out[inode]; ok {
+			continue
+		}
+		comm, err := os.ReadFile(filepath.Join(filepath.Dir(filepath.Dir(fd)), "comm"))
+		if err == nil {
+			out[inode] = strings.TrimSpace(string(comm))
+		}
+	}
+	return out
+}
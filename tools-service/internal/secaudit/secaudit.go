@@ -0,0 +1,112 @@
+// Package secaudit — проверки безопасности хоста в структурированном виде:
+// открытые порты, пользователи с sudo, слабые настройки SSH, ожидающие
+// обновления безопасности и файлы, доступные на запись всем.
+//
+// Каждая проверка возвращает собранные данные и замечания (Finding) с
+// уровнем важности и советом по исправлению; сводный приоритетный отчёт
+// собирает составной скил security_audit в agent-service. Системные файлы
+// (/etc/passwd, /etc/ssh/sshd_config, /proc/net/tcp) читаются напрямую по
+// фиксированным путям; недоступный без root файл — замечание уровня info,
+// а не ошибка проверки.
+package secaudit
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// Уровни важности замечаний — от самого срочного.
+const (
+	SeverityCritical = "critical"
+	SeverityHigh     = "high"
+	SeverityMedium   = "medium"
+	SeverityLow      = "low"
+	SeverityInfo     = "info"
+)
+
+// Severities — уровни по убыванию важности.
+var Severities = []string{SeverityCritical, SeverityHigh, SeverityMedium, SeverityLow, SeverityInfo}
+
+// Finding — замечание проверки.
+type Finding struct {
+	Check    string `json:"check"` // ports, sudo, ssh, updates, writable
+	Severity string `json:"severity"`
+	Title    string `json:"title"`
+	Detail   string `json:"detail,omitempty"`
+	Fix      string `json:"fix,omitempty"` // Как исправить
+}
+
+// Auditor — проверки относительно корня файловой системы Root ("/" в работе,
+// временный каталог в тестах).
+type Auditor struct {
+	Root     string
+	lookPath func(string) (string, error)
+	run      func(ctx context.Context, name string, args ...string) (stdout []byte, code int, err error)
+}
+
+// New — проверки хоста.
+func New() *Auditor {
+	return &Auditor{Root: "/", lookPath: exec.LookPath, run: run}
+}
+
+// path — системный путь относительно Root.
+func (a *Auditor) path(p string) string {
+	return filepath.Join(a.Root, p)
+}
+
+// readFile — содержимое системного файла; ok=false — файла нет.
+func (a *Auditor) readFile(p string) (data []byte, ok bool, err error) {
+	data, err = os.ReadFile(a.path(p))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, false, nil
+	}
+	return data, err == nil, err
+}
+
+// unreadable — замечание о файле, который не удалось прочитать (нужен root).
+func unreadable(check, p string, err error) Finding {
+	return Finding{
+		Check:    check,
+		Severity: SeverityInfo,
+		Title:    "Не удалось прочитать " + p,
+		Detail:   err.Error(),
+		Fix:      "Запустите tools-service с правами на чтение файла, чтобы проверка была полной",
+	}
+}
+
+// run — запуск утилиты с LC_ALL=C; ненулевой код возврата не ошибка.
+func run(ctx context.Context, name string, args ...string) ([]byte, int, error) {
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Env = append(os.Environ(), "LC_ALL=C")
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	err := cmd.Run()
+	if ctx.Err() != nil {
+		return nil, -1, fmt.Errorf("%s: превышен таймаут", filepath.Base(name))
+	}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return out.Bytes(), exitErr.ExitCode(), nil
+	}
+	return out.Bytes(), 0, err
+}
+
+// lines — непустые строки без комментариев (#).
+func lines(data []byte) []string {
+	var out []string
+	for _, line := range strings.Split(string(data), "\n") {
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+		if line = strings.TrimSpace(line); line != "" {
+			out = append(out, line)
+		}
+	}
+	return out
+}
@@ -0,0 +1,167 @@
+package secaudit
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// fakeRoot — файловая система хоста во временном каталоге.
+func fakeRoot(t *testing.T, files map[string]string) *Auditor {
+	t.Helper()
+	root := t.TempDir()
+	for p, content := range files {
+		full := filepath.Join(root, p)
+		os.MkdirAll(filepath.Dir(full), 0o755)
+		if err := os.WriteFile(full, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	a := New()
+	a.Root = root
+	a.lookPath = func(string) (string, error) { return "", errors.New("not found") }
+	return a
+}
+
+// severities — уровень замечания по подстроке заголовка.
+func severityOf(findings []Finding, title string) string {
+	for _, f := range findings {
+		if strings.Contains(f.Title, title) {
+			return f.Severity
+		}
+	}
+	return ""
+}
+
+func TestPorts(t *testing.T) {
+	a := fakeRoot(t, map[string]string{
+		"/proc/net/tcp": `  sl  local_address rem_address   st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode
+   0: 00000000:18EB 00000000:0000 0A 00000000:00000000 00:00000000 00000000   999        0 1001 1 0 100 0 0 10 0
+   1: 0100007F:0CEA 00000000:0000 0A 00000000:00000000 00:00000000 00000000   999        0 1002 1 0 100 0 0 10 0
+   2: 00000000:0016 00000000:0000 0A 00000000:00000000 00:00000000 00000000     0        0 1003 1 0 100 0 0 10 0
+   3: 0100007F:A1B2 0100007F:0016 01 00000000:00000000 00:00000000 00000000     0        0 1004 1 0 100 0 0 10 0
+`,
+	})
+	rep, err := a.Ports()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rep.Listeners) != 3 || rep.Listeners[0].Port != 22 || rep.Listeners[1].Port != 3306 || rep.Listeners[1].Public || rep.Listeners[2].Port != 6379 {
+		t.Errorf("слушающие порты: %+v", rep.Listeners)
+	}
+	if severityOf(rep.Findings, "Порт 6379") != SeverityHigh || severityOf(rep.Findings, "Порт 22") != SeverityInfo || severityOf(rep.Findings, "Порт 3306") != "" {
+		t.Errorf("замечания: %+v", rep.Findings)
+	}
+	if ip, port, ok := parseHexAddr("00000000000000000000000001000000:0050"); !ok || port != 80 || ip.String() != "::1" {
+		t.Errorf("IPv6: %v %d", ip, port)
+	}
+}
+
+func TestSudo(t *testing.T) {
+	a := fakeRoot(t, map[string]string{
+		"/etc/passwd":           "root:x:0:0:root:/root:/bin/bash\ntoor:x:0:0::/root:/bin/sh\nalice:x:1000:1000::/home/alice:/bin/bash\n",
+		"/etc/group":            "sudo:x:27:alice,bob\nusers:x:100:carol\n",
+		"/etc/sudoers":          "Defaults env_reset\nroot ALL=(ALL:ALL) ALL\n%sudo ALL=(ALL:ALL) ALL\n",
+		"/etc/sudoers.d/deploy": "deploy ALL=(ALL) NOPASSWD: ALL\nbackup ALL=(root) NOPASSWD: /usr/bin/rsync\n",
+		"/etc/shadow":           "root:$6$x:19000::::::\nbob::19000::::::\n",
+	})
+	rep, err := a.Sudo()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, u := range rep.Users {
+		names = append(names, u.Name)
+	}
+	if strings.Join(names, ",") != "alice,backup,bob,deploy,toor" {
+		t.Errorf("пользователи: %v", names)
+	}
+	for title, want := range map[string]string{
+		"UID 0":                 SeverityCritical,
+		"без пароля для deploy": SeverityHigh,
+		"без пароля для backup": SeverityMedium,
+		"Пустой пароль у пользователя bob": SeverityCritical,
+	} {
+		if got := severityOf(rep.Findings, title); got != want {
+			t.Errorf("%s: %q, ожидали %q", title, got, want)
+		}
+	}
+}
+
+func TestSSH(t *testing.T) {
+	a := fakeRoot(t, map[string]string{
+		"/etc/ssh/sshd_config":              "Include /etc/ssh/sshd_config.d/*.conf\nPermitRootLogin yes\nX11Forwarding yes\nMatch User git\n  PasswordAuthentication yes\n",
+		"/etc/ssh/sshd_config.d/10-pw.conf": "PasswordAuthentication no\nMaxAuthTries 10\n",
+	})
+	rep, err := a.SSH()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !rep.Installed || len(rep.Files) != 2 || rep.Settings["passwordauthentication"] != "no" {
+		t.Errorf("настройки: %+v", rep)
+	}
+	if severityOf(rep.Findings, "вход root") != SeverityHigh || severityOf(rep.Findings, "принимает пароли") != "" ||
+		severityOf(rep.Findings, "X11Forwarding") != SeverityLow || severityOf(rep.Findings, "MaxAuthTries") != SeverityLow {
+		t.Errorf("замечания: %+v", rep.Findings)
+	}
+
+	if rep, _ := fakeRoot(t, nil).SSH(); rep.Installed || len(rep.Findings) != 0 {
+		t.Errorf("без sshd: %+v", rep)
+	}
+}
+
+func TestUpdates(t *testing.T) {
+	list := parseApt(`NOTE: This is only a simulation!
+Inst libssl3 [3.0.11-1~deb12u1] (3.0.11-1~deb12u2 Debian-Security:12/stable-security [amd64])
+Inst curl [7.88.1-10] (7.88.1-10+deb12u5 Debian:12.5/stable [amd64])
+Conf libssl3 (3.0.11-1~deb12u2 Debian-Security:12/stable-security [amd64])
+`)
+	if len(list) != 2 || !list[0].Security || list[1].Security || list[0].Current != "3.0.11-1~deb12u1" {
+		t.Errorf("apt: %+v", list)
+	}
+
+	list = parseDnf(`
+openssl.x86_64      1:3.0.7-25.el9    baseos
+vim-minimal.x86_64  2:8.2.2637-21.el9 baseos
+`, "RHSA-2024:1234 Important/Sec. openssl-3.0.7-25.el9.x86_64\n")
+	if len(list) != 2 || !list[0].Security || list[1].Security {
+		t.Errorf("dnf: %+v", list)
+	}
+
+	a := fakeRoot(t, nil)
+	a.lookPath = func(name string) (string, error) {
+		if name == "apt-get" {
+			return "/usr/bin/apt-get", nil
+		}
+		return "", errors.New("not found")
+	}
+	a.run = func(context.Context, string, ...string) ([]byte, int, error) {
+		return []byte("Inst libssl3 [1] (2 Debian-Security:12/stable-security [amd64])\n"), 0, nil
+	}
+	rep, err := a.Updates(context.Background())
+	if err != nil || rep.Manager != "apt" || rep.Security != 1 || severityOf(rep.Findings, "безопасности") != SeverityHigh {
+		t.Errorf("обновления: %+v, %v", rep, err)
+	}
+}
+
+func TestWorldWritable(t *testing.T) {
+	a := fakeRoot(t, map[string]string{"/etc/app.conf": "x", "/opt/app/data.txt": "x", "/opt/app/ok.txt": "x"})
+	os.Chmod(filepath.Join(a.Root, "/etc/app.conf"), 0o666)
+	os.Chmod(filepath.Join(a.Root, "/opt/app/data.txt"), 0o646)
+	os.MkdirAll(filepath.Join(a.Root, "/opt/tmp"), 0o755)
+	os.Chmod(filepath.Join(a.Root, "/opt/tmp"), 0o777|os.ModeSticky)
+
+	rep, err := a.WorldWritable(context.Background(), []string{"/etc", "/opt"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rep.Total != 2 || rep.Truncated {
+		t.Errorf("найдено: %+v", rep.Entries)
+	}
+	if severityOf(rep.Findings, "/etc/app.conf") != SeverityHigh || severityOf(rep.Findings, "/opt/app/data.txt") != SeverityMedium {
+		t.Errorf("замечания: %+v", rep.Findings)
+	}
+}
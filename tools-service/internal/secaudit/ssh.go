@@ -0,0 +1,112 @@
+package secaudit
+
+import (
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// SSHReport — действующие настройки sshd и замечания.
+type SSHReport struct {
+	Installed bool              `json:"installed"`
+	Settings  map[string]string `json:"settings"` // Ключи в нижнем регистре; первое значение побеждает, как у sshd
+	Files     []string          `json:"files"`
+	Findings  []Finding         `json:"findings"`
+}
+
+// sshDefaults — значения OpenSSH по умолчанию для проверяемых ключей.
+var sshDefaults = map[string]string{
+	"permitrootlogin":        "prohibit-password",
+	"passwordauthentication": "yes",
+	"permitemptypasswords":   "no",
+	"x11forwarding":          "no",
+	"maxauthtries":           "6",
+	"port":                   "22",
+}
+
+// SSH — разбор /etc/ssh/sshd_config и подключаемых файлов (Include).
+func (a *Auditor) SSH() (SSHReport, error) {
+	rep := SSHReport{Settings: map[string]string{}, Files: []string{}, Findings: []Finding{}}
+	if !a.readSSHConfig("/etc/ssh/sshd_config", &rep, 0) {
+		return rep, nil
+	}
+	rep.Installed = true
+	if len(rep.Files) == 0 {
+		return rep, nil // Файл есть, но не читается — только замечание о доступе
+	}
+	for k, v := range sshDefaults {
+		if _, ok := rep.Settings[k]; !ok {
+			rep.Settings[k] = v
+		}
+	}
+	s := rep.Settings
+	add := func(sev, title, fix string) {
+		rep.Findings = append(rep.Findings, Finding{Check: "ssh", Severity: sev, Title: title, Fix: fix})
+	}
+	if s["permitemptypasswords"] == "yes" {
+		add(SeverityCritical, "SSH разрешает вход с пустым паролем", "PermitEmptyPasswords no")
+	}
+	if s["permitrootlogin"] == "yes" {
+		sev := SeverityHigh
+		if s["passwordauthentication"] == "yes" {
+			sev = SeverityCritical
+		}
+		add(sev, "SSH разрешает вход root", "PermitRootLogin no (или prohibit-password) и вход через пользователя с sudo")
+	}
+	if s["passwordauthentication"] == "yes" {
+		add(SeverityMedium, "SSH принимает пароли (уязвимо к подбору)", "Настройте ключи и задайте PasswordAuthentication no")
+	}
+	if s["protocol"] == "1" {
+		add(SeverityHigh, "Включён устаревший протокол SSH 1", "Удалите Protocol 1 из sshd_config")
+	}
+	if s["x11forwarding"] == "yes" {
+		add(SeverityLow, "Включён X11Forwarding", "X11Forwarding no, если графика по SSH не нужна")
+	}
+	if n, err := strconv.Atoi(s["maxauthtries"]); err == nil && n > 6 {
+		add(SeverityLow, "MaxAuthTries "+s["maxauthtries"]+" — много попыток входа за соединение", "MaxAuthTries 3")
+	}
+	return rep, nil
+}
+
+// readSSHConfig — ключи файла p (относительно Root) и его Include; первое
+// значение ключа побеждает. Match-блоки не учитываются.
+func (a *Auditor) readSSHConfig(p string, rep *SSHReport, depth int) bool {
+	data, ok, err := a.readFile(p)
+	if err != nil {
+		rep.Findings = append(rep.Findings, unreadable("ssh", p, err))
+		return true
+	}
+	if !ok {
+		return false
+	}
+	rep.Files = append(rep.Files, p)
+	for _, line := range lines(data) {
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		key, val := strings.ToLower(fields[0]), strings.ToLower(fields[1])
+		if key == "match" {
+			break
+		}
+		if key == "include" && depth < 3 {
+			for _, pattern := range fields[1:] {
+				if !filepath.IsAbs(pattern) {
+					pattern = "/etc/ssh/" + pattern
+				}
+				matches, _ := filepath.Glob(a.path(pattern))
+				sort.Strings(matches)
+				for _, m := range matches {
+					rel, _ := filepath.Rel(a.Root, m)
+					a.readSSHConfig("/"+filepath.ToSlash(rel), rep, depth+1)
+				}
+			}
+			continue
+		}
+		if _, ok := rep.Settings[key]; !ok {
+			rep.Settings[key] = val
+		}
+	}
+	return true
+}
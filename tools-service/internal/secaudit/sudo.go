@@ -0,0 +1,170 @@
+package secaudit
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// adminGroups — группы, участники которых получают sudo в популярных дистрибутивах.
+var adminGroups = []string{"sudo", "wheel", "admin"}
+
+// SudoUser — пользователь с правами администратора.
+type SudoUser struct {
+	Name       string   `json:"name"`
+	Via        []string `json:"via"`         // group:sudo, sudoers:/etc/sudoers.d/deploy, uid0
+	NoPassword bool     `json:"no_password"` // Правило с NOPASSWD
+}
+
+// SudoReport — результат проверки привилегированных пользователей.
+type SudoReport struct {
+	Users    []SudoUser `json:"users"`
+	Findings []Finding  `json:"findings"`
+}
+
+// Sudo — пользователи с UID 0, участники групп sudo/wheel/admin и правила
+// sudoers (с NOPASSWD — отдельным замечанием); пустые пароли — по /etc/shadow,
+// если он доступен.
+func (a *Auditor) Sudo() (SudoReport, error) {
+	rep := SudoReport{Users: []SudoUser{}, Findings: []Finding{}}
+	users := map[string]*SudoUser{}
+	add := func(name, via string, nopasswd bool) {
+		u := users[name]
+		if u == nil {
+			u = &SudoUser{Name: name}
+			users[name] = u
+		}
+		u.Via = append(u.Via, via)
+		u.NoPassword = u.NoPassword || nopasswd
+	}
+
+	passwd, ok, err := a.readFile("/etc/passwd")
+	if err != nil || !ok {
+		return rep, fmt.Errorf("нет доступа к /etc/passwd: %v", err)
+	}
+	for _, line := range lines(passwd) {
+		f := strings.Split(line, ":")
+		if len(f) >= 3 && f[2] == "0" && f[0] != "root" {
+			add(f[0], "uid0", false)
+			rep.Findings = append(rep.Findings, Finding{
+				Check:    "sudo",
+				Severity: SeverityCritical,
+				Title:    "Пользователь " + f[0] + " с UID 0 (второй root)",
+				Detail:   line,
+				Fix:      "Удалите учётную запись или смените ей UID (usermod -u)",
+			})
+		}
+	}
+
+	if group, ok, err := a.readFile("/etc/group"); err != nil {
+		rep.Findings = append(rep.Findings, unreadable("sudo", "/etc/group", err))
+	} else if ok {
+		for _, line := range lines(group) {
+			f := strings.Split(line, ":")
+			if len(f) < 4 || !contains(adminGroups, f[0]) {
+				continue
+			}
+			for _, m := range strings.Split(f[3], ",") {
+				if m = strings.TrimSpace(m); m != "" {
+					add(m, "group:"+f[0], false)
+				}
+			}
+		}
+	}
+
+	files := []string{"/etc/sudoers"}
+	if extra, _ := filepath.Glob(a.path("/etc/sudoers.d/*")); len(extra) > 0 {
+		for _, p := range extra {
+			files = append(files, "/etc/sudoers.d/"+filepath.Base(p))
+		}
+	}
+	for _, p := range files {
+		data, ok, err := a.readFile(p)
+		if err != nil {
+			rep.Findings = append(rep.Findings, unreadable("sudo", p, err))
+			continue
+		}
+		if !ok {
+			continue
+		}
+		for _, line := range lines(data) {
+			who, rule, ok := parseSudoRule(line)
+			if !ok {
+				continue
+			}
+			nopasswd := strings.Contains(rule, "NOPASSWD")
+			if !strings.HasPrefix(who, "%") && who != "root" {
+				add(who, "sudoers:"+p, nopasswd)
+			}
+			if !nopasswd {
+				continue
+			}
+			sev := SeverityMedium
+			if strings.HasSuffix(strings.TrimSpace(rule), "ALL") {
+				sev = SeverityHigh
+			}
+			rep.Findings = append(rep.Findings, Finding{
+				Check:    "sudo",
+				Severity: sev,
+				Title:    "sudo без пароля для " + who,
+				Detail:   p + ": " + line,
+				Fix:      "Уберите NOPASSWD или ограничьте правило конкретными командами (visudo -f " + p + ")",
+			})
+		}
+	}
+
+	if shadow, ok, err := a.readFile("/etc/shadow"); err == nil && ok {
+		for _, line := range lines(shadow) {
+			if f := strings.Split(line, ":"); len(f) >= 2 && f[1] == "" {
+				rep.Findings = append(rep.Findings, Finding{
+					Check:    "sudo",
+					Severity: SeverityCritical,
+					Title:    "Пустой пароль у пользователя " + f[0],
+					Fix:      "Задайте пароль (passwd " + f[0] + ") или заблокируйте учётную запись (passwd -l " + f[0] + ")",
+				})
+			}
+		}
+	}
+
+	for _, u := range users {
+		rep.Users = append(rep.Users, *u)
+	}
+	sort.Slice(rep.Users, func(i, j int) bool { return rep.Users[i].Name < rep.Users[j].Name })
+	if len(rep.Users) > 0 {
+		names := make([]string, len(rep.Users))
+		for i, u := range rep.Users {
+			names[i] = u.Name
+		}
+		rep.Findings = append(rep.Findings, Finding{
+			Check:    "sudo",
+			Severity: SeverityInfo,
+			Title:    fmt.Sprintf("Пользователей с правами администратора: %d", len(rep.Users)),
+			Detail:   strings.Join(names, ", "),
+			Fix:      "Проверьте, что каждому из них нужны права sudo",
+		})
+	}
+	return rep, nil
+}
+
+// parseSudoRule — «кто ХОСТ=(…) команды» из строки sudoers; алиасы,
+// Defaults и директивы (@include, #includedir) пропускаются.
+func parseSudoRule(line string) (who, rule string, ok bool) {
+	if strings.HasPrefix(line, "Defaults") || strings.HasPrefix(line, "@") || strings.Contains(strings.SplitN(line, " ", 2)[0], "_Alias") {
+		return "", "", false
+	}
+	who, rule, ok = strings.Cut(line, " ")
+	if !ok || !strings.Contains(rule, "=") {
+		return "", "", false
+	}
+	return who, strings.TrimSpace(rule), true
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
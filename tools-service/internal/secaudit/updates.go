@@ -0,0 +1,196 @@
+package secaudit
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// maxPackages — сколько пакетов перечислять в отчёте.
+const maxPackages = 100
+
+// Update — пакет с доступным обновлением.
+type Update struct {
+	Name      string `json:"name"`
+	Current   string `json:"current,omitempty"`
+	Available string `json:"available,omitempty"`
+	Security  bool   `json:"security"`
+}
+
+// UpdatesReport — ожидающие обновления.
+type UpdatesReport struct {
+	Manager  string    `json:"manager"` // apt, dnf, yum, apk; пусто — не найден
+	Total    int       `json:"total"`
+	Security int       `json:"security"`
+	Packages []Update  `json:"packages"` // Сначала обновления безопасности, не больше 100
+	Findings []Finding `json:"findings"`
+}
+
+var (
+	aptInstRe = regexp.MustCompile(`^Inst (\S+) (?:\[(\S+)\] )?\((\S+) ([^)]*)\)`)
+	apkVerRe  = regexp.MustCompile(`^(\S+?)-(\d\S*)\s+<\s+(\S+)`)
+)
+
+// Updates — доступные обновления по менеджеру пакетов без изменения системы
+// (apt-get -s upgrade, dnf updateinfo, apk version). Списки пакетов не
+// обновляются — свежесть зависит от последнего apt update / dnf makecache.
+func (a *Auditor) Updates(ctx context.Context) (UpdatesReport, error) {
+	rep := UpdatesReport{Packages: []Update{}, Findings: []Finding{}}
+	var list []Update
+	var err error
+	switch {
+	case a.has("apt-get"):
+		rep.Manager = "apt"
+		list, err = a.aptUpdates(ctx)
+	case a.has("dnf"), a.has("yum"):
+		rep.Manager = "dnf"
+		if !a.has("dnf") {
+			rep.Manager = "yum"
+		}
+		list, err = a.dnfUpdates(ctx, rep.Manager)
+	case a.has("apk"):
+		rep.Manager = "apk"
+		list, err = a.apkUpdates(ctx)
+	default:
+		rep.Findings = append(rep.Findings, Finding{Check: "updates", Severity: SeverityInfo, Title: "Менеджер пакетов не найден (apt, dnf, yum, apk)", Fix: "Проверьте обновления средствами вашего дистрибутива"})
+		return rep, nil
+	}
+	if err != nil {
+		return rep, err
+	}
+	var security, other []Update
+	for _, u := range list {
+		if u.Security {
+			security = append(security, u)
+		} else {
+			other = append(other, u)
+		}
+	}
+	rep.Total, rep.Security = len(list), len(security)
+	rep.Packages = append(append(rep.Packages, security...), other...)
+	if len(rep.Packages) > maxPackages {
+		rep.Packages = rep.Packages[:maxPackages]
+	}
+	fix := map[string]string{
+		"apt": "apt-get update && apt-get upgrade (только безопасность — unattended-upgrade)",
+		"dnf": "dnf upgrade --security",
+		"yum": "yum update --security",
+		"apk": "apk upgrade",
+	}[rep.Manager]
+	if len(security) > 0 {
+		rep.Findings = append(rep.Findings, Finding{
+			Check:    "updates",
+			Severity: SeverityHigh,
+			Title:    fmt.Sprintf("Ожидают установки обновлений безопасности: %d", len(security)),
+			Detail:   names(security, 10),
+			Fix:      fix,
+		})
+	}
+	if len(other) > 0 {
+		rep.Findings = append(rep.Findings, Finding{
+			Check:    "updates",
+			Severity: SeverityLow,
+			Title:    fmt.Sprintf("Доступны обновления пакетов: %d", len(other)),
+			Detail:   names(other, 10),
+			Fix:      fix,
+		})
+	}
+	return rep, nil
+}
+
+func (a *Auditor) has(bin string) bool {
+	_, err := a.lookPath(bin)
+	return err == nil
+}
+
+// aptUpdates — «Inst pkg [текущая] (новая Debian-Security:12/stable-security [amd64])».
+func (a *Auditor) aptUpdates(ctx context.Context) ([]Update, error) {
+	out, code, err := a.run(ctx, "apt-get", "-s", "-o", "Debug::NoLocking=1", "upgrade")
+	if err != nil {
+		return nil, err
+	}
+	if code != 0 {
+		return nil, fmt.Errorf("apt-get -s upgrade: код возврата %d", code)
+	}
+	return parseApt(string(out)), nil
+}
+
+func parseApt(out string) []Update {
+	var list []Update
+	for _, line := range strings.Split(out, "\n") {
+		if m := aptInstRe.FindStringSubmatch(line); m != nil {
+			list = append(list, Update{Name: m[1], Current: m[2], Available: m[3], Security: strings.Contains(strings.ToLower(m[4]), "security")})
+		}
+	}
+	return list
+}
+
+// dnfUpdates — все обновления (check-update) и отмеченные как security (updateinfo).
+func (a *Auditor) dnfUpdates(ctx context.Context, bin string) ([]Update, error) {
+	out, code, err := a.run(ctx, bin, "-q", "check-update")
+	if err != nil {
+		return nil, err
+	}
+	if code != 0 && code != 100 { // 100 — есть обновления
+		return nil, fmt.Errorf("%s check-update: код возврата %d", bin, code)
+	}
+	sec, _, err := a.run(ctx, bin, "-q", "updateinfo", "list", "--security")
+	if err != nil {
+		return nil, err
+	}
+	return parseDnf(string(out), string(sec)), nil
+}
+
+// parseDnf — «pkg.arch  версия  репозиторий» и «ADVISORY  Important/Sec.  pkg-версия.arch».
+func parseDnf(updates, security string) []Update {
+	secure := map[string]bool{}
+	for _, line := range strings.Split(security, "\n") {
+		if f := strings.Fields(line); len(f) >= 3 && strings.Contains(f[1], "Sec") {
+			secure[f[2]] = true
+		}
+	}
+	var list []Update
+	for _, line := range strings.Split(updates, "\n") {
+		f := strings.Fields(line)
+		if len(f) != 3 || !strings.Contains(f[0], ".") || strings.HasPrefix(line, " ") {
+			continue
+		}
+		i := strings.LastIndex(f[0], ".")
+		name, arch := f[0][:i], f[0][i+1:]
+		version := f[1]
+		if _, v, ok := strings.Cut(version, ":"); ok {
+			version = v
+		}
+		list = append(list, Update{Name: name, Available: f[1], Security: secure[name+"-"+version+"."+arch]})
+	}
+	return list
+}
+
+// apkUpdates — «pkg-1.2.3-r0 < 1.2.4-r0»; у apk нет признака обновления безопасности.
+func (a *Auditor) apkUpdates(ctx context.Context) ([]Update, error) {
+	out, _, err := a.run(ctx, "apk", "version", "-l", "<")
+	if err != nil {
+		return nil, err
+	}
+	var list []Update
+	for _, line := range strings.Split(string(out), "\n") {
+		if m := apkVerRe.FindStringSubmatch(line); m != nil {
+			list = append(list, Update{Name: m[1], Current: m[2], Available: m[3]})
+		}
+	}
+	return list, nil
+}
+
+// names — первые n имён пакетов.
+func names(list []Update, n int) string {
+	out := make([]string, 0, n)
+	for i, u := range list {
+		if i == n {
+			out = append(out, fmt.Sprintf("и ещё %d", len(list)-n))
+			break
+		}
+		out = append(out, u.Name)
+	}
+	return strings.Join(out, ", ")
+}
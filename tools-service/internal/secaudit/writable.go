@@ -0,0 +1,127 @@
+package secaudit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Ограничения обхода WorldWritable.
+const (
+	writableTimeout  = 30 * time.Second
+	writableEntries  = 300_000
+	writableListed   = 100
+	writableFindings = 20
+)
+
+// DefaultWritablePaths — каталоги, где запись всем особенно опасна.
+var DefaultWritablePaths = []string{"/etc", "/usr/local", "/usr/bin", "/usr/sbin", "/usr/lib/systemd", "/opt", "/srv", "/var/www", "/root", "/home"}
+
+// sensitiveDirs — запись в файл под этими каталогами позволяет подменить
+// конфигурацию или исполняемый файл.
+var sensitiveDirs = []string{"/etc", "/usr/bin", "/usr/sbin", "/usr/local/bin", "/usr/local/sbin", "/usr/lib", "/root"}
+
+// WritableEntry — файл или каталог с правом записи для всех.
+type WritableEntry struct {
+	Path string `json:"path"`
+	Dir  bool   `json:"dir"`
+	Mode string `json:"mode"`
+}
+
+// WritableReport — результат поиска.
+type WritableReport struct {
+	Paths     []string        `json:"paths"`
+	Entries   []WritableEntry `json:"entries"` // Не больше 100
+	Total     int             `json:"total"`
+	Scanned   int             `json:"scanned"`
+	Truncated bool            `json:"truncated"` // Обход остановлен по времени или числу записей
+	Findings  []Finding       `json:"findings"`
+}
+
+// WorldWritable — файлы и каталоги с правом записи для всех (o+w) под
+// paths; каталоги со sticky-битом (как /tmp) и символические ссылки не
+// считаются. Обход ограничен по времени и числу записей.
+func (a *Auditor) WorldWritable(ctx context.Context, paths []string) (WritableReport, error) {
+	if len(paths) == 0 {
+		paths = DefaultWritablePaths
+	}
+	rep := WritableReport{Paths: paths, Entries: []WritableEntry{}, Findings: []Finding{}}
+	ctx, cancel := context.WithTimeout(ctx, writableTimeout)
+	defer cancel()
+	errStop := errors.New("stop")
+	for _, root := range paths {
+		err := filepath.WalkDir(a.path(root), func(p string, d fs.DirEntry, err error) error {
+			if err != nil {
+				if d != nil && d.IsDir() {
+					return fs.SkipDir
+				}
+				return nil
+			}
+			if rep.Scanned++; rep.Scanned > writableEntries || (rep.Scanned%1024 == 0 && ctx.Err() != nil) {
+				return errStop
+			}
+			if d.Type()&fs.ModeSymlink != 0 {
+				return nil
+			}
+			info, err := d.Info()
+			if err != nil {
+				return nil
+			}
+			mode := info.Mode()
+			if mode.Perm()&0o002 == 0 || (d.IsDir() && mode&fs.ModeSticky != 0) {
+				return nil
+			}
+			rep.Total++
+			if len(rep.Entries) < writableListed {
+				rel, _ := filepath.Rel(a.Root, p)
+				rep.Entries = append(rep.Entries, WritableEntry{Path: "/" + filepath.ToSlash(rel), Dir: d.IsDir(), Mode: mode.String()})
+			}
+			return nil
+		})
+		if errors.Is(err, errStop) {
+			rep.Truncated = true
+			break
+		}
+	}
+
+	for i, e := range rep.Entries {
+		if i == writableFindings {
+			rep.Findings = append(rep.Findings, Finding{
+				Check:    "writable",
+				Severity: SeverityMedium,
+				Title:    fmt.Sprintf("И ещё %d путей с записью для всех", rep.Total-writableFindings),
+				Fix:      "Полный список — в entries; найти все: find / -xdev -perm -0002 ! -type l",
+			})
+			break
+		}
+		sev, what := SeverityMedium, "Файл"
+		if e.Dir {
+			what = "Каталог без sticky-бита"
+		}
+		for _, s := range sensitiveDirs {
+			if e.Path == s || strings.HasPrefix(e.Path, s+"/") {
+				sev = SeverityHigh
+			}
+		}
+		rep.Findings = append(rep.Findings, Finding{
+			Check:    "writable",
+			Severity: sev,
+			Title:    what + " доступен на запись всем: " + e.Path,
+			Detail:   e.Mode,
+			Fix:      "chmod o-w " + e.Path,
+		})
+	}
+	if rep.Truncated {
+		rep.Findings = append(rep.Findings, Finding{
+			Check:    "writable",
+			Severity: SeverityInfo,
+			Title:    fmt.Sprintf("Поиск остановлен после %d записей — результат неполный", rep.Scanned),
+			Fix:      "Сузьте paths и повторите проверку",
+		})
+	}
+	return rep, nil
+}
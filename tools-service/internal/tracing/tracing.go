@@ -0,0 +1,149 @@
+// Package tracing — трассировка tools-service на OpenTelemetry SDK.
+//
+// Вызов инструмента продолжает трассировку agent-service из traceparent
+// (заголовок HTTP или метаданные gRPC). Спаны экспортируются в OTLP/HTTP,
+// если задан OTEL_EXPORTER_OTLP_ENDPOINT; семплер — из OTEL_TRACES_SAMPLER
+// и OTEL_TRACES_SAMPLER_ARG, как в остальных сервисах.
+package tracing
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"os"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc/metadata"
+)
+
+// tracerName — имя инструментирующей библиотеки в экспортируемых спанах.
+const tracerName = "tools-service"
+
+// Init — настраивает W3C-пропагацию, провайдер спанов с семплером из
+// OTEL_TRACES_SAMPLER и, если задан OTEL_EXPORTER_OTLP_ENDPOINT (или полный
+// адрес OTEL_EXPORTER_OTLP_TRACES_ENDPOINT), экспорт в OTLP/HTTP. Имя сервиса
+// берётся из OTEL_SERVICE_NAME, иначе используется serviceName.
+//
+// Возвращает функцию остановки, которая отправляет накопленные спаны.
+func Init(serviceName string) func(context.Context) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{}, propagation.Baggage{},
+	))
+	if v := os.Getenv("OTEL_SERVICE_NAME"); v != "" {
+		serviceName = v
+	}
+	res, err := resource.Merge(resource.Default(), resource.NewSchemaless(
+		attribute.String("service.name", serviceName),
+	))
+	if err != nil {
+		slog.Warn("Описание ресурса трассировки", slog.String("ошибка", err.Error()))
+		res = resource.Default()
+	}
+	opts := []sdktrace.TracerProviderOption{sdktrace.WithResource(res)}
+	if os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") != "" || os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT") != "" {
+		exporter, err := otlptracehttp.New(context.Background())
+		if err != nil {
+			slog.Warn("Экспорт трассировки OTLP выключен", slog.String("ошибка", err.Error()))
+		} else {
+			opts = append(opts, sdktrace.WithBatcher(exporter))
+			slog.Info("Экспорт трассировки OTLP включён", slog.String("сервис", serviceName))
+		}
+	}
+	tp := sdktrace.NewTracerProvider(opts...)
+	otel.SetTracerProvider(tp)
+
+	return func(ctx context.Context) {
+		if err := tp.Shutdown(ctx); err != nil {
+			slog.Warn("Остановка трассировки", slog.String("ошибка", err.Error()))
+		}
+	}
+}
+
+// Extract — контекст с родительским спаном из заголовка traceparent.
+func Extract(ctx context.Context, h http.Header) context.Context {
+	return otel.GetTextMapPropagator().Extract(ctx, propagation.HeaderCarrier(h))
+}
+
+// statusRecorder — запоминает HTTP-статус ответа для атрибутов спана.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(code int) {
+	r.status = code
+	r.ResponseWriter.WriteHeader(code)
+}
+
+// Unwrap — даёт http.ResponseController доступ к исходному writer.
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+// Middleware — открывает серверный спан на каждый входящий запрос,
+// продолжая трассировку из заголовка traceparent.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, span := otel.Tracer(tracerName).Start(Extract(r.Context(), r.Header), r.Method+" "+r.URL.Path,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				attribute.String("http.request.method", r.Method),
+				attribute.String("url.path", r.URL.Path),
+			))
+		defer span.End()
+		if rid := r.Header.Get("X-Request-ID"); rid != "" {
+			span.SetAttributes(attribute.String("request_id", rid))
+		}
+		w.Header().Set("X-Trace-ID", span.SpanContext().TraceID().String())
+
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r.WithContext(ctx))
+
+		span.SetAttributes(attribute.Int("http.response.status_code", rec.status))
+		if rec.status >= 500 {
+			span.SetStatus(codes.Error, http.StatusText(rec.status))
+		}
+	})
+}
+
+// StartRPC — серверный спан вызова gRPC; трассировка продолжается из
+// метаданных traceparent входящего вызова.
+func StartRPC(ctx context.Context, method string) (context.Context, trace.Span) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	ctx = otel.GetTextMapPropagator().Extract(ctx, metadataCarrier(md))
+	return otel.Tracer(tracerName).Start(ctx, method,
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(
+			attribute.String("rpc.system", "grpc"),
+			attribute.String("rpc.method", method),
+		))
+}
+
+// metadataCarrier — метаданные gRPC как носитель заголовков W3C.
+type metadataCarrier metadata.MD
+
+func (c metadataCarrier) Get(key string) string {
+	if v := metadata.MD(c).Get(key); len(v) > 0 {
+		return v[0]
+	}
+	return ""
+}
+
+func (c metadataCarrier) Set(key, value string) {
+	metadata.MD(c).Set(key, value)
+}
+
+func (c metadataCarrier) Keys() []string {
+	keys := make([]string, 0, len(c))
+	for k := range c {
+		keys = append(keys, k)
+	}
+	return keys
+}
@@ -0,0 +1,113 @@
+// Package workspace — текущее рабочее пространство запроса.
+//
+// agent-service передаёт каталог активного рабочего пространства в заголовке
+// X-Workspace-Root (по gRPC — в метаданных x-workspace-root). Файловые
+// инструменты (read, write, list, delete) разрешают относительные пути от
+// этого каталога, /execute запускает команду в нём. По умолчанию пути за
+// пределами пространства отклоняются (WORKSPACE_CONFINE=false — разрешить,
+// как без пространства). Без заголовка поведение прежнее.
+package workspace
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Header — заголовок с каталогом рабочего пространства.
+const Header = "X-Workspace-Root"
+
+// ErrOutside — путь ведёт за пределы рабочего пространства.
+var ErrOutside = errors.New("путь за пределами рабочего пространства")
+
+var confine = true
+
+// Init — читает WORKSPACE_CONFINE (по умолчанию true).
+func Init() {
+	confine = !strings.EqualFold(strings.TrimSpace(os.Getenv("WORKSPACE_CONFINE")), "false")
+	if !confine {
+		slog.Warn("WORKSPACE_CONFINE=false — инструменты могут выходить за пределы рабочего пространства")
+	}
+}
+
+// Confined — отклоняются ли пути за пределами пространства.
+func Confined() bool { return confine }
+
+// Root — каталог рабочего пространства из заголовка запроса ("" — не задан).
+// Каталог должен быть абсолютным путём к существующей директории.
+func Root(r *http.Request) (string, error) {
+	return Dir(r.Header.Get(Header))
+}
+
+// Dir — проверенный каталог рабочего пространства из значения заголовка или
+// метаданных gRPC x-workspace-root ("" — не задан).
+func Dir(root string) (string, error) {
+	root = strings.TrimSpace(root)
+	if root == "" {
+		return "", nil
+	}
+	if !filepath.IsAbs(root) {
+		return "", fmt.Errorf("%s: путь %q не абсолютный", Header, root)
+	}
+	root = filepath.Clean(root)
+	info, err := os.Stat(root)
+	if err != nil {
+		return "", fmt.Errorf("каталог рабочего пространства %s недоступен: %w", root, err)
+	}
+	if !info.IsDir() {
+		return "", fmt.Errorf("рабочее пространство %s — не каталог", root)
+	}
+	return root, nil
+}
+
+// Resolve — путь path относительно рабочего пространства root. Пустой path —
+// сам root; абсолютные пути и ~ не меняются. При confine путь, который после
+// нормализации и разрешения символических ссылок оказывается вне root,
+// отклоняется с ErrOutside. Пустой root — path без изменений.
+func Resolve(root, path string, confine bool) (string, error) {
+	if root == "" {
+		return path, nil
+	}
+	path = strings.TrimSpace(path)
+	switch {
+	case path == "":
+		path = root
+	case strings.HasPrefix(path, "~"):
+		if home, err := os.UserHomeDir(); err == nil {
+			path = filepath.Join(home, strings.TrimPrefix(strings.TrimPrefix(path, "~"), "/"))
+		}
+	case !filepath.IsAbs(path):
+		path = filepath.Join(root, path)
+	}
+	path = filepath.Clean(path)
+	if confine && !within(realPath(root), realPath(path)) {
+		return "", fmt.Errorf("%w: %s (пространство %s)", ErrOutside, path, root)
+	}
+	return path, nil
+}
+
+// within — лежит ли path внутри root (или совпадает с ним).
+func within(root, path string) bool {
+	rel, err := filepath.Rel(root, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// realPath — путь с разрешёнными символическими ссылками. Для ещё не
+// существующего файла разрешается ближайший существующий родитель:
+// так запись через ссылку на каталог вне пространства тоже ловится.
+func realPath(path string) string {
+	var rest []string
+	for p := path; ; p = filepath.Dir(p) {
+		if real, err := filepath.EvalSymlinks(p); err == nil {
+			return filepath.Join(append([]string{real}, rest...)...)
+		}
+		if filepath.Dir(p) == p {
+			return path
+		}
+		rest = append([]string{filepath.Base(p)}, rest...)
+	}
+}
@@ -0,0 +1,87 @@
+package workspace
+
+import (
+	"errors"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestResolve_NoRoot(t *testing.T) {
+	got, err := Resolve("", "notes.txt", true)
+	if err != nil || got != "notes.txt" {
+		t.Errorf("без пространства путь не меняется: %q, %v", got, err)
+	}
+}
+
+func TestResolve_Relative(t *testing.T) {
+	root := t.TempDir()
+	got, err := Resolve(root, "src/main.go", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := filepath.Join(root, "src", "main.go"); got != want {
+		t.Errorf("получили %q, ожидали %q", got, want)
+	}
+	if got, _ := Resolve(root, "", true); got != root {
+		t.Errorf("пустой путь — корень пространства, получили %q", got)
+	}
+}
+
+func TestResolve_Outside(t *testing.T) {
+	root := t.TempDir()
+	for _, p := range []string{"../secret", "/etc/passwd", filepath.Join(root, "..", "x")} {
+		if _, err := Resolve(root, p, true); !errors.Is(err, ErrOutside) {
+			t.Errorf("%s: ожидали ErrOutside, получили %v", p, err)
+		}
+	}
+	if got, err := Resolve(root, "/etc/passwd", false); err != nil || got != "/etc/passwd" {
+		t.Errorf("без ограничения абсолютный путь разрешён: %q, %v", got, err)
+	}
+}
+
+func TestResolve_Symlink(t *testing.T) {
+	root, outside := t.TempDir(), t.TempDir()
+	if err := os.Symlink(outside, filepath.Join(root, "link")); err != nil {
+		t.Skip(err)
+	}
+	if _, err := Resolve(root, "link/new.txt", true); !errors.Is(err, ErrOutside) {
+		t.Errorf("ссылка за пределы пространства: ожидали ErrOutside, получили %v", err)
+	}
+	if err := os.Mkdir(filepath.Join(root, "dir"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Resolve(root, "dir/a/b.txt", true); err != nil {
+		t.Errorf("несуществующий файл внутри пространства: %v", err)
+	}
+}
+
+func TestRoot(t *testing.T) {
+	r := httptest.NewRequest("POST", "/read", nil)
+	if root, err := Root(r); root != "" || err != nil {
+		t.Errorf("без заголовка: %q, %v", root, err)
+	}
+	r.Header.Set(Header, "relative/dir")
+	if _, err := Root(r); err == nil {
+		t.Error("относительный путь должен отклоняться")
+	}
+	dir := t.TempDir()
+	r.Header.Set(Header, dir)
+	if root, err := Root(r); err != nil || root != dir {
+		t.Errorf("каталог %s: %q, %v", dir, root, err)
+	}
+}
+
+func TestInit(t *testing.T) {
+	t.Setenv("WORKSPACE_CONFINE", "false")
+	Init()
+	if Confined() {
+		t.Error("WORKSPACE_CONFINE=false — без ограничения")
+	}
+	t.Setenv("WORKSPACE_CONFINE", "")
+	Init()
+	if !Confined() {
+		t.Error("по умолчанию пути ограничены пространством")
+	}
+}
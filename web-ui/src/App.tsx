@@ -20,7 +20,7 @@ import { SYSTEM_PANEL_MODES, type SystemPanelMode, toggleSystemPanelMode, UI_LAY
 import { deriveRagPanelState, RAG_PANEL_STATE_LABELS } from './config/ragPanelState';
 import { DEFAULT_UI_PREFERENCES, parseUiPreferences, UI_PREFERENCES_STORAGE_KEY } from './config/uiPreferences';
 import { normalizeWorkspaceList, type WorkspaceInfo } from './config/workspaceApi';
-import { normalizeModelList, type ModelInfo } from './config/modelsApi';
+import { formatBenchmark, normalizeModelList, type ModelInfo } from './config/modelsApi';
 import { normalizeProviderList, type ModelDetailInfo, type ProviderInfo } from './config/providersApi';
 import { normalizeAgentList, type AgentInfo } from './config/agentsApi';
 import { LOG_LEVEL_OPTIONS, LOG_SERVICE_OPTIONS } from './config/logFilters';
@@ -1829,6 +1829,9 @@ function App() {
                           supportsTools: m.supportsTools,
                           isSuitable: m.suitableRoles?.includes(agent.name),
                           roleNote: m.roleNotes?.[agent.name] || undefined,
+                          benchmark: m.benchmark
+                            ? formatBenchmark(m.benchmark)
+                            : m.usableContext ? `контекст ${Math.round(m.usableContext / 1024)}K` : undefined,
                         }));
                       }
                       return providerModels.map((m): ModelPopoverItem => {
@@ -35,6 +35,8 @@ export interface ModelPopoverItem {
   isAvailable?: boolean;
   /** Информация о ценах (для облачных моделей) */
   pricingInfo?: string;
+  /** Итоги последнего замера: скорость, инструменты, контекст */
+  benchmark?: string;
 }
 
 interface ModelPopoverProps {
@@ -190,6 +192,9 @@ export function ModelPopover({
                     {item.supportsTools && (
                       <span className="model-popover-item-tools" title="Поддерживает инструменты">🔧</span>
                     )}
+                    {item.benchmark && (
+                      <span className="model-popover-item-bench" title="Последний замер: скорость, точность инструментов, рабочий контекст">{item.benchmark}</span>
+                    )}
                   </div>
                   {item.roleNote && (
                     <div className={`model-popover-item-note ${item.isSuitable ? 'suitable' : 'unsuitable'}`}>
@@ -1,6 +1,6 @@
 import { describe, expect, it } from 'vitest';
 
-import { normalizeModelList } from './modelsApi';
+import { formatBenchmark, normalizeModelList } from './modelsApi';
 
 describe('normalizeModelList', () => {
   const fullModel = {
@@ -11,6 +11,7 @@ describe('normalizeModelList', () => {
     isCodeModel: false,
     suitableRoles: ['admin'],
     roleNotes: { admin: 'ok' },
+    usableContext: 8192,
   };
 
   it('принимает массив', () => {
@@ -35,7 +36,32 @@ describe('normalizeModelList', () => {
         isCodeModel: false,
         suitableRoles: [],
         roleNotes: {},
+        usableContext: 0,
       },
     ]);
   });
+
+  it('разбирает последний замер модели', () => {
+    const [model] = normalizeModelList([{
+      ...fullModel,
+      benchmark: {
+        CreatedAt: '2024-05-01T10:00:00Z',
+        tokens_per_sec: 41.6,
+        first_token_ms: 180,
+        tool_calls: 4,
+        tool_success_rate: 0.75,
+        context_tokens: 8192,
+      },
+    }]);
+    expect(model.benchmark).toEqual({
+      tokensPerSec: 41.6,
+      firstTokenMs: 180,
+      toolSuccessRate: 0.75,
+      toolCalls: 4,
+      contextTokens: 8192,
+      measuredAt: '2024-05-01T10:00:00Z',
+    });
+    expect(formatBenchmark(model.benchmark!)).toBe('42 ток/с · 🔧 75% · 8K');
+    expect(formatBenchmark({ ...model.benchmark!, toolCalls: 0 })).toBe('42 ток/с · 8K');
+  });
 });
@@ -13,6 +13,53 @@ export interface ModelInfo {
   isCodeModel: boolean;
   suitableRoles: string[];
   roleNotes: { [role: string]: string };
+  /** Наибольший контекст, который модель удержала при фоновой проверке (0 — не проверялась). */
+  usableContext: number;
+  /** Последний замер POST /models/benchmark, если модель замерялась. */
+  benchmark?: ModelBenchmarkSummary;
+}
+
+/** Краткие итоги замера модели для выбора модели. */
+export interface ModelBenchmarkSummary {
+  tokensPerSec: number;
+  firstTokenMs: number;
+  /** Доля верных вызовов инструментов, 0..1 (0 при toolCalls = 0 — не проверялось). */
+  toolSuccessRate: number;
+  toolCalls: number;
+  /** Наибольший контекст (≈ токенов), в котором модель нашла метку. */
+  contextTokens: number;
+  measuredAt: string;
+}
+
+function asNumber(value: unknown): number {
+  return typeof value === 'number' && Number.isFinite(value) ? value : 0;
+}
+
+function asBenchmark(value: unknown): ModelBenchmarkSummary | undefined {
+  if (!value || typeof value !== 'object' || Array.isArray(value)) {
+    return undefined;
+  }
+  const raw = value as Record<string, unknown>;
+  return {
+    tokensPerSec: asNumber(raw.tokens_per_sec),
+    firstTokenMs: asNumber(raw.first_token_ms),
+    toolSuccessRate: asNumber(raw.tool_success_rate),
+    toolCalls: asNumber(raw.tool_calls),
+    contextTokens: asNumber(raw.context_tokens),
+    measuredAt: typeof raw.CreatedAt === 'string' ? raw.CreatedAt : '',
+  };
+}
+
+/** Строка для карточки модели: «42 ток/с · 🔧 75% · 8K». */
+export function formatBenchmark(b: ModelBenchmarkSummary): string {
+  const parts = [`${Math.round(b.tokensPerSec)} ток/с`];
+  if (b.toolCalls > 0) {
+    parts.push(`🔧 ${Math.round(b.toolSuccessRate * 100)}%`);
+  }
+  if (b.contextTokens > 0) {
+    parts.push(b.contextTokens >= 1024 ? `${Math.round(b.contextTokens / 1024)}K` : String(b.contextTokens));
+  }
+  return parts.join(' · ');
 }
 
 const MODEL_RESPONSE_KEYS = ['models', 'items', 'data'] as const;
@@ -59,7 +106,7 @@ function asModel(item: unknown): ModelInfo | null {
     )
     : {};
 
-  return {
+  const model: ModelInfo = {
     name,
     supportsTools: Boolean(candidate.supportsTools),
     family: typeof candidate.family === 'string' ? candidate.family : '',
@@ -67,7 +114,13 @@ function asModel(item: unknown): ModelInfo | null {
     isCodeModel: Boolean(candidate.isCodeModel),
     suitableRoles,
     roleNotes,
+    usableContext: asNumber(candidate.usableContext),
   };
+  const benchmark = asBenchmark(candidate.benchmark);
+  if (benchmark) {
+    model.benchmark = benchmark;
+  }
+  return model;
 }
 
 export function normalizeModelList(payload: unknown): ModelInfo[] {
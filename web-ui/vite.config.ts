@@ -28,6 +28,7 @@ export default defineConfig({
       '/health': apiTarget,
       '/providers': apiTarget,
       '/cloud-models': apiTarget,
+      '/ollama': apiTarget,
       '/workspaces': apiTarget,
       '/agent': apiTarget,
       '/learning-stats': apiTarget,